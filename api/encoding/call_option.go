@@ -92,3 +92,29 @@ func (r routingDelegateOption) apply(call *OutboundCall) {
 func WithRoutingDelegate(rd string) CallOption {
 	return CallOption{routingDelegateOption(rd)}
 }
+
+type noRetryOption struct{}
+
+func (noRetryOption) apply(call *OutboundCall) {
+	call.noRetry = true
+}
+
+// WithNoRetry disables retries of the request by the retry middleware,
+// for procedures that are not idempotent.
+func WithNoRetry() CallOption {
+	return CallOption{noRetryOption{}}
+}
+
+type peerOption string
+
+func (r peerOption) apply(call *OutboundCall) {
+	x := string(r)
+	call.peer = &x
+}
+
+// WithPeer sends the request to the peer with the given identifier, like
+// "127.0.0.1:8080", instead of the peer chosen by the peer chooser of the
+// outbound.
+func WithPeer(id string) CallOption {
+	return CallOption{peerOption(id)}
+}
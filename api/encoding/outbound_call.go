@@ -23,7 +23,9 @@ package encoding
 import (
 	"context"
 
+	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/retry"
 	"go.uber.org/yarpc/yarpcerrors"
 )
 
@@ -39,6 +41,12 @@ type OutboundCall struct {
 	routingKey      *string
 	routingDelegate *string
 
+	// noRetry disables retries of unary requests.
+	noRetry bool
+
+	// peer, if non-nil, pins the request to the peer with this identifier.
+	peer *string
+
 	// If non-nil, response headers should be written here.
 	responseHeaders *map[string]string
 }
@@ -80,9 +88,15 @@ func (c *OutboundCall) WriteToRequest(ctx context.Context, req *transport.Reques
 	if c.routingDelegate != nil {
 		req.RoutingDelegate = *c.routingDelegate
 	}
+	if c.noRetry {
+		ctx = retry.WithNoRetry(ctx)
+	}
+	if c.peer != nil {
+		ctx = peer.WithPinnedPeer(ctx, *c.peer)
+	}
 
-	// NB(abg): context and error are unused for now but we want to leave room
-	// for CallOptions which can fail or modify the context.
+	// NB(abg): the error is unused for now but we want to leave room for
+	// CallOptions which can fail.
 	return ctx, nil
 }
 
@@ -104,9 +118,12 @@ func (c *OutboundCall) WriteToRequestMeta(ctx context.Context, reqMeta *transpor
 	if c.routingDelegate != nil {
 		reqMeta.RoutingDelegate = *c.routingDelegate
 	}
+	if c.peer != nil {
+		ctx = peer.WithPinnedPeer(ctx, *c.peer)
+	}
 
-	// NB(abg): context and error are unused for now but we want to leave room
-	// for CallOptions which can fail or modify the context.
+	// NB(abg): the error is unused for now but we want to leave room for
+	// CallOptions which can fail.
 	return ctx, nil
 }
 
@@ -26,6 +26,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 )
 
@@ -159,6 +160,27 @@ func TestOutboundCallWriteToRequestAndRequestMeta(t *testing.T) {
 	}
 }
 
+func TestOutboundCallWithPeer(t *testing.T) {
+	call := NewOutboundCall(WithPeer("127.0.0.1:8080"))
+
+	ctx, err := call.WriteToRequest(context.Background(), &transport.Request{})
+	require.NoError(t, err)
+	id, ok := peer.PinnedPeerFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "127.0.0.1:8080", id)
+
+	ctx, err = call.WriteToRequestMeta(context.Background(), &transport.RequestMeta{})
+	require.NoError(t, err)
+	id, ok = peer.PinnedPeerFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "127.0.0.1:8080", id)
+
+	ctx, err = NewOutboundCall().WriteToRequest(context.Background(), &transport.Request{})
+	require.NoError(t, err)
+	_, ok = peer.PinnedPeerFromContext(ctx)
+	assert.False(t, ok)
+}
+
 func TestOutboundCallReadFromResponse(t *testing.T) {
 	var headers map[string]string
 	call := NewOutboundCall(ResponseHeaders(&headers))
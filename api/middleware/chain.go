@@ -0,0 +1,302 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Constraint positions middleware registered with a Chain relative to other
+// middleware in the chain.
+type Constraint interface {
+	apply(*chainEntry)
+}
+
+// First places middleware before all middleware that are not also placed
+// first.
+var First Constraint = positionConstraint{first: true}
+
+// Last places middleware after all middleware that are not also placed last.
+var Last Constraint = positionConstraint{last: true}
+
+// Before places middleware before the named middleware. Names that are not
+// registered with the chain are ignored, so that middleware may be placed
+// relative to middleware that are optional.
+func Before(names ...string) Constraint {
+	return beforeConstraint(names)
+}
+
+// After places middleware after the named middleware. Names that are not
+// registered with the chain are ignored, so that middleware may be placed
+// relative to middleware that are optional.
+func After(names ...string) Constraint {
+	return afterConstraint(names)
+}
+
+type positionConstraint struct{ first, last bool }
+
+func (c positionConstraint) apply(e *chainEntry) {
+	e.first = e.first || c.first
+	e.last = e.last || c.last
+}
+
+type beforeConstraint []string
+
+func (c beforeConstraint) apply(e *chainEntry) { e.before = append(e.before, c...) }
+
+type afterConstraint []string
+
+func (c afterConstraint) apply(e *chainEntry) { e.after = append(e.after, c...) }
+
+type chainEntry struct {
+	name   string
+	mw     interface{}
+	first  bool
+	last   bool
+	before []string
+	after  []string
+}
+
+// Chain orders named middleware by the constraints they are registered
+// with, rather than by their position in a list.
+//
+// Middleware registered with a chain may implement any combination of
+// UnaryInbound, OnewayInbound, StreamInbound, UnaryOutbound, OnewayOutbound,
+// and StreamOutbound; the chain of each kind holds the middleware that
+// implement it. Earlier middleware in a chain are called first, and wrap the
+// later ones, as with positional chains.
+//
+// Middleware whose order is not determined by their constraints keep the
+// order in which they were registered, so that the order of a chain is
+// deterministic. Register middleware when setting up a dispatcher; a Chain is
+// not safe for concurrent use.
+//
+//	chain := middleware.NewChain()
+//	chain.MustRegister("auth", auth)
+//	chain.MustRegister("audit", audit, middleware.After("auth"))
+//	chain.MustRegister("tracing", tracing, middleware.First)
+type Chain struct {
+	entries []*chainEntry
+	names   map[string]*chainEntry
+}
+
+// NewChain builds an empty middleware chain.
+func NewChain() *Chain {
+	return &Chain{names: make(map[string]*chainEntry)}
+}
+
+// Register adds middleware to the chain under the given name, placed by the
+// given constraints.
+//
+// An error is returned if the name is empty or already registered, if the
+// middleware implements none of the middleware interfaces, or if the
+// constraints place the middleware relative to itself or both first and
+// last. Use MustRegister to panic instead.
+func (c *Chain) Register(name string, mw interface{}, constraints ...Constraint) error {
+	if name == "" {
+		return errors.New("middleware name is required")
+	}
+	if _, ok := c.names[name]; ok {
+		return fmt.Errorf("middleware %q is already registered", name)
+	}
+	if !isMiddleware(mw) {
+		return fmt.Errorf("middleware %q of type %T implements no middleware interface", name, mw)
+	}
+
+	e := &chainEntry{name: name, mw: mw}
+	for _, c := range constraints {
+		c.apply(e)
+	}
+	if e.first && e.last {
+		return fmt.Errorf("middleware %q cannot be both first and last", name)
+	}
+	for _, others := range [][]string{e.before, e.after} {
+		for _, other := range others {
+			if other == name {
+				return fmt.Errorf("middleware %q cannot be ordered relative to itself", name)
+			}
+		}
+	}
+
+	c.entries = append(c.entries, e)
+	c.names[name] = e
+	return nil
+}
+
+// MustRegister adds middleware to the chain, panicking if Register fails.
+func (c *Chain) MustRegister(name string, mw interface{}, constraints ...Constraint) {
+	if err := c.Register(name, mw, constraints...); err != nil {
+		panic(err)
+	}
+}
+
+// Merge registers the middleware of the other chain with this chain, with
+// their constraints, after the middleware already registered with it.
+//
+// An error is returned if both chains register middleware under the same
+// name, in which case this chain is left unchanged.
+func (c *Chain) Merge(other *Chain) error {
+	if other == nil {
+		return nil
+	}
+	for _, e := range other.entries {
+		if _, ok := c.names[e.name]; ok {
+			return fmt.Errorf("middleware %q is already registered", e.name)
+		}
+	}
+	for _, e := range other.entries {
+		copied := *e
+		c.entries = append(c.entries, &copied)
+		c.names[e.name] = &copied
+	}
+	return nil
+}
+
+// Names returns the names of the middleware in the chain in order.
+//
+// An error is returned if the constraints of the middleware conflict.
+func (c *Chain) Names() ([]string, error) {
+	entries, err := c.order()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.name
+	}
+	return names, nil
+}
+
+// Ordered returns the middleware in the chain in order.
+//
+// An error is returned if the constraints of the middleware conflict.
+func (c *Chain) Ordered() ([]interface{}, error) {
+	entries, err := c.order()
+	if err != nil {
+		return nil, err
+	}
+	mws := make([]interface{}, len(entries))
+	for i, e := range entries {
+		mws[i] = e.mw
+	}
+	return mws, nil
+}
+
+// order sorts the entries topologically. Entries are placed in registration
+// order, each after placing those of its predecessors that are not placed
+// yet, so that constraints move as few entries as possible.
+func (c *Chain) order() ([]*chainEntry, error) {
+	n := len(c.entries)
+	index := make(map[string]int, n)
+	for i, e := range c.entries {
+		index[e.name] = i
+	}
+
+	// predecessors[i] are the entries that must come before entry i.
+	predecessors := make([][]int, n)
+	for i, e := range c.entries {
+		for _, name := range e.before {
+			if j, ok := index[name]; ok {
+				predecessors[j] = append(predecessors[j], i)
+			}
+		}
+		for _, name := range e.after {
+			if j, ok := index[name]; ok {
+				predecessors[i] = append(predecessors[i], j)
+			}
+		}
+		for j, other := range c.entries {
+			if e.first && !other.first {
+				predecessors[j] = append(predecessors[j], i)
+			}
+			if e.last && !other.last {
+				predecessors[i] = append(predecessors[i], j)
+			}
+		}
+	}
+	for _, preds := range predecessors {
+		sort.Ints(preds)
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		placed
+	)
+	state := make([]int, n)
+	ordered := make([]*chainEntry, 0, n)
+	var path []int // entries being visited, each a successor of the previous
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case placed:
+			return nil
+		case visiting:
+			return c.cycleError(path, i)
+		}
+		state[i] = visiting
+		path = append(path, i)
+		for _, j := range predecessors[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = placed
+		ordered = append(ordered, c.entries[i])
+		return nil
+	}
+
+	for i := range c.entries {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// cycleError describes the cycle closed by visiting entry i again. The path
+// leads from entries to their predecessors, against the order of the chain.
+func (c *Chain) cycleError(path []int, i int) error {
+	start := 0
+	for path[start] != i {
+		start++
+	}
+	cycle := []string{c.entries[i].name}
+	for k := len(path) - 1; k > start; k-- {
+		cycle = append(cycle, c.entries[path[k]].name)
+	}
+	cycle = append(cycle, c.entries[i].name)
+	return fmt.Errorf("conflicting middleware order constraints: %v", strings.Join(cycle, " -> "))
+}
+
+func isMiddleware(mw interface{}) bool {
+	switch mw.(type) {
+	case UnaryInbound, OnewayInbound, StreamInbound, UnaryOutbound, OnewayOutbound, StreamOutbound:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package middleware_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/middleware"
+)
+
+func TestChainOrder(t *testing.T) {
+	type registration struct {
+		name        string
+		constraints []middleware.Constraint
+	}
+
+	tests := []struct {
+		desc    string
+		give    []registration
+		want    []string
+		wantErr string
+	}{
+		{
+			desc: "empty",
+			want: []string{},
+		},
+		{
+			desc: "registration order",
+			give: []registration{{name: "a"}, {name: "b"}, {name: "c"}},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			desc: "before",
+			give: []registration{
+				{name: "observability"},
+				{name: "auth"},
+				{name: "tracing", constraints: []middleware.Constraint{middleware.Before("observability")}},
+			},
+			want: []string{"tracing", "observability", "auth"},
+		},
+		{
+			desc: "after",
+			give: []registration{
+				{name: "audit", constraints: []middleware.Constraint{middleware.After("auth")}},
+				{name: "auth"},
+				{name: "other"},
+			},
+			want: []string{"auth", "audit", "other"},
+		},
+		{
+			desc: "first and last",
+			give: []registration{
+				{name: "a"},
+				{name: "z", constraints: []middleware.Constraint{middleware.Last}},
+				{name: "b"},
+				{name: "y", constraints: []middleware.Constraint{middleware.First}},
+				{name: "x", constraints: []middleware.Constraint{middleware.First}},
+			},
+			want: []string{"y", "x", "a", "b", "z"},
+		},
+		{
+			desc: "constraints among first",
+			give: []registration{
+				{name: "y", constraints: []middleware.Constraint{middleware.First}},
+				{name: "x", constraints: []middleware.Constraint{middleware.First, middleware.Before("y")}},
+				{name: "a"},
+			},
+			want: []string{"x", "y", "a"},
+		},
+		{
+			desc: "unknown names are ignored",
+			give: []registration{
+				{name: "a", constraints: []middleware.Constraint{middleware.After("missing")}},
+				{name: "b", constraints: []middleware.Constraint{middleware.Before("missing", "a")}},
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			desc: "chained constraints",
+			give: []registration{
+				{name: "c", constraints: []middleware.Constraint{middleware.After("b")}},
+				{name: "b", constraints: []middleware.Constraint{middleware.After("a")}},
+				{name: "d"},
+				{name: "a"},
+			},
+			want: []string{"a", "b", "c", "d"},
+		},
+		{
+			desc: "cycle",
+			give: []registration{
+				{name: "a", constraints: []middleware.Constraint{middleware.Before("b")}},
+				{name: "b", constraints: []middleware.Constraint{middleware.Before("c")}},
+				{name: "c", constraints: []middleware.Constraint{middleware.Before("a")}},
+				{name: "d"},
+			},
+			wantErr: "conflicting middleware order constraints: a -> b -> c -> a",
+		},
+		{
+			desc: "first after middleware that is not first",
+			give: []registration{
+				{name: "a"},
+				{name: "b", constraints: []middleware.Constraint{middleware.First, middleware.After("a")}},
+			},
+			wantErr: "conflicting middleware order constraints: a -> b -> a",
+		},
+		{
+			desc: "before last and after first",
+			give: []registration{
+				{name: "first", constraints: []middleware.Constraint{middleware.First}},
+				{name: "last", constraints: []middleware.Constraint{middleware.Last, middleware.Before("first")}},
+			},
+			wantErr: "conflicting middleware order constraints: first -> last -> first",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			chain := middleware.NewChain()
+			for _, r := range tt.give {
+				require.NoError(t, chain.Register(r.name, middleware.NopUnaryInbound, r.constraints...))
+			}
+
+			got, err := chain.Names()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantErr, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// The order must not depend on anything but the registrations.
+			again, err := chain.Names()
+			require.NoError(t, err)
+			assert.Equal(t, got, again)
+		})
+	}
+}
+
+func TestChainRegisterErrors(t *testing.T) {
+	chain := middleware.NewChain()
+	chain.MustRegister("a", middleware.NopUnaryOutbound)
+
+	tests := []struct {
+		desc        string
+		name        string
+		mw          interface{}
+		constraints []middleware.Constraint
+		wantErr     string
+	}{
+		{
+			desc:    "empty name",
+			mw:      middleware.NopUnaryInbound,
+			wantErr: "middleware name is required",
+		},
+		{
+			desc:    "duplicate name",
+			name:    "a",
+			mw:      middleware.NopUnaryInbound,
+			wantErr: `middleware "a" is already registered`,
+		},
+		{
+			desc:    "not middleware",
+			name:    "b",
+			mw:      "foo",
+			wantErr: `middleware "b" of type string implements no middleware interface`,
+		},
+		{
+			desc:    "nil",
+			name:    "b",
+			wantErr: `middleware "b" of type <nil> implements no middleware interface`,
+		},
+		{
+			desc:        "first and last",
+			name:        "b",
+			mw:          middleware.NopUnaryInbound,
+			constraints: []middleware.Constraint{middleware.First, middleware.Last},
+			wantErr:     `middleware "b" cannot be both first and last`,
+		},
+		{
+			desc:        "after itself",
+			name:        "b",
+			mw:          middleware.NopUnaryInbound,
+			constraints: []middleware.Constraint{middleware.After("a", "b")},
+			wantErr:     `middleware "b" cannot be ordered relative to itself`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := chain.Register(tt.name, tt.mw, tt.constraints...)
+			require.Error(t, err)
+			assert.Equal(t, tt.wantErr, err.Error())
+		})
+	}
+
+	names, err := chain.Names()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, names, "failed registrations must not change the chain")
+
+	assert.Panics(t, func() { chain.MustRegister("a", middleware.NopUnaryInbound) })
+}
+
+func TestChainMerge(t *testing.T) {
+	chain := middleware.NewChain()
+	chain.MustRegister("legacy", middleware.NopUnaryInbound)
+
+	other := middleware.NewChain()
+	other.MustRegister("audit", middleware.NopOnewayInbound, middleware.After("auth"))
+	other.MustRegister("auth", middleware.NopStreamInbound)
+	other.MustRegister("tracing", middleware.NopUnaryOutbound, middleware.First)
+
+	require.NoError(t, chain.Merge(other))
+	require.NoError(t, chain.Merge(nil))
+
+	names, err := chain.Names()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tracing", "legacy", "auth", "audit"}, names)
+
+	ordered, err := chain.Ordered()
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		middleware.NopUnaryOutbound,
+		middleware.NopUnaryInbound,
+		middleware.NopStreamInbound,
+		middleware.NopOnewayInbound,
+	}, ordered)
+
+	err = chain.Merge(other)
+	require.Error(t, err)
+	assert.Equal(t, `middleware "audit" is already registered`, err.Error())
+	names, err = chain.Names()
+	require.NoError(t, err)
+	assert.Len(t, names, 4, "failed merges must not change the chain")
+}
@@ -142,6 +142,23 @@ type StreamInbound interface {
 	HandleStream(s *transport.ServerStream, h transport.StreamHandler) error
 }
 
+// StreamMessageInbound is an optional interface for StreamInbound middleware
+// that intercepts the individual messages of the stream, for example to
+// account for their size or transform their payload.
+//
+// The dispatcher invokes HandleMessage for every message the handler
+// receives and SendMessage for every message the handler sends, with the
+// stream of the next middleware closer to the transport. The messages pass
+// through the middleware in the same order as the stream establishment:
+// received messages reach the outermost middleware first, and sent messages
+// reach it last.
+//
+// StreamMessageInbound middleware MUST be thread-safe.
+type StreamMessageInbound interface {
+	HandleMessage(ctx context.Context, next transport.Stream) (*transport.StreamMessage, error)
+	SendMessage(ctx context.Context, msg *transport.StreamMessage, next transport.Stream) error
+}
+
 // NopStreamInbound is an inbound middleware that does not do
 // anything special. It simply calls the underlying StreamHandler.
 var NopStreamInbound StreamInbound = nopStreamInbound{}
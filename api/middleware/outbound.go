@@ -23,6 +23,7 @@ package middleware
 import (
 	"context"
 
+	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/x/introspection"
 )
@@ -109,6 +110,13 @@ func (fo unaryOutboundWithMiddleware) Introspect() introspection.OutboundStatus
 	return introspection.OutboundStatusNotSupported
 }
 
+func (fo unaryOutboundWithMiddleware) SubscribePeerStatus(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func(), ok bool) {
+	if o, ok := fo.o.(introspection.PeerStatusWatchableOutbound); ok {
+		return o.SubscribePeerStatus(fn)
+	}
+	return nil, false
+}
+
 func (fo unaryOutboundWithMiddleware) Call(ctx context.Context, request *transport.Request) (*transport.Response, error) {
 	return fo.f.Call(ctx, request, fo.o)
 }
@@ -198,6 +206,13 @@ func (fo onewayOutboundWithMiddleware) Introspect() introspection.OutboundStatus
 	return introspection.OutboundStatusNotSupported
 }
 
+func (fo onewayOutboundWithMiddleware) SubscribePeerStatus(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func(), ok bool) {
+	if o, ok := fo.o.(introspection.PeerStatusWatchableOutbound); ok {
+		return o.SubscribePeerStatus(fn)
+	}
+	return nil, false
+}
+
 type nopOnewayOutbound struct{}
 
 func (nopOnewayOutbound) CallOneway(ctx context.Context, request *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
@@ -220,6 +235,22 @@ type StreamOutbound interface {
 	CallStream(ctx context.Context, request *transport.StreamRequest, out transport.StreamOutbound) (*transport.ClientStream, error)
 }
 
+// StreamMessageOutbound is an optional interface for StreamOutbound
+// middleware that intercepts the individual messages of the stream, for
+// example to account for their size or transform their payload.
+//
+// The dispatcher invokes SendMessage for every message the caller sends and
+// ReceiveMessage for every message the caller receives, with the stream of
+// the next middleware closer to the transport. The messages pass through the
+// middleware in the same order as the stream establishment: sent messages
+// reach the outermost middleware first, and received messages reach it last.
+//
+// StreamMessageOutbound middleware MUST be thread-safe.
+type StreamMessageOutbound interface {
+	SendMessage(ctx context.Context, msg *transport.StreamMessage, next transport.Stream) error
+	ReceiveMessage(ctx context.Context, next transport.Stream) (*transport.StreamMessage, error)
+}
+
 // NopStreamOutbound is a stream outbound middleware that does not do
 // anything special. It simply calls the underlying StreamOutbound.
 var NopStreamOutbound StreamOutbound = nopStreamOutbound{}
@@ -273,6 +304,13 @@ func (fo streamOutboundWithMiddleware) IsRunning() bool {
 	return fo.o.IsRunning()
 }
 
+func (fo streamOutboundWithMiddleware) Introspect() introspection.OutboundStatus {
+	if o, ok := fo.o.(introspection.IntrospectableOutbound); ok {
+		return o.Introspect()
+	}
+	return introspection.OutboundStatusNotSupported
+}
+
 func (fo streamOutboundWithMiddleware) CallStream(ctx context.Context, request *transport.StreamRequest) (*transport.ClientStream, error) {
 	return fo.f.CallStream(ctx, request, fo.o)
 }
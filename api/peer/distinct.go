@@ -0,0 +1,85 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peer
+
+import (
+	"context"
+	"sync"
+)
+
+type distinctPeersKey struct{}
+
+// DistinctPeers records the peers chosen for the calls made with a context
+// from WithDistinctPeers, so that peer lists may choose a different peer for
+// each of them.
+//
+// Peer lists that honor the hint check whether a peer was already chosen
+// before choosing it, and record the peer they choose. Other peer lists
+// ignore it.
+type DistinctPeers struct {
+	mu     sync.Mutex
+	chosen map[string]struct{}
+}
+
+// WithDistinctPeers returns a context that asks peer lists to choose a
+// different peer for every call made with it, or with a context derived
+// from it, when they can.
+//
+// This is useful for middleware that sends several attempts of the same
+// request, like hedging, where an attempt sent to the same peer as another
+// is unlikely to finish sooner.
+func WithDistinctPeers(ctx context.Context) context.Context {
+	return context.WithValue(ctx, distinctPeersKey{}, &DistinctPeers{})
+}
+
+// DistinctPeersFromContext returns the DistinctPeers of the context, or nil
+// if the context is not from WithDistinctPeers.
+//
+// The methods of a nil DistinctPeers report that no peer was chosen.
+func DistinctPeersFromContext(ctx context.Context) *DistinctPeers {
+	d, _ := ctx.Value(distinctPeersKey{}).(*DistinctPeers)
+	return d
+}
+
+// Chosen reports whether the peer was chosen for a call made with the
+// context.
+func (d *DistinctPeers) Chosen(id Identifier) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.chosen[id.Identifier()]
+	return ok
+}
+
+// Choose records that the peer was chosen for a call made with the context.
+func (d *DistinctPeers) Choose(id Identifier) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.chosen == nil {
+		d.chosen = make(map[string]struct{})
+	}
+	d.chosen[id.Identifier()] = struct{}{}
+}
@@ -60,6 +60,22 @@ type ChooserList interface {
 	List
 }
 
+// StatusWatcher is a peer list that reports changes to the connection status
+// of its peers.
+type StatusWatcher interface {
+	// Subscribe registers a function to call with the identifier and
+	// connection status of each peer that is added to the list, removed from
+	// the list, or changes status while in the list.
+	// Removed peers are reported as Unavailable.
+	// The returned function cancels the subscription.
+	//
+	// Updates for each peer are delivered in order, but never concurrently and
+	// never while the list holds its lock, so the function may block.
+	// Updates for a peer that arrive while the function is busy are coalesced
+	// into the latest status of that peer.
+	Subscribe(func(Identifier, ConnectionStatus)) (unsubscribe func())
+}
+
 // ListImplementation is a collection of available peers, with its own
 // subscribers for peer status change notifications.
 // The available peer list encapsulates the logic for selecting from among
@@ -48,6 +48,32 @@ type Identifier interface {
 	Identifier() string
 }
 
+// WeightedIdentifier is an Identifier that also carries the relative weight
+// of the peer, typically provided by a peer list updater.
+//
+// Peer lists that balance load by weight may check whether an Identifier
+// implements this interface. Other peer lists treat it as a plain Identifier.
+type WeightedIdentifier interface {
+	Identifier
+
+	// Weight returns the relative weight of the peer.
+	Weight() int
+}
+
+// LimitedIdentifier is an Identifier that also carries the maximum number of
+// requests that may be pending to the peer at once.
+//
+// Peer lists that limit pending requests per peer may check whether an
+// Identifier implements this interface, in favor of their own limit. Other
+// peer lists treat it as a plain Identifier.
+type LimitedIdentifier interface {
+	Identifier
+
+	// MaxPendingRequests returns the maximum number of pending requests to
+	// the peer, or zero for the limit of the peer list.
+	MaxPendingRequests() int
+}
+
 // StatusPeer captures a concrete peer implementation for a particular
 // transport, exposing its Identifier and Status.
 // StatusPeer provides observability without mutability.
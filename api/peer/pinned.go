@@ -0,0 +1,43 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peer
+
+import "context"
+
+type pinnedPeerKey struct{}
+
+// WithPinnedPeer returns a context that asks peer choosers to send the calls
+// made with it, or with a context derived from it, to the peer with the
+// given identifier, like "127.0.0.1:8080", rather than choosing a peer.
+//
+// Peer choosers that honor the hint return a NotFound error if the peer is
+// not among their peers. Other peer choosers ignore it.
+func WithPinnedPeer(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, pinnedPeerKey{}, id)
+}
+
+// PinnedPeerFromContext returns the identifier of the peer that the context
+// from WithPinnedPeer pins calls to, and whether the context pins calls to a
+// peer.
+func PinnedPeerFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(pinnedPeerKey{}).(string)
+	return id, ok
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport
+
+import "context"
+
+// BidiStream is one end of a bidirectional stream. Both ends of the stream
+// send and receive messages with the same methods, independently of each
+// other: one goroutine may Send while another calls Recv.
+type BidiStream interface {
+	// Context returns the context for the stream.
+	Context() context.Context
+
+	// Request contains all the metadata about the request.
+	Request() *StreamRequest
+
+	// Send sends a message over the stream. It blocks until the message has
+	// been sent.
+	Send(msg *StreamMessage) error
+
+	// Recv blocks until a message is received from the other end of the
+	// stream. It returns io.EOF once the other end has finished sending.
+	Recv() (*StreamMessage, error)
+}
+
+// BidiClientStream is the client end of a bidirectional stream.
+type BidiClientStream interface {
+	BidiStream
+
+	// CloseSend signals to the server that the client has finished sending.
+	// The client may continue to receive messages until Recv returns io.EOF.
+	CloseSend() error
+}
+
+// BidiStreamHandler handles a bidirectional stream.
+type BidiStreamHandler interface {
+	// HandleBidiStream handles the given stream. The stream will close when
+	// the function returns.
+	//
+	// An error may be returned in case of failures.
+	HandleBidiStream(stream BidiStream) error
+}
+
+// BidiStreamHandlerFunc adapts a function into a BidiStreamHandler.
+type BidiStreamHandlerFunc func(BidiStream) error
+
+// HandleBidiStream for BidiStreamHandlerFunc.
+func (f BidiStreamHandlerFunc) HandleBidiStream(stream BidiStream) error {
+	return f(stream)
+}
+
+// BidiStreamOutbound is a transport that knows how to open bidirectional
+// streams for procedure calls.
+type BidiStreamOutbound interface {
+	Outbound
+
+	// CallBidiStream opens a bidirectional stream based on the metadata in
+	// the request passed in. If there is a timeout on the context, this
+	// timeout is for establishing the stream, and not for its lifetime.
+	CallBidiStream(ctx context.Context, request *StreamRequest) (BidiClientStream, error)
+}
+
+// NewBidiStreamHandlerSpec returns a new HandlerSpec with a
+// BidiStreamHandler.
+//
+// Bidirectional stream handlers are served as Streaming handlers, so that
+// stream middleware and every transport that supports streaming apply to
+// them.
+func NewBidiStreamHandlerSpec(handler BidiStreamHandler) HandlerSpec {
+	return NewStreamHandlerSpec(bidiStreamHandler{handler})
+}
+
+type bidiStreamHandler struct {
+	h BidiStreamHandler
+}
+
+func (h bidiStreamHandler) HandleStream(stream *ServerStream) error {
+	return h.h.HandleBidiStream(serverBidiStream{stream})
+}
+
+type serverBidiStream struct {
+	stream *ServerStream
+}
+
+func (s serverBidiStream) Context() context.Context {
+	return s.stream.Context()
+}
+
+func (s serverBidiStream) Request() *StreamRequest {
+	return s.stream.Request()
+}
+
+func (s serverBidiStream) Send(msg *StreamMessage) error {
+	return s.stream.SendMessage(s.stream.Context(), msg)
+}
+
+func (s serverBidiStream) Recv() (*StreamMessage, error) {
+	return s.stream.ReceiveMessage(s.stream.Context())
+}
+
+// NewBidiClientStream adapts a ClientStream into the client end of a
+// bidirectional stream.
+func NewBidiClientStream(stream *ClientStream) BidiClientStream {
+	return clientBidiStream{stream}
+}
+
+type clientBidiStream struct {
+	stream *ClientStream
+}
+
+func (s clientBidiStream) Context() context.Context {
+	return s.stream.Context()
+}
+
+func (s clientBidiStream) Request() *StreamRequest {
+	return s.stream.Request()
+}
+
+func (s clientBidiStream) Send(msg *StreamMessage) error {
+	return s.stream.SendMessage(s.stream.Context(), msg)
+}
+
+func (s clientBidiStream) Recv() (*StreamMessage, error) {
+	return s.stream.ReceiveMessage(s.stream.Context())
+}
+
+func (s clientBidiStream) CloseSend() error {
+	return s.stream.Close(s.stream.Context())
+}
+
+// AsBidiStreamOutbound returns the given StreamOutbound as a
+// BidiStreamOutbound. Outbounds that do not implement BidiStreamOutbound,
+// like the stream outbounds of client configurations that carry middleware,
+// open bidirectional streams through CallStream.
+func AsBidiStreamOutbound(o StreamOutbound) BidiStreamOutbound {
+	if bo, ok := o.(BidiStreamOutbound); ok {
+		return bo
+	}
+	return bidiStreamOutbound{o}
+}
+
+type bidiStreamOutbound struct {
+	StreamOutbound
+}
+
+func (o bidiStreamOutbound) CallBidiStream(ctx context.Context, request *StreamRequest) (BidiClientStream, error) {
+	stream, err := o.CallStream(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return NewBidiClientStream(stream), nil
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+)
+
+func TestBidiStreamHandlerSpec(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.Background()
+	msg := &transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewBufferString("hello"))}
+	mockStream := transporttest.NewMockStream(mockCtrl)
+	mockStream.EXPECT().Context().Return(ctx).AnyTimes()
+	gomock.InOrder(
+		mockStream.EXPECT().ReceiveMessage(ctx).Return(msg, nil),
+		mockStream.EXPECT().SendMessage(ctx, msg).Return(nil),
+		mockStream.EXPECT().ReceiveMessage(ctx).Return(nil, io.EOF),
+	)
+
+	spec := transport.NewBidiStreamHandlerSpec(transport.BidiStreamHandlerFunc(
+		func(stream transport.BidiStream) error {
+			for {
+				msg, err := stream.Recv()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if err := stream.Send(msg); err != nil {
+					return err
+				}
+			}
+		}))
+	require.Equal(t, transport.Streaming, spec.Type())
+
+	serverStream, err := transport.NewServerStream(mockStream)
+	require.NoError(t, err)
+	assert.NoError(t, spec.Stream().HandleStream(serverStream))
+}
+
+func TestAsBidiStreamOutbound(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.Background()
+	req := &transport.StreamRequest{Meta: &transport.RequestMeta{Procedure: "chat"}}
+	msg := &transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewBufferString("hello"))}
+
+	mockStream := transporttest.NewMockStreamCloser(mockCtrl)
+	mockStream.EXPECT().Context().Return(ctx).AnyTimes()
+	mockStream.EXPECT().Request().Return(req)
+	gomock.InOrder(
+		mockStream.EXPECT().SendMessage(ctx, msg).Return(nil),
+		mockStream.EXPECT().ReceiveMessage(ctx).Return(msg, nil),
+		mockStream.EXPECT().Close(ctx).Return(nil),
+	)
+	clientStream, err := transport.NewClientStream(mockStream)
+	require.NoError(t, err)
+
+	mockOutbound := transporttest.NewMockStreamOutbound(mockCtrl)
+	mockOutbound.EXPECT().CallStream(ctx, req).Return(clientStream, nil)
+
+	stream, err := transport.AsBidiStreamOutbound(mockOutbound).CallBidiStream(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, req, stream.Request())
+	require.NoError(t, stream.Send(msg))
+	got, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, msg, got)
+	assert.NoError(t, stream.CloseSend())
+}
+
+func TestGetBidiStreamOutbound(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cfg := &transport.OutboundConfig{Outbounds: transport.Outbounds{ServiceName: "chat"}}
+	assert.Panics(t, func() { cfg.GetBidiStreamOutbound() })
+
+	cfg.Outbounds.Stream = transporttest.NewMockStreamOutbound(mockCtrl)
+	assert.NotNil(t, cfg.GetBidiStreamOutbound())
+}
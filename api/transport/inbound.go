@@ -20,6 +20,8 @@
 
 package transport
 
+import "context"
+
 // Inbound is a transport that knows how to receive requests for procedure
 // calls.
 type Inbound interface {
@@ -36,3 +38,15 @@ type Inbound interface {
 	// An inbound may submit zero or more transports.
 	Transports() []Transport
 }
+
+// GracefulStopper is an additional interface that Inbounds may implement to
+// bound the time they spend draining the requests in flight when they stop.
+//
+// This interface is not embedded into Inbound to preserve backwards
+// compatibility.
+type GracefulStopper interface {
+	// GracefulStop stops the inbound like Stop, waiting for the requests in
+	// flight to complete until the context is done, and then closing the
+	// connections that remain.
+	GracefulStop(ctx context.Context) error
+}
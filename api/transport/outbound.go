@@ -40,10 +40,11 @@ type Outbound interface {
 }
 
 // Namer is an additional interface that Outbounds may implement in order
-// properly set the transport.Request#Transport field.
+// properly set the transport.Request#Transport field. Inbounds may implement
+// it to tell the transport they receive requests with.
 //
-// This interface is not embeded into Outbound to preserve backwards
-// compatiblity.
+// This interface is not embeded into Outbound or Inbound to preserve
+// backwards compatiblity.
 type Namer interface {
 	TransportName() string
 }
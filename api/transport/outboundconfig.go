@@ -75,3 +75,12 @@ func (o *OutboundConfig) GetOnewayOutbound() OnewayOutbound {
 	}
 	return o.Outbounds.Oneway
 }
+
+// GetBidiStreamOutbound returns an outbound to open bidirectional streams
+// through or panics if there is no stream outbound for this service.
+func (o *OutboundConfig) GetBidiStreamOutbound() BidiStreamOutbound {
+	if o.Outbounds.Stream == nil {
+		panic(fmt.Sprintf("service %q does not have a stream outbound", o.Outbounds.ServiceName))
+	}
+	return AsBidiStreamOutbound(o.Outbounds.Stream)
+}
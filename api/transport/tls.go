@@ -0,0 +1,45 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+)
+
+type tlsConnectionStateKey struct{}
+
+// WithTLSConnectionState returns a context that carries the state of the TLS
+// connection that a request arrived on.
+//
+// Inbounds that accept TLS connections call this so that handlers and
+// middleware may identify their peers.
+func WithTLSConnectionState(ctx context.Context, state *tls.ConnectionState) context.Context {
+	return context.WithValue(ctx, tlsConnectionStateKey{}, state)
+}
+
+// TLSConnectionStateFromContext returns the state of the TLS connection that
+// the request of the context arrived on, or nil if the request did not
+// arrive over TLS or its inbound does not report TLS connections.
+func TLSConnectionStateFromContext(ctx context.Context) *tls.ConnectionState {
+	state, _ := ctx.Value(tlsConnectionStateKey{}).(*tls.ConnectionState)
+	return state
+}
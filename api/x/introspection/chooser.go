@@ -30,6 +30,14 @@ type ChooserStatus struct {
 	Name  string       `json:"name"`
 	State string       `json:"state"`
 	Peers []PeerStatus `json:"peers"`
+
+	// Config holds the resolved configuration attributes of the chooser,
+	// with their defaults applied, keyed as in YARPC configuration.
+	Config map[string]interface{} `json:"config,omitempty"`
+
+	// Choosers are the statuses of the choosers that the chooser is
+	// composed of, if any, in order.
+	Choosers []ChooserStatus `json:"choosers,omitempty"`
 }
 
 // PeerStatus is a collection of basic peers info.
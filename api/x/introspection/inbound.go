@@ -30,4 +30,8 @@ type InboundStatus struct {
 	Transport string `json:"transport"`
 	Endpoint  string `json:"endpoint"`
 	State     string `json:"state"`
+
+	// Config holds the resolved configuration attributes of the inbound,
+	// with their defaults applied, keyed as in YARPC configuration.
+	Config map[string]interface{} `json:"config,omitempty"`
 }
@@ -20,11 +20,22 @@
 
 package introspection
 
+import "go.uber.org/yarpc/api/peer"
+
 // IntrospectableOutbound extends the Outbound interface.
 type IntrospectableOutbound interface {
 	Introspect() OutboundStatus
 }
 
+// PeerStatusWatchableOutbound extends the Outbound interface for outbounds
+// whose peer chooser reports changes to the status of its peers.
+type PeerStatusWatchableOutbound interface {
+	// SubscribePeerStatus subscribes to the peer status changes of the
+	// chooser of the outbound, returning false if the chooser does not
+	// implement peer.StatusWatcher.
+	SubscribePeerStatus(func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func(), ok bool)
+}
+
 // OutboundStatus is a collection of basics info about an Outbound.
 type OutboundStatus struct {
 	Transport   string        `json:"transport"`
@@ -34,6 +45,15 @@ type OutboundStatus struct {
 	Chooser     ChooserStatus `json:"chooser"`
 	Service     string        `json:"service"`
 	OutboundKey string        `json:"outboundkey"`
+
+	// Middleware are the names of the named outbound middleware of the
+	// outbound, outermost first, excluding the middleware the dispatcher
+	// adds itself.
+	Middleware []string `json:"middleware,omitempty"`
+
+	// Config holds the resolved configuration attributes of the outbound,
+	// with their defaults applied, keyed as in YARPC configuration.
+	Config map[string]interface{} `json:"config,omitempty"`
 }
 
 // OutboundStatusNotSupported is returned when not valid OutboundStatus can be
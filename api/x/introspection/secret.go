@@ -0,0 +1,45 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package introspection
+
+// Redacted replaces the value of a Secret wherever it is printed or
+// serialized.
+const Redacted = "[REDACTED]"
+
+// Secret marks the value of a configuration attribute that holds a secret,
+// so that it is redacted whatever the name of the attribute is.
+//
+//	status.Config["signingKeyPEM"] = introspection.Secret(pem)
+//
+// Attributes are otherwise redacted by their names, which is only a guess:
+// components should report secrets under names that do not look like one as
+// a Secret.
+type Secret string
+
+// String returns Redacted.
+func (Secret) String() string { return Redacted }
+
+// GoString returns Redacted.
+func (Secret) GoString() string { return Redacted }
+
+// MarshalText marshals the secret as Redacted, so that it never appears in
+// JSON or YAML documents.
+func (Secret) MarshalText() ([]byte, error) { return []byte(Redacted), nil }
@@ -0,0 +1,39 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpc
+
+import "go.uber.org/yarpc/api/transport"
+
+// BidiStreamProcedure builds a procedure for a bidirectional stream handler,
+// to register with a Dispatcher.
+//
+//  dispatcher.Register([]transport.Procedure{
+//  	yarpc.BidiStreamProcedure("chat", transport.BidiStreamHandlerFunc(chat)),
+//  })
+//
+// The procedure handles streams of any encoding. Use the Encoding and
+// Service fields of the returned procedure to narrow it down.
+func BidiStreamProcedure(name string, handler transport.BidiStreamHandler) transport.Procedure {
+	return transport.Procedure{
+		Name:        name,
+		HandlerSpec: transport.NewBidiStreamHandlerSpec(handler),
+	}
+}
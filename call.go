@@ -81,6 +81,23 @@ func WithRoutingDelegate(rd string) CallOption {
 	return CallOption(encoding.WithRoutingDelegate(rd))
 }
 
+// WithNoRetry disables retries of the request, for procedures that are
+// not idempotent.
+//
+// 	_, err := client.Charge(ctx, req, yarpc.WithNoRetry())
+func WithNoRetry() CallOption {
+	return CallOption(encoding.WithNoRetry())
+}
+
+// WithPeer sends the request to the peer with the given host:port instead of
+// the peer chosen by the peer list of the outbound. The call fails with a
+// NotFound error if the peer is not in the peer list.
+//
+// 	_, err := client.GetValue(ctx, req, yarpc.WithPeer("127.0.0.1:8080"))
+func WithPeer(peer string) CallOption {
+	return CallOption(encoding.WithPeer(peer))
+}
+
 // Call provides information about the current request inside handlers. An
 // instance of Call for the current request can be obtained by calling
 // CallFromContext on the request context.
@@ -0,0 +1,89 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/yarpc/internal/callgraph"
+)
+
+// CallGraph is the graph of the outbound calls made with a context from
+// InstrumentCallGraph.
+type CallGraph struct {
+	// Edges are the calls, in the order they finished.
+	Edges []CallGraphEdge
+}
+
+// CallGraphEdge is a call from a procedure of a service to a procedure of
+// another, as "service/procedure". Calls made outside of a handler are from
+// the service alone.
+type CallGraphEdge struct {
+	From    string
+	To      string
+	Latency time.Duration
+}
+
+// InstrumentCallGraph returns a context that records the unary and oneway
+// calls made through a Dispatcher with it, and with the contexts derived from
+// it, in a call graph.
+//
+//	ctx = yarpc.InstrumentCallGraph(ctx)
+//	res, err := client.Get(ctx, req)
+//	fmt.Println(yarpc.CallGraphFromContext(ctx).DOT())
+//
+// Only the calls made by the current process are recorded.
+func InstrumentCallGraph(ctx context.Context) context.Context {
+	return callgraph.WithGraph(ctx)
+}
+
+// CallGraphFromContext returns the calls recorded so far for a context from
+// InstrumentCallGraph. The graph is empty for other contexts.
+func CallGraphFromContext(ctx context.Context) CallGraph {
+	g := callgraph.FromContext(ctx)
+	if g == nil {
+		return CallGraph{}
+	}
+
+	edges := g.Edges()
+	graph := CallGraph{Edges: make([]CallGraphEdge, len(edges))}
+	for i, e := range edges {
+		graph.Edges[i] = CallGraphEdge{From: e.From, To: e.To, Latency: e.Latency}
+	}
+	return graph
+}
+
+// DOT renders the call graph in the DOT language of Graphviz, labeling each
+// call with its latency.
+func (g CallGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph calls {\n")
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n",
+			strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(e.Latency.String()))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
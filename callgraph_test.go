@@ -0,0 +1,47 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallGraphDOT(t *testing.T) {
+	g := CallGraph{Edges: []CallGraphEdge{
+		{From: "foo/fanout", To: "bar/a", Latency: 12 * time.Millisecond},
+		{From: "foo", To: `bar/"quoted"`, Latency: time.Second},
+	}}
+	assert.Equal(t, `digraph calls {
+	"foo/fanout" -> "bar/a" [label="12ms"];
+	"foo" -> "bar/\"quoted\"" [label="1s"];
+}
+`, g.DOT())
+	assert.Equal(t, "digraph calls {\n}\n", CallGraph{}.DOT())
+}
+
+func TestCallGraphFromContext(t *testing.T) {
+	assert.Equal(t, CallGraph{}, CallGraphFromContext(context.Background()))
+	assert.Empty(t, CallGraphFromContext(InstrumentCallGraph(context.Background())).Edges)
+}
@@ -22,14 +22,23 @@ package yarpc
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/uber-go/tally"
 	"go.uber.org/net/metrics"
 	"go.uber.org/net/metrics/tallypush"
+	"go.uber.org/yarpc/api/backoff"
 	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/deadline"
 	"go.uber.org/yarpc/internal/observability"
+	"go.uber.org/yarpc/internal/propagation"
+	"go.uber.org/yarpc/internal/ratelimit"
+	"go.uber.org/yarpc/internal/retry"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpcrecovery"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -117,6 +126,37 @@ type LoggingConfig struct {
 
 	// Levels configures the levels at which YARPC logs various messages.
 	Levels LogLevelConfig
+
+	// Redaction scrubs request headers and error messages before YARPC logs
+	// them.
+	Redaction LogRedactionConfig
+}
+
+// LogRedactionConfig configures how the observability middleware scrubs
+// request headers and error messages before logging them, whether calls
+// succeed, fail, or fail with an application error.
+type LogRedactionConfig struct {
+	// LogHeaders logs the headers of inbound requests and outbound calls
+	// under the "headers" key, after redaction.
+	// Headers are not logged by default.
+	LogHeaders bool
+
+	// AllowedHeaders, if non-empty, limits the logged headers to these.
+	AllowedHeaders []string
+
+	// DeniedHeaders are never logged, even if the HeaderTransformer returns
+	// them.
+	DeniedHeaders []string
+
+	// HeaderTransformer, if supplied, rewrites the headers of requests to a
+	// procedure before they are logged. It receives a copy of the headers
+	// with lower-case keys and may return a new map or modify the copy.
+	HeaderTransformer func(procedure string, headers map[string]string) map[string]string
+
+	// ErrorMessageScrubber, if supplied, rewrites error messages, including
+	// the names and details of application errors, of calls to a procedure
+	// before they are logged.
+	ErrorMessageScrubber func(procedure string, message string) string
 }
 
 func (c LoggingConfig) logger(name string) *zap.Logger {
@@ -157,6 +197,79 @@ type MetricsConfig struct {
 	// TagsBlocklist enlists tags' keys that should be suppressed from all the metrics
 	// emitted from w/in YARPC middleware.
 	TagsBlocklist []string
+	// LatencyBuckets are the upper bounds of the buckets of the latency
+	// histograms of RPCs, like success_latency_ms, in ascending order.
+	// Since the histograms record milliseconds, the bounds must be whole
+	// milliseconds; a bound of zero counts the RPCs that took less than a
+	// millisecond. Use ExponentialLatencyBuckets to generate bounds.
+	//
+	// Defaults to buckets from 1ms to 10s. NewDispatcher panics if the
+	// buckets are invalid.
+	LatencyBuckets []time.Duration
+	// LatencyBucketOverrides specify the latency buckets of procedures.
+	LatencyBucketOverrides []LatencyBucketsOverride
+	// DisablePayloadSizeMetrics stops recording the histograms of the sizes
+	// of request and response payloads, like request_payload_size_bytes.
+	DisablePayloadSizeMetrics bool
+}
+
+// LatencyBucketsOverride specifies the latency buckets of the procedures
+// that match a pattern. The pattern is either a procedure name, or a prefix
+// of procedure names followed by "*", like "Cache::*" for all procedures of
+// a Thrift service. Procedure names take precedence over prefixes, and
+// longer prefixes over shorter ones.
+type LatencyBucketsOverride struct {
+	Procedure string
+	Buckets   []time.Duration
+}
+
+// ExponentialLatencyBuckets returns count latency buckets, starting at start
+// and growing by factor, rounded to whole milliseconds. Buckets that round to
+// the same millisecond as the previous bucket are raised by a millisecond,
+// so that the buckets remain ascending.
+//
+//	// 1ms, 2ms, 4ms, ..., 8.192s
+//	yarpc.ExponentialLatencyBuckets(time.Millisecond, 2, 14)
+func ExponentialLatencyBuckets(start time.Duration, factor float64, count int) []time.Duration {
+	if count < 1 || start < 0 || factor <= 1 {
+		return nil
+	}
+	buckets := make([]time.Duration, count)
+	bound := float64(start)
+	for i := range buckets {
+		b := time.Duration(bound).Round(time.Millisecond)
+		if i > 0 && b <= buckets[i-1] {
+			b = buckets[i-1] + time.Millisecond
+		}
+		buckets[i] = b
+		bound *= factor
+	}
+	return buckets
+}
+
+// latencyBuckets converts the latency buckets of the configuration to those
+// of the observability middleware.
+func (c MetricsConfig) latencyBuckets() ([]int64, map[string][]int64, error) {
+	var defaults []int64
+	if len(c.LatencyBuckets) > 0 {
+		buckets, err := observability.LatencyBuckets(c.LatencyBuckets)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid latency buckets: %v", err)
+		}
+		defaults = buckets
+	}
+	var overrides map[string][]int64
+	for _, o := range c.LatencyBucketOverrides {
+		buckets, err := observability.LatencyBuckets(o.Buckets)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid latency buckets for procedure %q: %v", o.Procedure, err)
+		}
+		if overrides == nil {
+			overrides = make(map[string][]int64, len(c.LatencyBucketOverrides))
+		}
+		overrides[o.Procedure] = buckets
+	}
+	return defaults, overrides, nil
 }
 
 func (c MetricsConfig) scope(name string, logger *zap.Logger) (*metrics.Scope, context.CancelFunc) {
@@ -204,6 +317,455 @@ func (c MetricsConfig) scope(name string, logger *zap.Logger) (*metrics.Scope, c
 	return meter, stopMeter
 }
 
+// RetryConfig describes how unary outbound calls should be retried.
+//
+// Calls are retried only if a policy applies to them, and only if their
+// error has a retryable code.
+// Retries respect the deadline of the call: a call is not retried if its
+// deadline would pass before the backoff elapses.
+// Use the WithNoRetry call option for procedures that are not idempotent.
+type RetryConfig struct {
+	// Default is the policy for calls that no override matches.
+	// Without a default, only the calls that an override matches are
+	// retried.
+	Default *RetryPolicy
+
+	// Overrides specify the policies for the procedures of a service, or
+	// for a single procedure.
+	// An override for a procedure takes precedence over an override for its
+	// service.
+	Overrides []RetryPolicyOverride
+
+	// Budget limits the retries to each service, so that retries cannot
+	// amplify an outage.
+	Budget RetryBudgetConfig
+}
+
+// RetryPolicy specifies how to retry the calls of a procedure.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of a call, including the
+	// first.
+	// Calls are not retried if MaxAttempts is less than 2.
+	MaxAttempts int
+
+	// Backoff specifies how long to wait between attempts.
+	//
+	// Defaults to exponential backoff with full jitter.
+	Backoff backoff.Strategy
+
+	// RetryableCodes are the error codes of the attempts to retry.
+	//
+	// Defaults to CodeUnavailable.
+	RetryableCodes []yarpcerrors.Code
+}
+
+// RetryPolicyOverride specifies the retry policy for the procedures of a
+// service, or of a single procedure of the service if Procedure is set.
+//
+// Procedure is either a procedure name, or a prefix of procedure names
+// followed by "*", like "KeyValue::*". Procedure names take precedence over
+// prefixes, and longer prefixes over shorter ones.
+type RetryPolicyOverride struct {
+	Service   string
+	Procedure string
+	Policy    RetryPolicy
+}
+
+// RetryBudgetConfig configures the token bucket that limits the retries to
+// each service.
+//
+// Every call deposits TokenRatio tokens, up to MaxTokens, and every retry
+// withdraws one token.
+// When the bucket has less than one token, calls are not retried.
+type RetryBudgetConfig struct {
+	// MaxTokens is the capacity of the bucket, bounding bursts of retries.
+	//
+	// Defaults to 10.
+	MaxTokens float64
+
+	// TokenRatio is the number of tokens each call deposits, bounding the
+	// ratio of retries to calls in the long run.
+	//
+	// Defaults to 0.1.
+	TokenRatio float64
+}
+
+func (c RetryConfig) enabled() bool {
+	return c.Default != nil || len(c.Overrides) > 0
+}
+
+func (c RetryConfig) middleware(meter *metrics.Scope, logger *zap.Logger) *retry.Middleware {
+	def, overrides := retryPolicies(c.Default, c.Overrides)
+	return retry.NewMiddleware(retry.Config{
+		Default:   def,
+		Overrides: overrides,
+		Budget: retry.Budget{
+			MaxTokens:  c.Budget.MaxTokens,
+			TokenRatio: c.Budget.TokenRatio,
+		},
+		Meter:  meter,
+		Logger: logger,
+	})
+}
+
+func retryPolicies(def *RetryPolicy, overrides []RetryPolicyOverride) (*retry.Policy, []retry.Override) {
+	var p *retry.Policy
+	if def != nil {
+		policy := def.policy()
+		p = &policy
+	}
+	policies := make([]retry.Override, len(overrides))
+	for i, o := range overrides {
+		policies[i] = retry.Override{
+			Service:   o.Service,
+			Procedure: o.Procedure,
+			Policy:    o.Policy.policy(),
+		}
+	}
+	return p, policies
+}
+
+func (p RetryPolicy) policy() retry.Policy {
+	return retry.Policy{
+		MaxAttempts:    p.MaxAttempts,
+		Backoff:        p.Backoff,
+		RetryableCodes: p.RetryableCodes,
+	}
+}
+
+// Retrier changes the retry policies of the outbound calls of a Dispatcher
+// at runtime.
+type Retrier struct {
+	m *retry.Middleware
+}
+
+// SetPolicies replaces the default policy and the overrides of the retry
+// policies. The retry budget does not change.
+//
+// Calls in flight keep the policy they started with.
+func (r *Retrier) SetPolicies(def *RetryPolicy, overrides []RetryPolicyOverride) {
+	r.m.SetPolicies(retryPolicies(def, overrides))
+}
+
+// RateLimitConfig describes how to limit the rate of inbound requests with
+// token buckets.
+//
+// Requests that find their bucket empty fail with a ResourceExhausted error
+// that carries the delay until a token is available as RetryInfo details.
+// The default limit and every override have their own buckets.
+type RateLimitConfig struct {
+	// Default is the limit of the requests that no override matches.
+	// Without a default, only the requests that an override matches are
+	// limited.
+	Default *RateLimit
+
+	// Overrides specify the limits of the requests for a procedure, from a
+	// caller, or for a procedure from a caller.
+	// An override for a procedure and caller takes precedence over an
+	// override for the procedure, which takes precedence over an override
+	// for the caller.
+	Overrides []RateLimitOverride
+
+	// KeyByProcedure gives each procedure its own bucket, instead of
+	// sharing the bucket of its limit.
+	KeyByProcedure bool
+
+	// KeyByCaller gives each caller its own bucket, instead of sharing the
+	// bucket of its limit.
+	KeyByCaller bool
+
+	// Key returns an additional key of the bucket of a request, like a
+	// tenant from a header.
+	//
+	// A bucket is kept for every key, so keys must have a bounded number
+	// of values.
+	Key func(*transport.Request) string
+}
+
+// RateLimit is the rate of a token bucket.
+type RateLimit struct {
+	// Rate is the number of requests per second the bucket allows in the
+	// long run. A non-positive rate disables the limit.
+	Rate float64
+
+	// Burst is the number of requests the bucket allows at once after a
+	// quiet period.
+	//
+	// Defaults to the rate, rounded up, and at least 1.
+	Burst int
+}
+
+// RateLimitOverride specifies the limit of the requests for a procedure, from
+// a caller, or for a procedure from a caller if both are set.
+type RateLimitOverride struct {
+	Procedure string
+	Caller    string
+	Limit     RateLimit
+}
+
+func (c RateLimitConfig) enabled() bool {
+	return c.Default != nil || len(c.Overrides) > 0
+}
+
+func (c RateLimitConfig) middleware(meter *metrics.Scope, logger *zap.Logger) *ratelimit.Middleware {
+	def, overrides := rateLimits(c.Default, c.Overrides)
+	return ratelimit.NewMiddleware(ratelimit.Config{
+		Default:        def,
+		Overrides:      overrides,
+		KeyByProcedure: c.KeyByProcedure,
+		KeyByCaller:    c.KeyByCaller,
+		Key:            c.Key,
+		Meter:          meter,
+		Logger:         logger,
+	})
+}
+
+func rateLimits(def *RateLimit, overrides []RateLimitOverride) (*ratelimit.Limit, []ratelimit.Override) {
+	var l *ratelimit.Limit
+	if def != nil {
+		l = &ratelimit.Limit{Rate: def.Rate, Burst: def.Burst}
+	}
+	limits := make([]ratelimit.Override, len(overrides))
+	for i, o := range overrides {
+		limits[i] = ratelimit.Override{
+			Procedure: o.Procedure,
+			Caller:    o.Caller,
+			Limit:     ratelimit.Limit{Rate: o.Limit.Rate, Burst: o.Limit.Burst},
+		}
+	}
+	return l, limits
+}
+
+// RateLimiter changes the rate limits of the inbound requests of a
+// Dispatcher at runtime.
+type RateLimiter struct {
+	m *ratelimit.Middleware
+}
+
+// SetLimits replaces the default limit and the overrides of the rate limits.
+// The keys of the buckets do not change.
+//
+// Buckets keep their tokens across changes, so a change does not reset the
+// limits of busy callers.
+func (r *RateLimiter) SetLimits(def *RateLimit, overrides []RateLimitOverride) {
+	r.m.SetLimits(rateLimits(def, overrides))
+}
+
+// DeadlineConfig describes the minimum remaining TTLs of inbound requests
+// and outbound calls, so that no work starts on requests that cannot finish
+// in time.
+type DeadlineConfig struct {
+	// Inbound rejects unary and oneway requests whose remaining TTL is too
+	// short, before they reach their handler.
+	Inbound InboundDeadlineConfig
+
+	// Outbound refuses to send unary and oneway calls whose remaining TTL
+	// is too short, or that have no deadline.
+	Outbound OutboundDeadlineConfig
+}
+
+// InboundDeadlineConfig specifies the minimum remaining TTLs of the inbound
+// requests for each procedure.
+//
+// Requests with less time left fail with a DeadlineExceeded error. Requests
+// without a deadline are let through.
+type InboundDeadlineConfig struct {
+	// MinTTL is the minimum remaining TTL of the requests for procedures
+	// that no override matches. Zero disables the minimum.
+	MinTTL time.Duration
+
+	// Overrides specify the minimum remaining TTLs of the requests for
+	// procedures.
+	Overrides []InboundDeadlineOverride
+}
+
+// InboundDeadlineOverride specifies the minimum remaining TTL of the
+// requests for a procedure. Zero disables the minimum for the procedure.
+type InboundDeadlineOverride struct {
+	Procedure string
+	MinTTL    time.Duration
+}
+
+// OutboundDeadlineConfig specifies the minimum remaining TTL of outbound
+// calls.
+//
+// Calls with less time left fail with a DeadlineExceeded error, and calls
+// without a deadline fail with an InvalidArgument error, without being sent.
+type OutboundDeadlineConfig struct {
+	// MinTTL is the minimum remaining TTL of calls. Zero disables the
+	// minimum.
+	MinTTL time.Duration
+
+	// DefaultTTL is the TTL of calls without a deadline, so that they are
+	// sent instead of refused.
+	DefaultTTL time.Duration
+}
+
+func (c InboundDeadlineConfig) enabled() bool {
+	return c.MinTTL > 0 || len(c.Overrides) > 0
+}
+
+func (c InboundDeadlineConfig) middleware(meter *metrics.Scope, logger *zap.Logger) *deadline.Inbound {
+	overrides := make(map[string]time.Duration, len(c.Overrides))
+	for _, o := range c.Overrides {
+		overrides[o.Procedure] = o.MinTTL
+	}
+	return deadline.NewInbound(deadline.InboundConfig{
+		MinTTL:    c.MinTTL,
+		Overrides: overrides,
+		Meter:     meter,
+		Logger:    logger,
+	})
+}
+
+func (c OutboundDeadlineConfig) enabled() bool {
+	return c.MinTTL > 0 || c.DefaultTTL > 0
+}
+
+func (c OutboundDeadlineConfig) middleware(meter *metrics.Scope, logger *zap.Logger) *deadline.Outbound {
+	return deadline.NewOutbound(deadline.OutboundConfig{
+		MinTTL:     c.MinTTL,
+		DefaultTTL: c.DefaultTTL,
+		Meter:      meter,
+		Logger:     logger,
+	})
+}
+
+// TimeoutConfig specifies the maximum time handlers spend on the inbound
+// unary and oneway requests for each procedure, regardless of the TTLs that
+// callers set.
+//
+// Handlers receive a context whose deadline is the earlier of the deadline
+// of the request and the timeout of its procedure. Requests that fail after
+// the timeout elapsed, before their deadline, fail with a DeadlineExceeded
+// error whose application error name is "server-timeout", which tells them
+// apart from requests that ran out of TTL in metrics.
+type TimeoutConfig struct {
+	// Default is the timeout of the requests for procedures that no
+	// override matches. Zero disables the timeout.
+	Default time.Duration
+
+	// Overrides specify the timeouts of the requests for procedures.
+	Overrides []TimeoutOverride
+}
+
+// TimeoutOverride specifies the timeout of the requests for the procedures
+// that match a pattern. The pattern is either a procedure name, or a prefix
+// of procedure names followed by "*", like "Store::*" for all procedures of
+// a Thrift service. Procedure names take precedence over prefixes, and
+// longer prefixes over shorter ones. Zero disables the timeout for the
+// procedures.
+type TimeoutOverride struct {
+	Procedure string
+	Timeout   time.Duration
+}
+
+func (c TimeoutConfig) enabled() bool {
+	return c.Default > 0 || len(c.Overrides) > 0
+}
+
+func (c TimeoutConfig) middleware(meter *metrics.Scope, logger *zap.Logger) *deadline.Timeout {
+	overrides := make(map[string]time.Duration, len(c.Overrides))
+	for _, o := range c.Overrides {
+		overrides[o.Procedure] = o.Timeout
+	}
+	return deadline.NewTimeout(deadline.TimeoutConfig{
+		Default:   c.Default,
+		Overrides: overrides,
+		Meter:     meter,
+		Logger:    logger,
+	})
+}
+
+// DrainConfig specifies how long inbounds may take to drain the requests in
+// flight when the dispatcher stops, for each transport.
+//
+// Inbounds that implement transport.GracefulStopper, like those of HTTP and
+// gRPC, close the connections that remain once their budget elapses. Other
+// inbounds, like those of TChannel, stop as they do without a budget. The
+// dispatcher logs a warning for every inbound that exceeds its budget.
+type DrainConfig struct {
+	// Default is the drain budget of the inbounds whose transport has no
+	// budget in Budgets. Zero leaves those inbounds to stop with their own
+	// shutdown timeouts.
+	Default time.Duration
+
+	// Budgets maps the names of transports, like "http" or "tchannel", to
+	// the drain budgets of their inbounds.
+	Budgets map[string]time.Duration
+}
+
+// budget returns the drain budget of the inbounds of a transport.
+func (c DrainConfig) budget(transportName string) time.Duration {
+	if budget, ok := c.Budgets[transportName]; ok {
+		return budget
+	}
+	return c.Default
+}
+
+// PanicRecoveryConfig configures the yarpcrecovery middleware, which fails
+// requests whose handlers panic with an Internal error that does not reveal
+// the panic value, and logs the panic with the stack of the handler.
+//
+// Without it, transports recover panics themselves, and fail their requests
+// with errors that include the panic value.
+type PanicRecoveryConfig struct {
+	// Enabled installs the middleware outside of all other inbound
+	// middleware.
+	Enabled bool
+
+	// OnPanic, if set, receives every recovered panic, like for reporting
+	// it to a crash reporting service.
+	OnPanic yarpcrecovery.Reporter
+
+	// CrashReporter, if set, also receives every recovered panic.
+	CrashReporter yarpcrecovery.CrashReporter
+}
+
+func (c PanicRecoveryConfig) middleware(meter *metrics.Scope, logger *zap.Logger) *yarpcrecovery.Middleware {
+	opts := []yarpcrecovery.Option{
+		yarpcrecovery.Meter(meter),
+		yarpcrecovery.Logger(logger),
+	}
+	if c.OnPanic != nil {
+		opts = append(opts, yarpcrecovery.OnPanic(c.OnPanic))
+	}
+	if c.CrashReporter != nil {
+		opts = append(opts, yarpcrecovery.ReportCrashes(c.CrashReporter))
+	}
+	return yarpcrecovery.NewInboundMiddleware(opts...)
+}
+
+// HeaderPropagationConfig specifies the application headers of inbound
+// requests that are propagated to the unary, oneway, and streaming outbound
+// calls made with their context, unless the calls set the headers
+// explicitly.
+type HeaderPropagationConfig struct {
+	// Headers are the names of the propagated headers, like "x-request-id".
+	Headers []string
+
+	// MaxValueSize, if positive, is the maximum size in bytes of the values
+	// of propagated headers. Longer values are truncated.
+	MaxValueSize int
+
+	// Rename maps the names of propagated headers to the names of the
+	// headers that they are propagated into, like "x-parent-request-id"
+	// for "x-request-id".
+	Rename map[string]string
+}
+
+func (c HeaderPropagationConfig) enabled() bool {
+	return len(c.Headers) > 0
+}
+
+func (c HeaderPropagationConfig) middleware() *propagation.Middleware {
+	return propagation.New(propagation.Config{
+		Headers:      c.Headers,
+		MaxValueSize: c.MaxValueSize,
+		Rename:       c.Rename,
+	})
+}
+
 // Config specifies the parameters of a new Dispatcher constructed via
 // NewDispatcher.
 type Config struct {
@@ -229,6 +791,27 @@ type Config struct {
 	InboundMiddleware  InboundMiddleware
 	OutboundMiddleware OutboundMiddleware
 
+	// MiddlewareChain orders named inbound and outbound middleware by their
+	// constraints, as an alternative to composing InboundMiddleware and
+	// OutboundMiddleware by position.
+	//
+	// InboundMiddleware and OutboundMiddleware, if any, are registered with
+	// the chain under ConfigMiddlewareName, before the middleware of the
+	// chain. The chains take their place inside the middleware the
+	// dispatcher adds itself. An invalid chain causes NewDispatcher to panic.
+	MiddlewareChain *middleware.Chain
+
+	// PerOutboundMiddleware specifies named outbound middleware for the calls
+	// of individual outbounds, by outbound key, like retries for one
+	// downstream and a circuit breaker for another.
+	//
+	// The middleware of an outbound are applied inside the outbound
+	// middleware of every outbound, including those of the dispatcher, and
+	// are ordered by their constraints. Inbound middleware of the chains are
+	// ignored. NewDispatcher panics if a chain is invalid, or if its outbound
+	// key is not among the Outbounds.
+	PerOutboundMiddleware map[string]*middleware.Chain
+
 	// Tracer is meant to add/record tracing information to a request.
 	//
 	// Deprecated: The dispatcher does nothing with this property.  Set the
@@ -244,6 +827,45 @@ type Config struct {
 	// Configures telemetry.
 	Metrics MetricsConfig
 
+	// Configures retries of unary outbound calls.
+	//
+	// Retries are disabled by default.
+	Retry RetryConfig
+
+	// Configures rate limits of inbound requests.
+	//
+	// Rate limits are disabled by default.
+	RateLimit RateLimitConfig
+
+	// Configures the minimum remaining TTLs of inbound requests and of
+	// outbound calls.
+	//
+	// Minimum TTLs are disabled by default.
+	Deadline DeadlineConfig
+
+	// Configures the maximum time handlers spend on inbound requests.
+	//
+	// Timeouts are disabled by default.
+	Timeout TimeoutConfig
+
+	// Configures the recovery of panics in inbound handlers.
+	//
+	// Panic recovery is disabled by default, and enabled by default for
+	// dispatchers configured with yarpcconfig.
+	PanicRecovery PanicRecoveryConfig
+
+	// Configures the propagation of application headers from inbound
+	// requests to the outbound calls made while handling them.
+	//
+	// Header propagation is disabled by default.
+	HeaderPropagation HeaderPropagationConfig
+
+	// Configures how long the inbounds of each transport may take to drain
+	// the requests in flight when the dispatcher stops.
+	//
+	// Inbounds stop with their own shutdown timeouts by default.
+	Drain DrainConfig
+
 	// DisableAutoObservabilityMiddleware is used to stop the dispatcher from
 	// automatically attaching observability middleware to all inbounds and
 	// outbounds.  It is the assumption that if if this option is disabled the
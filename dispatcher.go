@@ -29,6 +29,7 @@ import (
 	"go.uber.org/yarpc/api/middleware"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal"
+	"go.uber.org/yarpc/internal/callgraph"
 	"go.uber.org/yarpc/internal/firstoutboundmiddleware"
 	"go.uber.org/yarpc/internal/inboundmiddleware"
 	"go.uber.org/yarpc/internal/observability"
@@ -80,33 +81,135 @@ func NewDispatcher(cfg Config) *Dispatcher {
 	extractor := cfg.Logging.extractor()
 
 	meter, stopMeter := cfg.Metrics.scope(cfg.Name, logger)
+	cfg, middlewareNames := applyMiddlewareChain(cfg)
+	cfg, outboundMiddlewareNames := applyPerOutboundMiddleware(cfg, middlewareNames)
+	cfg, retrier := addRetryMiddleware(cfg, meter, logger)
+	cfg = addTimeoutMiddleware(cfg, meter, logger)
+	cfg, rateLimiter := addRateLimitMiddleware(cfg, meter, logger)
+	cfg, deadlineMiddleware := addDeadlineMiddleware(cfg, meter, logger)
+	cfg = addHeaderPropagationMiddleware(cfg)
 	cfg = addObservingMiddleware(cfg, meter, logger, extractor)
+	cfg = addPanicRecoveryMiddleware(cfg, meter, logger)
+	cfg = addCallGraphMiddleware(cfg)
 	cfg = addFirstOutboundMiddleware(cfg)
 
 	return &Dispatcher{
-		name:              cfg.Name,
-		table:             middleware.ApplyRouteTable(NewMapRouter(cfg.Name), cfg.RouterMiddleware),
-		inbounds:          cfg.Inbounds,
-		outbounds:         convertOutbounds(cfg.Outbounds, cfg.OutboundMiddleware),
-		transports:        collectTransports(cfg.Inbounds, cfg.Outbounds),
-		inboundMiddleware: cfg.InboundMiddleware,
-		log:               logger,
-		meter:             meter,
-		stopMeter:         stopMeter,
-		once:              lifecycle.NewOnce(),
+		name:               cfg.Name,
+		table:              middleware.ApplyRouteTable(NewMapRouter(cfg.Name), cfg.RouterMiddleware),
+		inbounds:           cfg.Inbounds,
+		outbounds:          convertOutbounds(cfg.Outbounds, cfg.OutboundMiddleware, deadlineMiddleware),
+		transports:         collectTransports(cfg.Inbounds, cfg.Outbounds),
+		inboundMiddleware:  cfg.InboundMiddleware,
+		outboundMiddleware: outboundMiddlewareNames,
+		log:                logger,
+		meter:              meter,
+		stopMeter:          stopMeter,
+		rateLimiter:        rateLimiter,
+		retrier:            retrier,
+		drain:              cfg.Drain,
+		once:               lifecycle.NewOnce(),
 	}
 }
 
+// Add the retry middleware after the outbound middleware from the config, and
+// before the observability middleware, so that every attempt is observed.
+func addRetryMiddleware(cfg Config, meter *metrics.Scope, logger *zap.Logger) (Config, *Retrier) {
+	if !cfg.Retry.enabled() {
+		return cfg, nil
+	}
+
+	retrier := cfg.Retry.middleware(meter, logger)
+	cfg.OutboundMiddleware.Unary = outboundmiddleware.UnaryChain(cfg.OutboundMiddleware.Unary, retrier)
+	return cfg, &Retrier{m: retrier}
+}
+
+// Add the timeout middleware before the inbound middleware from the config,
+// so that the timeout bounds their work too, and after the rate limit and
+// deadline middleware, so that requests they reject never start the timer.
+func addTimeoutMiddleware(cfg Config, meter *metrics.Scope, logger *zap.Logger) Config {
+	if !cfg.Timeout.enabled() {
+		return cfg
+	}
+
+	m := cfg.Timeout.middleware(meter, logger)
+	cfg.InboundMiddleware.Unary = inboundmiddleware.UnaryChain(m, cfg.InboundMiddleware.Unary)
+	cfg.InboundMiddleware.Oneway = inboundmiddleware.OnewayChain(m, cfg.InboundMiddleware.Oneway)
+	return cfg
+}
+
+// Add the rate limit middleware before the inbound middleware from the
+// config, so that limited requests skip it, and after the observability
+// middleware, so that limited requests are observed.
+func addRateLimitMiddleware(cfg Config, meter *metrics.Scope, logger *zap.Logger) (Config, *RateLimiter) {
+	if !cfg.RateLimit.enabled() {
+		return cfg, nil
+	}
+
+	limiter := cfg.RateLimit.middleware(meter, logger)
+	cfg.InboundMiddleware.Unary = inboundmiddleware.UnaryChain(limiter, cfg.InboundMiddleware.Unary)
+	cfg.InboundMiddleware.Oneway = inboundmiddleware.OnewayChain(limiter, cfg.InboundMiddleware.Oneway)
+	cfg.InboundMiddleware.Stream = inboundmiddleware.StreamChain(limiter, cfg.InboundMiddleware.Stream)
+	return cfg, &RateLimiter{m: limiter}
+}
+
+// Add the inbound deadline middleware before the rate limit middleware, so
+// that requests that cannot finish in time take no tokens. The outbound
+// deadline middleware is returned separately, because it must give calls
+// without a deadline the default TTL before the outbounds validate them.
+func addDeadlineMiddleware(cfg Config, meter *metrics.Scope, logger *zap.Logger) (Config, OutboundMiddleware) {
+	if cfg.Deadline.Inbound.enabled() {
+		m := cfg.Deadline.Inbound.middleware(meter, logger)
+		cfg.InboundMiddleware.Unary = inboundmiddleware.UnaryChain(m, cfg.InboundMiddleware.Unary)
+		cfg.InboundMiddleware.Oneway = inboundmiddleware.OnewayChain(m, cfg.InboundMiddleware.Oneway)
+	}
+
+	var outbound OutboundMiddleware
+	if cfg.Deadline.Outbound.enabled() {
+		m := cfg.Deadline.Outbound.middleware(meter, logger)
+		outbound.Unary = m
+		outbound.Oneway = m
+	}
+	return cfg, outbound
+}
+
+// Add the header propagation middleware before the inbound middleware from the
+// config, so that it sees the context with the propagated headers, and before
+// the outbound middleware from the config, so that it sees the propagated
+// headers of calls.
+func addHeaderPropagationMiddleware(cfg Config) Config {
+	if !cfg.HeaderPropagation.enabled() {
+		return cfg
+	}
+
+	m := cfg.HeaderPropagation.middleware()
+	cfg.InboundMiddleware.Unary = inboundmiddleware.UnaryChain(m, cfg.InboundMiddleware.Unary)
+	cfg.InboundMiddleware.Oneway = inboundmiddleware.OnewayChain(m, cfg.InboundMiddleware.Oneway)
+	cfg.InboundMiddleware.Stream = inboundmiddleware.StreamChain(m, cfg.InboundMiddleware.Stream)
+
+	cfg.OutboundMiddleware.Unary = outboundmiddleware.UnaryChain(m, cfg.OutboundMiddleware.Unary)
+	cfg.OutboundMiddleware.Oneway = outboundmiddleware.OnewayChain(m, cfg.OutboundMiddleware.Oneway)
+	cfg.OutboundMiddleware.Stream = outboundmiddleware.StreamChain(m, cfg.OutboundMiddleware.Stream)
+	return cfg
+}
+
 func addObservingMiddleware(cfg Config, meter *metrics.Scope, logger *zap.Logger, extractor observability.ContextExtractor) Config {
 	if cfg.DisableAutoObservabilityMiddleware {
 		return cfg
 	}
 
+	latencyBuckets, latencyBucketOverrides, err := cfg.Metrics.latencyBuckets()
+	if err != nil {
+		panic("yarpc.NewDispatcher expects valid metrics configuration: " + err.Error())
+	}
+
 	observer := observability.NewMiddleware(observability.Config{
-		Logger:              logger,
-		Scope:               meter,
-		ContextExtractor:    extractor,
-		MetricTagsBlocklist: cfg.Metrics.TagsBlocklist,
+		Logger:                    logger,
+		Scope:                     meter,
+		ContextExtractor:          extractor,
+		MetricTagsBlocklist:       cfg.Metrics.TagsBlocklist,
+		LatencyBuckets:            latencyBuckets,
+		LatencyBucketOverrides:    latencyBucketOverrides,
+		DisablePayloadSizeMetrics: cfg.Metrics.DisablePayloadSizeMetrics,
 		Levels: observability.LevelsConfig{
 			Default: observability.DirectionalLevelsConfig{
 				Success:          cfg.Logging.Levels.Success,
@@ -130,6 +233,7 @@ func addObservingMiddleware(cfg Config, meter *metrics.Scope, logger *zap.Logger
 				ClientError:      cfg.Logging.Levels.Outbound.ClientError,
 			},
 		},
+		Redaction: observability.RedactionConfig(cfg.Logging.Redaction),
 	})
 
 	cfg.InboundMiddleware.Unary = inboundmiddleware.UnaryChain(observer, cfg.InboundMiddleware.Unary)
@@ -143,6 +247,34 @@ func addObservingMiddleware(cfg Config, meter *metrics.Scope, logger *zap.Logger
 	return cfg
 }
 
+// Add the panic recovery middleware before the observability middleware, so
+// that the observability middleware sees and counts panics before they are
+// recovered.
+func addPanicRecoveryMiddleware(cfg Config, meter *metrics.Scope, logger *zap.Logger) Config {
+	if !cfg.PanicRecovery.Enabled {
+		return cfg
+	}
+
+	if !cfg.DisableAutoObservabilityMiddleware {
+		// The observability middleware already counts panics.
+		meter = nil
+	}
+	m := cfg.PanicRecovery.middleware(meter, logger)
+	cfg.InboundMiddleware.Unary = inboundmiddleware.UnaryChain(m, cfg.InboundMiddleware.Unary)
+	cfg.InboundMiddleware.Oneway = inboundmiddleware.OnewayChain(m, cfg.InboundMiddleware.Oneway)
+	cfg.InboundMiddleware.Stream = inboundmiddleware.StreamChain(m, cfg.InboundMiddleware.Stream)
+	return cfg
+}
+
+// Add the call graph middleware before the outbound middleware from the
+// config, so that the latency of calls it records includes their retries.
+func addCallGraphMiddleware(cfg Config) Config {
+	m := callgraph.New()
+	cfg.OutboundMiddleware.Unary = outboundmiddleware.UnaryChain(m, cfg.OutboundMiddleware.Unary)
+	cfg.OutboundMiddleware.Oneway = outboundmiddleware.OnewayChain(m, cfg.OutboundMiddleware.Oneway)
+	return cfg
+}
+
 // Add the first outbound middleware, which ensures that `transport.Request`
 // will have appropriate fields.
 func addFirstOutboundMiddleware(cfg Config) Config {
@@ -153,8 +285,10 @@ func addFirstOutboundMiddleware(cfg Config) Config {
 	return cfg
 }
 
-// convertOutbounds applies outbound middleware and creates validator outbounds
-func convertOutbounds(outbounds Outbounds, mw OutboundMiddleware) Outbounds {
+// convertOutbounds applies outbound middleware and creates validator outbounds,
+// wrapped in the beforeValidation middleware, which sees requests before they
+// are validated.
+func convertOutbounds(outbounds Outbounds, mw OutboundMiddleware, beforeValidation OutboundMiddleware) Outbounds {
 	outboundSpecs := make(Outbounds, len(outbounds))
 
 	for outboundKey, outs := range outbounds {
@@ -174,11 +308,13 @@ func convertOutbounds(outbounds Outbounds, mw OutboundMiddleware) Outbounds {
 		if outs.Unary != nil {
 			unaryOutbound = middleware.ApplyUnaryOutbound(outs.Unary, mw.Unary)
 			unaryOutbound = request.UnaryValidatorOutbound{UnaryOutbound: unaryOutbound, Namer: namerOrNil(unaryOutbound)}
+			unaryOutbound = middleware.ApplyUnaryOutbound(unaryOutbound, beforeValidation.Unary)
 		}
 
 		if outs.Oneway != nil {
 			onewayOutbound = middleware.ApplyOnewayOutbound(outs.Oneway, mw.Oneway)
 			onewayOutbound = request.OnewayValidatorOutbound{OnewayOutbound: onewayOutbound, Namer: namerOrNil(onewayOutbound)}
+			onewayOutbound = middleware.ApplyOnewayOutbound(onewayOutbound, beforeValidation.Oneway)
 		}
 
 		if outs.Stream != nil {
@@ -255,13 +391,36 @@ type Dispatcher struct {
 
 	inboundMiddleware InboundMiddleware
 
+	// outboundMiddleware holds the names of the outbound middleware of every
+	// outbound key, for introspection.
+	outboundMiddleware map[string]outboundMiddlewareNames
+
 	log       *zap.Logger
 	meter     *metrics.Scope
 	stopMeter context.CancelFunc
 
+	rateLimiter *RateLimiter
+	retrier     *Retrier
+
+	drain DrainConfig
+
 	once *lifecycle.Once
 }
 
+// RateLimiter returns the handle that changes the rate limits of inbound
+// requests at runtime, or nil if the Config of the Dispatcher has no rate
+// limits.
+func (d *Dispatcher) RateLimiter() *RateLimiter {
+	return d.rateLimiter
+}
+
+// Retrier returns the handle that changes the retry policies of outbound
+// calls at runtime, or nil if the Config of the Dispatcher has no retry
+// policies.
+func (d *Dispatcher) Retrier() *Retrier {
+	return d.retrier
+}
+
 // Inbounds returns a copy of the list of inbounds for this RPC object.
 //
 // The Inbounds will be returned in the same order that was used in the
@@ -446,6 +605,39 @@ func (d *Dispatcher) Stop() error {
 	})
 }
 
+// WaitForShutdown blocks until the dispatcher has stopped and returns the
+// error, if any, from Stop.
+//
+// This is a convenience for main functions, which otherwise need to block
+// until another goroutine stops the dispatcher, for example in response to a
+// signal.
+//
+//  if err := dispatcher.Start(); err != nil {
+//    log.Fatal(err)
+//  }
+//  go stopOnSignal(dispatcher)
+//  if err := dispatcher.WaitForShutdown(); err != nil {
+//    log.Fatal(err)
+//  }
+//
+// If the dispatcher fails to start, WaitForShutdown returns the error from
+// Start. If the dispatcher is stopped with PhasedStop, WaitForShutdown
+// returns as soon as PhasedStop is called, without waiting for the
+// PhasedStopper to complete shutdown.
+func (d *Dispatcher) WaitForShutdown() error {
+	<-d.Done()
+	// Once the dispatcher has stopped, Stop is a no-op that returns the
+	// error from the first attempt.
+	return d.once.Stop(nil)
+}
+
+// Done returns a channel that is closed when the dispatcher has stopped,
+// suitable for use in select statements. Use WaitForShutdown to retrieve the
+// error from Stop.
+func (d *Dispatcher) Done() <-chan struct{} {
+	return d.once.Stopped()
+}
+
 // PhasedStop is a more granular alternative to Stop, and is intended only for
 // advanced users. Rather than stopping all inbounds, outbounds, and
 // transports at once, it lets the user stop them separately.
@@ -26,8 +26,13 @@ import (
 	"log"
 
 	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/encoding/json"
 	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/yarpctest"
 )
 
 func ExampleDispatcher_minimal() {
@@ -71,3 +76,46 @@ func ExampleDispatcher_Register_jSON() {
 	dispatcher.Register(json.Procedure("get", handler))
 	// Output:
 }
+
+func ExampleDispatcher_SubscribePeerStatus() {
+	// A fake transport stands in for HTTP connections to the peers.
+	list := roundrobin.New(yarpctest.NewFakeTransport())
+	outbound := http.NewTransport().NewOutbound(list)
+	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+		Name:      "my-fancy-service",
+		Outbounds: yarpc.Outbounds{"backend": {Unary: outbound}},
+	})
+	if err := dispatcher.Start(); err != nil {
+		log.Fatal(err)
+	}
+	defer dispatcher.Stop()
+
+	available := make(map[string]bool)
+	logged := make(chan struct{})
+	unsubscribe, err := dispatcher.SubscribePeerStatus("backend", func(id peer.Identifier, status peer.ConnectionStatus) {
+		if status == peer.Available {
+			available[id.Identifier()] = true
+		} else {
+			delete(available, id.Identifier())
+		}
+		fmt.Printf("%v is %v, %d peers available\n", id.Identifier(), status, len(available))
+		logged <- struct{}{}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer unsubscribe()
+
+	id := hostport.PeerIdentifier("127.0.0.1:8080")
+	if err := list.Update(peer.ListUpdates{Additions: []peer.Identifier{id}}); err != nil {
+		log.Fatal(err)
+	}
+	<-logged
+	if err := list.Update(peer.ListUpdates{Removals: []peer.Identifier{id}}); err != nil {
+		log.Fatal(err)
+	}
+	<-logged
+	// Output:
+	// 127.0.0.1:8080 is Available, 1 peers available
+	// 127.0.0.1:8080 is Unavailable, 0 peers available
+}
@@ -27,6 +27,7 @@ import (
 
 	tchannel "github.com/uber/tchannel-go"
 	thriftrw "go.uber.org/thriftrw/version"
+	"go.uber.org/yarpc/api/peer"
 	xintrospection "go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/internal/introspection"
 	"google.golang.org/grpc"
@@ -35,12 +36,27 @@ import (
 // Introspect returns detailed information about the dispatcher. This function
 // acquires a lots of locks throughout and should only be called with some
 // reserve.
+//
+// The configuration attributes that transports, inbounds, outbounds, and peer
+// choosers report are redacted: attributes that hold secrets, like passwords
+// and tokens, and the passwords of URLs, are replaced.
 func (d *Dispatcher) Introspect() introspection.DispatcherStatus {
+	var transports []xintrospection.TransportStatus
+	for _, t := range d.transports {
+		if t, ok := t.(xintrospection.IntrospectableTransport); ok {
+			status := t.Introspect()
+			status.Config = introspection.RedactConfig(status.Config)
+			transports = append(transports, status)
+		}
+	}
+	sort.Slice(transports, func(i, j int) bool { return transports[i].Name < transports[j].Name })
+
 	var inbounds []xintrospection.InboundStatus
 	for _, i := range d.inbounds {
 		var status xintrospection.InboundStatus
 		if i, ok := i.(xintrospection.IntrospectableInbound); ok {
 			status = i.Introspect()
+			status.Config = introspection.RedactConfig(status.Config)
 		} else {
 			status = xintrospection.InboundStatus{
 				Transport: "Introspection not supported",
@@ -50,29 +66,15 @@ func (d *Dispatcher) Introspect() introspection.DispatcherStatus {
 	}
 	var outbounds []xintrospection.OutboundStatus
 	for outboundKey, o := range d.outbounds {
+		names := d.outboundMiddleware[outboundKey]
 		if o.Unary != nil {
-			var status xintrospection.OutboundStatus
-			if o, ok := o.Unary.(xintrospection.IntrospectableOutbound); ok {
-				status = o.Introspect()
-			} else {
-				status.Transport = "Introspection not supported"
-			}
-			status.RPCType = "unary"
-			status.Service = o.ServiceName
-			status.OutboundKey = outboundKey
-			outbounds = append(outbounds, status)
+			outbounds = append(outbounds, introspectOutbound(o.Unary, "unary", outboundKey, o.ServiceName, names.unary))
 		}
 		if o.Oneway != nil {
-			var status xintrospection.OutboundStatus
-			if o, ok := o.Oneway.(xintrospection.IntrospectableOutbound); ok {
-				status = o.Introspect()
-			} else {
-				status.Transport = "Introspection not supported"
-			}
-			status.RPCType = "oneway"
-			status.Service = o.ServiceName
-			status.OutboundKey = outboundKey
-			outbounds = append(outbounds, status)
+			outbounds = append(outbounds, introspectOutbound(o.Oneway, "oneway", outboundKey, o.ServiceName, names.oneway))
+		}
+		if o.Stream != nil {
+			outbounds = append(outbounds, introspectOutbound(o.Stream, "stream", outboundKey, o.ServiceName, names.stream))
 		}
 	}
 
@@ -83,12 +85,56 @@ func (d *Dispatcher) Introspect() introspection.DispatcherStatus {
 		Name:            d.name,
 		ID:              fmt.Sprintf("%p", d),
 		Procedures:      procedures,
+		Transports:      transports,
 		Inbounds:        inbounds,
 		Outbounds:       outbounds,
 		PackageVersions: PackageVersions,
 	}
 }
 
+// introspectOutbound returns the redacted status of an outbound of the given
+// RPC type.
+func introspectOutbound(o interface{}, rpcType, outboundKey, service string, middleware []string) xintrospection.OutboundStatus {
+	var status xintrospection.OutboundStatus
+	if o, ok := o.(xintrospection.IntrospectableOutbound); ok {
+		status = o.Introspect()
+	} else {
+		status.Transport = "Introspection not supported"
+	}
+	status.RPCType = rpcType
+	status.Service = service
+	status.OutboundKey = outboundKey
+	status.Middleware = middleware
+	status.Endpoint = introspection.RedactURL(status.Endpoint)
+	status.Config = introspection.RedactConfig(status.Config)
+	status.Chooser = introspection.RedactChooser(status.Chooser)
+	return status
+}
+
+// SubscribePeerStatus registers a function to call with the connection status
+// of each peer of the peer list behind the given outbound key, as peers are
+// added, removed, or change status.
+// The function first receives the status of every peer already in the list.
+// The returned function cancels the subscription.
+//
+// The subscription follows the unary outbound if it supports peer status
+// subscriptions, then the oneway outbound, then the stream outbound.
+// See peer.StatusWatcher for the delivery guarantees.
+func (d *Dispatcher) SubscribePeerStatus(outboundKey string, fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func(), err error) {
+	outs, ok := d.outbounds[outboundKey]
+	if !ok {
+		return nil, fmt.Errorf("no configured outbound transport for outbound key %q", outboundKey)
+	}
+	for _, o := range []interface{}{outs.Unary, outs.Oneway, outs.Stream} {
+		if o, ok := o.(xintrospection.PeerStatusWatchableOutbound); ok {
+			if unsubscribe, ok := o.SubscribePeerStatus(fn); ok {
+				return unsubscribe, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("outbound key %q has no peer list that supports peer status subscriptions", outboundKey)
+}
+
 // PackageVersions is a list of packages with corresponding versions.
 var PackageVersions = []introspection.PackageVersion{
 	{Name: "yarpc", Version: Version},
@@ -21,8 +21,10 @@
 package yarpc
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/errorsync"
@@ -190,8 +192,8 @@ type PhasedStopper struct {
 
 // StopInbounds is the first step in shutdown. It stops all inbounds
 // configured on the dispatcher, which stops routing RPCs to all registered
-// procedures. It's safe to call concurrently, but all calls after the first
-// return an error.
+// procedures. Inbounds are given the drain budgets of their transports. It's
+// safe to call concurrently, but all calls after the first return an error.
 func (s *PhasedStopper) StopInbounds() error {
 	if s.inboundsStopInitiated.Swap(true) {
 		return errors.New("already began stopping inbounds")
@@ -200,7 +202,8 @@ func (s *PhasedStopper) StopInbounds() error {
 	s.log.Debug("stopping inbounds")
 	wait := errorsync.ErrorWaiter{}
 	for _, ib := range s.dispatcher.inbounds {
-		wait.Submit(ib.Stop)
+		ib := ib
+		wait.Submit(func() error { return s.stopInbound(ib) })
 	}
 	if errs := wait.Wait(); len(errs) > 0 {
 		return multierr.Combine(errs...)
@@ -209,6 +212,36 @@ func (s *PhasedStopper) StopInbounds() error {
 	return nil
 }
 
+// stopInbound stops an inbound within the drain budget of its transport,
+// warning if it takes longer.
+func (s *PhasedStopper) stopInbound(ib transport.Inbound) error {
+	var name string
+	if namer, ok := ib.(transport.Namer); ok {
+		name = namer.TransportName()
+	}
+	budget := s.dispatcher.drain.budget(name)
+	if budget <= 0 {
+		return ib.Stop()
+	}
+
+	start := time.Now()
+	var err error
+	if stopper, ok := ib.(transport.GracefulStopper); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), budget)
+		err = stopper.GracefulStop(ctx)
+		cancel()
+	} else {
+		err = ib.Stop()
+	}
+	if elapsed := time.Since(start); elapsed >= budget {
+		s.log.Warn("inbound exceeded its drain budget",
+			zap.String("transport", name),
+			zap.Duration("budget", budget),
+			zap.Duration("elapsed", elapsed))
+	}
+	return err
+}
+
 // StopOutbounds is the second step in shutdown. It stops all outbounds
 // configured on the dispatcher, which stops clients from making outbound
 // RPCs. It's safe to call concurrently, but all calls after the first return
@@ -21,21 +21,34 @@
 package yarpc_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	. "go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/backoff"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/transport/transporttest"
 	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/encoding/raw"
 	"go.uber.org/yarpc/internal/observability"
+	"go.uber.org/yarpc/internal/outboundmiddleware"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
 	"go.uber.org/yarpc/transport/http"
 	"go.uber.org/yarpc/transport/tchannel"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -44,6 +57,7 @@ import (
 	tchannelgo "github.com/uber/tchannel-go"
 	"go.uber.org/atomic"
 	"go.uber.org/multierr"
+	"go.uber.org/net/metrics"
 	thriftrwversion "go.uber.org/thriftrw/version"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -417,6 +431,7 @@ func TestStartStopFailures(t *testing.T) {
 				if assert.Error(t, err, "expected Start() to fail") {
 					assert.Contains(t, err.Error(), tt.wantStartErr)
 				}
+				assert.Equal(t, err, dispatcher.WaitForShutdown(), "expected WaitForShutdown() to return the Start() error")
 				return
 			}
 			if !assert.NoError(t, err, "expected Start() to succeed") {
@@ -424,6 +439,7 @@ func TestStartStopFailures(t *testing.T) {
 			}
 
 			err = dispatcher.Stop()
+			assert.Equal(t, err, dispatcher.WaitForShutdown(), "expected WaitForShutdown() to return the Stop() error")
 			if tt.wantStopErr == "" {
 				assert.NoError(t, err, "expected Stop() to succeed")
 				return
@@ -567,6 +583,127 @@ func TestPhasedStopRaces(t *testing.T) {
 	assert.Equal(t, 3*concurrency-3, int(errs.Load()), "wrong number of errors")
 }
 
+func TestWaitForShutdown(t *testing.T) {
+	d := NewDispatcher(outboundConfig(t))
+	require.NoError(t, d.Start(), "starting dispatcher failed")
+
+	done := make(chan error)
+	go func() {
+		done <- d.WaitForShutdown()
+	}()
+
+	select {
+	case <-d.Done():
+		t.Fatal("dispatcher done before stopping")
+	case <-done:
+		t.Fatal("WaitForShutdown returned before stopping")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	require.NoError(t, d.Stop(), "stopping dispatcher failed")
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err, "WaitForShutdown returned an error")
+	case <-time.After(testtime.Second):
+		t.Fatal("WaitForShutdown did not return after stopping")
+	}
+
+	select {
+	case <-d.Done():
+	default:
+		t.Fatal("expected Done channel to be closed")
+	}
+	assert.NoError(t, d.WaitForShutdown(), "WaitForShutdown after stopping returned an error")
+}
+
+// drainingInbound is an inbound of a named transport that takes drainTime
+// to drain when it stops gracefully.
+type drainingInbound struct {
+	*transporttest.MockInbound
+
+	name      string
+	drainTime time.Duration
+	budget    time.Duration
+}
+
+func (i *drainingInbound) TransportName() string { return i.name }
+
+func (i *drainingInbound) GracefulStop(ctx context.Context) error {
+	deadline, ok := ctx.Deadline()
+	if ok {
+		i.budget = time.Until(deadline)
+	}
+	select {
+	case <-time.After(i.drainTime):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestDrainBudgets(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	newInbound := func(name string, drainTime time.Duration) *drainingInbound {
+		in := transporttest.NewMockInbound(mockCtrl)
+		in.EXPECT().Transports()
+		in.EXPECT().SetRouter(gomock.Any())
+		in.EXPECT().Start().Return(nil)
+		return &drainingInbound{MockInbound: in, name: name, drainTime: drainTime}
+	}
+	fast := newInbound("http", 0)
+	slow := newInbound("tchannel", testtime.Second)
+	unnamed := transporttest.NewMockInbound(mockCtrl)
+	unnamed.EXPECT().Transports()
+	unnamed.EXPECT().SetRouter(gomock.Any())
+	unnamed.EXPECT().Start().Return(nil)
+	unnamed.EXPECT().Stop().Return(nil)
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	d := NewDispatcher(Config{
+		Name:     "test",
+		Inbounds: Inbounds{fast, slow, unnamed},
+		Logging:  LoggingConfig{Zap: zap.New(core)},
+		Drain: DrainConfig{
+			Default: 10 * time.Millisecond,
+			Budgets: map[string]time.Duration{"http": testtime.Second},
+		},
+	})
+	require.NoError(t, d.Start())
+
+	err := d.Stop()
+	assert.Equal(t, context.DeadlineExceeded, err, "inbounds that exceed their budget must be forced to stop")
+	assert.True(t, fast.budget > 10*time.Millisecond && fast.budget <= testtime.Second,
+		"inbounds must receive the budget of their transport, got %v", fast.budget)
+	assert.True(t, slow.budget <= 10*time.Millisecond,
+		"inbounds must receive the default budget, got %v", slow.budget)
+
+	warnings := logs.FilterMessage("inbound exceeded its drain budget")
+	assert.Equal(t, 1, warnings.Len())
+	assert.Equal(t, 1, warnings.FilterField(zap.String("transport", "tchannel")).Len())
+}
+
+func TestDrainBudgetsDisabled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Without a budget, inbounds stop with Stop.
+	in := transporttest.NewMockInbound(mockCtrl)
+	in.EXPECT().Transports()
+	in.EXPECT().SetRouter(gomock.Any())
+	in.EXPECT().Start().Return(nil)
+	in.EXPECT().Stop().Return(nil)
+	d := NewDispatcher(Config{
+		Name:     "test",
+		Inbounds: Inbounds{&drainingInbound{MockInbound: in, name: "http"}},
+		Drain:    DrainConfig{Budgets: map[string]time.Duration{"tchannel": time.Second}},
+	})
+	require.NoError(t, d.Start())
+	assert.NoError(t, d.Stop())
+}
+
 func TestNoOutboundsForService(t *testing.T) {
 	defer func() {
 		r := recover()
@@ -754,6 +891,55 @@ func TestObservabilityMiddlewareApplicationErrorLevel(t *testing.T) {
 
 }
 
+func TestObservabilityMiddlewareRedaction(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req := &transport.Request{
+		Service:   "test",
+		Caller:    "test",
+		Procedure: "test",
+		Encoding:  transport.Encoding("test"),
+		Headers:   transport.NewHeaders().With("authorization", "s3cr3t").With("x-request-id", "42"),
+	}
+	out := transporttest.NewMockUnaryOutbound(mockCtrl)
+	out.EXPECT().Transports().AnyTimes()
+	out.EXPECT().Call(ctx, req).Return(nil, yarpcerrors.UnauthenticatedErrorf("bad token s3cr3t"))
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	dispatcher := NewDispatcher(Config{
+		Name: "test",
+		Outbounds: Outbounds{
+			"my-test-service": {
+				ServiceName: "my-real-service",
+				Unary:       out,
+			},
+		},
+		Logging: LoggingConfig{
+			Zap: zap.New(core),
+			Redaction: LogRedactionConfig{
+				LogHeaders:    true,
+				DeniedHeaders: []string{"Authorization"},
+				ErrorMessageScrubber: func(_ string, msg string) string {
+					return strings.Replace(msg, "s3cr3t", "[REDACTED]", -1)
+				},
+			},
+		},
+	})
+
+	cc := dispatcher.MustOutboundConfig("my-test-service")
+	_, err := cc.Outbounds.Unary.Call(ctx, req)
+	require.Error(t, err)
+
+	require.Equal(t, 1, logs.Len())
+	fields, ok := logs.TakeAll()[0].ContextMap()["yarpc"].(map[string]interface{})
+	require.True(t, ok, "expected fields in the yarpc namespace")
+	assert.Equal(t, map[string]interface{}{"x-request-id": "42"}, fields["headers"])
+	assert.Equal(t, "code:unauthenticated message:bad token [REDACTED]", fields["error"])
+}
+
 func TestDisableObservabilityMiddleware(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -821,6 +1007,577 @@ func TestObservabilityConfig(t *testing.T) {
 	}
 }
 
+func TestRetryConfig(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	out := transporttest.NewMockUnaryOutbound(mockCtrl)
+	out.EXPECT().Transports().AnyTimes()
+
+	dispatcher := NewDispatcher(Config{
+		Name: "test",
+		Outbounds: Outbounds{
+			"my-service": {Unary: out},
+		},
+		Retry: RetryConfig{
+			Default: &RetryPolicy{
+				MaxAttempts: 3,
+				Backoff:     backoff.None,
+			},
+		},
+	})
+	client := raw.New(dispatcher.ClientConfig("my-service"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	t.Run("retried", func(t *testing.T) {
+		gomock.InOrder(
+			out.EXPECT().Call(gomock.Any(), gomock.Any()).
+				Return(nil, yarpcerrors.UnavailableErrorf("try again")),
+			out.EXPECT().Call(gomock.Any(), gomock.Any()).
+				Return(&transport.Response{Body: ioutil.NopCloser(bytes.NewReader([]byte("world")))}, nil),
+		)
+
+		res, err := client.Call(ctx, "hello", []byte("hello"))
+		require.NoError(t, err)
+		assert.Equal(t, "world", string(res))
+	})
+
+	t.Run("without retries", func(t *testing.T) {
+		out.EXPECT().Call(gomock.Any(), gomock.Any()).
+			Return(nil, yarpcerrors.UnavailableErrorf("try again"))
+
+		_, err := client.Call(ctx, "hello", []byte("hello"), WithNoRetry())
+		assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code())
+	})
+
+	t.Run("new policies", func(t *testing.T) {
+		retrier := dispatcher.Retrier()
+		require.NotNil(t, retrier, "expected a retrier")
+		retrier.SetPolicies(nil, nil)
+
+		out.EXPECT().Call(gomock.Any(), gomock.Any()).
+			Return(nil, yarpcerrors.UnavailableErrorf("try again"))
+
+		_, err := client.Call(ctx, "hello", []byte("hello"))
+		assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code(),
+			"expected removing the policies to disable retries")
+	})
+
+	assert.Nil(t, NewDispatcher(Config{Name: "test"}).Retrier(), "expected no retrier without policies")
+}
+
+func TestNoRetry(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	out := transporttest.NewMockUnaryOutbound(mockCtrl)
+	out.EXPECT().Transports().AnyTimes()
+	out.EXPECT().Call(gomock.Any(), gomock.Any()).
+		Return(nil, yarpcerrors.UnavailableErrorf("try again")).
+		Times(1)
+
+	type layerKey struct{}
+	layer := middleware.UnaryOutboundFunc(
+		func(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+			ctx, cancel := context.WithCancel(context.WithValue(ctx, layerKey{}, req.Procedure))
+			defer cancel()
+			return out.Call(ctx, req)
+		})
+
+	dispatcher := NewDispatcher(Config{
+		Name: "test",
+		Outbounds: Outbounds{
+			"my-service": {Unary: out},
+		},
+		OutboundMiddleware: OutboundMiddleware{
+			Unary: outboundmiddleware.UnaryChain(layer, layer),
+		},
+		Retry: RetryConfig{
+			Default: &RetryPolicy{
+				MaxAttempts: 3,
+				Backoff:     backoff.None,
+			},
+		},
+	})
+	client := raw.New(dispatcher.ClientConfig("my-service"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	_, err := client.Call(ctx, "charge", []byte("hello"), WithNoRetry())
+	assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code())
+}
+
+func TestDeadlineConfig(t *testing.T) {
+	t.Run("inbound", func(t *testing.T) {
+		dispatcher := NewDispatcher(Config{
+			Name: "test",
+			Deadline: DeadlineConfig{
+				Inbound: InboundDeadlineConfig{MinTTL: 100 * time.Millisecond},
+			},
+		})
+		dispatcher.Register(raw.Procedure("hello", func(ctx context.Context, body []byte) ([]byte, error) {
+			return body, nil
+		}))
+
+		handle := func(ttl time.Duration) error {
+			ctx, cancel := context.WithTimeout(context.Background(), ttl)
+			defer cancel()
+			req := &transport.Request{Caller: "caller", Service: "test", Procedure: "hello", Encoding: raw.Encoding, Body: bytes.NewReader(nil)}
+			spec, err := dispatcher.Router().Choose(ctx, req)
+			require.NoError(t, err)
+			return spec.Unary().Handle(ctx, req, new(transporttest.FakeResponseWriter))
+		}
+
+		assert.NoError(t, handle(testtime.Second))
+		err := handle(time.Millisecond)
+		assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, yarpcerrors.FromError(err).Code())
+	})
+
+	t.Run("outbound", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		out := transporttest.NewMockUnaryOutbound(mockCtrl)
+		out.EXPECT().Transports().AnyTimes()
+
+		newClient := func(cfg OutboundDeadlineConfig) raw.Client {
+			dispatcher := NewDispatcher(Config{
+				Name:      "test",
+				Outbounds: Outbounds{"my-service": {Unary: out}},
+				Deadline:  DeadlineConfig{Outbound: cfg},
+			})
+			return raw.New(dispatcher.ClientConfig("my-service"))
+		}
+
+		// Calls without a deadline get the default TTL before they are
+		// validated.
+		client := newClient(OutboundDeadlineConfig{MinTTL: 100 * time.Millisecond, DefaultTTL: time.Minute})
+		out.EXPECT().Call(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, _ *transport.Request) (*transport.Response, error) {
+				_, ok := ctx.Deadline()
+				assert.True(t, ok, "expected the call to have the default TTL")
+				return &transport.Response{Body: ioutil.NopCloser(bytes.NewReader([]byte("world")))}, nil
+			})
+		res, err := client.Call(context.Background(), "hello", []byte("hello"))
+		require.NoError(t, err)
+		assert.Equal(t, "world", string(res))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		_, err = client.Call(ctx, "hello", []byte("hello"))
+		assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, yarpcerrors.FromError(err).Code())
+
+		client = newClient(OutboundDeadlineConfig{MinTTL: 100 * time.Millisecond})
+		_, err = client.Call(context.Background(), "hello", []byte("hello"))
+		assert.Equal(t, yarpcerrors.CodeInvalidArgument, yarpcerrors.FromError(err).Code())
+	})
+}
+
+func TestTimeoutConfig(t *testing.T) {
+	root := metrics.New()
+	dispatcher := NewDispatcher(Config{
+		Name:    "test",
+		Metrics: MetricsConfig{Metrics: root.Scope()},
+		Timeout: TimeoutConfig{
+			Default:   testtime.Second,
+			Overrides: []TimeoutOverride{{Procedure: "slow::*", Timeout: 10 * time.Millisecond}},
+		},
+	})
+	wait := func(ctx context.Context, body []byte) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	dispatcher.Register(raw.Procedure("slow::wait", wait))
+	dispatcher.Register(raw.Procedure("fast::wait", wait))
+
+	handle := func(procedure string, ttl time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), ttl)
+		defer cancel()
+		req := &transport.Request{Caller: "caller", Service: "test", Procedure: procedure, Encoding: raw.Encoding, Body: bytes.NewReader(nil)}
+		spec, err := dispatcher.Router().Choose(ctx, req)
+		require.NoError(t, err)
+		return spec.Unary().Handle(ctx, req, new(transporttest.FakeResponseWriter))
+	}
+
+	err := handle("slow::wait", testtime.Second)
+	assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, yarpcerrors.FromError(err).Code())
+	assert.Contains(t, err.Error(), "server timeout of 10ms")
+
+	err = handle("fast::wait", 10*time.Millisecond)
+	assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, yarpcerrors.FromError(err).Code())
+	assert.NotContains(t, err.Error(), "server timeout", "requests that ran out of TTL must fail as they did")
+
+	var serverTimeouts int64
+	for _, c := range root.Snapshot().Counters {
+		if c.Name == "server_failures" && c.Tags["error_name"] == "server-timeout" {
+			serverTimeouts += c.Value
+		}
+	}
+	assert.Equal(t, int64(1), serverTimeouts, "server timeouts must be told apart in metrics")
+}
+
+func TestExponentialLatencyBuckets(t *testing.T) {
+	tests := []struct {
+		desc   string
+		start  time.Duration
+		factor float64
+		count  int
+		want   []time.Duration
+	}{
+		{
+			desc:   "powers of two",
+			start:  time.Millisecond,
+			factor: 2,
+			count:  4,
+			want:   []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 8 * time.Millisecond},
+		},
+		{
+			desc:   "rounded to milliseconds",
+			start:  time.Millisecond,
+			factor: 1.5,
+			count:  5,
+			want:   []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond, 4 * time.Millisecond, 5 * time.Millisecond},
+		},
+		{
+			desc:   "no buckets",
+			start:  time.Millisecond,
+			factor: 2,
+		},
+		{
+			desc:   "factor too small",
+			start:  time.Millisecond,
+			factor: 1,
+			count:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExponentialLatencyBuckets(tt.start, tt.factor, tt.count))
+		})
+	}
+}
+
+func TestLatencyBucketsConfig(t *testing.T) {
+	root := metrics.New()
+	dispatcher := NewDispatcher(Config{
+		Name: "test",
+		Metrics: MetricsConfig{
+			Metrics:        root.Scope(),
+			LatencyBuckets: []time.Duration{5 * time.Second, 10 * time.Second},
+			LatencyBucketOverrides: []LatencyBucketsOverride{
+				{Procedure: "fast::*", Buckets: []time.Duration{3 * time.Second}},
+			},
+		},
+	})
+	echo := func(ctx context.Context, body []byte) ([]byte, error) { return body, nil }
+	dispatcher.Register(raw.Procedure("slow::echo", echo))
+	dispatcher.Register(raw.Procedure("fast::echo", echo))
+
+	for _, procedure := range []string{"slow::echo", "fast::echo"} {
+		ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+		req := &transport.Request{Caller: "caller", Service: "test", Procedure: procedure, Encoding: raw.Encoding, Body: bytes.NewReader(nil)}
+		spec, err := dispatcher.Router().Choose(ctx, req)
+		require.NoError(t, err)
+		require.NoError(t, spec.Unary().Handle(ctx, req, new(transporttest.FakeResponseWriter)))
+		cancel()
+	}
+
+	got := make(map[string][]int64)
+	for _, h := range root.Snapshot().Histograms {
+		if h.Name == "success_latency_ms" {
+			got[h.Tags["procedure"]] = h.Values
+		}
+	}
+	assert.Equal(t, map[string][]int64{
+		"slow__echo": {5000},
+		"fast__echo": {3000},
+	}, got)
+}
+
+func TestLatencyBucketsConfigInvalid(t *testing.T) {
+	tests := []struct {
+		desc string
+		give MetricsConfig
+		want string
+	}{
+		{
+			desc: "sub-millisecond",
+			give: MetricsConfig{LatencyBuckets: []time.Duration{500 * time.Microsecond}},
+			want: "yarpc.NewDispatcher expects valid metrics configuration: invalid latency buckets: bucket 500µs is not a whole number of milliseconds",
+		},
+		{
+			desc: "not ascending",
+			give: MetricsConfig{LatencyBucketOverrides: []LatencyBucketsOverride{
+				{Procedure: "foo", Buckets: []time.Duration{time.Second, time.Millisecond}},
+			}},
+			want: `yarpc.NewDispatcher expects valid metrics configuration: invalid latency buckets for procedure "foo": buckets must be ascending, got 1ms after 1s`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.PanicsWithValue(t, tt.want, func() {
+				NewDispatcher(Config{Name: "test", Metrics: tt.give})
+			})
+		})
+	}
+}
+
+func TestPayloadSizeMetrics(t *testing.T) {
+	tests := []struct {
+		desc string
+		// newTransports returns an inbound accepting requests for service
+		// bar, and a constructor of an outbound to it once it has started.
+		newTransports func(t *testing.T) (transport.Inbound, func() transport.UnaryOutbound)
+	}{
+		{
+			desc: "http",
+			newTransports: func(t *testing.T) (transport.Inbound, func() transport.UnaryOutbound) {
+				httpTransport := http.NewTransport()
+				inbound := httpTransport.NewInbound("127.0.0.1:0")
+				return inbound, func() transport.UnaryOutbound {
+					return httpTransport.NewSingleOutbound("http://" + inbound.Addr().String())
+				}
+			},
+		},
+		{
+			desc: "tchannel",
+			newTransports: func(t *testing.T) (transport.Inbound, func() transport.UnaryOutbound) {
+				serverTransport, err := tchannel.NewTransport(tchannel.ServiceName("bar"), tchannel.ListenAddr("127.0.0.1:0"))
+				require.NoError(t, err)
+				clientTransport, err := tchannel.NewTransport(tchannel.ServiceName("foo"))
+				require.NoError(t, err)
+				return serverTransport.NewInbound(), func() transport.UnaryOutbound {
+					return clientTransport.NewSingleOutbound(serverTransport.ListenAddr())
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			inbound, newOutbound := tt.newTransports(t)
+
+			serverRoot := metrics.New()
+			bar := NewDispatcher(Config{
+				Name:     "bar",
+				Inbounds: Inbounds{inbound},
+				Metrics:  MetricsConfig{Metrics: serverRoot.Scope()},
+			})
+			bar.Register(raw.Procedure("hello", func(ctx context.Context, body []byte) ([]byte, error) {
+				return bytes.Repeat(body, 3), nil
+			}))
+			require.NoError(t, bar.Start())
+			defer bar.Stop()
+
+			clientRoot := metrics.New()
+			foo := NewDispatcher(Config{
+				Name:      "foo",
+				Outbounds: Outbounds{"bar": {Unary: newOutbound()}},
+				Metrics:   MetricsConfig{Metrics: clientRoot.Scope()},
+			})
+			require.NoError(t, foo.Start())
+			defer foo.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+			defer cancel()
+			res, err := raw.New(foo.ClientConfig("bar")).Call(ctx, "hello", []byte("Foobar"))
+			require.NoError(t, err)
+			require.Equal(t, "FoobarFoobarFoobar", string(res))
+
+			// Histograms record the upper bounds of the buckets of values, so
+			// the 6 bytes of the request count as 8, and the 18 bytes of the
+			// response as 32.
+			for name, root := range map[string]*metrics.Root{"client": clientRoot, "server": serverRoot} {
+				got := make(map[string][]int64)
+				for _, h := range root.Snapshot().Histograms {
+					if strings.HasSuffix(h.Name, "_payload_size_bytes") {
+						got[h.Name] = h.Values
+					}
+				}
+				assert.Equal(t, map[string][]int64{
+					"request_payload_size_bytes":  {8},
+					"response_payload_size_bytes": {32},
+				}, got, "unexpected %v payload sizes", name)
+			}
+		})
+	}
+}
+
+func TestPayloadSizeMetricsDisabled(t *testing.T) {
+	root := metrics.New()
+	dispatcher := NewDispatcher(Config{
+		Name:    "test",
+		Metrics: MetricsConfig{Metrics: root.Scope(), DisablePayloadSizeMetrics: true},
+	})
+	dispatcher.Register(raw.Procedure("echo", func(ctx context.Context, body []byte) ([]byte, error) { return body, nil }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	req := &transport.Request{Caller: "caller", Service: "test", Procedure: "echo", Encoding: raw.Encoding, Body: bytes.NewReader([]byte("Foobar"))}
+	spec, err := dispatcher.Router().Choose(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, spec.Unary().Handle(ctx, req, new(transporttest.FakeResponseWriter)))
+
+	histograms := root.Snapshot().Histograms
+	require.NotEmpty(t, histograms, "expected latency histograms")
+	for _, h := range histograms {
+		assert.NotContains(t, h.Name, "payload_size", "unexpected payload size histogram")
+	}
+}
+
+func TestHeaderPropagationConfig(t *testing.T) {
+	propagation := HeaderPropagationConfig{
+		Headers:      []string{"x-request-id", "x-tenant"},
+		MaxValueSize: 8,
+	}
+
+	// Service bar records the headers of its requests.
+	httpTransport := http.NewTransport()
+	bar := NewDispatcher(Config{
+		Name:              "bar",
+		Inbounds:          Inbounds{httpTransport.NewInbound("127.0.0.1:0")},
+		HeaderPropagation: propagation,
+	})
+	var requestID, tenant, secret string
+	bar.Register(raw.Procedure("hello", func(ctx context.Context, body []byte) ([]byte, error) {
+		call := CallFromContext(ctx)
+		requestID, tenant, secret = call.Header("x-request-id"), call.Header("x-tenant"), call.Header("x-secret")
+		return body, nil
+	}))
+	require.NoError(t, bar.Start())
+	defer bar.Stop()
+
+	// Service foo calls bar without setting any headers.
+	addr := bar.Inbounds()[0].(*http.Inbound).Addr().String()
+	foo := NewDispatcher(Config{
+		Name: "foo",
+		Outbounds: Outbounds{
+			"bar": {Unary: httpTransport.NewSingleOutbound("http://" + addr)},
+		},
+		HeaderPropagation: propagation,
+	})
+	client := raw.New(foo.ClientConfig("bar"))
+	foo.Register(raw.Procedure("hello", func(ctx context.Context, body []byte) ([]byte, error) {
+		return client.Call(ctx, "hello", body)
+	}))
+	require.NoError(t, foo.Start())
+	defer foo.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	req := &transport.Request{
+		Caller:    "caller",
+		Service:   "foo",
+		Procedure: "hello",
+		Encoding:  raw.Encoding,
+		Headers: transport.NewHeaders().
+			With("x-request-id", "abc").
+			With("x-tenant", "a-tenant-name-that-is-too-long").
+			With("x-secret", "hunter2"),
+		Body: bytes.NewReader([]byte("world")),
+	}
+	spec, err := foo.Router().Choose(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, spec.Unary().Handle(ctx, req, new(transporttest.FakeResponseWriter)))
+
+	assert.Equal(t, "abc", requestID)
+	assert.Equal(t, "a-tenant", tenant, "expected the value to be truncated")
+	assert.Empty(t, secret, "headers that are not allowed must not be propagated")
+}
+
+type crashReporterFunc func(context.Context, interface{}, []byte) error
+
+func (f crashReporterFunc) Report(ctx context.Context, panicValue interface{}, stackTrace []byte) error {
+	return f(ctx, panicValue, stackTrace)
+}
+
+func TestPanicRecoveryConfig(t *testing.T) {
+	tests := []struct {
+		msg                  string
+		disableObservability bool
+	}{
+		{msg: "with observability"},
+		{msg: "without observability", disableObservability: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			root := metrics.New()
+			var reported, crashed interface{}
+			dispatcher := NewDispatcher(Config{
+				Name:    "test",
+				Metrics: MetricsConfig{Metrics: root.Scope()},
+				PanicRecovery: PanicRecoveryConfig{
+					Enabled: true,
+					OnPanic: func(_ context.Context, _ *transport.RequestMeta, recovered interface{}, _ []byte) {
+						reported = recovered
+					},
+					CrashReporter: crashReporterFunc(func(_ context.Context, panicValue interface{}, _ []byte) error {
+						crashed = panicValue
+						return nil
+					}),
+				},
+				DisableAutoObservabilityMiddleware: tt.disableObservability,
+			})
+			dispatcher.Register(raw.Procedure("hello", func(ctx context.Context, body []byte) ([]byte, error) {
+				panic("great sadness")
+			}))
+
+			req := &transport.Request{Caller: "caller", Service: "test", Procedure: "hello", Encoding: raw.Encoding, Body: bytes.NewReader(nil)}
+			spec, err := dispatcher.Router().Choose(context.Background(), req)
+			require.NoError(t, err)
+			err = spec.Unary().Handle(context.Background(), req, new(transporttest.FakeResponseWriter))
+			assert.Equal(t, yarpcerrors.CodeInternal, yarpcerrors.FromError(err).Code())
+			assert.NotContains(t, err.Error(), "great sadness")
+			assert.Equal(t, "great sadness", reported)
+			assert.Equal(t, "great sadness", crashed)
+
+			var panics int64
+			for _, c := range root.Snapshot().Counters {
+				if c.Name == "panics" {
+					panics += c.Value
+				}
+			}
+			assert.Equal(t, int64(1), panics, "panics must be counted once")
+		})
+	}
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	dispatcher := NewDispatcher(Config{
+		Name: "test",
+		RateLimit: RateLimitConfig{
+			Default: &RateLimit{Rate: 1, Burst: 1},
+		},
+	})
+	dispatcher.Register(raw.Procedure("hello", func(ctx context.Context, body []byte) ([]byte, error) {
+		return body, nil
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	handle := func() error {
+		req := &transport.Request{Caller: "caller", Service: "test", Procedure: "hello", Encoding: raw.Encoding, Body: bytes.NewReader(nil)}
+		spec, err := dispatcher.Router().Choose(ctx, req)
+		require.NoError(t, err)
+		return spec.Unary().Handle(ctx, req, new(transporttest.FakeResponseWriter))
+	}
+
+	require.NoError(t, handle())
+	err := handle()
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+
+	limiter := dispatcher.RateLimiter()
+	require.NotNil(t, limiter, "expected a rate limiter")
+	limiter.SetLimits(nil, nil)
+	assert.NoError(t, handle(), "expected removing the limits to disable them")
+
+	assert.Nil(t, NewDispatcher(Config{Name: "test"}).RateLimiter(), "expected no rate limiter without limits")
+}
+
 func TestIntrospect(t *testing.T) {
 	httpTransport := http.NewTransport()
 	tchannelChannelTransport, err := tchannel.NewChannelTransport(tchannel.ServiceName("test"), tchannel.ListenAddr("127.0.0.1:4040"))
@@ -859,6 +1616,12 @@ func TestIntrospect(t *testing.T) {
 	require.Len(t, dispatcherStatus.Inbounds, 3)
 	require.Len(t, dispatcherStatus.Outbounds, 4)
 
+	require.Len(t, dispatcherStatus.Transports, 2, "expected the HTTP and TChannel transports to report their status")
+	assert.Equal(t, "http", dispatcherStatus.Transports[0].Name)
+	assert.Equal(t, "30s", dispatcherStatus.Transports[0].Config["keepAlive"], "expected the default keep-alive")
+	assert.Equal(t, "tchannel", dispatcherStatus.Transports[1].Name)
+	assert.Equal(t, "500ms", dispatcherStatus.Transports[1].Config["connTimeout"], "expected the default connection timeout")
+
 	inboundStatus := getInboundStatus(t, dispatcherStatus.Inbounds, "http", "")
 	assert.Equal(t, "Stopped", inboundStatus.State)
 	inboundStatus = getInboundStatus(t, dispatcherStatus.Inbounds, "tchannel", "127.0.0.1:4040")
@@ -915,6 +1678,49 @@ func TestIntrospect(t *testing.T) {
 	checkPackageVersion(t, packageNameToVersion, "go", runtime.Version())
 }
 
+func TestSubscribePeerStatus(t *testing.T) {
+	fakeTransport := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := roundrobin.New(fakeTransport)
+	httpOutbound := http.NewTransport().NewOutbound(list)
+
+	dispatcher := NewDispatcher(Config{
+		Name: "test",
+		Outbounds: Outbounds{
+			"peers": {
+				Unary:  httpOutbound,
+				Oneway: httpOutbound,
+			},
+			"single": {
+				Unary: http.NewTransport().NewSingleOutbound("http://127.0.0.1:1234"),
+			},
+		},
+	})
+	require.NoError(t, dispatcher.Start())
+	defer dispatcher.Stop()
+
+	updates := make(chan peer.ConnectionStatus, 1)
+	unsubscribe, err := dispatcher.SubscribePeerStatus("peers", func(id peer.Identifier, status peer.ConnectionStatus) {
+		assert.Equal(t, "127.0.0.1:8080", id.Identifier())
+		updates <- status
+	})
+	require.NoError(t, err)
+	defer unsubscribe()
+
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{hostport.PeerIdentifier("127.0.0.1:8080")}}))
+	select {
+	case status := <-updates:
+		assert.Equal(t, peer.Available, status)
+	case <-time.After(testtime.Second):
+		t.Fatal("timed out waiting for peer status update")
+	}
+
+	_, err = dispatcher.SubscribePeerStatus("single", func(peer.Identifier, peer.ConnectionStatus) {})
+	assert.EqualError(t, err, `outbound key "single" has no peer list that supports peer status subscriptions`)
+
+	_, err = dispatcher.SubscribePeerStatus("unknown", func(peer.Identifier, peer.ConnectionStatus) {})
+	assert.EqualError(t, err, `no configured outbound transport for outbound key "unknown"`)
+}
+
 func getInboundStatus(t *testing.T, inbounds []introspection.InboundStatus, transport string, endpoint string) introspection.InboundStatus {
 	for _, inboundStatus := range inbounds {
 		if inboundStatus.Transport == transport && inboundStatus.Endpoint == endpoint {
@@ -930,3 +1736,250 @@ func checkPackageVersion(t *testing.T, packageNameToVersion map[string]string, k
 	assert.NotEmpty(t, version)
 	assert.Equal(t, expectedVersion, version)
 }
+
+func TestCallGraph(t *testing.T) {
+	httpTransport := http.NewTransport()
+	bar := NewDispatcher(Config{
+		Name:     "bar",
+		Inbounds: Inbounds{httpTransport.NewInbound("127.0.0.1:0")},
+	})
+	echo := func(ctx context.Context, body []byte) ([]byte, error) { return body, nil }
+	bar.Register(raw.Procedure("a", echo))
+	bar.Register(raw.Procedure("b", echo))
+	require.NoError(t, bar.Start())
+	defer bar.Stop()
+
+	// Service foo fans out to bar.
+	addr := bar.Inbounds()[0].(*http.Inbound).Addr().String()
+	foo := NewDispatcher(Config{
+		Name: "foo",
+		Outbounds: Outbounds{
+			"bar": {Unary: httpTransport.NewSingleOutbound("http://" + addr)},
+		},
+	})
+	client := raw.New(foo.ClientConfig("bar"))
+	foo.Register(raw.Procedure("fanout", func(ctx context.Context, body []byte) ([]byte, error) {
+		if _, err := client.Call(ctx, "a", body); err != nil {
+			return nil, err
+		}
+		return client.Call(ctx, "b", body)
+	}))
+	require.NoError(t, foo.Start())
+	defer foo.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	_, err := client.Call(ctx, "a", nil)
+	require.NoError(t, err)
+	assert.Empty(t, CallGraphFromContext(ctx).Edges, "calls must only be recorded for instrumented contexts")
+
+	ctx = InstrumentCallGraph(ctx)
+	req := &transport.Request{
+		Caller:    "caller",
+		Service:   "foo",
+		Procedure: "fanout",
+		Encoding:  raw.Encoding,
+		Body:      bytes.NewReader([]byte("hello")),
+	}
+	spec, err := foo.Router().Choose(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, spec.Unary().Handle(ctx, req, new(transporttest.FakeResponseWriter)))
+	_, err = client.Call(ctx, "b", nil)
+	require.NoError(t, err)
+
+	edges := CallGraphFromContext(ctx).Edges
+	require.Len(t, edges, 3)
+	for i, want := range []CallGraphEdge{
+		{From: "foo/fanout", To: "bar/a"},
+		{From: "foo/fanout", To: "bar/b"},
+		{From: "foo", To: "bar/b"},
+	} {
+		assert.Equal(t, want.From, edges[i].From, "edge %d", i)
+		assert.Equal(t, want.To, edges[i].To, "edge %d", i)
+		assert.True(t, edges[i].Latency > 0, "edge %d must have a latency", i)
+	}
+}
+
+// recordingOutbound records its name each time it is called.
+type recordingOutbound struct {
+	name  string
+	calls *[]string
+}
+
+func (m recordingOutbound) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	*m.calls = append(*m.calls, m.name)
+	return out.Call(ctx, req)
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var calls []string
+	record := func(name string) recordingOutbound {
+		return recordingOutbound{name: name, calls: &calls}
+	}
+
+	chain := middleware.NewChain()
+	chain.MustRegister("inner", record("inner"))
+	chain.MustRegister("tracing", record("tracing"), middleware.First)
+	chain.MustRegister("auth", record("auth"), middleware.Before(ConfigMiddlewareName))
+	// Named inbound middleware do not apply to outbound calls.
+	chain.MustRegister("inbound", middleware.NopUnaryInbound, middleware.First)
+
+	out := transporttest.NewMockUnaryOutbound(mockCtrl)
+	out.EXPECT().Transports().AnyTimes()
+	out.EXPECT().Call(gomock.Any(), gomock.Any()).Return(&transport.Response{}, nil)
+
+	dispatcher := NewDispatcher(Config{
+		Name: "test",
+		Outbounds: Outbounds{
+			"my-test-service": {Unary: out},
+		},
+		OutboundMiddleware: OutboundMiddleware{
+			Unary: UnaryOutboundMiddleware(record("legacy-1"), record("legacy-2")),
+		},
+		MiddlewareChain: chain,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	cc := dispatcher.MustOutboundConfig("my-test-service")
+	_, err := cc.Outbounds.Unary.Call(ctx, &transport.Request{
+		Service:   "my-test-service",
+		Caller:    "test",
+		Procedure: "test",
+		Encoding:  transport.Encoding("test"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tracing", "auth", "legacy-1", "legacy-2", "inner"}, calls)
+
+	// The chain is not modified, so it can be reused.
+	names, err := chain.Names()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tracing", "inbound", "inner", "auth"}, names)
+	assert.NotPanics(t, func() {
+		NewDispatcher(Config{Name: "test", MiddlewareChain: chain})
+	})
+}
+
+func TestPerOutboundMiddleware(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var calls []string
+	record := func(name string) recordingOutbound {
+		return recordingOutbound{name: name, calls: &calls}
+	}
+
+	newOutbound := func() transport.UnaryOutbound {
+		out := transporttest.NewMockUnaryOutbound(mockCtrl)
+		out.EXPECT().Transports().AnyTimes()
+		out.EXPECT().Call(gomock.Any(), gomock.Any()).Return(&transport.Response{}, nil).AnyTimes()
+		return out
+	}
+
+	global := middleware.NewChain()
+	global.MustRegister("tracing", record("tracing"))
+	a := middleware.NewChain()
+	a.MustRegister("counting", record("counting"))
+	a.MustRegister("auth", record("auth"), middleware.First)
+
+	dispatcher := NewDispatcher(Config{
+		Name: "test",
+		Outbounds: Outbounds{
+			"a": {Unary: newOutbound()},
+			"b": {Unary: newOutbound()},
+		},
+		MiddlewareChain:       global,
+		PerOutboundMiddleware: map[string]*middleware.Chain{"a": a},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	call := func(outboundKey string) {
+		_, err := dispatcher.MustOutboundConfig(outboundKey).Outbounds.Unary.Call(ctx, &transport.Request{
+			Service:   outboundKey,
+			Caller:    "test",
+			Procedure: "test",
+			Encoding:  transport.Encoding("test"),
+		})
+		require.NoError(t, err)
+	}
+
+	call("b")
+	call("b")
+	assert.Equal(t, []string{"tracing", "tracing"}, calls, "outbound b must not see the middleware of outbound a")
+
+	calls = nil
+	call("a")
+	assert.Equal(t, []string{"tracing", "auth", "counting"}, calls)
+
+	middlewareOf := make(map[string][]string)
+	for _, status := range dispatcher.Introspect().Outbounds {
+		middlewareOf[status.OutboundKey] = status.Middleware
+	}
+	assert.Equal(t, map[string][]string{
+		"a": {"tracing", "auth", "counting"},
+		"b": {"tracing"},
+	}, middlewareOf)
+}
+
+func TestPerOutboundMiddlewareErrors(t *testing.T) {
+	t.Run("unknown outbound", func(t *testing.T) {
+		chain := middleware.NewChain()
+		chain.MustRegister("a", middleware.NopUnaryOutbound)
+
+		assert.PanicsWithValue(t,
+			`yarpc.NewDispatcher expects an outbound for the middleware of outbound key "missing"`,
+			func() {
+				NewDispatcher(Config{
+					Name:                  "test",
+					PerOutboundMiddleware: map[string]*middleware.Chain{"missing": chain},
+				})
+			})
+	})
+
+	t.Run("conflicting constraints", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		out := transporttest.NewMockUnaryOutbound(mockCtrl)
+		out.EXPECT().Transports().AnyTimes()
+
+		chain := middleware.NewChain()
+		chain.MustRegister("a", middleware.NopUnaryOutbound, middleware.Before("b"))
+		chain.MustRegister("b", middleware.NopUnaryOutbound, middleware.Before("a"))
+
+		assert.PanicsWithValue(t,
+			`yarpc.NewDispatcher expects a valid middleware chain for outbound key "a": conflicting middleware order constraints: a -> b -> a`,
+			func() {
+				NewDispatcher(Config{
+					Name:                  "test",
+					Outbounds:             Outbounds{"a": {Unary: out}},
+					PerOutboundMiddleware: map[string]*middleware.Chain{"a": chain},
+				})
+			})
+	})
+}
+
+func TestMiddlewareChainErrors(t *testing.T) {
+	t.Run("conflicting constraints", func(t *testing.T) {
+		chain := middleware.NewChain()
+		chain.MustRegister("a", middleware.NopUnaryInbound, middleware.Before(ConfigMiddlewareName))
+		chain.MustRegister("b", middleware.NopUnaryInbound, middleware.Before("a"), middleware.After(ConfigMiddlewareName))
+
+		assert.PanicsWithValue(t,
+			"yarpc.NewDispatcher expects a valid middleware chain: conflicting middleware order constraints: config -> b -> a -> config",
+			func() { NewDispatcher(Config{Name: "test", MiddlewareChain: chain}) })
+	})
+
+	t.Run("reserved name", func(t *testing.T) {
+		chain := middleware.NewChain()
+		chain.MustRegister(ConfigMiddlewareName, middleware.NopUnaryInbound)
+
+		assert.PanicsWithValue(t,
+			`yarpc.NewDispatcher expects a valid middleware chain: middleware "config" is already registered`,
+			func() { NewDispatcher(Config{Name: "test", MiddlewareChain: chain}) })
+	})
+}
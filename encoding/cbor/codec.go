@@ -0,0 +1,66 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cbor
+
+import (
+	"io"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+var (
+	_encMode = mustEncMode()
+	_decMode = mustDecMode()
+)
+
+func mustEncMode() cbor.EncMode {
+	em, err := cbor.EncOptions{}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return em
+}
+
+// mustDecMode returns the decoding mode of the encoding, which decodes maps
+// into interface{} values as map[string]interface{} rather than the default
+// map[interface{}]interface{}.
+func mustDecMode() cbor.DecMode {
+	dm, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return dm
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	return _encMode.Marshal(v)
+}
+
+func newEncoder(w io.Writer) *cbor.Encoder {
+	return _encMode.NewEncoder(w)
+}
+
+func newDecoder(r io.Reader) *cbor.Decoder {
+	return _decMode.NewDecoder(r)
+}
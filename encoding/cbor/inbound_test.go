@@ -0,0 +1,208 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cbor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+)
+
+type simpleRequest struct {
+	Name       string           `cbor:"name"`
+	Attributes map[string]int32 `cbor:"attributes"`
+}
+
+type simpleResponse struct {
+	Success bool `cbor:"success"`
+}
+
+func TestHandleStructSuccess(t *testing.T) {
+	h := func(ctx context.Context, body *simpleRequest) (*simpleResponse, error) {
+		assert.Equal(t, "simpleCall", yarpc.CallFromContext(ctx).Procedure())
+		assert.Equal(t, "foo", body.Name)
+		assert.Equal(t, map[string]int32{"bar": 42}, body.Attributes)
+
+		return &simpleResponse{Success: true}, nil
+	}
+
+	handler := newCBORHandler(reflect.TypeOf(&simpleRequest{}), h)
+
+	resw := new(transporttest.FakeResponseWriter)
+	err := handler.Handle(context.Background(), &transport.Request{
+		Procedure: "simpleCall",
+		Encoding:  "cbor",
+		Body: cborBody(t, map[string]interface{}{
+			"name":       "foo",
+			"attributes": map[string]int32{"bar": 42},
+		}),
+	}, resw)
+	require.NoError(t, err)
+
+	var response map[string]interface{}
+	require.NoError(t, cbor.Unmarshal(resw.Body.Bytes(), &response))
+	assert.Equal(t, map[string]interface{}{"success": true}, response)
+}
+
+func TestHandleMapSuccess(t *testing.T) {
+	h := func(ctx context.Context, body map[string]interface{}) (map[string]string, error) {
+		assert.Equal(t, uint64(42), body["foo"])
+		assert.Equal(t, []interface{}{"a", "b", "c"}, body["bar"])
+		assert.Equal(t, map[string]interface{}{"baz": "qux"}, body["nested"], "expected nested maps to have string keys")
+
+		return map[string]string{"success": "true"}, nil
+	}
+
+	handler := newCBORHandler(reflect.TypeOf(map[string]interface{}{}), h)
+
+	resw := new(transporttest.FakeResponseWriter)
+	err := handler.Handle(context.Background(), &transport.Request{
+		Procedure: "foo",
+		Encoding:  "cbor",
+		Body: cborBody(t, map[string]interface{}{
+			"foo":    42,
+			"bar":    []string{"a", "b", "c"},
+			"nested": map[string]string{"baz": "qux"},
+		}),
+	}, resw)
+	require.NoError(t, err)
+
+	var response struct {
+		Success string `cbor:"success"`
+	}
+	require.NoError(t, cbor.Unmarshal(resw.Body.Bytes(), &response))
+	assert.Equal(t, "true", response.Success)
+}
+
+func TestHandleBytesSuccess(t *testing.T) {
+	h := func(ctx context.Context, body []byte) ([]byte, error) {
+		assert.Equal(t, []byte("hello"), body)
+		return []byte("world"), nil
+	}
+
+	handler := newCBORHandler(reflect.TypeOf([]byte(nil)), h)
+
+	resw := new(transporttest.FakeResponseWriter)
+	err := handler.Handle(context.Background(), &transport.Request{
+		Procedure: "echo",
+		Encoding:  "cbor",
+		Body:      cborBody(t, []byte("hello")),
+	}, resw)
+	require.NoError(t, err)
+
+	var response []byte
+	require.NoError(t, cbor.Unmarshal(resw.Body.Bytes(), &response))
+	assert.Equal(t, []byte("world"), response)
+}
+
+func TestHandleInterfaceEmptySuccess(t *testing.T) {
+	h := func(ctx context.Context, body interface{}) (interface{}, error) {
+		return body, nil
+	}
+
+	handler := newCBORHandler(_interfaceEmptyType, h)
+
+	resw := new(transporttest.FakeResponseWriter)
+	err := handler.Handle(context.Background(), &transport.Request{
+		Procedure: "foo",
+		Encoding:  "cbor",
+		Body:      cborBody(t, []string{"a", "b", "c"}),
+	}, resw)
+	require.NoError(t, err)
+
+	var response []string
+	require.NoError(t, cbor.Unmarshal(resw.Body.Bytes(), &response))
+	assert.Equal(t, []string{"a", "b", "c"}, response)
+}
+
+func TestHandleErrors(t *testing.T) {
+	h := func(ctx context.Context, body *simpleRequest) (*simpleResponse, error) {
+		return &simpleResponse{}, errors.New("great sadness")
+	}
+	handler := newCBORHandler(reflect.TypeOf(&simpleRequest{}), h)
+
+	t.Run("wrong encoding", func(t *testing.T) {
+		err := handler.Handle(context.Background(), &transport.Request{
+			Service:   "service",
+			Procedure: "foo",
+			Encoding:  "json",
+			Body:      cborBody(t, &simpleRequest{}),
+		}, new(transporttest.FakeResponseWriter))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `expected encoding "cbor" but got "json"`)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		err := handler.Handle(context.Background(), &transport.Request{
+			Service:   "service",
+			Procedure: "foo",
+			Encoding:  "cbor",
+			Body:      bytes.NewReader([]byte{0xff}),
+		}, new(transporttest.FakeResponseWriter))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `failed to decode "cbor" request body for procedure "foo" of service "service"`)
+	})
+
+	t.Run("application error", func(t *testing.T) {
+		resw := new(transporttest.FakeResponseWriter)
+		err := handler.Handle(context.Background(), &transport.Request{
+			Service:   "service",
+			Procedure: "foo",
+			Encoding:  "cbor",
+			Body:      cborBody(t, &simpleRequest{}),
+		}, resw)
+		assert.EqualError(t, err, "great sadness")
+		assert.True(t, resw.IsApplicationError)
+	})
+}
+
+func TestHandleOneway(t *testing.T) {
+	var got *simpleRequest
+	h := func(ctx context.Context, body *simpleRequest) error {
+		got = body
+		return nil
+	}
+
+	handler := newCBORHandler(reflect.TypeOf(&simpleRequest{}), h)
+	err := handler.HandleOneway(context.Background(), &transport.Request{
+		Procedure: "foo",
+		Encoding:  "cbor",
+		Body:      cborBody(t, &simpleRequest{Name: "foo"}),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "foo", got.Name)
+}
+
+func cborBody(t *testing.T, v interface{}) *bytes.Reader {
+	b, err := cbor.Marshal(v)
+	require.NoError(t, err)
+	return bytes.NewReader(b)
+}
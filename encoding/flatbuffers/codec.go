@@ -0,0 +1,103 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package flatbuffers
+
+import (
+	"errors"
+	"fmt"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+	"google.golang.org/grpc/encoding"
+)
+
+// Packer is implemented by the object API types flatc generates, which
+// serialize themselves into a Builder.
+type Packer interface {
+	Pack(builder *flatbuffers.Builder) flatbuffers.UOffsetT
+}
+
+// Table is implemented by pointers to the table types flatc generates, which
+// read their fields from the bytes of a message in place.
+type Table interface {
+	Init(buf []byte, i flatbuffers.UOffsetT)
+}
+
+var _defaultCodec = NewCodec(func() *flatbuffers.Builder {
+	return flatbuffers.NewBuilder(0)
+})
+
+type codec struct {
+	newBuilder func() *flatbuffers.Builder
+}
+
+// NewCodec returns a gRPC codec for FlatBuffers messages, which may be
+// registered with encoding.RegisterCodec or passed to grpc.ForceCodec.
+//
+// The codec marshals finished Builders and Packers, and unmarshals messages
+// into Tables. Each Packer is serialized into a Builder from builderFn. The
+// marshaled bytes belong to that Builder, so builderFn must not return a
+// Builder that is still in use.
+func NewCodec(builderFn func() *flatbuffers.Builder) encoding.Codec {
+	return codec{newBuilder: builderFn}
+}
+
+func (codec) Name() string {
+	return string(Encoding)
+}
+
+func (c codec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case *flatbuffers.Builder:
+		if v == nil {
+			return nil, errors.New("cannot marshal a nil *flatbuffers.Builder")
+		}
+		return finishedBytes(v)
+	case Packer:
+		builder := c.newBuilder()
+		builder.Finish(v.Pack(builder))
+		return builder.FinishedBytes(), nil
+	default:
+		return nil, fmt.Errorf("cannot marshal %T: expected a *flatbuffers.Builder or a type with a Pack method", v)
+	}
+}
+
+// finishedBytes returns the bytes of a finished Builder, and an error
+// instead of the panic of FinishedBytes if Finish was not called.
+func finishedBytes(builder *flatbuffers.Builder) (b []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return builder.FinishedBytes(), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	table, ok := v.(Table)
+	if !ok {
+		return fmt.Errorf("cannot unmarshal into %T: expected a FlatBuffers table", v)
+	}
+	if len(data) < flatbuffers.SizeUOffsetT {
+		return fmt.Errorf("message of %d bytes is too short to hold a FlatBuffers table", len(data))
+	}
+	table.Init(data, flatbuffers.GetUOffsetT(data))
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package flatbuffers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/prototest/examplepb"
+)
+
+// keyValue is a table with the accessors flatc generates for
+//
+//	table KeyValue {
+//	  key: string;
+//	  value: string;
+//	}
+type keyValue struct {
+	_tab flatbuffers.Table
+}
+
+func (kv *keyValue) Init(buf []byte, i flatbuffers.UOffsetT) {
+	kv._tab.Bytes = buf
+	kv._tab.Pos = i
+}
+
+func (kv *keyValue) Key() []byte {
+	if o := flatbuffers.UOffsetT(kv._tab.Offset(4)); o != 0 {
+		return kv._tab.ByteVector(o + kv._tab.Pos)
+	}
+	return nil
+}
+
+func (kv *keyValue) Value() []byte {
+	if o := flatbuffers.UOffsetT(kv._tab.Offset(6)); o != 0 {
+		return kv._tab.ByteVector(o + kv._tab.Pos)
+	}
+	return nil
+}
+
+// keyValueT is the object API type flatc generates for keyValue.
+type keyValueT struct {
+	Key   string
+	Value string
+}
+
+func (t *keyValueT) Pack(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	key := builder.CreateString(t.Key)
+	value := builder.CreateString(t.Value)
+	builder.StartObject(2)
+	builder.PrependUOffsetTSlot(0, key, 0)
+	builder.PrependUOffsetTSlot(1, value, 0)
+	return builder.EndObject()
+}
+
+func newKeyValueBuilder(key, value string) *flatbuffers.Builder {
+	builder := flatbuffers.NewBuilder(0)
+	builder.Finish((&keyValueT{Key: key, Value: value}).Pack(builder))
+	return builder
+}
+
+func mustMarshal(t testing.TB, v interface{}) []byte {
+	b, err := _defaultCodec.Marshal(v)
+	require.NoError(t, err, "failed to encode %v", v)
+	return b
+}
+
+func TestCodec(t *testing.T) {
+	var builderCalls int
+	codec := NewCodec(func() *flatbuffers.Builder {
+		builderCalls++
+		return flatbuffers.NewBuilder(64)
+	})
+	assert.Equal(t, "flatbuffers", codec.Name())
+
+	t.Run("builder", func(t *testing.T) {
+		b, err := codec.Marshal(newKeyValueBuilder("foo", "bar"))
+		require.NoError(t, err)
+
+		var kv keyValue
+		require.NoError(t, codec.Unmarshal(b, &kv))
+		assert.Equal(t, "foo", string(kv.Key()))
+		assert.Equal(t, "bar", string(kv.Value()))
+		assert.Zero(t, builderCalls, "finished builders must be marshaled as they are")
+	})
+
+	t.Run("packer", func(t *testing.T) {
+		b, err := codec.Marshal(&keyValueT{Key: "foo", Value: "bar"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, builderCalls, "packers must be serialized into a builder from builderFn")
+
+		var kv keyValue
+		require.NoError(t, codec.Unmarshal(b, &kv))
+		assert.Equal(t, "foo", string(kv.Key()))
+		assert.Equal(t, "bar", string(kv.Value()))
+	})
+}
+
+func TestCodecErrors(t *testing.T) {
+	t.Run("nil builder", func(t *testing.T) {
+		_, err := _defaultCodec.Marshal((*flatbuffers.Builder)(nil))
+		assert.EqualError(t, err, "cannot marshal a nil *flatbuffers.Builder")
+	})
+
+	t.Run("unfinished builder", func(t *testing.T) {
+		_, err := _defaultCodec.Marshal(flatbuffers.NewBuilder(0))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Finish")
+	})
+
+	t.Run("unsupported value", func(t *testing.T) {
+		_, err := _defaultCodec.Marshal("foo")
+		assert.EqualError(t, err, "cannot marshal string: expected a *flatbuffers.Builder or a type with a Pack method")
+	})
+
+	t.Run("unsupported target", func(t *testing.T) {
+		var s string
+		err := _defaultCodec.Unmarshal(mustMarshal(t, newKeyValueBuilder("foo", "bar")), &s)
+		assert.EqualError(t, err, "cannot unmarshal into *string: expected a FlatBuffers table")
+	})
+
+	t.Run("short message", func(t *testing.T) {
+		err := _defaultCodec.Unmarshal([]byte{0x01}, &keyValue{})
+		assert.EqualError(t, err, "message of 1 bytes is too short to hold a FlatBuffers table")
+	})
+}
+
+// BenchmarkDecode compares the cost of reading the fields of a 1KB message
+// encoded with FlatBuffers and with Protobuf.
+func BenchmarkDecode(b *testing.B) {
+	value := strings.Repeat("x", 1024)
+
+	b.Run("flatbuffers", func(b *testing.B) {
+		data := mustMarshal(b, newKeyValueBuilder("key", value))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var kv keyValue
+			if err := _defaultCodec.Unmarshal(data, &kv); err != nil {
+				b.Fatal(err)
+			}
+			if !bytes.Equal(kv.Key(), []byte("key")) || len(kv.Value()) != len(value) {
+				b.Fatal("unexpected message")
+			}
+		}
+	})
+
+	b.Run("protobuf", func(b *testing.B) {
+		data, err := proto.Marshal(&examplepb.SetValueRequest{Key: "key", Value: value})
+		require.NoError(b, err)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var req examplepb.SetValueRequest
+			if err := proto.Unmarshal(data, &req); err != nil {
+				b.Fatal(err)
+			}
+			if req.Key != "key" || len(req.Value) != len(value) {
+				b.Fatal("unexpected message")
+			}
+		}
+	})
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package flatbuffers provides the FlatBuffers encoding for YARPC.
+//
+// FlatBuffers messages are read in place: the table types flatc generates
+// read their fields from the serialized bytes when they are accessed, without
+// parsing or copying the message first. This makes decoding cheaper than with
+// Protobuf for latency-sensitive services.
+//
+// Messages are written either with a *flatbuffers.Builder on which Finish was
+// called, or with the object API types flatc generates with
+// --gen-object-api, whose Pack methods serialize them into a Builder.
+//
+// To make outbound requests using this encoding,
+//
+//	client := flatbuffers.New(clientConfig)
+//	var res schema.GetValueResponse
+//	err := client.Call(ctx, "getValue", &schema.GetValueRequestT{Key: "foo"}, &res)
+//	value := res.Value()
+//
+// To register a FlatBuffers procedure, define functions in the format,
+//
+//	f(ctx context.Context, req $reqTable) ($resBody, error)
+//
+// Where '$reqTable' is a pointer to a table type generated by flatc, and
+// '$resBody' is either a *flatbuffers.Builder or a type with a Pack method.
+//
+// Use the Procedure function to build procedures to register against a
+// Router.
+//
+//	dispatcher.Register(flatbuffers.Procedure("getValue", GetValue))
+//
+// Request and response tables refer to the bytes of the messages they were
+// read from, which belong to them and may be retained.
+//
+// The FlatBuffers runtime for Go does not verify messages before they are
+// read, so accessing the fields of a malformed message may panic. YARPC
+// recovers from panics in handlers and fails the request.
+//
+// NewCodec makes the same messages usable with gRPC clients and servers that
+// do not use YARPC.
+package flatbuffers
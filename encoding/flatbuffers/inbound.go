@@ -0,0 +1,100 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package flatbuffers
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+
+	encodingapi "go.uber.org/yarpc/api/encoding"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/pkg/errors"
+)
+
+// flatbuffersHandler adapts a user-provided high-level handler into a
+// transport-level Handler.
+//
+// The wrapped function must already be in the correct format:
+//
+//	f(ctx context.Context, req $reqTable) ($resBody, error)
+type flatbuffersHandler struct {
+	// Type of the request table (not a pointer to the table)
+	reqTableType reflect.Type
+	handler      reflect.Value
+}
+
+func (h flatbuffersHandler) Handle(ctx context.Context, treq *transport.Request, rw transport.ResponseWriter) error {
+	if err := errors.ExpectEncodings(treq, Encoding); err != nil {
+		return err
+	}
+
+	ctx, call := encodingapi.NewInboundCall(ctx)
+	if err := call.ReadFromRequest(treq); err != nil {
+		return err
+	}
+
+	body, err := ioutil.ReadAll(treq.Body)
+	if err != nil {
+		return err
+	}
+
+	reqTable := reflect.New(h.reqTableType)
+	if err := _defaultCodec.Unmarshal(body, reqTable.Interface()); err != nil {
+		return errors.RequestBodyDecodeError(treq, err)
+	}
+
+	results := h.handler.Call([]reflect.Value{reflect.ValueOf(ctx), reqTable})
+
+	if err := call.WriteToResponse(rw); err != nil {
+		return err
+	}
+
+	// we want to return the appErr if it exists as this is what
+	// the JSON encoding does so we deprioritize this error
+	var encodeErr error
+	if result := results[0]; !isNil(result) {
+		resBody, err := _defaultCodec.Marshal(result.Interface())
+		if err == nil {
+			_, err = rw.Write(resBody)
+		}
+		if err != nil {
+			encodeErr = errors.ResponseBodyEncodeError(treq, err)
+		}
+	}
+
+	if appErr, _ := results[1].Interface().(error); appErr != nil {
+		rw.SetApplicationError()
+		return appErr
+	}
+
+	return encodeErr
+}
+
+// isNil reports whether the handler returned no response body.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
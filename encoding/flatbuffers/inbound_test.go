@@ -0,0 +1,145 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package flatbuffers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+)
+
+func TestHandleBuilderSuccess(t *testing.T) {
+	h := func(ctx context.Context, req *keyValue) (*flatbuffers.Builder, error) {
+		assert.Equal(t, "getValue", yarpc.CallFromContext(ctx).Procedure())
+		assert.Equal(t, "foo", string(req.Key()))
+		return newKeyValueBuilder(string(req.Key()), "bar"), nil
+	}
+
+	resw := new(transporttest.FakeResponseWriter)
+	err := wrapUnaryHandler("getValue", h).Handle(context.Background(), &transport.Request{
+		Procedure: "getValue",
+		Encoding:  "flatbuffers",
+		Body:      bytes.NewReader(mustMarshal(t, newKeyValueBuilder("foo", ""))),
+	}, resw)
+	require.NoError(t, err)
+
+	var res keyValue
+	require.NoError(t, _defaultCodec.Unmarshal(resw.Body.Bytes(), &res))
+	assert.Equal(t, "foo", string(res.Key()))
+	assert.Equal(t, "bar", string(res.Value()))
+}
+
+func TestHandlePackerSuccess(t *testing.T) {
+	h := func(ctx context.Context, req *keyValue) (*keyValueT, error) {
+		return &keyValueT{Key: string(req.Key()), Value: "bar"}, nil
+	}
+
+	resw := new(transporttest.FakeResponseWriter)
+	err := wrapUnaryHandler("getValue", h).Handle(context.Background(), &transport.Request{
+		Procedure: "getValue",
+		Encoding:  "flatbuffers",
+		Body:      bytes.NewReader(mustMarshal(t, &keyValueT{Key: "foo"})),
+	}, resw)
+	require.NoError(t, err)
+
+	var res keyValue
+	require.NoError(t, _defaultCodec.Unmarshal(resw.Body.Bytes(), &res))
+	assert.Equal(t, "foo", string(res.Key()))
+	assert.Equal(t, "bar", string(res.Value()))
+}
+
+func TestHandleNilResponse(t *testing.T) {
+	h := func(ctx context.Context, req *keyValue) (*keyValueT, error) {
+		return nil, nil
+	}
+
+	resw := new(transporttest.FakeResponseWriter)
+	err := wrapUnaryHandler("getValue", h).Handle(context.Background(), &transport.Request{
+		Procedure: "getValue",
+		Encoding:  "flatbuffers",
+		Body:      bytes.NewReader(mustMarshal(t, &keyValueT{Key: "foo"})),
+	}, resw)
+	require.NoError(t, err)
+	assert.Zero(t, resw.Body.Len(), "expected no response body")
+}
+
+func TestHandleErrors(t *testing.T) {
+	h := func(ctx context.Context, req *keyValue) (*keyValueT, error) {
+		return &keyValueT{}, errors.New("great sadness")
+	}
+	handler := wrapUnaryHandler("foo", h)
+
+	t.Run("wrong encoding", func(t *testing.T) {
+		err := handler.Handle(context.Background(), &transport.Request{
+			Service:   "service",
+			Procedure: "foo",
+			Encoding:  "json",
+			Body:      bytes.NewReader(mustMarshal(t, &keyValueT{})),
+		}, new(transporttest.FakeResponseWriter))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `expected encoding "flatbuffers" but got "json"`)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		err := handler.Handle(context.Background(), &transport.Request{
+			Service:   "service",
+			Procedure: "foo",
+			Encoding:  "flatbuffers",
+			Body:      bytes.NewReader([]byte{0xff}),
+		}, new(transporttest.FakeResponseWriter))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `failed to decode "flatbuffers" request body for procedure "foo" of service "service"`)
+	})
+
+	t.Run("application error", func(t *testing.T) {
+		resw := new(transporttest.FakeResponseWriter)
+		err := handler.Handle(context.Background(), &transport.Request{
+			Service:   "service",
+			Procedure: "foo",
+			Encoding:  "flatbuffers",
+			Body:      bytes.NewReader(mustMarshal(t, &keyValueT{})),
+		}, resw)
+		assert.EqualError(t, err, "great sadness")
+		assert.True(t, resw.IsApplicationError)
+	})
+
+	t.Run("unfinished builder", func(t *testing.T) {
+		h := func(ctx context.Context, req *keyValue) (*flatbuffers.Builder, error) {
+			return flatbuffers.NewBuilder(0), nil
+		}
+		err := wrapUnaryHandler("foo", h).Handle(context.Background(), &transport.Request{
+			Service:   "service",
+			Procedure: "foo",
+			Encoding:  "flatbuffers",
+			Body:      bytes.NewReader(mustMarshal(t, &keyValueT{})),
+		}, new(transporttest.FakeResponseWriter))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `failed to encode "flatbuffers" response body for procedure "foo" of service "service"`)
+	})
+}
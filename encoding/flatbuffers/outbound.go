@@ -0,0 +1,118 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package flatbuffers
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+
+	"go.uber.org/yarpc"
+	encodingapi "go.uber.org/yarpc/api/encoding"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/pkg/encoding"
+	"go.uber.org/yarpc/pkg/errors"
+)
+
+// Client makes FlatBuffers requests to a single service.
+type Client interface {
+	// Call performs an outbound FlatBuffers request.
+	//
+	// reqBody is a finished *flatbuffers.Builder or a Packer, and resBodyOut
+	// is a Table, like a pointer to a table type generated by flatc, that
+	// reads the response in place.
+	//
+	// Returns an error if the request failed.
+	Call(ctx context.Context, procedure string, reqBody interface{}, resBodyOut Table, opts ...yarpc.CallOption) error
+}
+
+// New builds a new FlatBuffers client.
+func New(c transport.ClientConfig) Client {
+	return flatbuffersClient{cc: c}
+}
+
+func init() {
+	yarpc.RegisterClientBuilder(New)
+}
+
+type flatbuffersClient struct {
+	cc transport.ClientConfig
+}
+
+func (c flatbuffersClient) Call(ctx context.Context, procedure string, reqBody interface{}, resBodyOut Table, opts ...yarpc.CallOption) error {
+	call := encodingapi.NewOutboundCall(encoding.FromOptions(opts)...)
+	treq := transport.Request{
+		Caller:    c.cc.Caller(),
+		Service:   c.cc.Service(),
+		Procedure: procedure,
+		Encoding:  Encoding,
+	}
+
+	ctx, err := call.WriteToRequest(ctx, &treq)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := _defaultCodec.Marshal(reqBody)
+	if err != nil {
+		return errors.RequestBodyEncodeError(&treq, err)
+	}
+
+	treq.Body = bytes.NewReader(encoded)
+	treq.BodySize = len(encoded)
+
+	tres, appErr := c.cc.GetUnaryOutbound().Call(ctx, &treq)
+	if tres == nil {
+		return appErr
+	}
+
+	// we want to return the appErr if it exists as this is what
+	// the JSON encoding does so we deprioritize this error
+	var decodeErr error
+	if _, err = call.ReadFromResponse(ctx, tres); err != nil {
+		decodeErr = err
+	}
+	var resBody []byte
+	if tres.Body != nil {
+		resBody, err = ioutil.ReadAll(tres.Body)
+		if err != nil && decodeErr == nil {
+			decodeErr = err
+		}
+		if err := tres.Body.Close(); err != nil && decodeErr == nil {
+			decodeErr = err
+		}
+	}
+
+	if appErr != nil {
+		// Application errors may come without a response body.
+		if len(resBody) > 0 {
+			_ = _defaultCodec.Unmarshal(resBody, resBodyOut)
+		}
+		return appErr
+	}
+	if decodeErr != nil {
+		return decodeErr
+	}
+	if err := _defaultCodec.Unmarshal(resBody, resBodyOut); err != nil {
+		return errors.ResponseBodyDecodeError(&treq, err)
+	}
+	return nil
+}
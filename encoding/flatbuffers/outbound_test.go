@@ -0,0 +1,174 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package flatbuffers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/internal/clientconfig"
+)
+
+func TestCall(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ctx := context.Background()
+
+	caller := "caller"
+	service := "service"
+
+	tests := []struct {
+		msg             string
+		procedure       string
+		headers         map[string]string
+		body            interface{}
+		encodedResponse []byte
+		responseErr     error
+
+		// whether the outbound receives the request
+		noCall bool
+
+		wantKey     string
+		wantValue   string
+		wantHeaders map[string]string
+		wantErr     string
+	}{
+		{
+			msg:             "builder",
+			procedure:       "foo",
+			body:            newKeyValueBuilder("foo", ""),
+			encodedResponse: mustMarshal(t, &keyValueT{Key: "foo", Value: "bar"}),
+			wantKey:         "foo",
+			wantValue:       "bar",
+		},
+		{
+			msg:             "packer",
+			procedure:       "foo",
+			body:            &keyValueT{Key: "foo"},
+			encodedResponse: mustMarshal(t, &keyValueT{Key: "foo", Value: "bar"}),
+			wantKey:         "foo",
+			wantValue:       "bar",
+		},
+		{
+			msg:             "application error",
+			procedure:       "foo",
+			body:            &keyValueT{Key: "foo"},
+			encodedResponse: mustMarshal(t, &keyValueT{Key: "foo", Value: "bar"}),
+			responseErr:     errors.New("bar"),
+			wantKey:         "foo",
+			wantValue:       "bar",
+			wantErr:         "bar",
+		},
+		{
+			msg:         "application error without body",
+			procedure:   "foo",
+			body:        &keyValueT{Key: "foo"},
+			responseErr: errors.New("bar"),
+			wantErr:     "bar",
+		},
+		{
+			msg:             "invalid response",
+			procedure:       "bar",
+			body:            &keyValueT{},
+			encodedResponse: []byte{0xff},
+			wantErr:         `failed to decode "flatbuffers" response body for procedure "bar" of service "service"`,
+		},
+		{
+			msg:       "invalid request",
+			procedure: "baz",
+			body:      "foo",
+			noCall:    true,
+			wantErr:   `failed to encode "flatbuffers" request body for procedure "baz" of service "service"`,
+		},
+		{
+			msg:             "headers",
+			procedure:       "requestHeaders",
+			headers:         map[string]string{"user-id": "42"},
+			body:            &keyValueT{},
+			encodedResponse: mustMarshal(t, &keyValueT{}),
+			wantHeaders:     map[string]string{"success": "true"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			outbound := transporttest.NewMockUnaryOutbound(mockCtrl)
+			client := New(clientconfig.MultiOutbound(caller, service,
+				transport.Outbounds{
+					Unary: outbound,
+				}))
+
+			if !tt.noCall {
+				outbound.EXPECT().Call(gomock.Any(),
+					transporttest.NewRequestMatcher(t,
+						&transport.Request{
+							Caller:    caller,
+							Service:   service,
+							Procedure: tt.procedure,
+							Encoding:  Encoding,
+							Headers:   transport.HeadersFromMap(tt.headers),
+							Body:      bytes.NewReader(mustMarshal(t, tt.body)),
+						}),
+				).Return(
+					&transport.Response{
+						Body:    ioutil.NopCloser(bytes.NewReader(tt.encodedResponse)),
+						Headers: transport.HeadersFromMap(tt.wantHeaders),
+					}, tt.responseErr)
+			}
+
+			var (
+				opts       []yarpc.CallOption
+				resHeaders map[string]string
+			)
+
+			for k, v := range tt.headers {
+				opts = append(opts, yarpc.WithHeader(k, v))
+			}
+			opts = append(opts, yarpc.ResponseHeaders(&resHeaders))
+
+			var res keyValue
+			err := client.Call(ctx, tt.procedure, tt.body, &res, opts...)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			if tt.wantHeaders != nil {
+				assert.Equal(t, tt.wantHeaders, resHeaders)
+			}
+			if tt.wantKey != "" {
+				assert.Equal(t, tt.wantKey, string(res.Key()))
+				assert.Equal(t, tt.wantValue, string(res.Value()))
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package flatbuffers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+	"go.uber.org/yarpc/api/transport"
+)
+
+var (
+	_ctxType     = reflect.TypeOf((*context.Context)(nil)).Elem()
+	_errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	_tableType   = reflect.TypeOf((*Table)(nil)).Elem()
+	_packerType  = reflect.TypeOf((*Packer)(nil)).Elem()
+	_builderType = reflect.TypeOf((*flatbuffers.Builder)(nil))
+)
+
+// Procedure builds a Procedure from the given FlatBuffers handler. handler
+// must be a function with a signature similar to,
+//
+//	f(ctx context.Context, req $reqTable) ($resBody, error)
+//
+// Where $reqTable is a pointer to a table type generated by flatc, and
+// $resBody is a *flatbuffers.Builder or a type with a Pack method.
+func Procedure(name string, handler interface{}) []transport.Procedure {
+	return []transport.Procedure{
+		{
+			Name: name,
+			HandlerSpec: transport.NewUnaryHandlerSpec(
+				wrapUnaryHandler(name, handler),
+			),
+			Encoding: Encoding,
+		},
+	}
+}
+
+// wrapUnaryHandler takes a valid FlatBuffers handler function and converts
+// it into a transport.UnaryHandler.
+func wrapUnaryHandler(name string, handler interface{}) transport.UnaryHandler {
+	reqTableType := verifyUnarySignature(name, reflect.TypeOf(handler))
+	return flatbuffersHandler{
+		reqTableType: reqTableType.Elem(),
+		handler:      reflect.ValueOf(handler),
+	}
+}
+
+// verifyUnarySignature verifies that the given type matches what we expect
+// from FlatBuffers unary handlers and returns the request type.
+func verifyUnarySignature(n string, t reflect.Type) reflect.Type {
+	if t.Kind() != reflect.Func {
+		panic(fmt.Sprintf(
+			"handler for %q is not a function but a %v", n, t.Kind(),
+		))
+	}
+
+	if t.NumIn() != 2 {
+		panic(fmt.Sprintf(
+			"expected handler for %q to have 2 arguments but it had %v",
+			n, t.NumIn(),
+		))
+	}
+
+	if t.In(0) != _ctxType {
+		panic(fmt.Sprintf(
+			"the first argument of the handler for %q must be of type "+
+				"context.Context, and not: %v", n, t.In(0),
+		))
+	}
+
+	reqTableType := t.In(1)
+	if reqTableType.Kind() != reflect.Ptr || reqTableType.Elem().Kind() != reflect.Struct ||
+		!reqTableType.Implements(_tableType) {
+		panic(fmt.Sprintf(
+			"the second argument of the handler for %q must be "+
+				"a pointer to a FlatBuffers table, and not: %v",
+			n, reqTableType,
+		))
+	}
+
+	if t.NumOut() != 2 {
+		panic(fmt.Sprintf(
+			"expected handler for %q to have 2 results but it had %v",
+			n, t.NumOut(),
+		))
+	}
+
+	if t.Out(1) != _errorType {
+		panic(fmt.Sprintf(
+			"handler for %q must return error as its second result, not %v",
+			n, t.Out(1),
+		))
+	}
+
+	if resBodyType := t.Out(0); resBodyType != _builderType && !resBodyType.Implements(_packerType) {
+		panic(fmt.Sprintf(
+			"the first result of the handler for %q must be "+
+				"a *flatbuffers.Builder or a type with a Pack method, and not: %v",
+			n, resBodyType,
+		))
+	}
+
+	return reqTableType
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package flatbuffers
+
+import (
+	"context"
+	"testing"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapUnaryHandlerInvalid(t *testing.T) {
+	tests := []struct {
+		Name string
+		Func interface{}
+	}{
+		{"empty", func() {}},
+		{"not-a-function", 0},
+		{
+			"wrong-args-in",
+			func(context.Context) (*keyValueT, error) {
+				return nil, nil
+			},
+		},
+		{
+			"wrong-ctx",
+			func(string, *keyValue) (*keyValueT, error) {
+				return nil, nil
+			},
+		},
+		{
+			"non-table-req",
+			func(context.Context, *struct{}) (*keyValueT, error) {
+				return nil, nil
+			},
+		},
+		{
+			"non-pointer-req",
+			func(context.Context, keyValue) (*keyValueT, error) {
+				return nil, nil
+			},
+		},
+		{
+			"wrong-response",
+			func(context.Context, *keyValue) error {
+				return nil
+			},
+		},
+		{
+			"non-packer-res",
+			func(context.Context, *keyValue) (*keyValue, error) {
+				return nil, nil
+			},
+		},
+		{
+			"second-return-value-not-error",
+			func(context.Context, *keyValue) (*keyValueT, *keyValueT) {
+				return nil, nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Panics(t, func() {
+				wrapUnaryHandler(tt.Name, tt.Func)
+			})
+		})
+	}
+}
+
+func TestWrapUnaryHandlerValid(t *testing.T) {
+	tests := []struct {
+		Name string
+		Func interface{}
+	}{
+		{
+			"builder",
+			func(context.Context, *keyValue) (*flatbuffers.Builder, error) {
+				return nil, nil
+			},
+		},
+		{
+			"packer",
+			func(context.Context, *keyValue) (*keyValueT, error) {
+				return nil, nil
+			},
+		},
+		{
+			"packer-interface",
+			func(context.Context, *keyValue) (Packer, error) {
+				return nil, nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			procedures := Procedure(tt.Name, tt.Func)
+			assert.Len(t, procedures, 1)
+			assert.Equal(t, Encoding, procedures[0].Encoding)
+		})
+	}
+}
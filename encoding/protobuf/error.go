@@ -62,8 +62,9 @@ func (err *pberror) Error() string {
 
 // NewError returns a new YARPC protobuf error. To access the error's fields,
 // use the yarpcerrors package APIs for the code and message, and the
-// `GetErrorDetails(error)` function for error details. The `yarpcerrors.Details()`
-// will not work on this error.
+// `GetErrorDetails(error)` or `yarpcerrors.Details(error)` functions for error
+// details. The `Details()` method of `yarpcerrors.Status` will not work on this
+// error.
 //
 // If the Code is CodeOK, this will return nil.
 func NewError(code yarpcerrors.Code, message string, options ...ErrorOption) error {
@@ -223,6 +224,13 @@ func convertFromYARPCError(encoding transport.Encoding, err error, codec *codec)
 	}
 	st := &rpc.Status{}
 	unmarshalErr := unmarshalBytes(encoding, yarpcErr.Details(), st, codec)
+	if unmarshalErr != nil && encoding != Encoding {
+		// Details attached with yarpcerrors.WithDetails are always serialized
+		// as Protobuf, regardless of the encoding of the request.
+		if proto.Unmarshal(yarpcErr.Details(), st) == nil {
+			unmarshalErr = nil
+		}
+	}
 	if unmarshalErr != nil {
 		return unmarshalErr
 	}
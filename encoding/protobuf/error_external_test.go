@@ -45,3 +45,17 @@ func TestGetDetailsFromWrappedError(t *testing.T) {
 	require.Len(t, details, 1, "expected exactly one detail")
 	assert.Equal(t, errDetail, details[0], "unexpected detail")
 }
+
+func TestYARPCErrorsDetailsFromProtobufError(t *testing.T) {
+	errDetail := &types.BytesValue{Value: []byte("err detail bytes")}
+
+	pbErr := protobuf.NewError(
+		yarpcerrors.CodeAborted,
+		"aborted",
+		protobuf.WithErrorDetails(errDetail))
+
+	details, err := yarpcerrors.Details(fmt.Errorf("wrapped: %w", pbErr))
+	require.NoError(t, err, "unexpected error decoding details")
+	require.Len(t, details, 1, "expected exactly one detail")
+	assert.Equal(t, errDetail, details[0], "unexpected detail")
+}
@@ -39,6 +39,8 @@ import (
 	"go.uber.org/yarpc/encoding/protobuf/internal/testpb"
 	"go.uber.org/yarpc/encoding/raw"
 	"go.uber.org/yarpc/transport/grpc"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/transport/tchannel"
 	"go.uber.org/yarpc/yarpcerrors"
 )
 
@@ -324,3 +326,128 @@ func TestJSONGrpcServerErrorDetails(t *testing.T) {
 	assert.Equal(t, expectedDetails, status.Details(), "unexpected error details")
 
 }
+
+func TestYARPCErrorDetailsAcrossTransports(t *testing.T) {
+	details := []proto.Message{
+		&types.StringValue{Value: "string value"},
+		&rpc.RetryInfo{RetryDelay: &types.Duration{Seconds: 1}},
+	}
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T) (transport.Inbound, func() transport.UnaryOutbound)
+	}{
+		{
+			name: "grpc",
+			setup: func(t *testing.T) (transport.Inbound, func() transport.UnaryOutbound) {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				require.NoError(t, err)
+				inbound := grpc.NewTransport().NewInbound(listener)
+				return inbound, func() transport.UnaryOutbound {
+					return grpc.NewTransport().NewSingleOutbound(inbound.Addr().String())
+				}
+			},
+		},
+		{
+			name: "http",
+			setup: func(t *testing.T) (transport.Inbound, func() transport.UnaryOutbound) {
+				inbound := http.NewTransport().NewInbound("127.0.0.1:0")
+				return inbound, func() transport.UnaryOutbound {
+					return http.NewTransport().NewSingleOutbound("http://" + inbound.Addr().String())
+				}
+			},
+		},
+		{
+			name: "tchannel",
+			setup: func(t *testing.T) (transport.Inbound, func() transport.UnaryOutbound) {
+				trans, err := tchannel.NewTransport(
+					tchannel.ServiceName(_serverName),
+					tchannel.ListenAddr("127.0.0.1:0"),
+				)
+				require.NoError(t, err)
+				return trans.NewInbound(), func() transport.UnaryOutbound {
+					clientTrans, err := tchannel.NewTransport(tchannel.ServiceName(_clientName))
+					require.NoError(t, err)
+					return clientTrans.NewSingleOutbound(trans.ListenAddr())
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inbound, newOutbound := tt.setup(t)
+			dispatcher := yarpc.NewDispatcher(yarpc.Config{
+				Name:     _serverName,
+				Inbounds: yarpc.Inbounds{inbound},
+			})
+			dispatcher.Register(raw.Procedure("test", func(context.Context, []byte) ([]byte, error) {
+				return nil, yarpcerrors.WithDetails(
+					yarpcerrors.InvalidArgumentErrorf("error message"), details...)
+			}))
+			require.NoError(t, dispatcher.Start(), "could not start server dispatcher")
+			defer func() { assert.NoError(t, dispatcher.Stop(), "could not stop dispatcher") }()
+
+			clientDispatcher := yarpc.NewDispatcher(yarpc.Config{
+				Name: _clientName,
+				Outbounds: yarpc.Outbounds{
+					_serverName: {Unary: newOutbound()},
+				},
+			})
+			require.NoError(t, clientDispatcher.Start(), "could not start client dispatcher")
+			defer func() { assert.NoError(t, clientDispatcher.Stop(), "could not stop client dispatcher") }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			_, err := raw.New(clientDispatcher.ClientConfig(_serverName)).Call(ctx, "test", nil)
+			require.Error(t, err, "unexpected nil error")
+			assert.Equal(t, yarpcerrors.CodeInvalidArgument, yarpcerrors.FromError(err).Code(), "unexpected error code")
+			assert.Equal(t, "error message", yarpcerrors.FromError(err).Message(), "unexpected error message")
+
+			actualDetails, err := yarpcerrors.Details(err)
+			require.NoError(t, err, "unexpected error decoding details")
+			assert.Equal(t, details, actualDetails, "unexpected error details")
+		})
+	}
+}
+
+func TestYARPCErrorDetailsWithProtobufJSONEncoding(t *testing.T) {
+	inbound := http.NewTransport().NewInbound("127.0.0.1:0")
+	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+		Name:     _serverName,
+		Inbounds: yarpc.Inbounds{inbound},
+	})
+	dispatcher.Register(testpb.BuildTestYARPCProcedures(&yarpcErrorServer{}))
+	require.NoError(t, dispatcher.Start(), "could not start server dispatcher")
+	defer func() { assert.NoError(t, dispatcher.Stop(), "could not stop dispatcher") }()
+
+	clientDispatcher := yarpc.NewDispatcher(yarpc.Config{
+		Name: _clientName,
+		Outbounds: yarpc.Outbounds{
+			_serverName: {
+				Unary: http.NewTransport().NewSingleOutbound("http://" + inbound.Addr().String()),
+			},
+		},
+	})
+	require.NoError(t, clientDispatcher.Start(), "could not start client dispatcher")
+	defer func() { assert.NoError(t, clientDispatcher.Stop(), "could not stop client dispatcher") }()
+
+	client := testpb.NewTestYARPCClient(clientDispatcher.ClientConfig(_serverName), protobuf.UseJSON)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := client.Unary(ctx, &testpb.TestMessage{Value: "error message"})
+	require.Error(t, err, "unexpected nil error")
+	assert.Equal(t, yarpcerrors.CodeInvalidArgument, yarpcerrors.FromError(err).Code(), "unexpected error code")
+	assert.Equal(t, []interface{}{&types.StringValue{Value: "string value"}},
+		protobuf.GetErrorDetails(err), "unexpected error details")
+}
+
+type yarpcErrorServer struct{ errorServer }
+
+func (yarpcErrorServer) Unary(ctx context.Context, msg *testpb.TestMessage) (*testpb.TestMessage, error) {
+	return nil, yarpcerrors.WithDetails(yarpcerrors.InvalidArgumentErrorf(msg.Value),
+		&types.StringValue{Value: "string value"})
+}
@@ -182,10 +182,22 @@ func TestProtobufStreamMetrics(t *testing.T) {
 		wantHistograms := []testutils.HistogramAssertion{
 			{Name: "stream_duration_ms", IgnoreValueCompare: true, ValueLength: 1},
 			{Name: "stream_request_payload_size_bytes", Value: []int64{8}},
+			{Name: "stream_request_total_payload_size_bytes", Value: []int64{8}},
 			{Name: "stream_response_payload_size_bytes", Value: []int64{8}},
+			{Name: "stream_response_total_payload_size_bytes", Value: []int64{8}},
 		}
 		testutils.AssertHistograms(t, wantHistograms, serverMetricsRoot.Snapshot().Histograms)
 	})
+	t.Run("outbound histograms", func(t *testing.T) {
+		wantHistograms := []testutils.HistogramAssertion{
+			{Name: "stream_duration_ms", IgnoreValueCompare: true, ValueLength: 1},
+			{Name: "stream_request_payload_size_bytes", Value: []int64{8}},
+			{Name: "stream_request_total_payload_size_bytes", Value: []int64{8}},
+			{Name: "stream_response_payload_size_bytes", Value: []int64{8}},
+			{Name: "stream_response_total_payload_size_bytes", Value: []int64{8}},
+		}
+		testutils.AssertHistograms(t, wantHistograms, clientMetricsRoot.Snapshot().Histograms)
+	})
 }
 
 func assertLogs(t *testing.T, wantFields []zapcore.Field, logs []observer.LoggedEntry) {
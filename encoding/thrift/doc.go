@@ -93,6 +93,9 @@
 // 	             multiplexing enabled. Equivalent to passing
 // 	             thrift.Multiplexed. This option has no effect if enveloped
 // 	             was not set.
+// 	compact:     Requests and responses will be encoded with the Thrift
+// 	             Compact Protocol. Equivalent to passing
+// 	             thrift.WithCompactProtocol().
 //
 // For example,
 //
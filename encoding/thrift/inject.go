@@ -47,6 +47,8 @@ func ClientBuilderOptions(_ transport.ClientConfig, f reflect.StructField) []Cli
 			opts = append(opts, Enveloped)
 		case "nowire":
 			opts = append(opts, NoWire(true))
+		case "compact":
+			opts = append(opts, WithCompactProtocol())
 		default:
 			// Ignore unknown options
 		}
@@ -27,6 +27,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/encoding/thrift/internal/compact"
 )
 
 type someInterface interface{}
@@ -75,6 +76,15 @@ func TestClientBuilderOptions(t *testing.T) {
 			},
 			want: clientConfig{Enveloping: true, Multiplexed: true},
 		},
+		{
+			desc: "compact",
+			give: reflect.StructField{
+				Name: "Client",
+				Type: _typeOfSomeInterface,
+				Tag:  `service:"keyvalue" thrift:"compact"`,
+			},
+			want: clientConfig{Protocol: compact.Default},
+		},
 		{
 			desc: "ignore unknown",
 			give: reflect.StructField{
@@ -0,0 +1,125 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compact
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"go.uber.org/thriftrw/protocol"
+	"go.uber.org/thriftrw/protocol/binary"
+	"go.uber.org/thriftrw/wire"
+)
+
+var benchmarkProtocols = []struct {
+	name     string
+	protocol protocol.Protocol
+}{
+	{"binary", binary.Default},
+	{"compact", Default},
+}
+
+// benchmarkValue builds a struct resembling a typical RPC payload: a few
+// identifiers and timestamps, some short strings, flags, tags and a list of
+// nested line items.
+func benchmarkValue() wire.Value {
+	items := make([]wire.Value, 10)
+	for i := range items {
+		items[i] = wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+			{ID: 1, Value: wire.NewValueI64(int64(1000 + i))},
+			{ID: 2, Value: wire.NewValueString(fmt.Sprintf("item-%d", i))},
+			{ID: 3, Value: wire.NewValueI32(int32(i + 1))},
+			{ID: 4, Value: wire.NewValueDouble(float64(i) * 1.25)},
+			{ID: 5, Value: wire.NewValueBool(i%2 == 0)},
+		}})
+	}
+
+	tags := []wire.MapItem{
+		{Key: wire.NewValueString("region"), Value: wire.NewValueString("us-east")},
+		{Key: wire.NewValueString("channel"), Value: wire.NewValueString("mobile")},
+		{Key: wire.NewValueString("version"), Value: wire.NewValueString("1.2.3")},
+	}
+
+	return wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+		{ID: 1, Value: wire.NewValueI64(123456789)},
+		{ID: 2, Value: wire.NewValueString("9f7c2a4e-0b1d-4c55-a1e2-3f4d5e6a7b8c")},
+		{ID: 3, Value: wire.NewValueI64(1600000000000)},
+		{ID: 4, Value: wire.NewValueI32(3)},
+		{ID: 5, Value: wire.NewValueBool(true)},
+		{ID: 6, Value: wire.NewValueBool(false)},
+		{ID: 7, Value: wire.NewValueList(wire.ValueListFromSlice(wire.TStruct, items))},
+		{ID: 8, Value: wire.NewValueMap(wire.MapItemListFromSlice(wire.TBinary, wire.TBinary, tags))},
+		{ID: 9, Value: wire.NewValueString("Please leave at the front door.")},
+	}})
+}
+
+func BenchmarkEncode(b *testing.B) {
+	v := benchmarkValue()
+	for _, p := range benchmarkProtocols {
+		b.Run(p.name, func(b *testing.B) {
+			var buf bytes.Buffer
+			if err := p.protocol.Encode(v, &buf); err != nil {
+				b.Fatal(err)
+			}
+			size := buf.Len()
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := p.protocol.Encode(v, &buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(size), "payload-bytes")
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	v := benchmarkValue()
+	for _, p := range benchmarkProtocols {
+		b.Run(p.name, func(b *testing.B) {
+			var buf bytes.Buffer
+			if err := p.protocol.Encode(v, &buf); err != nil {
+				b.Fatal(err)
+			}
+			payload := buf.Bytes()
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				got, err := p.protocol.Decode(bytes.NewReader(payload), wire.TStruct)
+				if err != nil {
+					b.Fatal(err)
+				}
+				// Binary decoding is lazy; force the whole value to be read.
+				if err := wire.EvaluateValue(got); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(payload)), "payload-bytes")
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package compact implements the Apache Thrift Compact Protocol on top of the
+// ThriftRW wire and streaming representations.
+//
+// See https://github.com/apache/thrift/blob/master/doc/specs/thrift-compact-protocol.md
+// for the specification.
+package compact
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math"
+
+	"go.uber.org/thriftrw/protocol"
+	"go.uber.org/thriftrw/protocol/envelope"
+	"go.uber.org/thriftrw/protocol/stream"
+	"go.uber.org/thriftrw/wire"
+)
+
+// Default is the default implementation of the Thrift Compact Protocol.
+var Default = new(Protocol)
+
+// Protocol implements the Thrift Compact Protocol.
+type Protocol struct{}
+
+var (
+	_ protocol.EnvelopeAgnosticProtocol = (*Protocol)(nil)
+	_ stream.Protocol                   = (*Protocol)(nil)
+	_ stream.RequestReader              = (*Protocol)(nil)
+)
+
+// Encode the given Value and write the result to the given Writer.
+func (*Protocol) Encode(v wire.Value, w io.Writer) error {
+	return writeValue(NewStreamWriter(w), v)
+}
+
+// Decode reads a Value of the given type from the given Reader.
+//
+// The Reader must contain exactly one value; trailing bytes are treated as
+// a decode error.
+func (*Protocol) Decode(r io.ReaderAt, t wire.Type) (wire.Value, error) {
+	br := newReaderAt(r)
+	v, err := readValue(NewStreamReader(br), t)
+	if err != nil {
+		return v, err
+	}
+	return v, expectEOF(br)
+}
+
+// Writer builds a stream writer that writes to the provided stream using the
+// Thrift Compact Protocol.
+func (*Protocol) Writer(w io.Writer) stream.Writer {
+	return NewStreamWriter(w)
+}
+
+// Reader builds a stream reader that reads from the provided stream using the
+// Thrift Compact Protocol.
+func (*Protocol) Reader(r io.Reader) stream.Reader {
+	return NewStreamReader(r)
+}
+
+// EncodeEnveloped encodes the enveloped value and writes the result to the
+// given Writer.
+func (*Protocol) EncodeEnveloped(e wire.Envelope, w io.Writer) error {
+	return writeEnveloped(NewStreamWriter(w), e)
+}
+
+// DecodeEnveloped reads an enveloped value from the given Reader.
+// Enveloped values are assumed to be TStructs.
+func (*Protocol) DecodeEnveloped(r io.ReaderAt) (wire.Envelope, error) {
+	br := newReaderAt(r)
+	e, err := readEnveloped(NewStreamReader(br))
+	if err != nil {
+		return e, err
+	}
+	return e, expectEOF(br)
+}
+
+// DecodeRequest specializes Decode and replaces DecodeEnveloped for the
+// purpose of decoding request structs that may or may not have an envelope.
+//
+// Envelopes are told apart from bare structs by their first two bytes: the
+// protocol ID 0x82 followed by the version in the low five bits. A bare
+// struct could only begin this way if its first two fields were booleans with
+// IDs 8 and 10 or higher, with the first one false.
+func (p *Protocol) DecodeRequest(et wire.EnvelopeType, r io.ReaderAt) (wire.Value, envelope.Responder, error) {
+	br := newReaderAt(r)
+	sr := NewStreamReader(br)
+
+	if !isEnvelope(br) {
+		v, err := readValue(sr, wire.TStruct)
+		if err == nil {
+			err = expectEOF(br)
+		}
+		return v, NoEnvelopeResponder, err
+	}
+
+	e, err := readEnveloped(sr)
+	if err == nil {
+		err = expectEOF(br)
+	}
+	if err != nil {
+		return wire.Value{}, NoEnvelopeResponder, err
+	}
+	if e.Type != et {
+		return wire.Value{}, NoEnvelopeResponder, errUnexpectedEnvelopeType(e.Type)
+	}
+	return e.Value, &EnvelopeResponder{Name: e.Name, SeqID: e.SeqID}, nil
+}
+
+// ReadRequest reads off the request envelope (if present) from an io.Reader,
+// populating the provided BodyReader to read off the full request struct,
+// asserting the EnvelopeType (either OneWay or Unary) if an envelope exists.
+// A ResponseWriter that understands the enveloping used is returned.
+//
+// Envelopes are detected the same way as with DecodeRequest.
+func (p *Protocol) ReadRequest(
+	ctx context.Context,
+	et wire.EnvelopeType,
+	r io.Reader,
+	body stream.BodyReader,
+) (stream.ResponseWriter, error) {
+	br := bufio.NewReader(r)
+	sr := NewStreamReader(br)
+
+	if !isEnvelope(br) {
+		if err := body.Decode(sr); err != nil {
+			return NoEnvelopeResponder, err
+		}
+		return NoEnvelopeResponder, expectEOF(br)
+	}
+
+	eh, err := sr.ReadEnvelopeBegin()
+	if err != nil {
+		return NoEnvelopeResponder, err
+	}
+	if eh.Type != et {
+		return NoEnvelopeResponder, errUnexpectedEnvelopeType(eh.Type)
+	}
+	if err := body.Decode(sr); err != nil {
+		return NoEnvelopeResponder, err
+	}
+	if err := sr.ReadEnvelopeEnd(); err != nil {
+		return NoEnvelopeResponder, err
+	}
+	if err := expectEOF(br); err != nil {
+		return NoEnvelopeResponder, err
+	}
+	return &EnvelopeResponder{Name: eh.Name, SeqID: eh.SeqID}, nil
+}
+
+// newReaderAt adapts an io.ReaderAt into a buffered reader positioned at the
+// start of its contents.
+func newReaderAt(r io.ReaderAt) *bufio.Reader {
+	return bufio.NewReader(io.NewSectionReader(r, 0, math.MaxInt64))
+}
+
+func isEnvelope(br *bufio.Reader) bool {
+	bs, err := br.Peek(2)
+	return err == nil && bs[0] == protocolID && bs[1]&versionMask == version
+}
+
+// expectEOF reports a decode error if any bytes remain after a value was
+// read. Payloads encoded with another protocol often appear to decode
+// successfully as a prefix of the payload, so this guards against silently
+// accepting them.
+func expectEOF(br *bufio.Reader) error {
+	_, err := br.ReadByte()
+	switch err {
+	case nil:
+		return decodeErrorf("unexpected bytes after the end of the value")
+	case io.EOF:
+		return nil
+	default:
+		return err
+	}
+}
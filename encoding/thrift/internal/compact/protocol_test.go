@@ -0,0 +1,537 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compact
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"testing"
+
+	athrift "github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/thriftrw/protocol/binary"
+	"go.uber.org/thriftrw/protocol/stream"
+	"go.uber.org/thriftrw/ptr"
+	"go.uber.org/thriftrw/wire"
+	"go.uber.org/yarpc/encoding/thrift/internal"
+)
+
+type compatibilityTest struct {
+	desc string
+	give wire.Value
+	// apache writes the same value using Apache Thrift.
+	apache func(athrift.TProtocol) error
+}
+
+func compatibilityTests() []compatibilityTest {
+	return []compatibilityTest{
+		{
+			desc: "empty struct",
+			give: wire.NewValueStruct(wire.Struct{}),
+			apache: func(p athrift.TProtocol) error {
+				return writeApacheStruct(p)
+			},
+		},
+		{
+			desc: "primitives",
+			give: wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+				{ID: 1, Value: wire.NewValueString("hello")},
+				{ID: 4, Value: wire.NewValueI8(-1)},
+				{ID: 9, Value: wire.NewValueI32(math.MinInt32)},
+				{ID: 11, Value: wire.NewValueI64(math.MaxInt64)},
+				{ID: 12, Value: wire.NewValueI16(-4)},
+				{ID: 13, Value: wire.NewValueDouble(6.5)},
+				{ID: 14, Value: wire.NewValueBinary([]byte{0, 1, 2})},
+			}}),
+			apache: func(p athrift.TProtocol) error {
+				return writeApacheStruct(p,
+					apacheField(p, athrift.STRING, 1, func() error { return p.WriteString("hello") }),
+					apacheField(p, athrift.BYTE, 4, func() error { return p.WriteByte(-1) }),
+					apacheField(p, athrift.I32, 9, func() error { return p.WriteI32(math.MinInt32) }),
+					apacheField(p, athrift.I64, 11, func() error { return p.WriteI64(math.MaxInt64) }),
+					apacheField(p, athrift.I16, 12, func() error { return p.WriteI16(-4) }),
+					apacheField(p, athrift.DOUBLE, 13, func() error { return p.WriteDouble(6.5) }),
+					apacheField(p, athrift.STRING, 14, func() error { return p.WriteBinary([]byte{0, 1, 2}) }),
+				)
+			},
+		},
+		{
+			desc: "bools",
+			give: wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+				{ID: 1, Value: wire.NewValueBool(true)},
+				{ID: 2, Value: wire.NewValueBool(false)},
+			}}),
+			apache: func(p athrift.TProtocol) error {
+				return writeApacheStruct(p,
+					apacheField(p, athrift.BOOL, 1, func() error { return p.WriteBool(true) }),
+					apacheField(p, athrift.BOOL, 2, func() error { return p.WriteBool(false) }),
+				)
+			},
+		},
+		{
+			desc: "collections and nested structs",
+			give: wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+				{ID: 1, Value: wire.NewValueList(wire.ValueListFromSlice(wire.TI32, []wire.Value{
+					wire.NewValueI32(1), wire.NewValueI32(2), wire.NewValueI32(3), wire.NewValueI32(4),
+					wire.NewValueI32(5), wire.NewValueI32(6), wire.NewValueI32(7), wire.NewValueI32(8),
+					wire.NewValueI32(9), wire.NewValueI32(10), wire.NewValueI32(11), wire.NewValueI32(12),
+					wire.NewValueI32(13), wire.NewValueI32(14), wire.NewValueI32(15), wire.NewValueI32(16),
+				}))},
+				{ID: 2, Value: wire.NewValueSet(wire.ValueListFromSlice(wire.TBinary, []wire.Value{
+					wire.NewValueString("foo"),
+				}))},
+				{ID: 3, Value: wire.NewValueMap(wire.MapItemListFromSlice(wire.TI32, wire.TStruct, []wire.MapItem{
+					{
+						Key: wire.NewValueI32(9),
+						Value: wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+							{ID: 1, Value: wire.NewValueBool(true)},
+						}}),
+					},
+				}))},
+				{ID: 4, Value: wire.NewValueList(wire.ValueListFromSlice(wire.TBool, []wire.Value{
+					wire.NewValueBool(true), wire.NewValueBool(false),
+				}))},
+				{ID: 5, Value: wire.NewValueMap(wire.MapItemListFromSlice(wire.TBinary, wire.TI64, nil))},
+			}}),
+			apache: func(p athrift.TProtocol) error {
+				return writeApacheStruct(p,
+					apacheField(p, athrift.LIST, 1, func() error {
+						if err := p.WriteListBegin(athrift.I32, 16); err != nil {
+							return err
+						}
+						for i := int32(1); i <= 16; i++ {
+							if err := p.WriteI32(i); err != nil {
+								return err
+							}
+						}
+						return p.WriteListEnd()
+					}),
+					apacheField(p, athrift.SET, 2, func() error {
+						if err := p.WriteSetBegin(athrift.STRING, 1); err != nil {
+							return err
+						}
+						if err := p.WriteString("foo"); err != nil {
+							return err
+						}
+						return p.WriteSetEnd()
+					}),
+					apacheField(p, athrift.MAP, 3, func() error {
+						if err := p.WriteMapBegin(athrift.I32, athrift.STRUCT, 1); err != nil {
+							return err
+						}
+						if err := p.WriteI32(9); err != nil {
+							return err
+						}
+						if err := writeApacheStruct(p,
+							apacheField(p, athrift.BOOL, 1, func() error { return p.WriteBool(true) }),
+						); err != nil {
+							return err
+						}
+						return p.WriteMapEnd()
+					}),
+					apacheField(p, athrift.LIST, 4, func() error {
+						if err := p.WriteListBegin(athrift.BOOL, 2); err != nil {
+							return err
+						}
+						if err := p.WriteBool(true); err != nil {
+							return err
+						}
+						if err := p.WriteBool(false); err != nil {
+							return err
+						}
+						return p.WriteListEnd()
+					}),
+					apacheField(p, athrift.MAP, 5, func() error {
+						if err := p.WriteMapBegin(athrift.STRING, athrift.I64, 0); err != nil {
+							return err
+						}
+						return p.WriteMapEnd()
+					}),
+				)
+			},
+		},
+		{
+			desc: "field ID zero",
+			give: wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+				{ID: 0, Value: wire.NewValueI64(42)},
+			}}),
+			apache: func(p athrift.TProtocol) error {
+				return writeApacheStruct(p,
+					apacheField(p, athrift.I64, 0, func() error { return p.WriteI64(42) }),
+				)
+			},
+		},
+		{
+			desc: "large and descending field IDs",
+			give: wire.NewValueStruct(wire.Struct{Fields: []wire.Field{
+				{ID: 1, Value: wire.NewValueBool(true)},
+				{ID: 500, Value: wire.NewValueI32(500)},
+				{ID: 100, Value: wire.NewValueBool(false)},
+				{ID: -1, Value: wire.NewValueString("negative")},
+			}}),
+			apache: func(p athrift.TProtocol) error {
+				return writeApacheStruct(p,
+					apacheField(p, athrift.BOOL, 1, func() error { return p.WriteBool(true) }),
+					apacheField(p, athrift.I32, 500, func() error { return p.WriteI32(500) }),
+					apacheField(p, athrift.BOOL, 100, func() error { return p.WriteBool(false) }),
+					apacheField(p, athrift.STRING, -1, func() error { return p.WriteString("negative") }),
+				)
+			},
+		},
+	}
+}
+
+func apacheField(p athrift.TProtocol, t athrift.TType, id int16, write func() error) func() error {
+	return func() error {
+		if err := p.WriteFieldBegin("", t, id); err != nil {
+			return err
+		}
+		if err := write(); err != nil {
+			return err
+		}
+		return p.WriteFieldEnd()
+	}
+}
+
+func writeApacheStruct(p athrift.TProtocol, fields ...func() error) error {
+	if err := p.WriteStructBegin(""); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	if err := p.WriteFieldStop(); err != nil {
+		return err
+	}
+	return p.WriteStructEnd()
+}
+
+func apacheEncode(t *testing.T, write func(athrift.TProtocol) error) []byte {
+	buf := athrift.NewTMemoryBuffer()
+	proto := athrift.NewTCompactProtocol(buf)
+	require.NoError(t, write(proto), "Apache Thrift failed to encode")
+	require.NoError(t, proto.Flush(), "Apache Thrift failed to flush")
+	return buf.Bytes()
+}
+
+func TestApacheThriftCompatibility(t *testing.T) {
+	for _, tt := range compatibilityTests() {
+		t.Run(tt.desc, func(t *testing.T) {
+			want := apacheEncode(t, tt.apache)
+
+			var buf bytes.Buffer
+			require.NoError(t, Default.Encode(tt.give, &buf))
+			assert.Equal(t, want, buf.Bytes(), "encoded bytes must match Apache Thrift")
+
+			got, err := Default.Decode(bytes.NewReader(want), wire.TStruct)
+			require.NoError(t, err)
+
+			// Empty maps lose their key and value types in the compact
+			// protocol, so compare the decoded value by re-encoding it.
+			buf.Reset()
+			require.NoError(t, Default.Encode(got, &buf))
+			assert.Equal(t, want, buf.Bytes(), "decoded value must round trip")
+
+			br := bytes.NewReader(want)
+			require.NoError(t, Default.Reader(br).Skip(wire.TStruct))
+			assert.Zero(t, br.Len(), "Skip left bytes unread")
+		})
+	}
+}
+
+func TestStreamMatchesWire(t *testing.T) {
+	give := &internal.TApplicationException{
+		Message: ptr.String("great sadness"),
+		Type:    internal.ExceptionTypeInternalError.Ptr(),
+	}
+	v, err := give.ToWire()
+	require.NoError(t, err)
+
+	var want bytes.Buffer
+	require.NoError(t, Default.Encode(v, &want))
+
+	var got bytes.Buffer
+	require.NoError(t, give.Encode(Default.Writer(&got)))
+	assert.Equal(t, want.Bytes(), got.Bytes())
+
+	var decoded internal.TApplicationException
+	require.NoError(t, decoded.Decode(Default.Reader(bytes.NewReader(got.Bytes()))))
+	assert.Equal(t, give, &decoded)
+}
+
+func TestEnvelopeCompatibility(t *testing.T) {
+	buf := athrift.NewTMemoryBuffer()
+	proto := athrift.NewTCompactProtocol(buf)
+	require.NoError(t, proto.WriteMessageBegin("hello", athrift.CALL, 42))
+	require.NoError(t, writeApacheStruct(proto,
+		apacheField(proto, athrift.STRING, 1, func() error { return proto.WriteString("hi") }),
+	))
+	require.NoError(t, proto.WriteMessageEnd())
+	require.NoError(t, proto.Flush())
+	want := buf.Bytes()
+
+	body, err := (&internal.TApplicationException{Message: ptr.String("hi")}).ToWire()
+	require.NoError(t, err)
+	give := wire.Envelope{Name: "hello", Type: wire.Call, SeqID: 42, Value: body}
+
+	var out bytes.Buffer
+	require.NoError(t, Default.EncodeEnveloped(give, &out))
+	assert.Equal(t, want, out.Bytes())
+
+	got, err := Default.DecodeEnveloped(bytes.NewReader(want))
+	require.NoError(t, err)
+	assert.Equal(t, give.Name, got.Name)
+	assert.Equal(t, give.Type, got.Type)
+	assert.Equal(t, give.SeqID, got.SeqID)
+	assert.True(t, wire.ValuesAreEqual(give.Value, got.Value), "envelope body mismatch")
+}
+
+type exceptionResponse struct{ internal.TApplicationException }
+
+func (*exceptionResponse) MethodName() string              { return "hello" }
+func (*exceptionResponse) EnvelopeType() wire.EnvelopeType { return wire.Reply }
+
+func TestDecodeRequest(t *testing.T) {
+	give := &internal.TApplicationException{
+		Message: ptr.String("hi"),
+		Type:    internal.ExceptionTypeUnknownMethod.Ptr(),
+	}
+	body, err := give.ToWire()
+	require.NoError(t, err)
+
+	var bare bytes.Buffer
+	require.NoError(t, Default.Encode(body, &bare))
+
+	var enveloped bytes.Buffer
+	require.NoError(t, Default.EncodeEnveloped(wire.Envelope{
+		Name:  "hello",
+		Type:  wire.Call,
+		SeqID: 7,
+		Value: body,
+	}, &enveloped))
+
+	tests := []struct {
+		desc          string
+		give          []byte
+		envelopeType  wire.EnvelopeType
+		wantResponder interface{}
+		wantErr       string
+	}{
+		{
+			desc:          "bare",
+			give:          bare.Bytes(),
+			envelopeType:  wire.Call,
+			wantResponder: NoEnvelopeResponder,
+		},
+		{
+			desc:          "enveloped",
+			give:          enveloped.Bytes(),
+			envelopeType:  wire.Call,
+			wantResponder: &EnvelopeResponder{Name: "hello", SeqID: 7},
+		},
+		{
+			desc:         "unexpected envelope type",
+			give:         enveloped.Bytes(),
+			envelopeType: wire.OneWay,
+			wantErr:      "unexpected envelope type: Call",
+		},
+		{
+			desc:         "trailing bytes",
+			give:         append(append([]byte{}, bare.Bytes()...), 0),
+			envelopeType: wire.Call,
+			wantErr:      "unexpected bytes after the end of the value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Run("wire", func(t *testing.T) {
+				v, responder, err := Default.DecodeRequest(tt.envelopeType, bytes.NewReader(tt.give))
+				if tt.wantErr != "" {
+					require.Error(t, err)
+					assert.Contains(t, err.Error(), tt.wantErr)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantResponder, responder)
+				assert.True(t, wire.ValuesAreEqual(body, v), "request body mismatch")
+			})
+
+			t.Run("stream", func(t *testing.T) {
+				var got internal.TApplicationException
+				responder, err := Default.ReadRequest(context.Background(), tt.envelopeType, bytes.NewReader(tt.give), &got)
+				if tt.wantErr != "" {
+					require.Error(t, err)
+					assert.Contains(t, err.Error(), tt.wantErr)
+					return
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantResponder, responder)
+				assert.Equal(t, give, &got)
+			})
+		})
+	}
+}
+
+func TestResponders(t *testing.T) {
+	res := &exceptionResponse{internal.TApplicationException{Message: ptr.String("bye")}}
+	body, err := res.ToWire()
+	require.NoError(t, err)
+
+	responders := []interface {
+		EncodeResponse(wire.Value, wire.EnvelopeType, io.Writer) error
+		WriteResponse(wire.EnvelopeType, io.Writer, stream.Enveloper) error
+	}{
+		NoEnvelopeResponder,
+		&EnvelopeResponder{Name: "hello", SeqID: 7},
+	}
+	for _, r := range responders {
+		var encoded, written bytes.Buffer
+		require.NoError(t, r.EncodeResponse(body, wire.Reply, &encoded))
+		require.NoError(t, r.WriteResponse(wire.Reply, &written, res))
+		assert.Equal(t, encoded.Bytes(), written.Bytes(), "%T: wire and stream responses differ", r)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, (&EnvelopeResponder{Name: "hello", SeqID: 7}).EncodeResponse(body, wire.Reply, &buf))
+	e, err := Default.DecodeEnveloped(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", e.Name)
+	assert.Equal(t, wire.Reply, e.Type)
+	assert.Equal(t, int32(7), e.SeqID)
+}
+
+func TestBinaryPayloadsFailToDecode(t *testing.T) {
+	for _, tt := range compatibilityTests() {
+		if tt.desc == "empty struct" {
+			// An empty struct is a lone stop byte in both protocols.
+			continue
+		}
+		t.Run(tt.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, binary.Default.Encode(tt.give, &buf))
+
+			_, err := Default.Decode(bytes.NewReader(buf.Bytes()), wire.TStruct)
+			require.Error(t, err)
+			assert.True(t, IsDecodeError(err), "expected a decode error, got %v", err)
+			assert.Contains(t, err.Error(), "may have been encoded with a different Thrift protocol")
+
+			_, _, err = Default.DecodeRequest(wire.Call, bytes.NewReader(buf.Bytes()))
+			require.Error(t, err)
+			assert.True(t, IsDecodeError(err), "expected a decode error, got %v", err)
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    []byte
+		wantErr string
+	}{
+		{
+			desc:    "unknown field type",
+			give:    []byte{0x1d},
+			wantErr: "unknown compact type 0xd",
+		},
+		{
+			desc:    "field ID not encoded as a delta",
+			give:    []byte{0x05, 0x02, 0x02, 0x00},
+			wantErr: "field ID 1 should have been encoded as a delta",
+		},
+		{
+			desc:    "truncated field",
+			give:    []byte{0x15},
+			wantErr: "unexpected end of payload",
+		},
+		{
+			desc:    "i32 out of range",
+			give:    []byte{0x15, 0xff, 0xff, 0xff, 0xff, 0x7f, 0x00},
+			wantErr: "out of range",
+		},
+		{
+			desc:    "varint overflow",
+			give:    []byte{0x16, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x00},
+			wantErr: "invalid varint",
+		},
+		{
+			desc:    "truncated binary",
+			give:    []byte{0x18, 0x05, 'a', 'b'},
+			wantErr: "unexpected end of payload",
+		},
+		{
+			desc:    "invalid bool in list",
+			give:    []byte{0x19, 0x11, 0x03, 0x00},
+			wantErr: "invalid bool value: 3",
+		},
+		{
+			desc:    "invalid map types",
+			give:    []byte{0x1b, 0x01, 0xd5, 0x00},
+			wantErr: "unknown compact type 0xd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := Default.Decode(bytes.NewReader(tt.give), wire.TStruct)
+			require.Error(t, err)
+			assert.True(t, IsDecodeError(err), "expected a decode error, got %v", err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+
+			err = Default.Reader(bytes.NewReader(tt.give)).Skip(wire.TStruct)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestDecodeEnvelopeErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    []byte
+		wantErr string
+	}{
+		{
+			desc:    "binary envelope",
+			give:    []byte{0x80, 0x01, 0x00, 0x01},
+			wantErr: "unexpected protocol ID 0x80",
+		},
+		{
+			desc:    "unknown version",
+			give:    []byte{0x82, 0x22},
+			wantErr: "cannot decode envelope of version: 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := Default.DecodeEnveloped(bytes.NewReader(tt.give))
+			require.Error(t, err)
+			assert.True(t, IsDecodeError(err), "expected a decode error, got %v", err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
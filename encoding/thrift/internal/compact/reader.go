@@ -0,0 +1,475 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compact
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"go.uber.org/thriftrw/protocol/stream"
+	"go.uber.org/thriftrw/wire"
+)
+
+// Binary values larger than this are read incrementally rather than
+// allocated up front, so that a corrupt length cannot exhaust memory.
+const bytesAllocThreshold = 1 << 20
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// StreamReader implements basic logic for reading the Thrift Compact Protocol
+// from an io.Reader.
+type StreamReader struct {
+	reader byteReader
+	buffer [8]byte
+
+	// State of the current struct and of the enclosing structs. Field IDs
+	// are encoded as deltas from the last one.
+	structState
+	enclosing []structState
+
+	// Boolean fields encode their value in the field header, which is held
+	// until ReadBool is called.
+	boolFieldPending bool
+	boolFieldValue   bool
+}
+
+var _ stream.Reader = (*StreamReader)(nil)
+
+// NewStreamReader returns a new StreamReader. The reader is buffered unless
+// it already implements io.ByteReader.
+func NewStreamReader(r io.Reader) *StreamReader {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &StreamReader{reader: br}
+}
+
+func (sr *StreamReader) read(bs []byte) error {
+	_, err := io.ReadFull(sr.reader, bs)
+	return unexpectedEOF(err)
+}
+
+func (sr *StreamReader) readByte() (byte, error) {
+	b, err := sr.reader.ReadByte()
+	return b, unexpectedEOF(err)
+}
+
+func (sr *StreamReader) readUvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(sr.reader)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, decodeErrorf("invalid varint: %v", err)
+	}
+	return v, unexpectedEOF(err)
+}
+
+func (sr *StreamReader) readVarint(min, max int64) (int64, error) {
+	v, err := binary.ReadVarint(sr.reader)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, decodeErrorf("invalid varint: %v", err)
+	}
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	if v < min || v > max {
+		return 0, decodeErrorf("varint %v out of range [%v, %v]", v, min, max)
+	}
+	return v, nil
+}
+
+// readSize reads an unsigned varint length, bounded by math.MaxInt32.
+func (sr *StreamReader) readSize() (int, error) {
+	v, err := sr.readUvarint()
+	if err != nil {
+		return 0, err
+	}
+	if v > math.MaxInt32 {
+		return 0, decodeErrorf("invalid size %v", v)
+	}
+	return int(v), nil
+}
+
+func (sr *StreamReader) discard(n int64) error {
+	_, err := io.CopyN(ioutil.Discard, sr.reader, n)
+	return unexpectedEOF(err)
+}
+
+// ReadBool reads a Thrift encoded bool value. Inside a boolean field, the
+// value was read as part of the field header.
+func (sr *StreamReader) ReadBool() (bool, error) {
+	if sr.boolFieldPending {
+		sr.boolFieldPending = false
+		return sr.boolFieldValue, nil
+	}
+
+	b, err := sr.readByte()
+	if err != nil {
+		return false, err
+	}
+	switch b {
+	case typeBoolTrue:
+		return true, nil
+	case 0, typeBoolFalse:
+		return false, nil
+	default:
+		return false, decodeErrorf("invalid bool value: %v", b)
+	}
+}
+
+// ReadInt8 reads a Thrift encoded int8 value.
+func (sr *StreamReader) ReadInt8() (int8, error) {
+	b, err := sr.readByte()
+	return int8(b), err
+}
+
+// ReadInt16 reads a Thrift encoded int16 value.
+func (sr *StreamReader) ReadInt16() (int16, error) {
+	v, err := sr.readVarint(math.MinInt16, math.MaxInt16)
+	return int16(v), err
+}
+
+// ReadInt32 reads a Thrift encoded int32 value.
+func (sr *StreamReader) ReadInt32() (int32, error) {
+	v, err := sr.readVarint(math.MinInt32, math.MaxInt32)
+	return int32(v), err
+}
+
+// ReadInt64 reads a Thrift encoded int64 value.
+func (sr *StreamReader) ReadInt64() (int64, error) {
+	return sr.readVarint(math.MinInt64, math.MaxInt64)
+}
+
+// ReadString reads a Thrift encoded string.
+func (sr *StreamReader) ReadString() (string, error) {
+	bs, err := sr.ReadBinary()
+	return string(bs), err
+}
+
+// ReadDouble reads a Thrift encoded double, returning a float64.
+func (sr *StreamReader) ReadDouble() (float64, error) {
+	bs := sr.buffer[0:8]
+	if err := sr.read(bs); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(bs)), nil
+}
+
+// ReadBinary reads a Thrift encoded binary type, returning a byte array.
+func (sr *StreamReader) ReadBinary() ([]byte, error) {
+	length, err := sr.readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	if length == 0 {
+		return []byte{}, nil
+	}
+
+	if length > bytesAllocThreshold {
+		var buf bytes.Buffer
+		_, err := io.CopyN(&buf, sr.reader, int64(length))
+		return buf.Bytes(), unexpectedEOF(err)
+	}
+
+	bs := make([]byte, length)
+	return bs, sr.read(bs)
+}
+
+type structState struct {
+	lastFieldID int16
+	readField   bool
+}
+
+// ReadStructBegin reads the "beginning" of a Thrift encoded struct, which
+// resets the field ID deltas for its fields.
+func (sr *StreamReader) ReadStructBegin() error {
+	sr.enclosing = append(sr.enclosing, sr.structState)
+	sr.structState = structState{}
+	return nil
+}
+
+// ReadStructEnd reads the "end" of a Thrift encoded struct. The stop field
+// was already consumed by ReadFieldBegin.
+func (sr *StreamReader) ReadStructEnd() error {
+	if n := len(sr.enclosing); n > 0 {
+		sr.structState = sr.enclosing[n-1]
+		sr.enclosing = sr.enclosing[:n-1]
+	}
+	return nil
+}
+
+// ReadFieldBegin reads off a Thrift encoded field-header returning that and a
+// 'bool' representing whether or not a field-value follows.
+// A 'false' without any error means that it has reached the stop-field.
+func (sr *StreamReader) ReadFieldBegin() (fh stream.FieldHeader, ok bool, err error) {
+	b, err := sr.readByte()
+	if err != nil {
+		return fh, false, err
+	}
+	if b == typeStop {
+		return fh, false, nil
+	}
+
+	t := b & 0x0f
+	if fh.Type, err = wireType(t); err != nil {
+		return fh, false, err
+	}
+
+	if delta := int16(b >> 4); delta != 0 {
+		fh.ID = sr.lastFieldID + delta
+	} else {
+		if fh.ID, err = sr.ReadInt16(); err != nil {
+			return fh, false, err
+		}
+		// Writers only spell out IDs that cannot be encoded as a delta.
+		// Anything else is a strong sign of a payload in another protocol.
+		delta := int(fh.ID) - int(sr.lastFieldID)
+		if delta <= 15 && (delta > 0 || (delta == 0 && sr.readField)) {
+			return fh, false, decodeErrorf("field ID %v should have been encoded as a delta", fh.ID)
+		}
+	}
+	sr.lastFieldID = fh.ID
+	sr.readField = true
+
+	if fh.Type == wire.TBool {
+		sr.boolFieldPending = true
+		sr.boolFieldValue = t == typeBoolTrue
+	}
+	return fh, true, nil
+}
+
+// ReadFieldEnd reads the "end" of a Thrift encoded field. No-op.
+func (sr *StreamReader) ReadFieldEnd() error {
+	return nil
+}
+
+// ReadListBegin reads off the list header of a Thrift encoded list.
+func (sr *StreamReader) ReadListBegin() (lh stream.ListHeader, err error) {
+	lh.Type, lh.Length, err = sr.readCollectionBegin()
+	return lh, err
+}
+
+// ReadListEnd reads the "end" of a Thrift encoded list. No-op.
+func (sr *StreamReader) ReadListEnd() error {
+	return nil
+}
+
+// ReadSetBegin reads off the set header of a Thrift encoded set.
+func (sr *StreamReader) ReadSetBegin() (sh stream.SetHeader, err error) {
+	sh.Type, sh.Length, err = sr.readCollectionBegin()
+	return sh, err
+}
+
+// ReadSetEnd reads the "end" of a Thrift encoded set. No-op.
+func (sr *StreamReader) ReadSetEnd() error {
+	return nil
+}
+
+func (sr *StreamReader) readCollectionBegin() (wire.Type, int, error) {
+	b, err := sr.readByte()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	t, err := wireType(b & 0x0f)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	size := int(b >> 4)
+	if size == 15 {
+		if size, err = sr.readSize(); err != nil {
+			return 0, 0, err
+		}
+	}
+	return t, size, nil
+}
+
+// ReadMapBegin reads off the map header of a Thrift encoded map.
+//
+// Empty maps do not record their key and value types, so both are reported
+// as zero.
+func (sr *StreamReader) ReadMapBegin() (mh stream.MapHeader, err error) {
+	if mh.Length, err = sr.readSize(); err != nil || mh.Length == 0 {
+		return mh, err
+	}
+
+	b, err := sr.readByte()
+	if err != nil {
+		return mh, err
+	}
+	if mh.KeyType, err = wireType(b >> 4); err != nil {
+		return mh, err
+	}
+	mh.ValueType, err = wireType(b & 0x0f)
+	return mh, err
+}
+
+// ReadMapEnd reads the "end" of a Thrift encoded map. No-op.
+func (sr *StreamReader) ReadMapEnd() error {
+	return nil
+}
+
+// ReadEnvelopeBegin reads the start of a compact protocol envelope.
+func (sr *StreamReader) ReadEnvelopeBegin() (stream.EnvelopeHeader, error) {
+	var eh stream.EnvelopeHeader
+
+	id, err := sr.readByte()
+	if err != nil {
+		return eh, err
+	}
+	if id != protocolID {
+		return eh, decodeErrorf("unexpected protocol ID %#x", id)
+	}
+
+	b, err := sr.readByte()
+	if err != nil {
+		return eh, err
+	}
+	if v := b & versionMask; v != version {
+		return eh, decodeErrorf("cannot decode envelope of version: %v", v)
+	}
+	eh.Type = wire.EnvelopeType((b >> typeShift) & typeMask)
+
+	seqID, err := sr.readUvarint()
+	if err != nil {
+		return eh, err
+	}
+	if seqID > math.MaxUint32 {
+		return eh, decodeErrorf("invalid sequence ID %v", seqID)
+	}
+	eh.SeqID = int32(uint32(seqID))
+
+	eh.Name, err = sr.ReadString()
+	return eh, err
+}
+
+// ReadEnvelopeEnd reads the "end" of an envelope. Since there is no real
+// envelope end, this is a no-op.
+func (sr *StreamReader) ReadEnvelopeEnd() error {
+	return nil
+}
+
+// Skip skips over the bytes of the wire type and any applicable headers.
+func (sr *StreamReader) Skip(t wire.Type) error {
+	switch t {
+	case wire.TBool:
+		_, err := sr.ReadBool()
+		return err
+	case wire.TI8:
+		_, err := sr.readByte()
+		return err
+	case wire.TI16:
+		_, err := sr.ReadInt16()
+		return err
+	case wire.TI32:
+		_, err := sr.ReadInt32()
+		return err
+	case wire.TI64:
+		_, err := sr.ReadInt64()
+		return err
+	case wire.TDouble:
+		return sr.discard(8)
+	case wire.TBinary:
+		length, err := sr.readSize()
+		if err != nil {
+			return err
+		}
+		return sr.discard(int64(length))
+	case wire.TStruct:
+		return sr.skipStruct()
+	case wire.TMap:
+		return sr.skipMap()
+	case wire.TSet, wire.TList:
+		return sr.skipList()
+	default:
+		return decodeErrorf("unknown ttype %v", t)
+	}
+}
+
+func (sr *StreamReader) skipStruct() error {
+	if err := sr.ReadStructBegin(); err != nil {
+		return err
+	}
+	for {
+		fh, ok, err := sr.ReadFieldBegin()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if err := sr.Skip(fh.Type); err != nil {
+			return err
+		}
+	}
+	return sr.ReadStructEnd()
+}
+
+func (sr *StreamReader) skipMap() error {
+	mh, err := sr.ReadMapBegin()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < mh.Length; i++ {
+		if err := sr.Skip(mh.KeyType); err != nil {
+			return err
+		}
+		if err := sr.Skip(mh.ValueType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sr *StreamReader) skipList() error {
+	t, size, err := sr.readCollectionBegin()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < size; i++ {
+		if err := sr.Skip(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close is a no-op; the StreamReader holds no resources.
+func (sr *StreamReader) Close() error {
+	return nil
+}
+
+// unexpectedEOF reports all EOFs as decode errors since a value was only
+// partially read.
+func unexpectedEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return decodeErrorf("unexpected end of payload")
+	}
+	return err
+}
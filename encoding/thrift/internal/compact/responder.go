@@ -0,0 +1,83 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compact
+
+import (
+	"io"
+
+	"go.uber.org/thriftrw/protocol/envelope"
+	"go.uber.org/thriftrw/protocol/stream"
+	"go.uber.org/thriftrw/wire"
+)
+
+var (
+	_ envelope.Responder    = NoEnvelopeResponder
+	_ stream.ResponseWriter = NoEnvelopeResponder
+	_ envelope.Responder    = (*EnvelopeResponder)(nil)
+	_ stream.ResponseWriter = (*EnvelopeResponder)(nil)
+)
+
+// noEnvelopeResponder responds to a request without an envelope.
+type noEnvelopeResponder struct{}
+
+func (noEnvelopeResponder) EncodeResponse(v wire.Value, t wire.EnvelopeType, w io.Writer) error {
+	return Default.Encode(v, w)
+}
+
+func (noEnvelopeResponder) WriteResponse(et wire.EnvelopeType, w io.Writer, ev stream.Enveloper) error {
+	return ev.Encode(NewStreamWriter(w))
+}
+
+// NoEnvelopeResponder responds to a request without an envelope.
+var NoEnvelopeResponder = &noEnvelopeResponder{}
+
+// EnvelopeResponder responds to requests with a compact protocol envelope
+// matching the name and sequence ID of the request.
+type EnvelopeResponder struct {
+	Name  string
+	SeqID int32
+}
+
+// EncodeResponse writes the response to the writer using an envelope.
+func (r EnvelopeResponder) EncodeResponse(v wire.Value, t wire.EnvelopeType, w io.Writer) error {
+	return Default.EncodeEnveloped(wire.Envelope{
+		Name:  r.Name,
+		Type:  t,
+		SeqID: r.SeqID,
+		Value: v,
+	}, w)
+}
+
+// WriteResponse writes the response to the writer using an envelope.
+func (r EnvelopeResponder) WriteResponse(et wire.EnvelopeType, w io.Writer, ev stream.Enveloper) error {
+	sw := NewStreamWriter(w)
+	if err := sw.WriteEnvelopeBegin(stream.EnvelopeHeader{
+		Name:  r.Name,
+		Type:  et,
+		SeqID: r.SeqID,
+	}); err != nil {
+		return err
+	}
+	if err := ev.Encode(sw); err != nil {
+		return err
+	}
+	return sw.WriteEnvelopeEnd()
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compact
+
+import (
+	"fmt"
+
+	"go.uber.org/thriftrw/wire"
+)
+
+const (
+	protocolID  = 0x82
+	version     = 1
+	versionMask = 0x1f
+	typeShift   = 5
+	typeMask    = 0x07
+)
+
+// Type identifiers used by the compact protocol for struct fields and
+// collection elements. Boolean fields carry their value in the type
+// identifier.
+const (
+	typeStop byte = iota
+	typeBoolTrue
+	typeBoolFalse
+	typeByte
+	typeI16
+	typeI32
+	typeI64
+	typeDouble
+	typeBinary
+	typeList
+	typeSet
+	typeMap
+	typeStruct
+)
+
+func compactType(t wire.Type) (byte, error) {
+	switch t {
+	case wire.TBool:
+		return typeBoolTrue, nil
+	case wire.TI8:
+		return typeByte, nil
+	case wire.TI16:
+		return typeI16, nil
+	case wire.TI32:
+		return typeI32, nil
+	case wire.TI64:
+		return typeI64, nil
+	case wire.TDouble:
+		return typeDouble, nil
+	case wire.TBinary:
+		return typeBinary, nil
+	case wire.TList:
+		return typeList, nil
+	case wire.TSet:
+		return typeSet, nil
+	case wire.TMap:
+		return typeMap, nil
+	case wire.TStruct:
+		return typeStruct, nil
+	default:
+		return 0, fmt.Errorf("unknown ttype %v", t)
+	}
+}
+
+func wireType(t byte) (wire.Type, error) {
+	switch t {
+	case typeBoolTrue, typeBoolFalse:
+		return wire.TBool, nil
+	case typeByte:
+		return wire.TI8, nil
+	case typeI16:
+		return wire.TI16, nil
+	case typeI32:
+		return wire.TI32, nil
+	case typeI64:
+		return wire.TI64, nil
+	case typeDouble:
+		return wire.TDouble, nil
+	case typeBinary:
+		return wire.TBinary, nil
+	case typeList:
+		return wire.TList, nil
+	case typeSet:
+		return wire.TSet, nil
+	case typeMap:
+		return wire.TMap, nil
+	case typeStruct:
+		return wire.TStruct, nil
+	default:
+		return 0, decodeErrorf("unknown compact type %#x", t)
+	}
+}
+
+type decodeError struct {
+	message string
+}
+
+func (e decodeError) Error() string {
+	return "thrift compact protocol: " + e.message +
+		"; the payload may have been encoded with a different Thrift protocol"
+}
+
+func decodeErrorf(f string, args ...interface{}) error {
+	return decodeError{message: fmt.Sprintf(f, args...)}
+}
+
+// IsDecodeError reports whether the error was caused by a payload that is
+// not valid compact protocol.
+func IsDecodeError(err error) bool {
+	_, ok := err.(decodeError)
+	return ok
+}
+
+type errUnexpectedEnvelopeType wire.EnvelopeType
+
+func (e errUnexpectedEnvelopeType) Error() string {
+	return fmt.Sprintf("unexpected envelope type: %v", wire.EnvelopeType(e))
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compact
+
+import (
+	"go.uber.org/thriftrw/protocol/stream"
+	"go.uber.org/thriftrw/wire"
+)
+
+// Collections are allocated with at most this capacity up front so that a
+// corrupt size cannot exhaust memory before the payload runs out.
+const maxInitialCapacity = 1024
+
+func initialCapacity(size int) int {
+	if size > maxInitialCapacity {
+		return maxInitialCapacity
+	}
+	return size
+}
+
+func writeValue(sw *StreamWriter, v wire.Value) error {
+	switch v.Type() {
+	case wire.TBool:
+		return sw.WriteBool(v.GetBool())
+	case wire.TI8:
+		return sw.WriteInt8(v.GetI8())
+	case wire.TDouble:
+		return sw.WriteDouble(v.GetDouble())
+	case wire.TI16:
+		return sw.WriteInt16(v.GetI16())
+	case wire.TI32:
+		return sw.WriteInt32(v.GetI32())
+	case wire.TI64:
+		return sw.WriteInt64(v.GetI64())
+	case wire.TBinary:
+		return sw.WriteBinary(v.GetBinary())
+	case wire.TStruct:
+		return writeStruct(sw, v.GetStruct())
+	case wire.TMap:
+		return writeMap(sw, v.GetMap())
+	case wire.TSet:
+		s := v.GetSet()
+		if err := sw.WriteSetBegin(stream.SetHeader{Type: s.ValueType(), Length: s.Size()}); err != nil {
+			return err
+		}
+		if err := writeValueList(sw, s); err != nil {
+			return err
+		}
+		return sw.WriteSetEnd()
+	case wire.TList:
+		l := v.GetList()
+		if err := sw.WriteListBegin(stream.ListHeader{Type: l.ValueType(), Length: l.Size()}); err != nil {
+			return err
+		}
+		if err := writeValueList(sw, l); err != nil {
+			return err
+		}
+		return sw.WriteListEnd()
+	default:
+		_, err := compactType(v.Type())
+		return err
+	}
+}
+
+func writeStruct(sw *StreamWriter, s wire.Struct) error {
+	if err := sw.WriteStructBegin(); err != nil {
+		return err
+	}
+	for _, f := range s.Fields {
+		if err := sw.WriteFieldBegin(stream.FieldHeader{ID: f.ID, Type: f.Value.Type()}); err != nil {
+			return err
+		}
+		if err := writeValue(sw, f.Value); err != nil {
+			return err
+		}
+		if err := sw.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+	return sw.WriteStructEnd()
+}
+
+func writeMap(sw *StreamWriter, m wire.MapItemList) error {
+	if err := sw.WriteMapBegin(stream.MapHeader{
+		KeyType:   m.KeyType(),
+		ValueType: m.ValueType(),
+		Length:    m.Size(),
+	}); err != nil {
+		return err
+	}
+	if err := m.ForEach(func(item wire.MapItem) error {
+		if err := writeValue(sw, item.Key); err != nil {
+			return err
+		}
+		return writeValue(sw, item.Value)
+	}); err != nil {
+		return err
+	}
+	return sw.WriteMapEnd()
+}
+
+func writeValueList(sw *StreamWriter, l wire.ValueList) error {
+	return l.ForEach(func(v wire.Value) error {
+		return writeValue(sw, v)
+	})
+}
+
+func writeEnveloped(sw *StreamWriter, e wire.Envelope) error {
+	if err := sw.WriteEnvelopeBegin(stream.EnvelopeHeader{
+		Name:  e.Name,
+		Type:  e.Type,
+		SeqID: e.SeqID,
+	}); err != nil {
+		return err
+	}
+	if err := writeValue(sw, e.Value); err != nil {
+		return err
+	}
+	return sw.WriteEnvelopeEnd()
+}
+
+func readValue(sr *StreamReader, t wire.Type) (wire.Value, error) {
+	switch t {
+	case wire.TBool:
+		b, err := sr.ReadBool()
+		return wire.NewValueBool(b), err
+	case wire.TI8:
+		i, err := sr.ReadInt8()
+		return wire.NewValueI8(i), err
+	case wire.TDouble:
+		d, err := sr.ReadDouble()
+		return wire.NewValueDouble(d), err
+	case wire.TI16:
+		i, err := sr.ReadInt16()
+		return wire.NewValueI16(i), err
+	case wire.TI32:
+		i, err := sr.ReadInt32()
+		return wire.NewValueI32(i), err
+	case wire.TI64:
+		i, err := sr.ReadInt64()
+		return wire.NewValueI64(i), err
+	case wire.TBinary:
+		bs, err := sr.ReadBinary()
+		return wire.NewValueBinary(bs), err
+	case wire.TStruct:
+		s, err := readStruct(sr)
+		return wire.NewValueStruct(s), err
+	case wire.TMap:
+		m, err := readMap(sr)
+		return wire.NewValueMap(m), err
+	case wire.TSet:
+		sh, err := sr.ReadSetBegin()
+		if err != nil {
+			return wire.Value{}, err
+		}
+		vs, err := readValues(sr, sh.Type, sh.Length)
+		if err != nil {
+			return wire.Value{}, err
+		}
+		return wire.NewValueSet(wire.ValueListFromSlice(sh.Type, vs)), sr.ReadSetEnd()
+	case wire.TList:
+		lh, err := sr.ReadListBegin()
+		if err != nil {
+			return wire.Value{}, err
+		}
+		vs, err := readValues(sr, lh.Type, lh.Length)
+		if err != nil {
+			return wire.Value{}, err
+		}
+		return wire.NewValueList(wire.ValueListFromSlice(lh.Type, vs)), sr.ReadListEnd()
+	default:
+		return wire.Value{}, decodeErrorf("unknown ttype %v", t)
+	}
+}
+
+func readStruct(sr *StreamReader) (wire.Struct, error) {
+	var s wire.Struct
+	if err := sr.ReadStructBegin(); err != nil {
+		return s, err
+	}
+	for {
+		fh, ok, err := sr.ReadFieldBegin()
+		if err != nil {
+			return s, err
+		}
+		if !ok {
+			break
+		}
+		v, err := readValue(sr, fh.Type)
+		if err != nil {
+			return s, err
+		}
+		s.Fields = append(s.Fields, wire.Field{ID: fh.ID, Value: v})
+		if err := sr.ReadFieldEnd(); err != nil {
+			return s, err
+		}
+	}
+	return s, sr.ReadStructEnd()
+}
+
+func readMap(sr *StreamReader) (wire.MapItemList, error) {
+	mh, err := sr.ReadMapBegin()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]wire.MapItem, 0, initialCapacity(mh.Length))
+	for i := 0; i < mh.Length; i++ {
+		k, err := readValue(sr, mh.KeyType)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readValue(sr, mh.ValueType)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, wire.MapItem{Key: k, Value: v})
+	}
+	return wire.MapItemListFromSlice(mh.KeyType, mh.ValueType, items), sr.ReadMapEnd()
+}
+
+func readValues(sr *StreamReader, t wire.Type, size int) ([]wire.Value, error) {
+	vs := make([]wire.Value, 0, initialCapacity(size))
+	for i := 0; i < size; i++ {
+		v, err := readValue(sr, t)
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+func readEnveloped(sr *StreamReader) (wire.Envelope, error) {
+	var e wire.Envelope
+
+	eh, err := sr.ReadEnvelopeBegin()
+	if err != nil {
+		return e, err
+	}
+	e.Name = eh.Name
+	e.Type = eh.Type
+	e.SeqID = eh.SeqID
+
+	if e.Value, err = readValue(sr, wire.TStruct); err != nil {
+		return e, err
+	}
+	return e, sr.ReadEnvelopeEnd()
+}
@@ -0,0 +1,270 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compact
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"go.uber.org/thriftrw/protocol/stream"
+	"go.uber.org/thriftrw/wire"
+)
+
+// StreamWriter implements basic logic for writing the Thrift Compact Protocol
+// to an io.Writer.
+type StreamWriter struct {
+	writer io.Writer
+	buffer [binary.MaxVarintLen64]byte
+
+	// ID of the last field written in the current struct and of the
+	// enclosing structs. Field IDs are encoded as deltas from the last one.
+	lastFieldID  int16
+	lastFieldIDs []int16
+
+	// Boolean fields encode their value in the field header, so the header
+	// is held back until WriteBool is called.
+	boolFieldPending bool
+	boolFieldID      int16
+}
+
+var _ stream.Writer = (*StreamWriter)(nil)
+
+// NewStreamWriter returns a new StreamWriter.
+func NewStreamWriter(w io.Writer) *StreamWriter {
+	return &StreamWriter{writer: w}
+}
+
+func (sw *StreamWriter) write(bs []byte) error {
+	_, err := sw.writer.Write(bs)
+	return err
+}
+
+func (sw *StreamWriter) writeByte(b byte) error {
+	sw.buffer[0] = b
+	return sw.write(sw.buffer[0:1])
+}
+
+func (sw *StreamWriter) writeUvarint(v uint64) error {
+	n := binary.PutUvarint(sw.buffer[:], v)
+	return sw.write(sw.buffer[:n])
+}
+
+func (sw *StreamWriter) writeVarint(v int64) error {
+	n := binary.PutVarint(sw.buffer[:], v)
+	return sw.write(sw.buffer[:n])
+}
+
+// WriteBool writes a Thrift encoded bool value. Inside a boolean field, the
+// value is written as part of the field header.
+func (sw *StreamWriter) WriteBool(b bool) error {
+	t := typeBoolFalse
+	if b {
+		t = typeBoolTrue
+	}
+	if sw.boolFieldPending {
+		sw.boolFieldPending = false
+		return sw.writeFieldHeader(t, sw.boolFieldID)
+	}
+	return sw.writeByte(t)
+}
+
+// WriteInt8 writes a Thrift encoded int8 value.
+func (sw *StreamWriter) WriteInt8(i int8) error {
+	return sw.writeByte(byte(i))
+}
+
+// WriteInt16 writes a Thrift encoded int16 value as a zigzag varint.
+func (sw *StreamWriter) WriteInt16(i int16) error {
+	return sw.writeVarint(int64(i))
+}
+
+// WriteInt32 writes a Thrift encoded int32 value as a zigzag varint.
+func (sw *StreamWriter) WriteInt32(i int32) error {
+	return sw.writeVarint(int64(i))
+}
+
+// WriteInt64 writes a Thrift encoded int64 value as a zigzag varint.
+func (sw *StreamWriter) WriteInt64(i int64) error {
+	return sw.writeVarint(i)
+}
+
+// WriteString writes a Thrift encoded string.
+func (sw *StreamWriter) WriteString(s string) error {
+	if err := sw.writeUvarint(uint64(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(sw.writer, s)
+	return err
+}
+
+// WriteDouble writes a Thrift encoded double in little-endian order.
+func (sw *StreamWriter) WriteDouble(d float64) error {
+	bs := sw.buffer[0:8]
+	binary.LittleEndian.PutUint64(bs, math.Float64bits(d))
+	return sw.write(bs)
+}
+
+// WriteBinary writes a Thrift encoded binary value.
+func (sw *StreamWriter) WriteBinary(b []byte) error {
+	if err := sw.writeUvarint(uint64(len(b))); err != nil {
+		return err
+	}
+	return sw.write(b)
+}
+
+// WriteFieldBegin writes the header of a field. The header of a boolean field
+// is written by the following call to WriteBool.
+func (sw *StreamWriter) WriteFieldBegin(f stream.FieldHeader) error {
+	if f.Type == wire.TBool {
+		sw.boolFieldPending = true
+		sw.boolFieldID = f.ID
+		return nil
+	}
+
+	t, err := compactType(f.Type)
+	if err != nil {
+		return err
+	}
+	return sw.writeFieldHeader(t, f.ID)
+}
+
+func (sw *StreamWriter) writeFieldHeader(t byte, id int16) error {
+	delta := int(id) - int(sw.lastFieldID)
+	sw.lastFieldID = id
+	if delta > 0 && delta <= 15 {
+		return sw.writeByte(byte(delta<<4) | t)
+	}
+	if err := sw.writeByte(t); err != nil {
+		return err
+	}
+	return sw.WriteInt16(id)
+}
+
+// WriteFieldEnd denotes the end of a field. No-op.
+func (sw *StreamWriter) WriteFieldEnd() error {
+	return nil
+}
+
+// WriteStructBegin denotes the beginning of a struct, which resets the field
+// ID deltas for its fields.
+func (sw *StreamWriter) WriteStructBegin() error {
+	sw.lastFieldIDs = append(sw.lastFieldIDs, sw.lastFieldID)
+	sw.lastFieldID = 0
+	return nil
+}
+
+// WriteStructEnd uses the zero byte to mark the end of a struct.
+func (sw *StreamWriter) WriteStructEnd() error {
+	if n := len(sw.lastFieldIDs); n > 0 {
+		sw.lastFieldID = sw.lastFieldIDs[n-1]
+		sw.lastFieldIDs = sw.lastFieldIDs[:n-1]
+	}
+	return sw.writeByte(typeStop)
+}
+
+// WriteListBegin marks the beginning of a new list. Lists with fewer than 15
+// items store their size alongside the item type in a single byte.
+func (sw *StreamWriter) WriteListBegin(l stream.ListHeader) error {
+	return sw.writeCollectionBegin(l.Type, l.Length)
+}
+
+// WriteListEnd marks the end of a list. No-op.
+func (sw *StreamWriter) WriteListEnd() error {
+	return nil
+}
+
+// WriteSetBegin marks the beginning of a new set. Sets are encoded like
+// lists.
+func (sw *StreamWriter) WriteSetBegin(s stream.SetHeader) error {
+	return sw.writeCollectionBegin(s.Type, s.Length)
+}
+
+// WriteSetEnd marks the end of a set. No-op.
+func (sw *StreamWriter) WriteSetEnd() error {
+	return nil
+}
+
+func (sw *StreamWriter) writeCollectionBegin(et wire.Type, length int) error {
+	t, err := compactType(et)
+	if err != nil {
+		return err
+	}
+	if length < 15 {
+		return sw.writeByte(byte(length<<4) | t)
+	}
+	if err := sw.writeByte(0xf0 | t); err != nil {
+		return err
+	}
+	return sw.writeUvarint(uint64(length))
+}
+
+// WriteMapBegin marks the beginning of a new map. Empty maps are written as
+// a single zero byte, omitting the key and value types.
+func (sw *StreamWriter) WriteMapBegin(m stream.MapHeader) error {
+	if m.Length == 0 {
+		return sw.writeByte(0)
+	}
+
+	kt, err := compactType(m.KeyType)
+	if err != nil {
+		return err
+	}
+	vt, err := compactType(m.ValueType)
+	if err != nil {
+		return err
+	}
+	if err := sw.writeUvarint(uint64(m.Length)); err != nil {
+		return err
+	}
+	return sw.writeByte(kt<<4 | vt)
+}
+
+// WriteMapEnd marks the end of a map. No-op.
+func (sw *StreamWriter) WriteMapEnd() error {
+	return nil
+}
+
+// WriteEnvelopeBegin writes the start of an envelope: the protocol ID, the
+// version and message type, the sequence ID and the message name.
+func (sw *StreamWriter) WriteEnvelopeBegin(eh stream.EnvelopeHeader) error {
+	if err := sw.writeByte(protocolID); err != nil {
+		return err
+	}
+	if err := sw.writeByte(version | byte(eh.Type)<<typeShift); err != nil {
+		return err
+	}
+	if err := sw.writeUvarint(uint64(uint32(eh.SeqID))); err != nil {
+		return err
+	}
+	return sw.WriteString(eh.Name)
+}
+
+// WriteEnvelopeEnd writes the "end" of an envelope. Since there is no ending
+// to an envelope, this is a no-op.
+func (sw *StreamWriter) WriteEnvelopeEnd() error {
+	return nil
+}
+
+// Close is a no-op; the StreamWriter holds no resources.
+func (sw *StreamWriter) Close() error {
+	return nil
+}
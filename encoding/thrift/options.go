@@ -20,7 +20,10 @@
 
 package thrift
 
-import "go.uber.org/thriftrw/protocol"
+import (
+	"go.uber.org/thriftrw/protocol"
+	"go.uber.org/yarpc/encoding/thrift/internal/compact"
+)
 
 type clientConfig struct {
 	ServiceName string
@@ -157,6 +160,26 @@ func Protocol(p protocol.Protocol) Option {
 	return protocolOption{Protocol: p}
 }
 
+// WithCompactProtocol is an option that specifies that servers and clients
+// should use the Thrift Compact Protocol instead of the Binary Protocol. The
+// compact protocol encodes integers as varints and field IDs as deltas, which
+// usually produces smaller payloads at a slightly higher CPU cost.
+//
+// It may be specified on the client side when the client is constructed,
+//
+// 	client := myserviceclient.New(clientConfig, thrift.WithCompactProtocol())
+//
+// and on the server side when the handler is registered.
+//
+// 	dispatcher.Register(myserviceserver.New(handler, thrift.WithCompactProtocol()))
+//
+// Both sides must agree on the protocol. Requests or responses encoded with
+// the other protocol fail to decode with an error rather than being
+// misinterpreted.
+func WithCompactProtocol() Option {
+	return protocolOption{Protocol: compact.Default}
+}
+
 // NoWire is an option that specifies to *not* use the thriftrw.Wire
 // intermediary format. Note that if this is enabled, and a
 // 'protocol.Protocol' is provided (e.g., via thrift.Protocol) that provided
@@ -78,18 +78,25 @@ func TestRoundTrip(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		name := fmt.Sprintf("enveloped(%v)/multiplexed(%v)/nowireServer(%v)/nowireClient(%v)", tt.enveloped, tt.multiplexed, tt.nowireServer, tt.nowireClient)
-		t.Run(name, func(t *testing.T) { testRoundTrip(t, tt.enveloped, tt.multiplexed, tt.nowireServer, tt.nowireClient) })
+		for _, compact := range []bool{false, true} {
+			name := fmt.Sprintf("enveloped(%v)/multiplexed(%v)/nowireServer(%v)/nowireClient(%v)/compact(%v)", tt.enveloped, tt.multiplexed, tt.nowireServer, tt.nowireClient, compact)
+			t.Run(name, func(t *testing.T) {
+				testRoundTrip(t, tt.enveloped, tt.multiplexed, tt.nowireServer, tt.nowireClient, compact)
+			})
+		}
 	}
 }
 
-func testRoundTrip(t *testing.T, enveloped, multiplexed, nowireServer, nowireClient bool) {
+func testRoundTrip(t *testing.T, enveloped, multiplexed, nowireServer, nowireClient, compact bool) {
 	t.Helper()
 
 	var serverOpts []thrift.RegisterOption
 	if enveloped {
 		serverOpts = append(serverOpts, thrift.Enveloped)
 	}
+	if compact {
+		serverOpts = append(serverOpts, thrift.WithCompactProtocol())
+	}
 	serverOpts = append(serverOpts, thrift.NoWire(nowireServer))
 
 	var clientOpts []string
@@ -102,6 +109,9 @@ func testRoundTrip(t *testing.T, enveloped, multiplexed, nowireServer, nowireCli
 	if nowireClient {
 		clientOpts = append(clientOpts, "nowire")
 	}
+	if compact {
+		clientOpts = append(clientOpts, "compact")
+	}
 
 	var thriftTag string
 	if len(clientOpts) > 0 {
@@ -344,6 +354,71 @@ func testRoundTrip(t *testing.T, enveloped, multiplexed, nowireServer, nowireCli
 	}
 }
 
+func TestProtocolMismatch(t *testing.T) {
+	tests := []struct {
+		desc          string
+		serverOpts    []thrift.RegisterOption
+		clientOpts    []thrift.ClientOption
+		wantErrSubstr string
+	}{
+		{
+			desc:          "compact client, binary server",
+			clientOpts:    []thrift.ClientOption{thrift.WithCompactProtocol()},
+			wantErrSubstr: "unknown ttype",
+		},
+		{
+			desc:          "binary client, compact server",
+			serverOpts:    []thrift.RegisterOption{thrift.WithCompactProtocol()},
+			wantErrSubstr: "the payload may have been encoded with a different Thrift protocol",
+		},
+		{
+			desc:          "compact client, binary server without nowire",
+			serverOpts:    []thrift.RegisterOption{thrift.NoWire(false)},
+			clientOpts:    []thrift.ClientOption{thrift.WithCompactProtocol(), thrift.NoWire(false)},
+			wantErrSubstr: "unknown ttype",
+		},
+		{
+			desc:          "binary client, compact server without nowire",
+			serverOpts:    []thrift.RegisterOption{thrift.WithCompactProtocol(), thrift.NoWire(false)},
+			clientOpts:    []thrift.ClientOption{thrift.NoWire(false)},
+			wantErrSubstr: "the payload may have been encoded with a different Thrift protocol",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			httpInbound := http.NewTransport().NewInbound("127.0.0.1:0")
+			server := yarpc.NewDispatcher(yarpc.Config{
+				Name:     "roundtrip-server",
+				Inbounds: yarpc.Inbounds{httpInbound},
+			})
+			server.Register(storeserver.New(&storeHandler{integer: 42}, tt.serverOpts...))
+			require.NoError(t, server.Start())
+			defer server.Stop()
+
+			outbound := http.NewTransport().NewSingleOutbound(
+				fmt.Sprintf("http://%v", yarpctest.ZeroAddrToHostPort(httpInbound.Addr())))
+			dispatcher := yarpc.NewDispatcher(yarpc.Config{
+				Name: "roundtrip-client",
+				Outbounds: yarpc.Outbounds{
+					"roundtrip-server": {Unary: outbound},
+				},
+			})
+			require.NoError(t, dispatcher.Start())
+			defer dispatcher.Stop()
+
+			client := storeclient.New(dispatcher.ClientConfig("roundtrip-server"), tt.clientOpts...)
+
+			ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+			defer cancel()
+
+			_, err := client.Integer(ctx, ptr.String("foo"))
+			require.Error(t, err, "expected protocol mismatch to fail")
+			assert.Contains(t, err.Error(), tt.wantErrSubstr)
+		})
+	}
+}
+
 func values(xs ...interface{}) []reflect.Value {
 	vs := make([]reflect.Value, len(xs))
 	for i, x := range xs {
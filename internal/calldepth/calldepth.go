@@ -0,0 +1,57 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package calldepth carries the depth of a chain of RPCs from the handler of
+// an inbound request to the outbound calls it makes.
+package calldepth
+
+import (
+	"context"
+	"strconv"
+)
+
+// Header is the application header that carries the call depth of a
+// request: the number of calls in the chain that led to it.
+const Header = "rpc-call-depth"
+
+type contextKey struct{}
+
+// WithDepth returns a context that carries the call depth for outbound
+// calls made with it.
+func WithDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, contextKey{}, depth)
+}
+
+// FromContext returns the call depth for outbound calls made with the
+// context, if any.
+func FromContext(ctx context.Context) (depth int, ok bool) {
+	depth, ok = ctx.Value(contextKey{}).(int)
+	return depth, ok
+}
+
+// HeaderValue returns the value of the call depth header for outbound calls
+// made with the context, if any.
+func HeaderValue(ctx context.Context) (string, bool) {
+	depth, ok := FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return strconv.Itoa(depth), true
+}
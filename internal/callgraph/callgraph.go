@@ -0,0 +1,132 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package callgraph records the outbound calls made on behalf of a request,
+// for the context of the request.
+package callgraph
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/encoding"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+)
+
+// Edge is a call from a procedure to another.
+type Edge struct {
+	From    string
+	To      string
+	Latency time.Duration
+}
+
+// Graph collects the edges of the calls made with a context.
+type Graph struct {
+	lock  sync.Mutex
+	edges []Edge
+}
+
+// Add records an edge.
+func (g *Graph) Add(e Edge) {
+	g.lock.Lock()
+	g.edges = append(g.edges, e)
+	g.lock.Unlock()
+}
+
+// Edges returns a copy of the edges recorded so far, in the order the calls
+// finished.
+func (g *Graph) Edges() []Edge {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return append([]Edge(nil), g.edges...)
+}
+
+type graphKey struct{}
+
+// WithGraph returns a context that records the calls made with it, and with
+// the contexts derived from it, in a new graph.
+func WithGraph(ctx context.Context) context.Context {
+	return context.WithValue(ctx, graphKey{}, &Graph{})
+}
+
+// FromContext returns the graph recording the calls made with the context,
+// or nil.
+func FromContext(ctx context.Context) *Graph {
+	g, _ := ctx.Value(graphKey{}).(*Graph)
+	return g
+}
+
+var (
+	_ middleware.UnaryOutbound  = (*Middleware)(nil)
+	_ middleware.OnewayOutbound = (*Middleware)(nil)
+)
+
+// Middleware is outbound middleware that records calls made with contexts
+// from WithGraph in their graph.
+type Middleware struct {
+	now func() time.Time
+}
+
+// New returns middleware that records calls in call graphs.
+func New() *Middleware {
+	return &Middleware{now: time.Now}
+}
+
+// Call implements middleware.UnaryOutbound.
+func (m *Middleware) Call(ctx context.Context, req *transport.Request, next transport.UnaryOutbound) (*transport.Response, error) {
+	g := FromContext(ctx)
+	if g == nil {
+		return next.Call(ctx, req)
+	}
+
+	start := m.now()
+	res, err := next.Call(ctx, req)
+	g.Add(m.edge(ctx, req, start))
+	return res, err
+}
+
+// CallOneway implements middleware.OnewayOutbound.
+func (m *Middleware) CallOneway(ctx context.Context, req *transport.Request, next transport.OnewayOutbound) (transport.Ack, error) {
+	g := FromContext(ctx)
+	if g == nil {
+		return next.CallOneway(ctx, req)
+	}
+
+	start := m.now()
+	ack, err := next.CallOneway(ctx, req)
+	g.Add(m.edge(ctx, req, start))
+	return ack, err
+}
+
+// edge describes a call from the procedure handling the current request, if
+// any, to the procedure of the request.
+func (m *Middleware) edge(ctx context.Context, req *transport.Request, start time.Time) Edge {
+	from := req.Caller
+	if procedure := encoding.CallFromContext(ctx).Procedure(); procedure != "" {
+		from += "/" + procedure
+	}
+	return Edge{
+		From:    from,
+		To:      req.Service + "/" + req.Procedure,
+		Latency: m.now().Sub(start),
+	}
+}
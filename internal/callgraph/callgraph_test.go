@@ -0,0 +1,73 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package callgraph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/encoding"
+	"go.uber.org/yarpc/api/transport"
+)
+
+type fakeOutbound struct {
+	transport.Outbound
+
+	advance func()
+}
+
+func (o fakeOutbound) Call(context.Context, *transport.Request) (*transport.Response, error) {
+	o.advance()
+	return &transport.Response{}, nil
+}
+
+func (o fakeOutbound) CallOneway(context.Context, *transport.Request) (transport.Ack, error) {
+	o.advance()
+	return nil, nil
+}
+
+func TestMiddleware(t *testing.T) {
+	now := time.Now()
+	m := New()
+	m.now = func() time.Time { return now }
+	out := fakeOutbound{advance: func() { now = now.Add(time.Second) }}
+	req := &transport.Request{Caller: "foo", Service: "bar", Procedure: "get"}
+
+	_, err := m.Call(context.Background(), req, out)
+	require.NoError(t, err)
+
+	ctx := WithGraph(context.Background())
+	_, err = m.Call(ctx, req, out)
+	require.NoError(t, err)
+	handlerCtx, call := encoding.NewInboundCall(ctx)
+	require.NoError(t, call.ReadFromRequest(&transport.Request{Procedure: "list"}))
+	_, err = m.CallOneway(handlerCtx, req, out)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Edge{
+		{From: "foo", To: "bar/get", Latency: time.Second},
+		{From: "foo/list", To: "bar/get", Latency: time.Second},
+	}, FromContext(ctx).Edges())
+	assert.Nil(t, FromContext(context.Background()))
+}
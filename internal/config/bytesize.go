@@ -0,0 +1,107 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// _byteSizeUnits are the multiples of bytes that sizes may be expressed in,
+// by lowercase unit. Units without an "i" are decimal.
+var _byteSizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"m":   1e6,
+	"mb":  1e6,
+	"g":   1e9,
+	"gb":  1e9,
+	"t":   1e12,
+	"tb":  1e12,
+	"ki":  1 << 10,
+	"kib": 1 << 10,
+	"mi":  1 << 20,
+	"mib": 1 << 20,
+	"gi":  1 << 30,
+	"gib": 1 << 30,
+	"ti":  1 << 40,
+	"tib": 1 << 40,
+}
+
+// ParseByteSize parses a size in bytes from configuration: a plain number of
+// bytes like 4194304, or a number with a unit like "4MiB" or "512kb". Units
+// are case-insensitive; KB, MB, GB, and TB are decimal multiples, and KiB,
+// MiB, GiB, and TiB binary multiples.
+func ParseByteSize(value interface{}) (int64, error) {
+	s, ok := value.(string)
+	if !ok {
+		n := reflect.ValueOf(value)
+		switch n.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n.Int() < 0 {
+				return 0, fmt.Errorf("size %v must not be negative", value)
+			}
+			return n.Int(), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n.Uint() > math.MaxInt64 {
+				return 0, fmt.Errorf("size %v is too large", value)
+			}
+			return int64(n.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			s = strconv.FormatFloat(n.Float(), 'f', -1, 64)
+		default:
+			return 0, fmt.Errorf("cannot decode %T as a size", value)
+		}
+	}
+
+	literal := strings.TrimSpace(s)
+	i := strings.IndexFunc(literal, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+'
+	})
+	if i < 0 {
+		i = len(literal)
+	}
+	number, unit := literal[:i], strings.TrimSpace(literal[i:])
+
+	multiple, ok := _byteSizeUnits[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("size %q has an unknown unit %q", s, unit)
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("size %q is not a number of bytes", s)
+	}
+	size := f * multiple
+	switch {
+	case size < 0:
+		return 0, fmt.Errorf("size %q must not be negative", s)
+	case size >= math.MaxInt64:
+		return 0, fmt.Errorf("size %q is too large", s)
+	case size != math.Trunc(size):
+		return 0, fmt.Errorf("size %q is not a whole number of bytes", s)
+	}
+	return int64(size), nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		give    interface{}
+		want    int64
+		wantErr string
+	}{
+		{give: 4194304, want: 4194304},
+		{give: uint32(1024), want: 1024},
+		{give: 2.0, want: 2},
+		{give: "1024", want: 1024},
+		{give: "4MiB", want: 4 << 20},
+		{give: "4 mib", want: 4 << 20},
+		{give: "512kb", want: 512000},
+		{give: "512K", want: 512000},
+		{give: "1.5KiB", want: 1536},
+		{give: "2GB", want: 2e9},
+		{give: "1Ti", want: 1 << 40},
+		{give: "100B", want: 100},
+		{give: -1, wantErr: "size -1 must not be negative"},
+		{give: "-1KiB", wantErr: `size "-1KiB" must not be negative`},
+		{give: "4 MBps", wantErr: `size "4 MBps" has an unknown unit "MBps"`},
+		{give: "MiB", wantErr: `size "MiB" is not a number of bytes`},
+		{give: "1.5B", wantErr: `size "1.5B" is not a whole number of bytes`},
+		{give: "10000000TiB", wantErr: `size "10000000TiB" is too large`},
+		{give: true, wantErr: "cannot decode bool as a size"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.give)
+		if tt.wantErr != "" {
+			assert.EqualError(t, err, tt.wantErr, "ParseByteSize(%#v)", tt.give)
+			continue
+		}
+		if assert.NoError(t, err, "ParseByteSize(%#v)", tt.give) {
+			assert.Equal(t, tt.want, got, "ParseByteSize(%#v)", tt.give)
+		}
+	}
+}
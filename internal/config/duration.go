@@ -0,0 +1,81 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var _typeOfDuration = reflect.TypeOf(time.Duration(0))
+
+// ParseDuration parses a duration from configuration: a Go duration string
+// like "1m30s", or a number with a unit like "30 s". Plain numbers are
+// rejected, since their unit would be ambiguous, except for zero.
+func ParseDuration(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		s := strings.Join(strings.Fields(v), "")
+		if s == "" {
+			return 0, fmt.Errorf("duration %q is empty", v)
+		}
+		return time.ParseDuration(s)
+	}
+
+	n := reflect.ValueOf(value)
+	switch n.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if n.IsZero() {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("duration %v has no unit, like %q", value, fmt.Sprintf("%vs", value))
+	default:
+		return 0, fmt.Errorf("cannot decode %T as a duration", value)
+	}
+}
+
+// durationHook decodes durations with ParseDuration, leaving values of other
+// shapes for the decoder to report.
+func durationHook(from, to reflect.Type, data reflect.Value) (reflect.Value, error) {
+	if to != _typeOfDuration || from == _typeOfDuration {
+		return data, nil
+	}
+	switch from.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return data, nil
+	}
+
+	d, err := ParseDuration(data.Interface())
+	if err != nil {
+		return data, err
+	}
+	return reflect.ValueOf(d), nil
+}
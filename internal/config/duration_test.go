@@ -0,0 +1,82 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		give    interface{}
+		want    time.Duration
+		wantErr string
+	}{
+		{give: "1m30s", want: 90 * time.Second},
+		{give: "30 s", want: 30 * time.Second},
+		{give: " 1h 30m ", want: 90 * time.Minute},
+		{give: 0, want: 0},
+		{give: 0.0, want: 0},
+		{give: time.Second, want: time.Second},
+		{give: "", wantErr: `duration "" is empty`},
+		{give: "thirty", wantErr: `time: invalid duration "thirty"`},
+		{give: 500, wantErr: `duration 500 has no unit, like "500s"`},
+		{give: []interface{}{}, wantErr: "cannot decode []interface {} as a duration"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.give)
+		if tt.wantErr != "" {
+			assert.EqualError(t, err, tt.wantErr, "ParseDuration(%#v)", tt.give)
+			continue
+		}
+		if assert.NoError(t, err, "ParseDuration(%#v)", tt.give) {
+			assert.Equal(t, tt.want, got, "ParseDuration(%#v)", tt.give)
+		}
+	}
+}
+
+func TestDecodeDuration(t *testing.T) {
+	var dst struct {
+		Timeout  time.Duration    `config:"timeout"`
+		Optional *time.Duration   `config:"optional"`
+		List     []time.Duration  `config:"list"`
+		Map      map[string]int64 `config:"map"`
+	}
+	require.NoError(t, DecodeInto(&dst, map[string]interface{}{
+		"timeout":  "2 s",
+		"optional": "1ms",
+		"list":     []interface{}{"1s", 0},
+		"map":      map[string]interface{}{"count": 5},
+	}))
+	assert.Equal(t, 2*time.Second, dst.Timeout)
+	assert.Equal(t, time.Millisecond, *dst.Optional)
+	assert.Equal(t, []time.Duration{time.Second, 0}, dst.List)
+	assert.Equal(t, map[string]int64{"count": 5}, dst.Map, "expected other integers to decode")
+
+	err := DecodeInto(&dst, map[string]interface{}{"timeout": 5})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `duration 5 has no unit`)
+}
@@ -35,16 +35,24 @@ const (
 )
 
 // DecodeInto will decode the src's data into the dst interface.
+//
+// Durations are decoded with ParseDuration.
 func DecodeInto(dst interface{}, src interface{}, opts ...mapdecode.Option) error {
-	opts = append(opts, mapdecode.TagName(_tagName))
+	opts = append(opts, mapdecode.TagName(_tagName), mapdecode.DecodeHook(durationHook))
 	return mapdecode.Decode(dst, src, opts...)
 }
 
 // InterpolateWith is a MapDecode option that will read a structField's tag
 // information, and if the `interpolate` option is set, it will use the
 // interpolate resolver to alter data as it's being decoded into the struct.
+//
+// Fields are not interpolated without a resolver.
 func InterpolateWith(resolver interpolate.VariableResolver) mapdecode.Option {
 	return mapdecode.FieldHook(func(dest reflect.StructField, srcData reflect.Value) (reflect.Value, error) {
+		if resolver == nil {
+			return srcData, nil
+		}
+
 		shouldInterpolate := false
 
 		options := strings.Split(dest.Tag.Get(_tagName), ",")[1:]
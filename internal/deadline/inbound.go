@@ -0,0 +1,118 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package deadline
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// InboundConfig configures the inbound deadline middleware.
+type InboundConfig struct {
+	// MinTTL is the minimum remaining TTL of the requests for procedures
+	// that no override matches. Zero disables the minimum.
+	MinTTL time.Duration
+
+	// Overrides are the minimum remaining TTLs of the requests for
+	// procedures, by procedure name.
+	Overrides map[string]time.Duration
+
+	Meter  *metrics.Scope
+	Logger *zap.Logger
+}
+
+var (
+	_ middleware.UnaryInbound  = (*Inbound)(nil)
+	_ middleware.OnewayInbound = (*Inbound)(nil)
+)
+
+// Inbound is an inbound middleware that rejects requests whose remaining TTL
+// is too short for their handler to succeed.
+//
+// Requests without a deadline are let through.
+type Inbound struct {
+	minTTL    time.Duration
+	overrides map[string]time.Duration
+	rejected  *metrics.CounterVector
+}
+
+// NewInbound returns an inbound middleware that rejects requests with too
+// little time left.
+func NewInbound(cfg InboundConfig) *Inbound {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Inbound{
+		minTTL:    cfg.MinTTL,
+		overrides: cfg.Overrides,
+		rejected:  newInboundMetrics(cfg.Meter, logger),
+	}
+}
+
+// Handle implements middleware.UnaryInbound.
+func (m *Inbound) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	if err := m.admit(ctx, req); err != nil {
+		return err
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (m *Inbound) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	if err := m.admit(ctx, req); err != nil {
+		return err
+	}
+	return h.HandleOneway(ctx, req)
+}
+
+// admit returns a DeadlineExceeded error if the remaining TTL of the request
+// is below the minimum of its procedure.
+func (m *Inbound) admit(ctx context.Context, req *transport.Request) error {
+	minTTL, ok := m.overrides[req.Procedure]
+	if !ok {
+		minTTL = m.minTTL
+	}
+	if minTTL <= 0 {
+		return nil
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	ttl := time.Until(deadline)
+	if ttl >= minTTL {
+		return nil
+	}
+
+	m.rejected.MustGet(_procedureTag, req.Procedure, _callerTag, req.Caller).Inc()
+	return yarpcerrors.DeadlineExceededErrorf(
+		"remaining TTL %v of request for procedure %q of service %q from caller %q is below the minimum of %v",
+		ttl, req.Procedure, req.Service, req.Caller, minTTL)
+}
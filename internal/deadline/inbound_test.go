@@ -0,0 +1,117 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package deadline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// countingHandler counts the requests it handles.
+type countingHandler struct {
+	calls int
+}
+
+func (h *countingHandler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	h.calls++
+	return nil
+}
+
+func (h *countingHandler) HandleOneway(context.Context, *transport.Request) error {
+	h.calls++
+	return nil
+}
+
+func TestInbound(t *testing.T) {
+	m := NewInbound(InboundConfig{
+		MinTTL:    100 * time.Millisecond,
+		Overrides: map[string]time.Duration{"slow": time.Hour, "fast": 0},
+	})
+
+	tests := []struct {
+		msg       string
+		procedure string
+		ttl       time.Duration // no deadline if zero
+		wantErr   bool
+	}{
+		{msg: "enough time left", procedure: "get", ttl: testtime.Second},
+		{msg: "tiny TTL", procedure: "get", ttl: time.Millisecond, wantErr: true},
+		{msg: "expired TTL", procedure: "get", ttl: -time.Millisecond, wantErr: true},
+		{msg: "no deadline", procedure: "get"},
+		{msg: "override raises the minimum", procedure: "slow", ttl: testtime.Second, wantErr: true},
+		{msg: "override disables the minimum", procedure: "fast", ttl: time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ttl != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ttl)
+				defer cancel()
+			}
+			req := &transport.Request{Service: "service", Procedure: tt.procedure, Caller: "caller"}
+
+			var unary, oneway countingHandler
+			unaryErr := m.Handle(ctx, req, new(transporttest.FakeResponseWriter), &unary)
+			onewayErr := m.HandleOneway(ctx, req, &oneway)
+
+			if !tt.wantErr {
+				assert.NoError(t, unaryErr)
+				assert.NoError(t, onewayErr)
+				assert.Equal(t, 1, unary.calls)
+				assert.Equal(t, 1, oneway.calls)
+				return
+			}
+			assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, yarpcerrors.FromError(unaryErr).Code())
+			assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, yarpcerrors.FromError(onewayErr).Code())
+			assert.Zero(t, unary.calls, "handler must not be called")
+			assert.Zero(t, oneway.calls, "handler must not be called")
+		})
+	}
+}
+
+func TestInboundMetrics(t *testing.T) {
+	root := metrics.New()
+	m := NewInbound(InboundConfig{MinTTL: time.Second, Meter: root.Scope()})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	req := &transport.Request{Service: "service", Procedure: "get", Caller: "caller"}
+	for i := 0; i < 3; i++ {
+		assert.Error(t, m.Handle(ctx, req, new(transporttest.FakeResponseWriter), &countingHandler{}))
+	}
+
+	counters := root.Snapshot().Counters
+	if assert.Len(t, counters, 1) {
+		assert.Equal(t, "deadline_inbound_rejected", counters[0].Name)
+		assert.Equal(t, metrics.Tags{"procedure": "get", "caller": "caller"}, counters[0].Tags)
+		assert.Equal(t, int64(3), counters[0].Value)
+	}
+}
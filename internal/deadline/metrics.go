@@ -0,0 +1,99 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package deadline
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/zap"
+)
+
+const (
+	_serviceTag   = "service"
+	_procedureTag = "procedure"
+	_callerTag    = "caller"
+	_reasonTag    = "reason"
+
+	// Reasons of refused calls.
+	_belowMin = "below_min_ttl"
+	_missing  = "missing_deadline"
+)
+
+func newInboundMetrics(meter *metrics.Scope, logger *zap.Logger) *metrics.CounterVector {
+	rejected, err := meter.CounterVector(metrics.Spec{
+		Name:    "deadline_inbound_rejected",
+		Help:    "Total number of requests rejected because their remaining TTL was below the minimum.",
+		VarTags: []string{_procedureTag, _callerTag},
+	})
+	if err != nil {
+		logger.Error("failed to create inbound deadline rejected counter", zap.Error(err))
+	}
+	return rejected
+}
+
+func newTimeoutMetrics(meter *metrics.Scope, logger *zap.Logger) *metrics.CounterVector {
+	timeouts, err := meter.CounterVector(metrics.Spec{
+		Name:    "deadline_inbound_server_timeouts",
+		Help:    "Total number of requests that failed because the timeout of their procedure elapsed before their TTL.",
+		VarTags: []string{_procedureTag, _callerTag},
+	})
+	if err != nil {
+		logger.Error("failed to create inbound server timeouts counter", zap.Error(err))
+	}
+	return timeouts
+}
+
+type outboundMetrics struct {
+	rejectedCalls  *metrics.CounterVector
+	defaultedCalls *metrics.CounterVector
+}
+
+func newOutboundMetrics(meter *metrics.Scope, logger *zap.Logger) *outboundMetrics {
+	rejected, err := meter.CounterVector(metrics.Spec{
+		Name:    "deadline_outbound_rejected",
+		Help:    "Total number of calls refused because their remaining TTL was below the minimum, or because they had no deadline.",
+		VarTags: []string{_serviceTag, _procedureTag, _reasonTag},
+	})
+	if err != nil {
+		logger.Error("failed to create outbound deadline rejected counter", zap.Error(err))
+	}
+	defaulted, err := meter.CounterVector(metrics.Spec{
+		Name:    "deadline_outbound_defaulted",
+		Help:    "Total number of calls without a deadline sent with the default TTL.",
+		VarTags: []string{_serviceTag, _procedureTag},
+	})
+	if err != nil {
+		logger.Error("failed to create outbound deadline defaulted counter", zap.Error(err))
+	}
+
+	return &outboundMetrics{
+		rejectedCalls:  rejected,
+		defaultedCalls: defaulted,
+	}
+}
+
+func (m *outboundMetrics) rejected(req *transport.Request, reason string) {
+	m.rejectedCalls.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure, _reasonTag, reason).Inc()
+}
+
+func (m *outboundMetrics) defaulted(req *transport.Request) {
+	m.defaultedCalls.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure).Inc()
+}
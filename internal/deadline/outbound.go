@@ -0,0 +1,128 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package deadline
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/cancelbody"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// OutboundConfig configures the outbound deadline middleware.
+type OutboundConfig struct {
+	// MinTTL is the minimum remaining TTL of calls. Zero disables the
+	// minimum.
+	MinTTL time.Duration
+
+	// DefaultTTL is the TTL of calls without a deadline. Calls without a
+	// deadline are refused if it is zero.
+	DefaultTTL time.Duration
+
+	Meter  *metrics.Scope
+	Logger *zap.Logger
+}
+
+var (
+	_ middleware.UnaryOutbound  = (*Outbound)(nil)
+	_ middleware.OnewayOutbound = (*Outbound)(nil)
+)
+
+// Outbound is an outbound middleware that refuses to send calls whose
+// remaining TTL is too short to succeed, or that have no deadline.
+type Outbound struct {
+	minTTL     time.Duration
+	defaultTTL time.Duration
+	metrics    *outboundMetrics
+}
+
+// NewOutbound returns an outbound middleware that refuses calls with too
+// little time left.
+func NewOutbound(cfg OutboundConfig) *Outbound {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Outbound{
+		minTTL:     cfg.MinTTL,
+		defaultTTL: cfg.DefaultTTL,
+		metrics:    newOutboundMetrics(cfg.Meter, logger),
+	}
+}
+
+// Call implements middleware.UnaryOutbound.
+func (m *Outbound) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	ctx, cancel, err := m.check(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if cancel == nil {
+		return out.Call(ctx, req)
+	}
+	// The context with the default TTL is canceled once the caller closes
+	// the body of the response, which the transport may still be reading.
+	res, err := out.Call(ctx, req)
+	return cancelbody.Wrap(res, cancel), err
+}
+
+// CallOneway implements middleware.OnewayOutbound.
+func (m *Outbound) CallOneway(ctx context.Context, req *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	ctx, cancel, err := m.check(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if cancel != nil {
+		defer cancel()
+	}
+	return out.CallOneway(ctx, req)
+}
+
+// check returns the context to send the call with, or an error if the call
+// must not be sent. Calls without a deadline get the default TTL, and a
+// function that cancels it.
+func (m *Outbound) check(ctx context.Context, req *transport.Request) (context.Context, context.CancelFunc, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		if m.defaultTTL <= 0 {
+			m.metrics.rejected(req, _missing)
+			return nil, nil, yarpcerrors.InvalidArgumentErrorf(
+				"call to procedure %q of service %q has no deadline", req.Procedure, req.Service)
+		}
+		m.metrics.defaulted(req)
+		ctx, cancel := context.WithTimeout(ctx, m.defaultTTL)
+		return ctx, cancel, nil
+	}
+
+	if ttl := time.Until(deadline); ttl < m.minTTL {
+		m.metrics.rejected(req, _belowMin)
+		return nil, nil, yarpcerrors.DeadlineExceededErrorf(
+			"remaining TTL %v of call to procedure %q of service %q is below the minimum of %v",
+			ttl, req.Procedure, req.Service, m.minTTL)
+	}
+	return ctx, nil, nil
+}
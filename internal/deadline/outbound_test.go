@@ -0,0 +1,143 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package deadline
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// fakeOutbound records the contexts of the calls it receives.
+type fakeOutbound struct {
+	transport.Outbound
+
+	contexts []context.Context
+}
+
+func (o *fakeOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	o.contexts = append(o.contexts, ctx)
+	return &transport.Response{Body: ioutil.NopCloser(bytes.NewReader([]byte("body")))}, nil
+}
+
+func (o *fakeOutbound) CallOneway(ctx context.Context, req *transport.Request) (transport.Ack, error) {
+	o.contexts = append(o.contexts, ctx)
+	return nil, nil
+}
+
+func TestOutboundMinTTL(t *testing.T) {
+	m := NewOutbound(OutboundConfig{MinTTL: 100 * time.Millisecond})
+	req := &transport.Request{Service: "service", Procedure: "get"}
+
+	t.Run("enough time left", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+		defer cancel()
+
+		out := &fakeOutbound{}
+		_, err := m.Call(ctx, req, out)
+		require.NoError(t, err)
+		_, err = m.CallOneway(ctx, req, out)
+		require.NoError(t, err)
+		assert.Equal(t, []context.Context{ctx, ctx}, out.contexts, "contexts with deadlines must be passed on")
+	})
+
+	t.Run("tiny TTL", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		out := &fakeOutbound{}
+		_, err := m.Call(ctx, req, out)
+		assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, yarpcerrors.FromError(err).Code())
+		_, err = m.CallOneway(ctx, req, out)
+		assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, yarpcerrors.FromError(err).Code())
+		assert.Empty(t, out.contexts, "calls must not be sent")
+	})
+
+	t.Run("no deadline", func(t *testing.T) {
+		out := &fakeOutbound{}
+		_, err := m.Call(context.Background(), req, out)
+		assert.Equal(t, yarpcerrors.CodeInvalidArgument, yarpcerrors.FromError(err).Code())
+		_, err = m.CallOneway(context.Background(), req, out)
+		assert.Equal(t, yarpcerrors.CodeInvalidArgument, yarpcerrors.FromError(err).Code())
+		assert.Empty(t, out.contexts, "calls must not be sent")
+	})
+}
+
+func TestOutboundDefaultTTL(t *testing.T) {
+	root := metrics.New()
+	m := NewOutbound(OutboundConfig{
+		MinTTL:     100 * time.Millisecond,
+		DefaultTTL: time.Minute,
+		Meter:      root.Scope(),
+	})
+	req := &transport.Request{Service: "service", Procedure: "get"}
+	out := &fakeOutbound{}
+
+	start := time.Now()
+	res, err := m.Call(context.Background(), req, out)
+	require.NoError(t, err)
+	require.Len(t, out.contexts, 1)
+	deadline, ok := out.contexts[0].Deadline()
+	require.True(t, ok, "call must have the default TTL")
+	assert.WithinDuration(t, start.Add(time.Minute), deadline, testtime.Second)
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "body", string(body))
+	assert.NoError(t, out.contexts[0].Err(), "context must live until the body is closed")
+	require.NoError(t, res.Body.Close())
+	assert.Error(t, out.contexts[0].Err(), "context must be canceled when the body is closed")
+
+	_, err = m.CallOneway(context.Background(), req, out)
+	require.NoError(t, err)
+	require.Len(t, out.contexts, 2)
+	_, ok = out.contexts[1].Deadline()
+	assert.True(t, ok, "oneway call must have the default TTL")
+	assert.Error(t, out.contexts[1].Err(), "context must be canceled after the oneway call")
+
+	// The minimum still applies to calls with deadlines.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = m.Call(ctx, req, out)
+	assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, yarpcerrors.FromError(err).Code())
+
+	counters := make(map[string]int64)
+	for _, c := range root.Snapshot().Counters {
+		name := c.Name
+		if reason, ok := c.Tags[_reasonTag]; ok {
+			name += ":" + reason
+		}
+		counters[name] = c.Value
+	}
+	assert.Equal(t, map[string]int64{
+		"deadline_outbound_defaulted":              2,
+		"deadline_outbound_rejected:below_min_ttl": 1,
+	}, counters)
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package deadline
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// ServerTimeoutErrorName is the application error name of requests that fail
+// because the server-imposed timeout of their procedure elapsed before their
+// TTL, which tells them apart from requests that run out of TTL in metrics.
+const ServerTimeoutErrorName = "server-timeout"
+
+// TimeoutConfig configures the inbound timeout middleware.
+type TimeoutConfig struct {
+	// Default is the timeout of the requests for procedures that no
+	// override matches. Zero disables the timeout.
+	Default time.Duration
+
+	// Overrides are the timeouts of the requests for procedures, by
+	// procedure pattern. A pattern is either a procedure name, or a prefix
+	// of procedure names followed by "*", like "Store::*". Procedure names
+	// take precedence over prefixes, and longer prefixes over shorter ones.
+	Overrides map[string]time.Duration
+
+	Meter  *metrics.Scope
+	Logger *zap.Logger
+}
+
+var (
+	_ middleware.UnaryInbound  = (*Timeout)(nil)
+	_ middleware.OnewayInbound = (*Timeout)(nil)
+)
+
+// Timeout is an inbound middleware that bounds the time handlers spend on
+// requests with a timeout for each procedure, regardless of the TTL of the
+// requests.
+type Timeout struct {
+	defaultTimeout time.Duration
+	exact          map[string]time.Duration
+	prefixes       []timeoutPrefix
+	timeouts       *metrics.CounterVector
+}
+
+type timeoutPrefix struct {
+	prefix  string
+	timeout time.Duration
+}
+
+// NewTimeout builds an inbound timeout middleware.
+func NewTimeout(cfg TimeoutConfig) *Timeout {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	m := &Timeout{
+		defaultTimeout: cfg.Default,
+		exact:          make(map[string]time.Duration),
+		timeouts:       newTimeoutMetrics(cfg.Meter, logger),
+	}
+	for pattern, timeout := range cfg.Overrides {
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			m.prefixes = append(m.prefixes, timeoutPrefix{prefix: prefix, timeout: timeout})
+		} else {
+			m.exact[pattern] = timeout
+		}
+	}
+	return m
+}
+
+// Handle calls the handler with a context whose deadline is the earlier of
+// the deadline of the request and the timeout of its procedure.
+func (m *Timeout) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	ctx, serverDeadline, cancel := m.withTimeout(ctx, req)
+	defer cancel()
+
+	err := h.Handle(ctx, req, resw)
+	if err == nil || !serverDeadline || ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	if setter, ok := resw.(transport.ApplicationErrorMetaSetter); ok {
+		setter.SetApplicationErrorMeta(&transport.ApplicationErrorMeta{Name: ServerTimeoutErrorName})
+	}
+	return m.timedOut(req)
+}
+
+// HandleOneway calls the handler with a context whose deadline is the
+// earlier of the deadline of the request and the timeout of its procedure.
+func (m *Timeout) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	ctx, serverDeadline, cancel := m.withTimeout(ctx, req)
+	defer cancel()
+
+	err := h.HandleOneway(ctx, req)
+	if err == nil || !serverDeadline || ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	return m.timedOut(req)
+}
+
+// withTimeout returns the context of the handler of a request, and whether
+// its deadline is the one imposed by the timeout rather than the deadline of
+// the request.
+func (m *Timeout) withTimeout(ctx context.Context, req *transport.Request) (context.Context, bool, context.CancelFunc) {
+	timeout := m.timeout(req.Procedure)
+	if timeout <= 0 {
+		return ctx, false, func() {}
+	}
+
+	deadline := time.Now().Add(timeout)
+	if callerDeadline, ok := ctx.Deadline(); ok && !deadline.Before(callerDeadline) {
+		return ctx, false, func() {}
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	return ctx, true, cancel
+}
+
+func (m *Timeout) timeout(procedure string) time.Duration {
+	if timeout, ok := m.exact[procedure]; ok {
+		return timeout
+	}
+
+	timeout, longest := m.defaultTimeout, -1
+	for _, p := range m.prefixes {
+		if len(p.prefix) > longest && strings.HasPrefix(procedure, p.prefix) {
+			timeout, longest = p.timeout, len(p.prefix)
+		}
+	}
+	return timeout
+}
+
+func (m *Timeout) timedOut(req *transport.Request) error {
+	m.timeouts.MustGet(_procedureTag, req.Procedure, _callerTag, req.Caller).Inc()
+	return yarpcerrors.DeadlineExceededErrorf(
+		"server timeout of %v elapsed before the handler for procedure %q of service %q from caller %q finished",
+		m.timeout(req.Procedure), req.Procedure, req.Service, req.Caller)
+}
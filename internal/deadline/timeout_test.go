@@ -0,0 +1,198 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package deadline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// waitingHandler waits for its context to be done and fails with its error,
+// recording the deadline of the context.
+type waitingHandler struct {
+	deadline    time.Time
+	hasDeadline bool
+}
+
+func (h *waitingHandler) wait(ctx context.Context) error {
+	h.deadline, h.hasDeadline = ctx.Deadline()
+	if !h.hasDeadline {
+		return nil
+	}
+	<-ctx.Done()
+	return yarpcerrors.DeadlineExceededErrorf("handler: %v", ctx.Err())
+}
+
+func (h *waitingHandler) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	return h.wait(ctx)
+}
+
+func (h *waitingHandler) HandleOneway(ctx context.Context, req *transport.Request) error {
+	return h.wait(ctx)
+}
+
+func TestTimeout(t *testing.T) {
+	serverTimeout := 20 * testtime.Millisecond
+
+	tests := []struct {
+		msg               string
+		timeout           time.Duration
+		ttl               time.Duration // no deadline if zero
+		wantDeadline      bool
+		wantServerTimeout bool
+	}{
+		{
+			msg:          "caller TTL shorter",
+			timeout:      testtime.Second,
+			ttl:          serverTimeout,
+			wantDeadline: true,
+		},
+		{
+			msg:               "server timeout shorter",
+			timeout:           serverTimeout,
+			ttl:               testtime.Second,
+			wantDeadline:      true,
+			wantServerTimeout: true,
+		},
+		{
+			msg:               "server timeout without caller TTL",
+			timeout:           serverTimeout,
+			wantDeadline:      true,
+			wantServerTimeout: true,
+		},
+		{
+			msg:          "caller TTL without server timeout",
+			ttl:          serverTimeout,
+			wantDeadline: true,
+		},
+		{
+			msg: "neither set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			root := metrics.New()
+			m := NewTimeout(TimeoutConfig{Default: tt.timeout, Meter: root.Scope()})
+
+			ctx := context.Background()
+			if tt.ttl != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ttl)
+				defer cancel()
+			}
+			req := &transport.Request{Service: "service", Procedure: "get", Caller: "caller"}
+
+			var unary, oneway waitingHandler
+			resw := new(transporttest.FakeResponseWriter)
+			unaryErr := m.Handle(ctx, req, resw, &unary)
+			onewayErr := m.HandleOneway(ctx, req, &oneway)
+
+			assert.Equal(t, tt.wantDeadline, unary.hasDeadline)
+			assert.Equal(t, tt.wantDeadline, oneway.hasDeadline)
+			if !tt.wantDeadline {
+				assert.NoError(t, unaryErr)
+				assert.NoError(t, onewayErr)
+				assert.Empty(t, root.Snapshot().Counters)
+				return
+			}
+
+			for _, err := range []error{unaryErr, onewayErr} {
+				require.Error(t, err)
+				assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, yarpcerrors.FromError(err).Code())
+				if tt.wantServerTimeout {
+					assert.Contains(t, err.Error(), "server timeout of")
+				} else {
+					assert.Contains(t, err.Error(), "handler:", "caller TTL errors must be left as they are")
+				}
+			}
+
+			if !tt.wantServerTimeout {
+				assert.Nil(t, resw.ApplicationErrorMeta)
+				assert.Empty(t, root.Snapshot().Counters)
+				return
+			}
+			require.NotNil(t, resw.ApplicationErrorMeta)
+			assert.Equal(t, ServerTimeoutErrorName, resw.ApplicationErrorMeta.Name)
+
+			counters := root.Snapshot().Counters
+			if assert.Len(t, counters, 1) {
+				assert.Equal(t, "deadline_inbound_server_timeouts", counters[0].Name)
+				assert.Equal(t, metrics.Tags{"procedure": "get", "caller": "caller"}, counters[0].Tags)
+				assert.Equal(t, int64(2), counters[0].Value)
+			}
+		})
+	}
+}
+
+func TestTimeoutSuccessAfterDeadline(t *testing.T) {
+	m := NewTimeout(TimeoutConfig{Default: time.Millisecond})
+	err := m.Handle(context.Background(), &transport.Request{Procedure: "get"}, new(transporttest.FakeResponseWriter),
+		unaryFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		}))
+	assert.NoError(t, err, "handlers that succeed after the timeout must succeed")
+}
+
+type unaryFunc func(context.Context) error
+
+func (f unaryFunc) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	return f(ctx)
+}
+
+func TestTimeoutPatterns(t *testing.T) {
+	m := NewTimeout(TimeoutConfig{
+		Default: time.Second,
+		Overrides: map[string]time.Duration{
+			"Store::scan":   time.Minute,
+			"Store::*":      time.Millisecond,
+			"Store::admin*": time.Hour,
+			"Cache::get":    0,
+		},
+	})
+
+	tests := []struct {
+		procedure string
+		want      time.Duration
+	}{
+		{procedure: "Store::scan", want: time.Minute},
+		{procedure: "Store::get", want: time.Millisecond},
+		{procedure: "Store::adminReset", want: time.Hour},
+		{procedure: "Cache::get", want: 0},
+		{procedure: "Cache::set", want: time.Second},
+		{procedure: "Store", want: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.procedure, func(t *testing.T) {
+			assert.Equal(t, tt.want, m.timeout(tt.procedure))
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package dnstest provides a DNS resolver for tests that resolves host names
+// from a static table, without querying DNS servers.
+package dnstest
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// NewResolver builds a resolver that resolves the host names of the given
+// table to their IPv4 addresses, and reports all other names as missing.
+//
+// The resolver answers queries in-process, so it resolves names as if every
+// query reached a DNS server, even names that the system would resolve
+// otherwise.
+func NewResolver(hosts map[string]string) *net.Resolver {
+	table := make(map[string][4]byte, len(hosts))
+	for name, addr := range hosts {
+		var ip [4]byte
+		copy(ip[:], net.ParseIP(addr).To4())
+		table[strings.TrimSuffix(name, ".")] = ip
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go serve(server, table)
+			return client, nil
+		},
+	}
+}
+
+// serve answers the queries sent over the connection. net.Pipe is not a
+// net.PacketConn, so the resolver sends its queries as over TCP: prefixed
+// with their length.
+func serve(conn net.Conn, table map[string][4]byte) {
+	defer conn.Close()
+	for {
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		answer, err := respond(query, table)
+		if err != nil {
+			return
+		}
+		binary.BigEndian.PutUint16(size[:], uint16(len(answer)))
+		if _, err := conn.Write(append(size[:], answer...)); err != nil {
+			return
+		}
+	}
+}
+
+func respond(query []byte, table map[string][4]byte) ([]byte, error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		return nil, err
+	}
+
+	msg.Response = true
+	msg.Authoritative = true
+	msg.RecursionAvailable = true
+	msg.RCode = dnsmessage.RCodeNameError
+	for _, q := range msg.Questions {
+		ip, ok := table[strings.TrimSuffix(q.Name.String(), ".")]
+		if !ok {
+			continue
+		}
+		// The name exists, but may have no record of the queried type.
+		msg.RCode = dnsmessage.RCodeSuccess
+		if q.Type != dnsmessage.TypeA {
+			continue
+		}
+		msg.Answers = append(msg.Answers, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{
+				Name:  q.Name,
+				Type:  dnsmessage.TypeA,
+				Class: dnsmessage.ClassINET,
+				TTL:   60,
+			},
+			Body: &dnsmessage.AResource{A: ip},
+		})
+	}
+	return msg.Pack()
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/transport/grpc"
+)
+
+func main() {
+	if err := do(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func do() error {
+	name := flag.String("name", "chat-client", "name to chat as")
+	flag.Parse()
+
+	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+		Name: *name,
+		Outbounds: yarpc.Outbounds{
+			"chat": {
+				Stream: grpc.NewTransport().NewSingleOutbound("127.0.0.1:24040"),
+			},
+		},
+	})
+	outbound := dispatcher.MustOutboundConfig("chat").GetBidiStreamOutbound()
+
+	if err := dispatcher.Start(); err != nil {
+		return fmt.Errorf("failed to start Dispatcher: %v", err)
+	}
+	defer dispatcher.Stop()
+
+	// Specifying a deadline on the context affects the entire stream. As this is
+	// generally not the desired behavior, we use a cancelable context instead.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := outbound.CallBidiStream(ctx, &transport.StreamRequest{
+		Meta: &transport.RequestMeta{
+			Caller:    *name,
+			Service:   "chat",
+			Procedure: "chat",
+			Encoding:  raw.Encoding,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create stream: %v", err)
+	}
+
+	// Print messages from the room while sending lines from stdin.
+	received := make(chan error, 1)
+	go func() {
+		received <- receive(stream)
+	}()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		msg := &transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewBufferString(scanner.Text()))}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	return <-received
+}
+
+func receive(stream transport.BidiStream) error {
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		body, err := ioutil.ReadAll(msg.Body)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(body))
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"sync"
+
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/transport/grpc"
+	"go.uber.org/zap"
+)
+
+// room relays every message received from a member to all members.
+type room struct {
+	mu      sync.Mutex
+	members map[transport.BidiStream]*sync.Mutex
+}
+
+func newRoom() *room {
+	return &room{members: make(map[transport.BidiStream]*sync.Mutex)}
+}
+
+func (r *room) join(stream transport.BidiStream) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.members[stream] = &sync.Mutex{}
+}
+
+func (r *room) leave(stream transport.BidiStream) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.members, stream)
+}
+
+func (r *room) broadcast(msg []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for stream, sendMu := range r.members {
+		// Streams do not support concurrent sends.
+		sendMu.Lock()
+		err := stream.Send(&transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewReader(msg))})
+		sendMu.Unlock()
+		if err != nil {
+			fmt.Printf("failed to relay message to %q: %v\n", stream.Request().Meta.Caller, err)
+		}
+	}
+}
+
+func (r *room) chat(stream transport.BidiStream) error {
+	caller := stream.Request().Meta.Caller
+	r.join(stream)
+	defer r.leave(stream)
+	fmt.Printf("%q joined\n", caller)
+
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			fmt.Printf("%q left\n", caller)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		body, err := ioutil.ReadAll(msg.Body)
+		if err != nil {
+			return err
+		}
+		r.broadcast([]byte(fmt.Sprintf("%s: %s", caller, body)))
+	}
+}
+
+func main() {
+	if err := do(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func do() error {
+	logger := zap.NewNop()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:24040")
+	if err != nil {
+		return err
+	}
+	inbound := grpc.NewTransport(grpc.Logger(logger)).NewInbound(listener)
+
+	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+		Name:     "chat",
+		Inbounds: yarpc.Inbounds{inbound},
+		Logging: yarpc.LoggingConfig{
+			Zap: logger,
+		},
+	})
+
+	dispatcher.Register([]transport.Procedure{
+		yarpc.BidiStreamProcedure("chat", transport.BidiStreamHandlerFunc(newRoom().chat)),
+	})
+
+	if err := dispatcher.Start(); err != nil {
+		return err
+	}
+
+	select {}
+}
@@ -136,6 +136,9 @@ func StreamChain(mw ...middleware.StreamInbound) middleware.StreamInbound {
 			unchained = append(unchained, c...)
 			continue
 		}
+		if mm, ok := m.(middleware.StreamMessageInbound); ok {
+			m = streamMessageInbound{StreamInbound: m, messages: mm}
+		}
 		unchained = append(unchained, m)
 	}
 
@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io/ioutil"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -194,3 +195,98 @@ func TestStreamChain(t *testing.T) {
 		})
 	}
 }
+
+// messageInboundMiddleware counts the messages of the streams it handles and
+// appends its name to their bodies.
+type messageInboundMiddleware struct {
+	name     string
+	received int
+	sent     int
+}
+
+func (m *messageInboundMiddleware) HandleStream(s *transport.ServerStream, h transport.StreamHandler) error {
+	return h.HandleStream(s)
+}
+
+func (m *messageInboundMiddleware) HandleMessage(ctx context.Context, next transport.Stream) (*transport.StreamMessage, error) {
+	msg, err := next.ReceiveMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.received++
+	return appendToMessage(msg, m.name)
+}
+
+func (m *messageInboundMiddleware) SendMessage(ctx context.Context, msg *transport.StreamMessage, next transport.Stream) error {
+	m.sent++
+	msg, err := appendToMessage(msg, m.name)
+	if err != nil {
+		return err
+	}
+	return next.SendMessage(ctx, msg)
+}
+
+func appendToMessage(msg *transport.StreamMessage, s string) (*transport.StreamMessage, error) {
+	body, err := ioutil.ReadAll(msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	body = append(body, "+"+s...)
+	return &transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewReader(body)), BodySize: len(body)}, nil
+}
+
+func readMessage(t *testing.T, msg *transport.StreamMessage) string {
+	body, err := ioutil.ReadAll(msg.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func newMessage(s string) *transport.StreamMessage {
+	return &transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewBufferString(s)), BodySize: len(s)}
+}
+
+type streamHandlerFunc func(*transport.ServerStream) error
+
+func (f streamHandlerFunc) HandleStream(s *transport.ServerStream) error { return f(s) }
+
+func TestStreamChainMessages(t *testing.T) {
+	outer := &messageInboundMiddleware{name: "outer"}
+	inner := &messageInboundMiddleware{name: "inner"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	client, server, finish, err := transporttest.MessagePipe(ctx, &transport.StreamRequest{Meta: &transport.RequestMeta{}})
+	require.NoError(t, err)
+
+	var received string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h := streamHandlerFunc(func(s *transport.ServerStream) error {
+			msg, err := s.ReceiveMessage(ctx)
+			if err != nil {
+				return err
+			}
+			received = readMessage(t, msg)
+			return s.SendMessage(ctx, newMessage("world"))
+		})
+		finish(middleware.ApplyStreamInbound(h, StreamChain(outer, inner)).HandleStream(server))
+	}()
+
+	require.NoError(t, client.SendMessage(ctx, newMessage("hello")))
+	msg, err := client.ReceiveMessage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "world+inner+outer", readMessage(t, msg), "sent messages must reach the outer middleware last")
+	<-done
+
+	assert.Equal(t, "hello+outer+inner", received, "received messages must reach the outer middleware first")
+	assert.Equal(t, 1, outer.received)
+	assert.Equal(t, 1, outer.sent)
+	assert.Equal(t, 1, inner.received)
+	assert.Equal(t, 1, inner.sent)
+}
+
+func TestStreamChainWithoutMessageMiddleware(t *testing.T) {
+	mw := &countInboundMiddleware{}
+	assert.Equal(t, mw, StreamChain(mw), "expected middleware without message hooks to be left as is")
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package inboundmiddleware
+
+import (
+	"context"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+)
+
+// streamMessageInbound installs the message hooks of a StreamInbound
+// middleware on the stream it passes to the next handler, so that the
+// messages meet the middleware in the same place as the stream.
+type streamMessageInbound struct {
+	middleware.StreamInbound
+
+	messages middleware.StreamMessageInbound
+}
+
+func (m streamMessageInbound) HandleStream(s *transport.ServerStream, h transport.StreamHandler) error {
+	return m.StreamInbound.HandleStream(s, messageHandler{h: h, messages: m.messages})
+}
+
+type messageHandler struct {
+	h        transport.StreamHandler
+	messages middleware.StreamMessageInbound
+}
+
+func (h messageHandler) HandleStream(s *transport.ServerStream) error {
+	wrapped, err := transport.NewServerStream(&messageServerStream{ServerStream: s, messages: h.messages})
+	if err != nil {
+		return err
+	}
+	return h.h.HandleStream(wrapped)
+}
+
+// messageServerStream passes the messages of a server stream through the
+// hooks of a middleware.
+type messageServerStream struct {
+	*transport.ServerStream
+
+	messages middleware.StreamMessageInbound
+}
+
+var _ transport.StreamHeadersSender = (*messageServerStream)(nil)
+
+func (s *messageServerStream) SendMessage(ctx context.Context, msg *transport.StreamMessage) error {
+	return s.messages.SendMessage(ctx, msg, s.ServerStream)
+}
+
+func (s *messageServerStream) ReceiveMessage(ctx context.Context) (*transport.StreamMessage, error) {
+	return s.messages.HandleMessage(ctx, s.ServerStream)
+}
@@ -27,10 +27,11 @@ import (
 // DispatcherStatus represent detailed introspection information about a
 // dispatcher.
 type DispatcherStatus struct {
-	Name            string                          `json:"name"`
-	ID              string                          `json:"id"`
-	Procedures      []Procedure                     `json:"procedures"`
-	Inbounds        []xintrospection.InboundStatus  `json:"inbounds"`
-	Outbounds       []xintrospection.OutboundStatus `json:"outbounds"`
-	PackageVersions []PackageVersion                `json:"packageVersions"`
+	Name            string                           `json:"name"`
+	ID              string                           `json:"id"`
+	Procedures      []Procedure                      `json:"procedures"`
+	Transports      []xintrospection.TransportStatus `json:"transports"`
+	Inbounds        []xintrospection.InboundStatus   `json:"inbounds"`
+	Outbounds       []xintrospection.OutboundStatus  `json:"outbounds"`
+	PackageVersions []PackageVersion                 `json:"packageVersions"`
 }
@@ -0,0 +1,135 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package introspection
+
+import (
+	"net/url"
+	"strings"
+
+	xintrospection "go.uber.org/yarpc/api/x/introspection"
+)
+
+// Redacted replaces the values of configuration attributes that hold
+// secrets.
+const Redacted = xintrospection.Redacted
+
+// _secretKeys are the fragments of the names of configuration attributes
+// that hold secrets, in lower case.
+var _secretKeys = []string{"password", "secret", "token", "authorization", "credential", "cookie"}
+
+// isSecretKey returns whether the configuration attribute with the given
+// name holds a secret.
+//
+// This is a guess from the name alone. It misses secrets under names with
+// none of the fragments, like "pem" or "dsn", and redacts attributes that
+// merely contain one, like "tokenBucketSize". Components report secrets
+// under other names as an xintrospection.Secret, which is redacted whatever
+// its name.
+func isSecretKey(name string) bool {
+	name = strings.ToLower(name)
+	for _, fragment := range _secretKeys {
+		if strings.Contains(name, fragment) {
+			return true
+		}
+	}
+	return strings.HasSuffix(name, "key") && name != "key"
+}
+
+// RedactConfig returns a copy of the configuration attributes with the
+// values of attributes that hold secrets replaced by Redacted, and the
+// passwords of URLs redacted, in nested maps and lists as well.
+func RedactConfig(config map[string]interface{}) map[string]interface{} {
+	if config == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(config))
+	for name, value := range config {
+		if isSecretKey(name) {
+			out[name] = Redacted
+		} else {
+			out[name] = redactValue(value)
+		}
+	}
+	return out
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case xintrospection.Secret:
+		return Redacted
+	case map[string]interface{}:
+		return RedactConfig(v)
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for name, s := range v {
+			m[name] = s
+		}
+		return RedactConfig(m)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redactValue(item)
+		}
+		return items
+	case []string:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = RedactURL(item)
+		}
+		return items
+	case string:
+		return RedactURL(v)
+	default:
+		return value
+	}
+}
+
+// RedactURL replaces the password of the URL with "xxxxx" like
+// url.URL.Redacted, if the string is a URL with one, leaving other strings
+// as they are.
+func RedactURL(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	return u.String()
+}
+
+// RedactChooser redacts the configuration of the chooser, and of the
+// choosers that it is composed of.
+func RedactChooser(status xintrospection.ChooserStatus) xintrospection.ChooserStatus {
+	status.Config = RedactConfig(status.Config)
+	if len(status.Choosers) > 0 {
+		choosers := make([]xintrospection.ChooserStatus, len(status.Choosers))
+		for i, c := range status.Choosers {
+			choosers[i] = RedactChooser(c)
+		}
+		status.Choosers = choosers
+	}
+	return status
+}
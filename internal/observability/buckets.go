@@ -0,0 +1,108 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package observability
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LatencyBuckets converts the upper bounds of the buckets of latency
+// histograms to the milliseconds that the histograms record.
+//
+// The bounds must be whole, non-negative milliseconds in ascending order.
+func LatencyBuckets(bounds []time.Duration) ([]int64, error) {
+	if len(bounds) == 0 {
+		return nil, errors.New("at least one bucket is required")
+	}
+	buckets := make([]int64, len(bounds))
+	for i, b := range bounds {
+		if b < 0 {
+			return nil, fmt.Errorf("bucket %v must not be negative", b)
+		}
+		if b%time.Millisecond != 0 {
+			return nil, fmt.Errorf("bucket %v is not a whole number of milliseconds", b)
+		}
+		buckets[i] = int64(b / time.Millisecond)
+		if i > 0 && buckets[i] <= buckets[i-1] {
+			return nil, fmt.Errorf("buckets must be ascending, got %v after %v", b, bounds[i-1])
+		}
+	}
+	return buckets, nil
+}
+
+// latencyBuckets selects the buckets of the latency histograms of each
+// procedure.
+type latencyBuckets struct {
+	defaults []int64
+	exact    map[string][]int64
+	// prefixes are sorted from the longest to the shortest, so that the
+	// first match is the longest.
+	prefixes []latencyBucketsPrefix
+}
+
+type latencyBucketsPrefix struct {
+	prefix  string
+	buckets []int64
+}
+
+// newLatencyBuckets returns the latency buckets with the given defaults, if
+// any, and overrides by procedure pattern. A pattern is either a procedure
+// name, or a prefix of procedure names followed by "*".
+func newLatencyBuckets(defaults []int64, overrides map[string][]int64) *latencyBuckets {
+	if len(defaults) == 0 {
+		defaults = _bucketsMs
+	}
+	b := &latencyBuckets{
+		defaults: defaults,
+		exact:    make(map[string][]int64),
+	}
+	for pattern, buckets := range overrides {
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			b.prefixes = append(b.prefixes, latencyBucketsPrefix{prefix: prefix, buckets: buckets})
+		} else {
+			b.exact[pattern] = buckets
+		}
+	}
+	sort.Slice(b.prefixes, func(i, j int) bool {
+		return len(b.prefixes[i].prefix) > len(b.prefixes[j].prefix)
+	})
+	return b
+}
+
+// forProcedure returns the latency buckets of the procedure.
+func (b *latencyBuckets) forProcedure(procedure string) []int64 {
+	if b == nil {
+		return _bucketsMs
+	}
+	if buckets, ok := b.exact[procedure]; ok {
+		return buckets
+	}
+	for _, p := range b.prefixes {
+		if strings.HasPrefix(procedure, p.prefix) {
+			return p.buckets
+		}
+	}
+	return b.defaults
+}
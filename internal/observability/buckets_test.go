@@ -0,0 +1,155 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package observability
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/zap"
+)
+
+func TestLatencyBuckets(t *testing.T) {
+	buckets, err := LatencyBuckets([]time.Duration{0, time.Millisecond, 250 * time.Millisecond, 2 * time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 1, 250, 2000}, buckets)
+
+	tests := []struct {
+		desc    string
+		give    []time.Duration
+		wantErr string
+	}{
+		{
+			desc:    "empty",
+			wantErr: "at least one bucket is required",
+		},
+		{
+			desc:    "negative",
+			give:    []time.Duration{-time.Millisecond},
+			wantErr: "bucket -1ms must not be negative",
+		},
+		{
+			desc:    "fraction of a millisecond",
+			give:    []time.Duration{500 * time.Microsecond},
+			wantErr: "bucket 500µs is not a whole number of milliseconds",
+		},
+		{
+			desc:    "not ascending",
+			give:    []time.Duration{5 * time.Millisecond, 5 * time.Millisecond},
+			wantErr: "buckets must be ascending, got 5ms after 5ms",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := LatencyBuckets(tt.give)
+			require.Error(t, err)
+			assert.Equal(t, tt.wantErr, err.Error())
+		})
+	}
+}
+
+func TestLatencyBucketsForProcedure(t *testing.T) {
+	b := newLatencyBuckets([]int64{1, 2}, map[string][]int64{
+		"Cache::get": {0, 1},
+		"Cache::*":   {0, 1, 2},
+		"Batch::*":   {1000, 5000},
+		"Batch::r*":  {10000, 60000},
+	})
+
+	assert.Equal(t, []int64{0, 1}, b.forProcedure("Cache::get"))
+	assert.Equal(t, []int64{0, 1, 2}, b.forProcedure("Cache::set"))
+	assert.Equal(t, []int64{1000, 5000}, b.forProcedure("Batch::scan"))
+	assert.Equal(t, []int64{10000, 60000}, b.forProcedure("Batch::run"), "longer prefixes take precedence")
+	assert.Equal(t, []int64{1, 2}, b.forProcedure("Other::get"))
+
+	assert.Equal(t, _bucketsMs, newLatencyBuckets(nil, nil).forProcedure("Other::get"))
+	var unset *latencyBuckets
+	assert.Equal(t, _bucketsMs, unset.forProcedure("Other::get"))
+}
+
+// sleepyHandler advances the stubbed clock by the duration of the procedure
+// of each request.
+type sleepyHandler struct {
+	now      *time.Time
+	duration map[string]time.Duration
+}
+
+func (h sleepyHandler) Handle(_ context.Context, req *transport.Request, _ transport.ResponseWriter) error {
+	*h.now = h.now.Add(h.duration[req.Procedure])
+	return nil
+}
+
+func TestMiddlewareLatencyBuckets(t *testing.T) {
+	var now time.Time
+	prev := _timeNow
+	_timeNow = func() time.Time { return now }
+	defer func() { _timeNow = prev }()
+
+	root := metrics.New()
+	mw := NewMiddleware(Config{
+		Logger:           zap.NewNop(),
+		Scope:            root.Scope(),
+		ContextExtractor: NewNopContextExtractor(),
+		LatencyBuckets:   []int64{10, 100, 1000},
+		LatencyBucketOverrides: map[string][]int64{
+			"Cache::*":   {0, 1, 2},
+			"Batch::run": {1000, 10000, 60000},
+		},
+	})
+
+	h := sleepyHandler{now: &now, duration: map[string]time.Duration{
+		"Cache::get": 300 * time.Microsecond,
+		"Cache::set": 1500 * time.Microsecond,
+		"Batch::run": 7 * time.Second,
+		"Store::get": 50 * time.Millisecond,
+	}}
+	for _, procedure := range []string{"Cache::get", "Cache::get", "Cache::set", "Batch::run", "Store::get"} {
+		err := mw.Handle(context.Background(), &transport.Request{
+			Caller:    "caller",
+			Service:   "service",
+			Transport: "http",
+			Encoding:  "raw",
+			Procedure: procedure,
+		}, &transporttest.FakeResponseWriter{}, h)
+		require.NoError(t, err)
+	}
+
+	got := make(map[string][]int64)
+	for _, h := range root.Snapshot().Histograms {
+		if h.Name == "success_latency_ms" {
+			assert.Equal(t, time.Millisecond, h.Unit, "unit must not change")
+			got[h.Tags["procedure"]] = h.Values
+		}
+	}
+	// Tag values are scrubbed of the colons of procedure names.
+	assert.Equal(t, map[string][]int64{
+		"Cache__get": {0, 0},
+		"Cache__set": {1},
+		"Batch__run": {10000},
+		"Store__get": {100},
+	}, got)
+}
@@ -25,6 +25,8 @@ import (
 	"fmt"
 	"time"
 
+	"go.uber.org/atomic"
+	"go.uber.org/net/metrics"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/zap"
@@ -64,7 +66,8 @@ const (
 type call struct {
 	edge    *edge
 	extract ContextExtractor
-	fields  [10]zapcore.Field
+	redact  *redactor
+	fields  [11]zapcore.Field
 
 	started   time.Time
 	ctx       context.Context
@@ -73,6 +76,17 @@ type call struct {
 	direction directionName
 
 	levels *levels
+
+	// streamSizes accumulates the payload sizes of the messages of a
+	// stream, if payload size histograms are enabled.
+	streamSizes *streamPayloadSizes
+}
+
+// streamPayloadSizes is the total size of the request and response messages
+// of a stream so far.
+type streamPayloadSizes struct {
+	request  atomic.Int64
+	response atomic.Int64
 }
 
 type callResult struct {
@@ -117,7 +131,7 @@ func (c call) EndHandleWithAppError(res callResult) {
 	if res.isApplicationError && res.err == nil { // Thrift exceptions
 		droppedField = zap.String(_dropped, _droppedAppErrLog)
 	} else if res.err != nil { // other errors
-		droppedField = zap.String(_dropped, fmt.Sprintf(_droppedErrLogFmt, res.err))
+		droppedField = zap.String(_dropped, fmt.Sprintf(_droppedErrLogFmt, c.redact.message(c.req.Procedure, res.err.Error())))
 	} else {
 		droppedField = zap.String(_dropped, _droppedSuccessLog)
 	}
@@ -227,6 +241,9 @@ func (c call) endLogs(
 	fields = append(fields, zap.Duration("latency", elapsed))
 	fields = append(fields, zap.Bool("successful", err == nil && !isApplicationError))
 	fields = append(fields, c.extract(c.ctx))
+	if f, ok := c.redact.headers(c.req); ok {
+		fields = append(fields, f)
+	}
 	if deadlineTime, ok := c.ctx.Deadline(); ok {
 		fields = append(fields, zap.Duration("timeout", deadlineTime.Sub(c.started)))
 	}
@@ -238,29 +255,29 @@ func (c call) endLogs(
 				fields = append(fields, zap.String(_errorCodeLogKey, applicationErrorMeta.Code.String()))
 			}
 			if applicationErrorMeta.Name != "" {
-				fields = append(fields, zap.String(_errorNameLogKey, applicationErrorMeta.Name))
+				fields = append(fields, zap.String(_errorNameLogKey, c.redact.message(c.req.Procedure, applicationErrorMeta.Name)))
 			}
 			if applicationErrorMeta.Details != "" {
-				fields = append(fields, zap.String(_errorDetailsLogKey, applicationErrorMeta.Details))
+				fields = append(fields, zap.String(_errorDetailsLogKey, c.redact.message(c.req.Procedure, applicationErrorMeta.Details)))
 			}
 		}
 
 	} else if isApplicationError { // Protobuf error
-		fields = append(fields, zap.Error(err))
+		fields = append(fields, c.redact.error(c.req.Procedure, err))
 		fields = append(fields, zap.String(_errorCodeLogKey, yarpcerrors.FromError(err).Code().String()))
 		if applicationErrorMeta != nil {
 			// ignore transport.ApplicationErrorMeta#Code, since we should get this
 			// directly from the error
 			if applicationErrorMeta.Name != "" {
-				fields = append(fields, zap.String(_errorNameLogKey, applicationErrorMeta.Name))
+				fields = append(fields, zap.String(_errorNameLogKey, c.redact.message(c.req.Procedure, applicationErrorMeta.Name)))
 			}
 			if applicationErrorMeta.Details != "" {
-				fields = append(fields, zap.String(_errorDetailsLogKey, applicationErrorMeta.Details))
+				fields = append(fields, zap.String(_errorDetailsLogKey, c.redact.message(c.req.Procedure, applicationErrorMeta.Details)))
 			}
 		}
 
 	} else if err != nil { // unknown error
-		fields = append(fields, zap.Error(err))
+		fields = append(fields, c.redact.error(c.req.Procedure, err))
 		fields = append(fields, zap.String(_errorCodeLogKey, yarpcerrors.FromError(err).Code().String()))
 	}
 
@@ -278,14 +295,14 @@ func (c call) endStats(
 		c.edge.ttls.Observe(deadlineTime.Sub(c.started))
 	}
 
-	c.edge.requestPayloadSizes.IncBucket(int64(res.requestSize))
+	incPayloadSize(c.edge.requestPayloadSizes, res.requestSize)
 
 	if res.err == nil && !res.isApplicationError {
 		c.edge.successes.Inc()
 		c.edge.latencies.Observe(elapsed)
 
 		if c.rpcType == transport.Unary {
-			c.edge.responsePayloadSizes.IncBucket(int64(res.responseSize))
+			incPayloadSize(c.edge.responsePayloadSizes, res.responseSize)
 		}
 		return
 	}
@@ -397,9 +414,21 @@ func (c call) EndStream(err error) {
 
 	c.edge.streaming.streamsActive.Dec()
 	c.edge.streaming.streamDurations.Observe(elapsed)
+	if c.streamSizes != nil {
+		c.edge.streaming.streamRequestTotalPayloadSizes.IncBucket(c.streamSizes.request.Load())
+		c.edge.streaming.streamResponseTotalPayloadSizes.IncBucket(c.streamSizes.response.Load())
+	}
 	c.emitStreamError(err)
 }
 
+// incPayloadSize records a payload size in a histogram, unless the size is
+// unknown, like the size of HTTP bodies without a Content-Length.
+func incPayloadSize(h *metrics.Histogram, size int) {
+	if size >= 0 {
+		h.IncBucket(int64(size))
+	}
+}
+
 // EndStreamWithPanic ends the stream call with additional panic metrics
 func (c call) EndStreamWithPanic(err error) {
 	c.edge.panics.Inc()
@@ -490,7 +519,10 @@ func (c call) logStreamEvent(err error, success bool, succMsg, errMsg string, ex
 		zap.String("rpcType", c.rpcType.String()),
 		zap.Bool("successful", success),
 		c.extract(c.ctx),
-		zap.Error(err), // no-op if err == nil
+		c.redact.error(c.req.Procedure, err), // no-op if err == nil
+	}
+	if f, ok := c.redact.headers(c.req); ok {
+		fields = append(fields, f)
 	}
 	fields = append(fields, extraFields...)
 
@@ -36,8 +36,8 @@ import (
 var (
 	_timeNow          = time.Now // for tests
 	_defaultGraphSize = 128
-	// Latency buckets for histograms. At some point, we may want to make these
-	// configurable.
+	// Default latency buckets for histograms, which may be overridden with
+	// Config.LatencyBuckets.
 	_bucketsMs = bucket.NewRPCLatency()
 	// Bytes buckets for payload size histograms, containing exponential buckets
 	// in range of 0B, 1B, 2B, ... 256MB.
@@ -61,7 +61,11 @@ type graph struct {
 	meter               *metrics.Scope
 	logger              *zap.Logger
 	extract             ContextExtractor
+	redact              *redactor
 	metricTagsBlocklist []string
+	latencyBuckets      *latencyBuckets
+	// payloadSizes enables the payload size histograms of edges.
+	payloadSizes bool
 
 	edgesMu sync.RWMutex
 	edges   map[string]*edge
@@ -76,6 +80,7 @@ func newGraph(meter *metrics.Scope, logger *zap.Logger, extract ContextExtractor
 		logger:              logger,
 		extract:             extract,
 		metricTagsBlocklist: metricTagsBlocklist,
+		payloadSizes:        true,
 		inboundLevels: levels{
 			success:          zapcore.DebugLevel,
 			failure:          zapcore.ErrorLevel,
@@ -115,15 +120,22 @@ func (g *graph) begin(ctx context.Context, rpcType transport.Type, direction dir
 		levels = &g.outboundLevels
 	}
 
+	var streamSizes *streamPayloadSizes
+	if rpcType == transport.Streaming && g.payloadSizes {
+		streamSizes = new(streamPayloadSizes)
+	}
+
 	return call{
-		edge:      e,
-		extract:   g.extract,
-		started:   now,
-		ctx:       ctx,
-		req:       req,
-		rpcType:   rpcType,
-		direction: direction,
-		levels:    levels,
+		edge:        e,
+		extract:     g.extract,
+		redact:      g.redact,
+		started:     now,
+		ctx:         ctx,
+		req:         req,
+		rpcType:     rpcType,
+		direction:   direction,
+		levels:      levels,
+		streamSizes: streamSizes,
 	}
 }
 
@@ -151,7 +163,7 @@ func (g *graph) createEdge(key []byte, req *transport.Request, direction string,
 		return e
 	}
 
-	e := newEdge(g.logger, g.meter, g.metricTagsBlocklist, req, direction, rpcType)
+	e := newEdge(g.logger, g.meter, g.metricTagsBlocklist, g.latencyBuckets.forProcedure(req.Procedure), g.payloadSizes, req, direction, rpcType)
 	g.edges[string(key)] = e
 	return e
 }
@@ -187,16 +199,18 @@ type streamEdge struct {
 	receiveSuccesses *metrics.Counter
 	receiveFailures  *metrics.CounterVector
 
-	streamDurations            *metrics.Histogram
-	streamRequestPayloadSizes  *metrics.Histogram
-	streamResponsePayloadSizes *metrics.Histogram
+	streamDurations                 *metrics.Histogram
+	streamRequestPayloadSizes       *metrics.Histogram
+	streamResponsePayloadSizes      *metrics.Histogram
+	streamRequestTotalPayloadSizes  *metrics.Histogram
+	streamResponseTotalPayloadSizes *metrics.Histogram
 
 	streamsActive *metrics.Gauge
 }
 
 // newEdge constructs a new edge. Since Registries enforce metric uniqueness,
 // edges should be cached and re-used for each RPC.
-func newEdge(logger *zap.Logger, meter *metrics.Scope, metricTagsBlocklist []string, req *transport.Request, direction string, rpcType transport.Type) *edge {
+func newEdge(logger *zap.Logger, meter *metrics.Scope, metricTagsBlocklist []string, latencyBuckets []int64, payloadSizes bool, req *transport.Request, direction string, rpcType transport.Type) *edge {
 	tags := metrics.Tags{
 		"source":           req.Caller,
 		"dest":             req.Service,
@@ -268,7 +282,7 @@ func newEdge(logger *zap.Logger, meter *metrics.Scope, metricTagsBlocklist []str
 				ConstTags: tags,
 			},
 			Unit:    time.Millisecond,
-			Buckets: _bucketsMs,
+			Buckets: latencyBuckets,
 		})
 		if err != nil {
 			logger.Error("Failed to create success latency distribution.", zap.Error(err))
@@ -280,7 +294,7 @@ func newEdge(logger *zap.Logger, meter *metrics.Scope, metricTagsBlocklist []str
 				ConstTags: tags,
 			},
 			Unit:    time.Millisecond,
-			Buckets: _bucketsMs,
+			Buckets: latencyBuckets,
 		})
 		if err != nil {
 			logger.Error("Failed to create caller failure latency distribution.", zap.Error(err))
@@ -292,7 +306,7 @@ func newEdge(logger *zap.Logger, meter *metrics.Scope, metricTagsBlocklist []str
 				ConstTags: tags,
 			},
 			Unit:    time.Millisecond,
-			Buckets: _bucketsMs,
+			Buckets: latencyBuckets,
 		})
 		if err != nil {
 			logger.Error("Failed to create server failure latency distribution.", zap.Error(err))
@@ -321,6 +335,8 @@ func newEdge(logger *zap.Logger, meter *metrics.Scope, metricTagsBlocklist []str
 		if err != nil {
 			logger.Error("Failed to create timeout ttl distribution.", zap.Error(err))
 		}
+	}
+	if payloadSizes && (rpcType == transport.Unary || rpcType == transport.Oneway) {
 		requestPayloadSizes, err = meter.Histogram(metrics.HistogramSpec{
 			Spec: metrics.Spec{
 				Name:      "request_payload_size_bytes",
@@ -412,36 +428,66 @@ func newEdge(logger *zap.Logger, meter *metrics.Scope, metricTagsBlocklist []str
 				ConstTags: tags,
 			},
 			Unit:    time.Millisecond,
-			Buckets: _bucketsMs,
+			Buckets: latencyBuckets,
 		})
 		if err != nil {
 			logger.DPanic("Failed to create stream duration histogram.", zap.Error(err))
 		}
 
-		streamRequestPayloadSizes, err := meter.Histogram(metrics.HistogramSpec{
-			Spec: metrics.Spec{
-				Name:      "stream_request_payload_size_bytes",
-				Help:      "Stream request payload size distribution",
-				ConstTags: tags,
-			},
-			Unit:    time.Millisecond,
-			Buckets: _bucketsBytes,
-		})
-		if err != nil {
-			logger.DPanic("Failed to create stream request payload size histogram", zap.Error(err))
-		}
-
-		streamResponsePayloadSizes, err := meter.Histogram(metrics.HistogramSpec{
-			Spec: metrics.Spec{
-				Name:      "stream_response_payload_size_bytes",
-				Help:      "Stream response payload size distribution",
-				ConstTags: tags,
-			},
-			Unit:    time.Millisecond,
-			Buckets: _bucketsBytes,
-		})
-		if err != nil {
-			logger.DPanic("Failed to create stream response payload size histogram", zap.Error(err))
+		var streamRequestPayloadSizes, streamResponsePayloadSizes,
+			streamRequestTotalPayloadSizes, streamResponseTotalPayloadSizes *metrics.Histogram
+		if payloadSizes {
+			streamRequestPayloadSizes, err = meter.Histogram(metrics.HistogramSpec{
+				Spec: metrics.Spec{
+					Name:      "stream_request_payload_size_bytes",
+					Help:      "Stream request payload size distribution",
+					ConstTags: tags,
+				},
+				Unit:    time.Millisecond,
+				Buckets: _bucketsBytes,
+			})
+			if err != nil {
+				logger.DPanic("Failed to create stream request payload size histogram", zap.Error(err))
+			}
+
+			streamResponsePayloadSizes, err = meter.Histogram(metrics.HistogramSpec{
+				Spec: metrics.Spec{
+					Name:      "stream_response_payload_size_bytes",
+					Help:      "Stream response payload size distribution",
+					ConstTags: tags,
+				},
+				Unit:    time.Millisecond,
+				Buckets: _bucketsBytes,
+			})
+			if err != nil {
+				logger.DPanic("Failed to create stream response payload size histogram", zap.Error(err))
+			}
+
+			streamRequestTotalPayloadSizes, err = meter.Histogram(metrics.HistogramSpec{
+				Spec: metrics.Spec{
+					Name:      "stream_request_total_payload_size_bytes",
+					Help:      "Distribution of the total size of the request payloads of streams",
+					ConstTags: tags,
+				},
+				Unit:    time.Millisecond,
+				Buckets: _bucketsBytes,
+			})
+			if err != nil {
+				logger.DPanic("Failed to create stream request total payload size histogram", zap.Error(err))
+			}
+
+			streamResponseTotalPayloadSizes, err = meter.Histogram(metrics.HistogramSpec{
+				Spec: metrics.Spec{
+					Name:      "stream_response_total_payload_size_bytes",
+					Help:      "Distribution of the total size of the response payloads of streams",
+					ConstTags: tags,
+				},
+				Unit:    time.Millisecond,
+				Buckets: _bucketsBytes,
+			})
+			if err != nil {
+				logger.DPanic("Failed to create stream response total payload size histogram", zap.Error(err))
+			}
 		}
 
 		streamsActive, err := meter.Gauge(metrics.Spec{
@@ -461,9 +507,11 @@ func newEdge(logger *zap.Logger, meter *metrics.Scope, metricTagsBlocklist []str
 			receiveSuccesses: receiveSuccesses,
 			receiveFailures:  receiveFailures,
 
-			streamDurations:            streamDurations,
-			streamRequestPayloadSizes:  streamRequestPayloadSizes,
-			streamResponsePayloadSizes: streamResponsePayloadSizes,
+			streamDurations:                 streamDurations,
+			streamRequestPayloadSizes:       streamRequestPayloadSizes,
+			streamResponsePayloadSizes:      streamResponsePayloadSizes,
+			streamRequestTotalPayloadSizes:  streamRequestTotalPayloadSizes,
+			streamResponseTotalPayloadSizes: streamResponseTotalPayloadSizes,
 
 			streamsActive: streamsActive,
 		}
@@ -94,11 +94,33 @@ type Config struct {
 	// metrics emitted by the middleware.
 	MetricTagsBlocklist []string
 
+	// LatencyBuckets are the upper bounds, in milliseconds, of the buckets
+	// of the latency histograms of the middleware, like success_latency_ms.
+	// Use LatencyBuckets to convert durations.
+	//
+	// Defaults to the buckets of bucket.NewRPCLatency.
+	LatencyBuckets []int64
+
+	// LatencyBucketOverrides are the latency buckets of procedures, by
+	// procedure pattern. A pattern is either a procedure name, or a prefix
+	// of procedure names followed by "*", like "Store::*". Procedure names
+	// take precedence over prefixes, and longer prefixes over shorter ones.
+	LatencyBucketOverrides map[string][]int64
+
+	// DisablePayloadSizeMetrics stops the middleware from recording the
+	// histograms of the sizes of request and response payloads, like
+	// request_payload_size_bytes.
+	DisablePayloadSizeMetrics bool
+
 	// ContextExtractor Extracts request-scoped information from the context for logging.
 	ContextExtractor ContextExtractor
 
 	// Levels specify log levels for various classes of requests.
 	Levels LevelsConfig
+
+	// Redaction scrubs request headers and error messages before they are
+	// logged.
+	Redaction RedactionConfig
 }
 
 // LevelsConfig specifies log level overrides for inbound traffic, outbound
@@ -148,6 +170,9 @@ type DirectionalLevelsConfig struct {
 // configuration.
 func NewMiddleware(cfg Config) *Middleware {
 	m := &Middleware{newGraph(cfg.Scope, cfg.Logger, cfg.ContextExtractor, cfg.MetricTagsBlocklist)}
+	m.graph.redact = newRedactor(cfg.Redaction)
+	m.graph.latencyBuckets = newLatencyBuckets(cfg.LatencyBuckets, cfg.LatencyBucketOverrides)
+	m.graph.payloadSizes = !cfg.DisablePayloadSizeMetrics
 
 	// Apply the default levels
 	applyLogLevelsConfig(&m.graph.inboundLevels, &cfg.Levels.Default)
@@ -184,6 +209,7 @@ func (m *Middleware) Handle(ctx context.Context, req *transport.Request, w trans
 	call := m.graph.begin(ctx, transport.Unary, _directionInbound, req)
 	defer m.handlePanicForCall(call, transport.Unary)
 
+	requestSize := requestBodySize(req)
 	wrappedWriter := newWriter(w)
 	err := h.Handle(ctx, req, wrappedWriter)
 	ctxErr := ctxErrOverride(ctx, req)
@@ -194,7 +220,7 @@ func (m *Middleware) Handle(ctx context.Context, req *transport.Request, w trans
 			ctxOverrideErr:       ctxErr,
 			isApplicationError:   wrappedWriter.isApplicationError,
 			applicationErrorMeta: wrappedWriter.applicationErrorMeta,
-			requestSize:          req.BodySize,
+			requestSize:          requestSize,
 			responseSize:         wrappedWriter.responseSize,
 		})
 
@@ -210,6 +236,7 @@ func (m *Middleware) Call(ctx context.Context, req *transport.Request, out trans
 	call := m.graph.begin(ctx, transport.Unary, _directionOutbound, req)
 	defer m.handlePanicForCall(call, transport.Unary)
 
+	requestSize := requestBodySize(req)
 	res, err := out.Call(ctx, req)
 
 	isApplicationError := false
@@ -224,7 +251,7 @@ func (m *Middleware) Call(ctx context.Context, req *transport.Request, out trans
 		err:                  err,
 		isApplicationError:   isApplicationError,
 		applicationErrorMeta: applicationErrorMeta,
-		requestSize:          req.BodySize,
+		requestSize:          requestSize,
 		responseSize:         responseSize,
 	}
 	call.EndCallWithAppError(callRes)
@@ -236,8 +263,9 @@ func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h
 	call := m.graph.begin(ctx, transport.Oneway, _directionInbound, req)
 	defer m.handlePanicForCall(call, transport.Oneway)
 
+	requestSize := requestBodySize(req)
 	err := h.HandleOneway(ctx, req)
-	call.End(callResult{err: err, requestSize: req.BodySize})
+	call.End(callResult{err: err, requestSize: requestSize})
 	return err
 }
 
@@ -246,8 +274,9 @@ func (m *Middleware) CallOneway(ctx context.Context, req *transport.Request, out
 	call := m.graph.begin(ctx, transport.Oneway, _directionOutbound, req)
 	defer m.handlePanicForCall(call, transport.Oneway)
 
+	requestSize := requestBodySize(req)
 	ack, err := out.CallOneway(ctx, req)
-	call.End(callResult{err: err, requestSize: req.BodySize})
+	call.End(callResult{err: err, requestSize: requestSize})
 	return ack, err
 }
 
@@ -280,6 +309,20 @@ func (m *Middleware) CallStream(ctx context.Context, request *transport.StreamRe
 	return call.WrapClientStream(clientStream), nil
 }
 
+// requestBodySize returns the size of the body of a request, which must be
+// measured before the request is sent or handled. Transports and encodings
+// set BodySize, but bodies that are already buffered, like those of raw
+// calls, are measured without reading them.
+func requestBodySize(req *transport.Request) int {
+	if req.BodySize > 0 {
+		return req.BodySize
+	}
+	if body, ok := req.Body.(interface{ Len() int }); ok {
+		return body.Len()
+	}
+	return req.BodySize
+}
+
 func ctxErrOverride(ctx context.Context, req *transport.Request) (ctxErr error) {
 	if ctx.Err() == context.DeadlineExceeded {
 		return yarpcerrors.DeadlineExceededErrorf(
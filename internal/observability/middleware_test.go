@@ -3317,11 +3317,23 @@ func TestStreamingInboundApplicationPanics(t *testing.T) {
 					Tags: tags,
 					Unit: time.Millisecond,
 				},
+				{
+					Name:   "stream_request_total_payload_size_bytes",
+					Tags:   tags,
+					Unit:   time.Millisecond,
+					Values: []int64{0},
+				},
 				{
 					Name: "stream_response_payload_size_bytes",
 					Tags: tags,
 					Unit: time.Millisecond,
 				},
+				{
+					Name:   "stream_response_total_payload_size_bytes",
+					Tags:   tags,
+					Unit:   time.Millisecond,
+					Values: []int64{0},
+				},
 			},
 		}
 		assert.Equal(t, want, root.Snapshot(), "unexpected metrics snapshot")
@@ -3408,11 +3420,23 @@ func TestStreamingOutboundApplicationPanics(t *testing.T) {
 					Tags: tags,
 					Unit: time.Millisecond,
 				},
+				{
+					Name:   "stream_request_total_payload_size_bytes",
+					Tags:   tags,
+					Unit:   time.Millisecond,
+					Values: []int64{0},
+				},
 				{
 					Name: "stream_response_payload_size_bytes",
 					Tags: tags,
 					Unit: time.Millisecond,
 				},
+				{
+					Name:   "stream_response_total_payload_size_bytes",
+					Tags:   tags,
+					Unit:   time.Millisecond,
+					Values: []int64{0},
+				},
 			},
 		}
 		assert.Equal(t, want, root.Snapshot(), "unexpected metrics snapshot")
@@ -3510,7 +3534,9 @@ func TestStreamingMetrics(t *testing.T) {
 			Histograms: []metrics.HistogramSnapshot{
 				{Name: "stream_duration_ms", Tags: tags, Unit: time.Millisecond, Values: []int64{1}},
 				{Name: "stream_request_payload_size_bytes", Tags: tags, Unit: time.Millisecond, Values: []int64{8}},
+				{Name: "stream_request_total_payload_size_bytes", Tags: tags, Unit: time.Millisecond, Values: []int64{8}},
 				{Name: "stream_response_payload_size_bytes", Tags: tags, Unit: time.Millisecond, Values: []int64{4}},
+				{Name: "stream_response_total_payload_size_bytes", Tags: tags, Unit: time.Millisecond, Values: []int64{4}},
 			},
 		}
 		assert.Equal(t, want, snap, "unexpected metrics snapshot")
@@ -3593,7 +3619,9 @@ func TestStreamingMetrics(t *testing.T) {
 					Histograms: []metrics.HistogramSnapshot{
 						{Name: "stream_duration_ms", Tags: successTags, Unit: time.Millisecond, Values: []int64{1}},
 						{Name: "stream_request_payload_size_bytes", Tags: successTags, Unit: time.Millisecond},
+						{Name: "stream_request_total_payload_size_bytes", Tags: successTags, Unit: time.Millisecond, Values: []int64{0}},
 						{Name: "stream_response_payload_size_bytes", Tags: successTags, Unit: time.Millisecond},
+						{Name: "stream_response_total_payload_size_bytes", Tags: successTags, Unit: time.Millisecond, Values: []int64{0}},
 					},
 				}
 				assert.Equal(t, want, snap, "unexpected metrics snapshot")
@@ -3651,7 +3679,9 @@ func TestStreamingMetrics(t *testing.T) {
 			Histograms: []metrics.HistogramSnapshot{
 				{Name: "stream_duration_ms", Tags: successTags, Unit: time.Millisecond, Values: []int64{1}},
 				{Name: "stream_request_payload_size_bytes", Tags: successTags, Unit: time.Millisecond},
+				{Name: "stream_request_total_payload_size_bytes", Tags: successTags, Unit: time.Millisecond, Values: []int64{0}},
 				{Name: "stream_response_payload_size_bytes", Tags: successTags, Unit: time.Millisecond},
+				{Name: "stream_response_total_payload_size_bytes", Tags: successTags, Unit: time.Millisecond, Values: []int64{0}},
 			},
 		}
 		assert.Equal(t, want, snap, "unexpected metrics snapshot")
@@ -3694,7 +3724,9 @@ func TestStreamingMetrics(t *testing.T) {
 			Histograms: []metrics.HistogramSnapshot{
 				{Name: "stream_duration_ms", Tags: tags, Unit: time.Millisecond, Values: []int64{1}},
 				{Name: "stream_request_payload_size_bytes", Tags: tags, Unit: time.Millisecond},
+				{Name: "stream_request_total_payload_size_bytes", Tags: tags, Unit: time.Millisecond, Values: []int64{0}},
 				{Name: "stream_response_payload_size_bytes", Tags: tags, Unit: time.Millisecond},
+				{Name: "stream_response_total_payload_size_bytes", Tags: tags, Unit: time.Millisecond, Values: []int64{0}},
 			},
 		}
 		assert.Equal(t, want, snap, "unexpected metrics snapshot")
@@ -3736,7 +3768,9 @@ func TestStreamingMetrics(t *testing.T) {
 			Histograms: []metrics.HistogramSnapshot{
 				{Name: "stream_duration_ms", Tags: successTags, Unit: time.Millisecond},
 				{Name: "stream_request_payload_size_bytes", Tags: successTags, Unit: time.Millisecond},
+				{Name: "stream_request_total_payload_size_bytes", Tags: successTags, Unit: time.Millisecond},
 				{Name: "stream_response_payload_size_bytes", Tags: successTags, Unit: time.Millisecond},
+				{Name: "stream_response_total_payload_size_bytes", Tags: successTags, Unit: time.Millisecond},
 			},
 		}
 		assert.Equal(t, want, snap, "unexpected metrics snapshot")
@@ -3795,13 +3829,151 @@ func TestStreamingMetrics(t *testing.T) {
 			Histograms: []metrics.HistogramSnapshot{
 				{Name: "stream_duration_ms", Tags: successTags, Unit: time.Millisecond, Values: []int64{1}},
 				{Name: "stream_request_payload_size_bytes", Tags: successTags, Unit: time.Millisecond},
+				{Name: "stream_request_total_payload_size_bytes", Tags: successTags, Unit: time.Millisecond, Values: []int64{0}},
 				{Name: "stream_response_payload_size_bytes", Tags: successTags, Unit: time.Millisecond},
+				{Name: "stream_response_total_payload_size_bytes", Tags: successTags, Unit: time.Millisecond, Values: []int64{0}},
 			},
 		}
 		assert.Equal(t, want, snap, "unexpected metrics snapshot")
 	})
 }
 
+func TestPayloadSizeMetrics(t *testing.T) {
+	defer stubTime()()
+
+	req := &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  "raw",
+		Procedure: "procedure",
+	}
+	streamReq := &transport.StreamRequest{
+		Meta: &transport.RequestMeta{
+			Caller:    "caller",
+			Service:   "service",
+			Encoding:  "raw",
+			Procedure: "procedure",
+		},
+	}
+
+	// histogramValues returns the values of the histograms of a snapshot by
+	// name.
+	histogramValues := func(snap *metrics.RootSnapshot) map[string][]int64 {
+		values := make(map[string][]int64, len(snap.Histograms))
+		for _, h := range snap.Histograms {
+			values[h.Name] = h.Values
+		}
+		return values
+	}
+
+	t.Run("outbound stream", func(t *testing.T) {
+		root := metrics.New()
+		mw := NewMiddleware(Config{
+			Logger:           zap.NewNop(),
+			Scope:            root.Scope(),
+			ContextExtractor: NewNopContextExtractor(),
+		})
+
+		stream, err := mw.CallStream(context.Background(), streamReq, fakeOutbound{
+			stream: fakeStream{
+				receiveMsg: &transport.StreamMessage{
+					Body:     readCloser{bytes.NewReader([]byte("test"))},
+					BodySize: 4,
+				},
+			}})
+		require.NoError(t, err)
+
+		for _, body := range []string{"Foobar", "Foo"} {
+			require.NoError(t, stream.SendMessage(context.Background(), &transport.StreamMessage{
+				Body:     readCloser{bytes.NewReader([]byte(body))},
+				BodySize: len(body),
+			}))
+		}
+		_, err = stream.ReceiveMessage(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, stream.Close(context.Background()))
+
+		values := histogramValues(root.Snapshot())
+		assert.Equal(t, []int64{4, 8}, values["stream_request_payload_size_bytes"])
+		assert.Equal(t, []int64{16}, values["stream_request_total_payload_size_bytes"])
+		assert.Equal(t, []int64{4}, values["stream_response_payload_size_bytes"])
+		assert.Equal(t, []int64{4}, values["stream_response_total_payload_size_bytes"])
+	})
+
+	t.Run("buffered request body", func(t *testing.T) {
+		root := metrics.New()
+		mw := NewMiddleware(Config{
+			Logger:           zap.NewNop(),
+			Scope:            root.Scope(),
+			ContextExtractor: NewNopContextExtractor(),
+		})
+
+		bufferedReq := *req
+		bufferedReq.Body = bytes.NewReader([]byte("Foobar"))
+		_, err := mw.Call(context.Background(), &bufferedReq, fakeOutbound{body: []byte("test")})
+		require.NoError(t, err)
+
+		values := histogramValues(root.Snapshot())
+		assert.Equal(t, []int64{8}, values["request_payload_size_bytes"])
+		assert.Equal(t, []int64{4}, values["response_payload_size_bytes"])
+	})
+
+	t.Run("unknown response size", func(t *testing.T) {
+		root := metrics.New()
+		mw := NewMiddleware(Config{
+			Logger:           zap.NewNop(),
+			Scope:            root.Scope(),
+			ContextExtractor: NewNopContextExtractor(),
+		})
+
+		_, err := mw.Call(context.Background(), req, unknownSizeOutbound{})
+		require.NoError(t, err)
+
+		values := histogramValues(root.Snapshot())
+		assert.Equal(t, []int64{0}, values["request_payload_size_bytes"])
+		assert.Empty(t, values["response_payload_size_bytes"])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		root := metrics.New()
+		mw := NewMiddleware(Config{
+			Logger:                    zap.NewNop(),
+			Scope:                     root.Scope(),
+			ContextExtractor:          NewNopContextExtractor(),
+			DisablePayloadSizeMetrics: true,
+		})
+
+		_, err := mw.Call(context.Background(), req, fakeOutbound{body: []byte("test")})
+		require.NoError(t, err)
+
+		stream, err := mw.CallStream(context.Background(), streamReq, fakeOutbound{})
+		require.NoError(t, err)
+		require.NoError(t, stream.SendMessage(context.Background(), &transport.StreamMessage{
+			Body:     readCloser{bytes.NewReader([]byte("Foobar"))},
+			BodySize: 6,
+		}))
+		require.NoError(t, stream.Close(context.Background()))
+
+		values := histogramValues(root.Snapshot())
+		assert.Contains(t, values, "caller_failure_latency_ms", "expected latency histograms")
+		for name := range values {
+			assert.NotContains(t, name, "payload_size", "unexpected payload size histogram")
+		}
+	})
+}
+
+// unknownSizeOutbound returns responses which do not know the size of their
+// bodies.
+type unknownSizeOutbound struct{ fakeOutbound }
+
+func (o unknownSizeOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	res, err := o.fakeOutbound.Call(ctx, req)
+	if res != nil {
+		res.BodySize = -1
+	}
+	return res, err
+}
+
 func TestNewWriterIsEmpty(t *testing.T) {
 	code := yarpcerrors.CodeDataLoss
 
@@ -0,0 +1,145 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package observability
+
+import (
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const _headersLogKey = "headers"
+
+// RedactionConfig configures how the middleware scrubs request headers and
+// error messages before it logs them.
+type RedactionConfig struct {
+	// LogHeaders logs the headers of requests, after redaction.
+	LogHeaders bool
+
+	// AllowedHeaders, if non-empty, limits the logged headers to these.
+	AllowedHeaders []string
+
+	// DeniedHeaders are never logged, even if a HeaderTransformer returns
+	// them.
+	DeniedHeaders []string
+
+	// HeaderTransformer rewrites the headers of requests to a procedure
+	// before they are logged.
+	HeaderTransformer func(procedure string, headers map[string]string) map[string]string
+
+	// ErrorMessageScrubber rewrites error messages and application error
+	// names and details of calls to a procedure before they are logged.
+	ErrorMessageScrubber func(procedure string, message string) string
+}
+
+// redactor applies a RedactionConfig. A nil redactor logs no headers and
+// leaves error messages as they are.
+type redactor struct {
+	logHeaders bool
+	allowed    map[string]struct{}
+	denied     map[string]struct{}
+	transform  func(procedure string, headers map[string]string) map[string]string
+	scrub      func(procedure string, message string) string
+}
+
+func newRedactor(cfg RedactionConfig) *redactor {
+	return &redactor{
+		logHeaders: cfg.LogHeaders,
+		allowed:    headerSet(cfg.AllowedHeaders),
+		denied:     headerSet(cfg.DeniedHeaders),
+		transform:  cfg.HeaderTransformer,
+		scrub:      cfg.ErrorMessageScrubber,
+	}
+}
+
+func headerSet(names []string) map[string]struct{} {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[transport.CanonicalizeHeaderKey(name)] = struct{}{}
+	}
+	return set
+}
+
+// headers returns the field logging the redacted headers of the request, if
+// headers are logged.
+func (r *redactor) headers(req *transport.Request) (zap.Field, bool) {
+	if r == nil || !r.logHeaders {
+		return zap.Field{}, false
+	}
+
+	headers := req.Headers.Items()
+	if r.transform != nil {
+		// Items returns the headers of the request itself, which the
+		// transformer must not modify.
+		copied := make(map[string]string, len(headers))
+		for k, v := range headers {
+			copied[k] = v
+		}
+		headers = r.transform(req.Procedure, copied)
+	}
+
+	logged := make(loggedHeaders, len(headers))
+	for k, v := range headers {
+		key := transport.CanonicalizeHeaderKey(k)
+		if _, ok := r.denied[key]; ok {
+			continue
+		}
+		if r.allowed != nil {
+			if _, ok := r.allowed[key]; !ok {
+				continue
+			}
+		}
+		logged[key] = v
+	}
+	return zap.Object(_headersLogKey, logged), true
+}
+
+// error returns the field logging the scrubbed message of err, or a no-op
+// field if err is nil.
+func (r *redactor) error(procedure string, err error) zap.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+	if r == nil || r.scrub == nil {
+		return zap.Error(err)
+	}
+	return zap.String(_error, r.scrub(procedure, err.Error()))
+}
+
+// message scrubs an error message or application error metadata field.
+func (r *redactor) message(procedure, msg string) string {
+	if r == nil || r.scrub == nil {
+		return msg
+	}
+	return r.scrub(procedure, msg)
+}
+
+type loggedHeaders map[string]string
+
+func (h loggedHeaders) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for k, v := range h {
+		enc.AddString(k, v)
+	}
+	return nil
+}
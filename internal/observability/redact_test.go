@@ -0,0 +1,270 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package observability
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const _secret = "s3cr3t"
+
+func newRedactingMiddleware(t *testing.T, cfg RedactionConfig) (*Middleware, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return NewMiddleware(Config{
+		Logger:           zap.New(core),
+		Scope:            metrics.New().Scope(),
+		ContextExtractor: NewNopContextExtractor(),
+		Redaction:        cfg,
+	}), logs
+}
+
+func newRedactionRequest() *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Transport: "transport",
+		Encoding:  "raw",
+		Procedure: "procedure",
+		Headers: transport.NewHeaders().
+			With("Authorization", _secret).
+			With("x-token", _secret).
+			With("x-request-source", "tests"),
+	}
+}
+
+// encodeLogs renders the observed logs, so that tests can assert that a
+// secret appears in no field at all.
+func encodeLogs(t *testing.T, logs []observer.LoggedEntry) []string {
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	encoded := make([]string, 0, len(logs))
+	for _, l := range logs {
+		buf, err := enc.EncodeEntry(l.Entry, l.Context)
+		require.NoError(t, err)
+		encoded = append(encoded, buf.String())
+		buf.Free()
+	}
+	return encoded
+}
+
+func TestMiddlewareRedaction(t *testing.T) {
+	defer stubTime()()
+
+	cfg := RedactionConfig{
+		LogHeaders:    true,
+		DeniedHeaders: []string{"authorization"},
+		HeaderTransformer: func(procedure string, headers map[string]string) map[string]string {
+			assert.Equal(t, "procedure", procedure)
+			delete(headers, "x-token")
+			return headers
+		},
+		ErrorMessageScrubber: func(procedure string, msg string) string {
+			assert.Equal(t, "procedure", procedure)
+			return strings.Replace(msg, _secret, "[REDACTED]", -1)
+		},
+	}
+	code := yarpcerrors.CodeInvalidArgument
+	secretErr := yarpcerrors.InvalidArgumentErrorf("bad token %q", _secret)
+
+	tests := []struct {
+		desc string
+		give func(*Middleware, *transport.Request)
+	}{
+		{
+			desc: "inbound success",
+			give: func(mw *Middleware, req *transport.Request) {
+				mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, fakeHandler{})
+			},
+		},
+		{
+			desc: "inbound error",
+			give: func(mw *Middleware, req *transport.Request) {
+				mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, fakeHandler{err: secretErr})
+			},
+		},
+		{
+			desc: "inbound application error",
+			give: func(mw *Middleware, req *transport.Request) {
+				mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, fakeHandler{
+					applicationErr:        true,
+					applicationErrName:    "Token" + _secret,
+					applicationErrDetails: "token: " + _secret,
+					applicationErrCode:    &code,
+				})
+			},
+		},
+		{
+			desc: "inbound application error with error",
+			give: func(mw *Middleware, req *transport.Request) {
+				mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, fakeHandler{
+					err:                   secretErr,
+					applicationErr:        true,
+					applicationErrName:    "Token" + _secret,
+					applicationErrDetails: "token: " + _secret,
+				})
+			},
+		},
+		{
+			desc: "inbound dropped error",
+			give: func(mw *Middleware, req *transport.Request) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				mw.Handle(ctx, req, &transporttest.FakeResponseWriter{}, fakeHandler{err: secretErr})
+			},
+		},
+		{
+			desc: "inbound oneway error",
+			give: func(mw *Middleware, req *transport.Request) {
+				mw.HandleOneway(context.Background(), req, fakeHandler{err: secretErr})
+			},
+		},
+		{
+			desc: "outbound success",
+			give: func(mw *Middleware, req *transport.Request) {
+				mw.Call(context.Background(), req, fakeOutbound{})
+			},
+		},
+		{
+			desc: "outbound error",
+			give: func(mw *Middleware, req *transport.Request) {
+				mw.Call(context.Background(), req, fakeOutbound{err: secretErr})
+			},
+		},
+		{
+			desc: "outbound application error",
+			give: func(mw *Middleware, req *transport.Request) {
+				mw.Call(context.Background(), req, fakeOutbound{
+					applicationErr:        true,
+					applicationErrName:    "Token" + _secret,
+					applicationErrDetails: "token: " + _secret,
+				})
+			},
+		},
+		{
+			desc: "outbound stream error",
+			give: func(mw *Middleware, req *transport.Request) {
+				sreq := &transport.StreamRequest{Meta: req.ToRequestMeta()}
+				mw.CallStream(context.Background(), sreq, fakeOutbound{err: secretErr})
+			},
+		},
+		{
+			desc: "inbound stream",
+			give: func(mw *Middleware, req *transport.Request) {
+				stream, err := transport.NewServerStream(&fakeStream{
+					request: &transport.StreamRequest{Meta: req.ToRequestMeta()},
+					sendErr: secretErr,
+				})
+				require.NoError(t, err)
+				mw.HandleStream(stream, fakeHandler{
+					err: secretErr,
+					handleStream: func(stream *transport.ServerStream) {
+						stream.SendMessage(context.Background(), nil)
+					},
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			mw, logs := newRedactingMiddleware(t, cfg)
+			tt.give(mw, newRedactionRequest())
+
+			entries := logs.TakeAll()
+			require.NotEmpty(t, entries, "expected logs")
+			for i, encoded := range encodeLogs(t, entries) {
+				assert.NotContains(t, encoded, _secret, "secret logged")
+				assert.NotContains(t, encoded, "x-token", "transformed header logged")
+				assert.Contains(t, entries[i].ContextMap(), "headers", "headers not logged")
+			}
+		})
+	}
+}
+
+func TestMiddlewareRedactionHeaders(t *testing.T) {
+	tests := []struct {
+		desc string
+		give RedactionConfig
+		want map[string]interface{}
+	}{
+		{
+			desc: "headers not logged",
+		},
+		{
+			desc: "all headers",
+			give: RedactionConfig{LogHeaders: true},
+			want: map[string]interface{}{
+				"authorization":    _secret,
+				"x-token":          _secret,
+				"x-request-source": "tests",
+			},
+		},
+		{
+			desc: "allowed headers",
+			give: RedactionConfig{
+				LogHeaders:     true,
+				AllowedHeaders: []string{"X-Request-Source", "Authorization"},
+				DeniedHeaders:  []string{"Authorization"},
+			},
+			want: map[string]interface{}{"x-request-source": "tests"},
+		},
+		{
+			desc: "denied headers added by transformer",
+			give: RedactionConfig{
+				LogHeaders:    true,
+				DeniedHeaders: []string{"authorization", "x-token"},
+				HeaderTransformer: func(_ string, headers map[string]string) map[string]string {
+					return map[string]string{"Authorization": _secret, "x-masked": "***"}
+				},
+			},
+			want: map[string]interface{}{"x-masked": "***"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			mw, logs := newRedactingMiddleware(t, tt.give)
+			req := newRedactionRequest()
+			require.NoError(t, mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, fakeHandler{}))
+
+			entries := logs.TakeAll()
+			require.Len(t, entries, 1)
+			headers, ok := entries[0].ContextMap()["headers"]
+			if tt.want == nil {
+				assert.False(t, ok, "headers must not be logged")
+				return
+			}
+			assert.Equal(t, tt.want, headers)
+			assert.Equal(t, _secret, req.Headers.Items()["x-token"], "request headers must not be modified")
+		})
+	}
+}
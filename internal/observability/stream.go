@@ -85,8 +85,8 @@ func (c call) WrapServerStream(stream *transport.ServerStream) *transport.Server
 
 func (s *streamWrapper) SendMessage(ctx context.Context, msg *transport.StreamMessage) error {
 	// TODO: handle panic for metrics
-	if s.call.direction == _directionInbound && msg != nil {
-		s.edge.streamResponsePayloadSizes.IncBucket(int64(msg.BodySize))
+	if msg != nil {
+		s.recordPayloadSize(msg, s.call.direction == _directionOutbound)
 	}
 
 	err := s.StreamCloser.SendMessage(ctx, msg)
@@ -109,8 +109,8 @@ func (s *streamWrapper) SendMessage(ctx context.Context, msg *transport.StreamMe
 func (s *streamWrapper) ReceiveMessage(ctx context.Context) (*transport.StreamMessage, error) {
 	// TODO: handle panic for metrics
 	msg, err := s.StreamCloser.ReceiveMessage(ctx)
-	if err == nil && msg != nil && s.call.direction == _directionInbound {
-		s.edge.streamRequestPayloadSizes.IncBucket(int64(msg.BodySize))
+	if err == nil && msg != nil {
+		s.recordPayloadSize(msg, s.call.direction == _directionInbound)
 	}
 	// Receiving EOF does not constitute an error for the purposes of metrics and alerts.
 	// This is the only special case.
@@ -140,6 +140,22 @@ func (s *streamWrapper) Close(ctx context.Context) error {
 	return err
 }
 
+// recordPayloadSize records the size of a request or response message of the
+// stream, and adds it to the total size of the messages of the stream.
+func (s *streamWrapper) recordPayloadSize(msg *transport.StreamMessage, request bool) {
+	if s.call.streamSizes == nil || msg.BodySize < 0 {
+		return
+	}
+	size := int64(msg.BodySize)
+	if request {
+		s.edge.streamRequestPayloadSizes.IncBucket(size)
+		s.call.streamSizes.request.Add(size)
+	} else {
+		s.edge.streamResponsePayloadSizes.IncBucket(size)
+		s.call.streamSizes.response.Add(size)
+	}
+}
+
 func (s *streamWrapper) SendHeaders(headers transport.Headers) error {
 	return transport.SendStreamHeaders(s.StreamCloser, headers)
 }
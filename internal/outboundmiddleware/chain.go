@@ -205,6 +205,9 @@ func StreamChain(mw ...middleware.StreamOutbound) middleware.StreamOutbound {
 			unchained = append(unchained, c...)
 			continue
 		}
+		if mm, ok := m.(middleware.StreamMessageOutbound); ok {
+			m = streamMessageOutbound{StreamOutbound: m, messages: mm}
+		}
 		unchained = append(unchained, m)
 	}
 
@@ -325,3 +325,99 @@ func TestStreamChainExecFuncs(t *testing.T) {
 	assert.Nil(t, mw.Stop())
 	assert.Len(t, mw.Transports(), 0)
 }
+
+// messageOutboundMiddleware counts the messages of the streams it calls and
+// appends its name to their bodies.
+type messageOutboundMiddleware struct {
+	name     string
+	sent     int
+	received int
+}
+
+func (m *messageOutboundMiddleware) CallStream(ctx context.Context, req *transport.StreamRequest, out transport.StreamOutbound) (*transport.ClientStream, error) {
+	return out.CallStream(ctx, req)
+}
+
+func (m *messageOutboundMiddleware) SendMessage(ctx context.Context, msg *transport.StreamMessage, next transport.Stream) error {
+	m.sent++
+	msg, err := appendToMessage(msg, m.name)
+	if err != nil {
+		return err
+	}
+	return next.SendMessage(ctx, msg)
+}
+
+func (m *messageOutboundMiddleware) ReceiveMessage(ctx context.Context, next transport.Stream) (*transport.StreamMessage, error) {
+	msg, err := next.ReceiveMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.received++
+	return appendToMessage(msg, m.name)
+}
+
+func appendToMessage(msg *transport.StreamMessage, s string) (*transport.StreamMessage, error) {
+	body, err := ioutil.ReadAll(msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	body = append(body, "+"+s...)
+	return &transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewReader(body)), BodySize: len(body)}, nil
+}
+
+func readMessage(t *testing.T, msg *transport.StreamMessage) string {
+	body, err := ioutil.ReadAll(msg.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func newMessage(s string) *transport.StreamMessage {
+	return &transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewBufferString(s)), BodySize: len(s)}
+}
+
+func TestStreamChainMessages(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	outer := &messageOutboundMiddleware{name: "outer"}
+	inner := &messageOutboundMiddleware{name: "inner"}
+
+	req := &transport.StreamRequest{Meta: &transport.RequestMeta{}}
+	client, server, finish, err := transporttest.MessagePipe(ctx, req)
+	require.NoError(t, err)
+	o := transporttest.NewMockStreamOutbound(mockCtrl)
+	o.EXPECT().CallStream(ctx, req).Return(client, nil)
+
+	var received string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		msg, err := server.ReceiveMessage(ctx)
+		if err == nil {
+			received = readMessage(t, msg)
+			err = server.SendMessage(ctx, newMessage("world"))
+		}
+		finish(err)
+	}()
+
+	stream, err := middleware.ApplyStreamOutbound(o, StreamChain(outer, inner)).CallStream(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMessage(ctx, newMessage("hello")))
+	msg, err := stream.ReceiveMessage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "world+inner+outer", readMessage(t, msg), "received messages must reach the outer middleware last")
+	<-done
+
+	assert.Equal(t, "hello+outer+inner", received, "sent messages must reach the outer middleware first")
+	assert.Equal(t, 1, outer.sent)
+	assert.Equal(t, 1, outer.received)
+	assert.Equal(t, 1, inner.sent)
+	assert.Equal(t, 1, inner.received)
+}
+
+func TestStreamChainWithoutMessageMiddleware(t *testing.T) {
+	mw := &countOutboundMiddleware{}
+	assert.Equal(t, mw, StreamChain(mw), "expected middleware without message hooks to be left as is")
+}
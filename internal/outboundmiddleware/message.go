@@ -0,0 +1,63 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package outboundmiddleware
+
+import (
+	"context"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+)
+
+// streamMessageOutbound installs the message hooks of a StreamOutbound
+// middleware on the stream it returns to the previous caller, so that the
+// messages meet the middleware in the same place as the stream.
+type streamMessageOutbound struct {
+	middleware.StreamOutbound
+
+	messages middleware.StreamMessageOutbound
+}
+
+func (m streamMessageOutbound) CallStream(ctx context.Context, request *transport.StreamRequest, out transport.StreamOutbound) (*transport.ClientStream, error) {
+	stream, err := m.StreamOutbound.CallStream(ctx, request, out)
+	if err != nil {
+		return nil, err
+	}
+	return transport.NewClientStream(&messageClientStream{ClientStream: stream, messages: m.messages})
+}
+
+// messageClientStream passes the messages of a client stream through the
+// hooks of a middleware.
+type messageClientStream struct {
+	*transport.ClientStream
+
+	messages middleware.StreamMessageOutbound
+}
+
+var _ transport.StreamHeadersReader = (*messageClientStream)(nil)
+
+func (s *messageClientStream) SendMessage(ctx context.Context, msg *transport.StreamMessage) error {
+	return s.messages.SendMessage(ctx, msg, s.ClientStream)
+}
+
+func (s *messageClientStream) ReceiveMessage(ctx context.Context) (*transport.StreamMessage, error) {
+	return s.messages.ReceiveMessage(ctx, s.ClientStream)
+}
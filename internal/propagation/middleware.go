@@ -0,0 +1,208 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package propagation
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+)
+
+// Config configures the header propagation middleware.
+type Config struct {
+	// Headers are the names of the application headers of inbound requests
+	// that are propagated to outbound calls.
+	Headers []string
+
+	// MaxValueSize is the maximum size in bytes of propagated values.
+	// Longer values are truncated. Zero disables the maximum.
+	MaxValueSize int
+
+	// Rename maps the names of propagated headers to the names of the
+	// headers of outbound calls that they are propagated into.
+	Rename map[string]string
+}
+
+var (
+	_ middleware.UnaryInbound   = (*Middleware)(nil)
+	_ middleware.OnewayInbound  = (*Middleware)(nil)
+	_ middleware.StreamInbound  = (*Middleware)(nil)
+	_ middleware.UnaryOutbound  = (*Middleware)(nil)
+	_ middleware.OnewayOutbound = (*Middleware)(nil)
+	_ middleware.StreamOutbound = (*Middleware)(nil)
+)
+
+// Middleware is an inbound and outbound middleware that propagates
+// application headers from inbound requests into the outbound calls made
+// while handling them.
+//
+// As inbound middleware, it captures the allowed headers of requests into
+// their context. As outbound middleware, it adds the captured headers of the
+// context of calls to their request, unless the request already has them.
+type Middleware struct {
+	// names maps the canonical names of propagated headers to the names
+	// they are propagated into.
+	names        map[string]string
+	maxValueSize int
+}
+
+// New returns a header propagation middleware.
+func New(cfg Config) *Middleware {
+	names := make(map[string]string, len(cfg.Headers))
+	for _, h := range cfg.Headers {
+		names[transport.CanonicalizeHeaderKey(h)] = h
+	}
+	for from, to := range cfg.Rename {
+		from = transport.CanonicalizeHeaderKey(from)
+		if _, ok := names[from]; ok {
+			names[from] = to
+		}
+	}
+	return &Middleware{
+		names:        names,
+		maxValueSize: cfg.MaxValueSize,
+	}
+}
+
+type headersKey struct{}
+
+// headers are the propagated headers of a context, by the names they are
+// propagated into.
+type headers map[string]string
+
+func headersFromContext(ctx context.Context) headers {
+	h, _ := ctx.Value(headersKey{}).(headers)
+	return h
+}
+
+// Handle implements middleware.UnaryInbound.
+func (m *Middleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	return h.Handle(m.capture(ctx, req.Headers), req, resw)
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	return h.HandleOneway(m.capture(ctx, req.Headers), req)
+}
+
+// HandleStream implements middleware.StreamInbound.
+func (m *Middleware) HandleStream(s *transport.ServerStream, h transport.StreamHandler) error {
+	ctx := m.capture(s.Context(), s.Request().Meta.Headers)
+	if ctx == s.Context() {
+		return h.HandleStream(s)
+	}
+	stream, err := transport.NewServerStream(&propagatingStream{ServerStream: s, ctx: ctx})
+	if err != nil {
+		return err
+	}
+	return h.HandleStream(stream)
+}
+
+// capture returns a context with the propagated headers of the request.
+func (m *Middleware) capture(ctx context.Context, reqHeaders transport.Headers) context.Context {
+	var captured headers
+	for name, to := range m.names {
+		v, ok := reqHeaders.Get(name)
+		if !ok {
+			continue
+		}
+		if captured == nil {
+			captured = make(headers, len(m.names))
+		}
+		captured[to] = m.truncate(v)
+	}
+	if captured == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, headersKey{}, captured)
+}
+
+// truncate truncates the value to the maximum size, without splitting UTF-8
+// encoded characters.
+func (m *Middleware) truncate(v string) string {
+	if m.maxValueSize <= 0 || len(v) <= m.maxValueSize {
+		return v
+	}
+	n := m.maxValueSize
+	for n > 0 && !utf8.RuneStart(v[n]) {
+		n--
+	}
+	return v[:n]
+}
+
+// Call implements middleware.UnaryOutbound.
+func (m *Middleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	if h := headersFromContext(ctx); h != nil {
+		r := *req
+		r.Headers = h.inject(req.Headers)
+		req = &r
+	}
+	return out.Call(ctx, req)
+}
+
+// CallOneway implements middleware.OnewayOutbound.
+func (m *Middleware) CallOneway(ctx context.Context, req *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	if h := headersFromContext(ctx); h != nil {
+		r := *req
+		r.Headers = h.inject(req.Headers)
+		req = &r
+	}
+	return out.CallOneway(ctx, req)
+}
+
+// CallStream implements middleware.StreamOutbound.
+func (m *Middleware) CallStream(ctx context.Context, req *transport.StreamRequest, out transport.StreamOutbound) (*transport.ClientStream, error) {
+	if h := headersFromContext(ctx); h != nil {
+		meta := *req.Meta
+		meta.Headers = h.inject(req.Meta.Headers)
+		req = &transport.StreamRequest{Meta: &meta}
+	}
+	return out.CallStream(ctx, req)
+}
+
+// inject returns a copy of the headers of a request with the propagated
+// headers that the request does not set already.
+func (h headers) inject(reqHeaders transport.Headers) transport.Headers {
+	injected := transport.NewHeadersWithCapacity(reqHeaders.Len() + len(h))
+	for k, v := range reqHeaders.OriginalItems() {
+		injected = injected.With(k, v)
+	}
+	for k, v := range h {
+		if _, ok := injected.Get(k); !ok {
+			injected = injected.With(k, v)
+		}
+	}
+	return injected
+}
+
+// propagatingStream is a server stream whose context carries the propagated
+// headers of its request.
+type propagatingStream struct {
+	*transport.ServerStream
+
+	ctx context.Context
+}
+
+func (s *propagatingStream) Context() context.Context {
+	return s.ctx
+}
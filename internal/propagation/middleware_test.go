@@ -0,0 +1,196 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package propagation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+)
+
+// contextHandler records the contexts of the requests it handles.
+type contextHandler struct {
+	ctx context.Context
+}
+
+func (h *contextHandler) Handle(ctx context.Context, _ *transport.Request, _ transport.ResponseWriter) error {
+	h.ctx = ctx
+	return nil
+}
+
+func (h *contextHandler) HandleOneway(ctx context.Context, _ *transport.Request) error {
+	h.ctx = ctx
+	return nil
+}
+
+func (h *contextHandler) HandleStream(s *transport.ServerStream) error {
+	h.ctx = s.Context()
+	return nil
+}
+
+// fakeOutbound records the headers of the requests it receives.
+type fakeOutbound struct {
+	transport.Outbound
+
+	headers transport.Headers
+}
+
+func (o *fakeOutbound) Call(_ context.Context, req *transport.Request) (*transport.Response, error) {
+	o.headers = req.Headers
+	return &transport.Response{}, nil
+}
+
+func (o *fakeOutbound) CallOneway(_ context.Context, req *transport.Request) (transport.Ack, error) {
+	o.headers = req.Headers
+	return nil, nil
+}
+
+func (o *fakeOutbound) CallStream(_ context.Context, req *transport.StreamRequest) (*transport.ClientStream, error) {
+	o.headers = req.Meta.Headers
+	return nil, nil
+}
+
+type fakeStream struct {
+	ctx     context.Context
+	request *transport.StreamRequest
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeStream) Request() *transport.StreamRequest {
+	return s.request
+}
+
+func (s *fakeStream) SendMessage(context.Context, *transport.StreamMessage) error {
+	return nil
+}
+
+func (s *fakeStream) ReceiveMessage(context.Context) (*transport.StreamMessage, error) {
+	return nil, nil
+}
+
+func TestMiddleware(t *testing.T) {
+	m := New(Config{
+		Headers:      []string{"X-Request-Id", "x-tenant", "x-trace"},
+		MaxValueSize: 8,
+		Rename:       map[string]string{"x-trace": "x-parent-trace", "x-unknown": "x-other"},
+	})
+	inbound := transport.NewHeaders().
+		With("x-request-id", "abc").
+		With("X-Tenant", "acme").
+		With("x-trace", "trace-id-that-is-long").
+		With("x-secret", "hunter2").
+		With("x-unknown", "nope")
+	want := map[string]string{
+		"x-request-id":   "abc",
+		"x-tenant":       "explicit",
+		"x-parent-trace": "trace-id",
+		"x-caller":       "set",
+	}
+
+	// outbound headers contain a header that is set explicitly, which the
+	// middleware must not replace.
+	outbound := func() transport.Headers {
+		return transport.NewHeaders().With("x-tenant", "explicit").With("x-caller", "set")
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		var h contextHandler
+		req := &transport.Request{Procedure: "get", Headers: inbound}
+		require.NoError(t, m.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, &h))
+
+		var out fakeOutbound
+		outReq := &transport.Request{Procedure: "list", Headers: outbound()}
+		_, err := m.Call(h.ctx, outReq, &out)
+		require.NoError(t, err)
+		assert.Equal(t, want, out.headers.Items())
+		assert.Equal(t, map[string]string{"x-tenant": "explicit", "x-caller": "set"}, outReq.Headers.Items(),
+			"request of the caller must be left alone")
+	})
+
+	t.Run("oneway", func(t *testing.T) {
+		var h contextHandler
+		require.NoError(t, m.HandleOneway(context.Background(), &transport.Request{Headers: inbound}, &h))
+
+		var out fakeOutbound
+		_, err := m.CallOneway(h.ctx, &transport.Request{Headers: outbound()}, &out)
+		require.NoError(t, err)
+		assert.Equal(t, want, out.headers.Items())
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		var h contextHandler
+		stream, err := transport.NewServerStream(&fakeStream{
+			ctx:     context.Background(),
+			request: &transport.StreamRequest{Meta: &transport.RequestMeta{Headers: inbound}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, m.HandleStream(stream, &h))
+
+		var out fakeOutbound
+		_, err = m.CallStream(h.ctx, &transport.StreamRequest{Meta: &transport.RequestMeta{Headers: outbound()}}, &out)
+		require.NoError(t, err)
+		assert.Equal(t, want, out.headers.Items())
+	})
+
+	t.Run("no propagated headers", func(t *testing.T) {
+		ctx := context.Background()
+		var h contextHandler
+		req := &transport.Request{Headers: transport.NewHeaders().With("x-secret", "hunter2")}
+		require.NoError(t, m.Handle(ctx, req, &transporttest.FakeResponseWriter{}, &h))
+		assert.Equal(t, ctx, h.ctx)
+
+		var out fakeOutbound
+		outReq := &transport.Request{Headers: outbound()}
+		_, err := m.Call(h.ctx, outReq, &out)
+		require.NoError(t, err)
+		assert.Equal(t, outReq.Headers, out.headers)
+	})
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		msg  string
+		max  int
+		give string
+		want string
+	}{
+		{msg: "no maximum", give: "long value", want: "long value"},
+		{msg: "short value", max: 16, give: "value", want: "value"},
+		{msg: "exact size", max: 5, give: "value", want: "value"},
+		{msg: "long value", max: 4, give: "value", want: "valu"},
+		{msg: "multi-byte character", max: 4, give: "abcé", want: "abc"},
+		{msg: "after multi-byte character", max: 5, give: "abcéd", want: "abcé"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			m := New(Config{MaxValueSize: tt.max})
+			assert.Equal(t, tt.want, m.truncate(tt.give))
+		})
+	}
+}
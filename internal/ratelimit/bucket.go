@@ -0,0 +1,91 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"go.uber.org/net/metrics"
+)
+
+// Limit is the rate of a token bucket.
+type Limit struct {
+	// Rate is the number of requests per second that the bucket admits in
+	// the long run. A non-positive rate disables the limit.
+	Rate float64
+
+	// Burst is the capacity of the bucket, the number of requests that it
+	// admits at once after a quiet period.
+	//
+	// Defaults to the rate, rounded up, and at least 1.
+	Burst int
+}
+
+func (l Limit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.Rate))
+}
+
+// bucket is a token bucket, which starts full.
+//
+// The limit is passed to take, rather than stored in the bucket, so that
+// limits changed at runtime apply to the buckets that already exist.
+type bucket struct {
+	allowed *metrics.Counter
+	limited *metrics.Counter
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	fresh  bool
+}
+
+func newBucket(allowed, limited *metrics.Counter) *bucket {
+	return &bucket{allowed: allowed, limited: limited, fresh: true}
+}
+
+// take takes a token from the bucket, returning the delay until a token is
+// available if the bucket is empty.
+func (b *bucket) take(now time.Time, limit Limit) (wait time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	burst := limit.burst()
+	if b.fresh {
+		b.tokens = burst
+		b.fresh = false
+	} else if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * limit.Rate
+	}
+	b.tokens = math.Min(b.tokens, burst)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait = time.Duration(math.Ceil((1 - b.tokens) / limit.Rate * float64(time.Second)))
+	return wait, false
+}
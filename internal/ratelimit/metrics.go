@@ -0,0 +1,70 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	_procedureTag = "procedure"
+	_callerTag    = "caller"
+	_keyTag       = "key"
+)
+
+type limitMetrics struct {
+	allowed *metrics.CounterVector
+	limited *metrics.CounterVector
+}
+
+func newLimitMetrics(meter *metrics.Scope, logger *zap.Logger) *limitMetrics {
+	tags := []string{_procedureTag, _callerTag, _keyTag}
+
+	allowed, err := meter.CounterVector(metrics.Spec{
+		Name:    "ratelimit_allowed",
+		Help:    "Total number of requests allowed by the rate limit, by bucket.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create rate limit allowed counter", zap.Error(err))
+	}
+	limited, err := meter.CounterVector(metrics.Spec{
+		Name:    "ratelimit_limited",
+		Help:    "Total number of requests rejected by the rate limit, by bucket.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create rate limit limited counter", zap.Error(err))
+	}
+
+	return &limitMetrics{
+		allowed: allowed,
+		limited: limited,
+	}
+}
+
+// counters returns the counters of a bucket. Parts of the key that the
+// bucket does not have are tagged with the default tag value.
+func (m *limitMetrics) counters(k bucketKey) (allowed, limited *metrics.Counter) {
+	tags := []string{_procedureTag, k.procedure, _callerTag, k.caller, _keyTag, k.key}
+	return m.allowed.MustGet(tags...), m.limited.MustGet(tags...)
+}
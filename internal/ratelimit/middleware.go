@@ -0,0 +1,277 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"go.uber.org/atomic"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/pkg/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// Override specifies the limit of the requests for a procedure, from a
+// caller, or for a procedure from a caller if both are set.
+type Override struct {
+	Procedure string
+	Caller    string
+	Limit     Limit
+}
+
+// Config configures the rate limiting middleware.
+type Config struct {
+	// Default is the limit of the requests that no override matches.
+	// Requests are not limited without a limit.
+	Default *Limit
+
+	// Overrides specify the limits of procedures and callers.
+	Overrides []Override
+
+	// KeyByProcedure and KeyByCaller give each procedure and each caller
+	// its own bucket, instead of sharing the bucket of their limit.
+	KeyByProcedure bool
+	KeyByCaller    bool
+
+	// Key returns an additional key of the bucket of a request.
+	Key func(*transport.Request) string
+
+	Meter  *metrics.Scope
+	Logger *zap.Logger
+	Clock  clock.Clock
+}
+
+// scope identifies the kind of limit that applies to a request, so that the
+// buckets of different limits are never shared.
+type scope uint8
+
+const (
+	defaultScope scope = iota
+	callerScope
+	procedureScope
+	procedureCallerScope
+)
+
+type procedureCaller struct {
+	procedure string
+	caller    string
+}
+
+// rules are the limits of the middleware, which are replaced as a whole
+// when they change at runtime.
+type rules struct {
+	defaultLimit     *Limit
+	procedures       map[string]Limit
+	callers          map[string]Limit
+	procedureCallers map[procedureCaller]Limit
+}
+
+func newRules(def *Limit, overrides []Override) *rules {
+	r := &rules{
+		procedures:       make(map[string]Limit),
+		callers:          make(map[string]Limit),
+		procedureCallers: make(map[procedureCaller]Limit),
+	}
+	if def != nil {
+		l := *def
+		r.defaultLimit = &l
+	}
+	for _, o := range overrides {
+		switch {
+		case o.Procedure != "" && o.Caller != "":
+			r.procedureCallers[procedureCaller{o.Procedure, o.Caller}] = o.Limit
+		case o.Procedure != "":
+			r.procedures[o.Procedure] = o.Limit
+		case o.Caller != "":
+			r.callers[o.Caller] = o.Limit
+		}
+	}
+	return r
+}
+
+// limit returns the most specific limit of a request: that of its procedure
+// and caller, of its procedure, of its caller, or the default.
+func (r *rules) limit(procedure, caller string) (Limit, scope, bool) {
+	if l, ok := r.procedureCallers[procedureCaller{procedure, caller}]; ok {
+		return l, procedureCallerScope, true
+	}
+	if l, ok := r.procedures[procedure]; ok {
+		return l, procedureScope, true
+	}
+	if l, ok := r.callers[caller]; ok {
+		return l, callerScope, true
+	}
+	if r.defaultLimit != nil {
+		return *r.defaultLimit, defaultScope, true
+	}
+	return Limit{}, defaultScope, false
+}
+
+type bucketKey struct {
+	scope     scope
+	procedure string
+	caller    string
+	key       string
+}
+
+var (
+	_ middleware.UnaryInbound  = (*Middleware)(nil)
+	_ middleware.OnewayInbound = (*Middleware)(nil)
+	_ middleware.StreamInbound = (*Middleware)(nil)
+)
+
+// Middleware is an inbound middleware that limits the rate of requests with
+// token buckets.
+//
+// The middleware keeps a bucket for every key it sees, so the keys must
+// have a bounded number of values.
+type Middleware struct {
+	keyByProcedure bool
+	keyByCaller    bool
+	key            func(*transport.Request) string
+	clock          clock.Clock
+	metrics        *limitMetrics
+
+	rules atomic.Value // *rules
+
+	lock    sync.RWMutex
+	buckets map[bucketKey]*bucket
+}
+
+// NewMiddleware returns an inbound middleware that limits the rate of
+// requests.
+func NewMiddleware(cfg Config) *Middleware {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	clk := cfg.Clock
+	if clk == nil {
+		clk = clock.System
+	}
+
+	m := &Middleware{
+		keyByProcedure: cfg.KeyByProcedure,
+		keyByCaller:    cfg.KeyByCaller,
+		key:            cfg.Key,
+		clock:          clk,
+		metrics:        newLimitMetrics(cfg.Meter, logger),
+		buckets:        make(map[bucketKey]*bucket),
+	}
+	m.rules.Store(newRules(cfg.Default, cfg.Overrides))
+	return m
+}
+
+// SetLimits replaces the limits of the middleware.
+//
+// Buckets keep their tokens across changes, so a request that was limited
+// by a limit is limited by the same bucket after its rate changes.
+func (m *Middleware) SetLimits(def *Limit, overrides []Override) {
+	m.rules.Store(newRules(def, overrides))
+}
+
+// Handle implements middleware.UnaryInbound.
+func (m *Middleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	if err := m.admit(req); err != nil {
+		return err
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	if err := m.admit(req); err != nil {
+		return err
+	}
+	return h.HandleOneway(ctx, req)
+}
+
+// HandleStream implements middleware.StreamInbound, limiting the rate at
+// which streams open.
+func (m *Middleware) HandleStream(s *transport.ServerStream, h transport.StreamHandler) error {
+	if err := m.admit(s.Request().Meta.ToRequest()); err != nil {
+		return err
+	}
+	return h.HandleStream(s)
+}
+
+// admit takes a token from the bucket of the request, returning a
+// ResourceExhausted error if the bucket is empty.
+func (m *Middleware) admit(req *transport.Request) error {
+	limit, sc, ok := m.rules.Load().(*rules).limit(req.Procedure, req.Caller)
+	if !ok || limit.Rate <= 0 {
+		return nil
+	}
+
+	k := bucketKey{scope: sc}
+	if m.keyByProcedure || sc == procedureScope || sc == procedureCallerScope {
+		k.procedure = req.Procedure
+	}
+	if m.keyByCaller || sc == callerScope || sc == procedureCallerScope {
+		k.caller = req.Caller
+	}
+	if m.key != nil {
+		k.key = m.key(req)
+	}
+
+	b := m.bucket(k)
+	wait, ok := b.take(m.clock.Now(), limit)
+	if ok {
+		b.allowed.Inc()
+		return nil
+	}
+	b.limited.Inc()
+	return newRateLimitError(req, wait)
+}
+
+func (m *Middleware) bucket(k bucketKey) *bucket {
+	m.lock.RLock()
+	b, ok := m.buckets[k]
+	m.lock.RUnlock()
+	if ok {
+		return b
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if b, ok := m.buckets[k]; ok {
+		return b
+	}
+	allowed, limited := m.metrics.counters(k)
+	b = newBucket(allowed, limited)
+	m.buckets[k] = b
+	return b
+}
+
+func newRateLimitError(req *transport.Request, wait time.Duration) error {
+	err := yarpcerrors.ResourceExhaustedErrorf(
+		"rate limit exceeded for procedure %q of service %q from caller %q, retry after %v",
+		req.Procedure, req.Service, req.Caller, wait)
+	return yarpcerrors.WithDetails(err, &rpc.RetryInfo{RetryDelay: types.DurationProto(wait)})
+}
@@ -0,0 +1,317 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/pkg/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// fakeClock is a clock that only advances when told to.
+type fakeClock struct {
+	clock.Clock
+
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type nopHandler struct{}
+
+func (nopHandler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	return nil
+}
+
+func (nopHandler) HandleOneway(context.Context, *transport.Request) error {
+	return nil
+}
+
+func (nopHandler) HandleStream(*transport.ServerStream) error {
+	return nil
+}
+
+func call(m *Middleware, procedure, caller string) error {
+	req := &transport.Request{Service: "service", Procedure: procedure, Caller: caller}
+	return m.Handle(context.Background(), req, new(transporttest.FakeResponseWriter), nopHandler{})
+}
+
+// admitted returns the number of n requests that are allowed.
+func admitted(m *Middleware, n int, procedure, caller string) int {
+	var allowed int
+	for i := 0; i < n; i++ {
+		if call(m, procedure, caller) == nil {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestBurst(t *testing.T) {
+	clk := newFakeClock()
+	m := NewMiddleware(Config{
+		Default: &Limit{Rate: 1, Burst: 3},
+		Clock:   clk,
+	})
+
+	assert.Equal(t, 3, admitted(m, 3, "procedure", "caller"), "expected a full bucket to allow a burst")
+
+	err := call(m, "procedure", "caller")
+	require.Error(t, err)
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+	assert.Contains(t, err.Error(), `rate limit exceeded for procedure "procedure" of service "service" from caller "caller"`)
+
+	details, derr := yarpcerrors.Details(err)
+	require.NoError(t, derr)
+	require.Len(t, details, 1)
+	info, ok := details[0].(*rpc.RetryInfo)
+	require.True(t, ok, "expected retry info, got %T", details[0])
+	wait, derr := types.DurationFromProto(info.RetryDelay)
+	require.NoError(t, derr)
+	assert.Equal(t, time.Second, wait)
+
+	// The bucket never holds more than its burst.
+	clk.Add(time.Hour)
+	assert.Equal(t, 3, admitted(m, 5, "procedure", "caller"))
+}
+
+func TestDefaultBurst(t *testing.T) {
+	m := NewMiddleware(Config{
+		Default: &Limit{Rate: 2.5},
+		Clock:   newFakeClock(),
+	})
+	assert.Equal(t, 3, admitted(m, 5, "procedure", "caller"), "expected the burst to default to the rate, rounded up")
+}
+
+func TestSteadyRate(t *testing.T) {
+	clk := newFakeClock()
+	m := NewMiddleware(Config{
+		Default: &Limit{Rate: 10, Burst: 1},
+		Clock:   clk,
+	})
+
+	allowed := admitted(m, 3, "procedure", "caller")
+	for i := 0; i < 10; i++ {
+		clk.Add(100 * time.Millisecond)
+		assert.Equal(t, 1, admitted(m, 3, "procedure", "caller"), "expected one request every 100ms")
+	}
+	// Tokens accumulate over shorter intervals too.
+	for i := 0; i < 20; i++ {
+		clk.Add(50 * time.Millisecond)
+		allowed += admitted(m, 3, "procedure", "caller")
+	}
+	assert.Equal(t, 11, allowed, "expected one second at ten requests a second, plus the initial token")
+}
+
+func TestOverridePrecedence(t *testing.T) {
+	m := NewMiddleware(Config{
+		Default: &Limit{Rate: 1, Burst: 1},
+		Overrides: []Override{
+			{Caller: "batch", Limit: Limit{Rate: 1, Burst: 2}},
+			{Procedure: "get", Limit: Limit{Rate: 1, Burst: 3}},
+			{Procedure: "get", Caller: "batch", Limit: Limit{Rate: 1, Burst: 4}},
+			{Procedure: "health", Limit: Limit{Rate: 0}},
+		},
+		Clock: newFakeClock(),
+	})
+
+	tests := []struct {
+		desc      string
+		procedure string
+		caller    string
+		want      int
+	}{
+		{desc: "procedure and caller", procedure: "get", caller: "batch", want: 4},
+		{desc: "procedure", procedure: "get", caller: "web", want: 3},
+		{desc: "caller", procedure: "set", caller: "batch", want: 2},
+		{desc: "default", procedure: "set", caller: "web", want: 1},
+		{desc: "unlimited", procedure: "health", caller: "web", want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			assert.Equal(t, tt.want, admitted(m, 10, tt.procedure, tt.caller))
+		})
+	}
+}
+
+func TestNoLimit(t *testing.T) {
+	m := NewMiddleware(Config{
+		Overrides: []Override{{Procedure: "get", Limit: Limit{Rate: 1}}},
+		Clock:     newFakeClock(),
+	})
+	assert.Equal(t, 1, admitted(m, 10, "get", "caller"))
+	assert.Equal(t, 10, admitted(m, 10, "set", "caller"), "expected requests without a limit to be allowed")
+}
+
+func TestKeys(t *testing.T) {
+	limit := &Limit{Rate: 1, Burst: 1}
+
+	t.Run("shared", func(t *testing.T) {
+		m := NewMiddleware(Config{Default: limit, Clock: newFakeClock()})
+		assert.Equal(t, 1, admitted(m, 1, "get", "a"))
+		assert.Equal(t, 0, admitted(m, 1, "set", "b"), "expected every request to share the default bucket")
+	})
+
+	t.Run("by procedure", func(t *testing.T) {
+		m := NewMiddleware(Config{Default: limit, KeyByProcedure: true, Clock: newFakeClock()})
+		assert.Equal(t, 1, admitted(m, 1, "get", "a"))
+		assert.Equal(t, 0, admitted(m, 1, "get", "b"))
+		assert.Equal(t, 1, admitted(m, 1, "set", "a"))
+	})
+
+	t.Run("by caller", func(t *testing.T) {
+		m := NewMiddleware(Config{Default: limit, KeyByCaller: true, Clock: newFakeClock()})
+		assert.Equal(t, 1, admitted(m, 1, "get", "a"))
+		assert.Equal(t, 0, admitted(m, 1, "set", "a"))
+		assert.Equal(t, 1, admitted(m, 1, "get", "b"))
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		m := NewMiddleware(Config{
+			Default: limit,
+			Key: func(req *transport.Request) string {
+				tenant, _ := req.Headers.Get("tenant")
+				return tenant
+			},
+			Clock: newFakeClock(),
+		})
+		handle := func(tenant string) error {
+			req := &transport.Request{Procedure: "get", Headers: transport.NewHeaders().With("tenant", tenant)}
+			return m.Handle(context.Background(), req, new(transporttest.FakeResponseWriter), nopHandler{})
+		}
+		assert.NoError(t, handle("a"))
+		assert.Error(t, handle("a"))
+		assert.NoError(t, handle("b"))
+	})
+
+	t.Run("overrides have their own buckets", func(t *testing.T) {
+		m := NewMiddleware(Config{
+			Default: limit,
+			Overrides: []Override{
+				{Procedure: "get", Limit: *limit},
+				{Procedure: "set", Limit: *limit},
+			},
+			Clock: newFakeClock(),
+		})
+		assert.Equal(t, 1, admitted(m, 2, "get", "a"))
+		assert.Equal(t, 1, admitted(m, 2, "set", "a"))
+		assert.Equal(t, 1, admitted(m, 2, "list", "a"))
+	})
+}
+
+func TestSetLimits(t *testing.T) {
+	clk := newFakeClock()
+	m := NewMiddleware(Config{Default: &Limit{Rate: 1, Burst: 1}, Clock: clk})
+	assert.Equal(t, 1, admitted(m, 5, "get", "a"))
+
+	m.SetLimits(&Limit{Rate: 100, Burst: 10}, []Override{{Procedure: "set", Limit: Limit{Rate: 1, Burst: 1}}})
+	clk.Add(time.Second)
+	assert.Equal(t, 10, admitted(m, 20, "get", "a"), "expected the new default to apply")
+	assert.Equal(t, 1, admitted(m, 5, "set", "a"), "expected the new override to apply")
+
+	m.SetLimits(nil, nil)
+	assert.Equal(t, 5, admitted(m, 5, "set", "a"), "expected removing the limits to disable them")
+}
+
+func TestOnewayAndStream(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	m := NewMiddleware(Config{Default: &Limit{Rate: 1, Burst: 1}, Clock: newFakeClock()})
+
+	req := &transport.Request{Procedure: "get", Caller: "a"}
+	assert.NoError(t, m.HandleOneway(context.Background(), req, nopHandler{}))
+	assert.Error(t, m.HandleOneway(context.Background(), req, nopHandler{}))
+
+	stream := transporttest.NewMockStream(mockCtrl)
+	stream.EXPECT().Request().Return(&transport.StreamRequest{
+		Meta: &transport.RequestMeta{Procedure: "get", Caller: "a"},
+	}).AnyTimes()
+	s, err := transport.NewServerStream(stream)
+	require.NoError(t, err)
+	err = m.HandleStream(s, nopHandler{})
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+}
+
+func TestMetrics(t *testing.T) {
+	root := metrics.New()
+	m := NewMiddleware(Config{
+		Default:        &Limit{Rate: 1, Burst: 2},
+		KeyByProcedure: true,
+		Meter:          root.Scope(),
+		Clock:          newFakeClock(),
+	})
+	admitted(m, 3, "get", "a")
+	admitted(m, 1, "set", "a")
+
+	counts := make(map[string]int64)
+	for _, c := range root.Snapshot().Counters {
+		counts[c.Name+":"+c.Tags[_procedureTag]+":"+c.Tags[_callerTag]] = c.Value
+	}
+	assert.Equal(t, map[string]int64{
+		"ratelimit_allowed:get:default": 2,
+		"ratelimit_limited:get:default": 1,
+		"ratelimit_allowed:set:default": 1,
+		"ratelimit_limited:set:default": 0,
+	}, counts)
+}
+
+func TestAdmitAllocations(t *testing.T) {
+	m := NewMiddleware(Config{
+		Default:   &Limit{Rate: 1e9, Burst: 1e9},
+		Overrides: []Override{{Procedure: "get", Caller: "a", Limit: Limit{Rate: 1e9, Burst: 1e9}}},
+		Meter:     metrics.New().Scope(),
+	})
+	req := &transport.Request{Service: "service", Procedure: "get", Caller: "a"}
+	require.NoError(t, m.admit(req))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = m.admit(req)
+	})
+	assert.Zero(t, allocs, "expected allowed requests not to allocate")
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package recordwriter provides a transport.ResponseWriter that records the
+// response written through it, for middleware that inspect or replay
+// responses.
+package recordwriter
+
+import (
+	"bytes"
+
+	"go.uber.org/yarpc/api/transport"
+)
+
+// Writer forwards a response to an underlying ResponseWriter while recording
+// its headers, body and application error.
+type Writer struct {
+	transport.ResponseWriter
+
+	// maxBodyBytes is the number of bytes of the body to record, or a
+	// negative number to record all of it.
+	maxBodyBytes int
+
+	headers              transport.Headers
+	body                 bytes.Buffer
+	bodySize             int
+	applicationError     bool
+	applicationErrorMeta *transport.ApplicationErrorMeta
+}
+
+var _ transport.ApplicationErrorMetaSetter = (*Writer)(nil)
+
+// New returns a Writer that records the whole response written to w.
+func New(w transport.ResponseWriter) *Writer {
+	return NewLimited(w, -1)
+}
+
+// NewLimited returns a Writer that records at most maxBodyBytes of the body
+// of the response written to w, and the whole of the rest of the response.
+func NewLimited(w transport.ResponseWriter, maxBodyBytes int) *Writer {
+	return &Writer{ResponseWriter: w, maxBodyBytes: maxBodyBytes}
+}
+
+// AddHeaders records and forwards response headers.
+func (w *Writer) AddHeaders(h transport.Headers) {
+	for k, v := range h.OriginalItems() {
+		w.headers = w.headers.With(k, v)
+	}
+	w.ResponseWriter.AddHeaders(h)
+}
+
+// SetApplicationError records and forwards that the response is an
+// application error.
+func (w *Writer) SetApplicationError() {
+	w.applicationError = true
+	w.ResponseWriter.SetApplicationError()
+}
+
+// SetApplicationErrorMeta records the metadata of an application error, and
+// forwards it if the underlying ResponseWriter accepts it.
+func (w *Writer) SetApplicationErrorMeta(meta *transport.ApplicationErrorMeta) {
+	w.applicationErrorMeta = meta
+	if setter, ok := w.ResponseWriter.(transport.ApplicationErrorMetaSetter); ok {
+		setter.SetApplicationErrorMeta(meta)
+	}
+}
+
+// Write records and forwards a part of the response body.
+func (w *Writer) Write(p []byte) (int, error) {
+	record := p
+	if w.maxBodyBytes >= 0 {
+		room := w.maxBodyBytes - w.body.Len()
+		if room < 0 {
+			room = 0
+		}
+		if room < len(record) {
+			record = record[:room]
+		}
+	}
+	w.body.Write(record)
+	w.bodySize += len(p)
+	return w.ResponseWriter.Write(p)
+}
+
+// Headers returns the recorded response headers.
+func (w *Writer) Headers() transport.Headers {
+	return w.headers
+}
+
+// Body returns the recorded response body, which is truncated if the Writer
+// was built with NewLimited.
+func (w *Writer) Body() []byte {
+	return w.body.Bytes()
+}
+
+// BodySize returns the size of the whole response body written so far.
+func (w *Writer) BodySize() int {
+	return w.bodySize
+}
+
+// IsApplicationError reports whether the response is an application error.
+func (w *Writer) IsApplicationError() bool {
+	return w.applicationError
+}
+
+// ApplicationErrorMeta returns the recorded metadata of an application
+// error, if any.
+func (w *Writer) ApplicationErrorMeta() *transport.ApplicationErrorMeta {
+	return w.applicationErrorMeta
+}
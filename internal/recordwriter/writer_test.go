@@ -0,0 +1,81 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package recordwriter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+)
+
+func TestWriter(t *testing.T) {
+	meta := &transport.ApplicationErrorMeta{Name: "NotFound"}
+
+	underlying := new(transporttest.FakeResponseWriter)
+	w := New(underlying)
+	w.AddHeaders(transport.NewHeaders().With("foo", "bar"))
+	w.AddHeaders(transport.NewHeaders().With("Baz", "qux"))
+	w.SetApplicationError()
+	w.SetApplicationErrorMeta(meta)
+	w.Write([]byte("hello "))
+	w.Write([]byte("world"))
+
+	assert.Equal(t, map[string]string{"foo": "bar", "Baz": "qux"}, w.Headers().OriginalItems())
+	assert.Equal(t, "hello world", string(w.Body()))
+	assert.Equal(t, 11, w.BodySize())
+	assert.True(t, w.IsApplicationError())
+	assert.Equal(t, meta, w.ApplicationErrorMeta())
+
+	// The response is forwarded unchanged.
+	assert.Equal(t, map[string]string{"foo": "bar", "baz": "qux"}, underlying.Headers.Items())
+	assert.Equal(t, "hello world", underlying.Body.String())
+	assert.True(t, underlying.IsApplicationError)
+	assert.Equal(t, meta, underlying.ApplicationErrorMeta)
+}
+
+func TestNewLimited(t *testing.T) {
+	tests := []struct {
+		desc         string
+		maxBodyBytes int
+		want         string
+	}{
+		{desc: "within the limit", maxBodyBytes: 20, want: "hello world"},
+		{desc: "at the limit", maxBodyBytes: 11, want: "hello world"},
+		{desc: "across writes", maxBodyBytes: 8, want: "hello wo"},
+		{desc: "within the first write", maxBodyBytes: 3, want: "hel"},
+		{desc: "nothing", maxBodyBytes: 0, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			underlying := new(transporttest.FakeResponseWriter)
+			w := NewLimited(underlying, tt.maxBodyBytes)
+			w.Write([]byte("hello "))
+			w.Write([]byte("world"))
+
+			assert.Equal(t, tt.want, string(w.Body()))
+			assert.Equal(t, 11, w.BodySize())
+			assert.Equal(t, "hello world", underlying.Body.String(), "the whole body must be forwarded")
+		})
+	}
+}
@@ -23,6 +23,7 @@ package request
 import (
 	"context"
 
+	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/x/introspection"
 )
@@ -46,6 +47,15 @@ func (o UnaryValidatorOutbound) Call(ctx context.Context, request *transport.Req
 	return o.UnaryOutbound.Call(ctx, request)
 }
 
+// TransportName returns the name of the transport of the underlying
+// outbound, or an empty string if it has none.
+func (o UnaryValidatorOutbound) TransportName() string {
+	if o.Namer == nil {
+		return ""
+	}
+	return o.Namer.TransportName()
+}
+
 // Introspect returns the introspection status of the underlying outbound.
 func (o UnaryValidatorOutbound) Introspect() introspection.OutboundStatus {
 	if o, ok := o.UnaryOutbound.(introspection.IntrospectableOutbound); ok {
@@ -54,6 +64,15 @@ func (o UnaryValidatorOutbound) Introspect() introspection.OutboundStatus {
 	return introspection.OutboundStatusNotSupported
 }
 
+// SubscribePeerStatus subscribes to the peer status changes of the underlying
+// outbound.
+func (o UnaryValidatorOutbound) SubscribePeerStatus(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func(), ok bool) {
+	if o, ok := o.UnaryOutbound.(introspection.PeerStatusWatchableOutbound); ok {
+		return o.SubscribePeerStatus(fn)
+	}
+	return nil, false
+}
+
 // OnewayValidatorOutbound wraps an Outbound to validate all outgoing oneway requests.
 type OnewayValidatorOutbound struct {
 	transport.OnewayOutbound
@@ -73,6 +92,15 @@ func (o OnewayValidatorOutbound) CallOneway(ctx context.Context, request *transp
 	return o.OnewayOutbound.CallOneway(ctx, request)
 }
 
+// TransportName returns the name of the transport of the underlying
+// outbound, or an empty string if it has none.
+func (o OnewayValidatorOutbound) TransportName() string {
+	if o.Namer == nil {
+		return ""
+	}
+	return o.Namer.TransportName()
+}
+
 // Introspect returns the introspection status of the underlying outbound.
 func (o OnewayValidatorOutbound) Introspect() introspection.OutboundStatus {
 	if o, ok := o.OnewayOutbound.(introspection.IntrospectableOutbound); ok {
@@ -81,6 +109,15 @@ func (o OnewayValidatorOutbound) Introspect() introspection.OutboundStatus {
 	return introspection.OutboundStatusNotSupported
 }
 
+// SubscribePeerStatus subscribes to the peer status changes of the underlying
+// outbound.
+func (o OnewayValidatorOutbound) SubscribePeerStatus(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func(), ok bool) {
+	if o, ok := o.OnewayOutbound.(introspection.PeerStatusWatchableOutbound); ok {
+		return o.SubscribePeerStatus(fn)
+	}
+	return nil, false
+}
+
 // StreamValidatorOutbound wraps an Outbound to validate all outgoing oneway requests.
 type StreamValidatorOutbound struct {
 	transport.Namer
@@ -96,6 +133,15 @@ func (o StreamValidatorOutbound) CallStream(ctx context.Context, request *transp
 	return o.StreamOutbound.CallStream(ctx, request)
 }
 
+// TransportName returns the name of the transport of the underlying
+// outbound, or an empty string if it has none.
+func (o StreamValidatorOutbound) TransportName() string {
+	if o.Namer == nil {
+		return ""
+	}
+	return o.Namer.TransportName()
+}
+
 // Introspect returns the introspection status of the underlying outbound.
 func (o StreamValidatorOutbound) Introspect() introspection.OutboundStatus {
 	if o, ok := o.StreamOutbound.(introspection.IntrospectableOutbound); ok {
@@ -103,3 +149,12 @@ func (o StreamValidatorOutbound) Introspect() introspection.OutboundStatus {
 	}
 	return introspection.OutboundStatusNotSupported
 }
+
+// SubscribePeerStatus subscribes to the peer status changes of the underlying
+// outbound.
+func (o StreamValidatorOutbound) SubscribePeerStatus(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func(), ok bool) {
+	if o, ok := o.StreamOutbound.(introspection.PeerStatusWatchableOutbound); ok {
+		return o.SubscribePeerStatus(fn)
+	}
+	return nil, false
+}
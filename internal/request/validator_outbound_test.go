@@ -123,6 +123,25 @@ func TestIntrospect(t *testing.T) {
 	})
 }
 
+func TestTransportName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	t.Run("unary", func(t *testing.T) {
+		validatorOut := UnaryValidatorOutbound{UnaryOutbound: transporttest.NewMockUnaryOutbound(ctrl)}
+		assert.Empty(t, validatorOut.TransportName())
+	})
+
+	t.Run("oneway", func(t *testing.T) {
+		validatorOut := OnewayValidatorOutbound{OnewayOutbound: transporttest.NewMockOnewayOutbound(ctrl)}
+		assert.Empty(t, validatorOut.TransportName())
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		validatorOut := StreamValidatorOutbound{StreamOutbound: transporttest.NewMockStreamOutbound(ctrl)}
+		assert.Empty(t, validatorOut.TransportName())
+	})
+}
+
 func newValidTestRequest() *transport.Request {
 	return &transport.Request{
 		Service:   "service",
@@ -0,0 +1,92 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package retry
+
+import "sync"
+
+const (
+	_defaultBudgetMaxTokens  = 10
+	_defaultBudgetTokenRatio = 0.1
+)
+
+// Budget configures a token bucket that limits retries, so that retries
+// cannot amplify an outage.
+//
+// Every call deposits TokenRatio tokens, up to MaxTokens, and every retry
+// withdraws one token.
+// When the bucket has less than one token, calls are not retried.
+// The bucket starts full.
+type Budget struct {
+	// MaxTokens is the capacity of the bucket, bounding bursts of retries.
+	//
+	// Defaults to 10.
+	MaxTokens float64
+
+	// TokenRatio is the number of tokens each call deposits, bounding the
+	// ratio of retries to calls in the long run.
+	//
+	// Defaults to 0.1, allowing one retry for every ten calls.
+	TokenRatio float64
+}
+
+// tokenBucket is the thread-safe state of a retry budget.
+type tokenBucket struct {
+	lock   sync.Mutex
+	tokens float64
+	max    float64
+	ratio  float64
+}
+
+func newTokenBucket(b Budget) *tokenBucket {
+	max := b.MaxTokens
+	if max <= 0 {
+		max = _defaultBudgetMaxTokens
+	}
+	ratio := b.TokenRatio
+	if ratio <= 0 {
+		ratio = _defaultBudgetTokenRatio
+	}
+	return &tokenBucket{tokens: max, max: max, ratio: ratio}
+}
+
+// deposit adds the tokens of a call to the bucket.
+func (b *tokenBucket) deposit() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.tokens += b.ratio
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+}
+
+// withdraw takes a token for a retry from the bucket, returning false if
+// the budget is exhausted.
+func (b *tokenBucket) withdraw() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
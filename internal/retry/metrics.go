@@ -0,0 +1,107 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package retry
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+type retryMetrics struct {
+	attempts        *metrics.CounterVector
+	retries         *metrics.CounterVector
+	budgetExhausted *metrics.CounterVector
+	calls           *metrics.CounterVector
+}
+
+func newRetryMetrics(meter *metrics.Scope, logger *zap.Logger) *retryMetrics {
+	tags := []string{_serviceTag, _procedureTag}
+
+	attempts, err := meter.CounterVector(metrics.Spec{
+		Name:    "retry_attempts",
+		Help:    "Total number of attempts of calls that may be retried.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create retry attempts counter", zap.Error(err))
+	}
+	retries, err := meter.CounterVector(metrics.Spec{
+		Name:    "retry_retries",
+		Help:    "Total number of attempts that retried a failed attempt.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create retries counter", zap.Error(err))
+	}
+	budgetExhausted, err := meter.CounterVector(metrics.Spec{
+		Name:    "retry_budget_exhausted",
+		Help:    "Total number of calls that were not retried because the retry budget was exhausted.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create retry budget exhausted counter", zap.Error(err))
+	}
+	calls, err := meter.CounterVector(metrics.Spec{
+		Name:    "retry_calls",
+		Help:    "Total number of calls that may be retried, by the code of their final outcome.",
+		VarTags: append(tags, _codeTag),
+	})
+	if err != nil {
+		logger.Error("failed to create retry calls counter", zap.Error(err))
+	}
+
+	return &retryMetrics{
+		attempts:        attempts,
+		retries:         retries,
+		budgetExhausted: budgetExhausted,
+		calls:           calls,
+	}
+}
+
+// edgeMetrics are the counters of a service and procedure.
+type edgeMetrics struct {
+	attempts        *metrics.Counter
+	retries         *metrics.Counter
+	budgetExhausted *metrics.Counter
+
+	calls     *metrics.CounterVector
+	service   string
+	procedure string
+}
+
+func (m *retryMetrics) edge(req *transport.Request) *edgeMetrics {
+	return &edgeMetrics{
+		attempts:        m.attempts.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+		retries:         m.retries.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+		budgetExhausted: m.budgetExhausted.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+		calls:           m.calls,
+		service:         req.Service,
+		procedure:       req.Procedure,
+	}
+}
+
+// finish records the final outcome of a call.
+func (e *edgeMetrics) finish(err error) {
+	code := yarpcerrors.FromError(err).Code()
+	e.calls.MustGet(_serviceTag, e.service, _procedureTag, e.procedure, _codeTag, code.String()).Inc()
+}
@@ -0,0 +1,321 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package retry
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/backoff"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	intbackoff "go.uber.org/yarpc/internal/backoff"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	_serviceTag   = "service"
+	_procedureTag = "procedure"
+	_codeTag      = "code"
+)
+
+// Policy specifies how to retry the calls of a procedure.
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts of a call, including the
+	// first.
+	// Calls are not retried if MaxAttempts is less than 2.
+	MaxAttempts int
+
+	// Backoff specifies how long to wait between attempts.
+	//
+	// Defaults to exponential backoff with full jitter.
+	Backoff backoff.Strategy
+
+	// RetryableCodes are the error codes of the attempts to retry.
+	//
+	// Defaults to CodeUnavailable.
+	RetryableCodes []yarpcerrors.Code
+}
+
+// Override specifies the retry policy for the procedures of a service, or
+// for a single procedure of the service if Procedure is set.
+//
+// Procedure is either a procedure name, or a prefix of procedure names
+// followed by "*", like "Store::*". Procedure names take precedence over
+// prefixes, and longer prefixes over shorter ones.
+type Override struct {
+	Service   string
+	Procedure string
+	Policy    Policy
+}
+
+// Config configures the retry middleware.
+type Config struct {
+	// Default is the policy for calls that no override matches.
+	// Calls are not retried without a policy.
+	Default *Policy
+
+	// Overrides specify the policies for services and procedures.
+	// An override for a procedure takes precedence over an override for its
+	// service.
+	Overrides []Override
+
+	// Budget limits the retries to each service.
+	Budget Budget
+
+	Meter  *metrics.Scope
+	Logger *zap.Logger
+}
+
+type procedureKey struct {
+	service   string
+	procedure string
+}
+
+// policy is a policy with its defaults applied.
+type policy struct {
+	maxAttempts int
+	backoff     backoff.Strategy
+	retryable   map[yarpcerrors.Code]struct{}
+}
+
+func newPolicy(p Policy) *policy {
+	strategy := p.Backoff
+	if strategy == nil {
+		strategy = intbackoff.DefaultExponential
+	}
+	codes := p.RetryableCodes
+	if len(codes) == 0 {
+		codes = []yarpcerrors.Code{yarpcerrors.CodeUnavailable}
+	}
+	retryable := make(map[yarpcerrors.Code]struct{}, len(codes))
+	for _, code := range codes {
+		retryable[code] = struct{}{}
+	}
+	return &policy{
+		maxAttempts: p.MaxAttempts,
+		backoff:     strategy,
+		retryable:   retryable,
+	}
+}
+
+// policies are the policies of the middleware, which are replaced as a
+// whole when they change at runtime.
+type policies struct {
+	defaultPolicy *policy
+	services      map[string]*policy
+	procedures    map[procedureKey]*policy
+	prefixes      map[string][]policyPrefix
+}
+
+// policyPrefix is the policy of the procedures of a service whose names
+// start with a prefix.
+type policyPrefix struct {
+	prefix string
+	policy *policy
+}
+
+func newPolicies(def *Policy, overrides []Override) *policies {
+	p := &policies{
+		services:   make(map[string]*policy),
+		procedures: make(map[procedureKey]*policy),
+		prefixes:   make(map[string][]policyPrefix),
+	}
+	if def != nil {
+		p.defaultPolicy = newPolicy(*def)
+	}
+	for _, o := range overrides {
+		if o.Procedure == "" {
+			p.services[o.Service] = newPolicy(o.Policy)
+		} else if prefix := strings.TrimSuffix(o.Procedure, "*"); prefix != o.Procedure {
+			p.prefixes[o.Service] = append(p.prefixes[o.Service], policyPrefix{prefix: prefix, policy: newPolicy(o.Policy)})
+		} else {
+			p.procedures[procedureKey{o.Service, o.Procedure}] = newPolicy(o.Policy)
+		}
+	}
+	return p
+}
+
+// policy returns the policy for the request, or nil if the request must not
+// be retried.
+func (p *policies) policy(req *transport.Request) *policy {
+	if pol, ok := p.procedures[procedureKey{req.Service, req.Procedure}]; ok {
+		return pol
+	}
+	var (
+		matched *policy
+		longest = -1
+	)
+	for _, pp := range p.prefixes[req.Service] {
+		if len(pp.prefix) > longest && strings.HasPrefix(req.Procedure, pp.prefix) {
+			matched, longest = pp.policy, len(pp.prefix)
+		}
+	}
+	if matched != nil {
+		return matched
+	}
+	if pol, ok := p.services[req.Service]; ok {
+		return pol
+	}
+	return p.defaultPolicy
+}
+
+func (p *policy) isRetryable(err error) bool {
+	if !yarpcerrors.IsStatus(err) {
+		return false
+	}
+	_, ok := p.retryable[yarpcerrors.FromError(err).Code()]
+	return ok
+}
+
+var _ middleware.UnaryOutbound = (*Middleware)(nil)
+
+// Middleware is a unary outbound middleware that retries failed calls
+// according to the policy of their procedure.
+//
+// The middleware buffers the body of each request it may retry, so every
+// attempt can read it.
+// Calls made with a context from WithNoRetry are never retried.
+type Middleware struct {
+	policies atomic.Value // *policies
+	budget   Budget
+	metrics  *retryMetrics
+
+	lock    sync.Mutex
+	budgets map[string]*tokenBucket
+}
+
+// NewMiddleware returns a unary outbound middleware that retries calls.
+func NewMiddleware(cfg Config) *Middleware {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	m := &Middleware{
+		budget:  cfg.Budget,
+		metrics: newRetryMetrics(cfg.Meter, logger),
+		budgets: make(map[string]*tokenBucket),
+	}
+	m.policies.Store(newPolicies(cfg.Default, cfg.Overrides))
+	return m
+}
+
+// SetPolicies replaces the policies of the middleware.
+//
+// Calls in flight keep the policy they started with. The retry budgets of
+// services are kept across changes.
+func (m *Middleware) SetPolicies(def *Policy, overrides []Override) {
+	m.policies.Store(newPolicies(def, overrides))
+}
+
+// tokenBucket returns the retry budget of a service.
+func (m *Middleware) tokenBucket(service string) *tokenBucket {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	b, ok := m.budgets[service]
+	if !ok {
+		b = newTokenBucket(m.budget)
+		m.budgets[service] = b
+	}
+	return b
+}
+
+// Call sends the request, retrying failed attempts with a retryable error
+// code until the call succeeds, runs out of attempts, exhausts the retry
+// budget of the service, or would outlast its deadline.
+//
+// A call that cannot be retried again returns the result of its last
+// attempt.
+func (m *Middleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	p := m.policies.Load().(*policies).policy(req)
+	if p == nil || p.maxAttempts < 2 || disabled(ctx) {
+		return out.Call(ctx, req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	edge := m.metrics.edge(req)
+	bucket := m.tokenBucket(req.Service)
+	bucket.deposit()
+	bo := p.backoff.Backoff()
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := *req
+		if req.Body != nil {
+			attemptReq.Body = bytes.NewReader(body)
+		}
+
+		edge.attempts.Inc()
+		res, err := out.Call(ctx, &attemptReq)
+		if err == nil || !p.isRetryable(err) || attempt >= p.maxAttempts {
+			edge.finish(err)
+			return res, err
+		}
+		if !bucket.withdraw() {
+			edge.budgetExhausted.Inc()
+			edge.finish(err)
+			return res, err
+		}
+		if !wait(ctx, bo.Duration(uint(attempt-1))) {
+			edge.finish(err)
+			return res, err
+		}
+		// Release the response of the failed attempt, which may hold a
+		// connection or stream, before retrying.
+		if res != nil && res.Body != nil {
+			_ = res.Body.Close()
+		}
+		edge.retries.Inc()
+	}
+}
+
+// wait sleeps for the backoff duration, returning false without sleeping if
+// the context deadline would pass first, or early if the context finishes.
+func wait(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return false
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package retry
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/backoff"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+type constantBackoff time.Duration
+
+func (b constantBackoff) Backoff() backoff.Backoff             { return b }
+func (b constantBackoff) Duration(attempts uint) time.Duration { return time.Duration(b) }
+
+// fakeOutbound returns the given errors from successive attempts, then
+// succeeds, recording the body of each attempt.
+type fakeOutbound struct {
+	transport.UnaryOutbound
+
+	errs   []error
+	bodies []string
+}
+
+func (o *fakeOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	o.bodies = append(o.bodies, string(body))
+	if attempt := len(o.bodies) - 1; attempt < len(o.errs) {
+		return nil, o.errs[attempt]
+	}
+	return &transport.Response{Body: ioutil.NopCloser(bytes.NewReader([]byte("ok")))}, nil
+}
+
+func newRequest() *transport.Request {
+	return &transport.Request{
+		Service:   "service",
+		Procedure: "procedure",
+		Body:      bytes.NewReader([]byte("body")),
+	}
+}
+
+func unavailable() error {
+	return yarpcerrors.UnavailableErrorf("unavailable")
+}
+
+func counters(root *metrics.Root) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, c := range root.Snapshot().Counters {
+		name := c.Name
+		if code, ok := c.Tags[_codeTag]; ok {
+			name += ":" + code
+		}
+		counts[name] += c.Value
+	}
+	return counts
+}
+
+func TestSuccessAfterRetry(t *testing.T) {
+	root := metrics.New()
+	mw := NewMiddleware(Config{
+		Default: &Policy{MaxAttempts: 3, Backoff: constantBackoff(0)},
+		Meter:   root.Scope(),
+	})
+	out := &fakeOutbound{errs: []error{unavailable(), unavailable()}}
+
+	res, err := mw.Call(context.Background(), newRequest(), out)
+	require.NoError(t, err)
+	require.NotNil(t, res)
+	assert.Equal(t, []string{"body", "body", "body"}, out.bodies, "expected every attempt to read the whole body")
+
+	assert.Equal(t, map[string]int64{
+		"retry_attempts":         3,
+		"retry_retries":          2,
+		"retry_budget_exhausted": 0,
+		"retry_calls:ok":         1,
+	}, counters(root))
+}
+
+func TestMaxAttempts(t *testing.T) {
+	root := metrics.New()
+	mw := NewMiddleware(Config{
+		Default: &Policy{MaxAttempts: 2, Backoff: constantBackoff(0)},
+		Meter:   root.Scope(),
+	})
+	out := &fakeOutbound{errs: []error{unavailable(), unavailable(), unavailable()}}
+
+	_, err := mw.Call(context.Background(), newRequest(), out)
+	assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code())
+	assert.Len(t, out.bodies, 2)
+	assert.Equal(t, int64(1), counters(root)["retry_calls:unavailable"])
+}
+
+// closeRecorder is a response body that records whether it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeRecorder) Close() error {
+	b.closed = true
+	return nil
+}
+
+// failingOutbound fails every attempt with a response, recording the body of
+// each response.
+type failingOutbound struct {
+	transport.UnaryOutbound
+
+	bodies []*closeRecorder
+}
+
+func (o *failingOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	body := &closeRecorder{Reader: bytes.NewReader([]byte("error details"))}
+	o.bodies = append(o.bodies, body)
+	return &transport.Response{Body: body, ApplicationError: true}, unavailable()
+}
+
+func TestFailedAttemptBodiesClosed(t *testing.T) {
+	mw := NewMiddleware(Config{
+		Default: &Policy{MaxAttempts: 3, Backoff: constantBackoff(0)},
+	})
+	out := &failingOutbound{}
+
+	res, err := mw.Call(context.Background(), newRequest(), out)
+	assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code())
+	require.Len(t, out.bodies, 3)
+	assert.True(t, out.bodies[0].closed, "body of the first failed attempt must be closed")
+	assert.True(t, out.bodies[1].closed, "body of the second failed attempt must be closed")
+	assert.False(t, out.bodies[2].closed, "body of the returned attempt must be left to the caller")
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "error details", string(body))
+}
+
+func TestNonRetryableCode(t *testing.T) {
+	mw := NewMiddleware(Config{
+		Default: &Policy{MaxAttempts: 3, Backoff: constantBackoff(0)},
+	})
+
+	out := &fakeOutbound{errs: []error{yarpcerrors.InvalidArgumentErrorf("invalid")}}
+	_, err := mw.Call(context.Background(), newRequest(), out)
+	assert.Equal(t, yarpcerrors.CodeInvalidArgument, yarpcerrors.FromError(err).Code())
+	assert.Len(t, out.bodies, 1)
+
+	mw = NewMiddleware(Config{
+		Default: &Policy{
+			MaxAttempts:    3,
+			Backoff:        constantBackoff(0),
+			RetryableCodes: []yarpcerrors.Code{yarpcerrors.CodeResourceExhausted},
+		},
+	})
+	out = &fakeOutbound{errs: []error{unavailable()}}
+	_, err = mw.Call(context.Background(), newRequest(), out)
+	assert.Error(t, err)
+	assert.Len(t, out.bodies, 1, "expected only the configured codes to be retried")
+}
+
+func TestBudgetExhaustion(t *testing.T) {
+	root := metrics.New()
+	mw := NewMiddleware(Config{
+		Default: &Policy{MaxAttempts: 3, Backoff: constantBackoff(0)},
+		Budget:  Budget{MaxTokens: 2, TokenRatio: 0.5},
+		Meter:   root.Scope(),
+	})
+
+	// The first call spends both tokens on its retries.
+	out := &fakeOutbound{errs: []error{unavailable(), unavailable(), unavailable()}}
+	_, err := mw.Call(context.Background(), newRequest(), out)
+	assert.Error(t, err)
+	assert.Len(t, out.bodies, 3)
+
+	// The second call deposits half a token, which cannot pay for a retry.
+	out = &fakeOutbound{errs: []error{unavailable()}}
+	_, err = mw.Call(context.Background(), newRequest(), out)
+	assert.Error(t, err)
+	assert.Len(t, out.bodies, 1, "expected no retry with an exhausted budget")
+
+	// The third call deposits another half token, paying for a retry.
+	out = &fakeOutbound{errs: []error{unavailable()}}
+	_, err = mw.Call(context.Background(), newRequest(), out)
+	assert.NoError(t, err)
+	assert.Len(t, out.bodies, 2)
+
+	counts := counters(root)
+	assert.Equal(t, int64(1), counts["retry_budget_exhausted"])
+	assert.Equal(t, int64(3), counts["retry_retries"])
+
+	// Budgets are independent for each service.
+	req := newRequest()
+	req.Service = "other"
+	out = &fakeOutbound{errs: []error{unavailable()}}
+	_, err = mw.Call(context.Background(), req, out)
+	assert.NoError(t, err)
+}
+
+func TestDeadlineExpiryMidBackoff(t *testing.T) {
+	mw := NewMiddleware(Config{
+		Default: &Policy{MaxAttempts: 3, Backoff: constantBackoff(time.Hour)},
+	})
+
+	t.Run("backoff outlasts deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+		defer cancel()
+
+		out := &fakeOutbound{errs: []error{unavailable()}}
+		start := time.Now()
+		_, err := mw.Call(ctx, newRequest(), out)
+		assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code(),
+			"expected the error of the last attempt")
+		assert.Len(t, out.bodies, 1)
+		assert.True(t, time.Since(start) < testtime.Second, "expected the call not to wait for the deadline")
+	})
+
+	t.Run("context canceled during backoff", func(t *testing.T) {
+		mw := NewMiddleware(Config{
+			Default: &Policy{MaxAttempts: 3, Backoff: constantBackoff(testtime.Second)},
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*testtime.Millisecond, cancel)
+
+		out := &fakeOutbound{errs: []error{unavailable()}}
+		_, err := mw.Call(ctx, newRequest(), out)
+		assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code())
+		assert.Len(t, out.bodies, 1)
+	})
+}
+
+func TestWithNoRetry(t *testing.T) {
+	mw := NewMiddleware(Config{
+		Default: &Policy{MaxAttempts: 3, Backoff: constantBackoff(0)},
+	})
+	out := &fakeOutbound{errs: []error{unavailable()}}
+
+	_, err := mw.Call(WithNoRetry(context.Background()), newRequest(), out)
+	assert.Error(t, err)
+	assert.Len(t, out.bodies, 1)
+}
+
+func TestOverrides(t *testing.T) {
+	mw := NewMiddleware(Config{
+		Overrides: []Override{
+			{Service: "service", Policy: Policy{MaxAttempts: 2, Backoff: constantBackoff(0)}},
+			{Service: "service", Procedure: "procedure", Policy: Policy{MaxAttempts: 4, Backoff: constantBackoff(0)}},
+			{Service: "service", Procedure: "Store::*", Policy: Policy{MaxAttempts: 3, Backoff: constantBackoff(0)}},
+			{Service: "service", Procedure: "Store::Get*", Policy: Policy{MaxAttempts: 5, Backoff: constantBackoff(0)}},
+			{Service: "service", Procedure: "Store::GetAll", Policy: Policy{MaxAttempts: 1}},
+		},
+	})
+
+	attempts := func(service, procedure string) int {
+		req := newRequest()
+		req.Service = service
+		req.Procedure = procedure
+		out := &fakeOutbound{errs: []error{unavailable(), unavailable(), unavailable(), unavailable()}}
+		_, _ = mw.Call(context.Background(), req, out)
+		return len(out.bodies)
+	}
+
+	assert.Equal(t, 4, attempts("service", "procedure"), "expected the procedure policy")
+	assert.Equal(t, 2, attempts("service", "other"), "expected the service policy")
+	assert.Equal(t, 3, attempts("service", "Store::Put"), "expected the prefix policy")
+	assert.Equal(t, 5, attempts("service", "Store::GetValue"), "expected the longest prefix policy")
+	assert.Equal(t, 1, attempts("service", "Store::GetAll"), "expected the procedure policy over prefixes")
+	assert.Equal(t, 1, attempts("other", "procedure"), "expected no retries without a default policy")
+}
+
+func TestSetPolicies(t *testing.T) {
+	mw := NewMiddleware(Config{
+		Default: &Policy{MaxAttempts: 2, Backoff: constantBackoff(0)},
+	})
+
+	attempts := func(service string) int {
+		req := newRequest()
+		req.Service = service
+		out := &fakeOutbound{errs: []error{unavailable(), unavailable(), unavailable(), unavailable()}}
+		_, _ = mw.Call(context.Background(), req, out)
+		return len(out.bodies)
+	}
+	assert.Equal(t, 2, attempts("service"))
+
+	mw.SetPolicies(nil, []Override{
+		{Service: "service", Policy: Policy{MaxAttempts: 3, Backoff: constantBackoff(0)}},
+	})
+	assert.Equal(t, 3, attempts("service"), "expected the new override to apply")
+	assert.Equal(t, 1, attempts("other"), "expected removing the default policy to disable it")
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package window counts the outcomes of requests over a sliding time window,
+// shared by peer lists, choosers and middleware that act on failure rates.
+package window
+
+import "time"
+
+const _windowBuckets = 10
+
+// Window counts successes and failures over a sliding time window, split
+// into a fixed number of buckets.
+//
+// Window is not safe for concurrent use.
+type Window struct {
+	bucketWidth time.Duration
+	buckets     [_windowBuckets]bucket
+}
+
+type bucket struct {
+	index     int64
+	successes int
+	failures  int
+}
+
+// New returns a window of the given width.
+func New(width time.Duration) Window {
+	bucketWidth := width / _windowBuckets
+	if bucketWidth <= 0 {
+		bucketWidth = 1
+	}
+	return Window{bucketWidth: bucketWidth}
+}
+
+// Record records the outcome of a request at the given time.
+func (w *Window) Record(now time.Time, failed bool) {
+	index := int64(now.UnixNano()) / int64(w.bucketWidth)
+	b := &w.buckets[index%_windowBuckets]
+	if b.index != index {
+		*b = bucket{index: index}
+	}
+	if failed {
+		b.failures++
+	} else {
+		b.successes++
+	}
+}
+
+// Counts returns the number of successes and failures recorded within the
+// window ending at the given time.
+func (w *Window) Counts(now time.Time) (successes, failures int) {
+	index := int64(now.UnixNano()) / int64(w.bucketWidth)
+	for _, b := range w.buckets {
+		if b.index > index-_windowBuckets && b.index <= index {
+			successes += b.successes
+			failures += b.failures
+		}
+	}
+	return successes, failures
+}
+
+// Reset forgets all recorded outcomes.
+func (w *Window) Reset() {
+	w.buckets = [_windowBuckets]bucket{}
+}
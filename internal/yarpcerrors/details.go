@@ -0,0 +1,91 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcerrors
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// DetailsToJSON returns the error details of the given Status, as attached by
+// yarpcerrors.WithDetails, as a JSON array of google.protobuf.Any objects, for
+// transports that carry error details in a header.
+//
+// It returns false if the Status has no such details or they cannot be
+// represented as JSON, for example because a message type is not registered
+// with the Protobuf runtime.
+func DetailsToJSON(status *yarpcerrors.Status) (string, bool) {
+	details := status.Details()
+	if len(details) == 0 {
+		return "", false
+	}
+	pst := &rpc.Status{}
+	if err := proto.Unmarshal(details, pst); err != nil || len(pst.Details) == 0 {
+		return "", false
+	}
+
+	var marshaler jsonpb.Marshaler
+	elems := make([]string, 0, len(pst.Details))
+	for _, any := range pst.Details {
+		elem, err := marshaler.MarshalToString(any)
+		if err != nil {
+			return "", false
+		}
+		elems = append(elems, elem)
+	}
+	return "[" + strings.Join(elems, ",") + "]", true
+}
+
+// WithJSONDetails returns the Status with the error details from a JSON
+// array produced by DetailsToJSON.
+//
+// The Status is returned as is if the details cannot be decoded.
+func WithJSONDetails(status *yarpcerrors.Status, details string) *yarpcerrors.Status {
+	var elems []json.RawMessage
+	if err := json.Unmarshal([]byte(details), &elems); err != nil || len(elems) == 0 {
+		return status
+	}
+
+	pst := &rpc.Status{
+		Code:    int32(status.Code()),
+		Message: status.Message(),
+		Details: make([]*types.Any, 0, len(elems)),
+	}
+	for _, elem := range elems {
+		any := &types.Any{}
+		if err := jsonpb.UnmarshalString(string(elem), any); err != nil {
+			return status
+		}
+		pst.Details = append(pst.Details, any)
+	}
+
+	b, err := proto.Marshal(pst)
+	if err != nil {
+		return status
+	}
+	return status.WithDetails(b)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcerrors
+
+import (
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+func TestJSONDetailsRoundTrip(t *testing.T) {
+	err := yarpcerrors.WithDetails(yarpcerrors.InvalidArgumentErrorf("great sadness"),
+		&types.StringValue{Value: "string value"},
+		&types.Int32Value{Value: 100},
+	)
+
+	details, ok := DetailsToJSON(yarpcerrors.FromError(err))
+	require.True(t, ok, "expected JSON details")
+	assert.JSONEq(t, `[
+		{"@type": "type.googleapis.com/google.protobuf.StringValue", "value": "string value"},
+		{"@type": "type.googleapis.com/google.protobuf.Int32Value", "value": 100}
+	]`, details)
+
+	status := WithJSONDetails(yarpcerrors.Newf(yarpcerrors.CodeInvalidArgument, "great sadness"), details)
+	got, err := yarpcerrors.Details(status)
+	require.NoError(t, err)
+	assert.Equal(t, []proto.Message{
+		&types.StringValue{Value: "string value"},
+		&types.Int32Value{Value: 100},
+	}, got)
+}
+
+func TestDetailsToJSONWithoutDetails(t *testing.T) {
+	for _, status := range []*yarpcerrors.Status{
+		yarpcerrors.Newf(yarpcerrors.CodeInternal, "great sadness"),
+		yarpcerrors.Newf(yarpcerrors.CodeInternal, "great sadness").WithDetails([]byte("not a status")),
+	} {
+		_, ok := DetailsToJSON(status)
+		assert.False(t, ok)
+	}
+}
+
+func TestWithJSONDetailsInvalid(t *testing.T) {
+	status := yarpcerrors.Newf(yarpcerrors.CodeInternal, "great sadness")
+	for _, details := range []string{
+		"",
+		"not json",
+		"[]",
+		`[{"@type": "type.googleapis.com/unknown.Message"}]`,
+	} {
+		assert.Equal(t, status, WithJSONDetails(status, details), "details: %q", details)
+	}
+}
@@ -21,6 +21,8 @@
 package yarpc
 
 import (
+	"fmt"
+
 	"go.uber.org/yarpc/api/middleware"
 	"go.uber.org/yarpc/internal/inboundmiddleware"
 	"go.uber.org/yarpc/internal/outboundmiddleware"
@@ -61,3 +63,206 @@ func StreamOutboundMiddleware(mw ...middleware.StreamOutbound) middleware.Stream
 func StreamInboundMiddleware(mw ...middleware.StreamInbound) middleware.StreamInbound {
 	return inboundmiddleware.StreamChain(mw...)
 }
+
+// ConfigMiddlewareName is the name under which the InboundMiddleware and
+// OutboundMiddleware of a Config are registered with its MiddlewareChain, so
+// that named middleware may be ordered relative to them.
+const ConfigMiddlewareName = "config"
+
+// applyMiddlewareChain replaces the middleware of the config with the chains
+// of the middleware in its MiddlewareChain, including the middleware of the
+// config itself, before the middleware of the dispatcher are added around
+// them. It returns the names of the outbound middleware of the config.
+func applyMiddlewareChain(cfg Config) (Config, outboundMiddlewareNames) {
+	config := configMiddleware{
+		UnaryInbound:   cfg.InboundMiddleware.Unary,
+		OnewayInbound:  cfg.InboundMiddleware.Oneway,
+		StreamInbound:  cfg.InboundMiddleware.Stream,
+		UnaryOutbound:  cfg.OutboundMiddleware.Unary,
+		OnewayOutbound: cfg.OutboundMiddleware.Oneway,
+		StreamOutbound: cfg.OutboundMiddleware.Stream,
+	}
+	if cfg.MiddlewareChain == nil {
+		var names outboundMiddlewareNames
+		names.add(ConfigMiddlewareName, config)
+		return cfg, names
+	}
+
+	chain := middleware.NewChain()
+	chain.MustRegister(ConfigMiddlewareName, config)
+	if err := chain.Merge(cfg.MiddlewareChain); err != nil {
+		panic("yarpc.NewDispatcher expects a valid middleware chain: " + err.Error())
+	}
+	mw, err := orderChain(chain)
+	if err != nil {
+		panic("yarpc.NewDispatcher expects a valid middleware chain: " + err.Error())
+	}
+
+	cfg.InboundMiddleware = InboundMiddleware{
+		Unary:  inboundmiddleware.UnaryChain(mw.unaryInbound...),
+		Oneway: inboundmiddleware.OnewayChain(mw.onewayInbound...),
+		Stream: inboundmiddleware.StreamChain(mw.streamInbound...),
+	}
+	cfg.OutboundMiddleware = mw.outbound()
+	return cfg, mw.names
+}
+
+// applyPerOutboundMiddleware wraps the outbounds of the config with their
+// middleware from its PerOutboundMiddleware, so that the middleware of every
+// outbound are applied around them. It returns the names of the outbound
+// middleware of every outbound key, given the names of the middleware of
+// every outbound.
+func applyPerOutboundMiddleware(cfg Config, names outboundMiddlewareNames) (Config, map[string]outboundMiddlewareNames) {
+	byKey := make(map[string]outboundMiddlewareNames, len(cfg.Outbounds))
+	for key := range cfg.Outbounds {
+		byKey[key] = names
+	}
+	if len(cfg.PerOutboundMiddleware) == 0 {
+		return cfg, byKey
+	}
+
+	outbounds := make(Outbounds, len(cfg.Outbounds))
+	for key, outs := range cfg.Outbounds {
+		outbounds[key] = outs
+	}
+	for key, chain := range cfg.PerOutboundMiddleware {
+		outs, ok := outbounds[key]
+		if !ok {
+			panic(fmt.Sprintf("yarpc.NewDispatcher expects an outbound for the middleware of outbound key %q", key))
+		}
+		mw, err := orderChain(chain)
+		if err != nil {
+			panic(fmt.Sprintf("yarpc.NewDispatcher expects a valid middleware chain for outbound key %q: %v", key, err))
+		}
+		out := mw.outbound()
+		if outs.Unary != nil {
+			outs.Unary = middleware.ApplyUnaryOutbound(outs.Unary, out.Unary)
+		}
+		if outs.Oneway != nil {
+			outs.Oneway = middleware.ApplyOnewayOutbound(outs.Oneway, out.Oneway)
+		}
+		if outs.Stream != nil {
+			outs.Stream = middleware.ApplyStreamOutbound(outs.Stream, out.Stream)
+		}
+		outbounds[key] = outs
+		byKey[key] = names.concat(mw.names)
+	}
+	cfg.Outbounds = outbounds
+	return cfg, byKey
+}
+
+// outboundMiddlewareNames holds the names of outbound middleware by RPC type,
+// outermost first.
+type outboundMiddlewareNames struct {
+	unary  []string
+	oneway []string
+	stream []string
+}
+
+// add appends the name of the middleware to the RPC types it applies to.
+func (n *outboundMiddlewareNames) add(name string, mw interface{}) {
+	if m, ok := mw.(configMiddleware); ok {
+		if m.UnaryOutbound != nil {
+			n.unary = append(n.unary, name)
+		}
+		if m.OnewayOutbound != nil {
+			n.oneway = append(n.oneway, name)
+		}
+		if m.StreamOutbound != nil {
+			n.stream = append(n.stream, name)
+		}
+		return
+	}
+	if _, ok := mw.(middleware.UnaryOutbound); ok {
+		n.unary = append(n.unary, name)
+	}
+	if _, ok := mw.(middleware.OnewayOutbound); ok {
+		n.oneway = append(n.oneway, name)
+	}
+	if _, ok := mw.(middleware.StreamOutbound); ok {
+		n.stream = append(n.stream, name)
+	}
+}
+
+func (n outboundMiddlewareNames) concat(inner outboundMiddlewareNames) outboundMiddlewareNames {
+	return outboundMiddlewareNames{
+		unary:  append(append([]string(nil), n.unary...), inner.unary...),
+		oneway: append(append([]string(nil), n.oneway...), inner.oneway...),
+		stream: append(append([]string(nil), n.stream...), inner.stream...),
+	}
+}
+
+// orderedMiddleware holds the middleware of a chain by RPC type, in order.
+type orderedMiddleware struct {
+	unaryInbound   []middleware.UnaryInbound
+	onewayInbound  []middleware.OnewayInbound
+	streamInbound  []middleware.StreamInbound
+	unaryOutbound  []middleware.UnaryOutbound
+	onewayOutbound []middleware.OnewayOutbound
+	streamOutbound []middleware.StreamOutbound
+
+	names outboundMiddlewareNames
+}
+
+func orderChain(chain *middleware.Chain) (orderedMiddleware, error) {
+	var o orderedMiddleware
+	names, err := chain.Names()
+	if err != nil {
+		return o, err
+	}
+	ordered, err := chain.Ordered()
+	if err != nil {
+		return o, err
+	}
+
+	for i, mw := range ordered {
+		o.names.add(names[i], mw)
+		if m, ok := mw.(configMiddleware); ok {
+			o.unaryInbound = append(o.unaryInbound, m.UnaryInbound)
+			o.onewayInbound = append(o.onewayInbound, m.OnewayInbound)
+			o.streamInbound = append(o.streamInbound, m.StreamInbound)
+			o.unaryOutbound = append(o.unaryOutbound, m.UnaryOutbound)
+			o.onewayOutbound = append(o.onewayOutbound, m.OnewayOutbound)
+			o.streamOutbound = append(o.streamOutbound, m.StreamOutbound)
+			continue
+		}
+		if m, ok := mw.(middleware.UnaryInbound); ok {
+			o.unaryInbound = append(o.unaryInbound, m)
+		}
+		if m, ok := mw.(middleware.OnewayInbound); ok {
+			o.onewayInbound = append(o.onewayInbound, m)
+		}
+		if m, ok := mw.(middleware.StreamInbound); ok {
+			o.streamInbound = append(o.streamInbound, m)
+		}
+		if m, ok := mw.(middleware.UnaryOutbound); ok {
+			o.unaryOutbound = append(o.unaryOutbound, m)
+		}
+		if m, ok := mw.(middleware.OnewayOutbound); ok {
+			o.onewayOutbound = append(o.onewayOutbound, m)
+		}
+		if m, ok := mw.(middleware.StreamOutbound); ok {
+			o.streamOutbound = append(o.streamOutbound, m)
+		}
+	}
+	return o, nil
+}
+
+func (o orderedMiddleware) outbound() OutboundMiddleware {
+	return OutboundMiddleware{
+		Unary:  outboundmiddleware.UnaryChain(o.unaryOutbound...),
+		Oneway: outboundmiddleware.OnewayChain(o.onewayOutbound...),
+		Stream: outboundmiddleware.StreamChain(o.streamOutbound...),
+	}
+}
+
+// configMiddleware holds the middleware of a Config in a MiddlewareChain.
+// Each of them may be nil, so the chains take them from its fields.
+type configMiddleware struct {
+	middleware.UnaryInbound
+	middleware.OnewayInbound
+	middleware.StreamInbound
+	middleware.UnaryOutbound
+	middleware.OnewayOutbound
+	middleware.StreamOutbound
+}
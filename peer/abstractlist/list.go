@@ -31,10 +31,12 @@ import (
 
 	"go.uber.org/atomic"
 	"go.uber.org/multierr"
+	"go.uber.org/net/metrics"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/x/introspection"
 	intyarpcerrors "go.uber.org/yarpc/internal/yarpcerrors"
+	"go.uber.org/yarpc/pkg/clock"
 	"go.uber.org/yarpc/pkg/lifecycle"
 	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/zap"
@@ -80,6 +82,19 @@ type Subscriber interface {
 	UpdatePendingRequestCount(int)
 }
 
+// RequestObserver is an optional interface for Subscribers that observe the
+// outcome of every request sent to their peer, for example to score peers by
+// latency.
+//
+// The list calls ObserveRequest when it chooses the peer for a request, and
+// calls the returned function with the error of the request, if any, when the
+// request finishes.
+// Both calls are made under the list lock, after the pending request count
+// has been updated.
+type RequestObserver interface {
+	ObserveRequest() func(error)
+}
+
 type options struct {
 	capacity             int
 	defaultChooseTimeout time.Duration
@@ -87,12 +102,18 @@ type options struct {
 	failFast             bool
 	seed                 int64
 	logger               *zap.Logger
+	meter                *metrics.Scope
+	pendingTopK          int
+	drainTimeout         time.Duration
+	maxPendingRequests   int
+	clock                clock.Clock
 }
 
 var defaultOptions = options{
 	defaultChooseTimeout: 500 * time.Millisecond,
 	capacity:             10,
 	seed:                 time.Now().UnixNano(),
+	clock:                clock.System,
 }
 
 // Option customizes the behavior of a list.
@@ -121,6 +142,34 @@ func Logger(logger *zap.Logger) Option {
 	})
 }
 
+// Meter specifies the scope for peer list metrics: the numbers of available
+// and unavailable peers, the number of pending requests, the latency of
+// choosing a peer, and the number of choose calls that time out waiting for
+// an available peer.
+//
+// Metrics are registered when the list is constructed and tagged with the
+// name of the list, so lists sharing a meter should each use a distinctly
+// tagged scope.
+func Meter(meter *metrics.Scope) Option {
+	return optionFunc(func(options *options) {
+		options.meter = meter
+	})
+}
+
+// PendingRequestsTopK specifies the number of peers for which to report
+// individual pending request counts, in addition to the total.
+// The counts of the k busiest peers are reported by rank rather than by peer,
+// which keeps the number of metrics bounded regardless of the number of
+// peers.
+// Ranking costs time logarithmic in the number of peers with every request.
+//
+// Defaults to 0, reporting only the total.
+func PendingRequestsTopK(k int) Option {
+	return optionFunc(func(options *options) {
+		options.pendingTopK = k
+	})
+}
+
 // NoShuffle disables the default behavior of shuffling peer list order.
 func NoShuffle() Option {
 	return optionFunc(func(options *options) {
@@ -158,6 +207,53 @@ func DefaultChooseTimeout(timeout time.Duration) Option {
 	})
 }
 
+// DrainTimeout specifies how long the list waits for the requests in flight
+// to a removed peer to finish before releasing the peer to the transport.
+//
+// Removed peers are no longer chosen for new requests, but transports may
+// close the connections of released peers under the requests in flight. With
+// a drain timeout, the list releases a removed peer once its last pending
+// request finishes, or once the timeout elapses, whichever comes first.
+// Errors releasing drained peers are logged, since the update that removed
+// them has already returned.
+//
+// Defaults to 0, releasing removed peers immediately.
+func DrainTimeout(timeout time.Duration) Option {
+	return optionFunc(func(options *options) {
+		options.drainTimeout = timeout
+	})
+}
+
+// MaxPendingRequests limits the number of requests that may be pending to
+// each peer at once.
+//
+// Peers at their limit are set aside until one of their requests finishes, so
+// a slow peer cannot absorb an unbounded share of the requests in flight.
+// Choose waits for a peer with capacity if every available peer is at its
+// limit, and returns a ResourceExhausted error if none frees before the
+// context deadline, or immediately with FailFast.
+// An identifier that implements peer.LimitedIdentifier overrides the limit
+// for its peer.
+// Peers restored after reaching their limit are added back to the
+// implementation as though they had just become available.
+//
+// Defaults to 0, not limiting pending requests.
+func MaxPendingRequests(max int) Option {
+	return optionFunc(func(options *options) {
+		options.maxPendingRequests = max
+	})
+}
+
+// Clock specifies the clock that measures the latency of choosing peers and
+// times out draining peers, for tests that control time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) Option {
+	return optionFunc(func(options *options) {
+		options.clock = c
+	})
+}
+
 // New creates a new peer list with an identifier chooser for available peers.
 func New(name string, transport peer.Transport, implementation Implementation, opts ...Option) *List {
 	options := defaultOptions
@@ -171,17 +267,23 @@ func New(name string, transport peer.Transport, implementation Implementation, o
 	}
 
 	return &List{
-		once:               lifecycle.NewOnce(),
-		name:               name,
-		logger:             logger,
-		peers:              make(map[string]*peerFacade, options.capacity),
-		offlinePeers:       make(map[string]peer.Identifier, options.capacity),
-		implementation:     implementation,
-		transport:          transport,
-		noShuffle:          options.noShuffle,
-		failFast:           options.failFast,
-		randSrc:            rand.NewSource(options.seed),
-		peerAvailableEvent: make(chan struct{}, 1),
+		once:                 lifecycle.NewOnce(),
+		name:                 name,
+		logger:               logger,
+		peers:                make(map[string]*peerFacade, options.capacity),
+		offlinePeers:         make(map[string]peer.Identifier, options.capacity),
+		implementation:       implementation,
+		transport:            transport,
+		subscriptions:        make(map[*subscription]struct{}),
+		noShuffle:            options.noShuffle,
+		defaultChooseTimeout: options.defaultChooseTimeout,
+		failFast:             options.failFast,
+		drainTimeout:         options.drainTimeout,
+		maxPending:           options.maxPendingRequests,
+		randSrc:              rand.NewSource(options.seed),
+		clock:                options.clock,
+		peerAvailableEvent:   make(chan struct{}, 1),
+		metrics:              newListMetrics(options.meter, name, options.pendingTopK, logger),
 	}
 }
 
@@ -215,11 +317,17 @@ type List struct {
 	implementation     Implementation
 	peerAvailableEvent chan struct{}
 	transport          peer.Transport
+	subscriptions      map[*subscription]struct{}
 
 	defaultChooseTimeout time.Duration
 	noShuffle            bool
 	failFast             bool
+	drainTimeout         time.Duration
+	maxPending           int
 	randSrc              rand.Source
+	clock                clock.Clock
+
+	metrics listMetrics
 }
 
 // Name returns the name of the list.
@@ -354,7 +462,7 @@ func (pl *List) add(id peer.Identifier) error {
 		return peer.ErrPeerAddAlreadyInList(addr)
 	}
 
-	pf := &peerFacade{list: pl, id: id}
+	pf := &peerFacade{list: pl, id: id, maxPending: pl.maxPendingRequests(id)}
 	pf.onFinish = pl.onFinishFunc(pf)
 
 	// The transport must not call back before returning.
@@ -366,11 +474,27 @@ func (pl *List) add(id peer.Identifier) error {
 	pf.peer = p
 	pl.peers[addr] = pf
 	pl.numPeers.Inc()
+	pl.metrics.rankPeer()
 	pl.notifyStatusChanged(pf)
+	// notifyStatusChanged only publishes peers that are added with some status
+	// other than the initial Unavailable.
+	if pf.status.ConnectionStatus == peer.Unavailable {
+		pl.publishStatus(pf)
+	}
+	pl.recordPeers()
 
 	return nil
 }
 
+// maxPendingRequests returns the pending request limit for the identified
+// peer, preferring the limit carried by the identifier.
+func (pl *List) maxPendingRequests(id peer.Identifier) int {
+	if l, ok := id.(peer.LimitedIdentifier); ok && l.MaxPendingRequests() > 0 {
+		return l.MaxPendingRequests()
+	}
+	return pl.maxPending
+}
+
 // addOffline must be run under a list lock.
 func (pl *List) addOffline(id peer.Identifier) error {
 	addr := id.Identifier()
@@ -396,18 +520,66 @@ func (pl *List) remove(id peer.Identifier) error {
 
 	if pf.status.ConnectionStatus == peer.Available {
 		pl.numAvailable.Dec()
-		pl.implementation.Remove(pf, pf.id, pf.subscriber)
+		if !pf.atCapacity {
+			pl.implementation.Remove(pf, pf.id, pf.subscriber)
+		}
 		pf.subscriber = nil
 	}
 	pf.status.ConnectionStatus = peer.Unavailable
 
 	pl.numPeers.Dec()
 	delete(pl.peers, addr)
+	pf.removed = true
+	pl.publishStatus(pf)
+	pl.recordPeers()
+	pl.metrics.unrankPeer(pf.status.PendingRequestCount)
+
+	if pl.drainTimeout > 0 && pf.status.PendingRequestCount > 0 {
+		pl.drain(pf)
+		return nil
+	}
 
 	// The transport must not call back before returning.
 	return pl.transport.ReleasePeer(id, pf)
 }
 
+// drain defers releasing a removed peer until its pending requests finish or
+// the drain timeout elapses.
+//
+// drain must be run under a list lock.
+func (pl *List) drain(pf *peerFacade) {
+	pf.draining = true
+	pf.drainTimer = pl.clock.AfterFunc(pl.drainTimeout, func() {
+		pl.lock.Lock()
+		defer pl.lock.Unlock()
+
+		if pf.draining {
+			pl.metrics.drainTimeouts.Inc()
+			pl.logger.Warn("timed out draining removed peer, releasing it with pending requests",
+				zap.String("peer", pf.id.Identifier()),
+				zap.Int("pendingRequests", pf.status.PendingRequestCount))
+			pl.releaseDrained(pf)
+		}
+	})
+	pl.metrics.drainingPeers.Inc()
+}
+
+// releaseDrained releases a draining peer to the transport.
+//
+// releaseDrained must be run under a list lock.
+func (pl *List) releaseDrained(pf *peerFacade) {
+	pf.draining = false
+	pf.drainTimer.Stop()
+	pl.metrics.drainingPeers.Dec()
+
+	// The transport must not call back before returning.
+	if err := pl.transport.ReleasePeer(pf.id, pf); err != nil {
+		pl.logger.Error("failed to release drained peer",
+			zap.String("peer", pf.id.Identifier()),
+			zap.Error(err))
+	}
+}
+
 func (pl *List) removeOffline(id peer.Identifier) error {
 	addr := id.Identifier()
 
@@ -423,6 +595,11 @@ func (pl *List) removeOffline(id peer.Identifier) error {
 
 // Choose selects the next available peer in the peer list.
 func (pl *List) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	start := pl.clock.Now()
+	defer func() {
+		pl.metrics.chooseLatency.Observe(pl.clock.Now().Sub(start))
+	}()
+
 	if _, ok := ctx.Deadline(); !ok {
 		// set the default timeout on the chooser so that we do not wait
 		// indefinitely for a peer to become available
@@ -438,16 +615,22 @@ func (pl *List) Choose(ctx context.Context, req *transport.Request) (peer.Peer,
 		return nil, nil, intyarpcerrors.AnnotateWithInfo(yarpcerrors.FromError(err), "%q peer list is not running", pl.name)
 	}
 
+	if id, ok := peer.PinnedPeerFromContext(ctx); ok {
+		return pl.choosePinned(id)
+	}
+
 	// Choose runs without a lock because it spends the bulk of its time in a
 	// wait loop.
+	distinct := peer.DistinctPeersFromContext(ctx)
+	var waitedForCapacity bool
 	for {
-		p := pl.choose(req)
+		pf, onFinish, exhausted := pl.choose(req, distinct)
 		// choose signals that there are no available peers by returning nil.
 		// Thereafter, every Choose call will wait for a peer or peers to
 		// become available again.
 		// We reach for an available peer optimistically, resorting to waiting
 		// for a notification only if the underlying list is empty.
-		if p != nil {
+		if pf != nil {
 			// We call notifyPeerAvailable because there is a chance that more
 			// than one chooser is blocked in waitForPeerAddedEvent.
 			// Once a peer becomes available, all of these goroutines should
@@ -455,14 +638,22 @@ func (pl *List) Choose(ctx context.Context, req *transport.Request) (peer.Peer,
 			// The underlying channel has a limited capacity, so every success
 			// must trigger the rest to resume.
 			pl.notifyPeerAvailable()
-			pf := p.(*peerFacade)
-			pl.onStart(pf)
-			return pf.peer, pf.onFinish, nil
+			return pf.peer, onFinish, nil
+		}
+		if exhausted && !waitedForCapacity {
+			waitedForCapacity = true
+			pl.metrics.capacityWaits.Inc()
 		}
 		if pl.failFast {
+			if exhausted {
+				return nil, nil, pl.newResourceExhaustedError(nil)
+			}
 			return nil, nil, pl.newUnavailableError(nil)
 		}
 		if err := pl.waitForPeerAddedEvent(ctx); err != nil {
+			if exhausted {
+				return nil, nil, pl.newResourceExhaustedError(ctx.Err())
+			}
 			return nil, nil, err
 		}
 	}
@@ -470,7 +661,16 @@ func (pl *List) Choose(ctx context.Context, req *transport.Request) (peer.Peer,
 
 // choose guards the underlying implementation's consistency around a lock, and
 // recovers the lock if the underlying list panics.
-func (pl *List) choose(req *transport.Request) peer.StatusPeer {
+//
+// choose starts the request to the chosen peer under the same lock, so that
+// concurrent calls cannot exceed the pending request limit of the peer.
+// If there is no peer to choose, choose reports whether some peers are
+// available but all of them are at their limit.
+//
+// If the request asks for distinct peers, choose asks the implementation
+// again, up to once per available peer, while it chooses a peer that was
+// already chosen, settling for the last peer it chooses.
+func (pl *List) choose(req *transport.Request, distinct *peer.DistinctPeers) (_ *peerFacade, onFinish func(error), exhausted bool) {
 	// Even if all of the implementation provided by yarpc
 	// implements their own locking system - since v1.50.0
 	// this lock is needed for supporting potential
@@ -478,27 +678,111 @@ func (pl *List) choose(req *transport.Request) peer.StatusPeer {
 	pl.lock.Lock()
 	defer pl.lock.Unlock()
 
-	return pl.implementation.Choose(req)
+	p := pl.implementation.Choose(req)
+	if p == nil {
+		// Available peers are absent from the implementation only while
+		// they are at their limit.
+		return nil, nil, pl.numAvailable.Load() > 0
+	}
+	for i := int32(1); i < pl.numAvailable.Load() && distinct.Chosen(p); i++ {
+		next := pl.implementation.Choose(req)
+		if next == nil {
+			break
+		}
+		p = next
+	}
+	pf := p.(*peerFacade)
+	distinct.Choose(pf)
+	return pf, pl.onStart(pf), false
 }
 
-func (pl *List) onStart(pf *peerFacade) {
+// choosePinned returns the peer that the context pins the request to,
+// without consulting the implementation.
+func (pl *List) choosePinned(id string) (peer.Peer, func(error), error) {
 	pl.lock.Lock()
 	defer pl.lock.Unlock()
 
+	pf, ok := pl.peers[id]
+	if !ok {
+		return nil, nil, yarpcerrors.NotFoundErrorf("%q peer list does not contain peer %q", pl.name, id)
+	}
+	if pf.status.ConnectionStatus != peer.Available {
+		return nil, nil, yarpcerrors.UnavailableErrorf("%q peer list has peer %q but it is not available", pl.name, id)
+	}
+	if pf.atCapacity {
+		return nil, nil, yarpcerrors.ResourceExhaustedErrorf("%q peer list has peer %q but it is at its pending request limit", pl.name, id)
+	}
+	return pf.peer, pl.onStart(pf), nil
+}
+
+// onStart records the start of a request to the peer and returns the
+// function that finishes it.
+//
+// onStart must be called under the list lock.
+func (pl *List) onStart(pf *peerFacade) func(error) {
 	pf.status.PendingRequestCount++
 	if pf.subscriber != nil {
 		pf.subscriber.UpdatePendingRequestCount(pf.status.PendingRequestCount)
 	}
+	pl.metrics.pending.Inc()
+	if !pf.removed {
+		pl.metrics.startRanked(pf.status.PendingRequestCount - 1)
+	}
+
+	onFinish := pf.onFinish
+	if observer, ok := pf.subscriber.(RequestObserver); ok {
+		observe := observer.ObserveRequest()
+		onFinish = func(err error) {
+			pl.onFinishObserved(pf, observe, err)
+		}
+	}
+
+	if pf.maxPending > 0 && pf.status.PendingRequestCount >= pf.maxPending {
+		pf.atCapacity = true
+		pl.implementation.Remove(pf, pf.id, pf.subscriber)
+		pf.subscriber = nil
+	}
+	return onFinish
 }
 
 func (pl *List) onFinish(pf *peerFacade, err error) {
 	pl.lock.Lock()
 	defer pl.lock.Unlock()
 
+	pl.finishRequest(pf)
+}
+
+func (pl *List) onFinishObserved(pf *peerFacade, observe func(error), err error) {
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+
+	pl.finishRequest(pf)
+	observe(err)
+}
+
+// finishRequest must be called under the list lock.
+func (pl *List) finishRequest(pf *peerFacade) {
 	pf.status.PendingRequestCount--
 	if pf.subscriber != nil {
 		pf.subscriber.UpdatePendingRequestCount(pf.status.PendingRequestCount)
 	}
+	pl.metrics.pending.Dec()
+	if !pf.removed {
+		pl.metrics.finishRanked(pf.status.PendingRequestCount + 1)
+	}
+
+	if pf.atCapacity && pf.status.PendingRequestCount < pf.maxPending {
+		pf.atCapacity = false
+		// Peers that were removed or became unavailable while at their limit
+		// are not restored.
+		if pf.status.ConnectionStatus == peer.Available {
+			pl.addToImplementation(pf)
+		}
+	}
+
+	if pf.draining && pf.status.PendingRequestCount == 0 {
+		pl.releaseDrained(pf)
+	}
 }
 
 func (pl *List) onFinishFunc(pf *peerFacade) func(error) {
@@ -536,7 +820,8 @@ func (pl *List) status(pf *peerFacade) peer.Status {
 
 // notifyStatusChanged must be run under a list lock.
 func (pl *List) notifyStatusChanged(pf *peerFacade) {
-	if pf == nil {
+	// Draining peers have been removed and must not become available again.
+	if pf == nil || pf.draining {
 		return
 	}
 
@@ -545,18 +830,75 @@ func (pl *List) notifyStatusChanged(pf *peerFacade) {
 		pf.status.ConnectionStatus = status
 		switch status {
 		case peer.Available:
-			sub := pf.list.implementation.Add(pf, pf.id)
-			pf.subscriber = sub
 			pl.numAvailable.Inc()
-			pf.list.notifyPeerAvailable()
+			// Peers at their limit are restored when a request finishes.
+			if !pf.atCapacity {
+				pl.addToImplementation(pf)
+			}
 		default:
 			pl.numAvailable.Dec()
-			pf.list.implementation.Remove(pf, pf.id, pf.subscriber)
+			if !pf.atCapacity {
+				pf.list.implementation.Remove(pf, pf.id, pf.subscriber)
+			}
 			pf.subscriber = nil
 		}
+		pl.publishStatus(pf)
+		pl.recordPeers()
 	}
 }
 
+// Subscribe registers a function to call with the identifier and connection
+// status of each peer that is added to the list, removed from the list, or
+// changes status while in the list.
+// Removed peers are reported as Unavailable, as are all peers when the list
+// stops.
+// The subscriber first receives the status of every peer already in the list.
+// The returned function cancels the subscription.
+//
+// Updates are delivered from a separate goroutine, in order for each peer,
+// and never while the list holds its lock.
+// Updates for a peer that arrive while the subscriber is busy are coalesced
+// into the latest status of that peer, so a slow subscriber falls behind
+// without blocking the list.
+func (pl *List) Subscribe(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func()) {
+	s := newSubscription(fn)
+
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+
+	for _, pf := range pl.peers {
+		s.notify(pf.id, pf.status.ConnectionStatus)
+	}
+	pl.subscriptions[s] = struct{}{}
+
+	return func() {
+		pl.lock.Lock()
+		delete(pl.subscriptions, s)
+		pl.lock.Unlock()
+		s.stop()
+	}
+}
+
+// publishStatus notifies subscribers of the current status of a peer.
+//
+// publishStatus must be run under a list lock.
+func (pl *List) publishStatus(pf *peerFacade) {
+	for s := range pl.subscriptions {
+		s.notify(pf.id, pf.status.ConnectionStatus)
+	}
+}
+
+// addToImplementation makes an available peer a candidate for Choose.
+//
+// addToImplementation must be run under a list lock.
+func (pl *List) addToImplementation(pf *peerFacade) {
+	pf.subscriber = pl.implementation.Add(pf, pf.id)
+	if pf.subscriber != nil && pf.status.PendingRequestCount > 0 {
+		pf.subscriber.UpdatePendingRequestCount(pf.status.PendingRequestCount)
+	}
+	pl.notifyPeerAvailable()
+}
+
 // notifyPeerAvailable writes to a channel indicating that a Peer is currently
 // available for requests.
 //
@@ -577,6 +919,7 @@ func (pl *List) waitForPeerAddedEvent(ctx context.Context) error {
 	case <-pl.peerAvailableEvent:
 		return nil
 	case <-ctx.Done():
+		pl.metrics.chooseTimeouts.Inc()
 		return pl.newUnavailableError(ctx.Err())
 	}
 }
@@ -585,6 +928,23 @@ func (pl *List) newUnavailableError(err error) error {
 	return yarpcerrors.Newf(yarpcerrors.CodeUnavailable, "%q peer list %s", pl.name, pl.unavailableErrorMessage(err))
 }
 
+func (pl *List) newResourceExhaustedError(err error) error {
+	return yarpcerrors.Newf(yarpcerrors.CodeResourceExhausted, "%q peer list %s", pl.name, pl.resourceExhaustedErrorMessage(err))
+}
+
+func (pl *List) resourceExhaustedErrorMessage(err error) string {
+	var msg string
+	if num := int(pl.numAvailable.Load()); num == 1 {
+		msg = "has 1 available peer but it is at its pending request limit, "
+	} else {
+		msg = "has " + strconv.Itoa(num) + " available peers but all are at their pending request limit, "
+	}
+	if pl.failFast {
+		return msg + "did not wait for a request to finish (fail-fast is enabled)"
+	}
+	return msg + "timed out waiting for a request to finish (fail-fast is not enabled): " + err.Error()
+}
+
 func (pl *List) unavailableErrorMessage(err error) string {
 	num := int(pl.numPeers.Load())
 	if num == 0 {
@@ -655,6 +1015,20 @@ func (pl *List) Uninitialized(pid peer.Identifier) bool {
 	return exists
 }
 
+// Contains returns whether the identified peer has been added to the list,
+// regardless of its availability or whether the list is running.
+func (pl *List) Contains(pid peer.Identifier) bool {
+	pl.lock.RLock()
+	defer pl.lock.RUnlock()
+
+	addr := pid.Identifier()
+	if _, ok := pl.peers[addr]; ok {
+		return true
+	}
+	_, ok := pl.offlinePeers[addr]
+	return ok
+}
+
 // Peers returns a snapshot of all retained (available and unavailable) peers.
 func (pl *List) Peers() []peer.StatusPeer {
 	pl.lock.RLock()
@@ -678,7 +1052,7 @@ func (pl *List) onlinePeerIdentifiers() []peer.Identifier {
 
 	ids := make([]peer.Identifier, len(addrs))
 	for i, addr := range addrs {
-		ids[i] = pl.peers[addr].peer
+		ids[i] = pl.peers[addr].id
 	}
 	return ids
 }
@@ -740,6 +1114,17 @@ func (pl *List) Introspect() introspection.ChooserStatus {
 	}
 }
 
+// IntrospectConfig returns the resolved options of the list, keyed as in
+// YARPC configuration, for peer lists to report as the Config of their
+// introspection status.
+func (pl *List) IntrospectConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"failFast":             pl.failFast,
+		"defaultChooseTimeout": pl.defaultChooseTimeout.String(),
+		"maxPendingRequests":   pl.maxPending,
+	}
+}
+
 // shuffle randomizes the order of a slice of peers.
 // see: https://en.wikipedia.org/wiki/Fisher-Yates_shuffle
 func shuffle(src rand.Source, in []peer.Identifier) []peer.Identifier {
@@ -28,12 +28,15 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/internal/testtime"
+	yarpcpeer "go.uber.org/yarpc/peer"
 	"go.uber.org/yarpc/peer/abstractpeer"
 	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/yarpc/yarpctest"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest/observer"
@@ -179,6 +182,8 @@ func TestPeerList(t *testing.T) {
 	assert.Equal(t, 2, list.NumUninitialized())
 	assert.False(t, list.Available(abstractpeer.Identify("2.2.2.2:4040")))
 	assert.True(t, list.Uninitialized(abstractpeer.Identify("2.2.2.2:4040")))
+	assert.True(t, list.Contains(abstractpeer.Identify("2.2.2.2:4040")))
+	assert.False(t, list.Contains(abstractpeer.Identify("3.3.3.3:4040")))
 
 	require.NoError(t, list.Start())
 
@@ -189,6 +194,8 @@ func TestPeerList(t *testing.T) {
 	assert.Equal(t, 0, list.NumUninitialized())
 	assert.True(t, list.Available(abstractpeer.Identify("2.2.2.2:4040")))
 	assert.False(t, list.Uninitialized(abstractpeer.Identify("2.2.2.2:4040")))
+	assert.True(t, list.Contains(abstractpeer.Identify("2.2.2.2:4040")))
+	assert.True(t, list.Contains(abstractpeer.Identify("1.1.1.1:4040")))
 	peers = list.Peers()
 	assert.Len(t, peers, 2)
 	p, onFinish, err := list.Choose(ctx, &transport.Request{})
@@ -374,6 +381,23 @@ func TestIntrospect(t *testing.T) {
 	}, list.Introspect())
 }
 
+func TestIntrospectConfig(t *testing.T) {
+	fake := yarpctest.NewFakeTransport()
+
+	assert.Equal(t, map[string]interface{}{
+		"failFast":             false,
+		"defaultChooseTimeout": "500ms",
+		"maxPendingRequests":   0,
+	}, New("mra", fake, &mraList{}).IntrospectConfig(), "expected the defaults")
+
+	list := New("mra", fake, &mraList{}, FailFast(), DefaultChooseTimeout(time.Second), MaxPendingRequests(10))
+	assert.Equal(t, map[string]interface{}{
+		"failFast":             true,
+		"defaultChooseTimeout": "1s",
+		"maxPendingRequests":   10,
+	}, list.IntrospectConfig())
+}
+
 func TestWaitForNeverStarted(t *testing.T) {
 	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Unavailable))
 	impl := &mraList{}
@@ -406,3 +430,441 @@ func TestDefaultChooseTimeout(t *testing.T) {
 	_, _, err = list.Choose(ctx, req)
 	assert.NoError(t, err, "expected to choose peer without context deadline")
 }
+
+func TestDefaultChooseTimeoutWaitsForPeer(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Unavailable))
+	list := New("mra", fake, &mraList{}, DefaultChooseTimeout(testtime.Second))
+	require.NoError(t, list.Start(), "peer list failed to start")
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{
+		abstractpeer.Identify("0"),
+	}}))
+
+	go func() {
+		time.Sleep(testtime.Millisecond * 20)
+		fake.SimulateConnect(abstractpeer.Identify("0"))
+	}()
+
+	// Without a deadline, Choose waits up to the default timeout for the
+	// peer to become available.
+	p, onFinish, err := list.Choose(context.Background(), &transport.Request{})
+	require.NoError(t, err, "expected to wait for a peer without context deadline")
+	onFinish(nil)
+	assert.Equal(t, "0", p.Identifier())
+}
+
+func TestDefaultChooseTimeoutExpires(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Unavailable))
+	list := New("mra", fake, &mraList{}, DefaultChooseTimeout(timeout))
+	require.NoError(t, list.Start(), "peer list failed to start")
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{
+		abstractpeer.Identify("0"),
+	}}))
+
+	start := time.Now()
+	_, _, err := list.Choose(context.Background(), &transport.Request{})
+	require.Error(t, err)
+	assert.True(t, time.Since(start) >= timeout, "expected to wait for the default timeout, waited %v", time.Since(start))
+}
+
+func TestChooseWithoutDeadlineWaitsForDefaultTimeout(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Unavailable))
+	list := New("mra", fake, &mraList{})
+	require.NoError(t, list.Start(), "peer list failed to start")
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{
+		abstractpeer.Identify("0"),
+	}}))
+
+	// Without a deadline or the DefaultChooseTimeout option, Choose waits
+	// 500ms for a peer before failing.
+	start := time.Now()
+	_, _, err := list.Choose(context.Background(), &transport.Request{})
+	require.Error(t, err)
+	assert.True(t, time.Since(start) >= 500*time.Millisecond, "expected to wait 500ms, waited %v", time.Since(start))
+}
+
+// releaseRecorder reports the peers released to a fake transport.
+type releaseRecorder struct {
+	*yarpctest.FakeTransport
+
+	released chan string
+}
+
+func newReleaseRecorder() *releaseRecorder {
+	return &releaseRecorder{
+		FakeTransport: yarpctest.NewFakeTransport(),
+		released:      make(chan string, 10),
+	}
+}
+
+func (t *releaseRecorder) ReleasePeer(id peer.Identifier, ps peer.Subscriber) error {
+	err := t.FakeTransport.ReleasePeer(id, ps)
+	t.released <- id.Identifier()
+	return err
+}
+
+func (t *releaseRecorder) assertNotReleased(tb testing.TB) {
+	select {
+	case id := <-t.released:
+		tb.Errorf("unexpected release of peer %q", id)
+	default:
+	}
+}
+
+func TestDrainOnRemove(t *testing.T) {
+	root := metrics.New()
+	trans := newReleaseRecorder()
+	list := New("mra", trans, &mraList{}, DrainTimeout(testtime.Second), FailFast(), Meter(root.Scope()))
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	// A slow request is in flight while the peer is removed.
+	_, onFinish, err := list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	require.NoError(t, list.Update(peer.ListUpdates{Removals: []peer.Identifier{id1}}))
+
+	trans.assertNotReleased(t)
+	assert.Equal(t, int64(1), gauges(root)["peer_list_draining_peers"])
+	assert.Equal(t, int64(0), gauges(root)["peer_list_peers"])
+	_, _, err = list.Choose(ctx, &transport.Request{})
+	assert.Error(t, err, "draining peers must not be chosen")
+
+	// Connection status changes do not restore draining peers.
+	trans.SimulateDisconnect(id1)
+	trans.SimulateConnect(id1)
+	trans.Flush()
+	_, _, err = list.Choose(ctx, &transport.Request{})
+	assert.Error(t, err, "draining peers must not be chosen")
+	trans.assertNotReleased(t)
+
+	// The peer is released once the request finishes.
+	onFinish(nil)
+	select {
+	case id := <-trans.released:
+		assert.Equal(t, id1.Identifier(), id)
+	default:
+		t.Fatal("expected the drained peer to be released")
+	}
+	assert.Equal(t, int64(0), gauges(root)["peer_list_draining_peers"])
+	assert.Equal(t, int64(0), counters(root)["peer_list_drain_timeouts"])
+}
+
+func TestDrainTimeout(t *testing.T) {
+	root := metrics.New()
+	core, logs := observer.New(zap.WarnLevel)
+	trans := newReleaseRecorder()
+	clock := yarpctest.NewFakeClock()
+	list := New("mra", trans, &mraList{},
+		DrainTimeout(time.Second),
+		Clock(clock),
+		Meter(root.Scope()),
+		Logger(zap.New(core)))
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	_, onFinish, err := list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	require.NoError(t, list.Update(peer.ListUpdates{Removals: []peer.Identifier{id1}}))
+
+	clock.Add(time.Second - time.Millisecond)
+	trans.assertNotReleased(t)
+
+	clock.Add(time.Millisecond)
+	select {
+	case id := <-trans.released:
+		assert.Equal(t, id1.Identifier(), id)
+	default:
+		t.Fatal("expected the peer to be released after the drain timeout")
+	}
+	assert.Equal(t, int64(0), gauges(root)["peer_list_draining_peers"])
+	assert.Equal(t, int64(1), counters(root)["peer_list_drain_timeouts"])
+	assert.Equal(t, 1, logs.FilterMessage("timed out draining removed peer, releasing it with pending requests").Len())
+
+	// Finishing the request afterward does not release the peer again.
+	onFinish(nil)
+	trans.assertNotReleased(t)
+	assert.Equal(t, int64(0), gauges(root)["peer_list_pending_requests"])
+}
+
+func TestDrainWithoutPendingRequests(t *testing.T) {
+	trans := newReleaseRecorder()
+	list := New("mra", trans, &mraList{}, DrainTimeout(testtime.Second))
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	_, onFinish, err := list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	onFinish(nil)
+
+	// Peers without requests in flight are released immediately.
+	require.NoError(t, list.Update(peer.ListUpdates{Removals: []peer.Identifier{id1}}))
+	select {
+	case id := <-trans.released:
+		assert.Equal(t, id1.Identifier(), id)
+	default:
+		t.Fatal("expected the peer to be released immediately")
+	}
+}
+
+func TestMaxPendingRequests(t *testing.T) {
+	root := metrics.New()
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := New("mra", fake, &mraList{}, MaxPendingRequests(2), Meter(root.Scope()))
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	_, onFinish1, err := list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	_, onFinish2, err := list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+
+	// The peer is at its limit, so the next choice waits for a request to
+	// finish.
+	chosen := make(chan error, 1)
+	go func() {
+		_, onFinish, err := list.Choose(ctx, &transport.Request{})
+		if err == nil {
+			onFinish(nil)
+		}
+		chosen <- err
+	}()
+	select {
+	case err := <-chosen:
+		t.Fatalf("expected choose to wait for capacity, got %v", err)
+	case <-time.After(10 * testtime.Millisecond):
+	}
+
+	onFinish1(nil)
+	select {
+	case err := <-chosen:
+		require.NoError(t, err)
+	case <-time.After(testtime.Second):
+		t.Fatal("expected choose to resume once a request finished")
+	}
+	onFinish2(nil)
+
+	assert.Equal(t, int64(1), counters(root)["peer_list_choose_capacity_waits"])
+	assert.Equal(t, int64(0), gauges(root)["peer_list_pending_requests"])
+	assert.True(t, list.Available(id1), "peers at their limit remain available")
+}
+
+func TestMaxPendingRequestsExhausted(t *testing.T) {
+	root := metrics.New()
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := New("mra", fake, &mraList{}, MaxPendingRequests(1), Meter(root.Scope()))
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+
+	_, onFinish, err := list.Choose(context.Background(), &transport.Request{})
+	require.NoError(t, err)
+	defer onFinish(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*testtime.Millisecond)
+	defer cancel()
+	_, _, err = list.Choose(ctx, &transport.Request{})
+	require.Error(t, err)
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+	assert.Contains(t, err.Error(), `"mra" peer list has 1 available peer but it is at its pending request limit, timed out waiting for a request to finish`)
+	assert.Equal(t, int64(1), counters(root)["peer_list_choose_capacity_waits"])
+	assert.Equal(t, int64(1), counters(root)["peer_list_choose_timeouts"])
+}
+
+func TestMaxPendingRequestsFailFast(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := New("mra", fake, &mraList{}, MaxPendingRequests(1), FailFast())
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	_, onFinish, err := list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	_, _, err = list.Choose(ctx, &transport.Request{})
+	require.Error(t, err)
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+	assert.Contains(t, err.Error(), "did not wait for a request to finish (fail-fast is enabled)")
+
+	onFinish(nil)
+	_, onFinish, err = list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err, "expected the peer to be restored once its request finished")
+	onFinish(nil)
+}
+
+func TestMaxPendingRequestsPerPeer(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := New("mra", fake, &mraList{}, MaxPendingRequests(1), FailFast())
+	require.NoError(t, list.Start())
+	defer list.Stop()
+
+	// The limit of the identifier overrides the limit of the list.
+	id := yarpcpeer.IdentifyMaxPendingRequests(yarpcpeer.IdentifyWeight(id1, 2), 3)
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		_, onFinish, err := list.Choose(ctx, &transport.Request{})
+		require.NoError(t, err, "request %d", i)
+		defer onFinish(nil)
+	}
+	_, _, err := list.Choose(ctx, &transport.Request{})
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+}
+
+func TestMaxPendingRequestsUnavailableAtLimit(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := New("mra", fake, &mraList{}, MaxPendingRequests(1), FailFast())
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	_, onFinish, err := list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+
+	// A peer that disconnects at its limit is not restored when its request
+	// finishes, but is once it reconnects.
+	fake.SimulateDisconnect(id1)
+	onFinish(nil)
+	_, _, err = list.Choose(ctx, &transport.Request{})
+	assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code())
+
+	fake.SimulateConnect(id1)
+	_, onFinish, err = list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	onFinish(nil)
+}
+
+// sequenceList chooses peers in the given order of identifiers.
+type sequenceList struct {
+	mraList
+
+	peers map[string]peer.StatusPeer
+	order []string
+	next  int
+}
+
+func (l *sequenceList) Add(p peer.StatusPeer, pid peer.Identifier) Subscriber {
+	l.peers[pid.Identifier()] = p
+	return &mraSub{}
+}
+
+func (l *sequenceList) Choose(req *transport.Request) peer.StatusPeer {
+	p := l.peers[l.order[l.next%len(l.order)]]
+	l.next++
+	return p
+}
+
+func TestDistinctPeers(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	impl := &sequenceList{
+		peers: make(map[string]peer.StatusPeer),
+		order: []string{id1.Identifier(), id1.Identifier(), id2.Identifier()},
+	}
+	list := New("sequence", fake, impl)
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1, id2}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	ctx = peer.WithDistinctPeers(ctx)
+
+	p, onFinish, err := list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	onFinish(nil)
+	assert.Equal(t, id1.Identifier(), p.Identifier())
+
+	p, onFinish, err = list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	onFinish(nil)
+	assert.Equal(t, id2.Identifier(), p.Identifier(), "expected a peer that was not chosen yet")
+
+	// Once every peer was chosen, the list settles for the last peer it
+	// chooses.
+	p, onFinish, err = list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	onFinish(nil)
+	assert.Equal(t, id1.Identifier(), p.Identifier())
+}
+
+// countingList counts the calls to Choose.
+type countingList struct {
+	mraList
+
+	chooses int
+}
+
+func (l *countingList) Choose(req *transport.Request) peer.StatusPeer {
+	l.chooses++
+	return l.mraList.Choose(req)
+}
+
+func TestPinnedPeer(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	impl := &countingList{}
+	list := New("counting", fake, impl, MaxPendingRequests(1))
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1, id2}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	t.Run("chosen without the implementation", func(t *testing.T) {
+		p, onFinish, err := list.Choose(peer.WithPinnedPeer(ctx, id1.Identifier()), &transport.Request{})
+		require.NoError(t, err)
+		assert.Equal(t, id1.Identifier(), p.Identifier())
+		onFinish(nil)
+		assert.Equal(t, 0, impl.chooses, "expected the implementation not to be consulted")
+	})
+
+	t.Run("not in the list", func(t *testing.T) {
+		_, _, err := list.Choose(peer.WithPinnedPeer(ctx, id3.Identifier()), &transport.Request{})
+		require.Error(t, err)
+		assert.True(t, yarpcerrors.IsNotFound(err), "expected NotFound, got %v", err)
+		assert.Equal(t, 0, impl.chooses, "expected the implementation not to be consulted")
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		fake.SimulateDisconnect(id2)
+		defer fake.SimulateConnect(id2)
+
+		_, _, err := list.Choose(peer.WithPinnedPeer(ctx, id2.Identifier()), &transport.Request{})
+		assert.True(t, yarpcerrors.IsUnavailable(err), "expected Unavailable, got %v", err)
+	})
+
+	t.Run("at its limit", func(t *testing.T) {
+		pinned := peer.WithPinnedPeer(ctx, id1.Identifier())
+		_, onFinish, err := list.Choose(pinned, &transport.Request{})
+		require.NoError(t, err)
+		defer onFinish(nil)
+
+		_, _, err = list.Choose(pinned, &transport.Request{})
+		assert.True(t, yarpcerrors.IsResourceExhausted(err), "expected ResourceExhausted, got %v", err)
+	})
+}
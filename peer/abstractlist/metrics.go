@@ -0,0 +1,261 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package abstractlist
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/net/metrics/bucket"
+	"go.uber.org/zap"
+)
+
+var _chooseBucketsMs = bucket.NewRPCLatency()
+
+type listMetrics struct {
+	peers          *metrics.Gauge
+	available      *metrics.Gauge
+	unavailable    *metrics.Gauge
+	pending        *metrics.Gauge
+	chooseLatency  *metrics.Histogram
+	chooseTimeouts *metrics.Counter
+	capacityWaits  *metrics.Counter
+	drainingPeers  *metrics.Gauge
+	drainTimeouts  *metrics.Counter
+	// topPending holds the pending request counts of the busiest peers, by
+	// rank, so that cardinality is bounded regardless of the number of peers.
+	topPending []*metrics.Gauge
+	// ranks holds the pending request counts of all retained peers in
+	// descending order, if topPending is enabled.
+	// Each request moves one count by one, which changes a single rank, so
+	// ranks is kept in order with a binary search instead of sorting the
+	// peers with every request.
+	ranks []int
+}
+
+func newListMetrics(meter *metrics.Scope, name string, topK int, logger *zap.Logger) listMetrics {
+	tags := metrics.Tags{
+		"component": "yarpc",
+		"peer_list": name,
+	}
+
+	peers, err := meter.Gauge(metrics.Spec{
+		Name:      "peer_list_peers",
+		Help:      "Number of peers retained by the peer list.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create peer list peers gauge", zap.Error(err))
+	}
+
+	available, err := meter.Gauge(metrics.Spec{
+		Name:      "peer_list_available_peers",
+		Help:      "Number of retained peers available for requests.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create peer list available peers gauge", zap.Error(err))
+	}
+
+	unavailable, err := meter.Gauge(metrics.Spec{
+		Name:      "peer_list_unavailable_peers",
+		Help:      "Number of retained peers unavailable for requests.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create peer list unavailable peers gauge", zap.Error(err))
+	}
+
+	pending, err := meter.Gauge(metrics.Spec{
+		Name:      "peer_list_pending_requests",
+		Help:      "Number of requests in flight to peers chosen by the peer list.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create peer list pending requests gauge", zap.Error(err))
+	}
+
+	chooseLatency, err := meter.Histogram(metrics.HistogramSpec{
+		Spec: metrics.Spec{
+			Name:      "peer_list_choose_latency_ms",
+			Help:      "Latency distribution of choosing a peer, including waiting for an available peer.",
+			ConstTags: tags,
+		},
+		Unit:    time.Millisecond,
+		Buckets: _chooseBucketsMs,
+	})
+	if err != nil {
+		logger.Error("failed to create peer list choose latency distribution", zap.Error(err))
+	}
+
+	chooseTimeouts, err := meter.Counter(metrics.Spec{
+		Name:      "peer_list_choose_timeouts",
+		Help:      "Total number of choose calls that timed out waiting for an available peer.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create peer list choose timeouts counter", zap.Error(err))
+	}
+
+	capacityWaits, err := meter.Counter(metrics.Spec{
+		Name:      "peer_list_choose_capacity_waits",
+		Help:      "Total number of choose calls that blocked because every available peer was at its pending request limit.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create peer list choose capacity waits counter", zap.Error(err))
+	}
+
+	drainingPeers, err := meter.Gauge(metrics.Spec{
+		Name:      "peer_list_draining_peers",
+		Help:      "Number of removed peers waiting for their pending requests to finish before release.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create peer list draining peers gauge", zap.Error(err))
+	}
+
+	drainTimeouts, err := meter.Counter(metrics.Spec{
+		Name:      "peer_list_drain_timeouts",
+		Help:      "Total number of removed peers released with pending requests after the drain timeout.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create peer list drain timeouts counter", zap.Error(err))
+	}
+
+	var topPending []*metrics.Gauge
+	if topK > 0 {
+		vector, err := meter.GaugeVector(metrics.Spec{
+			Name:      "peer_list_top_pending_requests",
+			Help:      "Pending request counts of the busiest peers, by rank.",
+			ConstTags: tags,
+			VarTags:   []string{"rank"},
+		})
+		if err != nil {
+			logger.Error("failed to create peer list top pending requests gauges", zap.Error(err))
+		}
+		if vector != nil {
+			topPending = make([]*metrics.Gauge, topK)
+			for i := range topPending {
+				topPending[i] = vector.MustGet("rank", strconv.Itoa(i+1))
+			}
+		}
+	}
+
+	return listMetrics{
+		peers:          peers,
+		available:      available,
+		unavailable:    unavailable,
+		pending:        pending,
+		chooseLatency:  chooseLatency,
+		chooseTimeouts: chooseTimeouts,
+		capacityWaits:  capacityWaits,
+		drainingPeers:  drainingPeers,
+		drainTimeouts:  drainTimeouts,
+		topPending:     topPending,
+	}
+}
+
+// recordPeers must be called under the list lock.
+func (pl *List) recordPeers() {
+	peers := int64(pl.numPeers.Load())
+	available := int64(pl.numAvailable.Load())
+	pl.metrics.peers.Store(peers)
+	pl.metrics.available.Store(available)
+	pl.metrics.unavailable.Store(peers - available)
+}
+
+// rankPeer starts ranking a newly retained peer, which has no pending
+// requests.
+//
+// rankPeer must be called under the list lock.
+func (m *listMetrics) rankPeer() {
+	if len(m.topPending) == 0 {
+		return
+	}
+	// Zero is the lowest count, so the counts stay in order and the ranks
+	// above are unchanged.
+	m.ranks = append(m.ranks, 0)
+}
+
+// unrankPeer stops ranking a removed peer with n pending requests.
+//
+// unrankPeer must be called under the list lock.
+func (m *listMetrics) unrankPeer(n int) {
+	if len(m.topPending) == 0 {
+		return
+	}
+	i := m.lastRank(n)
+	m.ranks = append(m.ranks[:i], m.ranks[i+1:]...)
+	m.recordTopPending(i, len(m.topPending))
+}
+
+// startRanked records a request to a ranked peer that had n pending requests.
+//
+// startRanked must be called under the list lock.
+func (m *listMetrics) startRanked(n int) {
+	if len(m.topPending) == 0 {
+		return
+	}
+	// Incrementing the first of the counts equal to n keeps the counts in
+	// order, so only that rank changes.
+	i := m.firstRank(n)
+	m.ranks[i]++
+	m.recordTopPending(i, i+1)
+}
+
+// finishRanked records the end of a request to a ranked peer that had n
+// pending requests.
+//
+// finishRanked must be called under the list lock.
+func (m *listMetrics) finishRanked(n int) {
+	if len(m.topPending) == 0 {
+		return
+	}
+	i := m.lastRank(n)
+	m.ranks[i]--
+	m.recordTopPending(i, i+1)
+}
+
+// firstRank returns the lowest rank with n pending requests.
+func (m *listMetrics) firstRank(n int) int {
+	return sort.Search(len(m.ranks), func(i int) bool { return m.ranks[i] <= n })
+}
+
+// lastRank returns the highest rank with n pending requests.
+func (m *listMetrics) lastRank(n int) int {
+	return sort.Search(len(m.ranks), func(i int) bool { return m.ranks[i] < n }) - 1
+}
+
+// recordTopPending records the pending request counts of the ranks from
+// start up to end, among the busiest peers.
+func (m *listMetrics) recordTopPending(start, end int) {
+	for i := start; i < end && i < len(m.topPending); i++ {
+		var n int
+		if i < len(m.ranks) {
+			n = m.ranks[i]
+		}
+		m.topPending[i].Store(int64(n))
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package abstractlist
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/abstractpeer"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+// gauges returns the values of the gauges in a snapshot, keyed by name and,
+// for ranked gauges, by name and rank.
+func gauges(root *metrics.Root) map[string]int64 {
+	values := make(map[string]int64)
+	for _, g := range root.Snapshot().Gauges {
+		name := g.Name
+		if rank, ok := g.Tags["rank"]; ok {
+			name += "/" + rank
+		}
+		values[name] = g.Value
+	}
+	return values
+}
+
+func counters(root *metrics.Root) map[string]int64 {
+	values := make(map[string]int64)
+	for _, c := range root.Snapshot().Counters {
+		values[c.Name] = c.Value
+	}
+	return values
+}
+
+func TestMetricsDuringOutage(t *testing.T) {
+	root := metrics.New()
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Unavailable))
+	list := New("mra", fake, &mraList{}, Meter(root.Scope()), PendingRequestsTopK(2))
+	require.NoError(t, list.Start())
+	defer list.Stop()
+
+	require.NoError(t, list.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{
+			abstractpeer.Identify("1.1.1.1:4040"),
+			abstractpeer.Identify("2.2.2.2:4040"),
+			abstractpeer.Identify("3.3.3.3:4040"),
+		},
+	}))
+	for _, g := range root.Snapshot().Gauges {
+		assert.Equal(t, metrics.Tags{"component": "yarpc", "peer_list": "mra"}, withoutRank(g.Tags), "gauge %q", g.Name)
+	}
+	assert.Equal(t, int64(3), gauges(root)["peer_list_peers"])
+	assert.Equal(t, int64(0), gauges(root)["peer_list_available_peers"])
+	assert.Equal(t, int64(3), gauges(root)["peer_list_unavailable_peers"])
+
+	fake.SimulateConnect(abstractpeer.Identify("1.1.1.1:4040"))
+	fake.SimulateConnect(abstractpeer.Identify("2.2.2.2:4040"))
+	assert.Equal(t, int64(2), gauges(root)["peer_list_available_peers"])
+	assert.Equal(t, int64(1), gauges(root)["peer_list_unavailable_peers"])
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	// The most recently added list sends every request to the same peer.
+	var finishers []func(error)
+	for i := 0; i < 3; i++ {
+		_, onFinish, err := list.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		finishers = append(finishers, onFinish)
+	}
+	assert.Equal(t, int64(3), gauges(root)["peer_list_pending_requests"])
+	assert.Equal(t, int64(3), gauges(root)["peer_list_top_pending_requests/1"])
+	assert.Equal(t, int64(0), gauges(root)["peer_list_top_pending_requests/2"])
+
+	finishers[0](nil)
+	assert.Equal(t, int64(2), gauges(root)["peer_list_pending_requests"])
+	assert.Equal(t, int64(2), gauges(root)["peer_list_top_pending_requests/1"])
+
+	// Simulate an outage: every peer becomes unavailable and choosing a peer
+	// times out.
+	fake.SimulateDisconnect(abstractpeer.Identify("1.1.1.1:4040"))
+	fake.SimulateDisconnect(abstractpeer.Identify("2.2.2.2:4040"))
+	assert.Equal(t, int64(3), gauges(root)["peer_list_peers"])
+	assert.Equal(t, int64(0), gauges(root)["peer_list_available_peers"])
+	assert.Equal(t, int64(3), gauges(root)["peer_list_unavailable_peers"])
+
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 10*testtime.Millisecond)
+	defer shortCancel()
+	_, _, err := list.Choose(shortCtx, &transport.Request{})
+	require.Error(t, err)
+	assert.Equal(t, int64(1), counters(root)["peer_list_choose_timeouts"])
+
+	// Requests in flight during the outage still finish.
+	for _, onFinish := range finishers[1:] {
+		onFinish(nil)
+	}
+	assert.Equal(t, int64(0), gauges(root)["peer_list_pending_requests"])
+	assert.Equal(t, int64(0), gauges(root)["peer_list_top_pending_requests/1"])
+
+	histograms := root.Snapshot().Histograms
+	require.Len(t, histograms, 1)
+	assert.Equal(t, "peer_list_choose_latency_ms", histograms[0].Name)
+	assert.Len(t, histograms[0].Values, 4, "expected a latency observation per choose call")
+}
+
+func TestMetricsWithoutMeter(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := New("mra", fake, &mraList{}, PendingRequestsTopK(2))
+	require.NoError(t, list.Start())
+	defer list.Stop()
+
+	require.NoError(t, list.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{abstractpeer.Identify("1.1.1.1:4040")},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	_, onFinish, err := list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	onFinish(nil)
+}
+
+func TestMetricsTopPendingMatchesSortedPeers(t *testing.T) {
+	const (
+		numPeers = 20
+		topK     = 5
+	)
+	root := metrics.New()
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := New("mra", fake, &mraList{}, Meter(root.Scope()), PendingRequestsTopK(topK))
+	require.NoError(t, list.Start())
+	defer list.Stop()
+
+	addrs := make([]string, numPeers)
+	for i := range addrs {
+		addrs[i] = fmt.Sprintf("10.0.0.%d:4040", i)
+		require.NoError(t, list.Update(peer.ListUpdates{
+			Additions: []peer.Identifier{abstractpeer.Identify(addrs[i])},
+		}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	// Start and finish requests to random peers, removing and re-adding
+	// peers with requests pending, and compare the ranked gauges with the
+	// sorted pending counts of the retained peers.
+	rng := rand.New(rand.NewSource(0))
+	pending := make(map[string][]func(error))
+	retained := make(map[string]bool)
+	for _, addr := range addrs {
+		retained[addr] = true
+	}
+	for i := 0; i < 2000; i++ {
+		addr := addrs[rng.Intn(numPeers)]
+		switch op := rng.Intn(10); {
+		case op < 6 && retained[addr]:
+			_, onFinish, err := list.Choose(peer.WithPinnedPeer(ctx, addr), &transport.Request{})
+			require.NoError(t, err)
+			pending[addr] = append(pending[addr], onFinish)
+		case op < 9 && len(pending[addr]) > 0:
+			pending[addr][0](nil)
+			pending[addr] = pending[addr][1:]
+		case op == 9 && retained[addr]:
+			require.NoError(t, list.Update(peer.ListUpdates{
+				Removals: []peer.Identifier{abstractpeer.Identify(addr)},
+			}))
+			retained[addr] = false
+		case op == 9:
+			require.NoError(t, list.Update(peer.ListUpdates{
+				Additions: []peer.Identifier{abstractpeer.Identify(addr)},
+			}))
+			retained[addr] = true
+			// Requests to the removed peer no longer count towards the
+			// re-added peer.
+			for _, onFinish := range pending[addr] {
+				onFinish(nil)
+			}
+			pending[addr] = nil
+		}
+
+		var counts []int
+		for _, pf := range list.peers {
+			counts = append(counts, pf.status.PendingRequestCount)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+		values := gauges(root)
+		for rank := 0; rank < topK; rank++ {
+			var want int64
+			if rank < len(counts) {
+				want = int64(counts[rank])
+			}
+			require.Equal(t, want, values[fmt.Sprintf("peer_list_top_pending_requests/%d", rank+1)],
+				"rank %d after step %d", rank+1, i)
+		}
+	}
+}
+
+func BenchmarkChooseTopPending(b *testing.B) {
+	for _, numPeers := range []int{10, 5000} {
+		for _, topK := range []int{0, 10} {
+			b.Run(fmt.Sprintf("peers=%d/topK=%d", numPeers, topK), func(b *testing.B) {
+				benchmarkChooseTopPending(b, numPeers, topK)
+			})
+		}
+	}
+}
+
+func benchmarkChooseTopPending(b *testing.B, numPeers, topK int) {
+	root := metrics.New()
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := New("mra", fake, &mraList{}, Meter(root.Scope()), PendingRequestsTopK(topK))
+	require.NoError(b, list.Start())
+	defer list.Stop()
+
+	addrs := make([]string, numPeers)
+	ids := make([]peer.Identifier, numPeers)
+	for i := range addrs {
+		addrs[i] = fmt.Sprintf("10.0.%d.%d:4040", i/256, i%256)
+		ids[i] = abstractpeer.Identify(addrs[i])
+	}
+	require.NoError(b, list.Update(peer.ListUpdates{Additions: ids}))
+
+	// Keep a request pending to every peer so that ranks are contended.
+	for _, addr := range addrs {
+		_, _, err := list.Choose(peer.WithPinnedPeer(context.Background(), addr), &transport.Request{})
+		require.NoError(b, err)
+	}
+
+	ctx := context.Background()
+	req := &transport.Request{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, onFinish, err := list.Choose(peer.WithPinnedPeer(ctx, addrs[i%numPeers]), req)
+		if err != nil {
+			b.Fatal(err)
+		}
+		onFinish(nil)
+	}
+}
+
+func withoutRank(tags metrics.Tags) metrics.Tags {
+	out := make(metrics.Tags, len(tags))
+	for k, v := range tags {
+		if k != "rank" {
+			out[k] = v
+		}
+	}
+	return out
+}
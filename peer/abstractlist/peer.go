@@ -22,6 +22,7 @@ package abstractlist
 
 import (
 	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/pkg/clock"
 )
 
 var _ peer.Peer = (*peerFacade)(nil)
@@ -40,6 +41,21 @@ type peerFacade struct {
 	status     peer.Status
 	subscriber Subscriber
 	onFinish   func(error)
+
+	// maxPending is the limit of pending requests to the peer, if positive.
+	// atCapacity indicates that the peer has been set aside from the
+	// implementation because it reached the limit.
+	maxPending int
+	atCapacity bool
+
+	// removed indicates that the peer is no longer retained by the list,
+	// though requests to it may still be pending.
+	removed bool
+
+	// draining indicates that the peer was removed from the list but not yet
+	// released, until its pending requests finish or drainTimer fires.
+	draining   bool
+	drainTimer clock.Timer
 }
 
 // StartRequest is vestigial.
@@ -0,0 +1,121 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package abstractlist
+
+import (
+	"sync"
+
+	"go.uber.org/yarpc/api/peer"
+)
+
+// subscription delivers peer status changes to a subscriber function from its
+// own goroutine, so a slow subscriber cannot hold up the list.
+//
+// Changes that arrive while the function is busy are coalesced, keeping only
+// the latest status of each peer.
+type subscription struct {
+	fn func(peer.Identifier, peer.ConnectionStatus)
+
+	lock    sync.Mutex
+	pending map[string]statusChange
+	// order holds the keys of pending, in the order the peers first changed.
+	order []string
+
+	wake     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type statusChange struct {
+	id     peer.Identifier
+	status peer.ConnectionStatus
+}
+
+func newSubscription(fn func(peer.Identifier, peer.ConnectionStatus)) *subscription {
+	s := &subscription{
+		fn:      fn,
+		pending: make(map[string]statusChange),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// notify queues a status change for delivery and never blocks.
+func (s *subscription) notify(id peer.Identifier, status peer.ConnectionStatus) {
+	key := id.Identifier()
+
+	s.lock.Lock()
+	if _, ok := s.pending[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.pending[key] = statusChange{id: id, status: status}
+	s.lock.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// stop ends delivery, dropping any changes that have not been delivered.
+//
+// stop may be called any number of times, including from the subscriber
+// function.
+func (s *subscription) stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *subscription) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.wake:
+		}
+
+		for _, change := range s.take() {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			s.fn(change.id, change.status)
+		}
+	}
+}
+
+// take removes and returns the pending changes.
+func (s *subscription) take() []statusChange {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	changes := make([]statusChange, 0, len(s.order))
+	for _, key := range s.order {
+		changes = append(changes, s.pending[key])
+		delete(s.pending, key)
+	}
+	s.order = s.order[:0]
+	return changes
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package abstractlist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+type statusUpdate struct {
+	id     string
+	status peer.ConnectionStatus
+}
+
+// subscribe subscribes to the list, sending status updates to the returned
+// channel.
+// The channel is unbuffered, so each update is delivered only once the test
+// receives the previous one.
+func subscribe(list *List) (<-chan statusUpdate, func()) {
+	updates := make(chan statusUpdate)
+	unsubscribe := list.Subscribe(func(id peer.Identifier, status peer.ConnectionStatus) {
+		updates <- statusUpdate{id: id.Identifier(), status: status}
+	})
+	return updates, unsubscribe
+}
+
+func expectUpdate(t *testing.T, updates <-chan statusUpdate) statusUpdate {
+	t.Helper()
+	select {
+	case update := <-updates:
+		return update
+	case <-time.After(testtime.Second):
+		t.Fatal("timed out waiting for peer status update")
+		return statusUpdate{}
+	}
+}
+
+func expectNoUpdate(t *testing.T, updates <-chan statusUpdate) {
+	t.Helper()
+	select {
+	case update := <-updates:
+		t.Fatalf("unexpected peer status update %v", update)
+	case <-time.After(10 * testtime.Millisecond):
+	}
+}
+
+func TestSubscribeBeforeStart(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Unavailable))
+	list := New("mra", fake, &mraList{})
+
+	updates, unsubscribe := subscribe(list)
+	defer unsubscribe()
+
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+	expectNoUpdate(t, updates)
+
+	require.NoError(t, list.Start())
+	assert.Equal(t, statusUpdate{id: id1.Identifier(), status: peer.Unavailable}, expectUpdate(t, updates))
+
+	fake.SimulateConnect(id1)
+	assert.Equal(t, statusUpdate{id: id1.Identifier(), status: peer.Available}, expectUpdate(t, updates))
+
+	fake.SimulateStatusChange(id1, peer.Connecting)
+	assert.Equal(t, statusUpdate{id: id1.Identifier(), status: peer.Connecting}, expectUpdate(t, updates))
+
+	fake.SimulateConnect(id1)
+	assert.Equal(t, statusUpdate{id: id1.Identifier(), status: peer.Available}, expectUpdate(t, updates))
+
+	require.NoError(t, list.Stop())
+	assert.Equal(t, statusUpdate{id: id1.Identifier(), status: peer.Unavailable}, expectUpdate(t, updates))
+}
+
+func TestSubscribeAfterStart(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := New("mra", fake, &mraList{})
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+
+	updates, unsubscribe := subscribe(list)
+
+	// The subscriber first learns about the peers already in the list.
+	assert.Equal(t, statusUpdate{id: id1.Identifier(), status: peer.Available}, expectUpdate(t, updates))
+
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id2}}))
+	assert.Equal(t, statusUpdate{id: id2.Identifier(), status: peer.Available}, expectUpdate(t, updates))
+
+	require.NoError(t, list.Update(peer.ListUpdates{Removals: []peer.Identifier{id1}}))
+	assert.Equal(t, statusUpdate{id: id1.Identifier(), status: peer.Unavailable}, expectUpdate(t, updates))
+
+	unsubscribe()
+	unsubscribe() // idempotent
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id3}}))
+	expectNoUpdate(t, updates)
+}
+
+func TestSubscribeSlowSubscriber(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := New("mra", fake, &mraList{})
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+
+	busy := make(chan struct{})
+	updates := make(chan statusUpdate, 10)
+	unsubscribe := list.Subscribe(func(id peer.Identifier, status peer.ConnectionStatus) {
+		updates <- statusUpdate{id: id.Identifier(), status: status}
+		busy <- struct{}{}
+	})
+	defer unsubscribe()
+	assert.Equal(t, statusUpdate{id: id1.Identifier(), status: peer.Available}, expectUpdate(t, updates))
+
+	// The subscriber is busy with the initial status, but the list carries
+	// on.
+	for i := 0; i < 10; i++ {
+		fake.SimulateDisconnect(id1)
+		fake.SimulateConnect(id1)
+	}
+	fake.SimulateDisconnect(id1)
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id2}}))
+
+	// The changes while the subscriber was busy coalesce into the latest
+	// status of each peer, in the order the peers first changed.
+	<-busy
+	assert.Equal(t, statusUpdate{id: id1.Identifier(), status: peer.Unavailable}, expectUpdate(t, updates))
+	<-busy
+	assert.Equal(t, statusUpdate{id: id2.Identifier(), status: peer.Available}, expectUpdate(t, updates))
+	<-busy
+	expectNoUpdate(t, updates)
+}
+
+func TestUnsubscribeFromSubscriber(t *testing.T) {
+	fake := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Available))
+	list := New("mra", fake, &mraList{})
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+
+	called := make(chan struct{}, 1)
+	var unsubscribe func()
+	ready := make(chan struct{})
+	unsubscribe = list.Subscribe(func(peer.Identifier, peer.ConnectionStatus) {
+		<-ready
+		unsubscribe()
+		called <- struct{}{}
+	})
+	close(ready)
+
+	select {
+	case <-called:
+	case <-time.After(testtime.Second):
+		t.Fatal("timed out waiting for peer status update")
+	}
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{id2}}))
+	fake.SimulateDisconnect(id1)
+	select {
+	case <-called:
+		t.Fatal("unexpected peer status update after unsubscribing")
+	case <-time.After(10 * testtime.Millisecond):
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package circuit
+
+import (
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Configuration describes how to build a circuit breaking peer list.
+type Configuration struct {
+	// FailureThreshold is the fraction of failed requests above which a peer
+	// is ejected.
+	FailureThreshold *float64 `config:"failureThreshold"`
+	// MinRequests is the number of requests within the window required
+	// before a peer may be ejected.
+	MinRequests *int `config:"minRequests"`
+	// Window is the duration over which failure rates are measured.
+	Window *time.Duration `config:"window"`
+	// BaseEjectionDuration is the duration of the first ejection of a peer,
+	// doubling with every consecutive ejection.
+	BaseEjectionDuration *time.Duration `config:"baseEjectionDuration"`
+	// MaxEjectionDuration caps the duration of an ejection.
+	MaxEjectionDuration *time.Duration `config:"maxEjectionDuration"`
+	// MaxEjectionPercent is the largest percentage of peers that may be
+	// ejected at once.
+	MaxEjectionPercent *int `config:"maxEjectionPercent"`
+	// ProbeRequests is the number of successful requests required to
+	// readmit a peer.
+	ProbeRequests *int `config:"probeRequests"`
+	// With is the name of the underlying peer list, which chooses among the
+	// peers that are not ejected.
+	With string `config:"with"`
+	// Etc captures the configuration of the underlying peer list.
+	Etc map[string]interface{} `config:",squash"`
+}
+
+// Spec returns a configuration specification for the circuit breaking peer
+// list, making it possible to eject failing peers with transports that use
+// outbound peer list configuration (like HTTP).
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(circuit.Spec())
+//  cfg.MustRegisterPeerList(roundrobin.Spec())
+//
+// The circuit breaking peer list must name the underlying peer list,
+// registered with the same Configurator, that chooses among the peers that
+// are not ejected.
+// Attributes other than the circuit breaker thresholds configure the
+// underlying peer list.
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          circuit-breaker:
+//            failureThreshold: 0.5
+//            minRequests: 20
+//            window: 10s
+//            baseEjectionDuration: 30s
+//            maxEjectionDuration: 5m
+//            maxEjectionPercent: 50
+//            probeRequests: 3
+//            with: round-robin
+//            peers:
+//              - 127.0.0.1:8080
+//              - 127.0.0.1:8081
+//
+// Other than a specific peer or peers list, use any peer list updater
+// registered with a yarpc Configurator.
+func Spec() yarpcconfig.PeerListSpec {
+	return SpecWithOptions()
+}
+
+// SpecWithOptions accepts additional list constructor options, such as a
+// Meter for ejection metrics.
+func SpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
+	return yarpcconfig.PeerListSpec{
+		Name: "circuit-breaker",
+		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
+			if cfg.With == "" {
+				return nil, yarpcerrors.InvalidArgumentErrorf(
+					"circuit-breaker peer list requires the name of an underlying peer list in the \"with\" attribute")
+			}
+
+			opts, err := cfg.listOptions()
+			if err != nil {
+				return nil, err
+			}
+
+			list, err := k.BuildPeerList(cfg.With, cfg.Etc, t)
+			if err != nil {
+				return nil, err
+			}
+			var listOpts []ListOption
+			if meter := k.Meter(); meter != nil {
+				listOpts = append(listOpts, Meter(meter))
+			}
+			listOpts = append(listOpts, options...)
+			return New(list, append(listOpts, opts...)...), nil
+		},
+	}
+}
+
+func (cfg Configuration) listOptions() ([]ListOption, error) {
+	var opts []ListOption
+
+	if cfg.FailureThreshold != nil {
+		if *cfg.FailureThreshold <= 0 || *cfg.FailureThreshold > 1 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"FailureThreshold must be greater than 0 and at most 1. Got: %v.", *cfg.FailureThreshold)
+		}
+		opts = append(opts, FailureThreshold(*cfg.FailureThreshold))
+	}
+	if cfg.MinRequests != nil {
+		if *cfg.MinRequests <= 0 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"MinRequests must be greater than 0. Got: %d.", *cfg.MinRequests)
+		}
+		opts = append(opts, MinRequests(*cfg.MinRequests))
+	}
+	if cfg.ProbeRequests != nil {
+		if *cfg.ProbeRequests <= 0 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"ProbeRequests must be greater than 0. Got: %d.", *cfg.ProbeRequests)
+		}
+		opts = append(opts, ProbeRequests(*cfg.ProbeRequests))
+	}
+	if cfg.MaxEjectionPercent != nil {
+		if *cfg.MaxEjectionPercent < 0 || *cfg.MaxEjectionPercent > 100 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"MaxEjectionPercent must be between 0 and 100. Got: %d.", *cfg.MaxEjectionPercent)
+		}
+		opts = append(opts, MaxEjectionPercent(*cfg.MaxEjectionPercent))
+	}
+
+	durations := []struct {
+		name   string
+		value  *time.Duration
+		option func(time.Duration) ListOption
+	}{
+		{"Window", cfg.Window, Window},
+		{"BaseEjectionDuration", cfg.BaseEjectionDuration, BaseEjectionDuration},
+		{"MaxEjectionDuration", cfg.MaxEjectionDuration, MaxEjectionDuration},
+	}
+	for _, d := range durations {
+		if d.value == nil {
+			continue
+		}
+		if *d.value <= 0 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"%s must be greater than 0. Got: %v.", d.name, *d.value)
+		}
+		opts = append(opts, d.option(*d.value))
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package circuit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/whitespace"
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/pkg/clock"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func TestCircuitConfig(t *testing.T) {
+	tests := []struct {
+		desc     string
+		given    string
+		wantOpts listOptions
+		wantErr  string
+	}{
+		{
+			desc: "defaults",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							circuit-breaker:
+								with: round-robin
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantOpts: defaultListOptions,
+		},
+		{
+			desc: "all options",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							circuit-breaker:
+								failureThreshold: 0.25
+								minRequests: 5
+								window: 1m
+								baseEjectionDuration: 10s
+								maxEjectionDuration: 1h
+								maxEjectionPercent: 30
+								probeRequests: 1
+								with: round-robin
+								failFast: true
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantOpts: listOptions{
+				failureThreshold:     0.25,
+				minRequests:          5,
+				window:               time.Minute,
+				baseEjectionDuration: 10 * time.Second,
+				maxEjectionDuration:  time.Hour,
+				maxEjectionPercent:   30,
+				probeRequests:        1,
+				clock:                clock.System,
+			},
+		},
+		{
+			desc: "missing underlying list",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							circuit-breaker:
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: `requires the name of an underlying peer list in the "with" attribute`,
+		},
+		{
+			desc: "invalid threshold",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							circuit-breaker:
+								failureThreshold: 1.5
+								with: round-robin
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "FailureThreshold must be greater than 0 and at most 1. Got: 1.5.",
+		},
+		{
+			desc: "invalid min requests",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							circuit-breaker:
+								minRequests: 0
+								with: round-robin
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "MinRequests must be greater than 0. Got: 0.",
+		},
+		{
+			desc: "invalid probe requests",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							circuit-breaker:
+								probeRequests: -1
+								with: round-robin
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "ProbeRequests must be greater than 0. Got: -1.",
+		},
+		{
+			desc: "invalid max ejection percent",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							circuit-breaker:
+								maxEjectionPercent: 101
+								with: round-robin
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "MaxEjectionPercent must be between 0 and 100. Got: 101.",
+		},
+		{
+			desc: "invalid duration",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							circuit-breaker:
+								baseEjectionDuration: 0s
+								with: round-robin
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "BaseEjectionDuration must be greater than 0. Got: 0s.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfgr := yarpctest.NewFakeConfigurator()
+			cfgr.MustRegisterPeerList(Spec())
+			cfgr.MustRegisterPeerList(roundrobin.Spec())
+
+			cfg, err := cfgr.LoadConfigFromYAML("test", strings.NewReader(whitespace.Expand(tt.given)))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			out := cfg.Outbounds["myservice"].Unary.(*yarpctest.FakeOutbound)
+			pl, ok := out.Chooser().(*peerbind.BoundChooser).ChooserList().(*List)
+			require.True(t, ok, "expected a circuit breaking list, got %T", out.Chooser())
+			assert.Equal(t, tt.wantOpts, pl.opts)
+			assert.IsType(t, &roundrobin.List{}, pl.list)
+		})
+	}
+}
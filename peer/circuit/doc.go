@@ -0,0 +1,39 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package circuit provides a peer list decorator that temporarily ejects
+// peers with excessive failure rates from an underlying peer list.
+//
+// A backend instance that is degraded but not down keeps receiving its share
+// of traffic from load balancers like round-robin. The circuit breaking peer
+// list measures the failure rate of every peer over a sliding window, using
+// the results reported to the onFinish callback of each chosen peer, and
+// removes a peer from the underlying list once its failure rate crosses a
+// threshold. Only server faults count as failures; client faults like invalid
+// arguments do not.
+//
+// After an ejection elapses, the peer returns to the underlying list for a
+// limited number of probe requests. If they all succeed, the peer is
+// readmitted. Otherwise, it is ejected again for twice as long, up to a
+// maximum duration.
+//
+// To avoid ejecting every peer during a broader outage, the proportion of
+// peers ejected at once is capped and at least one peer always remains.
+package circuit
@@ -0,0 +1,499 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package circuit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/internal/window"
+	"go.uber.org/yarpc/pkg/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFailureThreshold     = 0.5
+	defaultMinRequests          = 20
+	defaultWindow               = 10 * time.Second
+	defaultBaseEjectionDuration = 30 * time.Second
+	defaultMaxEjectionDuration  = 5 * time.Minute
+	defaultMaxEjectionPercent   = 50
+	defaultProbeRequests        = 3
+)
+
+type listOptions struct {
+	failureThreshold     float64
+	minRequests          int
+	window               time.Duration
+	baseEjectionDuration time.Duration
+	maxEjectionDuration  time.Duration
+	maxEjectionPercent   int
+	probeRequests        int
+	meter                *metrics.Scope
+	logger               *zap.Logger
+	clock                clock.Clock
+}
+
+var defaultListOptions = listOptions{
+	failureThreshold:     defaultFailureThreshold,
+	minRequests:          defaultMinRequests,
+	window:               defaultWindow,
+	baseEjectionDuration: defaultBaseEjectionDuration,
+	maxEjectionDuration:  defaultMaxEjectionDuration,
+	maxEjectionPercent:   defaultMaxEjectionPercent,
+	probeRequests:        defaultProbeRequests,
+	clock:                clock.System,
+}
+
+// ListOption customizes the behavior of a circuit breaking peer list.
+type ListOption func(*listOptions)
+
+// FailureThreshold specifies the fraction of failed requests, between 0 and
+// 1, above which a peer is ejected.
+//
+// Defaults to 0.5.
+func FailureThreshold(threshold float64) ListOption {
+	return func(o *listOptions) {
+		o.failureThreshold = threshold
+	}
+}
+
+// MinRequests specifies the number of requests a peer must have completed
+// within the window before it may be ejected.
+//
+// Defaults to 20.
+func MinRequests(n int) ListOption {
+	return func(o *listOptions) {
+		o.minRequests = n
+	}
+}
+
+// Window specifies the duration over which the failure rate of each peer is
+// measured.
+//
+// Defaults to 10 seconds.
+func Window(d time.Duration) ListOption {
+	return func(o *listOptions) {
+		o.window = d
+	}
+}
+
+// BaseEjectionDuration specifies how long a peer is ejected the first time.
+// Every consecutive ejection doubles this duration, up to the maximum.
+//
+// Defaults to 30 seconds.
+func BaseEjectionDuration(d time.Duration) ListOption {
+	return func(o *listOptions) {
+		o.baseEjectionDuration = d
+	}
+}
+
+// MaxEjectionDuration caps the duration of an ejection.
+//
+// Defaults to 5 minutes.
+func MaxEjectionDuration(d time.Duration) ListOption {
+	return func(o *listOptions) {
+		o.maxEjectionDuration = d
+	}
+}
+
+// MaxEjectionPercent specifies the largest percentage of peers that may be
+// ejected at once. Regardless of this setting, at least one peer is always
+// left available.
+//
+// Defaults to 50.
+func MaxEjectionPercent(percent int) ListOption {
+	return func(o *listOptions) {
+		o.maxEjectionPercent = percent
+	}
+}
+
+// ProbeRequests specifies the number of requests sent to a peer after its
+// ejection elapses. The peer is readmitted if all of them succeed and ejected
+// again if any fails.
+//
+// Defaults to 3.
+func ProbeRequests(n int) ListOption {
+	return func(o *listOptions) {
+		o.probeRequests = n
+	}
+}
+
+// Meter specifies the scope for ejection and readmission metrics.
+//
+// Metrics are registered when the list is constructed, so lists sharing a
+// meter should each use a distinctly tagged scope.
+func Meter(meter *metrics.Scope) ListOption {
+	return func(o *listOptions) {
+		o.meter = meter
+	}
+}
+
+// Logger specifies a logger.
+func Logger(logger *zap.Logger) ListOption {
+	return func(o *listOptions) {
+		o.logger = logger
+	}
+}
+
+// Clock specifies the clock that measures failure rates and ejection
+// durations, for tests that control time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) ListOption {
+	return func(o *listOptions) {
+		o.clock = c
+	}
+}
+
+type peerState int
+
+const (
+	// healthy peers are in the underlying list and their results are
+	// recorded.
+	healthy peerState = iota
+	// ejected peers are absent from the underlying list until their ejection
+	// elapses.
+	ejected
+	// probing peers are in the underlying list until they have been chosen
+	// for the configured number of probe requests.
+	probing
+)
+
+type peerStatus struct {
+	id     peer.Identifier
+	state  peerState
+	inList bool
+	// generation changes with every change of state so that results of
+	// requests chosen in a previous state are ignored.
+	generation int
+
+	window       window.Window
+	ejections    int
+	ejectedUntil time.Time
+	probes       int
+	probeSuccess int
+}
+
+// New creates a peer list that ejects peers with excessive failure rates from
+// the given peer list.
+//
+// Failures are server faults reported by the onFinish callbacks of chosen
+// peers. A peer whose failure rate exceeds the threshold is removed from the
+// underlying list for an exponentially increasing duration, then readmitted
+// once a limited number of probe requests succeed.
+func New(list peer.ChooserList, opts ...ListOption) *List {
+	options := defaultListOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.probeRequests < 1 {
+		options.probeRequests = 1
+	}
+
+	logger := options.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &List{
+		list:    list,
+		opts:    options,
+		logger:  logger,
+		metrics: newListMetrics(options.meter, logger),
+		now:     options.clock.Now,
+		peers:   make(map[string]*peerStatus),
+	}
+}
+
+var _ peer.ChooserList = (*List)(nil)
+var _ introspection.IntrospectableChooser = (*List)(nil)
+
+// List is a peer list that forwards peers to an underlying peer list and
+// temporarily withholds those that fail too often.
+type List struct {
+	list    peer.ChooserList
+	opts    listOptions
+	logger  *zap.Logger
+	metrics listMetrics
+	now     func() time.Time
+
+	lock    sync.Mutex
+	peers   map[string]*peerStatus
+	ejected int
+}
+
+// Update adds and removes peers from the list and forwards the changes to
+// the underlying peer list, except for removals of peers it currently
+// withholds.
+func (l *List) Update(updates peer.ListUpdates) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var (
+		errs    error
+		forward peer.ListUpdates
+	)
+	for _, id := range updates.Removals {
+		addr := id.Identifier()
+		status, ok := l.peers[addr]
+		if !ok {
+			errs = multierr.Append(errs, peer.ErrPeerRemoveNotInList(addr))
+			continue
+		}
+		delete(l.peers, addr)
+		if status.state != healthy {
+			l.ejected--
+			l.metrics.ejectedPeers.Store(int64(l.ejected))
+		}
+		if status.inList {
+			forward.Removals = append(forward.Removals, id)
+		}
+	}
+	for _, id := range updates.Additions {
+		addr := id.Identifier()
+		if _, ok := l.peers[addr]; ok {
+			errs = multierr.Append(errs, peer.ErrPeerAddAlreadyInList(addr))
+			continue
+		}
+		l.peers[addr] = &peerStatus{
+			id:     id,
+			inList: true,
+			window: window.New(l.opts.window),
+		}
+		forward.Additions = append(forward.Additions, id)
+	}
+
+	if len(forward.Additions) == 0 && len(forward.Removals) == 0 {
+		return errs
+	}
+	return multierr.Append(errs, l.list.Update(forward))
+}
+
+// Choose returns a peer from the underlying peer list, readmitting peers
+// whose ejection has elapsed for probing.
+func (l *List) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	l.probeElapsed()
+
+	p, onFinish, err := l.list.Choose(ctx, req)
+	if err != nil {
+		return p, onFinish, err
+	}
+
+	addr := p.Identifier()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	status, ok := l.peers[addr]
+	if !ok {
+		return p, onFinish, nil
+	}
+	generation := status.generation
+	// Send no more than the configured number of probes until they have all
+	// completed.
+	if status.state == probing && status.inList {
+		status.probes++
+		if status.probes >= l.opts.probeRequests {
+			status.inList = false
+			l.updateList(peer.ListUpdates{Removals: []peer.Identifier{status.id}})
+		}
+	}
+
+	return p, func(err error) {
+		onFinish(err)
+		l.record(addr, generation, isFailure(err))
+	}, nil
+}
+
+func isFailure(err error) bool {
+	return err != nil && yarpcerrors.GetFaultTypeFromError(err) != yarpcerrors.ClientFault
+}
+
+// probeElapsed moves peers whose ejection has elapsed into the probing state
+// and returns them to the underlying list.
+func (l *List) probeElapsed() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.ejected == 0 {
+		return
+	}
+
+	now := l.now()
+	var updates peer.ListUpdates
+	for _, status := range l.peers {
+		if status.state != ejected || now.Before(status.ejectedUntil) {
+			continue
+		}
+		status.state = probing
+		status.generation++
+		status.probes = 0
+		status.probeSuccess = 0
+		status.inList = true
+		updates.Additions = append(updates.Additions, status.id)
+	}
+	l.updateList(updates)
+}
+
+func (l *List) record(addr string, generation int, failed bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	status, ok := l.peers[addr]
+	if !ok || status.generation != generation {
+		return
+	}
+
+	var updates peer.ListUpdates
+	switch status.state {
+	case healthy:
+		now := l.now()
+		status.window.Record(now, failed)
+		if failed && l.shouldEject(status, now) {
+			l.eject(status, now)
+			l.ejected++
+			l.metrics.ejectedPeers.Store(int64(l.ejected))
+			updates.Removals = append(updates.Removals, status.id)
+		}
+	case probing:
+		if failed {
+			if status.inList {
+				updates.Removals = append(updates.Removals, status.id)
+			}
+			l.eject(status, l.now())
+			break
+		}
+		status.probeSuccess++
+		if status.probeSuccess < l.opts.probeRequests {
+			break
+		}
+		status.state = healthy
+		status.generation++
+		status.ejections = 0
+		status.window.Reset()
+		if !status.inList {
+			status.inList = true
+			updates.Additions = append(updates.Additions, status.id)
+		}
+		l.ejected--
+		l.metrics.ejectedPeers.Store(int64(l.ejected))
+		l.metrics.readmissions.Inc()
+		l.logger.Info("readmitted peer", zap.String("peer", addr))
+	}
+	l.updateList(updates)
+}
+
+// shouldEject must be called under the list lock.
+func (l *List) shouldEject(status *peerStatus, now time.Time) bool {
+	successes, failures := status.window.Counts(now)
+	total := successes + failures
+	if total < l.opts.minRequests || float64(failures) <= l.opts.failureThreshold*float64(total) {
+		return false
+	}
+
+	// Never eject the last available peer, nor more than the maximum
+	// proportion of peers.
+	ejected := l.ejected + 1
+	if ejected >= len(l.peers) || ejected*100 > l.opts.maxEjectionPercent*len(l.peers) {
+		l.logger.Debug("not ejecting peer, too many peers ejected",
+			zap.String("peer", status.id.Identifier()))
+		return false
+	}
+	return true
+}
+
+// eject must be called under the list lock.
+func (l *List) eject(status *peerStatus, now time.Time) {
+	duration := l.opts.baseEjectionDuration
+	for i := 0; i < status.ejections && duration < l.opts.maxEjectionDuration; i++ {
+		duration *= 2
+	}
+	if duration > l.opts.maxEjectionDuration {
+		duration = l.opts.maxEjectionDuration
+	}
+
+	status.state = ejected
+	status.generation++
+	status.inList = false
+	status.ejections++
+	status.ejectedUntil = now.Add(duration)
+
+	l.metrics.ejections.Inc()
+	l.logger.Info("ejected peer",
+		zap.String("peer", status.id.Identifier()),
+		zap.Duration("duration", duration))
+}
+
+// updateList must be called under the list lock, so that changes reach the
+// underlying list in the order they are made.
+func (l *List) updateList(updates peer.ListUpdates) {
+	if len(updates.Additions) == 0 && len(updates.Removals) == 0 {
+		return
+	}
+	if err := l.list.Update(updates); err != nil {
+		l.logger.Error("failed to update peer list", zap.Error(err))
+	}
+}
+
+// Ejected returns the identifiers of the peers currently withheld from the
+// underlying peer list or being probed, in no particular order.
+func (l *List) Ejected() []peer.Identifier {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	ids := make([]peer.Identifier, 0, l.ejected)
+	for _, status := range l.peers {
+		if status.state != healthy {
+			ids = append(ids, status.id)
+		}
+	}
+	return ids
+}
+
+// Start starts the underlying peer list.
+func (l *List) Start() error {
+	return l.list.Start()
+}
+
+// Stop stops the underlying peer list.
+func (l *List) Stop() error {
+	return l.list.Stop()
+}
+
+// IsRunning returns whether the underlying peer list is running.
+func (l *List) IsRunning() bool {
+	return l.list.IsRunning()
+}
+
+// Introspect introspects the underlying peer list.
+func (l *List) Introspect() introspection.ChooserStatus {
+	if ic, ok := l.list.(introspection.IntrospectableChooser); ok {
+		return ic.Introspect()
+	}
+	return introspection.ChooserStatus{}
+}
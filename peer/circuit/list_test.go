@@ -0,0 +1,276 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package circuit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func makePeers(n int) (ids []peer.Identifier) {
+	for i := 0; i < n; i++ {
+		ids = append(ids, hostport.Identify(fmt.Sprintf("10.0.0.%d:4040", i)))
+	}
+	return ids
+}
+
+type harness struct {
+	t     *testing.T
+	list  *List
+	trans *yarpctest.FakeTransport
+	clock *yarpctest.FakeClock
+	root  *metrics.Root
+	// bad is the set of peers whose requests fail.
+	bad map[string]error
+}
+
+func newHarness(t *testing.T, peers int, opts ...ListOption) *harness {
+	root := metrics.New()
+	trans := yarpctest.NewFakeTransport()
+	clock := yarpctest.NewFakeClock()
+	opts = append([]ListOption{
+		MinRequests(10),
+		Window(10 * time.Second),
+		BaseEjectionDuration(time.Minute),
+		MaxEjectionDuration(4 * time.Minute),
+		ProbeRequests(2),
+		Meter(root.Scope()),
+		Clock(clock),
+	}, opts...)
+	pl := New(roundrobin.New(trans), opts...)
+
+	require.NoError(t, pl.Start())
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: makePeers(peers)}))
+	trans.Flush()
+
+	return &harness{
+		t:     t,
+		list:  pl,
+		trans: trans,
+		clock: clock,
+		root:  root,
+		bad:   make(map[string]error),
+	}
+}
+
+// call sends n requests and returns the number of requests each peer
+// received.
+func (h *harness) call(n int) map[string]int {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		p, onFinish, err := h.list.Choose(ctx, &transport.Request{})
+		require.NoError(h.t, err)
+		counts[p.Identifier()]++
+		onFinish(h.bad[p.Identifier()])
+		h.trans.Flush()
+	}
+	return counts
+}
+
+func (h *harness) counters() map[string]int64 {
+	counters := make(map[string]int64)
+	for _, c := range h.root.Snapshot().Counters {
+		counters[c.Name] = c.Value
+	}
+	for _, g := range h.root.Snapshot().Gauges {
+		counters[g.Name] = g.Value
+	}
+	return counters
+}
+
+func TestBadPeerEjectedAndReadmitted(t *testing.T) {
+	h := newHarness(t, 4)
+	defer h.list.Stop()
+	bad := "10.0.0.1:4040"
+	h.bad[bad] = yarpcerrors.UnavailableErrorf("great sadness")
+
+	// The bad peer receives a quarter of the traffic until it has failed
+	// often enough to be ejected.
+	counts := h.call(40)
+	assert.Equal(t, 10, counts[bad])
+	assert.Equal(t, []peer.Identifier{hostport.Identify(bad)}, h.list.Ejected())
+
+	counts = h.call(30)
+	assert.Zero(t, counts[bad], "ejected peer must not receive traffic")
+	assert.Len(t, counts, 3)
+
+	// The peer is still bad when its ejection elapses, so the first probe
+	// ejects it again, for twice as long.
+	h.clock.Add(time.Minute)
+	counts = h.call(30)
+	assert.Equal(t, 1, counts[bad], "expected a single failed probe")
+
+	h.clock.Add(time.Minute)
+	counts = h.call(30)
+	assert.Zero(t, counts[bad], "ejection must double")
+
+	// The peer recovers and is readmitted after its probes succeed.
+	delete(h.bad, bad)
+	h.clock.Add(time.Minute)
+	counts = h.call(40)
+	assert.Equal(t, 10, counts[bad], "readmitted peer must receive its share of traffic")
+	assert.Empty(t, h.list.Ejected())
+
+	assert.Equal(t, map[string]int64{
+		"circuit_ejections":     2,
+		"circuit_readmissions":  1,
+		"circuit_ejected_peers": 0,
+	}, h.counters())
+}
+
+func TestProbeRequestsLimited(t *testing.T) {
+	h := newHarness(t, 2, MaxEjectionPercent(50))
+	defer h.list.Stop()
+	bad := "10.0.0.0:4040"
+	h.bad[bad] = yarpcerrors.InternalErrorf("great sadness")
+
+	h.call(20)
+	require.Len(t, h.list.Ejected(), 1)
+
+	h.clock.Add(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	// Hold the probes open. No more than two may be sent.
+	var (
+		probes  int
+		pending []func(error)
+	)
+	for i := 0; i < 10; i++ {
+		p, onFinish, err := h.list.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		h.trans.Flush()
+		if p.Identifier() == bad {
+			probes++
+			pending = append(pending, onFinish)
+			continue
+		}
+		onFinish(nil)
+	}
+	assert.Equal(t, 2, probes)
+
+	for _, onFinish := range pending {
+		onFinish(nil)
+	}
+	h.trans.Flush()
+	assert.Empty(t, h.list.Ejected(), "peer must be readmitted once all probes succeed")
+}
+
+func TestClientFaultsIgnored(t *testing.T) {
+	h := newHarness(t, 4)
+	defer h.list.Stop()
+	h.bad["10.0.0.1:4040"] = yarpcerrors.InvalidArgumentErrorf("bad request")
+
+	h.call(80)
+	assert.Empty(t, h.list.Ejected())
+}
+
+func TestMaxEjectionPercent(t *testing.T) {
+	tests := []struct {
+		desc        string
+		peers       int
+		percent     int
+		wantEjected int
+	}{
+		{desc: "half of four", peers: 4, percent: 50, wantEjected: 2},
+		{desc: "quarter of four", peers: 4, percent: 25, wantEjected: 1},
+		{desc: "none", peers: 4, percent: 0, wantEjected: 0},
+		{desc: "never the last peer", peers: 2, percent: 100, wantEjected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			h := newHarness(t, tt.peers, MaxEjectionPercent(tt.percent))
+			defer h.list.Stop()
+			for _, id := range makePeers(tt.peers) {
+				h.bad[id.Identifier()] = yarpcerrors.UnavailableErrorf("outage")
+			}
+
+			h.call(20 * tt.peers)
+			assert.Len(t, h.list.Ejected(), tt.wantEjected)
+		})
+	}
+}
+
+func TestFailuresOutsideWindowForgotten(t *testing.T) {
+	h := newHarness(t, 4)
+	defer h.list.Stop()
+	bad := "10.0.0.1:4040"
+	h.bad[bad] = yarpcerrors.UnavailableErrorf("great sadness")
+
+	// Each round sends the bad peer fewer failures than required for
+	// ejection, and the window forgets them before the next round.
+	for i := 0; i < 3; i++ {
+		h.call(32)
+		h.clock.Add(11 * time.Second)
+	}
+	assert.Empty(t, h.list.Ejected())
+}
+
+func TestRemoveEjectedPeer(t *testing.T) {
+	h := newHarness(t, 4)
+	defer h.list.Stop()
+	bad := hostport.Identify("10.0.0.1:4040")
+	h.bad[bad.Identifier()] = yarpcerrors.UnavailableErrorf("great sadness")
+
+	h.call(40)
+	require.Len(t, h.list.Ejected(), 1)
+
+	require.NoError(t, h.list.Update(peer.ListUpdates{Removals: []peer.Identifier{bad}}))
+	assert.Empty(t, h.list.Ejected())
+	require.NoError(t, h.list.Update(peer.ListUpdates{Additions: []peer.Identifier{bad}}))
+	h.trans.Flush()
+
+	counts := h.call(40)
+	assert.Equal(t, 10, counts[bad.Identifier()], "re-added peer must start healthy")
+
+	err := h.list.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{bad},
+		Removals:  []peer.Identifier{hostport.Identify("10.0.0.9:4040")},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `can't add peer "10.0.0.1:4040" because is already in peerlist`)
+	assert.Contains(t, err.Error(), `can't remove peer (10.0.0.9:4040) because it is not in peerlist`)
+}
+
+func TestListDelegates(t *testing.T) {
+	h := newHarness(t, 1)
+	assert.True(t, h.list.IsRunning())
+	assert.Equal(t, "round-robin", h.list.Introspect().Name)
+	require.NoError(t, h.list.Stop())
+	assert.False(t, h.list.IsRunning())
+}
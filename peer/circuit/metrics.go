@@ -0,0 +1,69 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package circuit
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
+type listMetrics struct {
+	ejections    *metrics.Counter
+	readmissions *metrics.Counter
+	ejectedPeers *metrics.Gauge
+}
+
+func newListMetrics(meter *metrics.Scope, logger *zap.Logger) listMetrics {
+	tags := metrics.Tags{"component": "yarpc"}
+
+	ejections, err := meter.Counter(metrics.Spec{
+		Name:      "circuit_ejections",
+		Help:      "Total number of peers ejected for excessive failures.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create circuit ejections counter", zap.Error(err))
+	}
+
+	readmissions, err := meter.Counter(metrics.Spec{
+		Name:      "circuit_readmissions",
+		Help:      "Total number of ejected peers readmitted after successful probes.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create circuit readmissions counter", zap.Error(err))
+	}
+
+	ejectedPeers, err := meter.Gauge(metrics.Spec{
+		Name:      "circuit_ejected_peers",
+		Help:      "Number of peers currently ejected or being probed.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create circuit ejected peers gauge", zap.Error(err))
+	}
+
+	return listMetrics{
+		ejections:    ejections,
+		readmissions: readmissions,
+		ejectedPeers: ejectedPeers,
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package decaychooser
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/pkg/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultThreshold = 0.05
+	defaultHalfLife  = 30 * time.Second
+
+	// maxChooseAttempts is the number of peers offered by the underlying
+	// chooser that the decay chooser considers for each request. It accepts
+	// the last one regardless of its error rate.
+	maxChooseAttempts = 3
+
+	// idleRequests is the decayed number of requests below which a peer is
+	// forgotten, about ten half-lives after its last request.
+	idleRequests = 1e-3
+)
+
+type chooserOptions struct {
+	threshold float64
+	meter     *metrics.Scope
+	logger    *zap.Logger
+	clock     clock.Clock
+	source    rand.Source
+}
+
+// Option customizes the behavior of a decay chooser.
+type Option func(*chooserOptions)
+
+// Threshold specifies the error rate, between 0 and 1, below which a peer
+// receives its full share of requests.
+//
+// Defaults to 0.05.
+func Threshold(threshold float64) Option {
+	return func(o *chooserOptions) {
+		o.threshold = threshold
+	}
+}
+
+// Meter specifies the scope for the weight of every peer.
+//
+// Metrics are registered when the chooser is constructed, so choosers
+// sharing a meter should each use a distinctly tagged scope.
+func Meter(meter *metrics.Scope) Option {
+	return func(o *chooserOptions) {
+		o.meter = meter
+	}
+}
+
+// Logger specifies a logger.
+func Logger(logger *zap.Logger) Option {
+	return func(o *chooserOptions) {
+		o.logger = logger
+	}
+}
+
+// Clock specifies the clock that decays error rates, for tests that control
+// time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) Option {
+	return func(o *chooserOptions) {
+		o.clock = c
+	}
+}
+
+// Source specifies the source of randomness that decides whether to accept
+// a peer.
+//
+// Defaults to a source seeded with the current time.
+func Source(source rand.Source) Option {
+	return func(o *chooserOptions) {
+		o.source = source
+	}
+}
+
+// peerStatus holds the decayed counts of the requests to a peer.
+type peerStatus struct {
+	failures float64
+	requests float64
+	updated  time.Time
+	weight   *metrics.Gauge
+}
+
+// New creates a peer chooser that chooses peers from the given chooser, and
+// sends fewer requests to peers in proportion to their error rate, decayed
+// with the given half-life.
+//
+// A non-positive half-life defaults to 30 seconds.
+func New(base peer.Chooser, halfLife time.Duration, opts ...Option) *Chooser {
+	if halfLife <= 0 {
+		halfLife = defaultHalfLife
+	}
+	options := chooserOptions{
+		threshold: defaultThreshold,
+		clock:     clock.System,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.source == nil {
+		options.source = rand.NewSource(time.Now().UnixNano())
+	}
+
+	logger := options.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &Chooser{
+		base:      base,
+		halfLife:  halfLife,
+		threshold: options.threshold,
+		metrics:   newChooserMetrics(options.meter, logger),
+		now:       options.clock.Now,
+		random:    rand.New(options.source),
+		peers:     make(map[string]*peerStatus),
+	}
+}
+
+var _ peer.Chooser = (*Chooser)(nil)
+
+// Chooser is a peer chooser that penalizes peers of an underlying chooser
+// with high error rates.
+type Chooser struct {
+	base      peer.Chooser
+	halfLife  time.Duration
+	threshold float64
+	metrics   chooserMetrics
+	now       func() time.Time
+
+	lock       sync.Mutex
+	random     *rand.Rand
+	peers      map[string]*peerStatus
+	lastForget time.Time
+}
+
+// Start starts the underlying chooser.
+func (c *Chooser) Start() error {
+	return c.base.Start()
+}
+
+// Stop stops the underlying chooser.
+func (c *Chooser) Stop() error {
+	return c.base.Stop()
+}
+
+// IsRunning returns whether the underlying chooser is running.
+func (c *Chooser) IsRunning() bool {
+	return c.base.IsRunning()
+}
+
+// Choose returns a peer from the underlying chooser, declining peers that
+// have failed recently with a probability of their error rate.
+func (c *Chooser) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	for attempt := 1; ; attempt++ {
+		p, onFinish, err := c.base.Choose(ctx, req)
+		if err != nil {
+			return p, onFinish, err
+		}
+
+		addr := p.Identifier()
+		if attempt < maxChooseAttempts && !c.accept(addr) {
+			onFinish(nil)
+			continue
+		}
+		return p, func(err error) {
+			onFinish(err)
+			c.record(addr, isFailure(err))
+		}, nil
+	}
+}
+
+// Weight returns the share of its requests, between 0 and 1, that the peer
+// with the given identifier receives.
+func (c *Chooser) Weight(addr string) float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.peerWeight(addr)
+}
+
+func isFailure(err error) bool {
+	return err != nil && yarpcerrors.GetFaultTypeFromError(err) != yarpcerrors.ClientFault
+}
+
+func (c *Chooser) accept(addr string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	weight := c.peerWeight(addr)
+	return weight >= 1 || c.random.Float64() < weight
+}
+
+// peerWeight returns the weight of a peer and updates its gauge. It must be
+// called under the lock of the chooser.
+func (c *Chooser) peerWeight(addr string) float64 {
+	status, ok := c.peers[addr]
+	if !ok {
+		return 1
+	}
+	weight := c.weight(status, c.now())
+	status.weight.Store(int64(math.Round(weight * 100)))
+	return weight
+}
+
+func (c *Chooser) record(addr string, failed bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	c.forgetIdle(now)
+
+	status, ok := c.peers[addr]
+	if !ok {
+		status = &peerStatus{weight: c.metrics.peerWeight(addr)}
+		c.peers[addr] = status
+	}
+	c.decay(status, now)
+	status.requests++
+	if failed {
+		status.failures++
+	}
+	status.weight.Store(int64(math.Round(c.weight(status, now) * 100)))
+}
+
+// forgetIdle forgets peers that have not been chosen for long enough that
+// their counts have decayed away, like peers removed from the underlying
+// chooser, at most once per half-life. It must be called under the lock of
+// the chooser.
+func (c *Chooser) forgetIdle(now time.Time) {
+	if now.Sub(c.lastForget) < c.halfLife {
+		return
+	}
+	c.lastForget = now
+	for addr, status := range c.peers {
+		c.decay(status, now)
+		if status.requests < idleRequests {
+			status.weight.Store(100)
+			delete(c.peers, addr)
+		}
+	}
+}
+
+// decay reduces the counts of a peer by the time elapsed since they were
+// last updated. It must be called under the lock of the chooser.
+func (c *Chooser) decay(status *peerStatus, now time.Time) {
+	if !status.updated.IsZero() {
+		factor := math.Exp2(-float64(now.Sub(status.updated)) / float64(c.halfLife))
+		status.failures *= factor
+		status.requests *= factor
+	}
+	status.updated = now
+}
+
+// weight returns the fraction of requests the peer receives. It must be
+// called under the lock of the chooser.
+func (c *Chooser) weight(status *peerStatus, now time.Time) float64 {
+	c.decay(status, now)
+	// The error rate counts at least one request, so that the failures of a
+	// peer that receives no more requests still decay away.
+	rate := status.failures / math.Max(status.requests, 1)
+	if rate < c.threshold {
+		return 1
+	}
+	return 1 - rate
+}
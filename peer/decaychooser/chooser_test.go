@@ -0,0 +1,222 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package decaychooser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+const halfLife = 10 * time.Second
+
+func peerAddr(i int) string {
+	return fmt.Sprintf("10.0.0.%d:4040", i)
+}
+
+type harness struct {
+	t       *testing.T
+	chooser *Chooser
+	clock   *yarpctest.FakeClock
+	root    *metrics.Root
+	// bad is the set of peers whose requests fail.
+	bad map[string]error
+}
+
+func newHarness(t *testing.T, peers int, opts ...Option) *harness {
+	root := metrics.New()
+	trans := yarpctest.NewFakeTransport()
+	clock := yarpctest.NewFakeClock()
+	list := roundrobin.New(trans)
+
+	opts = append([]Option{
+		Meter(root.Scope()),
+		Clock(clock),
+		Source(yarpctest.NewRandSource(0)),
+	}, opts...)
+	chooser := New(list, halfLife, opts...)
+
+	var ids []peer.Identifier
+	for i := 0; i < peers; i++ {
+		ids = append(ids, hostport.Identify(peerAddr(i)))
+	}
+	require.NoError(t, chooser.Start())
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: ids}))
+	trans.Flush()
+
+	return &harness{
+		t:       t,
+		chooser: chooser,
+		clock:   clock,
+		root:    root,
+		bad:     make(map[string]error),
+	}
+}
+
+// call sends n requests and returns the number of requests each peer
+// received. Requests whose results are not recorded leave the error rates of
+// the peers as they are.
+func (h *harness) call(n int, recordResults bool) map[string]int {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		p, onFinish, err := h.chooser.Choose(ctx, &transport.Request{})
+		require.NoError(h.t, err)
+		counts[p.Identifier()]++
+		if recordResults {
+			onFinish(h.bad[p.Identifier()])
+		}
+	}
+	return counts
+}
+
+// weightGauge returns the weight gauge of a peer, whose tag has the colon of
+// the address scrubbed.
+func (h *harness) weightGauge(addr string) int64 {
+	tag := strings.Replace(addr, ":", "_", -1)
+	for _, g := range h.root.Snapshot().Gauges {
+		if g.Name == "decay_peer_weight" && g.Tags[_peerTag] == tag {
+			return g.Value
+		}
+	}
+	h.t.Fatalf("no weight gauge for peer %q", addr)
+	return 0
+}
+
+func TestFailingPeerIsAvoided(t *testing.T) {
+	h := newHarness(t, 2)
+	h.bad[peerAddr(0)] = yarpcerrors.InternalErrorf("broken")
+
+	counts := h.call(100, true)
+	assert.True(t, counts[peerAddr(0)] < counts[peerAddr(1)]/2,
+		"failing peer must receive fewer requests, got %v", counts)
+	assert.Equal(t, 0.0, h.chooser.Weight(peerAddr(0)))
+	assert.Equal(t, 1.0, h.chooser.Weight(peerAddr(1)))
+	assert.Equal(t, int64(0), h.weightGauge(peerAddr(0)))
+	assert.Equal(t, int64(100), h.weightGauge(peerAddr(1)))
+
+	counts = h.call(100, true)
+	assert.Equal(t, map[string]int{peerAddr(1): 100}, counts,
+		"peer without weight must only be chosen as a last resort")
+}
+
+func TestPeersChosenInProportionToWeight(t *testing.T) {
+	h := newHarness(t, 2)
+	for i := 0; i < 50; i++ {
+		h.chooser.record(peerAddr(0), true)
+		h.chooser.record(peerAddr(0), false)
+	}
+	require.InDelta(t, 0.5, h.chooser.Weight(peerAddr(0)), 0.001)
+	assert.Equal(t, int64(50), h.weightGauge(peerAddr(0)))
+
+	counts := h.call(10000, false)
+	ratio := float64(counts[peerAddr(0)]) / float64(counts[peerAddr(1)])
+	assert.InDelta(t, 0.5, ratio, 0.05, "peers must be chosen in proportion to their weight, got %v", counts)
+}
+
+func TestErrorRateDecays(t *testing.T) {
+	h := newHarness(t, 2)
+	for i := 0; i < 50; i++ {
+		h.chooser.record(peerAddr(0), true)
+	}
+	assert.Equal(t, 0.0, h.chooser.Weight(peerAddr(0)))
+
+	// The error rate holds while the peer has many recent failures.
+	h.clock.Add(3 * halfLife)
+	assert.Equal(t, 0.0, h.chooser.Weight(peerAddr(0)))
+
+	// Once fewer than one failure remains, the rate decays with it.
+	h.clock.Add(4 * halfLife)
+	weight := h.chooser.Weight(peerAddr(0))
+	assert.True(t, weight > 0 && weight < 1, "weight must be partially restored, got %v", weight)
+
+	// Below the threshold, the peer is restored to its full weight.
+	h.clock.Add(5 * halfLife)
+	assert.Equal(t, 1.0, h.chooser.Weight(peerAddr(0)))
+	assert.Equal(t, int64(100), h.weightGauge(peerAddr(0)))
+}
+
+func TestFailuresBelowThreshold(t *testing.T) {
+	h := newHarness(t, 2, Threshold(0.1))
+	for i := 0; i < 100; i++ {
+		h.chooser.record(peerAddr(0), i < 9)
+	}
+	assert.Equal(t, 1.0, h.chooser.Weight(peerAddr(0)))
+
+	h.chooser.record(peerAddr(0), true)
+	h.chooser.record(peerAddr(0), true)
+	assert.InDelta(t, 1-11.0/102, h.chooser.Weight(peerAddr(0)), 0.001)
+}
+
+func TestClientFaultsAreNotFailures(t *testing.T) {
+	h := newHarness(t, 2)
+	h.bad[peerAddr(0)] = yarpcerrors.InvalidArgumentErrorf("bad request")
+
+	counts := h.call(100, true)
+	assert.Equal(t, map[string]int{peerAddr(0): 50, peerAddr(1): 50}, counts)
+	assert.Equal(t, 1.0, h.chooser.Weight(peerAddr(0)))
+}
+
+func TestIdlePeersAreForgotten(t *testing.T) {
+	h := newHarness(t, 2)
+	h.chooser.record(peerAddr(0), true)
+	h.chooser.record(peerAddr(1), false)
+	require.Len(t, h.chooser.peers, 2)
+
+	h.clock.Add(20 * halfLife)
+	h.chooser.record(peerAddr(1), false)
+	assert.Len(t, h.chooser.peers, 1)
+	assert.Contains(t, h.chooser.peers, peerAddr(1))
+	assert.Equal(t, int64(100), h.weightGauge(peerAddr(0)))
+}
+
+func TestChooseError(t *testing.T) {
+	list := roundrobin.New(yarpctest.NewFakeTransport(), roundrobin.FailFast())
+	chooser := New(list, halfLife)
+	require.NoError(t, chooser.Start())
+	assert.True(t, chooser.IsRunning())
+
+	_, _, err := chooser.Choose(context.Background(), &transport.Request{})
+	assert.Error(t, err)
+
+	require.NoError(t, chooser.Stop())
+	assert.False(t, chooser.IsRunning())
+}
+
+func TestDefaultHalfLife(t *testing.T) {
+	chooser := New(roundrobin.New(yarpctest.NewFakeTransport()), 0)
+	assert.Equal(t, defaultHalfLife, chooser.halfLife)
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package decaychooser provides a peer chooser decorator that sends fewer
+// requests to peers that fail, in proportion to their recent error rate.
+//
+// Peer lists only stop choosing a peer once it is unavailable. The decay
+// chooser measures the error rate of every peer it chooses, using the results
+// reported to the onFinish callback of each chosen peer, with counts that
+// decay exponentially over a half-life. When the underlying chooser offers a
+// peer with an error rate of, say, 30%, the decay chooser accepts it with a
+// probability of 70%, and otherwise asks the underlying chooser for another
+// peer, up to three times. Only server faults count as errors; client faults
+// like invalid arguments do not.
+//
+// 	list := roundrobin.New(trans)
+// 	chooser := decaychooser.New(list, 30*time.Second)
+//
+// Errors decay even while a peer receives no requests, so a peer restores to
+// its full share of requests once its error rate decays below the threshold.
+//
+// Peers the decay chooser declines are released with a nil error, so the
+// underlying chooser counts them as successful requests.
+package decaychooser
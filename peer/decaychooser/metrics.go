@@ -0,0 +1,50 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package decaychooser
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
+const _peerTag = "peer"
+
+type chooserMetrics struct {
+	weight *metrics.GaugeVector
+}
+
+func newChooserMetrics(meter *metrics.Scope, logger *zap.Logger) chooserMetrics {
+	weight, err := meter.GaugeVector(metrics.Spec{
+		Name:      "decay_peer_weight",
+		Help:      "Percentage of its share of requests a peer receives, given its error rate.",
+		ConstTags: metrics.Tags{"component": "yarpc"},
+		VarTags:   []string{_peerTag},
+	})
+	if err != nil {
+		logger.Error("failed to create decay peer weight gauge", zap.Error(err))
+	}
+
+	return chooserMetrics{weight: weight}
+}
+
+func (m chooserMetrics) peerWeight(addr string) *metrics.Gauge {
+	return m.weight.MustGet(_peerTag, addr)
+}
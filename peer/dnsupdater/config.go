@@ -0,0 +1,162 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dnsupdater
+
+import (
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// RefreshConfiguration describes how often and how records are resolved,
+// common to the "dns" and "dns-srv" peer list updaters.
+type RefreshConfiguration struct {
+	RefreshInterval *time.Duration `config:"refreshInterval"`
+	Jitter          *float64       `config:"jitter"`
+	ResolveTimeout  *time.Duration `config:"resolveTimeout"`
+}
+
+// HostConfiguration describes how to build a "dns" peer list updater.
+type HostConfiguration struct {
+	Host                 string `config:"host"`
+	Port                 int    `config:"port"`
+	RefreshConfiguration `config:",squash"`
+}
+
+// SRVConfiguration describes how to build a "dns-srv" peer list updater.
+type SRVConfiguration struct {
+	Name                 string `config:"name"`
+	RefreshConfiguration `config:",squash"`
+}
+
+// Spec returns a configuration specification for the "dns" peer list
+// updater, which binds a peer list to the A and AAAA records of a host.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerListUpdater(dnsupdater.Spec())
+//
+// This enables the dns peer list updater under any peer list:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: http://host/rpc
+//          round-robin:
+//            dns:
+//              host: otherservice.default.svc.cluster.local
+//              port: 8080
+//              refreshInterval: 30s
+//              jitter: 0.1
+//              resolveTimeout: 5s
+func Spec() yarpcconfig.PeerListUpdaterSpec {
+	return SpecWithOptions()
+}
+
+// SpecWithOptions accepts additional updater options.
+func SpecWithOptions(options ...Option) yarpcconfig.PeerListUpdaterSpec {
+	return yarpcconfig.PeerListUpdaterSpec{
+		Name: "dns",
+		BuildPeerListUpdater: func(cfg HostConfiguration, k *yarpcconfig.Kit) (peer.Binder, error) {
+			if cfg.Host == "" {
+				return nil, yarpcerrors.InvalidArgumentErrorf("dns peer list updater requires a host")
+			}
+			if cfg.Port <= 0 || cfg.Port > 65535 {
+				return nil, yarpcerrors.InvalidArgumentErrorf(
+					"Port must be between 1 and 65535. Got: %d.", cfg.Port)
+			}
+			opts, err := cfg.RefreshConfiguration.options(options)
+			if err != nil {
+				return nil, err
+			}
+			return BindHost(cfg.Host, cfg.Port, opts...), nil
+		},
+	}
+}
+
+// SRVSpec returns a configuration specification for the "dns-srv" peer list
+// updater, which binds a peer list to the targets of SRV records, weighted by
+// the weights of the records.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerListUpdater(dnsupdater.SRVSpec())
+//
+// This enables the dns-srv peer list updater under any peer list:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: http://host/rpc
+//          weighted-round-robin:
+//            dns-srv:
+//              name: _http._tcp.otherservice.default.svc.cluster.local
+//              refreshInterval: 30s
+func SRVSpec() yarpcconfig.PeerListUpdaterSpec {
+	return SRVSpecWithOptions()
+}
+
+// SRVSpecWithOptions accepts additional updater options.
+func SRVSpecWithOptions(options ...Option) yarpcconfig.PeerListUpdaterSpec {
+	return yarpcconfig.PeerListUpdaterSpec{
+		Name: "dns-srv",
+		BuildPeerListUpdater: func(cfg SRVConfiguration, k *yarpcconfig.Kit) (peer.Binder, error) {
+			if cfg.Name == "" {
+				return nil, yarpcerrors.InvalidArgumentErrorf("dns-srv peer list updater requires a name")
+			}
+			opts, err := cfg.RefreshConfiguration.options(options)
+			if err != nil {
+				return nil, err
+			}
+			return BindSRV(cfg.Name, opts...), nil
+		},
+	}
+}
+
+func (c RefreshConfiguration) options(options []Option) ([]Option, error) {
+	opts := make([]Option, 0, len(options)+3)
+	opts = append(opts, options...)
+
+	if c.RefreshInterval != nil {
+		if *c.RefreshInterval <= 0 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"RefreshInterval must be greater than 0. Got: %v.", *c.RefreshInterval)
+		}
+		opts = append(opts, RefreshInterval(*c.RefreshInterval))
+	}
+	if c.Jitter != nil {
+		if *c.Jitter < 0 || *c.Jitter > 1 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"Jitter must be between 0 and 1. Got: %v.", *c.Jitter)
+		}
+		opts = append(opts, Jitter(*c.Jitter))
+	}
+	if c.ResolveTimeout != nil {
+		if *c.ResolveTimeout <= 0 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"ResolveTimeout must be greater than 0. Got: %v.", *c.ResolveTimeout)
+		}
+		opts = append(opts, ResolveTimeout(*c.ResolveTimeout))
+	}
+	return opts, nil
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dnsupdater
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/internal/whitespace"
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func TestConfig(t *testing.T) {
+	resolver := newFakeResolver()
+	resolver.setHost("myservice", "10.0.0.1")
+	resolver.setSRV("_http._tcp.myservice", &net.SRV{Target: "a.myservice.", Port: 8080, Weight: 10})
+
+	tests := []struct {
+		desc     string
+		given    string
+		wantIDs  []peer.Identifier
+		wantOpts options
+		wantErr  string
+	}{
+		{
+			desc: "dns defaults",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								dns:
+									host: myservice
+									port: 8080
+			`,
+			wantIDs:  ids("10.0.0.1:8080"),
+			wantOpts: defaultOptions,
+		},
+		{
+			desc: "dns all options",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								dns:
+									host: myservice
+									port: 8080
+									refreshInterval: 1m
+									jitter: 0.2
+									resolveTimeout: 1s
+			`,
+			wantIDs: ids("10.0.0.1:8080"),
+			wantOpts: options{
+				refreshInterval: time.Minute,
+				jitter:          0.2,
+				resolveTimeout:  time.Second,
+			},
+		},
+		{
+			desc: "dns-srv",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								dns-srv:
+									name: _http._tcp.myservice
+									refreshInterval: 10s
+			`,
+			wantIDs: []peer.Identifier{
+				peerbind.IdentifyWeight(hostport.Identify("a.myservice:8080"), 10),
+			},
+			wantOpts: options{
+				refreshInterval: 10 * time.Second,
+				jitter:          defaultJitter,
+				resolveTimeout:  defaultResolveTimeout,
+			},
+		},
+		{
+			desc: "dns missing host",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								dns:
+									port: 8080
+			`,
+			wantErr: "dns peer list updater requires a host",
+		},
+		{
+			desc: "dns invalid port",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								dns:
+									host: myservice
+			`,
+			wantErr: "Port must be between 1 and 65535. Got: 0.",
+		},
+		{
+			desc: "dns-srv missing name",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								dns-srv: {}
+			`,
+			wantErr: "dns-srv peer list updater requires a name",
+		},
+		{
+			desc: "invalid refresh interval",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								dns-srv:
+									name: _http._tcp.myservice
+									refreshInterval: 0s
+			`,
+			wantErr: "RefreshInterval must be greater than 0. Got: 0s.",
+		},
+		{
+			desc: "invalid jitter",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								dns:
+									host: myservice
+									port: 8080
+									jitter: 2
+			`,
+			wantErr: "Jitter must be between 0 and 1. Got: 2.",
+		},
+		{
+			desc: "invalid resolve timeout",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								dns:
+									host: myservice
+									port: 8080
+									resolveTimeout: -1s
+			`,
+			wantErr: "ResolveTimeout must be greater than 0. Got: -1s.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfgr := yarpctest.NewFakeConfigurator()
+			cfgr.MustRegisterPeerListUpdater(SpecWithOptions(WithResolver(resolver)))
+			cfgr.MustRegisterPeerListUpdater(SRVSpecWithOptions(WithResolver(resolver)))
+
+			cfg, err := cfgr.LoadConfigFromYAML("test", strings.NewReader(whitespace.Expand(tt.given)))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			out := cfg.Outbounds["myservice"].Unary.(*yarpctest.FakeOutbound)
+			u, ok := out.Chooser().(*peerbind.BoundChooser).Updater().(*Updater)
+			require.True(t, ok, "expected a DNS updater")
+
+			gotIDs, err := u.resolve(context.Background(), u.opts.resolver)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIDs, gotIDs)
+
+			u.opts.resolver = nil
+			tt.wantOpts.resolver = nil
+			assert.Equal(t, tt.wantOpts, u.opts)
+		})
+	}
+}
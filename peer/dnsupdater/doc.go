@@ -0,0 +1,42 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package dnsupdater provides peer list updaters that discover peers by
+// periodically resolving DNS records, suitable for headless Kubernetes
+// services.
+//
+// BindHost resolves the A and AAAA records of a host name and pairs every
+// address with a fixed port:
+//
+// 	list := roundrobin.New(transport)
+// 	chooser := peer.Bind(list, dnsupdater.BindHost("myservice.default.svc.cluster.local", 8080))
+//
+// BindSRV resolves SRV records, which carry the port of every peer as well
+// as a weight. The weights are passed to the peer list with
+// peer.IdentifyWeight, so that weight-aware peer lists like
+// weightedroundrobin honor them:
+//
+// 	list := weightedroundrobin.New(transport)
+// 	chooser := peer.Bind(list, dnsupdater.BindSRV("_http._tcp.myservice.default.svc.cluster.local"))
+//
+// Records are resolved again on an interval with jitter, and only the
+// differences are sent to the peer list. If resolution fails, or returns no
+// records at all, the peer list retains its existing peers.
+package dnsupdater
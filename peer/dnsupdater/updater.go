@@ -0,0 +1,326 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dnsupdater
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/zap"
+
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/hostport"
+)
+
+const (
+	defaultRefreshInterval = 30 * time.Second
+	defaultJitter          = 0.1
+	defaultResolveTimeout  = 5 * time.Second
+)
+
+var errNoRecords = errors.New("no records found")
+
+// Resolver resolves DNS records.
+//
+// *net.Resolver satisfies this interface.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+}
+
+type options struct {
+	refreshInterval time.Duration
+	jitter          float64
+	resolveTimeout  time.Duration
+	resolver        Resolver
+	logger          *zap.Logger
+}
+
+var defaultOptions = options{
+	refreshInterval: defaultRefreshInterval,
+	jitter:          defaultJitter,
+	resolveTimeout:  defaultResolveTimeout,
+	resolver:        net.DefaultResolver,
+}
+
+// Option customizes the behavior of a DNS peer list updater.
+type Option func(*options)
+
+// RefreshInterval specifies how often records are resolved.
+//
+// Defaults to 30 seconds.
+func RefreshInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.refreshInterval = interval
+	}
+}
+
+// Jitter specifies the fraction, between 0 and 1, by which every refresh
+// interval is randomly lengthened or shortened, so that clients started at
+// the same time do not resolve records in lockstep.
+//
+// Defaults to 0.1.
+func Jitter(jitter float64) Option {
+	return func(o *options) {
+		o.jitter = jitter
+	}
+}
+
+// ResolveTimeout specifies the deadline for resolving records.
+//
+// Defaults to 5 seconds.
+func ResolveTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.resolveTimeout = timeout
+	}
+}
+
+// WithResolver specifies the resolver for DNS records.
+//
+// Defaults to net.DefaultResolver.
+func WithResolver(resolver Resolver) Option {
+	return func(o *options) {
+		o.resolver = resolver
+	}
+}
+
+// Logger specifies a logger.
+func Logger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// BindHost returns a binder (suitable as an argument to peer.Bind) that
+// binds a peer list to the addresses in the A and AAAA records of the given
+// host, each paired with the given port.
+func BindHost(host string, port int, opts ...Option) peer.Binder {
+	portStr := strconv.Itoa(port)
+	return bind(func(ctx context.Context, r Resolver) ([]peer.Identifier, error) {
+		addrs, err := r.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]peer.Identifier, len(addrs))
+		for i, addr := range addrs {
+			ids[i] = hostport.Identify(net.JoinHostPort(addr, portStr))
+		}
+		return ids, nil
+	}, host, opts)
+}
+
+// BindSRV returns a binder (suitable as an argument to peer.Bind) that binds
+// a peer list to the targets of the SRV records with the given name, like
+// "_http._tcp.myservice.default.svc.cluster.local".
+//
+// Peers are identified with their weight using peer.IdentifyWeight.
+func BindSRV(name string, opts ...Option) peer.Binder {
+	return bind(func(ctx context.Context, r Resolver) ([]peer.Identifier, error) {
+		_, records, err := r.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]peer.Identifier, len(records))
+		for i, record := range records {
+			addr := net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+			ids[i] = peerbind.IdentifyWeight(hostport.Identify(addr), int(record.Weight))
+		}
+		return ids, nil
+	}, name, opts)
+}
+
+type resolveFunc func(context.Context, Resolver) ([]peer.Identifier, error)
+
+func bind(resolve resolveFunc, name string, opts []Option) peer.Binder {
+	options := defaultOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	logger := options.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return func(pl peer.List) transport.Lifecycle {
+		return &Updater{
+			once:    lifecycle.NewOnce(),
+			pl:      pl,
+			resolve: resolve,
+			opts:    options,
+			logger:  logger.With(zap.String("name", name)),
+			rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+			after:   time.After,
+			peers:   make(map[string]peer.Identifier),
+		}
+	}
+}
+
+// Updater periodically resolves DNS records and updates a peer list with the
+// differences.
+type Updater struct {
+	once    *lifecycle.Once
+	pl      peer.List
+	resolve resolveFunc
+	opts    options
+	logger  *zap.Logger
+	rand    *rand.Rand
+	// after is replaced in tests to control refreshes.
+	after func(time.Duration) <-chan time.Time
+
+	stop chan struct{}
+	done chan struct{}
+
+	// peers is only accessed by the refresh loop.
+	peers map[string]peer.Identifier
+}
+
+// Start starts resolving records in the background.
+func (u *Updater) Start() error {
+	return u.once.Start(u.start)
+}
+
+func (u *Updater) start() error {
+	u.stop = make(chan struct{})
+	u.done = make(chan struct{})
+	// The peer list may block updates until it has started, so the updater
+	// must not block.
+	go u.run()
+	return nil
+}
+
+// Stop stops resolving records and removes all peers from the peer list.
+func (u *Updater) Stop() error {
+	return u.once.Stop(u.stopUpdates)
+}
+
+func (u *Updater) stopUpdates() error {
+	close(u.stop)
+	<-u.done
+
+	var updates peer.ListUpdates
+	for _, id := range u.peers {
+		updates.Removals = append(updates.Removals, id)
+	}
+	sortIdentifiers(updates.Removals)
+	u.peers = make(map[string]peer.Identifier)
+	if len(updates.Removals) == 0 {
+		return nil
+	}
+	return u.pl.Update(updates)
+}
+
+// IsRunning returns whether the updater is resolving records.
+func (u *Updater) IsRunning() bool {
+	return u.once.IsRunning()
+}
+
+func (u *Updater) run() {
+	defer close(u.done)
+
+	for {
+		u.refresh()
+		select {
+		case <-u.after(u.nextInterval()):
+		case <-u.stop:
+			return
+		}
+	}
+}
+
+func (u *Updater) nextInterval() time.Duration {
+	jitter := u.opts.jitter * (2*u.rand.Float64() - 1)
+	return time.Duration(float64(u.opts.refreshInterval) * (1 + jitter))
+}
+
+func (u *Updater) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), u.opts.resolveTimeout)
+	defer cancel()
+
+	ids, err := u.resolve(ctx, u.opts.resolver)
+	if err == nil && len(ids) == 0 {
+		err = errNoRecords
+	}
+	if err != nil {
+		u.logger.Warn("failed to resolve peers, retaining existing peers", zap.Error(err))
+		return
+	}
+
+	updates := u.diff(ids)
+	if len(updates.Additions) == 0 && len(updates.Removals) == 0 {
+		return
+	}
+	u.logger.Debug("resolved peer changes",
+		zap.Int("additions", len(updates.Additions)),
+		zap.Int("removals", len(updates.Removals)))
+	if err := u.pl.Update(updates); err != nil {
+		u.logger.Error("failed to update peer list", zap.Error(err))
+	}
+}
+
+// diff updates the known peers and returns the changes for the peer list.
+//
+// A peer whose weight changed is both removed and added, which weighted peer
+// lists treat as a change of weight.
+func (u *Updater) diff(ids []peer.Identifier) peer.ListUpdates {
+	next := make(map[string]peer.Identifier, len(ids))
+	for _, id := range ids {
+		next[id.Identifier()] = id
+	}
+
+	var updates peer.ListUpdates
+	for addr, id := range u.peers {
+		if nextID, ok := next[addr]; !ok || weight(nextID) != weight(id) {
+			updates.Removals = append(updates.Removals, id)
+		}
+	}
+	for addr, id := range next {
+		if prevID, ok := u.peers[addr]; !ok || weight(prevID) != weight(id) {
+			updates.Additions = append(updates.Additions, id)
+		}
+	}
+	u.peers = next
+	sortIdentifiers(updates.Removals)
+	sortIdentifiers(updates.Additions)
+	return updates
+}
+
+func sortIdentifiers(ids []peer.Identifier) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Identifier() < ids[j].Identifier()
+	})
+}
+
+func weight(id peer.Identifier) int {
+	if wid, ok := id.(peer.WeightedIdentifier); ok {
+		return wid.Weight()
+	}
+	return 0
+}
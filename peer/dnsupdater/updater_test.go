@@ -0,0 +1,276 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dnsupdater
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/internal/testtime"
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/hostport"
+)
+
+type fakeResolver struct {
+	sync.Mutex
+
+	hosts map[string][]string
+	srvs  map[string][]*net.SRV
+	err   error
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{
+		hosts: make(map[string][]string),
+		srvs:  make(map[string][]*net.SRV),
+	}
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.Lock()
+	defer r.Unlock()
+	return r.hosts[host], r.err
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.Lock()
+	defer r.Unlock()
+	return name, r.srvs[name], r.err
+}
+
+func (r *fakeResolver) setHost(host string, addrs ...string) {
+	r.Lock()
+	defer r.Unlock()
+	r.hosts[host] = addrs
+}
+
+func (r *fakeResolver) setSRV(name string, records ...*net.SRV) {
+	r.Lock()
+	defer r.Unlock()
+	r.srvs[name] = records
+}
+
+func (r *fakeResolver) setErr(err error) {
+	r.Lock()
+	defer r.Unlock()
+	r.err = err
+}
+
+type recordingList struct {
+	sync.Mutex
+
+	updates []peer.ListUpdates
+}
+
+func (l *recordingList) Update(updates peer.ListUpdates) error {
+	l.Lock()
+	defer l.Unlock()
+	l.updates = append(l.updates, updates)
+	return nil
+}
+
+func (l *recordingList) takeUpdates() []peer.ListUpdates {
+	l.Lock()
+	defer l.Unlock()
+	updates := l.updates
+	l.updates = nil
+	return updates
+}
+
+// refresher drives the refresh loop of an updater one resolution at a time.
+type refresher struct {
+	t       *testing.T
+	ticks   chan time.Time
+	waiting chan time.Duration
+}
+
+func newRefresher(t *testing.T, u *Updater) *refresher {
+	r := &refresher{
+		t:       t,
+		ticks:   make(chan time.Time),
+		waiting: make(chan time.Duration),
+	}
+	u.after = func(d time.Duration) <-chan time.Time {
+		r.waiting <- d
+		return r.ticks
+	}
+	return r
+}
+
+// wait blocks until the updater completes a resolution and returns the
+// interval until the next one.
+func (r *refresher) wait() time.Duration {
+	select {
+	case d := <-r.waiting:
+		return d
+	case <-time.After(testtime.Second):
+		r.t.Fatal("timed out waiting for resolution")
+		return 0
+	}
+}
+
+// refresh triggers a resolution and waits for it to complete.
+func (r *refresher) refresh() {
+	r.ticks <- time.Now()
+	r.wait()
+}
+
+func ids(addrs ...string) []peer.Identifier {
+	ids := make([]peer.Identifier, len(addrs))
+	for i, addr := range addrs {
+		ids[i] = hostport.Identify(addr)
+	}
+	return ids
+}
+
+func TestBindHost(t *testing.T) {
+	resolver := newFakeResolver()
+	resolver.setHost("myservice", "10.0.0.1", "10.0.0.2")
+
+	list := &recordingList{}
+	u := BindHost("myservice", 8080, WithResolver(resolver))(list).(*Updater)
+	r := newRefresher(t, u)
+
+	require.NoError(t, u.Start())
+	r.wait()
+	assert.True(t, u.IsRunning())
+	assert.Equal(t, []peer.ListUpdates{
+		{Additions: ids("10.0.0.1:8080", "10.0.0.2:8080")},
+	}, list.takeUpdates(), "initial resolution adds all peers")
+
+	t.Run("steady state", func(t *testing.T) {
+		r.refresh()
+		assert.Empty(t, list.takeUpdates(), "unchanged records must not update the list")
+	})
+
+	t.Run("add and remove", func(t *testing.T) {
+		resolver.setHost("myservice", "10.0.0.2", "10.0.0.3", "::1")
+		r.refresh()
+		assert.Equal(t, []peer.ListUpdates{
+			{
+				Additions: ids("10.0.0.3:8080", "[::1]:8080"),
+				Removals:  ids("10.0.0.1:8080"),
+			},
+		}, list.takeUpdates())
+	})
+
+	t.Run("failure retains peers", func(t *testing.T) {
+		resolver.setErr(errors.New("great sadness"))
+		r.refresh()
+		assert.Empty(t, list.takeUpdates(), "failed resolution must not update the list")
+		resolver.setErr(nil)
+	})
+
+	t.Run("empty result retains peers", func(t *testing.T) {
+		resolver.setHost("myservice")
+		r.refresh()
+		assert.Empty(t, list.takeUpdates(), "empty resolution must not update the list")
+	})
+
+	t.Run("recovery", func(t *testing.T) {
+		resolver.setHost("myservice", "10.0.0.3")
+		r.refresh()
+		assert.Equal(t, []peer.ListUpdates{
+			{Removals: ids("10.0.0.2:8080", "[::1]:8080")},
+		}, list.takeUpdates())
+	})
+
+	require.NoError(t, u.Stop())
+	assert.False(t, u.IsRunning())
+	assert.Equal(t, []peer.ListUpdates{
+		{Removals: ids("10.0.0.3:8080")},
+	}, list.takeUpdates(), "stop removes all peers")
+}
+
+func TestBindSRV(t *testing.T) {
+	const name = "_http._tcp.myservice"
+
+	resolver := newFakeResolver()
+	resolver.setSRV(name,
+		&net.SRV{Target: "a.myservice.", Port: 8080, Weight: 10},
+		&net.SRV{Target: "b.myservice.", Port: 8081, Weight: 20},
+	)
+
+	list := &recordingList{}
+	u := BindSRV(name, WithResolver(resolver))(list).(*Updater)
+	r := newRefresher(t, u)
+
+	require.NoError(t, u.Start())
+	r.wait()
+	assert.Equal(t, []peer.ListUpdates{
+		{Additions: []peer.Identifier{
+			peerbind.IdentifyWeight(hostport.Identify("a.myservice:8080"), 10),
+			peerbind.IdentifyWeight(hostport.Identify("b.myservice:8081"), 20),
+		}},
+	}, list.takeUpdates())
+
+	resolver.setSRV(name,
+		&net.SRV{Target: "a.myservice.", Port: 8080, Weight: 30},
+		&net.SRV{Target: "b.myservice.", Port: 8081, Weight: 20},
+	)
+	r.refresh()
+	assert.Equal(t, []peer.ListUpdates{
+		{
+			Additions: []peer.Identifier{peerbind.IdentifyWeight(hostport.Identify("a.myservice:8080"), 30)},
+			Removals:  []peer.Identifier{peerbind.IdentifyWeight(hostport.Identify("a.myservice:8080"), 10)},
+		},
+	}, list.takeUpdates(), "weight changes replace the peer")
+
+	require.NoError(t, u.Stop())
+	assert.Len(t, list.takeUpdates(), 1)
+}
+
+func TestRefreshIntervalJitter(t *testing.T) {
+	resolver := newFakeResolver()
+	resolver.setHost("myservice", "10.0.0.1")
+
+	u := BindHost("myservice", 80,
+		WithResolver(resolver),
+		RefreshInterval(10*time.Second),
+		Jitter(0.5),
+	)(&recordingList{}).(*Updater)
+	r := newRefresher(t, u)
+
+	require.NoError(t, u.Start())
+	defer func() { assert.NoError(t, u.Stop()) }()
+
+	interval := r.wait()
+	for i := 0; i < 10; i++ {
+		assert.True(t, interval >= 5*time.Second && interval <= 15*time.Second,
+			"interval %v out of jitter range", interval)
+		r.ticks <- time.Now()
+		interval = r.wait()
+	}
+}
+
+func TestStopBeforeStart(t *testing.T) {
+	list := &recordingList{}
+	u := BindHost("myservice", 80, WithResolver(newFakeResolver()))(list)
+	assert.NoError(t, u.Stop())
+	assert.Empty(t, list.takeUpdates())
+}
@@ -0,0 +1,322 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fallback
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/internal/window"
+	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMinRequests = 20
+	defaultWindow      = 10 * time.Second
+)
+
+type chooserOptions struct {
+	spillErrorRate float64
+	minRequests    int
+	window         time.Duration
+	meter          *metrics.Scope
+	logger         *zap.Logger
+}
+
+var defaultChooserOptions = chooserOptions{
+	minRequests: defaultMinRequests,
+	window:      defaultWindow,
+}
+
+// ChooserOption customizes the behavior of a fallback peer chooser.
+type ChooserOption func(*chooserOptions)
+
+// SpillErrorRate specifies the fraction of failed requests, between 0 and 1,
+// above which a group spills its requests to the next group with an
+// available peer.
+//
+// Defaults to 0, which spills requests only when a group has no available
+// peer.
+func SpillErrorRate(rate float64) ChooserOption {
+	return func(o *chooserOptions) {
+		o.spillErrorRate = rate
+	}
+}
+
+// MinRequests specifies the number of requests a group must have completed
+// within the window before its failure rate may spill requests.
+//
+// Defaults to 20.
+func MinRequests(n int) ChooserOption {
+	return func(o *chooserOptions) {
+		o.minRequests = n
+	}
+}
+
+// Window specifies the duration over which the failure rate of each group is
+// measured.
+//
+// Defaults to 10 seconds.
+func Window(d time.Duration) ChooserOption {
+	return func(o *chooserOptions) {
+		o.window = d
+	}
+}
+
+// Meter specifies the scope for the spilled requests counter.
+//
+// Metrics are registered when the chooser is constructed, so choosers
+// sharing a meter should each use a distinctly tagged scope.
+func Meter(meter *metrics.Scope) ChooserOption {
+	return func(o *chooserOptions) {
+		o.meter = meter
+	}
+}
+
+// Logger specifies a logger.
+func Logger(logger *zap.Logger) ChooserOption {
+	return func(o *chooserOptions) {
+		o.logger = logger
+	}
+}
+
+type group struct {
+	chooser peer.Chooser
+
+	// window is guarded by the chooser lock.
+	window window.Window
+}
+
+// New creates a peer chooser that chooses peers from the first of the given
+// groups, in order, that has an available peer and is not spilling requests
+// for its failure rate.
+//
+// If no group has an available peer, the chooser waits for a peer of the
+// first group.
+// The chooser starts and stops the groups with its own lifecycle.
+func New(groups []peer.Chooser, opts ...ChooserOption) *Chooser {
+	options := defaultChooserOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	logger := options.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	gs := make([]*group, len(groups))
+	for i, chooser := range groups {
+		gs[i] = &group{
+			chooser: chooser,
+			window:  window.New(options.window),
+		}
+	}
+
+	return &Chooser{
+		once:    lifecycle.NewOnce(),
+		groups:  gs,
+		opts:    options,
+		metrics: newChooserMetrics(options.meter, logger),
+		now:     time.Now,
+	}
+}
+
+var _ peer.Chooser = (*Chooser)(nil)
+var _ introspection.IntrospectableChooser = (*Chooser)(nil)
+
+// Chooser is a peer chooser that sends requests to a fallback group of
+// peers when the preceding groups cannot carry them.
+type Chooser struct {
+	once    *lifecycle.Once
+	groups  []*group
+	opts    chooserOptions
+	metrics chooserMetrics
+	now     func() time.Time
+
+	lock sync.Mutex
+}
+
+// Choose returns a peer from the first group that has an available peer and
+// is not spilling requests.
+func (c *Chooser) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	if len(c.groups) == 0 {
+		return nil, nil, yarpcerrors.UnavailableErrorf("fallback peer chooser has no groups of peers")
+	}
+
+	index := c.pick()
+	g := c.groups[index]
+	p, onFinish, err := g.chooser.Choose(ctx, req)
+	if err != nil {
+		return p, onFinish, err
+	}
+	if index > 0 {
+		c.metrics.spilled.Inc()
+	}
+
+	return p, func(err error) {
+		onFinish(err)
+		c.record(g, isFailure(err))
+	}, nil
+}
+
+// pick returns the index of the group to choose a peer from.
+func (c *Chooser) pick() int {
+	firstAvailable := -1
+	for i, g := range c.groups {
+		if !hasAvailablePeer(g.chooser) {
+			continue
+		}
+		if firstAvailable < 0 {
+			firstAvailable = i
+		}
+		if !c.spilling(g) {
+			return i
+		}
+	}
+	// Every available group is spilling, so the first of them carries the
+	// requests.
+	if firstAvailable >= 0 {
+		return firstAvailable
+	}
+	return 0
+}
+
+// spilling returns whether the group's failure rate exceeds the threshold.
+func (c *Chooser) spilling(g *group) bool {
+	if c.opts.spillErrorRate <= 0 {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	successes, failures := g.window.Counts(c.now())
+	total := successes + failures
+	if total == 0 || total < c.opts.minRequests {
+		return false
+	}
+	return float64(failures)/float64(total) > c.opts.spillErrorRate
+}
+
+func (c *Chooser) record(g *group, failed bool) {
+	if c.opts.spillErrorRate <= 0 {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	g.window.Record(c.now(), failed)
+}
+
+func isFailure(err error) bool {
+	return err != nil && yarpcerrors.GetFaultTypeFromError(err) != yarpcerrors.ClientFault
+}
+
+// hasAvailablePeer returns whether the chooser has an available peer,
+// assuming it does if the chooser does not reveal the status of its peers.
+func hasAvailablePeer(chooser peer.Chooser) bool {
+	if bound, ok := chooser.(interface{ ChooserList() peer.ChooserList }); ok {
+		return hasAvailablePeer(bound.ChooserList())
+	}
+	list, ok := chooser.(interface{ Peers() []peer.StatusPeer })
+	if !ok {
+		return true
+	}
+	for _, p := range list.Peers() {
+		if p.Status().ConnectionStatus == peer.Available {
+			return true
+		}
+	}
+	return false
+}
+
+// Start starts all groups of peers.
+func (c *Chooser) Start() error {
+	return c.once.Start(c.start)
+}
+
+func (c *Chooser) start() error {
+	for i, g := range c.groups {
+		if err := g.chooser.Start(); err != nil {
+			// Abort the groups that already started.
+			for _, started := range c.groups[:i] {
+				err = multierr.Append(err, started.chooser.Stop())
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop stops all groups of peers.
+func (c *Chooser) Stop() error {
+	return c.once.Stop(c.stop)
+}
+
+func (c *Chooser) stop() error {
+	var errs error
+	for _, g := range c.groups {
+		errs = multierr.Append(errs, g.chooser.Stop())
+	}
+	return errs
+}
+
+// IsRunning returns whether all groups of peers are running.
+func (c *Chooser) IsRunning() bool {
+	for _, g := range c.groups {
+		if !g.chooser.IsRunning() {
+			return false
+		}
+	}
+	return c.once.IsRunning()
+}
+
+// Introspect reveals the peers of all groups, in order, and the status of
+// the chooser of each group.
+func (c *Chooser) Introspect() introspection.ChooserStatus {
+	status := introspection.ChooserStatus{
+		Name:  "fallback",
+		State: c.once.State().String(),
+		Config: map[string]interface{}{
+			"spillErrorRate": c.opts.spillErrorRate,
+			"minRequests":    c.opts.minRequests,
+			"window":         c.opts.window.String(),
+		},
+	}
+	for _, g := range c.groups {
+		if ic, ok := g.chooser.(introspection.IntrospectableChooser); ok {
+			group := ic.Introspect()
+			status.Peers = append(status.Peers, group.Peers...)
+			status.Choosers = append(status.Choosers, group)
+		}
+	}
+	return status
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fallback
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func identifyAll(ids ...string) []peer.Identifier {
+	pids := make([]peer.Identifier, len(ids))
+	for i, id := range ids {
+		pids[i] = hostport.Identify(id)
+	}
+	return pids
+}
+
+// chooseGroups makes n requests, finishing each with the given error, and
+// returns the number of requests sent to each peer.
+func chooseGroups(t *testing.T, c *Chooser, n int, err error) map[string]int {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		p, onFinish, chooseErr := c.Choose(ctx, &transport.Request{})
+		require.NoError(t, chooseErr)
+		counts[p.Identifier()]++
+		onFinish(err)
+	}
+	return counts
+}
+
+func TestFailover(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	primary := roundrobin.New(trans)
+	secondary := roundrobin.New(trans)
+
+	root := metrics.New()
+	c := New([]peer.Chooser{primary, secondary}, Meter(root.Scope()))
+	require.NoError(t, c.Start())
+	defer c.Stop()
+	assert.True(t, c.IsRunning())
+
+	require.NoError(t, primary.Update(peer.ListUpdates{Additions: identifyAll("local-1", "local-2")}))
+	require.NoError(t, secondary.Update(peer.ListUpdates{Additions: identifyAll("remote-1")}))
+	trans.Flush()
+
+	assert.Equal(t, map[string]int{"local-1": 5, "local-2": 5}, chooseGroups(t, c, 10, nil),
+		"expected requests to go to the primary group")
+
+	require.NoError(t, primary.Update(peer.ListUpdates{Removals: identifyAll("local-1", "local-2")}))
+	trans.Flush()
+	assert.Equal(t, map[string]int{"remote-1": 10}, chooseGroups(t, c, 10, nil),
+		"expected requests to spill to the fallback group without primary peers")
+
+	require.NoError(t, primary.Update(peer.ListUpdates{Additions: identifyAll("local-1")}))
+	trans.Flush()
+	assert.Equal(t, map[string]int{"local-1": 10}, chooseGroups(t, c, 10, nil),
+		"expected requests to return to the recovered primary group")
+
+	trans.SimulateDisconnect(hostport.Identify("local-1"))
+	trans.Flush()
+	assert.Equal(t, map[string]int{"remote-1": 10}, chooseGroups(t, c, 10, nil),
+		"expected requests to spill to the fallback group without available primary peers")
+
+	snap := root.Snapshot()
+	require.Len(t, snap.Counters, 1)
+	assert.Equal(t, "fallback_spilled_requests", snap.Counters[0].Name)
+	assert.Equal(t, int64(20), snap.Counters[0].Value)
+}
+
+func TestNoAvailableGroup(t *testing.T) {
+	trans := yarpctest.NewFakeTransport(yarpctest.InitialConnectionStatus(peer.Unavailable))
+	primary := roundrobin.New(trans)
+	secondary := roundrobin.New(trans)
+
+	c := New([]peer.Chooser{primary, secondary})
+	require.NoError(t, c.Start())
+	defer c.Stop()
+
+	require.NoError(t, primary.Update(peer.ListUpdates{Additions: identifyAll("local-1")}))
+	require.NoError(t, secondary.Update(peer.ListUpdates{Additions: identifyAll("remote-1")}))
+	trans.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	// The chooser waits for a primary peer.
+	go func() {
+		trans.SimulateConnect(hostport.Identify("local-1"))
+	}()
+	p, onFinish, err := c.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	onFinish(nil)
+	assert.Equal(t, "local-1", p.Identifier())
+}
+
+func TestSpillErrorRate(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	primary := roundrobin.New(trans)
+	secondary := roundrobin.New(trans)
+
+	c := New([]peer.Chooser{primary, secondary}, SpillErrorRate(0.5), MinRequests(10), Window(time.Minute))
+	now := time.Unix(1000, 0)
+	c.now = func() time.Time { return now }
+	require.NoError(t, c.Start())
+	defer c.Stop()
+
+	require.NoError(t, primary.Update(peer.ListUpdates{Additions: identifyAll("local-1")}))
+	require.NoError(t, secondary.Update(peer.ListUpdates{Additions: identifyAll("remote-1")}))
+	trans.Flush()
+
+	// Client faults do not count as failures.
+	assert.Equal(t, map[string]int{"local-1": 10}, chooseGroups(t, c, 10, yarpcerrors.InvalidArgumentErrorf("bad request")))
+
+	// Once failures exceed half of the requests, requests spill.
+	assert.Equal(t, map[string]int{"local-1": 11}, chooseGroups(t, c, 11, yarpcerrors.UnavailableErrorf("overloaded")))
+	assert.Equal(t, map[string]int{"remote-1": 10}, chooseGroups(t, c, 10, nil))
+
+	// The primary group is retried once its failures age out of the window.
+	now = now.Add(time.Minute)
+	assert.Equal(t, map[string]int{"local-1": 10}, chooseGroups(t, c, 10, nil))
+}
+
+func TestEmptyChooser(t *testing.T) {
+	c := New(nil)
+	require.NoError(t, c.Start())
+	defer c.Stop()
+
+	_, _, err := c.Choose(context.Background(), &transport.Request{})
+	require.Error(t, err)
+	assert.True(t, yarpcerrors.IsUnavailable(err))
+}
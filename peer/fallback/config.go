@@ -0,0 +1,140 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fallback
+
+import (
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Configuration describes how to build a fallback peer chooser.
+type Configuration struct {
+	// SpillErrorRate is the fraction of failed requests above which a group
+	// spills its requests to the next group.
+	SpillErrorRate *float64 `config:"spillErrorRate"`
+	// MinRequests is the number of requests within the window required
+	// before a group spills for its failure rate.
+	MinRequests *int `config:"minRequests"`
+	// Window is the duration over which failure rates are measured.
+	Window *time.Duration `config:"window"`
+	// Groups are the peer chooser configurations of each group, in order of
+	// preference.
+	Groups []map[string]interface{} `config:"groups"`
+}
+
+// Spec returns a configuration specification for the fallback peer chooser,
+// making it possible to fail over between groups of peers with transports
+// that use outbound peer chooser configuration (like HTTP).
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerChooser(fallback.Spec())
+//  cfg.MustRegisterPeerList(roundrobin.Spec())
+//
+// Each group is configured like the peer chooser of an outbound, with its own
+// peer list and peer list updater, and groups are listed in order of
+// preference.
+// The failure rate thresholds are optional.
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          fallback:
+//            spillErrorRate: 0.5
+//            minRequests: 20
+//            window: 10s
+//            groups:
+//              - round-robin:
+//                  peers:
+//                    - 127.0.0.1:8080
+//                    - 127.0.0.1:8081
+//              - round-robin:
+//                  dns:
+//                    name: otherservice.remote.example.com
+func Spec() yarpcconfig.PeerChooserSpec {
+	return SpecWithOptions()
+}
+
+// SpecWithOptions accepts additional chooser constructor options.
+func SpecWithOptions(options ...ChooserOption) yarpcconfig.PeerChooserSpec {
+	return yarpcconfig.PeerChooserSpec{
+		Name: "fallback",
+		BuildPeerChooser: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.Chooser, error) {
+			if len(cfg.Groups) < 2 {
+				return nil, yarpcerrors.InvalidArgumentErrorf(
+					"fallback peer chooser requires at least 2 groups. Got: %d.", len(cfg.Groups))
+			}
+
+			opts, err := cfg.chooserOptions()
+			if err != nil {
+				return nil, err
+			}
+
+			groups := make([]peer.Chooser, 0, len(cfg.Groups))
+			for _, attrs := range cfg.Groups {
+				group, err := k.BuildPeerChooser(attrs, t)
+				if err != nil {
+					return nil, err
+				}
+				groups = append(groups, group)
+			}
+
+			var chooserOpts []ChooserOption
+			if meter := k.Meter(); meter != nil {
+				chooserOpts = append(chooserOpts, Meter(meter))
+			}
+			chooserOpts = append(chooserOpts, options...)
+			return New(groups, append(chooserOpts, opts...)...), nil
+		},
+	}
+}
+
+func (cfg Configuration) chooserOptions() ([]ChooserOption, error) {
+	var opts []ChooserOption
+
+	if cfg.SpillErrorRate != nil {
+		if *cfg.SpillErrorRate <= 0 || *cfg.SpillErrorRate > 1 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"SpillErrorRate must be greater than 0 and at most 1. Got: %v.", *cfg.SpillErrorRate)
+		}
+		opts = append(opts, SpillErrorRate(*cfg.SpillErrorRate))
+	}
+	if cfg.MinRequests != nil {
+		if *cfg.MinRequests <= 0 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"MinRequests must be greater than 0. Got: %d.", *cfg.MinRequests)
+		}
+		opts = append(opts, MinRequests(*cfg.MinRequests))
+	}
+	if cfg.Window != nil {
+		if *cfg.Window <= 0 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"Window must be greater than 0. Got: %v.", *cfg.Window)
+		}
+		opts = append(opts, Window(*cfg.Window))
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package fallback
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/whitespace"
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func TestFallbackConfig(t *testing.T) {
+	tests := []struct {
+		desc     string
+		given    string
+		wantOpts chooserOptions
+		wantErr  string
+	}{
+		{
+			desc: "defaults",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fallback:
+								groups:
+									- round-robin:
+											peers:
+												- 127.0.0.1:8080
+									- round-robin:
+											peers:
+												- 127.0.0.1:8081
+			`,
+			wantOpts: defaultChooserOptions,
+		},
+		{
+			desc: "all options",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fallback:
+								spillErrorRate: 0.25
+								minRequests: 5
+								window: 1m
+								groups:
+									- round-robin:
+											peers:
+												- 127.0.0.1:8080
+									- round-robin:
+											failFast: true
+											peers:
+												- 127.0.0.1:8081
+			`,
+			wantOpts: chooserOptions{
+				spillErrorRate: 0.25,
+				minRequests:    5,
+				window:         time.Minute,
+			},
+		},
+		{
+			desc: "single group",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fallback:
+								groups:
+									- round-robin:
+											peers:
+												- 127.0.0.1:8080
+			`,
+			wantErr: "fallback peer chooser requires at least 2 groups. Got: 1.",
+		},
+		{
+			desc: "group without updater",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fallback:
+								groups:
+									- round-robin:
+											peers:
+												- 127.0.0.1:8080
+									- round-robin: {}
+			`,
+			wantErr: "no recognized peer list updater in config",
+		},
+		{
+			desc: "invalid spill error rate",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fallback:
+								spillErrorRate: 1.5
+								groups:
+									- round-robin:
+											peers:
+												- 127.0.0.1:8080
+									- round-robin:
+											peers:
+												- 127.0.0.1:8081
+			`,
+			wantErr: "SpillErrorRate must be greater than 0 and at most 1. Got: 1.5.",
+		},
+		{
+			desc: "invalid window",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fallback:
+								window: 0s
+								groups:
+									- round-robin:
+											peers:
+												- 127.0.0.1:8080
+									- round-robin:
+											peers:
+												- 127.0.0.1:8081
+			`,
+			wantErr: "Window must be greater than 0. Got: 0s.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfgr := yarpctest.NewFakeConfigurator()
+			cfgr.MustRegisterPeerChooser(Spec())
+			cfgr.MustRegisterPeerList(roundrobin.Spec())
+
+			cfg, err := cfgr.LoadConfigFromYAML("test", strings.NewReader(whitespace.Expand(tt.given)))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			out := cfg.Outbounds["myservice"].Unary.(*yarpctest.FakeOutbound)
+			c, ok := out.Chooser().(*Chooser)
+			require.True(t, ok, "expected a fallback chooser, got %T", out.Chooser())
+			assert.Equal(t, tt.wantOpts, c.opts)
+			require.Len(t, c.groups, 2)
+			for _, g := range c.groups {
+				bound, ok := g.chooser.(*peerbind.BoundChooser)
+				require.True(t, ok, "expected a bound peer list, got %T", g.chooser)
+				assert.IsType(t, &roundrobin.List{}, bound.ChooserList())
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package filter
+
+import (
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Configuration describes how to build a filter peer list.
+type Configuration struct {
+	// Allow lists the addresses of the only peers to forward to the
+	// underlying peer list, if any.
+	Allow []string `config:"allow"`
+	// Deny lists the addresses of peers not to forward to the underlying
+	// peer list.
+	Deny []string `config:"deny"`
+	// With is the name of the underlying peer list, which chooses among the
+	// admitted peers.
+	With string `config:"with"`
+	// Etc captures the configuration of the underlying peer list.
+	Etc map[string]interface{} `config:",squash"`
+}
+
+// Spec returns a configuration specification for the filter peer list,
+// making it possible to keep individual peers out of rotation with
+// transports that use outbound peer list configuration (like HTTP).
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(filter.Spec())
+//  cfg.MustRegisterPeerList(roundrobin.Spec())
+//
+// The filter peer list must name the underlying peer list, registered with
+// the same Configurator, that chooses among the admitted peers.
+// Attributes other than allow and deny configure the underlying peer list.
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          filter:
+//            deny:
+//              - 127.0.0.1:8081
+//            with: round-robin
+//            peers:
+//              - 127.0.0.1:8080
+//              - 127.0.0.1:8081
+//
+// Other than a specific peer or peers list, use any peer list updater
+// registered with a yarpc Configurator.
+func Spec() yarpcconfig.PeerListSpec {
+	return SpecWithOptions()
+}
+
+// SpecWithOptions accepts additional list constructor options.
+func SpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
+	return yarpcconfig.PeerListSpec{
+		Name: "filter",
+		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
+			if cfg.With == "" {
+				return nil, yarpcerrors.InvalidArgumentErrorf(
+					"filter peer list requires the name of an underlying peer list in the \"with\" attribute")
+			}
+
+			opts := make([]ListOption, 0, len(options)+2)
+			opts = append(opts, options...)
+
+			if len(cfg.Allow) > 0 {
+				allowed := addressSet(cfg.Allow)
+				opts = append(opts, Allow(func(id peer.Identifier) bool {
+					_, ok := allowed[id.Identifier()]
+					return ok
+				}))
+			}
+			if len(cfg.Deny) > 0 {
+				denied := addressSet(cfg.Deny)
+				opts = append(opts, Deny(func(id peer.Identifier) bool {
+					_, ok := denied[id.Identifier()]
+					return ok
+				}))
+			}
+
+			list, err := k.BuildPeerList(cfg.With, cfg.Etc, t)
+			if err != nil {
+				return nil, err
+			}
+			return New(list, opts...), nil
+		},
+	}
+}
+
+func addressSet(addrs []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		set[addr] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package filter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/internal/whitespace"
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func TestFilterConfig(t *testing.T) {
+	tests := []struct {
+		desc    string
+		given   string
+		want    []string
+		wantErr string
+	}{
+		{
+			desc: "filter of round-robin",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							filter:
+								allow:
+									- 127.0.0.1:8080
+									- 127.0.0.1:8081
+								deny:
+									- 127.0.0.1:8081
+								with: round-robin
+								failFast: true
+								peers:
+									- 127.0.0.1:8080
+									- 127.0.0.1:8081
+									- 127.0.0.1:8082
+			`,
+			want: []string{"127.0.0.1:8080"},
+		},
+		{
+			desc: "missing underlying list",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							filter:
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: `requires the name of an underlying peer list in the "with" attribute`,
+		},
+		{
+			desc: "invalid underlying list attribute",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							filter:
+								with: round-robin
+								bogus: true
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "has invalid keys: bogus",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfgr := yarpctest.NewFakeConfigurator()
+			cfgr.MustRegisterPeerList(Spec())
+			cfgr.MustRegisterPeerList(roundrobin.Spec())
+
+			cfg, err := cfgr.LoadConfigFromYAML("test", strings.NewReader(whitespace.Expand(tt.given)))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			out := cfg.Outbounds["myservice"].Unary.(*yarpctest.FakeOutbound)
+			pl, ok := out.Chooser().(*peerbind.BoundChooser).ChooserList().(*List)
+			require.True(t, ok, "expected a filter list, got %T", out.Chooser())
+
+			d := yarpc.NewDispatcher(cfg)
+			require.NoError(t, d.Start())
+			defer d.Stop()
+
+			rr, ok := pl.list.(*roundrobin.List)
+			require.True(t, ok, "expected a round-robin list, got %T", pl.list)
+			var got []string
+			for _, p := range rr.Peers() {
+				got = append(got, p.Identifier())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
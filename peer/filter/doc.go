@@ -0,0 +1,34 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package filter provides a peer list decorator that forwards only the peers
+// it admits to an underlying peer list.
+//
+// Allow and deny predicates decide which of the peers from the peer list
+// updater reach the underlying list. At runtime, Block and Unblock take an
+// individual peer out of rotation and put it back, without redeploying or
+// reconfiguring the source of the peers, as during incident response.
+// Blocking a peer that the underlying list already retains removes it from
+// that list, and unblocking it adds it back if the updater still provides
+// it.
+//
+// NewHandler exposes Block and Unblock as an HTTP endpoint, for operators to
+// mount alongside the debug pages.
+package filter
@@ -0,0 +1,73 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package filter
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/peer/hostport"
+)
+
+// NewHandler returns an HTTP handler that blocks and unblocks the peers of
+// the given list at runtime, for operators to mount alongside the debug
+// pages.
+//
+//  mux.Handle("/debug/yarpc/peers/blocked", filter.NewHandler(list))
+//
+// GET lists the blocked peers, one per line. POST blocks the peers of the
+// "block" form values and unblocks the peers of the "unblock" form values,
+// then lists the blocked peers.
+//
+//  curl -d block=127.0.0.1:8080 http://localhost:8080/debug/yarpc/peers/blocked
+func NewHandler(list *List) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if err := req.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var errs error
+			for _, addr := range req.PostForm["block"] {
+				errs = multierr.Append(errs, list.Block(hostport.Identify(addr)))
+			}
+			for _, addr := range req.PostForm["unblock"] {
+				errs = multierr.Append(errs, list.Unblock(hostport.Identify(addr)))
+			}
+			if errs != nil {
+				http.Error(w, errs.Error(), http.StatusInternalServerError)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, addr := range list.Blocked() {
+			fmt.Fprintln(w, addr)
+		}
+	})
+}
@@ -0,0 +1,255 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package filter
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/zap"
+)
+
+type listOptions struct {
+	allow  func(peer.Identifier) bool
+	deny   func(peer.Identifier) bool
+	logger *zap.Logger
+}
+
+// ListOption customizes the behavior of a filter list.
+type ListOption func(*listOptions)
+
+// Allow specifies a predicate that peers must satisfy to be forwarded to the
+// underlying peer list.
+//
+// Defaults to allowing all peers.
+func Allow(allow func(peer.Identifier) bool) ListOption {
+	return func(o *listOptions) {
+		o.allow = allow
+	}
+}
+
+// Deny specifies a predicate for peers that must not be forwarded to the
+// underlying peer list. Deny takes precedence over Allow.
+//
+// Defaults to denying no peers.
+func Deny(deny func(peer.Identifier) bool) ListOption {
+	return func(o *listOptions) {
+		o.deny = deny
+	}
+}
+
+// Logger specifies a logger for blocking and unblocking peers.
+func Logger(logger *zap.Logger) ListOption {
+	return func(o *listOptions) {
+		o.logger = logger
+	}
+}
+
+// New creates a peer list that forwards the peers it admits to the given
+// peer list.
+func New(list peer.ChooserList, opts ...ListOption) *List {
+	var options listOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	logger := options.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &List{
+		list:      list,
+		allow:     options.allow,
+		deny:      options.deny,
+		logger:    logger,
+		peers:     make(map[string]peer.Identifier),
+		forwarded: make(map[string]peer.Identifier),
+		blocked:   make(map[string]struct{}),
+	}
+}
+
+var _ peer.ChooserList = (*List)(nil)
+var _ introspection.IntrospectableChooser = (*List)(nil)
+
+// List is a peer list that forwards the peers it admits to an underlying
+// peer list, which chooses among them.
+type List struct {
+	list   peer.ChooserList
+	allow  func(peer.Identifier) bool
+	deny   func(peer.Identifier) bool
+	logger *zap.Logger
+
+	lock      sync.Mutex
+	peers     map[string]peer.Identifier
+	forwarded map[string]peer.Identifier
+	blocked   map[string]struct{}
+}
+
+// Update adds and removes peers from the list, and forwards the changes to
+// the admitted peers to the underlying peer list.
+func (l *List) Update(updates peer.ListUpdates) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var errs error
+	var forward peer.ListUpdates
+	for _, id := range updates.Removals {
+		addr := id.Identifier()
+		if _, ok := l.peers[addr]; !ok {
+			errs = multierr.Append(errs, peer.ErrPeerRemoveNotInList(addr))
+			continue
+		}
+		delete(l.peers, addr)
+		if fid, ok := l.forwarded[addr]; ok {
+			delete(l.forwarded, addr)
+			forward.Removals = append(forward.Removals, fid)
+		}
+	}
+	for _, id := range updates.Additions {
+		addr := id.Identifier()
+		if _, ok := l.peers[addr]; ok {
+			errs = multierr.Append(errs, peer.ErrPeerAddAlreadyInList(addr))
+			continue
+		}
+		l.peers[addr] = id
+		if l.admits(id) {
+			l.forwarded[addr] = id
+			forward.Additions = append(forward.Additions, id)
+		}
+	}
+
+	if len(forward.Additions) == 0 && len(forward.Removals) == 0 {
+		return errs
+	}
+	return multierr.Append(errs, l.list.Update(forward))
+}
+
+// admits must be called under the list lock.
+func (l *List) admits(id peer.Identifier) bool {
+	if _, ok := l.blocked[id.Identifier()]; ok {
+		return false
+	}
+	if l.deny != nil && l.deny(id) {
+		return false
+	}
+	return l.allow == nil || l.allow(id)
+}
+
+// Block takes the peer with the given identifier out of rotation, until it
+// is unblocked. Peers may be blocked before the list receives them.
+//
+// If the underlying peer list already retains the peer, Block removes it
+// from that list.
+func (l *List) Block(id peer.Identifier) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	addr := id.Identifier()
+	if _, ok := l.blocked[addr]; ok {
+		return nil
+	}
+	l.blocked[addr] = struct{}{}
+	l.logger.Info("blocked peer", zap.String("peer", addr))
+
+	fid, ok := l.forwarded[addr]
+	if !ok {
+		return nil
+	}
+	delete(l.forwarded, addr)
+	return l.list.Update(peer.ListUpdates{Removals: []peer.Identifier{fid}})
+}
+
+// Unblock puts the peer with the given identifier back into rotation.
+//
+// If the list has received the peer and admits it, Unblock adds it to the
+// underlying peer list.
+func (l *List) Unblock(id peer.Identifier) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	addr := id.Identifier()
+	if _, ok := l.blocked[addr]; !ok {
+		return nil
+	}
+	delete(l.blocked, addr)
+	l.logger.Info("unblocked peer", zap.String("peer", addr))
+
+	pid, ok := l.peers[addr]
+	if !ok || !l.admits(pid) {
+		return nil
+	}
+	l.forwarded[addr] = pid
+	return l.list.Update(peer.ListUpdates{Additions: []peer.Identifier{pid}})
+}
+
+// Blocked returns the identifiers of the blocked peers, in sorted order.
+func (l *List) Blocked() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	addrs := make([]string, 0, len(l.blocked))
+	for addr := range l.blocked {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// Choose returns a peer from the underlying peer list.
+func (l *List) Choose(ctx context.Context, req *transport.Request) (peer peer.Peer, onFinish func(error), err error) {
+	return l.list.Choose(ctx, req)
+}
+
+// Start starts the underlying peer list.
+func (l *List) Start() error {
+	return l.list.Start()
+}
+
+// Stop stops the underlying peer list.
+func (l *List) Stop() error {
+	return l.list.Stop()
+}
+
+// IsRunning returns whether the underlying peer list is running.
+func (l *List) IsRunning() bool {
+	return l.list.IsRunning()
+}
+
+// Introspect introspects the underlying peer list, and lists the peers that
+// are blocked in addition to its peers.
+func (l *List) Introspect() introspection.ChooserStatus {
+	var status introspection.ChooserStatus
+	if ic, ok := l.list.(introspection.IntrospectableChooser); ok {
+		status = ic.Introspect()
+	}
+	for _, addr := range l.Blocked() {
+		status.Peers = append(status.Peers, introspection.PeerStatus{
+			Identifier: addr,
+			State:      "blocked",
+		})
+	}
+	return status
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package filter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+var (
+	id1 = hostport.Identify("10.0.0.1:4040")
+	id2 = hostport.Identify("10.0.0.2:4040")
+	id3 = hostport.Identify("10.0.0.3:4040")
+)
+
+// recordingList records the peers forwarded to it.
+type recordingList struct {
+	*yarpctest.FakePeerList
+
+	peers map[string]struct{}
+}
+
+func newRecordingList() *recordingList {
+	return &recordingList{
+		FakePeerList: yarpctest.NewFakePeerList(),
+		peers:        make(map[string]struct{}),
+	}
+}
+
+func (l *recordingList) Update(updates peer.ListUpdates) error {
+	for _, id := range updates.Removals {
+		delete(l.peers, id.Identifier())
+	}
+	for _, id := range updates.Additions {
+		l.peers[id.Identifier()] = struct{}{}
+	}
+	return nil
+}
+
+func set(ids ...peer.Identifier) map[string]struct{} {
+	s := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		s[id.Identifier()] = struct{}{}
+	}
+	return s
+}
+
+func TestBlockBeforeAdd(t *testing.T) {
+	rec := newRecordingList()
+	pl := New(rec)
+
+	require.NoError(t, pl.Block(id2))
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: []peer.Identifier{id1, id2}}))
+	assert.Equal(t, set(id1), rec.peers)
+	assert.Equal(t, []string{id2.Identifier()}, pl.Blocked())
+
+	// Removing a blocked peer changes nothing downstream, and unblocking a
+	// peer that is gone adds nothing.
+	require.NoError(t, pl.Update(peer.ListUpdates{Removals: []peer.Identifier{id2}}))
+	require.NoError(t, pl.Unblock(id2))
+	assert.Equal(t, set(id1), rec.peers)
+	assert.Empty(t, pl.Blocked())
+}
+
+func TestBlockAfterAdd(t *testing.T) {
+	rec := newRecordingList()
+	pl := New(rec)
+
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: []peer.Identifier{id1, id2}}))
+	assert.Equal(t, set(id1, id2), rec.peers)
+
+	require.NoError(t, pl.Block(id2))
+	assert.Equal(t, set(id1), rec.peers, "blocking a retained peer must remove it downstream")
+	require.NoError(t, pl.Block(id2), "blocking twice must be a no-op")
+
+	// The updater removing and adding the peer again does not restore it.
+	require.NoError(t, pl.Update(peer.ListUpdates{Removals: []peer.Identifier{id2}}))
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: []peer.Identifier{id2}}))
+	assert.Equal(t, set(id1), rec.peers)
+
+	require.NoError(t, pl.Unblock(id2))
+	assert.Equal(t, set(id1, id2), rec.peers)
+	require.NoError(t, pl.Unblock(id2), "unblocking twice must be a no-op")
+}
+
+func TestAllowDeny(t *testing.T) {
+	rec := newRecordingList()
+	pl := New(rec,
+		Allow(func(id peer.Identifier) bool { return id != id3 }),
+		Deny(func(id peer.Identifier) bool { return id == id2 }))
+
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: []peer.Identifier{id1, id2, id3}}))
+	assert.Equal(t, set(id1), rec.peers)
+
+	// Unblocking does not override the predicates.
+	require.NoError(t, pl.Block(id2))
+	require.NoError(t, pl.Unblock(id2))
+	assert.Equal(t, set(id1), rec.peers)
+}
+
+func TestListInvalidUpdates(t *testing.T) {
+	pl := New(newRecordingList())
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: []peer.Identifier{id1}}))
+
+	err := pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{id1},
+		Removals:  []peer.Identifier{id2},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `can't add peer "10.0.0.1:4040" because is already in peerlist`)
+	assert.Contains(t, err.Error(), `can't remove peer (10.0.0.2:4040) because it is not in peerlist`)
+}
+
+func TestUnblockRestoresTraffic(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	pl := New(roundrobin.New(trans))
+	require.NoError(t, pl.Start())
+	defer pl.Stop()
+	assert.True(t, pl.IsRunning())
+
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: []peer.Identifier{id1, id2}}))
+	trans.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	chosen := func() map[string]int {
+		counts := make(map[string]int)
+		for i := 0; i < 10; i++ {
+			p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+			require.NoError(t, err)
+			onFinish(nil)
+			counts[p.Identifier()]++
+		}
+		return counts
+	}
+
+	require.NoError(t, pl.Block(id2))
+	assert.Equal(t, map[string]int{id1.Identifier(): 10}, chosen())
+
+	status := pl.Introspect()
+	assert.Equal(t, "round-robin", status.Name)
+	var states []string
+	for _, ps := range status.Peers {
+		states = append(states, ps.Identifier+" "+strings.SplitN(ps.State, ",", 2)[0])
+	}
+	assert.ElementsMatch(t, []string{"10.0.0.1:4040 Available", "10.0.0.2:4040 blocked"}, states)
+
+	require.NoError(t, pl.Unblock(id2))
+	trans.Flush()
+	assert.Equal(t, map[string]int{id1.Identifier(): 5, id2.Identifier(): 5}, chosen())
+}
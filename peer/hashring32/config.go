@@ -84,9 +84,10 @@ type Config struct {
 // Spec returns a configuration specification for the hashed peer list
 // implementation, making it possible to select peer based on a specified hashing
 // function.
+//
+// Peer list metrics are recorded to the given meter, or to the metrics scope
+// of the Configurator if the meter is nil.
 func Spec(logger *zap.Logger, meter *metrics.Scope) yarpcconfig.PeerListSpec {
-	// TODO thread meter through list options to abstract list metrics.
-
 	return yarpcconfig.PeerListSpec{
 		Name: "hashring32",
 		BuildPeerList: func(c Config, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
@@ -98,6 +99,12 @@ func Spec(logger *zap.Logger, meter *metrics.Scope) yarpcconfig.PeerListSpec {
 				Logger(logger),
 			}
 
+			if meter != nil {
+				opts = append(opts, Meter(meter))
+			} else if meter := k.Meter(); meter != nil {
+				opts = append(opts, Meter(meter))
+			}
+
 			if c.DefaultChooseTimeout != nil {
 				opts = append(opts, DefaultChooseTimeout(*c.DefaultChooseTimeout))
 			}
@@ -26,11 +26,13 @@ import (
 	"context"
 	"time"
 
+	"go.uber.org/net/metrics"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/peer/abstractlist"
 	"go.uber.org/yarpc/peer/hashring32/internal/hashring32"
+	"go.uber.org/yarpc/pkg/clock"
 	"go.uber.org/zap"
 )
 
@@ -42,6 +44,10 @@ type options struct {
 	peerRingOptions         []hashring32.Option
 	defaultChooseTimeout    *time.Duration
 	logger                  *zap.Logger
+	meter                   *metrics.Scope
+	pendingTopK             int
+	drainTimeout            time.Duration
+	clock                   clock.Clock
 }
 
 // Option customizes the behavior of hashring32 peer list.
@@ -205,6 +211,48 @@ func DefaultChooseTimeout(timeout time.Duration) Option {
 	})
 }
 
+// Meter specifies the scope for peer list metrics, like the numbers of
+// available and unavailable peers and the latency of choosing a peer.
+//
+// Lists sharing a meter should each use a distinctly tagged scope.
+func Meter(meter *metrics.Scope) Option {
+	return optionFunc(func(options *options) {
+		options.meter = meter
+	})
+}
+
+// PendingRequestsTopK specifies the number of busiest peers whose pending
+// request counts are reported, by rank, in addition to the total.
+//
+// Defaults to 0, reporting only the total.
+func PendingRequestsTopK(k int) Option {
+	return optionFunc(func(options *options) {
+		options.pendingTopK = k
+	})
+}
+
+// DrainTimeout specifies how long the list waits for the requests in flight
+// to a removed peer to finish before releasing the peer to the transport.
+// Removed peers are no longer chosen, and are released once their last
+// pending request finishes or the timeout elapses.
+//
+// Defaults to 0, releasing removed peers immediately.
+func DrainTimeout(timeout time.Duration) Option {
+	return optionFunc(func(options *options) {
+		options.drainTimeout = timeout
+	})
+}
+
+// Clock specifies the clock that times out draining peers, for tests that
+// control time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) Option {
+	return optionFunc(func(options *options) {
+		options.clock = c
+	})
+}
+
 type optionFunc func(*options)
 
 func (f optionFunc) apply(options *options) { f(options) }
@@ -236,6 +284,15 @@ func New(transport peer.Transport, hashFunc hashring32.HashFunc32, opts ...Optio
 	if options.defaultChooseTimeout != nil {
 		plOpts = append(plOpts, abstractlist.DefaultChooseTimeout(*options.defaultChooseTimeout))
 	}
+	if options.drainTimeout > 0 {
+		plOpts = append(plOpts, abstractlist.DrainTimeout(options.drainTimeout))
+	}
+	if options.clock != nil {
+		plOpts = append(plOpts, abstractlist.Clock(options.clock))
+	}
+	if options.meter != nil {
+		plOpts = append(plOpts, abstractlist.Meter(options.meter), abstractlist.PendingRequestsTopK(options.pendingTopK))
+	}
 
 	return &List{
 		list: abstractlist.New("hashring32", transport, ring, plOpts...),
@@ -309,7 +366,18 @@ func (l *List) NotifyStatusChanged(pid peer.Identifier) {
 // Introspect reveals information about the list to the internal YARPC
 // introspection system.
 func (l *List) Introspect() introspection.ChooserStatus {
-	return l.list.Introspect()
+	status := l.list.Introspect()
+	status.Config = l.list.IntrospectConfig()
+	return status
+}
+
+// Subscribe registers a function to call with the connection status of each
+// peer that is added to the list, removed from the list, or changes status.
+//
+// The function is called from a separate goroutine, and updates that arrive
+// while it is busy are coalesced, so a slow subscriber never blocks the list.
+func (l *List) Subscribe(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func()) {
+	return l.list.Subscribe(fn)
 }
 
 // Peers produces a slice of all retained peers.
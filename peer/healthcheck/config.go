@@ -0,0 +1,175 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package healthcheck
+
+import (
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Configuration describes how to build a health checking peer list.
+type Configuration struct {
+	// Interval is how often every peer is probed.
+	Interval *time.Duration `config:"interval"`
+	// Timeout is the deadline of every probe.
+	Timeout *time.Duration `config:"timeout"`
+	// UnhealthyThreshold is the number of consecutive failed probes after
+	// which a peer is withheld from the underlying peer list.
+	UnhealthyThreshold *int `config:"unhealthyThreshold"`
+	// HealthyThreshold is the number of consecutive successful probes after
+	// which an unhealthy peer is returned to the underlying peer list.
+	HealthyThreshold *int `config:"healthyThreshold"`
+	// Concurrency is the largest number of probes in flight at once.
+	Concurrency *int `config:"concurrency"`
+	// Procedure is the procedure called to probe a peer.
+	Procedure string `config:"procedure"`
+	// With is the name of the underlying peer list, which chooses among the
+	// healthy peers.
+	With string `config:"with"`
+	// Etc captures the configuration of the underlying peer list.
+	Etc map[string]interface{} `config:",squash"`
+}
+
+// Spec returns a configuration specification for the health checking peer
+// list, making it possible to probe peers with transports that use outbound
+// peer list configuration (like HTTP).
+//
+// Probes are sent through outbounds built by the given function, each bound
+// to a single peer with the transport of the outbound.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(healthcheck.Spec(
+//    func(t peer.Transport, c peer.Chooser) (transport.UnaryOutbound, error) {
+//      return t.(*http.Transport).NewOutbound(c), nil
+//    },
+//  ))
+//  cfg.MustRegisterPeerList(roundrobin.Spec())
+//
+// The health checking peer list must name the underlying peer list,
+// registered with the same Configurator, that chooses among the healthy
+// peers.
+// Attributes other than the health check settings configure the underlying
+// peer list.
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          health-check:
+//            interval: 5s
+//            timeout: 1s
+//            unhealthyThreshold: 3
+//            healthyThreshold: 2
+//            concurrency: 10
+//            procedure: health
+//            with: round-robin
+//            peers:
+//              - 127.0.0.1:8080
+//              - 127.0.0.1:8081
+//
+// Other than a specific peer or peers list, use any peer list updater
+// registered with a yarpc Configurator.
+func Spec(newOutbound NewOutboundFunc) yarpcconfig.PeerListSpec {
+	return SpecWithOptions(newOutbound)
+}
+
+// SpecWithOptions accepts additional list constructor options, such as a
+// Logger.
+func SpecWithOptions(newOutbound NewOutboundFunc, options ...ListOption) yarpcconfig.PeerListSpec {
+	return yarpcconfig.PeerListSpec{
+		Name: "health-check",
+		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
+			if cfg.With == "" {
+				return nil, yarpcerrors.InvalidArgumentErrorf(
+					"health-check peer list requires the name of an underlying peer list in the \"with\" attribute")
+			}
+
+			opts, err := cfg.listOptions()
+			if err != nil {
+				return nil, err
+			}
+
+			list, err := k.BuildPeerList(cfg.With, cfg.Etc, t)
+			if err != nil {
+				return nil, err
+			}
+
+			proberOpts := []ProberOption{
+				Service(k.OutboundServiceName()),
+				Caller(k.ServiceName()),
+			}
+			if cfg.Procedure != "" {
+				proberOpts = append(proberOpts, Procedure(cfg.Procedure))
+			}
+			prober := NewOutboundProber(t, newOutbound, proberOpts...)
+			return New(list, prober, append(options, opts...)...), nil
+		},
+	}
+}
+
+func (cfg Configuration) listOptions() ([]ListOption, error) {
+	var opts []ListOption
+
+	counts := []struct {
+		name   string
+		value  *int
+		option func(int) ListOption
+	}{
+		{"UnhealthyThreshold", cfg.UnhealthyThreshold, UnhealthyThreshold},
+		{"HealthyThreshold", cfg.HealthyThreshold, HealthyThreshold},
+		{"Concurrency", cfg.Concurrency, Concurrency},
+	}
+	for _, c := range counts {
+		if c.value == nil {
+			continue
+		}
+		if *c.value <= 0 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"%s must be greater than 0. Got: %d.", c.name, *c.value)
+		}
+		opts = append(opts, c.option(*c.value))
+	}
+
+	durations := []struct {
+		name   string
+		value  *time.Duration
+		option func(time.Duration) ListOption
+	}{
+		{"Interval", cfg.Interval, Interval},
+		{"Timeout", cfg.Timeout, Timeout},
+	}
+	for _, d := range durations {
+		if d.value == nil {
+			continue
+		}
+		if *d.value <= 0 {
+			return nil, yarpcerrors.InvalidArgumentErrorf(
+				"%s must be greater than 0. Got: %v.", d.name, *d.value)
+		}
+		opts = append(opts, d.option(*d.value))
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package healthcheck
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/whitespace"
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func TestHealthCheckConfig(t *testing.T) {
+	tests := []struct {
+		desc          string
+		given         string
+		wantOpts      listOptions
+		wantProcedure string
+		wantErr       string
+	}{
+		{
+			desc: "defaults",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							health-check:
+								with: round-robin
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantOpts:      defaultListOptions,
+			wantProcedure: DefaultProcedure,
+		},
+		{
+			desc: "all options",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							health-check:
+								interval: 1m
+								timeout: 2s
+								unhealthyThreshold: 5
+								healthyThreshold: 4
+								concurrency: 3
+								procedure: Meta::health
+								with: round-robin
+								failFast: true
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantOpts: listOptions{
+				interval:           time.Minute,
+				timeout:            2 * time.Second,
+				unhealthyThreshold: 5,
+				healthyThreshold:   4,
+				concurrency:        3,
+			},
+			wantProcedure: "Meta::health",
+		},
+		{
+			desc: "missing underlying list",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							health-check:
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: `requires the name of an underlying peer list in the "with" attribute`,
+		},
+		{
+			desc: "invalid threshold",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							health-check:
+								unhealthyThreshold: 0
+								with: round-robin
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "UnhealthyThreshold must be greater than 0. Got: 0.",
+		},
+		{
+			desc: "invalid concurrency",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							health-check:
+								concurrency: -1
+								with: round-robin
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "Concurrency must be greater than 0. Got: -1.",
+		},
+		{
+			desc: "invalid interval",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							health-check:
+								interval: 0s
+								with: round-robin
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "Interval must be greater than 0. Got: 0s.",
+		},
+	}
+
+	newOutbound := func(t peer.Transport, c peer.Chooser) (transport.UnaryOutbound, error) {
+		return t.(*yarpctest.FakeTransport).NewOutbound(c), nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfgr := yarpctest.NewFakeConfigurator()
+			cfgr.MustRegisterPeerList(Spec(newOutbound))
+			cfgr.MustRegisterPeerList(roundrobin.Spec())
+
+			cfg, err := cfgr.LoadConfigFromYAML("test", strings.NewReader(whitespace.Expand(tt.given)))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			out := cfg.Outbounds["myservice"].Unary.(*yarpctest.FakeOutbound)
+			pl, ok := out.Chooser().(*peerbind.BoundChooser).ChooserList().(*List)
+			require.True(t, ok, "expected a health checking list, got %T", out.Chooser())
+			assert.Equal(t, tt.wantOpts, pl.opts)
+			assert.IsType(t, &roundrobin.List{}, pl.list)
+
+			prober, ok := pl.prober.(*outboundProber)
+			require.True(t, ok, "expected an outbound prober, got %T", pl.prober)
+			assert.Equal(t, proberOptions{
+				procedure: tt.wantProcedure,
+				service:   "myservice",
+				caller:    "test",
+			}, prober.opts)
+		})
+	}
+}
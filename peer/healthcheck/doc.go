@@ -0,0 +1,43 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package healthcheck provides a peer list that actively probes the health
+// of its peers.
+//
+// Connection status alone does not show whether a peer is serving requests.
+// The health checking peer list periodically probes every peer it retains and
+// forwards only healthy peers to an underlying peer list, so that its chooser
+// skips peers that fail consecutive probes:
+//
+// 	trans := http.NewTransport()
+// 	prober := healthcheck.NewOutboundProber(trans,
+// 		func(t peer.Transport, c peer.Chooser) (transport.UnaryOutbound, error) {
+// 			return trans.NewOutbound(c), nil
+// 		},
+// 		healthcheck.Service("otherservice"),
+// 	)
+// 	list := healthcheck.New(roundrobin.New(trans), prober,
+// 		healthcheck.Interval(5*time.Second),
+// 		healthcheck.UnhealthyThreshold(3),
+// 	)
+//
+// Any Prober may be used instead, for example to probe peers over a separate
+// administrative port.
+package healthcheck
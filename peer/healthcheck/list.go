@@ -0,0 +1,374 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package healthcheck
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultInterval           = 5 * time.Second
+	defaultTimeout            = time.Second
+	defaultUnhealthyThreshold = 3
+	defaultHealthyThreshold   = 2
+	defaultConcurrency        = 10
+)
+
+type listOptions struct {
+	interval           time.Duration
+	timeout            time.Duration
+	unhealthyThreshold int
+	healthyThreshold   int
+	concurrency        int
+	logger             *zap.Logger
+}
+
+var defaultListOptions = listOptions{
+	interval:           defaultInterval,
+	timeout:            defaultTimeout,
+	unhealthyThreshold: defaultUnhealthyThreshold,
+	healthyThreshold:   defaultHealthyThreshold,
+	concurrency:        defaultConcurrency,
+}
+
+// ListOption customizes the behavior of a health checking peer list.
+type ListOption func(*listOptions)
+
+// Interval specifies how often every peer is probed.
+//
+// Defaults to 5 seconds.
+func Interval(d time.Duration) ListOption {
+	return func(o *listOptions) {
+		o.interval = d
+	}
+}
+
+// Timeout specifies the deadline of every probe.
+//
+// Defaults to 1 second.
+func Timeout(d time.Duration) ListOption {
+	return func(o *listOptions) {
+		o.timeout = d
+	}
+}
+
+// UnhealthyThreshold specifies the number of consecutive failed probes after
+// which a healthy peer is considered unhealthy.
+//
+// Defaults to 3.
+func UnhealthyThreshold(n int) ListOption {
+	return func(o *listOptions) {
+		o.unhealthyThreshold = n
+	}
+}
+
+// HealthyThreshold specifies the number of consecutive successful probes
+// after which an unhealthy peer is considered healthy again.
+//
+// Defaults to 2.
+func HealthyThreshold(n int) ListOption {
+	return func(o *listOptions) {
+		o.healthyThreshold = n
+	}
+}
+
+// Concurrency specifies the largest number of probes in flight at once.
+//
+// Defaults to 10.
+func Concurrency(n int) ListOption {
+	return func(o *listOptions) {
+		o.concurrency = n
+	}
+}
+
+// Logger specifies a logger.
+func Logger(logger *zap.Logger) ListOption {
+	return func(o *listOptions) {
+		o.logger = logger
+	}
+}
+
+type peerHealth struct {
+	id        peer.Identifier
+	healthy   bool
+	failures  int
+	successes int
+}
+
+// New creates a peer list that probes every peer on an interval and forwards
+// only healthy peers to the given peer list.
+//
+// Peers are considered healthy when they are added. A peer is removed from
+// the underlying list after the configured number of consecutive failed
+// probes, and added back after the configured number of consecutive
+// successful probes.
+func New(list peer.ChooserList, prober Prober, opts ...ListOption) *List {
+	options := defaultListOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.unhealthyThreshold < 1 {
+		options.unhealthyThreshold = 1
+	}
+	if options.healthyThreshold < 1 {
+		options.healthyThreshold = 1
+	}
+	if options.concurrency < 1 {
+		options.concurrency = 1
+	}
+
+	logger := options.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &List{
+		list:   list,
+		prober: prober,
+		opts:   options,
+		logger: logger,
+		once:   lifecycle.NewOnce(),
+		peers:  make(map[string]*peerHealth),
+	}
+}
+
+var _ peer.ChooserList = (*List)(nil)
+var _ introspection.IntrospectableChooser = (*List)(nil)
+
+// List is a peer list that actively probes the health of its peers and
+// forwards the healthy ones to an underlying peer list.
+type List struct {
+	list   peer.ChooserList
+	prober Prober
+	opts   listOptions
+	logger *zap.Logger
+	once   *lifecycle.Once
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	lock  sync.Mutex
+	peers map[string]*peerHealth
+}
+
+// Update adds and removes peers from the list and forwards the changes to
+// the underlying peer list, except for removals of unhealthy peers.
+func (l *List) Update(updates peer.ListUpdates) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var (
+		errs    error
+		forward peer.ListUpdates
+	)
+	for _, id := range updates.Removals {
+		addr := id.Identifier()
+		health, ok := l.peers[addr]
+		if !ok {
+			errs = multierr.Append(errs, peer.ErrPeerRemoveNotInList(addr))
+			continue
+		}
+		delete(l.peers, addr)
+		if health.healthy {
+			forward.Removals = append(forward.Removals, id)
+		}
+	}
+	for _, id := range updates.Additions {
+		addr := id.Identifier()
+		if _, ok := l.peers[addr]; ok {
+			errs = multierr.Append(errs, peer.ErrPeerAddAlreadyInList(addr))
+			continue
+		}
+		l.peers[addr] = &peerHealth{id: id, healthy: true}
+		forward.Additions = append(forward.Additions, id)
+	}
+
+	if len(forward.Additions) == 0 && len(forward.Removals) == 0 {
+		return errs
+	}
+	return multierr.Append(errs, l.list.Update(forward))
+}
+
+// Choose returns a healthy peer from the underlying peer list.
+func (l *List) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	return l.list.Choose(ctx, req)
+}
+
+// Start starts the underlying peer list and begins probing peers.
+func (l *List) Start() error {
+	return l.once.Start(l.start)
+}
+
+func (l *List) start() error {
+	if err := l.list.Start(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+	l.done = make(chan struct{})
+	go l.run(ctx)
+	return nil
+}
+
+// Stop stops probing peers and stops the underlying peer list.
+func (l *List) Stop() error {
+	return l.once.Stop(l.stop)
+}
+
+func (l *List) stop() error {
+	l.cancel()
+	<-l.done
+	return l.list.Stop()
+}
+
+// IsRunning returns whether the list is probing peers.
+func (l *List) IsRunning() bool {
+	return l.once.IsRunning()
+}
+
+func (l *List) run(ctx context.Context) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.probeAll(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// probeAll probes every peer, with no more than the configured number of
+// probes in flight, and returns once all probes have completed.
+func (l *List) probeAll(ctx context.Context) {
+	l.lock.Lock()
+	peers := make([]*peerHealth, 0, len(l.peers))
+	for _, health := range l.peers {
+		peers = append(peers, health)
+	}
+	l.lock.Unlock()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, l.opts.concurrency)
+	for _, health := range peers {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(health *peerHealth) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			l.probe(ctx, health)
+		}(health)
+	}
+	wg.Wait()
+}
+
+func (l *List) probe(ctx context.Context, health *peerHealth) {
+	ctx, cancel := context.WithTimeout(ctx, l.opts.timeout)
+	defer cancel()
+
+	err := l.prober.Probe(ctx, health.id)
+	if ctx.Err() == context.Canceled {
+		// The list is stopping.
+		return
+	}
+	l.record(health, err)
+}
+
+func (l *List) record(health *peerHealth, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	// Ignore the results of probes of peers that were removed meanwhile.
+	addr := health.id.Identifier()
+	if l.peers[addr] != health {
+		return
+	}
+
+	if err != nil {
+		health.successes = 0
+		health.failures++
+		if health.healthy && health.failures >= l.opts.unhealthyThreshold {
+			health.healthy = false
+			l.logger.Info("peer is unhealthy", zap.String("peer", addr), zap.Error(err))
+			l.updateList(peer.ListUpdates{Removals: []peer.Identifier{health.id}})
+		}
+		return
+	}
+
+	health.failures = 0
+	health.successes++
+	if !health.healthy && health.successes >= l.opts.healthyThreshold {
+		health.healthy = true
+		l.logger.Info("peer is healthy", zap.String("peer", addr))
+		l.updateList(peer.ListUpdates{Additions: []peer.Identifier{health.id}})
+	}
+}
+
+// updateList must be called under the list lock, so that changes reach the
+// underlying list in the order they are made.
+func (l *List) updateList(updates peer.ListUpdates) {
+	if err := l.list.Update(updates); err != nil {
+		l.logger.Error("failed to update peer list", zap.Error(err))
+	}
+}
+
+// Unhealthy returns the identifiers of the peers currently withheld from the
+// underlying peer list, in no particular order.
+func (l *List) Unhealthy() []peer.Identifier {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var ids []peer.Identifier
+	for _, health := range l.peers {
+		if !health.healthy {
+			ids = append(ids, health.id)
+		}
+	}
+	return ids
+}
+
+// Introspect introspects the underlying peer list.
+func (l *List) Introspect() introspection.ChooserStatus {
+	if ic, ok := l.list.(introspection.IntrospectableChooser); ok {
+		return ic.Introspect()
+	}
+	return introspection.ChooserStatus{}
+}
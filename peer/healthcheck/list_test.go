@@ -0,0 +1,302 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func makePeers(n int) (ids []peer.Identifier) {
+	for i := 0; i < n; i++ {
+		ids = append(ids, hostport.Identify(fmt.Sprintf("10.0.0.%d:4040", i)))
+	}
+	return ids
+}
+
+// fakeBackend is a prober for peers that can be flipped between healthy and
+// failing.
+type fakeBackend struct {
+	sync.Mutex
+
+	failing map[string]bool
+	probes  map[string]int
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		failing: make(map[string]bool),
+		probes:  make(map[string]int),
+	}
+}
+
+func (b *fakeBackend) Probe(ctx context.Context, id peer.Identifier) error {
+	b.Lock()
+	defer b.Unlock()
+	b.probes[id.Identifier()]++
+	if b.failing[id.Identifier()] {
+		return errors.New("great sadness")
+	}
+	return nil
+}
+
+func (b *fakeBackend) setFailing(addr string, failing bool) {
+	b.Lock()
+	defer b.Unlock()
+	b.failing[addr] = failing
+}
+
+type harness struct {
+	t       *testing.T
+	list    *List
+	trans   *yarpctest.FakeTransport
+	backend *fakeBackend
+}
+
+func newHarness(t *testing.T, peers int, opts ...ListOption) *harness {
+	trans := yarpctest.NewFakeTransport()
+	backend := newFakeBackend()
+	opts = append([]ListOption{
+		// Probes are driven by the tests.
+		Interval(time.Hour),
+		UnhealthyThreshold(2),
+		HealthyThreshold(2),
+	}, opts...)
+	pl := New(roundrobin.New(trans), backend, opts...)
+
+	require.NoError(t, pl.Start())
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: makePeers(peers)}))
+	trans.Flush()
+
+	return &harness{
+		t:       t,
+		list:    pl,
+		trans:   trans,
+		backend: backend,
+	}
+}
+
+func (h *harness) probe(rounds int) {
+	for i := 0; i < rounds; i++ {
+		h.list.probeAll(context.Background())
+	}
+	h.trans.Flush()
+}
+
+// call sends n requests and returns the number of requests each peer
+// received.
+func (h *harness) call(n int) map[string]int {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		p, onFinish, err := h.list.Choose(ctx, &transport.Request{})
+		require.NoError(h.t, err)
+		counts[p.Identifier()]++
+		onFinish(nil)
+		h.trans.Flush()
+	}
+	return counts
+}
+
+func (h *harness) unhealthy() []string {
+	var addrs []string
+	for _, id := range h.list.Unhealthy() {
+		addrs = append(addrs, id.Identifier())
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+func TestTrafficFollowsHealth(t *testing.T) {
+	h := newHarness(t, 3)
+	defer func() { assert.NoError(t, h.list.Stop()) }()
+
+	assert.Equal(t, map[string]int{
+		"10.0.0.0:4040": 2,
+		"10.0.0.1:4040": 2,
+		"10.0.0.2:4040": 2,
+	}, h.call(6), "all peers start healthy")
+
+	h.backend.setFailing("10.0.0.1:4040", true)
+	h.probe(1)
+	assert.Empty(t, h.unhealthy(), "a single failure is below the threshold")
+	assert.Len(t, h.call(6), 3)
+
+	h.probe(1)
+	assert.Equal(t, []string{"10.0.0.1:4040"}, h.unhealthy())
+	assert.Equal(t, map[string]int{
+		"10.0.0.0:4040": 3,
+		"10.0.0.2:4040": 3,
+	}, h.call(6), "traffic must skip the unhealthy peer")
+
+	h.backend.setFailing("10.0.0.1:4040", false)
+	h.probe(1)
+	assert.Equal(t, []string{"10.0.0.1:4040"}, h.unhealthy(), "a single success is below the threshold")
+
+	h.probe(1)
+	assert.Empty(t, h.unhealthy())
+	assert.Equal(t, map[string]int{
+		"10.0.0.0:4040": 2,
+		"10.0.0.1:4040": 2,
+		"10.0.0.2:4040": 2,
+	}, h.call(6), "traffic must return to the healthy peer")
+}
+
+func TestFailuresMustBeConsecutive(t *testing.T) {
+	h := newHarness(t, 2)
+	defer func() { assert.NoError(t, h.list.Stop()) }()
+
+	for i := 0; i < 5; i++ {
+		h.backend.setFailing("10.0.0.0:4040", true)
+		h.probe(1)
+		h.backend.setFailing("10.0.0.0:4040", false)
+		h.probe(1)
+	}
+	assert.Empty(t, h.unhealthy(), "alternating results must not mark the peer unhealthy")
+}
+
+func TestUpdateUnhealthyPeers(t *testing.T) {
+	h := newHarness(t, 2)
+	defer func() { assert.NoError(t, h.list.Stop()) }()
+
+	h.backend.setFailing("10.0.0.0:4040", true)
+	h.probe(2)
+	require.Equal(t, []string{"10.0.0.0:4040"}, h.unhealthy())
+
+	// The unhealthy peer is absent from the underlying list, so its removal
+	// must not be forwarded.
+	ids := makePeers(1)
+	require.NoError(t, h.list.Update(peer.ListUpdates{Removals: ids}))
+	assert.Empty(t, h.unhealthy())
+
+	// Re-adding the peer starts it healthy.
+	require.NoError(t, h.list.Update(peer.ListUpdates{Additions: ids}))
+	h.trans.Flush()
+	assert.Len(t, h.call(4), 2)
+
+	err := h.list.Update(peer.ListUpdates{
+		Additions: ids,
+		Removals:  []peer.Identifier{hostport.Identify("10.0.0.9:4040")},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `can't add peer "10.0.0.0:4040" because is already in peerlist`)
+	assert.Contains(t, err.Error(), `can't remove peer (10.0.0.9:4040) because it is not in peerlist`)
+}
+
+func TestProbeConcurrency(t *testing.T) {
+	var inflight, maxInflight atomic.Int32
+	prober := ProberFunc(func(ctx context.Context, id peer.Identifier) error {
+		n := inflight.Inc()
+		defer inflight.Dec()
+		for {
+			max := maxInflight.Load()
+			if n <= max || maxInflight.CAS(max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+
+	trans := yarpctest.NewFakeTransport()
+	pl := New(roundrobin.New(trans), prober, Interval(time.Hour), Concurrency(3))
+	require.NoError(t, pl.Start())
+	defer func() { assert.NoError(t, pl.Stop()) }()
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: makePeers(20)}))
+
+	pl.probeAll(context.Background())
+	assert.True(t, maxInflight.Load() <= 3, "at most 3 probes in flight, got %d", maxInflight.Load())
+	assert.True(t, maxInflight.Load() >= 1)
+}
+
+func TestProbeTimeout(t *testing.T) {
+	prober := ProberFunc(func(ctx context.Context, id peer.Identifier) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	trans := yarpctest.NewFakeTransport()
+	pl := New(roundrobin.New(trans), prober,
+		Interval(time.Hour),
+		Timeout(time.Millisecond),
+		UnhealthyThreshold(1),
+	)
+	require.NoError(t, pl.Start())
+	defer func() { assert.NoError(t, pl.Stop()) }()
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: makePeers(2)}))
+
+	pl.probeAll(context.Background())
+	assert.Len(t, pl.Unhealthy(), 2, "timed out probes must fail")
+}
+
+func TestProbesOnInterval(t *testing.T) {
+	backend := newFakeBackend()
+	backend.setFailing("10.0.0.0:4040", true)
+
+	trans := yarpctest.NewFakeTransport()
+	pl := New(roundrobin.New(trans), backend,
+		Interval(time.Millisecond),
+		UnhealthyThreshold(2),
+	)
+	require.NoError(t, pl.Start())
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: makePeers(2)}))
+
+	deadline := time.Now().Add(testtime.Second)
+	for len(pl.Unhealthy()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Len(t, pl.Unhealthy(), 1, "peer must become unhealthy through periodic probes")
+
+	require.NoError(t, pl.Stop())
+	assert.False(t, pl.IsRunning())
+
+	backend.Lock()
+	probes := backend.probes["10.0.0.1:4040"]
+	backend.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	backend.Lock()
+	defer backend.Unlock()
+	assert.Equal(t, probes, backend.probes["10.0.0.1:4040"], "no probes after stopping")
+}
+
+func TestIntrospect(t *testing.T) {
+	h := newHarness(t, 2)
+	defer func() { assert.NoError(t, h.list.Stop()) }()
+
+	assert.Equal(t, "round-robin", h.list.Introspect().Name)
+}
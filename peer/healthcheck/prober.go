@@ -0,0 +1,145 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// DefaultProcedure is the procedure called by outbound probers unless
+// configured otherwise.
+const DefaultProcedure = "health"
+
+// Prober checks the health of a peer.
+type Prober interface {
+	// Probe returns an error if the peer is unhealthy.
+	Probe(ctx context.Context, id peer.Identifier) error
+}
+
+// ProberFunc is a Prober implemented by a function.
+type ProberFunc func(ctx context.Context, id peer.Identifier) error
+
+// Probe calls the function.
+func (f ProberFunc) Probe(ctx context.Context, id peer.Identifier) error {
+	return f(ctx, id)
+}
+
+// NewOutboundFunc builds a unary outbound that sends requests to the peers
+// of the given chooser, for example:
+//
+//  func(t peer.Transport, c peer.Chooser) (transport.UnaryOutbound, error) {
+//    return t.(*http.Transport).NewOutbound(c), nil
+//  }
+type NewOutboundFunc func(peer.Transport, peer.Chooser) (transport.UnaryOutbound, error)
+
+type proberOptions struct {
+	procedure string
+	service   string
+	caller    string
+}
+
+// ProberOption customizes the requests of an outbound prober.
+type ProberOption func(*proberOptions)
+
+// Procedure specifies the procedure called to probe a peer.
+//
+// Defaults to DefaultProcedure.
+func Procedure(name string) ProberOption {
+	return func(o *proberOptions) {
+		o.procedure = name
+	}
+}
+
+// Service specifies the name of the service called to probe a peer.
+func Service(name string) ProberOption {
+	return func(o *proberOptions) {
+		o.service = name
+	}
+}
+
+// Caller specifies the name of the caller of probes.
+func Caller(name string) ProberOption {
+	return func(o *proberOptions) {
+		o.caller = name
+	}
+}
+
+// NewOutboundProber returns a prober that calls a procedure on a peer with an
+// empty raw request, through an outbound bound to only that peer.
+//
+// A probe fails if the call fails with anything but a client fault. Client
+// faults, like an unimplemented procedure, show that the peer is serving
+// requests.
+func NewOutboundProber(t peer.Transport, newOutbound NewOutboundFunc, opts ...ProberOption) Prober {
+	options := proberOptions{procedure: DefaultProcedure}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &outboundProber{
+		transport:   t,
+		newOutbound: newOutbound,
+		opts:        options,
+	}
+}
+
+type outboundProber struct {
+	transport   peer.Transport
+	newOutbound NewOutboundFunc
+	opts        proberOptions
+}
+
+func (p *outboundProber) Probe(ctx context.Context, id peer.Identifier) (err error) {
+	out, err := p.newOutbound(p.transport, peerbind.NewSingle(id, p.transport))
+	if err != nil {
+		return err
+	}
+	if err := out.Start(); err != nil {
+		return err
+	}
+	defer func() {
+		err = multierr.Append(err, out.Stop())
+	}()
+
+	res, err := out.Call(ctx, &transport.Request{
+		Caller:    p.opts.caller,
+		Service:   p.opts.service,
+		Procedure: p.opts.procedure,
+		Encoding:  transport.Encoding("raw"),
+		Body:      &bytes.Buffer{},
+	})
+	if err != nil {
+		if yarpcerrors.GetFaultTypeFromError(err) == yarpcerrors.ClientFault {
+			return nil
+		}
+		return err
+	}
+	if res.Body != nil {
+		return res.Body.Close()
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	yarpchttp "go.uber.org/yarpc/transport/http"
+)
+
+// healthServer is an HTTP backend that responds to health probes with the
+// configured status, counting the probes and other requests it receives.
+type healthServer struct {
+	*httptest.Server
+
+	status   atomic.Int32
+	probes   atomic.Int32
+	requests atomic.Int32
+}
+
+func newHealthServer(t *testing.T) *healthServer {
+	s := &healthServer{}
+	s.status.Store(http.StatusOK)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Rpc-Procedure") == DefaultProcedure {
+			s.probes.Inc()
+			w.WriteHeader(int(s.status.Load()))
+			return
+		}
+		s.requests.Inc()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *healthServer) addr() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+func newHTTPOutbound(t peer.Transport, c peer.Chooser) (transport.UnaryOutbound, error) {
+	return t.(*yarpchttp.Transport).NewOutbound(c), nil
+}
+
+func TestOutboundProber(t *testing.T) {
+	server := newHealthServer(t)
+
+	trans := yarpchttp.NewTransport()
+	require.NoError(t, trans.Start())
+	defer func() { assert.NoError(t, trans.Stop()) }()
+
+	prober := NewOutboundProber(trans, newHTTPOutbound, Service("myservice"), Caller("test"))
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	id := hostport.Identify(server.addr())
+	assert.NoError(t, prober.Probe(ctx, id))
+
+	server.status.Store(http.StatusInternalServerError)
+	assert.Error(t, prober.Probe(ctx, id), "server errors must fail the probe")
+
+	server.status.Store(http.StatusNotImplemented)
+	assert.NoError(t, prober.Probe(ctx, id), "client faults show that the peer is serving")
+
+	assert.Equal(t, int32(3), server.probes.Load())
+
+	failing := NewOutboundProber(trans, func(peer.Transport, peer.Chooser) (transport.UnaryOutbound, error) {
+		return nil, errors.New("great sadness")
+	})
+	assert.EqualError(t, failing.Probe(ctx, id), "great sadness")
+}
+
+func TestHTTPTrafficFollowsHealth(t *testing.T) {
+	good, flaky := newHealthServer(t), newHealthServer(t)
+
+	trans := yarpchttp.NewTransport()
+	require.NoError(t, trans.Start())
+	defer func() { assert.NoError(t, trans.Stop()) }()
+
+	list := New(roundrobin.New(trans),
+		NewOutboundProber(trans, newHTTPOutbound, Service("myservice"), Caller("test")),
+		Interval(time.Hour),
+		UnhealthyThreshold(1),
+		HealthyThreshold(1),
+	)
+	out := trans.NewOutbound(list)
+	require.NoError(t, out.Start())
+	defer func() { assert.NoError(t, out.Stop()) }()
+	require.NoError(t, list.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{hostport.Identify(good.addr()), hostport.Identify(flaky.addr())},
+	}))
+
+	call := func(n int) {
+		for i := 0; i < n; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+			res, err := out.Call(ctx, &transport.Request{
+				Caller:    "test",
+				Service:   "myservice",
+				Procedure: "echo",
+				Encoding:  "raw",
+				Body:      strings.NewReader("hello"),
+			})
+			cancel()
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+		}
+	}
+
+	// Peers of the HTTP transport become available asynchronously, so call
+	// until the backend receives traffic.
+	callUntilReached := func(s *healthServer) {
+		before := s.requests.Load()
+		deadline := time.Now().Add(testtime.Second)
+		for s.requests.Load() == before {
+			require.True(t, time.Now().Before(deadline), "backend never received traffic")
+			call(1)
+		}
+	}
+
+	callUntilReached(flaky)
+
+	flaky.status.Store(http.StatusServiceUnavailable)
+	list.probeAll(context.Background())
+	before := flaky.requests.Load()
+	call(4)
+	assert.Equal(t, before, flaky.requests.Load(), "traffic must skip the failing backend")
+
+	flaky.status.Store(http.StatusOK)
+	list.probeAll(context.Background())
+	callUntilReached(flaky)
+}
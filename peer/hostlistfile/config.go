@@ -0,0 +1,109 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hostlistfile
+
+import (
+	"time"
+
+	"github.com/uber-go/mapdecode"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Configuration describes how to build a "with-file" peer list updater.
+type Configuration struct {
+	Path         string         `config:"path,interpolate"`
+	PollInterval *time.Duration `config:"pollInterval"`
+	Debounce     *time.Duration `config:"debounce"`
+}
+
+// Decode decodes the configuration from either the path of the file alone or
+// a map of attributes.
+func (c *Configuration) Decode(into mapdecode.Into) error {
+	if err := into(&c.Path); err == nil {
+		return nil
+	}
+	type configuration Configuration
+	return into((*configuration)(c))
+}
+
+// Spec returns a configuration specification for the "with-file" peer list
+// updater, which binds a peer list to the addresses in a file.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerListUpdater(hostlistfile.Spec())
+//
+// This enables the with-file peer list updater under any peer list:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: http://host/rpc
+//          round-robin:
+//            with-file: /etc/peers/otherservice.list
+//
+// The file may also be configured with the intervals for polling and
+// debouncing:
+//
+//  round-robin:
+//    with-file:
+//      path: /etc/peers/otherservice.list
+//      pollInterval: 5s
+//      debounce: 100ms
+func Spec() yarpcconfig.PeerListUpdaterSpec {
+	return SpecWithOptions()
+}
+
+// SpecWithOptions accepts additional updater options.
+func SpecWithOptions(options ...Option) yarpcconfig.PeerListUpdaterSpec {
+	return yarpcconfig.PeerListUpdaterSpec{
+		Name: "with-file",
+		BuildPeerListUpdater: func(cfg Configuration, k *yarpcconfig.Kit) (peer.Binder, error) {
+			if cfg.Path == "" {
+				return nil, yarpcerrors.InvalidArgumentErrorf("with-file peer list updater requires a path")
+			}
+
+			opts := make([]Option, 0, len(options)+3)
+			if meter := k.Meter(); meter != nil {
+				opts = append(opts, Meter(meter))
+			}
+			opts = append(opts, options...)
+
+			if cfg.PollInterval != nil {
+				if *cfg.PollInterval <= 0 {
+					return nil, yarpcerrors.InvalidArgumentErrorf(
+						"PollInterval must be greater than 0. Got: %v.", *cfg.PollInterval)
+				}
+				opts = append(opts, PollInterval(*cfg.PollInterval))
+			}
+			if cfg.Debounce != nil {
+				if *cfg.Debounce < 0 {
+					return nil, yarpcerrors.InvalidArgumentErrorf(
+						"Debounce must not be negative. Got: %v.", *cfg.Debounce)
+				}
+				opts = append(opts, Debounce(*cfg.Debounce))
+			}
+			return Bind(cfg.Path, opts...), nil
+		},
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hostlistfile
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/whitespace"
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		desc             string
+		given            string
+		wantPath         string
+		wantPollInterval time.Duration
+		wantDebounce     time.Duration
+		wantErr          string
+	}{
+		{
+			desc: "path",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								with-file: /etc/peers/myservice.list
+			`,
+			wantPath:         "/etc/peers/myservice.list",
+			wantPollInterval: defaultPollInterval,
+			wantDebounce:     defaultDebounce,
+		},
+		{
+			desc: "all options",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								with-file:
+									path: /etc/peers/myservice.list
+									pollInterval: 1m
+									debounce: 1s
+			`,
+			wantPath:         "/etc/peers/myservice.list",
+			wantPollInterval: time.Minute,
+			wantDebounce:     time.Second,
+		},
+		{
+			desc: "missing path",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								with-file:
+									debounce: 1s
+			`,
+			wantErr: "with-file peer list updater requires a path",
+		},
+		{
+			desc: "invalid poll interval",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								with-file:
+									path: /etc/peers/myservice.list
+									pollInterval: 0s
+			`,
+			wantErr: "PollInterval must be greater than 0. Got: 0s.",
+		},
+		{
+			desc: "invalid debounce",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								with-file:
+									path: /etc/peers/myservice.list
+									debounce: -1s
+			`,
+			wantErr: "Debounce must not be negative. Got: -1s.",
+		},
+		{
+			desc: "unexpected value",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								with-file:
+									- /etc/peers/myservice.list
+			`,
+			wantErr: `failed to read attribute "with-file"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfgr := yarpctest.NewFakeConfigurator()
+			cfgr.MustRegisterPeerListUpdater(Spec())
+
+			cfg, err := cfgr.LoadConfigFromYAML("test", strings.NewReader(whitespace.Expand(tt.given)))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			out := cfg.Outbounds["myservice"].Unary.(*yarpctest.FakeOutbound)
+			u, ok := out.Chooser().(*peerbind.BoundChooser).Updater().(*Updater)
+			require.True(t, ok, "expected a host list file updater")
+
+			assert.Equal(t, tt.wantPath, u.path)
+			assert.Equal(t, tt.wantPollInterval, u.opts.pollInterval)
+			assert.Equal(t, tt.wantDebounce, u.opts.debounce)
+		})
+	}
+}
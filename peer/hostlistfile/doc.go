@@ -0,0 +1,43 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package hostlistfile provides a peer list updater that reads peers from a
+// file with one "host:port" address per line and reloads it when it changes.
+//
+// 	list := roundrobin.New(transport)
+// 	chooser := peer.Bind(list, hostlistfile.Bind("/etc/peers/myservice.list"))
+//
+// Blank lines and text following "#" are ignored:
+//
+// 	# myservice
+// 	10.0.0.1:8080
+// 	10.0.0.2:8080 # canary
+//
+// The file is read when the updater starts, which fails if the file cannot be
+// read. Thereafter, the updater watches the directory of the file for
+// changes, which also observes files replaced by renaming, and falls back to
+// polling the file if watching is not possible. Changes are debounced so
+// that a file that is being written is not read before the write completes,
+// and only the differences are sent to the peer list.
+//
+// Malformed lines are skipped with a warning instead of failing the whole
+// update. If the file cannot be read, or contains no valid addresses at all,
+// the peer list retains its existing peers.
+package hostlistfile
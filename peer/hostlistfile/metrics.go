@@ -0,0 +1,42 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hostlistfile
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
+type updaterMetrics struct {
+	malformedLines *metrics.Counter
+}
+
+func newUpdaterMetrics(meter *metrics.Scope, logger *zap.Logger) updaterMetrics {
+	malformedLines, err := meter.Counter(metrics.Spec{
+		Name:      "hostlistfile_malformed_lines",
+		Help:      "Total number of malformed lines skipped in peer list files.",
+		ConstTags: metrics.Tags{"component": "yarpc"},
+	})
+	if err != nil {
+		logger.Error("failed to create hostlistfile malformed lines counter", zap.Error(err))
+	}
+	return updaterMetrics{malformedLines: malformedLines}
+}
@@ -0,0 +1,398 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hostlistfile
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultDebounce     = 100 * time.Millisecond
+)
+
+var errNoPeers = errors.New("no valid addresses found")
+
+type options struct {
+	pollInterval time.Duration
+	debounce     time.Duration
+	logger       *zap.Logger
+	meter        *metrics.Scope
+	// newWatcher is replaced in tests to force polling.
+	newWatcher func() (*fsnotify.Watcher, error)
+}
+
+var defaultOptions = options{
+	pollInterval: defaultPollInterval,
+	debounce:     defaultDebounce,
+	newWatcher:   fsnotify.NewWatcher,
+}
+
+// Option customizes the behavior of a host list file peer list updater.
+type Option func(*options)
+
+// PollInterval specifies how often the file is checked for changes when the
+// file system cannot be watched.
+//
+// Defaults to 5 seconds.
+func PollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.pollInterval = interval
+	}
+}
+
+// Debounce specifies how long the file must go without changes before it is
+// read again.
+//
+// Defaults to 100 milliseconds.
+func Debounce(d time.Duration) Option {
+	return func(o *options) {
+		o.debounce = d
+	}
+}
+
+// Logger specifies a logger.
+func Logger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// Meter specifies the scope for the malformed lines counter.
+//
+// Metrics are registered when the updater is bound, so updaters sharing a
+// meter should each use a distinctly tagged scope.
+func Meter(meter *metrics.Scope) Option {
+	return func(o *options) {
+		o.meter = meter
+	}
+}
+
+// Bind returns a binder (suitable as an argument to peer.Bind) that binds a
+// peer list to the addresses in the file at the given path.
+func Bind(path string, opts ...Option) peer.Binder {
+	options := defaultOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	logger := options.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	logger = logger.With(zap.String("path", path))
+
+	return func(pl peer.List) transport.Lifecycle {
+		return &Updater{
+			once:    lifecycle.NewOnce(),
+			pl:      pl,
+			path:    path,
+			opts:    options,
+			logger:  logger,
+			metrics: newUpdaterMetrics(options.meter, logger),
+			peers:   make(map[string]peer.Identifier),
+		}
+	}
+}
+
+// Updater reads peers from a file and updates a peer list with the
+// differences whenever the file changes.
+type Updater struct {
+	once    *lifecycle.Once
+	pl      peer.List
+	path    string
+	opts    options
+	logger  *zap.Logger
+	metrics updaterMetrics
+
+	stop chan struct{}
+	done chan struct{}
+
+	// peers and lastStat are only accessed by the watch loop.
+	peers    map[string]peer.Identifier
+	lastStat fileStat
+}
+
+// Start reads the file and starts watching it for changes in the background.
+func (u *Updater) Start() error {
+	return u.once.Start(u.start)
+}
+
+func (u *Updater) start() error {
+	// Watch before reading, so that no change made after the file is read
+	// goes unnoticed.
+	watcher := u.watch()
+	u.lastStat = u.stat()
+	ids, err := u.read()
+	if err != nil {
+		if watcher != nil {
+			_ = watcher.Close()
+		}
+		return err
+	}
+
+	u.stop = make(chan struct{})
+	u.done = make(chan struct{})
+	// The peer list may block updates until it has started, so the updater
+	// must not block.
+	go u.run(ids, watcher)
+	return nil
+}
+
+// Stop stops watching the file and removes all peers from the peer list.
+func (u *Updater) Stop() error {
+	return u.once.Stop(u.stopUpdates)
+}
+
+func (u *Updater) stopUpdates() error {
+	close(u.stop)
+	<-u.done
+
+	var updates peer.ListUpdates
+	for _, id := range u.peers {
+		updates.Removals = append(updates.Removals, id)
+	}
+	sortIdentifiers(updates.Removals)
+	u.peers = make(map[string]peer.Identifier)
+	if len(updates.Removals) == 0 {
+		return nil
+	}
+	return u.pl.Update(updates)
+}
+
+// IsRunning returns whether the updater is watching the file.
+func (u *Updater) IsRunning() bool {
+	return u.once.IsRunning()
+}
+
+// watch returns a watcher for the directory of the file, or nil if the file
+// system cannot be watched. The directory is watched rather than the file
+// itself so that files replaced by renaming, as editors and configuration
+// management tools commonly do, remain watched.
+func (u *Updater) watch() *fsnotify.Watcher {
+	watcher, err := u.opts.newWatcher()
+	if err == nil {
+		if err = watcher.Add(filepath.Dir(u.path)); err != nil {
+			_ = watcher.Close()
+		}
+	}
+	if err != nil {
+		u.logger.Warn("failed to watch peer list file, polling for changes instead", zap.Error(err))
+		return nil
+	}
+	return watcher
+}
+
+func (u *Updater) run(ids []peer.Identifier, watcher *fsnotify.Watcher) {
+	defer close(u.done)
+
+	u.update(ids)
+
+	var (
+		events   <-chan fsnotify.Event
+		errs     <-chan error
+		ticker   *time.Ticker
+		poll     <-chan time.Time
+		debounce <-chan time.Time
+	)
+	startPolling := func() {
+		ticker = time.NewTicker(u.opts.pollInterval)
+		poll = ticker.C
+	}
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
+	if watcher != nil {
+		defer watcher.Close()
+		events, errs = watcher.Events, watcher.Errors
+	} else {
+		startPolling()
+	}
+
+	for {
+		select {
+		case _, ok := <-events:
+			if ok {
+				debounce = time.After(u.opts.debounce)
+				continue
+			}
+			u.logger.Warn("stopped watching peer list file, polling for changes instead")
+			events, errs = nil, nil
+			startPolling()
+		case err, ok := <-errs:
+			if !ok {
+				continue
+			}
+			u.logger.Warn("failed to watch peer list file, polling for changes instead", zap.Error(err))
+			_ = watcher.Close()
+			events, errs = nil, nil
+			startPolling()
+		case <-poll:
+			if stat := u.stat(); !stat.equal(u.lastStat) {
+				u.lastStat = stat
+				debounce = time.After(u.opts.debounce)
+			}
+		case <-debounce:
+			debounce = nil
+			u.reload()
+		case <-u.stop:
+			return
+		}
+	}
+}
+
+func (u *Updater) reload() {
+	ids, err := u.read()
+	if err != nil {
+		u.logger.Warn("failed to read peer list file, retaining existing peers", zap.Error(err))
+		return
+	}
+	u.update(ids)
+}
+
+func (u *Updater) update(ids []peer.Identifier) {
+	if len(ids) == 0 {
+		u.logger.Warn("failed to read peer list file, retaining existing peers", zap.Error(errNoPeers))
+		return
+	}
+
+	updates := u.diff(ids)
+	if len(updates.Additions) == 0 && len(updates.Removals) == 0 {
+		return
+	}
+	u.logger.Debug("read peer changes",
+		zap.Int("additions", len(updates.Additions)),
+		zap.Int("removals", len(updates.Removals)))
+	if err := u.pl.Update(updates); err != nil {
+		u.logger.Error("failed to update peer list", zap.Error(err))
+	}
+}
+
+// read reads and parses the file, skipping malformed lines.
+func (u *Updater) read() ([]peer.Identifier, error) {
+	data, err := ioutil.ReadFile(u.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []peer.Identifier
+	for i, line := range strings.Split(string(data), "\n") {
+		if j := strings.IndexByte(line, '#'); j >= 0 {
+			line = line[:j]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if err := validateAddress(line); err != nil {
+			u.logger.Warn("skipping malformed line in peer list file",
+				zap.Int("line", i+1),
+				zap.String("text", line),
+				zap.Error(err))
+			u.metrics.malformedLines.Inc()
+			continue
+		}
+		ids = append(ids, hostport.Identify(line))
+	}
+	return ids, nil
+}
+
+func validateAddress(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" || strings.ContainsAny(host, " \t") {
+		return fmt.Errorf("invalid host %q", host)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// diff updates the known peers and returns the changes for the peer list.
+func (u *Updater) diff(ids []peer.Identifier) peer.ListUpdates {
+	next := make(map[string]peer.Identifier, len(ids))
+	for _, id := range ids {
+		next[id.Identifier()] = id
+	}
+
+	var updates peer.ListUpdates
+	for addr, id := range u.peers {
+		if _, ok := next[addr]; !ok {
+			updates.Removals = append(updates.Removals, id)
+		}
+	}
+	for addr, id := range next {
+		if _, ok := u.peers[addr]; !ok {
+			updates.Additions = append(updates.Additions, id)
+		}
+	}
+	u.peers = next
+	sortIdentifiers(updates.Removals)
+	sortIdentifiers(updates.Additions)
+	return updates
+}
+
+func sortIdentifiers(ids []peer.Identifier) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Identifier() < ids[j].Identifier()
+	})
+}
+
+// fileStat is the state of the file used to detect changes while polling.
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+func (s fileStat) equal(o fileStat) bool {
+	return s.modTime.Equal(o.modTime) && s.size == o.size
+}
+
+func (u *Updater) stat() fileStat {
+	info, err := os.Stat(u.path)
+	if err != nil {
+		return fileStat{}
+	}
+	return fileStat{modTime: info.ModTime(), size: info.Size()}
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hostlistfile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/internal/testtime"
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpctest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// tempPath returns the path of a peer list file in a temporary directory
+// that is removed when the test completes.
+func tempPath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "hostlistfile")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "peers.list")
+}
+
+func writeFile(t *testing.T, path string, lines ...string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644))
+}
+
+// replaceFile replaces the file by renaming a new file over it, as editors
+// and configuration management tools commonly do.
+func replaceFile(t *testing.T, path string, lines ...string) {
+	tmp := path + ".tmp"
+	writeFile(t, tmp, lines...)
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func peerIdentifiers(list *roundrobin.List) []string {
+	var ids []string
+	for _, p := range list.Peers() {
+		ids = append(ids, p.Identifier())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func assertEventuallyPeers(t *testing.T, list *roundrobin.List, want ...string) {
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(want, peerIdentifiers(list))
+	}, testtime.Second, 5*time.Millisecond, "want peers %v, got %v", want, peerIdentifiers(list))
+}
+
+func forcePolling() Option {
+	return func(o *options) {
+		o.newWatcher = func() (*fsnotify.Watcher, error) {
+			return nil, errors.New("watching is not supported")
+		}
+	}
+}
+
+func TestUpdater(t *testing.T) {
+	tests := []struct {
+		desc string
+		opts []Option
+	}{
+		{desc: "watch"},
+		{desc: "poll", opts: []Option{forcePolling(), PollInterval(5 * time.Millisecond)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			path := tempPath(t)
+			writeFile(t, path,
+				"# peers",
+				"127.0.0.1:8080",
+				"127.0.0.1:8081",
+			)
+
+			list := roundrobin.New(yarpctest.NewFakeTransport())
+			opts := append([]Option{Debounce(10 * time.Millisecond)}, tt.opts...)
+			chooser := peerbind.Bind(list, Bind(path, opts...))
+			require.NoError(t, chooser.Start())
+			assertEventuallyPeers(t, list, "127.0.0.1:8080", "127.0.0.1:8081")
+
+			writeFile(t, path,
+				"127.0.0.1:8081",
+				"127.0.0.1:8082",
+			)
+			assertEventuallyPeers(t, list, "127.0.0.1:8081", "127.0.0.1:8082")
+
+			replaceFile(t, path,
+				"127.0.0.1:8082 # canary",
+				"",
+				"127.0.0.1:8083",
+			)
+			assertEventuallyPeers(t, list, "127.0.0.1:8082", "127.0.0.1:8083")
+
+			require.NoError(t, chooser.Stop())
+			assert.Empty(t, list.Peers())
+		})
+	}
+}
+
+func TestUpdaterMalformedLines(t *testing.T) {
+	path := tempPath(t)
+	writeFile(t, path,
+		"127.0.0.1:8080",
+		"127.0.0.1",
+		":8081",
+		"127.0.0.1:http",
+		"127.0.0.1:0",
+		"127.0.0.1:65536",
+		"local host:8082",
+		"[::1]:8083",
+	)
+
+	core, logs := observer.New(zap.WarnLevel)
+	root := metrics.New()
+	list := roundrobin.New(yarpctest.NewFakeTransport())
+	chooser := peerbind.Bind(list, Bind(path, Logger(zap.New(core)), Meter(root.Scope())))
+	require.NoError(t, chooser.Start())
+	defer chooser.Stop()
+
+	assertEventuallyPeers(t, list, "127.0.0.1:8080", "[::1]:8083")
+
+	malformed := logs.FilterMessage("skipping malformed line in peer list file").AllUntimed()
+	require.Len(t, malformed, 6)
+	var lines []int64
+	for _, entry := range malformed {
+		lines = append(lines, entry.ContextMap()["line"].(int64))
+	}
+	assert.Equal(t, []int64{2, 3, 4, 5, 6, 7}, lines)
+
+	snap := root.Snapshot()
+	require.Len(t, snap.Counters, 1)
+	assert.Equal(t, "hostlistfile_malformed_lines", snap.Counters[0].Name)
+	assert.Equal(t, int64(6), snap.Counters[0].Value)
+}
+
+func TestUpdaterRetainsPeers(t *testing.T) {
+	tests := []struct {
+		desc    string
+		change  func(t *testing.T, path string)
+		wantErr string
+	}{
+		{
+			desc: "no valid addresses",
+			change: func(t *testing.T, path string) {
+				writeFile(t, path, "# drained", "localhost")
+			},
+			wantErr: "no valid addresses found",
+		},
+		{
+			desc: "removed file",
+			change: func(t *testing.T, path string) {
+				require.NoError(t, os.Remove(path))
+			},
+			wantErr: "no such file or directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			path := tempPath(t)
+			writeFile(t, path, "127.0.0.1:8080")
+
+			core, logs := observer.New(zap.WarnLevel)
+			list := roundrobin.New(yarpctest.NewFakeTransport())
+			chooser := peerbind.Bind(list, Bind(path, Debounce(time.Millisecond), Logger(zap.New(core))))
+			require.NoError(t, chooser.Start())
+			defer chooser.Stop()
+			assertEventuallyPeers(t, list, "127.0.0.1:8080")
+
+			tt.change(t, path)
+			require.Eventually(t, func() bool {
+				return logs.FilterMessage("failed to read peer list file, retaining existing peers").Len() > 0
+			}, testtime.Second, 5*time.Millisecond)
+
+			entry := logs.FilterMessage("failed to read peer list file, retaining existing peers").AllUntimed()[0]
+			assert.Contains(t, entry.ContextMap()["error"], tt.wantErr)
+			assert.Equal(t, []string{"127.0.0.1:8080"}, peerIdentifiers(list))
+		})
+	}
+}
+
+func TestUpdaterStartMissingFile(t *testing.T) {
+	path := tempPath(t)
+	list := roundrobin.New(yarpctest.NewFakeTransport())
+	chooser := peerbind.Bind(list, Bind(path))
+
+	err := chooser.Start()
+	require.Error(t, err)
+	assert.True(t, os.IsNotExist(err), "unexpected error: %v", err)
+	assert.False(t, chooser.IsRunning())
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package slowstart computes the weights of peers warming up after they
+// become available, shared by peer list implementations that support a
+// slow-start window.
+package slowstart
+
+import (
+	"math"
+	"time"
+
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// MinWeight is the weight of a peer at the moment it becomes available.
+const MinWeight = 0.1
+
+// Curve determines how the weight of a warming peer increases over the
+// window.
+type Curve int
+
+const (
+	// Linear increases the weight by equal steps.
+	Linear Curve = iota
+	// Exponential multiplies the weight by equal factors, so a peer receives
+	// little traffic for most of the window.
+	Exponential
+)
+
+// ParseCurve returns the curve with the given configuration name, "linear"
+// or "exponential". The empty string is linear.
+func ParseCurve(name string) (Curve, error) {
+	switch name {
+	case "", "linear":
+		return Linear, nil
+	case "exponential":
+		return Exponential, nil
+	}
+	return Linear, yarpcerrors.InvalidArgumentErrorf(
+		"slowStartCurve must be \"linear\" or \"exponential\". Got: %q.", name)
+}
+
+// Ramp describes the warm-up of peers.
+//
+// The zero value disables slow start.
+type Ramp struct {
+	Window time.Duration
+	Curve  Curve
+}
+
+// Enabled returns whether peers warm up at all.
+func (r Ramp) Enabled() bool {
+	return r.Window > 0
+}
+
+// Weight returns the fraction of its full share of traffic that a peer
+// should receive, given how long it has been available, between MinWeight
+// and 1.
+func (r Ramp) Weight(elapsed time.Duration) float64 {
+	if elapsed >= r.Window {
+		return 1
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	progress := float64(elapsed) / float64(r.Window)
+	if r.Curve == Exponential {
+		return MinWeight * math.Pow(1/MinWeight, progress)
+	}
+	return MinWeight + (1-MinWeight)*progress
+}
+
+// Peer tracks the warm-up of a single peer.
+type Peer struct {
+	since  time.Time
+	credit float64
+}
+
+// NewPeer starts tracking a peer that became available at the given time.
+func NewPeer(since time.Time) Peer {
+	return Peer{since: since}
+}
+
+// Admit returns whether a peer that is up next for selection should be
+// chosen.
+//
+// Warming peers accumulate their weight as credit every time they are
+// considered, and are admitted once they have accrued a full unit, so that
+// over many selections they are chosen in proportion to their weight.
+func (p *Peer) Admit(r Ramp, now time.Time) bool {
+	weight := r.Weight(now.Sub(p.since))
+	if weight >= 1 {
+		return true
+	}
+
+	p.credit += weight
+	// Tolerate rounding, since repeatedly adding fractions like 0.1 falls
+	// just short of 1.
+	if p.credit < 1-1e-9 {
+		return false
+	}
+	p.credit--
+	return true
+}
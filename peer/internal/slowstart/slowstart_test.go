@@ -0,0 +1,73 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package slowstart
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeight(t *testing.T) {
+	tests := []struct {
+		desc    string
+		curve   Curve
+		elapsed time.Duration
+		want    float64
+	}{
+		{desc: "linear start", curve: Linear, elapsed: 0, want: MinWeight},
+		{desc: "linear midway", curve: Linear, elapsed: 5 * time.Second, want: 0.55},
+		{desc: "linear end", curve: Linear, elapsed: 10 * time.Second, want: 1},
+		{desc: "linear after", curve: Linear, elapsed: time.Minute, want: 1},
+		{desc: "clock skew", curve: Linear, elapsed: -time.Second, want: MinWeight},
+		{desc: "exponential start", curve: Exponential, elapsed: 0, want: MinWeight},
+		{desc: "exponential midway", curve: Exponential, elapsed: 5 * time.Second, want: 0.316},
+		{desc: "exponential end", curve: Exponential, elapsed: 10 * time.Second, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			r := Ramp{Window: 10 * time.Second, Curve: tt.curve}
+			assert.InDelta(t, tt.want, r.Weight(tt.elapsed), 0.001)
+		})
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	now := time.Now()
+	r := Ramp{Window: 10 * time.Second}
+
+	p := NewPeer(now)
+	var admitted int
+	for i := 0; i < 100; i++ {
+		if p.Admit(r, now) {
+			admitted++
+		}
+	}
+	assert.Equal(t, 10, admitted, "peers must be admitted in proportion to their weight")
+
+	for i := 0; i < 10; i++ {
+		assert.True(t, p.Admit(r, now.Add(r.Window)), "warm peers must always be admitted")
+	}
+	assert.False(t, Ramp{}.Enabled())
+	assert.True(t, r.Enabled())
+}
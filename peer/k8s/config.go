@@ -0,0 +1,73 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package k8s
+
+import (
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Configuration describes how to build a "k8s" peer list updater.
+type Configuration struct {
+	Namespace string `config:"namespace"`
+	Service   string `config:"service"`
+	PortName  string `config:"port-name"`
+}
+
+// Spec returns a configuration specification for the "k8s" peer list
+// updater, which binds a peer list to the EndpointSlices of a Kubernetes
+// service.
+//
+//	cfg := yarpcconfig.New()
+//	cfg.MustRegisterPeerListUpdater(k8s.Spec())
+//
+// This enables the k8s peer list updater under any peer list:
+//
+//	outbounds:
+//	  otherservice:
+//	    unary:
+//	      http:
+//	        url: http://host/rpc
+//	        round-robin:
+//	          k8s:
+//	            namespace: default
+//	            service: otherservice
+//	            port-name: http
+func Spec() yarpcconfig.PeerListUpdaterSpec {
+	return SpecWithOptions()
+}
+
+// SpecWithOptions accepts additional updater options.
+func SpecWithOptions(options ...Option) yarpcconfig.PeerListUpdaterSpec {
+	return yarpcconfig.PeerListUpdaterSpec{
+		Name: "k8s",
+		BuildPeerListUpdater: func(cfg Configuration, k *yarpcconfig.Kit) (peer.Binder, error) {
+			if cfg.Namespace == "" {
+				return nil, yarpcerrors.InvalidArgumentErrorf("k8s peer list updater requires a namespace")
+			}
+			if cfg.Service == "" {
+				return nil, yarpcerrors.InvalidArgumentErrorf("k8s peer list updater requires a service")
+			}
+			return Bind(cfg.Namespace, cfg.Service, cfg.PortName, options...), nil
+		},
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/whitespace"
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/yarpctest"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		desc    string
+		given   string
+		want    []string
+		wantErr string
+	}{
+		{
+			desc: "k8s",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								k8s:
+									namespace: default
+									service: myservice
+									port-name: http
+			`,
+			want: []string{"10.0.0.1:8080"},
+		},
+		{
+			desc: "missing namespace",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								k8s:
+									service: myservice
+			`,
+			wantErr: "k8s peer list updater requires a namespace",
+		},
+		{
+			desc: "missing service",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							fake-list:
+								k8s:
+									namespace: default
+			`,
+			wantErr: "k8s peer list updater requires a service",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			client := fake.NewSimpleClientset(
+				endpointSlice("myservice-a", testService, "http", 8080, ready("10.0.0.1")))
+
+			cfgr := yarpctest.NewFakeConfigurator()
+			cfgr.MustRegisterPeerListUpdater(SpecWithOptions(Clientset(client)))
+
+			cfg, err := cfgr.LoadConfigFromYAML("test", strings.NewReader(whitespace.Expand(tt.given)))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			out := cfg.Outbounds["myservice"].Unary.(*yarpctest.FakeOutbound)
+			u, ok := out.Chooser().(*peerbind.BoundChooser).Updater().(*Updater)
+			require.True(t, ok, "expected a Kubernetes updater")
+			assert.Equal(t, "default", u.namespace)
+			assert.Equal(t, "myservice", u.service)
+			assert.Equal(t, "http", u.portName)
+
+			pl := newMemberList()
+			u.pl = pl
+			require.NoError(t, u.Start())
+			defer func() { assert.NoError(t, u.Stop()) }()
+			pl.assertMembers(t, tt.want...)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package k8s provides a peer list updater that binds peer lists to the
+// EndpointSlices of a Kubernetes service.
+//
+// The updater watches the EndpointSlices of the service with a client-go
+// informer, so changes reach the peer list as soon as the API server reports
+// them, without waiting for DNS records to refresh. Each endpoint that is
+// ready becomes a peer, identified by its first address and the number of
+// the named port. Endpoints that are terminating are removed, unless no
+// endpoint is ready, in which case the terminating endpoints that still
+// serve requests are used until they go away.
+//
+// The informer retries failed lists and watches of the API server on its
+// own. Peers only change when the API server reports a change, so errors of
+// the API server never clear the peer list.
+//
+// The "k8s" configuration specification enables the updater under any peer
+// list:
+//
+//	cfg := yarpcconfig.New()
+//	cfg.MustRegisterPeerListUpdater(k8s.Spec())
+//
+//	outbounds:
+//	  otherservice:
+//	    unary:
+//	      grpc:
+//	        round-robin:
+//	          k8s:
+//	            namespace: default
+//	            service: otherservice
+//	            port-name: grpc
+//
+// Unless a client is given with the Clientset option, the updater connects
+// to the API server of the cluster it runs in, using the service account of
+// its pod. The account must be allowed to list and watch EndpointSlices in
+// the namespace.
+//
+// This package is a module of its own, go.uber.org/yarpc/peer/k8s, so that
+// only its users depend on client-go. It requires Go 1.18 or later.
+package k8s
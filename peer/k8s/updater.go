@@ -0,0 +1,334 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package k8s
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/zap"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	discoveryinformers "k8s.io/client-go/informers/discovery/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+const defaultResyncPeriod = 30 * time.Second
+
+type options struct {
+	client       kubernetes.Interface
+	resyncPeriod time.Duration
+	logger       *zap.Logger
+}
+
+var defaultOptions = options{
+	resyncPeriod: defaultResyncPeriod,
+}
+
+// Option customizes the behavior of a Kubernetes peer list updater.
+type Option func(*options)
+
+// Clientset specifies the client of the Kubernetes API.
+//
+// Defaults to a client of the API server of the cluster the process runs in.
+func Clientset(client kubernetes.Interface) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// ResyncPeriod specifies how often the peers are recomputed from the
+// EndpointSlices the informer holds, in addition to every change.
+//
+// Defaults to 30 seconds.
+func ResyncPeriod(period time.Duration) Option {
+	return func(o *options) {
+		o.resyncPeriod = period
+	}
+}
+
+// Logger specifies a logger.
+func Logger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// Bind returns a binder (suitable as an argument to peer.Bind) that binds a
+// peer list to the endpoints of the given service and namespace, each paired
+// with the number of the port with the given name.
+//
+// An empty port name selects the unnamed port of services with a single
+// port.
+func Bind(namespace, service, portName string, opts ...Option) peer.Binder {
+	options := defaultOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	logger := options.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return func(pl peer.List) transport.Lifecycle {
+		return &Updater{
+			once:      lifecycle.NewOnce(),
+			pl:        pl,
+			namespace: namespace,
+			service:   service,
+			portName:  portName,
+			opts:      options,
+			logger: logger.With(
+				zap.String("namespace", namespace),
+				zap.String("service", service)),
+			peers: make(map[string]peer.Identifier),
+		}
+	}
+}
+
+// Updater watches the EndpointSlices of a Kubernetes service and updates a
+// peer list with the differences.
+type Updater struct {
+	once      *lifecycle.Once
+	pl        peer.List
+	namespace string
+	service   string
+	portName  string
+	opts      options
+	logger    *zap.Logger
+
+	informer cache.SharedIndexInformer
+	stop     chan struct{}
+	done     chan struct{}
+
+	// mu serializes updates of the peer list, which happen on the goroutines
+	// of the informer.
+	mu      sync.Mutex
+	synced  bool
+	stopped bool
+	peers   map[string]peer.Identifier
+}
+
+// Start starts watching EndpointSlices in the background.
+func (u *Updater) Start() error {
+	return u.once.Start(u.start)
+}
+
+func (u *Updater) start() error {
+	client := u.opts.client
+	if client == nil {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return fmt.Errorf("failed to configure the Kubernetes client: %v", err)
+		}
+		if client, err = kubernetes.NewForConfig(config); err != nil {
+			return fmt.Errorf("failed to build the Kubernetes client: %v", err)
+		}
+	}
+
+	selector := labels.Set{discoveryv1.LabelServiceName: u.service}.String()
+	u.informer = discoveryinformers.NewFilteredEndpointSliceInformer(
+		client, u.namespace, u.opts.resyncPeriod, cache.Indexers{},
+		func(o *metav1.ListOptions) { o.LabelSelector = selector },
+	)
+	if err := u.informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+		u.logger.Warn("failed to watch EndpointSlices, retaining existing peers", zap.Error(err))
+	}); err != nil {
+		return err
+	}
+	u.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { u.sync() },
+		UpdateFunc: func(interface{}, interface{}) { u.sync() },
+		DeleteFunc: func(interface{}) { u.sync() },
+	})
+
+	u.stop = make(chan struct{})
+	u.done = make(chan struct{})
+	// The peer list may block updates until it has started, so the updater
+	// must not block.
+	go u.run()
+	return nil
+}
+
+// Stop stops watching EndpointSlices and removes all peers from the peer
+// list.
+func (u *Updater) Stop() error {
+	return u.once.Stop(u.stopUpdates)
+}
+
+func (u *Updater) stopUpdates() error {
+	close(u.stop)
+	<-u.done
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.stopped = true
+	var updates peer.ListUpdates
+	for _, id := range u.peers {
+		updates.Removals = append(updates.Removals, id)
+	}
+	sortIdentifiers(updates.Removals)
+	u.peers = make(map[string]peer.Identifier)
+	if len(updates.Removals) == 0 {
+		return nil
+	}
+	return u.pl.Update(updates)
+}
+
+// IsRunning returns whether the updater is watching EndpointSlices.
+func (u *Updater) IsRunning() bool {
+	return u.once.IsRunning()
+}
+
+func (u *Updater) run() {
+	defer close(u.done)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		u.informer.Run(u.stop)
+	}()
+
+	// Events for the EndpointSlices of the initial list are ignored until
+	// the list is complete, so that the peer list is updated once with all
+	// of them.
+	if cache.WaitForCacheSync(u.stop, u.informer.HasSynced) {
+		u.mu.Lock()
+		u.synced = true
+		u.mu.Unlock()
+		u.sync()
+	}
+	wg.Wait()
+}
+
+func (u *Updater) sync() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if !u.synced || u.stopped {
+		return
+	}
+
+	updates := u.diff(u.endpoints(u.informer.GetStore().List()))
+	if len(updates.Additions) == 0 && len(updates.Removals) == 0 {
+		return
+	}
+	u.logger.Debug("EndpointSlices changed",
+		zap.Int("additions", len(updates.Additions)),
+		zap.Int("removals", len(updates.Removals)))
+	if err := u.pl.Update(updates); err != nil {
+		u.logger.Error("failed to update peer list", zap.Error(err))
+	}
+}
+
+// endpoints returns the peers of the ready endpoints of the EndpointSlices,
+// or, if none is ready, of the terminating endpoints that are still
+// serving.
+func (u *Updater) endpoints(objs []interface{}) []peer.Identifier {
+	var ready, terminating []peer.Identifier
+	for _, obj := range objs {
+		slice, ok := obj.(*discoveryv1.EndpointSlice)
+		if !ok {
+			continue
+		}
+		port, ok := findPort(slice.Ports, u.portName)
+		if !ok {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if len(ep.Addresses) == 0 {
+				continue
+			}
+			id := hostport.Identify(net.JoinHostPort(ep.Addresses[0], strconv.Itoa(int(port))))
+
+			// Conditions the API server does not report are nil. Kubernetes
+			// asks consumers to treat an unknown readiness as ready.
+			conditions := ep.Conditions
+			isTerminating := conditions.Terminating != nil && *conditions.Terminating
+			switch {
+			case !isTerminating && (conditions.Ready == nil || *conditions.Ready):
+				ready = append(ready, id)
+			case isTerminating && conditions.Serving != nil && *conditions.Serving:
+				terminating = append(terminating, id)
+			}
+		}
+	}
+	if len(ready) > 0 {
+		return ready
+	}
+	return terminating
+}
+
+func findPort(ports []discoveryv1.EndpointPort, name string) (int32, bool) {
+	for _, p := range ports {
+		if p.Port == nil {
+			continue
+		}
+		if (p.Name == nil && name == "") || (p.Name != nil && *p.Name == name) {
+			return *p.Port, true
+		}
+	}
+	return 0, false
+}
+
+// diff updates the known peers and returns the changes for the peer list.
+func (u *Updater) diff(ids []peer.Identifier) peer.ListUpdates {
+	next := make(map[string]peer.Identifier, len(ids))
+	for _, id := range ids {
+		next[id.Identifier()] = id
+	}
+
+	var updates peer.ListUpdates
+	for addr, id := range u.peers {
+		if _, ok := next[addr]; !ok {
+			updates.Removals = append(updates.Removals, id)
+		}
+	}
+	for addr, id := range next {
+		if _, ok := u.peers[addr]; !ok {
+			updates.Additions = append(updates.Additions, id)
+		}
+	}
+	u.peers = next
+	sortIdentifiers(updates.Removals)
+	sortIdentifiers(updates.Additions)
+	return updates
+}
+
+func sortIdentifiers(ids []peer.Identifier) {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Identifier() < ids[j].Identifier()
+	})
+}
@@ -0,0 +1,304 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	testNamespace = "default"
+	testService   = "myservice"
+)
+
+// memberList is a peer list that tracks its members.
+type memberList struct {
+	sync.Mutex
+
+	peers map[string]struct{}
+}
+
+func newMemberList() *memberList {
+	return &memberList{peers: make(map[string]struct{})}
+}
+
+func (l *memberList) Update(updates peer.ListUpdates) error {
+	l.Lock()
+	defer l.Unlock()
+	for _, id := range updates.Removals {
+		delete(l.peers, id.Identifier())
+	}
+	for _, id := range updates.Additions {
+		l.peers[id.Identifier()] = struct{}{}
+	}
+	return nil
+}
+
+func (l *memberList) members() []string {
+	l.Lock()
+	defer l.Unlock()
+	members := make([]string, 0, len(l.peers))
+	for addr := range l.peers {
+		members = append(members, addr)
+	}
+	sort.Strings(members)
+	return members
+}
+
+func (l *memberList) assertMembers(t *testing.T, want ...string) {
+	if want == nil {
+		want = []string{}
+	}
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(want, l.members())
+	}, 5*time.Second, 10*time.Millisecond, "expected peers %v, got %v", want, l.members())
+}
+
+func endpointSlice(name, service, portName string, port int32, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &port}},
+		Endpoints:   endpoints,
+	}
+}
+
+func endpoint(addr string, ready, serving, terminating *bool) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Addresses: []string{addr},
+		Conditions: discoveryv1.EndpointConditions{
+			Ready:       ready,
+			Serving:     serving,
+			Terminating: terminating,
+		},
+	}
+}
+
+func ready(addr string) discoveryv1.Endpoint {
+	return endpoint(addr, boolPtr(true), boolPtr(true), boolPtr(false))
+}
+
+func notReady(addr string) discoveryv1.Endpoint {
+	return endpoint(addr, boolPtr(false), boolPtr(false), boolPtr(false))
+}
+
+func terminating(addr string, serving bool) discoveryv1.Endpoint {
+	return endpoint(addr, boolPtr(false), boolPtr(serving), boolPtr(true))
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func startUpdater(t *testing.T, pl peer.List, portName string, opts ...Option) *Updater {
+	u := Bind(testNamespace, testService, portName, opts...)(pl).(*Updater)
+	require.NoError(t, u.Start())
+	return u
+}
+
+func TestBind(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		endpointSlice("myservice-a", testService, "http", 8080,
+			ready("10.0.0.1"), ready("10.0.0.2"), notReady("10.0.0.3")),
+		endpointSlice("otherservice-a", "otherservice", "http", 8080, ready("10.0.1.1")),
+	)
+	slices := client.DiscoveryV1().EndpointSlices(testNamespace)
+
+	pl := newMemberList()
+	u := startUpdater(t, pl, "http", Clientset(client))
+	assert.True(t, u.IsRunning())
+	pl.assertMembers(t, "10.0.0.1:8080", "10.0.0.2:8080")
+
+	t.Run("update", func(t *testing.T) {
+		_, err := slices.Update(ctx, endpointSlice("myservice-a", testService, "http", 8080,
+			terminating("10.0.0.1", true), ready("10.0.0.2"), ready("10.0.0.3")), metav1.UpdateOptions{})
+		require.NoError(t, err)
+		pl.assertMembers(t, "10.0.0.2:8080", "10.0.0.3:8080")
+	})
+
+	t.Run("add", func(t *testing.T) {
+		_, err := slices.Create(ctx, endpointSlice("myservice-b", testService, "http", 8080,
+			ready("10.0.0.4")), metav1.CreateOptions{})
+		require.NoError(t, err)
+		pl.assertMembers(t, "10.0.0.2:8080", "10.0.0.3:8080", "10.0.0.4:8080")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, slices.Delete(ctx, "myservice-a", metav1.DeleteOptions{}))
+		pl.assertMembers(t, "10.0.0.4:8080")
+	})
+
+	require.NoError(t, u.Stop())
+	assert.False(t, u.IsRunning())
+	assert.Empty(t, pl.members(), "peers must be removed on stop")
+}
+
+func TestEndpointSelection(t *testing.T) {
+	tests := []struct {
+		desc     string
+		portName string
+		slices   []runtime.Object
+		want     []string
+	}{
+		{
+			desc:     "unknown readiness",
+			portName: "http",
+			slices: []runtime.Object{
+				endpointSlice("myservice-a", testService, "http", 8080,
+					endpoint("10.0.0.1", nil, nil, nil), ready("10.0.0.2")),
+			},
+			want: []string{"10.0.0.1:8080", "10.0.0.2:8080"},
+		},
+		{
+			desc:     "terminating endpoints while others are ready",
+			portName: "http",
+			slices: []runtime.Object{
+				endpointSlice("myservice-a", testService, "http", 8080,
+					terminating("10.0.0.1", true), ready("10.0.0.2")),
+			},
+			want: []string{"10.0.0.2:8080"},
+		},
+		{
+			desc:     "serving terminating endpoints without ready endpoints",
+			portName: "http",
+			slices: []runtime.Object{
+				endpointSlice("myservice-a", testService, "http", 8080,
+					terminating("10.0.0.1", true), terminating("10.0.0.2", false), notReady("10.0.0.3")),
+			},
+			want: []string{"10.0.0.1:8080"},
+		},
+		{
+			desc:     "port name",
+			portName: "grpc",
+			slices: []runtime.Object{
+				endpointSlice("myservice-a", testService, "http", 8080, ready("10.0.0.1")),
+				endpointSlice("myservice-b", testService, "grpc", 9090, ready("10.0.0.2")),
+			},
+			want: []string{"10.0.0.2:9090"},
+		},
+		{
+			desc: "unnamed port",
+			slices: []runtime.Object{
+				endpointSlice("myservice-a", testService, "", 8080, ready("10.0.0.1")),
+				endpointSlice("myservice-b", testService, "grpc", 9090, ready("10.0.0.2")),
+			},
+			want: []string{"10.0.0.1:8080"},
+		},
+		{
+			desc:     "duplicate endpoints",
+			portName: "http",
+			slices: []runtime.Object{
+				endpointSlice("myservice-a", testService, "http", 8080, ready("10.0.0.1")),
+				endpointSlice("myservice-b", testService, "http", 8080, ready("10.0.0.1")),
+			},
+			want: []string{"10.0.0.1:8080"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			pl := newMemberList()
+			u := startUpdater(t, pl, tt.portName, Clientset(fake.NewSimpleClientset(tt.slices...)))
+			defer func() { assert.NoError(t, u.Stop()) }()
+			pl.assertMembers(t, tt.want...)
+		})
+	}
+}
+
+func TestAPIErrorsRetainPeers(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		endpointSlice("myservice-a", testService, "http", 8080, ready("10.0.0.1"), ready("10.0.0.2")),
+	)
+
+	var (
+		mu      sync.Mutex
+		listErr error
+	)
+	client.PrependReactor("list", "endpointslices", func(k8stesting.Action) (bool, runtime.Object, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return listErr != nil, nil, listErr
+	})
+	watchers := make(chan *watch.FakeWatcher, 10)
+	client.PrependWatchReactor("endpointslices", func(k8stesting.Action) (bool, watch.Interface, error) {
+		w := watch.NewFake()
+		watchers <- w
+		return true, w, nil
+	})
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	pl := newMemberList()
+	u := startUpdater(t, pl, "http", Clientset(client), Logger(zap.New(core)))
+	defer func() { assert.NoError(t, u.Stop()) }()
+	pl.assertMembers(t, "10.0.0.1:8080", "10.0.0.2:8080")
+
+	// Drop the watch while the API server fails to list EndpointSlices.
+	mu.Lock()
+	listErr = errors.New("great sadness")
+	mu.Unlock()
+	(<-watchers).Stop()
+
+	assert.Eventually(t, func() bool {
+		return logs.FilterMessage("failed to watch EndpointSlices, retaining existing peers").Len() > 0
+	}, 10*time.Second, 10*time.Millisecond, "expected the list error to be logged")
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, pl.members(), "API errors must not change peers")
+
+	// The informer recovers once the API server lists EndpointSlices again.
+	mu.Lock()
+	listErr = nil
+	mu.Unlock()
+	w := <-watchers
+	w.Modify(endpointSlice("myservice-a", testService, "http", 8080, ready("10.0.0.2")))
+	pl.assertMembers(t, "10.0.0.2:8080")
+}
+
+func TestStartWithoutCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+
+	u := Bind(testNamespace, testService, "http")(newMemberList())
+	err := u.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to configure the Kubernetes client")
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peer
+
+import "go.uber.org/yarpc/api/peer"
+
+// IdentifyMaxPendingRequests decorates a peer identifier with the maximum
+// number of requests that may be pending to the peer at once, overriding the
+// limit of the peer list for that peer.
+// The decorated identifier retains the weight of a weighted identifier.
+//
+// Peer lists that limit pending requests, like roundrobin and pendingheap
+// with the MaxPendingRequests option, stop choosing a peer at its limit until
+// one of its requests finishes. Other peer lists disregard the limit.
+//
+// 	peer.IdentifyMaxPendingRequests(hostport.Identify("127.0.0.1:8080"), 100)
+func IdentifyMaxPendingRequests(id peer.Identifier, max int) peer.LimitedIdentifier {
+	if w, ok := id.(peer.WeightedIdentifier); ok {
+		return weightedLimitedIdentifier{
+			id:         id,
+			weight:     w.Weight(),
+			maxPending: max,
+		}
+	}
+	return limitedIdentifier{id: id, maxPending: max}
+}
+
+type limitedIdentifier struct {
+	id         peer.Identifier
+	maxPending int
+}
+
+func (l limitedIdentifier) Identifier() string {
+	return l.id.Identifier()
+}
+
+func (l limitedIdentifier) MaxPendingRequests() int {
+	return l.maxPending
+}
+
+type weightedLimitedIdentifier struct {
+	id         peer.Identifier
+	weight     int
+	maxPending int
+}
+
+func (w weightedLimitedIdentifier) Identifier() string {
+	return w.id.Identifier()
+}
+
+func (w weightedLimitedIdentifier) Weight() int {
+	return w.weight
+}
+
+func (w weightedLimitedIdentifier) MaxPendingRequests() int {
+	return w.maxPending
+}
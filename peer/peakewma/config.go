@@ -0,0 +1,119 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peakewma
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Configuration describes how to construct a peak EWMA peer list.
+type Configuration struct {
+	Capacity *int `config:"capacity"`
+	FailFast bool `config:"failFast"`
+	// DecayTime specifies how quickly the latency average of a peer forgets
+	// slow responses.
+	DecayTime *time.Duration `config:"decayTime"`
+	// ErrorPenalty specifies the minimum latency recorded for failed
+	// requests.
+	ErrorPenalty *time.Duration `config:"errorPenalty"`
+}
+
+// Spec returns a configuration specification for the "fewest pending requests
+// weighted by latency" implementation, making it possible to select the less
+// loaded of two random peers with transports that use outbound peer list
+// configuration (like HTTP).
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(peakewma.Spec())
+//
+// This enables the peak EWMA peer list:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          fewest-pending-ewma:
+//            peers:
+//              - 127.0.0.1:8080
+//              - 127.0.0.1:8081
+//
+// The decay time and error penalty tune how quickly the list forgets slow
+// responses and how heavily it penalizes failures.
+//
+//  fewest-pending-ewma:
+//    peers:
+//      - 127.0.0.1:8080
+//    decayTime: 10s
+//    errorPenalty: 1s
+func Spec() yarpcconfig.PeerListSpec {
+	return SpecWithOptions()
+}
+
+// SpecWithOptions accepts additional list constructor options.
+func SpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
+	return yarpcconfig.PeerListSpec{
+		Name: "fewest-pending-ewma",
+		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
+			opts := make([]ListOption, 0, len(options)+5)
+
+			if meter := k.Meter(); meter != nil {
+				opts = append(opts, Meter(meter))
+			}
+			opts = append(opts, options...)
+
+			if cfg.Capacity != nil {
+				if *cfg.Capacity <= 0 {
+					return nil, yarpcerrors.Newf(yarpcerrors.CodeInvalidArgument,
+						fmt.Sprintf("Capacity must be greater than 0. Got: %d.", *cfg.Capacity))
+				}
+				opts = append(opts, Capacity(*cfg.Capacity))
+			}
+
+			if cfg.FailFast {
+				opts = append(opts, FailFast())
+			}
+
+			if cfg.DecayTime != nil {
+				if *cfg.DecayTime <= 0 {
+					return nil, yarpcerrors.InvalidArgumentErrorf(
+						"DecayTime must be greater than 0. Got: %v.", *cfg.DecayTime)
+				}
+				opts = append(opts, DecayTime(*cfg.DecayTime))
+			}
+
+			if cfg.ErrorPenalty != nil {
+				if *cfg.ErrorPenalty < 0 {
+					return nil, yarpcerrors.InvalidArgumentErrorf(
+						"ErrorPenalty must not be negative. Got: %v.", *cfg.ErrorPenalty)
+				}
+				opts = append(opts, ErrorPenalty(*cfg.ErrorPenalty))
+			}
+
+			return New(t, opts...), nil
+		},
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package peakewma provides a load balancer implementation that picks two
+// peers at random and chooses the one with the lower load, where a peer's
+// load is the product of its pending request count and a peak-sensitive
+// exponentially weighted moving average (EWMA) of its request latency.
+//
+// The average ramps up to a slow response at once and decays towards faster
+// responses over the decay time, so peers that slow down lose traffic
+// quickly and regain it gradually.
+// Failed requests count as taking at least the error penalty, so that peers
+// that fail fast do not attract traffic.
+//
+// Peak EWMA is described in:
+// https://linkerd.io/2016/03/16/beyond-round-robin-load-balancing-for-latency/
+package peakewma
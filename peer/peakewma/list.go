@@ -0,0 +1,302 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peakewma
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/peer/abstractlist"
+	"go.uber.org/yarpc/pkg/clock"
+	"go.uber.org/zap"
+)
+
+type listOptions struct {
+	capacity     int
+	source       rand.Source
+	failFast     bool
+	drainTimeout time.Duration
+	decay        time.Duration
+	errorPenalty time.Duration
+	logger       *zap.Logger
+	meter        *metrics.Scope
+	pendingTopK  int
+	clock        clock.Clock
+}
+
+var defaultListOptions = listOptions{
+	capacity:     10,
+	decay:        10 * time.Second,
+	errorPenalty: time.Second,
+	clock:        clock.System,
+}
+
+func newListOptions(opts []ListOption) listOptions {
+	options := defaultListOptions
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+	if options.source == nil {
+		options.source = rand.NewSource(time.Now().UnixNano())
+	}
+	return options
+}
+
+// ListOption customizes the behavior of a peak EWMA peer list.
+type ListOption interface {
+	apply(*listOptions)
+}
+
+type listOptionFunc func(*listOptions)
+
+func (f listOptionFunc) apply(options *listOptions) { f(options) }
+
+// Capacity specifies the default capacity of the underlying
+// data structures for this list.
+//
+// Defaults to 10.
+func Capacity(capacity int) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.capacity = capacity
+	})
+}
+
+// Seed specifies the seed for generating random choices.
+func Seed(seed int64) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.source = rand.NewSource(seed)
+	})
+}
+
+// Source is a source of randomness for the peer list.
+func Source(source rand.Source) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.source = source
+	})
+}
+
+// FailFast indicates that the peer list should not wait for a peer to become
+// available when choosing a peer.
+//
+// This option is preferrable when the better failure mode is to retry from the
+// origin, since another proxy instance might already have a connection.
+func FailFast() ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.failFast = true
+	})
+}
+
+// DrainTimeout specifies how long the list waits for the requests in flight
+// to a removed peer to finish before releasing the peer to the transport.
+// Removed peers are no longer chosen, and are released once their last
+// pending request finishes or the timeout elapses.
+//
+// Defaults to 0, releasing removed peers immediately.
+func DrainTimeout(timeout time.Duration) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.drainTimeout = timeout
+	})
+}
+
+// DecayTime specifies how quickly the latency average of a peer forgets
+// slow responses.
+// The weight of a response decays by a factor of e over the decay time.
+//
+// Defaults to 10 seconds.
+func DecayTime(decay time.Duration) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.decay = decay
+	})
+}
+
+// ErrorPenalty specifies the minimum latency recorded for failed requests,
+// so that peers that fail fast do not attract traffic.
+// Client faults, like invalid arguments, are not penalized.
+//
+// Defaults to 1 second.
+func ErrorPenalty(penalty time.Duration) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.errorPenalty = penalty
+	})
+}
+
+// Logger specifies a logger.
+func Logger(logger *zap.Logger) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.logger = logger
+	})
+}
+
+// Meter specifies the scope for peer list metrics, like the numbers of
+// available and unavailable peers and the latency of choosing a peer.
+//
+// Lists sharing a meter should each use a distinctly tagged scope.
+func Meter(meter *metrics.Scope) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.meter = meter
+	})
+}
+
+// PendingRequestsTopK specifies the number of busiest peers whose pending
+// request counts are reported, by rank, in addition to the total.
+//
+// Defaults to 0, reporting only the total.
+func PendingRequestsTopK(k int) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.pendingTopK = k
+	})
+}
+
+// Clock specifies the clock that times requests and decays latency averages,
+// as well as drain timeouts, for tests that control time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.clock = c
+	})
+}
+
+// New creates a new peak EWMA peer list, choosing the less loaded of two
+// random peers.
+func New(transport peer.Transport, opts ...ListOption) *List {
+	options := newListOptions(opts)
+
+	plOpts := []abstractlist.Option{
+		abstractlist.Capacity(options.capacity),
+		abstractlist.Clock(options.clock),
+		abstractlist.NoShuffle(),
+	}
+
+	if options.logger != nil {
+		plOpts = append(plOpts, abstractlist.Logger(options.logger))
+	}
+	if options.failFast {
+		plOpts = append(plOpts, abstractlist.FailFast())
+	}
+	if options.drainTimeout > 0 {
+		plOpts = append(plOpts, abstractlist.DrainTimeout(options.drainTimeout))
+	}
+	if options.meter != nil {
+		plOpts = append(plOpts, abstractlist.Meter(options.meter), abstractlist.PendingRequestsTopK(options.pendingTopK))
+	}
+
+	return &List{
+		list: abstractlist.New(
+			"fewest-pending-ewma",
+			transport,
+			newPeakEWMAList(options),
+			plOpts...,
+		),
+	}
+}
+
+// List is a PeerList that sends requests to the less loaded of two random
+// peers, by pending requests and latency.
+type List struct {
+	list *abstractlist.List
+}
+
+// Start causes the peer list to start.
+//
+// Starting will retain all peers that have been added but not removed
+// the first time it is called.
+//
+// Start may be called any number of times and in any order in relation to Stop
+// but will only cause the list to start the first time, and only if it has not
+// already been stopped.
+func (l *List) Start() error {
+	return l.list.Start()
+}
+
+// Stop causes the peer list to stop.
+//
+// Stopping will release all retained peers to the underlying transport.
+//
+// Stop may be called any number of times and in order in relation to Start but
+// will only cause the list to stop the first time, and only if it has
+// previously been started.
+func (l *List) Stop() error {
+	return l.list.Stop()
+}
+
+// IsRunning returns whether the list has started and not yet stopped.
+func (l *List) IsRunning() bool {
+	return l.list.IsRunning()
+}
+
+// Choose returns a peer, suitable for sending a request.
+//
+// The peer is not guaranteed to be connected and available, but the peer list
+// makes every attempt to ensure this and minimize the probability that a
+// chosen peer will fail to carry a request.
+func (l *List) Choose(ctx context.Context, req *transport.Request) (peer peer.Peer, onFinish func(error), err error) {
+	return l.list.Choose(ctx, req)
+}
+
+// Update may add and remove logical peers in the list.
+//
+// The peer list uses a transport to obtain a physical peer for each logical
+// peer.
+// The transport is responsible for informing the peer list whether the peer is
+// available or unavailable, but cannot guarantee that the peer will still be
+// available after it is chosen.
+func (l *List) Update(updates peer.ListUpdates) error {
+	return l.list.Update(updates)
+}
+
+// NotifyStatusChanged forwards a status change notification to an individual
+// peer in the list.
+//
+// This satisfies the peer.Subscriber interface and should only be used to
+// send notifications in tests.
+// The list's RetainPeer and ReleasePeer methods deal with an individual
+// peer.Subscriber instance for each peer in the list, avoiding a map lookup.
+func (l *List) NotifyStatusChanged(pid peer.Identifier) {
+	l.list.NotifyStatusChanged(pid)
+}
+
+// Introspect reveals information about the list to the internal YARPC
+// introspection system.
+func (l *List) Introspect() introspection.ChooserStatus {
+	status := l.list.Introspect()
+	status.Config = l.list.IntrospectConfig()
+	return status
+}
+
+// Subscribe registers a function to call with the connection status of each
+// peer that is added to the list, removed from the list, or changes status.
+//
+// The function is called from a separate goroutine, and updates that arrive
+// while it is busy are coalesced, so a slow subscriber never blocks the list.
+func (l *List) Subscribe(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func()) {
+	return l.list.Subscribe(fn)
+}
+
+// Peers produces a slice of all retained peers.
+func (l *List) Peers() []peer.StatusPeer {
+	return l.list.Peers()
+}
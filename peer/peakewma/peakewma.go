@@ -0,0 +1,204 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peakewma
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/abstractlist"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// _unknownCostPenalty is the load of a peer with pending requests but no
+// observed latency, which is most likely a new peer that has yet to respond,
+// so that requests do not pile onto it.
+const _unknownCostPenalty = math.MaxFloat64 / 2
+
+type peakEWMAList struct {
+	subscribers  []*subscriber
+	random       *rand.Rand
+	decay        time.Duration
+	errorPenalty time.Duration
+	now          func() time.Time
+
+	m sync.Mutex
+}
+
+// NewImplementation creates a new peak EWMA abstractlist.Implementation.
+//
+// Use this constructor instead of New, when wanting to do custom peer
+// connection management.
+// Options that configure the abstract list, like FailFast, are ignored.
+func NewImplementation(opts ...ListOption) abstractlist.Implementation {
+	options := newListOptions(opts)
+	return newPeakEWMAList(options)
+}
+
+func newPeakEWMAList(options listOptions) *peakEWMAList {
+	return &peakEWMAList{
+		subscribers:  make([]*subscriber, 0, options.capacity),
+		random:       rand.New(options.source),
+		decay:        options.decay,
+		errorPenalty: options.errorPenalty,
+		now:          options.clock.Now,
+	}
+}
+
+func (l *peakEWMAList) Add(peer peer.StatusPeer, _ peer.Identifier) abstractlist.Subscriber {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	index := len(l.subscribers)
+	l.subscribers = append(l.subscribers, &subscriber{
+		list:  l,
+		index: index,
+		peer:  peer,
+		stamp: l.now(),
+	})
+	return l.subscribers[index]
+}
+
+func (l *peakEWMAList) Remove(peer peer.StatusPeer, _ peer.Identifier, ps abstractlist.Subscriber) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	sub, ok := ps.(*subscriber)
+	if !ok || len(l.subscribers) == 0 {
+		return
+	}
+	index := sub.index
+	last := len(l.subscribers) - 1
+	l.subscribers[index] = l.subscribers[last]
+	l.subscribers[index].index = index
+	l.subscribers = l.subscribers[0:last]
+}
+
+func (l *peakEWMAList) Choose(_ *transport.Request) peer.StatusPeer {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	numSubs := len(l.subscribers)
+	if numSubs == 0 {
+		return nil
+	}
+	if numSubs == 1 {
+		return l.subscribers[0].peer
+	}
+	i := l.random.Intn(numSubs)
+	j := i + 1 + l.random.Intn(numSubs-1)
+	if j >= numSubs {
+		j -= numSubs
+	}
+	now := l.now()
+	if l.subscribers[j].load(now) < l.subscribers[i].load(now) {
+		i = j
+	}
+	return l.subscribers[i].peer
+}
+
+type subscriber struct {
+	list  *peakEWMAList
+	index int
+	peer  peer.StatusPeer
+
+	// The following fields are guarded by the list lock.
+	pending int
+	// cost is the moving average of the latency of the peer, in nanoseconds,
+	// as of stamp.
+	cost  float64
+	stamp time.Time
+}
+
+var (
+	_ abstractlist.Subscriber      = (*subscriber)(nil)
+	_ abstractlist.RequestObserver = (*subscriber)(nil)
+)
+
+func (s *subscriber) UpdatePendingRequestCount(pendingRequestCount int) {
+	s.list.m.Lock()
+	defer s.list.m.Unlock()
+
+	s.pending = pendingRequestCount
+}
+
+// ObserveRequest records the latency of the request when it finishes,
+// penalizing failures.
+func (s *subscriber) ObserveRequest() func(error) {
+	start := s.list.now()
+	return func(err error) {
+		s.list.m.Lock()
+		defer s.list.m.Unlock()
+
+		now := s.list.now()
+		rtt := now.Sub(start)
+		if isFailure(err) && rtt < s.list.errorPenalty {
+			rtt = s.list.errorPenalty
+		}
+		s.observe(now, rtt)
+	}
+}
+
+// observe must be called under the list lock.
+func (s *subscriber) observe(now time.Time, rtt time.Duration) {
+	sample := float64(rtt)
+	if sample > s.cost {
+		// Ramp up to slow responses at once.
+		s.cost = sample
+	} else {
+		w := s.weight(now)
+		s.cost = s.cost*w + sample*(1-w)
+	}
+	s.stamp = now
+}
+
+// load returns the load of the peer, with the latency average decayed to the
+// given time.
+//
+// load must be called under the list lock.
+func (s *subscriber) load(now time.Time) float64 {
+	cost := s.cost * s.weight(now)
+	if cost == 0 && s.pending != 0 {
+		return _unknownCostPenalty + float64(s.pending)
+	}
+	return cost * float64(s.pending+1)
+}
+
+// weight returns the weight of the latency average as of stamp, at the
+// given time.
+func (s *subscriber) weight(now time.Time) float64 {
+	if s.list.decay <= 0 {
+		return 0
+	}
+	elapsed := now.Sub(s.stamp)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Exp(-float64(elapsed) / float64(s.list.decay))
+}
+
+func isFailure(err error) bool {
+	return err != nil && yarpcerrors.GetFaultTypeFromError(err) != yarpcerrors.ClientFault
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peakewma
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/pendingheap"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+type chooserList interface {
+	peer.Chooser
+	peer.List
+}
+
+// backend describes how a simulated peer responds.
+type backend struct {
+	latency time.Duration
+	err     error
+}
+
+type inflight struct {
+	finish   time.Time
+	err      error
+	onFinish func(error)
+}
+
+// simulate sends a request every interval for the duration of the
+// simulation and returns the number of requests sent to each peer.
+// Each request finishes after the latency of the chosen peer.
+func simulate(t *testing.T, pl chooserList, trans *yarpctest.FakeTransport, clock *yarpctest.FakeClock, backends map[string]backend, interval, duration time.Duration) map[string]int {
+	var ids []peer.Identifier
+	for id := range backends {
+		ids = append(ids, hostport.Identify(id))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Identifier() < ids[j].Identifier() })
+
+	require.NoError(t, pl.Start())
+	defer pl.Stop()
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: ids}))
+	trans.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	var pending []inflight
+	counts := make(map[string]int)
+	for end := clock.Now().Add(duration); clock.Now().Before(end); clock.Add(interval) {
+		sort.Slice(pending, func(i, j int) bool { return pending[i].finish.Before(pending[j].finish) })
+		for len(pending) > 0 && !pending[0].finish.After(clock.Now()) {
+			pending[0].onFinish(pending[0].err)
+			pending = pending[1:]
+		}
+
+		p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		b := backends[p.Identifier()]
+		counts[p.Identifier()]++
+		pending = append(pending, inflight{
+			finish:   clock.Now().Add(b.latency),
+			err:      b.err,
+			onFinish: onFinish,
+		})
+	}
+	for _, req := range pending {
+		req.onFinish(req.err)
+	}
+	return counts
+}
+
+func TestSlowPeerShare(t *testing.T) {
+	backends := map[string]backend{
+		"fast-1": {latency: 10 * time.Millisecond},
+		"fast-2": {latency: 10 * time.Millisecond},
+		"fast-3": {latency: 10 * time.Millisecond},
+		"slow":   {latency: 100 * time.Millisecond},
+	}
+	const (
+		interval = 5 * time.Millisecond
+		duration = time.Minute
+	)
+
+	heapClock := yarpctest.NewFakeClock()
+	heapTrans := yarpctest.NewFakeTransport()
+	heapCounts := simulate(t, pendingheap.New(heapTrans, pendingheap.Clock(heapClock), pendingheap.Seed(0)), heapTrans, heapClock, backends, interval, duration)
+
+	ewmaClock := yarpctest.NewFakeClock()
+	ewmaTrans := yarpctest.NewFakeTransport()
+	ewmaCounts := simulate(t, New(ewmaTrans, Clock(ewmaClock), Seed(0)), ewmaTrans, ewmaClock, backends, interval, duration)
+
+	total := int(duration / interval)
+	heapShare := float64(heapCounts["slow"]) / float64(total)
+	ewmaShare := float64(ewmaCounts["slow"]) / float64(total)
+	t.Logf("share of the slow peer: fewest-pending %.3f, fewest-pending-ewma %.3f", heapShare, ewmaShare)
+	assert.True(t, ewmaShare < heapShare/4,
+		"expected the slow peer to receive a much smaller share with fewest-pending-ewma (%.3f) than fewest-pending (%.3f)", ewmaShare, heapShare)
+}
+
+func TestErrorPenalty(t *testing.T) {
+	backends := map[string]backend{
+		"healthy-1": {latency: 10 * time.Millisecond},
+		"healthy-2": {latency: 10 * time.Millisecond},
+		"healthy-3": {latency: 10 * time.Millisecond},
+		"failing":   {latency: time.Millisecond, err: yarpcerrors.UnavailableErrorf("down")},
+	}
+	const (
+		interval = 5 * time.Millisecond
+		duration = time.Minute
+	)
+	total := int(duration / interval)
+
+	tests := []struct {
+		desc      string
+		opts      []ListOption
+		wantShare func(float64) bool
+	}{
+		{
+			desc:      "penalized",
+			wantShare: func(share float64) bool { return share < 0.01 },
+		},
+		{
+			desc:      "not penalized",
+			opts:      []ListOption{ErrorPenalty(0)},
+			wantShare: func(share float64) bool { return share > 0.25 },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			clock := yarpctest.NewFakeClock()
+			trans := yarpctest.NewFakeTransport()
+			opts := append([]ListOption{Clock(clock), Seed(0)}, tt.opts...)
+			counts := simulate(t, New(trans, opts...), trans, clock, backends, interval, duration)
+
+			share := float64(counts["failing"]) / float64(total)
+			assert.True(t, tt.wantShare(share), "unexpected share of the failing peer: %.3f", share)
+		})
+	}
+}
+
+func TestClientFaultsNotPenalized(t *testing.T) {
+	assert.False(t, isFailure(nil))
+	assert.False(t, isFailure(yarpcerrors.InvalidArgumentErrorf("bad request")))
+	assert.True(t, isFailure(yarpcerrors.InternalErrorf("oops")))
+	assert.True(t, isFailure(context.DeadlineExceeded))
+}
+
+func TestPeakEWMA(t *testing.T) {
+	clock := yarpctest.NewFakeClock()
+	now := clock.Now()
+	list := newPeakEWMAList(newListOptions([]ListOption{Clock(clock), DecayTime(10 * time.Second)}))
+	sub := list.Add(nil, nil).(*subscriber)
+
+	assert.Equal(t, 0.0, sub.load(now), "unobserved idle peers have no load")
+	sub.pending = 2
+	assert.Equal(t, _unknownCostPenalty+2, sub.load(now), "unobserved busy peers have a penalty")
+
+	sub.observe(now, 100*time.Millisecond)
+	assert.Equal(t, float64(100*time.Millisecond)*3, sub.load(now), "the latency peak applies at once")
+
+	sub.observe(now, 10*time.Millisecond)
+	assert.Equal(t, float64(100*time.Millisecond)*3, sub.load(now), "faster responses have no weight without elapsed time")
+
+	now = now.Add(10 * time.Second)
+	sub.observe(now, 10*time.Millisecond)
+	assert.InDelta(t, float64(10*time.Millisecond)+float64(90*time.Millisecond)/2.718281828, sub.cost, float64(time.Microsecond),
+		"faster responses decay the average over the decay time")
+}
@@ -22,8 +22,10 @@ package pendingheap
 
 import (
 	"fmt"
+	"time"
 
 	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/peer/internal/slowstart"
 	"go.uber.org/yarpc/yarpcconfig"
 	"go.uber.org/yarpc/yarpcerrors"
 )
@@ -32,6 +34,14 @@ import (
 type Configuration struct {
 	Capacity *int `config:"capacity"`
 	FailFast bool `config:"failFast"`
+	// MaxPendingRequests limits the number of requests that may be pending
+	// to each peer at once.
+	MaxPendingRequests *int `config:"maxPendingRequests"`
+	// SlowStart specifies a warm-up window over which the share of traffic
+	// of newly available peers ramps up to their full share.
+	SlowStart *time.Duration `config:"slowStart"`
+	// SlowStartCurve is either "linear", the default, or "exponential".
+	SlowStartCurve string `config:"slowStartCurve"`
 }
 
 // Spec returns a configuration specification for the pending heap peer list
@@ -59,12 +69,19 @@ type Configuration struct {
 // fail-fast option.
 // With fail-fast enabled, the peer list will return an error immediately if no
 // peers are available (connected) at the time the request is sent.
+// The maximum pending requests limits the requests in flight to each peer, so
+// that a slow peer cannot absorb them all.
+// The slow start window ramps up the share of traffic of peers that become
+// available, linearly by default or exponentially.
 //
 //  fewest-pending-requests:
 //    peers:
 //      - 127.0.0.1:8080
 //    capacity: 1
 //    failFast: true
+//    maxPendingRequests: 100
+//    slowStart: 30s
+//    slowStartCurve: exponential
 func Spec() yarpcconfig.PeerListSpec {
 	return SpecWithOptions()
 }
@@ -76,6 +93,9 @@ func SpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
 		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
 			opts := make([]ListOption, 0, len(options)+2)
 
+			if meter := k.Meter(); meter != nil {
+				opts = append(opts, Meter(meter))
+			}
 			opts = append(opts, options...)
 
 			if cfg.Capacity != nil {
@@ -90,7 +110,35 @@ func SpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
 				opts = append(opts, FailFast())
 			}
 
+			if cfg.MaxPendingRequests != nil {
+				if *cfg.MaxPendingRequests <= 0 {
+					return nil, yarpcerrors.InvalidArgumentErrorf(
+						"MaxPendingRequests must be greater than 0. Got: %d.", *cfg.MaxPendingRequests)
+				}
+				opts = append(opts, MaxPendingRequests(*cfg.MaxPendingRequests))
+			}
+
+			if cfg.SlowStart != nil {
+				opt, err := slowStartOption(*cfg.SlowStart, cfg.SlowStartCurve)
+				if err != nil {
+					return nil, err
+				}
+				opts = append(opts, opt)
+			}
+
 			return New(t, opts...), nil
 		},
 	}
 }
+
+func slowStartOption(window time.Duration, curveName string) (ListOption, error) {
+	if window <= 0 {
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"SlowStart must be greater than 0. Got: %v.", window)
+	}
+	curve, err := slowstart.ParseCurve(curveName)
+	if err != nil {
+		return nil, err
+	}
+	return slowStart(slowstart.Ramp{Window: window, Curve: curve}), nil
+}
@@ -22,6 +22,7 @@ package pendingheap
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/yarpc/api/peer"
@@ -32,6 +33,7 @@ import (
 
 func TestPendingHeapConfig(t *testing.T) {
 	minus1, zero, twenty := -1, 0, 20
+	minute, noWindow := time.Minute, time.Duration(0)
 	tests := []struct {
 		name    string
 		cfg     Configuration
@@ -60,6 +62,41 @@ func TestPendingHeapConfig(t *testing.T) {
 				Capacity: &twenty,
 			},
 		},
+		{
+			name: "slow start",
+			cfg: Configuration{
+				SlowStart:      &minute,
+				SlowStartCurve: "exponential",
+			},
+		},
+		{
+			name: "max pending requests",
+			cfg: Configuration{
+				MaxPendingRequests: &twenty,
+			},
+		},
+		{
+			name: "zero max pending requests",
+			cfg: Configuration{
+				MaxPendingRequests: &zero,
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero slow start window",
+			cfg: Configuration{
+				SlowStart: &noWindow,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid slow start curve",
+			cfg: Configuration{
+				SlowStart:      &minute,
+				SlowStartCurve: "quadratic",
+			},
+			wantErr: true,
+		},
 	}
 
 	s := Spec()
@@ -28,6 +28,7 @@ import (
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/peer/abstractlist"
+	"go.uber.org/yarpc/peer/internal/slowstart"
 )
 
 type pendingHeap struct {
@@ -45,6 +46,9 @@ type pendingHeap struct {
 	//
 	// nextRand MUST return a number in [0, numPeers)
 	nextRand func(numPeers int) int
+
+	slowStart slowstart.Ramp
+	now       func() time.Time
 }
 
 // Option configures the peer list implementation constructor.
@@ -52,7 +56,21 @@ type Option interface {
 	apply(*options)
 }
 
-type options struct{}
+type options struct {
+	slowStart slowstart.Ramp
+	now       func() time.Time
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+func withSlowStart(ramp slowstart.Ramp, now func() time.Time) Option {
+	return optionFunc(func(o *options) {
+		o.slowStart = ramp
+		o.now = now
+	})
+}
 
 // NewImplementation creates a new fewest pending heap
 // abstractlist.Implementation.
@@ -60,12 +78,18 @@ type options struct{}
 // Use this constructor instead of NewList, when wanting to do custom peer
 // connection management.
 func NewImplementation(opts ...Option) abstractlist.Implementation {
-	return newHeap(nextRand(time.Now().UnixNano()))
+	return newHeap(nextRand(time.Now().UnixNano()), opts...)
 }
 
-func newHeap(nextRand func(numPeers int) int) *pendingHeap {
+func newHeap(nextRand func(numPeers int) int, opts ...Option) *pendingHeap {
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
 	return &pendingHeap{
-		nextRand: nextRand,
+		nextRand:  nextRand,
+		slowStart: o.slowStart,
+		now:       o.now,
 	}
 }
 
@@ -77,6 +101,10 @@ func (ph *pendingHeap) Choose(req *transport.Request) peer.StatusPeer {
 		return nil
 	}
 
+	if ph.slowStart.Enabled() {
+		ps = ph.chooseWarm(ps)
+	}
+
 	// Note: We push the peer back to reset the "next" counter.
 	// This gives us round-robin behavior.
 	ph.pushPeer(ps)
@@ -91,6 +119,9 @@ func (ph *pendingHeap) Add(p peer.StatusPeer, _ peer.Identifier) abstractlist.Su
 	}
 
 	ps := &peerScore{peer: p, heap: ph}
+	if ph.slowStart.Enabled() {
+		ps.warmup = slowstart.NewPeer(ph.now())
+	}
 
 	ph.Lock()
 	ph.pushPeerRandom(ps)
@@ -227,6 +258,32 @@ func (ph *pendingHeap) pushPeerRandom(ps *peerScore) {
 	heap.Push(ph, ps)
 }
 
+// chooseWarm passes over warming peers, starting with the given popped peer,
+// until one has accrued enough credit to be chosen, and returns it popped.
+// Peers passed over are pushed back in the heap.
+//
+// If every peer is passed over, the one with the best score is chosen.
+//
+// chooseWarm must be called in the context of a lock.
+func (ph *pendingHeap) chooseWarm(ps *peerScore) *peerScore {
+	now := ph.now()
+	var skipped []*peerScore
+	for !ps.warmup.Admit(ph.slowStart, now) {
+		skipped = append(skipped, ps)
+		next, ok := ph.popPeer()
+		if !ok {
+			ps, skipped = skipped[0], skipped[1:]
+			break
+		}
+		ps = next
+	}
+
+	for _, s := range skipped {
+		ph.pushPeer(s)
+	}
+	return ps
+}
+
 // popPeer must be called in the context of a lock.
 func (ph *pendingHeap) popPeer() (*peerScore, bool) {
 	if ph.Len() == 0 {
@@ -25,26 +25,36 @@ import (
 	"math/rand"
 	"time"
 
+	"go.uber.org/net/metrics"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/peer/abstractlist"
+	"go.uber.org/yarpc/peer/internal/slowstart"
+	"go.uber.org/yarpc/pkg/clock"
 	"go.uber.org/zap"
 )
 
 type listConfig struct {
-	capacity int
-	shuffle  bool
-	failFast bool
-	seed     int64
-	nextRand func(int) int
-	logger   *zap.Logger
+	capacity           int
+	shuffle            bool
+	failFast           bool
+	drainTimeout       time.Duration
+	maxPendingRequests int
+	seed               int64
+	nextRand           func(int) int
+	logger             *zap.Logger
+	meter              *metrics.Scope
+	topK               int
+	slowStart          slowstart.Ramp
+	clock              clock.Clock
 }
 
 var defaultListConfig = listConfig{
 	capacity: 10,
 	shuffle:  true,
 	seed:     time.Now().UnixNano(),
+	clock:    clock.System,
 }
 
 // ListOption customizes the behavior of a pending requests peer heap.
@@ -76,6 +86,26 @@ func Logger(logger *zap.Logger) ListOption {
 	}
 }
 
+// Meter specifies the scope for peer list metrics, like the numbers of
+// available and unavailable peers and the latency of choosing a peer.
+//
+// Lists sharing a meter should each use a distinctly tagged scope.
+func Meter(meter *metrics.Scope) ListOption {
+	return func(c *listConfig) {
+		c.meter = meter
+	}
+}
+
+// PendingRequestsTopK specifies the number of busiest peers whose pending
+// request counts are reported, by rank, in addition to the total.
+//
+// Defaults to 0, reporting only the total.
+func PendingRequestsTopK(k int) ListOption {
+	return func(c *listConfig) {
+		c.topK = k
+	}
+}
+
 // FailFast indicates that the peer list should not wait for a peer to become
 // available when choosing a peer.
 //
@@ -87,6 +117,65 @@ func FailFast() ListOption {
 	}
 }
 
+// DrainTimeout specifies how long the list waits for the requests in flight
+// to a removed peer to finish before releasing the peer to the transport.
+// Removed peers are no longer chosen, and are released once their last
+// pending request finishes or the timeout elapses.
+//
+// Defaults to 0, releasing removed peers immediately.
+func DrainTimeout(timeout time.Duration) ListOption {
+	return func(c *listConfig) {
+		c.drainTimeout = timeout
+	}
+}
+
+// MaxPendingRequests limits the number of requests that may be pending to
+// each peer at once.
+// Peers at their limit are not chosen until one of their requests finishes.
+// If every available peer is at its limit, Choose waits for capacity and
+// returns a ResourceExhausted error if none frees before the deadline.
+// Use peer.IdentifyMaxPendingRequests to override the limit for a peer.
+//
+// Defaults to 0, not limiting pending requests.
+func MaxPendingRequests(max int) ListOption {
+	return func(c *listConfig) {
+		c.maxPendingRequests = max
+	}
+}
+
+// SlowStart specifies a warm-up window for peers that become available.
+// Over the window, the share of traffic a peer receives ramps up linearly
+// from a tenth of its full share, giving new instances the chance to warm
+// their caches before receiving full load.
+//
+// Slow start is disabled by default.
+func SlowStart(window time.Duration) ListOption {
+	return slowStart(slowstart.Ramp{Window: window, Curve: slowstart.Linear})
+}
+
+// ExponentialSlowStart specifies a warm-up window like SlowStart, but ramps
+// up the share of traffic exponentially, so that peers receive little
+// traffic for most of the window.
+func ExponentialSlowStart(window time.Duration) ListOption {
+	return slowStart(slowstart.Ramp{Window: window, Curve: slowstart.Exponential})
+}
+
+func slowStart(ramp slowstart.Ramp) ListOption {
+	return func(c *listConfig) {
+		c.slowStart = ramp
+	}
+}
+
+// Clock specifies the clock that paces slow start and drain timeouts, for
+// tests that control time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) ListOption {
+	return func(cfg *listConfig) {
+		cfg.clock = c
+	}
+}
+
 // New creates a new pending heap.
 func New(transport peer.Transport, opts ...ListOption) *List {
 	cfg := defaultListConfig
@@ -96,6 +185,7 @@ func New(transport peer.Transport, opts ...ListOption) *List {
 
 	plOpts := []abstractlist.Option{
 		abstractlist.Capacity(cfg.capacity),
+		abstractlist.Clock(cfg.clock),
 		abstractlist.Logger(cfg.logger),
 	}
 	if !cfg.shuffle {
@@ -104,6 +194,15 @@ func New(transport peer.Transport, opts ...ListOption) *List {
 	if cfg.failFast {
 		plOpts = append(plOpts, abstractlist.FailFast())
 	}
+	if cfg.drainTimeout > 0 {
+		plOpts = append(plOpts, abstractlist.DrainTimeout(cfg.drainTimeout))
+	}
+	if cfg.maxPendingRequests > 0 {
+		plOpts = append(plOpts, abstractlist.MaxPendingRequests(cfg.maxPendingRequests))
+	}
+	if cfg.meter != nil {
+		plOpts = append(plOpts, abstractlist.Meter(cfg.meter), abstractlist.PendingRequestsTopK(cfg.topK))
+	}
 
 	nextRandFn := nextRand(cfg.seed)
 	if cfg.nextRand != nil {
@@ -115,7 +214,7 @@ func New(transport peer.Transport, opts ...ListOption) *List {
 		list: abstractlist.New(
 			"fewest-pending-requests",
 			transport,
-			newHeap(nextRandFn),
+			newHeap(nextRandFn, withSlowStart(cfg.slowStart, cfg.clock.Now)),
 			plOpts...,
 		),
 	}
@@ -201,7 +300,18 @@ func (l *List) NotifyStatusChanged(pid peer.Identifier) {
 // Introspect reveals information about the list to the internal YARPC
 // introspection system.
 func (l *List) Introspect() introspection.ChooserStatus {
-	return l.list.Introspect()
+	status := l.list.Introspect()
+	status.Config = l.list.IntrospectConfig()
+	return status
+}
+
+// Subscribe registers a function to call with the connection status of each
+// peer that is added to the list, removed from the list, or changes status.
+//
+// The function is called from a separate goroutine, and updates that arrive
+// while it is busy are coalesced, so a slow subscriber never blocks the list.
+func (l *List) Subscribe(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func()) {
+	return l.list.Subscribe(fn)
 }
 
 // Peers produces a slice of all retained peers.
@@ -23,6 +23,7 @@ package pendingheap
 import (
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/peer/abstractlist"
+	"go.uber.org/yarpc/peer/internal/slowstart"
 )
 
 var _ abstractlist.Subscriber = (*peerScore)(nil)
@@ -36,6 +37,7 @@ type peerScore struct {
 	pending int
 	index   int // index in the peer list.
 	last    int // snapshot of the heap's incrementing counter.
+	warmup  slowstart.Peer
 }
 
 func (ps *peerScore) UpdatePendingRequestCount(pendingRequestCount int) {
@@ -0,0 +1,128 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pinned
+
+import (
+	"context"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// New creates a peer chooser that sends requests to the procedures in pins
+// to the peer the procedure is pinned to, like "127.0.0.1:8080", and lets
+// the fallback chooser choose peers for other requests.
+//
+// The chooser starts and stops the fallback chooser with its own lifecycle.
+func New(pins map[string]string, fallback peer.Chooser) *Chooser {
+	copied := make(map[string]string, len(pins))
+	for procedure, id := range pins {
+		copied[procedure] = id
+	}
+	return &Chooser{
+		once:     lifecycle.NewOnce(),
+		pins:     copied,
+		fallback: fallback,
+	}
+}
+
+var _ peer.ChooserList = (*Chooser)(nil)
+var _ introspection.IntrospectableChooser = (*Chooser)(nil)
+
+// Chooser is a peer chooser that pins procedures to peers.
+type Chooser struct {
+	once     *lifecycle.Once
+	pins     map[string]string
+	fallback peer.Chooser
+}
+
+// Choose returns the peer that the procedure of the request is pinned to, if
+// any, or a peer from the fallback chooser otherwise.
+//
+// Requests to a pinned procedure fail, rather than going to another peer, if
+// the pinned peer is not among the peers of the fallback chooser or is not
+// available.
+func (c *Chooser) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	id, ok := c.pins[req.Procedure]
+	if !ok {
+		return c.fallback.Choose(ctx, req)
+	}
+
+	p, onFinish, err := c.fallback.Choose(peer.WithPinnedPeer(ctx, id), req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Identifier() != id {
+		// The fallback chooser ignored the pinned peer.
+		err := yarpcerrors.FailedPreconditionErrorf(
+			"procedure %q is pinned to peer %q, but the peer chooser chose peer %q", req.Procedure, id, p.Identifier())
+		onFinish(err)
+		return nil, nil, err
+	}
+	return p, onFinish, nil
+}
+
+// Update forwards the peers to the fallback chooser, if it is a peer list.
+func (c *Chooser) Update(updates peer.ListUpdates) error {
+	if list, ok := c.fallback.(peer.List); ok {
+		return list.Update(updates)
+	}
+	return nil
+}
+
+// Start starts the fallback chooser.
+func (c *Chooser) Start() error {
+	return c.once.Start(c.fallback.Start)
+}
+
+// Stop stops the fallback chooser.
+func (c *Chooser) Stop() error {
+	return c.once.Stop(c.fallback.Stop)
+}
+
+// IsRunning returns whether the chooser is running.
+func (c *Chooser) IsRunning() bool {
+	return c.once.IsRunning()
+}
+
+// Introspect reveals the pins, and the peers and status of the fallback
+// chooser.
+func (c *Chooser) Introspect() introspection.ChooserStatus {
+	pins := make(map[string]interface{}, len(c.pins))
+	for procedure, id := range c.pins {
+		pins[procedure] = id
+	}
+
+	status := introspection.ChooserStatus{
+		Name:   "pinned",
+		State:  c.once.State().String(),
+		Config: map[string]interface{}{"pins": pins},
+	}
+	if ic, ok := c.fallback.(introspection.IntrospectableChooser); ok {
+		fallback := ic.Introspect()
+		status.Peers = fallback.Peers
+		status.Choosers = append(status.Choosers, fallback)
+	}
+	return status
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package pinned
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func identifyAll(ids ...string) []peer.Identifier {
+	pids := make([]peer.Identifier, len(ids))
+	for i, id := range ids {
+		pids[i] = hostport.Identify(id)
+	}
+	return pids
+}
+
+// choose makes n requests to the procedure and returns the number of
+// requests sent to each peer.
+func choose(t *testing.T, c *Chooser, procedure string, n int) map[string]int {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		p, onFinish, err := c.Choose(ctx, &transport.Request{Procedure: procedure})
+		require.NoError(t, err)
+		counts[p.Identifier()]++
+		onFinish(nil)
+	}
+	return counts
+}
+
+func chooseErr(c *Chooser, procedure string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	_, _, err := c.Choose(ctx, &transport.Request{Procedure: procedure})
+	return err
+}
+
+func TestPinnedProcedures(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	c := New(map[string]string{"write": "leader"}, roundrobin.New(trans))
+	require.NoError(t, c.Start())
+	defer c.Stop()
+	assert.True(t, c.IsRunning())
+
+	require.NoError(t, c.Update(peer.ListUpdates{Additions: identifyAll("leader", "follower-1", "follower-2")}))
+	assert.Equal(t, map[string]int{"leader": 10}, choose(t, c, "write", 10),
+		"expected requests to the pinned procedure to go to its peer")
+	assert.Equal(t, map[string]int{"leader": 4, "follower-1": 4, "follower-2": 4}, choose(t, c, "read", 12),
+		"expected other requests to go to the fallback chooser")
+
+	status := c.Introspect()
+	assert.Equal(t, "pinned", status.Name)
+	assert.Equal(t, map[string]interface{}{"write": "leader"}, status.Config["pins"])
+	assert.Len(t, status.Peers, 3)
+	require.Len(t, status.Choosers, 1)
+	assert.Equal(t, "round-robin", status.Choosers[0].Name)
+}
+
+func TestPinnedPeerUnavailable(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	c := New(map[string]string{"write": "leader"}, roundrobin.New(trans, roundrobin.FailFast()))
+	require.NoError(t, c.Start())
+	defer c.Stop()
+
+	require.NoError(t, c.Update(peer.ListUpdates{Additions: identifyAll("leader", "follower")}))
+	trans.SimulateDisconnect(hostport.Identify("leader"))
+	err := chooseErr(c, "write")
+	assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code(),
+		"expected an error rather than another peer: %v", err)
+
+	require.NoError(t, c.Update(peer.ListUpdates{Removals: identifyAll("leader")}))
+	err = chooseErr(c, "write")
+	assert.Equal(t, yarpcerrors.CodeNotFound, yarpcerrors.FromError(err).Code(),
+		"expected an error rather than another peer: %v", err)
+
+	assert.Equal(t, map[string]int{"follower": 2}, choose(t, c, "read", 2))
+}
+
+// ignoringChooser always chooses its peer, ignoring pinned peers.
+type ignoringChooser struct {
+	peer.Chooser
+
+	p        peer.Peer
+	finished error
+}
+
+func (c *ignoringChooser) Choose(context.Context, *transport.Request) (peer.Peer, func(error), error) {
+	return c.p, func(err error) { c.finished = err }, nil
+}
+
+func TestFallbackIgnoresPinnedPeer(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	p, err := trans.RetainPeer(hostport.Identify("follower"), nil)
+	require.NoError(t, err)
+
+	fallback := &ignoringChooser{p: p}
+	c := New(map[string]string{"write": "leader"}, fallback)
+	err = chooseErr(c, "write")
+	assert.Equal(t, yarpcerrors.CodeFailedPrecondition, yarpcerrors.FromError(err).Code())
+	assert.Contains(t, err.Error(), `procedure "write" is pinned to peer "leader", but the peer chooser chose peer "follower"`)
+	assert.Equal(t, err, fallback.finished, "expected the request to the chosen peer to finish")
+}
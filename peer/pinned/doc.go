@@ -0,0 +1,37 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package pinned provides a peer chooser that sends the requests to some
+// procedures to specific peers, regardless of the policy of the peer chooser
+// that chooses peers for other requests, as for procedures that must reach
+// the write leader of a replicated system.
+//
+// The chooser is also a peer list: bind it to a peer list updater and it
+// forwards every peer to the fallback chooser, if that is a peer list.
+//
+//  chooser := pinned.New(map[string]string{
+//    "KeyValue::setValue": "10.0.0.1:4040",
+//  }, roundrobin.New(transport))
+//
+// Requests to a pinned procedure fail if the pinned peer is not among the
+// peers of the fallback chooser or is not available, rather than going to
+// another peer. The fallback chooser must support peers pinned with
+// peer.WithPinnedPeer, as all peer lists built with abstractlist do.
+package pinned
@@ -68,6 +68,9 @@ func SpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
 		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
 			opts := make([]ListOption, 0, len(options)+2)
 
+			if meter := k.Meter(); meter != nil {
+				opts = append(opts, Meter(meter))
+			}
 			opts = append(opts, options...)
 
 			if cfg.Capacity != nil {
@@ -25,10 +25,12 @@ import (
 	"math/rand"
 	"time"
 
+	"go.uber.org/net/metrics"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/peer/abstractlist"
+	"go.uber.org/yarpc/pkg/clock"
 	"go.uber.org/zap"
 )
 
@@ -36,8 +38,12 @@ type listOptions struct {
 	capacity             int
 	source               rand.Source
 	failFast             bool
+	drainTimeout         time.Duration
+	clock                clock.Clock
 	defaultChooseTimeout *time.Duration
 	logger               *zap.Logger
+	meter                *metrics.Scope
+	pendingTopK          int
 }
 
 var defaultListOptions = listOptions{
@@ -88,6 +94,28 @@ func FailFast() ListOption {
 	})
 }
 
+// DrainTimeout specifies how long the list waits for the requests in flight
+// to a removed peer to finish before releasing the peer to the transport.
+// Removed peers are no longer chosen, and are released once their last
+// pending request finishes or the timeout elapses.
+//
+// Defaults to 0, releasing removed peers immediately.
+func DrainTimeout(timeout time.Duration) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.drainTimeout = timeout
+	})
+}
+
+// Clock specifies the clock that times out draining peers, for tests that
+// control time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.clock = c
+	})
+}
+
 // Logger specifies a logger.
 func Logger(logger *zap.Logger) ListOption {
 	return listOptionFunc(func(options *listOptions) {
@@ -95,6 +123,26 @@ func Logger(logger *zap.Logger) ListOption {
 	})
 }
 
+// Meter specifies the scope for peer list metrics, like the numbers of
+// available and unavailable peers and the latency of choosing a peer.
+//
+// Lists sharing a meter should each use a distinctly tagged scope.
+func Meter(meter *metrics.Scope) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.meter = meter
+	})
+}
+
+// PendingRequestsTopK specifies the number of busiest peers whose pending
+// request counts are reported, by rank, in addition to the total.
+//
+// Defaults to 0, reporting only the total.
+func PendingRequestsTopK(k int) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.pendingTopK = k
+	})
+}
+
 // DefaultChooseTimeout specifies the default timeout to add to 'Choose' calls
 // without context deadlines. This prevents long-lived streams from setting
 // calling deadlines.
@@ -128,6 +176,15 @@ func New(transport peer.Transport, opts ...ListOption) *List {
 	if options.failFast {
 		plOpts = append(plOpts, abstractlist.FailFast())
 	}
+	if options.drainTimeout > 0 {
+		plOpts = append(plOpts, abstractlist.DrainTimeout(options.drainTimeout))
+	}
+	if options.clock != nil {
+		plOpts = append(plOpts, abstractlist.Clock(options.clock))
+	}
+	if options.meter != nil {
+		plOpts = append(plOpts, abstractlist.Meter(options.meter), abstractlist.PendingRequestsTopK(options.pendingTopK))
+	}
 	if options.defaultChooseTimeout != nil {
 		plOpts = append(plOpts, abstractlist.DefaultChooseTimeout(*options.defaultChooseTimeout))
 	}
@@ -209,7 +266,18 @@ func (l *List) NotifyStatusChanged(pid peer.Identifier) {
 // Introspect reveals information about the list to the internal YARPC
 // introspection system.
 func (l *List) Introspect() introspection.ChooserStatus {
-	return l.list.Introspect()
+	status := l.list.Introspect()
+	status.Config = l.list.IntrospectConfig()
+	return status
+}
+
+// Subscribe registers a function to call with the connection status of each
+// peer that is added to the list, removed from the list, or changes status.
+//
+// The function is called from a separate goroutine, and updates that arrive
+// while it is busy are coalesced, so a slow subscriber never blocks the list.
+func (l *List) Subscribe(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func()) {
+	return l.list.Subscribe(fn)
 }
 
 // Peers produces a slice of all retained peers.
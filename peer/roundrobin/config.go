@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/peer/internal/slowstart"
 	"go.uber.org/yarpc/yarpcconfig"
 	"go.uber.org/yarpc/yarpcerrors"
 )
@@ -37,6 +38,14 @@ type Configuration struct {
 	// present. This enables calls without deadlines, ie streaming, to choose
 	// peers without waiting indefinitely.
 	DefaultChooseTimeout *time.Duration `config:"defaultChooseTimeout"`
+	// MaxPendingRequests limits the number of requests that may be pending
+	// to each peer at once.
+	MaxPendingRequests *int `config:"maxPendingRequests"`
+	// SlowStart specifies a warm-up window over which the share of traffic
+	// of newly available peers ramps up to their full share.
+	SlowStart *time.Duration `config:"slowStart"`
+	// SlowStartCurve is either "linear", the default, or "exponential".
+	SlowStartCurve string `config:"slowStartCurve"`
 }
 
 // Spec returns a configuration specification for the round-robin peer list
@@ -66,6 +75,10 @@ type Configuration struct {
 // peers are available (connected) at the time the request is sent.
 // The default choose timeout enables calls without deadlines, ie streaming, to
 // choose peers without waiting indefinitely.
+// The maximum pending requests limits the requests in flight to each peer, so
+// that a slow peer cannot absorb them all.
+// The slow start window ramps up the share of traffic of peers that become
+// available, linearly by default or exponentially.
 //
 //  round-robin:
 //    peers:
@@ -73,6 +86,9 @@ type Configuration struct {
 //    capacity: 1
 //    failFast: true
 //    defaultChooseTimeout: 1s
+//    maxPendingRequests: 100
+//    slowStart: 30s
+//    slowStartCurve: exponential
 func Spec() yarpcconfig.PeerListSpec {
 	return SpecWithOptions()
 }
@@ -84,6 +100,9 @@ func SpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
 		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
 			opts := make([]ListOption, 0, len(options)+3)
 
+			if meter := k.Meter(); meter != nil {
+				opts = append(opts, Meter(meter))
+			}
 			opts = append(opts, options...)
 
 			if cfg.Capacity != nil {
@@ -99,7 +118,33 @@ func SpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
 			if cfg.DefaultChooseTimeout != nil {
 				opts = append(opts, DefaultChooseTimeout(*cfg.DefaultChooseTimeout))
 			}
+			if cfg.MaxPendingRequests != nil {
+				if *cfg.MaxPendingRequests <= 0 {
+					return nil, yarpcerrors.InvalidArgumentErrorf(
+						"MaxPendingRequests must be greater than 0. Got: %d.", *cfg.MaxPendingRequests)
+				}
+				opts = append(opts, MaxPendingRequests(*cfg.MaxPendingRequests))
+			}
+			if cfg.SlowStart != nil {
+				opt, err := slowStartOption(*cfg.SlowStart, cfg.SlowStartCurve)
+				if err != nil {
+					return nil, err
+				}
+				opts = append(opts, opt)
+			}
 			return New(t, opts...), nil
 		},
 	}
 }
+
+func slowStartOption(window time.Duration, curveName string) (ListOption, error) {
+	if window <= 0 {
+		return nil, yarpcerrors.InvalidArgumentErrorf(
+			"SlowStart must be greater than 0. Got: %v.", window)
+	}
+	curve, err := slowstart.ParseCurve(curveName)
+	if err != nil {
+		return nil, err
+	}
+	return slowStart(slowstart.Ramp{Window: window, Curve: curve}), nil
+}
@@ -21,10 +21,16 @@
 package roundrobin
 
 import (
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
 	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/internal/whitespace"
+	peerbind "go.uber.org/yarpc/peer"
 	"go.uber.org/yarpc/peer/hostport"
 	"go.uber.org/yarpc/yarpcconfig"
 	"go.uber.org/yarpc/yarpctest"
@@ -32,6 +38,7 @@ import (
 
 func TestPendingHeapConfig(t *testing.T) {
 	minus1, zero, twenty := -1, 0, 20
+	minute, noWindow := time.Minute, time.Duration(0)
 	tests := []struct {
 		name    string
 		cfg     Configuration
@@ -60,6 +67,41 @@ func TestPendingHeapConfig(t *testing.T) {
 				Capacity: &twenty,
 			},
 		},
+		{
+			name: "slow start",
+			cfg: Configuration{
+				SlowStart:      &minute,
+				SlowStartCurve: "exponential",
+			},
+		},
+		{
+			name: "max pending requests",
+			cfg: Configuration{
+				MaxPendingRequests: &twenty,
+			},
+		},
+		{
+			name: "zero max pending requests",
+			cfg: Configuration{
+				MaxPendingRequests: &zero,
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero slow start window",
+			cfg: Configuration{
+				SlowStart: &noWindow,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid slow start curve",
+			cfg: Configuration{
+				SlowStart:      &minute,
+				SlowStartCurve: "quadratic",
+			},
+			wantErr: true,
+		},
 	}
 
 	s := Spec()
@@ -78,3 +120,43 @@ func TestPendingHeapConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestConfiguratorMetrics(t *testing.T) {
+	root := metrics.New()
+	cfgr := yarpctest.NewFakeConfigurator(yarpcconfig.Metrics(root.Scope()))
+	cfgr.MustRegisterPeerList(Spec())
+
+	cfg, err := cfgr.LoadConfigFromYAML("myservice", strings.NewReader(whitespace.Expand(`
+		outbounds:
+			otherservice:
+				fake-transport:
+					round-robin:
+						peers:
+							- 127.0.0.1:8080
+							- 127.0.0.1:8081
+	`)))
+	require.NoError(t, err)
+	assert.Equal(t, root.Scope(), cfg.Metrics.Metrics, "dispatcher must use the configured scope")
+
+	out := cfg.Outbounds["otherservice"].Unary.(*yarpctest.FakeOutbound)
+	list := out.Chooser().(*peerbind.BoundChooser).ChooserList()
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{
+		hostport.PeerIdentifier("127.0.0.1:8080"),
+		hostport.PeerIdentifier("127.0.0.1:8081"),
+	}}))
+
+	var found bool
+	for _, g := range root.Snapshot().Gauges {
+		if g.Name != "peer_list_peers" || g.Tags["rpc_type"] != "Unary" {
+			continue
+		}
+		found = true
+		assert.Equal(t, int64(2), g.Value)
+		assert.Equal(t, "myservice", g.Tags["dispatcher"])
+		assert.Equal(t, "otherservice", g.Tags["outbound"])
+		assert.Equal(t, "round-robin", g.Tags["peer_list"])
+	}
+	assert.True(t, found, "expected unary peer list metrics")
+}
@@ -39,6 +39,7 @@ import (
 	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/internal/testtime"
 	"go.uber.org/yarpc/internal/whitespace"
+	yarpcpeer "go.uber.org/yarpc/peer"
 	"go.uber.org/yarpc/peer/abstractpeer"
 	"go.uber.org/yarpc/peer/hostport"
 	"go.uber.org/yarpc/transport/http"
@@ -1025,3 +1026,105 @@ func TestFailFastConfig(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "has 1 peer but it is not responsive")
 }
+
+func TestSlowStart(t *testing.T) {
+	tests := []struct {
+		desc   string
+		option func(time.Duration) ListOption
+		// wantNew is the number of the 40 requests sent to the new peer
+		// after each step of the warm-up.
+		wantNew []int
+	}{
+		{desc: "linear", option: SlowStart, wantNew: []int{1, 6, 10}},
+		{desc: "exponential", option: ExponentialSlowStart, wantNew: []int{1, 4, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			clock := yarpctest.NewFakeClock()
+			trans := yarpctest.NewFakeTransport()
+			pl := New(trans, tt.option(time.Minute), Clock(clock), seed(0))
+			require.NoError(t, pl.Start())
+			defer pl.Stop()
+
+			require.NoError(t, pl.Update(peer.ListUpdates{Additions: []peer.Identifier{
+				hostport.Identify("1"), hostport.Identify("2"), hostport.Identify("3"),
+			}}))
+			trans.Flush()
+
+			// The original peers warm up before the new peer arrives.
+			clock.Add(time.Minute)
+			require.NoError(t, pl.Update(peer.ListUpdates{Additions: []peer.Identifier{
+				hostport.Identify("new"),
+			}}))
+			trans.Flush()
+
+			ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+			defer cancel()
+
+			for i, want := range tt.wantNew {
+				var got int
+				for j := 0; j < 40; j++ {
+					p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+					require.NoError(t, err)
+					onFinish(nil)
+					if p.Identifier() == "new" {
+						got++
+					}
+				}
+				assert.Equal(t, want, got, "unexpected share of traffic after %v", time.Duration(i)*30*time.Second)
+				clock.Add(30 * time.Second)
+			}
+		})
+	}
+}
+
+func TestMaxPendingRequestsOverflow(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	pl := New(trans, MaxPendingRequests(10))
+	require.NoError(t, pl.Start())
+	defer pl.Stop()
+
+	// The slow peer accepts requests but does not finish them, and has a
+	// lower limit than the other peers.
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: []peer.Identifier{
+		yarpcpeer.IdentifyMaxPendingRequests(hostport.Identify("slow"), 2),
+		hostport.Identify("2"),
+		hostport.Identify("3"),
+	}}))
+	trans.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	onFinishes := make(map[string][]func(error))
+	for i := 0; i < 22; i++ {
+		p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		onFinishes[p.Identifier()] = append(onFinishes[p.Identifier()], onFinish)
+	}
+	defer func() {
+		for _, fs := range onFinishes {
+			for _, onFinish := range fs {
+				onFinish(nil)
+			}
+		}
+	}()
+	assert.Len(t, onFinishes["slow"], 2, "expected overflow traffic to go to the other peers")
+	assert.Len(t, onFinishes["2"], 10)
+	assert.Len(t, onFinishes["3"], 10)
+
+	// Every peer is at its limit.
+	shortCtx, shortCancel := context.WithTimeout(ctx, 10*testtime.Millisecond)
+	defer shortCancel()
+	_, _, err := pl.Choose(shortCtx, &transport.Request{})
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+
+	// Finishing a request to the slow peer frees its capacity.
+	onFinishes["slow"][0](nil)
+	onFinishes["slow"] = onFinishes["slow"][1:]
+	p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, "slow", p.Identifier())
+	onFinish(nil)
+}
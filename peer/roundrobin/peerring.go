@@ -23,10 +23,12 @@ package roundrobin
 import (
 	"container/ring"
 	"sync"
+	"time"
 
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/peer/abstractlist"
+	"go.uber.org/yarpc/peer/internal/slowstart"
 )
 
 // Option configures the peer list implementation constructor.
@@ -34,19 +36,42 @@ type Option interface {
 	apply(*options)
 }
 
-type options struct{}
+type options struct {
+	slowStart slowstart.Ramp
+	now       func() time.Time
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+func withSlowStart(ramp slowstart.Ramp, now func() time.Time) Option {
+	return optionFunc(func(o *options) {
+		o.slowStart = ramp
+		o.now = now
+	})
+}
 
 // NewImplementation creates a new round-robin abstractlist.Implementation.
 //
 // Use this constructor instead of NewList, when wanting to do custom peer
 // connection management.
 func NewImplementation(opts ...Option) abstractlist.Implementation {
-	return &peerRing{}
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return &peerRing{
+		slowStart: o.slowStart,
+		now:       o.now,
+	}
 }
 
 type subscriber struct {
 	peer peer.StatusPeer
 	node *ring.Ring
+	// warmup is only tracked with slow start enabled.
+	warmup slowstart.Peer
 }
 
 func (s *subscriber) UpdatePendingRequestCount(int) {}
@@ -56,6 +81,9 @@ func (s *subscriber) UpdatePendingRequestCount(int) {}
 type peerRing struct {
 	nextNode *ring.Ring
 
+	slowStart slowstart.Ramp
+	now       func() time.Time
+
 	m sync.RWMutex
 }
 
@@ -66,6 +94,9 @@ func (pr *peerRing) Add(p peer.StatusPeer, _ peer.Identifier) abstractlist.Subsc
 	defer pr.m.Unlock()
 
 	sub := &subscriber{peer: p}
+	if pr.slowStart.Enabled() {
+		sub.warmup = slowstart.NewPeer(pr.now())
+	}
 	newNode := ring.New(1)
 	newNode.Value = sub
 	sub.node = newNode
@@ -117,12 +148,33 @@ func (pr *peerRing) Choose(_ *transport.Request) peer.StatusPeer {
 		return nil
 	}
 
+	if pr.slowStart.Enabled() {
+		return pr.chooseWarm()
+	}
+
 	p := getPeerForRingNode(pr.nextNode)
 	pr.nextNode = pr.nextNode.Next()
 
 	return p
 }
 
+// chooseWarm advances around the ring, passing over warming peers until they
+// have accrued enough credit to be chosen.
+// Every pass accrues credit, so this terminates even if every peer is
+// warming.
+//
+// chooseWarm must be called in the context of a lock.
+func (pr *peerRing) chooseWarm() peer.StatusPeer {
+	now := pr.now()
+	for {
+		sub := pr.nextNode.Value.(*subscriber)
+		pr.nextNode = pr.nextNode.Next()
+		if sub.warmup.Admit(pr.slowStart, now) {
+			return sub.peer
+		}
+	}
+}
+
 func getPeerForRingNode(rNode *ring.Ring) peer.StatusPeer {
 	return rNode.Value.(*subscriber).peer
 }
@@ -28,6 +28,7 @@ import (
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/yarpc/yarpcerrors"
 )
 
 // Single implements the Chooser interface for a single peer
@@ -61,6 +62,9 @@ func (s *Single) Choose(ctx context.Context, _ *transport.Request) (peer.Peer, f
 	if err := s.once.WaitUntilRunning(ctx); err != nil {
 		return nil, nil, err
 	}
+	if id, ok := peer.PinnedPeerFromContext(ctx); ok && id != s.pid.Identifier() {
+		return nil, nil, yarpcerrors.NotFoundErrorf("single peer chooser does not contain peer %q", id)
+	}
 	s.p.StartRequest()
 	return s.p, s.boundOnFinish, s.err
 }
@@ -21,12 +21,17 @@
 package peer_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apipeer "go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/peer"
 	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/yarpc/yarpctest"
 )
 
@@ -37,3 +42,19 @@ func TestSingleIntrospect(t *testing.T) {
 		Peers: []introspection.PeerStatus{{Identifier: "x", State: "uninitialized"}},
 	}, single.Introspect())
 }
+
+func TestSinglePinnedPeer(t *testing.T) {
+	single := peer.NewSingle(hostport.PeerIdentifier("127.0.0.1:8080"), yarpctest.NewFakeTransport())
+	require.NoError(t, single.Start())
+	defer single.Stop()
+
+	ctx := apipeer.WithPinnedPeer(context.Background(), "127.0.0.1:8080")
+	p, onFinish, err := single.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	onFinish(nil)
+	assert.Equal(t, "127.0.0.1:8080", p.Identifier())
+
+	ctx = apipeer.WithPinnedPeer(context.Background(), "127.0.0.1:8081")
+	_, _, err = single.Choose(ctx, &transport.Request{})
+	assert.True(t, yarpcerrors.IsNotFound(err), "expected NotFound, got %v", err)
+}
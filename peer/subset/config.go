@@ -0,0 +1,107 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package subset
+
+import (
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Configuration describes how to build a subset peer list.
+type Configuration struct {
+	// Size is the number of peers to forward to the underlying peer list.
+	Size *int `config:"size"`
+	// ClientID determines which subset of peers this client chooses.
+	// Defaults to the host name.
+	ClientID *string `config:"clientID,interpolate"`
+	// With is the name of the underlying peer list, which chooses among the
+	// subset of peers.
+	With string `config:"with"`
+	// Etc captures the configuration of the underlying peer list.
+	Etc map[string]interface{} `config:",squash"`
+}
+
+// Spec returns a configuration specification for the subset peer list,
+// making it possible to connect to a deterministic subset of peers with
+// transports that use outbound peer list configuration (like HTTP).
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(subset.Spec())
+//  cfg.MustRegisterPeerList(roundrobin.Spec())
+//
+// The subset peer list must name the underlying peer list, registered with
+// the same Configurator, that chooses among the subset of peers.
+// Attributes other than the size and client ID configure the underlying
+// peer list.
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          subset:
+//            size: 10
+//            clientID: ${HOSTNAME}
+//            with: round-robin
+//            failFast: true
+//            peers:
+//              - 127.0.0.1:8080
+//              - 127.0.0.1:8081
+//
+// Other than a specific peer or peers list, use any peer list updater
+// registered with a yarpc Configurator.
+func Spec() yarpcconfig.PeerListSpec {
+	return SpecWithOptions()
+}
+
+// SpecWithOptions accepts additional list constructor options.
+func SpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
+	return yarpcconfig.PeerListSpec{
+		Name: "subset",
+		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
+			if cfg.With == "" {
+				return nil, yarpcerrors.InvalidArgumentErrorf(
+					"subset peer list requires the name of an underlying peer list in the \"with\" attribute")
+			}
+
+			opts := make([]ListOption, 0, len(options)+2)
+			opts = append(opts, options...)
+
+			if cfg.Size != nil {
+				if *cfg.Size <= 0 {
+					return nil, yarpcerrors.InvalidArgumentErrorf(
+						"Size must be greater than 0. Got: %d.", *cfg.Size)
+				}
+				opts = append(opts, Size(*cfg.Size))
+			}
+			if cfg.ClientID != nil {
+				opts = append(opts, ClientID(*cfg.ClientID))
+			}
+
+			list, err := k.BuildPeerList(cfg.With, cfg.Etc, t)
+			if err != nil {
+				return nil, err
+			}
+			return New(list, opts...), nil
+		},
+	}
+}
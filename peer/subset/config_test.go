@@ -0,0 +1,142 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package subset
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/internal/whitespace"
+	peerbind "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func TestSubsetConfig(t *testing.T) {
+	tests := []struct {
+		desc    string
+		given   string
+		wantErr string
+	}{
+		{
+			desc: "subset of round-robin",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							subset:
+								size: 2
+								clientID: host-a
+								with: round-robin
+								failFast: true
+								peers:
+									- 127.0.0.1:8080
+									- 127.0.0.1:8081
+									- 127.0.0.1:8082
+									- 127.0.0.1:8083
+			`,
+		},
+		{
+			desc: "missing underlying list",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							subset:
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: `requires the name of an underlying peer list in the "with" attribute`,
+		},
+		{
+			desc: "unknown underlying list",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							subset:
+								with: bogus
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: `no recognized peer list or chooser "bogus"`,
+		},
+		{
+			desc: "invalid size",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							subset:
+								size: 0
+								with: round-robin
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "Size must be greater than 0. Got: 0.",
+		},
+		{
+			desc: "invalid underlying list attribute",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							subset:
+								with: round-robin
+								bogus: true
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "has invalid keys: bogus",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			cfgr := yarpctest.NewFakeConfigurator()
+			cfgr.MustRegisterPeerList(Spec())
+			cfgr.MustRegisterPeerList(roundrobin.Spec())
+
+			cfg, err := cfgr.LoadConfigFromYAML("test", strings.NewReader(whitespace.Expand(tt.given)))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			out := cfg.Outbounds["myservice"].Unary.(*yarpctest.FakeOutbound)
+			pl, ok := out.Chooser().(*peerbind.BoundChooser).ChooserList().(*List)
+			require.True(t, ok, "expected a subset list, got %T", out.Chooser())
+			assert.Len(t, pl.Subset(), 0, "peers must not be added before start")
+
+			d := yarpc.NewDispatcher(cfg)
+			require.NoError(t, d.Start())
+			defer d.Stop()
+
+			assert.Len(t, pl.Subset(), 2)
+			assert.IsType(t, &roundrobin.List{}, pl.list)
+		})
+	}
+}
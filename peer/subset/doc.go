@@ -0,0 +1,37 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package subset provides a peer list decorator that forwards only a
+// deterministic subset of the peers it receives to an underlying peer list.
+//
+// Large pools of clients that each connect to every server produce enormous
+// connection fan-in. With subsetting, every client connects to a fixed number
+// of servers, and the deterministic subsetting algorithm spreads clients
+// evenly across all servers.
+//
+// The subset for a client depends only on the client identifier, the subset
+// size, and the current set of peers, so a client selects the same subset
+// across restarts. Adding or removing a peer changes the subset of each
+// client by at most one peer, unless the number of subsets, the number of
+// peers divided by the subset size, changes as well.
+//
+// See "Site Reliability Engineering", chapter 20: Load Balancing in the
+// Datacenter, https://sre.google/sre-book/load-balancing-datacenter/.
+package subset
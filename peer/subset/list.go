@@ -0,0 +1,215 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package subset
+
+import (
+	"context"
+	"os"
+	"sort"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+)
+
+const defaultSize = 10
+
+type listOptions struct {
+	size     int
+	clientID *string
+}
+
+// ListOption customizes the behavior of a subset list.
+type ListOption func(*listOptions)
+
+// Size specifies the number of peers to forward to the underlying peer list.
+//
+// Defaults to 10.
+func Size(size int) ListOption {
+	return func(o *listOptions) {
+		o.size = size
+	}
+}
+
+// ClientID specifies the identifier of this client, which determines which
+// subset of peers it chooses. Clients with the same identifier choose the same
+// subset.
+//
+// Defaults to the host name.
+func ClientID(id string) ListOption {
+	return func(o *listOptions) {
+		o.clientID = &id
+	}
+}
+
+// New creates a peer list that forwards a deterministic subset of the peers
+// it receives to the given peer list.
+func New(list peer.ChooserList, opts ...ListOption) *List {
+	options := listOptions{size: defaultSize}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.size < 1 {
+		options.size = defaultSize
+	}
+
+	var clientID string
+	if options.clientID != nil {
+		clientID = *options.clientID
+	} else {
+		// The subset is still deterministic, albeit shared by all clients,
+		// if the host name is not available.
+		clientID, _ = os.Hostname()
+	}
+
+	return &List{
+		list:     list,
+		size:     options.size,
+		clientID: numericClientID(clientID),
+		peers:    make(map[string]peer.Identifier),
+		subset:   make(map[string]peer.Identifier),
+	}
+}
+
+var _ peer.ChooserList = (*List)(nil)
+var _ introspection.IntrospectableChooser = (*List)(nil)
+
+// List is a peer list that forwards a deterministic subset of its peers to an
+// underlying peer list, which chooses among them.
+type List struct {
+	list     peer.ChooserList
+	size     int
+	clientID uint64
+
+	lock   sync.Mutex
+	peers  map[string]peer.Identifier
+	subset map[string]peer.Identifier
+}
+
+// Update adds and removes peers from the list, and forwards the resulting
+// changes to the subset to the underlying peer list.
+//
+// The subset for the current peers is computed from scratch, so the changes
+// forwarded to the underlying list may include peers other than those in the
+// given updates.
+func (l *List) Update(updates peer.ListUpdates) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var errs error
+	for _, id := range updates.Removals {
+		addr := id.Identifier()
+		if _, ok := l.peers[addr]; !ok {
+			errs = multierr.Append(errs, peer.ErrPeerRemoveNotInList(addr))
+			continue
+		}
+		delete(l.peers, addr)
+	}
+	for _, id := range updates.Additions {
+		addr := id.Identifier()
+		if _, ok := l.peers[addr]; ok {
+			errs = multierr.Append(errs, peer.ErrPeerAddAlreadyInList(addr))
+			continue
+		}
+		l.peers[addr] = id
+	}
+
+	subset := make(map[string]peer.Identifier, l.size)
+	for _, id := range choose(l.sortedPeers(), l.clientID, l.size) {
+		subset[id.Identifier()] = id
+	}
+
+	var forward peer.ListUpdates
+	for addr, id := range l.subset {
+		if _, ok := subset[addr]; !ok {
+			forward.Removals = append(forward.Removals, id)
+		}
+	}
+	for addr, id := range subset {
+		if _, ok := l.subset[addr]; !ok {
+			forward.Additions = append(forward.Additions, id)
+		}
+	}
+	l.subset = subset
+
+	if len(forward.Additions) == 0 && len(forward.Removals) == 0 {
+		return errs
+	}
+	return multierr.Append(errs, l.list.Update(forward))
+}
+
+// sortedPeers must be called under the list lock.
+func (l *List) sortedPeers() []peer.Identifier {
+	addrs := make([]string, 0, len(l.peers))
+	for addr := range l.peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	ids := make([]peer.Identifier, len(addrs))
+	for i, addr := range addrs {
+		ids[i] = l.peers[addr]
+	}
+	return ids
+}
+
+// Subset returns the identifiers of the peers currently forwarded to the
+// underlying peer list, in no particular order.
+func (l *List) Subset() []peer.Identifier {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	ids := make([]peer.Identifier, 0, len(l.subset))
+	for _, id := range l.subset {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Choose returns a peer from the underlying peer list.
+func (l *List) Choose(ctx context.Context, req *transport.Request) (peer peer.Peer, onFinish func(error), err error) {
+	return l.list.Choose(ctx, req)
+}
+
+// Start starts the underlying peer list.
+func (l *List) Start() error {
+	return l.list.Start()
+}
+
+// Stop stops the underlying peer list.
+func (l *List) Stop() error {
+	return l.list.Stop()
+}
+
+// IsRunning returns whether the underlying peer list is running.
+func (l *List) IsRunning() bool {
+	return l.list.IsRunning()
+}
+
+// Introspect introspects the underlying peer list.
+func (l *List) Introspect() introspection.ChooserStatus {
+	if ic, ok := l.list.(introspection.IntrospectableChooser); ok {
+		return ic.Introspect()
+	}
+	return introspection.ChooserStatus{}
+}
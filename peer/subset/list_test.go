@@ -0,0 +1,135 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package subset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+// recordingList records the peers forwarded to it.
+type recordingList struct {
+	*yarpctest.FakePeerList
+
+	peers map[string]struct{}
+}
+
+func newRecordingList() *recordingList {
+	return &recordingList{
+		FakePeerList: yarpctest.NewFakePeerList(),
+		peers:        make(map[string]struct{}),
+	}
+}
+
+func (l *recordingList) Update(updates peer.ListUpdates) error {
+	for _, id := range updates.Removals {
+		delete(l.peers, id.Identifier())
+	}
+	for _, id := range updates.Additions {
+		l.peers[id.Identifier()] = struct{}{}
+	}
+	return nil
+}
+
+func TestListForwardsSubset(t *testing.T) {
+	peers := makePeers(30)
+	rec := newRecordingList()
+	pl := New(rec, Size(5), ClientID("host-a"))
+
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: peers}))
+	assert.Len(t, rec.peers, 5)
+	assert.Equal(t, identifiers(pl.Subset()), rec.peers)
+
+	// The same client ID yields the same subset after a restart.
+	restarted := newRecordingList()
+	require.NoError(t, New(restarted, Size(5), ClientID("host-a")).Update(peer.ListUpdates{Additions: peers}))
+	assert.Equal(t, rec.peers, restarted.peers)
+
+	// Removing a peer outside of the subset changes nothing downstream.
+	var outside peer.Identifier
+	for _, id := range peers {
+		if _, ok := rec.peers[id.Identifier()]; !ok {
+			outside = id
+			break
+		}
+	}
+	before := identifiers(pl.Subset())
+	require.NoError(t, pl.Update(peer.ListUpdates{Removals: []peer.Identifier{outside}}))
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: []peer.Identifier{outside}}))
+	assert.Equal(t, before, rec.peers)
+
+	// Removing a peer in the subset replaces it with another peer.
+	var inside peer.Identifier
+	for _, id := range peers {
+		if _, ok := rec.peers[id.Identifier()]; ok {
+			inside = id
+			break
+		}
+	}
+	require.NoError(t, pl.Update(peer.ListUpdates{Removals: []peer.Identifier{inside}}))
+	assert.Len(t, rec.peers, 5)
+	assert.NotContains(t, rec.peers, inside.Identifier())
+}
+
+func TestListInvalidUpdates(t *testing.T) {
+	pl := New(newRecordingList(), Size(2))
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: makePeers(3)}))
+
+	err := pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{hostport.Identify("10.0.0.0:4040")},
+		Removals:  []peer.Identifier{hostport.Identify("10.0.0.9:4040")},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `can't add peer "10.0.0.0:4040" because is already in peerlist`)
+	assert.Contains(t, err.Error(), `can't remove peer (10.0.0.9:4040) because it is not in peerlist`)
+}
+
+func TestListChoosesFromSubset(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	pl := New(roundrobin.New(trans), Size(3), ClientID("7"))
+	require.NoError(t, pl.Start())
+	defer pl.Stop()
+	assert.True(t, pl.IsRunning())
+
+	require.NoError(t, pl.Update(peer.ListUpdates{Additions: makePeers(12)}))
+	trans.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	subset := identifiers(pl.Subset())
+	for i := 0; i < 12; i++ {
+		p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		onFinish(nil)
+		assert.Contains(t, subset, p.Identifier())
+	}
+	assert.Equal(t, "round-robin", pl.Introspect().Name)
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package subset
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	"go.uber.org/yarpc/api/peer"
+)
+
+// choose returns the subset of the given peers for a client.
+//
+// This is the deterministic subsetting algorithm, except that peers are
+// shuffled by sorting them on a hash of their identifier and the round,
+// rather than with a seeded random shuffle. Unlike a random shuffle, this
+// keeps the relative order of peers stable as peers are added and removed,
+// so the subset of a client changes by at most one peer for each membership
+// change.
+//
+// Clients are divided into rounds, each of which divides all peers into
+// disjoint subsets, so that clients within a round are spread evenly over
+// every peer. Each round orders the peers differently, so the peers left
+// over when the number of peers is not a multiple of the subset size are
+// covered by other rounds.
+func choose(peers []peer.Identifier, clientID uint64, size int) []peer.Identifier {
+	if len(peers) <= size {
+		return peers
+	}
+
+	subsetCount := uint64(len(peers) / size)
+	round := clientID / subsetCount
+	subsetID := clientID % subsetCount
+
+	ordered := make([]rankedPeer, len(peers))
+	for i, id := range peers {
+		ordered[i] = rankedPeer{id: id, rank: rank(round, id.Identifier())}
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].rank != ordered[j].rank {
+			return ordered[i].rank < ordered[j].rank
+		}
+		return ordered[i].id.Identifier() < ordered[j].id.Identifier()
+	})
+
+	start := int(subsetID) * size
+	subset := make([]peer.Identifier, size)
+	for i := range subset {
+		subset[i] = ordered[start+i].id
+	}
+	return subset
+}
+
+type rankedPeer struct {
+	id   peer.Identifier
+	rank uint64
+}
+
+// rank is the position of a peer in the shuffled order for a round.
+func rank(round uint64, id string) uint64 {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], round)
+
+	h := fnv.New64a()
+	h.Write(buf[:])
+	h.Write([]byte(id))
+	return h.Sum64()
+}
+
+// numericClientID converts a client identifier into the integer client ID
+// that the deterministic subsetting algorithm expects.
+//
+// Client identifiers that are non-negative integers, like the index of an
+// instance in its fleet, are used as-is, which spreads consecutive clients
+// exactly evenly over peers. Other identifiers, like host names, are hashed,
+// which spreads clients evenly on average.
+func numericClientID(clientID string) uint64 {
+	if id, err := strconv.ParseUint(clientID, 10, 64); err == nil {
+		return id
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(clientID))
+	return h.Sum64()
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package subset
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/peer/hostport"
+)
+
+func makePeers(n int) []peer.Identifier {
+	ids := make([]peer.Identifier, n)
+	for i := range ids {
+		ids[i] = hostport.Identify(fmt.Sprintf("10.0.0.%d:4040", i))
+	}
+	return ids
+}
+
+func identifiers(ids []peer.Identifier) map[string]struct{} {
+	set := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		set[id.Identifier()] = struct{}{}
+	}
+	return set
+}
+
+func TestChooseSmallerThanSize(t *testing.T) {
+	peers := makePeers(3)
+	assert.Equal(t, peers, choose(peers, 42, 5))
+}
+
+func TestChooseIsStable(t *testing.T) {
+	peers := makePeers(50)
+	reversed := make([]peer.Identifier, len(peers))
+	for i, id := range peers {
+		reversed[len(peers)-1-i] = id
+	}
+
+	for _, clientID := range []string{"0", "7", "host-a", "host-b"} {
+		t.Run(clientID, func(t *testing.T) {
+			id := numericClientID(clientID)
+			first := choose(peers, id, 10)
+			assert.Len(t, first, 10)
+			assert.Equal(t, first, choose(peers, id, 10), "subset must not change for the same client")
+			assert.Equal(t, identifiers(first), identifiers(choose(reversed, id, 10)),
+				"subset must not depend on the order of peers")
+		})
+	}
+}
+
+func TestChooseCoverage(t *testing.T) {
+	const (
+		numPeers   = 40
+		numClients = 400
+		size       = 10
+	)
+	peers := makePeers(numPeers)
+
+	t.Run("sequential client IDs", func(t *testing.T) {
+		counts := make(map[string]int)
+		for c := 0; c < numClients; c++ {
+			for _, id := range choose(peers, numericClientID(strconv.Itoa(c)), size) {
+				counts[id.Identifier()]++
+			}
+		}
+		assert.Len(t, counts, numPeers, "every peer must be used by some client")
+		for addr, count := range counts {
+			assert.Equal(t, numClients*size/numPeers, count,
+				"every peer must be used by the same number of clients: %v", addr)
+		}
+	})
+
+	t.Run("hashed client IDs", func(t *testing.T) {
+		counts := make(map[string]int)
+		for c := 0; c < numClients; c++ {
+			for _, id := range choose(peers, numericClientID(fmt.Sprintf("host-%d", c)), size) {
+				counts[id.Identifier()]++
+			}
+		}
+		assert.Len(t, counts, numPeers, "every peer must be used by some client")
+	})
+}
+
+func TestChooseChurn(t *testing.T) {
+	peers := makePeers(55)
+	for c := 0; c < 100; c++ {
+		clientID := numericClientID(fmt.Sprintf("host-%d", c))
+		before := identifiers(choose(peers[:54], clientID, 10))
+		after := identifiers(choose(peers, clientID, 10))
+
+		var changed int
+		for addr := range after {
+			if _, ok := before[addr]; !ok {
+				changed++
+			}
+		}
+		assert.True(t, changed <= 1, "adding one peer must replace at most one peer, replaced %d", changed)
+	}
+}
@@ -64,6 +64,9 @@ func SpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
 		BuildPeerList: func(cfg Configuration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
 			opts := make([]ListOption, 0, len(options)+2)
 
+			if meter := k.Meter(); meter != nil {
+				opts = append(opts, Meter(meter))
+			}
 			opts = append(opts, options...)
 
 			if cfg.Capacity != nil {
@@ -82,3 +85,78 @@ func SpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
 		},
 	}
 }
+
+// PowerOfChoicesConfiguration describes how to construct a power-of-choices
+// peer list.
+type PowerOfChoicesConfiguration struct {
+	Capacity *int `config:"capacity"`
+	FailFast bool `config:"failFast"`
+	// Choices is the number of random peers from which the peer with the
+	// fewest pending requests is chosen. Defaults to 2.
+	Choices *int `config:"choices"`
+}
+
+// PowerOfChoicesSpec returns a configuration specification for the "fewest
+// pending requests of d random peers" implementation, a generalization of
+// the two-random-choices peer list that considers more peers for every
+// request.
+//
+//  cfg := yarpcconfig.New()
+//  cfg.MustRegisterPeerList(tworandomchoices.PowerOfChoicesSpec())
+//
+// This enables the power-of-choices peer list:
+//
+//  outbounds:
+//    otherservice:
+//      unary:
+//        http:
+//          url: https://host:port/rpc
+//          power-of-choices:
+//            choices: 3
+//            peers:
+//              - 127.0.0.1:8080
+//              - 127.0.0.1:8081
+//              - 127.0.0.1:8082
+//
+// If the list has fewer peers than choices, all peers are considered.
+func PowerOfChoicesSpec() yarpcconfig.PeerListSpec {
+	return PowerOfChoicesSpecWithOptions()
+}
+
+// PowerOfChoicesSpecWithOptions accepts additional list constructor options.
+func PowerOfChoicesSpecWithOptions(options ...ListOption) yarpcconfig.PeerListSpec {
+	return yarpcconfig.PeerListSpec{
+		Name: "power-of-choices",
+		BuildPeerList: func(cfg PowerOfChoicesConfiguration, t peer.Transport, k *yarpcconfig.Kit) (peer.ChooserList, error) {
+			opts := make([]ListOption, 0, len(options)+4)
+			opts = append(opts, listName("power-of-choices"))
+
+			if meter := k.Meter(); meter != nil {
+				opts = append(opts, Meter(meter))
+			}
+			opts = append(opts, options...)
+
+			if cfg.Capacity != nil {
+				if *cfg.Capacity <= 0 {
+					return nil, yarpcerrors.Newf(yarpcerrors.CodeInvalidArgument,
+						fmt.Sprintf("Capacity must be greater than 0. Got: %d.", *cfg.Capacity))
+				}
+				opts = append(opts, Capacity(*cfg.Capacity))
+			}
+
+			if cfg.FailFast {
+				opts = append(opts, FailFast())
+			}
+
+			if cfg.Choices != nil {
+				if *cfg.Choices < defaultChoices {
+					return nil, yarpcerrors.InvalidArgumentErrorf(
+						"Choices must be at least 2. Got: %d.", *cfg.Choices)
+				}
+				opts = append(opts, Choices(*cfg.Choices))
+			}
+
+			return New(t, opts...), nil
+		},
+	}
+}
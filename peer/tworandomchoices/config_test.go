@@ -23,7 +23,9 @@ package tworandomchoices
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/peer"
 	"go.uber.org/yarpc/yarpcconfig"
 	"go.uber.org/yarpc/yarpctest"
 )
@@ -53,3 +55,52 @@ func TestConfig(t *testing.T) {
 	require.NotNil(t, config.Outbounds["their-service"])
 	require.NotNil(t, config.Outbounds["their-service"].Unary)
 }
+
+func TestPowerOfChoicesConfig(t *testing.T) {
+	tests := []struct {
+		desc    string
+		choices interface{}
+		wantErr string
+	}{
+		{desc: "default"},
+		{desc: "choices", choices: 4},
+		{desc: "too few choices", choices: 1, wantErr: "Choices must be at least 2. Got: 1."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			list := attrs{
+				"peers": []string{
+					"1.1.1.1:1111",
+					"2.2.2.2:2222",
+				},
+			}
+			if tt.choices != nil {
+				list["choices"] = tt.choices
+			}
+
+			cfg := yarpcconfig.New()
+			cfg.RegisterPeerList(PowerOfChoicesSpec())
+			cfg.RegisterTransport(yarpctest.FakeTransportSpec())
+			config, err := cfg.LoadConfig("our-service", attrs{
+				"outbounds": attrs{
+					"their-service": attrs{
+						"fake-transport": attrs{
+							"power-of-choices": list,
+						},
+					},
+				},
+			})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			out := config.Outbounds["their-service"].Unary.(*yarpctest.FakeOutbound)
+			pl := out.Chooser().(*peer.BoundChooser).ChooserList().(*List)
+			assert.Equal(t, "power-of-choices", pl.Introspect().Name)
+		})
+	}
+}
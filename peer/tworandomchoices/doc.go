@@ -21,6 +21,11 @@
 // Package tworandomchoices provides a load balancer implementation that picks
 // two peers at random and chooses the one with fewer pending requests.
 //
+// The Choices option generalizes the list to pick more peers at random, which
+// further reduces the pending requests of the busiest peers for very large
+// numbers of peers. The list is then also known as "power of d choices", and
+// PowerOfChoicesSpec configures it.
+//
 // The Power of Two Choices in Randomized Load Balancing:
 // https://www.eecs.harvard.edu/~michaelm/postscripts/tpds2001.pdf
 //
@@ -25,22 +25,32 @@ import (
 	"math/rand"
 	"time"
 
+	"go.uber.org/net/metrics"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/peer/abstractlist"
+	"go.uber.org/yarpc/pkg/clock"
 	"go.uber.org/zap"
 )
 
 type listOptions struct {
-	capacity int
-	source   rand.Source
-	failFast bool
-	logger   *zap.Logger
+	capacity     int
+	source       rand.Source
+	failFast     bool
+	drainTimeout time.Duration
+	clock        clock.Clock
+	logger       *zap.Logger
+	meter        *metrics.Scope
+	pendingTopK  int
+	choices      int
+	name         string
 }
 
 var defaultListOptions = listOptions{
 	capacity: 10,
+	choices:  defaultChoices,
+	name:     "two-random-choices",
 }
 
 // ListOption customizes the behavior of a fewest pending of two random peers
@@ -88,6 +98,28 @@ func FailFast() ListOption {
 	})
 }
 
+// DrainTimeout specifies how long the list waits for the requests in flight
+// to a removed peer to finish before releasing the peer to the transport.
+// Removed peers are no longer chosen, and are released once their last
+// pending request finishes or the timeout elapses.
+//
+// Defaults to 0, releasing removed peers immediately.
+func DrainTimeout(timeout time.Duration) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.drainTimeout = timeout
+	})
+}
+
+// Clock specifies the clock that times out draining peers, for tests that
+// control time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.clock = c
+	})
+}
+
 // Logger specifies a logger.
 func Logger(logger *zap.Logger) ListOption {
 	return listOptionFunc(func(options *listOptions) {
@@ -95,6 +127,49 @@ func Logger(logger *zap.Logger) ListOption {
 	})
 }
 
+// Meter specifies the scope for peer list metrics, like the numbers of
+// available and unavailable peers and the latency of choosing a peer.
+//
+// Lists sharing a meter should each use a distinctly tagged scope.
+func Meter(meter *metrics.Scope) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.meter = meter
+	})
+}
+
+// PendingRequestsTopK specifies the number of busiest peers whose pending
+// request counts are reported, by rank, in addition to the total.
+//
+// Defaults to 0, reporting only the total.
+func PendingRequestsTopK(k int) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.pendingTopK = k
+	})
+}
+
+// Choices specifies the number of random peers from which the peer with the
+// fewest pending requests is chosen. With very large numbers of peers, more
+// choices reduce the pending requests of the busiest peers, at the cost of
+// drawing more random numbers for every request.
+//
+// If the list has fewer peers, all peers are considered. Values less than 2
+// are treated as 2.
+//
+// Defaults to 2.
+func Choices(n int) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.choices = n
+	})
+}
+
+// listName specifies the name of the list in metrics and errors, to match
+// the name of the configuration spec that built it.
+func listName(name string) ListOption {
+	return listOptionFunc(func(options *listOptions) {
+		options.name = name
+	})
+}
+
 // New creates a new fewest pending requests of two random peers peer list.
 func New(transport peer.Transport, opts ...ListOption) *List {
 	options := defaultListOptions
@@ -117,12 +192,21 @@ func New(transport peer.Transport, opts ...ListOption) *List {
 	if options.failFast {
 		plOpts = append(plOpts, abstractlist.FailFast())
 	}
+	if options.drainTimeout > 0 {
+		plOpts = append(plOpts, abstractlist.DrainTimeout(options.drainTimeout))
+	}
+	if options.clock != nil {
+		plOpts = append(plOpts, abstractlist.Clock(options.clock))
+	}
+	if options.meter != nil {
+		plOpts = append(plOpts, abstractlist.Meter(options.meter), abstractlist.PendingRequestsTopK(options.pendingTopK))
+	}
 
 	return &List{
 		list: abstractlist.New(
-			"two-random-choices",
+			options.name,
 			transport,
-			newTwoRandomChoicesList(options.capacity, options.source),
+			newTwoRandomChoicesList(options.capacity, options.source, options.choices),
 			plOpts...,
 		),
 	}
@@ -195,7 +279,18 @@ func (l *List) NotifyStatusChanged(pid peer.Identifier) {
 // Introspect reveals information about the list to the internal YARPC
 // introspection system.
 func (l *List) Introspect() introspection.ChooserStatus {
-	return l.list.Introspect()
+	status := l.list.Introspect()
+	status.Config = l.list.IntrospectConfig()
+	return status
+}
+
+// Subscribe registers a function to call with the connection status of each
+// peer that is added to the list, removed from the list, or changes status.
+//
+// The function is called from a separate goroutine, and updates that arrive
+// while it is busy are coalesced, so a slow subscriber never blocks the list.
+func (l *List) Subscribe(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func()) {
+	return l.list.Subscribe(fn)
 }
 
 // Peers produces a slice of all retained peers.
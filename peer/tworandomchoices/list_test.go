@@ -23,6 +23,7 @@ package tworandomchoices
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"net"
 	"sort"
 	"strings"
@@ -40,6 +41,7 @@ import (
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/testtime"
 	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/peer/abstractlist"
 	"go.uber.org/yarpc/transport/http"
 	"go.uber.org/yarpc/yarpcconfig"
 	"go.uber.org/yarpc/yarpcerrors"
@@ -657,3 +659,101 @@ func TestFailFastConfig(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "peer list has 1 peer but it is not responsive")
 }
+
+type simulatedPeer struct {
+	peer.StatusPeer
+
+	index int
+}
+
+// simulateMaxPending sends requests to a list with the given number of
+// choices, from which peers with skewed latencies complete requests, and
+// returns the greatest number of pending requests of any peer.
+func simulateMaxPending(choices int, seed int64) int {
+	const (
+		numPeers = 200
+		arrivals = 85
+		ticks    = 2000
+	)
+
+	random := rand.New(rand.NewSource(seed))
+	list := newTwoRandomChoicesList(numPeers, rand.NewSource(seed), choices)
+	subs := make([]abstractlist.Subscriber, numPeers)
+	// A tenth of the peers are slow, completing a request on a tenth of the
+	// ticks, while the others complete a request on half of the ticks.
+	completion := make([]float64, numPeers)
+	for i := range subs {
+		subs[i] = list.Add(&simulatedPeer{index: i}, nil)
+		completion[i] = 0.5
+		if i%10 == 0 {
+			completion[i] = 0.1
+		}
+	}
+
+	pending := make([]int, numPeers)
+	maxPending := 0
+	for tick := 0; tick < ticks; tick++ {
+		for i := 0; i < arrivals; i++ {
+			p := list.Choose(nil).(*simulatedPeer)
+			pending[p.index]++
+			subs[p.index].UpdatePendingRequestCount(pending[p.index])
+			if pending[p.index] > maxPending {
+				maxPending = pending[p.index]
+			}
+		}
+		for i := range pending {
+			if pending[i] > 0 && random.Float64() < completion[i] {
+				pending[i]--
+				subs[i].UpdatePendingRequestCount(pending[i])
+			}
+		}
+	}
+	return maxPending
+}
+
+func TestChoicesReduceMaxPending(t *testing.T) {
+	for seed := int64(0); seed < 5; seed++ {
+		two := simulateMaxPending(2, seed)
+		for choices := 3; choices <= 5; choices++ {
+			got := simulateMaxPending(choices, seed)
+			assert.True(t, got < two,
+				"seed %d: expected %d choices to reduce max pending requests below %d, got %d", seed, choices, two, got)
+		}
+	}
+}
+
+func TestChoicesConsidersAllPeers(t *testing.T) {
+	list := newTwoRandomChoicesList(3, rand.NewSource(0), 5)
+	subs := make([]abstractlist.Subscriber, 3)
+	for i := range subs {
+		subs[i] = list.Add(&simulatedPeer{index: i}, nil)
+		subs[i].UpdatePendingRequestCount(1)
+	}
+	subs[1].UpdatePendingRequestCount(0)
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, 1, list.Choose(nil).(*simulatedPeer).index)
+	}
+
+	// Removing a peer after the order of the subscribers has changed removes
+	// the right peer.
+	list.Remove(nil, nil, subs[1])
+	for i := 0; i < 10; i++ {
+		assert.NotEqual(t, 1, list.Choose(nil).(*simulatedPeer).index)
+	}
+}
+
+func TestChoicesAtLeastTwo(t *testing.T) {
+	assert.Equal(t, 2, newTwoRandomChoicesList(1, rand.NewSource(0), 0).choices)
+}
+
+func TestChooseDoesNotAllocate(t *testing.T) {
+	for _, choices := range []int{2, 5} {
+		list := newTwoRandomChoicesList(100, rand.NewSource(0), choices)
+		for i := 0; i < 100; i++ {
+			list.Add(&simulatedPeer{index: i}, nil)
+		}
+		allocs := testing.AllocsPerRun(100, func() { list.Choose(nil) })
+		assert.Zero(t, allocs, "%d choices", choices)
+	}
+}
@@ -31,9 +31,12 @@ import (
 	"go.uber.org/yarpc/peer/abstractlist"
 )
 
+const defaultChoices = 2
+
 type twoRandomChoicesList struct {
 	subscribers []*subscriber
 	random      *rand.Rand
+	choices     int
 
 	m sync.RWMutex
 }
@@ -51,13 +54,17 @@ type options struct{}
 // Use this constructor instead of NewList, when wanting to do custom peer
 // connection management.
 func NewImplementation(opts ...Option) abstractlist.Implementation {
-	return newTwoRandomChoicesList(10, rand.NewSource(time.Now().UnixNano()))
+	return newTwoRandomChoicesList(10, rand.NewSource(time.Now().UnixNano()), defaultChoices)
 }
 
-func newTwoRandomChoicesList(cap int, source rand.Source) *twoRandomChoicesList {
+func newTwoRandomChoicesList(cap int, source rand.Source, choices int) *twoRandomChoicesList {
+	if choices < defaultChoices {
+		choices = defaultChoices
+	}
 	return &twoRandomChoicesList{
 		subscribers: make([]*subscriber, 0, cap),
 		random:      rand.New(source),
+		choices:     choices,
 	}
 }
 
@@ -101,15 +108,38 @@ func (l *twoRandomChoicesList) Choose(_ *transport.Request) peer.StatusPeer {
 	if numSubs == 1 {
 		return l.subscribers[0].peer
 	}
-	i := l.random.Intn(numSubs)
-	j := i + 1 + l.random.Intn(numSubs-1)
-	if j >= numSubs {
-		j -= numSubs
+	if l.choices == defaultChoices {
+		i := l.random.Intn(numSubs)
+		j := i + 1 + l.random.Intn(numSubs-1)
+		if j >= numSubs {
+			j -= numSubs
+		}
+		if l.subscribers[i].pending.Load() > l.subscribers[j].pending.Load() {
+			i = j
+		}
+		return l.subscribers[i].peer
+	}
+
+	// Draw distinct peers with a partial Fisher-Yates shuffle of the
+	// subscribers, which only moves the drawn peers to the front.
+	choices := l.choices
+	if choices > numSubs {
+		choices = numSubs
 	}
-	if l.subscribers[i].pending.Load() > l.subscribers[j].pending.Load() {
-		i = j
+	best := 0
+	for k := 0; k < choices; k++ {
+		l.swap(k, k+l.random.Intn(numSubs-k))
+		if l.subscribers[k].pending.Load() < l.subscribers[best].pending.Load() {
+			best = k
+		}
 	}
-	return l.subscribers[i].peer
+	return l.subscribers[best].peer
+}
+
+func (l *twoRandomChoicesList) swap(i, j int) {
+	l.subscribers[i], l.subscribers[j] = l.subscribers[j], l.subscribers[i]
+	l.subscribers[i].index = i
+	l.subscribers[j].index = j
 }
 
 type subscriber struct {
@@ -0,0 +1,53 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package peer
+
+import "go.uber.org/yarpc/api/peer"
+
+// IdentifyWeight decorates a peer identifier with a relative weight.
+//
+// Weighted peer lists, like weightedroundrobin, choose peers in proportion
+// to their weight. Other peer lists disregard the weight.
+//
+// 	peer.IdentifyWeight(hostport.Identify("127.0.0.1:8080"), 3)
+func IdentifyWeight(id peer.Identifier, weight int) peer.WeightedIdentifier {
+	if l, ok := id.(peer.LimitedIdentifier); ok {
+		return weightedLimitedIdentifier{
+			id:         id,
+			weight:     weight,
+			maxPending: l.MaxPendingRequests(),
+		}
+	}
+	return weightedIdentifier{id: id, weight: weight}
+}
+
+type weightedIdentifier struct {
+	id     peer.Identifier
+	weight int
+}
+
+func (w weightedIdentifier) Identifier() string {
+	return w.id.Identifier()
+}
+
+func (w weightedIdentifier) Weight() int {
+	return w.weight
+}
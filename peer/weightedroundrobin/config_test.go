@@ -0,0 +1,125 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package weightedroundrobin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func TestWeightedRoundRobinConfig(t *testing.T) {
+	minus1, zero, twenty := -1, 0, 20
+	tests := []struct {
+		name    string
+		cfg     Configuration
+		wantErr bool
+	}{
+		{
+			name: "no configuration",
+		},
+		{
+			name: "negative capacity",
+			cfg: Configuration{
+				Capacity: &minus1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero capacity",
+			cfg: Configuration{
+				Capacity: &zero,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid capacity",
+			cfg: Configuration{
+				Capacity: &twenty,
+			},
+		},
+		{
+			name: "valid weights",
+			cfg: Configuration{
+				Weights: map[string]int{"foo-host:port": 3},
+			},
+		},
+		{
+			name: "zero weight",
+			cfg: Configuration{
+				Weights: map[string]int{"foo-host:port": 0},
+			},
+			wantErr: true,
+		},
+	}
+
+	s := Spec()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			build := s.BuildPeerList.(func(Configuration, peer.Transport, *yarpcconfig.Kit) (peer.ChooserList, error))
+			pl, err := build(tt.cfg, yarpctest.NewFakeTransport(), nil)
+
+			if tt.wantErr {
+				require.Error(t, err, "must not construct a peer list")
+
+			} else {
+				require.NoError(t, err)
+				pl.Update(peer.ListUpdates{Additions: []peer.Identifier{hostport.PeerIdentifier("foo-host:port")}})
+			}
+		})
+	}
+}
+
+func TestWeightsFromYAML(t *testing.T) {
+	config := whitespace.Expand(`
+		outbounds:
+			weighted:
+				fake-transport:
+					weighted-round-robin:
+						peers:
+							- 127.0.0.1:8080
+							- 127.0.0.1:8081
+						weights:
+							127.0.0.1:8080: 3
+	`)
+	cfgr := yarpctest.NewFakeConfigurator()
+	cfgr.MustRegisterPeerList(Spec())
+	cfg, err := cfgr.LoadConfigFromYAML("test", strings.NewReader(config))
+	require.NoError(t, err)
+
+	out := cfg.Outbounds["weighted"].Unary.(*yarpctest.FakeOutbound)
+
+	d := yarpc.NewDispatcher(cfg)
+	require.NoError(t, d.Start())
+	defer d.Stop()
+
+	assertRatios(t, map[string]int{
+		"127.0.0.1:8080": 3,
+		"127.0.0.1:8081": 1,
+	}, chooseN(t, out.Chooser(), 1000))
+}
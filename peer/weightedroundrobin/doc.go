@@ -0,0 +1,37 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package weightedroundrobin provides a peer list that rotates through its
+// available peers, choosing each peer in proportion to its relative weight.
+//
+// The list uses the smooth weighted round-robin algorithm, which interleaves
+// choices so that heavily weighted peers do not receive bursts of
+// consecutive requests. For weights {a: 5, b: 1, c: 1}, the list chooses
+// a, a, b, a, c, a, a, and repeats.
+//
+// Weights come from peer identifiers that implement
+// peer.WeightedIdentifier, as produced by peer.IdentifyWeight, or from a
+// static map of weights by peer identifier. Peers without a weight have a
+// weight of 1.
+//
+// To change the weight of a peer at runtime, send an update that both
+// removes and adds the peer with its new weight. The list adjusts the weight
+// in place without releasing or reconnecting the peer.
+package weightedroundrobin
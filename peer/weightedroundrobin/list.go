@@ -0,0 +1,329 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package weightedroundrobin
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/peer/abstractlist"
+	"go.uber.org/yarpc/pkg/clock"
+	"go.uber.org/zap"
+)
+
+type listConfig struct {
+	capacity             int
+	shuffle              bool
+	failFast             bool
+	drainTimeout         time.Duration
+	defaultChooseTimeout *time.Duration
+	seed                 int64
+	weights              map[string]int
+	logger               *zap.Logger
+	meter                *metrics.Scope
+	pendingTopK          int
+	clock                clock.Clock
+}
+
+var defaultListConfig = listConfig{
+	capacity: 10,
+	shuffle:  true,
+	seed:     time.Now().UnixNano(),
+}
+
+// ListOption customizes the behavior of a weighted round-robin list.
+type ListOption func(*listConfig)
+
+// Capacity specifies the default capacity of the underlying
+// data structures for this list.
+//
+// Defaults to 10.
+func Capacity(capacity int) ListOption {
+	return func(c *listConfig) {
+		c.capacity = capacity
+	}
+}
+
+// FailFast indicates that the peer list should not wait for a peer to become
+// available when choosing a peer.
+//
+// This option is preferrable when the better failure mode is to retry from the
+// origin, since another proxy instance might already have a connection.
+func FailFast() ListOption {
+	return func(c *listConfig) {
+		c.failFast = true
+	}
+}
+
+// DrainTimeout specifies how long the list waits for the requests in flight
+// to a removed peer to finish before releasing the peer to the transport.
+// Removed peers are no longer chosen, and are released once their last
+// pending request finishes or the timeout elapses.
+//
+// Defaults to 0, releasing removed peers immediately.
+func DrainTimeout(timeout time.Duration) ListOption {
+	return func(c *listConfig) {
+		c.drainTimeout = timeout
+	}
+}
+
+// Seed specifies the random seed to use for shuffling peers.
+//
+// Defaults to approximately the process start time in nanoseconds.
+func Seed(seed int64) ListOption {
+	return func(c *listConfig) {
+		c.seed = seed
+	}
+}
+
+// Clock specifies the clock that times out draining peers, for tests that
+// control time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) ListOption {
+	return func(cfg *listConfig) {
+		cfg.clock = c
+	}
+}
+
+// Logger specifies a logger.
+func Logger(logger *zap.Logger) ListOption {
+	return func(c *listConfig) {
+		c.logger = logger
+	}
+}
+
+// Meter specifies the scope for peer list metrics, like the numbers of
+// available and unavailable peers and the latency of choosing a peer.
+//
+// Lists sharing a meter should each use a distinctly tagged scope.
+func Meter(meter *metrics.Scope) ListOption {
+	return func(c *listConfig) {
+		c.meter = meter
+	}
+}
+
+// PendingRequestsTopK specifies the number of busiest peers whose pending
+// request counts are reported, by rank, in addition to the total.
+//
+// Defaults to 0, reporting only the total.
+func PendingRequestsTopK(k int) ListOption {
+	return func(c *listConfig) {
+		c.pendingTopK = k
+	}
+}
+
+// DefaultChooseTimeout specifies the default timeout to add to 'Choose' calls
+// without context deadlines. This prevents long-lived streams from setting
+// calling deadlines.
+//
+// Defaults to 500ms.
+func DefaultChooseTimeout(timeout time.Duration) ListOption {
+	return func(c *listConfig) {
+		c.defaultChooseTimeout = &timeout
+	}
+}
+
+// Weights specifies the weights of peers by identifier.
+//
+// Weights carried by peer identifiers (see peer.IdentifyWeight) take
+// precedence over these weights. Peers that have no weight from either source
+// have a weight of 1.
+func Weights(weights map[string]int) ListOption {
+	return func(c *listConfig) {
+		c.weights = weights
+	}
+}
+
+// New creates a new weighted round robin peer list.
+func New(transport peer.Transport, opts ...ListOption) *List {
+	cfg := defaultListConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	plOpts := []abstractlist.Option{
+		abstractlist.Capacity(cfg.capacity),
+		abstractlist.Seed(cfg.seed),
+	}
+	if cfg.logger != nil {
+		plOpts = append(plOpts, abstractlist.Logger(cfg.logger))
+	}
+	if !cfg.shuffle {
+		plOpts = append(plOpts, abstractlist.NoShuffle())
+	}
+	if cfg.failFast {
+		plOpts = append(plOpts, abstractlist.FailFast())
+	}
+	if cfg.drainTimeout > 0 {
+		plOpts = append(plOpts, abstractlist.DrainTimeout(cfg.drainTimeout))
+	}
+	if cfg.clock != nil {
+		plOpts = append(plOpts, abstractlist.Clock(cfg.clock))
+	}
+	if cfg.defaultChooseTimeout != nil {
+		plOpts = append(plOpts, abstractlist.DefaultChooseTimeout(*cfg.defaultChooseTimeout))
+	}
+	if cfg.meter != nil {
+		plOpts = append(plOpts, abstractlist.Meter(cfg.meter), abstractlist.PendingRequestsTopK(cfg.pendingTopK))
+	}
+
+	ring := newWeightedRing(StaticWeights(cfg.weights))
+	return &List{
+		ring: ring,
+		list: abstractlist.New(
+			"weighted-round-robin",
+			transport,
+			ring,
+			plOpts...,
+		),
+	}
+}
+
+var _ peer.List = (*List)(nil)
+var _ peer.Chooser = (*List)(nil)
+var _ introspection.IntrospectableChooser = (*List)(nil)
+var _ peer.StatusWatcher = (*List)(nil)
+
+// List is a PeerList which rotates which peers are to be selected in
+// proportion to their weights.
+type List struct {
+	ring *weightedRing
+	list *abstractlist.List
+}
+
+// Start causes the peer list to start.
+//
+// Starting will retain all peers that have been added but not removed
+// the first time it is called.
+//
+// Start may be called any number of times and in any order in relation to Stop
+// but will only cause the list to start the first time, and only if it has not
+// already been stopped.
+func (l *List) Start() error {
+	return l.list.Start()
+}
+
+// Stop causes the peer list to stop.
+//
+// Stopping will release all retained peers to the underlying transport.
+//
+// Stop may be called any number of times and in order in relation to Start but
+// will only cause the list to stop the first time, and only if it has
+// previously been started.
+func (l *List) Stop() error {
+	return l.list.Stop()
+}
+
+// IsRunning returns whether the list has started and not yet stopped.
+func (l *List) IsRunning() bool {
+	return l.list.IsRunning()
+}
+
+// Choose returns a peer, suitable for sending a request.
+//
+// The peer is not guaranteed to be connected and available, but the peer list
+// makes every attempt to ensure this and minimize the probability that a
+// chosen peer will fail to carry a request.
+func (l *List) Choose(ctx context.Context, req *transport.Request) (peer peer.Peer, onFinish func(error), err error) {
+	return l.list.Choose(ctx, req)
+}
+
+// Update may add and remove logical peers in the list.
+//
+// The peer list uses a transport to obtain a physical peer for each logical
+// peer.
+// The transport is responsible for informing the peer list whether the peer is
+// available or unavailable, but cannot guarantee that the peer will still be
+// available after it is chosen.
+//
+// An update that removes and adds a peer that is already in the list changes
+// the weight of that peer in place, without releasing it.
+func (l *List) Update(updates peer.ListUpdates) error {
+	removals := make(map[string]struct{}, len(updates.Removals))
+	for _, id := range updates.Removals {
+		removals[id.Identifier()] = struct{}{}
+	}
+
+	forward := peer.ListUpdates{
+		Additions: make([]peer.Identifier, 0, len(updates.Additions)),
+		Removals:  make([]peer.Identifier, 0, len(updates.Removals)),
+	}
+	reweighted := make(map[string]struct{})
+	for _, id := range updates.Additions {
+		addr := id.Identifier()
+		if _, ok := removals[addr]; ok && l.list.Contains(id) {
+			reweighted[addr] = struct{}{}
+			l.ring.setWeight(id)
+			continue
+		}
+		forward.Additions = append(forward.Additions, id)
+	}
+	for _, id := range updates.Removals {
+		if _, ok := reweighted[id.Identifier()]; ok {
+			continue
+		}
+		l.ring.forgetWeight(id)
+		forward.Removals = append(forward.Removals, id)
+	}
+	for _, id := range forward.Additions {
+		l.ring.setWeight(id)
+	}
+
+	return l.list.Update(forward)
+}
+
+// NotifyStatusChanged forwards a status change notification to an individual
+// peer in the list.
+//
+// This satisfies the peer.Subscriber interface and should only be used to
+// send notifications in tests.
+// The list's RetainPeer and ReleasePeer methods deal with an individual
+// peer.Subscriber instance for each peer in the list, avoiding a map lookup.
+func (l *List) NotifyStatusChanged(pid peer.Identifier) {
+	l.list.NotifyStatusChanged(pid)
+}
+
+// Introspect reveals information about the list to the internal YARPC
+// introspection system.
+func (l *List) Introspect() introspection.ChooserStatus {
+	status := l.list.Introspect()
+	status.Config = l.list.IntrospectConfig()
+	return status
+}
+
+// Subscribe registers a function to call with the connection status of each
+// peer that is added to the list, removed from the list, or changes status.
+//
+// The function is called from a separate goroutine, and updates that arrive
+// while it is busy are coalesced, so a slow subscriber never blocks the list.
+func (l *List) Subscribe(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func()) {
+	return l.list.Subscribe(fn)
+}
+
+// Peers produces a slice of all retained peers.
+func (l *List) Peers() []peer.StatusPeer {
+	return l.list.Peers()
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package weightedroundrobin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	yarpcpeer "go.uber.org/yarpc/peer"
+	"go.uber.org/yarpc/peer/abstractpeer"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+var noShuffle ListOption = func(c *listConfig) {
+	c.shuffle = false
+}
+
+// chooseN chooses n peers and counts the choices by peer identifier.
+func chooseN(t *testing.T, pl peer.Chooser, n int) map[string]int {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		onFinish(nil)
+		counts[p.Identifier()]++
+	}
+	return counts
+}
+
+func assertRatios(t *testing.T, want map[string]int, counts map[string]int) {
+	var totalWeight, totalCount int
+	for _, w := range want {
+		totalWeight += w
+	}
+	for _, c := range counts {
+		totalCount += c
+	}
+	for id, w := range want {
+		wantRatio := float64(w) / float64(totalWeight)
+		gotRatio := float64(counts[id]) / float64(totalCount)
+		assert.InDelta(t, wantRatio, gotRatio, 0.01, "unexpected selection ratio for peer %q", id)
+	}
+}
+
+func TestSmoothSelectionOrder(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	pl := New(trans, noShuffle)
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{
+			yarpcpeer.IdentifyWeight(abstractpeer.Identify("a"), 5),
+			yarpcpeer.IdentifyWeight(abstractpeer.Identify("b"), 1),
+			yarpcpeer.IdentifyWeight(abstractpeer.Identify("c"), 1),
+		},
+	}))
+	require.NoError(t, pl.Start())
+	defer pl.Stop()
+	trans.Flush()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	var got []string
+	for i := 0; i < 14; i++ {
+		p, onFinish, err := pl.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		onFinish(nil)
+		got = append(got, p.Identifier())
+	}
+	assert.Equal(t, []string{
+		"a", "a", "b", "a", "c", "a", "a",
+		"a", "a", "b", "a", "c", "a", "a",
+	}, got, "heavy peers must be interleaved with light peers")
+}
+
+func TestSelectionRatios(t *testing.T) {
+	tests := []struct {
+		msg     string
+		opts    []ListOption
+		ids     []peer.Identifier
+		weights map[string]int
+	}{
+		{
+			msg: "weights from identifiers",
+			ids: []peer.Identifier{
+				yarpcpeer.IdentifyWeight(abstractpeer.Identify("small"), 1),
+				yarpcpeer.IdentifyWeight(abstractpeer.Identify("medium"), 3),
+				yarpcpeer.IdentifyWeight(abstractpeer.Identify("large"), 6),
+			},
+			weights: map[string]int{"small": 1, "medium": 3, "large": 6},
+		},
+		{
+			msg: "static weights",
+			opts: []ListOption{
+				Weights(map[string]int{"small": 2, "large": 8}),
+			},
+			ids: []peer.Identifier{
+				abstractpeer.Identify("small"),
+				abstractpeer.Identify("large"),
+				abstractpeer.Identify("default"),
+			},
+			weights: map[string]int{"small": 2, "large": 8, "default": 1},
+		},
+		{
+			msg: "identifier weights take precedence over static weights",
+			opts: []ListOption{
+				Weights(map[string]int{"small": 7}),
+			},
+			ids: []peer.Identifier{
+				yarpcpeer.IdentifyWeight(abstractpeer.Identify("small"), 1),
+				yarpcpeer.IdentifyWeight(abstractpeer.Identify("large"), 4),
+			},
+			weights: map[string]int{"small": 1, "large": 4},
+		},
+		{
+			msg: "non-positive weights are treated as 1",
+			ids: []peer.Identifier{
+				yarpcpeer.IdentifyWeight(abstractpeer.Identify("zero"), 0),
+				yarpcpeer.IdentifyWeight(abstractpeer.Identify("negative"), -3),
+				yarpcpeer.IdentifyWeight(abstractpeer.Identify("two"), 2),
+			},
+			weights: map[string]int{"zero": 1, "negative": 1, "two": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			trans := yarpctest.NewFakeTransport()
+			pl := New(trans, tt.opts...)
+			require.NoError(t, pl.Start())
+			defer pl.Stop()
+
+			require.NoError(t, pl.Update(peer.ListUpdates{Additions: tt.ids}))
+			trans.Flush()
+
+			assertRatios(t, tt.weights, chooseN(t, pl, 10000))
+		})
+	}
+}
+
+func TestUpdateWeights(t *testing.T) {
+	trans := yarpctest.NewFakeTransport(
+		yarpctest.ReleaseErrors(errors.New("peer must not be released"), []string{"a"}),
+	)
+	pl := New(trans)
+	require.NoError(t, pl.Start())
+	defer pl.Stop()
+
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{
+			yarpcpeer.IdentifyWeight(abstractpeer.Identify("a"), 1),
+			yarpcpeer.IdentifyWeight(abstractpeer.Identify("b"), 1),
+		},
+	}))
+	trans.Flush()
+	assertRatios(t, map[string]int{"a": 1, "b": 1}, chooseN(t, pl, 1000))
+
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Removals: []peer.Identifier{
+			yarpcpeer.IdentifyWeight(abstractpeer.Identify("a"), 1),
+		},
+		Additions: []peer.Identifier{
+			yarpcpeer.IdentifyWeight(abstractpeer.Identify("a"), 4),
+		},
+	}), "changing a weight must not release the peer")
+	assertRatios(t, map[string]int{"a": 4, "b": 1}, chooseN(t, pl, 1000))
+
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Removals:  []peer.Identifier{abstractpeer.Identify("a")},
+		Additions: []peer.Identifier{abstractpeer.Identify("a")},
+	}), "dropping a weight must not release the peer")
+	assertRatios(t, map[string]int{"a": 1, "b": 1}, chooseN(t, pl, 1000))
+}
+
+func TestWeightsSurviveRestart(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	pl := New(trans)
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{
+			yarpcpeer.IdentifyWeight(abstractpeer.Identify("a"), 1),
+			yarpcpeer.IdentifyWeight(abstractpeer.Identify("b"), 1),
+		},
+	}))
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Removals:  []peer.Identifier{abstractpeer.Identify("b")},
+		Additions: []peer.Identifier{yarpcpeer.IdentifyWeight(abstractpeer.Identify("b"), 3)},
+	}), "changing a weight must succeed before start")
+	require.NoError(t, pl.Start())
+	trans.Flush()
+	assertRatios(t, map[string]int{"a": 1, "b": 3}, chooseN(t, pl, 1000))
+}
+
+func TestRemovalForgetsWeight(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	pl := New(trans)
+	require.NoError(t, pl.Start())
+	defer pl.Stop()
+
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{
+			yarpcpeer.IdentifyWeight(abstractpeer.Identify("a"), 9),
+			abstractpeer.Identify("b"),
+		},
+	}))
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Removals: []peer.Identifier{abstractpeer.Identify("a")},
+	}))
+	require.NoError(t, pl.Update(peer.ListUpdates{
+		Additions: []peer.Identifier{abstractpeer.Identify("a")},
+	}))
+	trans.Flush()
+	assertRatios(t, map[string]int{"a": 1, "b": 1}, chooseN(t, pl, 1000))
+}
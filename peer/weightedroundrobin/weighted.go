@@ -0,0 +1,205 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package weightedroundrobin
+
+import (
+	"sync"
+
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/peer/abstractlist"
+)
+
+const defaultWeight = 1
+
+// Option configures the peer list implementation constructor.
+type Option interface {
+	apply(*options)
+}
+
+type options struct {
+	weights map[string]int
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(options *options) { f(options) }
+
+// StaticWeights specifies the weights of peers by identifier, for peers whose
+// identifiers do not carry their own weight.
+func StaticWeights(weights map[string]int) Option {
+	return optionFunc(func(options *options) {
+		options.weights = weights
+	})
+}
+
+// NewImplementation creates a new weighted round-robin
+// abstractlist.Implementation.
+//
+// Use this constructor instead of New, when wanting to do custom peer
+// connection management.
+func NewImplementation(opts ...Option) abstractlist.Implementation {
+	return newWeightedRing(opts...)
+}
+
+func newWeightedRing(opts ...Option) *weightedRing {
+	var o options
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return &weightedRing{
+		static:  o.weights,
+		dynamic: make(map[string]int),
+		byAddr:  make(map[string]*subscriber),
+	}
+}
+
+type subscriber struct {
+	index   int
+	peer    peer.StatusPeer
+	weight  int
+	current int
+}
+
+func (s *subscriber) UpdatePendingRequestCount(int) {}
+
+// weightedRing chooses among available peers with the smooth weighted
+// round-robin algorithm.
+//
+// Weights carried by peer identifiers in list updates are recorded in the
+// dynamic weights so that they survive the peer list restarting, and take
+// precedence over the static weights.
+type weightedRing struct {
+	subscribers []*subscriber
+	byAddr      map[string]*subscriber
+	static      map[string]int
+	dynamic     map[string]int
+	total       int
+
+	m sync.Mutex
+}
+
+var _ abstractlist.Implementation = (*weightedRing)(nil)
+
+func (r *weightedRing) Add(p peer.StatusPeer, id peer.Identifier) abstractlist.Subscriber {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	sub := &subscriber{
+		index:  len(r.subscribers),
+		peer:   p,
+		weight: r.weightOf(id),
+	}
+	r.subscribers = append(r.subscribers, sub)
+	r.byAddr[id.Identifier()] = sub
+	r.total += sub.weight
+	return sub
+}
+
+func (r *weightedRing) Remove(_ peer.StatusPeer, id peer.Identifier, s abstractlist.Subscriber) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	sub, ok := s.(*subscriber)
+	if !ok || len(r.subscribers) == 0 {
+		return
+	}
+	index := sub.index
+	last := len(r.subscribers) - 1
+	r.subscribers[index] = r.subscribers[last]
+	r.subscribers[index].index = index
+	r.subscribers = r.subscribers[:last]
+	delete(r.byAddr, id.Identifier())
+	r.total -= sub.weight
+}
+
+// Choose increases the current weight of every peer by its weight, chooses
+// the peer with the greatest current weight, and decreases the current weight
+// of the chosen peer by the total of all weights.
+func (r *weightedRing) Choose(_ *transport.Request) peer.StatusPeer {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var best *subscriber
+	for _, sub := range r.subscribers {
+		sub.current += sub.weight
+		if best == nil || sub.current > best.current {
+			best = sub
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	best.current -= r.total
+	return best.peer
+}
+
+// setWeight records the weight carried by the identifier, if any, and applies
+// the resulting weight to the peer if it is available.
+func (r *weightedRing) setWeight(id peer.Identifier) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	addr := id.Identifier()
+	if w, ok := id.(peer.WeightedIdentifier); ok {
+		r.dynamic[addr] = normalizeWeight(w.Weight())
+	} else {
+		delete(r.dynamic, addr)
+	}
+
+	if sub, ok := r.byAddr[addr]; ok {
+		weight := r.weightOf(id)
+		r.total += weight - sub.weight
+		sub.weight = weight
+	}
+}
+
+// forgetWeight discards the weight recorded for a removed peer.
+func (r *weightedRing) forgetWeight(id peer.Identifier) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	delete(r.dynamic, id.Identifier())
+}
+
+// weightOf must be called under the ring lock.
+func (r *weightedRing) weightOf(id peer.Identifier) int {
+	addr := id.Identifier()
+	if w, ok := r.dynamic[addr]; ok {
+		return w
+	}
+	if w, ok := id.(peer.WeightedIdentifier); ok {
+		return normalizeWeight(w.Weight())
+	}
+	if w, ok := r.static[addr]; ok {
+		return normalizeWeight(w)
+	}
+	return defaultWeight
+}
+
+// normalizeWeight treats non-positive weights as the default weight, so that
+// every peer in the list remains eligible for traffic.
+func normalizeWeight(weight int) int {
+	if weight < 1 {
+		return defaultWeight
+	}
+	return weight
+}
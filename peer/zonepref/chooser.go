@@ -0,0 +1,233 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zonepref
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/pkg/lifecycle"
+)
+
+// ZoneEnvVar is the environment variable that holds the local zone if New
+// receives none.
+const ZoneEnvVar = "YARPC_ZONE"
+
+type options struct {
+	local peer.ChooserList
+	zones map[string]string
+}
+
+// Option customizes the behavior of a zone preferring peer chooser.
+type Option func(*options)
+
+// LocalList specifies the peer list that chooses among the peers in the
+// local zone.
+//
+// Without a local list, the chooser always falls back.
+func LocalList(list peer.ChooserList) Option {
+	return func(o *options) {
+		o.local = list
+	}
+}
+
+// WithPeerZone labels the peer with the given identifier as belonging to a
+// zone.
+//
+// Peers without a label belong to no zone, so are never local.
+func WithPeerZone(id string, zone string) Option {
+	return func(o *options) {
+		if o.zones == nil {
+			o.zones = make(map[string]string)
+		}
+		o.zones[id] = zone
+	}
+}
+
+// New creates a peer chooser that chooses peers in the local zone from the
+// local peer list, and falls through to the fallback chooser if none of them
+// is available.
+//
+// If localZone is empty, the local zone is read from the YARPC_ZONE
+// environment variable.
+// The chooser starts and stops the local list and the fallback chooser with
+// its own lifecycle.
+func New(localZone string, fallback peer.Chooser, opts ...Option) *Chooser {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if localZone == "" {
+		localZone = os.Getenv(ZoneEnvVar)
+	}
+
+	return &Chooser{
+		once:      lifecycle.NewOnce(),
+		localZone: localZone,
+		local:     options.local,
+		fallback:  fallback,
+		zones:     options.zones,
+		peers:     make(map[string]peer.Identifier),
+	}
+}
+
+var _ peer.ChooserList = (*Chooser)(nil)
+var _ introspection.IntrospectableChooser = (*Chooser)(nil)
+
+// Chooser is a peer chooser that prefers available peers in the local zone.
+type Chooser struct {
+	once      *lifecycle.Once
+	localZone string
+	local     peer.ChooserList
+	fallback  peer.Chooser
+	zones     map[string]string
+
+	lock  sync.Mutex
+	peers map[string]peer.Identifier
+}
+
+// LocalZone returns the zone whose peers the chooser prefers.
+func (c *Chooser) LocalZone() string {
+	return c.localZone
+}
+
+// Choose returns a peer from the local list if it has an available peer, or
+// from the fallback chooser otherwise.
+func (c *Chooser) Choose(ctx context.Context, req *transport.Request) (peer.Peer, func(error), error) {
+	if c.local != nil && hasAvailablePeer(c.local) {
+		return c.local.Choose(ctx, req)
+	}
+	return c.fallback.Choose(ctx, req)
+}
+
+// Update forwards all peers to the fallback chooser, if it is a peer list,
+// and the peers in the local zone to the local list.
+func (c *Chooser) Update(updates peer.ListUpdates) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var local peer.ListUpdates
+	for _, id := range updates.Removals {
+		addr := id.Identifier()
+		if lid, ok := c.peers[addr]; ok {
+			delete(c.peers, addr)
+			local.Removals = append(local.Removals, lid)
+		}
+	}
+	for _, id := range updates.Additions {
+		if c.isLocal(id) {
+			c.peers[id.Identifier()] = id
+			local.Additions = append(local.Additions, id)
+		}
+	}
+
+	var errs error
+	if list, ok := c.fallback.(peer.List); ok {
+		errs = multierr.Append(errs, list.Update(updates))
+	}
+	if c.local != nil && (len(local.Additions) > 0 || len(local.Removals) > 0) {
+		errs = multierr.Append(errs, c.local.Update(local))
+	}
+	return errs
+}
+
+func (c *Chooser) isLocal(id peer.Identifier) bool {
+	zone, ok := c.zones[id.Identifier()]
+	return ok && c.localZone != "" && zone == c.localZone
+}
+
+// hasAvailablePeer returns whether the chooser has an available peer,
+// assuming it does if the chooser does not reveal the status of its peers.
+func hasAvailablePeer(chooser peer.Chooser) bool {
+	list, ok := chooser.(interface{ Peers() []peer.StatusPeer })
+	if !ok {
+		return true
+	}
+	for _, p := range list.Peers() {
+		if p.Status().ConnectionStatus == peer.Available {
+			return true
+		}
+	}
+	return false
+}
+
+// Start starts the local list and the fallback chooser.
+func (c *Chooser) Start() error {
+	return c.once.Start(c.start)
+}
+
+func (c *Chooser) start() error {
+	if c.local != nil {
+		if err := c.local.Start(); err != nil {
+			return err
+		}
+	}
+	if err := c.fallback.Start(); err != nil {
+		if c.local != nil {
+			err = multierr.Append(err, c.local.Stop())
+		}
+		return err
+	}
+	return nil
+}
+
+// Stop stops the local list and the fallback chooser.
+func (c *Chooser) Stop() error {
+	return c.once.Stop(c.stop)
+}
+
+func (c *Chooser) stop() error {
+	var errs error
+	if c.local != nil {
+		errs = multierr.Append(errs, c.local.Stop())
+	}
+	return multierr.Append(errs, c.fallback.Stop())
+}
+
+// IsRunning returns whether the chooser is running.
+func (c *Chooser) IsRunning() bool {
+	return c.once.IsRunning()
+}
+
+// Introspect reveals the peers of the fallback chooser, which include the
+// local peers if it is bound to the same peer list updater, and the status
+// of the local and fallback choosers.
+func (c *Chooser) Introspect() introspection.ChooserStatus {
+	status := introspection.ChooserStatus{
+		Name:   "zone-preferring",
+		State:  c.once.State().String(),
+		Config: map[string]interface{}{"localZone": c.localZone},
+	}
+	if ic, ok := c.local.(introspection.IntrospectableChooser); ok {
+		status.Choosers = append(status.Choosers, ic.Introspect())
+	}
+	if ic, ok := c.fallback.(introspection.IntrospectableChooser); ok {
+		fallback := ic.Introspect()
+		status.Peers = fallback.Peers
+		status.Choosers = append(status.Choosers, fallback)
+	}
+	return status
+}
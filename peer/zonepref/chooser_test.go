@@ -0,0 +1,141 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package zonepref
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+func identifyAll(ids ...string) []peer.Identifier {
+	pids := make([]peer.Identifier, len(ids))
+	for i, id := range ids {
+		pids[i] = hostport.Identify(id)
+	}
+	return pids
+}
+
+// choose makes n requests and returns the number of requests sent to each
+// peer.
+func choose(t *testing.T, c *Chooser, n int) map[string]int {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		p, onFinish, err := c.Choose(ctx, &transport.Request{})
+		require.NoError(t, err)
+		counts[p.Identifier()]++
+		onFinish(nil)
+	}
+	return counts
+}
+
+func newChooser(trans peer.Transport, localZone string) *Chooser {
+	return New(localZone, roundrobin.New(trans),
+		LocalList(roundrobin.New(trans)),
+		WithPeerZone("local-1", "zone-a"),
+		WithPeerZone("local-2", "zone-a"),
+		WithPeerZone("remote-1", "zone-b"),
+	)
+}
+
+func TestPreferLocalZone(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	c := newChooser(trans, "zone-a")
+	require.NoError(t, c.Start())
+	defer c.Stop()
+	assert.True(t, c.IsRunning())
+
+	require.NoError(t, c.Update(peer.ListUpdates{Additions: identifyAll("local-1", "local-2", "remote-1", "unlabeled")}))
+	assert.Equal(t, map[string]int{"local-1": 5, "local-2": 5}, choose(t, c, 10),
+		"expected requests to go to peers in the local zone")
+
+	require.NoError(t, c.Update(peer.ListUpdates{Removals: identifyAll("local-1")}))
+	assert.Equal(t, map[string]int{"local-2": 10}, choose(t, c, 10),
+		"expected requests to go to the remaining local peer")
+
+	assert.Len(t, c.Introspect().Peers, 3)
+}
+
+func TestFallbackWhenLocalZoneUnavailable(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	c := newChooser(trans, "zone-a")
+	require.NoError(t, c.Start())
+	defer c.Stop()
+
+	require.NoError(t, c.Update(peer.ListUpdates{Additions: identifyAll("local-1", "local-2", "remote-1")}))
+
+	trans.SimulateDisconnect(hostport.Identify("local-1"))
+	trans.SimulateDisconnect(hostport.Identify("local-2"))
+	assert.Equal(t, map[string]int{"remote-1": 10}, choose(t, c, 10),
+		"expected requests to fall back without available local peers")
+
+	trans.SimulateConnect(hostport.Identify("local-2"))
+	assert.Equal(t, map[string]int{"local-2": 10}, choose(t, c, 10),
+		"expected requests to return to the recovered local peer")
+}
+
+func TestNoLocalPeers(t *testing.T) {
+	trans := yarpctest.NewFakeTransport()
+	c := newChooser(trans, "zone-c")
+	require.NoError(t, c.Start())
+	defer c.Stop()
+
+	require.NoError(t, c.Update(peer.ListUpdates{Additions: identifyAll("local-1", "remote-1")}))
+	assert.Equal(t, map[string]int{"local-1": 5, "remote-1": 5}, choose(t, c, 10),
+		"expected requests to fall back to all peers outside their zones")
+}
+
+func TestLocalZoneFromEnvironment(t *testing.T) {
+	old, ok := os.LookupEnv(ZoneEnvVar)
+	defer func() {
+		if ok {
+			os.Setenv(ZoneEnvVar, old)
+		} else {
+			os.Unsetenv(ZoneEnvVar)
+		}
+	}()
+
+	require.NoError(t, os.Setenv(ZoneEnvVar, "zone-b"))
+	trans := yarpctest.NewFakeTransport()
+	c := newChooser(trans, "")
+	assert.Equal(t, "zone-b", c.LocalZone())
+
+	require.NoError(t, c.Start())
+	defer c.Stop()
+	require.NoError(t, c.Update(peer.ListUpdates{Additions: identifyAll("local-1", "remote-1")}))
+	assert.Equal(t, map[string]int{"remote-1": 10}, choose(t, c, 10),
+		"expected requests to go to the zone from the environment")
+
+	assert.Equal(t, "zone-a", newChooser(trans, "zone-a").LocalZone(),
+		"expected an explicit zone to take precedence")
+}
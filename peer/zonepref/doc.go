@@ -0,0 +1,41 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package zonepref provides a peer chooser that prefers peers in the same
+// availability zone as the caller, to reduce the cost and latency of
+// crossing zones.
+//
+// The chooser is also a peer list: bind it to a peer list updater and it
+// forwards every peer to the fallback chooser, if that is a peer list, and
+// the peers in the local zone to the local peer list.
+// Requests go to the local peer list while it has an available peer, and to
+// the fallback chooser otherwise.
+//
+//  local := roundrobin.New(transport)
+//  all := roundrobin.New(transport)
+//  chooser := zonepref.New("", all,
+//    zonepref.LocalList(local),
+//    zonepref.WithPeerZone("10.0.0.1:4040", "us-east-1a"),
+//    zonepref.WithPeerZone("10.0.1.1:4040", "us-east-1b"),
+//  )
+//
+// The local zone defaults to the YARPC_ZONE environment variable, which
+// sidecars may inject.
+package zonepref
@@ -0,0 +1,141 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpc_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/transport/grpc"
+)
+
+const bidiMessages = 1000
+
+// echoBidiStream sends back every message it receives until the client
+// finishes sending.
+func echoBidiStream(stream transport.BidiStream) error {
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func newBidiServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	inbound := grpc.NewTransport().NewInbound(listener)
+	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+		Name:     "chat",
+		Inbounds: yarpc.Inbounds{inbound},
+	})
+	dispatcher.Register([]transport.Procedure{
+		yarpc.BidiStreamProcedure("echo", transport.BidiStreamHandlerFunc(echoBidiStream)),
+	})
+	require.NoError(t, dispatcher.Start())
+	t.Cleanup(func() { assert.NoError(t, dispatcher.Stop()) })
+	return inbound.Addr().String()
+}
+
+// exchangeBidiMessages sends messages from one goroutine while receiving
+// their echoes on another.
+func exchangeBidiMessages(t *testing.T, out transport.BidiStreamOutbound) {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	stream, err := out.CallBidiStream(ctx, &transport.StreamRequest{
+		Meta: &transport.RequestMeta{
+			Caller:    "chat-client",
+			Service:   "chat",
+			Procedure: "echo",
+			Encoding:  raw.Encoding,
+		},
+	})
+	require.NoError(t, err)
+
+	sendErr := make(chan error, 1)
+	go func() {
+		for i := 0; i < bidiMessages; i++ {
+			msg := &transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewBufferString(fmt.Sprint(i)))}
+			if err := stream.Send(msg); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- stream.CloseSend()
+	}()
+
+	for i := 0; i < bidiMessages; i++ {
+		msg, err := stream.Recv()
+		require.NoError(t, err, "failed to receive message %d", i)
+		body, err := ioutil.ReadAll(msg.Body)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprint(i), string(body))
+	}
+	require.NoError(t, <-sendErr)
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestBidiStream(t *testing.T) {
+	t.Run("outbound", func(t *testing.T) {
+		addr := newBidiServer(t)
+		out := grpc.NewTransport().NewSingleOutbound(addr)
+		require.NoError(t, out.Transports()[0].Start())
+		defer out.Transports()[0].Stop()
+		require.NoError(t, out.Start())
+		defer out.Stop()
+
+		exchangeBidiMessages(t, out)
+	})
+
+	t.Run("dispatcher", func(t *testing.T) {
+		addr := newBidiServer(t)
+		trans := grpc.NewTransport()
+		dispatcher := yarpc.NewDispatcher(yarpc.Config{
+			Name: "chat-client",
+			Outbounds: yarpc.Outbounds{
+				"chat": {Stream: trans.NewSingleOutbound(addr)},
+			},
+		})
+		require.NoError(t, dispatcher.Start())
+		defer dispatcher.Stop()
+
+		exchangeBidiMessages(t, dispatcher.MustOutboundConfig("chat").GetBidiStreamOutbound())
+	})
+}
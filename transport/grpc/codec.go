@@ -20,9 +20,16 @@
 
 package grpc
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
 
 // customCodec pass bytes to/from the wire without modification.
+//
+// Protobuf messages, of the gRPC services registered with an inbound, are
+// marshaled as Protobuf.
 type customCodec struct{}
 
 // Marshal takes a []byte and passes it through as a []byte.
@@ -30,6 +37,8 @@ func (customCodec) Marshal(obj interface{}) ([]byte, error) {
 	switch value := obj.(type) {
 	case []byte:
 		return value, nil
+	case proto.Message:
+		return proto.Marshal(value)
 	default:
 		return nil, newCustomCodecMarshalCastError(obj)
 	}
@@ -41,6 +50,8 @@ func (customCodec) Unmarshal(data []byte, obj interface{}) error {
 	case *[]byte:
 		*value = data
 		return nil
+	case proto.Message:
+		return proto.Unmarshal(data, value)
 	default:
 		return newCustomCodecUnmarshalCastError(obj)
 	}
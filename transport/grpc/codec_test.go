@@ -24,6 +24,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func TestCustomCodecMarshalBytes(t *testing.T) {
@@ -33,6 +35,15 @@ func TestCustomCodecMarshalBytes(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestCustomCodecProtobuf(t *testing.T) {
+	data, err := customCodec{}.Marshal(&healthpb.HealthCheckRequest{Service: "test"})
+	require.NoError(t, err)
+
+	var value healthpb.HealthCheckRequest
+	require.NoError(t, customCodec{}.Unmarshal(data, &value))
+	assert.Equal(t, "test", value.Service)
+}
+
 func TestCustomCodecMarshalCastError(t *testing.T) {
 	value := "test"
 	data, err := customCodec{}.Marshal(&value)
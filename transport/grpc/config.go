@@ -23,6 +23,7 @@ package grpc
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"time"
 
@@ -31,6 +32,7 @@ import (
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
 	peerchooser "go.uber.org/yarpc/peer"
 	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/transport/internal/tls/keypair"
 	"go.uber.org/yarpc/yarpcconfig"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
@@ -70,19 +72,23 @@ func TransportSpec(opts ...Option) yarpcconfig.TransportSpec {
 //          max: 30s
 //      clientMaxHeaderListSize: 1024
 //      serverMaxHeaderListSize: 2048
+//      serverMaxRecvMsgSize: 4MiB
+//
+// Message and header list sizes are in bytes, and accept units like "4MiB"
+// or "512kb".
 //
 // All parameters of TransportConfig are optional. This section
 // may be omitted in the transports section.
 type TransportConfig struct {
-	ServerMaxRecvMsgSize int `config:"serverMaxRecvMsgSize"`
-	ServerMaxSendMsgSize int `config:"serverMaxSendMsgSize"`
-	ClientMaxRecvMsgSize int `config:"clientMaxRecvMsgSize"`
-	ClientMaxSendMsgSize int `config:"clientMaxSendMsgSize"`
+	ServerMaxRecvMsgSize yarpcconfig.ByteSize `config:"serverMaxRecvMsgSize"`
+	ServerMaxSendMsgSize yarpcconfig.ByteSize `config:"serverMaxSendMsgSize"`
+	ClientMaxRecvMsgSize yarpcconfig.ByteSize `config:"clientMaxRecvMsgSize"`
+	ClientMaxSendMsgSize yarpcconfig.ByteSize `config:"clientMaxSendMsgSize"`
 	// GRPC header lise size options accept uint32 param.
 	// see: https://pkg.go.dev/google.golang.org/grpc#WithMaxHeaderListSize
-	ServerMaxHeaderListSize uint32              `config:"serverMaxHeaderListSize"`
-	ClientMaxHeaderListSize uint32              `config:"clientMaxHeaderListSize"`
-	Backoff                 yarpcconfig.Backoff `config:"backoff"`
+	ServerMaxHeaderListSize yarpcconfig.ByteSize `config:"serverMaxHeaderListSize"`
+	ClientMaxHeaderListSize yarpcconfig.ByteSize `config:"clientMaxHeaderListSize"`
+	Backoff                 yarpcconfig.Backoff  `config:"backoff"`
 }
 
 // InboundConfig configures a gRPC Inbound.
@@ -100,6 +106,17 @@ type TransportConfig struct {
 //       enabled: true
 //       keyFile: "/path/to/key"
 //       certFile: "/path/to/cert"
+//
+// The key and cert may also be given as secrets, which are the PEM-encoded
+// key and cert themselves, or references to them.
+//
+// inbounds:
+//   grpc:
+//     address: ":443"
+//     tls:
+//       enabled: true
+//       key: env://TLS_KEY
+//       cert: file:///etc/tls/cert.pem
 type InboundConfig struct {
 	// Address to listen on. This field is required.
 	Address string           `config:"address,interpolate"`
@@ -115,16 +132,29 @@ type InboundTLSConfig struct {
 	Enabled  bool   `config:"enabled"` // disabled by default
 	CertFile string `config:"certFile,interpolate"`
 	KeyFile  string `config:"keyFile,interpolate"`
+	// Cert and Key are the PEM-encoded cert and key, in place of the cert
+	// and key files.
+	Cert yarpcconfig.Secret `config:"cert"`
+	Key  yarpcconfig.Secret `config:"key"`
 
 	// Mode when set to Permissive or Enforced enables TLS inbound and
-	// TLS configuration must be passed as an inbound option.
-	// Note: enable, cert and key fields are ignored when mode is set.
+	// TLS configuration must be passed as an inbound option, or as the
+	// cert and key secrets.
+	// Note: enable, certFile and keyFile fields are ignored when mode is set.
 	Mode yarpctls.Mode `config:"mode,interpolate"`
 }
 
 func (c InboundTLSConfig) inboundOptions() ([]InboundOption, error) {
 	if c.Mode != yarpctls.Disabled {
-		return []InboundOption{InboundTLSMode(c.Mode)}, nil
+		opts := []InboundOption{InboundTLSMode(c.Mode)}
+		tlsConfig, err := keypair.ServerConfig(c.Cert, c.Key)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig != nil {
+			opts = append(opts, InboundTLSConfiguration(tlsConfig))
+		}
+		return opts, nil
 	}
 
 	if !c.Enabled {
@@ -138,6 +168,13 @@ func (c InboundTLSConfig) inboundOptions() ([]InboundOption, error) {
 }
 
 func (c InboundTLSConfig) newInboundCredentials() (credentials.TransportCredentials, error) {
+	if !c.Cert.IsZero() || !c.Key.IsZero() {
+		tlsConfig, err := keypair.ServerConfig(c.Cert, c.Key)
+		if err != nil {
+			return nil, err
+		}
+		return credentials.NewTLS(tlsConfig), nil
+	}
 	if c.CertFile != "" && c.KeyFile != "" {
 		return credentials.NewServerTLSFromFile(c.CertFile, c.KeyFile)
 	}
@@ -246,10 +283,10 @@ func (c OutboundTLSConfig) dialOptions(tlsConfigProvider yarpctls.OutboundTLSCon
 
 // OutboundKeepaliveConfig configures gRPC keepalive for a gRPC outbound.
 type OutboundKeepaliveConfig struct {
-	Enabled             bool   `config:"enabled"`
-	Time                string `config:"time"`
-	Timeout             string `config:"timeout"`
-	PermitWithoutStream bool   `config:"permit-without-stream"`
+	Enabled             bool          `config:"enabled"`
+	Time                time.Duration `config:"time"`
+	Timeout             time.Duration `config:"timeout"`
+	PermitWithoutStream bool          `config:"permit-without-stream"`
 }
 
 func (c OutboundKeepaliveConfig) dialOptions() ([]DialOption, error) {
@@ -257,26 +294,18 @@ func (c OutboundKeepaliveConfig) dialOptions() ([]DialOption, error) {
 		return nil, nil
 	}
 
-	var err error
-
 	// gRPC keepalive expects time to be minimum 10s.
 	// read more: https://pkg.go.dev/google.golang.org/grpc/keepalive#ClientParameters
 	keepaliveTime := time.Second * 10
-	if c.Time != "" {
-		keepaliveTime, err = time.ParseDuration(c.Time)
-		if err != nil {
-			return nil, fmt.Errorf("could not parse gRPC keepalive time: %v", err)
-		}
+	if c.Time > 0 {
+		keepaliveTime = c.Time
 	}
 
 	// gRPC keepalive defaults timeout to 20s.
 	// read more: https://pkg.go.dev/google.golang.org/grpc/keepalive#ClientParameters
 	keepaliveTimeout := time.Second * 20
-	if c.Timeout != "" {
-		keepaliveTimeout, err = time.ParseDuration(c.Timeout)
-		if err != nil {
-			return nil, fmt.Errorf("could not parse gRPC keepalive timeout: %v", err)
-		}
+	if c.Timeout > 0 {
+		keepaliveTimeout = c.Timeout
 	}
 
 	option := KeepaliveParams(keepalive.ClientParameters{
@@ -316,22 +345,25 @@ func newTransportSpec(opts ...Option) (*transportSpec, error) {
 func (t *transportSpec) buildTransport(transportConfig *TransportConfig, kit *yarpcconfig.Kit) (transport.Transport, error) {
 	options := t.TransportOptions
 	if transportConfig.ServerMaxRecvMsgSize > 0 {
-		options = append(options, ServerMaxRecvMsgSize(transportConfig.ServerMaxRecvMsgSize))
+		options = append(options, ServerMaxRecvMsgSize(int(transportConfig.ServerMaxRecvMsgSize)))
 	}
 	if transportConfig.ServerMaxSendMsgSize > 0 {
-		options = append(options, ServerMaxSendMsgSize(transportConfig.ServerMaxSendMsgSize))
+		options = append(options, ServerMaxSendMsgSize(int(transportConfig.ServerMaxSendMsgSize)))
 	}
 	if transportConfig.ClientMaxRecvMsgSize > 0 {
-		options = append(options, ClientMaxRecvMsgSize(transportConfig.ClientMaxRecvMsgSize))
+		options = append(options, ClientMaxRecvMsgSize(int(transportConfig.ClientMaxRecvMsgSize)))
 	}
 	if transportConfig.ClientMaxSendMsgSize > 0 {
-		options = append(options, ClientMaxSendMsgSize(transportConfig.ClientMaxSendMsgSize))
+		options = append(options, ClientMaxSendMsgSize(int(transportConfig.ClientMaxSendMsgSize)))
+	}
+	if transportConfig.ServerMaxHeaderListSize > math.MaxUint32 || transportConfig.ClientMaxHeaderListSize > math.MaxUint32 {
+		return nil, fmt.Errorf("header list sizes must not exceed %d bytes", uint32(math.MaxUint32))
 	}
 	if transportConfig.ServerMaxHeaderListSize > 0 {
-		options = append(options, ServerMaxHeaderListSize(transportConfig.ServerMaxHeaderListSize))
+		options = append(options, ServerMaxHeaderListSize(uint32(transportConfig.ServerMaxHeaderListSize)))
 	}
 	if transportConfig.ClientMaxHeaderListSize > 0 {
-		options = append(options, ClientMaxHeaderListSize(transportConfig.ClientMaxHeaderListSize))
+		options = append(options, ClientMaxHeaderListSize(uint32(transportConfig.ClientMaxHeaderListSize)))
 	}
 	backoffStrategy, err := transportConfig.Backoff.Strategy()
 	if err != nil {
@@ -349,14 +381,14 @@ func (t *transportSpec) buildInbound(inboundConfig *InboundConfig, tr transport.
 	if inboundConfig.Address == "" {
 		return nil, newRequiredFieldMissingError("address")
 	}
-	listener, err := net.Listen("tcp", inboundConfig.Address)
-	if err != nil {
-		return nil, err
-	}
 	inboundOptions, err := inboundConfig.inboundOptions()
 	if err != nil {
 		return nil, fmt.Errorf("cannot build gRPC inbound from given configuration: %v", err)
 	}
+	listener, err := net.Listen("tcp", inboundConfig.Address)
+	if err != nil {
+		return nil, err
+	}
 	return trans.NewInbound(listener, append(t.InboundOptions, inboundOptions...)...), nil
 }
 
@@ -287,6 +287,36 @@ func TestTransportSpec(t *testing.T) {
 			},
 			wantErrors: []string{`both certFile and keyFile`},
 		},
+		{
+			desc: "TLS enabled on an inbound with secrets",
+			inboundCfg: attrs{
+				"address": "localhost:54570",
+				"tls": attrs{
+					"enabled": true,
+					"cert":    "file://testdata/cert",
+					"key":     "file://testdata/key",
+				},
+			},
+			wantInbound: &wantInbound{
+				Address: "127.0.0.1:54570",
+				TLS:     true,
+			},
+		},
+		{
+			desc: "TLS enabled on an inbound with a missing secret",
+			inboundCfg: attrs{
+				"address": "localhost:54714",
+				"tls": attrs{
+					"enabled": true,
+					"cert":    "file://testdata/cert",
+					"key":     "env://GRPC_CONFIG_TEST_UNSET_KEY",
+				},
+			},
+			wantErrors: []string{
+				`invalid secret at inbounds.grpc.tls.key`,
+				`environment variable "GRPC_CONFIG_TEST_UNSET_KEY" is not set`,
+			},
+		},
 		{
 			desc: "TLS enabled on an outbound",
 			outboundCfg: attrs{
@@ -407,7 +437,8 @@ func TestTransportSpec(t *testing.T) {
 				},
 			},
 			wantErrors: []string{
-				`could not parse gRPC keepalive time: time: unknown unit`,
+				`invalid duration at outbounds.myservice.grpc.grpc-keepalive.time: time: unknown unit "foo"`,
+				`invalid duration at outbounds.myservice.grpc.grpc-keepalive.timeout: time: missing unit`,
 			},
 		},
 		{
@@ -425,7 +456,7 @@ func TestTransportSpec(t *testing.T) {
 				},
 			},
 			wantErrors: []string{
-				`could not parse gRPC keepalive timeout: time: unknown unit`,
+				`invalid duration at outbounds.myservice.grpc.grpc-keepalive.timeout: time: unknown unit "foo"`,
 			},
 		},
 		{
@@ -436,8 +467,8 @@ func TestTransportSpec(t *testing.T) {
 						"address": "localhost:54816",
 						"grpc-keepalive": attrs{
 							"enabled": "false",
-							"time":    "10",
-							"timeout": "10",
+							"time":    "10s",
+							"timeout": "10s",
 						},
 					},
 				},
@@ -0,0 +1,154 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpchealth
+
+import (
+	"context"
+	"sync"
+)
+
+// Status is the serving status of a service.
+type Status int
+
+const (
+	// Unknown is the status of a service whose health is not known yet.
+	Unknown Status = iota
+	// Serving is the status of a service that is healthy.
+	Serving
+	// NotServing is the status of a service that is unhealthy.
+	NotServing
+	// ServiceUnknown is the status of a service the checker does not know.
+	ServiceUnknown
+)
+
+func (s Status) String() string {
+	switch s {
+	case Unknown:
+		return "unknown"
+	case Serving:
+		return "serving"
+	case NotServing:
+		return "not-serving"
+	case ServiceUnknown:
+		return "service-unknown"
+	default:
+		return "invalid"
+	}
+}
+
+// HealthChecker reports the serving status of services. The empty service
+// name stands for the health of the server as a whole.
+type HealthChecker interface {
+	// Check returns the current status of a service.
+	Check(ctx context.Context, service string) (Status, error)
+
+	// Subscribe returns a channel that receives the status of a service,
+	// first its current status and then every change. The channel may skip
+	// intermediate statuses of a slow receiver, but always delivers the
+	// latest one. Call unsubscribe to release the subscription.
+	Subscribe(service string) (updates <-chan Status, unsubscribe func())
+}
+
+// StatusChecker is a HealthChecker whose statuses are set by the service
+// itself. The server as a whole is Serving until its status is set.
+type StatusChecker struct {
+	lock        sync.Mutex
+	statuses    map[string]Status
+	subscribers map[string]map[chan Status]struct{}
+}
+
+var _ HealthChecker = (*StatusChecker)(nil)
+
+// NewStatusChecker returns a StatusChecker that reports the server as
+// Serving, and no other services.
+func NewStatusChecker() *StatusChecker {
+	return &StatusChecker{
+		statuses:    map[string]Status{"": Serving},
+		subscribers: make(map[string]map[chan Status]struct{}),
+	}
+}
+
+// SetStatus sets the status of a service, notifying its subscribers if it
+// changed.
+func (c *StatusChecker) SetStatus(service string, status Status) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if old, ok := c.statuses[service]; ok && old == status {
+		return
+	}
+	c.statuses[service] = status
+	for ch := range c.subscribers[service] {
+		publish(ch, status)
+	}
+}
+
+// Check implements HealthChecker.
+func (c *StatusChecker) Check(_ context.Context, service string) (Status, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.status(service), nil
+}
+
+// Subscribe implements HealthChecker.
+func (c *StatusChecker) Subscribe(service string) (<-chan Status, func()) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ch := make(chan Status, 1)
+	ch <- c.status(service)
+	subs, ok := c.subscribers[service]
+	if !ok {
+		subs = make(map[chan Status]struct{})
+		c.subscribers[service] = subs
+	}
+	subs[ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			delete(subs, ch)
+			if len(subs) == 0 {
+				delete(c.subscribers, service)
+			}
+		})
+	}
+}
+
+// status must be called under the checker lock.
+func (c *StatusChecker) status(service string) Status {
+	if status, ok := c.statuses[service]; ok {
+		return status
+	}
+	return ServiceUnknown
+}
+
+// publish replaces the pending status of a subscriber, if any, so that
+// publishing never blocks on a slow receiver.
+func publish(ch chan Status, status Status) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- status
+}
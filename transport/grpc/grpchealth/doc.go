@@ -0,0 +1,35 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package grpchealth serves the gRPC health checking protocol,
+// grpc.health.v1.Health, from YARPC gRPC inbounds, for load balancers and
+// Kubernetes gRPC probes.
+//
+// 	checker := grpchealth.NewStatusChecker()
+// 	inbound := grpcTransport.NewInbound(listener, grpchealth.InboundOption(checker))
+//
+// 	// Later, while draining:
+// 	checker.SetStatus("", grpchealth.NotServing)
+//
+// The Check RPC reports the current status of a service from the
+// HealthChecker, failing with NotFound for services it does not know. The
+// Watch RPC streams the status of a service, and then every change of it,
+// from a subscription to the HealthChecker.
+package grpchealth
@@ -0,0 +1,106 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpchealth
+
+import (
+	"context"
+
+	"go.uber.org/yarpc/internal/grpcerrorcodes"
+	"go.uber.org/yarpc/transport/grpc"
+	"go.uber.org/yarpc/yarpcerrors"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// InboundOption returns a gRPC InboundOption that serves the
+// grpc.health.v1.Health service of the inbound from the given checker.
+func InboundOption(checker HealthChecker) grpc.InboundOption {
+	return grpc.InboundServices(func(r ggrpc.ServiceRegistrar) {
+		healthpb.RegisterHealthServer(r, NewServer(checker))
+	})
+}
+
+type server struct {
+	healthpb.UnimplementedHealthServer
+
+	checker HealthChecker
+}
+
+// NewServer returns a grpc.health.v1.Health server that reports the statuses
+// of the given checker, for gRPC servers outside YARPC.
+func NewServer(checker HealthChecker) healthpb.HealthServer {
+	return &server{checker: checker}
+}
+
+func (s *server) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, err := s.checker.Check(ctx, req.Service)
+	if err != nil {
+		if yarpcerrors.IsStatus(err) {
+			return nil, status.Error(grpcerrorcodes.YARPCCodeToGRPCCode[yarpcerrors.FromError(err).Code()], err.Error())
+		}
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+	if st == ServiceUnknown {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.Service)
+	}
+	return &healthpb.HealthCheckResponse{Status: servingStatus(st)}, nil
+}
+
+func (s *server) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	updates, unsubscribe := s.checker.Subscribe(req.Service)
+	defer unsubscribe()
+
+	sent := false
+	var last healthpb.HealthCheckResponse_ServingStatus
+	for {
+		select {
+		case st, ok := <-updates:
+			if !ok {
+				return status.Error(codes.Unavailable, "health checker stopped")
+			}
+			ss := servingStatus(st)
+			if sent && ss == last {
+				continue
+			}
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: ss}); err != nil {
+				return status.Error(codes.Canceled, "stream has ended")
+			}
+			sent, last = true, ss
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		}
+	}
+}
+
+func servingStatus(s Status) healthpb.HealthCheckResponse_ServingStatus {
+	switch s {
+	case Serving:
+		return healthpb.HealthCheckResponse_SERVING
+	case NotServing:
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	case ServiceUnknown:
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+	default:
+		return healthpb.HealthCheckResponse_UNKNOWN
+	}
+}
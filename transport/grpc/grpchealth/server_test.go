@@ -0,0 +1,147 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpchealth_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/transport/grpc"
+	"go.uber.org/yarpc/transport/grpc/grpchealth"
+	"go.uber.org/yarpc/yarpcerrors"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func newHealthClient(t *testing.T, checker grpchealth.HealthChecker) healthpb.HealthClient {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	inbound := grpc.NewTransport().NewInbound(listener, grpchealth.InboundOption(checker))
+	inbound.SetRouter(yarpc.NewMapRouter("myservice"))
+	require.NoError(t, inbound.Start())
+	t.Cleanup(func() { assert.NoError(t, inbound.Stop()) })
+
+	conn, err := ggrpc.Dial(listener.Addr().String(), ggrpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, conn.Close()) })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestCheck(t *testing.T) {
+	checker := grpchealth.NewStatusChecker()
+	checker.SetStatus("myservice", grpchealth.NotServing)
+	client := newHealthClient(t, checker)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	res, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+
+	res, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "myservice"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, res.Status)
+
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "other"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+type failingChecker struct {
+	grpchealth.HealthChecker
+}
+
+func (failingChecker) Check(context.Context, string) (grpchealth.Status, error) {
+	return grpchealth.Unknown, yarpcerrors.UnavailableErrorf("database is down")
+}
+
+func TestCheckError(t *testing.T) {
+	client := newHealthClient(t, failingChecker{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestWatch(t *testing.T) {
+	checker := grpchealth.NewStatusChecker()
+	client := newHealthClient(t, checker)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "myservice"})
+	require.NoError(t, err)
+	recv := func() healthpb.HealthCheckResponse_ServingStatus {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		return res.Status
+	}
+
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVICE_UNKNOWN, recv())
+
+	checker.SetStatus("myservice", grpchealth.Serving)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, recv())
+
+	// Statuses that do not change are not sent again.
+	checker.SetStatus("myservice", grpchealth.Serving)
+	checker.SetStatus("other", grpchealth.NotServing)
+	checker.SetStatus("myservice", grpchealth.NotServing)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, recv())
+}
+
+func TestSubscribe(t *testing.T) {
+	checker := grpchealth.NewStatusChecker()
+	updates, unsubscribe := checker.Subscribe("")
+	assert.Equal(t, grpchealth.Serving, <-updates)
+
+	// Slow receivers see the latest status.
+	checker.SetStatus("", grpchealth.NotServing)
+	checker.SetStatus("", grpchealth.Unknown)
+	assert.Equal(t, grpchealth.Unknown, <-updates)
+
+	unsubscribe()
+	unsubscribe()
+	checker.SetStatus("", grpchealth.Serving)
+	select {
+	case st := <-updates:
+		t.Fatalf("unexpected status after unsubscribing: %v", st)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestStatusString(t *testing.T) {
+	assert.Equal(t, "unknown", grpchealth.Unknown.String())
+	assert.Equal(t, "serving", grpchealth.Serving.String())
+	assert.Equal(t, "not-serving", grpchealth.NotServing.String())
+	assert.Equal(t, "service-unknown", grpchealth.ServiceUnknown.String())
+	assert.Equal(t, "invalid", grpchealth.Status(42).String())
+}
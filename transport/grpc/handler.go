@@ -34,7 +34,9 @@ import (
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
@@ -58,6 +60,11 @@ func (h *handler) handle(srv interface{}, serverStream grpc.ServerStream) (err e
 
 	start := time.Now()
 	ctx := serverStream.Context()
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			ctx = transport.WithTLSConnectionState(ctx, &info.State)
+		}
+	}
 	streamMethod, ok := grpc.MethodFromServerStream(serverStream)
 	if !ok {
 		return errInvalidGRPCStream
@@ -21,8 +21,10 @@
 package grpc
 
 import (
+	"context"
 	"errors"
 	"net"
+	"strings"
 	"sync"
 
 	"go.uber.org/yarpc/api/transport"
@@ -40,6 +42,8 @@ var (
 
 	_ introspection.IntrospectableInbound = (*Inbound)(nil)
 	_ transport.Inbound                   = (*Inbound)(nil)
+	_ transport.GracefulStopper           = (*Inbound)(nil)
+	_ transport.Namer                     = (*Inbound)(nil)
 )
 
 // Inbound is a grpc transport.Inbound.
@@ -73,6 +77,18 @@ func (i *Inbound) Stop() error {
 	return i.once.Stop(i.stop)
 }
 
+// GracefulStop implements transport.GracefulStopper#GracefulStop. It waits
+// for pending calls until the context is done, and then closes the
+// connections that remain.
+func (i *Inbound) GracefulStop(ctx context.Context) error {
+	return i.once.Stop(func() error { return i.gracefulStop(ctx) })
+}
+
+// TransportName implements transport.Namer#TransportName.
+func (i *Inbound) TransportName() string {
+	return TransportName
+}
+
 // IsRunning implements transport.Lifecycle#IsRunning.
 func (i *Inbound) IsRunning() bool {
 	return i.once.IsRunning()
@@ -147,6 +163,9 @@ func (i *Inbound) start() error {
 	}
 
 	server := grpc.NewServer(serverOptions...)
+	for _, register := range i.options.services {
+		register(server)
+	}
 
 	go func() {
 		i.t.options.logger.Info("started GRPC inbound", zap.Stringer("address", i.listener.Addr()))
@@ -179,6 +198,30 @@ func (i *Inbound) stop() error {
 	return nil
 }
 
+func (i *Inbound) gracefulStop(ctx context.Context) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.server == nil {
+		return nil
+	}
+	server := i.server
+	i.server = nil
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		server.Stop()
+		<-stopped
+		return ctx.Err()
+	}
+}
+
 // Introspect returns the current state of the inbound.
 func (i *Inbound) Introspect() introspection.InboundStatus {
 	state := "Stopped"
@@ -189,9 +232,16 @@ func (i *Inbound) Introspect() introspection.InboundStatus {
 	if addr := i.Addr(); addr != nil {
 		addrString = addr.String()
 	}
+	config := map[string]interface{}{
+		"tls": map[string]interface{}{"mode": strings.ToLower(i.options.tlsMode.String())},
+	}
+	if i.listener != nil {
+		config["address"] = i.listener.Addr().String()
+	}
 	return introspection.InboundStatus{
 		Transport: TransportName,
 		Endpoint:  addrString,
 		State:     state,
+		Config:    config,
 	}
 }
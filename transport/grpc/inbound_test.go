@@ -21,11 +21,19 @@
 package grpc
 
 import (
+	"bytes"
+	"context"
 	"net"
 	"testing"
+	"time"
 
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	yarpctls "go.uber.org/yarpc/api/transport/tls"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/internal/testtime"
 )
 
 func TestInboundMechanics(t *testing.T) {
@@ -65,3 +73,66 @@ func TestInboundIntrospection(t *testing.T) {
 	assert.Equal(t, "Stopped", inbound.Introspect().State, "expected 'Stopped' state")
 	assert.Empty(t, inbound.Introspect().Endpoint, "unexpected endpoint")
 }
+
+func TestInboundIntrospectionConfig(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	inbound := NewTransport().NewInbound(listener, InboundTLSMode(yarpctls.Permissive))
+
+	assert.Equal(t, map[string]interface{}{
+		"address": listener.Addr().String(),
+		"tls":     map[string]interface{}{"mode": "permissive"},
+	}, inbound.Introspect().Config)
+}
+
+func TestInboundGracefulStop(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	handler := transporttest.NewMockUnaryHandler(mockCtrl)
+	handler.EXPECT().Handle(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, *transport.Request, transport.ResponseWriter) error {
+			close(entered)
+			<-release
+			return nil
+		})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	trans := NewTransport()
+	inbound := trans.NewInbound(listener)
+	inbound.SetRouter(newTestRouter([]transport.Procedure{
+		{Name: "slow", HandlerSpec: transport.NewUnaryHandlerSpec(handler)},
+	}))
+	outbound := trans.NewSingleOutbound(listener.Addr().String())
+	require.NoError(t, trans.Start())
+	defer trans.Stop()
+	require.NoError(t, inbound.Start())
+	require.NoError(t, outbound.Start())
+	defer outbound.Stop()
+
+	callErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+		defer cancel()
+		_, err := outbound.Call(ctx, &transport.Request{
+			Caller:    "caller",
+			Service:   "service",
+			Procedure: "slow",
+			Encoding:  "raw",
+			Body:      bytes.NewReader(nil),
+		})
+		callErr <- err
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, inbound.GracefulStop(ctx))
+	assert.False(t, inbound.IsRunning())
+	assert.Error(t, <-callErr, "calls still pending after the context is done must fail")
+}
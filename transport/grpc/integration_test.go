@@ -45,6 +45,7 @@ import (
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
 	"go.uber.org/yarpc/encoding/protobuf"
 	"go.uber.org/yarpc/internal/clientconfig"
+	"go.uber.org/yarpc/internal/dnstest"
 	"go.uber.org/yarpc/internal/grpcctx"
 	"go.uber.org/yarpc/internal/prototest/example"
 	"go.uber.org/yarpc/internal/prototest/examplepb"
@@ -59,6 +60,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/status"
 )
 
@@ -450,7 +452,7 @@ func TestTLSWithYARPCAndGRPC(t *testing.T) {
 // Validates compression is applied for the outbound with compression enabled
 // and rest of the outbounds are still uncompressed.
 func TestCompressionWithMultipleOutbounds(t *testing.T) {
-	env, err := newTestEnv(t, nil, nil, nil, nil)
+	env, err := newTestEnv(t, nil, nil, nil, nil, "")
 	require.NoError(t, err)
 	defer func() { assert.NoError(t, env.Close()) }()
 
@@ -636,6 +638,46 @@ func TestOutboundTLS(t *testing.T) {
 	}
 }
 
+func TestCustomResolver(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	scenario := testscenario.Create(t, time.Minute, time.Minute)
+
+	tests := []struct {
+		desc           string
+		inboundOptions []InboundOption
+		dialOptions    []DialOption
+	}{
+		{desc: "plaintext"},
+		{
+			desc:           "tls",
+			inboundOptions: []InboundOption{InboundTLSConfiguration(scenario.ServerTLSConfig()), InboundTLSMode(yarpctls.Enforced)},
+			dialOptions: []DialOption{
+				DialerTLSConfig(scenario.ClientTLSConfig()),
+				KeepaliveParams(keepalive.ClientParameters{Time: time.Minute}),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			te := testEnvOptions{
+				TransportOptions: []TransportOption{
+					WithCustomResolver(dnstest.NewResolver(map[string]string{"example.com": "127.0.0.1"})),
+				},
+				InboundOptions: tt.inboundOptions,
+				DialOptions:    tt.dialOptions,
+				OutboundHost:   "example.com",
+			}
+			te.do(t, func(t *testing.T, e *testEnv) {
+				err := e.SetValueYARPC(context.Background(), "foo", "bar")
+				assert.NoError(t, err)
+
+				err = e.SetValueGRPC(context.Background(), "foo", "bar")
+				assert.NoError(t, err)
+			})
+		})
+	}
+}
+
 type metricCollection struct {
 	metrics []metric
 }
@@ -794,6 +836,9 @@ type testEnvOptions struct {
 	InboundOptions   []InboundOption
 	OutboundOptions  []OutboundOption
 	DialOptions      []DialOption
+	// OutboundHost replaces the IP address of the inbound in the address
+	// that the clients dial, if set.
+	OutboundHost string
 }
 
 func (te *testEnvOptions) do(t *testing.T, f func(*testing.T, *testEnv)) {
@@ -803,6 +848,7 @@ func (te *testEnvOptions) do(t *testing.T, f func(*testing.T, *testEnv)) {
 		te.InboundOptions,
 		te.OutboundOptions,
 		te.DialOptions,
+		te.OutboundHost,
 	)
 	require.NoError(t, err)
 	defer func() {
@@ -817,6 +863,7 @@ func newTestEnv(
 	inboundOptions []InboundOption,
 	outboundOptions []OutboundOption,
 	dialOptions []DialOption,
+	outboundHost string,
 ) (_ *testEnv, err error) {
 	keyValueYARPCServer := example.NewKeyValueYARPCServer()
 	procedures := examplepb.BuildKeyValueYARPCProcedures(keyValueYARPCServer)
@@ -827,12 +874,21 @@ func newTestEnv(
 		return nil, err
 	}
 
+	addr := listener.Addr().String()
+	if outboundHost != "" {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addr = net.JoinHostPort(outboundHost, port)
+	}
+
 	logger := zaptest.NewLogger(t)
 	transportOptions = append(transportOptions, Logger(logger))
 	trans := NewTransport(transportOptions...)
 	inbound := trans.NewInbound(listener, inboundOptions...)
 	inbound.SetRouter(testRouter)
-	chooser := peer.NewSingle(hostport.Identify(listener.Addr().String()), trans.NewDialer(dialOptions...))
+	chooser := peer.NewSingle(hostport.Identify(addr), trans.NewDialer(dialOptions...))
 	outbound := trans.NewOutbound(chooser, outboundOptions...)
 
 	if err := trans.Start(); err != nil {
@@ -864,7 +920,7 @@ func newTestEnv(
 
 	var clientConn *grpc.ClientConn
 
-	clientConn, err = grpc.Dial(listener.Addr().String(), newDialOptions(dialOptions).grpcOptions(trans)...)
+	clientConn, err = grpc.Dial(addr, newDialOptions(dialOptions).grpcOptions(trans)...)
 	if err != nil {
 		return nil, err
 	}
@@ -37,6 +37,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
@@ -169,6 +170,17 @@ func ClientMaxHeaderListSize(clientMaxHeaderListSize uint32) TransportOption {
 	}
 }
 
+// WithCustomResolver specifies the resolver for the host names of the peers
+// of the transport's outbounds, in place of the system resolver. This
+// applies to outbounds with and without TLS.
+//
+// This option does not apply to outbounds dialing with a ContextDialer.
+func WithCustomResolver(r *net.Resolver) TransportOption {
+	return func(transportOptions *transportOptions) {
+		transportOptions.resolver = r
+	}
+}
+
 // InboundOption is an option for an inbound.
 type InboundOption func(*inboundOptions)
 
@@ -199,6 +211,17 @@ func InboundTLSMode(mode yarpctls.Mode) InboundOption {
 	}
 }
 
+// InboundServices returns an InboundOption that registers gRPC services
+// with the server of the inbound, like the gRPC health checking service.
+//
+// Registered services take precedence over procedures of the same service
+// names, and their calls bypass the router and middleware of the dispatcher.
+func InboundServices(register func(grpc.ServiceRegistrar)) InboundOption {
+	return func(inboundOptions *inboundOptions) {
+		inboundOptions.services = append(inboundOptions.services, register)
+	}
+}
+
 // OutboundOption is an option for an outbound.
 type OutboundOption func(*outboundOptions)
 
@@ -222,6 +245,22 @@ func OutboundCompressor(compressor transport.Compressor) OutboundOption {
 	}
 }
 
+// WithMetadataInterceptor returns an OutboundOption that adds a function to
+// call on every unary and streaming call of the outbound, producing the final
+// gRPC metadata of the call from the metadata derived from the YARPC request
+// and its headers.
+//
+// Interceptors can inject dynamic metadata, like a per-request token, that
+// need not be passed as call options.
+// Multiple interceptors chain in the order they were registered, each
+// receiving the metadata returned by the previous interceptor.
+// Interceptors must not retain the metadata beyond the call.
+func WithMetadataInterceptor(interceptor func(ctx context.Context, md metadata.MD) metadata.MD) OutboundOption {
+	return func(outboundOptions *outboundOptions) {
+		outboundOptions.metadataInterceptors = append(outboundOptions.metadataInterceptors, interceptor)
+	}
+}
+
 // DialOption is an option that influences grpc.Dial.
 type DialOption func(*dialOptions)
 
@@ -295,6 +334,25 @@ type transportOptions struct {
 	clientMaxSendMsgSize    int
 	serverMaxHeaderListSize *uint32
 	clientMaxHeaderListSize *uint32
+	resolver                *net.Resolver
+}
+
+// introspectConfig returns the resolved options of the transport, keyed as
+// in YARPC configuration.
+func (o *transportOptions) introspectConfig() map[string]interface{} {
+	config := map[string]interface{}{
+		"serverMaxRecvMsgSize": o.serverMaxRecvMsgSize,
+		"serverMaxSendMsgSize": o.serverMaxSendMsgSize,
+		"clientMaxRecvMsgSize": o.clientMaxRecvMsgSize,
+		"clientMaxSendMsgSize": o.clientMaxSendMsgSize,
+	}
+	if o.serverMaxHeaderListSize != nil {
+		config["serverMaxHeaderListSize"] = *o.serverMaxHeaderListSize
+	}
+	if o.clientMaxHeaderListSize != nil {
+		config["clientMaxHeaderListSize"] = *o.clientMaxHeaderListSize
+	}
+	return config
 }
 
 func newTransportOptions(options []TransportOption) *transportOptions {
@@ -322,6 +380,8 @@ type inboundOptions struct {
 
 	tlsConfig *tls.Config
 	tlsMode   yarpctls.Mode
+
+	services []func(grpc.ServiceRegistrar)
 }
 
 func newInboundOptions(options []InboundOption) *inboundOptions {
@@ -333,8 +393,9 @@ func newInboundOptions(options []InboundOption) *inboundOptions {
 }
 
 type outboundOptions struct {
-	compressor        string
-	tlsConfigProvider yarpctls.OutboundTLSConfigProvider
+	compressor           string
+	tlsConfigProvider    yarpctls.OutboundTLSConfigProvider
+	metadataInterceptors []func(context.Context, metadata.MD) metadata.MD
 }
 
 func newOutboundOptions(options []OutboundOption) *outboundOptions {
@@ -373,6 +434,12 @@ func (d *dialOptions) grpcOptions(t *Transport) []grpc.DialOption {
 	}
 
 	contextDialer := d.contextDialer
+	if contextDialer == nil && t.options.resolver != nil {
+		netDialer := &net.Dialer{Resolver: t.options.resolver}
+		contextDialer = func(ctx context.Context, addr string) (net.Conn, error) {
+			return netDialer.DialContext(ctx, "tcp", addr)
+		}
+	}
 	if d.tlsConfig != nil {
 		params := dialer.Params{
 			Config:        d.tlsConfig,
@@ -383,9 +450,9 @@ func (d *dialOptions) grpcOptions(t *Transport) []grpc.DialOption {
 			Dest:          d.destServiceName,
 		}
 
-		if d.contextDialer != nil {
+		if baseDialer := contextDialer; baseDialer != nil {
 			params.Dialer = func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return d.contextDialer(ctx, addr)
+				return baseDialer(ctx, addr)
 			}
 		}
 		tlsDialer := dialer.NewTLSDialer(params)
@@ -49,6 +49,8 @@ const UserAgent = "yarpc-go/" + yarpc.Version
 
 var (
 	_                         transport.UnaryOutbound              = (*Outbound)(nil)
+	_                         transport.StreamOutbound             = (*Outbound)(nil)
+	_                         transport.BidiStreamOutbound         = (*Outbound)(nil)
 	_                         introspection.IntrospectableOutbound = (*Outbound)(nil)
 	invalidHeaderValueCharSet                                      = "\r\n" + string('\x00') // NUL
 )
@@ -105,6 +107,15 @@ func (o *Outbound) Chooser() peer.Chooser {
 	return o.peerChooser
 }
 
+// SubscribePeerStatus subscribes to the peer status changes of the outbound's
+// peer chooser, if the chooser supports it.
+func (o *Outbound) SubscribePeerStatus(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func(), ok bool) {
+	if w, ok := o.peerChooser.(peer.StatusWatcher); ok {
+		return w.Subscribe(fn), true
+	}
+	return nil, false
+}
+
 // Call implements transport.UnaryOutbound#Call.
 func (o *Outbound) Call(ctx context.Context, request *transport.Request) (*transport.Response, error) {
 	if request == nil {
@@ -208,6 +219,8 @@ func (o *Outbound) invoke(
 		return err
 	}
 
+	md = o.interceptMetadata(ctx, md)
+
 	err = transport.UpdateSpanWithErr(
 		span,
 		grpcPeer.clientConn.Invoke(
@@ -230,6 +243,15 @@ func (o *Outbound) invoke(
 	return nil
 }
 
+// interceptMetadata passes the metadata of a call through the metadata
+// interceptors of the outbound, in order.
+func (o *Outbound) interceptMetadata(ctx context.Context, md metadata.MD) metadata.MD {
+	for _, interceptor := range o.options.metadataInterceptors {
+		md = interceptor(ctx, md)
+	}
+	return md
+}
+
 func metadataToIsApplicationError(responseMD metadata.MD) bool {
 	if responseMD == nil {
 		return false
@@ -288,6 +310,15 @@ func (o *Outbound) CallStream(ctx context.Context, request *transport.StreamRequ
 	return o.stream(ctx, request, time.Now())
 }
 
+// CallBidiStream implements transport.BidiStreamOutbound#CallBidiStream.
+func (o *Outbound) CallBidiStream(ctx context.Context, request *transport.StreamRequest) (transport.BidiClientStream, error) {
+	stream, err := o.CallStream(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return transport.NewBidiClientStream(stream), nil
+}
+
 func (o *Outbound) stream(
 	ctx context.Context,
 	req *transport.StreamRequest,
@@ -341,6 +372,7 @@ func (o *Outbound) stream(
 		return nil, err
 	}
 
+	md = o.interceptMetadata(ctx, md)
 	streamCtx := metadata.NewOutgoingContext(ctx, md)
 	clientStream, err := grpcPeer.clientConn.NewStream(
 		streamCtx,
@@ -383,6 +415,10 @@ func (o *Outbound) Introspect() introspection.OutboundStatus {
 		Transport: TransportName,
 		State:     state,
 		Chooser:   chooser,
+		Config: map[string]interface{}{
+			"compressor": o.options.compressor,
+			"tls":        map[string]interface{}{"enabled": o.options.tlsConfigProvider != nil},
+		},
 	}
 }
 
@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"context"
 	"net"
+	"strconv"
 	"testing"
 	"time"
 
@@ -36,6 +37,7 @@ import (
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/yarpcerrors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 // shared between Unary and Streaming InvalidHeaderValue tests.
@@ -347,6 +349,72 @@ func TestCallServiceMatch(t *testing.T) {
 	}
 }
 
+func TestMetadataInterceptor(t *testing.T) {
+	received := make(chan metadata.MD, 1)
+	server := grpc.NewServer(
+		grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			received <- md
+			return stream.SendMsg(&types.Empty{})
+		}),
+	)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	type tokenKey struct{}
+	var order []string
+	grpcTransport := NewTransport()
+	out := grpcTransport.NewSingleOutbound(listener.Addr().String(),
+		WithMetadataInterceptor(func(ctx context.Context, md metadata.MD) metadata.MD {
+			order = append(order, "first")
+			assert.Equal(t, []string{"bar"}, md.Get("foo"), "interceptors receive the request headers")
+			md.Set("authorization", ctx.Value(tokenKey{}).(string))
+			return md
+		}),
+		WithMetadataInterceptor(func(ctx context.Context, md metadata.MD) metadata.MD {
+			order = append(order, "second")
+			md = md.Copy()
+			md.Set("x-token-seen", strconv.Itoa(len(md.Get("authorization"))))
+			return md
+		}),
+	)
+	require.NoError(t, grpcTransport.Start())
+	require.NoError(t, out.Start())
+	defer grpcTransport.Stop()
+	defer out.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ctx = context.WithValue(ctx, tokenKey{}, "Bearer token")
+	_, err = out.Call(ctx, &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  "raw",
+		Procedure: "Hello",
+		Headers:   transport.NewHeaders().With("foo", "bar"),
+		Body:      bytes.NewReader([]byte("world")),
+	})
+	require.NoError(t, err)
+
+	md := <-received
+	assert.Equal(t, []string{"first", "second"}, order)
+	assert.Equal(t, []string{"Bearer token"}, md.Get("authorization"))
+	assert.Equal(t, []string{"1"}, md.Get("x-token-seen"))
+	assert.Equal(t, []string{"bar"}, md.Get("foo"))
+	assert.Equal(t, []string{"caller"}, md.Get(CallerHeader))
+}
+
+func TestOutboundIntrospectionConfig(t *testing.T) {
+	o := NewTransport().NewSingleOutbound("127.0.0.1:1234")
+
+	assert.Equal(t, map[string]interface{}{
+		"compressor": "",
+		"tls":        map[string]interface{}{"enabled": false},
+	}, o.Introspect().Config)
+}
+
 func TestOutboundIntrospection(t *testing.T) {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	require.NoError(t, err)
@@ -150,7 +150,10 @@ func (cs *clientStream) ReceiveMessage(context.Context) (*transport.StreamMessag
 	if err := cs.stream.RecvMsg(&msg); err != nil {
 		return nil, toYARPCStreamError(cs.closeWithErr(err))
 	}
-	return &transport.StreamMessage{Body: ioutil.NopCloser(bytes.NewReader(msg))}, nil
+	return &transport.StreamMessage{
+		Body:     ioutil.NopCloser(bytes.NewReader(msg)),
+		BodySize: len(msg),
+	}, nil
 }
 
 func (cs *clientStream) Close(context.Context) error {
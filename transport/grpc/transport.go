@@ -25,6 +25,7 @@ import (
 	"sync"
 
 	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/pkg/lifecycle"
 )
 
@@ -80,6 +81,16 @@ func (t *Transport) IsRunning() bool {
 	return t.once.IsRunning()
 }
 
+// Introspect returns the state and the resolved configuration of the
+// transport for introspection purposes.
+func (t *Transport) Introspect() introspection.TransportStatus {
+	return introspection.TransportStatus{
+		Name:   TransportName,
+		State:  t.once.State().String(),
+		Config: t.options.introspectConfig(),
+	}
+}
+
 // NewInbound returns a new Inbound for the given listener.
 func (t *Transport) NewInbound(listener net.Listener, options ...InboundOption) *Inbound {
 	return newInbound(t, listener, options...)
@@ -38,6 +38,25 @@ func TestTransportLifecycle(t *testing.T) {
 	assert.False(t, transport.IsRunning())
 }
 
+func TestTransportIntrospect(t *testing.T) {
+	transport := NewTransport(ServerMaxRecvMsgSize(1024), ClientMaxHeaderListSize(2048))
+
+	status := transport.Introspect()
+	assert.Equal(t, TransportName, status.Name)
+	assert.Equal(t, "Idle", status.State)
+	assert.Equal(t, map[string]interface{}{
+		"serverMaxRecvMsgSize":    1024,
+		"serverMaxSendMsgSize":    defaultServerMaxSendMsgSize,
+		"clientMaxRecvMsgSize":    defaultClientMaxRecvMsgSize,
+		"clientMaxSendMsgSize":    defaultClientMaxSendMsgSize,
+		"clientMaxHeaderListSize": uint32(2048),
+	}, status.Config)
+
+	require.NoError(t, transport.Start())
+	assert.Equal(t, "Running", transport.Introspect().State)
+	require.NoError(t, transport.Stop())
+}
+
 func TestRetainReleasePeerSuccess(t *testing.T) {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	require.NoError(t, err)
@@ -0,0 +1,43 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package grpcweb implements an inbound that serves a gRPC server to both
+// native gRPC clients and browser clients speaking the gRPC-Web protocol.
+//
+// gRPC-Web is a variant of the gRPC wire protocol that works over HTTP/1.1,
+// which browsers can send. The inbound accepts cleartext HTTP/2 for native
+// gRPC and HTTP/1.1 for gRPC-Web on the same port.
+//
+// 	server := grpc.NewServer()
+// 	healthpb.RegisterHealthServer(server, health.NewServer())
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		Inbounds: yarpc.Inbounds{
+// 			grpcweb.NewInbound(server,
+// 				grpcweb.WithAddress(":8080"),
+// 				grpcweb.WithAllowedOrigins([]string{"https://example.com"}),
+// 			),
+// 		},
+// 	})
+//
+// The inbound serves the services registered with the given gRPC server
+// rather than the procedures registered with the dispatcher.
+package grpcweb
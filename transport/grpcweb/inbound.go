@@ -0,0 +1,182 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpcweb
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+// TransportName is the name of the transport.
+//
+// This value is what is used for introspection.
+const TransportName = "grpc-web"
+
+var (
+	_ introspection.IntrospectableInbound = (*Inbound)(nil)
+	_ transport.Inbound                   = (*Inbound)(nil)
+)
+
+// Inbound serves a gRPC server over native gRPC and gRPC-Web.
+type Inbound struct {
+	once       *lifecycle.Once
+	options    inboundOptions
+	grpcServer *grpc.Server
+
+	lock     sync.RWMutex
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewInbound builds a new gRPC-Web inbound that serves the given gRPC server.
+//
+// The gRPC server must not be served elsewhere. Stopping the inbound
+// gracefully stops the gRPC server.
+func NewInbound(grpcServer *grpc.Server, opts ...InboundOption) *Inbound {
+	return &Inbound{
+		once:       lifecycle.NewOnce(),
+		options:    newInboundOptions(opts),
+		grpcServer: grpcServer,
+	}
+}
+
+// Start implements transport.Lifecycle#Start.
+func (i *Inbound) Start() error {
+	return i.once.Start(i.start)
+}
+
+// Stop implements transport.Lifecycle#Stop.
+func (i *Inbound) Stop() error {
+	return i.once.Stop(i.stop)
+}
+
+// IsRunning implements transport.Lifecycle#IsRunning.
+func (i *Inbound) IsRunning() bool {
+	return i.once.IsRunning()
+}
+
+// SetRouter implements transport.Inbound#SetRouter.
+//
+// The router is ignored: the inbound serves the services registered with its
+// gRPC server.
+func (i *Inbound) SetRouter(transport.Router) {}
+
+// Transports implements transport.Inbound#Transports.
+//
+// The inbound does not use any transports.
+func (i *Inbound) Transports() []transport.Transport {
+	return nil
+}
+
+// Addr returns the address on which the server is listening.
+//
+// Returns nil if Start has not been called yet.
+func (i *Inbound) Addr() net.Addr {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	if i.listener == nil {
+		return nil
+	}
+	return i.listener.Addr()
+}
+
+func (i *Inbound) start() error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	listener, err := net.Listen("tcp", i.options.address)
+	if err != nil {
+		return err
+	}
+
+	wrapped := grpcweb.WrapServer(i.grpcServer, grpcweb.WithOriginFunc(originFunc(i.options.allowedOrigins)))
+	// Native gRPC clients use HTTP/2 without TLS, which net/http only
+	// accepts through h2c.
+	server := &http.Server{Handler: h2c.NewHandler(wrapped, &http2.Server{})}
+
+	go func() {
+		i.options.logger.Info("started gRPC-Web inbound", zap.Stringer("address", listener.Addr()))
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			i.options.logger.Error("gRPC-Web inbound stopped serving", zap.Error(err))
+		}
+	}()
+
+	i.listener = listener
+	i.server = server
+	return nil
+}
+
+func (i *Inbound) stop() error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.server == nil {
+		return nil
+	}
+	err := i.server.Shutdown(context.Background())
+	i.grpcServer.GracefulStop()
+	i.server = nil
+	i.listener = nil
+	return err
+}
+
+// originFunc returns a predicate accepting the given origins.
+func originFunc(allowed []string) func(string) bool {
+	origins := make(map[string]struct{}, len(allowed))
+	for _, origin := range allowed {
+		if origin == "*" {
+			return func(string) bool { return true }
+		}
+		origins[origin] = struct{}{}
+	}
+	return func(origin string) bool {
+		_, ok := origins[origin]
+		return ok
+	}
+}
+
+// Introspect returns the current state of the inbound.
+func (i *Inbound) Introspect() introspection.InboundStatus {
+	state := "Stopped"
+	if i.IsRunning() {
+		state = "Started"
+	}
+	var addrString string
+	if addr := i.Addr(); addr != nil {
+		addrString = addr.String()
+	}
+	return introspection.InboundStatus{
+		Transport: TransportName,
+		Endpoint:  addrString,
+		State:     state,
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpcweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/testtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func newTestInbound(t *testing.T, opts ...InboundOption) *Inbound {
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+
+	inbound := NewInbound(server, opts...)
+	require.NoError(t, inbound.Start())
+	t.Cleanup(func() { assert.NoError(t, inbound.Stop()) })
+	return inbound
+}
+
+func TestNativeGRPC(t *testing.T) {
+	inbound := newTestInbound(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, inbound.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+}
+
+func TestGRPCWeb(t *testing.T) {
+	inbound := newTestInbound(t)
+
+	reqBody, err := proto.Marshal(&healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest("POST",
+		"http://"+inbound.Addr().String()+"/grpc.health.v1.Health/Check",
+		bytes.NewReader(frame(0, reqBody)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	req.Header.Set("X-Grpc-Web", "1")
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, 1, res.ProtoMajor, "gRPC-Web must be served over HTTP/1.1")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/grpc-web+proto", res.Header.Get("Content-Type"))
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	// The response holds a data frame followed by a trailer frame.
+	flags, data, rest := readFrame(t, body)
+	require.Equal(t, byte(0), flags, "expected a data frame")
+	var msg healthpb.HealthCheckResponse
+	require.NoError(t, proto.Unmarshal(data, &msg))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, msg.Status)
+
+	flags, trailer, _ := readFrame(t, rest)
+	require.Equal(t, byte(0x80), flags, "expected a trailer frame")
+	assert.Contains(t, string(trailer), "grpc-status: 0")
+}
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		desc      string
+		allowed   []string
+		origin    string
+		wantAllow string
+	}{
+		{desc: "rejected by default", origin: "https://example.com"},
+		{
+			desc:      "allowed origin",
+			allowed:   []string{"https://example.com"},
+			origin:    "https://example.com",
+			wantAllow: "https://example.com",
+		},
+		{
+			desc:    "other origin",
+			allowed: []string{"https://example.com"},
+			origin:  "https://example.org",
+		},
+		{
+			desc:      "all origins",
+			allowed:   []string{"*"},
+			origin:    "https://example.org",
+			wantAllow: "https://example.org",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			inbound := newTestInbound(t, WithAllowedOrigins(tt.allowed))
+
+			req, err := http.NewRequest("OPTIONS",
+				"http://"+inbound.Addr().String()+"/grpc.health.v1.Health/Check", nil)
+			require.NoError(t, err)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			res.Body.Close()
+			assert.Equal(t, tt.wantAllow, res.Header.Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
+
+func TestInboundLifecycle(t *testing.T) {
+	inbound := NewInbound(grpc.NewServer(), WithAddress("127.0.0.1:0"))
+	assert.Nil(t, inbound.Addr())
+	assert.Nil(t, inbound.Transports())
+	assert.Equal(t, "Stopped", inbound.Introspect().State)
+
+	require.NoError(t, inbound.Start())
+	assert.True(t, inbound.IsRunning())
+	status := inbound.Introspect()
+	assert.Equal(t, TransportName, status.Transport)
+	assert.Equal(t, inbound.Addr().String(), status.Endpoint)
+	assert.Equal(t, "Started", status.State)
+
+	require.NoError(t, inbound.Stop())
+	assert.False(t, inbound.IsRunning())
+	assert.Nil(t, inbound.Addr())
+}
+
+func TestInboundListenError(t *testing.T) {
+	inbound := NewInbound(grpc.NewServer(), WithAddress("not an address"))
+	assert.Error(t, inbound.Start())
+}
+
+// frame encodes a gRPC-Web frame with the given flags.
+func frame(flags byte, data []byte) []byte {
+	buf := make([]byte, 5, 5+len(data))
+	buf[0] = flags
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(data)))
+	return append(buf, data...)
+}
+
+func readFrame(t *testing.T, b []byte) (flags byte, data, rest []byte) {
+	require.True(t, len(b) >= 5, "frame too short")
+	n := binary.BigEndian.Uint32(b[1:5])
+	require.True(t, len(b) >= 5+int(n), "frame too short")
+	return b[0], b[5 : 5+n], b[5+n:]
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package grpcweb
+
+import "go.uber.org/zap"
+
+const defaultAddress = ":0"
+
+type inboundOptions struct {
+	address        string
+	allowedOrigins []string
+	logger         *zap.Logger
+}
+
+func newInboundOptions(options []InboundOption) inboundOptions {
+	opts := inboundOptions{
+		address: defaultAddress,
+		logger:  zap.NewNop(),
+	}
+	for _, option := range options {
+		option(&opts)
+	}
+	return opts
+}
+
+// InboundOption customizes the behavior of a gRPC-Web Inbound.
+type InboundOption func(*inboundOptions)
+
+// WithAddress specifies the address on which the inbound listens, in the
+// form "host:port".
+//
+// Defaults to ":0", an arbitrary free port.
+func WithAddress(address string) InboundOption {
+	return func(options *inboundOptions) {
+		options.address = address
+	}
+}
+
+// WithAllowedOrigins specifies the origins from which browsers may send
+// cross-origin gRPC-Web requests. The origin "*" allows all origins.
+//
+// By default, cross-origin requests are rejected.
+func WithAllowedOrigins(origins []string) InboundOption {
+	return func(options *inboundOptions) {
+		options.allowedOrigins = origins
+	}
+}
+
+// WithLogger specifies a logger for the inbound.
+func WithLogger(logger *zap.Logger) InboundOption {
+	return func(options *inboundOptions) {
+		options.logger = logger
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// _baggageHeader is the header of the W3C Baggage specification.
+//
+// See https://www.w3.org/TR/baggage/.
+const _baggageHeader = "Baggage"
+
+// WithInboundW3CBaggagePropagation returns an InboundOption that reads the
+// W3C Baggage headers of requests, and adds their members to the baggage of
+// the span of the request.
+//
+//	baggage: user=alice, tenant=acme%20corp;region=eu
+//
+// Values are percent-decoded, and the properties of members are ignored.
+// Malformed members are skipped. Members of requests with several Baggage
+// headers are merged, with later members taking precedence.
+func WithInboundW3CBaggagePropagation() InboundOption {
+	return func(i *Inbound) {
+		i.w3cBaggage = true
+	}
+}
+
+// WithOutboundW3CBaggagePropagation returns an OutboundOption that writes the
+// baggage of the span of each request into its W3C Baggage header, with
+// values percent-encoded where the specification requires it.
+//
+// Baggage items whose keys are not valid HTTP tokens are left out of the
+// header.
+func WithOutboundW3CBaggagePropagation() OutboundOption {
+	return func(o *Outbound) {
+		o.w3cBaggage = true
+	}
+}
+
+// extractW3CBaggage adds the members of the W3C Baggage headers to the
+// baggage of the span.
+func extractW3CBaggage(header http.Header, span opentracing.Span) {
+	for _, value := range header.Values(_baggageHeader) {
+		for _, member := range strings.Split(value, ",") {
+			if k, v, ok := parseBaggageMember(member); ok {
+				span.SetBaggageItem(k, v)
+			}
+		}
+	}
+}
+
+// parseBaggageMember parses a single key=value;properties member of a W3C
+// Baggage header.
+func parseBaggageMember(member string) (key, value string, ok bool) {
+	if i := strings.IndexByte(member, ';'); i >= 0 {
+		member = member[:i]
+	}
+	i := strings.IndexByte(member, '=')
+	if i < 0 {
+		return "", "", false
+	}
+	key = strings.TrimSpace(member[:i])
+	if !isBaggageKey(key) {
+		return "", "", false
+	}
+	value, err := url.PathUnescape(strings.TrimSpace(member[i+1:]))
+	if err != nil {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// injectW3CBaggage writes the baggage of the span context into the W3C
+// Baggage header, with members sorted by key.
+func injectW3CBaggage(sc opentracing.SpanContext, header http.Header) {
+	var members []string
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		if isBaggageKey(k) {
+			members = append(members, k+"="+escapeBaggageValue(v))
+		}
+		return true
+	})
+	if len(members) == 0 {
+		return
+	}
+	sort.Strings(members)
+	header.Set(_baggageHeader, strings.Join(members, ","))
+}
+
+// isBaggageKey reports whether the key is an HTTP token, as the keys of W3C
+// Baggage members must be.
+func isBaggageKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if !isTokenChar(key[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// escapeBaggageValue percent-encodes the bytes of the value that are not
+// baggage-octets, and percent signs, so that the value decodes back to
+// itself.
+func escapeBaggageValue(v string) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if isBaggageOctet(c) && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		const hex = "0123456789ABCDEF"
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
+
+// isBaggageOctet reports whether the byte may appear unencoded in the value
+// of a W3C Baggage member: printable US-ASCII other than spaces, double
+// quotes, commas, semicolons, and backslashes.
+func isBaggageOctet(c byte) bool {
+	return c > ' ' && c < 0x7f && c != '"' && c != ',' && c != ';' && c != '\\'
+}
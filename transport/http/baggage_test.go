@@ -0,0 +1,202 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+)
+
+func TestInboundW3CBaggage(t *testing.T) {
+	tests := []struct {
+		desc    string
+		headers []string
+		want    map[string]string
+	}{
+		{
+			desc:    "single member",
+			headers: []string{"user=alice"},
+			want:    map[string]string{"user": "alice"},
+		},
+		{
+			desc:    "multiple members",
+			headers: []string{"user=alice, tenant = acme ,region=eu"},
+			want:    map[string]string{"user": "alice", "tenant": "acme", "region": "eu"},
+		},
+		{
+			desc:    "multiple headers",
+			headers: []string{"user=alice,tenant=acme", "tenant=initech", "region=eu"},
+			want:    map[string]string{"user": "alice", "tenant": "initech", "region": "eu"},
+		},
+		{
+			desc:    "properties",
+			headers: []string{"user=alice;sensitive;ttl=30,tenant=acme"},
+			want:    map[string]string{"user": "alice", "tenant": "acme"},
+		},
+		{
+			desc:    "percent-encoded values",
+			headers: []string{"name=Alice%20Smith,city=Z%C3%BCrich,expr=a%2Cb%3Bc%3Dd,pct=100%25,plus=a+b"},
+			want: map[string]string{
+				"name": "Alice Smith",
+				"city": "Zürich",
+				"expr": "a,b;c=d",
+				"pct":  "100%",
+				"plus": "a+b",
+			},
+		},
+		{
+			desc:    "equal signs in values",
+			headers: []string{"query=a=b"},
+			want:    map[string]string{"query": "a=b"},
+		},
+		{
+			desc:    "empty value",
+			headers: []string{"flag="},
+			want:    map[string]string{"flag": ""},
+		},
+		{
+			desc:    "malformed members",
+			headers: []string{"user=alice,,junk,=value,bad key=x,bad=%zz,tenant=acme"},
+			want:    map[string]string{"user": "alice", "tenant": "acme"},
+		},
+		{
+			desc:    "no header",
+			headers: nil,
+			want:    map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "http://localhost/", nil)
+			require.NoError(t, err)
+			for _, h := range tt.headers {
+				req.Header.Add(_baggageHeader, h)
+			}
+
+			h := handler{tracer: mocktracer.New(), w3cBaggage: true}
+			_, span := h.createSpan(context.Background(), req, &transport.Request{Procedure: "hello"}, time.Now())
+
+			got := make(map[string]string)
+			span.Context().ForeachBaggageItem(func(k, v string) bool {
+				got[k] = v
+				return true
+			})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInboundW3CBaggageDisabled(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://localhost/", nil)
+	require.NoError(t, err)
+	req.Header.Set(_baggageHeader, "user=alice")
+
+	h := handler{tracer: mocktracer.New()}
+	_, span := h.createSpan(context.Background(), req, &transport.Request{Procedure: "hello"}, time.Now())
+	assert.Empty(t, span.BaggageItem("user"))
+}
+
+func TestOutboundW3CBaggage(t *testing.T) {
+	tests := []struct {
+		desc    string
+		baggage map[string]string
+		want    string
+	}{
+		{
+			desc:    "multiple items",
+			baggage: map[string]string{"user": "alice", "tenant": "acme", "region": "eu"},
+			want:    "region=eu,tenant=acme,user=alice",
+		},
+		{
+			desc: "encoded values",
+			baggage: map[string]string{
+				"name":  "Alice Smith",
+				"city":  "Zürich",
+				"expr":  `a,b;c="d"\`,
+				"pct":   "100%",
+				"query": "a=b+c",
+			},
+			want: "city=Z%C3%BCrich,expr=a%2Cb%3Bc=%22d%22%5C,name=Alice%20Smith,pct=100%25,query=a=b+c",
+		},
+		{
+			desc:    "invalid keys",
+			baggage: map[string]string{"bad key": "x", "bad,key": "y", "user": "alice"},
+			want:    "user=alice",
+		},
+		{
+			desc:    "no baggage",
+			baggage: nil,
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			tracer := mocktracer.New()
+			out := NewTransport(Tracer(tracer)).NewSingleOutbound("http://localhost/", WithOutboundW3CBaggagePropagation())
+
+			parent := tracer.StartSpan("parent")
+			for k, v := range tt.baggage {
+				parent.SetBaggageItem(k, v)
+			}
+			ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+			req, err := http.NewRequest("POST", "http://localhost/", nil)
+			require.NoError(t, err)
+			_, req, _, err = out.withOpentracingSpan(ctx, req, &transport.Request{Procedure: "hello"}, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, req.Header.Get(_baggageHeader))
+		})
+	}
+}
+
+func TestW3CBaggageRoundTrip(t *testing.T) {
+	baggage := map[string]string{
+		"ascii":    "hello world",
+		"unicode":  "日本語",
+		"reserved": `,;="\%`,
+		"control":  "line\nbreak\ttab",
+		"empty":    "",
+	}
+
+	tracer := mocktracer.New()
+	parent := tracer.StartSpan("parent")
+	for k, v := range baggage {
+		parent.SetBaggageItem(k, v)
+	}
+	header := make(http.Header)
+	injectW3CBaggage(parent.Context(), header)
+
+	span := tracer.StartSpan("child")
+	extractW3CBaggage(header, span)
+	for k, v := range baggage {
+		assert.Equal(t, v, span.BaggageItem(k), "baggage item %q", k)
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"go.uber.org/yarpc/api/transport"
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
 	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/transport/internal/tls/keypair"
 	"go.uber.org/yarpc/yarpcconfig"
 )
 
@@ -90,6 +91,8 @@ func (ts *transportSpec) Spec() yarpcconfig.TransportSpec {
 //      disableCompression: false
 //      responseHeaderTimeout: 0s
 //      connTimeout: 500ms
+//      tcpSendBufferSize: 0
+//      tcpReceiveBufferSize: 0
 //      connBackoff:
 //        exponential:
 //          first: 10ms
@@ -109,6 +112,16 @@ type TransportConfig struct {
 	ResponseHeaderTimeout time.Duration       `config:"responseHeaderTimeout"`
 	ConnTimeout           time.Duration       `config:"connTimeout"`
 	ConnBackoff           yarpcconfig.Backoff `config:"connBackoff"`
+	// Specifies the sizes in bytes of the send and receive buffers of TCP
+	// connections, like 4194304 or 4MiB. Zero leaves the operating system
+	// default.
+	TCPSendBufferSize    yarpcconfig.ByteSize `config:"tcpSendBufferSize"`
+	TCPReceiveBufferSize yarpcconfig.ByteSize `config:"tcpReceiveBufferSize"`
+	// Specifies the number of connections to establish to every peer before
+	// reporting it available, and how long to wait for them. Warm-up is
+	// disabled unless both are positive.
+	WarmUpConnections int           `config:"warmUpConnections"`
+	WarmUpTimeout     time.Duration `config:"warmUpTimeout"`
 }
 
 func (ts *transportSpec) buildTransport(tc *TransportConfig, k *yarpcconfig.Kit) (transport.Transport, error) {
@@ -145,6 +158,18 @@ func (ts *transportSpec) buildTransport(tc *TransportConfig, k *yarpcconfig.Kit)
 	if tc.ConnTimeout > 0 {
 		options.connTimeout = tc.ConnTimeout
 	}
+	if tc.TCPSendBufferSize > 0 {
+		options.tcpBufferSizes.Send = int(tc.TCPSendBufferSize)
+	}
+	if tc.TCPReceiveBufferSize > 0 {
+		options.tcpBufferSizes.Receive = int(tc.TCPReceiveBufferSize)
+	}
+	if tc.WarmUpConnections > 0 {
+		options.warmUpConns = tc.WarmUpConnections
+	}
+	if tc.WarmUpTimeout > 0 {
+		options.warmUpTimeout = tc.WarmUpTimeout
+	}
 
 	strategy, err := tc.ConnBackoff.Strategy()
 	if err != nil {
@@ -164,6 +189,17 @@ func (ts *transportSpec) buildTransport(tc *TransportConfig, k *yarpcconfig.Kit)
 //        - x-foo
 //        - x-bar
 //      shutdownTimeout: 5s
+//
+// The inbound can serve the pprof handlers of net/http/pprof under a path
+// prefix, to clients with loopback addresses or in the allowed CIDR ranges.
+//
+//  inbounds:
+//    http:
+//      address: ":80"
+//      pprof:
+//        path: /debug/pprof/
+//        allowedCIDRs:
+//          - 10.0.0.0/8
 type InboundConfig struct {
 	// Address to listen on. This field is required.
 	Address string `config:"address,interpolate"`
@@ -174,13 +210,35 @@ type InboundConfig struct {
 	ShutdownTimeout *time.Duration `config:"shutdownTimeout"`
 	// TLS configuration of the inbound.
 	TLSConfig TLSConfig `config:"tls"`
+	// Pprof serves the pprof handlers on the inbound, if specified.
+	Pprof *PprofConfig `config:"pprof"`
+}
+
+// PprofConfig specifies the pprof handlers of the HTTP inbound.
+type PprofConfig struct {
+	// Path prefix of the handlers. Defaults to /debug/pprof/.
+	Path string `config:"path"`
+	// CIDR ranges of the clients, other than loopback clients, that may
+	// access the handlers.
+	AllowedCIDRs []string `config:"allowedCIDRs"`
 }
 
 // TLSConfig specifies the TLS configuration of the HTTP inbound.
 type TLSConfig struct {
 	// Mode when set to Permissive or Enforced enables TLS inbound and
-	// TLS configuration must be passed as an inbound option.
+	// TLS configuration must be passed as an inbound option, or as the
+	// cert and key attributes.
 	Mode yarpctls.Mode `config:"mode,interpolate"`
+	// Cert and Key are the PEM-encoded certificate and private key that
+	// the inbound serves, unless TLS configuration is passed as an
+	// inbound option.
+	//
+	//  tls:
+	//    mode: enforced
+	//    cert: file:///etc/tls/cert.pem
+	//    key: file:///etc/tls/key.pem
+	Cert yarpcconfig.Secret `config:"cert"`
+	Key  yarpcconfig.Secret `config:"key"`
 }
 
 func (ts *transportSpec) buildInbound(ic *InboundConfig, t transport.Transport, k *yarpcconfig.Kit) (transport.Inbound, error) {
@@ -188,9 +246,18 @@ func (ts *transportSpec) buildInbound(ic *InboundConfig, t transport.Transport,
 		return nil, fmt.Errorf("inbound address is required")
 	}
 
-	// TLS mode provided in the inbound options takes higher precedence than
-	// the TLS mode passed in YAML config.
-	inboundOptions := append([]InboundOption{InboundTLSMode(ic.TLSConfig.Mode)}, ts.InboundOptions...)
+	tlsConfig, err := keypair.ServerConfig(ic.TLSConfig.Cert, ic.TLSConfig.Key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure TLS for HTTP inbound: %v", err)
+	}
+
+	// TLS mode and configuration provided in the inbound options take higher
+	// precedence than the TLS mode and key pair passed in YAML config.
+	inboundOptions := []InboundOption{InboundTLSMode(ic.TLSConfig.Mode)}
+	if tlsConfig != nil {
+		inboundOptions = append(inboundOptions, InboundTLSConfiguration(tlsConfig))
+	}
+	inboundOptions = append(inboundOptions, ts.InboundOptions...)
 	if len(ic.GrabHeaders) > 0 {
 		inboundOptions = append(inboundOptions, GrabHeaders(ic.GrabHeaders...))
 	}
@@ -202,6 +269,12 @@ func (ts *transportSpec) buildInbound(ic *InboundConfig, t transport.Transport,
 		inboundOptions = append(inboundOptions, ShutdownTimeout(*ic.ShutdownTimeout))
 	}
 
+	if ic.Pprof != nil {
+		inboundOptions = append(inboundOptions,
+			WithPprofEndpoint(ic.Pprof.Path),
+			WithPprofAllowedCIDRs(ic.Pprof.AllowedCIDRs))
+	}
+
 	return t.(*Transport).NewInbound(ic.Address, inboundOptions...), nil
 }
 
@@ -260,6 +333,42 @@ type OutboundConfig struct {
 	//      spiffe-ids:
 	//        - destination-id
 	TLS OutboundTLSConfig `config:"tls"`
+	// OAuth2 authenticates requests with an OAuth2 access token obtained
+	// with the client credentials flow.
+	//
+	//  http:
+	//    url: "https://localhost:8080/yarpc"
+	//    oauth2:
+	//      tokenURL: https://auth.example.com/oauth2/token
+	//      clientID: myservice
+	//      clientSecret: env://OAUTH2_CLIENT_SECRET
+	//      scopes: [read]
+	OAuth2 *OAuth2Config `config:"oauth2"`
+}
+
+// OAuth2Config configures the OAuth2 client credentials of the HTTP
+// outbound.
+type OAuth2Config struct {
+	// TokenURL is the URL from which access tokens are obtained. This field
+	// is required.
+	TokenURL     string             `config:"tokenURL,interpolate"`
+	ClientID     string             `config:"clientID,interpolate"`
+	ClientSecret yarpcconfig.Secret `config:"clientSecret"`
+	Scopes       []string           `config:"scopes"`
+}
+
+func (c *OAuth2Config) options() ([]OutboundOption, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if c.TokenURL == "" {
+		return nil, errors.New("oauth2.tokenURL is required")
+	}
+	secret, err := c.ClientSecret.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve oauth2.clientSecret: %v", err)
+	}
+	return []OutboundOption{WithOAuth2ClientCredentials(c.TokenURL, c.ClientID, string(secret), c.Scopes)}, nil
 }
 
 // OutboundTLSConfig configures TLS for the HTTP outbound.
@@ -315,6 +424,12 @@ func (ts *transportSpec) buildOutbound(oc *OutboundConfig, t transport.Transport
 	}
 	opts = append(option, opts...)
 
+	oauth2Options, err := oc.OAuth2.options()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(oauth2Options, opts...)
+
 	// Special case where the URL implies the single peer.
 	if oc.Empty() {
 		return x.NewSingleOutbound(oc.URL, opts...), nil
@@ -32,6 +32,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/transport/internal/sockopt"
 	"go.uber.org/yarpc/yarpcconfig"
 )
 
@@ -81,6 +83,7 @@ func TestTransportSpec(t *testing.T) {
 		GrabHeaders     map[string]struct{}
 		ShutdownTimeout time.Duration
 		TLSMode         yarpctls.Mode
+		Pprof           pprofConfig
 	}
 
 	type inboundTest struct {
@@ -99,6 +102,9 @@ func TestTransportSpec(t *testing.T) {
 		URLTemplate string
 		Headers     http.Header
 		TLSConfig   bool
+		// OAuth2ClientSecret is the resolved client secret of the OAuth2
+		// client credentials, if any.
+		OAuth2ClientSecret string
 	}
 
 	type outboundTest struct {
@@ -147,6 +153,8 @@ func TestTransportSpec(t *testing.T) {
 				"disableKeepAlives":     true,
 				"disableCompression":    true,
 				"responseHeaderTimeout": "1s",
+				"tcpSendBufferSize":     1 << 20,
+				"tcpReceiveBufferSize":  2 << 20,
 			},
 			wantClient: &wantHTTPClient{
 				KeepAlive:             5 * time.Second,
@@ -157,6 +165,35 @@ func TestTransportSpec(t *testing.T) {
 				DisableKeepAlives:     true,
 				DisableCompression:    true,
 				ResponseHeaderTimeout: 1 * time.Second,
+				TCPBufferSizes:        sockopt.BufferSizes{Send: 1 << 20, Receive: 2 << 20},
+			},
+		},
+		{
+			desc: "TCP buffer size option",
+			opts: []Option{
+				WithTCPBufferSize(1<<20, 0),
+			},
+			wantClient: &wantHTTPClient{
+				KeepAlive:           30 * time.Second,
+				MaxIdleConnsPerHost: 2,
+				ConnTimeout:         defaultConnTimeout,
+				IdleConnTimeout:     defaultIdleConnTimeout,
+				TCPBufferSizes:      sockopt.BufferSizes{Send: 1 << 20},
+			},
+		},
+		{
+			desc: "warm-up config",
+			cfg: attrs{
+				"warmUpConnections": 2,
+				"warmUpTimeout":     "1s",
+			},
+			wantClient: &wantHTTPClient{
+				KeepAlive:           30 * time.Second,
+				MaxIdleConnsPerHost: 2,
+				ConnTimeout:         defaultConnTimeout,
+				IdleConnTimeout:     defaultIdleConnTimeout,
+				WarmUpConns:         2,
+				WarmUpTimeout:       time.Second,
 			},
 		},
 	}
@@ -239,6 +276,26 @@ func TestTransportSpec(t *testing.T) {
 			cfg:        attrs{"address": ":8080", "shutdownTimeout": "-1s"},
 			wantErrors: []string{`shutdownTimeout must not be negative, got: "-1s"`},
 		},
+		{
+			desc: "pprof",
+			cfg: attrs{
+				"address": ":8080",
+				"pprof":   attrs{"allowedCIDRs": []string{"10.0.0.0/8"}},
+			},
+			wantInbound: &wantInbound{
+				Address:         ":8080",
+				ShutdownTimeout: defaultShutdownTimeout,
+				Pprof: pprofConfig{
+					path:         "/debug/pprof/",
+					allowedCIDRs: []string{"10.0.0.0/8"},
+				},
+			},
+		},
+		{
+			desc:        "pprof path",
+			cfg:         attrs{"address": ":8080", "pprof": attrs{"path": "/pprof"}},
+			wantInbound: &wantInbound{Address: ":8080", ShutdownTimeout: defaultShutdownTimeout, Pprof: pprofConfig{path: "/pprof/"}},
+		},
 	}
 
 	outboundTests := []outboundTest{
@@ -391,6 +448,26 @@ func TestTransportSpec(t *testing.T) {
 				`failed to read attribute "least-pending"`,
 			},
 		},
+		{
+			desc: "outbound peer list build error",
+			cfg: attrs{
+				"myservice": attrs{
+					"http": attrs{
+						"round-robin": attrs{
+							"capacity": 0,
+							"peers": []string{
+								"127.0.0.1:8080",
+								"127.0.0.1:8081",
+							},
+						},
+					},
+				},
+			},
+			wantErrors: []string{
+				"cannot configure peer chooser for HTTP outbound",
+				"Capacity must be greater than 0",
+			},
+		},
 		{
 			desc: "unknown preset",
 			cfg: attrs{
@@ -488,6 +565,40 @@ func TestTransportSpec(t *testing.T) {
 			},
 			wantErrors: []string{"outbound TLS enforced but outbound TLS config provider is nil"},
 		},
+		{
+			desc: "OAuth2 outbound with an inline secret",
+			cfg: attrs{
+				"myservice": attrs{
+					TransportName: attrs{
+						"url": "http://localhost/yarpc",
+						"oauth2": attrs{
+							"tokenURL":     "http://localhost/token",
+							"clientID":     "foo",
+							"clientSecret": "hunter2",
+							"scopes":       []string{"read"},
+						},
+					},
+				},
+			},
+			wantOutbounds: map[string]wantOutbound{
+				"myservice": {
+					URLTemplate:        "http://localhost/yarpc",
+					OAuth2ClientSecret: "hunter2",
+				},
+			},
+		},
+		{
+			desc: "OAuth2 outbound without a token URL",
+			cfg: attrs{
+				"myservice": attrs{
+					TransportName: attrs{
+						"url":    "http://localhost/yarpc",
+						"oauth2": attrs{"clientID": "foo"},
+					},
+				},
+			},
+			wantErrors: []string{"oauth2.tokenURL is required"},
+		},
 	}
 
 	runTest := func(t *testing.T, trans transportTest, inbound inboundTest, outbound outboundTest) {
@@ -508,6 +619,7 @@ func TestTransportSpec(t *testing.T) {
 			env[k] = v
 		}
 		configurator := yarpcconfig.New(yarpcconfig.InterpolationResolver(mapResolver(env)))
+		configurator.MustRegisterPeerList(roundrobin.Spec())
 
 		opts := append(append(trans.opts, inbound.opts...), outbound.opts...)
 		if trans.wantClient != nil {
@@ -558,6 +670,7 @@ func TestTransportSpec(t *testing.T) {
 				assert.Equal(t, want.ShutdownTimeout, ib.shutdownTimeout, "shutdownTimeout should match")
 				assert.Equal(t, "foo", ib.transport.serviceName, "service name must match")
 				assert.Equal(t, want.TLSMode, ib.tlsMode, "tlsMode should match")
+				assert.Equal(t, want.Pprof, ib.pprof, "pprof should match")
 			}
 		}
 
@@ -572,6 +685,14 @@ func TestTransportSpec(t *testing.T) {
 				assert.Equal(t, want.Headers, ob.headers, "outbound headers should match")
 				assert.Equal(t, svc, ob.destServiceName, "outbound destination service name must match")
 				assert.Equal(t, want.TLSConfig, ob.tlsConfig != nil, "unexpected outbound tls config")
+				if want.OAuth2ClientSecret != "" {
+					require.NotNil(t, ob.oauth2Config, "expected OAuth2 client credentials")
+					assert.Equal(t, want.OAuth2ClientSecret, ob.oauth2Config.ClientSecret, "OAuth2 client secret should match")
+					assert.NotContains(t, fmt.Sprint(ob.Introspect().Config), want.OAuth2ClientSecret,
+						"introspection must not report the client secret")
+				} else {
+					assert.Nil(t, ob.oauth2Config, "unexpected OAuth2 client credentials")
+				}
 			}
 
 		}
@@ -613,6 +734,9 @@ type wantHTTPClient struct {
 	DisableCompression    bool
 	ResponseHeaderTimeout time.Duration
 	ConnTimeout           time.Duration
+	TCPBufferSizes        sockopt.BufferSizes
+	WarmUpConns           int
+	WarmUpTimeout         time.Duration
 }
 
 // useFakeBuildClient verifies the configuration we use to build an HTTP
@@ -627,6 +751,9 @@ func useFakeBuildClient(t *testing.T, want *wantHTTPClient) TransportOption {
 		assert.Equal(t, want.DisableCompression, options.disableCompression, "http.Client: DisableCompression should match")
 		assert.Equal(t, want.ResponseHeaderTimeout, options.responseHeaderTimeout, "http.Client: ResponseHeaderTimeout should match")
 		assert.Equal(t, want.ConnTimeout, options.connTimeout, "http.Client: ConnTimeout should match")
+		assert.Equal(t, want.TCPBufferSizes, options.tcpBufferSizes, "http.Client: TCPBufferSizes should match")
+		assert.Equal(t, want.WarmUpConns, options.warmUpConns, "http.Client: WarmUpConns should match")
+		assert.Equal(t, want.WarmUpTimeout, options.warmUpTimeout, "http.Client: WarmUpTimeout should match")
 		return buildHTTPClient(options)
 	})
 }
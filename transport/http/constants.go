@@ -92,6 +92,11 @@ const (
 	// https://github.com/grpc/grpc-go/blob/04ea82009cdb9ecdefc6289f4c93ec919a10b3b6/internal/transport/handler_server.go#L218
 	ErrorDetailsHeader = "Grpc-Status-Details-Bin"
 
+	// ErrorDetailsJSONHeader contains the Protobuf error details of an error,
+	// attached with yarpcerrors.WithDetails, as a JSON array of
+	// google.protobuf.Any objects.
+	ErrorDetailsJSONHeader = "Rpc-Error-Details"
+
 	// AcceptsBothResponseErrorHeader says that the BothResponseError
 	// feature is supported on the client. If the value is "true",
 	// this indicates true.
@@ -0,0 +1,81 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// strictContentType configures the Content-Type validation of an inbound.
+type strictContentType struct {
+	extraTypes []string
+}
+
+// handler wraps the YARPC handler with the validation of the Content-Type of
+// requests against the content types of the encodings of the procedures.
+func (s *strictContentType) handler(next http.Handler, procedures []transport.Procedure) http.Handler {
+	allowed := make(map[string]struct{}, len(procedures)+len(s.extraTypes))
+	for _, p := range procedures {
+		if contentType := getContentType(p.Encoding); contentType != "" {
+			allowed[contentType] = struct{}{}
+		}
+	}
+	for _, t := range s.extraTypes {
+		if mediaType, _, err := mime.ParseMediaType(t); err == nil {
+			allowed[mediaType] = struct{}{}
+		} else {
+			allowed[strings.ToLower(t)] = struct{}{}
+		}
+	}
+	return contentTypeHandler{next: next, allowed: allowed}
+}
+
+type contentTypeHandler struct {
+	next    http.Handler
+	allowed map[string]struct{}
+}
+
+func (h contentTypeHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if contentType := req.Header.Get("Content-Type"); contentType != "" && !h.isAllowed(contentType) {
+		code, _ := yarpcerrors.CodeInvalidArgument.MarshalText()
+		w.Header().Set(ErrorCodeHeader, string(code))
+		w.Header().Set("Content-Type", "text/plain; charset=utf8")
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		_, _ = fmt.Fprintf(w, "unsupported content type %q\n", contentType)
+		return
+	}
+	h.next.ServeHTTP(w, req)
+}
+
+func (h contentTypeHandler) isAllowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	_, ok := h.allowed[mediaType]
+	return ok
+}
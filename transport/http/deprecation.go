@@ -0,0 +1,102 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// Response headers of deprecated procedures, per RFC 8594.
+const (
+	_deprecationHeader = "Deprecation"
+	_sunsetHeader      = "Sunset"
+)
+
+// deprecations configures the procedures of an inbound that are deprecated.
+type deprecations struct {
+	sunsets     map[string]time.Time
+	logInterval time.Duration
+}
+
+// handler wraps the YARPC handler with the deprecation headers of
+// deprecated procedures.
+func (d *deprecations) handler(next http.Handler, logger *zap.Logger) http.Handler {
+	procedures := make(map[string]*deprecatedProcedure, len(d.sunsets))
+	for procedure, sunset := range d.sunsets {
+		procedures[procedure] = &deprecatedProcedure{
+			sunset: sunset.UTC().Format(http.TimeFormat),
+		}
+	}
+	return deprecationHandler{
+		next:        next,
+		procedures:  procedures,
+		logInterval: d.logInterval,
+		logger:      logger,
+		now:         time.Now,
+	}
+}
+
+type deprecatedProcedure struct {
+	sunset string
+
+	// nextLog is the time, in nanoseconds since the epoch, before which
+	// calls to the procedure are not logged.
+	nextLog atomic.Int64
+}
+
+type deprecationHandler struct {
+	next        http.Handler
+	procedures  map[string]*deprecatedProcedure
+	logInterval time.Duration
+	logger      *zap.Logger
+	now         func() time.Time
+}
+
+func (h deprecationHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	procedure := req.Header.Get(ProcedureHeader)
+	if p, ok := h.procedures[procedure]; ok {
+		w.Header().Set(_deprecationHeader, "true")
+		w.Header().Set(_sunsetHeader, p.sunset)
+		if h.logInterval > 0 {
+			h.log(p, procedure, req)
+		}
+	}
+	h.next.ServeHTTP(w, req)
+}
+
+// log logs a call to a deprecated procedure, unless a call to it was logged
+// within the log interval.
+func (h deprecationHandler) log(p *deprecatedProcedure, procedure string, req *http.Request) {
+	now := h.now().UnixNano()
+	next := p.nextLog.Load()
+	if now < next || !p.nextLog.CAS(next, now+int64(h.logInterval)) {
+		return
+	}
+	h.logger.Warn("deprecated procedure called",
+		zap.String("procedure", procedure),
+		zap.String("caller", req.Header.Get(CallerHeader)),
+		zap.String("sunset", p.sunset),
+	)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDeprecationLogInterval(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	sunset := time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)
+	d := deprecations{
+		sunsets:     map[string]time.Time{"old": sunset, "older": sunset},
+		logInterval: time.Minute,
+	}
+	h := d.handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), zap.New(core)).(deprecationHandler)
+	now := time.Unix(1000, 0)
+	h.now = func() time.Time { return now }
+
+	call := func(procedure string) {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.Header.Set(CallerHeader, "foo")
+		req.Header.Set(ProcedureHeader, procedure)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	call("old")
+	call("old")
+	call("older")
+	call("new")
+	assert.Equal(t, 2, logs.Len(), "expected one log for each deprecated procedure")
+
+	now = now.Add(59 * time.Second)
+	call("old")
+	assert.Equal(t, 2, logs.Len(), "expected no logs within the interval")
+
+	now = now.Add(time.Second)
+	call("old")
+	entries := logs.TakeAll()
+	if assert.Len(t, entries, 3, "expected a log after the interval") {
+		assert.Equal(t, "deprecated procedure called", entries[2].Message)
+		assert.Equal(t, map[string]interface{}{
+			"procedure": "old",
+			"caller":    "foo",
+			"sunset":    "Fri, 01 Jan 2027 00:00:00 GMT",
+		}, entries[2].ContextMap())
+	}
+}
+
+func TestDeprecationNoLogInterval(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	d := deprecations{sunsets: map[string]time.Time{"old": time.Now()}}
+	h := d.handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), zap.New(core))
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set(ProcedureHeader, "old")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+	assert.Equal(t, 0, logs.Len(), "expected no logs without a log interval")
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/internal/yarpctest"
+	"golang.org/x/net/http2"
+)
+
+// protoInterceptor answers requests to /proto with the protocol of the
+// request, and forwards all other requests to YARPC.
+func protoInterceptor(yarpcHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/proto" {
+			io.WriteString(w, r.Proto)
+			return
+		}
+		yarpcHandler.ServeHTTP(w, r)
+	})
+}
+
+// newH2CClient returns a client that speaks HTTP/2 with prior knowledge,
+// without TLS.
+func newH2CClient(t *testing.T) *http.Client {
+	rt := &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}
+	t.Cleanup(rt.CloseIdleConnections)
+	return &http.Client{Transport: rt}
+}
+
+func startH2CInbound(t *testing.T, router transport.Router, opts ...InboundOption) string {
+	httpTransport := NewTransport()
+	require.NoError(t, httpTransport.Start())
+	t.Cleanup(func() { assert.NoError(t, httpTransport.Stop()) })
+
+	i := httpTransport.NewInbound("127.0.0.1:0", append(opts, Interceptor(protoInterceptor))...)
+	i.SetRouter(router)
+	require.NoError(t, i.Start())
+	t.Cleanup(func() { assert.NoError(t, i.Stop()) })
+	return yarpctest.ZeroAddrToHostPort(i.Addr())
+}
+
+func getProto(t *testing.T, client *http.Client, addr string) (string, error) {
+	resp, err := client.Get(fmt.Sprintf("http://%s/proto", addr))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body), nil
+}
+
+func TestInboundH2CUpgrade(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	router := transporttest.NewMockRouter(mockCtrl)
+	router.EXPECT().Procedures()
+	addr := startH2CInbound(t, router, WithH2CUpgrade())
+
+	t.Run("HTTP/1.1 fallback", func(t *testing.T) {
+		rt := &http.Transport{}
+		defer rt.CloseIdleConnections()
+		proto, err := getProto(t, &http.Client{Transport: rt}, addr)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/1.1", proto)
+	})
+
+	t.Run("prior knowledge", func(t *testing.T) {
+		proto, err := getProto(t, newH2CClient(t), addr)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/2.0", proto)
+	})
+
+	t.Run("upgrade", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = io.WriteString(conn, "GET /proto HTTP/1.1\r\n"+
+			"Host: "+addr+"\r\n"+
+			"Connection: Upgrade, HTTP2-Settings\r\n"+
+			"Upgrade: h2c\r\n"+
+			"HTTP2-Settings: \r\n\r\n")
+		require.NoError(t, err)
+
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+		assert.Equal(t, "h2c", resp.Header.Get("Upgrade"))
+	})
+
+	t.Run("YARPC request", func(t *testing.T) {
+		h := transporttest.NewMockUnaryHandler(mockCtrl)
+		router.EXPECT().Choose(gomock.Any(), gomock.Any()).Return(transport.NewUnaryHandlerSpec(h), nil)
+		h.EXPECT().Handle(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, _ *transport.Request, resw transport.ResponseWriter) error {
+				_, err := resw.Write([]byte("hello over h2c"))
+				return err
+			})
+
+		req, err := http.NewRequest("POST", fmt.Sprintf("http://%s/", addr), bytes.NewBufferString("hello"))
+		require.NoError(t, err)
+		req.Header.Set(CallerHeader, "caller")
+		req.Header.Set(ServiceHeader, "service")
+		req.Header.Set(ProcedureHeader, "hello")
+		req.Header.Set(EncodingHeader, "raw")
+		req.Header.Set(TTLMSHeader, fmt.Sprint(testtime.Second.Milliseconds()))
+
+		resp, err := newH2CClient(t).Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		assert.Equal(t, 2, resp.ProtoMajor)
+		assert.Equal(t, "hello over h2c", string(body))
+	})
+}
+
+func TestInboundWithoutH2CUpgrade(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	router := transporttest.NewMockRouter(mockCtrl)
+	router.EXPECT().Procedures()
+	addr := startH2CInbound(t, router)
+
+	_, err := getProto(t, newH2CClient(t), addr)
+	assert.Error(t, err, "cleartext HTTP/2 must not be served by default")
+}
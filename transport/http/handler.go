@@ -23,6 +23,8 @@ package http
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -35,6 +37,7 @@ import (
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/bufferpool"
 	"go.uber.org/yarpc/internal/iopool"
+	intyarpcerrors "go.uber.org/yarpc/internal/yarpcerrors"
 	"go.uber.org/yarpc/pkg/errors"
 	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/zap"
@@ -53,6 +56,8 @@ type handler struct {
 	grabHeaders       map[string]struct{}
 	bothResponseError bool
 	logger            *zap.Logger
+	tlsMetrics        *tlsMetrics
+	w3cBaggage        bool
 }
 
 func (h handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -76,11 +81,16 @@ func (h handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if status.Name() != "" {
 		responseWriter.AddSystemHeader(ErrorNameHeader, status.Name())
 	}
+	if details, ok := intyarpcerrors.DetailsToJSON(status); ok {
+		responseWriter.AddSystemHeader(ErrorDetailsJSONHeader, details)
+	}
 	if bothResponseError && h.bothResponseError {
 		responseWriter.AddSystemHeader(BothResponseErrorHeader, AcceptTrue)
 		responseWriter.AddSystemHeader(ErrorMessageHeader, status.Message())
 		if details := status.Details(); details != nil {
-			responseWriter.AddSystemHeader(ErrorDetailsHeader, string(details))
+			// Binary header values are base64 encoded, like gRPC does. Clients
+			// read the details from the body.
+			responseWriter.AddSystemHeader(ErrorDetailsHeader, base64.StdEncoding.EncodeToString(details))
 			responseWriter.ResetBuffer()
 			_, _ = responseWriter.Write(details)
 		}
@@ -134,16 +144,30 @@ func (h handler) callHandler(responseWriter *responseWriter, req *http.Request,
 	}()
 
 	ctx := req.Context()
+	if req.TLS != nil {
+		ctx = transport.WithTLSConnectionState(ctx, req.TLS)
+	}
 	ctx, cancel, parseTTLErr := parseTTL(ctx, treq, popHeader(req.Header, TTLMSHeader))
 	// parseTTLErr != nil is a problem only if the request is unary.
 	defer cancel()
 	ctx, span := h.createSpan(ctx, req, treq, start)
 
+	var cipherSuite string
+	if h.tlsMetrics != nil && req.TLS != nil {
+		cipherSuite = tls.CipherSuiteName(req.TLS.CipherSuite)
+		span.SetTag(_cipherSuiteSpanTag, cipherSuite)
+	}
+
 	spec, err := h.router.Choose(ctx, treq)
 	if err != nil {
 		updateSpanWithErr(span, err)
 		return err
 	}
+	if cipherSuite != "" {
+		// Only count requests to known procedures, to bound the number of
+		// metrics.
+		h.tlsMetrics.observeRequest(treq.Procedure, cipherSuite)
+	}
 
 	if parseTTLErr != nil {
 		return parseTTLErr
@@ -165,7 +189,7 @@ func (h handler) callHandler(responseWriter *responseWriter, req *http.Request,
 		})
 
 	case transport.Oneway:
-		err = handleOnewayRequest(span, treq, spec.Oneway(), h.logger)
+		err = handleOnewayRequest(span, treq, req.TLS, spec.Oneway(), h.logger)
 
 	default:
 		err = yarpcerrors.Newf(yarpcerrors.CodeUnimplemented, "transport http does not handle %s handlers", spec.Type().String())
@@ -178,6 +202,7 @@ func (h handler) callHandler(responseWriter *responseWriter, req *http.Request,
 func handleOnewayRequest(
 	span opentracing.Span,
 	treq *transport.Request,
+	tlsState *tls.ConnectionState,
 	onewayHandler transport.OnewayHandler,
 	logger *zap.Logger,
 ) error {
@@ -192,6 +217,9 @@ func handleOnewayRequest(
 	// create a new context for oneway requests since the HTTP handler cancels
 	// http.Request's context when ServeHTTP returns
 	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	if tlsState != nil {
+		ctx = transport.WithTLSConnectionState(ctx, tlsState)
+	}
 
 	go func() {
 		// ensure the span lasts for length of the handler in case of errors
@@ -239,6 +267,9 @@ func (h handler) createSpan(ctx context.Context, req *http.Request, treq *transp
 		tags,
 	)
 	ext.PeerService.Set(span, treq.Caller)
+	if h.w3cBaggage {
+		extractW3CBaggage(req.Header, span)
+	}
 	ctx = opentracing.ContextWithSpan(ctx, span)
 	return ctx, span
 }
@@ -326,6 +357,10 @@ func getContentType(encoding transport.Encoding) string {
 		return "application/vnd.apache.thrift.binary"
 	case "proto":
 		return "application/x-protobuf"
+	case "cbor":
+		return "application/cbor"
+	case "flatbuffers":
+		return "application/x-flatbuffers"
 	default:
 		return ""
 	}
@@ -23,6 +23,7 @@ package http
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -153,6 +154,22 @@ func TestHandlerHeaders(t *testing.T) {
 			wantTTL:     time.Second,
 			wantHeaders: map[string]string{},
 		},
+		{
+			giveEncoding: "cbor",
+			giveHeaders: http.Header{
+				TTLMSHeader: {"1000"},
+			},
+			wantTTL:     time.Second,
+			wantHeaders: map[string]string{},
+		},
+		{
+			giveEncoding: "flatbuffers",
+			giveHeaders: http.Header{
+				TTLMSHeader: {"1000"},
+			},
+			wantTTL:     time.Second,
+			wantHeaders: map[string]string{},
+		},
 	}
 
 	for _, tt := range tests {
@@ -362,6 +379,44 @@ func TestHandlerInternalFailure(t *testing.T) {
 		httpResponse.Body.String())
 }
 
+func TestHandlerBinaryErrorDetails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	details := []byte{0x08, 0x03, 0x00, 0xff, '\r', '\n'}
+
+	headers := make(http.Header)
+	headers.Set(CallerHeader, "somecaller")
+	headers.Set(EncodingHeader, "raw")
+	headers.Set(TTLMSHeader, "1000")
+	headers.Set(ProcedureHeader, "hello")
+	headers.Set(ServiceHeader, "fake")
+	headers.Set(AcceptsBothResponseErrorHeader, AcceptTrue)
+
+	request := http.Request{
+		Method: "POST",
+		Header: headers,
+		Body:   ioutil.NopCloser(bytes.NewReader([]byte{})),
+	}
+
+	rpcHandler := transporttest.NewMockUnaryHandler(mockCtrl)
+	rpcHandler.EXPECT().Handle(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(yarpcerrors.Newf(yarpcerrors.CodeInvalidArgument, "great sadness").WithDetails(details))
+
+	router := transporttest.NewMockRouter(mockCtrl)
+	router.EXPECT().Choose(gomock.Any(), gomock.Any()).
+		Return(transport.NewUnaryHandlerSpec(rpcHandler), nil)
+
+	httpHandler := handler{router: router, tracer: &opentracing.NoopTracer{}, bothResponseError: true}
+	httpResponse := httptest.NewRecorder()
+	httpHandler.ServeHTTP(httpResponse, &request)
+
+	assert.Equal(t, http.StatusBadRequest, httpResponse.Code)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(details),
+		httpResponse.Header().Get(ErrorDetailsHeader), "details header must be base64 encoded")
+	assert.Equal(t, details, httpResponse.Body.Bytes(), "body must contain the raw details")
+}
+
 type panickedHandler struct{}
 
 func (th panickedHandler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
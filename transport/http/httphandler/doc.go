@@ -0,0 +1,44 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package httphandler provides an inbound that serves YARPC requests from
+// the ServeMux of an existing HTTP server, for services that embed YARPC
+// procedures alongside their own HTTP routes.
+//
+// The inbound registers its handler with the mux under a path prefix as it is
+// constructed, and does not listen on its own:
+//
+// 	mux := http.NewServeMux()
+// 	mux.HandleFunc("/health", health)
+// 	inbound := httphandler.NewInbound(mux, "/rpc/")
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:     "myservice",
+// 		Inbounds: yarpc.Inbounds{inbound},
+// 	})
+// 	...
+// 	go http.ListenAndServe(":8080", mux)
+//
+// Outbounds reach the procedures at the path prefix, like
+// http://host:8080/rpc/.
+//
+// Starting and stopping the inbound does nothing beyond logging a warning:
+// requests are served from when the dispatcher sets the router of the inbound
+// until the HTTP server of the mux stops.
+package httphandler
@@ -0,0 +1,169 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package httphandler
+
+import (
+	nethttp "net/http"
+	"sync"
+
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/zap"
+)
+
+// Option customizes an Inbound.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	transport *http.Transport
+	logger    *zap.Logger
+}
+
+// Transport sets the HTTP transport of the inbound, whose tracer and logger
+// serve its requests.
+//
+// Defaults to a new HTTP transport.
+func Transport(t *http.Transport) Option {
+	return optionFunc(func(o *options) {
+		o.transport = t
+	})
+}
+
+// Logger sets the logger for the inbound.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+var (
+	_ transport.Inbound                   = (*Inbound)(nil)
+	_ introspection.IntrospectableInbound = (*Inbound)(nil)
+)
+
+// Inbound serves YARPC requests from the HTTP server of a ServeMux, rather
+// than from a server of its own.
+type Inbound struct {
+	transport  *http.Transport
+	logger     *zap.Logger
+	pathPrefix string
+
+	lock    sync.RWMutex
+	handler nethttp.Handler
+}
+
+// NewInbound returns an inbound that registers a handler for YARPC requests
+// with the mux, under the path prefix, as a pattern of the mux. The handler
+// fails requests with Unavailable until the dispatcher sets the router of the
+// inbound.
+//
+// The inbound does not listen: requests are served by the HTTP server of the
+// mux, which the caller starts and stops.
+func NewInbound(mux *nethttp.ServeMux, pathPrefix string, opts ...Option) *Inbound {
+	var o options
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.transport == nil {
+		o.transport = http.NewTransport()
+	}
+	if o.logger == nil {
+		o.logger = zap.NewNop()
+	}
+
+	i := &Inbound{
+		transport:  o.transport,
+		logger:     o.logger,
+		pathPrefix: pathPrefix,
+	}
+	mux.Handle(pathPrefix, nethttp.HandlerFunc(i.serveHTTP))
+	return i
+}
+
+func (i *Inbound) serveHTTP(w nethttp.ResponseWriter, req *nethttp.Request) {
+	i.lock.RLock()
+	h := i.handler
+	i.lock.RUnlock()
+	if h == nil {
+		nethttp.Error(w, "YARPC inbound has no router", nethttp.StatusServiceUnavailable)
+		return
+	}
+	h.ServeHTTP(w, req)
+}
+
+// SetRouter implements transport.Inbound, serving requests with the
+// procedures of the router.
+func (i *Inbound) SetRouter(router transport.Router) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.handler = i.transport.Handler(router)
+}
+
+// Transports implements transport.Inbound.
+func (i *Inbound) Transports() []transport.Transport {
+	return []transport.Transport{i.transport}
+}
+
+// Start implements transport.Lifecycle. It does nothing, since the inbound
+// does not listen.
+func (i *Inbound) Start() error {
+	i.logger.Warn("HTTP handler inbound does not listen: "+
+		"its requests are served by the HTTP server of its mux",
+		zap.String("pathPrefix", i.pathPrefix))
+	return nil
+}
+
+// Stop implements transport.Lifecycle. It does nothing, since the inbound
+// does not listen.
+func (i *Inbound) Stop() error {
+	i.logger.Warn("HTTP handler inbound does not stop serving: "+
+		"its requests are served until the HTTP server of its mux stops",
+		zap.String("pathPrefix", i.pathPrefix))
+	return nil
+}
+
+// IsRunning implements transport.Lifecycle. The inbound runs once it has a
+// router.
+func (i *Inbound) IsRunning() bool {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.handler != nil
+}
+
+// Introspect returns the state of the inbound for introspection purposes.
+func (i *Inbound) Introspect() introspection.InboundStatus {
+	state := "Stopped"
+	if i.IsRunning() {
+		state = "Started"
+	}
+	return introspection.InboundStatus{
+		Transport: "http",
+		Endpoint:  i.pathPrefix,
+		State:     state,
+	}
+}
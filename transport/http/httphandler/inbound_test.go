@@ -0,0 +1,135 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package httphandler_test
+
+import (
+	"context"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/transport/http/httphandler"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type echoHandler struct{}
+
+func (echoHandler) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	_, err = resw.Write(body)
+	return err
+}
+
+func TestInbound(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+
+	mux := nethttp.NewServeMux()
+	mux.HandleFunc("/health", func(w nethttp.ResponseWriter, _ *nethttp.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	inbound := httphandler.NewInbound(mux, "/rpc/", httphandler.Logger(zap.New(core)))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	assert.False(t, inbound.IsRunning(), "inbound must not run without a router")
+	res, err := nethttp.Post(server.URL+"/rpc/", "application/octet-stream", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, nethttp.StatusServiceUnavailable, res.StatusCode)
+
+	serverDispatcher := yarpc.NewDispatcher(yarpc.Config{
+		Name:     "server",
+		Inbounds: yarpc.Inbounds{inbound},
+	})
+	serverDispatcher.Register([]transport.Procedure{
+		{
+			Name:        "echo",
+			HandlerSpec: transport.NewUnaryHandlerSpec(echoHandler{}),
+		},
+	})
+	require.NoError(t, serverDispatcher.Start())
+	assert.True(t, inbound.IsRunning(), "inbound must run with a router")
+	assert.Equal(t, "/rpc/", inbound.Introspect().Endpoint)
+
+	clientDispatcher := yarpc.NewDispatcher(yarpc.Config{
+		Name: "client",
+		Outbounds: yarpc.Outbounds{
+			"server": {
+				Unary: http.NewTransport().NewSingleOutbound(server.URL + "/rpc/"),
+			},
+		},
+	})
+	require.NoError(t, clientDispatcher.Start())
+	defer clientDispatcher.Stop()
+
+	client := raw.New(clientDispatcher.ClientConfig("server"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	body, err := client.Call(ctx, "echo", []byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+
+	_, err = client.Call(ctx, "unknown", []byte("hello"))
+	assert.Equal(t, yarpcerrors.CodeUnimplemented, yarpcerrors.FromError(err).Code())
+
+	res, err = nethttp.Get(server.URL + "/health")
+	require.NoError(t, err)
+	health, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(health), "routes of the mux must still be served")
+
+	require.NoError(t, serverDispatcher.Stop())
+
+	// The inbound keeps serving until the HTTP server of the mux stops.
+	body, err = client.Call(ctx, "echo", []byte("still here"))
+	require.NoError(t, err)
+	assert.Equal(t, "still here", string(body))
+
+	entries := logs.FilterMessageSnippet("HTTP handler inbound").AllUntimed()
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		assert.Equal(t, zap.WarnLevel, entry.Level)
+		assert.Equal(t, "/rpc/", entry.ContextMap()["pathPrefix"])
+	}
+}
+
+func TestInboundTransports(t *testing.T) {
+	trans := http.NewTransport()
+	inbound := httphandler.NewInbound(nethttp.NewServeMux(), "/", httphandler.Transport(trans))
+	assert.Equal(t, []transport.Transport{trans}, inbound.Transports())
+	assert.Equal(t, "Stopped", inbound.Introspect().State)
+}
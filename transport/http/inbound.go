@@ -26,6 +26,7 @@ import (
 	"errors"
 	"net"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -38,6 +39,8 @@ import (
 	"go.uber.org/yarpc/transport/internal/tls/muxlistener"
 	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // We want a value that's around 5 seconds, but slightly higher than how
@@ -124,6 +127,93 @@ func InboundTLSMode(mode yarpctls.Mode) InboundOption {
 	}
 }
 
+// WithTLSMetrics returns an InboundOption that records the TLS cipher suites
+// negotiated by clients, for auditing clients that still use deprecated
+// cipher suites.
+//
+// The IANA name of the cipher suite of every request received over TLS is
+// recorded as the "tls.cipher_suite" span tag and counted by procedure, and
+// the active TLS connections are counted by cipher suite, with the meter of
+// the transport.
+func WithTLSMetrics() InboundOption {
+	return func(i *Inbound) {
+		i.tlsMetrics = true
+	}
+}
+
+// WithH2CUpgrade returns an InboundOption that serves cleartext HTTP/2 (h2c)
+// in addition to HTTP/1.1, for clients behind load balancers that terminate
+// TLS to multiplex their requests over HTTP/2 without TLS.
+//
+// The inbound accepts both h2c upgrades of HTTP/1.1 requests and HTTP/2
+// connections with prior knowledge. Requests of clients that do not ask for
+// h2c are served over HTTP/1.1.
+func WithH2CUpgrade() InboundOption {
+	return func(i *Inbound) {
+		i.h2c = true
+	}
+}
+
+// WithStrictContentType returns an InboundOption that rejects requests with a
+// Content-Type other than the content types of the encodings of the
+// procedures registered when the inbound starts, like "application/json" for
+// JSON procedures, with a 415 Unsupported Media Type response, before they
+// reach a handler.
+//
+// Parameters of the media type, like the charset, are ignored. Requests
+// without a Content-Type are not rejected, since YARPC clients specify the
+// encoding of requests with the Rpc-Encoding header instead.
+func WithStrictContentType(opts ...StrictContentTypeOption) InboundOption {
+	return func(i *Inbound) {
+		i.strictContentType = &strictContentType{}
+		for _, opt := range opts {
+			opt(i.strictContentType)
+		}
+	}
+}
+
+// StrictContentTypeOption customizes the Content-Type validation of
+// WithStrictContentType.
+type StrictContentTypeOption func(*strictContentType)
+
+// WithExtraAllowedTypes allows requests with the given media types, for
+// services with handlers that accept several content types, like
+// "application/x-www-form-urlencoded" in addition to "application/json".
+func WithExtraAllowedTypes(types []string) StrictContentTypeOption {
+	return func(s *strictContentType) {
+		s.extraTypes = append(s.extraTypes, types...)
+	}
+}
+
+// WithDeprecation returns an InboundOption that marks the given procedure as
+// deprecated, adding the Deprecation and Sunset response headers of RFC 8594
+// to its responses, so that clients can tell that they should migrate before
+// the sunset date.
+//
+//	Deprecation: true
+//	Sunset: Fri, 01 Jan 2027 00:00:00 GMT
+//
+// Deprecate several procedures with separate WithDeprecation options.
+func WithDeprecation(procedure string, sunset time.Time) InboundOption {
+	return func(i *Inbound) {
+		if i.deprecations.sunsets == nil {
+			i.deprecations.sunsets = make(map[string]time.Time)
+		}
+		i.deprecations.sunsets[procedure] = sunset
+	}
+}
+
+// WithDeprecationLogInterval returns an InboundOption that logs a warning
+// when a procedure deprecated with WithDeprecation is called, at most once
+// per interval for each procedure.
+//
+// Calls to deprecated procedures are not logged by default.
+func WithDeprecationLogInterval(d time.Duration) InboundOption {
+	return func(i *Inbound) {
+		i.deprecations.logInterval = d
+	}
+}
+
 // NewInbound builds a new HTTP inbound that listens on the given address and
 // sharing this transport.
 func (t *Transport) NewInbound(addr string, opts ...InboundOption) *Inbound {
@@ -143,6 +233,19 @@ func (t *Transport) NewInbound(addr string, opts ...InboundOption) *Inbound {
 	return i
 }
 
+// Handler returns an http.Handler that serves YARPC requests with the
+// procedures of the router, like the server of an inbound does, for HTTP
+// servers outside YARPC.
+func (t *Transport) Handler(router transport.Router) http.Handler {
+	return handler{
+		router:            router,
+		tracer:            t.tracer,
+		grabHeaders:       make(map[string]struct{}),
+		bothResponseError: true,
+		logger:            t.logger,
+	}
+}
+
 // Inbound receives YARPC requests using an HTTP server. It may be constructed
 // using the NewInbound method on the Transport.
 type Inbound struct {
@@ -163,8 +266,20 @@ type Inbound struct {
 	// should only be false in testing
 	bothResponseError bool
 
-	tlsConfig *tls.Config
-	tlsMode   yarpctls.Mode
+	tlsConfig  *tls.Config
+	tlsMode    yarpctls.Mode
+	tlsMetrics bool
+
+	h2c        bool
+	w3cBaggage bool
+
+	strictContentType *strictContentType
+	deprecations      deprecations
+
+	webSocketHandlers map[string]WebSocketHandler
+	webSockets        *webSocketUpgrader
+
+	pprof pprofConfig
 }
 
 // Tracer configures a tracer on this inbound.
@@ -201,12 +316,30 @@ func (i *Inbound) start() error {
 		}
 	}
 
+	var tlsMetrics *tlsMetrics
+	if i.tlsMetrics {
+		tlsMetrics = newTLSMetrics(i.transport.meter, i.logger, i.transport.serviceName)
+	}
+
 	var httpHandler http.Handler = handler{
 		router:            i.router,
 		tracer:            i.tracer,
 		grabHeaders:       i.grabHeaders,
 		bothResponseError: i.bothResponseError,
 		logger:            i.logger,
+		tlsMetrics:        tlsMetrics,
+		w3cBaggage:        i.w3cBaggage,
+	}
+
+	if len(i.deprecations.sunsets) > 0 {
+		httpHandler = i.deprecations.handler(httpHandler, i.logger)
+	}
+	if i.strictContentType != nil {
+		httpHandler = i.strictContentType.handler(httpHandler, i.router.Procedures())
+	}
+	if len(i.webSocketHandlers) > 0 {
+		i.webSockets = newWebSocketUpgrader(httpHandler, i)
+		httpHandler = i.webSockets
 	}
 
 	// reverse iterating because we want the last from options to wrap the
@@ -220,18 +353,46 @@ func (i *Inbound) start() error {
 		i.mux.Handle(i.muxPattern, httpHandler)
 		httpHandler = i.mux
 	}
+	if i.pprof.path != "" {
+		var err error
+		if httpHandler, err = i.pprof.handler(httpHandler); err != nil {
+			return err
+		}
+	}
+
+	var h2s *http2.Server
+	if i.h2c {
+		h2s = &http2.Server{}
+		httpHandler = h2c.NewHandler(httpHandler, h2s)
+	}
 
-	i.server = intnet.NewHTTPServer(&http.Server{
+	server := &http.Server{
 		Addr:    i.addr,
 		Handler: httpHandler,
-	})
+	}
+	if h2s != nil {
+		// HTTP/2 connections are hijacked from the server, so only an
+		// HTTP/2 server configured with it closes them gracefully on
+		// shutdown.
+		if err := http2.ConfigureServer(server, h2s); err != nil {
+			return err
+		}
+	}
+	if tlsMetrics != nil {
+		server.ConnState = tlsMetrics.connState
+	}
+	i.server = intnet.NewHTTPServer(server)
 
 	addr := i.addr
 	if addr == "" {
 		addr = ":http"
 	}
 
-	listener, err := net.Listen("tcp", addr)
+	lc := net.ListenConfig{}
+	if !i.transport.tcpBufferSizes.IsZero() {
+		lc.Control = i.transport.tcpBufferSizes.Control(i.logger)
+	}
+	listener, err := lc.Listen(context.Background(), "tcp", addr)
 	if err != nil {
 		return err
 	}
@@ -269,18 +430,41 @@ func (i *Inbound) Stop() error {
 	ctx, cancel := context.WithTimeout(context.Background(), i.shutdownTimeout)
 	defer cancel()
 
-	return i.shutdown(ctx)
+	return i.shutdown(ctx, false /* force */)
+}
+
+// GracefulStop stops the inbound like Stop, but waits for pending calls
+// until the context is done instead of the shutdown timeout, and then closes
+// the connections that remain.
+func (i *Inbound) GracefulStop(ctx context.Context) error {
+	return i.shutdown(ctx, true /* force */)
+}
+
+// TransportName is the name of the transport of the inbound.
+func (i *Inbound) TransportName() string {
+	return TransportName
 }
 
 // shutdown the inbound, closing the listening socket, closing idle
-// connections, and waiting for all pending calls to complete.
-func (i *Inbound) shutdown(ctx context.Context) error {
+// connections, and waiting for all pending calls to complete. If force is
+// set, the connections of the calls still pending when the context is done
+// are closed.
+func (i *Inbound) shutdown(ctx context.Context, force bool) error {
 	return i.once.Stop(func() error {
 		if i.server == nil {
 			return nil
 		}
 
-		return i.server.Shutdown(ctx)
+		err := i.server.Shutdown(ctx)
+		if force && ctx.Err() != nil {
+			i.server.Close()
+		}
+		if i.webSockets != nil {
+			// The server does not track the connections it hands off to
+			// WebSocket handlers.
+			i.webSockets.closeAll()
+		}
+		return err
 	})
 }
 
@@ -314,9 +498,27 @@ func (i *Inbound) Introspect() introspection.InboundStatus {
 	if addr := i.Addr(); addr != nil {
 		addrString = addr.String()
 	}
-	return introspection.InboundStatus{
+	grabHeaders := make([]string, 0, len(i.grabHeaders))
+	for h := range i.grabHeaders {
+		grabHeaders = append(grabHeaders, h)
+	}
+	sort.Strings(grabHeaders)
+	status := introspection.InboundStatus{
 		Transport: "http",
 		Endpoint:  addrString,
 		State:     state,
+		Config: map[string]interface{}{
+			"address":         i.addr,
+			"grabHeaders":     grabHeaders,
+			"shutdownTimeout": i.shutdownTimeout.String(),
+			"tls":             map[string]interface{}{"mode": strings.ToLower(i.tlsMode.String())},
+		},
+	}
+	if i.pprof.path != "" {
+		status.Config["pprof"] = map[string]interface{}{
+			"path":         i.pprof.path,
+			"allowedCIDRs": i.pprof.allowedCIDRs,
+		}
 	}
+	return status
 }
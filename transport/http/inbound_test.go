@@ -31,6 +31,7 @@ import (
 	"os"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -92,6 +93,46 @@ func TestInboundStartAndStop(t *testing.T) {
 	assert.NoError(t, i.Stop())
 }
 
+func TestInboundGracefulStop(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	i := NewTransport().NewInbound("127.0.0.1:0", Mux("/", mux))
+	i.SetRouter(newTestRouter(nil))
+	require.NoError(t, i.Start())
+	assert.Equal(t, TransportName, i.TransportName())
+
+	callErr := make(chan error, 1)
+	go func() {
+		res, err := http.Get(fmt.Sprintf("http://%v/slow", i.Addr()))
+		if err == nil {
+			res.Body.Close()
+		}
+		callErr <- err
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, i.GracefulStop(ctx))
+	assert.False(t, i.IsRunning())
+	select {
+	case err := <-callErr:
+		assert.Error(t, err, "calls still pending after the context is done must fail")
+	case <-time.After(testtime.Second):
+		t.Fatal("connections of pending calls were not closed")
+	}
+}
+
 func TestInboundStartError(t *testing.T) {
 	x := NewTransport()
 	i := x.NewInbound("invalid")
@@ -357,6 +398,143 @@ func TestMultipleInterceptors(t *testing.T) {
 	}
 }
 
+func TestStrictContentType(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	httpTransport := NewTransport()
+	defer httpTransport.Stop()
+
+	i := httpTransport.NewInbound("127.0.0.1:0", WithStrictContentType(
+		WithExtraAllowedTypes([]string{"application/x-www-form-urlencoded"}),
+	))
+	h := transporttest.NewMockUnaryHandler(mockCtrl)
+	h.EXPECT().Handle(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	reg := transporttest.NewMockRouter(mockCtrl)
+	reg.EXPECT().Procedures().AnyTimes().Return([]transport.Procedure{
+		{Name: "hello", Service: "bar", Encoding: "json"},
+	})
+	reg.EXPECT().Choose(gomock.Any(), gomock.Any()).AnyTimes().Return(transport.NewUnaryHandlerSpec(h), nil)
+	i.SetRouter(reg)
+	require.NoError(t, i.Start())
+	defer i.Stop()
+
+	addr := fmt.Sprintf("http://%v/", yarpctest.ZeroAddrToHostPort(i.Addr()))
+
+	tests := []struct {
+		contentType string
+		wantStatus  int
+	}{
+		{contentType: "", wantStatus: http.StatusOK},
+		{contentType: "application/json", wantStatus: http.StatusOK},
+		{contentType: "Application/JSON; charset=utf-8", wantStatus: http.StatusOK},
+		{contentType: "application/x-www-form-urlencoded", wantStatus: http.StatusOK},
+		{contentType: "application/xml", wantStatus: http.StatusUnsupportedMediaType},
+		{contentType: "application/x-protobuf", wantStatus: http.StatusUnsupportedMediaType},
+		{contentType: "not a media type", wantStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			req, err := http.NewRequest("POST", addr, bytes.NewReader([]byte("{}")))
+			require.NoError(t, err)
+			req.Header.Set(CallerHeader, "foo")
+			req.Header.Set(ServiceHeader, "bar")
+			req.Header.Set(ProcedureHeader, "hello")
+			req.Header.Set(EncodingHeader, "json")
+			req.Header.Set(TTLMSHeader, "1000")
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+
+			if tt.wantStatus == http.StatusUnsupportedMediaType {
+				assert.Equal(t, "invalid-argument", res.Header.Get(ErrorCodeHeader))
+				body, err := ioutil.ReadAll(res.Body)
+				require.NoError(t, err)
+				assert.Contains(t, string(body), "unsupported content type")
+			}
+		})
+	}
+}
+
+func TestDeprecation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	httpTransport := NewTransport()
+	defer httpTransport.Stop()
+
+	sunset := time.Date(2027, time.January, 1, 0, 0, 0, 0, time.FixedZone("PST", -8*60*60))
+	i := httpTransport.NewInbound("127.0.0.1:0",
+		WithDeprecation("old", sunset),
+		WithDeprecation("older", sunset.AddDate(0, -6, 0)),
+	)
+	h := transporttest.NewMockUnaryHandler(mockCtrl)
+	h.EXPECT().Handle(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	reg := transporttest.NewMockRouter(mockCtrl)
+	reg.EXPECT().Procedures().AnyTimes()
+	reg.EXPECT().Choose(gomock.Any(), routertest.NewMatcher().WithProcedure("unknown")).AnyTimes().Return(
+		transport.HandlerSpec{}, yarpcerrors.UnimplementedErrorf("unknown procedure"))
+	reg.EXPECT().Choose(gomock.Any(), gomock.Any()).AnyTimes().Return(transport.NewUnaryHandlerSpec(h), nil)
+	i.SetRouter(reg)
+	require.NoError(t, i.Start())
+	defer i.Stop()
+
+	addr := fmt.Sprintf("http://%v/", yarpctest.ZeroAddrToHostPort(i.Addr()))
+
+	tests := []struct {
+		procedure       string
+		wantStatus      int
+		wantDeprecation string
+		wantSunset      string
+	}{
+		{
+			procedure:       "old",
+			wantStatus:      http.StatusOK,
+			wantDeprecation: "true",
+			wantSunset:      "Fri, 01 Jan 2027 08:00:00 GMT",
+		},
+		{
+			procedure:       "older",
+			wantStatus:      http.StatusOK,
+			wantDeprecation: "true",
+			wantSunset:      "Wed, 01 Jul 2026 08:00:00 GMT",
+		},
+		{
+			procedure:  "new",
+			wantStatus: http.StatusOK,
+		},
+		{
+			procedure:  "unknown",
+			wantStatus: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.procedure, func(t *testing.T) {
+			req, err := http.NewRequest("POST", addr, bytes.NewReader([]byte("{}")))
+			require.NoError(t, err)
+			req.Header.Set(CallerHeader, "foo")
+			req.Header.Set(ServiceHeader, "bar")
+			req.Header.Set(ProcedureHeader, tt.procedure)
+			req.Header.Set(EncodingHeader, "raw")
+			req.Header.Set(TTLMSHeader, "1000")
+
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			assert.Equal(t, tt.wantDeprecation, res.Header.Get("Deprecation"))
+			assert.Equal(t, tt.wantSunset, res.Header.Get("Sunset"))
+		})
+	}
+}
+
 func TestRequestAfterStop(t *testing.T) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
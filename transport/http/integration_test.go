@@ -29,14 +29,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
 	"go.uber.org/multierr"
+	"go.uber.org/net/metrics"
 	"go.uber.org/yarpc/api/transport"
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
 	"go.uber.org/yarpc/encoding/json"
 	"go.uber.org/yarpc/internal/clientconfig"
+	"go.uber.org/yarpc/internal/dnstest"
 	pkgerrors "go.uber.org/yarpc/pkg/errors"
 	"go.uber.org/yarpc/transport/internal/tls/testscenario"
 )
@@ -74,8 +78,13 @@ func TestInboundTLS(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.desc, func(t *testing.T) {
+			var hasTLSState bool
+			handler := func(ctx context.Context, request *testFooRequest) (*testFooResponse, error) {
+				hasTLSState = transport.TLSConnectionStateFromContext(ctx) != nil
+				return testFooHandler(ctx, request)
+			}
 			doWithTestEnv(t, testEnvOptions{
-				Procedures:       json.Procedure("testFoo", testFooHandler),
+				Procedures:       json.Procedure("testFoo", handler),
 				InboundOptions:   tt.inboundOptions,
 				TransportOptions: tt.transportOptions,
 			}, func(t *testing.T, testEnv *testEnv) {
@@ -87,11 +96,72 @@ func TestInboundTLS(t *testing.T) {
 				err := client.Call(ctx, "testFoo", &testFooRequest{One: "one"}, &response)
 				require.Nil(t, err)
 				assert.Equal(t, testFooResponse{One: "one"}, response)
+				assert.Equal(t, tt.isTLSClient, hasTLSState, "unexpected TLS connection state in handler context")
 			})
 		})
 	}
 }
 
+func TestInboundTLSMetrics(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	scenario := testscenario.Create(t, time.Minute, time.Minute)
+	clientTLSConfig := scenario.ClientTLSConfig()
+	// Pin the cipher suite, which TLS 1.3 does not allow configuring.
+	clientTLSConfig.MaxVersion = tls.VersionTLS12
+	clientTLSConfig.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+
+	root := metrics.New()
+	tracer := mocktracer.New()
+	testEnv, err := newTestEnv(testEnvOptions{
+		Procedures: json.Procedure("testFoo", testFooHandler),
+		TransportOptions: []TransportOption{
+			Meter(root.Scope()),
+			ServiceName("example"),
+			Tracer(tracer),
+		},
+		InboundOptions: []InboundOption{
+			InboundTLSConfiguration(scenario.ServerTLSConfig()),
+			InboundTLSMode(yarpctls.Enforced),
+			WithTLSMetrics(),
+		},
+		OutboundOptions: []OutboundOption{OutboundTLSConfiguration(clientTLSConfig)},
+	})
+	require.NoError(t, err)
+
+	client := json.New(testEnv.ClientConfig)
+	var response testFooResponse
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, client.Call(ctx, "testFoo", &testFooRequest{One: "one"}, &response))
+
+	const cipherSuite = "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"
+	var serverSpans []*mocktracer.MockSpan
+	for _, span := range tracer.FinishedSpans() {
+		if span.Tag("span.kind") == ext.SpanKindRPCServerEnum {
+			serverSpans = append(serverSpans, span)
+		}
+	}
+	require.Len(t, serverSpans, 1)
+	assert.Equal(t, cipherSuite, serverSpans[0].Tag("tls.cipher_suite"))
+
+	tags := metrics.Tags{
+		"component":    "yarpc",
+		"service":      "example",
+		"transport":    TransportName,
+		"cipher_suite": cipherSuite,
+	}
+	requestTags := metrics.Tags{"procedure": "testFoo"}
+	for k, v := range tags {
+		requestTags[k] = v
+	}
+	snap := root.Snapshot()
+	assert.Contains(t, snap.Counters, metrics.Snapshot{Name: "tls_requests", Tags: requestTags, Value: 1})
+	assert.Contains(t, snap.Gauges, metrics.Snapshot{Name: "tls_active_connections", Tags: tags, Value: 1})
+
+	assert.NoError(t, testEnv.Close())
+}
+
 func TestOutboundTLS(t *testing.T) {
 	defer goleak.VerifyNone(t)
 
@@ -139,6 +209,50 @@ func TestOutboundTLS(t *testing.T) {
 	}
 }
 
+func TestCustomResolver(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	scenario := testscenario.Create(t, time.Minute, time.Minute)
+	tests := []struct {
+		desc            string
+		inboundOptions  []InboundOption
+		outboundOptions []OutboundOption
+	}{
+		{desc: "plaintext"},
+		{
+			desc: "tls",
+			inboundOptions: []InboundOption{
+				InboundTLSConfiguration(scenario.ServerTLSConfig()),
+				InboundTLSMode(yarpctls.Enforced),
+			},
+			outboundOptions: []OutboundOption{OutboundTLSConfiguration(scenario.ClientTLSConfig())},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			doWithTestEnv(t, testEnvOptions{
+				Procedures:      json.Procedure("testFoo", testFooHandler),
+				InboundOptions:  tt.inboundOptions,
+				OutboundOptions: tt.outboundOptions,
+				TransportOptions: []TransportOption{
+					WithCustomResolver(dnstest.NewResolver(map[string]string{"example.com": "127.0.0.1"})),
+					KeepAlive(time.Minute),
+				},
+				OutboundHost: "example.com",
+			}, func(t *testing.T, testEnv *testEnv) {
+				client := json.New(testEnv.ClientConfig)
+				var response testFooResponse
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+
+				err := client.Call(ctx, "testFoo", &testFooRequest{One: "one"}, &response)
+				require.NoError(t, err)
+				assert.Equal(t, testFooResponse{One: "one"}, response)
+			})
+		})
+	}
+}
+
 func TestBothResponseError(t *testing.T) {
 	tests := []struct {
 		inboundBothResponseError  bool
@@ -233,6 +347,9 @@ type testEnvOptions struct {
 	TransportOptions []TransportOption
 	InboundOptions   []InboundOption
 	OutboundOptions  []OutboundOption
+	// OutboundHost replaces the IP address of the inbound in the URL of the
+	// outbound, if set.
+	OutboundHost string
 }
 
 func newTestEnv(options testEnvOptions) (_ *testEnv, err error) {
@@ -257,7 +374,15 @@ func newTestEnv(options testEnvOptions) (_ *testEnv, err error) {
 		}
 	}()
 
-	outbound := t.NewSingleOutbound(fmt.Sprintf("http://%s", inbound.Addr().String()), options.OutboundOptions...)
+	addr := inbound.Addr().String()
+	if options.OutboundHost != "" {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addr = net.JoinHostPort(options.OutboundHost, port)
+	}
+	outbound := t.NewSingleOutbound(fmt.Sprintf("http://%s", addr), options.OutboundOptions...)
 	if err := outbound.Start(); err != nil {
 		return nil, err
 	}
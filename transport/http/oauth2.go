@@ -0,0 +1,110 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/yarpcerrors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// _oauth2ExpiryBuffer is how long before its expiry a cached OAuth2 token is
+// refreshed, so that tokens do not expire while requests are in flight.
+const _oauth2ExpiryBuffer = 30 * time.Second
+
+// WithOAuth2ClientCredentials authenticates outgoing requests with an OAuth2
+// access token obtained with the client credentials flow.
+//
+//	httpTransport.NewOutbound(chooser, http.WithOAuth2ClientCredentials(
+//		"https://auth.example.com/oauth2/token", "client-id", "client-secret",
+//		[]string{"read"},
+//	))
+//
+// The outbound fetches a token from the token URL before its first request,
+// caches it, and refreshes it 30 seconds before it expires. If the outbound
+// cannot obtain a token, the request fails with an Unauthenticated error.
+func WithOAuth2ClientCredentials(tokenURL, clientID, clientSecret string, scopes []string) OutboundOption {
+	return func(o *Outbound) {
+		o.oauth2Config = &clientcredentials.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			TokenURL:     tokenURL,
+			Scopes:       scopes,
+		}
+		o.tokenSource = newCachedTokenSource(o.oauth2Config)
+	}
+}
+
+// tokenFetcher fetches new OAuth2 tokens.
+type tokenFetcher interface {
+	Token(ctx context.Context) (*oauth2.Token, error)
+}
+
+// cachedTokenSource is a thread-safe cache for OAuth2 tokens.
+//
+// Unlike oauth2.ReuseTokenSource, it fetches tokens with the context of the
+// request that needs one, so fetching a token respects the request deadline.
+type cachedTokenSource struct {
+	fetcher tokenFetcher
+	now     func() time.Time
+
+	lock  sync.Mutex
+	token *oauth2.Token
+}
+
+func newCachedTokenSource(fetcher tokenFetcher) *cachedTokenSource {
+	return &cachedTokenSource{
+		fetcher: fetcher,
+		now:     time.Now,
+	}
+}
+
+// Token returns the cached token if it is valid, or fetches a new token.
+func (s *cachedTokenSource) Token(ctx context.Context) (*oauth2.Token, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.valid(s.token) {
+		return s.token, nil
+	}
+
+	token, err := s.fetcher.Token(ctx)
+	if err != nil {
+		return nil, yarpcerrors.UnauthenticatedErrorf("failed to obtain OAuth2 token: %v", err)
+	}
+	s.token = token
+	return token, nil
+}
+
+// valid returns whether the token can be used until well after now.
+func (s *cachedTokenSource) valid(token *oauth2.Token) bool {
+	if token == nil || token.AccessToken == "" {
+		return false
+	}
+	if token.Expiry.IsZero() {
+		return true
+	}
+	return s.now().Add(_oauth2ExpiryBuffer).Before(token.Expiry)
+}
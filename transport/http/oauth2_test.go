@@ -0,0 +1,190 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+	"golang.org/x/oauth2"
+)
+
+// newTokenServer returns a fake OAuth2 token endpoint that issues a new
+// access token for every request, valid for the given number of seconds.
+func newTokenServer(t *testing.T, expiresIn int, fetches *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		require.NoError(t, req.ParseForm())
+		assert.Equal(t, "client_credentials", req.Form.Get("grant_type"))
+		assert.Equal(t, "read write", req.Form.Get("scope"))
+
+		id, secret, ok := req.BasicAuth()
+		if !ok || id != "client-id" || secret != "client-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		n := fetches.Inc()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": %d}`, n, expiresIn)
+	}))
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	tests := []struct {
+		desc         string
+		expiresIn    int
+		clientSecret string
+		wantTokens   []string
+		wantFetches  int32
+		wantErr      bool
+	}{
+		{
+			desc:         "token is cached",
+			expiresIn:    3600,
+			clientSecret: "client-secret",
+			wantTokens:   []string{"Bearer token-1", "Bearer token-1", "Bearer token-1"},
+			wantFetches:  1,
+		},
+		{
+			desc:         "token expiring within the buffer is refreshed",
+			expiresIn:    20,
+			clientSecret: "client-secret",
+			wantTokens:   []string{"Bearer token-1", "Bearer token-2", "Bearer token-3"},
+			wantFetches:  3,
+		},
+		{
+			desc:         "token refresh fails",
+			expiresIn:    3600,
+			clientSecret: "wrong-secret",
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var fetches atomic.Int32
+			tokenServer := newTokenServer(t, tt.expiresIn, &fetches)
+			defer tokenServer.Close()
+
+			var gotTokens []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				gotTokens = append(gotTokens, req.Header.Get("Authorization"))
+			}))
+			defer server.Close()
+
+			httpTransport := NewTransport()
+			defer httpTransport.Stop()
+			out := httpTransport.NewSingleOutbound(server.URL, WithOAuth2ClientCredentials(
+				tokenServer.URL, "client-id", tt.clientSecret, []string{"read", "write"}))
+			require.NoError(t, out.Start(), "failed to start outbound")
+			defer out.Stop()
+
+			for i := 0; i < 3; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+				res, err := out.Call(ctx, &transport.Request{
+					Caller:    "caller",
+					Service:   "service",
+					Encoding:  raw.Encoding,
+					Procedure: "hello",
+					Body:      bytes.NewReader([]byte("world")),
+				})
+				cancel()
+				if tt.wantErr {
+					require.Error(t, err)
+					assert.Equal(t, yarpcerrors.CodeUnauthenticated, yarpcerrors.FromError(err).Code())
+					continue
+				}
+				require.NoError(t, err)
+				require.NoError(t, res.Body.Close())
+			}
+
+			assert.Equal(t, tt.wantTokens, gotTokens)
+			if !tt.wantErr {
+				assert.Equal(t, tt.wantFetches, fetches.Load())
+			}
+		})
+	}
+}
+
+type fakeTokenFetcher struct {
+	fetches atomic.Int32
+	expiry  time.Time
+}
+
+func (f *fakeTokenFetcher) Token(context.Context) (*oauth2.Token, error) {
+	n := f.fetches.Inc()
+	return &oauth2.Token{AccessToken: fmt.Sprintf("token-%d", n), Expiry: f.expiry}, nil
+}
+
+func TestCachedTokenSourceExpiry(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	fetcher := &fakeTokenFetcher{expiry: now.Add(time.Minute)}
+	source := newCachedTokenSource(fetcher)
+	source.now = func() time.Time { return now }
+
+	token, err := source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token.AccessToken)
+
+	// Still valid for more than the buffer.
+	now = now.Add(29 * time.Second)
+	token, err = source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token.AccessToken)
+
+	// Expires within the buffer.
+	now = now.Add(time.Second)
+	fetcher.expiry = now.Add(time.Hour)
+	token, err = source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token.AccessToken)
+}
+
+func TestCachedTokenSourceConcurrency(t *testing.T) {
+	fetcher := &fakeTokenFetcher{expiry: time.Now().Add(time.Hour)}
+	source := newCachedTokenSource(fetcher)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := source.Token(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "token-1", token.AccessToken)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), fetcher.fetches.Load(), "concurrent requests must share one token")
+}
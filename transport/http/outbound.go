@@ -24,6 +24,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -39,12 +40,14 @@ import (
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/internal/calldepth"
 	intyarpcerrors "go.uber.org/yarpc/internal/yarpcerrors"
 	peerchooser "go.uber.org/yarpc/peer"
 	"go.uber.org/yarpc/peer/hostport"
 	"go.uber.org/yarpc/pkg/lifecycle"
 	"go.uber.org/yarpc/transport/internal/tls/dialer"
 	"go.uber.org/yarpc/yarpcerrors"
+	"golang.org/x/oauth2/clientcredentials"
 )
 
 // this ensures the HTTP outbound implements both transport.Outbound interfaces
@@ -110,6 +113,22 @@ func OutboundDestinationServiceName(name string) OutboundOption {
 	}
 }
 
+// WithStreamingBody specifies that an HTTP outbound should stream request
+// bodies to peers as it reads them, rather than sending bodies of a known
+// size, for large payloads like file uploads.
+//
+//	httpTransport.NewOutbound(chooser, http.WithStreamingBody())
+//
+// Requests are sent with chunked transfer encoding and without a
+// Content-Length header. Because the body cannot be read again, this option
+// disables the low-level retries net/http makes when a reused connection is
+// reset before the request is written: such requests fail instead.
+func WithStreamingBody() OutboundOption {
+	return func(o *Outbound) {
+		o.streamingBody = true
+	}
+}
+
 // NewOutbound builds an HTTP outbound that sends requests to peers supplied
 // by the given peer.Chooser. The URL template for used for the different
 // peers may be customized using the URLTemplate option.
@@ -219,6 +238,10 @@ type Outbound struct {
 	destServiceName   string
 	client            *http.Client
 	tlsConfig         *tls.Config
+	tokenSource       *cachedTokenSource
+	oauth2Config      *clientcredentials.Config
+	streamingBody     bool
+	w3cBaggage        bool
 }
 
 // TransportName is the transport name that will be set on `transport.Request` struct.
@@ -247,6 +270,15 @@ func (o *Outbound) Chooser() peer.Chooser {
 	return o.chooser
 }
 
+// SubscribePeerStatus subscribes to the peer status changes of the outbound's
+// peer chooser, if the chooser supports it.
+func (o *Outbound) SubscribePeerStatus(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func(), ok bool) {
+	if w, ok := o.chooser.(peer.StatusWatcher); ok {
+		return w.Subscribe(fn), true
+	}
+	return nil, false
+}
+
 // Start the HTTP outbound
 func (o *Outbound) Start() error {
 	return o.once.Start(o.chooser.Start)
@@ -303,6 +335,13 @@ func (o *Outbound) call(ctx context.Context, treq *transport.Request) (*transpor
 	if err != nil {
 		return nil, err
 	}
+	if o.tokenSource != nil {
+		token, err := o.tokenSource.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		token.SetAuthHeader(hreq)
+	}
 	ctx, hreq, span, err := o.withOpentracingSpan(ctx, hreq, treq, start)
 	if err != nil {
 		return nil, err
@@ -310,6 +349,9 @@ func (o *Outbound) call(ctx context.Context, treq *transport.Request) (*transpor
 	defer span.Finish()
 
 	hreq = o.withCoreHeaders(hreq, treq, ttl)
+	if depth, ok := calldepth.HeaderValue(ctx); ok {
+		hreq.Header.Set(ApplicationHeaderPrefix+calldepth.Header, depth)
+	}
 	hreq = hreq.WithContext(ctx)
 
 	response, err := o.roundTrip(hreq, treq, start, o.client)
@@ -389,10 +431,20 @@ func (o *Outbound) getPeerForRequest(ctx context.Context, treq *transport.Reques
 
 func (o *Outbound) createRequest(treq *transport.Request) (*http.Request, error) {
 	newURL := *o.urlTemplate
-	hreq, err := http.NewRequest("POST", newURL.String(), treq.Body)
+	body := treq.Body
+	if o.streamingBody && body != nil {
+		// Hide the type of the body so that net/http does not infer its
+		// length and how to read it again from buffers like bytes.Reader.
+		body = struct{ io.Reader }{body}
+	}
+	hreq, err := http.NewRequest("POST", newURL.String(), body)
 	if err != nil {
 		return nil, err
 	}
+	if o.streamingBody && body != nil {
+		// An unknown length makes net/http use chunked transfer encoding.
+		hreq.ContentLength = -1
+	}
 	// YARPC needs to remove all the HTTP/2 pseudo headers when a HTTP/2 request (gRPC)
 	// was propagated from a YARPC transport middleware to a HTTP/1 service.
 	// It should be noted that net/http will return an error if a pseudo
@@ -435,6 +487,9 @@ func (o *Outbound) withOpentracingSpan(ctx context.Context, req *http.Request, t
 		opentracing.HTTPHeaders,
 		opentracing.HTTPHeadersCarrier(req.Header),
 	)
+	if o.w3cBaggage {
+		injectW3CBaggage(span.Context(), req.Header)
+	}
 
 	return ctx, req, span, err
 }
@@ -485,8 +540,8 @@ func getYARPCErrorFromResponse(tres *transport.Response, response *http.Response
 		contents = response.Header.Get(ErrorMessageHeader)
 		if response.Header.Get(ErrorDetailsHeader) != "" {
 			// the contents of this header and the body should be the same, but
-			// use the contents in the body, in case the contents were not ASCII and
-			// the contents were not preserved in the header.
+			// use the contents in the body, since the header is base64 encoded,
+			// or, from older servers, may not have preserved non-ASCII contents.
 			var err error
 			details, err = ioutil.ReadAll(response.Body)
 			if err != nil {
@@ -522,6 +577,9 @@ func getYARPCErrorFromResponse(tres *transport.Response, response *http.Response
 		response.Header.Get(ErrorNameHeader),
 		strings.TrimSuffix(contents, "\n"),
 	).WithDetails(details)
+	if jsonDetails := response.Header.Get(ErrorDetailsJSONHeader); len(details) == 0 && jsonDetails != "" {
+		yarpcErr = intyarpcerrors.WithJSONDetails(yarpcErr, jsonDetails)
+	}
 
 	if bothResponseError {
 		return tres, yarpcErr
@@ -666,10 +724,27 @@ func (o *Outbound) Introspect() introspection.OutboundStatus {
 			Name: "Introspection not available",
 		}
 	}
+	headers := make(map[string]interface{}, len(o.headers))
+	for k := range o.headers {
+		headers[k] = o.headers.Get(k)
+	}
+	config := map[string]interface{}{
+		"url":        o.urlTemplate.String(),
+		"addHeaders": headers,
+	}
+	if c := o.oauth2Config; c != nil {
+		// The client secret is never reported.
+		config["oauth2"] = map[string]interface{}{
+			"tokenURL": c.TokenURL,
+			"clientID": c.ClientID,
+			"scopes":   c.Scopes,
+		}
+	}
 	return introspection.OutboundStatus{
 		Transport: "http",
 		Endpoint:  o.urlTemplate.String(),
 		State:     state,
 		Chooser:   chooser,
+		Config:    config,
 	}
 }
@@ -34,6 +34,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
 	"github.com/golang/mock/gomock"
 	"github.com/opentracing/opentracing-go"
 	"github.com/stretchr/testify/assert"
@@ -41,6 +43,7 @@ import (
 	"go.uber.org/yarpc/api/peer/peertest"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/calldepth"
 	"go.uber.org/yarpc/internal/testtime"
 	"go.uber.org/yarpc/peer/abstractpeer"
 	"go.uber.org/yarpc/pkg/lifecycle"
@@ -167,6 +170,64 @@ func TestCallSuccess(t *testing.T) {
 	}
 }
 
+func TestCallStreamingBody(t *testing.T) {
+	tests := []struct {
+		desc              string
+		opts              []OutboundOption
+		wantChunked       bool
+		wantContentLength int64
+	}{
+		{
+			desc:              "buffered",
+			wantContentLength: 5,
+		},
+		{
+			desc:              "streaming",
+			opts:              []OutboundOption{WithStreamingBody()},
+			wantChunked:       true,
+			wantContentLength: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, req *http.Request) {
+					defer req.Body.Close()
+
+					assert.Equal(t, tt.wantChunked, len(req.TransferEncoding) > 0 && req.TransferEncoding[0] == "chunked",
+						"unexpected transfer encoding %v", req.TransferEncoding)
+					assert.Equal(t, tt.wantContentLength, req.ContentLength)
+
+					body, err := ioutil.ReadAll(req.Body)
+					if assert.NoError(t, err) {
+						assert.Equal(t, []byte("world"), body)
+					}
+				},
+			))
+			defer server.Close()
+
+			httpTransport := NewTransport()
+			defer httpTransport.Stop()
+			out := httpTransport.NewSingleOutbound(server.URL, tt.opts...)
+			require.NoError(t, out.Start(), "failed to start outbound")
+			defer out.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+			defer cancel()
+			res, err := out.Call(ctx, &transport.Request{
+				Caller:    "caller",
+				Service:   "service",
+				Encoding:  raw.Encoding,
+				Procedure: "hello",
+				Body:      bytes.NewReader([]byte("world")),
+			})
+			require.NoError(t, err)
+			assert.NoError(t, res.Body.Close())
+		})
+	}
+}
+
 func TestCallOneWaySuccessWithBody(t *testing.T) {
 	successServer := httptest.NewServer(http.HandlerFunc(
 		func(w http.ResponseWriter, req *http.Request) {
@@ -321,6 +382,15 @@ func TestOutboundHeaders(t *testing.T) {
 				"X-Bar":        "BAZ",
 			},
 		},
+		{
+			desc:    "call depth",
+			context: calldepth.WithDepth(context.Background(), 3),
+			headers: transport.NewHeaders().With("foo", "bar"),
+			wantHeaders: map[string]string{
+				"Rpc-Header-Foo":            "bar",
+				"Rpc-Header-Rpc-Call-Depth": "3",
+			},
+		},
 		{
 			desc:    "pseudo headers",
 			headers: transport.NewHeaders().With(":authority", "localhost").With(":path", "/my/path").With(":scheme", "http").With(":method", "POST").With("baz", "Qux"),
@@ -347,10 +417,10 @@ func TestOutboundHeaders(t *testing.T) {
 
 		ctx := tt.context
 		if ctx == nil {
-			var cancel context.CancelFunc
-			ctx, cancel = context.WithTimeout(context.Background(), testtime.Second)
-			defer cancel()
+			ctx = context.Background()
 		}
+		ctx, cancel := context.WithTimeout(ctx, testtime.Second)
+		defer cancel()
 
 		out := httpTransport.NewSingleOutbound(server.URL, tt.opts...)
 		assert.Len(t, out.Transports(), 1, "transports must contain the transport")
@@ -847,3 +917,41 @@ func TestIsolatedSchemaChange(t *testing.T) {
 	assert.Equal(t, "http", plainOutbound.urlTemplate.Scheme)
 	assert.Equal(t, "https", tlsOutbound.urlTemplate.Scheme)
 }
+
+func TestCallErrorDetailsFromHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer r.Body.Close()
+			w.Header().Set(ErrorCodeHeader, "invalid-argument")
+			w.Header().Set(ErrorDetailsJSONHeader, `[{"@type":"type.googleapis.com/google.protobuf.StringValue","value":"string value"}]`)
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("error message\n"))
+		},
+	))
+	defer server.Close()
+
+	httpTransport := NewTransport()
+	defer httpTransport.Stop()
+
+	out := httpTransport.NewSingleOutbound(server.URL)
+	require.NoError(t, out.Start(), "failed to start outbound")
+	defer out.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	_, err := out.Call(ctx, &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Encoding:  raw.Encoding,
+		Procedure: "hello",
+		Body:      bytes.NewReader([]byte("world")),
+	})
+	require.Error(t, err, "expected call to fail")
+	assert.Equal(t, yarpcerrors.CodeInvalidArgument, yarpcerrors.FromError(err).Code())
+	assert.Equal(t, "error message", yarpcerrors.FromError(err).Message())
+
+	details, err := yarpcerrors.Details(err)
+	require.NoError(t, err, "unexpected error decoding details")
+	assert.Equal(t, []proto.Message{&types.StringValue{Value: "string value"}}, details)
+}
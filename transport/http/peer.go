@@ -21,6 +21,7 @@
 package http
 
 import (
+	"context"
 	"net"
 	"time"
 
@@ -154,6 +155,9 @@ func (p *httpPeer) MaintainConn() {
 	// Attempt to retain an open connection to each peer so long as it is
 	// retained.
 	p.setStatus(peer.Connecting)
+	if p.transport.warmUp != nil {
+		p.warmUp()
+	}
 	for {
 		// Invariant: Status is Connecting initially, or after exponential
 		// back-off, or after onDisconnected, but still Available after
@@ -191,6 +195,22 @@ func (p *httpPeer) MaintainConn() {
 	p.transport.connectorsGroup.Done()
 }
 
+// warmUp establishes connections to the peer ahead of its first requests,
+// but stops early if the transport releases the peer or stops.
+func (p *httpPeer) warmUp() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-p.released:
+		case <-p.transport.once.Stopping():
+		}
+		cancel()
+	}()
+	p.transport.warmUp.warmUp(ctx, p.addr)
+}
+
 func (p *httpPeer) setStatus(status peer.ConnectionStatus) {
 	p.transport.logger.Debug(
 		"peer status change",
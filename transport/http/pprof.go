@@ -0,0 +1,149 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// _defaultPprofPath is the path prefix under which pprof handlers are
+// served, unless WithPprofEndpoint specifies another.
+const _defaultPprofPath = "/debug/pprof/"
+
+// WithPprofEndpoint returns an InboundOption that serves the handlers of
+// net/http/pprof on the inbound under the given path prefix, so that services
+// do not need a separate HTTP server to profile them. The prefix defaults to
+// "/debug/pprof/".
+//
+//	/debug/pprof/          index of the profiles
+//	/debug/pprof/heap      heap profile
+//	/debug/pprof/profile   CPU profile
+//	/debug/pprof/trace     execution trace
+//
+// Only clients with loopback addresses may access the handlers, unless
+// WithPprofAllowedCIDRs allows others. Other clients receive a 403 Forbidden
+// response.
+func WithPprofEndpoint(path string) InboundOption {
+	return func(i *Inbound) {
+		if path == "" {
+			path = _defaultPprofPath
+		}
+		if !strings.HasSuffix(path, "/") {
+			path += "/"
+		}
+		i.pprof.path = path
+	}
+}
+
+// WithPprofAllowedCIDRs returns an InboundOption that allows clients with
+// addresses in the given CIDR ranges, like "10.0.0.0/8", to access the
+// handlers of WithPprofEndpoint.
+//
+// The inbound returns an error when Start is called if a range is invalid.
+func WithPprofAllowedCIDRs(cidrs []string) InboundOption {
+	return func(i *Inbound) {
+		i.pprof.allowedCIDRs = append(i.pprof.allowedCIDRs, cidrs...)
+	}
+}
+
+// pprofConfig configures the pprof handlers of an inbound.
+type pprofConfig struct {
+	path         string
+	allowedCIDRs []string
+}
+
+// handler serves the pprof handlers under the path prefix, and passes other
+// requests to the next handler.
+func (c *pprofConfig) handler(next http.Handler) (http.Handler, error) {
+	allowed := make([]*net.IPNet, 0, len(c.allowedCIDRs))
+	for _, cidr := range c.allowedCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pprof allowed CIDR %q: %v", cidr, err)
+		}
+		allowed = append(allowed, ipNet)
+	}
+	return pprofHandler{
+		next:    next,
+		path:    c.path,
+		allowed: allowed,
+	}, nil
+}
+
+type pprofHandler struct {
+	next    http.Handler
+	path    string
+	allowed []*net.IPNet
+}
+
+func (h pprofHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.URL.Path, h.path) {
+		h.next.ServeHTTP(w, req)
+		return
+	}
+	if !h.isAllowed(req.RemoteAddr) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	// pprof.Index only serves profiles under "/debug/pprof/", so profiles
+	// are looked up by name here to support other prefixes.
+	switch name := strings.TrimPrefix(req.URL.Path, h.path); name {
+	case "":
+		pprof.Index(w, req)
+	case "cmdline":
+		pprof.Cmdline(w, req)
+	case "profile":
+		pprof.Profile(w, req)
+	case "symbol":
+		pprof.Symbol(w, req)
+	case "trace":
+		pprof.Trace(w, req)
+	default:
+		pprof.Handler(name).ServeHTTP(w, req)
+	}
+}
+
+// isAllowed reports whether the client with the given remote address may
+// access the pprof handlers.
+func (h pprofHandler) isAllowed(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, ipNet := range h.allowed {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/internal/yarpctest"
+)
+
+func TestPprofHandler(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	c := pprofConfig{path: "/pprof/", allowedCIDRs: []string{"10.0.0.0/8", "2001:db8::/32"}}
+	h, err := c.handler(next)
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc       string
+		path       string
+		remoteAddr string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			desc:       "index from loopback",
+			path:       "/pprof/",
+			remoteAddr: "127.0.0.1:1234",
+			wantStatus: http.StatusOK,
+			wantBody:   "goroutine",
+		},
+		{
+			desc:       "profile from allowed range",
+			path:       "/pprof/goroutine?debug=1",
+			remoteAddr: "10.1.2.3:1234",
+			wantStatus: http.StatusOK,
+			wantBody:   "goroutine profile:",
+		},
+		{
+			desc:       "cmdline from allowed IPv6 range",
+			path:       "/pprof/cmdline",
+			remoteAddr: "[2001:db8::1]:1234",
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "disallowed address",
+			path:       "/pprof/heap",
+			remoteAddr: "192.0.2.1:1234",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			desc:       "invalid address",
+			path:       "/pprof/",
+			remoteAddr: "foo",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			desc:       "other path",
+			path:       "/yarpc",
+			remoteAddr: "192.0.2.1:1234",
+			wantStatus: http.StatusTeapot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestPprofInvalidCIDR(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	httpTransport := NewTransport()
+	i := httpTransport.NewInbound("127.0.0.1:0",
+		WithPprofEndpoint(""),
+		WithPprofAllowedCIDRs([]string{"10.0.0.0"}),
+	)
+	i.SetRouter(transporttest.NewMockRouter(mockCtrl))
+	err := i.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid pprof allowed CIDR "10.0.0.0"`)
+}
+
+func TestPprofInbound(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	httpTransport := NewTransport()
+	defer httpTransport.Stop()
+
+	i := httpTransport.NewInbound("127.0.0.1:0", WithPprofEndpoint(""))
+	reg := transporttest.NewMockRouter(mockCtrl)
+	reg.EXPECT().Procedures().AnyTimes()
+	i.SetRouter(reg)
+	require.NoError(t, i.Start())
+	defer i.Stop()
+
+	res, err := http.Get(fmt.Sprintf("http://%v/debug/pprof/heap?debug=1", yarpctest.ZeroAddrToHostPort(i.Addr())))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(body), "heap profile:")
+
+	assert.Equal(t, map[string]interface{}{
+		"path":         "/debug/pprof/",
+		"allowedCIDRs": []string(nil),
+	}, i.Introspect().Config["pprof"])
+}
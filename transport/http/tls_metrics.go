@@ -0,0 +1,123 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	_cipherSuiteTag = "cipher_suite"
+	_procedureTag   = "procedure"
+
+	// _cipherSuiteSpanTag is the span tag recording the cipher suite of the
+	// connection of a request, by its IANA name.
+	_cipherSuiteSpanTag = "tls.cipher_suite"
+)
+
+// tlsMetrics records the TLS cipher suites negotiated by clients of an
+// inbound.
+type tlsMetrics struct {
+	requests    *metrics.CounterVector
+	connections *metrics.GaugeVector
+
+	mu sync.Mutex
+	// conns holds the cipher suites of the connections counted as active.
+	conns map[net.Conn]string
+}
+
+func newTLSMetrics(meter *metrics.Scope, logger *zap.Logger, serviceName string) *tlsMetrics {
+	tags := metrics.Tags{
+		"component": "yarpc",
+		"service":   serviceName,
+		"transport": TransportName,
+	}
+
+	requests, err := meter.CounterVector(metrics.Spec{
+		Name:      "tls_requests",
+		Help:      "Total number of requests received over TLS, by procedure and cipher suite.",
+		ConstTags: tags,
+		VarTags:   []string{_procedureTag, _cipherSuiteTag},
+	})
+	if err != nil {
+		logger.Error("failed to create tls requests counter", zap.Error(err))
+	}
+
+	connections, err := meter.GaugeVector(metrics.Spec{
+		Name:      "tls_active_connections",
+		Help:      "Number of active TLS connections, by cipher suite.",
+		ConstTags: tags,
+		VarTags:   []string{_cipherSuiteTag},
+	})
+	if err != nil {
+		logger.Error("failed to create tls active connections gauge", zap.Error(err))
+	}
+
+	return &tlsMetrics{
+		requests:    requests,
+		connections: connections,
+		conns:       make(map[net.Conn]string),
+	}
+}
+
+// observeRequest counts a request to the given procedure over a connection
+// with the given cipher suite.
+func (m *tlsMetrics) observeRequest(procedure, cipherSuite string) {
+	m.requests.MustGet(_procedureTag, procedure, _cipherSuiteTag, cipherSuite).Inc()
+}
+
+// connState is suitable for http.Server.ConnState, counting the active TLS
+// connections of the server.
+func (m *tlsMetrics) connState(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		tlsConn, ok := conn.(*tls.Conn)
+		if !ok {
+			return
+		}
+		// The inbound completes handshakes when it accepts connections.
+		connState := tlsConn.ConnectionState()
+		if !connState.HandshakeComplete {
+			return
+		}
+		cipherSuite := tls.CipherSuiteName(connState.CipherSuite)
+
+		m.mu.Lock()
+		m.conns[conn] = cipherSuite
+		m.mu.Unlock()
+		m.connections.MustGet(_cipherSuiteTag, cipherSuite).Inc()
+
+	case http.StateHijacked, http.StateClosed:
+		m.mu.Lock()
+		cipherSuite, ok := m.conns[conn]
+		delete(m.conns, conn)
+		m.mu.Unlock()
+		if ok {
+			m.connections.MustGet(_cipherSuiteTag, cipherSuite).Dec()
+		}
+	}
+}
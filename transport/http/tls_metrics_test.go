@@ -0,0 +1,89 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/transport/internal/tls/testscenario"
+	"go.uber.org/zap"
+)
+
+func TestTLSMetricsConnState(t *testing.T) {
+	scenario := testscenario.Create(t, time.Minute, time.Minute)
+	clientTLSConfig := scenario.ClientTLSConfig()
+	clientTLSConfig.MaxVersion = tls.VersionTLS12
+	clientTLSConfig.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	tlsServerConn := tls.Server(serverConn, scenario.ServerTLSConfig())
+	tlsClientConn := tls.Client(clientConn, clientTLSConfig)
+	errs := make(chan error, 1)
+	go func() { errs <- tlsClientConn.Handshake() }()
+	require.NoError(t, tlsServerConn.Handshake())
+	require.NoError(t, <-errs)
+
+	root := metrics.New()
+	m := newTLSMetrics(root.Scope(), zap.NewNop(), "example")
+	activeConnections := func() []metrics.Snapshot {
+		return root.Snapshot().Gauges
+	}
+	want := func(value int64) []metrics.Snapshot {
+		return []metrics.Snapshot{{
+			Name: "tls_active_connections",
+			Tags: metrics.Tags{
+				"component":    "yarpc",
+				"service":      "example",
+				"transport":    TransportName,
+				"cipher_suite": "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+			},
+			Value: value,
+		}}
+	}
+
+	// Plaintext connections are not counted.
+	plaintextConn, otherConn := net.Pipe()
+	defer plaintextConn.Close()
+	defer otherConn.Close()
+	m.connState(plaintextConn, http.StateNew)
+	m.connState(plaintextConn, http.StateClosed)
+	assert.Empty(t, activeConnections())
+
+	m.connState(tlsServerConn, http.StateNew)
+	assert.Equal(t, want(1), activeConnections())
+	m.connState(tlsServerConn, http.StateActive)
+	m.connState(tlsServerConn, http.StateIdle)
+	assert.Equal(t, want(1), activeConnections())
+
+	m.connState(tlsServerConn, http.StateClosed)
+	assert.Equal(t, want(0), activeConnections())
+	m.connState(tlsServerConn, http.StateClosed)
+	assert.Equal(t, want(0), activeConnections(), "connections must only be counted once")
+}
@@ -34,8 +34,10 @@ import (
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
+	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/internal/backoff"
 	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/yarpc/transport/internal/sockopt"
 	"go.uber.org/zap"
 )
 
@@ -51,6 +53,8 @@ type transportOptions struct {
 	connBackoffStrategy       backoffapi.Strategy
 	innocenceWindow           time.Duration
 	dialContext               func(ctx context.Context, network, addr string) (net.Conn, error)
+	resolver                  *net.Resolver
+	tcpBufferSizes            sockopt.BufferSizes
 	jitter                    func(int64) int64
 	tracer                    opentracing.Tracer
 	buildClient               func(*transportOptions) *http.Client
@@ -58,6 +62,8 @@ type transportOptions struct {
 	meter                     *metrics.Scope
 	serviceName               string
 	outboundTLSConfigProvider yarpctls.OutboundTLSConfigProvider
+	warmUpConns               int
+	warmUpTimeout             time.Duration
 }
 
 var defaultTransportOptions = transportOptions{
@@ -204,6 +210,52 @@ func DialContext(f func(ctx context.Context, network, addr string) (net.Conn, er
 	}
 }
 
+// WithCustomResolver specifies the resolver for the host names of the peers
+// of the transport's outbounds, in place of the system resolver. This
+// applies to outbounds with and without TLS.
+//
+// This option does not apply to outbounds when DialContext is specified.
+func WithCustomResolver(r *net.Resolver) TransportOption {
+	return func(options *transportOptions) {
+		options.resolver = r
+	}
+}
+
+// WarmUp specifies that the transport establishes the given number of
+// connections to every peer it retains, before reporting the peer available,
+// so that the first requests after deploys do not pay for dialing.
+// The transport waits for the connections at most for the given timeout,
+// after which it admits the peer anyway. Connections that remain unused for
+// longer than the idle connection timeout are closed.
+//
+// Warm-up connections are plaintext, so they serve outbounds without TLS.
+// The gRPC transport does not need warming up, since gRPC peers establish
+// their connection as soon as they are retained, and are only reported
+// available once their connection is ready.
+//
+// Warm-up is disabled by default.
+func WarmUp(conns int, timeout time.Duration) TransportOption {
+	return func(options *transportOptions) {
+		options.warmUpConns = conns
+		options.warmUpTimeout = timeout
+	}
+}
+
+// WithTCPBufferSize specifies the sizes in bytes of the send and receive
+// buffers of the TCP connections of the transport's outbounds and inbounds.
+// A size of zero leaves the operating system default, which is often too
+// small for high-throughput services.
+//
+// The operating system may cap the sizes. If the sizes cannot be set, for
+// example on unsupported platforms, the transport logs a warning and proceeds
+// with the default sizes.
+// This option does not apply to outbounds when DialContext is specified.
+func WithTCPBufferSize(sendBufBytes, recvBufBytes int) TransportOption {
+	return func(options *transportOptions) {
+		options.tcpBufferSizes = sockopt.BufferSizes{Send: sendBufBytes, Receive: recvBufBytes}
+	}
+}
+
 // Tracer configures a tracer for the transport and all its inbounds and
 // outbounds.
 func Tracer(tracer opentracing.Tracer) TransportOption {
@@ -268,9 +320,15 @@ func (o *transportOptions) newTransport() *Transport {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
+	var warmUp *warmUpPool
+	if o.warmUpConns > 0 && o.warmUpTimeout > 0 {
+		warmUp = newWarmUpPool(o.baseDialContext(), o, logger)
+		o.dialContext = warmUp.DialContext
+	}
 	return &Transport{
 		once:                     lifecycle.NewOnce(),
 		client:                   o.buildClient(o),
+		warmUp:                   warmUp,
 		connTimeout:              o.connTimeout,
 		connBackoffStrategy:      o.connBackoffStrategy,
 		innocenceWindow:          o.innocenceWindow,
@@ -281,23 +339,53 @@ func (o *transportOptions) newTransport() *Transport {
 		meter:                    o.meter,
 		serviceName:              o.serviceName,
 		ouboundTLSConfigProvider: o.outboundTLSConfigProvider,
+		tcpBufferSizes:           o.tcpBufferSizes,
+		config:                   o.introspectConfig(),
 	}
 }
 
-func buildHTTPClient(options *transportOptions) *http.Client {
-	dialContext := options.dialContext
-	if dialContext == nil {
-		dialContext = (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: options.keepAlive,
-		}).DialContext
+// introspectConfig returns the resolved options of the transport, keyed as
+// in YARPC configuration.
+func (o *transportOptions) introspectConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"keepAlive":             o.keepAlive.String(),
+		"maxIdleConns":          o.maxIdleConns,
+		"maxIdleConnsPerHost":   o.maxIdleConnsPerHost,
+		"idleConnTimeout":       o.idleConnTimeout.String(),
+		"disableKeepAlives":     o.disableKeepAlives,
+		"disableCompression":    o.disableCompression,
+		"responseHeaderTimeout": o.responseHeaderTimeout.String(),
+		"connTimeout":           o.connTimeout.String(),
+		"tcpSendBufferSize":     o.tcpBufferSizes.Send,
+		"tcpReceiveBufferSize":  o.tcpBufferSizes.Receive,
+		"warmUpConnections":     o.warmUpConns,
+		"warmUpTimeout":         o.warmUpTimeout.String(),
+	}
+}
+
+// baseDialContext returns the dial function given with the DialContext
+// option, or the default dial function.
+func (o *transportOptions) baseDialContext() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if o.dialContext != nil {
+		return o.dialContext
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: o.keepAlive,
+		Resolver:  o.resolver,
 	}
+	if !o.tcpBufferSizes.IsZero() {
+		dialer.Control = o.tcpBufferSizes.Control(o.logger)
+	}
+	return dialer.DialContext
+}
 
+func buildHTTPClient(options *transportOptions) *http.Client {
 	return &http.Client{
 		Transport: &http.Transport{
 			// options lifted from https://golang.org/src/net/http/transport.go
 			Proxy:                 http.ProxyFromEnvironment,
-			DialContext:           dialContext,
+			DialContext:           options.baseDialContext(),
 			TLSHandshakeTimeout:   10 * time.Second,
 			ExpectContinueTimeout: 1 * time.Second,
 			MaxIdleConns:          options.maxIdleConns,
@@ -331,6 +419,12 @@ type Transport struct {
 	meter                    *metrics.Scope
 	serviceName              string
 	ouboundTLSConfigProvider yarpctls.OutboundTLSConfigProvider
+	tcpBufferSizes           sockopt.BufferSizes
+	warmUp                   *warmUpPool
+
+	// config holds the resolved configuration of the transport for
+	// introspection.
+	config map[string]interface{}
 }
 
 var _ transport.Transport = (*Transport)(nil)
@@ -347,6 +441,9 @@ func (a *Transport) Stop() error {
 	return a.once.Stop(func() error {
 		closeIdleConnections(a.client)
 		a.connectorsGroup.Wait()
+		if a.warmUp != nil {
+			a.warmUp.close()
+		}
 		return nil
 	})
 }
@@ -356,6 +453,20 @@ func (a *Transport) IsRunning() bool {
 	return a.once.IsRunning()
 }
 
+// Introspect returns the state and the resolved configuration of the
+// transport for introspection purposes.
+func (a *Transport) Introspect() introspection.TransportStatus {
+	config := make(map[string]interface{}, len(a.config))
+	for k, v := range a.config {
+		config[k] = v
+	}
+	return introspection.TransportStatus{
+		Name:   TransportName,
+		State:  a.once.State().String(),
+		Config: config,
+	}
+}
+
 // RetainPeer gets or creates a Peer for the specified peer.Subscriber (usually a peer.Chooser)
 func (a *Transport) RetainPeer(pid peer.Identifier, sub peer.Subscriber) (peer.Peer, error) {
 	a.lock.Lock()
@@ -400,6 +511,9 @@ func (a *Transport) ReleasePeer(pid peer.Identifier, sub peer.Subscriber) error
 	if p.NumSubscribers() == 0 {
 		delete(a.peers, pid.Identifier())
 		p.Release()
+		if a.warmUp != nil {
+			a.warmUp.release(pid.Identifier())
+		}
 	}
 
 	return nil
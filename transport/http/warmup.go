@@ -0,0 +1,179 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// warmUpPool holds connections established to peers ahead of their first
+// requests, and hands them to the HTTP client in place of new connections.
+type warmUpPool struct {
+	dial    dialFunc
+	conns   int
+	timeout time.Duration
+	// maxAge bounds how long connections may wait for a request, since
+	// servers close idle connections.
+	maxAge time.Duration
+	now    func() time.Time
+	logger *zap.Logger
+
+	successes *metrics.Counter
+	failures  *metrics.Counter
+
+	mu     sync.Mutex
+	parked map[string][]warmConn
+}
+
+type warmConn struct {
+	net.Conn
+
+	since time.Time
+}
+
+func newWarmUpPool(dial dialFunc, options *transportOptions, logger *zap.Logger) *warmUpPool {
+	tags := metrics.Tags{
+		"component": "yarpc",
+		"service":   options.serviceName,
+		"transport": TransportName,
+	}
+
+	successes, err := options.meter.Counter(metrics.Spec{
+		Name:      "peer_warm_up_connections",
+		Help:      "Total number of connections established to peers ahead of their first requests.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create peer warm-up connections counter", zap.Error(err))
+	}
+
+	failures, err := options.meter.Counter(metrics.Spec{
+		Name:      "peer_warm_up_connection_failures",
+		Help:      "Total number of connections that failed to be established to peers ahead of their first requests.",
+		ConstTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create peer warm-up connection failures counter", zap.Error(err))
+	}
+
+	return &warmUpPool{
+		dial:      dial,
+		conns:     options.warmUpConns,
+		timeout:   options.warmUpTimeout,
+		maxAge:    options.idleConnTimeout,
+		now:       time.Now,
+		logger:    logger,
+		successes: successes,
+		failures:  failures,
+		parked:    make(map[string][]warmConn),
+	}
+}
+
+// DialContext returns a connection established by warming up the address,
+// if any, or dials a new connection.
+func (w *warmUpPool) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if conn := w.take(addr); conn != nil {
+		return conn, nil
+	}
+	return w.dial(ctx, network, addr)
+}
+
+func (w *warmUpPool) take(addr string) net.Conn {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for conns := w.parked[addr]; len(conns) > 0; conns = w.parked[addr] {
+		conn := conns[len(conns)-1]
+		if len(conns) == 1 {
+			delete(w.parked, addr)
+		} else {
+			w.parked[addr] = conns[:len(conns)-1]
+		}
+		if w.maxAge <= 0 || w.now().Sub(conn.since) < w.maxAge {
+			return conn.Conn
+		}
+		_ = conn.Close()
+	}
+	return nil
+}
+
+// warmUp establishes connections to the address concurrently, until all are
+// established or the warm-up timeout elapses.
+func (w *warmUpPool) warmUp(ctx context.Context, addr string) {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(w.conns)
+	for i := 0; i < w.conns; i++ {
+		go func() {
+			defer wg.Done()
+			conn, err := w.dial(ctx, "tcp", addr)
+			if err != nil {
+				w.failures.Inc()
+				w.logger.Debug("failed to warm up connection to peer",
+					zap.String("peer", addr),
+					zap.String("transport", "http"),
+					zap.Error(err))
+				return
+			}
+			w.successes.Inc()
+			w.park(addr, conn)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *warmUpPool) park(addr string, conn net.Conn) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.parked[addr] = append(w.parked[addr], warmConn{Conn: conn, since: w.now()})
+}
+
+// release closes the unused connections to the address.
+func (w *warmUpPool) release(addr string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, conn := range w.parked[addr] {
+		_ = conn.Close()
+	}
+	delete(w.parked, addr)
+}
+
+// close closes all unused connections.
+func (w *warmUpPool) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for addr, conns := range w.parked {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		delete(w.parked, addr)
+	}
+}
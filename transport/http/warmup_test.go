@@ -0,0 +1,184 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/zap"
+)
+
+func warmUpCounters(root *metrics.Root) map[string]int64 {
+	counters := make(map[string]int64)
+	for _, c := range root.Snapshot().Counters {
+		counters[c.Name] = c.Value
+	}
+	return counters
+}
+
+func TestWarmUp(t *testing.T) {
+	var accepted atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			accepted.Inc()
+		}
+	}
+	server.Start()
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	var dials atomic.Int32
+	dialer := &net.Dialer{}
+	root := metrics.New()
+	trans := NewTransport(
+		DialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Inc()
+			return dialer.DialContext(ctx, network, addr)
+		}),
+		WarmUp(2, testtime.Second),
+		Meter(root.Scope()),
+	)
+	require.NoError(t, trans.Start())
+	defer trans.Stop()
+
+	list := roundrobin.New(trans)
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{hostport.Identify(addr)}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	p, onFinish, err := list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	onFinish(nil)
+	assert.Equal(t, addr, p.Identifier())
+	assert.Equal(t, int32(2), dials.Load(), "connections must be established before the peer is available")
+	assert.Equal(t, int64(2), warmUpCounters(root)["peer_warm_up_connections"])
+
+	out := trans.NewOutbound(list)
+	require.NoError(t, out.Start())
+	defer out.Stop()
+	for i := 0; i < 3; i++ {
+		res, err := out.Call(ctx, &transport.Request{
+			Caller:    "caller",
+			Service:   "service",
+			Procedure: "procedure",
+			Encoding:  raw.Encoding,
+			Body:      &bytes.Buffer{},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+	assert.Equal(t, int32(2), dials.Load(), "requests must use the warmed up connections")
+}
+
+func TestWarmUpTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	addr := listener.Addr().String()
+
+	root := metrics.New()
+	trans := NewTransport(
+		// Warm-up connections never get established.
+		DialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}),
+		WarmUp(3, 10*testtime.Millisecond),
+		Meter(root.Scope()),
+	)
+	require.NoError(t, trans.Start())
+	defer trans.Stop()
+
+	list := roundrobin.New(trans)
+	require.NoError(t, list.Start())
+	defer list.Stop()
+	require.NoError(t, list.Update(peer.ListUpdates{Additions: []peer.Identifier{hostport.Identify(addr)}}))
+
+	// The peer is admitted anyway once the warm-up times out.
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	_, onFinish, err := list.Choose(ctx, &transport.Request{})
+	require.NoError(t, err)
+	onFinish(nil)
+
+	counters := warmUpCounters(root)
+	assert.Equal(t, int64(0), counters["peer_warm_up_connections"])
+	assert.Equal(t, int64(3), counters["peer_warm_up_connection_failures"])
+}
+
+func TestWarmUpPoolExpiresConnections(t *testing.T) {
+	var dials atomic.Int32
+	pool := newWarmUpPool(func(context.Context, string, string) (net.Conn, error) {
+		dials.Inc()
+		return nil, errors.New("dialed")
+	}, &transportOptions{warmUpConns: 1, idleConnTimeout: time.Minute}, zap.NewNop())
+
+	start := time.Now()
+	pool.now = func() time.Time { return start }
+	stale, staleRemote := net.Pipe()
+	defer staleRemote.Close()
+	pool.park("addr", stale)
+
+	pool.now = func() time.Time { return start.Add(2 * time.Minute) }
+	fresh, freshRemote := net.Pipe()
+	defer freshRemote.Close()
+	pool.park("addr", fresh)
+
+	conn, err := pool.DialContext(context.Background(), "tcp", "addr")
+	require.NoError(t, err)
+	assert.Equal(t, fresh, conn)
+
+	_, err = pool.DialContext(context.Background(), "tcp", "addr")
+	assert.EqualError(t, err, "dialed", "stale connections must not be used")
+	assert.Equal(t, int32(1), dials.Load())
+	_, err = staleRemote.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err, "stale connections must be closed")
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// _maxCloseReason is the largest reason that fits in a WebSocket close frame.
+const _maxCloseReason = 123
+
+// WebSocketHandler handles a WebSocket connection upgraded from a request to
+// a procedure, and returns when done with it. Its context is canceled when
+// the inbound stops.
+//
+// The metadata of the request is read from the headers of the upgrade
+// request. The connection is closed when the handler returns, with the
+// message of the error it returns, if any, as the reason.
+type WebSocketHandler func(ctx context.Context, req *transport.RequestMeta, conn *websocket.Conn) error
+
+// WithWebSocketUpgrade returns an InboundOption that upgrades requests to the
+// given procedure that ask to switch to the WebSocket protocol, and hands
+// their connection to the given handler.
+//
+// Upgrade requests must have the Rpc-Caller, Rpc-Service, Rpc-Procedure and
+// Rpc-Encoding headers of YARPC requests, and may have application headers.
+// Requests to the procedure without an "Upgrade: websocket" header are
+// handled like any other request.
+//
+// Cross-origin upgrade requests from browsers are rejected.
+func WithWebSocketUpgrade(procedure string, handler WebSocketHandler) InboundOption {
+	return func(i *Inbound) {
+		if i.webSocketHandlers == nil {
+			i.webSocketHandlers = make(map[string]WebSocketHandler)
+		}
+		i.webSocketHandlers[procedure] = handler
+	}
+}
+
+// webSocketUpgrader is an http.Handler that upgrades the WebSocket requests
+// of procedures with a WebSocketHandler, and passes other requests to the
+// next handler.
+//
+// Upgraded connections are hijacked from the HTTP server, so the upgrader
+// tracks them to close them when the inbound stops.
+type webSocketUpgrader struct {
+	next        http.Handler
+	handlers    map[string]WebSocketHandler
+	grabHeaders map[string]struct{}
+	logger      *zap.Logger
+	upgrader    websocket.Upgrader
+
+	lock    sync.Mutex
+	cancels map[*websocket.Conn]context.CancelFunc
+	closed  bool
+}
+
+func newWebSocketUpgrader(next http.Handler, i *Inbound) *webSocketUpgrader {
+	return &webSocketUpgrader{
+		next:        next,
+		handlers:    i.webSocketHandlers,
+		grabHeaders: i.grabHeaders,
+		logger:      i.logger,
+		cancels:     make(map[*websocket.Conn]context.CancelFunc),
+	}
+}
+
+func (u *webSocketUpgrader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	procedure := req.Header.Get(ProcedureHeader)
+	handler, ok := u.handlers[procedure]
+	if !ok || !websocket.IsWebSocketUpgrade(req) {
+		u.next.ServeHTTP(w, req)
+		return
+	}
+
+	// The metadata must be read before the connection is hijacked.
+	meta := &transport.RequestMeta{
+		Caller:          req.Header.Get(CallerHeader),
+		Service:         req.Header.Get(ServiceHeader),
+		Procedure:       procedure,
+		Encoding:        transport.Encoding(req.Header.Get(EncodingHeader)),
+		Transport:       TransportName,
+		ShardKey:        req.Header.Get(ShardKeyHeader),
+		RoutingKey:      req.Header.Get(RoutingKeyHeader),
+		RoutingDelegate: req.Header.Get(RoutingDelegateHeader),
+		CallerProcedure: req.Header.Get(CallerProcedureHeader),
+		Headers:         applicationHeaders.FromHTTPHeaders(req.Header, transport.Headers{}),
+	}
+	for header := range u.grabHeaders {
+		if value := req.Header.Get(header); value != "" {
+			meta.Headers = meta.Headers.With(header, value)
+		}
+	}
+	if err := transport.ValidateRequest(meta.ToRequest()); err != nil {
+		http.Error(w, yarpcerrors.FromError(err).Message(), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := u.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// The upgrader already responded with an error.
+		u.logger.Debug("failed to upgrade WebSocket request",
+			zap.String("procedure", procedure), zap.Error(err))
+		return
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	if !u.track(conn, cancel) {
+		cancel()
+		closeWebSocket(conn, yarpcerrors.UnavailableErrorf("inbound is stopping"))
+		return
+	}
+	defer u.untrack(conn)
+
+	closeWebSocket(conn, handler(ctx, meta, conn))
+}
+
+func (u *webSocketUpgrader) track(conn *websocket.Conn, cancel context.CancelFunc) bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if u.closed {
+		return false
+	}
+	u.cancels[conn] = cancel
+	return true
+}
+
+func (u *webSocketUpgrader) untrack(conn *websocket.Conn) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	if cancel, ok := u.cancels[conn]; ok {
+		cancel()
+		delete(u.cancels, conn)
+	}
+}
+
+// closeAll cancels the handlers of the upgraded connections and closes them.
+func (u *webSocketUpgrader) closeAll() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.closed = true
+	for conn, cancel := range u.cancels {
+		cancel()
+		conn.Close()
+	}
+}
+
+// closeWebSocket closes the connection, telling the peer why with the error,
+// if any.
+func closeWebSocket(conn *websocket.Conn, err error) {
+	code, reason := websocket.CloseNormalClosure, ""
+	if err != nil {
+		code, reason = websocket.CloseInternalServerErr, err.Error()
+		if len(reason) > _maxCloseReason {
+			reason = reason[:_maxCloseReason]
+		}
+	}
+	// The handler may have closed the connection already.
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	conn.Close()
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/internal/yarpctest"
+)
+
+// startWebSocketInbound starts an inbound that routes every request that is
+// not upgraded to the given unary handler.
+func startWebSocketInbound(t *testing.T, mockCtrl *gomock.Controller, h transport.UnaryHandler, opts ...InboundOption) (*Inbound, string) {
+	httpTransport := NewTransport()
+	i := httpTransport.NewInbound("127.0.0.1:0", opts...)
+	reg := transporttest.NewMockRouter(mockCtrl)
+	reg.EXPECT().Procedures().AnyTimes().Return(nil)
+	reg.EXPECT().Choose(gomock.Any(), gomock.Any()).AnyTimes().Return(transport.NewUnaryHandlerSpec(h), nil)
+	i.SetRouter(reg)
+	require.NoError(t, i.Start())
+	return i, yarpctest.ZeroAddrToHostPort(i.Addr())
+}
+
+func webSocketHeaders(procedure string) http.Header {
+	h := http.Header{}
+	h.Set(CallerHeader, "caller")
+	h.Set(ServiceHeader, "service")
+	h.Set(ProcedureHeader, procedure)
+	h.Set(EncodingHeader, "raw")
+	h.Set(ApplicationHeaderPrefix+"Token", "secret")
+	return h
+}
+
+func TestWebSocketUpgrade(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	metas := make(chan *transport.RequestMeta, 1)
+	echo := func(ctx context.Context, req *transport.RequestMeta, conn *websocket.Conn) error {
+		metas <- req
+		typ, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(typ, append([]byte("echo: "), msg...))
+	}
+	i, addr := startWebSocketInbound(t, mockCtrl, transporttest.NewMockUnaryHandler(mockCtrl), WithWebSocketUpgrade("chat", echo))
+	defer i.Stop()
+
+	conn, res, err := websocket.DefaultDialer.Dial("ws://"+addr+"/", webSocketHeaders("chat"))
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "echo: hello", string(msg))
+
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), "expected a normal closure, got %v", err)
+
+	assert.Equal(t, &transport.RequestMeta{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "chat",
+		Encoding:  "raw",
+		Transport: TransportName,
+		Headers:   transport.NewHeaders().With("Token", "secret"),
+	}, <-metas)
+}
+
+func TestWebSocketUpgradeFallsThrough(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	h := transporttest.NewMockUnaryHandler(mockCtrl)
+	h.EXPECT().Handle(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	upgrade := func(context.Context, *transport.RequestMeta, *websocket.Conn) error {
+		t.Error("requests without an upgrade header must not be upgraded")
+		return nil
+	}
+	i, addr := startWebSocketInbound(t, mockCtrl, h, WithWebSocketUpgrade("chat", upgrade))
+	defer i.Stop()
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("http://%v/", addr), bytes.NewReader([]byte("hello")))
+	require.NoError(t, err)
+	req.Header = webSocketHeaders("chat")
+	req.Header.Set(TTLMSHeader, "1000")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestWebSocketUpgradeErrors(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	fail := func(context.Context, *transport.RequestMeta, *websocket.Conn) error {
+		return errors.New("great sadness")
+	}
+	i, addr := startWebSocketInbound(t, mockCtrl, transporttest.NewMockUnaryHandler(mockCtrl), WithWebSocketUpgrade("chat", fail))
+	defer i.Stop()
+
+	headers := webSocketHeaders("chat")
+	headers.Del(CallerHeader)
+	_, res, err := websocket.DefaultDialer.Dial("ws://"+addr+"/", headers)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "upgrade requests must have the metadata of YARPC requests")
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/", webSocketHeaders("chat"))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.True(t, errors.As(err, &closeErr), "expected a close error, got %v", err)
+	assert.Equal(t, websocket.CloseInternalServerErr, closeErr.Code)
+	assert.Equal(t, "great sadness", closeErr.Text)
+}
+
+func TestWebSocketClosedOnStop(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	wait := func(ctx context.Context, _ *transport.RequestMeta, _ *websocket.Conn) error {
+		close(started)
+		<-ctx.Done()
+		done <- ctx.Err()
+		return ctx.Err()
+	}
+	i, addr := startWebSocketInbound(t, mockCtrl, transporttest.NewMockUnaryHandler(mockCtrl), WithWebSocketUpgrade("wait", wait))
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/", webSocketHeaders("wait"))
+	require.NoError(t, err)
+	defer conn.Close()
+	<-started
+
+	require.NoError(t, i.Stop())
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(testtime.Second):
+		t.Fatal("handler was not canceled when the inbound stopped")
+	}
+	_, _, err = conn.ReadMessage()
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package sockopt sets options on the TCP sockets of transports.
+package sockopt
+
+import (
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// BufferSizes are the sizes in bytes of the send and receive buffers of TCP
+// sockets. A size of zero leaves the operating system default.
+type BufferSizes struct {
+	Send    int
+	Receive int
+}
+
+// IsZero returns whether neither buffer size is set.
+func (b BufferSizes) IsZero() bool {
+	return b.Send <= 0 && b.Receive <= 0
+}
+
+// Control returns a function, suitable for the Control field of net.Dialer
+// and net.ListenConfig, that sets the buffer sizes of TCP sockets.
+//
+// If the buffer sizes cannot be set, for example on unsupported platforms,
+// the function logs a warning and lets the connection proceed.
+func (b BufferSizes) Control(logger *zap.Logger) func(network, address string, c syscall.RawConn) error {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		if b.IsZero() || !strings.HasPrefix(network, "tcp") {
+			return nil
+		}
+
+		var err error
+		if cerr := c.Control(func(fd uintptr) {
+			err = setBufferSizes(fd, b)
+		}); cerr != nil {
+			err = cerr
+		}
+		if err != nil {
+			logger.Warn("failed to set TCP socket buffer sizes",
+				zap.String("address", address),
+				zap.Int("sendBufferSize", b.Send),
+				zap.Int("receiveBufferSize", b.Receive),
+				zap.Error(err))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package sockopt
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func bufferSizes(t *testing.T, conn net.Conn) BufferSizes {
+	raw, err := conn.(syscall.Conn).SyscallConn()
+	require.NoError(t, err)
+
+	var (
+		b          BufferSizes
+		sendErr    error
+		receiveErr error
+	)
+	require.NoError(t, raw.Control(func(fd uintptr) {
+		b.Send, sendErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+		b.Receive, receiveErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+	}))
+	require.NoError(t, sendErr)
+	require.NoError(t, receiveErr)
+	return b
+}
+
+func TestControlSetsBufferSizes(t *testing.T) {
+	// Use sizes below the defaults and the limits of common platforms, so
+	// that they take effect without privileges.
+	want := BufferSizes{Send: 6 << 10, Receive: 6 << 10}
+	control := want.Control(zaptest.NewLogger(t))
+
+	lc := net.ListenConfig{Control: control}
+	listener, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+		close(accepted)
+	}()
+
+	d := net.Dialer{Control: control}
+	conn, err := d.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	server, ok := <-accepted
+	require.True(t, ok, "expected to accept a connection")
+	defer server.Close()
+
+	// Linux reserves double the requested size for bookkeeping.
+	for _, c := range []net.Conn{conn, server} {
+		got := bufferSizes(t, c)
+		assert.True(t, got.Send >= want.Send && got.Send <= 2*want.Send,
+			"unexpected send buffer size %d, want %d", got.Send, want.Send)
+		assert.True(t, got.Receive >= want.Receive && got.Receive <= 2*want.Receive,
+			"unexpected receive buffer size %d, want %d", got.Receive, want.Receive)
+	}
+}
+
+func TestControlIgnoresOtherNetworks(t *testing.T) {
+	control := BufferSizes{Send: 1 << 20}.Control(nil)
+	// The raw connection is never used for networks other than TCP.
+	assert.NoError(t, control("unix", "/tmp/socket", nil))
+	assert.NoError(t, BufferSizes{}.Control(nil)("tcp", "127.0.0.1:0", nil))
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package keypair builds the TLS configuration of inbounds from the
+// certificate and key secrets of their configuration.
+package keypair
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+// ServerConfig returns a TLS configuration serving the PEM-encoded
+// certificate and key of the given secrets, or nil if neither is set.
+func ServerConfig(cert, key yarpcconfig.Secret) (*tls.Config, error) {
+	if cert.IsZero() && key.IsZero() {
+		return nil, nil
+	}
+	if cert.IsZero() || key.IsZero() {
+		return nil, errors.New("both tls.cert and tls.key are necessary to serve TLS")
+	}
+
+	certPEM, err := cert.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve tls.cert: %v", err)
+	}
+	keyPEM, err := key.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve tls.key: %v", err)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key pair in tls.cert and tls.key: %v", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{pair}}, nil
+}
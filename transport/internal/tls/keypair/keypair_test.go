@@ -0,0 +1,84 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package keypair
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+func mustParseSecret(t *testing.T, s string) yarpcconfig.Secret {
+	secret, err := yarpcconfig.ParseSecret(s)
+	require.NoError(t, err)
+	return secret
+}
+
+func TestServerConfig(t *testing.T) {
+	cfg, err := ServerConfig(
+		mustParseSecret(t, "file://../../../grpc/testdata/cert"),
+		mustParseSecret(t, "file://../../../grpc/testdata/key"),
+	)
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+	assert.Len(t, cfg.Certificates, 1)
+
+	cfg, err = ServerConfig(yarpcconfig.Secret{}, yarpcconfig.Secret{})
+	require.NoError(t, err)
+	assert.Nil(t, cfg)
+}
+
+func TestServerConfigErrors(t *testing.T) {
+	tests := []struct {
+		desc      string
+		cert, key string
+		wantErr   string
+	}{
+		{
+			desc:    "missing key",
+			cert:    "file://../../../grpc/testdata/cert",
+			wantErr: "both tls.cert and tls.key are necessary to serve TLS",
+		},
+		{
+			desc:    "unresolved cert",
+			cert:    "env://KEYPAIR_TEST_UNSET_CERT",
+			key:     "file://../../../grpc/testdata/key",
+			wantErr: "cannot resolve tls.cert: ",
+		},
+		{
+			desc:    "invalid pair",
+			cert:    "not a certificate",
+			key:     "not a key",
+			wantErr: "invalid key pair in tls.cert and tls.key: ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := ServerConfig(mustParseSecret(t, tt.cert), mustParseSecret(t, tt.key))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			assert.NotContains(t, err.Error(), "not a key")
+		})
+	}
+}
@@ -22,9 +22,16 @@ package muxlistener
 
 import (
 	"bytes"
+	"io"
 	"net"
+
+	"go.uber.org/zap"
 )
 
+// _defaultMaxSniffBytes is the default number of bytes that may be buffered
+// while sniffing a connection.
+const _defaultMaxSniffBytes = 4096
+
 // connSniffer wraps the connection and enables muxlistener to sniff inital bytes from the
 // connection efficiently.
 type connSniffer struct {
@@ -35,16 +42,31 @@ type connSniffer struct {
 	// buf stores bytes read from the underlying connection when in sniffing
 	// mode. When sniffing mode is disabled, buffered bytes is returned.
 	buf bytes.Buffer
+	// maxSniffBytes caps the number of bytes stored in the buffer so that a
+	// client cannot exhaust memory before the protocol is sniffed.
+	maxSniffBytes int
+
+	logger *zap.Logger
 }
 
-func newConnectionSniffer(conn net.Conn) *connSniffer {
-	return &connSniffer{Conn: conn}
+func newConnectionSniffer(conn net.Conn, logger *zap.Logger) *connSniffer {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &connSniffer{
+		Conn:          conn,
+		maxSniffBytes: _defaultMaxSniffBytes,
+		logger:        logger,
+	}
 }
 
 // Read returns bytes read from the underlying connection. When sniffing is
 // true, data read from the connection is stored in the buffer. When sniffing
 // mode is disabled, data is first read from the buffer and once the buffer is
 // empty the underlying connection is read.
+//
+// Reads in sniffing mode return io.ErrShortBuffer once the buffer holds
+// maxSniffBytes.
 func (c *connSniffer) Read(b []byte) (int, error) {
 	if c.disableSniffing && c.buf.Len() != 0 {
 		// Read from the buffer when sniffing is disabled and buffer is not empty.
@@ -56,6 +78,21 @@ func (c *connSniffer) Read(b []byte) (int, error) {
 		return n, nil
 	}
 
+	if !c.disableSniffing {
+		remaining := c.maxSniffBytes - c.buf.Len()
+		if remaining <= 0 {
+			c.logger.Debug("connection sniffing buffer limit reached",
+				zap.Int("maxSniffBytes", c.maxSniffBytes),
+				zap.Binary("buffer", c.buf.Bytes()))
+			return 0, io.ErrShortBuffer
+		}
+		if len(b) > remaining {
+			// Never read more than the buffer can hold, so that no bytes
+			// are lost from the connection.
+			b = b[:remaining]
+		}
+	}
+
 	n, err := c.Conn.Read(b)
 	if err != nil {
 		return n, err
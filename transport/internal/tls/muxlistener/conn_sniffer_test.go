@@ -22,11 +22,14 @@ package muxlistener
 
 import (
 	"bytes"
+	"io"
 	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 type mockConn struct {
@@ -45,7 +48,7 @@ func (m *mockConn) Read(b []byte) (int, error) {
 func TestConnSniffer(t *testing.T) {
 	t.Run("must_read_directly_when_not_sniffing", func(t *testing.T) {
 		data := []byte("test")
-		sniffer := newConnectionSniffer(newMockConn(data))
+		sniffer := newConnectionSniffer(newMockConn(data), nil)
 		sniffer.disableSniffing = true
 
 		buf := make([]byte, 4)
@@ -58,7 +61,7 @@ func TestConnSniffer(t *testing.T) {
 
 	t.Run("must_store_data_when_sniffing", func(t *testing.T) {
 		data := []byte("test")
-		sniffer := newConnectionSniffer(newMockConn(data))
+		sniffer := newConnectionSniffer(newMockConn(data), nil)
 		require.False(t, sniffer.disableSniffing, "unexpected sniffing value")
 
 		buf := make([]byte, 2)
@@ -77,7 +80,7 @@ func TestConnSniffer(t *testing.T) {
 
 	t.Run("must_empty_buffer_after_sniffing", func(t *testing.T) {
 		data := []byte("test")
-		sniffer := newConnectionSniffer(newMockConn(data))
+		sniffer := newConnectionSniffer(newMockConn(data), nil)
 
 		buf := make([]byte, 2)
 		n, err := sniffer.Read(buf)
@@ -98,4 +101,42 @@ func TestConnSniffer(t *testing.T) {
 		assert.Equal(t, 2, n, "unexpected length")
 		assert.Equal(t, data[2:], buf, "unexpected data")
 	})
+
+	t.Run("must_enforce_buffer_cap_when_sniffing", func(t *testing.T) {
+		data := []byte("testdata")
+		core, logs := observer.New(zap.DebugLevel)
+		sniffer := newConnectionSniffer(newMockConn(data), zap.New(core))
+		sniffer.maxSniffBytes = 6
+
+		buf := make([]byte, 4)
+		n, err := sniffer.Read(buf)
+		require.NoError(t, err, "unexpected error")
+		assert.Equal(t, 4, n, "unexpected length")
+
+		// The read is shortened to what the buffer can still hold.
+		n, err = sniffer.Read(buf)
+		require.NoError(t, err, "unexpected error")
+		assert.Equal(t, 2, n, "unexpected length")
+		assert.Equal(t, data[:6], sniffer.buf.Bytes(), "unexpected buffer content")
+
+		n, err = sniffer.Read(buf)
+		assert.Equal(t, io.ErrShortBuffer, err, "unexpected error")
+		assert.Zero(t, n, "unexpected length")
+		assert.Equal(t, 6, sniffer.buf.Len(), "buffer must not grow beyond the cap")
+
+		entries := logs.FilterMessage("connection sniffing buffer limit reached").AllUntimed()
+		require.Len(t, entries, 1, "unexpected log entries")
+		assert.Equal(t, data[:6], entries[0].ContextMap()["buffer"], "unexpected logged buffer")
+
+		// Unsniffed bytes remain readable once sniffing stops.
+		sniffer.stopSniffing()
+		got, err := io.ReadAll(sniffer)
+		require.NoError(t, err, "unexpected error")
+		assert.Equal(t, data, got, "unexpected data")
+	})
+
+	t.Run("must_default_buffer_cap", func(t *testing.T) {
+		sniffer := newConnectionSniffer(newMockConn(nil), nil)
+		assert.Equal(t, _defaultMaxSniffBytes, sniffer.maxSniffBytes, "unexpected buffer cap")
+	})
 }
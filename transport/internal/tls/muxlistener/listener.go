@@ -178,7 +178,7 @@ func (l *listener) mux(ctx context.Context, conn net.Conn) (net.Conn, error) {
 		return l.handleTLSConn(ctx, conn)
 	}
 
-	c := newConnectionSniffer(conn)
+	c := newConnectionSniffer(conn, l.logger)
 	isTLS, err := matchTLSConnection(c)
 	if err != nil {
 		l.logger.Error("TLS connection matcher failed", zap.Error(err))
@@ -83,6 +83,11 @@ func (i *ChannelInbound) Start() error {
 	})
 }
 
+// TransportName is the name of the transport of the inbound.
+func (i *ChannelInbound) TransportName() string {
+	return TransportName
+}
+
 // Stop stops the TChannel outbound. This currently does nothing.
 func (i *ChannelInbound) Stop() error {
 	return i.once.Stop(nil)
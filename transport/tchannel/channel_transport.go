@@ -62,8 +62,17 @@ func NewChannelTransport(opts ...TransportOption) (*ChannelTransport, error) {
 	if ch == nil {
 		if options.name == "" {
 			err = errChannelOrServiceNameIsRequired
-		} else {
-			opts := tchannel.ChannelOptions{Tracer: options.tracer}
+		} else if err = options.validateMaxFramePayloadSize(); err == nil {
+			logger := options.logger
+			if logger == nil {
+				logger = zap.NewNop()
+			}
+			opts := tchannel.ChannelOptions{
+				Tracer: options.tracer,
+				DefaultConnectionOptions: tchannel.ConnectionOptions{
+					FramePool: options.framePool(logger),
+				},
+			}
 			ch, err = tchannel.NewChannel(options.name, &opts)
 			options.ch = ch
 		}
@@ -29,6 +29,7 @@ import (
 	"go.uber.org/yarpc/api/transport"
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
 	"go.uber.org/yarpc/peer/hostport"
+	"go.uber.org/yarpc/transport/internal/tls/keypair"
 	"go.uber.org/yarpc/yarpcconfig"
 )
 
@@ -38,6 +39,9 @@ import (
 //  transports:
 //    tchannel:
 //      connTimeout: 500ms
+//      tcpSendBufferSize: 0
+//      tcpReceiveBufferSize: 0
+//      maxFramePayloadSize: 65519
 //      connBackoff:
 //        exponential:
 //          first: 10ms
@@ -45,6 +49,15 @@ import (
 type TransportConfig struct {
 	ConnTimeout time.Duration       `config:"connTimeout"`
 	ConnBackoff yarpcconfig.Backoff `config:"connBackoff"`
+	// Specifies the sizes in bytes of the send and receive buffers of TCP
+	// connections, like 4194304 or 4MiB. Zero leaves the operating system
+	// default.
+	TCPSendBufferSize    yarpcconfig.ByteSize `config:"tcpSendBufferSize"`
+	TCPReceiveBufferSize yarpcconfig.ByteSize `config:"tcpReceiveBufferSize"`
+	// Limits the payloads of the frames the transport writes, like 16384 or
+	// 16KiB. See WithMaxFramePayloadSize. Zero leaves the default of 65519
+	// bytes.
+	MaxFramePayloadSize yarpcconfig.ByteSize `config:"maxFramePayloadSize"`
 }
 
 // InboundConfig configures a TChannel inbound.
@@ -67,8 +80,19 @@ type InboundConfig struct {
 // InboundTLSConfig specifies the TLS configuration of the tchannel inbound.
 type InboundTLSConfig struct {
 	// Mode when set to Permissive or Enforced enables TLS inbound and
-	// TLS configuration must be passed as an inbound option.
+	// TLS configuration must be passed as an inbound option, or as the
+	// cert and key attributes.
 	Mode yarpctls.Mode `config:"mode,interpolate"`
+	// Cert and Key are the PEM-encoded certificate and private key that
+	// the inbound serves, unless TLS configuration is passed as a
+	// transport option.
+	//
+	// 	tls:
+	// 	  mode: enforced
+	// 	  cert: file:///etc/tls/cert.pem
+	// 	  key: env://TLS_KEY
+	Cert yarpcconfig.Secret `config:"cert"`
+	Key  yarpcconfig.Secret `config:"key"`
 }
 
 // OutboundConfig configures a TChannel outbound.
@@ -167,6 +191,18 @@ func (ts *transportSpec) buildTransport(tc *TransportConfig, k *yarpcconfig.Kit)
 	if tc.ConnTimeout != 0 {
 		options.connTimeout = tc.ConnTimeout
 	}
+	if tc.TCPSendBufferSize > 0 {
+		options.tcpBufferSizes.Send = int(tc.TCPSendBufferSize)
+	}
+	if tc.TCPReceiveBufferSize > 0 {
+		options.tcpBufferSizes.Receive = int(tc.TCPReceiveBufferSize)
+	}
+	if tc.MaxFramePayloadSize > 0 {
+		options.maxFramePayloadSize = int(tc.MaxFramePayloadSize)
+	}
+	if err := options.validateMaxFramePayloadSize(); err != nil {
+		return nil, err
+	}
 
 	strategy, err := tc.ConnBackoff.Strategy()
 	if err != nil {
@@ -203,8 +239,15 @@ func (ts *transportSpec) buildInbound(c *InboundConfig, t transport.Transport, k
 		return nil, fmt.Errorf("at most one TChannel inbound may be specified")
 	}
 
+	// Override inbound TLS mode and configuration when not set by an option.
+	if trans.inboundTLSConfig == nil {
+		tlsConfig, err := keypair.ServerConfig(c.TLS.Cert, c.TLS.Key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot configure TLS for TChannel inbound: %v", err)
+		}
+		trans.inboundTLSConfig = tlsConfig
+	}
 	trans.addr = c.Address
-	// Override inbound TLS mode when not set by an option.
 	if trans.inboundTLSMode == nil {
 		trans.inboundTLSMode = &c.TLS.Mode
 	}
@@ -221,5 +264,7 @@ func (ts *transportSpec) buildUnaryOutbound(oc *OutboundConfig, t transport.Tran
 	if err != nil {
 		return nil, err
 	}
-	return x.NewOutbound(chooser), nil
+	o := x.NewOutbound(chooser)
+	o.tlsMode = oc.TLS.Mode
+	return o, nil
 }
@@ -32,6 +32,8 @@ import (
 	tchanneltest "github.com/uber/tchannel-go/testutils"
 	"go.uber.org/yarpc"
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/transport/internal/sockopt"
 	"go.uber.org/yarpc/yarpcconfig"
 )
 
@@ -45,6 +47,93 @@ func TestTransportSpecInvalidOption(t *testing.T) {
 	})
 }
 
+func TestTransportSpecTCPBufferSizes(t *testing.T) {
+	configurator := yarpcconfig.New()
+	require.NoError(t, configurator.RegisterTransport(TransportSpec(WithTCPBufferSize(1<<20, 1<<20))))
+
+	cfg, err := configurator.LoadConfig("foo", map[string]interface{}{
+		"transports": map[string]interface{}{
+			"tchannel": map[string]interface{}{
+				"tcpReceiveBufferSize": 2 << 20,
+			},
+		},
+		"inbounds": map[string]interface{}{
+			"tchannel": map[string]interface{}{"address": "127.0.0.1:0"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, cfg.Inbounds, 1)
+
+	trans := cfg.Inbounds[0].(*Inbound).transport
+	assert.Equal(t, sockopt.BufferSizes{Send: 1 << 20, Receive: 2 << 20}, trans.tcpBufferSizes)
+	assert.NotNil(t, trans.dialer, "expected a dialer that sets buffer sizes")
+
+	d := yarpc.NewDispatcher(cfg)
+	require.NoError(t, d.Start(), "failed to start dispatcher")
+	require.NoError(t, d.Stop(), "failed to stop dispatcher")
+}
+
+func TestTransportSpecMaxFramePayloadSize(t *testing.T) {
+	configurator := yarpcconfig.New()
+	require.NoError(t, configurator.RegisterTransport(TransportSpec()))
+
+	cfg, err := configurator.LoadConfig("foo", map[string]interface{}{
+		"transports": map[string]interface{}{
+			"tchannel": map[string]interface{}{
+				"maxFramePayloadSize": "4KiB",
+			},
+		},
+		"inbounds": map[string]interface{}{
+			"tchannel": map[string]interface{}{"address": "127.0.0.1:0"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, cfg.Inbounds, 1)
+
+	trans := cfg.Inbounds[0].(*Inbound).transport
+	require.IsType(t, &framePool{}, trans.framePool)
+	assert.Equal(t, 4096, trans.framePool.(*framePool).payloadSize)
+
+	_, err = configurator.LoadConfig("foo", map[string]interface{}{
+		"transports": map[string]interface{}{
+			"tchannel": map[string]interface{}{
+				"maxFramePayloadSize": 512,
+			},
+		},
+		"inbounds": map[string]interface{}{
+			"tchannel": map[string]interface{}{"address": "127.0.0.1:0"},
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TChannel frame payload size 512 is less than the minimum of 1024 bytes")
+}
+
+func TestTransportSpecOutboundIntrospect(t *testing.T) {
+	configurator := yarpcconfig.New()
+	require.NoError(t, configurator.RegisterTransport(TransportSpec(OutboundTLSConfigProvider(
+		&fakeOutboundTLSConfigProvider{expectedSpiffeIDs: []string{"test-spiffe"}},
+	))))
+	configurator.MustRegisterPeerList(roundrobin.Spec())
+
+	cfg, err := configurator.LoadConfig("foo", map[string]interface{}{
+		"outbounds": map[string]interface{}{
+			"myservice": map[string]interface{}{
+				"tchannel": map[string]interface{}{
+					"peer": "127.0.0.1:4040",
+					"tls": map[string]interface{}{
+						"mode":       "enforced",
+						"spiffe-ids": []string{"test-spiffe"},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	ob := cfg.Outbounds["myservice"].Unary.(*Outbound)
+	assert.Equal(t, map[string]interface{}{"mode": "enforced"}, ob.Introspect().Config["tls"])
+}
+
 type fakeOutboundTLSConfigProvider struct {
 	returnErr         error
 	expectedSpiffeIDs []string
@@ -202,6 +291,20 @@ func TestTransportSpec(t *testing.T) {
 				`failed to read attribute "least-pending": wat`,
 			},
 		},
+		{
+			desc: "outbound peer list build error",
+			cfg: attrs{
+				"myservice": attrs{
+					"tchannel": attrs{
+						"round-robin": attrs{
+							"capacity": 0,
+							"peers":    []string{"127.0.0.1:4040"},
+						},
+					},
+				},
+			},
+			wantErrors: []string{"Capacity must be greater than 0"},
+		},
 		{
 			desc: "fail TLS outbound with invalid tls mode",
 			cfg: attrs{
@@ -300,6 +403,7 @@ func TestTransportSpec(t *testing.T) {
 		opts := append(inbound.opts, outbound.opts...)
 		err := configurator.RegisterTransport(TransportSpec(opts...))
 		require.NoError(t, err, "failed to register transport spec")
+		configurator.MustRegisterPeerList(roundrobin.Spec())
 
 		cfgData := make(attrs)
 		if inbound.cfg != nil {
@@ -61,6 +61,22 @@
 // 		},
 // 	})
 //
+// Resource Exhaustion
+//
+// TChannel inbounds do not respond to requests that fail with a
+// ResourceExhausted error, including requests rejected by inbound middleware.
+// The request is black-holed, and the caller times out instead of receiving
+// the error.
+//
+// Frames
+//
+// TChannel fragments the bodies and headers of requests and responses into
+// frames of at most 64KiB, including a 16-byte frame header. The TChannel
+// protocol encodes the size of frames in 16 bits, so the limit cannot be
+// raised. Use the WithMaxFramePayloadSize option, or the maxFramePayloadSize
+// attribute of the transport configuration, to lower it. Set the same limit
+// on clients and servers to bound the frames in both directions.
+//
 // Configuration
 //
 // A TChannel transport may be configured using YARPC's configuration system.
@@ -0,0 +1,93 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/uber/tchannel-go"
+	"go.uber.org/zap"
+)
+
+// minFramePayloadSize is the smallest frame payload limit the transport
+// accepts. Call request frames carry the headers of the call, like the
+// service and procedure names, in addition to its arguments.
+const minFramePayloadSize = 1024
+
+// framePool is a TChannel FramePool whose frames have payloads of the given
+// size.
+//
+// TChannel fragments the messages it writes into frames that fill the
+// payloads of the frames it gets from the pool, so shorter payloads lower the
+// size of the frames the transport writes. The payloads keep the capacity of
+// the largest frame the protocol allows, so frames read from peers with a
+// larger limit still fit.
+type framePool struct {
+	payloadSize int
+	pool        sync.Pool
+}
+
+func newFramePool(payloadSize int) *framePool {
+	return &framePool{payloadSize: payloadSize}
+}
+
+func (p *framePool) Get() *tchannel.Frame {
+	f, _ := p.pool.Get().(*tchannel.Frame)
+	if f == nil {
+		f = tchannel.NewFrame(tchannel.MaxFramePayloadSize)
+	}
+	f.Payload = f.Payload[:p.payloadSize]
+	return f
+}
+
+func (p *framePool) Release(f *tchannel.Frame) {
+	p.pool.Put(f)
+}
+
+// validateMaxFramePayloadSize returns an error if the frame payload limit
+// requested with WithMaxFramePayloadSize is too small to use.
+func (o transportOptions) validateMaxFramePayloadSize() error {
+	if o.maxFramePayloadSize != 0 && o.maxFramePayloadSize < minFramePayloadSize {
+		return fmt.Errorf("TChannel frame payload size %d is less than the minimum of %d bytes",
+			o.maxFramePayloadSize, minFramePayloadSize)
+	}
+	return nil
+}
+
+// framePool returns the frame pool for the channels of the transport, or nil
+// to use the default pool of TChannel.
+//
+// TChannel encodes the size of frames in 16 bits, so limits above
+// MaxFramePayloadSize are capped to it.
+func (o transportOptions) framePool(logger *zap.Logger) tchannel.FramePool {
+	size := o.maxFramePayloadSize
+	if size == 0 || size == tchannel.MaxFramePayloadSize {
+		return nil
+	}
+	if size > tchannel.MaxFramePayloadSize {
+		logger.Warn("TChannel frame payload size exceeds the protocol limit, using the limit instead",
+			zap.Int("maxFramePayloadSize", size),
+			zap.Int("limit", tchannel.MaxFramePayloadSize))
+		return nil
+	}
+	return newFramePool(size)
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber/tchannel-go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFramePool(t *testing.T) {
+	pool := newFramePool(2048)
+
+	f := pool.Get()
+	assert.Len(t, f.Payload, 2048, "payload must be limited to the frame payload size")
+	assert.Equal(t, tchannel.MaxFramePayloadSize, cap(f.Payload), "payload must fit the largest frames")
+
+	f.Payload = f.Payload[:0]
+	pool.Release(f)
+	assert.Len(t, pool.Get().Payload, 2048, "released frames must get the limit back")
+}
+
+func TestTransportOptionsFramePool(t *testing.T) {
+	tests := []struct {
+		msg         string
+		size        int
+		wantPool    bool
+		wantWarning bool
+	}{
+		{msg: "default", size: 0},
+		{msg: "protocol limit", size: tchannel.MaxFramePayloadSize},
+		{msg: "lower limit", size: 4096, wantPool: true},
+		{msg: "above protocol limit", size: 1 << 20, wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			core, logs := observer.New(zapcore.WarnLevel)
+			options := transportOptions{maxFramePayloadSize: tt.size}
+			require.NoError(t, options.validateMaxFramePayloadSize())
+
+			pool := options.framePool(zap.New(core))
+			if tt.wantPool {
+				require.IsType(t, &framePool{}, pool)
+				assert.Equal(t, tt.size, pool.(*framePool).payloadSize)
+			} else {
+				assert.Nil(t, pool, "expected the default frame pool")
+			}
+			if tt.wantWarning {
+				assert.Equal(t, 1, logs.Len(), "expected a warning")
+			} else {
+				assert.Zero(t, logs.Len(), "unexpected logs")
+			}
+		})
+	}
+}
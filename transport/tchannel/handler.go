@@ -32,6 +32,7 @@ import (
 	"go.uber.org/multierr"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/bufferpool"
+	intyarpcerrors "go.uber.org/yarpc/internal/yarpcerrors"
 	"go.uber.org/yarpc/pkg/errors"
 	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/zap"
@@ -154,6 +155,9 @@ func (h handler) handle(ctx context.Context, call inboundCall) {
 		if status.Message() != "" {
 			responseWriter.AddHeader(ErrorMessageHeaderKey, status.Message())
 		}
+		if details, ok := intyarpcerrors.DetailsToJSON(status); ok {
+			responseWriter.AddHeader(ErrorDetailsHeaderKey, details)
+		}
 	}
 	if reswErr := responseWriter.Close(); reswErr != nil && !clientTimedOut {
 		if sendSysErr := call.Response().SendSystemError(getSystemError(reswErr)); sendSysErr != nil {
@@ -28,6 +28,7 @@ import (
 
 	"github.com/uber/tchannel-go"
 	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/calldepth"
 	"go.uber.org/yarpc/transport/tchannel/internal"
 	"go.uber.org/yarpc/yarpcerrors"
 )
@@ -39,6 +40,9 @@ const (
 	ErrorNameHeaderKey = "$rpc$-error-name"
 	// ErrorMessageHeaderKey is the response header key for the error message.
 	ErrorMessageHeaderKey = "$rpc$-error-message"
+	// ErrorDetailsHeaderKey is the response header key for the Protobuf error
+	// details, as a JSON array of google.protobuf.Any objects.
+	ErrorDetailsHeaderKey = "$rpc$-error-details"
 	// ServiceHeaderKey is the response header key for the respond service
 	ServiceHeaderKey = "$rpc$-service"
 	// ApplicationErrorNameHeaderKey is the response header key for the application error name.
@@ -56,6 +60,7 @@ var _reservedHeaderKeys = map[string]struct{}{
 	ErrorCodeHeaderKey:               {},
 	ErrorNameHeaderKey:               {},
 	ErrorMessageHeaderKey:            {},
+	ErrorDetailsHeaderKey:            {},
 	ServiceHeaderKey:                 {},
 	ApplicationErrorNameHeaderKey:    {},
 	ApplicationErrorDetailsHeaderKey: {},
@@ -195,6 +200,23 @@ func requestCallerProcedureToHeader(req *transport.Request, reqHeaders map[strin
 	return reqHeaders
 }
 
+// callDepthToHeader adds the call depth of the context as an application
+// header, copying the headers rather than modifying the headers of the
+// request.
+func callDepthToHeader(ctx context.Context, reqHeaders map[string]string) map[string]string {
+	depth, ok := calldepth.HeaderValue(ctx)
+	if !ok {
+		return reqHeaders
+	}
+
+	headers := make(map[string]string, len(reqHeaders)+1)
+	for k, v := range reqHeaders {
+		headers[k] = v
+	}
+	headers[calldepth.Header] = depth
+	return headers
+}
+
 // encodeHeaders encodes headers using the format:
 //
 // 	nh:2 (k~2 v~2){nh}
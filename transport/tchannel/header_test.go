@@ -22,6 +22,7 @@ package tchannel
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io/ioutil"
 	"testing"
@@ -30,6 +31,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/uber/tchannel-go"
 	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/calldepth"
 	"go.uber.org/yarpc/yarpcerrors"
 )
 
@@ -106,6 +108,49 @@ func TestAddCallerProcedureHeader(t *testing.T) {
 	}
 }
 
+func TestAddCallDepthHeader(t *testing.T) {
+	for _, tt := range []struct {
+		desc            string
+		ctx             context.Context
+		headers         map[string]string
+		expectedHeaders map[string]string
+	}{
+		{
+			desc:            "no_call_depth",
+			ctx:             context.Background(),
+			headers:         map[string]string{"header": "value"},
+			expectedHeaders: map[string]string{"header": "value"},
+		},
+		{
+			desc:    "call_depth_and_valid_header",
+			ctx:     calldepth.WithDepth(context.Background(), 2),
+			headers: map[string]string{"header": "value"},
+			expectedHeaders: map[string]string{
+				"rpc-call-depth": "2",
+				"header":         "value",
+			},
+		},
+		{
+			desc:            "call_depth_and_empty_header",
+			ctx:             calldepth.WithDepth(context.Background(), 1),
+			headers:         nil,
+			expectedHeaders: map[string]string{"rpc-call-depth": "1"},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			original := make(map[string]string, len(tt.headers))
+			for k, v := range tt.headers {
+				original[k] = v
+			}
+			headers := callDepthToHeader(tt.ctx, tt.headers)
+			assert.Equal(t, tt.expectedHeaders, headers)
+			if tt.headers != nil {
+				assert.Equal(t, original, tt.headers, "headers of the request must not change")
+			}
+		})
+	}
+}
+
 func TestMoveCallerProcedureToRequest(t *testing.T) {
 	for _, tt := range []struct {
 		desc            string
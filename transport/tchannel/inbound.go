@@ -21,7 +21,10 @@
 package tchannel
 
 import (
+	"strings"
+
 	"go.uber.org/yarpc/api/transport"
+	yarpctls "go.uber.org/yarpc/api/transport/tls"
 	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/pkg/lifecycle"
 	"go.uber.org/zap"
@@ -72,6 +75,11 @@ func (i *Inbound) Start() error {
 	})
 }
 
+// TransportName is the name of the transport of the inbound.
+func (i *Inbound) TransportName() string {
+	return TransportName
+}
+
 // Stop stops the TChannel outbound. This currently does nothing.
 func (i *Inbound) Stop() error {
 	return i.once.Stop(nil)
@@ -88,9 +96,17 @@ func (i *Inbound) Introspect() introspection.InboundStatus {
 	if i.transport.ch != nil {
 		stateString = i.transport.ch.State().String()
 	}
+	tlsMode := yarpctls.Disabled
+	if i.transport.inboundTLSMode != nil {
+		tlsMode = *i.transport.inboundTLSMode
+	}
 	return introspection.InboundStatus{
 		Transport: "tchannel",
 		Endpoint:  i.transport.addr,
 		State:     stateString,
+		Config: map[string]interface{}{
+			"address": i.transport.addr,
+			"tls":     map[string]interface{}{"mode": strings.ToLower(tlsMode.String())},
+		},
 	}
 }
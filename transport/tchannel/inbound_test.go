@@ -31,6 +31,7 @@ import (
 	"github.com/uber/tchannel-go"
 	"go.uber.org/yarpc"
 	"go.uber.org/yarpc/api/transport"
+	yarpctls "go.uber.org/yarpc/api/transport/tls"
 	"go.uber.org/yarpc/api/transport/transporttest"
 	"go.uber.org/yarpc/encoding/raw"
 	"go.uber.org/yarpc/internal/testtime"
@@ -74,6 +75,20 @@ func (nophandler) Handle(ctx context.Context, req *transport.Request, resw trans
 	return nil
 }
 
+func TestInboundIntrospect(t *testing.T) {
+	x, err := NewTransport(ServiceName("foo"), ListenAddr("127.0.0.1:4040"), InboundTLSMode(yarpctls.Permissive))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"address": "127.0.0.1:4040",
+		"tls":     map[string]interface{}{"mode": "permissive"},
+	}, x.NewInbound().Introspect().Config)
+
+	x, err = NewTransport(ServiceName("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"mode": "disabled"}, x.NewInbound().Introspect().Config["tls"])
+}
+
 func TestInboundSubServices(t *testing.T) {
 	it, err := NewTransport(ServiceName("myservice"), ListenAddr("localhost:0"))
 	require.NoError(t, err)
@@ -32,6 +32,7 @@ import (
 	backoffapi "go.uber.org/yarpc/api/backoff"
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
 	"go.uber.org/yarpc/internal/backoff"
+	"go.uber.org/yarpc/transport/internal/sockopt"
 	"go.uber.org/zap"
 )
 
@@ -59,7 +60,10 @@ type transportOptions struct {
 	meter                          *metrics.Scope
 	addr                           string
 	listener                       net.Listener
+	unixSocketPath                 string
 	dialer                         func(ctx context.Context, network, hostPort string) (net.Conn, error)
+	tcpBufferSizes                 sockopt.BufferSizes
+	maxFramePayloadSize            int
 	name                           string
 	connTimeout                    time.Duration
 	connBackoffStrategy            backoffapi.Strategy
@@ -161,6 +165,20 @@ func Listener(l net.Listener) TransportOption {
 	}
 }
 
+// WithUnixSocket configures the transport to listen on a Unix domain socket
+// at the given path instead of a TCP address. The transport advertises itself
+// as "unix://path", and outbounds may address such peers with the same URI,
+// for example, through hostport.Identify("unix:///var/run/myservice.sock").
+//
+// The socket file is removed when the transport stops. This only applies to
+// NewTransport (will not work with NewChannelTransport) and takes precedence
+// over ListenAddr, but not over Listener.
+func WithUnixSocket(path string) TransportOption {
+	return func(t *transportOptions) {
+		t.unixSocketPath = path
+	}
+}
+
 // Dialer sets a dialer function for outbound calls.
 //
 // The function signature matches the net.Dialer DialContext method.
@@ -171,6 +189,44 @@ func Dialer(dialer func(ctx context.Context, network, hostPort string) (net.Conn
 	}
 }
 
+// WithTCPBufferSize specifies the sizes in bytes of the send and receive
+// buffers of the TCP connections of the transport, both dialed and accepted.
+// A size of zero leaves the operating system default, which is often too
+// small for high-throughput services.
+//
+// The operating system may cap the sizes. If the sizes cannot be set, for
+// example on unsupported platforms, the transport logs a warning and proceeds
+// with the default sizes.
+// This only applies to NewTransport (will not work with NewChannelTransport),
+// does not apply to connections dialed by a custom Dialer, and does not apply
+// to a Listener provided by the caller.
+func WithTCPBufferSize(sendBufBytes, recvBufBytes int) TransportOption {
+	return func(t *transportOptions) {
+		t.tcpBufferSizes = sockopt.BufferSizes{Send: sendBufBytes, Receive: recvBufBytes}
+	}
+}
+
+// WithMaxFramePayloadSize limits the payloads of the frames the transport
+// writes to n bytes. TChannel fragments the headers and bodies of requests
+// and responses that do not fit in one frame across several frames.
+//
+// The TChannel protocol encodes the size of frames in 16 bits, which bounds
+// payloads to 65519 bytes, and that is also the default limit. Larger values
+// are accepted but capped to it, with a warning. NewTransport and
+// NewChannelTransport reject values below 1024 bytes.
+//
+// The limit only applies to the frames the transport writes. The transport
+// reads frames of any size the protocol allows, so clients and servers stay
+// compatible whatever limits they use; lower the limit on both sides to keep
+// the frames exchanged in both directions small.
+//
+// This option has no effect if WithChannel was used.
+func WithMaxFramePayloadSize(n int) TransportOption {
+	return func(options *transportOptions) {
+		options.maxFramePayloadSize = n
+	}
+}
+
 // ServiceName informs the NewChannelTransport constructor which service
 // name to use if it needs to construct a root Channel object, as when called
 // without the WithChannel option.
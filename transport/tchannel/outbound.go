@@ -25,10 +25,12 @@ import (
 	"context"
 	"io"
 	"strconv"
+	"strings"
 
 	"github.com/uber/tchannel-go"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
+	yarpctls "go.uber.org/yarpc/api/transport/tls"
 	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/internal/iopool"
 	intyarpcerrors "go.uber.org/yarpc/internal/yarpcerrors"
@@ -53,6 +55,10 @@ type Outbound struct {
 	transport *Transport
 	chooser   peer.Chooser
 	once      *lifecycle.Once
+
+	// tlsMode is the TLS mode of the outbound when it is built from
+	// configuration, for introspection.
+	tlsMode yarpctls.Mode
 }
 
 // NewOutbound builds a new TChannel outbound that selects a peer for each
@@ -82,6 +88,15 @@ func (o *Outbound) Chooser() peer.Chooser {
 	return o.chooser
 }
 
+// SubscribePeerStatus subscribes to the peer status changes of the outbound's
+// peer chooser, if the chooser supports it.
+func (o *Outbound) SubscribePeerStatus(fn func(peer.Identifier, peer.ConnectionStatus)) (unsubscribe func(), ok bool) {
+	if w, ok := o.chooser.(peer.StatusWatcher); ok {
+		return w.Subscribe(fn), true
+	}
+	return nil, false
+}
+
 // Call sends an RPC over this TChannel outbound.
 func (o *Outbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
 	if req == nil {
@@ -144,6 +159,7 @@ func callWithPeer(ctx context.Context, req *transport.Request, peer *tchannel.Pe
 
 	// for tchannel, callerProcedure is added to application headers.
 	reqHeaders = requestCallerProcedureToHeader(req, reqHeaders)
+	reqHeaders = callDepthToHeader(ctx, reqHeaders)
 
 	// baggage headers are transport implementation details that are stripped out (and stored in the context). Users don't interact with it
 	tracingBaggage := tchannel.InjectOutboundSpan(call.Response(), nil)
@@ -238,7 +254,11 @@ func getResponseError(headers transport.Headers) error {
 	}
 	errorName, _ := headers.Get(ErrorNameHeaderKey)
 	errorMessage, _ := headers.Get(ErrorMessageHeaderKey)
-	return intyarpcerrors.NewWithNamef(errorCode, errorName, errorMessage)
+	status := intyarpcerrors.NewWithNamef(errorCode, errorName, errorMessage)
+	if details, ok := headers.Get(ErrorDetailsHeaderKey); ok {
+		status = intyarpcerrors.WithJSONDetails(status, details)
+	}
+	return status
 }
 
 func getApplicationErrorCodeFromHeaders(headers transport.Headers) *yarpcerrors.Code {
@@ -311,6 +331,9 @@ func (o *Outbound) Introspect() introspection.OutboundStatus {
 		Transport: "tchannel",
 		State:     state,
 		Chooser:   chooser,
+		Config: map[string]interface{}{
+			"tls": map[string]interface{}{"mode": strings.ToLower(o.tlsMode.String())},
+		},
 	}
 }
 
@@ -66,6 +66,9 @@ func (o *outboundChannel) start() (err error) {
 		Dialer:              o.dialer,
 		OnPeerStatusChanged: o.t.onPeerStatusChanged,
 		Tracer:              o.t.tracer,
+		DefaultConnectionOptions: tchannel.ConnectionOptions{
+			FramePool: o.t.framePool,
+		},
 	})
 	return err
 }
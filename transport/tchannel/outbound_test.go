@@ -391,6 +391,15 @@ func TestApplicationError(t *testing.T) {
 
 }
 
+func TestOutboundIntrospect(t *testing.T) {
+	x, err := NewTransport(ServiceName("caller"))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"tls": map[string]interface{}{"mode": "disabled"},
+	}, x.NewSingleOutbound("127.0.0.1:4040").Introspect().Config)
+}
+
 func TestStartMultiple(t *testing.T) {
 	out, trans := newSingleOutbound(t, "localhost:4040")
 	defer out.Stop()
@@ -27,12 +27,16 @@ import (
 	"errors"
 	"io"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	tchannelgo "github.com/uber/tchannel-go"
 	"go.uber.org/yarpc/api/peer/peertest"
 	"go.uber.org/yarpc/api/transport"
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
@@ -201,6 +205,209 @@ func TestTLSOutbound(t *testing.T) {
 	assert.Equal(t, "hello", string(resBody))
 }
 
+func TestUnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "test-svc.sock")
+
+	serverTransport, err := tchannel.NewTransport(
+		tchannel.ServiceName("test-svc"),
+		tchannel.WithUnixSocket(socketPath),
+	)
+	require.NoError(t, err)
+	inbound := serverTransport.NewInbound()
+	inbound.SetRouter(testRouter{proc: transport.Procedure{HandlerSpec: transport.NewUnaryHandlerSpec(testServer{})}})
+	require.NoError(t, serverTransport.Start())
+	require.NoError(t, inbound.Start())
+	assert.Equal(t, "unix://"+socketPath, serverTransport.ListenAddr())
+
+	clientTransport, err := tchannel.NewTransport(tchannel.ServiceName("test-client-svc"))
+	require.NoError(t, err)
+	outbound := clientTransport.NewOutbound(peer.NewSingle(hostport.Identify(serverTransport.ListenAddr()), clientTransport))
+	require.NoError(t, clientTransport.Start())
+	defer clientTransport.Stop()
+	require.NoError(t, outbound.Start())
+	defer outbound.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	res, err := outbound.Call(ctx, &transport.Request{
+		Service:   "test-svc",
+		Procedure: "test-proc",
+		Body:      strings.NewReader("hello"),
+	})
+	require.NoError(t, err)
+
+	resBody, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(resBody))
+
+	require.NoError(t, inbound.Stop())
+	require.NoError(t, serverTransport.Stop())
+	_, err = os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err), "socket file must be removed on Stop")
+}
+
+func TestUnixSocketClosedOnStartFailure(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "test-svc.sock")
+
+	// TLS without a configuration fails after the socket is opened.
+	serverTransport, err := tchannel.NewTransport(
+		tchannel.ServiceName("test-svc"),
+		tchannel.WithUnixSocket(socketPath),
+		tchannel.InboundTLSMode(yarpctls.Permissive),
+	)
+	require.NoError(t, err)
+	require.Error(t, serverTransport.Start())
+
+	_, err = os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err), "socket file must be removed when Start fails")
+
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err, "socket path must be free to listen on again")
+	assert.NoError(t, l.Close())
+}
+
+func TestMaxFramePayloadSize(t *testing.T) {
+	const payloadSize = 1024
+
+	var serverWrites, clientWrites maxWrite
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	serverTransport, err := tchannel.NewTransport(
+		tchannel.ServiceName("test-svc"),
+		tchannel.Listener(recordingListener{Listener: listener, writes: &serverWrites}),
+		tchannel.WithMaxFramePayloadSize(payloadSize),
+	)
+	require.NoError(t, err)
+	inbound := serverTransport.NewInbound()
+	inbound.SetRouter(testRouter{proc: transport.Procedure{HandlerSpec: transport.NewUnaryHandlerSpec(testServer{})}})
+	require.NoError(t, serverTransport.Start())
+	defer serverTransport.Stop()
+	require.NoError(t, inbound.Start())
+	defer inbound.Stop()
+
+	clientTransport, err := tchannel.NewTransport(
+		tchannel.ServiceName("test-client-svc"),
+		tchannel.Dialer(func(ctx context.Context, network, hostPort string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, hostPort)
+			if err != nil {
+				return nil, err
+			}
+			return recordingConn{Conn: conn, writes: &clientWrites}, nil
+		}),
+		tchannel.WithMaxFramePayloadSize(payloadSize),
+	)
+	require.NoError(t, err)
+	outbound := clientTransport.NewOutbound(peer.NewSingle(hostport.Identify(serverTransport.ListenAddr()), clientTransport))
+	require.NoError(t, clientTransport.Start())
+	defer clientTransport.Stop()
+	require.NoError(t, outbound.Start())
+	defer outbound.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	body := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	res, err := outbound.Call(ctx, &transport.Request{
+		Service:   "test-svc",
+		Procedure: "test-proc",
+		Body:      bytes.NewReader(body),
+	})
+	require.NoError(t, err)
+
+	resBody, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, resBody)
+
+	assert.LessOrEqual(t, clientWrites.load(), payloadSize+tchannelgo.FrameHeaderSize, "request frames must respect the limit")
+	assert.LessOrEqual(t, serverWrites.load(), payloadSize+tchannelgo.FrameHeaderSize, "response frames must respect the limit")
+}
+
+func TestMaxFramePayloadSizeTooSmall(t *testing.T) {
+	_, err := tchannel.NewTransport(
+		tchannel.ServiceName("test-svc"),
+		tchannel.WithMaxFramePayloadSize(512),
+	)
+	assert.EqualError(t, err, "TChannel frame payload size 512 is less than the minimum of 1024 bytes")
+
+	_, err = tchannel.NewChannelTransport(
+		tchannel.ServiceName("test-svc"),
+		tchannel.WithMaxFramePayloadSize(512),
+	)
+	assert.EqualError(t, err, "TChannel frame payload size 512 is less than the minimum of 1024 bytes")
+}
+
+// maxWrite records the size of the largest write to a connection.
+func TestTransportIntrospect(t *testing.T) {
+	x, err := tchannel.NewTransport(
+		tchannel.ServiceName("foo"),
+		tchannel.ConnTimeout(time.Second),
+		tchannel.WithTCPBufferSize(4096, 8192),
+		tchannel.WithMaxFramePayloadSize(4096),
+	)
+	require.NoError(t, err)
+
+	status := x.Introspect()
+	assert.Equal(t, tchannel.TransportName, status.Name)
+	assert.Equal(t, "Idle", status.State)
+	assert.Equal(t, map[string]interface{}{
+		"connTimeout":          "1s",
+		"tcpSendBufferSize":    4096,
+		"tcpReceiveBufferSize": 8192,
+		"maxFramePayloadSize":  4096,
+	}, status.Config)
+
+	x, err = tchannel.NewTransport(tchannel.ServiceName("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, tchannelgo.MaxFramePayloadSize, x.Introspect().Config["maxFramePayloadSize"],
+		"expected the TChannel maximum by default")
+}
+
+type maxWrite struct {
+	mu  sync.Mutex
+	max int
+}
+
+func (m *maxWrite) record(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n > m.max {
+		m.max = n
+	}
+}
+
+func (m *maxWrite) load() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.max
+}
+
+type recordingConn struct {
+	net.Conn
+
+	writes *maxWrite
+}
+
+func (c recordingConn) Write(b []byte) (int, error) {
+	c.writes.record(len(b))
+	return c.Conn.Write(b)
+}
+
+type recordingListener struct {
+	net.Listener
+
+	writes *maxWrite
+}
+
+func (l recordingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return recordingConn{Conn: conn, writes: l.writes}, nil
+}
+
 type testRouter struct {
 	proc transport.Procedure
 }
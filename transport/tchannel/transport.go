@@ -36,7 +36,9 @@ import (
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
+	"go.uber.org/yarpc/api/x/introspection"
 	"go.uber.org/yarpc/pkg/lifecycle"
+	"go.uber.org/yarpc/transport/internal/sockopt"
 	"go.uber.org/yarpc/transport/internal/tls/dialer"
 	"go.uber.org/yarpc/transport/internal/tls/muxlistener"
 	"go.uber.org/zap"
@@ -67,7 +69,10 @@ type Transport struct {
 	name              string
 	addr              string
 	listener          net.Listener
+	unixSocketPath    string
 	dialer            func(ctx context.Context, network, hostPort string) (net.Conn, error)
+	tcpBufferSizes    sockopt.BufferSizes
+	framePool         tchannel.FramePool
 	newResponseWriter func(inboundCallResponse, tchannel.Format, headerCase) responseWriter
 
 	connTimeout         time.Duration
@@ -85,6 +90,10 @@ type Transport struct {
 
 	outboundTLSConfigProvider yarpctls.OutboundTLSConfigProvider
 	outboundChannels          []*outboundChannel
+
+	// config holds the resolved configuration of the transport for
+	// introspection.
+	config map[string]interface{}
 }
 
 // NewTransport is a YARPC transport that facilitates sending and receiving
@@ -105,6 +114,10 @@ func NewTransport(opts ...TransportOption) (*Transport, error) {
 		return nil, fmt.Errorf("NewTransport does not accept WithChannel, use NewChannelTransport")
 	}
 
+	if err := options.validateMaxFramePayloadSize(); err != nil {
+		return nil, err
+	}
+
 	return options.newTransport(), nil
 }
 
@@ -117,12 +130,19 @@ func (o transportOptions) newTransport() *Transport {
 	if o.originalHeaders {
 		headerCase = originalHeaderCase
 	}
+	dialer := o.dialer
+	if dialer == nil && !o.tcpBufferSizes.IsZero() {
+		dialer = (&net.Dialer{Control: o.tcpBufferSizes.Control(logger)}).DialContext
+	}
 	return &Transport{
 		once:                           lifecycle.NewOnce(),
 		name:                           o.name,
 		addr:                           o.addr,
 		listener:                       o.listener,
-		dialer:                         o.dialer,
+		unixSocketPath:                 o.unixSocketPath,
+		dialer:                         dialer,
+		tcpBufferSizes:                 o.tcpBufferSizes,
+		framePool:                      o.framePool(logger),
 		connTimeout:                    o.connTimeout,
 		connBackoffStrategy:            o.connBackoffStrategy,
 		peers:                          make(map[string]*tchannelPeer),
@@ -136,6 +156,22 @@ func (o transportOptions) newTransport() *Transport {
 		inboundTLSConfig:               o.inboundTLSConfig,
 		inboundTLSMode:                 o.inboundTLSMode,
 		outboundTLSConfigProvider:      o.outboundTLSConfigProvider,
+		config:                         o.introspectConfig(),
+	}
+}
+
+// introspectConfig returns the resolved options of the transport, keyed as
+// in YARPC configuration.
+func (o transportOptions) introspectConfig() map[string]interface{} {
+	maxFramePayloadSize := o.maxFramePayloadSize
+	if maxFramePayloadSize == 0 || maxFramePayloadSize > tchannel.MaxFramePayloadSize {
+		maxFramePayloadSize = tchannel.MaxFramePayloadSize
+	}
+	return map[string]interface{}{
+		"connTimeout":          o.connTimeout.String(),
+		"tcpSendBufferSize":    o.tcpBufferSizes.Send,
+		"tcpReceiveBufferSize": o.tcpBufferSizes.Receive,
+		"maxFramePayloadSize":  maxFramePayloadSize,
 	}
 }
 
@@ -144,6 +180,20 @@ func (t *Transport) ListenAddr() string {
 	return t.addr
 }
 
+// Introspect returns the state and the resolved configuration of the
+// transport for introspection purposes.
+func (t *Transport) Introspect() introspection.TransportStatus {
+	config := make(map[string]interface{}, len(t.config))
+	for k, v := range t.config {
+		config[k] = v
+	}
+	return introspection.TransportStatus{
+		Name:   TransportName,
+		State:  t.once.State().String(),
+		Config: config,
+	}
+}
+
 // RetainPeer adds a peer subscriber (typically a peer chooser) and causes the
 // transport to maintain persistent connections with that peer.
 func (t *Transport) RetainPeer(pid peer.Identifier, sub peer.Subscriber) (peer.Peer, error) {
@@ -229,8 +279,11 @@ func (t *Transport) start() error {
 			excludeServiceHeaderInResponse: t.excludeServiceHeaderInResponse,
 		},
 		OnPeerStatusChanged: t.onPeerStatusChanged,
-		Dialer:              t.dialer,
+		Dialer:              t.dialContext,
 		SkipHandlerMethods:  skipHandlerMethods,
+		DefaultConnectionOptions: tchannel.ConnectionOptions{
+			FramePool: t.framePool,
+		},
 	}
 	ch, err := tchannel.NewChannel(t.name, &chopts)
 	if err != nil {
@@ -245,6 +298,12 @@ func (t *Transport) start() error {
 	}
 
 	listener := t.listener
+	if listener == nil && t.unixSocketPath != "" {
+		listener, err = listenUnix(t.unixSocketPath)
+		if err != nil {
+			return err
+		}
+	}
 	if listener == nil {
 		addr := t.addr
 		// Default to ListenIP if addr wasn't given.
@@ -260,12 +319,28 @@ func (t *Transport) start() error {
 
 		// TODO(abg): If addr was just the port (":4040"), we want to use
 		// ListenIP() + ":4040" rather than just ":4040".
-		listener, err = net.Listen("tcp", addr)
+		lc := net.ListenConfig{}
+		if !t.tcpBufferSizes.IsZero() {
+			lc.Control = t.tcpBufferSizes.Control(t.logger)
+		}
+		listener, err = lc.Listen(context.Background(), "tcp", addr)
 		if err != nil {
 			return err
 		}
 	}
 
+	var serving bool
+	if listener != t.listener {
+		// Close the listener we opened, which also removes the socket file of
+		// a Unix domain socket, unless the channel takes it over.
+		opened := listener
+		defer func() {
+			if !serving {
+				_ = opened.Close()
+			}
+		}()
+	}
+
 	if t.inboundTLSMode != nil && *t.inboundTLSMode != yarpctls.Disabled {
 		if t.inboundTLSConfig == nil {
 			return errors.New("tchannel TLS enabled but configuration not provided")
@@ -285,6 +360,7 @@ func (t *Transport) start() error {
 	if err := t.ch.Serve(listener); err != nil {
 		return err
 	}
+	serving = true
 	t.addr = t.ch.PeerInfo().HostPort
 
 	for _, outboundChannel := range t.outboundChannels {
@@ -309,6 +385,9 @@ func (t *Transport) stop() error {
 		outboundChannel.stop()
 	}
 	t.connectorsGroup.Wait()
+	if t.listener == nil && t.unixSocketPath != "" {
+		return removeUnixSocket(t.unixSocketPath)
+	}
 	return nil
 }
 
@@ -0,0 +1,92 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tchannel
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+)
+
+// unixSocketScheme is the prefix of peer addresses that are reachable over
+// Unix domain sockets.
+const unixSocketScheme = "unix://"
+
+// splitUnixAddr returns the network and address to dial for the given TChannel
+// host-port, which is either a TCP host-port or a unix:// URI.
+func splitUnixAddr(hostPort string) (network, addr string) {
+	if strings.HasPrefix(hostPort, unixSocketScheme) {
+		return "unix", strings.TrimPrefix(hostPort, unixSocketScheme)
+	}
+	return "tcp", hostPort
+}
+
+// dialContext dials peers on behalf of the root TChannel Channel.
+//
+// TChannel always asks for a "tcp" connection, so peers identified by unix://
+// URIs are translated to a "unix" network before reaching the user-provided
+// dialer, if any.
+func (t *Transport) dialContext(ctx context.Context, network, hostPort string) (net.Conn, error) {
+	if network == "tcp" {
+		network, hostPort = splitUnixAddr(hostPort)
+	}
+	if t.dialer != nil {
+		return t.dialer(ctx, network, hostPort)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, hostPort)
+}
+
+// unixListener is a Unix domain socket listener that reports its address as a
+// unix:// URI so that TChannel advertises an address other transports can dial.
+type unixListener struct {
+	net.Listener
+}
+
+func listenUnix(path string) (net.Listener, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return unixListener{Listener: l}, nil
+}
+
+func (l unixListener) Addr() net.Addr {
+	return unixAddr{Addr: l.Listener.Addr()}
+}
+
+type unixAddr struct {
+	net.Addr
+}
+
+func (a unixAddr) String() string {
+	return unixSocketScheme + a.Addr.String()
+}
+
+// removeUnixSocket removes the socket file at the given path, ignoring files
+// that have already been cleaned up by closing the listener.
+func removeUnixSocket(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
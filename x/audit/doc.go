@@ -0,0 +1,42 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package audit provides inbound middleware that records an audit entry for
+// every unary call a service handles, for services that must keep a trail of
+// who called what and with which outcome.
+//
+// Entries are handed to an AuditWriter once the handler returns. The file
+// writer appends them to a file as newline-delimited JSON:
+//
+// 	writer := audit.NewFileWriter("/var/log/myservice/audit.log")
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: audit.NewInboundMiddleware(writer,
+// 				audit.RedactHeaders("authorization", "x-api-key"),
+// 				audit.Logger(logger),
+// 			),
+// 		},
+// 	})
+//
+// Entries identify requests by the SHA-256 hash of their body rather than the
+// body itself, and redacted headers are recorded with their values replaced.
+// Failing to write an entry is logged as a warning and never fails the call.
+package audit
@@ -0,0 +1,112 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func readEntries(t *testing.T, path string) []AuditEntry {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), "malformed line %q", scanner.Text())
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestFileWriter(t *testing.T) {
+	path := filepath.Join(tempDir(t), "audit.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"procedure":"existing"}`+"\n"), 0600))
+
+	entry := AuditEntry{
+		Timestamp:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Caller:      "caller",
+		Service:     "service",
+		Procedure:   "Store::get",
+		Headers:     map[string]string{"authorization": Redacted},
+		RequestHash: hash("hello"),
+		StatusCode:  yarpcerrors.CodeNotFound,
+		Duration:    time.Second,
+	}
+
+	w := NewFileWriter(path)
+	require.NoError(t, w.WriteEntry(entry))
+	require.NoError(t, w.(io.Closer).Close())
+	// Writing after closing reopens the file.
+	require.NoError(t, w.WriteEntry(entry))
+	require.NoError(t, w.(io.Closer).Close())
+
+	assert.Equal(t, []AuditEntry{{Procedure: "existing"}, entry, entry}, readEntries(t, path),
+		"entries must be appended")
+}
+
+func TestFileWriterConcurrent(t *testing.T) {
+	path := filepath.Join(tempDir(t), "audit.log")
+	w := NewFileWriter(path)
+	defer w.(io.Closer).Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, w.WriteEntry(AuditEntry{Procedure: "Store::get", RequestHash: hash("hello")}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, readEntries(t, path), 200)
+}
+
+func TestFileWriterError(t *testing.T) {
+	w := NewFileWriter(filepath.Join(tempDir(t), "missing", "audit.log"))
+	assert.Error(t, w.WriteEntry(AuditEntry{}))
+	assert.NoError(t, w.(io.Closer).Close())
+}
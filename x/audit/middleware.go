@@ -0,0 +1,215 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// Redacted replaces the values of redacted headers in audit entries.
+const Redacted = "[REDACTED]"
+
+var _timeNow = time.Now // for tests
+
+// AuditEntry records a single call handled by a service.
+type AuditEntry struct {
+	// Timestamp is the time at which the call was received.
+	Timestamp time.Time `json:"timestamp"`
+
+	Caller    string `json:"caller"`
+	Service   string `json:"service"`
+	Procedure string `json:"procedure"`
+
+	// Headers are the request headers, with lower-case keys, and with the
+	// values of redacted headers replaced by Redacted.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// RequestHash is the hex-encoded SHA-256 hash of the request body.
+	RequestHash string `json:"requestHash"`
+
+	// StatusCode is the code of the error the call failed with, or
+	// yarpcerrors.CodeOK.
+	// Application errors without an error are recorded with the code from
+	// their metadata if any, and yarpcerrors.CodeUnknown otherwise.
+	StatusCode yarpcerrors.Code `json:"statusCode"`
+
+	// ApplicationError reports whether the call failed with an application
+	// error.
+	ApplicationError bool `json:"applicationError,omitempty"`
+
+	// Duration is the time the handler took, in nanoseconds when encoded.
+	Duration time.Duration `json:"duration"`
+}
+
+// AuditWriter persists audit entries.
+//
+// WriteEntry is called concurrently from the handlers of different calls.
+type AuditWriter interface {
+	WriteEntry(AuditEntry) error
+}
+
+// Option customizes the behavior of the audit middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	redacted map[string]struct{}
+	logger   *zap.Logger
+}
+
+// RedactHeaders replaces the values of the given headers in audit entries
+// with Redacted. Header names are case-insensitive.
+func RedactHeaders(names ...string) Option {
+	return optionFunc(func(o *options) {
+		for _, name := range names {
+			o.redacted[transport.CanonicalizeHeaderKey(name)] = struct{}{}
+		}
+	})
+}
+
+// Logger sets the logger to which failures to write audit entries are
+// logged.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+type inboundMiddleware struct {
+	writer AuditWriter
+	opts   options
+}
+
+// NewInboundMiddleware builds unary inbound middleware that writes an audit
+// entry to the given writer for every call, once its handler returns.
+//
+// The middleware reads the request body to hash it, and hands the handler a
+// copy of it.
+func NewInboundMiddleware(writer AuditWriter, opts ...Option) middleware.UnaryInbound {
+	o := options{
+		redacted: make(map[string]struct{}),
+		logger:   zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return &inboundMiddleware{writer: writer, opts: o}
+}
+
+func (m *inboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	started := _timeNow()
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return err
+		}
+	}
+	req.Body = bytes.NewReader(body)
+	hash := sha256.Sum256(body)
+
+	w := &statusWriter{ResponseWriter: resw}
+	err := h.Handle(ctx, req, w)
+
+	entry := AuditEntry{
+		Timestamp:        started,
+		Caller:           req.Caller,
+		Service:          req.Service,
+		Procedure:        req.Procedure,
+		Headers:          m.headers(req.Headers),
+		RequestHash:      hex.EncodeToString(hash[:]),
+		StatusCode:       statusCode(err, w),
+		ApplicationError: w.isApplicationError,
+		Duration:         _timeNow().Sub(started),
+	}
+	if werr := m.writer.WriteEntry(entry); werr != nil {
+		m.opts.logger.Warn("failed to write audit entry",
+			zap.String("service", req.Service),
+			zap.String("procedure", req.Procedure),
+			zap.Error(werr))
+	}
+	return err
+}
+
+func (m *inboundMiddleware) headers(h transport.Headers) map[string]string {
+	if h.Len() == 0 {
+		return nil
+	}
+	headers := make(map[string]string, h.Len())
+	for k, v := range h.Items() {
+		if _, ok := m.opts.redacted[k]; ok {
+			v = Redacted
+		}
+		headers[k] = v
+	}
+	return headers
+}
+
+func statusCode(err error, w *statusWriter) yarpcerrors.Code {
+	if err != nil {
+		return yarpcerrors.FromError(err).Code()
+	}
+	if !w.isApplicationError {
+		return yarpcerrors.CodeOK
+	}
+	if w.applicationErrorMeta != nil && w.applicationErrorMeta.Code != nil {
+		return *w.applicationErrorMeta.Code
+	}
+	return yarpcerrors.CodeUnknown
+}
+
+// statusWriter records whether the handler reported an application error.
+type statusWriter struct {
+	transport.ResponseWriter
+
+	isApplicationError   bool
+	applicationErrorMeta *transport.ApplicationErrorMeta
+}
+
+var _ transport.ApplicationErrorMetaSetter = (*statusWriter)(nil)
+
+func (w *statusWriter) SetApplicationError() {
+	w.isApplicationError = true
+	w.ResponseWriter.SetApplicationError()
+}
+
+func (w *statusWriter) SetApplicationErrorMeta(meta *transport.ApplicationErrorMeta) {
+	w.applicationErrorMeta = meta
+	if setter, ok := w.ResponseWriter.(transport.ApplicationErrorMetaSetter); ok {
+		setter.SetApplicationErrorMeta(meta)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type handlerFunc func(context.Context, *transport.Request, transport.ResponseWriter) error
+
+func (f handlerFunc) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	return f(ctx, req, resw)
+}
+
+type memoryWriter struct {
+	entries []AuditEntry
+	err     error
+}
+
+func (w *memoryWriter) WriteEntry(entry AuditEntry) error {
+	w.entries = append(w.entries, entry)
+	return w.err
+}
+
+func stubTime(t *testing.T, times ...time.Time) {
+	prev := _timeNow
+	t.Cleanup(func() { _timeNow = prev })
+	_timeNow = func() time.Time {
+		now := times[0]
+		times = times[1:]
+		return now
+	}
+}
+
+func newRequest(body string) *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "Store::get",
+		Encoding:  "raw",
+		Headers: transport.NewHeaders().
+			With("Authorization", "Bearer s3cr3t").
+			With("x-request-id", "42"),
+		Body: bytes.NewBufferString(body),
+	}
+}
+
+func hash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestInboundMiddleware(t *testing.T) {
+	started := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	notFound := yarpcerrors.CodeNotFound
+
+	tests := []struct {
+		desc             string
+		handle           func(transport.ResponseWriter) error
+		wantErr          bool
+		wantCode         yarpcerrors.Code
+		wantAppError     bool
+		wantAppErrorMeta bool
+	}{
+		{
+			desc:     "success",
+			handle:   func(transport.ResponseWriter) error { return nil },
+			wantCode: yarpcerrors.CodeOK,
+		},
+		{
+			desc: "error",
+			handle: func(transport.ResponseWriter) error {
+				return yarpcerrors.PermissionDeniedErrorf("denied")
+			},
+			wantErr:  true,
+			wantCode: yarpcerrors.CodePermissionDenied,
+		},
+		{
+			desc:     "unknown error",
+			handle:   func(transport.ResponseWriter) error { return errors.New("great sadness") },
+			wantErr:  true,
+			wantCode: yarpcerrors.CodeUnknown,
+		},
+		{
+			desc: "application error",
+			handle: func(resw transport.ResponseWriter) error {
+				resw.SetApplicationError()
+				return nil
+			},
+			wantCode:     yarpcerrors.CodeUnknown,
+			wantAppError: true,
+		},
+		{
+			desc: "application error with code",
+			handle: func(resw transport.ResponseWriter) error {
+				resw.SetApplicationError()
+				resw.(transport.ApplicationErrorMetaSetter).SetApplicationErrorMeta(
+					&transport.ApplicationErrorMeta{Name: "NotFound", Code: &notFound})
+				return nil
+			},
+			wantCode:         yarpcerrors.CodeNotFound,
+			wantAppError:     true,
+			wantAppErrorMeta: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			stubTime(t, started, started.Add(5*time.Millisecond))
+			writer := &memoryWriter{}
+			mw := NewInboundMiddleware(writer, RedactHeaders("AUTHORIZATION"))
+
+			resw := &transporttest.FakeResponseWriter{}
+			err := mw.Handle(context.Background(), newRequest("hello"), resw,
+				handlerFunc(func(_ context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+					body, err := ioutil.ReadAll(req.Body)
+					require.NoError(t, err)
+					assert.Equal(t, "hello", string(body), "handler must see the request body")
+					return tt.handle(resw)
+				}))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantAppError, resw.IsApplicationError, "application error must be forwarded")
+			if tt.wantAppErrorMeta {
+				assert.NotNil(t, resw.ApplicationErrorMeta, "application error meta must be forwarded")
+			}
+
+			require.Len(t, writer.entries, 1)
+			assert.Equal(t, AuditEntry{
+				Timestamp: started,
+				Caller:    "caller",
+				Service:   "service",
+				Procedure: "Store::get",
+				Headers: map[string]string{
+					"authorization": Redacted,
+					"x-request-id":  "42",
+				},
+				RequestHash:      hash("hello"),
+				StatusCode:       tt.wantCode,
+				ApplicationError: tt.wantAppError,
+				Duration:         5 * time.Millisecond,
+			}, writer.entries[0])
+		})
+	}
+}
+
+func TestInboundMiddlewareNoBody(t *testing.T) {
+	writer := &memoryWriter{}
+	mw := NewInboundMiddleware(writer)
+
+	req := &transport.Request{Service: "service", Procedure: "ping"}
+	err := mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{},
+		handlerFunc(func(context.Context, *transport.Request, transport.ResponseWriter) error { return nil }))
+	require.NoError(t, err)
+
+	require.Len(t, writer.entries, 1)
+	assert.Equal(t, hash(""), writer.entries[0].RequestHash)
+	assert.Nil(t, writer.entries[0].Headers)
+}
+
+func TestInboundMiddlewareWriteError(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	writer := &memoryWriter{err: errors.New("disk full")}
+	mw := NewInboundMiddleware(writer, Logger(zap.New(core)))
+
+	err := mw.Handle(context.Background(), newRequest("hello"), &transporttest.FakeResponseWriter{},
+		handlerFunc(func(context.Context, *transport.Request, transport.ResponseWriter) error { return nil }))
+	require.NoError(t, err, "audit failures must not fail calls")
+
+	entries := logs.FilterMessage("failed to write audit entry").AllUntimed()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	assert.Equal(t, "disk full", entries[0].ContextMap()["error"])
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package backpressure
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/recordwriter"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+type coalesceMiddleware struct {
+	keyFn      func(*transport.Request) string
+	maxWaiters int
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+var _ middleware.UnaryInbound = (*coalesceMiddleware)(nil)
+
+// call is a request being handled whose response is shared by the requests
+// with the same key that arrive while it is in progress.
+type call struct {
+	done    chan struct{}
+	waiters int
+
+	res response
+	err error
+	// abandoned is set if the request failed because its own context ended,
+	// in which case its error is not shared with the waiting requests.
+	abandoned bool
+}
+
+// response is the part of the response of a handler that can be replayed to
+// other requests.
+type response struct {
+	headers              transport.Headers
+	body                 []byte
+	applicationError     bool
+	applicationErrorMeta *transport.ApplicationErrorMeta
+}
+
+// NewCoalesceMiddleware builds unary inbound middleware that collapses
+// concurrent requests with the same key into a single call to the handler.
+//
+// The key of a request is given by keyFn, which must not read the body of
+// the request. Requests with an empty key are always handled. A request whose
+// key matches a request being handled waits for it to complete and receives
+// the same response or error, while still honoring its own context. If the
+// request being handled fails because its own context ended, the requests
+// waiting for it do not receive its error: one of them is handled in its
+// place and the others wait for that one instead. At most
+// maxWaiters requests wait for each key; the requests that arrive after them
+// fail with a ResourceExhausted error. If maxWaiters is zero or less, no
+// request waits and all duplicates of a request being handled are rejected.
+func NewCoalesceMiddleware(keyFn func(*transport.Request) string, maxWaiters int) middleware.UnaryInbound {
+	if maxWaiters < 0 {
+		maxWaiters = 0
+	}
+	return &coalesceMiddleware{
+		keyFn:      keyFn,
+		maxWaiters: maxWaiters,
+		calls:      make(map[string]*call),
+	}
+}
+
+func (m *coalesceMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	var key string
+	if m.keyFn != nil {
+		key = m.keyFn(req)
+	}
+	if key == "" {
+		return h.Handle(ctx, req, resw)
+	}
+
+	for {
+		m.mu.Lock()
+		c, ok := m.calls[key]
+		if !ok {
+			c = &call{done: make(chan struct{})}
+			m.calls[key] = c
+			m.mu.Unlock()
+			return m.lead(ctx, key, c, req, resw, h)
+		}
+		if c.waiters >= m.maxWaiters {
+			m.mu.Unlock()
+			return yarpcerrors.ResourceExhaustedErrorf(
+				"too many requests waiting for a coalesced request to procedure %q of service %q",
+				req.Procedure, req.Service)
+		}
+		c.waiters++
+		m.mu.Unlock()
+
+		if replayed, err := m.wait(ctx, c, resw); replayed {
+			return err
+		}
+		// The request that was handled ran out of time, so this request is
+		// handled, or waits for another request that is.
+	}
+}
+
+// lead handles a request on behalf of the requests with the same key that
+// arrive while it is in progress.
+func (m *coalesceMiddleware) lead(ctx context.Context, key string, c *call, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	// Release the waiting requests even if the handler panics.
+	c.err = yarpcerrors.InternalErrorf(
+		"coalesced request to procedure %q of service %q did not complete", req.Procedure, req.Service)
+	defer func() {
+		m.mu.Lock()
+		delete(m.calls, key)
+		m.mu.Unlock()
+		close(c.done)
+	}()
+
+	rw := recordwriter.New(resw)
+	err := h.Handle(ctx, req, rw)
+	c.err = err
+	c.abandoned = err != nil && ctx.Err() != nil && isContextError(err)
+	c.res = response{
+		headers:              rw.Headers(),
+		body:                 rw.Body(),
+		applicationError:     rw.IsApplicationError(),
+		applicationErrorMeta: rw.ApplicationErrorMeta(),
+	}
+	return err
+}
+
+// wait replays the response of the call once it completes, unless the
+// context of the waiting request ends first. It reports whether the request
+// is done, which it is not if the call was abandoned.
+func (m *coalesceMiddleware) wait(ctx context.Context, c *call, resw transport.ResponseWriter) (replayed bool, err error) {
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		m.mu.Lock()
+		c.waiters--
+		m.mu.Unlock()
+		return true, ctx.Err()
+	}
+	if c.abandoned {
+		return false, nil
+	}
+
+	if c.res.headers.Len() > 0 {
+		resw.AddHeaders(c.res.headers)
+	}
+	if c.res.applicationError {
+		resw.SetApplicationError()
+	}
+	if c.res.applicationErrorMeta != nil {
+		if setter, ok := resw.(transport.ApplicationErrorMetaSetter); ok {
+			setter.SetApplicationErrorMeta(c.res.applicationErrorMeta)
+		}
+	}
+	if len(c.res.body) > 0 {
+		if _, err := resw.Write(c.res.body); err != nil {
+			return true, err
+		}
+	}
+	return true, c.err
+}
+
+// isContextError reports whether an error is the result of a context that
+// was cancelled or whose deadline passed.
+func isContextError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	if !yarpcerrors.IsStatus(err) {
+		return false
+	}
+	switch yarpcerrors.FromError(err).Code() {
+	case yarpcerrors.CodeCancelled, yarpcerrors.CodeDeadlineExceeded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,327 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package backpressure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// blockingHandler holds every request until it is released, then responds
+// with the configured response.
+type blockingHandler struct {
+	entered chan struct{}
+	release chan struct{}
+	respond func(transport.ResponseWriter) error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func newBlockingHandler(respond func(transport.ResponseWriter) error) *blockingHandler {
+	return &blockingHandler{
+		entered: make(chan struct{}, 10),
+		release: make(chan struct{}),
+		respond: respond,
+	}
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	h.mu.Lock()
+	h.calls++
+	h.mu.Unlock()
+
+	h.entered <- struct{}{}
+	<-h.release
+	return h.respond(resw)
+}
+
+func (h *blockingHandler) numCalls() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.calls
+}
+
+type handlerFunc func(context.Context, *transport.Request, transport.ResponseWriter) error
+
+func (f handlerFunc) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	return f(ctx, req, resw)
+}
+
+func procedureKey(req *transport.Request) string {
+	return req.Procedure
+}
+
+func newRequest(procedure string) *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: procedure,
+		Encoding:  "raw",
+		Body:      bytes.NewReader(nil),
+	}
+}
+
+// waitForWaiters blocks until n requests wait for the given key.
+func waitForWaiters(t *testing.T, m *coalesceMiddleware, key string, n int) {
+	require.Eventually(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		c, ok := m.calls[key]
+		return ok && c.waiters == n
+	}, time.Second, time.Millisecond, "expected %d waiters for %q", n, key)
+}
+
+type result struct {
+	resw *transporttest.FakeResponseWriter
+	err  error
+}
+
+func handleAsync(ctx context.Context, m *coalesceMiddleware, h transport.UnaryHandler, req *transport.Request) <-chan result {
+	ch := make(chan result, 1)
+	go func() {
+		resw := new(transporttest.FakeResponseWriter)
+		err := m.Handle(ctx, req, resw, h)
+		ch <- result{resw: resw, err: err}
+	}()
+	return ch
+}
+
+func TestCoalesceSharesResponse(t *testing.T) {
+	meta := &transport.ApplicationErrorMeta{Name: "NotReady"}
+	h := newBlockingHandler(func(resw transport.ResponseWriter) error {
+		resw.AddHeaders(transport.NewHeaders().With("foo", "bar"))
+		resw.SetApplicationError()
+		resw.(transport.ApplicationErrorMetaSetter).SetApplicationErrorMeta(meta)
+		_, err := resw.Write([]byte("hello"))
+		return err
+	})
+	m := NewCoalesceMiddleware(procedureKey, 3).(*coalesceMiddleware)
+
+	leader := handleAsync(context.Background(), m, h, newRequest("get"))
+	<-h.entered
+	var waiters []<-chan result
+	for i := 0; i < 3; i++ {
+		waiters = append(waiters, handleAsync(context.Background(), m, h, newRequest("get")))
+	}
+	waitForWaiters(t, m, "get", 3)
+	close(h.release)
+
+	for _, ch := range append(waiters, leader) {
+		r := <-ch
+		require.NoError(t, r.err)
+		assert.Equal(t, "hello", r.resw.Body.String())
+		assert.Equal(t, map[string]string{"foo": "bar"}, r.resw.Headers.Items())
+		assert.True(t, r.resw.IsApplicationError)
+		assert.Equal(t, meta, r.resw.ApplicationErrorMeta)
+	}
+	assert.Equal(t, 1, h.numCalls())
+	assert.Empty(t, m.calls, "calls must be forgotten once they complete")
+}
+
+func TestCoalesceSharesError(t *testing.T) {
+	want := yarpcerrors.UnavailableErrorf("try again")
+	h := newBlockingHandler(func(transport.ResponseWriter) error { return want })
+	m := NewCoalesceMiddleware(procedureKey, 1).(*coalesceMiddleware)
+
+	leader := handleAsync(context.Background(), m, h, newRequest("get"))
+	<-h.entered
+	waiter := handleAsync(context.Background(), m, h, newRequest("get"))
+	waitForWaiters(t, m, "get", 1)
+	close(h.release)
+
+	assert.Equal(t, want, (<-leader).err)
+	assert.Equal(t, want, (<-waiter).err)
+	assert.Equal(t, 1, h.numCalls())
+}
+
+func TestCoalesceRejectsBeyondMaxWaiters(t *testing.T) {
+	h := newBlockingHandler(func(transport.ResponseWriter) error { return nil })
+	m := NewCoalesceMiddleware(procedureKey, 1).(*coalesceMiddleware)
+
+	leader := handleAsync(context.Background(), m, h, newRequest("get"))
+	<-h.entered
+	waiter := handleAsync(context.Background(), m, h, newRequest("get"))
+	waitForWaiters(t, m, "get", 1)
+
+	err := m.Handle(context.Background(), newRequest("get"), new(transporttest.FakeResponseWriter), h)
+	require.Error(t, err)
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+	assert.Contains(t, err.Error(), `procedure "get" of service "service"`)
+
+	// Other keys have waiters of their own.
+	other := handleAsync(context.Background(), m, h, newRequest("list"))
+	<-h.entered
+
+	close(h.release)
+	assert.NoError(t, (<-leader).err)
+	assert.NoError(t, (<-waiter).err)
+	assert.NoError(t, (<-other).err)
+	assert.Equal(t, 2, h.numCalls())
+}
+
+func TestCoalesceWithoutWaiters(t *testing.T) {
+	h := newBlockingHandler(func(transport.ResponseWriter) error { return nil })
+	m := NewCoalesceMiddleware(procedureKey, -1).(*coalesceMiddleware)
+
+	leader := handleAsync(context.Background(), m, h, newRequest("get"))
+	<-h.entered
+
+	err := m.Handle(context.Background(), newRequest("get"), new(transporttest.FakeResponseWriter), h)
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+
+	close(h.release)
+	assert.NoError(t, (<-leader).err)
+}
+
+func TestCoalesceEmptyKey(t *testing.T) {
+	h := newBlockingHandler(func(transport.ResponseWriter) error { return nil })
+	m := NewCoalesceMiddleware(func(*transport.Request) string { return "" }, 0).(*coalesceMiddleware)
+
+	first := handleAsync(context.Background(), m, h, newRequest("get"))
+	second := handleAsync(context.Background(), m, h, newRequest("get"))
+	<-h.entered
+	<-h.entered
+
+	close(h.release)
+	assert.NoError(t, (<-first).err)
+	assert.NoError(t, (<-second).err)
+	assert.Equal(t, 2, h.numCalls())
+}
+
+func TestCoalesceWaiterContext(t *testing.T) {
+	h := newBlockingHandler(func(resw transport.ResponseWriter) error {
+		_, err := resw.Write([]byte("hello"))
+		return err
+	})
+	m := NewCoalesceMiddleware(procedureKey, 1).(*coalesceMiddleware)
+
+	leader := handleAsync(context.Background(), m, h, newRequest("get"))
+	<-h.entered
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiter := handleAsync(ctx, m, h, newRequest("get"))
+	waitForWaiters(t, m, "get", 1)
+	cancel()
+	r := <-waiter
+	assert.Equal(t, context.Canceled, r.err)
+	assert.Empty(t, r.resw.Body.String())
+
+	// The waiter that gave up frees its place.
+	waitForWaiters(t, m, "get", 0)
+	waiter = handleAsync(context.Background(), m, h, newRequest("get"))
+	waitForWaiters(t, m, "get", 1)
+
+	close(h.release)
+	assert.NoError(t, (<-leader).err)
+	r = <-waiter
+	assert.NoError(t, r.err)
+	assert.Equal(t, "hello", r.resw.Body.String())
+}
+
+func TestCoalesceHandlerPanic(t *testing.T) {
+	h := newBlockingHandler(func(transport.ResponseWriter) error { panic(errors.New("great sadness")) })
+	m := NewCoalesceMiddleware(procedureKey, 1).(*coalesceMiddleware)
+
+	leader := make(chan interface{}, 1)
+	go func() {
+		defer func() { leader <- recover() }()
+		m.Handle(context.Background(), newRequest("get"), new(transporttest.FakeResponseWriter), h)
+	}()
+	<-h.entered
+	waiter := handleAsync(context.Background(), m, h, newRequest("get"))
+	waitForWaiters(t, m, "get", 1)
+	close(h.release)
+
+	assert.NotNil(t, <-leader, "the panic must reach the caller of the middleware")
+	err := (<-waiter).err
+	assert.Equal(t, yarpcerrors.CodeInternal, yarpcerrors.FromError(err).Code())
+	assert.Empty(t, m.calls)
+}
+
+func TestCoalesceLeaderContext(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		calls    int
+		entered  = make(chan struct{}, 2)
+		release  = make(chan struct{})
+		takeover = make(chan struct{})
+	)
+	h := handlerFunc(func(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+		mu.Lock()
+		calls++
+		first := calls == 1
+		mu.Unlock()
+
+		entered <- struct{}{}
+		if first {
+			// The leader runs out of time.
+			<-release
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		<-takeover
+		_, err := resw.Write([]byte("hello"))
+		return err
+	})
+	m := NewCoalesceMiddleware(procedureKey, 2).(*coalesceMiddleware)
+
+	leaderCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	leader := handleAsync(leaderCtx, m, h, newRequest("get"))
+	<-entered
+
+	waiterCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	waiters := []<-chan result{
+		handleAsync(waiterCtx, m, h, newRequest("get")),
+		handleAsync(waiterCtx, m, h, newRequest("get")),
+	}
+	waitForWaiters(t, m, "get", 2)
+	close(release)
+
+	err := (<-leader).err
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// One waiter is handled in place of the leader, and the other waits
+	// for it.
+	<-entered
+	waitForWaiters(t, m, "get", 1)
+	close(takeover)
+
+	for _, ch := range waiters {
+		r := <-ch
+		require.NoError(t, r.err, "waiters must not fail with the error of the leader")
+		assert.Equal(t, "hello", r.resw.Body.String())
+	}
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, m.calls)
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package backpressure provides inbound middleware that sheds the load of
+// duplicate requests on a saturated handler.
+//
+// The coalescing middleware lets requests that share a key wait for the
+// result of the request with the same key that is already being handled,
+// rather than calling the handler again:
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:     "myservice",
+// 		Inbounds: inbounds,
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: backpressure.NewCoalesceMiddleware(func(req *transport.Request) string {
+// 				if req.Procedure != "Reports::get" {
+// 					return ""
+// 				}
+// 				id, _ := req.Headers.Get("report-id")
+// 				return id
+// 			}, 100),
+// 		},
+// 	})
+//
+// At most the given number of requests wait for each key. Further requests
+// with the key fail with a ResourceExhausted error until the request being
+// handled completes, so that a handler that cannot keep up does not
+// accumulate waiting requests without bound.
+//
+// Over TChannel, a request turned away because too many requests wait for
+// its key gets no response, per the tchannel package's handling of
+// ResourceExhausted, so its caller times out rather than failing fast.
+package backpressure
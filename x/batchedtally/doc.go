@@ -0,0 +1,47 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package batchedtally provides a Tally scope that batches the metrics it
+// emits, trading their staleness for throughput.
+//
+// The metrics of the standard Tally scopes are shared by all the goroutines
+// that emit them, which contend for them at very high rates of requests. The
+// metrics of a batched scope buffer their updates in shards instead, spread
+// across goroutines, and flush them to the delegate scope on every flush
+// interval.
+//
+// 	scope := batchedtally.NewScope(rootScope, time.Second, 128)
+// 	defer scope.(io.Closer).Close()
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		Metrics: yarpc.MetricsConfig{
+// 			Tally: scope,
+// 		},
+// 	})
+//
+// Counter increments are summed, and only the last update of a gauge is
+// flushed. The values recorded by timers and histograms are buffered up to
+// the buffer size for every shard, and flushed early when a buffer is full.
+//
+// The delegate scope observes the metrics up to one flush interval late.
+// Closing the returned scope flushes the remaining metrics and stops
+// flushing.
+package batchedtally
@@ -0,0 +1,223 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package batchedtally
+
+import (
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/uber-go/tally"
+	"go.uber.org/atomic"
+)
+
+// _cacheLineSize pads the shards of metrics, so that goroutines updating
+// different shards do not contend for the same cache line.
+const _cacheLineSize = 64
+
+// shardIndex returns the index of the shard of the calling goroutine among
+// n shards.
+//
+// Goroutines run on distinct stacks, so the address of a local variable
+// spreads them across the shards without any state shared between them.
+// Any goroutine may update any shard: the index only needs to be cheap
+// and spread well.
+func shardIndex(n int) int {
+	var marker byte
+	h := uint64(uintptr(unsafe.Pointer(&marker)) >> 10)
+	h *= 0x9e3779b97f4a7c15
+	return int((h >> 32) % uint64(n))
+}
+
+type counterShard struct {
+	delta atomic.Int64
+	_     [_cacheLineSize - 8]byte
+}
+
+// counter sums the increments of every shard, and flushes them as a
+// single increment.
+type counter struct {
+	delegate tally.Counter
+	shards   []counterShard
+}
+
+func newCounter(delegate tally.Counter, shards int) *counter {
+	return &counter{
+		delegate: delegate,
+		shards:   make([]counterShard, shards),
+	}
+}
+
+func (c *counter) Inc(delta int64) {
+	c.shards[shardIndex(len(c.shards))].delta.Add(delta)
+}
+
+func (c *counter) flush() {
+	var delta int64
+	for i := range c.shards {
+		delta += c.shards[i].delta.Swap(0)
+	}
+	if delta != 0 {
+		c.delegate.Inc(delta)
+	}
+}
+
+// gauge flushes the last update of the gauge, if it was updated since the
+// last flush.
+type gauge struct {
+	delegate tally.Gauge
+	value    atomic.Float64
+	updated  atomic.Bool
+}
+
+func newGauge(delegate tally.Gauge) *gauge {
+	return &gauge{delegate: delegate}
+}
+
+func (g *gauge) Update(value float64) {
+	g.value.Store(value)
+	g.updated.Store(true)
+}
+
+func (g *gauge) flush() {
+	if g.updated.Swap(false) {
+		g.delegate.Update(g.value.Load())
+	}
+}
+
+// sampleShard buffers the values recorded by a timer or histogram.
+type sampleShard struct {
+	mu        sync.Mutex
+	values    []float64
+	durations []time.Duration
+	_         [_cacheLineSize]byte
+}
+
+// sampler buffers the values recorded by a timer or histogram in shards,
+// and records them in the delegate when they are flushed, or when the
+// buffer of a shard is full.
+type sampler struct {
+	bufSize        int
+	shards         []sampleShard
+	recordValue    func(float64)
+	recordDuration func(time.Duration)
+}
+
+func newSampler(shards, bufSize int, recordValue func(float64), recordDuration func(time.Duration)) sampler {
+	return sampler{
+		bufSize:        bufSize,
+		shards:         make([]sampleShard, shards),
+		recordValue:    recordValue,
+		recordDuration: recordDuration,
+	}
+}
+
+func (s *sampler) value(v float64) {
+	shard := &s.shards[shardIndex(len(s.shards))]
+	shard.mu.Lock()
+	shard.values = append(shard.values, v)
+	if len(shard.values) >= s.bufSize {
+		s.flushValues(shard)
+	}
+	shard.mu.Unlock()
+}
+
+func (s *sampler) duration(d time.Duration) {
+	shard := &s.shards[shardIndex(len(s.shards))]
+	shard.mu.Lock()
+	shard.durations = append(shard.durations, d)
+	if len(shard.durations) >= s.bufSize {
+		s.flushDurations(shard)
+	}
+	shard.mu.Unlock()
+}
+
+// flushValues records the buffered values of the shard, which must be
+// locked.
+func (s *sampler) flushValues(shard *sampleShard) {
+	for _, v := range shard.values {
+		s.recordValue(v)
+	}
+	shard.values = shard.values[:0]
+}
+
+// flushDurations records the buffered durations of the shard, which must be
+// locked.
+func (s *sampler) flushDurations(shard *sampleShard) {
+	for _, d := range shard.durations {
+		s.recordDuration(d)
+	}
+	shard.durations = shard.durations[:0]
+}
+
+func (s *sampler) flush() {
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		s.flushValues(shard)
+		s.flushDurations(shard)
+		shard.mu.Unlock()
+	}
+}
+
+type timer struct {
+	sampler
+}
+
+func newTimer(delegate tally.Timer, shards, bufSize int) *timer {
+	return &timer{newSampler(shards, bufSize, nil, delegate.Record)}
+}
+
+func (t *timer) Record(d time.Duration) {
+	t.duration(d)
+}
+
+func (t *timer) Start() tally.Stopwatch {
+	return tally.NewStopwatch(time.Now(), t)
+}
+
+func (t *timer) RecordStopwatch(start time.Time) {
+	t.duration(time.Since(start))
+}
+
+type histogram struct {
+	sampler
+}
+
+func newHistogram(delegate tally.Histogram, shards, bufSize int) *histogram {
+	return &histogram{newSampler(shards, bufSize, delegate.RecordValue, delegate.RecordDuration)}
+}
+
+func (h *histogram) RecordValue(v float64) {
+	h.value(v)
+}
+
+func (h *histogram) RecordDuration(d time.Duration) {
+	h.duration(d)
+}
+
+func (h *histogram) Start() tally.Stopwatch {
+	return tally.NewStopwatch(time.Now(), h)
+}
+
+func (h *histogram) RecordStopwatch(start time.Time) {
+	h.duration(time.Since(start))
+}
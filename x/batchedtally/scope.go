@@ -0,0 +1,287 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package batchedtally
+
+import (
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally"
+)
+
+const (
+	_defaultFlushInterval = time.Second
+	_defaultBufferSize    = 128
+)
+
+var _ io.Closer = (*rootScope)(nil)
+
+// NewScope returns a Tally scope that batches the metrics it emits, and
+// flushes them to the delegate scope on every flush interval.
+//
+// Timers and histograms buffer up to bufSize values for every shard
+// before they are flushed early.
+// The flush interval defaults to one second and the buffer size to 128 if
+// they are not positive.
+//
+// The returned scope implements io.Closer. Closing it flushes the remaining
+// metrics and stops flushing.
+func NewScope(delegate tally.Scope, flushInterval time.Duration, bufSize int) tally.Scope {
+	if flushInterval <= 0 {
+		flushInterval = _defaultFlushInterval
+	}
+	if bufSize <= 0 {
+		bufSize = _defaultBufferSize
+	}
+
+	b := &batcher{
+		shards:  runtime.GOMAXPROCS(0),
+		bufSize: bufSize,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go b.run(flushInterval)
+	return &rootScope{scope: newScope(b, delegate)}
+}
+
+// batcher keeps the metrics of all the scopes derived from a root scope,
+// and flushes them.
+type batcher struct {
+	shards  int
+	bufSize int
+
+	mu      sync.Mutex
+	metrics []flusher
+
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// flusher is a metric that flushes its buffered updates to the delegate
+// scope.
+type flusher interface {
+	flush()
+}
+
+func (b *batcher) register(f flusher) {
+	b.mu.Lock()
+	b.metrics = append(b.metrics, f)
+	b.mu.Unlock()
+}
+
+func (b *batcher) run(interval time.Duration) {
+	defer close(b.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.flush()
+		case <-b.stop:
+			b.flush()
+			return
+		}
+	}
+}
+
+func (b *batcher) flush() {
+	b.mu.Lock()
+	metrics := b.metrics
+	b.mu.Unlock()
+
+	for _, m := range metrics {
+		m.flush()
+	}
+}
+
+func (b *batcher) close() {
+	b.stopOnce.Do(func() { close(b.stop) })
+	<-b.stopped
+}
+
+// rootScope is the scope returned by NewScope, which may be closed.
+type rootScope struct {
+	*scope
+}
+
+// Close flushes the remaining metrics of the scope, and all the scopes
+// derived from it, and stops flushing.
+func (s *rootScope) Close() error {
+	s.b.close()
+	return nil
+}
+
+// scope is a batched scope, which caches its metrics and derived scopes
+// like the standard Tally scopes do.
+type scope struct {
+	b        *batcher
+	delegate tally.Scope
+
+	mu         sync.RWMutex
+	counters   map[string]*counter
+	gauges     map[string]*gauge
+	timers     map[string]*timer
+	histograms map[string]*histogram
+	subscopes  map[string]*scope
+}
+
+var _ tally.Scope = (*scope)(nil)
+
+func newScope(b *batcher, delegate tally.Scope) *scope {
+	return &scope{
+		b:          b,
+		delegate:   delegate,
+		counters:   make(map[string]*counter),
+		gauges:     make(map[string]*gauge),
+		timers:     make(map[string]*timer),
+		histograms: make(map[string]*histogram),
+		subscopes:  make(map[string]*scope),
+	}
+}
+
+func (s *scope) Counter(name string) tally.Counter {
+	s.mu.RLock()
+	c, ok := s.counters[name]
+	s.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.counters[name]; ok {
+		return c
+	}
+	c = newCounter(s.delegate.Counter(name), s.b.shards)
+	s.counters[name] = c
+	s.b.register(c)
+	return c
+}
+
+func (s *scope) Gauge(name string) tally.Gauge {
+	s.mu.RLock()
+	g, ok := s.gauges[name]
+	s.mu.RUnlock()
+	if ok {
+		return g
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g, ok := s.gauges[name]; ok {
+		return g
+	}
+	g = newGauge(s.delegate.Gauge(name))
+	s.gauges[name] = g
+	s.b.register(g)
+	return g
+}
+
+func (s *scope) Timer(name string) tally.Timer {
+	s.mu.RLock()
+	t, ok := s.timers[name]
+	s.mu.RUnlock()
+	if ok {
+		return t
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.timers[name]; ok {
+		return t
+	}
+	t = newTimer(s.delegate.Timer(name), s.b.shards, s.b.bufSize)
+	s.timers[name] = t
+	s.b.register(t)
+	return t
+}
+
+// Histogram returns the histogram with the given name. Like the standard
+// Tally scopes, the buckets of the first call for a name are used.
+func (s *scope) Histogram(name string, buckets tally.Buckets) tally.Histogram {
+	s.mu.RLock()
+	h, ok := s.histograms[name]
+	s.mu.RUnlock()
+	if ok {
+		return h
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h, ok := s.histograms[name]; ok {
+		return h
+	}
+	h = newHistogram(s.delegate.Histogram(name, buckets), s.b.shards, s.b.bufSize)
+	s.histograms[name] = h
+	s.b.register(h)
+	return h
+}
+
+func (s *scope) Tagged(tags map[string]string) tally.Scope {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var key strings.Builder
+	key.WriteString("tagged:")
+	for _, k := range keys {
+		key.WriteString(k)
+		key.WriteByte('=')
+		key.WriteString(tags[k])
+		key.WriteByte(',')
+	}
+	return s.subscope(key.String(), func() tally.Scope { return s.delegate.Tagged(tags) })
+}
+
+func (s *scope) SubScope(name string) tally.Scope {
+	return s.subscope("sub:"+name, func() tally.Scope { return s.delegate.SubScope(name) })
+}
+
+func (s *scope) subscope(key string, delegate func() tally.Scope) *scope {
+	s.mu.RLock()
+	sub, ok := s.subscopes[key]
+	s.mu.RUnlock()
+	if ok {
+		return sub
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub, ok := s.subscopes[key]; ok {
+		return sub
+	}
+	sub = newScope(s.b, delegate())
+	s.subscopes[key] = sub
+	return sub
+}
+
+func (s *scope) Capabilities() tally.Capabilities {
+	return s.delegate.Capabilities()
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package batchedtally
+
+import (
+	"io"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+// A flush interval long enough that tests only observe the flushes of
+// closing the scope, or of full buffers.
+const _testFlushInterval = time.Hour
+
+func TestCounter(t *testing.T) {
+	delegate := tally.NewTestScope("", nil)
+	scope := NewScope(delegate, _testFlushInterval, 0)
+
+	const goroutines, increments = 8, 1000
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				scope.Counter("calls").Inc(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, 0, delegate.Snapshot().Counters()["calls+"].Value(),
+		"counter must not be flushed before the flush interval")
+
+	require.NoError(t, scope.(io.Closer).Close())
+	assert.EqualValues(t, goroutines*increments, delegate.Snapshot().Counters()["calls+"].Value())
+}
+
+func TestGauge(t *testing.T) {
+	delegate := tally.NewTestScope("", nil)
+	scope := NewScope(delegate, _testFlushInterval, 0)
+
+	gauge := scope.Gauge("pending")
+	gauge.Update(1)
+	gauge.Update(3)
+
+	require.NoError(t, scope.(io.Closer).Close())
+	assert.Equal(t, float64(3), delegate.Snapshot().Gauges()["pending+"].Value())
+}
+
+func TestTimer(t *testing.T) {
+	delegate := tally.NewTestScope("", nil)
+	scope := NewScope(delegate, _testFlushInterval, 0)
+
+	timer := scope.Timer("latency")
+	timer.Record(time.Millisecond)
+	timer.Record(2 * time.Millisecond)
+	timer.Start().Stop()
+
+	require.NoError(t, scope.(io.Closer).Close())
+	values := delegate.Snapshot().Timers()["latency+"].Values()
+	require.Len(t, values, 3)
+	assert.ElementsMatch(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, values[:2])
+}
+
+func TestHistogram(t *testing.T) {
+	delegate := tally.NewTestScope("", nil)
+	scope := NewScope(delegate, _testFlushInterval, 0)
+
+	values := scope.Histogram("size", tally.ValueBuckets{10, 100})
+	values.RecordValue(5)
+	values.RecordValue(50)
+	values.RecordValue(60)
+
+	durations := scope.Histogram("latency", tally.DurationBuckets{time.Millisecond, time.Second})
+	durations.RecordDuration(time.Microsecond)
+	durations.Start().Stop()
+
+	require.NoError(t, scope.(io.Closer).Close())
+	histograms := delegate.Snapshot().Histograms()
+	assert.Equal(t, map[float64]int64{10: 1, 100: 2}, nonZero(histograms["size+"].Values()))
+	assert.Equal(t, map[time.Duration]int64{time.Millisecond: 2}, nonZero(histograms["latency+"].Durations()))
+}
+
+func TestFullBufferFlushes(t *testing.T) {
+	delegate := tally.NewTestScope("", nil)
+	scope := NewScope(delegate, _testFlushInterval, 1)
+	defer scope.(io.Closer).Close()
+
+	scope.Timer("latency").Record(time.Millisecond)
+	scope.Histogram("size", tally.ValueBuckets{10}).RecordValue(5)
+
+	snapshot := delegate.Snapshot()
+	assert.Equal(t, []time.Duration{time.Millisecond}, snapshot.Timers()["latency+"].Values())
+	assert.Equal(t, map[float64]int64{10: 1}, nonZero(snapshot.Histograms()["size+"].Values()))
+}
+
+func TestFlushInterval(t *testing.T) {
+	delegate := tally.NewTestScope("", nil)
+	scope := NewScope(delegate, time.Millisecond, 0)
+	defer scope.(io.Closer).Close()
+
+	scope.Counter("calls").Inc(2)
+	assert.Eventually(t, func() bool {
+		return delegate.Snapshot().Counters()["calls+"].Value() == 2
+	}, time.Second, time.Millisecond)
+}
+
+func TestDerivedScopes(t *testing.T) {
+	delegate := tally.NewTestScope("", nil)
+	scope := NewScope(delegate, _testFlushInterval, 0)
+
+	tagged := scope.Tagged(map[string]string{"a": "1", "b": "2"})
+	assert.True(t, tagged == scope.Tagged(map[string]string{"b": "2", "a": "1"}),
+		"scopes with the same tags must be cached")
+	assert.True(t, tagged.Counter("calls") == tagged.Counter("calls"),
+		"counters must be cached")
+
+	sub := scope.SubScope("inbound")
+	assert.True(t, sub == scope.SubScope("inbound"), "subscopes must be cached")
+
+	tagged.Counter("calls").Inc(1)
+	sub.Counter("calls").Inc(2)
+	scope.Counter("calls").Inc(3)
+
+	require.NoError(t, scope.(io.Closer).Close())
+	counters := delegate.Snapshot().Counters()
+	assert.EqualValues(t, 1, counters["calls+a=1,b=2"].Value())
+	assert.EqualValues(t, 2, counters["inbound.calls+"].Value())
+	assert.EqualValues(t, 3, counters["calls+"].Value())
+	assert.Equal(t, delegate.Capabilities(), scope.Capabilities())
+}
+
+func TestCloseTwice(t *testing.T) {
+	scope := NewScope(tally.NoopScope, _testFlushInterval, 0)
+	require.NoError(t, scope.(io.Closer).Close())
+	require.NoError(t, scope.(io.Closer).Close())
+}
+
+func nonZero(m interface{}) interface{} {
+	switch m := m.(type) {
+	case map[float64]int64:
+		out := make(map[float64]int64)
+		for k, v := range m {
+			if v != 0 {
+				out[k] = v
+			}
+		}
+		return out
+	case map[time.Duration]int64:
+		out := make(map[time.Duration]int64)
+		for k, v := range m {
+			if v != 0 {
+				out[k] = v
+			}
+		}
+		return out
+	}
+	panic("unexpected map")
+}
+
+// BenchmarkCounter compares 10M increments of a counter, from as many
+// goroutines as GOMAXPROCS=8 runs, with the standard and batched scopes.
+func BenchmarkCounter(b *testing.B) {
+	const procs, increments = 8, 10000000
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+
+	run := func(b *testing.B, scope tally.Scope) {
+		counter := scope.Counter("calls")
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var wg sync.WaitGroup
+			for p := 0; p < procs; p++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < increments/procs; j++ {
+						counter.Inc(1)
+					}
+				}()
+			}
+			wg.Wait()
+		}
+	}
+
+	b.Run("tally", func(b *testing.B) {
+		scope, closer := tally.NewRootScope(tally.ScopeOptions{Reporter: tally.NullStatsReporter}, time.Second)
+		defer closer.Close()
+		run(b, scope)
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		root, closer := tally.NewRootScope(tally.ScopeOptions{Reporter: tally.NullStatsReporter}, time.Second)
+		defer closer.Close()
+		scope := NewScope(root, time.Second, 0)
+		defer scope.(io.Closer).Close()
+		run(b, scope)
+	})
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package bodylog provides inbound middleware that logs the bodies of the
+// unary requests a service handles and of their responses, for debugging.
+//
+// Bodies may hold personal information, so the middleware logs at most a
+// configurable number of bytes of each, replaces the values of redacted
+// headers, and may be switched on and off at runtime:
+//
+// 	enabled := atomic.NewBool(false)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: bodylog.NewInboundMiddleware(logger,
+// 				bodylog.WithMaxBodyBytes(1024),
+// 				bodylog.WithRedactedHeaders([]string{"authorization"}),
+// 				bodylog.WithBodyEncoder(bodylog.StringEncoder),
+// 				bodylog.WithEnabled(enabled),
+// 			),
+// 		},
+// 	})
+//
+// 	// Later, while investigating an issue:
+// 	enabled.Store(true)
+//
+// While logging is disabled, the middleware calls the handler directly
+// without buffering the bodies.
+package bodylog
@@ -0,0 +1,194 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bodylog
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/recordwriter"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the values of redacted headers in logs.
+const Redacted = "***"
+
+// DefaultMaxBodyBytes is the number of bytes of each body that the
+// middleware logs by default.
+const DefaultMaxBodyBytes = 4096
+
+// StringEncoder logs bodies as strings, for text encodings like JSON.
+func StringEncoder(body []byte) string { return string(body) }
+
+// HexEncoder logs bodies as hexadecimal.
+func HexEncoder(body []byte) string { return hex.EncodeToString(body) }
+
+// Base64Encoder logs bodies as standard base64, the default, which keeps
+// binary encodings like Thrift and Protobuf readable in logs.
+func Base64Encoder(body []byte) string { return base64.StdEncoding.EncodeToString(body) }
+
+// BodyLogOption customizes the behavior of the body logging middleware.
+type BodyLogOption interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	maxBodyBytes int
+	redacted     map[string]struct{}
+	encode       func([]byte) string
+	enabled      *atomic.Bool
+}
+
+// WithMaxBodyBytes limits the number of bytes of each body that the
+// middleware logs. Bodies are passed on in full regardless.
+//
+// Defaults to DefaultMaxBodyBytes.
+func WithMaxBodyBytes(n int) BodyLogOption {
+	return optionFunc(func(o *options) {
+		o.maxBodyBytes = n
+	})
+}
+
+// WithRedactedHeaders replaces the values of the given request and response
+// headers in logs with Redacted. Header names are case-insensitive.
+func WithRedactedHeaders(keys []string) BodyLogOption {
+	return optionFunc(func(o *options) {
+		for _, key := range keys {
+			o.redacted[transport.CanonicalizeHeaderKey(key)] = struct{}{}
+		}
+	})
+}
+
+// WithBodyEncoder specifies how bodies are rendered in logs, like
+// StringEncoder, HexEncoder or Base64Encoder.
+//
+// Defaults to Base64Encoder.
+func WithBodyEncoder(fn func([]byte) string) BodyLogOption {
+	return optionFunc(func(o *options) {
+		o.encode = fn
+	})
+}
+
+// WithEnabled guards logging behind the given flag, so that it may be
+// switched on and off at runtime.
+//
+// Logging is always enabled without this option.
+func WithEnabled(flag *atomic.Bool) BodyLogOption {
+	return optionFunc(func(o *options) {
+		o.enabled = flag
+	})
+}
+
+type inboundMiddleware struct {
+	logger *zap.Logger
+	opts   options
+}
+
+// NewInboundMiddleware builds unary inbound middleware that logs the body and
+// headers of every request, along with the body and headers of its response,
+// once its handler returns.
+//
+// The middleware buffers the request body and hands the handler a copy of it.
+func NewInboundMiddleware(logger *zap.Logger, opts ...BodyLogOption) middleware.UnaryInbound {
+	o := options{
+		maxBodyBytes: DefaultMaxBodyBytes,
+		redacted:     make(map[string]struct{}),
+		encode:       Base64Encoder,
+		enabled:      atomic.NewBool(true),
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &inboundMiddleware{logger: logger, opts: o}
+}
+
+func (m *inboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	if !m.opts.enabled.Load() {
+		return h.Handle(ctx, req, resw)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return err
+		}
+	}
+	req.Body = bytes.NewReader(body)
+
+	w := recordwriter.NewLimited(resw, m.opts.maxBodyBytes)
+	err := h.Handle(ctx, req, w)
+
+	m.logger.Info("handled request",
+		zap.String("caller", req.Caller),
+		zap.String("service", req.Service),
+		zap.String("procedure", req.Procedure),
+		zap.Object("requestHeaders", m.headers(req.Headers)),
+		m.body("requestBody", body, len(body)),
+		zap.Object("responseHeaders", m.headers(w.Headers())),
+		m.body("responseBody", w.Body(), w.BodySize()),
+		zap.Bool("applicationError", w.IsApplicationError()),
+		zap.Error(err))
+	return err
+}
+
+func (m *inboundMiddleware) headers(h transport.Headers) zapcore.ObjectMarshaler {
+	return zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		for k, v := range h.Items() {
+			if _, ok := m.opts.redacted[k]; ok {
+				v = Redacted
+			}
+			enc.AddString(k, v)
+		}
+		return nil
+	})
+}
+
+// body renders at most the maximum number of bytes of a body of the given
+// size.
+func (m *inboundMiddleware) body(key string, body []byte, size int) zap.Field {
+	truncated := len(body) > m.opts.maxBodyBytes
+	if truncated {
+		body = body[:m.opts.maxBodyBytes]
+	}
+	return zap.Object(key, zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("body", m.opts.encode(body))
+		enc.AddInt("size", size)
+		if truncated || size > len(body) {
+			enc.AddBool("truncated", true)
+		}
+		return nil
+	}))
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package bodylog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type handlerFunc func(context.Context, *transport.Request, transport.ResponseWriter) error
+
+func (f handlerFunc) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	return f(ctx, req, resw)
+}
+
+func newRequest(body string) *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "Store::get",
+		Encoding:  "json",
+		Headers: transport.NewHeaders().
+			With("Authorization", "Bearer s3cr3t").
+			With("x-request-id", "42"),
+		Body: bytes.NewBufferString(body),
+	}
+}
+
+// echo replies with the request body and a response header.
+var echo = handlerFunc(func(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	resw.AddHeaders(transport.NewHeaders().With("Set-Cookie", "session=1"))
+	_, err = resw.Write(body)
+	return err
+})
+
+func TestInboundMiddleware(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	mw := NewInboundMiddleware(zap.New(core),
+		WithRedactedHeaders([]string{"authorization", "SET-COOKIE"}),
+		WithBodyEncoder(StringEncoder),
+	)
+
+	resw := &transporttest.FakeResponseWriter{}
+	require.NoError(t, mw.Handle(context.Background(), newRequest(`{"key":"foo"}`), resw, echo))
+	assert.Equal(t, `{"key":"foo"}`, resw.Body.String(), "expected the handler to read the whole body")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "handled request", entries[0].Message)
+	assert.Equal(t, map[string]interface{}{
+		"caller":    "caller",
+		"service":   "service",
+		"procedure": "Store::get",
+		"requestHeaders": map[string]interface{}{
+			"authorization": Redacted,
+			"x-request-id":  "42",
+		},
+		"requestBody": map[string]interface{}{
+			"body": `{"key":"foo"}`,
+			"size": 13,
+		},
+		"responseHeaders": map[string]interface{}{
+			"set-cookie": Redacted,
+		},
+		"responseBody": map[string]interface{}{
+			"body": `{"key":"foo"}`,
+			"size": 13,
+		},
+		"applicationError": false,
+	}, entries[0].ContextMap())
+}
+
+func TestInboundMiddlewareMaxBodyBytes(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	mw := NewInboundMiddleware(zap.New(core), WithMaxBodyBytes(4), WithBodyEncoder(StringEncoder))
+
+	resw := &transporttest.FakeResponseWriter{}
+	require.NoError(t, mw.Handle(context.Background(), newRequest("hello world"), resw, echo))
+	assert.Equal(t, "hello world", resw.Body.String(), "expected the whole body to be passed on")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	truncated := map[string]interface{}{"body": "hell", "size": 11, "truncated": true}
+	assert.Equal(t, truncated, fields["requestBody"])
+	assert.Equal(t, truncated, fields["responseBody"])
+}
+
+func TestInboundMiddlewareEncoders(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func([]byte) string
+		want   string
+	}{
+		{name: "default", want: "aGk="},
+		{name: "string", encode: StringEncoder, want: "hi"},
+		{name: "hex", encode: HexEncoder, want: "6869"},
+		{name: "base64", encode: Base64Encoder, want: "aGk="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			var opts []BodyLogOption
+			if tt.encode != nil {
+				opts = append(opts, WithBodyEncoder(tt.encode))
+			}
+			mw := NewInboundMiddleware(zap.New(core), opts...)
+
+			require.NoError(t, mw.Handle(context.Background(), newRequest("hi"), &transporttest.FakeResponseWriter{}, echo))
+			entries := logs.TakeAll()
+			require.Len(t, entries, 1)
+			assert.Equal(t, tt.want, entries[0].ContextMap()["requestBody"].(map[string]interface{})["body"])
+		})
+	}
+}
+
+func TestInboundMiddlewareEnabled(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	enabled := atomic.NewBool(false)
+	mw := NewInboundMiddleware(zap.New(core), WithEnabled(enabled))
+
+	req := newRequest("hi")
+	body := req.Body
+	var handled *transport.Request
+	handler := handlerFunc(func(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+		handled = req
+		return nil
+	})
+
+	require.NoError(t, mw.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, handler))
+	assert.Equal(t, 0, logs.Len(), "expected nothing to be logged while disabled")
+	assert.Equal(t, body, handled.Body, "expected the body not to be buffered while disabled")
+
+	enabled.Store(true)
+	require.NoError(t, mw.Handle(context.Background(), newRequest("hi"), &transporttest.FakeResponseWriter{}, handler))
+	assert.Equal(t, 1, logs.Len(), "expected the request to be logged once enabled")
+}
+
+func TestInboundMiddlewareError(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	mw := NewInboundMiddleware(zap.New(core))
+
+	handler := handlerFunc(func(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+		resw.SetApplicationError()
+		return errors.New("great sadness")
+	})
+	resw := &transporttest.FakeResponseWriter{}
+	err := mw.Handle(context.Background(), newRequest("hi"), resw, handler)
+	assert.EqualError(t, err, "great sadness")
+	assert.True(t, resw.IsApplicationError, "expected the application error to be passed on")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, true, fields["applicationError"])
+	assert.Equal(t, "great sadness", fields["error"])
+}
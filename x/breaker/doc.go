@@ -0,0 +1,45 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package breaker provides outbound middleware that breaks the circuit to
+// failing procedures.
+//
+// The middleware keeps a circuit breaker for every service and procedure it
+// calls. A breaker opens when the failure rate of the calls over a sliding
+// window, or the number of consecutive failures, crosses a threshold. While it
+// is open, calls fail with an Unavailable error without reaching the
+// transport. After a cool-down, the breaker is half-open: it lets a limited
+// number of probe calls through, and closes if they all succeed or opens
+// again if any fails.
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:      "myservice",
+// 		Outbounds: outbounds,
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary: breaker.NewUnaryOutboundMiddleware(
+// 				breaker.FailureThreshold(0.25),
+// 				breaker.CoolDown(10*time.Second),
+// 			),
+// 		},
+// 	})
+//
+// Unlike the circuit peer list, which ejects failing peers of a service, the
+// breaker stops calls to a procedure altogether.
+package breaker
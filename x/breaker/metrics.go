@@ -0,0 +1,104 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package breaker
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	_serviceTag   = "service"
+	_procedureTag = "procedure"
+	_fromTag      = "from"
+	_toTag        = "to"
+)
+
+type breakerMetrics struct {
+	state       *metrics.GaugeVector
+	transitions *metrics.CounterVector
+	rejected    *metrics.CounterVector
+}
+
+func newBreakerMetrics(meter *metrics.Scope, logger *zap.Logger) *breakerMetrics {
+	tags := []string{_serviceTag, _procedureTag}
+
+	state, err := meter.GaugeVector(metrics.Spec{
+		Name:    "breaker_state",
+		Help:    "State of the circuit breaker of a procedure: 0 closed, 1 half-open, 2 open.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create breaker state gauge", zap.Error(err))
+	}
+	transitions, err := meter.CounterVector(metrics.Spec{
+		Name:    "breaker_transitions",
+		Help:    "Total number of changes of state of circuit breakers.",
+		VarTags: append(tags, _fromTag, _toTag),
+	})
+	if err != nil {
+		logger.Error("failed to create breaker transitions counter", zap.Error(err))
+	}
+	rejected, err := meter.CounterVector(metrics.Spec{
+		Name:    "breaker_rejected",
+		Help:    "Total number of calls failed without being sent because their circuit breaker was open.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create breaker rejected counter", zap.Error(err))
+	}
+
+	return &breakerMetrics{
+		state:       state,
+		transitions: transitions,
+		rejected:    rejected,
+	}
+}
+
+// edgeMetrics are the metrics of the breaker of a service and procedure.
+type edgeMetrics struct {
+	service   string
+	procedure string
+
+	state       *metrics.Gauge
+	transitions *metrics.CounterVector
+	rejected    *metrics.Counter
+}
+
+func (m *breakerMetrics) edge(k procedureKey) *edgeMetrics {
+	return &edgeMetrics{
+		service:     k.service,
+		procedure:   k.procedure,
+		state:       m.state.MustGet(_serviceTag, k.service, _procedureTag, k.procedure),
+		transitions: m.transitions,
+		rejected:    m.rejected.MustGet(_serviceTag, k.service, _procedureTag, k.procedure),
+	}
+}
+
+func (e *edgeMetrics) transition(from, to State) {
+	e.state.Store(int64(to))
+	e.transitions.MustGet(
+		_serviceTag, e.service,
+		_procedureTag, e.procedure,
+		_fromTag, from.String(),
+		_toTag, to.String(),
+	).Inc()
+}
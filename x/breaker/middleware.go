@@ -0,0 +1,392 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package breaker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/window"
+	"go.uber.org/yarpc/pkg/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	_defaultWindow           = 10 * time.Second
+	_defaultFailureThreshold = 0.5
+	_defaultMinRequests      = 20
+	_defaultCoolDown         = 30 * time.Second
+	_defaultProbeRequests    = 3
+)
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// Closed breakers let calls through and record their results.
+	Closed State = iota
+	// HalfOpen breakers let a limited number of probe calls through, to
+	// decide whether to close again.
+	HalfOpen
+	// Open breakers fail calls without sending them until their cool-down
+	// elapses.
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Option customizes the behavior of the circuit breaking middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	window              time.Duration
+	failureThreshold    float64
+	minRequests         int
+	consecutiveFailures int
+	coolDown            time.Duration
+	probeRequests       int
+	isFailure           func(error) bool
+	meter               *metrics.Scope
+	logger              *zap.Logger
+	clock               clock.Clock
+}
+
+// Window is the duration over which the failure rate of the calls to each
+// procedure is measured.
+//
+// Defaults to 10 seconds.
+func Window(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d > 0 {
+			o.window = d
+		}
+	})
+}
+
+// FailureThreshold is the fraction of failed calls, between 0 and 1, above
+// which a breaker opens.
+//
+// Defaults to 0.5.
+func FailureThreshold(threshold float64) Option {
+	return optionFunc(func(o *options) {
+		if threshold >= 0 && threshold < 1 {
+			o.failureThreshold = threshold
+		}
+	})
+}
+
+// MinRequests is the number of calls to a procedure that must have finished
+// within the window before the failure rate may open its breaker.
+//
+// Defaults to 20.
+func MinRequests(n int) Option {
+	return optionFunc(func(o *options) {
+		if n > 0 {
+			o.minRequests = n
+		}
+	})
+}
+
+// ConsecutiveFailures opens a breaker after the given number of consecutive
+// failed calls, regardless of the failure rate.
+//
+// Defaults to opening on the failure rate alone.
+func ConsecutiveFailures(n int) Option {
+	return optionFunc(func(o *options) {
+		if n > 0 {
+			o.consecutiveFailures = n
+		}
+	})
+}
+
+// CoolDown is how long a breaker stays open before probing the procedure.
+//
+// Defaults to 30 seconds.
+func CoolDown(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d > 0 {
+			o.coolDown = d
+		}
+	})
+}
+
+// ProbeRequests is the number of calls a half-open breaker lets through. The
+// breaker closes if all of them succeed and opens again if any fails.
+//
+// Defaults to 3.
+func ProbeRequests(n int) Option {
+	return optionFunc(func(o *options) {
+		if n > 0 {
+			o.probeRequests = n
+		}
+	})
+}
+
+// Classifier decides which errors count as failures of the procedure.
+//
+// Defaults to counting every error but client faults, like InvalidArgument
+// or NotFound, which say nothing about the health of the procedure.
+func Classifier(isFailure func(error) bool) Option {
+	return optionFunc(func(o *options) {
+		if isFailure != nil {
+			o.isFailure = isFailure
+		}
+	})
+}
+
+// Meter sets the scope for the metrics of the middleware.
+func Meter(meter *metrics.Scope) Option {
+	return optionFunc(func(o *options) {
+		o.meter = meter
+	})
+}
+
+// Logger sets the logger for the middleware.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+// Clock sets the clock that measures failure rates and cool-downs, for tests
+// that control time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) Option {
+	return optionFunc(func(o *options) {
+		o.clock = c
+	})
+}
+
+func isFailure(err error) bool {
+	return err != nil && yarpcerrors.GetFaultTypeFromError(err) != yarpcerrors.ClientFault
+}
+
+type procedureKey struct {
+	service   string
+	procedure string
+}
+
+type breaker struct {
+	key     procedureKey
+	metrics *edgeMetrics
+	state   State
+	// generation changes with every change of state so that results of
+	// calls sent in a previous state are ignored.
+	generation int
+
+	window         window.Window
+	consecutive    int
+	openUntil      time.Time
+	probes         int
+	probeSuccesses int
+}
+
+var _ middleware.UnaryOutbound = (*Middleware)(nil)
+
+// Middleware is a unary outbound middleware that breaks the circuit to
+// procedures that fail too often.
+type Middleware struct {
+	opts    options
+	metrics *breakerMetrics
+	now     func() time.Time
+
+	lock     sync.Mutex
+	breakers map[procedureKey]*breaker
+}
+
+// NewUnaryOutboundMiddleware returns a unary outbound middleware with a
+// circuit breaker for every procedure it calls.
+func NewUnaryOutboundMiddleware(opts ...Option) *Middleware {
+	o := options{
+		window:           _defaultWindow,
+		failureThreshold: _defaultFailureThreshold,
+		minRequests:      _defaultMinRequests,
+		coolDown:         _defaultCoolDown,
+		probeRequests:    _defaultProbeRequests,
+		isFailure:        isFailure,
+		clock:            clock.System,
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.logger == nil {
+		o.logger = zap.NewNop()
+	}
+
+	return &Middleware{
+		opts:     o,
+		metrics:  newBreakerMetrics(o.meter, o.logger),
+		now:      o.clock.Now,
+		breakers: make(map[procedureKey]*breaker),
+	}
+}
+
+// Call sends the request unless the breaker of its procedure is open, and
+// records the result.
+func (m *Middleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	k := procedureKey{req.Service, req.Procedure}
+	generation, ok := m.allow(k)
+	if !ok {
+		return nil, yarpcerrors.UnavailableErrorf(
+			"circuit breaker is open for procedure %q of service %q", req.Procedure, req.Service)
+	}
+
+	res, err := out.Call(ctx, req)
+	m.record(k, generation, m.opts.isFailure(err))
+	return res, err
+}
+
+// State returns the state of the breaker of a procedure.
+func (m *Middleware) State(service, procedure string) State {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if b, ok := m.breakers[procedureKey{service, procedure}]; ok {
+		return b.state
+	}
+	return Closed
+}
+
+// allow returns whether a call to the procedure may be sent, and the
+// generation of its breaker to record the result against.
+func (m *Middleware) allow(k procedureKey) (generation int, ok bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	b, ok := m.breakers[k]
+	if !ok {
+		b = &breaker{
+			key:     k,
+			metrics: m.metrics.edge(k),
+			window:  window.New(m.opts.window),
+		}
+		m.breakers[k] = b
+	}
+
+	if b.state == Open {
+		if m.now().Before(b.openUntil) {
+			b.metrics.rejected.Inc()
+			return 0, false
+		}
+		m.transition(b, HalfOpen)
+	}
+	if b.state == HalfOpen {
+		// Send no more than the configured number of probes until they have
+		// all completed.
+		if b.probes >= m.opts.probeRequests {
+			b.metrics.rejected.Inc()
+			return 0, false
+		}
+		b.probes++
+	}
+	return b.generation, true
+}
+
+func (m *Middleware) record(k procedureKey, generation int, failed bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	b := m.breakers[k]
+	if b.generation != generation {
+		return
+	}
+
+	switch b.state {
+	case Closed:
+		now := m.now()
+		b.window.Record(now, failed)
+		if !failed {
+			b.consecutive = 0
+			return
+		}
+		b.consecutive++
+		if m.shouldOpen(b, now) {
+			m.transition(b, Open)
+		}
+	case HalfOpen:
+		if failed {
+			m.transition(b, Open)
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= m.opts.probeRequests {
+			m.transition(b, Closed)
+		}
+	}
+}
+
+// shouldOpen must be called under the middleware lock.
+func (m *Middleware) shouldOpen(b *breaker, now time.Time) bool {
+	if m.opts.consecutiveFailures > 0 && b.consecutive >= m.opts.consecutiveFailures {
+		return true
+	}
+	successes, failures := b.window.Counts(now)
+	total := successes + failures
+	return total >= m.opts.minRequests && float64(failures) > m.opts.failureThreshold*float64(total)
+}
+
+// transition must be called under the middleware lock.
+func (m *Middleware) transition(b *breaker, to State) {
+	from := b.state
+	b.state = to
+	b.generation++
+
+	switch to {
+	case Closed:
+		b.window.Reset()
+		b.consecutive = 0
+	case HalfOpen:
+		b.probes = 0
+		b.probeSuccesses = 0
+	case Open:
+		b.openUntil = m.now().Add(m.opts.coolDown)
+	}
+
+	b.metrics.transition(from, to)
+	m.opts.logger.Info("circuit breaker changed state",
+		zap.String("service", b.key.service),
+		zap.String("procedure", b.key.procedure),
+		zap.Stringer("from", from),
+		zap.Stringer("to", to))
+}
@@ -0,0 +1,319 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+// fakeOutbound fails calls with its error, counting the calls it receives.
+type fakeOutbound struct {
+	transport.UnaryOutbound
+
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+func (o *fakeOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls++
+	return &transport.Response{}, o.err
+}
+
+func (o *fakeOutbound) fail(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.err = err
+}
+
+func (o *fakeOutbound) numCalls() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.calls
+}
+
+type harness struct {
+	t     *testing.T
+	mw    *Middleware
+	out   *fakeOutbound
+	clock *yarpctest.FakeClock
+	root  *metrics.Root
+}
+
+func newHarness(t *testing.T, opts ...Option) *harness {
+	root := metrics.New()
+	clock := yarpctest.NewFakeClock()
+	opts = append([]Option{
+		MinRequests(10),
+		Window(10 * time.Second),
+		CoolDown(time.Minute),
+		ProbeRequests(2),
+		Meter(root.Scope()),
+		Clock(clock),
+	}, opts...)
+
+	return &harness{
+		t:     t,
+		mw:    NewUnaryOutboundMiddleware(opts...),
+		out:   &fakeOutbound{},
+		clock: clock,
+		root:  root,
+	}
+}
+
+// call sends n calls to the procedure and returns the number that failed
+// without being sent.
+func (h *harness) call(procedure string, n int) (rejected int) {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	for i := 0; i < n; i++ {
+		before := h.out.numCalls()
+		_, err := h.mw.Call(ctx, &transport.Request{Service: "service", Procedure: procedure}, h.out)
+		if h.out.numCalls() == before {
+			require.Error(h.t, err)
+			assert.Equal(h.t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code())
+			rejected++
+		}
+	}
+	return rejected
+}
+
+func (h *harness) metrics() map[string]int64 {
+	values := make(map[string]int64)
+	snap := h.root.Snapshot()
+	for _, c := range snap.Counters {
+		name := c.Name
+		if to, ok := c.Tags[_toTag]; ok {
+			name += ":" + c.Tags[_fromTag] + "->" + to
+		}
+		values[name] += c.Value
+	}
+	for _, g := range snap.Gauges {
+		values[g.Name+":"+g.Tags[_procedureTag]] = g.Value
+	}
+	return values
+}
+
+func TestOpensOnFailureRate(t *testing.T) {
+	h := newHarness(t)
+	h.out.fail(yarpcerrors.UnavailableErrorf("great sadness"))
+
+	// The breaker needs the minimum number of calls to judge the failure
+	// rate.
+	assert.Zero(t, h.call("procedure", 10))
+	assert.Equal(t, Open, h.mw.State("service", "procedure"))
+
+	assert.Equal(t, 5, h.call("procedure", 5), "calls must fail fast while the breaker is open")
+	assert.Equal(t, 10, h.out.numCalls())
+	assert.Zero(t, h.call("other", 1), "breakers of other procedures must stay closed")
+
+	assert.Equal(t, map[string]int64{
+		"breaker_state:procedure":          int64(Open),
+		"breaker_state:other":              int64(Closed),
+		"breaker_transitions:closed->open": 1,
+		"breaker_rejected":                 5,
+	}, h.metrics())
+}
+
+func TestStaysClosedBelowThreshold(t *testing.T) {
+	h := newHarness(t, FailureThreshold(0.5))
+
+	h.out.fail(yarpcerrors.UnavailableErrorf("great sadness"))
+	h.call("procedure", 5)
+	h.out.fail(nil)
+	h.call("procedure", 5)
+	assert.Equal(t, Closed, h.mw.State("service", "procedure"))
+
+	// Failures age out of the window.
+	h.clock.Add(11 * time.Second)
+	h.out.fail(yarpcerrors.UnavailableErrorf("great sadness"))
+	h.call("procedure", 9)
+	assert.Equal(t, Closed, h.mw.State("service", "procedure"))
+}
+
+func TestOpensOnConsecutiveFailures(t *testing.T) {
+	h := newHarness(t, ConsecutiveFailures(3), MinRequests(100))
+
+	h.out.fail(yarpcerrors.UnavailableErrorf("great sadness"))
+	h.call("procedure", 2)
+	h.out.fail(nil)
+	h.call("procedure", 1)
+	h.out.fail(yarpcerrors.UnavailableErrorf("great sadness"))
+	h.call("procedure", 2)
+	assert.Equal(t, Closed, h.mw.State("service", "procedure"), "a success resets the consecutive failures")
+
+	h.call("procedure", 1)
+	assert.Equal(t, Open, h.mw.State("service", "procedure"))
+}
+
+func TestClientErrorsDoNotOpen(t *testing.T) {
+	h := newHarness(t)
+	h.out.fail(yarpcerrors.InvalidArgumentErrorf("bad request"))
+
+	assert.Zero(t, h.call("procedure", 50))
+	assert.Equal(t, Closed, h.mw.State("service", "procedure"))
+}
+
+func TestClassifier(t *testing.T) {
+	errSad := errors.New("great sadness")
+	h := newHarness(t, Classifier(func(err error) bool {
+		return yarpcerrors.FromError(err).Code() == yarpcerrors.CodeInvalidArgument
+	}))
+
+	h.out.fail(errSad)
+	h.call("procedure", 20)
+	assert.Equal(t, Closed, h.mw.State("service", "procedure"))
+
+	h.out.fail(yarpcerrors.InvalidArgumentErrorf("bad request"))
+	h.call("procedure", 40)
+	assert.Equal(t, Open, h.mw.State("service", "procedure"))
+}
+
+func TestHalfOpenProbesSucceed(t *testing.T) {
+	h := newHarness(t)
+	h.out.fail(yarpcerrors.UnavailableErrorf("great sadness"))
+	h.call("procedure", 10)
+	require.Equal(t, Open, h.mw.State("service", "procedure"))
+
+	h.clock.Add(59 * time.Second)
+	assert.Equal(t, 1, h.call("procedure", 1), "the breaker must stay open until its cool-down elapses")
+
+	h.clock.Add(time.Second)
+	h.out.fail(nil)
+	assert.Zero(t, h.call("procedure", 1))
+	assert.Equal(t, HalfOpen, h.mw.State("service", "procedure"))
+	assert.Zero(t, h.call("procedure", 1))
+	assert.Equal(t, Closed, h.mw.State("service", "procedure"))
+	assert.Zero(t, h.call("procedure", 10))
+
+	assert.Equal(t, map[string]int64{
+		"breaker_state:procedure":               int64(Closed),
+		"breaker_transitions:closed->open":      1,
+		"breaker_transitions:open->half-open":   1,
+		"breaker_transitions:half-open->closed": 1,
+		"breaker_rejected":                      1,
+	}, h.metrics())
+}
+
+func TestHalfOpenProbeFails(t *testing.T) {
+	h := newHarness(t)
+	h.out.fail(yarpcerrors.UnavailableErrorf("great sadness"))
+	h.call("procedure", 10)
+	require.Equal(t, Open, h.mw.State("service", "procedure"))
+
+	h.clock.Add(time.Minute)
+	h.out.fail(nil)
+	h.call("procedure", 1)
+	h.out.fail(yarpcerrors.UnavailableErrorf("great sadness"))
+	h.call("procedure", 1)
+	assert.Equal(t, Open, h.mw.State("service", "procedure"), "a failed probe must open the breaker again")
+	assert.Equal(t, 3, h.call("procedure", 3))
+
+	// The breaker probes again after another cool-down.
+	h.clock.Add(time.Minute)
+	h.out.fail(nil)
+	assert.Zero(t, h.call("procedure", 2))
+	assert.Equal(t, Closed, h.mw.State("service", "procedure"))
+}
+
+// blockingOutbound blocks calls until released.
+type blockingOutbound struct {
+	transport.UnaryOutbound
+
+	started chan struct{}
+	release chan struct{}
+}
+
+func (o *blockingOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	o.started <- struct{}{}
+	<-o.release
+	return &transport.Response{}, nil
+}
+
+func TestHalfOpenLimitsProbes(t *testing.T) {
+	h := newHarness(t)
+	h.out.fail(yarpcerrors.UnavailableErrorf("great sadness"))
+	h.call("procedure", 10)
+	h.clock.Add(time.Minute)
+
+	out := &blockingOutbound{started: make(chan struct{}), release: make(chan struct{})}
+	req := &transport.Request{Service: "service", Procedure: "procedure"}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := h.mw.Call(context.Background(), req, out)
+			assert.NoError(t, err)
+		}()
+		<-out.started
+	}
+
+	_, err := h.mw.Call(context.Background(), req, out)
+	assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code(),
+		"calls beyond the probes must fail while they are in flight")
+
+	close(out.release)
+	wg.Wait()
+	assert.Equal(t, Closed, h.mw.State("service", "procedure"))
+}
+
+func TestStaleResultsIgnored(t *testing.T) {
+	h := newHarness(t, ConsecutiveFailures(1))
+	req := &transport.Request{Service: "service", Procedure: "procedure"}
+
+	// A call sent while the breaker was closed must not count as a probe
+	// when it finishes after the breaker opened.
+	generation, ok := h.mw.allow(procedureKey{"service", "procedure"})
+	require.True(t, ok)
+	h.out.fail(yarpcerrors.UnavailableErrorf("great sadness"))
+	h.call("procedure", 1)
+	require.Equal(t, Open, h.mw.State("service", "procedure"))
+
+	h.clock.Add(time.Minute)
+	h.out.fail(nil)
+	_, err := h.mw.Call(context.Background(), req, h.out)
+	require.NoError(t, err)
+	h.mw.record(procedureKey{"service", "procedure"}, generation, false)
+	assert.Equal(t, HalfOpen, h.mw.State("service", "procedure"))
+}
+
+func TestStateString(t *testing.T) {
+	assert.Equal(t, "closed", Closed.String())
+	assert.Equal(t, "half-open", HalfOpen.String())
+	assert.Equal(t, "open", Open.String())
+	assert.Equal(t, "unknown", State(42).String())
+}
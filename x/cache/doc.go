@@ -0,0 +1,57 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package cache provides middleware that caches responses of procedures whose
+// results are a pure function of their request.
+//
+// The inbound middleware answers repeated requests from a CacheStore without
+// calling the handler:
+//
+// 	store := cache.WithMemoryStore(1000)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: cache.NewInboundMiddleware(store, time.Minute, func(req *transport.Request) bool {
+// 				return req.Procedure == "Users::get"
+// 			}),
+// 		},
+// 	})
+//
+// Only successful responses are cached. Responses carrying an application
+// error and handlers returning an error are never stored.
+//
+// The outbound middleware answers repeated calls from a CacheStore without
+// sending them, and collapses concurrent identical calls that miss the cache
+// into a single request:
+//
+// 	caching := cache.NewOutboundMiddleware(store, time.Minute,
+// 		cache.ProcedureTTL("Users::list", 5*time.Second),
+// 		cache.KeyHeaders("tenant"),
+// 		cache.CacheErrors(time.Second, yarpcerrors.CodeNotFound),
+// 	)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary: caching,
+// 		},
+// 	})
+//
+// Inbound and outbound middleware may share a store.
+package cache
@@ -0,0 +1,204 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/recordwriter"
+)
+
+const _jsonEncoding transport.Encoding = "json"
+
+var errMalformedEntry = errors.New("malformed cache entry")
+
+type inboundMiddleware struct {
+	store       CacheStore
+	ttl         time.Duration
+	isCacheable func(*transport.Request) bool
+}
+
+// NewInboundMiddleware builds unary inbound middleware that serves responses
+// from the given store for requests accepted by isCacheable, skipping the
+// handler entirely on a cache hit.
+//
+// Responses are keyed by the service, procedure, encoding and a canonical
+// representation of the request body, and are retained for the given TTL.
+// JSON bodies are canonicalized so that requests differing only in
+// whitespace or key order share an entry; all other bodies are compared
+// byte-for-byte.
+func NewInboundMiddleware(store CacheStore, ttl time.Duration, isCacheable func(*transport.Request) bool) middleware.UnaryInbound {
+	return &inboundMiddleware{
+		store:       store,
+		ttl:         ttl,
+		isCacheable: isCacheable,
+	}
+}
+
+func (m *inboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	if m.isCacheable == nil || !m.isCacheable(req) {
+		return h.Handle(ctx, req, resw)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return err
+		}
+	}
+	// The handler must still be able to read the body on a miss.
+	req.Body = bytes.NewReader(body)
+
+	key := cacheKey(req, body)
+	if val, ok := m.store.Get(key); ok {
+		if headers, resBody, err := decodeEntry(val); err == nil {
+			resw.AddHeaders(headers)
+			_, err := resw.Write(resBody)
+			return err
+		}
+	}
+
+	rw := recordwriter.New(resw)
+	if err := h.Handle(ctx, req, rw); err != nil {
+		return err
+	}
+	if !rw.IsApplicationError() {
+		m.store.Set(key, encodeEntry(rw.Headers(), rw.Body()), m.ttl)
+	}
+	return nil
+}
+
+// cacheKey hashes the identifying parts of a request into a store key.
+func cacheKey(req *transport.Request, body []byte) string {
+	h := sha256.New()
+	for _, s := range []string{req.Service, req.Procedure, string(req.Encoding)} {
+		writeBytes(h, []byte(s))
+	}
+	writeBytes(h, canonicalBody(req.Encoding, body))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// canonicalBody returns a representation of the body that is stable across
+// semantically equivalent requests.
+func canonicalBody(encoding transport.Encoding, body []byte) []byte {
+	if encoding != _jsonEncoding {
+		return body
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+	// Trailing data makes the body malformed, so it must not share an entry
+	// with the well-formed value before it.
+	if dec.More() {
+		return body
+	}
+	// encoding/json sorts map keys, giving us a canonical form.
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return canonical
+}
+
+// encodeEntry serializes response headers and body as a sequence of
+// length-prefixed fields: the number of headers, each header key and value,
+// and finally the body.
+func encodeEntry(headers transport.Headers, body []byte) []byte {
+	items := headers.OriginalItems()
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	writeUvarint(&buf, uint64(len(keys)))
+	for _, k := range keys {
+		writeBytes(&buf, []byte(k))
+		writeBytes(&buf, []byte(items[k]))
+	}
+	writeBytes(&buf, body)
+	return buf.Bytes()
+}
+
+func decodeEntry(val []byte) (transport.Headers, []byte, error) {
+	r := bytes.NewReader(val)
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return transport.Headers{}, nil, errMalformedEntry
+	}
+
+	var headers transport.Headers
+	for i := uint64(0); i < n; i++ {
+		k, err := readBytes(r)
+		if err != nil {
+			return transport.Headers{}, nil, err
+		}
+		v, err := readBytes(r)
+		if err != nil {
+			return transport.Headers{}, nil, err
+		}
+		headers = headers.With(string(k), string(v))
+	}
+
+	body, err := readBytes(r)
+	if err != nil || r.Len() > 0 {
+		return transport.Headers{}, nil, errMalformedEntry
+	}
+	return headers, body, nil
+}
+
+func writeUvarint(w io.Writer, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func writeBytes(w io.Writer, b []byte) {
+	writeUvarint(w, uint64(len(b)))
+	w.Write(b)
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > uint64(r.Len()) {
+		return nil, errMalformedEntry
+	}
+	b := make([]byte, n)
+	r.Read(b)
+	return b, nil
+}
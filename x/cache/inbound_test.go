@@ -0,0 +1,202 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/encoding/raw"
+)
+
+type countingHandler struct {
+	calls   int
+	handler func(*transport.Request, transport.ResponseWriter) error
+}
+
+func (h *countingHandler) Handle(_ context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	h.calls++
+	return h.handler(req, resw)
+}
+
+// echo writes the request body back with a header.
+func echo(req *transport.Request, resw transport.ResponseWriter) error {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	resw.AddHeaders(transport.NewHeaders().With("Echo-Procedure", req.Procedure))
+	_, err = resw.Write(body)
+	return err
+}
+
+func cacheAll(*transport.Request) bool { return true }
+
+func call(t *testing.T, mw transport.UnaryHandler, procedure string, encoding transport.Encoding, body string) *transporttest.FakeResponseWriter {
+	resw := &transporttest.FakeResponseWriter{}
+	err := mw.Handle(context.Background(), &transport.Request{
+		Service:   "service",
+		Procedure: procedure,
+		Encoding:  encoding,
+		Body:      bytes.NewReader([]byte(body)),
+	}, resw)
+	require.NoError(t, err)
+	return resw
+}
+
+func newHandler(store CacheStore, isCacheable func(*transport.Request) bool, h transport.UnaryHandler) transport.UnaryHandler {
+	return middleware.ApplyUnaryInbound(h, NewInboundMiddleware(store, time.Minute, isCacheable))
+}
+
+func TestInboundMiddlewareCacheHit(t *testing.T) {
+	h := &countingHandler{handler: echo}
+	handler := newHandler(WithMemoryStore(10), cacheAll, h)
+
+	first := call(t, handler, "echo", raw.Encoding, "hello")
+	second := call(t, handler, "echo", raw.Encoding, "hello")
+
+	assert.Equal(t, 1, h.calls, "handler must be skipped on a cache hit")
+	for _, resw := range []*transporttest.FakeResponseWriter{first, second} {
+		assert.Equal(t, "hello", resw.Body.String())
+		assert.Equal(t, map[string]string{"Echo-Procedure": "echo"}, resw.Headers.OriginalItems())
+	}
+}
+
+func TestInboundMiddlewareCacheKey(t *testing.T) {
+	h := &countingHandler{handler: echo}
+	handler := newHandler(WithMemoryStore(10), cacheAll, h)
+
+	call(t, handler, "echo", raw.Encoding, "hello")
+	call(t, handler, "echo", raw.Encoding, "world")
+	call(t, handler, "other", raw.Encoding, "hello")
+	assert.Equal(t, 3, h.calls, "distinct procedures and bodies must not share entries")
+
+	call(t, handler, "json", "json", `{"a": 1, "b": [true, null]}`)
+	resw := call(t, handler, "json", "json", `{"b":[true,null],"a":1}`)
+	assert.Equal(t, 4, h.calls, "equivalent JSON bodies must share an entry")
+	assert.Equal(t, `{"a": 1, "b": [true, null]}`, resw.Body.String())
+
+	resw = call(t, handler, "json", "json", `{"a": 1, "b": [true, null]} garbage`)
+	assert.Equal(t, 5, h.calls, "JSON bodies with trailing data must not share an entry")
+	assert.Equal(t, `{"a": 1, "b": [true, null]} garbage`, resw.Body.String())
+
+	call(t, handler, "json", "json", `{"b":[true,null],"a":1}`+"\n")
+	assert.Equal(t, 5, h.calls, "trailing whitespace must not change the entry")
+}
+
+func TestInboundMiddlewareNotCacheable(t *testing.T) {
+	h := &countingHandler{handler: echo}
+	handler := newHandler(WithMemoryStore(10), func(req *transport.Request) bool {
+		return req.Procedure == "cached"
+	}, h)
+
+	call(t, handler, "uncached", raw.Encoding, "hello")
+	call(t, handler, "uncached", raw.Encoding, "hello")
+	assert.Equal(t, 2, h.calls)
+
+	call(t, handler, "cached", raw.Encoding, "hello")
+	call(t, handler, "cached", raw.Encoding, "hello")
+	assert.Equal(t, 3, h.calls)
+}
+
+func TestInboundMiddlewareSkipsFailures(t *testing.T) {
+	t.Run("application error", func(t *testing.T) {
+		h := &countingHandler{handler: func(req *transport.Request, resw transport.ResponseWriter) error {
+			resw.SetApplicationError()
+			return echo(req, resw)
+		}}
+		handler := newHandler(WithMemoryStore(10), cacheAll, h)
+
+		resw := call(t, handler, "echo", raw.Encoding, "hello")
+		assert.True(t, resw.IsApplicationError)
+		call(t, handler, "echo", raw.Encoding, "hello")
+		assert.Equal(t, 2, h.calls)
+	})
+
+	t.Run("handler error", func(t *testing.T) {
+		h := &countingHandler{handler: func(*transport.Request, transport.ResponseWriter) error {
+			return errors.New("great sadness")
+		}}
+		handler := newHandler(WithMemoryStore(10), cacheAll, h)
+
+		for i := 0; i < 2; i++ {
+			err := handler.Handle(context.Background(), &transport.Request{
+				Procedure: "echo",
+				Body:      bytes.NewReader([]byte("hello")),
+			}, &transporttest.FakeResponseWriter{})
+			assert.EqualError(t, err, "great sadness")
+		}
+		assert.Equal(t, 2, h.calls)
+	})
+}
+
+func TestInboundMiddlewareMalformedEntry(t *testing.T) {
+	store := WithMemoryStore(10)
+	h := &countingHandler{handler: echo}
+	handler := newHandler(store, cacheAll, h)
+
+	req := &transport.Request{Service: "service", Procedure: "echo", Encoding: raw.Encoding}
+	store.Set(cacheKey(req, []byte("hello")), []byte{0xff}, time.Minute)
+
+	resw := call(t, handler, "echo", raw.Encoding, "hello")
+	assert.Equal(t, "hello", resw.Body.String())
+	assert.Equal(t, 1, h.calls, "malformed entries must be treated as a miss")
+}
+
+func TestEntryRoundTrip(t *testing.T) {
+	tests := []struct {
+		desc    string
+		headers transport.Headers
+		body    []byte
+	}{
+		{desc: "empty"},
+		{desc: "body only", body: []byte("hello")},
+		{
+			desc:    "headers and body",
+			headers: transport.NewHeaders().With("Foo", "bar").With("baz", ""),
+			body:    []byte("hello"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			headers, body, err := decodeEntry(encodeEntry(tt.headers, tt.body))
+			require.NoError(t, err)
+			assert.Equal(t, tt.headers.OriginalItems(), headers.OriginalItems())
+			assert.Equal(t, string(tt.body), string(body))
+		})
+	}
+
+	val := encodeEntry(transport.NewHeaders().With("foo", "bar"), []byte("hello"))
+	for i := 0; i < len(val); i++ {
+		_, _, err := decodeEntry(val[:i])
+		assert.Error(t, err, "truncated entry of length %d must fail", i)
+	}
+}
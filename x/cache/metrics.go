@@ -0,0 +1,85 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/zap"
+)
+
+const (
+	_serviceTag   = "service"
+	_procedureTag = "procedure"
+)
+
+type outboundMetrics struct {
+	hits      *metrics.CounterVector
+	misses    *metrics.CounterVector
+	coalesced *metrics.CounterVector
+}
+
+func newOutboundMetrics(meter *metrics.Scope, logger *zap.Logger) *outboundMetrics {
+	tags := []string{_serviceTag, _procedureTag}
+
+	hits, err := meter.CounterVector(metrics.Spec{
+		Name:    "cache_outbound_hits",
+		Help:    "Total number of outbound calls answered from the cache.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create cache hits counter", zap.Error(err))
+	}
+	misses, err := meter.CounterVector(metrics.Spec{
+		Name:    "cache_outbound_misses",
+		Help:    "Total number of outbound calls that missed the cache and were sent.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create cache misses counter", zap.Error(err))
+	}
+	coalesced, err := meter.CounterVector(metrics.Spec{
+		Name:    "cache_outbound_coalesced",
+		Help:    "Total number of outbound calls that missed the cache and shared the result of an identical call in flight.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create cache coalesced counter", zap.Error(err))
+	}
+
+	return &outboundMetrics{
+		hits:      hits,
+		misses:    misses,
+		coalesced: coalesced,
+	}
+}
+
+func (m *outboundMetrics) incHits(req *transport.Request) {
+	m.hits.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure).Inc()
+}
+
+func (m *outboundMetrics) incMisses(req *transport.Request) {
+	m.misses.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure).Inc()
+}
+
+func (m *outboundMetrics) incCoalesced(req *transport.Request) {
+	m.coalesced.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure).Inc()
+}
@@ -0,0 +1,378 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	_successEntry byte = iota
+	_errorEntry
+)
+
+// OutboundOption customizes the behavior of the outbound caching middleware.
+type OutboundOption interface {
+	apply(*outboundOptions)
+}
+
+type outboundOptionFunc func(*outboundOptions)
+
+func (f outboundOptionFunc) apply(o *outboundOptions) { f(o) }
+
+type outboundOptions struct {
+	procedureTTLs map[string]time.Duration
+	keyHeaders    []string
+	keyFunc       func(req *transport.Request, body []byte) string
+	maxEntrySize  int
+	errorTTL      time.Duration
+	errorCodes    map[yarpcerrors.Code]struct{}
+	meter         *metrics.Scope
+	logger        *zap.Logger
+}
+
+// ProcedureTTL overrides the TTL of responses of the given procedure. A TTL
+// of zero disables caching for the procedure.
+func ProcedureTTL(procedure string, ttl time.Duration) OutboundOption {
+	return outboundOptionFunc(func(o *outboundOptions) {
+		if ttl >= 0 {
+			o.procedureTTLs[procedure] = ttl
+		}
+	})
+}
+
+// KeyHeaders includes the values of the given request headers in the cache
+// key, so that requests differing in these headers do not share an entry.
+// Other headers are ignored.
+func KeyHeaders(names ...string) OutboundOption {
+	return outboundOptionFunc(func(o *outboundOptions) {
+		o.keyHeaders = append(o.keyHeaders, names...)
+	})
+}
+
+// KeyFunc replaces the function that derives the cache key of a request from
+// the request and its body. The default key is a hash of the service,
+// procedure, encoding, the canonical body and the headers named by
+// KeyHeaders.
+func KeyFunc(f func(req *transport.Request, body []byte) string) OutboundOption {
+	return outboundOptionFunc(func(o *outboundOptions) {
+		if f != nil {
+			o.keyFunc = f
+		}
+	})
+}
+
+// MaxEntrySize bounds the size in bytes of the response bodies that are
+// cached. Larger responses are returned to the caller but not stored.
+// Defaults to 1MiB.
+func MaxEntrySize(n int) OutboundOption {
+	return outboundOptionFunc(func(o *outboundOptions) {
+		if n > 0 {
+			o.maxEntrySize = n
+		}
+	})
+}
+
+// CacheErrors caches errors with the given codes for the given TTL, so that a
+// failing dependency is not asked again for the same request until the TTL
+// elapses. Only the code and message of the error are retained.
+//
+// By default, errors are never cached.
+func CacheErrors(ttl time.Duration, codes ...yarpcerrors.Code) OutboundOption {
+	return outboundOptionFunc(func(o *outboundOptions) {
+		if ttl <= 0 {
+			return
+		}
+		o.errorTTL = ttl
+		for _, code := range codes {
+			o.errorCodes[code] = struct{}{}
+		}
+	})
+}
+
+// Meter sets a metrics scope for the middleware's hit, miss and coalesced
+// counters.
+func Meter(meter *metrics.Scope) OutboundOption {
+	return outboundOptionFunc(func(o *outboundOptions) {
+		o.meter = meter
+	})
+}
+
+// Logger sets a logger for the middleware.
+func Logger(logger *zap.Logger) OutboundOption {
+	return outboundOptionFunc(func(o *outboundOptions) {
+		if logger != nil {
+			o.logger = logger
+		}
+	})
+}
+
+// OutboundMiddleware is unary outbound middleware that answers calls from a
+// CacheStore and collapses concurrent identical calls into one.
+type OutboundMiddleware struct {
+	store   CacheStore
+	ttl     time.Duration
+	opts    outboundOptions
+	metrics *outboundMetrics
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+var _ middleware.UnaryOutbound = (*OutboundMiddleware)(nil)
+
+// flight is a call in progress whose result is shared by all callers that
+// missed the cache for the same key while it was running.
+type flight struct {
+	done chan struct{}
+
+	entry cachedResponse
+	res   *transport.Response
+	err   error
+}
+
+// cachedResponse is the part of the result of a call that can be replayed to
+// other callers.
+type cachedResponse struct {
+	headers              transport.Headers
+	body                 []byte
+	applicationError     bool
+	applicationErrorMeta *transport.ApplicationErrorMeta
+	err                  error
+}
+
+func (c cachedResponse) response() (*transport.Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &transport.Response{
+		Headers:              c.headers,
+		Body:                 ioutil.NopCloser(bytes.NewReader(c.body)),
+		BodySize:             len(c.body),
+		ApplicationError:     c.applicationError,
+		ApplicationErrorMeta: c.applicationErrorMeta,
+	}, nil
+}
+
+// NewOutboundMiddleware builds unary outbound middleware that caches
+// successful responses in the given store for the given TTL.
+//
+// Concurrent calls that miss the cache for the same key wait for the first of
+// them to complete and share its result, so that only one request is sent.
+// Waiting calls still honor their own context.
+//
+// Responses carrying an application error are never cached, and errors are
+// only cached with CacheErrors.
+func NewOutboundMiddleware(store CacheStore, ttl time.Duration, opts ...OutboundOption) *OutboundMiddleware {
+	options := outboundOptions{
+		procedureTTLs: make(map[string]time.Duration),
+		maxEntrySize:  1 << 20,
+		errorCodes:    make(map[yarpcerrors.Code]struct{}),
+		logger:        zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt.apply(&options)
+	}
+	if options.keyFunc == nil {
+		headers := options.keyHeaders
+		options.keyFunc = func(req *transport.Request, body []byte) string {
+			return outboundCacheKey(req, body, headers)
+		}
+	}
+	return &OutboundMiddleware{
+		store:   store,
+		ttl:     ttl,
+		opts:    options,
+		metrics: newOutboundMetrics(options.meter, options.logger),
+		flights: make(map[string]*flight),
+	}
+}
+
+// Call implements middleware.UnaryOutbound.
+func (m *OutboundMiddleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	ttl := m.ttl
+	if procedureTTL, ok := m.opts.procedureTTLs[req.Procedure]; ok {
+		ttl = procedureTTL
+	}
+	if ttl <= 0 {
+		return out.Call(ctx, req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+	// The outbound must still be able to read the body on a miss, and the
+	// caller's request must be left untouched.
+	r := *req
+	r.Body = bytes.NewReader(body)
+	r.BodySize = len(body)
+
+	key := m.opts.keyFunc(&r, body)
+	if val, ok := m.store.Get(key); ok {
+		if entry, err := decodeOutboundEntry(val); err == nil {
+			m.metrics.incHits(req)
+			return entry.response()
+		}
+	}
+
+	m.mu.Lock()
+	if f, ok := m.flights[key]; ok {
+		m.mu.Unlock()
+		m.metrics.incCoalesced(req)
+		select {
+		case <-f.done:
+			return f.entry.response()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f := &flight{done: make(chan struct{})}
+	m.flights[key] = f
+	m.mu.Unlock()
+
+	m.metrics.incMisses(req)
+	m.call(ctx, &r, out, key, ttl, f)
+
+	m.mu.Lock()
+	delete(m.flights, key)
+	m.mu.Unlock()
+	close(f.done)
+
+	return f.res, f.err
+}
+
+// call sends the request and records its result in the flight, storing it in
+// the cache if it is cacheable.
+func (m *OutboundMiddleware) call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound, key string, ttl time.Duration, f *flight) {
+	res, err := out.Call(ctx, req)
+	if err != nil {
+		f.err = err
+		f.entry = cachedResponse{err: err}
+		if !yarpcerrors.IsStatus(err) {
+			return
+		}
+		status := yarpcerrors.FromError(err)
+		if _, ok := m.opts.errorCodes[status.Code()]; ok {
+			m.store.Set(key, encodeErrorEntry(status), m.opts.errorTTL)
+		}
+		return
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if closeErr := res.Body.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		f.err = err
+		f.entry = cachedResponse{err: err}
+		return
+	}
+
+	copied := *res
+	copied.Body = ioutil.NopCloser(bytes.NewReader(body))
+	f.res = &copied
+	f.entry = cachedResponse{
+		headers:              res.Headers,
+		body:                 body,
+		applicationError:     res.ApplicationError,
+		applicationErrorMeta: res.ApplicationErrorMeta,
+	}
+	if res.ApplicationError || len(body) > m.opts.maxEntrySize {
+		return
+	}
+	m.store.Set(key, append([]byte{_successEntry}, encodeEntry(res.Headers, body)...), ttl)
+}
+
+// outboundCacheKey hashes the identifying parts of a request, including the
+// values of the given headers, into a store key. Keys are distinct from
+// those of the inbound middleware so that both may share a store.
+func outboundCacheKey(req *transport.Request, body []byte, headers []string) string {
+	h := sha256.New()
+	writeBytes(h, []byte("outbound"))
+	for _, s := range []string{req.Service, req.Procedure, string(req.Encoding)} {
+		writeBytes(h, []byte(s))
+	}
+	writeBytes(h, canonicalBody(req.Encoding, body))
+
+	names := append([]string(nil), headers...)
+	sort.Strings(names)
+	for _, name := range names {
+		v, _ := req.Headers.Get(name)
+		writeBytes(h, []byte(transport.CanonicalizeHeaderKey(name)))
+		writeBytes(h, []byte(v))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// encodeErrorEntry serializes the code and message of an error behind a
+// leading marker that distinguishes it from a cached response.
+func encodeErrorEntry(status *yarpcerrors.Status) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(_errorEntry)
+	writeUvarint(&buf, uint64(status.Code()))
+	writeBytes(&buf, []byte(status.Message()))
+	return buf.Bytes()
+}
+
+func decodeOutboundEntry(val []byte) (cachedResponse, error) {
+	if len(val) == 0 {
+		return cachedResponse{}, errMalformedEntry
+	}
+	switch val[0] {
+	case _successEntry:
+		headers, body, err := decodeEntry(val[1:])
+		if err != nil {
+			return cachedResponse{}, err
+		}
+		return cachedResponse{headers: headers, body: body}, nil
+	case _errorEntry:
+		r := bytes.NewReader(val[1:])
+		code, err := binary.ReadUvarint(r)
+		if err != nil {
+			return cachedResponse{}, errMalformedEntry
+		}
+		message, err := readBytes(r)
+		if err != nil || r.Len() > 0 {
+			return cachedResponse{}, errMalformedEntry
+		}
+		return cachedResponse{err: yarpcerrors.Newf(yarpcerrors.Code(code), "%s", message)}, nil
+	default:
+		return cachedResponse{}, errMalformedEntry
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// fakeOutbound answers calls with the given function, counting them.
+type fakeOutbound struct {
+	transport.UnaryOutbound
+
+	calls atomic.Int32
+	call  func(*transport.Request) (*transport.Response, error)
+}
+
+func (o *fakeOutbound) Call(_ context.Context, req *transport.Request) (*transport.Response, error) {
+	o.calls.Inc()
+	return o.call(req)
+}
+
+// echoOutbound responds with the request body and a header.
+func echoOutbound(req *transport.Request) (*transport.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &transport.Response{
+		Headers: transport.NewHeaders().With("Echo-Procedure", req.Procedure),
+		Body:    ioutil.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+func newOutboundRequest(procedure, body string) *transport.Request {
+	return &transport.Request{
+		Service:   "service",
+		Procedure: procedure,
+		Encoding:  "raw",
+		Body:      bytes.NewReader([]byte(body)),
+	}
+}
+
+func callOutbound(t *testing.T, mw *OutboundMiddleware, out transport.UnaryOutbound, req *transport.Request) string {
+	res, err := mw.Call(context.Background(), req, out)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	return string(body)
+}
+
+func cacheCounters(root *metrics.Root) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, c := range root.Snapshot().Counters {
+		counts[c.Name] += c.Value
+	}
+	return counts
+}
+
+func TestOutboundMiddlewareCacheHit(t *testing.T) {
+	root := metrics.New()
+	out := &fakeOutbound{call: echoOutbound}
+	mw := NewOutboundMiddleware(WithMemoryStore(10), time.Minute, Meter(root.Scope()))
+
+	assert.Equal(t, "hello", callOutbound(t, mw, out, newOutboundRequest("echo", "hello")))
+	res, err := mw.Call(context.Background(), newOutboundRequest("echo", "hello"), out)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, len(body), res.BodySize)
+	assert.Equal(t, transport.NewHeaders().With("Echo-Procedure", "echo"), res.Headers)
+	assert.Equal(t, int32(1), out.calls.Load(), "second call must be answered from the cache")
+	assert.Equal(t, map[string]int64{
+		"cache_outbound_hits":   1,
+		"cache_outbound_misses": 1,
+	}, cacheCounters(root))
+}
+
+func TestOutboundMiddlewareCacheKey(t *testing.T) {
+	out := &fakeOutbound{call: echoOutbound}
+	mw := NewOutboundMiddleware(WithMemoryStore(10), time.Minute, KeyHeaders("tenant"))
+
+	withTenant := func(req *transport.Request, tenant string) *transport.Request {
+		req.Headers = transport.NewHeaders().With("Tenant", tenant).With("Request-Id", tenant)
+		return req
+	}
+
+	callOutbound(t, mw, out, newOutboundRequest("echo", "a"))
+	callOutbound(t, mw, out, newOutboundRequest("echo", "b"))
+	callOutbound(t, mw, out, newOutboundRequest("other", "a"))
+	assert.Equal(t, int32(3), out.calls.Load(), "procedures and bodies must have distinct entries")
+
+	callOutbound(t, mw, out, withTenant(newOutboundRequest("echo", "a"), "x"))
+	callOutbound(t, mw, out, withTenant(newOutboundRequest("echo", "a"), "y"))
+	assert.Equal(t, int32(5), out.calls.Load(), "key headers must be part of the key")
+
+	req := withTenant(newOutboundRequest("echo", "a"), "x")
+	req.Headers = req.Headers.With("Request-Id", "z")
+	callOutbound(t, mw, out, req)
+	assert.Equal(t, int32(5), out.calls.Load(), "other headers must not be part of the key")
+}
+
+func TestOutboundMiddlewareTTL(t *testing.T) {
+	now := time.Now()
+	store := newMemoryStore(10, func() time.Time { return now })
+	out := &fakeOutbound{call: echoOutbound}
+	mw := NewOutboundMiddleware(store, time.Minute, ProcedureTTL("short", time.Second), ProcedureTTL("never", 0))
+
+	for _, procedure := range []string{"echo", "short", "never"} {
+		callOutbound(t, mw, out, newOutboundRequest(procedure, "body"))
+		callOutbound(t, mw, out, newOutboundRequest(procedure, "body"))
+	}
+	assert.Equal(t, int32(4), out.calls.Load(), "only the procedure without a TTL must be called twice")
+
+	now = now.Add(time.Second)
+	callOutbound(t, mw, out, newOutboundRequest("short", "body"))
+	callOutbound(t, mw, out, newOutboundRequest("echo", "body"))
+	assert.Equal(t, int32(5), out.calls.Load(), "only the expired entry must be refreshed")
+
+	now = now.Add(time.Minute)
+	callOutbound(t, mw, out, newOutboundRequest("echo", "body"))
+	assert.Equal(t, int32(6), out.calls.Load(), "entries must expire after the default TTL")
+}
+
+func TestOutboundMiddlewareCoalescing(t *testing.T) {
+	const callers = 10
+
+	root := metrics.New()
+	release := make(chan struct{})
+	out := &fakeOutbound{call: func(req *transport.Request) (*transport.Response, error) {
+		<-release
+		return echoOutbound(req)
+	}}
+	mw := NewOutboundMiddleware(WithMemoryStore(10), time.Minute, Meter(root.Scope()))
+
+	var wg sync.WaitGroup
+	bodies := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = callOutbound(t, mw, out, newOutboundRequest("echo", "hello"))
+		}(i)
+	}
+
+	// Release the call only once every other caller waits for it.
+	require.Eventually(t, func() bool {
+		coalesced := mw.metrics.coalesced.MustGet(_serviceTag, "service", _procedureTag, "echo")
+		return coalesced.Load() == callers-1
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), out.calls.Load(), "concurrent misses must be collapsed into one call")
+	for i, body := range bodies {
+		assert.Equal(t, "hello", body, "caller %d must receive the response", i)
+	}
+	assert.Equal(t, int64(1), cacheCounters(root)["cache_outbound_misses"])
+}
+
+func TestOutboundMiddlewareCoalescedCallerContext(t *testing.T) {
+	release := make(chan struct{})
+	out := &fakeOutbound{call: func(req *transport.Request) (*transport.Response, error) {
+		<-release
+		return echoOutbound(req)
+	}}
+	root := metrics.New()
+	mw := NewOutboundMiddleware(WithMemoryStore(10), time.Minute, Meter(root.Scope()))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.Equal(t, "hello", callOutbound(t, mw, out, newOutboundRequest("echo", "hello")))
+	}()
+	require.Eventually(t, func() bool { return out.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := mw.Call(ctx, newOutboundRequest("echo", "hello"), out)
+	assert.Equal(t, context.Canceled, err, "waiting callers must honor their own context")
+
+	close(release)
+	<-done
+}
+
+func TestOutboundMiddlewareErrors(t *testing.T) {
+	now := time.Now()
+	store := newMemoryStore(10, func() time.Time { return now })
+	var code yarpcerrors.Code
+	out := &fakeOutbound{call: func(*transport.Request) (*transport.Response, error) {
+		return nil, yarpcerrors.Newf(code, "failed with %v", code)
+	}}
+	mw := NewOutboundMiddleware(store, time.Minute, CacheErrors(time.Second, yarpcerrors.CodeNotFound))
+
+	code = yarpcerrors.CodeInternal
+	for i := 0; i < 2; i++ {
+		_, err := mw.Call(context.Background(), newOutboundRequest("get", "internal"), out)
+		assert.Equal(t, yarpcerrors.CodeInternal, yarpcerrors.FromError(err).Code())
+	}
+	assert.Equal(t, int32(2), out.calls.Load(), "errors must not be cached by default")
+
+	code = yarpcerrors.CodeNotFound
+	for i := 0; i < 2; i++ {
+		_, err := mw.Call(context.Background(), newOutboundRequest("get", "not-found"), out)
+		assert.Equal(t, yarpcerrors.NotFoundErrorf("failed with not-found"), err)
+	}
+	assert.Equal(t, int32(3), out.calls.Load(), "errors with the given codes must be cached")
+
+	now = now.Add(time.Second)
+	_, err := mw.Call(context.Background(), newOutboundRequest("get", "not-found"), out)
+	assert.Error(t, err)
+	assert.Equal(t, int32(4), out.calls.Load(), "cached errors must expire after their TTL")
+}
+
+func TestOutboundMiddlewareSkipsUncacheableResponses(t *testing.T) {
+	out := &fakeOutbound{call: func(req *transport.Request) (*transport.Response, error) {
+		res, err := echoOutbound(req)
+		res.ApplicationError = req.Procedure == "app-error"
+		return res, err
+	}}
+	mw := NewOutboundMiddleware(WithMemoryStore(10), time.Minute, MaxEntrySize(4))
+
+	for i := 0; i < 2; i++ {
+		res, err := mw.Call(context.Background(), newOutboundRequest("app-error", "body"), out)
+		require.NoError(t, err)
+		assert.True(t, res.ApplicationError)
+	}
+	assert.Equal(t, int32(2), out.calls.Load(), "application errors must not be cached")
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, "too large", callOutbound(t, mw, out, newOutboundRequest("echo", "too large")))
+	}
+	assert.Equal(t, int32(4), out.calls.Load(), "responses above the maximum size must not be cached")
+
+	callOutbound(t, mw, out, newOutboundRequest("echo", "ok"))
+	callOutbound(t, mw, out, newOutboundRequest("echo", "ok"))
+	assert.Equal(t, int32(5), out.calls.Load())
+}
+
+func TestOutboundMiddlewareKeyFunc(t *testing.T) {
+	out := &fakeOutbound{call: echoOutbound}
+	mw := NewOutboundMiddleware(WithMemoryStore(10), time.Minute, KeyFunc(func(req *transport.Request, _ []byte) string {
+		return req.Procedure
+	}))
+
+	assert.Equal(t, "a", callOutbound(t, mw, out, newOutboundRequest("echo", "a")))
+	assert.Equal(t, "a", callOutbound(t, mw, out, newOutboundRequest("echo", "b")))
+	assert.Equal(t, int32(1), out.calls.Load())
+}
+
+func TestOutboundEntryRoundTrip(t *testing.T) {
+	entry, err := decodeOutboundEntry(encodeErrorEntry(yarpcerrors.FromError(yarpcerrors.UnavailableErrorf("100%% down"))))
+	require.NoError(t, err)
+	assert.Equal(t, yarpcerrors.UnavailableErrorf("100%% down"), entry.err)
+
+	for _, val := range [][]byte{nil, {42}, {_errorEntry}, {_successEntry, 1}} {
+		_, err := decodeOutboundEntry(val)
+		assert.Equal(t, errMalformedEntry, err, "entry %v", val)
+	}
+}
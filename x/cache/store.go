@@ -0,0 +1,119 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheStore stores serialized responses by key.
+//
+// Implementations MUST be safe for concurrent use.
+type CacheStore interface {
+	// Get returns the value stored for the given key, if present and not
+	// expired.
+	Get(key string) ([]byte, bool)
+
+	// Set stores the given value for the given key. The value should not be
+	// returned by Get after the TTL elapses.
+	Set(key string, val []byte, ttl time.Duration)
+}
+
+// WithMemoryStore builds an in-memory CacheStore holding at most maxEntries
+// values. When full, the least recently used entry is evicted.
+//
+// A maxEntries of zero or less leaves the store unbounded.
+func WithMemoryStore(maxEntries int) CacheStore {
+	return newMemoryStore(maxEntries, time.Now)
+}
+
+type memoryStore struct {
+	maxEntries int
+	now        func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	// Most recently used entries are at the front.
+	lru *list.List
+}
+
+type memoryEntry struct {
+	key     string
+	val     []byte
+	expires time.Time
+}
+
+func newMemoryStore(maxEntries int, now func() time.Time) *memoryStore {
+	return &memoryStore{
+		maxEntries: maxEntries,
+		now:        now,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (s *memoryStore) Get(key string) ([]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !s.now().Before(entry.expires) {
+		s.remove(elem)
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	return entry.val, true
+}
+
+func (s *memoryStore) Set(key string, val []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	expires := s.now().Add(ttl)
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*memoryEntry)
+		entry.val = val
+		entry.expires = expires
+		s.lru.MoveToFront(elem)
+		return
+	}
+
+	s.entries[key] = s.lru.PushFront(&memoryEntry{key: key, val: val, expires: expires})
+	if s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		s.remove(s.lru.Back())
+	}
+}
+
+// remove deletes the given element. The lock must be held.
+func (s *memoryStore) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.entries, elem.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreTTL(t *testing.T) {
+	now := time.Now()
+	store := newMemoryStore(10, func() time.Time { return now })
+
+	store.Set("foo", []byte("bar"), time.Second)
+	store.Set("ignored", []byte("bar"), 0)
+
+	val, ok := store.Get("foo")
+	assert.True(t, ok)
+	assert.Equal(t, "bar", string(val))
+	_, ok = store.Get("ignored")
+	assert.False(t, ok, "entries without a TTL must not be stored")
+
+	now = now.Add(time.Second)
+	_, ok = store.Get("foo")
+	assert.False(t, ok, "expired entries must not be returned")
+	assert.Empty(t, store.entries, "expired entries must be removed")
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	store := newMemoryStore(2, time.Now)
+
+	store.Set("a", []byte("1"), time.Minute)
+	store.Set("b", []byte("2"), time.Minute)
+	store.Get("a") // b is now least recently used
+	store.Set("c", []byte("3"), time.Minute)
+
+	_, ok := store.Get("b")
+	assert.False(t, ok, "least recently used entry must be evicted")
+	for _, key := range []string{"a", "c"} {
+		_, ok := store.Get(key)
+		assert.True(t, ok, "expected %q to be retained", key)
+	}
+
+	store.Set("a", []byte("4"), time.Minute)
+	val, _ := store.Get("a")
+	assert.Equal(t, "4", string(val), "Set must overwrite existing entries")
+	assert.Equal(t, 2, store.lru.Len())
+}
+
+func TestMemoryStoreUnbounded(t *testing.T) {
+	store := newMemoryStore(0, time.Now)
+	for _, key := range []string{"a", "b", "c"} {
+		store.Set(key, nil, time.Minute)
+	}
+	assert.Equal(t, 3, store.lru.Len())
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	gzipName = "gzip"
+	zstdName = "zstd"
+)
+
+// errTooLarge is returned by codecs when a body decompresses to more than
+// their maximum size.
+var errTooLarge = errors.New("decompressed body is too large")
+
+// codec compresses and decompresses whole bodies.
+type codec interface {
+	compress(src []byte) ([]byte, error)
+	decompress(src []byte) ([]byte, error)
+}
+
+func newCodec(algo string, level int) (codec, error) {
+	switch algo {
+	case gzipName:
+		// Validate the level up front rather than on every request.
+		if _, err := gzip.NewWriterLevel(ioutil.Discard, level); err != nil {
+			return nil, fmt.Errorf("invalid gzip compression level: %d", level)
+		}
+		return gzipCodec{level: level}, nil
+	case zstdName:
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		if err != nil {
+			return nil, err
+		}
+		return zstdCodec{enc: enc}, nil
+	default:
+		return nil, fmt.Errorf(
+			"compression algorithm must be %q or %q. Got: %q.", gzipName, zstdName, algo)
+	}
+}
+
+type gzipCodec struct {
+	level int
+	// maxBytes is the maximum size of decompressed bodies.
+	maxBytes int
+}
+
+func (c gzipCodec) compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gzipCodec) decompress(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// Read one byte past the limit to tell bodies at the limit from those
+	// beyond it.
+	body, err := ioutil.ReadAll(io.LimitReader(r, int64(c.maxBytes)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > c.maxBytes {
+		return nil, errTooLarge
+	}
+	return body, nil
+}
+
+// zstdCodec uses a single encoder and decoder, which are safe for concurrent
+// use with EncodeAll and DecodeAll. Codecs used only for decompression have
+// no encoder.
+type zstdCodec struct {
+	enc *zstd.Encoder
+	dec *zstd.Decoder
+	// maxBytes is the maximum size of decompressed bodies, which the decoder
+	// also enforces while decoding.
+	maxBytes int
+}
+
+func (c zstdCodec) compress(src []byte) ([]byte, error) {
+	return c.enc.EncodeAll(src, make([]byte, 0, len(src))), nil
+}
+
+func (c zstdCodec) decompress(src []byte) ([]byte, error) {
+	body, err := c.dec.DecodeAll(src, nil)
+	switch err {
+	case nil:
+	case zstd.ErrDecoderSizeExceeded, zstd.ErrFrameSizeExceeded, zstd.ErrWindowSizeExceeded:
+		// Frames that need a window larger than the limit would decompress
+		// past it as well.
+		return nil, errTooLarge
+	default:
+		return nil, err
+	}
+	// The decoder checks its limit between blocks, so the last block may
+	// overshoot it.
+	if len(body) > c.maxBytes {
+		return nil, errTooLarge
+	}
+	return body, nil
+}
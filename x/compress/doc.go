@@ -0,0 +1,55 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package compress provides middleware that compresses the bodies of
+// outbound requests.
+//
+// Compressed requests carry a "content-encoding" transport header naming the
+// algorithm, either "gzip" or "zstd". Transports forward this header like any
+// other request header; over HTTP, for example, it is sent as
+// "Rpc-Header-Content-Encoding" rather than the HTTP Content-Encoding header.
+// Servers must therefore decompress requests themselves, which the inbound
+// middleware in this package does:
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:     "myservice",
+// 		Inbounds: yarpc.Inbounds{httpInbound},
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: compress.NewInboundMiddleware(),
+// 		},
+// 	})
+//
+// The inbound middleware rejects requests whose bodies decompress to more than
+// 64 MiB with a ResourceExhausted error; MaxDecompressedBytes changes this
+// limit.
+//
+// Deploy decompression to all servers before enabling compression on their
+// clients:
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:      "myclient",
+// 		Outbounds: outbounds,
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary: compress.NewOutboundMiddleware("zstd", 3, compress.MinBytes(1024)),
+// 		},
+// 	})
+//
+// This package is experimental and its API may change.
+package compress
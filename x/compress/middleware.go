@@ -0,0 +1,215 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compress
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// HeaderKey is the transport header naming the algorithm that compressed the
+// request body.
+const HeaderKey = "content-encoding"
+
+const (
+	defaultMinBytes        = 1024
+	defaultMaxDecompressed = 64 * 1024 * 1024
+)
+
+type outboundOptions struct {
+	minBytes int
+}
+
+// OutboundOption customizes the behavior of the outbound middleware.
+type OutboundOption func(*outboundOptions)
+
+// MinBytes specifies the size in bytes below which request bodies are sent
+// uncompressed, since compressing small bodies costs more than it saves.
+//
+// Defaults to 1024.
+func MinBytes(n int) OutboundOption {
+	return func(o *outboundOptions) {
+		o.minBytes = n
+	}
+}
+
+// NewOutboundMiddleware returns middleware that compresses the bodies of
+// unary requests with the given algorithm, "gzip" or "zstd", at the given
+// compression level, and sets the content-encoding header accordingly.
+//
+// Levels follow the conventions of each algorithm: gzip accepts the levels of
+// compress/gzip, from -2 to 9, and zstd accepts levels from 1 to 22.
+//
+// NewOutboundMiddleware panics if the algorithm or level is invalid.
+//
+// Servers must decompress requests, for example with NewInboundMiddleware,
+// before this middleware is enabled.
+func NewOutboundMiddleware(algo string, level int, opts ...OutboundOption) middleware.UnaryOutbound {
+	options := outboundOptions{minBytes: defaultMinBytes}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	c, err := newCodec(algo, level)
+	if err != nil {
+		panic(fmt.Sprintf("compress.NewOutboundMiddleware expects a valid algorithm and level: %v", err))
+	}
+	return &outboundMiddleware{
+		algo:     algo,
+		codec:    c,
+		minBytes: options.minBytes,
+	}
+}
+
+type outboundMiddleware struct {
+	algo     string
+	codec    codec
+	minBytes int
+}
+
+func (m *outboundMiddleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	if req.Body == nil {
+		return out.Call(ctx, req)
+	}
+	if _, ok := req.Headers.Get(HeaderKey); ok {
+		// Already encoded by the caller.
+		return out.Call(ctx, req)
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < m.minBytes {
+		req.Body = bytes.NewReader(body)
+		return out.Call(ctx, req)
+	}
+
+	compressed, err := m.codec.compress(body)
+	if err != nil {
+		return nil, yarpcerrors.InternalErrorf("failed to compress request body: %v", err)
+	}
+	req.Body = bytes.NewReader(compressed)
+	req.BodySize = len(compressed)
+	req.Headers = req.Headers.With(HeaderKey, m.algo)
+	return out.Call(ctx, req)
+}
+
+type inboundOptions struct {
+	maxDecompressedBytes int
+}
+
+// InboundOption customizes the behavior of the inbound middleware.
+type InboundOption func(*inboundOptions)
+
+// MaxDecompressedBytes specifies the maximum size in bytes of decompressed
+// request bodies, which protects servers from small requests that
+// decompress to exhaust their memory. Non-positive values are ignored.
+//
+// Defaults to 64 MiB.
+func MaxDecompressedBytes(n int) InboundOption {
+	return func(o *inboundOptions) {
+		if n > 0 {
+			o.maxDecompressedBytes = n
+		}
+	}
+}
+
+// NewInboundMiddleware returns middleware that decompresses the bodies of
+// unary requests compressed by the outbound middleware, and passes other
+// requests through unchanged.
+//
+// Requests compressed with an unsupported algorithm fail with an
+// Unimplemented error, malformed bodies fail with an InvalidArgument error,
+// and bodies that decompress to more than MaxDecompressedBytes fail with a
+// ResourceExhausted error.
+func NewInboundMiddleware(opts ...InboundOption) middleware.UnaryInbound {
+	options := inboundOptions{maxDecompressedBytes: defaultMaxDecompressed}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &inboundMiddleware{maxBytes: options.maxDecompressedBytes}
+}
+
+type inboundMiddleware struct {
+	maxBytes int
+
+	zstdOnce    sync.Once
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+}
+
+func (m *inboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	algo, ok := req.Headers.Get(HeaderKey)
+	if !ok || algo == "" {
+		return h.Handle(ctx, req, resw)
+	}
+
+	c, err := m.codec(algo)
+	if err != nil {
+		return err
+	}
+	compressed, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	body, err := c.decompress(compressed)
+	if err == errTooLarge {
+		return yarpcerrors.ResourceExhaustedErrorf(
+			"%s request body to procedure %q of service %q decompresses to more than %d bytes",
+			algo, req.Procedure, req.Service, m.maxBytes)
+	}
+	if err != nil {
+		return yarpcerrors.InvalidArgumentErrorf("failed to decompress %s request body: %v", algo, err)
+	}
+
+	req.Body = bytes.NewReader(body)
+	req.BodySize = len(body)
+	req.Headers.Del(HeaderKey)
+	return h.Handle(ctx, req, resw)
+}
+
+func (m *inboundMiddleware) codec(algo string) (codec, error) {
+	switch algo {
+	case gzipName:
+		// Decompression does not depend on the level.
+		return gzipCodec{maxBytes: m.maxBytes}, nil
+	case zstdName:
+		// Decoders are expensive to create, so share one for all requests.
+		m.zstdOnce.Do(func() {
+			m.zstdDecoder, m.zstdErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(m.maxBytes)))
+		})
+		if m.zstdErr != nil {
+			return nil, m.zstdErr
+		}
+		return zstdCodec{dec: m.zstdDecoder, maxBytes: m.maxBytes}, nil
+	default:
+		return nil, yarpcerrors.UnimplementedErrorf("unsupported request content-encoding %q", algo)
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package compress
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// recordingOutbound records the last request it was called with.
+type recordingOutbound struct {
+	transport.UnaryOutbound
+
+	req  *transport.Request
+	body []byte
+}
+
+func (o *recordingOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	o.req = req
+	o.body = body
+	return &transport.Response{}, nil
+}
+
+// recordingHandler records the last request it handled.
+type recordingHandler struct {
+	req  *transport.Request
+	body []byte
+}
+
+func (h *recordingHandler) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	h.req = req
+	h.body = body
+	return nil
+}
+
+func newRequest(body string) *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "procedure",
+		Encoding:  "raw",
+		Headers:   transport.NewHeaders().With("foo", "bar"),
+		Body:      strings.NewReader(body),
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	large := strings.Repeat("hello world ", 1000)
+
+	for _, algo := range []string{"gzip", "zstd"} {
+		t.Run(algo, func(t *testing.T) {
+			mw := NewOutboundMiddleware(algo, 3, MinBytes(100))
+
+			out := &recordingOutbound{}
+			_, err := middleware.ApplyUnaryOutbound(out, mw).Call(context.Background(), newRequest(large))
+			require.NoError(t, err)
+
+			got, ok := out.req.Headers.Get(HeaderKey)
+			assert.True(t, ok, "expected content-encoding header")
+			assert.Equal(t, algo, got)
+			assert.True(t, len(out.body) < len(large), "expected a smaller body, got %d bytes", len(out.body))
+			assert.Equal(t, len(out.body), out.req.BodySize)
+
+			// Decompress the request on the server side.
+			h := &recordingHandler{}
+			in := middleware.ApplyUnaryInbound(h, NewInboundMiddleware())
+			out.req.Body = bytes.NewReader(out.body)
+			require.NoError(t, in.Handle(context.Background(), out.req, new(transporttest.FakeResponseWriter)))
+
+			assert.Equal(t, large, string(h.body))
+			assert.Equal(t, len(large), h.req.BodySize)
+			_, ok = h.req.Headers.Get(HeaderKey)
+			assert.False(t, ok, "content-encoding header must be removed")
+			foo, _ := h.req.Headers.Get("foo")
+			assert.Equal(t, "bar", foo, "other headers must be retained")
+		})
+	}
+}
+
+func TestSkipSmallBodies(t *testing.T) {
+	mw := NewOutboundMiddleware("gzip", 5, MinBytes(100))
+
+	out := &recordingOutbound{}
+	body := strings.Repeat("a", 99)
+	_, err := middleware.ApplyUnaryOutbound(out, mw).Call(context.Background(), newRequest(body))
+	require.NoError(t, err)
+
+	_, ok := out.req.Headers.Get(HeaderKey)
+	assert.False(t, ok, "small bodies must not be compressed")
+	assert.Equal(t, body, string(out.body))
+}
+
+func TestSkipEncodedBodies(t *testing.T) {
+	mw := NewOutboundMiddleware("gzip", 5, MinBytes(0))
+
+	out := &recordingOutbound{}
+	req := newRequest("already compressed")
+	req.Headers = req.Headers.With(HeaderKey, "br")
+	_, err := middleware.ApplyUnaryOutbound(out, mw).Call(context.Background(), req)
+	require.NoError(t, err)
+
+	got, _ := out.req.Headers.Get(HeaderKey)
+	assert.Equal(t, "br", got)
+	assert.Equal(t, "already compressed", string(out.body))
+}
+
+func TestNewOutboundMiddlewareErrors(t *testing.T) {
+	tests := []struct {
+		algo    string
+		level   int
+		wantErr string
+	}{
+		{algo: "snappy", wantErr: `compression algorithm must be "gzip" or "zstd". Got: "snappy".`},
+		{algo: "gzip", level: 42, wantErr: "invalid gzip compression level: 42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.algo, tt.level), func(t *testing.T) {
+			assert.PanicsWithValue(t,
+				"compress.NewOutboundMiddleware expects a valid algorithm and level: "+tt.wantErr,
+				func() { NewOutboundMiddleware(tt.algo, tt.level) })
+		})
+	}
+}
+
+func TestInboundMiddleware(t *testing.T) {
+	tests := []struct {
+		desc     string
+		encoding string
+		body     string
+		wantBody string
+		wantCode yarpcerrors.Code
+	}{
+		{
+			desc:     "uncompressed",
+			body:     "hello",
+			wantBody: "hello",
+		},
+		{
+			desc:     "unsupported algorithm",
+			encoding: "br",
+			body:     "hello",
+			wantCode: yarpcerrors.CodeUnimplemented,
+		},
+		{
+			desc:     "malformed gzip",
+			encoding: "gzip",
+			body:     "hello",
+			wantCode: yarpcerrors.CodeInvalidArgument,
+		},
+		{
+			desc:     "malformed zstd",
+			encoding: "zstd",
+			body:     "hello",
+			wantCode: yarpcerrors.CodeInvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			req := newRequest(tt.body)
+			if tt.encoding != "" {
+				req.Headers = req.Headers.With(HeaderKey, tt.encoding)
+			}
+
+			h := &recordingHandler{}
+			in := middleware.ApplyUnaryInbound(h, NewInboundMiddleware())
+			err := in.Handle(context.Background(), req, new(transporttest.FakeResponseWriter))
+			if tt.wantCode != yarpcerrors.CodeOK {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantCode, yarpcerrors.FromError(err).Code())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBody, string(h.body))
+		})
+	}
+}
+
+func TestInboundMiddlewareMaxDecompressedBytes(t *testing.T) {
+	const maxBytes = 64 * 1024
+
+	compress := func(t *testing.T, algo string, body []byte) []byte {
+		c, err := newCodec(algo, 3)
+		require.NoError(t, err)
+		compressed, err := c.compress(body)
+		require.NoError(t, err)
+		return compressed
+	}
+
+	// A megabyte of zeroes compresses to about a kilobyte.
+	bomb := make([]byte, 1024*1024)
+
+	tests := []struct {
+		desc       string
+		algo       string
+		compressed func(t *testing.T, algo string) []byte
+		wantCode   yarpcerrors.Code
+	}{
+		{
+			desc: "at the limit",
+			compressed: func(t *testing.T, algo string) []byte {
+				return compress(t, algo, bomb[:maxBytes])
+			},
+		},
+		{
+			desc: "past the limit",
+			compressed: func(t *testing.T, algo string) []byte {
+				return compress(t, algo, bomb[:maxBytes+1])
+			},
+			wantCode: yarpcerrors.CodeResourceExhausted,
+		},
+		{
+			desc: "highly compressible",
+			compressed: func(t *testing.T, algo string) []byte {
+				return compress(t, algo, bomb)
+			},
+			wantCode: yarpcerrors.CodeResourceExhausted,
+		},
+		{
+			desc: "concatenated",
+			compressed: func(t *testing.T, algo string) []byte {
+				// Each part is within the limit, but not all of them.
+				part := compress(t, algo, bomb[:maxBytes/2])
+				return bytes.Repeat(part, 4)
+			},
+			wantCode: yarpcerrors.CodeResourceExhausted,
+		},
+	}
+
+	for _, algo := range []string{"gzip", "zstd"} {
+		for _, tt := range tests {
+			t.Run(algo+"/"+tt.desc, func(t *testing.T) {
+				compressed := tt.compressed(t, algo)
+				require.True(t, len(compressed) < maxBytes/8, "expected a small request, got %d bytes", len(compressed))
+
+				req := newRequest("")
+				req.Body = bytes.NewReader(compressed)
+				req.Headers = req.Headers.With(HeaderKey, algo)
+
+				h := &recordingHandler{}
+				in := middleware.ApplyUnaryInbound(h, NewInboundMiddleware(MaxDecompressedBytes(maxBytes)))
+				err := in.Handle(context.Background(), req, new(transporttest.FakeResponseWriter))
+				if tt.wantCode != yarpcerrors.CodeOK {
+					require.Error(t, err)
+					assert.Equal(t, tt.wantCode, yarpcerrors.FromError(err).Code())
+					assert.Nil(t, h.req, "handler must not be called")
+					return
+				}
+				require.NoError(t, err)
+				assert.Len(t, h.body, maxBytes)
+			})
+		}
+	}
+}
+
+func BenchmarkOutboundMiddleware(b *testing.B) {
+	// A JSON-like body with the redundancy typical of RPC payloads.
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < 64*1024; i++ {
+		fmt.Fprintf(&buf, `{"id":%d,"name":"user-%d","email":"user-%d@example.com","active":true},`, i, i, i)
+	}
+	body := buf.Bytes()
+
+	for _, tt := range []struct {
+		algo  string
+		level int
+	}{
+		{"gzip", 1},
+		{"gzip", 6},
+		{"zstd", 1},
+		{"zstd", 3},
+	} {
+		b.Run(fmt.Sprintf("%s/%d", tt.algo, tt.level), func(b *testing.B) {
+			mw := NewOutboundMiddleware(tt.algo, tt.level, MinBytes(0))
+			out := middleware.ApplyUnaryOutbound(&sizeOutbound{}, mw)
+
+			var size int
+			b.SetBytes(int64(len(body)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				req := &transport.Request{Body: bytes.NewReader(body)}
+				if _, err := out.Call(context.Background(), req); err != nil {
+					b.Fatal(err)
+				}
+				size = req.BodySize
+			}
+			b.ReportMetric(float64(len(body)), "bytes-before")
+			b.ReportMetric(float64(size), "bytes-after")
+		})
+	}
+}
+
+// sizeOutbound discards requests.
+type sizeOutbound struct {
+	transport.UnaryOutbound
+}
+
+func (*sizeOutbound) Call(context.Context, *transport.Request) (*transport.Response, error) {
+	return &transport.Response{}, nil
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package concurrencylimit provides inbound middleware that adapts the number
+// of requests each procedure handles at once to the latency of its handler.
+//
+// The middleware keeps a limit on the requests in flight for every service
+// and procedure. Over every window, it compares the average latency of the
+// window's requests against the long-term average latency of the procedure.
+// When the latency rises beyond the tolerance, the procedure is queueing
+// work, and the limit shrinks in proportion; otherwise the limit grows, by
+// the square root of the limit each window, so long as the requests in
+// flight come close to it. This is the gradient algorithm of Netflix's
+// concurrency-limits library.
+//
+// Requests beyond the limit fail with a ResourceExhausted error before the
+// handler is called, so their bodies are never decoded. The error carries the
+// recent latency of the procedure as a hint of when to retry, which
+// RetryAfter reveals.
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:     "myservice",
+// 		Inbounds: inbounds,
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: concurrencylimit.NewInboundMiddleware(
+// 				concurrencylimit.MinLimit(5),
+// 				concurrencylimit.MaxLimit(500),
+// 			),
+// 		},
+// 	})
+//
+// Every procedure has a limit of its own, so that one slow procedure cannot
+// starve the others of capacity.
+//
+// The retry hint does not reach TChannel callers: TChannel inbounds leave
+// requests rejected with ResourceExhausted unanswered, as the tchannel
+// package describes, so those callers see a timeout.
+package concurrencylimit
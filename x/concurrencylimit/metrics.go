@@ -0,0 +1,87 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package concurrencylimit
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	_serviceTag   = "service"
+	_procedureTag = "procedure"
+)
+
+type limiterMetrics struct {
+	limit    *metrics.GaugeVector
+	inflight *metrics.GaugeVector
+	rejected *metrics.CounterVector
+}
+
+func newLimiterMetrics(meter *metrics.Scope, logger *zap.Logger) *limiterMetrics {
+	tags := []string{_serviceTag, _procedureTag}
+
+	limit, err := meter.GaugeVector(metrics.Spec{
+		Name:    "concurrency_limit",
+		Help:    "Number of requests to a procedure that may be in flight at once.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create concurrency limit gauge", zap.Error(err))
+	}
+	inflight, err := meter.GaugeVector(metrics.Spec{
+		Name:    "concurrency_inflight",
+		Help:    "Number of requests to a procedure in flight.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create concurrency inflight gauge", zap.Error(err))
+	}
+	rejected, err := meter.CounterVector(metrics.Spec{
+		Name:    "concurrency_rejected",
+		Help:    "Total number of requests rejected because their procedure was at its concurrency limit.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create concurrency rejected counter", zap.Error(err))
+	}
+
+	return &limiterMetrics{
+		limit:    limit,
+		inflight: inflight,
+		rejected: rejected,
+	}
+}
+
+// procedureMetrics are the metrics of the limit of a service and procedure.
+type procedureMetrics struct {
+	limit    *metrics.Gauge
+	inflight *metrics.Gauge
+	rejected *metrics.Counter
+}
+
+func (m *limiterMetrics) procedure(k procedureKey) *procedureMetrics {
+	return &procedureMetrics{
+		limit:    m.limit.MustGet(_serviceTag, k.service, _procedureTag, k.procedure),
+		inflight: m.inflight.MustGet(_serviceTag, k.service, _procedureTag, k.procedure),
+		rejected: m.rejected.MustGet(_serviceTag, k.service, _procedureTag, k.procedure),
+	}
+}
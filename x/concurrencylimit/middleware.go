@@ -0,0 +1,421 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package concurrencylimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/pkg/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	_defaultInitialLimit = 20
+	_defaultMinLimit     = 10
+	_defaultMaxLimit     = 1000
+	_defaultTolerance    = 1.5
+	_defaultWindow       = time.Second
+
+	// _minWindowSamples is the number of requests a window must have
+	// measured before it may change the limit.
+	_minWindowSamples = 10
+	// _longWindows is the number of windows over which the long-term
+	// latency is averaged.
+	_longWindows = 60
+	// _smoothing is the weight of a new limit against the previous one.
+	_smoothing = 0.2
+	// _minGradient bounds how much a single window shrinks the limit.
+	_minGradient = 0.5
+	// _minRetryAfter is the retry hint of procedures without a measured
+	// latency.
+	_minRetryAfter = time.Millisecond
+)
+
+// Option customizes the behavior of the concurrency limiting middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	initialLimit int
+	minLimit     int
+	maxLimit     int
+	tolerance    float64
+	window       time.Duration
+	meter        *metrics.Scope
+	logger       *zap.Logger
+	clock        clock.Clock
+}
+
+// InitialLimit is the limit of every procedure before its latency is
+// measured.
+//
+// Defaults to 20.
+func InitialLimit(n int) Option {
+	return optionFunc(func(o *options) {
+		if n > 0 {
+			o.initialLimit = n
+		}
+	})
+}
+
+// MinLimit is the lowest the limit of a procedure shrinks to, however slow
+// its handler.
+//
+// Defaults to 10.
+func MinLimit(n int) Option {
+	return optionFunc(func(o *options) {
+		if n > 0 {
+			o.minLimit = n
+		}
+	})
+}
+
+// MaxLimit is the highest the limit of a procedure grows to.
+//
+// Defaults to 1000.
+func MaxLimit(n int) Option {
+	return optionFunc(func(o *options) {
+		if n > 0 {
+			o.maxLimit = n
+		}
+	})
+}
+
+// Tolerance is how many times its long-term latency the latency of a
+// procedure may rise to before its limit shrinks. It must be at least 1.
+//
+// Defaults to 1.5.
+func Tolerance(t float64) Option {
+	return optionFunc(func(o *options) {
+		if t >= 1 {
+			o.tolerance = t
+		}
+	})
+}
+
+// Window is the duration over which the latency of the requests to each
+// procedure is averaged before the limit changes.
+//
+// Defaults to 1 second.
+func Window(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d > 0 {
+			o.window = d
+		}
+	})
+}
+
+// Meter sets the scope for the metrics of the middleware.
+func Meter(meter *metrics.Scope) Option {
+	return optionFunc(func(o *options) {
+		o.meter = meter
+	})
+}
+
+// Logger sets the logger for the middleware.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+// Clock sets the clock that measures the latency of handlers, for tests that
+// control time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) Option {
+	return optionFunc(func(o *options) {
+		o.clock = c
+	})
+}
+
+type procedureKey struct {
+	service   string
+	procedure string
+}
+
+var (
+	_ middleware.UnaryInbound  = (*Middleware)(nil)
+	_ middleware.OnewayInbound = (*Middleware)(nil)
+)
+
+// Middleware is a unary and oneway inbound middleware that limits the
+// requests in flight to each procedure.
+type Middleware struct {
+	opts    options
+	metrics *limiterMetrics
+
+	lock     sync.RWMutex
+	limiters map[procedureKey]*limiter
+}
+
+// NewInboundMiddleware returns a unary and oneway inbound middleware with an
+// adaptive concurrency limit for every procedure it handles.
+func NewInboundMiddleware(opts ...Option) *Middleware {
+	o := options{
+		initialLimit: _defaultInitialLimit,
+		minLimit:     _defaultMinLimit,
+		maxLimit:     _defaultMaxLimit,
+		tolerance:    _defaultTolerance,
+		window:       _defaultWindow,
+		clock:        clock.System,
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.maxLimit < o.minLimit {
+		o.maxLimit = o.minLimit
+	}
+	if o.logger == nil {
+		o.logger = zap.NewNop()
+	}
+
+	return &Middleware{
+		opts:     o,
+		metrics:  newLimiterMetrics(o.meter, o.logger),
+		limiters: make(map[procedureKey]*limiter),
+	}
+}
+
+// Handle calls the handler unless the procedure is at its limit, and
+// measures the latency of the handler.
+func (m *Middleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	l := m.limiter(procedureKey{req.Service, req.Procedure})
+	start, err := l.acquire(req)
+	if err != nil {
+		return err
+	}
+	defer l.release(start)
+	return h.Handle(ctx, req, resw)
+}
+
+// HandleOneway calls the handler unless the procedure is at its limit, and
+// measures the latency of the handler.
+func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	l := m.limiter(procedureKey{req.Service, req.Procedure})
+	start, err := l.acquire(req)
+	if err != nil {
+		return err
+	}
+	defer l.release(start)
+	return h.HandleOneway(ctx, req)
+}
+
+// Limit returns the current limit of a procedure.
+func (m *Middleware) Limit(service, procedure string) int {
+	m.lock.RLock()
+	l, ok := m.limiters[procedureKey{service, procedure}]
+	m.lock.RUnlock()
+	if !ok {
+		return int(m.opts.clampLimit(float64(m.opts.initialLimit)))
+	}
+	return l.current()
+}
+
+func (m *Middleware) limiter(k procedureKey) *limiter {
+	m.lock.RLock()
+	l, ok := m.limiters[k]
+	m.lock.RUnlock()
+	if ok {
+		return l
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if l, ok := m.limiters[k]; ok {
+		return l
+	}
+	l = newLimiter(&m.opts, m.metrics.procedure(k))
+	m.limiters[k] = l
+	return l
+}
+
+func (o *options) clampLimit(limit float64) float64 {
+	return clamp(limit, float64(o.minLimit), float64(o.maxLimit))
+}
+
+// limiter holds the adaptive concurrency limit of a procedure.
+type limiter struct {
+	opts    *options
+	metrics *procedureMetrics
+
+	lock     sync.Mutex
+	limit    float64
+	inflight int
+	// longLatency is the long-term average latency in seconds, and
+	// shortLatency the average latency of the last window.
+	longLatency  float64
+	shortLatency float64
+
+	windowStart       time.Time
+	windowSamples     int
+	windowLatency     time.Duration
+	windowMaxInflight int
+}
+
+func newLimiter(opts *options, metrics *procedureMetrics) *limiter {
+	l := &limiter{
+		opts:        opts,
+		metrics:     metrics,
+		limit:       opts.clampLimit(float64(opts.initialLimit)),
+		windowStart: opts.clock.Now(),
+	}
+	metrics.limit.Store(int64(l.limit))
+	return l
+}
+
+func (l *limiter) current() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return int(l.limit)
+}
+
+// acquire admits a request unless the procedure is at its limit, returning
+// when the request started.
+func (l *limiter) acquire(req *transport.Request) (time.Time, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.inflight >= int(l.limit) {
+		l.metrics.rejected.Inc()
+		return time.Time{}, newLimitError(req, int(l.limit), l.retryAfter())
+	}
+	l.inflight++
+	if l.inflight > l.windowMaxInflight {
+		l.windowMaxInflight = l.inflight
+	}
+	l.metrics.inflight.Store(int64(l.inflight))
+	return l.opts.clock.Now(), nil
+}
+
+// release records the latency of a request that has finished.
+func (l *limiter) release(start time.Time) {
+	now := l.opts.clock.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.inflight--
+	l.metrics.inflight.Store(int64(l.inflight))
+
+	l.windowSamples++
+	l.windowLatency += now.Sub(start)
+	if l.windowSamples < _minWindowSamples || now.Sub(l.windowStart) < l.opts.window {
+		return
+	}
+
+	l.update(l.windowLatency.Seconds()/float64(l.windowSamples), l.windowMaxInflight)
+	l.windowStart = now
+	l.windowSamples = 0
+	l.windowLatency = 0
+	l.windowMaxInflight = l.inflight
+}
+
+// update adapts the limit to the average latency and the most requests in
+// flight of a window. It must be called under the lock of the limiter.
+func (l *limiter) update(latency float64, maxInflight int) {
+	l.shortLatency = latency
+	if l.longLatency == 0 {
+		l.longLatency = latency
+	} else {
+		l.longLatency += (latency - l.longLatency) / _longWindows
+	}
+	// Once a slow period is over, the long-term latency would take many
+	// windows to come back down, keeping the limit from growing. Decay it
+	// faster while the latency is well below it.
+	if l.longLatency > 2*latency {
+		l.longLatency *= 0.95
+	}
+
+	gradient := 1.0
+	if latency > 0 {
+		gradient = clamp(l.opts.tolerance*l.longLatency/latency, _minGradient, 1)
+	}
+	next := l.limit * gradient
+	// A procedure that does not use half of its limit says nothing about
+	// whether it could handle more, so its limit may only shrink.
+	if float64(maxInflight) >= l.limit/2 {
+		next += math.Sqrt(l.limit)
+	} else if gradient == 1 {
+		return
+	}
+	next = l.limit*(1-_smoothing) + next*_smoothing
+	l.limit = l.opts.clampLimit(next)
+	l.metrics.limit.Store(int64(l.limit))
+}
+
+// retryAfter estimates when the procedure will have room for a request: a
+// request in flight finishes within about the recent latency. It must be
+// called under the lock of the limiter.
+func (l *limiter) retryAfter() time.Duration {
+	d := time.Duration(l.shortLatency * float64(time.Second))
+	if d < _minRetryAfter {
+		d = _minRetryAfter
+	}
+	return d
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
+
+func newLimitError(req *transport.Request, limit int, wait time.Duration) error {
+	err := yarpcerrors.ResourceExhaustedErrorf(
+		"concurrency limit of %d requests exceeded for procedure %q of service %q, retry after %v",
+		limit, req.Procedure, req.Service, wait)
+	return yarpcerrors.WithDetails(err, &rpc.RetryInfo{RetryDelay: types.DurationProto(wait)})
+}
+
+// RetryAfter returns the delay after which a request rejected by the
+// concurrency limit may be retried, and false if the error does not carry
+// one.
+func RetryAfter(err error) (time.Duration, bool) {
+	details, derr := yarpcerrors.Details(err)
+	if derr != nil {
+		return 0, false
+	}
+	for _, detail := range details {
+		info, ok := detail.(*rpc.RetryInfo)
+		if !ok || info.RetryDelay == nil {
+			continue
+		}
+		if d, err := types.DurationFromProto(info.RetryDelay); err == nil {
+			return d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package concurrencylimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+// blockingHandler holds every request until it is released.
+type blockingHandler struct {
+	entered *sync.WaitGroup
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	h.mu.Lock()
+	h.calls++
+	h.mu.Unlock()
+
+	h.entered.Done()
+	<-h.release
+	return nil
+}
+
+func (h *blockingHandler) numCalls() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.calls
+}
+
+type harness struct {
+	t     *testing.T
+	mw    *Middleware
+	clock *yarpctest.FakeClock
+	root  *metrics.Root
+}
+
+func newHarness(t *testing.T, opts ...Option) *harness {
+	root := metrics.New()
+	clock := yarpctest.NewFakeClock()
+	opts = append([]Option{
+		Window(100 * time.Millisecond),
+		Meter(root.Scope()),
+		Clock(clock),
+	}, opts...)
+
+	return &harness{
+		t:     t,
+		mw:    NewInboundMiddleware(opts...),
+		clock: clock,
+		root:  root,
+	}
+}
+
+// tick sends n concurrent requests to the procedure, lets the admitted ones
+// take the given latency on the fake clock, and returns the number of
+// rejected requests.
+func (h *harness) tick(procedure string, n int, latency time.Duration) (rejected int) {
+	var entered, done sync.WaitGroup
+	handler := &blockingHandler{entered: &entered, release: make(chan struct{})}
+
+	var mu sync.Mutex
+	entered.Add(n)
+	done.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer done.Done()
+			req := &transport.Request{Service: "service", Procedure: procedure}
+			if err := h.mw.Handle(context.Background(), req, nil, handler); err != nil {
+				assert.Equal(h.t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+				mu.Lock()
+				rejected++
+				mu.Unlock()
+				entered.Done()
+			}
+		}()
+	}
+
+	entered.Wait()
+	h.clock.Add(latency)
+	close(handler.release)
+	done.Wait()
+
+	assert.Equal(h.t, n-rejected, handler.numCalls(), "rejected requests must not reach the handler")
+	return rejected
+}
+
+// run sends ticks of n requests for the duration.
+func (h *harness) run(procedure string, n int, latency, d time.Duration) {
+	for elapsed := time.Duration(0); elapsed < d; elapsed += latency {
+		h.tick(procedure, n, latency)
+	}
+}
+
+func (h *harness) gauges() map[string]int64 {
+	values := make(map[string]int64)
+	for _, g := range h.root.Snapshot().Gauges {
+		values[g.Name+":"+g.Tags[_procedureTag]] = g.Value
+	}
+	return values
+}
+
+func TestLimitAdaptsToLatency(t *testing.T) {
+	h := newHarness(t)
+
+	// With steady latency, the limit grows until the load no longer comes
+	// close to it.
+	h.run("proc", 100, 10*time.Millisecond, 10*time.Second)
+	baseline := h.mw.Limit("service", "proc")
+	assert.True(t, baseline >= 100, "limit must grow to the load, got %d", baseline)
+	assert.Equal(t, 0, h.tick("proc", 100, 10*time.Millisecond), "requests within the limit must be admitted")
+
+	// When the handler slows down, the limit falls.
+	h.run("proc", 100, 100*time.Millisecond, 3*time.Second)
+	degraded := h.mw.Limit("service", "proc")
+	assert.True(t, degraded < baseline/2, "limit must drop from %d when latency rises, got %d", baseline, degraded)
+	assert.True(t, h.tick("proc", 100, 100*time.Millisecond) > 0, "requests beyond the limit must be rejected")
+
+	// Once the handler recovers, so does the limit.
+	h.run("proc", 100, 10*time.Millisecond, 15*time.Second)
+	recovered := h.mw.Limit("service", "proc")
+	assert.True(t, recovered >= 100, "limit must recover from %d when latency falls, got %d", degraded, recovered)
+	assert.Equal(t, 0, h.tick("proc", 100, 10*time.Millisecond))
+}
+
+func TestLimitBounds(t *testing.T) {
+	h := newHarness(t, InitialLimit(15), MinLimit(12), MaxLimit(30))
+	assert.Equal(t, 15, h.mw.Limit("service", "proc"))
+
+	h.run("proc", 50, 10*time.Millisecond, 10*time.Second)
+	assert.Equal(t, 30, h.mw.Limit("service", "proc"), "limit must not exceed the maximum")
+
+	h.run("proc", 50, time.Second, 30*time.Second)
+	assert.Equal(t, 12, h.mw.Limit("service", "proc"), "limit must not fall below the minimum")
+	assert.Equal(t, 50-12, h.tick("proc", 50, time.Second))
+}
+
+func TestLimitIsPerProcedure(t *testing.T) {
+	h := newHarness(t, InitialLimit(10), MinLimit(10), MaxLimit(10))
+
+	var entered sync.WaitGroup
+	slow := &blockingHandler{entered: &entered, release: make(chan struct{})}
+	entered.Add(10)
+	var done sync.WaitGroup
+	done.Add(10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer done.Done()
+			req := &transport.Request{Service: "service", Procedure: "slow"}
+			assert.NoError(t, h.mw.Handle(context.Background(), req, nil, slow))
+		}()
+	}
+	entered.Wait()
+
+	assert.Equal(t, 1, h.tick("slow", 1, time.Millisecond), "saturated procedure must reject requests")
+	assert.Equal(t, 0, h.tick("fast", 10, time.Millisecond), "other procedures must not be affected")
+
+	close(slow.release)
+	done.Wait()
+
+	gauges := h.gauges()
+	assert.Equal(t, int64(10), gauges["concurrency_limit:slow"])
+	assert.Equal(t, int64(0), gauges["concurrency_inflight:slow"])
+
+	var rejected int64
+	for _, c := range h.root.Snapshot().Counters {
+		if c.Name == "concurrency_rejected" && c.Tags[_procedureTag] == "slow" {
+			rejected = c.Value
+		}
+	}
+	assert.Equal(t, int64(1), rejected)
+}
+
+func TestRejectionRetryAfter(t *testing.T) {
+	h := newHarness(t, InitialLimit(10), MinLimit(10), MaxLimit(10))
+
+	// Measure a window of 20ms requests.
+	h.run("proc", 10, 20*time.Millisecond, 100*time.Millisecond)
+
+	var entered sync.WaitGroup
+	handler := &blockingHandler{entered: &entered, release: make(chan struct{})}
+	entered.Add(10)
+	var done sync.WaitGroup
+	done.Add(10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer done.Done()
+			req := &transport.Request{Service: "service", Procedure: "proc"}
+			assert.NoError(t, h.mw.HandleOneway(context.Background(), req, onewayHandler{handler}))
+		}()
+	}
+	entered.Wait()
+	defer func() {
+		close(handler.release)
+		done.Wait()
+	}()
+
+	err := h.mw.Handle(context.Background(), &transport.Request{Service: "service", Procedure: "proc"}, nil, handler)
+	require.Error(t, err)
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+	assert.Contains(t, err.Error(), `concurrency limit of 10 requests exceeded for procedure "proc" of service "service"`)
+
+	wait, ok := RetryAfter(err)
+	require.True(t, ok, "error must carry a retry hint")
+	assert.Equal(t, 20*time.Millisecond, wait)
+}
+
+func TestRetryAfterWithoutHint(t *testing.T) {
+	_, ok := RetryAfter(yarpcerrors.ResourceExhaustedErrorf("too busy"))
+	assert.False(t, ok)
+}
+
+type onewayHandler struct{ h *blockingHandler }
+
+func (h onewayHandler) HandleOneway(ctx context.Context, req *transport.Request) error {
+	return h.h.Handle(ctx, req, nil)
+}
@@ -21,6 +21,7 @@
 package debug
 
 import (
+	"fmt"
 	"html/template"
 	"io"
 	"net/http"
@@ -169,50 +170,125 @@ var (
 		</tbody>
 		{{end}}
 	</table>
+	<h3>Transports</h3>
+	<table>
+		<tr>
+			<th>Name</th>
+			<th>State</th>
+		</tr>
+		{{range .Transports}}
+		<tr>
+			<td>{{.Name}}</td>
+			<td>{{.State}}</td>
+		</tr>
+		{{end}}
+	</table>
+{{end}}
+{{if .EffectiveConfig}}
+	<hr />
+	<h2>Effective Configuration <small>(<a href="?format=yaml">yaml</a>, <a href="?format=json">json</a>)</small></h2>
+	<pre>{{.EffectiveConfig}}</pre>
 {{end}}
 	</body>
 </html>
 `))
 )
 
-// NewHandler returns a http.HandlerFunc to expose dispatcher status and package versions.
+// NewHandler returns a http.HandlerFunc to expose dispatcher status and
+// package versions, and the effective configuration of the dispatcher if the
+// EffectiveConfig option is given.
+//
+// With a "format" query parameter of "yaml" or "json", the handler responds
+// with the effective configuration alone, as a document in that format.
 func NewHandler(dispatcher *yarpc.Dispatcher, opts ...Option) http.HandlerFunc {
 	return newHandler(dispatcher, opts...).handle
 }
 
 type handler struct {
-	dispatcher *yarpc.Dispatcher
-	logger     *zap.Logger
-	tmpl       templateIface
+	dispatcher      *yarpc.Dispatcher
+	logger          *zap.Logger
+	tmpl            templateIface
+	effectiveConfig func() ConfigRenderer
 }
 
 func newHandler(dispatcher *yarpc.Dispatcher, options ...Option) *handler {
 	opts := applyOptions(options...)
 	return &handler{
-		dispatcher: dispatcher,
-		logger:     opts.logger,
-		tmpl:       opts.tmpl,
+		dispatcher:      dispatcher,
+		logger:          opts.logger,
+		tmpl:            opts.tmpl,
+		effectiveConfig: opts.effectiveConfig,
 	}
 }
 
-func (h *handler) handle(responseWriter http.ResponseWriter, _ *http.Request) {
+func (h *handler) handle(responseWriter http.ResponseWriter, req *http.Request) {
 	defer func() {
 		if r := recover(); r != nil {
 			responseWriter.WriteHeader(http.StatusInternalServerError)
 			h.logger.Error("Unary handler panicked:", zap.Any("recover", r), zap.ByteString("stacktrace", debug.Stack()))
 		}
 	}()
+	if req != nil {
+		if format := req.URL.Query().Get("format"); format != "" {
+			h.handleEffectiveConfig(responseWriter, format)
+			return
+		}
+	}
+
+	data := newTmplData(h.dispatcher.Introspect())
+	if h.effectiveConfig != nil {
+		effective, err := h.effectiveConfig().YAML()
+		if err != nil {
+			h.logger.Error("yarpc/debug: failed rendering effective configuration", zap.Error(err))
+		}
+		data.EffectiveConfig = string(effective)
+	}
 	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := h.tmpl.Execute(responseWriter, newTmplData(h.dispatcher.Introspect())); err != nil {
+	if err := h.tmpl.Execute(responseWriter, data); err != nil {
 		// TODO: does this work, since we already tried a write?
 		responseWriter.WriteHeader(http.StatusInternalServerError)
 		h.logger.Error("yarpc/debug: failed executing template", zap.Error(err))
 	}
 }
 
+// handleEffectiveConfig responds with the effective configuration of the
+// dispatcher as a YAML or JSON document.
+func (h *handler) handleEffectiveConfig(responseWriter http.ResponseWriter, format string) {
+	if h.effectiveConfig == nil {
+		http.Error(responseWriter, "the effective configuration is not available", http.StatusNotFound)
+		return
+	}
+	effective := h.effectiveConfig()
+
+	var (
+		body []byte
+		err  error
+	)
+	switch format {
+	case "yaml":
+		responseWriter.Header().Set("Content-Type", "application/yaml")
+		body, err = effective.YAML()
+	case "json":
+		responseWriter.Header().Set("Content-Type", "application/json")
+		body, err = effective.JSON()
+	default:
+		http.Error(responseWriter, fmt.Sprintf("unknown format %q, expected yaml or json", format), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		h.logger.Error("yarpc/debug: failed rendering effective configuration", zap.Error(err))
+		return
+	}
+	if _, err := responseWriter.Write(body); err != nil {
+		h.logger.Error("yarpc/debug: failed writing effective configuration", zap.Error(err))
+	}
+}
+
 type tmplData struct {
 	Dispatchers     []introspection.DispatcherStatus
 	PackageVersions []introspection.PackageVersion
+	EffectiveConfig string
 }
 
 func newTmplData(dispatcherStatus introspection.DispatcherStatus) *tmplData {
@@ -31,8 +31,11 @@ import (
 
 	"go.uber.org/yarpc"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	yarpchttp "go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/yarpcconfig"
+	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -68,6 +71,61 @@ func TestHandler(t *testing.T) {
 	require.Equal(t, string(expectedData), string(data))
 }
 
+func TestHandlerShowsEffectiveConfig(t *testing.T) {
+	dispatcher := newTestDispatcher()
+	effective, err := yarpcconfig.NewEffectiveConfig(dispatcher).YAML()
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	NewHandler(dispatcher, tmpl(_jsonTestTmpl), effectiveConfigOption(dispatcher))(responseRecorder, nil)
+
+	require.Equal(t, http.StatusOK, responseRecorder.Code)
+	var data tmplData
+	require.NoError(t, json.Unmarshal(responseRecorder.Body.Bytes(), &data))
+	assert.Equal(t, string(effective), data.EffectiveConfig)
+}
+
+func TestHandlerEffectiveConfig(t *testing.T) {
+	dispatcher := newTestDispatcher()
+
+	tests := []struct {
+		format          string
+		wantCode        int
+		wantContentType string
+		unmarshal       func([]byte, interface{}) error
+	}{
+		{format: "yaml", wantCode: http.StatusOK, wantContentType: "application/yaml", unmarshal: yaml.Unmarshal},
+		{format: "json", wantCode: http.StatusOK, wantContentType: "application/json", unmarshal: json.Unmarshal},
+		{format: "xml", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			responseRecorder := httptest.NewRecorder()
+			NewHandler(dispatcher, effectiveConfigOption(dispatcher))(responseRecorder, httptest.NewRequest("GET", "/debug/yarpc?format="+tt.format, nil))
+
+			require.Equal(t, tt.wantCode, responseRecorder.Code)
+			if tt.unmarshal == nil {
+				return
+			}
+			assert.Equal(t, tt.wantContentType, responseRecorder.Header().Get("Content-Type"))
+
+			var got yarpcconfig.EffectiveConfig
+			require.NoError(t, tt.unmarshal(responseRecorder.Body.Bytes(), &got))
+			assert.Equal(t, "test", got.Name)
+			require.Contains(t, got.Outbounds, "test-client")
+			require.NotNil(t, got.Outbounds["test-client"].Unary)
+			assert.Equal(t, "http://127.0.0.1:1234", got.Outbounds["test-client"].Unary.Endpoint)
+		})
+	}
+}
+
+func TestHandlerEffectiveConfigNotAvailable(t *testing.T) {
+	responseRecorder := httptest.NewRecorder()
+	NewHandler(newTestDispatcher())(responseRecorder, httptest.NewRequest("GET", "/debug/yarpc?format=yaml", nil))
+	assert.Equal(t, http.StatusNotFound, responseRecorder.Code)
+}
+
 func TestHandlerError(t *testing.T) {
 	dispatcher := newTestDispatcher()
 
@@ -91,3 +149,9 @@ func newTestDispatcher() *yarpc.Dispatcher {
 		},
 	})
 }
+
+func effectiveConfigOption(dispatcher *yarpc.Dispatcher) Option {
+	return EffectiveConfig(func() ConfigRenderer {
+		return yarpcconfig.NewEffectiveConfig(dispatcher)
+	})
+}
@@ -31,8 +31,16 @@ type optionFunc func(*options)
 
 // opts represents the combined options supplied by the user.
 type options struct {
-	logger *zap.Logger
-	tmpl   templateIface
+	logger          *zap.Logger
+	tmpl            templateIface
+	effectiveConfig func() ConfigRenderer
+}
+
+// ConfigRenderer renders the effective configuration of a dispatcher as a
+// document. yarpcconfig.EffectiveConfig is a ConfigRenderer.
+type ConfigRenderer interface {
+	JSON() ([]byte, error)
+	YAML() ([]byte, error)
 }
 
 // Logger specifies the logger that should be used to log.
@@ -43,6 +51,22 @@ func Logger(logger *zap.Logger) Option {
 	})
 }
 
+// EffectiveConfig shows the effective configuration of the dispatcher on the
+// debug page, and serves it with the "format" query parameter. The given
+// function is called on every request, so that the page reflects the
+// current peers of the dispatcher.
+//
+// 	debug.NewHandler(dispatcher, debug.EffectiveConfig(func() debug.ConfigRenderer {
+// 		return yarpcconfig.NewEffectiveConfig(dispatcher)
+// 	}))
+//
+// Without it, the debug page omits the effective configuration.
+func EffectiveConfig(f func() ConfigRenderer) Option {
+	return optionFunc(func(opts *options) {
+		opts.effectiveConfig = f
+	})
+}
+
 // tmpl specifies the template to use.
 // It is only used for testing.
 func tmpl(tmpl templateIface) Option {
@@ -0,0 +1,53 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package durable provides oneway outbound middleware with at-least-once
+// delivery: calls are acknowledged once their request is persisted to a
+// Store, and delivered to the outbound from a background goroutine, retrying
+// with backoff until they succeed.
+//
+// 	store, err := durable.NewFileStore("/var/lib/myservice/oneway")
+// 	if err != nil {
+// 		log.Fatal(err)
+// 	}
+// 	mw := durable.NewOnewayOutboundMiddleware(store,
+// 		durable.MaxAttempts(20),
+// 		durable.Meter(meter),
+// 		durable.Logger(logger),
+// 	)
+// 	defer mw.Stop()
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Oneway: mw,
+// 		},
+// 	})
+//
+// Requests that a previous process persisted but did not deliver are
+// redelivered once the middleware knows its outbound, from the Outbound
+// option or from the first call it handles. Requests that fail the maximum
+// number of attempts in a process are dead-lettered: removed from the store,
+// after being appended to the DeadLetterStore if any.
+//
+// Delivered requests carry the caller, service, procedure, encoding, headers,
+// routing attributes and body of the original request, but not its context:
+// neither its deadline nor its tracing span.
+package durable
@@ -0,0 +1,100 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package durable
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/zap"
+)
+
+const (
+	_serviceTag   = "service"
+	_procedureTag = "procedure"
+)
+
+type durableMetrics struct {
+	persisted    *metrics.CounterVector
+	delivered    *metrics.CounterVector
+	failures     *metrics.CounterVector
+	deadLettered *metrics.CounterVector
+}
+
+func newDurableMetrics(meter *metrics.Scope, logger *zap.Logger) *durableMetrics {
+	tags := []string{_serviceTag, _procedureTag}
+
+	persisted, err := meter.CounterVector(metrics.Spec{
+		Name:    "durable_persisted",
+		Help:    "Total number of oneway requests persisted for delivery.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create durable persisted counter", zap.Error(err))
+	}
+	delivered, err := meter.CounterVector(metrics.Spec{
+		Name:    "durable_delivered",
+		Help:    "Total number of persisted oneway requests delivered to the outbound.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create durable delivered counter", zap.Error(err))
+	}
+	failures, err := meter.CounterVector(metrics.Spec{
+		Name:    "durable_delivery_failures",
+		Help:    "Total number of failed attempts to deliver persisted oneway requests.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create durable delivery failures counter", zap.Error(err))
+	}
+	deadLettered, err := meter.CounterVector(metrics.Spec{
+		Name:    "durable_dead_lettered",
+		Help:    "Total number of persisted oneway requests given up on after the maximum number of attempts.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create durable dead-lettered counter", zap.Error(err))
+	}
+
+	return &durableMetrics{
+		persisted:    persisted,
+		delivered:    delivered,
+		failures:     failures,
+		deadLettered: deadLettered,
+	}
+}
+
+// edgeMetrics are the counters of a service and procedure.
+type edgeMetrics struct {
+	persisted    *metrics.Counter
+	delivered    *metrics.Counter
+	failures     *metrics.Counter
+	deadLettered *metrics.Counter
+}
+
+func (m *durableMetrics) edge(req *transport.Request) *edgeMetrics {
+	return &edgeMetrics{
+		persisted:    m.persisted.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+		delivered:    m.delivered.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+		failures:     m.failures.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+		deadLettered: m.deadLettered.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package durable
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/atomic"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/backoff"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	intbackoff "go.uber.org/yarpc/internal/backoff"
+	"go.uber.org/yarpc/serialize"
+	"go.uber.org/zap"
+)
+
+const (
+	_defaultMaxAttempts = 10
+	_defaultTimeout     = time.Second
+)
+
+// Requests are delivered without the context of their original call, so
+// they are serialized without a tracing span.
+var _tracer = opentracing.NoopTracer{}
+
+// Option customizes the behavior of the durable middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	outbound    transport.OnewayOutbound
+	maxAttempts int
+	timeout     time.Duration
+	backoff     backoff.Strategy
+	deadLetter  Store
+	meter       *metrics.Scope
+	logger      *zap.Logger
+}
+
+// Outbound sets the outbound to deliver requests to directly, so that
+// requests persisted by a previous process are delivered before the first
+// call.
+//
+// Without it, requests are delivered to the outbound of the latest call.
+func Outbound(out transport.OnewayOutbound) Option {
+	return optionFunc(func(o *options) {
+		o.outbound = out
+	})
+}
+
+// MaxAttempts is the number of times the middleware attempts to deliver a
+// request before dead-lettering it.
+//
+// Defaults to 10.
+func MaxAttempts(n int) Option {
+	return optionFunc(func(o *options) {
+		if n > 0 {
+			o.maxAttempts = n
+		}
+	})
+}
+
+// Timeout bounds every attempt to deliver a request.
+//
+// Defaults to one second.
+func Timeout(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d > 0 {
+			o.timeout = d
+		}
+	})
+}
+
+// Backoff sets the strategy for the delay between rounds of redelivery while
+// requests are failing.
+//
+// Defaults to exponential backoff.
+func Backoff(strategy backoff.Strategy) Option {
+	return optionFunc(func(o *options) {
+		o.backoff = strategy
+	})
+}
+
+// DeadLetterStore sets the store to which dead-lettered requests are
+// appended, so that they may be inspected or replayed.
+//
+// Without it, dead-lettered requests are dropped.
+func DeadLetterStore(store Store) Option {
+	return optionFunc(func(o *options) {
+		o.deadLetter = store
+	})
+}
+
+// Meter sets the scope for the metrics of the middleware.
+func Meter(meter *metrics.Scope) Option {
+	return optionFunc(func(o *options) {
+		o.meter = meter
+	})
+}
+
+// Logger sets the logger for the middleware.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+type ack struct{}
+
+func (ack) String() string { return "persisted" }
+
+var _ middleware.OnewayOutbound = (*Middleware)(nil)
+
+// Middleware is a oneway outbound middleware that acknowledges calls once
+// their request is persisted, and delivers them from a background goroutine.
+type Middleware struct {
+	store    Store
+	opts     options
+	metrics  *durableMetrics
+	backoff  backoff.Backoff
+	outbound atomic.Value // transport.OnewayOutbound
+
+	// attempts counts the failed deliveries of every entry, and is only
+	// accessed by the delivery goroutine.
+	attempts map[string]int
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewOnewayOutboundMiddleware returns a oneway outbound middleware that
+// persists requests to the given store, and starts delivering the pending
+// requests of the store. Stop the middleware to stop delivering them.
+func NewOnewayOutboundMiddleware(store Store, opts ...Option) *Middleware {
+	o := options{
+		maxAttempts: _defaultMaxAttempts,
+		timeout:     _defaultTimeout,
+		backoff:     intbackoff.DefaultExponential,
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.logger == nil {
+		o.logger = zap.NewNop()
+	}
+
+	m := &Middleware{
+		store:    store,
+		opts:     o,
+		metrics:  newDurableMetrics(o.meter, o.logger),
+		backoff:  o.backoff.Backoff(),
+		attempts: make(map[string]int),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if o.outbound != nil {
+		m.outbound.Store(o.outbound)
+	}
+	m.notify()
+	go m.run()
+	return m
+}
+
+// CallOneway persists the request and acknowledges the call, without
+// sending it to the outbound. The request is delivered from the background.
+func (m *Middleware) CallOneway(ctx context.Context, req *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	payload, err := serialize.ToBytes(_tracer, nil, req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.store.Append(payload); err != nil {
+		return nil, err
+	}
+	m.metrics.edge(req).persisted.Inc()
+
+	m.outbound.Store(out)
+	m.notify()
+	return ack{}, nil
+}
+
+// Stop stops delivering requests, waiting for the delivery in progress, if
+// any, to finish. Pending requests remain in the store.
+func (m *Middleware) Stop() error {
+	m.stopOnce.Do(func() { close(m.stop) })
+	<-m.stopped
+	return nil
+}
+
+func (m *Middleware) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Middleware) run() {
+	defer close(m.stopped)
+
+	var (
+		failedRounds uint
+		timer        *time.Timer
+		retry        <-chan time.Time
+	)
+	for {
+		select {
+		case <-m.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-m.wake:
+		case <-retry:
+		}
+
+		if m.deliverPending() {
+			failedRounds = 0
+			retry = nil
+			continue
+		}
+		failedRounds++
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.NewTimer(m.backoff.Duration(failedRounds))
+		retry = timer.C
+	}
+}
+
+// deliverPending attempts to deliver every pending request once, and
+// reports whether none remain pending.
+func (m *Middleware) deliverPending() bool {
+	out, _ := m.outbound.Load().(transport.OnewayOutbound)
+	if out == nil {
+		// Delivery resumes with the first call.
+		return true
+	}
+	entries, err := m.store.ScanPending()
+	if err != nil {
+		m.opts.logger.Error("failed to scan pending oneway requests", zap.Error(err))
+		return false
+	}
+	done := true
+	for _, entry := range entries {
+		select {
+		case <-m.stop:
+			return true
+		default:
+		}
+		if !m.deliver(out, entry) {
+			done = false
+		}
+	}
+	return done
+}
+
+// deliver attempts to deliver the request of an entry, and reports whether
+// it is no longer pending.
+func (m *Middleware) deliver(out transport.OnewayOutbound, entry Entry) bool {
+	_, req, err := serialize.FromBytes(_tracer, entry.Payload)
+	if err != nil {
+		// Requests that cannot be deserialized can never be delivered.
+		return m.deadLetter(entry, &transport.Request{}, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.opts.timeout)
+	_, err = out.CallOneway(ctx, req)
+	cancel()
+
+	edge := m.metrics.edge(req)
+	if err == nil {
+		edge.delivered.Inc()
+		delete(m.attempts, entry.ID)
+		return m.markDone(entry)
+	}
+
+	edge.failures.Inc()
+	m.attempts[entry.ID]++
+	if m.attempts[entry.ID] < m.opts.maxAttempts {
+		return false
+	}
+	return m.deadLetter(entry, req, err)
+}
+
+// deadLetter gives up on delivering the request of an entry.
+func (m *Middleware) deadLetter(entry Entry, req *transport.Request, cause error) bool {
+	m.opts.logger.Warn("dead-lettering oneway request",
+		zap.String("id", entry.ID),
+		zap.String("service", req.Service),
+		zap.String("procedure", req.Procedure),
+		zap.Int("attempts", m.attempts[entry.ID]),
+		zap.Error(cause))
+	if m.opts.deadLetter != nil {
+		if _, err := m.opts.deadLetter.Append(entry.Payload); err != nil {
+			m.opts.logger.Error("failed to dead-letter oneway request",
+				zap.String("id", entry.ID), zap.Error(err))
+			return false
+		}
+	}
+	m.metrics.edge(req).deadLettered.Inc()
+	delete(m.attempts, entry.ID)
+	return m.markDone(entry)
+}
+
+func (m *Middleware) markDone(entry Entry) bool {
+	if err := m.store.MarkDone(entry.ID); err != nil {
+		m.opts.logger.Error("failed to mark oneway request done",
+			zap.String("id", entry.ID), zap.Error(err))
+		return false
+	}
+	return true
+}
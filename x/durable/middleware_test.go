@@ -0,0 +1,261 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package durable
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/backoff"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/serialize"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// fakeOutbound fails calls while the network is down, and records the
+// bodies of the requests it receives otherwise.
+type fakeOutbound struct {
+	transport.OnewayOutbound
+
+	down      atomic.Bool
+	attempts  atomic.Int32
+	delivered chan string
+}
+
+func newFakeOutbound() *fakeOutbound {
+	return &fakeOutbound{delivered: make(chan string, 10)}
+}
+
+func (o *fakeOutbound) CallOneway(ctx context.Context, req *transport.Request) (transport.Ack, error) {
+	o.attempts.Inc()
+	if o.down.Load() {
+		return nil, yarpcerrors.UnavailableErrorf("network is down")
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	o.delivered <- string(body)
+	return ack{}, nil
+}
+
+func (o *fakeOutbound) receive(t *testing.T) string {
+	select {
+	case body := <-o.delivered:
+		return body
+	case <-time.After(testtime.Second):
+		t.Fatal("timed out waiting for delivery")
+		return ""
+	}
+}
+
+// noBackoff retries immediately.
+type noBackoff struct{}
+
+func (noBackoff) Backoff() backoff.Backoff    { return noBackoff{} }
+func (noBackoff) Duration(uint) time.Duration { return time.Millisecond }
+
+// memoryStore is a Store that keeps its entries in memory.
+type memoryStore struct {
+	lock    sync.Mutex
+	entries []Entry
+	next    int
+}
+
+func (s *memoryStore) Append(payload []byte) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.next++
+	id := string(rune('a' + s.next))
+	s.entries = append(s.entries, Entry{ID: id, Payload: payload})
+	return id, nil
+}
+
+func (s *memoryStore) MarkDone(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, e := range s.entries {
+		if e.ID == id {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) ScanPending() ([]Entry, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Entry(nil), s.entries...), nil
+}
+
+func newRequest(body string) *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "Queue::push",
+		Encoding:  "raw",
+		Headers:   transport.NewHeaders().With("x-request-id", "42"),
+		Body:      bytes.NewBufferString(body),
+	}
+}
+
+func counters(root *metrics.Root) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, c := range root.Snapshot().Counters {
+		counts[c.Name] += c.Value
+	}
+	return counts
+}
+
+func TestDeliver(t *testing.T) {
+	root := metrics.New()
+	out := newFakeOutbound()
+	mw := NewOnewayOutboundMiddleware(&memoryStore{}, Meter(root.Scope()))
+	defer mw.Stop()
+
+	ack, err := mw.CallOneway(context.Background(), newRequest("hello"), out)
+	require.NoError(t, err)
+	assert.Equal(t, "persisted", ack.String())
+	assert.Equal(t, "hello", out.receive(t))
+
+	require.NoError(t, mw.Stop())
+	assert.Equal(t, map[string]int64{
+		"durable_persisted":         1,
+		"durable_delivered":         1,
+		"durable_delivery_failures": 0,
+		"durable_dead_lettered":     0,
+	}, counters(root))
+}
+
+func TestPersistFailure(t *testing.T) {
+	mw := NewOnewayOutboundMiddleware(&failingStore{})
+	defer mw.Stop()
+
+	_, err := mw.CallOneway(context.Background(), newRequest("hello"), newFakeOutbound())
+	assert.EqualError(t, err, "disk is full", "expected the call to fail if the request cannot be persisted")
+}
+
+type failingStore struct{ memoryStore }
+
+func (*failingStore) Append([]byte) (string, error) { return "", errors.New("disk is full") }
+
+func TestRedeliverAfterRestart(t *testing.T) {
+	dir := tempDir(t)
+	out := newFakeOutbound()
+	out.down.Store(true)
+
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+	mw := NewOnewayOutboundMiddleware(store, Backoff(noBackoff{}))
+	for _, body := range []string{"first", "second"} {
+		_, err := mw.CallOneway(context.Background(), newRequest(body), out)
+		require.NoError(t, err, "expected the call to be acknowledged while the network is down")
+	}
+	require.Eventually(t, func() bool { return out.attempts.Load() > 0 },
+		testtime.Second, time.Millisecond, "expected deliveries to be attempted")
+	require.NoError(t, mw.Stop())
+
+	// A new process reopens the store and delivers once the network
+	// recovers.
+	store, err = NewFileStore(dir)
+	require.NoError(t, err)
+	pending, err := store.ScanPending()
+	require.NoError(t, err)
+	require.Len(t, pending, 2, "expected the requests to survive the restart")
+
+	out.down.Store(false)
+	mw = NewOnewayOutboundMiddleware(store, Outbound(out), Backoff(noBackoff{}))
+	defer mw.Stop()
+	assert.Equal(t, "first", out.receive(t))
+	assert.Equal(t, "second", out.receive(t))
+
+	require.NoError(t, mw.Stop())
+	pending, err = store.ScanPending()
+	require.NoError(t, err)
+	assert.Empty(t, pending, "expected delivered requests to be marked done")
+}
+
+func TestDeadLetter(t *testing.T) {
+	root := metrics.New()
+	out := newFakeOutbound()
+	out.down.Store(true)
+	store := &memoryStore{}
+	deadLetters := &memoryStore{}
+
+	mw := NewOnewayOutboundMiddleware(store,
+		MaxAttempts(3),
+		Backoff(noBackoff{}),
+		DeadLetterStore(deadLetters),
+		Meter(root.Scope()),
+	)
+	defer mw.Stop()
+	_, err := mw.CallOneway(context.Background(), newRequest("poison"), out)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		pending, _ := store.ScanPending()
+		return len(pending) == 0
+	}, testtime.Second, time.Millisecond, "expected the request to be dead-lettered")
+	require.NoError(t, mw.Stop())
+
+	assert.Equal(t, int32(3), out.attempts.Load())
+	assert.Equal(t, map[string]int64{
+		"durable_persisted":         1,
+		"durable_delivered":         0,
+		"durable_delivery_failures": 3,
+		"durable_dead_lettered":     1,
+	}, counters(root))
+
+	dead, err := deadLetters.ScanPending()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	_, req, err := serialize.FromBytes(_tracer, dead[0].Payload)
+	require.NoError(t, err)
+	assert.Equal(t, "Queue::push", req.Procedure)
+}
+
+func TestDeadLetterUndecodable(t *testing.T) {
+	root := metrics.New()
+	store := &memoryStore{}
+	_, err := store.Append([]byte("garbage"))
+	require.NoError(t, err)
+
+	mw := NewOnewayOutboundMiddleware(store, Outbound(newFakeOutbound()), Meter(root.Scope()))
+	defer mw.Stop()
+
+	require.Eventually(t, func() bool {
+		pending, _ := store.ScanPending()
+		return len(pending) == 0
+	}, testtime.Second, time.Millisecond, "expected the request to be dead-lettered")
+	require.NoError(t, mw.Stop())
+	assert.Equal(t, int64(1), counters(root)["durable_dead_lettered"])
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package durable
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Entry is a request persisted in a Store and not yet delivered.
+type Entry struct {
+	// ID identifies the entry in its store.
+	ID string
+
+	// Payload is the serialized request.
+	Payload []byte
+}
+
+// Store persists the requests of oneway calls until they are delivered.
+//
+// Implementations MUST be safe for concurrent use.
+type Store interface {
+	// Append durably persists the payload and returns the identifier of its
+	// entry. Calls are acknowledged once Append returns.
+	Append(payload []byte) (id string, err error)
+
+	// MarkDone removes the entry with the given identifier from the pending
+	// entries.
+	MarkDone(id string) error
+
+	// ScanPending returns the entries that were appended but not marked
+	// done, in the order in which they were appended.
+	ScanPending() ([]Entry, error)
+}
+
+const (
+	_entrySuffix = ".msg"
+	_tmpSuffix   = ".tmp"
+)
+
+type fileStore struct {
+	dir string
+
+	lock sync.Mutex
+	next uint64
+}
+
+// NewFileStore builds a Store that persists every entry in a file of its own
+// in the given directory, creating it if needed.
+//
+// Entries are written to a temporary file and synced before they are
+// renamed into place, so that a crash never leaves a partial entry.
+// Reopening the store in the same directory, for example after a restart,
+// resumes with the pending entries of the previous one.
+func NewFileStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	// Temporary files are left behind by appends that never returned.
+	tmps, err := filepath.Glob(filepath.Join(dir, "*"+_tmpSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, tmp := range tmps {
+		if err := os.Remove(tmp); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &fileStore{dir: dir}
+	names, err := s.names()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > 0 {
+		last, err := strconv.ParseUint(strings.TrimSuffix(names[len(names)-1], _entrySuffix), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected entry %q in %q: %v", names[len(names)-1], dir, err)
+		}
+		s.next = last + 1
+	}
+	return s, nil
+}
+
+func (s *fileStore) Append(payload []byte) (string, error) {
+	s.lock.Lock()
+	id := fmt.Sprintf("%020d", s.next)
+	s.next++
+	s.lock.Unlock()
+
+	path := filepath.Join(s.dir, id+_entrySuffix)
+	tmp := path + _tmpSuffix
+	if err := writeFileSync(tmp, payload); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return id, syncDir(s.dir)
+}
+
+func (s *fileStore) MarkDone(id string) error {
+	err := os.Remove(filepath.Join(s.dir, id+_entrySuffix))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *fileStore) ScanPending() ([]Entry, error) {
+	names, err := s.names()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(names))
+	for _, name := range names {
+		payload, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+		if os.IsNotExist(err) {
+			// Marked done since the directory was read.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{ID: strings.TrimSuffix(name, _entrySuffix), Payload: payload})
+	}
+	return entries, nil
+}
+
+// names returns the sorted file names of the entries in the directory.
+// Zero-padded identifiers sort in the order in which they were appended.
+func (s *fileStore) names() ([]string, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		if name := info.Name(); strings.HasSuffix(name, _entrySuffix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir persists the renames in the directory.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
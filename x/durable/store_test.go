@@ -0,0 +1,101 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package durable
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "durable")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestFileStore(t *testing.T) {
+	dir := tempDir(t)
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	pending, err := store.ScanPending()
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	first, err := store.Append([]byte("first"))
+	require.NoError(t, err)
+	second, err := store.Append([]byte("second"))
+	require.NoError(t, err)
+	third, err := store.Append([]byte("third"))
+	require.NoError(t, err)
+
+	require.NoError(t, store.MarkDone(second))
+	require.NoError(t, store.MarkDone(second), "expected marking an entry done twice to succeed")
+
+	pending, err = store.ScanPending()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{ID: first, Payload: []byte("first")},
+		{ID: third, Payload: []byte("third")},
+	}, pending)
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	dir := tempDir(t)
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+	first, err := store.Append([]byte("first"))
+	require.NoError(t, err)
+
+	// An append interrupted by a crash leaves a temporary file behind.
+	tmp := filepath.Join(dir, "00000000000000000001.msg.tmp")
+	require.NoError(t, ioutil.WriteFile(tmp, []byte("partial"), 0600))
+
+	store, err = NewFileStore(dir)
+	require.NoError(t, err)
+	_, err = os.Stat(tmp)
+	assert.True(t, os.IsNotExist(err), "expected temporary files to be removed")
+
+	second, err := store.Append([]byte("second"))
+	require.NoError(t, err)
+	assert.True(t, second > first, "expected identifiers to keep increasing across stores")
+
+	pending, err := store.ScanPending()
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{ID: first, Payload: []byte("first")},
+		{ID: second, Payload: []byte("second")},
+	}, pending)
+}
+
+func TestFileStoreUnexpectedEntry(t *testing.T) {
+	dir := tempDir(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "foo.msg"), nil, 0600))
+
+	_, err := NewFileStore(dir)
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package dynratelimit provides inbound middleware that limits the rate of
+// the requests of every procedure by limits read from a LimitStore, like a
+// remote configuration store, so that limits change without a redeploy.
+//
+// 	store := dynratelimit.NewConsulStore(dynratelimit.ConsulConfig{
+// 		Address: "http://127.0.0.1:8500",
+// 		Prefix:  "ratelimits/myservice",
+// 	})
+// 	mw := dynratelimit.NewInboundMiddleware(store,
+// 		dynratelimit.RefreshInterval(30*time.Second),
+// 		dynratelimit.Meter(meter),
+// 		dynratelimit.Logger(logger),
+// 	)
+// 	defer mw.Stop()
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary:  mw,
+// 			Oneway: mw,
+// 		},
+// 	})
+//
+// The middleware reads the limit of a procedure when it sees its first
+// request, and refreshes the limits of every procedure it has seen at the
+// refresh interval. The requests of a procedure are not limited until its
+// limit is read, and procedures keep their previous limit while the store
+// fails to read it. Requests beyond the limit of their procedure fail with a
+// ResourceExhausted error.
+//
+// The Consul and etcd stores read the limit of a procedure from the JSON
+// value of its key, like {"rate": 100, "burst": 20}. The MemoryStore keeps
+// limits set by the service itself.
+package dynratelimit
@@ -0,0 +1,69 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dynratelimit
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
+const _procedureTag = "procedure"
+
+type limitMetrics struct {
+	allowed         *metrics.CounterVector
+	limited         *metrics.CounterVector
+	refreshFailures *metrics.CounterVector
+}
+
+func newLimitMetrics(meter *metrics.Scope, logger *zap.Logger) *limitMetrics {
+	tags := []string{_procedureTag}
+
+	allowed, err := meter.CounterVector(metrics.Spec{
+		Name:    "dynratelimit_allowed",
+		Help:    "Total number of requests allowed by the dynamic rate limit of their procedure.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create dynamic rate limit allowed counter", zap.Error(err))
+	}
+	limited, err := meter.CounterVector(metrics.Spec{
+		Name:    "dynratelimit_limited",
+		Help:    "Total number of requests rejected by the dynamic rate limit of their procedure.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create dynamic rate limit limited counter", zap.Error(err))
+	}
+	refreshFailures, err := meter.CounterVector(metrics.Spec{
+		Name:    "dynratelimit_refresh_failures",
+		Help:    "Total number of failures to read the rate limit of a procedure from the store.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create dynamic rate limit refresh failures counter", zap.Error(err))
+	}
+
+	return &limitMetrics{
+		allowed:         allowed,
+		limited:         limited,
+		refreshFailures: refreshFailures,
+	}
+}
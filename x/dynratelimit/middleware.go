@@ -0,0 +1,278 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dynratelimit
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/ratelimit"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const _defaultRefreshInterval = 10 * time.Second
+
+// Option customizes the behavior of the dynamic rate limiting middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	refreshInterval time.Duration
+	meter           *metrics.Scope
+	logger          *zap.Logger
+}
+
+// RefreshInterval is the interval at which the middleware reads the limits
+// of the procedures it has seen from the store.
+//
+// Defaults to ten seconds.
+func RefreshInterval(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d > 0 {
+			o.refreshInterval = d
+		}
+	})
+}
+
+// Meter sets the scope for the metrics of the middleware.
+func Meter(meter *metrics.Scope) Option {
+	return optionFunc(func(o *options) {
+		o.meter = meter
+	})
+}
+
+// Logger sets the logger for the middleware.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+var (
+	_ middleware.UnaryInbound  = (*Middleware)(nil)
+	_ middleware.OnewayInbound = (*Middleware)(nil)
+)
+
+// Middleware is an inbound middleware that limits the rate of the requests
+// of every procedure by the limit of the procedure in a LimitStore, which it
+// refreshes from a background goroutine.
+type Middleware struct {
+	store   LimitStore
+	opts    options
+	limiter *ratelimit.Middleware
+	metrics *limitMetrics
+
+	lock       sync.RWMutex
+	procedures map[string]struct{}
+
+	// limits are the latest limits read from the store, and are only
+	// accessed by the refresh goroutine.
+	limits map[string]ratelimit.Limit
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewInboundMiddleware returns an inbound middleware that limits the rate of
+// requests by the limits of their procedures in the given store, and starts
+// refreshing them. Stop the middleware to stop refreshing them.
+func NewInboundMiddleware(store LimitStore, opts ...Option) *Middleware {
+	o := options{
+		refreshInterval: _defaultRefreshInterval,
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.logger == nil {
+		o.logger = zap.NewNop()
+	}
+
+	m := &Middleware{
+		store:      store,
+		opts:       o,
+		limiter:    ratelimit.NewMiddleware(ratelimit.Config{Logger: o.logger}),
+		metrics:    newLimitMetrics(o.meter, o.logger),
+		procedures: make(map[string]struct{}),
+		limits:     make(map[string]ratelimit.Limit),
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+// Handle implements middleware.UnaryInbound.
+func (m *Middleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	m.observe(req.Procedure)
+	ah := &admittedUnaryHandler{h: h}
+	err := m.limiter.Handle(ctx, req, resw, ah)
+	m.count(req.Procedure, ah.admitted)
+	return err
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	m.observe(req.Procedure)
+	ah := &admittedOnewayHandler{h: h}
+	err := m.limiter.HandleOneway(ctx, req, ah)
+	m.count(req.Procedure, ah.admitted)
+	return err
+}
+
+// admittedUnaryHandler records whether the limiter admitted a request to
+// its handler, to tell limited requests from failed ones.
+type admittedUnaryHandler struct {
+	h        transport.UnaryHandler
+	admitted bool
+}
+
+func (a *admittedUnaryHandler) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	a.admitted = true
+	return a.h.Handle(ctx, req, resw)
+}
+
+type admittedOnewayHandler struct {
+	h        transport.OnewayHandler
+	admitted bool
+}
+
+func (a *admittedOnewayHandler) HandleOneway(ctx context.Context, req *transport.Request) error {
+	a.admitted = true
+	return a.h.HandleOneway(ctx, req)
+}
+
+// Stop stops refreshing the limits. The latest limits still apply.
+func (m *Middleware) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+	<-m.stopped
+}
+
+func (m *Middleware) count(procedure string, admitted bool) {
+	if admitted {
+		m.metrics.allowed.MustGet(_procedureTag, procedure).Inc()
+	} else {
+		m.metrics.limited.MustGet(_procedureTag, procedure).Inc()
+	}
+}
+
+// observe records a procedure, reading its limit from the store right away
+// the first time it is seen. The requests of a procedure are not limited
+// until its limit is read.
+//
+// Inbound middleware only see the procedures registered with the
+// dispatcher, so that the procedures are bounded.
+func (m *Middleware) observe(procedure string) {
+	m.lock.RLock()
+	_, ok := m.procedures[procedure]
+	m.lock.RUnlock()
+	if ok {
+		return
+	}
+
+	m.lock.Lock()
+	m.procedures[procedure] = struct{}{}
+	m.lock.Unlock()
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Middleware) run() {
+	defer close(m.stopped)
+
+	ticker := time.NewTicker(m.opts.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		case <-m.wake:
+		}
+		m.refresh()
+	}
+}
+
+// refresh reads the limits of every procedure seen so far from the store,
+// and replaces the limits of the limiter at once if any of them changed.
+// Procedures whose limit fails to read keep their previous limit.
+func (m *Middleware) refresh() {
+	m.lock.RLock()
+	procedures := make([]string, 0, len(m.procedures))
+	for procedure := range m.procedures {
+		procedures = append(procedures, procedure)
+	}
+	m.lock.RUnlock()
+	sort.Strings(procedures)
+
+	changed := false
+	for _, procedure := range procedures {
+		limit, burst, err := m.store.GetLimit(procedure)
+		if errors.Is(err, ErrNoLimit) {
+			if _, ok := m.limits[procedure]; ok {
+				delete(m.limits, procedure)
+				changed = true
+			}
+			continue
+		}
+		if err != nil {
+			m.metrics.refreshFailures.MustGet(_procedureTag, procedure).Inc()
+			m.opts.logger.Warn("failed to read rate limit of procedure",
+				zap.String("procedure", procedure), zap.Error(err))
+			continue
+		}
+		l := ratelimit.Limit{Rate: float64(limit), Burst: burst}
+		if limit == rate.Inf {
+			// The limiter disables non-positive rates.
+			l.Rate = 0
+		}
+		if old, ok := m.limits[procedure]; !ok || old != l {
+			m.limits[procedure] = l
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	overrides := make([]ratelimit.Override, 0, len(m.limits))
+	for procedure, l := range m.limits {
+		overrides = append(overrides, ratelimit.Override{Procedure: procedure, Limit: l})
+	}
+	m.limiter.SetLimits(nil, overrides)
+}
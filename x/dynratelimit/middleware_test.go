@@ -0,0 +1,197 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dynratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+	"golang.org/x/time/rate"
+)
+
+type okHandler struct{}
+
+func (okHandler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	return nil
+}
+
+func (okHandler) HandleOneway(context.Context, *transport.Request) error {
+	return nil
+}
+
+func handle(m *Middleware, procedure string) error {
+	req := &transport.Request{Service: "svc", Caller: "caller", Procedure: procedure}
+	return m.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, okHandler{})
+}
+
+func counters(root *metrics.Root) map[string]int64 {
+	got := make(map[string]int64)
+	for _, c := range root.Snapshot().Counters {
+		got[c.Name+":"+c.Tags[_procedureTag]] += c.Value
+	}
+	return got
+}
+
+func TestMiddleware(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetLimit("limited", 0.001, 1)
+	root := metrics.New()
+	m := NewInboundMiddleware(store, RefreshInterval(10*time.Millisecond), Meter(root.Scope()))
+	defer m.Stop()
+
+	// The limit of a procedure applies once it is read, after its first
+	// request.
+	require.Eventually(t, func() bool {
+		return yarpcerrors.IsResourceExhausted(handle(m, "limited"))
+	}, time.Second, time.Millisecond)
+	assert.True(t, yarpcerrors.IsResourceExhausted(handle(m, "limited")))
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, handle(m, "unlimited"), "procedures without a limit must not be limited")
+	}
+
+	// Limits changed in the store apply without a restart.
+	store.SetLimit("limited", 1000, 100)
+	require.Eventually(t, func() bool {
+		return handle(m, "limited") == nil
+	}, time.Second, time.Millisecond)
+
+	m.Stop()
+	got := counters(root)
+	assert.Equal(t, int64(10), got["dynratelimit_allowed:unlimited"])
+	assert.True(t, got["dynratelimit_limited:limited"] >= 2)
+	assert.True(t, got["dynratelimit_allowed:limited"] >= 2)
+}
+
+func TestMiddlewareOneway(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetLimit("limited", 0.001, 1)
+	m := NewInboundMiddleware(store, RefreshInterval(10*time.Millisecond))
+	defer m.Stop()
+
+	req := &transport.Request{Service: "svc", Caller: "caller", Procedure: "limited"}
+	require.Eventually(t, func() bool {
+		return yarpcerrors.IsResourceExhausted(m.HandleOneway(context.Background(), req, okHandler{}))
+	}, time.Second, time.Millisecond)
+}
+
+func TestMiddlewareDeletedLimit(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetLimit("limited", 0.001, 1)
+	m := NewInboundMiddleware(store, RefreshInterval(10*time.Millisecond))
+	defer m.Stop()
+
+	require.Eventually(t, func() bool {
+		return yarpcerrors.IsResourceExhausted(handle(m, "limited"))
+	}, time.Second, time.Millisecond)
+
+	store.DeleteLimit("limited")
+	require.Eventually(t, func() bool {
+		return handle(m, "limited") == nil
+	}, time.Second, time.Millisecond)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, handle(m, "limited"))
+	}
+}
+
+func TestMiddlewareInfiniteLimit(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetLimit("limited", 0.001, 1)
+	m := NewInboundMiddleware(store, RefreshInterval(10*time.Millisecond))
+	defer m.Stop()
+
+	require.Eventually(t, func() bool {
+		return yarpcerrors.IsResourceExhausted(handle(m, "limited"))
+	}, time.Second, time.Millisecond)
+
+	store.SetLimit("limited", rate.Inf, 0)
+	require.Eventually(t, func() bool {
+		return handle(m, "limited") == nil
+	}, time.Second, time.Millisecond)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, handle(m, "limited"), "an infinite limit must admit every request")
+	}
+}
+
+// flakyStore fails to read limits while it is failing.
+type flakyStore struct {
+	*MemoryStore
+
+	lock    sync.Mutex
+	failing bool
+	reads   int
+}
+
+func (s *flakyStore) setFailing(failing bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failing = failing
+	s.reads = 0
+}
+
+func (s *flakyStore) readCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.reads
+}
+
+func (s *flakyStore) GetLimit(procedure string) (rate.Limit, int, error) {
+	s.lock.Lock()
+	s.reads++
+	failing := s.failing
+	s.lock.Unlock()
+	if failing {
+		return 0, 0, errors.New("great sadness")
+	}
+	return s.MemoryStore.GetLimit(procedure)
+}
+
+func TestMiddlewareStoreFailure(t *testing.T) {
+	store := &flakyStore{MemoryStore: NewMemoryStore()}
+	store.SetLimit("limited", 0.001, 1)
+	root := metrics.New()
+	m := NewInboundMiddleware(store, RefreshInterval(10*time.Millisecond), Meter(root.Scope()))
+	defer m.Stop()
+
+	require.Eventually(t, func() bool {
+		return yarpcerrors.IsResourceExhausted(handle(m, "limited"))
+	}, time.Second, time.Millisecond)
+
+	// Failures to read a limit keep the previous limit.
+	store.setFailing(true)
+	store.DeleteLimit("limited")
+	require.Eventually(t, func() bool {
+		return store.readCount() >= 3
+	}, time.Second, time.Millisecond)
+	assert.True(t, yarpcerrors.IsResourceExhausted(handle(m, "limited")))
+
+	m.Stop()
+	assert.True(t, counters(root)["dynratelimit_refresh_failures:limited"] >= 1)
+}
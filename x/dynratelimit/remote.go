@@ -0,0 +1,212 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dynratelimit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const _defaultRemoteTimeout = time.Second
+
+// remoteLimit is the JSON value of the limit of a procedure in a remote
+// store, like {"rate": 100, "burst": 20}.
+type remoteLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+func parseLimit(key string, value []byte) (rate.Limit, int, error) {
+	var l remoteLimit
+	if err := json.Unmarshal(value, &l); err != nil {
+		return 0, 0, fmt.Errorf("invalid rate limit for key %q: %v", key, err)
+	}
+	return rate.Limit(l.Rate), l.Burst, nil
+}
+
+// ConsulConfig configures a LimitStore that reads limits from the Consul KV
+// store.
+type ConsulConfig struct {
+	// Address is the URL of the Consul agent, like http://127.0.0.1:8500.
+	Address string
+
+	// Prefix is the path of the keys of the limits, like
+	// ratelimits/myservice. The limit of a procedure is the JSON value of
+	// the key of the procedure under the prefix, like {"rate": 100}.
+	Prefix string
+
+	// Token is the ACL token of the requests to Consul, if any.
+	Token string
+
+	// Timeout bounds every request to Consul.
+	//
+	// Defaults to one second.
+	Timeout time.Duration
+
+	// Client is the HTTP client of the requests to Consul.
+	//
+	// Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type consulStore struct {
+	cfg ConsulConfig
+}
+
+// NewConsulStore returns a LimitStore that reads limits from the Consul KV
+// store.
+func NewConsulStore(cfg ConsulConfig) LimitStore {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = _defaultRemoteTimeout
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	cfg.Address = strings.TrimSuffix(cfg.Address, "/")
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+	return &consulStore{cfg: cfg}
+}
+
+func (s *consulStore) GetLimit(procedure string) (rate.Limit, int, error) {
+	key := s.cfg.Prefix + "/" + procedure
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, s.cfg.Address+"/v1/kv/"+s.cfg.Prefix+"/"+url.PathEscape(procedure)+"?raw", nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	req = req.WithContext(ctx)
+	if s.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", s.cfg.Token)
+	}
+
+	res, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return 0, 0, ErrNoLimit
+	default:
+		return 0, 0, fmt.Errorf("failed to read key %q from Consul: %v", key, res.Status)
+	}
+	value, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseLimit(key, value)
+}
+
+// EtcdConfig configures a LimitStore that reads limits from etcd, through the
+// JSON gateway of its v3 API.
+type EtcdConfig struct {
+	// Endpoint is the URL of an etcd member, like http://127.0.0.1:2379.
+	Endpoint string
+
+	// Prefix is the prefix of the keys of the limits, like
+	// /ratelimits/myservice/. The limit of a procedure is the JSON value of
+	// the prefix followed by the procedure, like {"rate": 100}.
+	Prefix string
+
+	// Timeout bounds every request to etcd.
+	//
+	// Defaults to one second.
+	Timeout time.Duration
+
+	// Client is the HTTP client of the requests to etcd.
+	//
+	// Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type etcdStore struct {
+	cfg EtcdConfig
+}
+
+// NewEtcdStore returns a LimitStore that reads limits from etcd.
+func NewEtcdStore(cfg EtcdConfig) LimitStore {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = _defaultRemoteTimeout
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	return &etcdStore{cfg: cfg}
+}
+
+type etcdRangeRequest struct {
+	Key []byte `json:"key"`
+}
+
+type etcdRangeResponse struct {
+	KVs []struct {
+		Value []byte `json:"value"`
+	} `json:"kvs"`
+}
+
+func (s *etcdStore) GetLimit(procedure string) (rate.Limit, int, error) {
+	key := s.cfg.Prefix + procedure
+	body, err := json.Marshal(etcdRangeRequest{Key: []byte(key)})
+	if err != nil {
+		return 0, 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, s.cfg.Endpoint+"/v3/kv/range", bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("failed to read key %q from etcd: %v", key, res.Status)
+	}
+
+	var rng etcdRangeResponse
+	if err := json.NewDecoder(res.Body).Decode(&rng); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode etcd response for key %q: %v", key, err)
+	}
+	if len(rng.KVs) == 0 {
+		return 0, 0, ErrNoLimit
+	}
+	return parseLimit(key, rng.KVs[0].Value)
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dynratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	_, _, err := store.GetLimit("foo")
+	assert.Equal(t, ErrNoLimit, err)
+
+	store.SetLimit("foo", 10, 5)
+	limit, burst, err := store.GetLimit("foo")
+	require.NoError(t, err)
+	assert.Equal(t, rate.Limit(10), limit)
+	assert.Equal(t, 5, burst)
+
+	store.DeleteLimit("foo")
+	_, _, err = store.GetLimit("foo")
+	assert.Equal(t, ErrNoLimit, err)
+}
+
+func TestConsulStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+		assert.Contains(t, r.URL.Query(), "raw")
+		switch r.URL.Path {
+		case "/v1/kv/ratelimits/svc/foo::bar":
+			w.Write([]byte(`{"rate": 100, "burst": 20}`))
+		case "/v1/kv/ratelimits/svc/invalid":
+			w.Write([]byte(`100`))
+		case "/v1/kv/ratelimits/svc/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	store := NewConsulStore(ConsulConfig{
+		Address: server.URL + "/",
+		Prefix:  "/ratelimits/svc/",
+		Token:   "secret",
+	})
+
+	limit, burst, err := store.GetLimit("foo::bar")
+	require.NoError(t, err)
+	assert.Equal(t, rate.Limit(100), limit)
+	assert.Equal(t, 20, burst)
+
+	_, _, err = store.GetLimit("missing")
+	assert.Equal(t, ErrNoLimit, err)
+
+	_, _, err = store.GetLimit("invalid")
+	assert.Contains(t, err.Error(), `invalid rate limit for key "ratelimits/svc/invalid"`)
+
+	_, _, err = store.GetLimit("broken")
+	assert.Contains(t, err.Error(), `failed to read key "ratelimits/svc/broken" from Consul: 500`)
+}
+
+func TestEtcdStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !assert.Equal(t, "/v3/kv/range", r.URL.Path) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req etcdRangeRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch string(req.Key) {
+		case "/ratelimits/svc/foo":
+			// Values are base64 encoded by the JSON gateway.
+			w.Write([]byte(`{"kvs": [{"key": "L3JhdGVsaW1pdHMvc3ZjL2Zvbw==", "value": "eyJyYXRlIjogNTB9"}], "count": "1"}`))
+		case "/ratelimits/svc/broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"header": {}}`))
+		}
+	}))
+	defer server.Close()
+
+	store := NewEtcdStore(EtcdConfig{
+		Endpoint: server.URL,
+		Prefix:   "/ratelimits/svc/",
+	})
+
+	limit, burst, err := store.GetLimit("foo")
+	require.NoError(t, err)
+	assert.Equal(t, rate.Limit(50), limit)
+	assert.Equal(t, 0, burst)
+
+	_, _, err = store.GetLimit("missing")
+	assert.Equal(t, ErrNoLimit, err)
+
+	_, _, err = store.GetLimit("broken")
+	assert.Contains(t, err.Error(), `failed to read key "/ratelimits/svc/broken" from etcd: 503`)
+}
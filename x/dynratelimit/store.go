@@ -0,0 +1,90 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dynratelimit
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrNoLimit is returned by a LimitStore for a procedure that has no limit.
+var ErrNoLimit = errors.New("no rate limit for procedure")
+
+// LimitStore is a source of the rate limits of procedures, like a remote
+// configuration store.
+type LimitStore interface {
+	// GetLimit returns the limit of a procedure: the number of requests per
+	// second it admits in the long run, and the number of requests it admits
+	// at once after a quiet period.
+	//
+	// A limit of rate.Inf disables the limit of the procedure, as does a
+	// non-positive limit, which stores cannot tell apart from a missing
+	// rate. A non-positive burst defaults to the limit, rounded up, and at
+	// least 1.
+	// GetLimit returns ErrNoLimit if the procedure has no limit.
+	GetLimit(procedure string) (limit rate.Limit, burst int, err error)
+}
+
+type limit struct {
+	limit rate.Limit
+	burst int
+}
+
+// MemoryStore is a LimitStore that keeps limits in memory, for tests and
+// for limits set by the service itself.
+type MemoryStore struct {
+	lock   sync.RWMutex
+	limits map[string]limit
+}
+
+var _ LimitStore = (*MemoryStore)(nil)
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{limits: make(map[string]limit)}
+}
+
+// SetLimit sets the limit of a procedure.
+func (s *MemoryStore) SetLimit(procedure string, l rate.Limit, burst int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.limits[procedure] = limit{limit: l, burst: burst}
+}
+
+// DeleteLimit removes the limit of a procedure.
+func (s *MemoryStore) DeleteLimit(procedure string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.limits, procedure)
+}
+
+// GetLimit implements LimitStore.
+func (s *MemoryStore) GetLimit(procedure string) (rate.Limit, int, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	l, ok := s.limits[procedure]
+	if !ok {
+		return 0, 0, ErrNoLimit
+	}
+	return l.limit, l.burst, nil
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package globalratelimit provides outbound middleware that limits the rate
+// of requests across all instances of a service, sharing a token bucket in
+// Redis.
+//
+// Every request takes a token from the bucket of its destination service,
+// which refills at the configured rate. Requests that find the bucket empty
+// fail with a ResourceExhausted error carrying the delay until a token is
+// available, which RetryAfter reveals.
+//
+// 	limiter := globalratelimit.NewOutboundMiddleware(redisClient, "ratelimit", 100)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:      "myservice",
+// 		Outbounds: outbounds,
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary: limiter,
+// 		},
+// 	})
+//
+// The middleware fails open: if Redis is unreachable, requests proceed as if
+// there were no limit.
+package globalratelimit
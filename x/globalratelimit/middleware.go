@@ -0,0 +1,206 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package globalratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// _tokenBucket takes a token from the bucket at KEYS[1], refilling it at
+// ARGV[1] tokens per second up to ARGV[2] tokens, using the clock of the
+// Redis server so that all clients agree on the time.
+//
+// It returns 1 and 0 if a token was taken, or 0 and the number of
+// milliseconds until a token is available otherwise.
+// The bucket expires once it would be full again.
+var _tokenBucket = redis.NewScript(`
+redis.replicate_commands()
+
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "timestamp")
+local tokens = tonumber(bucket[1])
+local timestamp = tonumber(bucket[2])
+if tokens == nil or timestamp == nil then
+	tokens = capacity
+	timestamp = now
+end
+
+local elapsed = math.max(0, now - timestamp)
+tokens = math.min(capacity, tokens + elapsed * rate / 1000000)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "timestamp", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.max(1, math.ceil((capacity - tokens) * 1000 / rate)))
+
+return {allowed, wait}
+`)
+
+type outboundOptions struct {
+	burst  int
+	logger *zap.Logger
+}
+
+// OutboundOption customizes the behavior of the outbound middleware.
+type OutboundOption func(*outboundOptions)
+
+// Burst specifies the number of requests that may be sent at once after a
+// quiet period, which is the capacity of the token bucket.
+//
+// Defaults to the rate, allowing one second's worth of requests at once.
+func Burst(n int) OutboundOption {
+	return func(o *outboundOptions) {
+		o.burst = n
+	}
+}
+
+// Logger specifies a logger for warnings about requests allowed because
+// Redis was unreachable.
+func Logger(logger *zap.Logger) OutboundOption {
+	return func(o *outboundOptions) {
+		o.logger = logger
+	}
+}
+
+// NewOutboundMiddleware returns middleware that limits unary requests to
+// each destination service to the given number of requests per second,
+// across every client sharing the Redis instance and key prefix.
+//
+// The token bucket of each service is stored under the key
+// "<keyPrefix>:<service>". A non-positive rate disables the limit.
+func NewOutboundMiddleware(client redis.UniversalClient, keyPrefix string, rps int, opts ...OutboundOption) middleware.UnaryOutbound {
+	options := outboundOptions{burst: rps}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.burst < 1 {
+		options.burst = 1
+	}
+
+	logger := options.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &outboundMiddleware{
+		client:    client,
+		keyPrefix: keyPrefix,
+		rps:       rps,
+		burst:     options.burst,
+		logger:    logger,
+	}
+}
+
+type outboundMiddleware struct {
+	client    redis.UniversalClient
+	keyPrefix string
+	rps       int
+	burst     int
+	logger    *zap.Logger
+}
+
+func (m *outboundMiddleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	if m.rps <= 0 {
+		return out.Call(ctx, req)
+	}
+
+	key := m.keyPrefix + ":" + req.Service
+	wait, err := m.take(ctx, key)
+	if err != nil {
+		m.logger.Warn("allowing request without global rate limit: failed to reach Redis",
+			zap.String("key", key),
+			zap.String("service", req.Service),
+			zap.String("procedure", req.Procedure),
+			zap.Error(err))
+		return out.Call(ctx, req)
+	}
+	if wait > 0 {
+		return nil, newRateLimitError(req.Service, wait)
+	}
+	return out.Call(ctx, req)
+}
+
+// take takes a token from the bucket, returning the delay until a token is
+// available if the bucket is empty.
+func (m *outboundMiddleware) take(ctx context.Context, key string) (time.Duration, error) {
+	result, err := _tokenBucket.Run(ctx, m.client, []string{key}, m.rps, m.burst).Int64Slice()
+	if err != nil {
+		return 0, err
+	}
+	if len(result) != 2 {
+		return 0, errors.New("unexpected result from token bucket script")
+	}
+	if result[0] == 1 {
+		return 0, nil
+	}
+	wait := time.Duration(result[1]) * time.Millisecond
+	if wait <= 0 {
+		wait = time.Millisecond
+	}
+	return wait, nil
+}
+
+func newRateLimitError(service string, wait time.Duration) error {
+	err := yarpcerrors.ResourceExhaustedErrorf(
+		"global rate limit exceeded for service %q, retry after %v", service, wait)
+	return yarpcerrors.WithDetails(err, &rpc.RetryInfo{RetryDelay: types.DurationProto(wait)})
+}
+
+// RetryAfter returns the delay after which a request rejected by the global
+// rate limit may be retried, and false if the error does not carry one.
+func RetryAfter(err error) (time.Duration, bool) {
+	details, derr := yarpcerrors.Details(err)
+	if derr != nil {
+		return 0, false
+	}
+	for _, detail := range details {
+		info, ok := detail.(*rpc.RetryInfo)
+		if !ok || info.RetryDelay == nil {
+			continue
+		}
+		if d, err := types.DurationFromProto(info.RetryDelay); err == nil {
+			return d, true
+		}
+	}
+	return 0, false
+}
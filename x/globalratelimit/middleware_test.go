@@ -0,0 +1,169 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package globalratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// countingOutbound counts the requests it was called with.
+type countingOutbound struct {
+	transport.UnaryOutbound
+
+	calls int
+}
+
+func (o *countingOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	o.calls++
+	return &transport.Response{}, nil
+}
+
+func newRequest(service string) *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   service,
+		Procedure: "procedure",
+		Encoding:  "raw",
+	}
+}
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	server, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	server.SetTime(time.Unix(1000, 0))
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+	server, client := newRedis(t)
+	out := &countingOutbound{}
+	// Two clients share the budget.
+	first := NewOutboundMiddleware(client, "ratelimit", 10)
+	second := NewOutboundMiddleware(client, "ratelimit", 10)
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		_, err := first.Call(ctx, newRequest("service"), out)
+		require.NoError(t, err)
+		_, err = second.Call(ctx, newRequest("service"), out)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 10, out.calls)
+
+	_, err := first.Call(ctx, newRequest("service"), out)
+	require.Error(t, err)
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+	assert.Contains(t, err.Error(), `global rate limit exceeded for service "service"`)
+	retryAfter, ok := RetryAfter(err)
+	require.True(t, ok, "expected a retry delay")
+	assert.Equal(t, 100*time.Millisecond, retryAfter)
+	assert.Equal(t, 10, out.calls, "rejected requests must not be sent")
+
+	// Other services have their own budget.
+	_, err = first.Call(ctx, newRequest("other-service"), out)
+	require.NoError(t, err)
+
+	// The bucket refills at the given rate.
+	server.SetTime(time.Unix(1000, 0).Add(250 * time.Millisecond))
+	for i := 0; i < 2; i++ {
+		_, err = second.Call(ctx, newRequest("service"), out)
+		require.NoError(t, err)
+	}
+	_, err = second.Call(ctx, newRequest("service"), out)
+	require.Error(t, err)
+	retryAfter, ok = RetryAfter(err)
+	require.True(t, ok, "expected a retry delay")
+	assert.Equal(t, 50*time.Millisecond, retryAfter)
+
+	assert.True(t, server.Exists("ratelimit:service"))
+	assert.True(t, server.TTL("ratelimit:service") > 0, "expected the bucket to expire")
+}
+
+func TestGlobalRateLimitBurst(t *testing.T) {
+	_, client := newRedis(t)
+	out := &countingOutbound{}
+	mw := NewOutboundMiddleware(client, "ratelimit", 100, Burst(3))
+
+	var rejected int
+	for i := 0; i < 5; i++ {
+		if _, err := mw.Call(context.Background(), newRequest("service"), out); err != nil {
+			rejected++
+		}
+	}
+	assert.Equal(t, 3, out.calls)
+	assert.Equal(t, 2, rejected)
+}
+
+func TestGlobalRateLimitDisabled(t *testing.T) {
+	out := &countingOutbound{}
+	mw := NewOutboundMiddleware(nil, "ratelimit", 0)
+	_, err := mw.Call(context.Background(), newRequest("service"), out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, out.calls)
+}
+
+func TestGlobalRateLimitFailOpen(t *testing.T) {
+	server, client := newRedis(t)
+	server.Close()
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	out := &countingOutbound{}
+	mw := NewOutboundMiddleware(client, "ratelimit", 1, Logger(zap.New(core)))
+
+	for i := 0; i < 3; i++ {
+		_, err := mw.Call(context.Background(), newRequest("service"), out)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, out.calls, "expected requests to be allowed without Redis")
+
+	entries := logs.FilterMessage("allowing request without global rate limit: failed to reach Redis").AllUntimed()
+	require.Len(t, entries, 3)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "ratelimit:service", fields["key"])
+	assert.NotEmpty(t, fields["error"])
+}
+
+func TestRetryAfter(t *testing.T) {
+	_, ok := RetryAfter(nil)
+	assert.False(t, ok)
+	_, ok = RetryAfter(yarpcerrors.ResourceExhaustedErrorf("busy"))
+	assert.False(t, ok)
+
+	d, ok := RetryAfter(newRateLimitError("service", time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, d)
+}
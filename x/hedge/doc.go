@@ -0,0 +1,45 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package hedge provides outbound middleware that hedges unary calls,
+// sending another attempt of a call that has not finished within a latency
+// threshold, and taking whichever attempt succeeds first.
+//
+// Hedging trades load for tail latency: every hedge is an extra request to
+// the service. Limit hedging to idempotent procedures, since several
+// attempts of a call may reach the service.
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:      "myservice",
+// 		Outbounds: outbounds,
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary: hedge.NewUnaryOutboundMiddleware(
+// 				hedge.PercentileDelay(95, 1000),
+// 				hedge.Hedgeable(func(req *transport.Request) bool {
+// 					return strings.HasPrefix(req.Procedure, "Store::get")
+// 				}),
+// 			),
+// 		},
+// 	})
+//
+// The attempts of a call ask the peer list to choose a different peer for
+// each of them, with peer.WithDistinctPeers. Peer lists built on the
+// abstractlist package honor the hint.
+package hedge
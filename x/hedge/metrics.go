@@ -0,0 +1,103 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/zap"
+)
+
+const (
+	_serviceTag   = "service"
+	_procedureTag = "procedure"
+	_winnerTag    = "winner"
+
+	_original = "original"
+	_hedge    = "hedge"
+)
+
+type hedgeMetrics struct {
+	calls  *metrics.CounterVector
+	hedges *metrics.CounterVector
+	wins   *metrics.CounterVector
+}
+
+func newHedgeMetrics(meter *metrics.Scope, logger *zap.Logger) *hedgeMetrics {
+	tags := []string{_serviceTag, _procedureTag}
+
+	calls, err := meter.CounterVector(metrics.Spec{
+		Name:    "hedge_calls",
+		Help:    "Total number of calls that may be hedged.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create hedge calls counter", zap.Error(err))
+	}
+	hedges, err := meter.CounterVector(metrics.Spec{
+		Name:    "hedge_hedges",
+		Help:    "Total number of attempts sent because earlier attempts were slow.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create hedges counter", zap.Error(err))
+	}
+	wins, err := meter.CounterVector(metrics.Spec{
+		Name:    "hedge_wins",
+		Help:    "Total number of calls that succeeded, by whether the original attempt or a hedge won.",
+		VarTags: append(tags, _winnerTag),
+	})
+	if err != nil {
+		logger.Error("failed to create hedge wins counter", zap.Error(err))
+	}
+
+	return &hedgeMetrics{
+		calls:  calls,
+		hedges: hedges,
+		wins:   wins,
+	}
+}
+
+// edgeMetrics are the counters of a service and procedure.
+type edgeMetrics struct {
+	calls        *metrics.Counter
+	hedges       *metrics.Counter
+	originalWins *metrics.Counter
+	hedgeWins    *metrics.Counter
+}
+
+func (m *hedgeMetrics) edge(req *transport.Request) *edgeMetrics {
+	return &edgeMetrics{
+		calls:        m.calls.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+		hedges:       m.hedges.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+		originalWins: m.wins.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure, _winnerTag, _original),
+		hedgeWins:    m.wins.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure, _winnerTag, _hedge),
+	}
+}
+
+// win records the attempt that won a call.
+func (e *edgeMetrics) win(attempt int) {
+	if attempt == 0 {
+		e.originalWins.Inc()
+	} else {
+		e.hedgeWins.Inc()
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/cancelbody"
+	"go.uber.org/zap"
+)
+
+const (
+	_defaultDelay     = 50 * time.Millisecond
+	_defaultMaxHedges = 1
+)
+
+// Option customizes the behavior of the hedging middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	delay      time.Duration
+	percentile float64
+	windowSize int
+	maxHedges  int
+	hedgeable  func(*transport.Request) bool
+	meter      *metrics.Scope
+	logger     *zap.Logger
+}
+
+// Delay is how long to wait for an attempt of a call before sending the
+// next.
+//
+// With PercentileDelay, this is the delay until the latency window of the
+// procedure fills up.
+//
+// Defaults to 50ms.
+func Delay(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d > 0 {
+			o.delay = d
+		}
+	})
+}
+
+// PercentileDelay derives the delay of the calls to each procedure from the
+// given percentile, between 0 and 100, of the latencies of the last
+// windowSize successful calls to the procedure.
+//
+// Hedging at the 95th percentile, for example, hedges about one call in
+// twenty.
+// Invalid values are ignored.
+func PercentileDelay(percentile float64, windowSize int) Option {
+	return optionFunc(func(o *options) {
+		if percentile > 0 && percentile <= 100 && windowSize > 0 {
+			o.percentile = percentile
+			o.windowSize = windowSize
+		}
+	})
+}
+
+// MaxHedges is the maximum number of attempts of a call in addition to the
+// first. Zero disables hedging.
+//
+// Defaults to 1.
+func MaxHedges(n int) Option {
+	return optionFunc(func(o *options) {
+		if n >= 0 {
+			o.maxHedges = n
+		}
+	})
+}
+
+// Hedgeable limits hedging to the requests for which f returns true, like
+// the requests for idempotent procedures.
+//
+// Defaults to hedging every request.
+func Hedgeable(f func(*transport.Request) bool) Option {
+	return optionFunc(func(o *options) {
+		o.hedgeable = f
+	})
+}
+
+// Meter sets the scope for the metrics of the middleware.
+func Meter(meter *metrics.Scope) Option {
+	return optionFunc(func(o *options) {
+		o.meter = meter
+	})
+}
+
+// Logger sets the logger for the middleware.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+type procedureKey struct {
+	service   string
+	procedure string
+}
+
+var _ middleware.UnaryOutbound = (*Middleware)(nil)
+
+// Middleware is a unary outbound middleware that hedges calls.
+//
+// The middleware buffers the body of each request it may hedge, so every
+// attempt can read it.
+type Middleware struct {
+	opts    options
+	metrics *hedgeMetrics
+
+	lock    sync.Mutex
+	windows map[procedureKey]*latencyWindow
+}
+
+// NewUnaryOutboundMiddleware returns a unary outbound middleware that hedges
+// calls.
+func NewUnaryOutboundMiddleware(opts ...Option) *Middleware {
+	o := options{
+		delay:     _defaultDelay,
+		maxHedges: _defaultMaxHedges,
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.logger == nil {
+		o.logger = zap.NewNop()
+	}
+
+	return &Middleware{
+		opts:    o,
+		metrics: newHedgeMetrics(o.meter, o.logger),
+		windows: make(map[procedureKey]*latencyWindow),
+	}
+}
+
+// window returns the latency window of the procedure of the request, or nil
+// if the delay is static.
+func (m *Middleware) window(req *transport.Request) *latencyWindow {
+	if m.opts.windowSize == 0 {
+		return nil
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	k := procedureKey{req.Service, req.Procedure}
+	w, ok := m.windows[k]
+	if !ok {
+		w = newLatencyWindow(m.opts.percentile, m.opts.windowSize)
+		m.windows[k] = w
+	}
+	return w
+}
+
+type attemptResult struct {
+	attempt int
+	res     *transport.Response
+	err     error
+	latency time.Duration
+}
+
+// discard releases the response of an attempt that lost.
+func (r attemptResult) discard() {
+	if r.res != nil && r.res.Body != nil {
+		r.res.Body.Close()
+	}
+}
+
+// Call sends the request, and another attempt of it every time the delay
+// elapses without an attempt finishing, up to the maximum number of hedges.
+//
+// The first attempt to succeed wins, and the others are canceled. If every
+// attempt that was sent fails, the call returns the result of the last to
+// fail.
+func (m *Middleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	if m.opts.maxHedges == 0 || (m.opts.hedgeable != nil && !m.opts.hedgeable(req)) {
+		return out.Call(ctx, req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	edge := m.metrics.edge(req)
+	edge.calls.Inc()
+	window := m.window(req)
+	delay := m.opts.delay
+	if d, ok := window.delay(); ok {
+		delay = d
+	}
+
+	ctx = peer.WithDistinctPeers(ctx)
+	results := make(chan attemptResult)
+	done := make(chan struct{})
+	defer close(done)
+
+	var cancels []context.CancelFunc
+	send := func() {
+		attempt := len(cancels)
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+
+		attemptReq := *req
+		if req.Body != nil {
+			attemptReq.Body = bytes.NewReader(body)
+		}
+		start := time.Now()
+		go func() {
+			res, err := out.Call(attemptCtx, &attemptReq)
+			r := attemptResult{attempt: attempt, res: res, err: err, latency: time.Since(start)}
+			select {
+			case results <- r:
+			case <-done:
+				r.discard()
+			}
+		}()
+	}
+
+	send()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for pending := 1; ; {
+		select {
+		case <-timer.C:
+			send()
+			pending++
+			edge.hedges.Inc()
+			if len(cancels) <= m.opts.maxHedges {
+				timer.Reset(delay)
+			}
+
+		case r := <-results:
+			pending--
+			if r.err != nil && pending > 0 {
+				r.discard()
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != r.attempt {
+					cancel()
+				}
+			}
+			if r.err == nil {
+				window.record(r.latency)
+				edge.win(r.attempt)
+			}
+			// The context of the attempt that won is canceled once the
+			// caller closes the body of its response.
+			return cancelbody.Wrap(r.res, cancels[r.attempt]), r.err
+		}
+	}
+}
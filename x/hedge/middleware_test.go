@@ -0,0 +1,264 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// attemptFunc is the behavior of one attempt of a call.
+type attemptFunc func(ctx context.Context, body string) (*transport.Response, error)
+
+// slow blocks until the attempt is canceled, and reports it.
+func slow(canceled chan<- struct{}) attemptFunc {
+	return func(ctx context.Context, _ string) (*transport.Response, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}
+}
+
+// fast echoes the body of the attempt.
+func fast(ctx context.Context, body string) (*transport.Response, error) {
+	return &transport.Response{Body: ioutil.NopCloser(bytes.NewReader([]byte(body)))}, nil
+}
+
+func fail(ctx context.Context, _ string) (*transport.Response, error) {
+	return nil, yarpcerrors.UnavailableErrorf("unavailable")
+}
+
+// fakeOutbound runs the behavior of each successive attempt, recording the
+// distinct peers of their contexts.
+type fakeOutbound struct {
+	transport.UnaryOutbound
+
+	mu       sync.Mutex
+	attempts []attemptFunc
+	calls    int
+	distinct []*peer.DistinctPeers
+}
+
+func (o *fakeOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	attempt := o.attempts[o.calls]
+	o.calls++
+	o.distinct = append(o.distinct, peer.DistinctPeersFromContext(ctx))
+	o.mu.Unlock()
+
+	return attempt(ctx, string(body))
+}
+
+func (o *fakeOutbound) numCalls() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.calls
+}
+
+func newRequest() *transport.Request {
+	return &transport.Request{
+		Service:   "service",
+		Procedure: "procedure",
+		Body:      bytes.NewReader([]byte("body")),
+	}
+}
+
+func counters(root *metrics.Root) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, c := range root.Snapshot().Counters {
+		name := c.Name
+		if winner, ok := c.Tags[_winnerTag]; ok {
+			name += ":" + winner
+		}
+		counts[name] += c.Value
+	}
+	return counts
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	select {
+	case <-ch:
+	case <-time.After(testtime.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestHedgeWins(t *testing.T) {
+	root := metrics.New()
+	mw := NewUnaryOutboundMiddleware(Delay(time.Millisecond), Meter(root.Scope()))
+	canceled := make(chan struct{})
+	out := &fakeOutbound{attempts: []attemptFunc{slow(canceled), fast}}
+
+	res, err := mw.Call(context.Background(), newRequest(), out)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "body", string(body), "expected the hedge to read the whole body")
+	require.NoError(t, res.Body.Close())
+
+	waitFor(t, canceled, "the original attempt to be canceled")
+	assert.Equal(t, 2, out.numCalls())
+
+	require.Len(t, out.distinct, 2)
+	require.NotNil(t, out.distinct[0], "expected attempts to ask for distinct peers")
+	assert.True(t, out.distinct[0] == out.distinct[1], "expected attempts to share distinct peers")
+
+	assert.Equal(t, map[string]int64{
+		"hedge_calls":         1,
+		"hedge_hedges":        1,
+		"hedge_wins:original": 0,
+		"hedge_wins:hedge":    1,
+	}, counters(root))
+}
+
+func TestOriginalWins(t *testing.T) {
+	root := metrics.New()
+	mw := NewUnaryOutboundMiddleware(Delay(testtime.Second), Meter(root.Scope()))
+	out := &fakeOutbound{attempts: []attemptFunc{fast}}
+
+	_, err := mw.Call(context.Background(), newRequest(), out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, out.numCalls(), "expected no hedge")
+
+	assert.Equal(t, map[string]int64{
+		"hedge_calls":         1,
+		"hedge_hedges":        0,
+		"hedge_wins:original": 1,
+		"hedge_wins:hedge":    0,
+	}, counters(root))
+}
+
+func TestMaxHedges(t *testing.T) {
+	mw := NewUnaryOutboundMiddleware(Delay(time.Millisecond), MaxHedges(2))
+	first, second := make(chan struct{}), make(chan struct{})
+	out := &fakeOutbound{attempts: []attemptFunc{slow(first), slow(second), fast}}
+
+	_, err := mw.Call(context.Background(), newRequest(), out)
+	require.NoError(t, err)
+	waitFor(t, first, "the original attempt to be canceled")
+	waitFor(t, second, "the first hedge to be canceled")
+	assert.Equal(t, 3, out.numCalls())
+}
+
+func TestFailedAttempts(t *testing.T) {
+	t.Run("waits for pending attempts", func(t *testing.T) {
+		mw := NewUnaryOutboundMiddleware(Delay(time.Millisecond))
+		release := make(chan struct{})
+		out := &fakeOutbound{attempts: []attemptFunc{
+			func(ctx context.Context, body string) (*transport.Response, error) {
+				<-release
+				return fast(ctx, body)
+			},
+			func(ctx context.Context, body string) (*transport.Response, error) {
+				defer close(release)
+				return fail(ctx, body)
+			},
+		}}
+
+		_, err := mw.Call(context.Background(), newRequest(), out)
+		assert.NoError(t, err, "expected the original attempt to win after the hedge failed")
+	})
+
+	t.Run("all attempts fail", func(t *testing.T) {
+		mw := NewUnaryOutboundMiddleware(Delay(testtime.Second))
+		out := &fakeOutbound{attempts: []attemptFunc{fail}}
+
+		_, err := mw.Call(context.Background(), newRequest(), out)
+		assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code())
+		assert.Equal(t, 1, out.numCalls(), "expected a failure not to be hedged")
+	})
+}
+
+func TestNotHedgeable(t *testing.T) {
+	root := metrics.New()
+	mw := NewUnaryOutboundMiddleware(
+		Delay(time.Millisecond),
+		Meter(root.Scope()),
+		Hedgeable(func(req *transport.Request) bool { return req.Procedure == "get" }),
+	)
+	out := &fakeOutbound{attempts: []attemptFunc{
+		func(ctx context.Context, body string) (*transport.Response, error) {
+			time.Sleep(10 * time.Millisecond)
+			return fast(ctx, body)
+		},
+	}}
+
+	_, err := mw.Call(context.Background(), newRequest(), out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, out.numCalls())
+	assert.Nil(t, out.distinct[0], "expected the request to pass through")
+	assert.Empty(t, counters(root))
+}
+
+func TestPercentileDelay(t *testing.T) {
+	mw := NewUnaryOutboundMiddleware(Delay(testtime.Second), PercentileDelay(50, 2))
+	req := newRequest()
+	w := mw.window(req)
+	require.NotNil(t, w)
+
+	_, ok := w.delay()
+	assert.False(t, ok, "expected no delay until the window fills")
+
+	out := &fakeOutbound{attempts: []attemptFunc{fast, fast}}
+	for i := 0; i < 2; i++ {
+		_, err := mw.Call(context.Background(), newRequest(), out)
+		require.NoError(t, err)
+	}
+	d, ok := w.delay()
+	require.True(t, ok, "expected a delay once the window filled")
+	assert.True(t, d < testtime.Second, "expected the delay to follow the latencies, got %v", d)
+}
+
+func TestLatencyWindow(t *testing.T) {
+	w := newLatencyWindow(90, 10)
+	for i := 1; i <= 10; i++ {
+		w.record(time.Duration(i) * time.Millisecond)
+	}
+	d, ok := w.delay()
+	require.True(t, ok)
+	assert.Equal(t, 9*time.Millisecond, d)
+
+	// The percentile is computed again once enough samples replace the
+	// oldest.
+	w.record(100 * time.Millisecond)
+	d, _ = w.delay()
+	assert.Equal(t, 9*time.Millisecond, d, "expected the cached percentile")
+	w.record(100 * time.Millisecond)
+	d, _ = w.delay()
+	assert.Equal(t, 100*time.Millisecond, d)
+}
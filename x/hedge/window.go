@@ -0,0 +1,108 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package hedge
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyWindow tracks a percentile of the latencies of the last calls to a
+// procedure.
+//
+// The methods of a nil latencyWindow do nothing.
+type latencyWindow struct {
+	percentile float64
+
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+
+	// The percentile is computed again once every recomputeEvery samples,
+	// so that calls do not sort the window each time.
+	recomputeEvery int
+	stale          int
+	computed       bool
+	value          time.Duration
+}
+
+func newLatencyWindow(percentile float64, size int) *latencyWindow {
+	return &latencyWindow{
+		percentile:     percentile,
+		samples:        make([]time.Duration, size),
+		recomputeEvery: size/10 + 1,
+	}
+}
+
+// record adds the latency of a call to the window, replacing the oldest
+// once the window is full.
+func (w *latencyWindow) record(d time.Duration) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.samples[w.next] = d
+	w.next++
+	if w.next == len(w.samples) {
+		w.next = 0
+		w.full = true
+	}
+	w.stale++
+}
+
+// delay returns the percentile of the latencies in the window, or false
+// until the window is full.
+func (w *latencyWindow) delay() (time.Duration, bool) {
+	if w == nil {
+		return 0, false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.full {
+		return 0, false
+	}
+	if !w.computed || w.stale >= w.recomputeEvery {
+		w.value = percentileOf(w.samples, w.percentile)
+		w.computed = true
+		w.stale = 0
+	}
+	return w.value, true
+}
+
+func percentileOf(samples []time.Duration, percentile float64) time.Duration {
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package idempotency provides inbound middleware that enforces the
+// uniqueness of idempotency keys: a client may only reuse an idempotency key
+// to retry the very same request.
+//
+// Clients attach an idempotency key to a request with the Idempotency-Key
+// header. The middleware records a hash of the request under the key, scoped
+// to the calling service, and rejects later requests from the same caller
+// that carry the key with a different request with an AlreadyExists error:
+//
+//	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+//		Name: "myservice",
+//		InboundMiddleware: yarpc.InboundMiddleware{
+//			Unary: idempotency.NewStrictInboundMiddleware(
+//				idempotency.NewMemoryStore(),
+//				idempotency.TTL(time.Hour),
+//			),
+//		},
+//	})
+//
+// The hash covers the service, procedure, encoding and body of the request.
+// Requests without an idempotency key are passed to the handler unchecked.
+//
+// The middleware does not deduplicate requests: retries with the same key and
+// request are still handled. Use an IdempotencyStore shared by all instances
+// of a service to enforce keys across them.
+package idempotency
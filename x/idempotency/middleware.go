@@ -0,0 +1,151 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	_defaultHeader = "Idempotency-Key"
+	_defaultTTL    = 24 * time.Hour
+)
+
+// Option customizes the behavior of the idempotency middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	header string
+	ttl    time.Duration
+}
+
+// Header sets the name of the request header carrying idempotency keys.
+//
+// Defaults to Idempotency-Key.
+func Header(name string) Option {
+	return optionFunc(func(o *options) {
+		if name != "" {
+			o.header = name
+		}
+	})
+}
+
+// TTL sets how long the middleware remembers the request of an idempotency
+// key after its first use. Clients may reuse a key for another request once
+// it elapses.
+//
+// Defaults to 24 hours.
+func TTL(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d > 0 {
+			o.ttl = d
+		}
+	})
+}
+
+type strictInboundMiddleware struct {
+	store IdempotencyStore
+	opts  options
+}
+
+// NewStrictInboundMiddleware builds unary inbound middleware that rejects
+// requests reusing the idempotency key of a different request from the same
+// caller with an AlreadyExists error.
+//
+// The hash of every request with an idempotency key is stored in the given
+// store, under the key and the name of the caller.
+func NewStrictInboundMiddleware(store IdempotencyStore, opts ...Option) middleware.UnaryInbound {
+	o := options{
+		header: _defaultHeader,
+		ttl:    _defaultTTL,
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return &strictInboundMiddleware{store: store, opts: o}
+}
+
+func (m *strictInboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	key, ok := req.Headers.Get(m.opts.header)
+	if !ok || key == "" {
+		return h.Handle(ctx, req, resw)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return err
+		}
+	}
+	// The handler must still be able to read the body.
+	req.Body = bytes.NewReader(body)
+
+	conflict, err := m.store.CompareAndStore(storeKey(req.Caller, key), requestHash(req, body), m.opts.ttl)
+	if err != nil {
+		return yarpcerrors.UnavailableErrorf("failed to check idempotency key %q: %v", key, err)
+	}
+	if conflict {
+		return yarpcerrors.AlreadyExistsErrorf("idempotency key %q was already used for a different request", key)
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+// storeKey scopes the idempotency key to the caller. The caller is prefixed
+// with its length so that no two pairs of caller and key collide.
+func storeKey(caller, key string) string {
+	return strconv.Itoa(len(caller)) + ":" + caller + ":" + key
+}
+
+// requestHash hashes the parts of a request that a retry must not change.
+func requestHash(req *transport.Request, body []byte) string {
+	h := sha256.New()
+	for _, s := range []string{req.Service, req.Procedure, string(req.Encoding)} {
+		writeBytes(h, []byte(s))
+	}
+	writeBytes(h, body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeBytes writes the length-prefixed bytes to the hash.
+func writeBytes(h hash.Hash, b []byte) {
+	var n [binary.MaxVarintLen64]byte
+	h.Write(n[:binary.PutUvarint(n[:], uint64(len(b)))])
+	h.Write(b)
+}
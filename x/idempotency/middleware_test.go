@@ -0,0 +1,181 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+type countingHandler struct {
+	calls int
+}
+
+func (h *countingHandler) Handle(_ context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	h.calls++
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	_, err = resw.Write(body)
+	return err
+}
+
+type request struct {
+	caller    string
+	procedure string
+	key       string
+	body      string
+}
+
+func (r request) call(handler transport.UnaryHandler) (*transporttest.FakeResponseWriter, error) {
+	headers := transport.NewHeaders()
+	if r.key != "" {
+		headers = headers.With("Idempotency-Key", r.key)
+	}
+	procedure := r.procedure
+	if procedure == "" {
+		procedure = "procedure"
+	}
+	resw := &transporttest.FakeResponseWriter{}
+	err := handler.Handle(context.Background(), &transport.Request{
+		Caller:    r.caller,
+		Service:   "service",
+		Procedure: procedure,
+		Encoding:  raw.Encoding,
+		Headers:   headers,
+		Body:      bytes.NewReader([]byte(r.body)),
+	}, resw)
+	return resw, err
+}
+
+func TestStrictInboundMiddleware(t *testing.T) {
+	tests := []struct {
+		msg          string
+		first        request
+		second       request
+		wantConflict bool
+	}{
+		{
+			msg:    "same request",
+			first:  request{caller: "client", key: "k", body: "hello"},
+			second: request{caller: "client", key: "k", body: "hello"},
+		},
+		{
+			msg:          "different body",
+			first:        request{caller: "client", key: "k", body: "hello"},
+			second:       request{caller: "client", key: "k", body: "goodbye"},
+			wantConflict: true,
+		},
+		{
+			msg:          "different procedure",
+			first:        request{caller: "client", key: "k", body: "hello"},
+			second:       request{caller: "client", procedure: "other", key: "k", body: "hello"},
+			wantConflict: true,
+		},
+		{
+			msg:    "different caller",
+			first:  request{caller: "client", key: "k", body: "hello"},
+			second: request{caller: "other", key: "k", body: "goodbye"},
+		},
+		{
+			msg:    "different key",
+			first:  request{caller: "client", key: "k", body: "hello"},
+			second: request{caller: "client", key: "l", body: "goodbye"},
+		},
+		{
+			msg:    "without key",
+			first:  request{caller: "client", body: "hello"},
+			second: request{caller: "client", body: "goodbye"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			h := &countingHandler{}
+			handler := middleware.ApplyUnaryInbound(h, NewStrictInboundMiddleware(NewMemoryStore()))
+
+			resw, err := tt.first.call(handler)
+			require.NoError(t, err)
+			assert.Equal(t, tt.first.body, resw.Body.String(), "handler must read the request body")
+
+			resw, err = tt.second.call(handler)
+			if tt.wantConflict {
+				assert.True(t, yarpcerrors.IsAlreadyExists(err), "expected AlreadyExists error, got %v", err)
+				assert.Equal(t, 1, h.calls, "handler must not be called on a conflict")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.second.body, resw.Body.String())
+			assert.Equal(t, 2, h.calls)
+		})
+	}
+}
+
+func TestStrictInboundMiddlewareOptions(t *testing.T) {
+	now := time.Now()
+	store := newMemoryStore(func() time.Time { return now })
+	h := &countingHandler{}
+	handler := middleware.ApplyUnaryInbound(h, NewStrictInboundMiddleware(store, Header("Request-Id"), TTL(time.Minute)))
+
+	call := func(body string) error {
+		return handler.Handle(context.Background(), &transport.Request{
+			Service:   "service",
+			Procedure: "procedure",
+			Headers:   transport.NewHeaders().With("request-id", "k"),
+			Body:      bytes.NewReader([]byte(body)),
+		}, &transporttest.FakeResponseWriter{})
+	}
+
+	require.NoError(t, call("hello"))
+	assert.True(t, yarpcerrors.IsAlreadyExists(call("goodbye")), "keys must be read from the custom header")
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, call("goodbye"), "keys must be reusable after the TTL")
+}
+
+type failingStore struct{}
+
+func (failingStore) CompareAndStore(string, string, time.Duration) (bool, error) {
+	return false, errors.New("great sadness")
+}
+
+func TestStrictInboundMiddlewareStoreError(t *testing.T) {
+	h := &countingHandler{}
+	handler := middleware.ApplyUnaryInbound(h, NewStrictInboundMiddleware(failingStore{}))
+
+	_, err := request{key: "k", body: "hello"}.call(handler)
+	assert.True(t, yarpcerrors.IsUnavailable(err), "expected Unavailable error, got %v", err)
+	assert.Contains(t, err.Error(), "great sadness")
+	assert.Zero(t, h.calls, "handler must not be called when the store fails")
+}
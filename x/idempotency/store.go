@@ -0,0 +1,105 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package idempotency
+
+import (
+	"sync"
+	"time"
+)
+
+// _minSweepSize is the number of entries below which the memory store does
+// not bother removing expired entries.
+const _minSweepSize = 64
+
+// IdempotencyStore records the request hash of every idempotency key.
+//
+// Implementations MUST be safe for concurrent use.
+type IdempotencyStore interface {
+	// CompareAndStore atomically compares the given hash with the hash stored
+	// for the given key. It reports a conflict if they differ. Otherwise, if
+	// no hash is stored for the key, it stores the given hash for the TTL.
+	CompareAndStore(key, hash string, ttl time.Duration) (conflict bool, err error)
+}
+
+// NewMemoryStore builds an in-memory IdempotencyStore.
+//
+// Expired keys are removed as the store grows, so the store holds about as
+// many keys as are used within a TTL.
+func NewMemoryStore() IdempotencyStore {
+	return newMemoryStore(time.Now)
+}
+
+type memoryStore struct {
+	now func() time.Time
+
+	lock    sync.Mutex
+	entries map[string]memoryEntry
+	// sweepAt is the number of entries at which expired entries are next
+	// removed.
+	sweepAt int
+}
+
+type memoryEntry struct {
+	hash    string
+	expires time.Time
+}
+
+func newMemoryStore(now func() time.Time) *memoryStore {
+	return &memoryStore{
+		now:     now,
+		entries: make(map[string]memoryEntry),
+		sweepAt: _minSweepSize,
+	}
+}
+
+func (s *memoryStore) CompareAndStore(key, hash string, ttl time.Duration) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expires) {
+		return entry.hash != hash, nil
+	}
+	if ttl <= 0 {
+		delete(s.entries, key)
+		return false, nil
+	}
+
+	s.entries[key] = memoryEntry{hash: hash, expires: now.Add(ttl)}
+	if len(s.entries) >= s.sweepAt {
+		s.sweep(now)
+	}
+	return false, nil
+}
+
+// sweep removes expired entries, and defers the next sweep until the store
+// doubles in size. The lock must be held.
+func (s *memoryStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+	s.sweepAt = 2 * len(s.entries)
+	if s.sweepAt < _minSweepSize {
+		s.sweepAt = _minSweepSize
+	}
+}
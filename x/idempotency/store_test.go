@@ -0,0 +1,83 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package idempotency
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreCompareAndStore(t *testing.T) {
+	now := time.Now()
+	store := newMemoryStore(func() time.Time { return now })
+
+	conflict, err := store.CompareAndStore("key", "a", time.Second)
+	require.NoError(t, err)
+	assert.False(t, conflict, "first use of a key must not conflict")
+
+	conflict, err = store.CompareAndStore("key", "a", time.Second)
+	require.NoError(t, err)
+	assert.False(t, conflict, "reuse with the same hash must not conflict")
+
+	conflict, err = store.CompareAndStore("key", "b", time.Second)
+	require.NoError(t, err)
+	assert.True(t, conflict, "reuse with a different hash must conflict")
+
+	now = now.Add(time.Second)
+	conflict, err = store.CompareAndStore("key", "b", time.Second)
+	require.NoError(t, err)
+	assert.False(t, conflict, "expired keys must not conflict")
+
+	conflict, err = store.CompareAndStore("key", "a", time.Second)
+	require.NoError(t, err)
+	assert.True(t, conflict, "the hash of an expired key must be replaced")
+}
+
+func TestMemoryStoreWithoutTTL(t *testing.T) {
+	store := newMemoryStore(time.Now)
+
+	for _, hash := range []string{"a", "b"} {
+		conflict, err := store.CompareAndStore("key", hash, 0)
+		require.NoError(t, err)
+		assert.False(t, conflict)
+	}
+	assert.Empty(t, store.entries, "keys without a TTL must not be stored")
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	now := time.Now()
+	store := newMemoryStore(func() time.Time { return now })
+
+	for i := 0; i < _minSweepSize-1; i++ {
+		_, err := store.CompareAndStore(fmt.Sprint(i), "hash", time.Second)
+		require.NoError(t, err)
+	}
+	now = now.Add(time.Second)
+	_, err := store.CompareAndStore("last", "hash", time.Minute)
+	require.NoError(t, err)
+
+	assert.Len(t, store.entries, 1, "expired entries must be removed")
+	assert.Equal(t, _minSweepSize, store.sweepAt)
+}
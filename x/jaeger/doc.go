@@ -0,0 +1,45 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package jaeger provides middleware that propagates Jaeger baggage sent in
+// jaeger-baggage headers, the format that Jaeger clients accept baggage in
+// from callers that are not traced, like curl or load tests.
+//
+// The inbound middleware reads the jaeger-baggage header of requests, adds
+// its items to the baggage of the span of the request, and to the headers of
+// the request. The outbound middleware writes the baggage of the span of the
+// context of calls into their jaeger-baggage header.
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:     "myservice",
+// 		Inbounds: inbounds,
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: jaeger.NewBaggagePropagationMiddleware(),
+// 		},
+// 		Outbounds: outbounds,
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary: jaeger.NewBaggageInjectionMiddleware(),
+// 		},
+// 	})
+//
+// The header holds comma-separated key=value pairs, as in
+// "jaeger-baggage: user=alice, tenant=acme". Keys and values that contain
+// commas or equal signs cannot be represented.
+package jaeger
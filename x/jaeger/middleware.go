@@ -0,0 +1,179 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jaeger
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+)
+
+// _baggageHeader is the header that Jaeger clients accept baggage in.
+const _baggageHeader = "jaeger-baggage"
+
+var (
+	_ middleware.UnaryInbound  = (*BaggagePropagationMiddleware)(nil)
+	_ middleware.OnewayInbound = (*BaggagePropagationMiddleware)(nil)
+)
+
+// BaggagePropagationMiddleware is an inbound middleware that propagates the
+// baggage of jaeger-baggage headers.
+type BaggagePropagationMiddleware struct{}
+
+// NewBaggagePropagationMiddleware returns an inbound middleware that reads
+// the jaeger-baggage header of each request, and adds its items to the
+// baggage of the span in the context of the request, if any, and to the
+// headers of the request, unless the request already has a header of the
+// same name.
+func NewBaggagePropagationMiddleware() *BaggagePropagationMiddleware {
+	return &BaggagePropagationMiddleware{}
+}
+
+// Handle implements middleware.UnaryInbound.
+func (m *BaggagePropagationMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	return h.Handle(ctx, propagate(ctx, req), resw)
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (m *BaggagePropagationMiddleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	return h.HandleOneway(ctx, propagate(ctx, req))
+}
+
+// propagate adds the baggage of the jaeger-baggage header of the request to
+// the span of the context, and returns the request with the baggage merged
+// into its headers.
+func propagate(ctx context.Context, req *transport.Request) *transport.Request {
+	header, ok := req.Headers.Get(_baggageHeader)
+	if !ok || header == "" {
+		return req
+	}
+	baggage := parseBaggage(header)
+	if len(baggage) == 0 {
+		return req
+	}
+
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		for k, v := range baggage {
+			span.SetBaggageItem(k, v)
+		}
+	}
+
+	headers := transport.NewHeadersWithCapacity(req.Headers.Len() + len(baggage))
+	for k, v := range req.Headers.OriginalItems() {
+		headers = headers.With(k, v)
+	}
+	for k, v := range baggage {
+		if _, ok := headers.Get(k); !ok {
+			headers = headers.With(k, v)
+		}
+	}
+	r := *req
+	r.Headers = headers
+	return &r
+}
+
+// parseBaggage parses the value of a jaeger-baggage header the way Jaeger
+// clients do, skipping malformed items.
+func parseBaggage(header string) map[string]string {
+	header, err := url.QueryUnescape(header)
+	if err != nil {
+		return nil
+	}
+	baggage := make(map[string]string)
+	for _, item := range strings.Split(header, ",") {
+		kv := strings.Split(strings.TrimSpace(item), "=")
+		if len(kv) == 2 && kv[0] != "" {
+			baggage[kv[0]] = kv[1]
+		}
+	}
+	return baggage
+}
+
+var (
+	_ middleware.UnaryOutbound  = (*BaggageInjectionMiddleware)(nil)
+	_ middleware.OnewayOutbound = (*BaggageInjectionMiddleware)(nil)
+)
+
+// BaggageInjectionMiddleware is an outbound middleware that injects the
+// baggage of spans into jaeger-baggage headers.
+type BaggageInjectionMiddleware struct{}
+
+// NewBaggageInjectionMiddleware returns an outbound middleware that writes
+// the baggage of the span in the context of each call, if any, into the
+// jaeger-baggage header of the request, replacing the header of the caller.
+//
+// Baggage items whose keys or values contain commas or equal signs are left
+// out of the header.
+func NewBaggageInjectionMiddleware() *BaggageInjectionMiddleware {
+	return &BaggageInjectionMiddleware{}
+}
+
+// Call implements middleware.UnaryOutbound.
+func (m *BaggageInjectionMiddleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	return out.Call(ctx, inject(ctx, req))
+}
+
+// CallOneway implements middleware.OnewayOutbound.
+func (m *BaggageInjectionMiddleware) CallOneway(ctx context.Context, req *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	return out.CallOneway(ctx, inject(ctx, req))
+}
+
+// inject returns the request with the baggage of the span of the context in
+// its jaeger-baggage header.
+func inject(ctx context.Context, req *transport.Request) *transport.Request {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return req
+	}
+	header := formatBaggage(span.Context())
+	if header == "" {
+		return req
+	}
+
+	headers := transport.NewHeadersWithCapacity(req.Headers.Len() + 1)
+	for k, v := range req.Headers.OriginalItems() {
+		if transport.CanonicalizeHeaderKey(k) != _baggageHeader {
+			headers = headers.With(k, v)
+		}
+	}
+	r := *req
+	r.Headers = headers.With(_baggageHeader, header)
+	return &r
+}
+
+// formatBaggage formats the baggage of the span context as the value of a
+// jaeger-baggage header, with items sorted by key.
+func formatBaggage(sc opentracing.SpanContext) string {
+	var items []string
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		if k != "" && !strings.ContainsAny(k, ",=") && !strings.ContainsAny(v, ",=") {
+			items = append(items, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+		return true
+	})
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package jaeger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+)
+
+// requestHandler records the requests it handles.
+type requestHandler struct {
+	req *transport.Request
+}
+
+func (h *requestHandler) Handle(_ context.Context, req *transport.Request, _ transport.ResponseWriter) error {
+	h.req = req
+	return nil
+}
+
+func (h *requestHandler) HandleOneway(_ context.Context, req *transport.Request) error {
+	h.req = req
+	return nil
+}
+
+func TestBaggagePropagationMiddleware(t *testing.T) {
+	tests := []struct {
+		msg         string
+		header      string
+		wantBaggage map[string]string
+		wantHeaders map[string]string
+	}{
+		{
+			msg:         "no header",
+			wantBaggage: map[string]string{},
+			wantHeaders: map[string]string{"tenant": "acme"},
+		},
+		{
+			msg:         "baggage",
+			header:      "user=alice, tenant=other,request%20source=load+test",
+			wantBaggage: map[string]string{"user": "alice", "tenant": "other", "request source": "load test"},
+			wantHeaders: map[string]string{
+				"tenant":         "acme",
+				"user":           "alice",
+				"request source": "load test",
+				_baggageHeader:   "user=alice, tenant=other,request%20source=load+test",
+			},
+		},
+		{
+			msg:         "malformed items",
+			header:      "user=alice,broken,a=b=c,=empty",
+			wantBaggage: map[string]string{"user": "alice"},
+			wantHeaders: map[string]string{
+				"tenant":       "acme",
+				"user":         "alice",
+				_baggageHeader: "user=alice,broken,a=b=c,=empty",
+			},
+		},
+		{
+			msg:         "malformed escaping",
+			header:      "user=%zz",
+			wantBaggage: map[string]string{},
+			wantHeaders: map[string]string{"tenant": "acme", _baggageHeader: "user=%zz"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			m := NewBaggagePropagationMiddleware()
+			headers := transport.NewHeaders().With("tenant", "acme")
+			if tt.header != "" {
+				headers = headers.With(_baggageHeader, tt.header)
+			}
+			req := &transport.Request{Procedure: "get", Headers: headers}
+
+			check := func(t *testing.T, span opentracing.Span, h *requestHandler) {
+				require.NotNil(t, h.req)
+				assert.Equal(t, tt.wantHeaders, h.req.Headers.Items())
+				baggage := make(map[string]string)
+				span.Context().ForeachBaggageItem(func(k, v string) bool {
+					baggage[k] = v
+					return true
+				})
+				assert.Equal(t, tt.wantBaggage, baggage)
+			}
+
+			t.Run("unary", func(t *testing.T) {
+				span := mocktracer.New().StartSpan("get")
+				var h requestHandler
+				ctx := opentracing.ContextWithSpan(context.Background(), span)
+				require.NoError(t, m.Handle(ctx, req, &transporttest.FakeResponseWriter{}, &h))
+				check(t, span, &h)
+			})
+			t.Run("oneway", func(t *testing.T) {
+				span := mocktracer.New().StartSpan("get")
+				var h requestHandler
+				ctx := opentracing.ContextWithSpan(context.Background(), span)
+				require.NoError(t, m.HandleOneway(ctx, req, &h))
+				check(t, span, &h)
+			})
+		})
+	}
+}
+
+func TestBaggagePropagationMiddlewareWithoutSpan(t *testing.T) {
+	var h requestHandler
+	req := &transport.Request{Headers: transport.NewHeaders().With(_baggageHeader, "user=alice")}
+	require.NoError(t, NewBaggagePropagationMiddleware().Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, &h))
+	assert.Equal(t, map[string]string{"user": "alice", _baggageHeader: "user=alice"}, h.req.Headers.Items())
+}
+
+// fakeOutbound records the requests it receives.
+type fakeOutbound struct {
+	transport.Outbound
+
+	req *transport.Request
+}
+
+func (o *fakeOutbound) Call(_ context.Context, req *transport.Request) (*transport.Response, error) {
+	o.req = req
+	return &transport.Response{}, nil
+}
+
+func (o *fakeOutbound) CallOneway(_ context.Context, req *transport.Request) (transport.Ack, error) {
+	o.req = req
+	return nil, nil
+}
+
+func TestBaggageInjectionMiddleware(t *testing.T) {
+	m := NewBaggageInjectionMiddleware()
+	headers := transport.NewHeaders().With("foo", "bar").With("Jaeger-Baggage", "stale=true")
+	req := &transport.Request{Procedure: "get", Headers: headers}
+
+	span := mocktracer.New().StartSpan("get")
+	span.SetBaggageItem("user", "alice")
+	span.SetBaggageItem("request source", "load test")
+	span.SetBaggageItem("bad", "a,b")
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	want := map[string]string{"foo": "bar", _baggageHeader: "request+source=load+test,user=alice"}
+
+	t.Run("unary", func(t *testing.T) {
+		out := &fakeOutbound{}
+		_, err := m.Call(ctx, req, out)
+		require.NoError(t, err)
+		assert.Equal(t, want, out.req.Headers.Items())
+		assert.Equal(t, want, out.req.Headers.OriginalItems())
+		assert.Equal(t, "stale=true", req.Headers.OriginalItems()["Jaeger-Baggage"],
+			"request of the caller must be left alone")
+	})
+
+	t.Run("oneway", func(t *testing.T) {
+		out := &fakeOutbound{}
+		_, err := m.CallOneway(ctx, req, out)
+		require.NoError(t, err)
+		assert.Equal(t, want, out.req.Headers.Items())
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		out := &fakeOutbound{}
+		_, err := m.Call(ctx, req, out)
+		require.NoError(t, err)
+		header, _ := out.req.Headers.Get(_baggageHeader)
+		assert.Equal(t, map[string]string{"user": "alice", "request source": "load test"}, parseBaggage(header))
+	})
+
+	t.Run("no span", func(t *testing.T) {
+		out := &fakeOutbound{}
+		_, err := m.Call(context.Background(), req, out)
+		require.NoError(t, err)
+		assert.Equal(t, req, out.req)
+	})
+
+	t.Run("no baggage", func(t *testing.T) {
+		out := &fakeOutbound{}
+		ctx := opentracing.ContextWithSpan(context.Background(), mocktracer.New().StartSpan("get"))
+		_, err := m.Call(ctx, req, out)
+		require.NoError(t, err)
+		assert.Equal(t, req, out.req)
+	})
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package locale provides inbound middleware that detects the locale of
+// requests from their Accept-Language header, so that handlers need not parse
+// it themselves.
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:     "myservice",
+// 		Inbounds: inbounds,
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: locale.NewInboundMiddleware("en-US",
+// 				locale.SupportedLocales("en-US", "fr-FR", "pt-BR"),
+// 			),
+// 		},
+// 	})
+//
+// Handlers read the locale of the request from its context.
+//
+// 	func (h *handler) Greet(ctx context.Context, req *GreetRequest) (*GreetResponse, error) {
+// 		return &GreetResponse{Text: h.greetings[locale.FromContext(ctx)]}, nil
+// 	}
+//
+// Callers send the header as an application header of the request.
+//
+// 	client.Greet(ctx, req, yarpc.WithHeader("Accept-Language", "fr-CH, fr;q=0.9, en;q=0.8"))
+package locale
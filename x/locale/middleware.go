@@ -0,0 +1,163 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package locale
+
+import (
+	"context"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"golang.org/x/text/language"
+)
+
+const _acceptLanguageHeader = "accept-language"
+
+// _wildcard is the tag that the wildcard of Accept-Language headers parses
+// to.
+var _wildcard = language.Make("mul")
+
+type localeKey struct{}
+
+// FromContext returns the locale that the middleware detected for the
+// request of the context, or an empty string if the middleware did not
+// handle the request.
+func FromContext(ctx context.Context) string {
+	l, _ := ctx.Value(localeKey{}).(string)
+	return l
+}
+
+func withLocale(ctx context.Context, l string) context.Context {
+	return context.WithValue(ctx, localeKey{}, l)
+}
+
+// Option customizes the behavior of the locale middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	supported []language.Tag
+	header    string
+}
+
+// SupportedLocales limits the detected locales to the best match among the
+// given locales, which are BCP 47 language tags like "en-US". Invalid tags
+// are ignored.
+//
+// Defaults to the preferred locale of the request, whichever it is.
+func SupportedLocales(locales ...string) Option {
+	return optionFunc(func(o *options) {
+		for _, l := range locales {
+			if tag, err := language.Parse(l); err == nil {
+				o.supported = append(o.supported, tag)
+			}
+		}
+	})
+}
+
+// Header sets the name of the header to read the locale from.
+//
+// Defaults to Accept-Language.
+func Header(name string) Option {
+	return optionFunc(func(o *options) {
+		if name != "" {
+			o.header = name
+		}
+	})
+}
+
+var (
+	_ middleware.UnaryInbound  = (*Middleware)(nil)
+	_ middleware.OnewayInbound = (*Middleware)(nil)
+)
+
+// Middleware is an inbound middleware that detects the locale of requests.
+type Middleware struct {
+	defaultLocale string
+	header        string
+	supported     []language.Tag
+	matcher       language.Matcher
+}
+
+// NewInboundMiddleware returns an inbound middleware that detects the locale
+// of each request from its Accept-Language header, and stores it in the
+// context of the request for FromContext.
+//
+// The locale is normalized to the canonical form of its language tag, like
+// "en-US" for "en_us". Requests whose header is missing or invalid, or that
+// accept none of the supported locales, get the default locale.
+func NewInboundMiddleware(defaultLocale string, opts ...Option) *Middleware {
+	o := options{header: _acceptLanguageHeader}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+
+	m := &Middleware{
+		defaultLocale: defaultLocale,
+		header:        o.header,
+		supported:     o.supported,
+	}
+	if len(o.supported) > 0 {
+		m.matcher = language.NewMatcher(o.supported)
+	}
+	return m
+}
+
+// Handle implements middleware.UnaryInbound.
+func (m *Middleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	return h.Handle(withLocale(ctx, m.detect(req)), req, resw)
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	return h.HandleOneway(withLocale(ctx, m.detect(req)), req)
+}
+
+// detect returns the locale of the request.
+func (m *Middleware) detect(req *transport.Request) string {
+	header, ok := req.Headers.Get(m.header)
+	if !ok || header == "" {
+		return m.defaultLocale
+	}
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(tags) == 0 {
+		return m.defaultLocale
+	}
+
+	if m.matcher == nil {
+		// Without supported locales, the preferred locale wins, unless it is
+		// the wildcard.
+		if tags[0] == language.Und || tags[0] == _wildcard {
+			return m.defaultLocale
+		}
+		return tags[0].String()
+	}
+
+	_, index, confidence := m.matcher.Match(tags...)
+	if confidence == language.No {
+		return m.defaultLocale
+	}
+	return m.supported[index].String()
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package locale
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+)
+
+// localeHandler records the locale of the requests it handles.
+type localeHandler struct {
+	locale string
+}
+
+func (h *localeHandler) Handle(ctx context.Context, _ *transport.Request, _ transport.ResponseWriter) error {
+	h.locale = FromContext(ctx)
+	return nil
+}
+
+func (h *localeHandler) HandleOneway(ctx context.Context, _ *transport.Request) error {
+	h.locale = FromContext(ctx)
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	supported := SupportedLocales("en-US", "fr-FR", "pt-BR", "not a locale")
+
+	tests := []struct {
+		msg        string
+		opts       []Option
+		headerName string
+		header     string
+		want       string
+	}{
+		{msg: "no header", want: "en-US"},
+		{msg: "invalid header", header: "en-US;q=nope;;", want: "en-US"},
+		{msg: "preferred locale", header: "fr-CH, fr;q=0.9, en;q=0.8", want: "fr-CH"},
+		{msg: "normalized locale", header: "pt_br", want: "pt-BR"},
+		{msg: "weights", header: "de;q=0.5, es;q=0.7", want: "es"},
+		{msg: "wildcard", header: "*", want: "en-US"},
+		{msg: "supported match", opts: []Option{supported}, header: "fr-CH, fr;q=0.9, en;q=0.8", want: "fr-FR"},
+		{msg: "supported regional match", opts: []Option{supported}, header: "pt", want: "pt-BR"},
+		{msg: "supported fallback", opts: []Option{supported}, header: "de, pt;q=0.1", want: "pt-BR"},
+		{msg: "no supported match", opts: []Option{supported}, header: "de, ja;q=0.3", want: "en-US"},
+		{msg: "custom header", opts: []Option{Header("X-Locale")}, headerName: "x-locale", header: "ja-JP", want: "ja-JP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			m := NewInboundMiddleware("en-US", tt.opts...)
+
+			header := _acceptLanguageHeader
+			if tt.headerName != "" {
+				header = tt.headerName
+			}
+			req := &transport.Request{Procedure: "greet"}
+			if tt.header != "" {
+				req.Headers = transport.NewHeaders().With(header, tt.header)
+			}
+
+			var unary, oneway localeHandler
+			assert.NoError(t, m.Handle(context.Background(), req, new(transporttest.FakeResponseWriter), &unary))
+			assert.NoError(t, m.HandleOneway(context.Background(), req, &oneway))
+			assert.Equal(t, tt.want, unary.locale)
+			assert.Equal(t, tt.want, oneway.locale)
+		})
+	}
+}
+
+func TestFromContextWithoutMiddleware(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package maxdepth provides inbound middleware that limits the depth of
+// chains of RPCs, to stop requests looping between misconfigured services
+// that call each other.
+//
+// The middleware reads the call depth of every unary request from the
+// "rpc-call-depth" header, rejecting requests deeper than the limit, and
+// propagates the incremented depth to the outbound calls the handler makes
+// with the request context. The HTTP and TChannel outbounds send the depth
+// in the header of these calls.
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:     "myservice",
+// 		Inbounds: inbounds,
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: maxdepth.NewInboundMiddleware(10),
+// 		},
+// 	})
+//
+// Requests without the header, like those from the first service in a
+// chain, have a depth of zero.
+//
+// Requests deeper than the limit fail with a ResourceExhausted error. Over
+// TChannel, which black-holes that error (see the Resource Exhaustion section
+// of package go.uber.org/yarpc/transport/tchannel), the service that made the
+// call one hop too deep waits out its deadline instead.
+package maxdepth
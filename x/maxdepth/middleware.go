@@ -0,0 +1,64 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxdepth
+
+import (
+	"context"
+	"strconv"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/calldepth"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// NewInboundMiddleware returns middleware that rejects unary requests whose
+// call depth exceeds maxDepth with a ResourceExhausted error, and otherwise
+// propagates the depth of the request, plus one, to outbound calls made with
+// the context of the handler.
+//
+// Requests with a malformed call depth header are rejected with an
+// InvalidArgument error.
+func NewInboundMiddleware(maxDepth int) middleware.UnaryInbound {
+	return inboundMiddleware{maxDepth: maxDepth}
+}
+
+type inboundMiddleware struct {
+	maxDepth int
+}
+
+func (m inboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	var depth int
+	if v, ok := req.Headers.Get(calldepth.Header); ok {
+		var err error
+		depth, err = strconv.Atoi(v)
+		if err != nil || depth < 0 {
+			return yarpcerrors.InvalidArgumentErrorf(
+				"invalid %s header %q for procedure %q of service %q", calldepth.Header, v, req.Procedure, req.Service)
+		}
+	}
+	if depth > m.maxDepth {
+		return yarpcerrors.ResourceExhaustedErrorf(
+			"call depth %d for procedure %q of service %q exceeds the limit of %d, the request may be part of an RPC loop",
+			depth, req.Procedure, req.Service, m.maxDepth)
+	}
+	return h.Handle(calldepth.WithDepth(ctx, depth+1), req, resw)
+}
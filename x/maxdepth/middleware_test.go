@@ -0,0 +1,191 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxdepth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/calldepth"
+	"go.uber.org/yarpc/internal/clientconfig"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/transport/tchannel"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+type depthHandler struct {
+	depth  int
+	called bool
+}
+
+func (h *depthHandler) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	h.called = true
+	h.depth, _ = calldepth.FromContext(ctx)
+	return nil
+}
+
+func TestInboundMiddleware(t *testing.T) {
+	tests := []struct {
+		desc      string
+		headers   transport.Headers
+		wantDepth int
+		wantCode  yarpcerrors.Code
+	}{
+		{
+			desc:      "no header",
+			wantDepth: 1,
+		},
+		{
+			desc:      "below limit",
+			headers:   transport.NewHeaders().With(calldepth.Header, "2"),
+			wantDepth: 3,
+		},
+		{
+			desc:      "at limit",
+			headers:   transport.NewHeaders().With(calldepth.Header, "3"),
+			wantDepth: 4,
+		},
+		{
+			desc:     "exceeds limit",
+			headers:  transport.NewHeaders().With(calldepth.Header, "4"),
+			wantCode: yarpcerrors.CodeResourceExhausted,
+		},
+		{
+			desc:     "malformed",
+			headers:  transport.NewHeaders().With(calldepth.Header, "deep"),
+			wantCode: yarpcerrors.CodeInvalidArgument,
+		},
+		{
+			desc:     "negative",
+			headers:  transport.NewHeaders().With(calldepth.Header, "-1"),
+			wantCode: yarpcerrors.CodeInvalidArgument,
+		},
+	}
+
+	mw := NewInboundMiddleware(3)
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			h := &depthHandler{}
+			req := &transport.Request{Service: "svc", Procedure: "proc", Headers: tt.headers}
+			err := mw.Handle(context.Background(), req, nil, h)
+			if tt.wantCode != yarpcerrors.CodeOK {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantCode, yarpcerrors.FromError(err).Code())
+				assert.False(t, h.called, "handler must not be called")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDepth, h.depth)
+		})
+	}
+}
+
+func TestInboundMiddlewareErrorMessage(t *testing.T) {
+	req := &transport.Request{
+		Service:   "svc",
+		Procedure: "proc",
+		Headers:   transport.NewHeaders().With(calldepth.Header, "11"),
+	}
+	err := NewInboundMiddleware(10).Handle(context.Background(), req, nil, &depthHandler{})
+	assert.EqualError(t, err, `code:resource-exhausted message:call depth 11 for procedure "proc" of service "svc" exceeds the limit of 10, the request may be part of an RPC loop`)
+}
+
+// TestLoop sends a request to a service that calls itself with the context
+// of every request, and checks that the loop stops at the limit.
+func TestLoop(t *testing.T) {
+	const maxDepth = 3
+
+	tests := []struct {
+		desc     string
+		setup    func(t *testing.T) (transport.Inbound, func() transport.UnaryOutbound)
+		wantCode yarpcerrors.Code
+	}{
+		{
+			desc:     "http",
+			wantCode: yarpcerrors.CodeResourceExhausted,
+			setup: func(t *testing.T) (transport.Inbound, func() transport.UnaryOutbound) {
+				trans := http.NewTransport()
+				inbound := trans.NewInbound("127.0.0.1:0")
+				return inbound, func() transport.UnaryOutbound {
+					return trans.NewSingleOutbound("http://" + inbound.Addr().String())
+				}
+			},
+		},
+		{
+			// TChannel inbounds black-hole ResourceExhausted errors, so the
+			// loop stops but the caller times out.
+			desc:     "tchannel",
+			wantCode: yarpcerrors.CodeDeadlineExceeded,
+			setup: func(t *testing.T) (transport.Inbound, func() transport.UnaryOutbound) {
+				trans, err := tchannel.NewTransport(
+					tchannel.ServiceName("loop"),
+					tchannel.ListenAddr("127.0.0.1:0"))
+				require.NoError(t, err)
+				return trans.NewInbound(), func() transport.UnaryOutbound {
+					return trans.NewSingleOutbound(trans.ListenAddr())
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			inbound, newOutbound := tt.setup(t)
+
+			var (
+				client raw.Client
+				calls  atomic.Int32
+			)
+			d := yarpc.NewDispatcher(yarpc.Config{
+				Name:     "loop",
+				Inbounds: yarpc.Inbounds{inbound},
+				InboundMiddleware: yarpc.InboundMiddleware{
+					Unary: NewInboundMiddleware(maxDepth),
+				},
+			})
+			d.Register(raw.Procedure("loop", func(ctx context.Context, body []byte) ([]byte, error) {
+				calls.Inc()
+				return client.Call(ctx, "loop", body)
+			}))
+			require.NoError(t, d.Start())
+			defer d.Stop()
+
+			outbound := newOutbound()
+			require.NoError(t, outbound.Start())
+			defer outbound.Stop()
+			client = raw.New(clientconfig.MultiOutbound("loop", "loop", transport.Outbounds{Unary: outbound}))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*testtime.Millisecond)
+			defer cancel()
+			_, err := client.Call(ctx, "loop", nil)
+			require.Error(t, err)
+			assert.Equal(t, tt.wantCode, yarpcerrors.FromError(err).Code())
+			assert.Equal(t, int32(maxDepth+1), calls.Load(), "handlers at depths 0 through the limit must be called")
+		})
+	}
+}
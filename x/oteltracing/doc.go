@@ -0,0 +1,67 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package oteltracing provides middleware that traces requests with
+// OpenTelemetry, alongside or instead of the opentracing spans of the
+// transports.
+//
+// The inbound middleware extracts the trace context of requests from their
+// headers, with W3C Trace Context and Baggage by default, and starts a
+// server span for each request. The outbound middleware starts a client
+// span for each call and injects its context into the headers of the
+// request.
+//
+// 	mw := oteltracing.NewMiddleware(
+// 		oteltracing.TracerProvider(tracerProvider),
+// 		oteltracing.Propagator(propagation.TraceContext{}),
+// 	)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:     "myservice",
+// 		Inbounds: inbounds,
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary:  mw,
+// 			Oneway: mw,
+// 		},
+// 		Outbounds: outbounds,
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary:  mw,
+// 			Oneway: mw,
+// 		},
+// 	})
+//
+// Spans are named after the procedure and have the rpc.system, rpc.service,
+// and rpc.method attributes, and the caller, transport, and encoding of the
+// request as yarpc.caller, yarpc.transport, and yarpc.encoding. Failed
+// requests record their error, and their YARPC error code as
+// yarpc.error_code. Client spans of failed calls, and server spans of
+// requests that failed with a server fault, like CodeInternal or
+// CodeUnavailable, have an error status.
+//
+// Since the trace context travels in the application headers of requests,
+// this middleware does not interfere with the opentracing spans of the
+// transports, and both may be used while migrating from one to the other.
+// Requests whose context already carries an OpenTelemetry span, like the
+// span of an instrumented HTTP handler, are traced as children of that span
+// instead of extracting their headers again.
+//
+// The package lives in its own module, go.uber.org/yarpc/x/oteltracing,
+// which keeps OpenTelemetry out of the dependencies of go.uber.org/yarpc. It
+// requires Go 1.18 or later.
+package oteltracing
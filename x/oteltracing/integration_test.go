@@ -0,0 +1,152 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package oteltracing_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/transport/grpc"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/x/oteltracing"
+)
+
+func TestClientServerSpans(t *testing.T) {
+	tests := []struct {
+		transport string
+		build     func(t *testing.T, tracer *mocktracer.MockTracer) (transport.Inbound, func() transport.UnaryOutbound)
+	}{
+		{
+			transport: "http",
+			build: func(t *testing.T, tracer *mocktracer.MockTracer) (transport.Inbound, func() transport.UnaryOutbound) {
+				trans := http.NewTransport(http.Tracer(tracer))
+				inbound := trans.NewInbound("127.0.0.1:0")
+				return inbound, func() transport.UnaryOutbound {
+					return trans.NewSingleOutbound("http://" + inbound.Addr().String())
+				}
+			},
+		},
+		{
+			transport: "grpc",
+			build: func(t *testing.T, tracer *mocktracer.MockTracer) (transport.Inbound, func() transport.UnaryOutbound) {
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				require.NoError(t, err)
+				trans := grpc.NewTransport(grpc.Tracer(tracer))
+				inbound := trans.NewInbound(listener)
+				return inbound, func() transport.UnaryOutbound {
+					return trans.NewSingleOutbound(listener.Addr().String())
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.transport, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			defer tp.Shutdown(context.Background())
+			mw := oteltracing.NewMiddleware(oteltracing.TracerProvider(tp))
+
+			// The opentracing spans of the transports are recorded as well.
+			opentracer := mocktracer.New()
+			inbound, newOutbound := tt.build(t, opentracer)
+
+			var handlerSpan trace.SpanContext
+			server := yarpc.NewDispatcher(yarpc.Config{
+				Name:              "server",
+				Inbounds:          yarpc.Inbounds{inbound},
+				InboundMiddleware: yarpc.InboundMiddleware{Unary: mw},
+			})
+			server.Register(raw.Procedure("echo", func(ctx context.Context, body []byte) ([]byte, error) {
+				handlerSpan = trace.SpanContextFromContext(ctx)
+				return body, nil
+			}))
+			require.NoError(t, server.Start())
+			defer server.Stop()
+
+			client := yarpc.NewDispatcher(yarpc.Config{
+				Name: "client",
+				Outbounds: yarpc.Outbounds{
+					"server": {Unary: newOutbound()},
+				},
+				OutboundMiddleware: yarpc.OutboundMiddleware{Unary: mw},
+			})
+			require.NoError(t, client.Start())
+			defer client.Stop()
+
+			ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+			ctx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			res, err := raw.New(client.ClientConfig("server")).Call(ctx, "echo", []byte("hello"))
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(res))
+			parent.End()
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 3)
+			var serverSpan, clientSpan tracetest.SpanStub
+			for _, span := range spans {
+				switch span.SpanKind {
+				case trace.SpanKindServer:
+					serverSpan = span
+				case trace.SpanKindClient:
+					clientSpan = span
+				}
+			}
+
+			traceID := parent.SpanContext().TraceID()
+			assert.Equal(t, traceID, clientSpan.SpanContext.TraceID())
+			assert.Equal(t, traceID, serverSpan.SpanContext.TraceID())
+			assert.Equal(t, parent.SpanContext().SpanID(), clientSpan.Parent.SpanID(),
+				"client span must be a child of the span of the caller")
+			assert.Equal(t, clientSpan.SpanContext.SpanID(), serverSpan.Parent.SpanID(),
+				"server span must be a child of the client span")
+			assert.Equal(t, serverSpan.SpanContext, handlerSpan)
+
+			for _, span := range []tracetest.SpanStub{clientSpan, serverSpan} {
+				assert.Equal(t, "echo", span.Name)
+				assert.Contains(t, span.Attributes, attribute.String("rpc.system", "yarpc"))
+				assert.Contains(t, span.Attributes, attribute.String("rpc.service", "server"))
+				assert.Contains(t, span.Attributes, attribute.String("rpc.method", "echo"))
+				assert.Contains(t, span.Attributes, attribute.String("yarpc.caller", "client"))
+				assert.Contains(t, span.Attributes, attribute.String("yarpc.transport", tt.transport))
+				assert.Contains(t, span.Attributes, attribute.String("yarpc.encoding", "raw"))
+			}
+
+			assert.Len(t, opentracer.FinishedSpans(), 2, "opentracing spans must still be recorded")
+		})
+	}
+}
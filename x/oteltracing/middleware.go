@@ -0,0 +1,309 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package oteltracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+const (
+	_instrumentationName = "go.uber.org/yarpc/x/oteltracing"
+
+	_callerKey           = attribute.Key("yarpc.caller")
+	_transportKey        = attribute.Key("yarpc.transport")
+	_encodingKey         = attribute.Key("yarpc.encoding")
+	_errorCodeKey        = attribute.Key("yarpc.error_code")
+	_applicationErrorKey = attribute.Key("yarpc.application_error")
+)
+
+var (
+	_ middleware.UnaryInbound   = (*Middleware)(nil)
+	_ middleware.OnewayInbound  = (*Middleware)(nil)
+	_ middleware.UnaryOutbound  = (*Middleware)(nil)
+	_ middleware.OnewayOutbound = (*Middleware)(nil)
+)
+
+// Option customizes the middleware returned by NewMiddleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// TracerProvider sets the provider of the tracer that starts the spans of
+// requests.
+//
+// Defaults to the global TracerProvider of go.opentelemetry.io/otel.
+func TracerProvider(tp trace.TracerProvider) Option {
+	return optionFunc(func(o *options) {
+		if tp != nil {
+			o.tracerProvider = tp
+		}
+	})
+}
+
+// Propagator sets the propagator that extracts the trace context of inbound
+// requests from their headers, and injects it into the headers of outbound
+// calls.
+//
+// Defaults to W3C Trace Context and Baggage.
+func Propagator(p propagation.TextMapPropagator) Option {
+	return optionFunc(func(o *options) {
+		if p != nil {
+			o.propagator = p
+		}
+	})
+}
+
+// Middleware is an inbound and outbound middleware that traces requests with
+// OpenTelemetry.
+type Middleware struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// NewMiddleware returns a middleware that starts server spans for inbound
+// requests and client spans for outbound calls, propagating their trace
+// context in request headers.
+func NewMiddleware(opts ...Option) *Middleware {
+	o := options{
+		tracerProvider: otel.GetTracerProvider(),
+		propagator: propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		),
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return &Middleware{
+		tracer: o.tracerProvider.Tracer(_instrumentationName,
+			trace.WithInstrumentationVersion(yarpc.Version)),
+		propagator: o.propagator,
+	}
+}
+
+// Handle implements middleware.UnaryInbound.
+func (m *Middleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	ctx, span := m.startServerSpan(ctx, req)
+	defer span.End()
+
+	w := &statusWriter{ResponseWriter: resw}
+	err := h.Handle(ctx, req, w)
+	if err == nil && w.isApplicationError {
+		setApplicationError(span, trace.SpanKindServer, w.applicationErrorCode())
+	}
+	setError(span, trace.SpanKindServer, err)
+	return err
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	ctx, span := m.startServerSpan(ctx, req)
+	defer span.End()
+
+	err := h.HandleOneway(ctx, req)
+	setError(span, trace.SpanKindServer, err)
+	return err
+}
+
+// Call implements middleware.UnaryOutbound.
+func (m *Middleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	ctx, span, req := m.startClientSpan(ctx, req, out)
+	defer span.End()
+
+	res, err := out.Call(ctx, req)
+	if err == nil && res != nil && res.ApplicationError {
+		code := yarpcerrors.CodeUnknown
+		if meta := res.ApplicationErrorMeta; meta != nil && meta.Code != nil {
+			code = *meta.Code
+		}
+		setApplicationError(span, trace.SpanKindClient, code)
+	}
+	setError(span, trace.SpanKindClient, err)
+	return res, err
+}
+
+// CallOneway implements middleware.OnewayOutbound.
+func (m *Middleware) CallOneway(ctx context.Context, req *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	ctx, span, req := m.startClientSpan(ctx, req, out)
+	defer span.End()
+
+	ack, err := out.CallOneway(ctx, req)
+	setError(span, trace.SpanKindClient, err)
+	return ack, err
+}
+
+// startServerSpan starts the span of an inbound request, as a child of the
+// span of the context, if any, or of the trace context of its headers.
+func (m *Middleware) startServerSpan(ctx context.Context, req *transport.Request) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = m.propagator.Extract(ctx, headerCarrier{headers: &req.Headers})
+	}
+	return m.tracer.Start(ctx, req.Procedure,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attributes(req, req.Transport)...),
+	)
+}
+
+// startClientSpan starts the span of an outbound call, and returns the
+// request with the trace context of the span in its headers.
+func (m *Middleware) startClientSpan(ctx context.Context, req *transport.Request, out interface{}) (context.Context, trace.Span, *transport.Request) {
+	transportName := req.Transport
+	if namer, ok := out.(transport.Namer); ok && transportName == "" {
+		transportName = namer.TransportName()
+	}
+	ctx, span := m.tracer.Start(ctx, req.Procedure,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes(req, transportName)...),
+	)
+
+	headers := transport.NewHeadersWithCapacity(req.Headers.Len() + len(m.propagator.Fields()))
+	for k, v := range req.Headers.OriginalItems() {
+		headers = headers.With(k, v)
+	}
+	m.propagator.Inject(ctx, headerCarrier{headers: &headers})
+	r := *req
+	r.Headers = headers
+	return ctx, span, &r
+}
+
+func attributes(req *transport.Request, transportName string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		semconv.RPCSystemKey.String("yarpc"),
+		semconv.RPCServiceKey.String(req.Service),
+		semconv.RPCMethodKey.String(req.Procedure),
+		_callerKey.String(req.Caller),
+		_transportKey.String(transportName),
+		_encodingKey.String(string(req.Encoding)),
+	}
+}
+
+// setError records the error of a request, if any, on its span.
+func setError(span trace.Span, kind trace.SpanKind, err error) {
+	if err == nil {
+		return
+	}
+	status := yarpcerrors.FromError(err)
+	span.RecordError(err)
+	span.SetAttributes(_errorCodeKey.String(status.Code().String()))
+	if isErrorStatus(kind, status.Code()) {
+		span.SetStatus(codes.Error, status.Message())
+	}
+}
+
+// setApplicationError records an application error of a request on its
+// span.
+func setApplicationError(span trace.Span, kind trace.SpanKind, code yarpcerrors.Code) {
+	span.SetAttributes(
+		_applicationErrorKey.Bool(true),
+		_errorCodeKey.String(code.String()),
+	)
+	if isErrorStatus(kind, code) {
+		span.SetStatus(codes.Error, "application error")
+	}
+}
+
+// isErrorStatus reports whether spans of the kind that fail with the code
+// have an error status: client spans always do, and server spans only do
+// for faults of the server, following the semantic conventions for RPC
+// spans.
+func isErrorStatus(kind trace.SpanKind, code yarpcerrors.Code) bool {
+	if kind == trace.SpanKindClient {
+		return true
+	}
+	return yarpcerrors.GetFaultTypeFromCode(code) != yarpcerrors.ClientFault
+}
+
+// headerCarrier adapts the headers of a request to a
+// propagation.TextMapCarrier.
+type headerCarrier struct {
+	headers *transport.Headers
+}
+
+var _ propagation.TextMapCarrier = headerCarrier{}
+
+func (c headerCarrier) Get(key string) string {
+	v, _ := c.headers.Get(key)
+	return v
+}
+
+func (c headerCarrier) Set(key, value string) {
+	*c.headers = c.headers.With(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, c.headers.Len())
+	for k := range c.headers.Items() {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// statusWriter records whether the handler reported an application error.
+type statusWriter struct {
+	transport.ResponseWriter
+
+	isApplicationError   bool
+	applicationErrorMeta *transport.ApplicationErrorMeta
+}
+
+var _ transport.ApplicationErrorMetaSetter = (*statusWriter)(nil)
+
+func (w *statusWriter) SetApplicationError() {
+	w.isApplicationError = true
+	w.ResponseWriter.SetApplicationError()
+}
+
+func (w *statusWriter) SetApplicationErrorMeta(meta *transport.ApplicationErrorMeta) {
+	w.applicationErrorMeta = meta
+	if setter, ok := w.ResponseWriter.(transport.ApplicationErrorMetaSetter); ok {
+		setter.SetApplicationErrorMeta(meta)
+	}
+}
+
+func (w *statusWriter) applicationErrorCode() yarpcerrors.Code {
+	if w.applicationErrorMeta != nil && w.applicationErrorMeta.Code != nil {
+		return *w.applicationErrorMeta.Code
+	}
+	return yarpcerrors.CodeUnknown
+}
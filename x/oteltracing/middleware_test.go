@@ -0,0 +1,286 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package oteltracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+func newTestMiddleware(t *testing.T) (*Middleware, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { assert.NoError(t, tp.Shutdown(context.Background())) })
+	return NewMiddleware(TracerProvider(tp)), exporter
+}
+
+func newRequest() *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Transport: "http",
+		Encoding:  "raw",
+		Procedure: "hello",
+	}
+}
+
+func attributeMap(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value, len(span.Attributes))
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+type unaryHandlerFunc func(context.Context, *transport.Request, transport.ResponseWriter) error
+
+func (f unaryHandlerFunc) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	return f(ctx, req, resw)
+}
+
+type unaryOutboundFunc func(context.Context, *transport.Request) (*transport.Response, error)
+
+func (f unaryOutboundFunc) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	return f(ctx, req)
+}
+func (unaryOutboundFunc) Start() error                      { return nil }
+func (unaryOutboundFunc) Stop() error                       { return nil }
+func (unaryOutboundFunc) IsRunning() bool                   { return true }
+func (unaryOutboundFunc) Transports() []transport.Transport { return nil }
+func (unaryOutboundFunc) TransportName() string             { return "grpc" }
+
+func TestPropagation(t *testing.T) {
+	mw, exporter := newTestMiddleware(t)
+
+	var inboundSpan trace.SpanContext
+	server := unaryHandlerFunc(func(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+		inboundSpan = trace.SpanContextFromContext(ctx)
+		return nil
+	})
+	client := unaryOutboundFunc(func(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+		_, ok := req.Headers.Get("traceparent")
+		assert.True(t, ok, "traceparent header must be injected")
+		// Forward the request to the server, as a transport would.
+		inbound := *req
+		inbound.Transport = "grpc"
+		err := mw.Handle(context.Background(), &inbound, new(transporttest.FakeResponseWriter), server)
+		return &transport.Response{}, err
+	})
+
+	req := newRequest()
+	req.Transport = ""
+	req.Headers = transport.NewHeaders().With("foo", "bar")
+	_, err := mw.Call(context.Background(), req, client)
+	require.NoError(t, err)
+	assert.Equal(t, 1, req.Headers.Len(), "headers of the caller must not change")
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	serverSpan, clientSpan := spans[0], spans[1]
+
+	assert.Equal(t, trace.SpanKindServer, serverSpan.SpanKind)
+	assert.Equal(t, trace.SpanKindClient, clientSpan.SpanKind)
+	assert.Equal(t, "hello", clientSpan.Name)
+	assert.Equal(t, clientSpan.SpanContext.TraceID(), serverSpan.SpanContext.TraceID())
+	assert.Equal(t, clientSpan.SpanContext.SpanID(), serverSpan.Parent.SpanID())
+	assert.True(t, serverSpan.Parent.IsRemote())
+	assert.Equal(t, serverSpan.SpanContext, inboundSpan, "handler must run in the server span")
+
+	for _, span := range spans {
+		attrs := attributeMap(span)
+		assert.Equal(t, "yarpc", attrs["rpc.system"].AsString())
+		assert.Equal(t, "service", attrs["rpc.service"].AsString())
+		assert.Equal(t, "hello", attrs["rpc.method"].AsString())
+		assert.Equal(t, "caller", attrs["yarpc.caller"].AsString())
+		assert.Equal(t, "grpc", attrs["yarpc.transport"].AsString())
+		assert.Equal(t, "raw", attrs["yarpc.encoding"].AsString())
+		assert.Equal(t, codes.Unset, span.Status.Code)
+	}
+}
+
+func TestInboundExistingSpan(t *testing.T) {
+	mw, exporter := newTestMiddleware(t)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+
+	// The context already carries a span, so the traceparent header of the
+	// request must not be extracted again.
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	req := newRequest()
+	req.Headers = transport.NewHeaders().
+		With("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	err := mw.Handle(ctx, req, new(transporttest.FakeResponseWriter),
+		unaryHandlerFunc(func(context.Context, *transport.Request, transport.ResponseWriter) error { return nil }))
+	require.NoError(t, err)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, parent.SpanContext().TraceID(), spans[0].SpanContext.TraceID())
+}
+
+func TestInboundErrors(t *testing.T) {
+	tests := []struct {
+		desc         string
+		err          error
+		appErrorCode *yarpcerrors.Code
+		wantCode     string
+		wantStatus   codes.Code
+	}{
+		{
+			desc:       "server fault",
+			err:        yarpcerrors.InternalErrorf("great sadness"),
+			wantCode:   "internal",
+			wantStatus: codes.Error,
+		},
+		{
+			desc:       "client fault",
+			err:        yarpcerrors.InvalidArgumentErrorf("bad request"),
+			wantCode:   "invalid-argument",
+			wantStatus: codes.Unset,
+		},
+		{
+			desc:       "unknown error",
+			err:        errors.New("great sadness"),
+			wantCode:   "unknown",
+			wantStatus: codes.Error,
+		},
+		{
+			desc:         "application error",
+			appErrorCode: codePtr(yarpcerrors.CodeNotFound),
+			wantCode:     "not-found",
+			wantStatus:   codes.Unset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			mw, exporter := newTestMiddleware(t)
+			err := mw.Handle(context.Background(), newRequest(), new(transporttest.FakeResponseWriter),
+				unaryHandlerFunc(func(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+					if tt.appErrorCode != nil {
+						resw.SetApplicationError()
+						resw.(transport.ApplicationErrorMetaSetter).SetApplicationErrorMeta(
+							&transport.ApplicationErrorMeta{Code: tt.appErrorCode})
+					}
+					return tt.err
+				}))
+			assert.Equal(t, tt.err, err)
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 1)
+			assert.Equal(t, tt.wantCode, attributeMap(spans[0])["yarpc.error_code"].AsString())
+			assert.Equal(t, tt.wantStatus, spans[0].Status.Code)
+			if tt.err != nil {
+				require.Len(t, spans[0].Events, 1)
+				assert.Equal(t, "exception", spans[0].Events[0].Name)
+			}
+		})
+	}
+}
+
+func TestOutboundErrors(t *testing.T) {
+	mw, exporter := newTestMiddleware(t)
+	client := unaryOutboundFunc(func(context.Context, *transport.Request) (*transport.Response, error) {
+		return nil, yarpcerrors.InvalidArgumentErrorf("bad request")
+	})
+	_, err := mw.Call(context.Background(), newRequest(), client)
+	require.Error(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "invalid-argument", attributeMap(spans[0])["yarpc.error_code"].AsString())
+	assert.Equal(t, codes.Error, spans[0].Status.Code, "client spans of failed calls must have an error status")
+	assert.Equal(t, "bad request", spans[0].Status.Description)
+}
+
+func TestOnewayPropagation(t *testing.T) {
+	mw, exporter := newTestMiddleware(t)
+
+	var headers transport.Headers
+	_, err := mw.CallOneway(context.Background(), newRequest(), onewayOutboundFunc(
+		func(ctx context.Context, req *transport.Request) (transport.Ack, error) {
+			headers = req.Headers
+			return nil, nil
+		}))
+	require.NoError(t, err)
+
+	req := newRequest()
+	req.Headers = headers
+	err = mw.HandleOneway(context.Background(), req, onewayHandlerFunc(
+		func(context.Context, *transport.Request) error { return nil }))
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, spans[0].SpanContext.SpanID(), spans[1].Parent.SpanID())
+}
+
+func TestPropagatorOption(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(context.Background())
+	mw := NewMiddleware(TracerProvider(tp), Propagator(propagation.Baggage{}))
+
+	_, err := mw.Call(context.Background(), newRequest(), unaryOutboundFunc(
+		func(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+			_, ok := req.Headers.Get("traceparent")
+			assert.False(t, ok, "traceparent must not be injected")
+			return &transport.Response{}, nil
+		}))
+	require.NoError(t, err)
+}
+
+func codePtr(code yarpcerrors.Code) *yarpcerrors.Code { return &code }
+
+type onewayHandlerFunc func(context.Context, *transport.Request) error
+
+func (f onewayHandlerFunc) HandleOneway(ctx context.Context, req *transport.Request) error {
+	return f(ctx, req)
+}
+
+type onewayOutboundFunc func(context.Context, *transport.Request) (transport.Ack, error)
+
+func (f onewayOutboundFunc) CallOneway(ctx context.Context, req *transport.Request) (transport.Ack, error) {
+	return f(ctx, req)
+}
+func (onewayOutboundFunc) Start() error                      { return nil }
+func (onewayOutboundFunc) Stop() error                       { return nil }
+func (onewayOutboundFunc) IsRunning() bool                   { return true }
+func (onewayOutboundFunc) Transports() []transport.Transport { return nil }
@@ -0,0 +1,52 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package otlp provides a Tally scope that exports metrics to an OpenTelemetry
+// Collector, or any other receiver of the OpenTelemetry protocol (OTLP), over
+// gRPC.
+//
+// 	scope, err := otlp.NewTallyScope(ctx, "otel-collector:4317",
+// 		otlp.ServiceName("myservice"),
+// 		otlp.FlushInterval(10*time.Second),
+// 	)
+// 	if err != nil {
+// 		log.Fatal(err)
+// 	}
+// 	defer scope.(io.Closer).Close()
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		Metrics: yarpc.MetricsConfig{
+// 			Tally: scope,
+// 		},
+// 	})
+//
+// Counters are exported as cumulative monotonic sums, gauges as gauges, and
+// timers and histograms as cumulative histograms, with timers and duration
+// histograms in seconds. Since Tally does not record the sum of the values of
+// histograms, the sums of exported histograms are always zero.
+//
+// The scope buffers metrics and exports them in the background on every
+// flush interval, and when it is closed.
+//
+// This package is a module of its own, go.uber.org/yarpc/x/otlp, because the
+// OTLP exporter needs newer gRPC and Protobuf releases than YARPC does. It
+// requires Go 1.18 or later.
+package otlp
@@ -0,0 +1,374 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package otlp
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.uber.org/zap"
+)
+
+// _instrumentationName is the name of the instrumentation scope of exported
+// metrics.
+const _instrumentationName = "go.uber.org/yarpc/x/otlp"
+
+// _timerBounds are the upper bounds in seconds of the buckets of the
+// histograms that timers are exported as.
+var _timerBounds = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// reporter is a Tally reporter that aggregates reported values into OTLP
+// metrics, and exports them on every flush.
+type reporter struct {
+	exporter sdkmetric.Exporter
+	resource *resource.Resource
+	timeout  time.Duration
+	logger   *zap.Logger
+	start    time.Time
+	now      func() time.Time
+
+	// exportMu serializes exports, which exporters require.
+	exportMu sync.Mutex
+
+	mu         sync.Mutex
+	counters   map[string]*counterPoint
+	gauges     map[string]*gaugePoint
+	histograms map[string]*histogramPoint
+}
+
+var _ tally.StatsReporter = (*reporter)(nil)
+
+type series struct {
+	name  string
+	attrs attribute.Set
+}
+
+type counterPoint struct {
+	series
+	value int64
+}
+
+type gaugePoint struct {
+	series
+	value float64
+	time  time.Time
+}
+
+type histogramPoint struct {
+	series
+	bounds []float64
+	counts []uint64
+}
+
+func newReporter(exporter sdkmetric.Exporter, res *resource.Resource, timeout time.Duration, logger *zap.Logger) *reporter {
+	return &reporter{
+		exporter:   exporter,
+		resource:   res,
+		timeout:    timeout,
+		logger:     logger,
+		start:      time.Now(),
+		now:        time.Now,
+		counters:   make(map[string]*counterPoint),
+		gauges:     make(map[string]*gaugePoint),
+		histograms: make(map[string]*histogramPoint),
+	}
+}
+
+// seriesKey identifies the time series of a metric with the given tags.
+func seriesKey(name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(tags[k])
+	}
+	return b.String()
+}
+
+func newSeries(name string, tags map[string]string) series {
+	attrs := make([]attribute.KeyValue, 0, len(tags))
+	for k, v := range tags {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	return series{name: name, attrs: attribute.NewSet(attrs...)}
+}
+
+// Tally reports counters as the change since their last report.
+func (r *reporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := seriesKey(name, tags)
+	p, ok := r.counters[key]
+	if !ok {
+		p = &counterPoint{series: newSeries(name, tags)}
+		r.counters[key] = p
+	}
+	p.value += value
+}
+
+func (r *reporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := seriesKey(name, tags)
+	p, ok := r.gauges[key]
+	if !ok {
+		p = &gaugePoint{series: newSeries(name, tags)}
+		r.gauges[key] = p
+	}
+	p.value = value
+	p.time = r.now()
+}
+
+func (r *reporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.histogram(name, tags, _timerBounds)
+	p.counts[sort.SearchFloat64s(p.bounds, interval.Seconds())]++
+}
+
+func (r *reporter) ReportHistogramValueSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound float64,
+	samples int64,
+) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.addSamples(name, tags, buckets.AsValues(), bucketUpperBound, samples)
+}
+
+func (r *reporter) ReportHistogramDurationSamples(
+	name string,
+	tags map[string]string,
+	buckets tally.Buckets,
+	bucketLowerBound,
+	bucketUpperBound time.Duration,
+	samples int64,
+) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	durations := buckets.AsDurations()
+	bounds := make([]float64, len(durations))
+	for i, d := range durations {
+		bounds[i] = d.Seconds()
+	}
+	upper := math.MaxFloat64
+	if bucketUpperBound != time.Duration(math.MaxInt64) {
+		upper = bucketUpperBound.Seconds()
+	}
+	r.addSamples(name, tags, bounds, upper, samples)
+}
+
+// addSamples adds samples to the bucket of the histogram with the given
+// upper bound. Tally puts samples above the last bound in a bucket whose
+// upper bound is the largest float.
+func (r *reporter) addSamples(name string, tags map[string]string, bounds []float64, upper float64, samples int64) {
+	p := r.histogram(name, tags, bounds)
+	i := len(p.bounds)
+	if upper != math.MaxFloat64 {
+		i = sort.SearchFloat64s(p.bounds, upper)
+	}
+	p.counts[i] += uint64(samples)
+}
+
+// histogram returns the histogram of a series, creating it with the given
+// bucket bounds if it does not exist.
+func (r *reporter) histogram(name string, tags map[string]string, bounds []float64) *histogramPoint {
+	key := seriesKey(name, tags)
+	p, ok := r.histograms[key]
+	if !ok {
+		sorted := append([]float64(nil), bounds...)
+		sort.Float64s(sorted)
+		p = &histogramPoint{
+			series: newSeries(name, tags),
+			bounds: sorted,
+			counts: make([]uint64, len(sorted)+1),
+		}
+		r.histograms[key] = p
+	}
+	return p
+}
+
+func (r *reporter) Capabilities() tally.Capabilities {
+	return r
+}
+
+func (r *reporter) Reporting() bool {
+	return true
+}
+
+func (r *reporter) Tagging() bool {
+	return true
+}
+
+// Flush exports the metrics reported so far. Tally calls it on every flush
+// interval from its reporting goroutine.
+func (r *reporter) Flush() {
+	r.exportMu.Lock()
+	defer r.exportMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	if err := r.exporter.Export(ctx, r.collect()); err != nil {
+		r.logger.Error("failed to export metrics", zap.Error(err))
+	}
+}
+
+// Close shuts the exporter down. Tally calls it when the scope is closed,
+// after flushing the remaining metrics.
+func (r *reporter) Close() error {
+	r.exportMu.Lock()
+	defer r.exportMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	return r.exporter.Shutdown(ctx)
+}
+
+// collect returns the OTLP metrics of all series, in the order of their
+// names.
+func (r *reporter) collect() metricdata.ResourceMetrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	var metrics []metricdata.Metrics
+
+	for _, name := range sortedNames(r.counters) {
+		var points []metricdata.DataPoint[int64]
+		for _, p := range r.counters {
+			if p.name == name {
+				points = append(points, metricdata.DataPoint[int64]{
+					Attributes: p.attrs,
+					StartTime:  r.start,
+					Time:       now,
+					Value:      p.value,
+				})
+			}
+		}
+		metrics = append(metrics, metricdata.Metrics{
+			Name: name,
+			Data: metricdata.Sum[int64]{
+				DataPoints:  points,
+				Temporality: metricdata.CumulativeTemporality,
+				IsMonotonic: true,
+			},
+		})
+	}
+
+	for _, name := range sortedNames(r.gauges) {
+		var points []metricdata.DataPoint[float64]
+		for _, p := range r.gauges {
+			if p.name == name {
+				points = append(points, metricdata.DataPoint[float64]{
+					Attributes: p.attrs,
+					Time:       p.time,
+					Value:      p.value,
+				})
+			}
+		}
+		metrics = append(metrics, metricdata.Metrics{
+			Name: name,
+			Data: metricdata.Gauge[float64]{DataPoints: points},
+		})
+	}
+
+	for _, name := range sortedNames(r.histograms) {
+		var points []metricdata.HistogramDataPoint
+		for _, p := range r.histograms {
+			if p.name == name {
+				var count uint64
+				for _, c := range p.counts {
+					count += c
+				}
+				points = append(points, metricdata.HistogramDataPoint{
+					Attributes:   p.attrs,
+					StartTime:    r.start,
+					Time:         now,
+					Count:        count,
+					Bounds:       append([]float64(nil), p.bounds...),
+					BucketCounts: append([]uint64(nil), p.counts...),
+				})
+			}
+		}
+		metrics = append(metrics, metricdata.Metrics{
+			Name: name,
+			Data: metricdata.Histogram{
+				DataPoints:  points,
+				Temporality: metricdata.CumulativeTemporality,
+			},
+		})
+	}
+
+	return metricdata.ResourceMetrics{
+		Resource: r.resource,
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Scope:   instrumentation.Scope{Name: _instrumentationName},
+			Metrics: metrics,
+		}},
+	}
+}
+
+// sortedNames returns the distinct names of the series of a map of points,
+// sorted.
+func sortedNames[P interface{ metricName() string }](points map[string]P) []string {
+	seen := make(map[string]struct{}, len(points))
+	names := make([]string, 0, len(points))
+	for _, p := range points {
+		name := p.metricName()
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s series) metricName() string { return s.name }
@@ -0,0 +1,175 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package otlp
+
+import (
+	"context"
+	"time"
+
+	"github.com/uber-go/tally"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.uber.org/zap"
+)
+
+const (
+	_defaultFlushInterval = 10 * time.Second
+	_defaultExportTimeout = 10 * time.Second
+)
+
+// Option customizes the scope returned by NewTallyScope.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	flushInterval time.Duration
+	exportTimeout time.Duration
+	prefix        string
+	tags          map[string]string
+	serviceName   string
+	insecure      bool
+	headers       map[string]string
+	logger        *zap.Logger
+}
+
+// FlushInterval sets how often the scope exports its metrics.
+//
+// Defaults to 10 seconds.
+func FlushInterval(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d > 0 {
+			o.flushInterval = d
+		}
+	})
+}
+
+// ExportTimeout sets how long the scope waits for the receiver to accept
+// each export.
+//
+// Defaults to 10 seconds.
+func ExportTimeout(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d > 0 {
+			o.exportTimeout = d
+		}
+	})
+}
+
+// Prefix sets a prefix for the names of all metrics of the scope.
+func Prefix(prefix string) Option {
+	return optionFunc(func(o *options) {
+		o.prefix = prefix
+	})
+}
+
+// Tags sets tags that all metrics of the scope have.
+func Tags(tags map[string]string) Option {
+	return optionFunc(func(o *options) {
+		o.tags = tags
+	})
+}
+
+// ServiceName sets the name of the service in the resource of the exported
+// metrics.
+//
+// Defaults to the service name that the OpenTelemetry SDK detects from the
+// environment.
+func ServiceName(name string) Option {
+	return optionFunc(func(o *options) {
+		o.serviceName = name
+	})
+}
+
+// Insecure disables TLS for the connection to the receiver.
+func Insecure() Option {
+	return optionFunc(func(o *options) {
+		o.insecure = true
+	})
+}
+
+// Headers sets headers that are sent to the receiver with every export, like
+// credentials.
+func Headers(headers map[string]string) Option {
+	return optionFunc(func(o *options) {
+		o.headers = headers
+	})
+}
+
+// Logger sets the logger for export failures.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+// NewTallyScope returns a Tally scope that exports its metrics to the OTLP
+// receiver at the given host:port endpoint on every flush interval.
+//
+// The returned scope implements io.Closer. Closing it exports the remaining
+// metrics and disconnects from the receiver.
+func NewTallyScope(ctx context.Context, endpoint string, opts ...Option) (tally.Scope, error) {
+	o := options{
+		flushInterval: _defaultFlushInterval,
+		exportTimeout: _defaultExportTimeout,
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.logger == nil {
+		o.logger = zap.NewNop()
+	}
+
+	exporterOpts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(endpoint)}
+	if o.insecure {
+		exporterOpts = append(exporterOpts, otlpmetricgrpc.WithInsecure())
+	}
+	if len(o.headers) > 0 {
+		exporterOpts = append(exporterOpts, otlpmetricgrpc.WithHeaders(o.headers))
+	}
+	exporter, err := otlpmetricgrpc.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.Default()
+	if o.serviceName != "" {
+		res, err = resource.Merge(res, resource.NewSchemaless(semconv.ServiceNameKey.String(o.serviceName)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scope, _ := tally.NewRootScope(tally.ScopeOptions{
+		Prefix:   o.prefix,
+		Tags:     o.tags,
+		Reporter: newReporter(exporter, res, o.exportTimeout, o.logger),
+	}, o.flushInterval)
+	return scope, nil
+}
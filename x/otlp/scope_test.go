@@ -0,0 +1,203 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build go1.18
+// +build go1.18
+
+package otlp
+
+import (
+	"context"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeCollector is an OTLP receiver that records the metrics and headers of
+// exports.
+type fakeCollector struct {
+	collectorpb.UnimplementedMetricsServiceServer
+
+	mu      sync.Mutex
+	metrics map[string]*metricspb.Metric
+	headers metadata.MD
+}
+
+func (c *fakeCollector) Export(ctx context.Context, req *collectorpb.ExportMetricsServiceRequest) (*collectorpb.ExportMetricsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.headers, _ = metadata.FromIncomingContext(ctx)
+	for _, rm := range req.ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				c.metrics[m.Name] = m
+			}
+		}
+	}
+	return &collectorpb.ExportMetricsServiceResponse{}, nil
+}
+
+func (c *fakeCollector) metric(name string) *metricspb.Metric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.metrics[name]
+}
+
+func startCollector(t *testing.T) (*fakeCollector, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	collector := &fakeCollector{metrics: make(map[string]*metricspb.Metric)}
+	server := grpc.NewServer()
+	collectorpb.RegisterMetricsServiceServer(server, collector)
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+
+	return collector, ln.Addr().String()
+}
+
+func attributes(attrs []*commonpb.KeyValue) map[string]string {
+	m := make(map[string]string, len(attrs))
+	for _, kv := range attrs {
+		m[kv.Key] = kv.Value.GetStringValue()
+	}
+	return m
+}
+
+func TestTallyScope(t *testing.T) {
+	collector, addr := startCollector(t)
+
+	scope, err := NewTallyScope(context.Background(), addr,
+		Insecure(),
+		FlushInterval(time.Hour),
+		Prefix("svc"),
+		Tags(map[string]string{"region": "west"}),
+		ServiceName("myservice"),
+		Headers(map[string]string{"x-token": "secret"}),
+	)
+	require.NoError(t, err)
+
+	counter := scope.Tagged(map[string]string{"procedure": "echo"}).Counter("calls")
+	counter.Inc(2)
+	counter.Inc(3)
+	scope.Gauge("pending").Update(7)
+	scope.Timer("latency").Record(30 * time.Millisecond)
+	scope.Histogram("size", tally.ValueBuckets{10, 100}).RecordValue(50)
+	scope.Histogram("size", tally.ValueBuckets{10, 100}).RecordValue(500)
+	scope.Histogram("wait", tally.DurationBuckets{time.Second}).RecordDuration(time.Millisecond)
+
+	// Closing the scope flushes the remaining metrics.
+	require.NoError(t, scope.(io.Closer).Close())
+
+	assert.Equal(t, []string{"secret"}, collector.headers.Get("x-token"))
+
+	calls := collector.metric("svc.calls")
+	require.NotNil(t, calls, "counter must be exported")
+	sum := calls.GetSum()
+	require.NotNil(t, sum, "counter must be exported as a sum")
+	assert.True(t, sum.IsMonotonic)
+	assert.Equal(t, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, sum.AggregationTemporality)
+	require.Len(t, sum.DataPoints, 1)
+	assert.Equal(t, int64(5), sum.DataPoints[0].GetAsInt())
+	assert.Equal(t, map[string]string{"region": "west", "procedure": "echo"}, attributes(sum.DataPoints[0].Attributes))
+
+	pending := collector.metric("svc.pending")
+	require.NotNil(t, pending, "gauge must be exported")
+	gauge := pending.GetGauge()
+	require.NotNil(t, gauge, "gauge must be exported as a gauge")
+	require.Len(t, gauge.DataPoints, 1)
+	assert.Equal(t, float64(7), gauge.DataPoints[0].GetAsDouble())
+
+	latency := collector.metric("svc.latency")
+	require.NotNil(t, latency, "timer must be exported")
+	require.NotNil(t, latency.GetHistogram(), "timer must be exported as a histogram")
+	timerPoints := latency.GetHistogram().DataPoints
+	require.Len(t, timerPoints, 1)
+	assert.Equal(t, _timerBounds, timerPoints[0].ExplicitBounds)
+	assert.Equal(t, uint64(1), timerPoints[0].Count)
+	assert.Equal(t, uint64(1), timerPoints[0].BucketCounts[3], "30ms must fall in the (25ms, 50ms] bucket")
+
+	size := collector.metric("svc.size")
+	require.NotNil(t, size, "histogram must be exported")
+	require.NotNil(t, size.GetHistogram(), "histogram must be exported as a histogram")
+	sizePoints := size.GetHistogram().DataPoints
+	require.Len(t, sizePoints, 1)
+	assert.Equal(t, []float64{10, 100}, sizePoints[0].ExplicitBounds)
+	assert.Equal(t, []uint64{0, 1, 1}, sizePoints[0].BucketCounts)
+	assert.Equal(t, uint64(2), sizePoints[0].Count)
+
+	wait := collector.metric("svc.wait")
+	require.NotNil(t, wait, "duration histogram must be exported")
+	waitPoints := wait.GetHistogram().DataPoints
+	require.Len(t, waitPoints, 1)
+	assert.Equal(t, []float64{1}, waitPoints[0].ExplicitBounds, "bounds must be in seconds")
+	assert.Equal(t, []uint64{1, 0}, waitPoints[0].BucketCounts)
+}
+
+func TestTallyScopeFlushesPeriodically(t *testing.T) {
+	collector, addr := startCollector(t)
+
+	scope, err := NewTallyScope(context.Background(), addr, Insecure(), FlushInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	defer scope.(io.Closer).Close()
+
+	scope.Counter("calls").Inc(1)
+	assert.Eventually(t, func() bool {
+		return collector.metric("calls") != nil
+	}, 5*time.Second, 10*time.Millisecond, "metrics must be exported without closing the scope")
+}
+
+func TestReporterCountersAreCumulative(t *testing.T) {
+	r := newReporter(nil, nil, time.Second, nil)
+	r.ReportCounter("calls", map[string]string{"a": "1"}, 2)
+	r.ReportCounter("calls", map[string]string{"a": "1"}, 3)
+	r.ReportCounter("calls", map[string]string{"a": "2"}, 1)
+	r.ReportCounter("errors", nil, 1)
+
+	rm := r.collect()
+	require.Len(t, rm.ScopeMetrics, 1)
+	assert.Equal(t, _instrumentationName, rm.ScopeMetrics[0].Scope.Name)
+
+	metrics := rm.ScopeMetrics[0].Metrics
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "calls", metrics[0].Name)
+	assert.Equal(t, "errors", metrics[1].Name)
+
+	var values []int64
+	for _, p := range metrics[0].Data.(metricdata.Sum[int64]).DataPoints {
+		values = append(values, p.Value)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	assert.Equal(t, []int64{1, 5}, values)
+}
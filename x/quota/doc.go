@@ -0,0 +1,44 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package quota provides inbound middleware that enforces quotas of requests
+// per caller and procedure over fixed windows of time, like hours or days.
+//
+// Every request is checked against and then consumes the quota of its caller
+// for its procedure, in a QuotaStore. Requests that exceed the quota fail
+// with a ResourceExhausted error carrying the delay until the quota renews,
+// which RetryAfter reveals.
+//
+// RedisStore shares quotas across all instances of a service:
+//
+// 	store := quota.RedisStore(redisClient, 24*time.Hour,
+// 		quota.DefaultLimit(10000),
+// 		quota.ProcedureLimit("Reports::generate", 100),
+// 	)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: quota.NewInboundMiddleware(store),
+// 		},
+// 	})
+//
+// The middleware fails open: if the store fails, requests proceed as if
+// there were no quota.
+package quota
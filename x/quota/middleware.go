@@ -0,0 +1,150 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// QuotaStore tracks the requests of every caller to every procedure.
+//
+// Implementations MUST be safe for concurrent use.
+type QuotaStore interface {
+	// Check returns an *ExceededError if the caller has no quota left for
+	// the procedure.
+	Check(caller, procedure string) error
+
+	// Consume consumes a request from the quota of the caller for the
+	// procedure. It returns an *ExceededError if the request exceeds the
+	// quota.
+	Consume(caller, procedure string) error
+}
+
+// ExceededError is returned by a QuotaStore for requests of a caller that
+// has exhausted its quota for a procedure.
+type ExceededError struct {
+	Caller    string
+	Procedure string
+
+	// RetryAfter is the delay until the quota renews.
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded for caller %q and procedure %q, retry after %v",
+		e.Caller, e.Procedure, e.RetryAfter)
+}
+
+type inboundOptions struct {
+	logger *zap.Logger
+}
+
+// InboundOption customizes the behavior of the inbound middleware.
+type InboundOption func(*inboundOptions)
+
+// Logger specifies a logger for warnings about requests allowed because the
+// store failed.
+func Logger(logger *zap.Logger) InboundOption {
+	return func(o *inboundOptions) {
+		o.logger = logger
+	}
+}
+
+// NewInboundMiddleware returns middleware that rejects unary requests of
+// callers that exhausted their quota for the procedure in the given store.
+func NewInboundMiddleware(store QuotaStore, opts ...InboundOption) middleware.UnaryInbound {
+	var options inboundOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	logger := options.logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &inboundMiddleware{
+		store:  store,
+		logger: logger,
+	}
+}
+
+type inboundMiddleware struct {
+	store  QuotaStore
+	logger *zap.Logger
+}
+
+func (m *inboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	// Checking first spares the store a write for requests of callers that
+	// exhausted their quota, which are likely to keep retrying.
+	err := m.store.Check(req.Caller, req.Procedure)
+	if err == nil {
+		err = m.store.Consume(req.Caller, req.Procedure)
+	}
+	if err != nil {
+		var exceeded *ExceededError
+		if errors.As(err, &exceeded) {
+			return newQuotaError(exceeded)
+		}
+		m.logger.Warn("allowing request without quota: quota store failed",
+			zap.String("caller", req.Caller),
+			zap.String("procedure", req.Procedure),
+			zap.Error(err))
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+func newQuotaError(e *ExceededError) error {
+	err := yarpcerrors.ResourceExhaustedErrorf("%v", e)
+	if e.RetryAfter <= 0 {
+		return err
+	}
+	return yarpcerrors.WithDetails(err, &rpc.RetryInfo{RetryDelay: types.DurationProto(e.RetryAfter)})
+}
+
+// RetryAfter returns the delay after which a request rejected for exceeding
+// its quota may be retried, and false if the error does not carry one.
+func RetryAfter(err error) (time.Duration, bool) {
+	details, derr := yarpcerrors.Details(err)
+	if derr != nil {
+		return 0, false
+	}
+	for _, detail := range details {
+		info, ok := detail.(*rpc.RetryInfo)
+		if !ok || info.RetryDelay == nil {
+			continue
+		}
+		if d, err := types.DurationFromProto(info.RetryDelay); err == nil {
+			return d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeStore returns the given errors and counts the consumed requests.
+type fakeStore struct {
+	checkErr   error
+	consumeErr error
+	consumed   int
+}
+
+func (s *fakeStore) Check(caller, procedure string) error {
+	return s.checkErr
+}
+
+func (s *fakeStore) Consume(caller, procedure string) error {
+	s.consumed++
+	return s.consumeErr
+}
+
+type countingHandler struct {
+	calls int
+}
+
+func (h *countingHandler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	h.calls++
+	return nil
+}
+
+func handle(mw middleware.UnaryInbound, h transport.UnaryHandler) error {
+	return middleware.ApplyUnaryInbound(h, mw).Handle(context.Background(), &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "procedure",
+	}, &transporttest.FakeResponseWriter{})
+}
+
+func TestInboundMiddleware(t *testing.T) {
+	exceeded := &ExceededError{Caller: "caller", Procedure: "procedure", RetryAfter: time.Minute}
+	tests := []struct {
+		desc         string
+		store        fakeStore
+		wantConsumed int
+		wantErr      bool
+	}{
+		{
+			desc:         "within quota",
+			wantConsumed: 1,
+		},
+		{
+			desc:    "check exceeded",
+			store:   fakeStore{checkErr: exceeded},
+			wantErr: true,
+		},
+		{
+			desc:         "consume exceeded",
+			store:        fakeStore{consumeErr: exceeded},
+			wantConsumed: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			h := &countingHandler{}
+			err := handle(NewInboundMiddleware(&tt.store), h)
+			assert.Equal(t, tt.wantConsumed, tt.store.consumed)
+			if !tt.wantErr {
+				require.NoError(t, err)
+				assert.Equal(t, 1, h.calls)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Zero(t, h.calls, "handler must not be called for requests exceeding their quota")
+			assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+			assert.Contains(t, err.Error(), `quota exceeded for caller "caller" and procedure "procedure"`)
+
+			retryAfter, ok := RetryAfter(err)
+			require.True(t, ok, "expected a retry-after hint")
+			assert.Equal(t, time.Minute, retryAfter)
+		})
+	}
+}
+
+func TestInboundMiddlewareWithoutRetryAfter(t *testing.T) {
+	store := &fakeStore{checkErr: &ExceededError{Caller: "caller", Procedure: "procedure"}}
+	err := handle(NewInboundMiddleware(store), &countingHandler{})
+
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+	_, ok := RetryAfter(err)
+	assert.False(t, ok)
+}
+
+func TestInboundMiddlewareFailsOpen(t *testing.T) {
+	for _, store := range []*fakeStore{
+		{checkErr: errors.New("great sadness")},
+		{consumeErr: errors.New("great sadness")},
+	} {
+		core, logs := observer.New(zapcore.WarnLevel)
+		h := &countingHandler{}
+
+		err := handle(NewInboundMiddleware(store, Logger(zap.New(core))), h)
+		require.NoError(t, err)
+		assert.Equal(t, 1, h.calls, "requests must be allowed when the store fails")
+		assert.Equal(t, 1, logs.FilterMessage("allowing request without quota: quota store failed").Len())
+	}
+}
+
+func TestRetryAfterOtherErrors(t *testing.T) {
+	_, ok := RetryAfter(errors.New("great sadness"))
+	assert.False(t, ok)
+	_, ok = RetryAfter(yarpcerrors.ResourceExhaustedErrorf("no details"))
+	assert.False(t, ok)
+}
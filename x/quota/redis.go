@@ -0,0 +1,171 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package quota
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const _defaultKeyPrefix = "quota"
+
+// _consume counts a request in the window at KEYS[1], which expires after
+// ARGV[1] milliseconds, at the end of the window. It returns the number of
+// requests in the window.
+var _consume = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+type redisOptions struct {
+	keyPrefix       string
+	defaultLimit    int
+	procedureLimits map[string]int
+	now             func() time.Time
+}
+
+// RedisOption customizes the behavior of the Redis store.
+type RedisOption func(*redisOptions)
+
+// KeyPrefix specifies the prefix of the keys of the store.
+//
+// Defaults to "quota".
+func KeyPrefix(prefix string) RedisOption {
+	return func(o *redisOptions) {
+		o.keyPrefix = prefix
+	}
+}
+
+// DefaultLimit specifies the number of requests that every caller may send
+// to every procedure without a limit of its own in each window.
+//
+// Defaults to no limit.
+func DefaultLimit(n int) RedisOption {
+	return func(o *redisOptions) {
+		o.defaultLimit = n
+	}
+}
+
+// ProcedureLimit specifies the number of requests that every caller may
+// send to the given procedure in each window.
+func ProcedureLimit(procedure string, n int) RedisOption {
+	return func(o *redisOptions) {
+		o.procedureLimits[procedure] = n
+	}
+}
+
+// RedisStore builds a QuotaStore that counts the requests of every caller to
+// every procedure in Redis, so that all instances of a service sharing the
+// Redis instance and key prefix share quotas.
+//
+// Quotas renew at the start of every window of the given duration, counted
+// from the Unix epoch, so that daily windows start at midnight UTC. The
+// requests of each window are counted under the key
+// "<keyPrefix>:<caller>:<procedure>:<window start in Unix milliseconds>",
+// which expires at the end of the window.
+//
+// Procedures without a limit, or with a non-positive limit, have no quota.
+func RedisStore(client redis.UniversalClient, windowDuration time.Duration, opts ...RedisOption) QuotaStore {
+	options := redisOptions{
+		keyPrefix:       _defaultKeyPrefix,
+		procedureLimits: make(map[string]int),
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if windowDuration < time.Millisecond {
+		windowDuration = time.Millisecond
+	}
+
+	return &redisStore{
+		client:  client,
+		window:  windowDuration,
+		options: options,
+	}
+}
+
+type redisStore struct {
+	client  redis.UniversalClient
+	window  time.Duration
+	options redisOptions
+}
+
+func (s *redisStore) Check(caller, procedure string) error {
+	limit := s.limit(procedure)
+	if limit <= 0 {
+		return nil
+	}
+
+	key, retryAfter := s.key(caller, procedure)
+	count, err := s.client.Get(context.Background(), key).Int64()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if count >= int64(limit) {
+		return &ExceededError{Caller: caller, Procedure: procedure, RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (s *redisStore) Consume(caller, procedure string) error {
+	limit := s.limit(procedure)
+	if limit <= 0 {
+		return nil
+	}
+
+	key, retryAfter := s.key(caller, procedure)
+	count, err := _consume.Run(context.Background(), s.client, []string{key}, retryAfter.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if count > int64(limit) {
+		return &ExceededError{Caller: caller, Procedure: procedure, RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (s *redisStore) limit(procedure string) int {
+	if limit, ok := s.options.procedureLimits[procedure]; ok {
+		return limit
+	}
+	return s.options.defaultLimit
+}
+
+// key returns the key of the current window of the procedure for the caller,
+// and the delay until the end of the window.
+func (s *redisStore) key(caller, procedure string) (string, time.Duration) {
+	now := s.options.now().UnixNano() / int64(time.Millisecond)
+	window := s.window.Milliseconds()
+	start := now - now%window
+
+	key := s.options.keyPrefix + ":" + caller + ":" + procedure + ":" + strconv.FormatInt(start, 10)
+	return key, time.Duration(start+window-now) * time.Millisecond
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package quota
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	server, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+// withClock replaces the clock of the store.
+func withClock(now *time.Time) RedisOption {
+	return func(o *redisOptions) {
+		o.now = func() time.Time { return *now }
+	}
+}
+
+// consume checks and consumes a request like the middleware.
+func consume(store QuotaStore, caller, procedure string) error {
+	if err := store.Check(caller, procedure); err != nil {
+		return err
+	}
+	return store.Consume(caller, procedure)
+}
+
+func TestRedisStore(t *testing.T) {
+	server, client := newRedis(t)
+	now := time.Unix(3600, 0).Add(59 * time.Minute)
+	store := RedisStore(client, time.Hour,
+		DefaultLimit(2),
+		ProcedureLimit("limited", 1),
+		ProcedureLimit("unlimited", 0),
+		withClock(&now),
+	)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, consume(store, "caller", "procedure"))
+	}
+	err := consume(store, "caller", "procedure")
+	var exceeded *ExceededError
+	require.True(t, errors.As(err, &exceeded), "expected an ExceededError, got %v", err)
+	assert.Equal(t, &ExceededError{Caller: "caller", Procedure: "procedure", RetryAfter: time.Minute}, exceeded)
+
+	require.NoError(t, consume(store, "other", "procedure"), "callers must have quotas of their own")
+	require.NoError(t, consume(store, "caller", "limited"))
+	assert.Error(t, consume(store, "caller", "limited"), "procedure limits must override the default limit")
+	for i := 0; i < 3; i++ {
+		require.NoError(t, consume(store, "caller", "unlimited"))
+	}
+
+	ttl := server.TTL("quota:caller:procedure:3600000")
+	assert.Equal(t, time.Minute, ttl, "counts must expire at the end of the window")
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, consume(store, "caller", "procedure"), "quotas must renew in the next window")
+}
+
+func TestRedisStoreConsumeExceeds(t *testing.T) {
+	_, client := newRedis(t)
+	now := time.Unix(0, 0)
+	first := RedisStore(client, time.Minute, DefaultLimit(1), withClock(&now))
+	second := RedisStore(client, time.Minute, DefaultLimit(1), withClock(&now))
+
+	// Both stores pass the check before either consumes the quota.
+	require.NoError(t, first.Check("caller", "procedure"))
+	require.NoError(t, second.Check("caller", "procedure"))
+	require.NoError(t, first.Consume("caller", "procedure"))
+
+	err := second.Consume("caller", "procedure")
+	var exceeded *ExceededError
+	assert.True(t, errors.As(err, &exceeded), "expected an ExceededError, got %v", err)
+}
+
+func TestRedisStoreKeyPrefix(t *testing.T) {
+	server, client := newRedis(t)
+	now := time.Unix(0, 0)
+	store := RedisStore(client, time.Minute, DefaultLimit(1), KeyPrefix("myservice"), withClock(&now))
+
+	require.NoError(t, consume(store, "caller", "procedure"))
+	assert.True(t, server.Exists("myservice:caller:procedure:0"))
+}
+
+func TestRedisStoreFailure(t *testing.T) {
+	server, client := newRedis(t)
+	store := RedisStore(client, time.Minute, DefaultLimit(1))
+	server.Close()
+
+	err := store.Check("caller", "procedure")
+	require.Error(t, err)
+	var exceeded *ExceededError
+	assert.False(t, errors.As(err, &exceeded), "failures must not be reported as exceeded quotas")
+	assert.Error(t, store.Consume("caller", "procedure"))
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package shadow provides outbound middleware that mirrors a sample of unary
+// calls to a second outbound, to exercise a new implementation of a service
+// with production traffic without affecting callers.
+//
+// The middleware wraps the outbound of the current implementation, and
+// resolves the shadow outbound by its outbound key once the dispatcher is
+// built:
+//
+// 	mirror := shadow.NewUnaryOutboundMiddleware("users-v2",
+// 		shadow.SampleRate(0.05),
+// 		shadow.Compare(func(req *transport.Request, primary, shadow shadow.Result) {
+// 			if !bytes.Equal(primary.Body, shadow.Body) {
+// 				logger.Info("mismatch", zap.String("procedure", req.Procedure))
+// 			}
+// 		}),
+// 	)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		Outbounds: yarpc.Outbounds{
+// 			"users": {
+// 				Unary: middleware.ApplyUnaryOutbound(users, mirror),
+// 			},
+// 			"users-v2": {
+// 				ServiceName: "users",
+// 				Unary:       usersV2,
+// 			},
+// 		},
+// 	})
+// 	mirror.Resolve(dispatcher)
+//
+// Shadow calls carry the shadow header, are sent in the background with their
+// own context, and are never retried. Their responses are discarded unless a
+// comparator is given. They never delay or fail the calls they mirror.
+package shadow
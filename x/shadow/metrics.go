@@ -0,0 +1,88 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package shadow
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/zap"
+)
+
+const (
+	_serviceTag   = "service"
+	_procedureTag = "procedure"
+)
+
+type shadowMetrics struct {
+	calls    *metrics.CounterVector
+	failures *metrics.CounterVector
+	dropped  *metrics.CounterVector
+}
+
+func newShadowMetrics(meter *metrics.Scope, logger *zap.Logger) *shadowMetrics {
+	tags := []string{_serviceTag, _procedureTag}
+
+	calls, err := meter.CounterVector(metrics.Spec{
+		Name:    "shadow_calls",
+		Help:    "Total number of calls mirrored to the shadow outbound.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create shadow calls counter", zap.Error(err))
+	}
+	failures, err := meter.CounterVector(metrics.Spec{
+		Name:    "shadow_failures",
+		Help:    "Total number of mirrored calls that failed.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create shadow failures counter", zap.Error(err))
+	}
+	dropped, err := meter.CounterVector(metrics.Spec{
+		Name:    "shadow_dropped",
+		Help:    "Total number of sampled calls not mirrored because too many mirrored calls were in flight.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create shadow dropped counter", zap.Error(err))
+	}
+
+	return &shadowMetrics{
+		calls:    calls,
+		failures: failures,
+		dropped:  dropped,
+	}
+}
+
+// edgeMetrics are the counters of a service and procedure.
+type edgeMetrics struct {
+	calls    *metrics.Counter
+	failures *metrics.Counter
+	dropped  *metrics.Counter
+}
+
+func (m *shadowMetrics) edge(req *transport.Request) *edgeMetrics {
+	return &edgeMetrics{
+		calls:    m.calls.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+		failures: m.failures.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+		dropped:  m.dropped.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure),
+	}
+}
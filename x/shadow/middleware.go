@@ -0,0 +1,359 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package shadow
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/retry"
+	"go.uber.org/zap"
+)
+
+// HeaderKey is the header that marks shadow calls. Calls that already carry
+// it are never mirrored again.
+const HeaderKey = "yarpc-shadow"
+
+const (
+	_defaultSampleRate     = 0.01
+	_defaultMaxConcurrency = 100
+	_defaultTimeout        = time.Second
+)
+
+// Option customizes the behavior of the shadowing middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	outbound       transport.UnaryOutbound
+	sampleRate     float64
+	maxConcurrency int
+	timeout        time.Duration
+	compare        func(req *transport.Request, primary, shadow Result)
+	source         rand.Source
+	meter          *metrics.Scope
+	logger         *zap.Logger
+}
+
+// Outbound sets the shadow outbound directly, instead of resolving it from
+// the outbound key with Resolve.
+func Outbound(out transport.UnaryOutbound) Option {
+	return optionFunc(func(o *options) {
+		o.outbound = out
+	})
+}
+
+// SampleRate is the fraction of calls, between 0 and 1, that are mirrored.
+//
+// Defaults to 0.01.
+func SampleRate(rate float64) Option {
+	return optionFunc(func(o *options) {
+		if rate >= 0 && rate <= 1 {
+			o.sampleRate = rate
+		}
+	})
+}
+
+// MaxConcurrency is the maximum number of mirrored calls in flight at once.
+// Sampled calls beyond it are not mirrored, so that a slow shadow cannot
+// pile up work.
+//
+// Defaults to 100.
+func MaxConcurrency(n int) Option {
+	return optionFunc(func(o *options) {
+		if n > 0 {
+			o.maxConcurrency = n
+		}
+	})
+}
+
+// Timeout bounds mirrored calls whose original call has no deadline.
+// Mirrored calls otherwise share the deadline of their original call.
+//
+// Defaults to one second.
+func Timeout(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d > 0 {
+			o.timeout = d
+		}
+	})
+}
+
+// Compare calls f with the results of every mirrored call and of its
+// original call, once both have finished, from a background goroutine.
+//
+// Comparing results requires buffering the response bodies of the original
+// calls that are mirrored. Without a comparator, the responses of mirrored
+// calls are discarded.
+func Compare(f func(req *transport.Request, primary, shadow Result)) Option {
+	return optionFunc(func(o *options) {
+		o.compare = f
+	})
+}
+
+// Source specifies the source of randomness that samples calls.
+//
+// Defaults to a source seeded with the current time.
+func Source(source rand.Source) Option {
+	return optionFunc(func(o *options) {
+		o.source = source
+	})
+}
+
+// Meter sets the scope for the metrics of the middleware.
+func Meter(meter *metrics.Scope) Option {
+	return optionFunc(func(o *options) {
+		o.meter = meter
+	})
+}
+
+// Logger sets the logger for the middleware.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+// Result is the outcome of a call, as given to comparators.
+type Result struct {
+	Headers          transport.Headers
+	Body             []byte
+	ApplicationError bool
+	Err              error
+}
+
+var _ middleware.UnaryOutbound = (*Middleware)(nil)
+
+// Middleware is a unary outbound middleware that mirrors a sample of calls to
+// a shadow outbound.
+//
+// The middleware buffers the body of each request it mirrors, so both calls
+// can read it.
+type Middleware struct {
+	outboundKey string
+	opts        options
+	metrics     *shadowMetrics
+	outbound    atomic.Value // transport.UnaryOutbound
+	inflight    atomic.Int64
+
+	lock   sync.Mutex
+	random *rand.Rand
+}
+
+// NewUnaryOutboundMiddleware returns a unary outbound middleware that
+// mirrors calls to the outbound with the given key, once resolved with
+// Resolve.
+//
+// Calls are not mirrored until the shadow outbound is known.
+func NewUnaryOutboundMiddleware(outboundKey string, opts ...Option) *Middleware {
+	o := options{
+		sampleRate:     _defaultSampleRate,
+		maxConcurrency: _defaultMaxConcurrency,
+		timeout:        _defaultTimeout,
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.logger == nil {
+		o.logger = zap.NewNop()
+	}
+	if o.source == nil {
+		o.source = rand.NewSource(time.Now().UnixNano())
+	}
+
+	m := &Middleware{
+		outboundKey: outboundKey,
+		opts:        o,
+		metrics:     newShadowMetrics(o.meter, o.logger),
+		random:      rand.New(o.source),
+	}
+	if o.outbound != nil {
+		m.outbound.Store(o.outbound)
+	}
+	return m
+}
+
+// Resolve looks up the shadow outbound by its outbound key, typically in the
+// dispatcher, and starts mirroring calls to it.
+//
+// Like ClientConfig, this panics if the outbound key is unknown.
+func (m *Middleware) Resolve(provider transport.ClientConfigProvider) {
+	m.outbound.Store(provider.ClientConfig(m.outboundKey).GetUnaryOutbound())
+}
+
+func (m *Middleware) shadow() transport.UnaryOutbound {
+	out, _ := m.outbound.Load().(transport.UnaryOutbound)
+	return out
+}
+
+func (m *Middleware) sample() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.random.Float64() < m.opts.sampleRate
+}
+
+// acquire reserves room for a mirrored call, if fewer than the maximum are
+// in flight.
+func (m *Middleware) acquire() bool {
+	if m.inflight.Inc() > int64(m.opts.maxConcurrency) {
+		m.inflight.Dec()
+		return false
+	}
+	return true
+}
+
+// Call sends the request to the outbound, mirroring it to the shadow
+// outbound if it is sampled.
+//
+// The result of the call is never affected by the mirrored call.
+func (m *Middleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	shadow := m.shadow()
+	if shadow == nil || isShadow(req) || !m.sample() {
+		return out.Call(ctx, req)
+	}
+	edge := m.metrics.edge(req)
+	if !m.acquire() {
+		edge.dropped.Inc()
+		return out.Call(ctx, req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			m.inflight.Dec()
+			return nil, err
+		}
+	}
+	primaryReq := *req
+	if req.Body != nil {
+		primaryReq.Body = bytes.NewReader(body)
+	}
+
+	var primary chan Result
+	if m.opts.compare != nil {
+		primary = make(chan Result, 1)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(m.opts.timeout)
+	}
+	go m.mirror(deadline, cloneRequest(req, body), shadow, edge, primary)
+
+	res, err := out.Call(ctx, &primaryReq)
+	if primary == nil {
+		return res, err
+	}
+	if err != nil {
+		primary <- Result{Err: err}
+		return res, err
+	}
+
+	resBody, err := readBody(res)
+	if err != nil {
+		primary <- Result{Err: err}
+		return nil, err
+	}
+	primary <- Result{
+		Headers:          res.Headers,
+		Body:             resBody,
+		ApplicationError: res.ApplicationError,
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+	return res, nil
+}
+
+// mirror sends the shadow request and, given the result of the original
+// call, compares both.
+//
+// The shadow request has its own context, independent of the cancellation
+// of the original call, that disables retries.
+func (m *Middleware) mirror(deadline time.Time, req *transport.Request, shadow transport.UnaryOutbound, edge *edgeMetrics, primary <-chan Result) {
+	defer m.inflight.Dec()
+
+	ctx, cancel := context.WithDeadline(retry.WithNoRetry(context.Background()), deadline)
+	defer cancel()
+
+	edge.calls.Inc()
+	res, err := shadow.Call(ctx, req)
+	result := Result{Err: err}
+	if err == nil {
+		result.Headers = res.Headers
+		result.ApplicationError = res.ApplicationError
+		result.Body, result.Err = readBody(res)
+	}
+	if result.Err != nil {
+		edge.failures.Inc()
+		m.opts.logger.Debug("shadow call failed",
+			zap.String("service", req.Service),
+			zap.String("procedure", req.Procedure),
+			zap.Error(result.Err))
+	}
+
+	if primary != nil {
+		m.opts.compare(req, <-primary, result)
+	}
+}
+
+// cloneRequest copies the request with its own headers and body, marked as
+// a shadow.
+func cloneRequest(req *transport.Request, body []byte) *transport.Request {
+	clone := *req
+	clone.Headers = transport.NewHeadersWithCapacity(req.Headers.Len() + 1)
+	for k, v := range req.Headers.OriginalItems() {
+		clone.Headers = clone.Headers.With(k, v)
+	}
+	clone.Headers = clone.Headers.With(HeaderKey, "true")
+	if req.Body != nil {
+		clone.Body = bytes.NewReader(body)
+	}
+	return &clone
+}
+
+func isShadow(req *transport.Request) bool {
+	_, ok := req.Headers.Get(HeaderKey)
+	return ok
+}
+
+// readBody reads and closes the body of the response.
+func readBody(res *transport.Response) ([]byte, error) {
+	if res == nil || res.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if closeErr := res.Body.Close(); err == nil {
+		err = closeErr
+	}
+	return body, err
+}
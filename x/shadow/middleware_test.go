@@ -0,0 +1,322 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package shadow
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/retry"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// fakeOutbound answers calls with the given function, recording the
+// requests it receives.
+type fakeOutbound struct {
+	transport.UnaryOutbound
+
+	calls atomic.Int32
+	call  func(context.Context, *transport.Request) (*transport.Response, error)
+
+	lock    sync.Mutex
+	bodies  []string
+	headers []transport.Headers
+}
+
+func (o *fakeOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	o.calls.Inc()
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	o.lock.Lock()
+	o.bodies = append(o.bodies, string(body))
+	o.headers = append(o.headers, req.Headers)
+	o.lock.Unlock()
+	return o.call(ctx, req)
+}
+
+func respond(body string) func(context.Context, *transport.Request) (*transport.Response, error) {
+	return func(context.Context, *transport.Request) (*transport.Response, error) {
+		return &transport.Response{
+			Headers: transport.NewHeaders().With("from", body),
+			Body:    ioutil.NopCloser(bytes.NewReader([]byte(body))),
+		}, nil
+	}
+}
+
+func fail(err error) func(context.Context, *transport.Request) (*transport.Response, error) {
+	return func(context.Context, *transport.Request) (*transport.Response, error) {
+		return nil, err
+	}
+}
+
+func newRequest() *transport.Request {
+	return &transport.Request{
+		Service:   "service",
+		Procedure: "procedure",
+		Headers:   transport.NewHeaders().With("key", "value"),
+		Body:      bytes.NewReader([]byte("body")),
+	}
+}
+
+func callPrimary(t *testing.T, ctx context.Context, mw *Middleware, out transport.UnaryOutbound) (*transport.Response, string, error) {
+	res, err := mw.Call(ctx, newRequest(), out)
+	if err != nil {
+		return res, "", err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	return res, string(body), nil
+}
+
+func counters(root *metrics.Root) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, c := range root.Snapshot().Counters {
+		counts[c.Name] += c.Value
+	}
+	return counts
+}
+
+func TestMirror(t *testing.T) {
+	root := metrics.New()
+	primary := &fakeOutbound{call: respond("primary")}
+	shadow := &fakeOutbound{call: respond("shadow")}
+
+	type comparison struct {
+		req             *transport.Request
+		primary, shadow Result
+	}
+	compared := make(chan comparison, 1)
+	mw := NewUnaryOutboundMiddleware("shadow",
+		Outbound(shadow),
+		SampleRate(1),
+		Meter(root.Scope()),
+		Compare(func(req *transport.Request, primary, shadow Result) {
+			compared <- comparison{req, primary, shadow}
+		}),
+	)
+
+	req := newRequest()
+	res, err := mw.Call(context.Background(), req, primary)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "primary", string(body))
+	assert.Equal(t, transport.NewHeaders().With("key", "value"), req.Headers, "the request must not be modified")
+
+	c := <-compared
+	assert.Equal(t, Result{Headers: transport.NewHeaders().With("from", "primary"), Body: []byte("primary")}, c.primary)
+	assert.Equal(t, Result{Headers: transport.NewHeaders().With("from", "shadow"), Body: []byte("shadow")}, c.shadow)
+	assert.Equal(t, []string{"body"}, primary.bodies)
+	assert.Equal(t, []string{"body"}, shadow.bodies)
+	assert.Equal(t, []transport.Headers{transport.NewHeaders().With("key", "value")}, primary.headers)
+	assert.Equal(t, []transport.Headers{
+		transport.NewHeaders().With("key", "value").With(HeaderKey, "true"),
+	}, shadow.headers)
+	assert.Equal(t, map[string]int64{
+		"shadow_calls":    1,
+		"shadow_failures": 0,
+		"shadow_dropped":  0,
+	}, counters(root))
+}
+
+func TestShadowFailureDoesNotAffectPrimary(t *testing.T) {
+	root := metrics.New()
+	done := make(chan Result, 1)
+	mw := NewUnaryOutboundMiddleware("shadow",
+		Outbound(&fakeOutbound{call: fail(yarpcerrors.InternalErrorf("shadow failed"))}),
+		SampleRate(1),
+		Meter(root.Scope()),
+		Compare(func(_ *transport.Request, _, shadow Result) { done <- shadow }),
+	)
+
+	_, body, err := callPrimary(t, context.Background(), mw, &fakeOutbound{call: respond("primary")})
+	require.NoError(t, err)
+	assert.Equal(t, "primary", body)
+	assert.Equal(t, yarpcerrors.InternalErrorf("shadow failed"), (<-done).Err)
+	assert.Equal(t, int64(1), counters(root)["shadow_failures"])
+}
+
+func TestPrimaryFailureIsReturned(t *testing.T) {
+	done := make(chan Result, 1)
+	mw := NewUnaryOutboundMiddleware("shadow",
+		Outbound(&fakeOutbound{call: respond("shadow")}),
+		SampleRate(1),
+		Compare(func(_ *transport.Request, primary, _ Result) { done <- primary }),
+	)
+
+	_, _, err := callPrimary(t, context.Background(), mw, &fakeOutbound{call: fail(yarpcerrors.UnavailableErrorf("primary failed"))})
+	assert.Equal(t, yarpcerrors.UnavailableErrorf("primary failed"), err)
+	assert.Equal(t, err, (<-done).Err)
+}
+
+func TestHangingShadow(t *testing.T) {
+	root := metrics.New()
+	released := make(chan struct{})
+	shadow := &fakeOutbound{call: func(ctx context.Context, _ *transport.Request) (*transport.Response, error) {
+		<-ctx.Done()
+		released <- struct{}{}
+		return nil, ctx.Err()
+	}}
+	mw := NewUnaryOutboundMiddleware("shadow",
+		Outbound(shadow),
+		SampleRate(1),
+		MaxConcurrency(1),
+		Timeout(50*time.Millisecond),
+		Meter(root.Scope()),
+	)
+	primary := &fakeOutbound{call: respond("primary")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, body, err := callPrimary(t, ctx, mw, primary)
+	require.NoError(t, err)
+	assert.Equal(t, "primary", body)
+	// Canceling the original call must not cancel the shadow.
+	cancel()
+
+	// The hanging shadow holds the only slot.
+	_, body, err = callPrimary(t, context.Background(), mw, primary)
+	require.NoError(t, err)
+	assert.Equal(t, "primary", body)
+
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("hanging shadow call was not bounded by the timeout")
+	}
+	assert.Equal(t, int32(1), shadow.calls.Load())
+	assert.Equal(t, int32(2), primary.calls.Load())
+	require.Eventually(t, func() bool { return mw.inflight.Load() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, int64(1), counters(root)["shadow_failures"])
+	assert.Equal(t, int64(1), counters(root)["shadow_dropped"])
+}
+
+func TestShadowSharesDeadline(t *testing.T) {
+	deadlines := make(chan time.Time, 1)
+	mw := NewUnaryOutboundMiddleware("shadow",
+		Outbound(&fakeOutbound{call: func(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+			d, _ := ctx.Deadline()
+			deadlines <- d
+			return respond("shadow")(ctx, req)
+		}}),
+		SampleRate(1),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	_, _, err := callPrimary(t, ctx, mw, &fakeOutbound{call: respond("primary")})
+	require.NoError(t, err)
+	assert.Equal(t, want, <-deadlines)
+}
+
+func TestShadowIsNotRetried(t *testing.T) {
+	done := make(chan Result, 1)
+	shadow := &fakeOutbound{call: fail(yarpcerrors.UnavailableErrorf("shadow failed"))}
+	retried := middleware.ApplyUnaryOutbound(shadow, retry.NewMiddleware(retry.Config{
+		Default: &retry.Policy{MaxAttempts: 3},
+	}))
+	mw := NewUnaryOutboundMiddleware("shadow",
+		Outbound(retried),
+		SampleRate(1),
+		Compare(func(_ *transport.Request, _, shadow Result) { done <- shadow }),
+	)
+
+	_, _, err := callPrimary(t, context.Background(), mw, &fakeOutbound{call: respond("primary")})
+	require.NoError(t, err)
+	assert.Error(t, (<-done).Err)
+	assert.Equal(t, int32(1), shadow.calls.Load(), "shadow calls must not be retried")
+}
+
+func TestSampling(t *testing.T) {
+	shadow := &fakeOutbound{call: respond("shadow")}
+	primary := &fakeOutbound{call: respond("primary")}
+
+	mw := NewUnaryOutboundMiddleware("shadow", Outbound(shadow), SampleRate(0))
+	for i := 0; i < 10; i++ {
+		_, _, err := callPrimary(t, context.Background(), mw, primary)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(0), shadow.calls.Load())
+
+	mw = NewUnaryOutboundMiddleware("shadow", Outbound(shadow), SampleRate(0.5), MaxConcurrency(1000), Source(rand.NewSource(1)))
+	for i := 0; i < 1000; i++ {
+		_, _, err := callPrimary(t, context.Background(), mw, primary)
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool { return mw.inflight.Load() == 0 }, time.Second, time.Millisecond)
+	assert.InDelta(t, 500, shadow.calls.Load(), 50)
+}
+
+func TestShadowRequestsAreNotMirrored(t *testing.T) {
+	shadow := &fakeOutbound{call: respond("shadow")}
+	mw := NewUnaryOutboundMiddleware("shadow", Outbound(shadow), SampleRate(1))
+
+	req := newRequest()
+	req.Headers = req.Headers.With(HeaderKey, "true")
+	_, err := mw.Call(context.Background(), req, &fakeOutbound{call: respond("primary")})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), shadow.calls.Load())
+}
+
+type fakeClientConfig struct {
+	transport.ClientConfig
+
+	out transport.UnaryOutbound
+}
+
+func (c fakeClientConfig) GetUnaryOutbound() transport.UnaryOutbound { return c.out }
+
+type fakeProvider map[string]transport.UnaryOutbound
+
+func (p fakeProvider) ClientConfig(key string) transport.ClientConfig {
+	return fakeClientConfig{out: p[key]}
+}
+
+func TestResolve(t *testing.T) {
+	shadow := &fakeOutbound{call: respond("shadow")}
+	primary := &fakeOutbound{call: respond("primary")}
+	mw := NewUnaryOutboundMiddleware("users-v2", SampleRate(1))
+
+	_, _, err := callPrimary(t, context.Background(), mw, primary)
+	require.NoError(t, err)
+
+	mw.Resolve(fakeProvider{"users-v2": shadow})
+	_, _, err = callPrimary(t, context.Background(), mw, primary)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return mw.inflight.Load() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, int32(2), primary.calls.Load())
+	assert.Equal(t, int32(1), shadow.calls.Load(), "calls must only be mirrored once the shadow is resolved")
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package slo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+)
+
+// SLONotifier is informed of requests that breach the latency objective of
+// their procedure.
+type SLONotifier interface {
+	// OnBreach is called after the handler of a request to the given
+	// procedure returns, if it took longer than the objective.
+	OnBreach(procedure string, elapsed, slo time.Duration)
+}
+
+// NewInboundMiddleware returns middleware that measures how long unary
+// handlers take and calls the notifier synchronously, after the handler
+// returns, for every request that takes longer than the objective of its
+// procedure.
+//
+// The keys of the map are procedure names, where "*" matches any sequence
+// of characters. If several keys match a procedure, a key without "*" takes
+// precedence, followed by the longest key. Requests to procedures that match
+// no key, or a key with a non-positive objective, are not measured.
+func NewInboundMiddleware(slos map[string]time.Duration, notifier SLONotifier) middleware.UnaryInbound {
+	patterns := make([]pattern, 0, len(slos))
+	for key, slo := range slos {
+		patterns = append(patterns, pattern{glob: key, slo: slo})
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return patterns[i].morePrecise(patterns[j])
+	})
+
+	return &inboundMiddleware{
+		patterns: patterns,
+		notifier: notifier,
+		now:      time.Now,
+	}
+}
+
+type inboundMiddleware struct {
+	// patterns are sorted from the most to the least precise.
+	patterns []pattern
+	notifier SLONotifier
+	now      func() time.Time
+
+	// objectives caches the objective of every procedure, as a
+	// time.Duration, so that patterns are only matched once per procedure.
+	objectives sync.Map
+}
+
+func (m *inboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	slo := m.objective(req.Procedure)
+	if slo <= 0 {
+		return h.Handle(ctx, req, resw)
+	}
+
+	start := m.now()
+	err := h.Handle(ctx, req, resw)
+	if elapsed := m.now().Sub(start); elapsed > slo {
+		m.notifier.OnBreach(req.Procedure, elapsed, slo)
+	}
+	return err
+}
+
+func (m *inboundMiddleware) objective(procedure string) time.Duration {
+	if slo, ok := m.objectives.Load(procedure); ok {
+		return slo.(time.Duration)
+	}
+
+	var slo time.Duration
+	for _, p := range m.patterns {
+		if matchGlob(p.glob, procedure) {
+			slo = p.slo
+			break
+		}
+	}
+	m.objectives.Store(procedure, slo)
+	return slo
+}
+
+type pattern struct {
+	glob string
+	slo  time.Duration
+}
+
+// morePrecise returns whether the pattern takes precedence over the other
+// for procedures that both match.
+func (p pattern) morePrecise(other pattern) bool {
+	literal, otherLiteral := !strings.Contains(p.glob, "*"), !strings.Contains(other.glob, "*")
+	if literal != otherLiteral {
+		return literal
+	}
+	if len(p.glob) != len(other.glob) {
+		return len(p.glob) > len(other.glob)
+	}
+	return p.glob < other.glob
+}
+
+// matchGlob returns whether the name matches the pattern, where "*" matches
+// any sequence of characters, including none.
+func matchGlob(pattern, name string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+
+	first, last := parts[0], parts[len(parts)-1]
+	if !strings.HasPrefix(name, first) {
+		return false
+	}
+	name = name[len(first):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return strings.HasSuffix(name, last)
+}
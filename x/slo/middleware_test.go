@@ -0,0 +1,150 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package slo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+)
+
+type breach struct {
+	procedure string
+	elapsed   time.Duration
+	slo       time.Duration
+}
+
+type recordingNotifier struct {
+	breaches []breach
+}
+
+func (n *recordingNotifier) OnBreach(procedure string, elapsed, slo time.Duration) {
+	n.breaches = append(n.breaches, breach{procedure, elapsed, slo})
+}
+
+// sleepingHandler advances a fake clock by its latency.
+type sleepingHandler struct {
+	now     *time.Time
+	latency time.Duration
+	err     error
+}
+
+func (h *sleepingHandler) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	*h.now = h.now.Add(h.latency)
+	return h.err
+}
+
+func TestInboundMiddleware(t *testing.T) {
+	notifier := &recordingNotifier{}
+	mw := NewInboundMiddleware(map[string]time.Duration{
+		"KeyValue::*":        100 * time.Millisecond,
+		"KeyValue::getValue": 10 * time.Millisecond,
+		"KeyValue::set*":     50 * time.Millisecond,
+		"Cache::*":           0,
+	}, notifier).(*inboundMiddleware)
+	now := time.Unix(1000, 0)
+	mw.now = func() time.Time { return now }
+
+	tests := []struct {
+		procedure  string
+		latency    time.Duration
+		wantBreach bool
+		wantSLO    time.Duration
+	}{
+		{procedure: "KeyValue::getValue", latency: 10 * time.Millisecond},
+		{procedure: "KeyValue::getValue", latency: 11 * time.Millisecond, wantBreach: true, wantSLO: 10 * time.Millisecond},
+		{procedure: "KeyValue::setValue", latency: 50 * time.Millisecond},
+		{procedure: "KeyValue::setValue", latency: 60 * time.Millisecond, wantBreach: true, wantSLO: 50 * time.Millisecond},
+		{procedure: "KeyValue::delete", latency: 60 * time.Millisecond},
+		{procedure: "KeyValue::delete", latency: time.Second, wantBreach: true, wantSLO: 100 * time.Millisecond},
+		{procedure: "Cache::get", latency: time.Hour},
+		{procedure: "Other::get", latency: time.Hour},
+	}
+
+	for _, tt := range tests {
+		notifier.breaches = nil
+		handlerErr := errors.New("great sadness")
+		h := &sleepingHandler{now: &now, latency: tt.latency, err: handlerErr}
+
+		err := mw.Handle(context.Background(), &transport.Request{Procedure: tt.procedure}, nil, h)
+		assert.Equal(t, handlerErr, err, "%v: handler error must be returned", tt.procedure)
+
+		if !tt.wantBreach {
+			assert.Empty(t, notifier.breaches, "%v took %v", tt.procedure, tt.latency)
+			continue
+		}
+		assert.Equal(t, []breach{{tt.procedure, tt.latency, tt.wantSLO}}, notifier.breaches,
+			"%v took %v", tt.procedure, tt.latency)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"KeyValue::getValue", "KeyValue::getValue", true},
+		{"KeyValue::getValue", "KeyValue::getValues", false},
+		{"*", "", true},
+		{"*", "anything/at::all", true},
+		{"KeyValue::*", "KeyValue::getValue", true},
+		{"KeyValue::*", "Cache::getValue", false},
+		{"*::getValue", "KeyValue::getValue", true},
+		{"*::getValue", "KeyValue::setValue", false},
+		{"Key*::*Value", "KeyValue::getValue", true},
+		{"Key*::*Value", "KeyValue::get", false},
+		{"a*a", "a", false},
+		{"a*a", "aa", true},
+		{"a**b", "ab", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchGlob(tt.pattern, tt.name), "matchGlob(%q, %q)", tt.pattern, tt.name)
+	}
+}
+
+func TestMetricsNotifier(t *testing.T) {
+	root := metrics.New()
+	notifier := NewMetricsNotifier(root.Scope(), nil)
+
+	slo := 10 * time.Millisecond
+	for _, elapsed := range []time.Duration{11, 19, 20, 49, 50, 100} {
+		notifier.OnBreach("KeyValue::getValue", elapsed*time.Millisecond, slo)
+	}
+
+	snap := root.Snapshot()
+	require.Len(t, snap.Counters, 3)
+	got := make(map[string]int64)
+	for _, c := range snap.Counters {
+		assert.Equal(t, "slo_breaches", c.Name)
+		// Tag values are scrubbed of characters that metrics systems reject.
+		assert.Equal(t, "KeyValue__getValue", c.Tags["procedure"])
+		got[c.Tags["severity"]] = c.Value
+	}
+	assert.Equal(t, map[string]int64{"slo": 2, "2xslo": 2, "5xslo": 2}, got)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package slo
+
+import (
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	_procedureTag = "procedure"
+	_severityTag  = "severity"
+)
+
+// NewMetricsNotifier returns a notifier that counts breaches in the
+// "slo_breaches" counter, tagged with the procedure and with the severity of
+// the breach: "slo" if the request took longer than the objective, "2xslo"
+// if it took at least twice as long, and "5xslo" if it took at least five
+// times as long.
+func NewMetricsNotifier(meter *metrics.Scope, logger *zap.Logger) SLONotifier {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	breaches, err := meter.CounterVector(metrics.Spec{
+		Name:      "slo_breaches",
+		Help:      "Total number of requests that took longer than the latency objective of their procedure.",
+		ConstTags: metrics.Tags{"component": "yarpc"},
+		VarTags:   []string{_procedureTag, _severityTag},
+	})
+	if err != nil {
+		logger.Error("failed to create slo breaches counter", zap.Error(err))
+	}
+	return &metricsNotifier{breaches: breaches}
+}
+
+type metricsNotifier struct {
+	breaches *metrics.CounterVector
+}
+
+func (n *metricsNotifier) OnBreach(procedure string, elapsed, slo time.Duration) {
+	n.breaches.MustGet(_procedureTag, procedure, _severityTag, severity(elapsed, slo)).Inc()
+}
+
+func severity(elapsed, slo time.Duration) string {
+	switch {
+	case elapsed >= 5*slo:
+		return "5xslo"
+	case elapsed >= 2*slo:
+		return "2xslo"
+	default:
+		return "slo"
+	}
+}
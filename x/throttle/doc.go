@@ -0,0 +1,50 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package throttle provides outbound middleware for adaptive client-side
+// throttling: callers fail calls locally when the downstream is rejecting
+// them, rather than sending requests it would reject anyway.
+//
+// The middleware keeps a throttle for every service and procedure it calls,
+// which counts the requests to the procedure and the requests the downstream
+// accepted over a sliding window. Calls fail locally with a
+// ResourceExhausted error with probability
+//
+// 	max(0, (requests - multiplier*accepts) / (requests + 1))
+//
+// so that callers keep sending about multiplier requests per accepted
+// request. Rejections are ResourceExhausted errors of the downstream, and
+// calls failed locally count as requests that were not accepted.
+//
+// When a rejection carries a retry-after hint, as RetryInfo error details,
+// the throttle fails every call to the procedure locally until the delay has
+// elapsed, with the remaining delay as RetryInfo details.
+//
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:      "myservice",
+// 		Outbounds: outbounds,
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary: throttle.NewOutboundMiddleware(
+// 				throttle.Multiplier(1.5),
+// 				throttle.Meter(meter),
+// 			),
+// 		},
+// 	})
+package throttle
@@ -0,0 +1,95 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package throttle
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	_serviceTag   = "service"
+	_procedureTag = "procedure"
+	_reasonTag    = "reason"
+
+	_probabilityReason = "probability"
+	_pushbackReason    = "pushback"
+)
+
+type throttleMetrics struct {
+	sent      *metrics.CounterVector
+	rejected  *metrics.CounterVector
+	pushbacks *metrics.CounterVector
+}
+
+func newThrottleMetrics(meter *metrics.Scope, logger *zap.Logger) *throttleMetrics {
+	tags := []string{_serviceTag, _procedureTag}
+
+	sent, err := meter.CounterVector(metrics.Spec{
+		Name:    "throttle_sent",
+		Help:    "Total number of calls sent to the downstream by the client-side throttle.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create throttle sent counter", zap.Error(err))
+	}
+	rejected, err := meter.CounterVector(metrics.Spec{
+		Name:    "throttle_rejected",
+		Help:    "Total number of calls failed locally by the client-side throttle, by reason.",
+		VarTags: append(tags, _reasonTag),
+	})
+	if err != nil {
+		logger.Error("failed to create throttle rejected counter", zap.Error(err))
+	}
+	pushbacks, err := meter.CounterVector(metrics.Spec{
+		Name:    "throttle_pushbacks",
+		Help:    "Total number of rejections from the downstream that asked to retry after a delay.",
+		VarTags: tags,
+	})
+	if err != nil {
+		logger.Error("failed to create throttle pushbacks counter", zap.Error(err))
+	}
+
+	return &throttleMetrics{
+		sent:      sent,
+		rejected:  rejected,
+		pushbacks: pushbacks,
+	}
+}
+
+// edgeMetrics are the metrics of the throttle of a service and procedure.
+type edgeMetrics struct {
+	sent                *metrics.Counter
+	rejectedProbability *metrics.Counter
+	rejectedPushback    *metrics.Counter
+	pushbacks           *metrics.Counter
+}
+
+func (m *throttleMetrics) edge(k procedureKey) *edgeMetrics {
+	return &edgeMetrics{
+		sent: m.sent.MustGet(_serviceTag, k.service, _procedureTag, k.procedure),
+		rejectedProbability: m.rejected.MustGet(
+			_serviceTag, k.service, _procedureTag, k.procedure, _reasonTag, _probabilityReason),
+		rejectedPushback: m.rejected.MustGet(
+			_serviceTag, k.service, _procedureTag, k.procedure, _reasonTag, _pushbackReason),
+		pushbacks: m.pushbacks.MustGet(_serviceTag, k.service, _procedureTag, k.procedure),
+	}
+}
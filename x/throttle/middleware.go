@@ -0,0 +1,308 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package throttle
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/window"
+	"go.uber.org/yarpc/pkg/clock"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+const (
+	_defaultWindow      = time.Minute
+	_defaultMultiplier  = 2
+	_defaultMaxPushback = 30 * time.Second
+)
+
+// Option customizes the behavior of the throttling middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	window      time.Duration
+	multiplier  float64
+	maxPushback time.Duration
+	meter       *metrics.Scope
+	logger      *zap.Logger
+	clock       clock.Clock
+}
+
+// Window is the duration over which the requests and accepts of each
+// procedure are counted.
+//
+// Defaults to one minute.
+func Window(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d > 0 {
+			o.window = d
+		}
+	})
+}
+
+// Multiplier is how many requests per request accepted by the downstream the
+// throttle sends before it starts failing calls locally. Lower multipliers
+// throttle sooner.
+//
+// Defaults to 2.
+func Multiplier(k float64) Option {
+	return optionFunc(func(o *options) {
+		if k >= 1 {
+			o.multiplier = k
+		}
+	})
+}
+
+// MaxPushback caps how long a retry-after hint of the downstream stops the
+// calls to a procedure.
+//
+// Defaults to 30 seconds.
+func MaxPushback(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		if d >= 0 {
+			o.maxPushback = d
+		}
+	})
+}
+
+// Meter sets the scope for the metrics of the middleware.
+func Meter(meter *metrics.Scope) Option {
+	return optionFunc(func(o *options) {
+		o.meter = meter
+	})
+}
+
+// Logger sets the logger for the middleware.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+// Clock sets the clock of the windows and retry-after hints, for tests that
+// control time.
+//
+// Defaults to the system clock.
+func Clock(c clock.Clock) Option {
+	return optionFunc(func(o *options) {
+		o.clock = c
+	})
+}
+
+type procedureKey struct {
+	service   string
+	procedure string
+}
+
+// throttle counts the requests to a procedure, as successes if the
+// downstream accepted them, and as failures otherwise.
+type throttle struct {
+	metrics       *edgeMetrics
+	window        window.Window
+	pushbackUntil time.Time
+}
+
+var (
+	_ middleware.UnaryOutbound  = (*Middleware)(nil)
+	_ middleware.OnewayOutbound = (*Middleware)(nil)
+)
+
+// Middleware is an outbound middleware that throttles the calls to
+// procedures that the downstream rejects.
+type Middleware struct {
+	opts    options
+	metrics *throttleMetrics
+	now     func() time.Time
+
+	lock      sync.Mutex
+	random    *rand.Rand
+	throttles map[procedureKey]*throttle
+}
+
+// NewOutboundMiddleware returns an outbound middleware with a throttle for
+// every procedure it calls.
+func NewOutboundMiddleware(opts ...Option) *Middleware {
+	o := options{
+		window:      _defaultWindow,
+		multiplier:  _defaultMultiplier,
+		maxPushback: _defaultMaxPushback,
+		clock:       clock.System,
+	}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.logger == nil {
+		o.logger = zap.NewNop()
+	}
+
+	return &Middleware{
+		opts:      o,
+		metrics:   newThrottleMetrics(o.meter, o.logger),
+		now:       o.clock.Now,
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		throttles: make(map[procedureKey]*throttle),
+	}
+}
+
+// Call sends the request unless the throttle of its procedure fails it
+// locally, and records whether the downstream accepted it.
+func (m *Middleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	k := procedureKey{req.Service, req.Procedure}
+	if err := m.allow(k); err != nil {
+		return nil, err
+	}
+	res, err := out.Call(ctx, req)
+	m.record(k, err)
+	return res, err
+}
+
+// CallOneway sends the request unless the throttle of its procedure fails
+// it locally, and records whether the downstream accepted it.
+func (m *Middleware) CallOneway(ctx context.Context, req *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	k := procedureKey{req.Service, req.Procedure}
+	if err := m.allow(k); err != nil {
+		return nil, err
+	}
+	ack, err := out.CallOneway(ctx, req)
+	m.record(k, err)
+	return ack, err
+}
+
+// RejectProbability returns the probability with which the throttle of a
+// procedure currently fails its calls locally, ignoring retry-after hints.
+func (m *Middleware) RejectProbability(service, procedure string) float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	t, ok := m.throttles[procedureKey{service, procedure}]
+	if !ok {
+		return 0
+	}
+	return m.rejectProbability(t, m.now())
+}
+
+// rejectProbability is the client request rejection probability of the
+// throttle: max(0, (requests - multiplier*accepts) / (requests + 1)).
+//
+// rejectProbability must be called under the middleware lock.
+func (m *Middleware) rejectProbability(t *throttle, now time.Time) float64 {
+	accepts, rejects := t.window.Counts(now)
+	requests := float64(accepts + rejects)
+	return math.Max(0, (requests-m.opts.multiplier*float64(accepts))/(requests+1))
+}
+
+// allow returns an error if the call must fail locally, counting it as a
+// request that the downstream did not accept.
+func (m *Middleware) allow(k procedureKey) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	t, ok := m.throttles[k]
+	if !ok {
+		t = &throttle{
+			metrics: m.metrics.edge(k),
+			window:  window.New(m.opts.window),
+		}
+		m.throttles[k] = t
+	}
+
+	now := m.now()
+	if now.Before(t.pushbackUntil) {
+		t.window.Record(now, true)
+		t.metrics.rejectedPushback.Inc()
+		return newPushbackError(k, t.pushbackUntil.Sub(now))
+	}
+	if p := m.rejectProbability(t, now); p > 0 && m.random.Float64() < p {
+		t.window.Record(now, true)
+		t.metrics.rejectedProbability.Inc()
+		return yarpcerrors.ResourceExhaustedErrorf(
+			"client throttled call to procedure %q of service %q", k.procedure, k.service)
+	}
+	t.metrics.sent.Inc()
+	return nil
+}
+
+func (m *Middleware) record(k procedureKey, err error) {
+	rejected := yarpcerrors.FromError(err).Code() == yarpcerrors.CodeResourceExhausted
+	var delay time.Duration
+	var pushback bool
+	if rejected {
+		delay, pushback = retryAfter(err)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	t := m.throttles[k]
+	now := m.now()
+	t.window.Record(now, rejected)
+	if !pushback {
+		return
+	}
+	t.metrics.pushbacks.Inc()
+	if delay > m.opts.maxPushback {
+		delay = m.opts.maxPushback
+	}
+	if until := now.Add(delay); until.After(t.pushbackUntil) {
+		t.pushbackUntil = until
+	}
+}
+
+func newPushbackError(k procedureKey, wait time.Duration) error {
+	err := yarpcerrors.ResourceExhaustedErrorf(
+		"client throttled call to procedure %q of service %q, retry after %v", k.procedure, k.service, wait)
+	return yarpcerrors.WithDetails(err, &rpc.RetryInfo{RetryDelay: types.DurationProto(wait)})
+}
+
+// retryAfter returns the delay of the RetryInfo details of an error, and
+// false if it carries none.
+func retryAfter(err error) (time.Duration, bool) {
+	details, derr := yarpcerrors.Details(err)
+	if derr != nil {
+		return 0, false
+	}
+	for _, detail := range details {
+		info, ok := detail.(*rpc.RetryInfo)
+		if !ok || info.RetryDelay == nil {
+			continue
+		}
+		if d, err := types.DurationFromProto(info.RetryDelay); err == nil && d > 0 {
+			return d, true
+		}
+	}
+	return 0, false
+}
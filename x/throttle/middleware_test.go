@@ -0,0 +1,255 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package throttle
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/yarpc/yarpctest"
+)
+
+// fakeOutbound rejects the calls for which reject returns true, counting
+// the calls it receives.
+type fakeOutbound struct {
+	transport.Outbound
+
+	mu     sync.Mutex
+	calls  int
+	reject func(call int) error
+}
+
+func (o *fakeOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	if err := o.call(); err != nil {
+		return nil, err
+	}
+	return &transport.Response{}, nil
+}
+
+func (o *fakeOutbound) CallOneway(ctx context.Context, req *transport.Request) (transport.Ack, error) {
+	return nil, o.call()
+}
+
+func (o *fakeOutbound) call() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls++
+	if o.reject == nil {
+		return nil
+	}
+	return o.reject(o.calls)
+}
+
+func (o *fakeOutbound) numCalls() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.calls
+}
+
+// rejectEighty rejects four out of every five calls.
+func rejectEighty(call int) error {
+	if call%5 == 0 {
+		return nil
+	}
+	return yarpcerrors.ResourceExhaustedErrorf("overloaded")
+}
+
+func pushback(delay time.Duration) error {
+	return yarpcerrors.WithDetails(yarpcerrors.ResourceExhaustedErrorf("overloaded"),
+		&rpc.RetryInfo{RetryDelay: types.DurationProto(delay)})
+}
+
+type harness struct {
+	t     *testing.T
+	mw    *Middleware
+	out   *fakeOutbound
+	clock *yarpctest.FakeClock
+	root  *metrics.Root
+}
+
+func newHarness(t *testing.T, opts ...Option) *harness {
+	root := metrics.New()
+	clock := yarpctest.NewFakeClock()
+	opts = append([]Option{
+		Window(10 * time.Second),
+		Meter(root.Scope()),
+		Clock(clock),
+	}, opts...)
+
+	mw := NewOutboundMiddleware(opts...)
+	mw.random = rand.New(rand.NewSource(1))
+	return &harness{
+		t:     t,
+		mw:    mw,
+		out:   &fakeOutbound{},
+		clock: clock,
+		root:  root,
+	}
+}
+
+// call sends n calls to the procedure and returns the number that the
+// throttle failed locally.
+func (h *harness) call(procedure string, n int) (rejected int) {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+
+	for i := 0; i < n; i++ {
+		before := h.out.numCalls()
+		_, err := h.mw.Call(ctx, &transport.Request{Service: "service", Procedure: procedure}, h.out)
+		if h.out.numCalls() == before {
+			require.Error(h.t, err)
+			assert.Equal(h.t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+			rejected++
+		}
+	}
+	return rejected
+}
+
+func (h *harness) metrics() map[string]int64 {
+	values := make(map[string]int64)
+	for _, c := range h.root.Snapshot().Counters {
+		name := c.Name
+		if reason, ok := c.Tags[_reasonTag]; ok {
+			name += ":" + reason
+		}
+		values[name] += c.Value
+	}
+	return values
+}
+
+func TestThrottlesRejectingDownstream(t *testing.T) {
+	h := newHarness(t)
+	h.out.reject = rejectEighty
+
+	var sent, rejected []int
+	for i := 0; i < 5; i++ {
+		before := h.out.numCalls()
+		rejected = append(rejected, h.call("proc", 100))
+		sent = append(sent, h.out.numCalls()-before)
+		h.clock.Add(time.Second)
+	}
+
+	assert.True(t, rejected[4] > rejected[0], "local rejections must rise: %v", rejected)
+	assert.True(t, sent[4] < sent[0], "real traffic must fall: %v", sent)
+	assert.True(t, sent[4] < 50, "most calls must be failed locally: %v", sent)
+	assert.True(t, h.mw.RejectProbability("service", "proc") > 0.5)
+
+	var totalSent, totalRejected int
+	for i := range sent {
+		totalSent += sent[i]
+		totalRejected += rejected[i]
+	}
+	got := h.metrics()
+	assert.Equal(t, int64(totalSent), got["throttle_sent"])
+	assert.Equal(t, int64(totalRejected), got["throttle_rejected:probability"])
+	assert.Equal(t, int64(0), got["throttle_rejected:pushback"])
+
+	// Throttles recover once the rejections leave the window.
+	h.out.reject = nil
+	h.clock.Add(10 * time.Second)
+	assert.Equal(t, 0.0, h.mw.RejectProbability("service", "proc"))
+	assert.Equal(t, 0, h.call("proc", 50))
+}
+
+func TestDoesNotThrottleHealthyDownstream(t *testing.T) {
+	h := newHarness(t)
+	h.out.reject = func(int) error { return yarpcerrors.InternalErrorf("not a rejection") }
+
+	assert.Equal(t, 0, h.call("proc", 500))
+	assert.Equal(t, 0.0, h.mw.RejectProbability("service", "proc"))
+}
+
+func TestThrottlesByProcedure(t *testing.T) {
+	h := newHarness(t)
+	h.out.reject = func(int) error { return yarpcerrors.ResourceExhaustedErrorf("overloaded") }
+	h.call("overloaded", 200)
+	require.True(t, h.mw.RejectProbability("service", "overloaded") > 0.9)
+
+	h.out.reject = nil
+	assert.Equal(t, 0.0, h.mw.RejectProbability("service", "healthy"))
+	assert.Equal(t, 0, h.call("healthy", 50))
+}
+
+func TestPushback(t *testing.T) {
+	h := newHarness(t)
+	h.out.reject = func(call int) error {
+		if call == 11 {
+			return pushback(5 * time.Second)
+		}
+		return nil
+	}
+
+	assert.Equal(t, 0, h.call("proc", 11))
+	ctx := context.Background()
+	_, err := h.mw.Call(ctx, &transport.Request{Service: "service", Procedure: "proc"}, h.out)
+	require.Error(t, err)
+	assert.Equal(t, yarpcerrors.CodeResourceExhausted, yarpcerrors.FromError(err).Code())
+	delay, ok := retryAfter(err)
+	require.True(t, ok, "local rejections must carry the remaining delay")
+	assert.Equal(t, 5*time.Second, delay)
+
+	h.clock.Add(4 * time.Second)
+	assert.Equal(t, 1, h.call("proc", 1))
+	h.clock.Add(time.Second)
+	assert.Equal(t, 0, h.call("proc", 1))
+
+	got := h.metrics()
+	assert.Equal(t, int64(1), got["throttle_pushbacks"])
+	assert.Equal(t, int64(2), got["throttle_rejected:pushback"])
+}
+
+func TestMaxPushback(t *testing.T) {
+	h := newHarness(t, MaxPushback(2*time.Second))
+	h.out.reject = func(call int) error {
+		if call == 11 {
+			return pushback(time.Hour)
+		}
+		return nil
+	}
+
+	assert.Equal(t, 0, h.call("proc", 11))
+	assert.Equal(t, 1, h.call("proc", 1))
+	h.clock.Add(2 * time.Second)
+	assert.Equal(t, 0, h.call("proc", 1))
+}
+
+func TestOneway(t *testing.T) {
+	h := newHarness(t)
+	h.out.reject = func(int) error { return pushback(time.Second) }
+
+	req := &transport.Request{Service: "service", Procedure: "proc"}
+	_, err := h.mw.CallOneway(context.Background(), req, h.out)
+	require.Error(t, err)
+	_, err = h.mw.CallOneway(context.Background(), req, h.out)
+	require.Error(t, err)
+	assert.Equal(t, 1, h.out.numCalls())
+}
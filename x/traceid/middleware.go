@@ -0,0 +1,142 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package traceid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+)
+
+// HeaderKey is the response header that carries the trace ID assigned to a
+// request. The same key names the baggage item and tag of the span of the
+// request.
+const HeaderKey = "rpc-trace-id"
+
+type traceIDKey struct{}
+
+// FromContext returns the trace ID that the middleware assigned to the
+// request of the context, or an empty string if the request arrived with an
+// active trace.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// Option customizes the behavior of the trace ID middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	tracer opentracing.Tracer
+}
+
+// Tracer sets the tracer that starts the spans of requests that arrive
+// without an active trace.
+//
+// Defaults to the global tracer.
+func Tracer(tracer opentracing.Tracer) Option {
+	return optionFunc(func(o *options) {
+		o.tracer = tracer
+	})
+}
+
+var _ middleware.UnaryInbound = (*inboundMiddleware)(nil)
+
+type inboundMiddleware struct {
+	generate func() string
+	tracer   opentracing.Tracer
+}
+
+// NewInboundMiddleware returns an inbound middleware that assigns a trace ID
+// from the generator to each request without an active trace span in its
+// context. Spans of the no-op tracer, which inbounds start when tracing is
+// not set up, are not active.
+//
+// The trace ID is stored in a new span for the request, as a baggage item
+// and a tag, in the context of the request for FromContext, and in the
+// rpc-trace-id response header. Requests with an active span pass through
+// unchanged.
+//
+// The generator defaults to random (version 4) UUIDs.
+func NewInboundMiddleware(generator func() string, opts ...Option) middleware.UnaryInbound {
+	var o options
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if generator == nil {
+		generator = newUUID
+	}
+	return &inboundMiddleware{generate: generator, tracer: o.tracer}
+}
+
+func (m *inboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	if hasActiveSpan(ctx) {
+		return h.Handle(ctx, req, resw)
+	}
+
+	id := m.generate()
+	tracer := m.tracer
+	if tracer == nil {
+		tracer = opentracing.GlobalTracer()
+	}
+	span := tracer.StartSpan(req.Procedure)
+	defer span.Finish()
+	span.SetBaggageItem(HeaderKey, id)
+	span.SetTag(HeaderKey, id)
+
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	ctx = context.WithValue(ctx, traceIDKey{}, id)
+	resw.AddHeaders(transport.NewHeaders().With(HeaderKey, id))
+	return h.Handle(ctx, req, resw)
+}
+
+// hasActiveSpan reports whether the context has a span of a tracer other than
+// the no-op tracer.
+func hasActiveSpan(ctx context.Context) bool {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return false
+	}
+	_, noop := span.Tracer().(opentracing.NoopTracer)
+	return !noop
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// The system source of randomness does not fail in practice.
+		panic(fmt.Sprintf("traceid: cannot read random bytes: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
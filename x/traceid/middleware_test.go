@@ -0,0 +1,125 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package traceid
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+)
+
+// contextHandler records the context of the request it handles.
+type contextHandler struct {
+	ctx context.Context
+}
+
+func (h *contextHandler) Handle(ctx context.Context, _ *transport.Request, _ transport.ResponseWriter) error {
+	h.ctx = ctx
+	return nil
+}
+
+func TestInboundMiddleware(t *testing.T) {
+	tracer := mocktracer.New()
+	mw := NewInboundMiddleware(func() string { return "trace-1" }, Tracer(tracer))
+
+	tests := []struct {
+		msg string
+		ctx context.Context
+	}{
+		{msg: "no span", ctx: context.Background()},
+		{
+			msg: "no-op span",
+			ctx: opentracing.ContextWithSpan(context.Background(), opentracing.NoopTracer{}.StartSpan("inbound")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			tracer.Reset()
+			var (
+				h    contextHandler
+				resw transporttest.FakeResponseWriter
+			)
+			require.NoError(t, mw.Handle(tt.ctx, &transport.Request{Procedure: "get"}, &resw, &h))
+
+			assert.Equal(t, "trace-1", FromContext(h.ctx))
+			id, ok := resw.Headers.Get("Rpc-Trace-Id")
+			assert.True(t, ok, "expected the trace ID in the response headers")
+			assert.Equal(t, "trace-1", id)
+
+			span := opentracing.SpanFromContext(h.ctx)
+			require.NotNil(t, span, "expected a span in the context of the handler")
+			assert.Equal(t, "trace-1", span.BaggageItem(HeaderKey))
+
+			finished := tracer.FinishedSpans()
+			require.Len(t, finished, 1, "expected the span to finish after the handler")
+			assert.Equal(t, "get", finished[0].OperationName)
+			assert.Equal(t, "trace-1", finished[0].Tag(HeaderKey))
+		})
+	}
+}
+
+func TestInboundMiddlewareActiveSpan(t *testing.T) {
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("inbound")
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+
+	generated := false
+	mw := NewInboundMiddleware(func() string {
+		generated = true
+		return "trace-1"
+	})
+
+	var (
+		h    contextHandler
+		resw transporttest.FakeResponseWriter
+	)
+	require.NoError(t, mw.Handle(ctx, &transport.Request{Procedure: "get"}, &resw, &h))
+	assert.False(t, generated, "expected no trace ID for a request with an active span")
+	assert.Empty(t, FromContext(h.ctx))
+	assert.Equal(t, 0, resw.Headers.Len())
+	assert.Equal(t, span, opentracing.SpanFromContext(h.ctx))
+}
+
+func TestDefaultGenerator(t *testing.T) {
+	mw := NewInboundMiddleware(nil)
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		var (
+			h    contextHandler
+			resw transporttest.FakeResponseWriter
+		)
+		require.NoError(t, mw.Handle(context.Background(), &transport.Request{}, &resw, &h))
+		id := FromContext(h.ctx)
+		assert.Regexp(t, uuid, id)
+		seen[id] = struct{}{}
+	}
+	assert.Len(t, seen, 100, "expected unique trace IDs")
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package versioning
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// NewInboundMiddleware returns middleware that calls the handler of the
+// version that the versionHeader of each unary request names, instead of the
+// handler of the procedure. Requests without the header are for the
+// defaultVersion, and requests for neither are left to the handler of the
+// procedure.
+//
+// The keys of the handlers are versions or path.Match patterns of versions.
+// A version matches its own key before any pattern, and longer patterns,
+// which tend to be more specific, are tried first. This function panics if a
+// pattern is malformed.
+func NewInboundMiddleware(handlers map[string]transport.UnaryHandler, versionHeader string, defaultVersion string) middleware.UnaryInbound {
+	patterns := make([]string, 0, len(handlers))
+	for pattern := range handlers {
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic(fmt.Sprintf("invalid version pattern %q: %v", pattern, err))
+		}
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	supported := strings.Join(patterns, ", ")
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return len(patterns[i]) > len(patterns[j])
+	})
+
+	return &inboundMiddleware{
+		handlers:       handlers,
+		patterns:       patterns,
+		supported:      supported,
+		versionHeader:  versionHeader,
+		defaultVersion: defaultVersion,
+	}
+}
+
+type inboundMiddleware struct {
+	handlers       map[string]transport.UnaryHandler
+	patterns       []string
+	supported      string
+	versionHeader  string
+	defaultVersion string
+}
+
+func (m *inboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	version, ok := req.Headers.Get(m.versionHeader)
+	if !ok || version == "" {
+		version = m.defaultVersion
+	}
+	if version == "" {
+		return h.Handle(ctx, req, resw)
+	}
+
+	handler, ok := m.handler(version)
+	if !ok {
+		return yarpcerrors.UnimplementedErrorf(
+			"version %q of procedure %q of service %q is not supported, supported versions: %s",
+			version, req.Procedure, req.Service, m.supported)
+	}
+	return handler.Handle(ctx, req, resw)
+}
+
+func (m *inboundMiddleware) handler(version string) (transport.UnaryHandler, bool) {
+	if h, ok := m.handlers[version]; ok {
+		return h, true
+	}
+	for _, pattern := range m.patterns {
+		// Patterns were validated when the middleware was built.
+		if ok, _ := path.Match(pattern, version); ok {
+			return m.handlers[pattern], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package versioning
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// namedHandler records the name of the handler that handled a request.
+type namedHandler struct {
+	name    string
+	handled *string
+}
+
+func (h namedHandler) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	*h.handled = h.name
+	return nil
+}
+
+func TestInboundMiddleware(t *testing.T) {
+	var handled string
+	handlers := map[string]transport.UnaryHandler{
+		"v1.*": namedHandler{"v1.*", &handled},
+		"v1.2": namedHandler{"v1.2", &handled},
+		"v2":   namedHandler{"v2", &handled},
+		"v*":   namedHandler{"v*", &handled},
+	}
+
+	tests := []struct {
+		desc           string
+		defaultVersion string
+		headers        transport.Headers
+		want           string
+		wantErr        string
+	}{
+		{
+			desc:    "exact version",
+			headers: transport.NewHeaders().With("x-api-version", "v2"),
+			want:    "v2",
+		},
+		{
+			desc:    "exact version before pattern",
+			headers: transport.NewHeaders().With("x-api-version", "v1.2"),
+			want:    "v1.2",
+		},
+		{
+			desc:    "wildcard version",
+			headers: transport.NewHeaders().With("X-Api-Version", "v1.7"),
+			want:    "v1.*",
+		},
+		{
+			desc:    "longer patterns first",
+			headers: transport.NewHeaders().With("x-api-version", "v1.0"),
+			want:    "v1.*",
+		},
+		{
+			desc:    "shorter pattern",
+			headers: transport.NewHeaders().With("x-api-version", "v3"),
+			want:    "v*",
+		},
+		{
+			desc:           "default version",
+			defaultVersion: "v2",
+			want:           "v2",
+		},
+		{
+			desc: "no version",
+			want: "procedure",
+		},
+		{
+			desc:    "unsupported version",
+			headers: transport.NewHeaders().With("x-api-version", "2"),
+			wantErr: `version "2" of procedure "get" of service "svc" is not supported, supported versions: v*, v1.*, v1.2, v2`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			handled = ""
+			mw := NewInboundMiddleware(handlers, "x-api-version", tt.defaultVersion)
+			req := &transport.Request{Service: "svc", Procedure: "get", Headers: tt.headers}
+
+			err := mw.Handle(context.Background(), req, new(transporttest.FakeResponseWriter), namedHandler{"procedure", &handled})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, yarpcerrors.CodeUnimplemented, yarpcerrors.FromError(err).Code())
+				assert.Equal(t, tt.wantErr, yarpcerrors.FromError(err).Message())
+				assert.Empty(t, handled, "no handler must be called")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, handled)
+		})
+	}
+}
+
+func TestInboundMiddlewareBadPattern(t *testing.T) {
+	assert.Panics(t, func() {
+		NewInboundMiddleware(map[string]transport.UnaryHandler{"v[": nil}, "x-api-version", "")
+	})
+}
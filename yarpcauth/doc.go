@@ -0,0 +1,68 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package yarpcauth provides middleware that authenticates the callers of a
+// service, and middleware that sends the credentials of a caller with its
+// requests.
+//
+// The inbound middleware runs an Authenticator against the headers of each
+// request, and the TLS connection it arrived on, if any. Requests that fail
+// to authenticate are rejected before they reach their handler, and handlers
+// read the identity of authenticated callers from the context.
+//
+// 	auth := yarpcauth.NewInboundMiddleware(
+// 		yarpcauth.AuthenticatorFunc(func(ctx context.Context, meta *transport.RequestMeta) (yarpcauth.Identity, error) {
+// 			token, ok := yarpcauth.BearerToken(meta)
+// 			if !ok {
+// 				return nil, yarpcerrors.UnauthenticatedErrorf("missing bearer token")
+// 			}
+// 			return tokens.Verify(ctx, token)
+// 		}),
+// 		yarpcauth.Exempt("grpc.health.v1.Health::Check"),
+// 	)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:     "myservice",
+// 		Inbounds: inbounds,
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary:  auth,
+// 			Oneway: auth,
+// 			Stream: auth,
+// 		},
+// 	})
+//
+// 	func (h *handler) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+// 		identity := yarpcauth.IdentityFromContext(ctx)
+// 		...
+// 	}
+//
+// The outbound middleware sets the Authorization header of each request to a
+// bearer token from a CredentialProvider.
+//
+// 	creds := yarpcauth.NewOutboundMiddleware(yarpcauth.StaticToken(token))
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:      "myclient",
+// 		Outbounds: outbounds,
+// 		OutboundMiddleware: yarpc.OutboundMiddleware{
+// 			Unary:  creds,
+// 			Oneway: creds,
+// 			Stream: creds,
+// 		},
+// 	})
+package yarpcauth
@@ -0,0 +1,100 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcauth
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+
+	"go.uber.org/yarpc/api/transport"
+)
+
+const (
+	_authorizationHeader = "authorization"
+	_bearerPrefix        = "Bearer "
+)
+
+// Identity is the identity of an authenticated caller.
+type Identity interface {
+	// Name identifies the caller, like the subject of its token or
+	// certificate.
+	Name() string
+}
+
+// Authenticator authenticates the callers of requests.
+type Authenticator interface {
+	// Authenticate returns the identity of the caller of the request with
+	// the given metadata.
+	//
+	// The context carries the state of the TLS connection that the request
+	// arrived on, if any; see PeerCertificate.
+	//
+	// Requests whose authentication fails are rejected with the returned
+	// error if it is a YARPC error, like a PermissionDenied error, and with
+	// an Unauthenticated error otherwise.
+	Authenticate(ctx context.Context, meta *transport.RequestMeta) (Identity, error)
+}
+
+// AuthenticatorFunc is an Authenticator implemented by a function.
+type AuthenticatorFunc func(context.Context, *transport.RequestMeta) (Identity, error)
+
+// Authenticate implements Authenticator.
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, meta *transport.RequestMeta) (Identity, error) {
+	return f(ctx, meta)
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the identity of the authenticated caller of the
+// request of the context, or nil if the request was not authenticated, like
+// requests for exempt procedures.
+func IdentityFromContext(ctx context.Context) Identity {
+	identity, _ := ctx.Value(identityKey{}).(Identity)
+	return identity
+}
+
+func withIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// BearerToken returns the bearer token of the Authorization header of the
+// request with the given metadata.
+func BearerToken(meta *transport.RequestMeta) (string, bool) {
+	header, ok := meta.Headers.Get(_authorizationHeader)
+	if !ok || len(header) < len(_bearerPrefix) ||
+		!strings.EqualFold(header[:len(_bearerPrefix)], _bearerPrefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(_bearerPrefix):])
+	return token, token != ""
+}
+
+// PeerCertificate returns the certificate that the peer of the request of the
+// context presented on its TLS connection, or nil if the request did not
+// arrive over TLS or the peer presented no certificate.
+func PeerCertificate(ctx context.Context) *x509.Certificate {
+	state := transport.TLSConnectionStateFromContext(ctx)
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return state.PeerCertificates[0]
+}
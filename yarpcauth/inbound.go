@@ -0,0 +1,154 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcauth
+
+import (
+	"context"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// InboundOption customizes the behavior of the inbound authentication
+// middleware.
+type InboundOption interface {
+	apply(*inboundOptions)
+}
+
+type inboundOptionFunc func(*inboundOptions)
+
+func (f inboundOptionFunc) apply(o *inboundOptions) { f(o) }
+
+type inboundOptions struct {
+	exempt map[string]struct{}
+}
+
+// Exempt exempts requests for the given procedures, like health checks, from
+// authentication. Their handlers find no identity in the context.
+func Exempt(procedures ...string) InboundOption {
+	return inboundOptionFunc(func(o *inboundOptions) {
+		for _, p := range procedures {
+			o.exempt[p] = struct{}{}
+		}
+	})
+}
+
+var (
+	_ middleware.UnaryInbound  = (*InboundMiddleware)(nil)
+	_ middleware.OnewayInbound = (*InboundMiddleware)(nil)
+	_ middleware.StreamInbound = (*InboundMiddleware)(nil)
+)
+
+// InboundMiddleware is an inbound middleware that authenticates the callers
+// of requests.
+type InboundMiddleware struct {
+	auth   Authenticator
+	exempt map[string]struct{}
+}
+
+// NewInboundMiddleware returns an inbound middleware that authenticates the
+// caller of each request with the given Authenticator, and stores its
+// identity in the context of the request for IdentityFromContext.
+//
+// Requests whose authentication fails are rejected with the error of the
+// Authenticator if it is a YARPC error, and with an Unauthenticated error
+// otherwise.
+func NewInboundMiddleware(auth Authenticator, opts ...InboundOption) *InboundMiddleware {
+	o := inboundOptions{exempt: make(map[string]struct{})}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return &InboundMiddleware{
+		auth:   auth,
+		exempt: o.exempt,
+	}
+}
+
+// Handle implements middleware.UnaryInbound.
+func (m *InboundMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	ctx, err := m.authenticate(ctx, req.ToRequestMeta())
+	if err != nil {
+		return err
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (m *InboundMiddleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	ctx, err := m.authenticate(ctx, req.ToRequestMeta())
+	if err != nil {
+		return err
+	}
+	return h.HandleOneway(ctx, req)
+}
+
+// HandleStream implements middleware.StreamInbound.
+func (m *InboundMiddleware) HandleStream(s *transport.ServerStream, h transport.StreamHandler) error {
+	ctx, err := m.authenticate(s.Context(), s.Request().Meta)
+	if err != nil {
+		return err
+	}
+	if ctx == s.Context() {
+		return h.HandleStream(s)
+	}
+	stream, err := transport.NewServerStream(&authenticatedStream{ServerStream: s, ctx: ctx})
+	if err != nil {
+		return err
+	}
+	return h.HandleStream(stream)
+}
+
+// authenticate returns a context with the identity of the caller of the
+// request, or an error if the request must be rejected.
+func (m *InboundMiddleware) authenticate(ctx context.Context, meta *transport.RequestMeta) (context.Context, error) {
+	if _, ok := m.exempt[meta.Procedure]; ok {
+		return ctx, nil
+	}
+
+	identity, err := m.auth.Authenticate(ctx, meta)
+	if err != nil {
+		if yarpcerrors.IsStatus(err) {
+			return nil, err
+		}
+		return nil, yarpcerrors.UnauthenticatedErrorf(
+			"failed to authenticate request for procedure %q of service %q from caller %q: %v",
+			meta.Procedure, meta.Service, meta.Caller, err)
+	}
+	if identity == nil {
+		return nil, yarpcerrors.UnauthenticatedErrorf(
+			"failed to authenticate request for procedure %q of service %q from caller %q",
+			meta.Procedure, meta.Service, meta.Caller)
+	}
+	return withIdentity(ctx, identity), nil
+}
+
+// authenticatedStream is a server stream whose context carries the identity
+// of its caller.
+type authenticatedStream struct {
+	*transport.ServerStream
+
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
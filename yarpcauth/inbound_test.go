@@ -0,0 +1,198 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcauth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+type fakeIdentity string
+
+func (i fakeIdentity) Name() string { return string(i) }
+
+// fakeAuthenticator accepts the bearer token "good", denies the bearer token
+// "banned", and rejects other requests.
+func fakeAuthenticator(calls *int) Authenticator {
+	return AuthenticatorFunc(func(_ context.Context, meta *transport.RequestMeta) (Identity, error) {
+		*calls++
+		token, ok := BearerToken(meta)
+		switch {
+		case !ok:
+			return nil, errors.New("missing token")
+		case token == "good":
+			return fakeIdentity("alice"), nil
+		case token == "banned":
+			return nil, yarpcerrors.PermissionDeniedErrorf("caller is banned")
+		default:
+			return nil, nil
+		}
+	})
+}
+
+// identityHandler records the identity of the requests it handles.
+type identityHandler struct {
+	called   bool
+	identity Identity
+}
+
+func (h *identityHandler) Handle(ctx context.Context, _ *transport.Request, _ transport.ResponseWriter) error {
+	h.called = true
+	h.identity = IdentityFromContext(ctx)
+	return nil
+}
+
+func (h *identityHandler) HandleOneway(ctx context.Context, _ *transport.Request) error {
+	h.called = true
+	h.identity = IdentityFromContext(ctx)
+	return nil
+}
+
+func (h *identityHandler) HandleStream(s *transport.ServerStream) error {
+	h.called = true
+	h.identity = IdentityFromContext(s.Context())
+	return nil
+}
+
+func TestInboundMiddleware(t *testing.T) {
+	tests := []struct {
+		msg          string
+		procedure    string
+		token        string
+		wantIdentity Identity
+		wantCode     yarpcerrors.Code
+		wantAuth     bool
+	}{
+		{msg: "allow", procedure: "get", token: "Bearer good", wantIdentity: fakeIdentity("alice"), wantAuth: true},
+		{msg: "lowercase scheme", procedure: "get", token: "bearer good", wantIdentity: fakeIdentity("alice"), wantAuth: true},
+		{msg: "deny", procedure: "get", token: "Bearer banned", wantCode: yarpcerrors.CodePermissionDenied, wantAuth: true},
+		{msg: "missing token", procedure: "get", wantCode: yarpcerrors.CodeUnauthenticated, wantAuth: true},
+		{msg: "basic scheme", procedure: "get", token: "Basic good", wantCode: yarpcerrors.CodeUnauthenticated, wantAuth: true},
+		{msg: "no identity", procedure: "get", token: "Bearer unknown", wantCode: yarpcerrors.CodeUnauthenticated, wantAuth: true},
+		{msg: "exempt", procedure: "health", token: "Bearer banned"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			var calls int
+			m := NewInboundMiddleware(fakeAuthenticator(&calls), Exempt("health", "ping"))
+
+			headers := transport.NewHeaders()
+			if tt.token != "" {
+				headers = headers.With("Authorization", tt.token)
+			}
+			req := &transport.Request{
+				Caller:    "caller",
+				Service:   "service",
+				Procedure: tt.procedure,
+				Headers:   headers,
+			}
+
+			check := func(t *testing.T, h *identityHandler, err error) {
+				if tt.wantCode != yarpcerrors.CodeOK {
+					require.Error(t, err)
+					assert.Equal(t, tt.wantCode, yarpcerrors.FromError(err).Code())
+					assert.False(t, h.called, "handler must not be called")
+					return
+				}
+				require.NoError(t, err)
+				assert.True(t, h.called, "handler must be called")
+				assert.Equal(t, tt.wantIdentity, h.identity)
+			}
+
+			t.Run("unary", func(t *testing.T) {
+				var h identityHandler
+				check(t, &h, m.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, &h))
+			})
+			t.Run("oneway", func(t *testing.T) {
+				var h identityHandler
+				check(t, &h, m.HandleOneway(context.Background(), req, &h))
+			})
+			t.Run("stream", func(t *testing.T) {
+				var h identityHandler
+				stream, err := transport.NewServerStream(&fakeStream{
+					ctx:     context.Background(),
+					request: &transport.StreamRequest{Meta: req.ToRequestMeta()},
+				})
+				require.NoError(t, err)
+				check(t, &h, m.HandleStream(stream, &h))
+			})
+
+			if tt.wantAuth {
+				assert.Equal(t, 3, calls, "authenticator must run for each request")
+			} else {
+				assert.Zero(t, calls, "authenticator must not run for exempt procedures")
+			}
+		})
+	}
+}
+
+func TestInboundMiddlewareErrorMessage(t *testing.T) {
+	var calls int
+	m := NewInboundMiddleware(fakeAuthenticator(&calls))
+	err := m.Handle(context.Background(), &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "get",
+	}, &transporttest.FakeResponseWriter{}, &identityHandler{})
+	assert.Equal(t, yarpcerrors.UnauthenticatedErrorf(
+		`failed to authenticate request for procedure "get" of service "service" from caller "caller": missing token`), err)
+}
+
+func TestPeerCertificate(t *testing.T) {
+	cert := &x509.Certificate{}
+
+	assert.Nil(t, PeerCertificate(context.Background()), "no TLS state")
+	assert.Nil(t, PeerCertificate(transport.WithTLSConnectionState(context.Background(),
+		&tls.ConnectionState{})), "no peer certificate")
+	assert.Equal(t, cert, PeerCertificate(transport.WithTLSConnectionState(context.Background(),
+		&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}})))
+}
+
+type fakeStream struct {
+	ctx     context.Context
+	request *transport.StreamRequest
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeStream) Request() *transport.StreamRequest {
+	return s.request
+}
+
+func (s *fakeStream) SendMessage(context.Context, *transport.StreamMessage) error {
+	return nil
+}
+
+func (s *fakeStream) ReceiveMessage(context.Context) (*transport.StreamMessage, error) {
+	return nil, nil
+}
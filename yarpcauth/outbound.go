@@ -0,0 +1,186 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcauth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// _tokenExpiryBuffer is how long before its expiry a cached token is
+// refreshed, so that tokens do not expire while requests are in flight.
+const _tokenExpiryBuffer = 30 * time.Second
+
+// CredentialProvider provides the credentials of a caller.
+type CredentialProvider interface {
+	// Credentials returns the headers to authenticate a request with.
+	//
+	// Requests fail with the returned error, if any.
+	Credentials(ctx context.Context) (transport.Headers, error)
+}
+
+// StaticToken returns a CredentialProvider that authenticates requests with
+// the given bearer token.
+func StaticToken(token string) CredentialProvider {
+	return staticToken{headers: bearerHeaders(token)}
+}
+
+type staticToken struct {
+	headers transport.Headers
+}
+
+func (t staticToken) Credentials(context.Context) (transport.Headers, error) {
+	return t.headers, nil
+}
+
+// RefreshingToken returns a CredentialProvider that authenticates requests
+// with a bearer token obtained from the given function, which returns a
+// token and its expiry, or a zero time if the token does not expire.
+//
+// The provider calls the function with the context of the request that needs
+// a token, caches the token, and refreshes it 30 seconds before it expires.
+// If the function fails, the request fails with an Unauthenticated error.
+func RefreshingToken(refresh func(ctx context.Context) (token string, expiry time.Time, err error)) CredentialProvider {
+	return &refreshingToken{
+		refresh: refresh,
+		now:     time.Now,
+	}
+}
+
+type refreshingToken struct {
+	refresh func(context.Context) (string, time.Time, error)
+	now     func() time.Time
+
+	lock    sync.Mutex
+	headers transport.Headers
+	expiry  time.Time
+}
+
+func (t *refreshingToken) Credentials(ctx context.Context) (transport.Headers, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.valid() {
+		return t.headers, nil
+	}
+
+	token, expiry, err := t.refresh(ctx)
+	if err != nil {
+		return transport.Headers{}, yarpcerrors.UnauthenticatedErrorf("failed to obtain token: %v", err)
+	}
+	if token == "" {
+		return transport.Headers{}, yarpcerrors.UnauthenticatedErrorf("failed to obtain token: token is empty")
+	}
+	t.headers = bearerHeaders(token)
+	t.expiry = expiry
+	return t.headers, nil
+}
+
+// valid returns whether the cached token can be used until well after now.
+func (t *refreshingToken) valid() bool {
+	if t.headers.Len() == 0 {
+		return false
+	}
+	if t.expiry.IsZero() {
+		return true
+	}
+	return t.now().Add(_tokenExpiryBuffer).Before(t.expiry)
+}
+
+func bearerHeaders(token string) transport.Headers {
+	return transport.NewHeadersWithCapacity(1).With(_authorizationHeader, _bearerPrefix+token)
+}
+
+var (
+	_ middleware.UnaryOutbound  = (*OutboundMiddleware)(nil)
+	_ middleware.OnewayOutbound = (*OutboundMiddleware)(nil)
+	_ middleware.StreamOutbound = (*OutboundMiddleware)(nil)
+)
+
+// OutboundMiddleware is an outbound middleware that adds the credentials of
+// the caller to the headers of requests.
+type OutboundMiddleware struct {
+	provider CredentialProvider
+}
+
+// NewOutboundMiddleware returns an outbound middleware that adds the
+// credentials from the given provider to the headers of each request,
+// replacing headers of the same names.
+func NewOutboundMiddleware(provider CredentialProvider) *OutboundMiddleware {
+	return &OutboundMiddleware{provider: provider}
+}
+
+// Call implements middleware.UnaryOutbound.
+func (m *OutboundMiddleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	headers, err := m.headers(ctx, req.Headers)
+	if err != nil {
+		return nil, err
+	}
+	r := *req
+	r.Headers = headers
+	return out.Call(ctx, &r)
+}
+
+// CallOneway implements middleware.OnewayOutbound.
+func (m *OutboundMiddleware) CallOneway(ctx context.Context, req *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	headers, err := m.headers(ctx, req.Headers)
+	if err != nil {
+		return nil, err
+	}
+	r := *req
+	r.Headers = headers
+	return out.CallOneway(ctx, &r)
+}
+
+// CallStream implements middleware.StreamOutbound.
+func (m *OutboundMiddleware) CallStream(ctx context.Context, req *transport.StreamRequest, out transport.StreamOutbound) (*transport.ClientStream, error) {
+	headers, err := m.headers(ctx, req.Meta.Headers)
+	if err != nil {
+		return nil, err
+	}
+	meta := *req.Meta
+	meta.Headers = headers
+	return out.CallStream(ctx, &transport.StreamRequest{Meta: &meta})
+}
+
+// headers returns a copy of the given request headers with the credentials
+// added, so that the request of the caller is left alone.
+func (m *OutboundMiddleware) headers(ctx context.Context, headers transport.Headers) (transport.Headers, error) {
+	creds, err := m.provider.Credentials(ctx)
+	if err != nil {
+		return transport.Headers{}, err
+	}
+	merged := transport.NewHeadersWithCapacity(headers.Len() + creds.Len())
+	for k, v := range headers.OriginalItems() {
+		if _, ok := creds.Get(k); !ok {
+			merged = merged.With(k, v)
+		}
+	}
+	for k, v := range creds.OriginalItems() {
+		merged = merged.With(k, v)
+	}
+	return merged, nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// fakeOutbound records the headers of the requests it receives.
+type fakeOutbound struct {
+	transport.Outbound
+
+	headers []transport.Headers
+}
+
+func (o *fakeOutbound) Call(_ context.Context, req *transport.Request) (*transport.Response, error) {
+	o.headers = append(o.headers, req.Headers)
+	return &transport.Response{}, nil
+}
+
+func (o *fakeOutbound) CallOneway(_ context.Context, req *transport.Request) (transport.Ack, error) {
+	o.headers = append(o.headers, req.Headers)
+	return nil, nil
+}
+
+func (o *fakeOutbound) CallStream(_ context.Context, req *transport.StreamRequest) (*transport.ClientStream, error) {
+	o.headers = append(o.headers, req.Meta.Headers)
+	return nil, nil
+}
+
+func TestOutboundMiddleware(t *testing.T) {
+	m := NewOutboundMiddleware(StaticToken("secret"))
+	headers := transport.NewHeaders().With("Authorization", "Bearer stale").With("foo", "bar")
+	want := map[string]string{"authorization": "Bearer secret", "foo": "bar"}
+
+	t.Run("unary", func(t *testing.T) {
+		out := &fakeOutbound{}
+		req := &transport.Request{Procedure: "get", Headers: headers}
+		_, err := m.Call(context.Background(), req, out)
+		require.NoError(t, err)
+		require.Len(t, out.headers, 1)
+		assert.Equal(t, want, out.headers[0].Items())
+		assert.Equal(t, want, out.headers[0].OriginalItems())
+		assert.Equal(t, map[string]string{"Authorization": "Bearer stale", "foo": "bar"},
+			req.Headers.OriginalItems(), "request of the caller must be left alone")
+	})
+
+	t.Run("oneway", func(t *testing.T) {
+		out := &fakeOutbound{}
+		_, err := m.CallOneway(context.Background(), &transport.Request{Procedure: "get"}, out)
+		require.NoError(t, err)
+		require.Len(t, out.headers, 1)
+		assert.Equal(t, map[string]string{"authorization": "Bearer secret"}, out.headers[0].Items())
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		out := &fakeOutbound{}
+		_, err := m.CallStream(context.Background(), &transport.StreamRequest{
+			Meta: &transport.RequestMeta{Procedure: "get", Headers: headers},
+		}, out)
+		require.NoError(t, err)
+		require.Len(t, out.headers, 1)
+		assert.Equal(t, want, out.headers[0].Items())
+	})
+}
+
+func TestOutboundMiddlewareProviderError(t *testing.T) {
+	m := NewOutboundMiddleware(RefreshingToken(func(context.Context) (string, time.Time, error) {
+		return "", time.Time{}, errors.New("great sadness")
+	}))
+	out := &fakeOutbound{}
+	_, err := m.Call(context.Background(), &transport.Request{}, out)
+	assert.Equal(t, yarpcerrors.UnauthenticatedErrorf("failed to obtain token: great sadness"), err)
+	assert.Empty(t, out.headers, "outbound must not be called")
+}
+
+func TestRefreshingToken(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var (
+		refreshes int
+		expiry    time.Time
+	)
+	provider := RefreshingToken(func(context.Context) (string, time.Time, error) {
+		refreshes++
+		return "token", expiry, nil
+	}).(*refreshingToken)
+	provider.now = func() time.Time { return now }
+
+	credentials := func() string {
+		headers, err := provider.Credentials(context.Background())
+		require.NoError(t, err)
+		v, _ := headers.Get("authorization")
+		return v
+	}
+
+	expiry = now.Add(time.Minute)
+	assert.Equal(t, "Bearer token", credentials())
+	assert.Equal(t, "Bearer token", credentials())
+	assert.Equal(t, 1, refreshes, "token must be cached")
+
+	now = now.Add(31 * time.Second)
+	credentials()
+	assert.Equal(t, 2, refreshes, "token must be refreshed before it expires")
+
+	expiry = time.Time{}
+	now = now.Add(time.Hour)
+	credentials()
+	credentials()
+	assert.Equal(t, 3, refreshes, "token without expiry must be cached")
+}
+
+func TestRefreshingTokenEmpty(t *testing.T) {
+	provider := RefreshingToken(func(context.Context) (string, time.Time, error) {
+		return "", time.Time{}, nil
+	})
+	_, err := provider.Credentials(context.Background())
+	assert.Equal(t, yarpcerrors.UnauthenticatedErrorf("failed to obtain token: token is empty"), err)
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/uber-go/mapdecode"
 	"go.uber.org/multierr"
+	netmetrics "go.uber.org/net/metrics"
 	"go.uber.org/yarpc"
 	"go.uber.org/yarpc/api/transport"
 	yarpctls "go.uber.org/yarpc/api/transport/tls"
@@ -115,7 +116,7 @@ func (b *builder) Build() (yarpc.Config, error) {
 			ob.ServiceName = c.Service
 		}
 
-		kit := b.kit.withOutboundName(c.Service)
+		kit := b.kit.withOutboundName(c.Service).withMeterTags(netmetrics.Tags{"outbound": ccname})
 		if o := c.Unary; o != nil {
 			ob.Unary, err = buildUnaryOutbound(o, transports[o.TransportSpec.Name], kit)
 			if err != nil {
@@ -170,6 +171,7 @@ func buildInbound(cv *buildable, t transport.Transport, k *Kit) (transport.Inbou
 // buildUnaryOutbound builds an UnaryOutbound from the given value. This will panic
 // if the output type for this is not transport.UnaryOutbound.
 func buildUnaryOutbound(o *buildableOutbound, t transport.Transport, k *Kit) (transport.UnaryOutbound, error) {
+	k = k.withMeterTags(netmetrics.Tags{"rpc_type": transport.Unary.String()})
 	result, err := o.Value.Build(t, k.withTransportSpec(o.TransportSpec))
 	if err != nil {
 		return nil, err
@@ -180,6 +182,7 @@ func buildUnaryOutbound(o *buildableOutbound, t transport.Transport, k *Kit) (tr
 // buildOnewayOutbound builds an OnewayOutbound from the given value. This will
 // panic if the output type for this is not transport.OnewayOutbound.
 func buildOnewayOutbound(o *buildableOutbound, t transport.Transport, k *Kit) (transport.OnewayOutbound, error) {
+	k = k.withMeterTags(netmetrics.Tags{"rpc_type": transport.Oneway.String()})
 	result, err := o.Value.Build(t, k.withTransportSpec(o.TransportSpec))
 	if err != nil {
 		return nil, err
@@ -190,6 +193,7 @@ func buildOnewayOutbound(o *buildableOutbound, t transport.Transport, k *Kit) (t
 // buildStreamOutbound builds an StreamOutbound from the given value. This will
 // panic if the output type for this is not transport.StreamOutbound.
 func buildStreamOutbound(o *buildableOutbound, t transport.Transport, k *Kit) (transport.StreamOutbound, error) {
+	k = k.withMeterTags(netmetrics.Tags{"rpc_type": transport.Streaming.String()})
 	result, err := o.Value.Build(t, k.withTransportSpec(o.TransportSpec))
 	if err != nil {
 		return nil, err
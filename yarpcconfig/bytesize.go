@@ -0,0 +1,54 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"fmt"
+
+	"github.com/uber-go/mapdecode"
+	"go.uber.org/yarpc/internal/config"
+)
+
+// ByteSize is a size in bytes, for the attributes of specs that configure
+// sizes, like buffer and message sizes. It decodes from a plain number of
+// bytes, or from a number with a unit.
+//
+//	maxMessageSize: 4MiB
+//	bufferSize: 512kb
+//	headerSize: 8192
+//
+// Units are case-insensitive. KB, MB, GB, and TB are multiples of 1000, and
+// KiB, MiB, GiB, and TiB multiples of 1024.
+type ByteSize int64
+
+// Decode decodes a size in bytes.
+func (s *ByteSize) Decode(into mapdecode.Into) error {
+	var value interface{}
+	if err := into(&value); err != nil {
+		return err
+	}
+	size, err := config.ParseByteSize(value)
+	if err != nil {
+		return fmt.Errorf("could not decode size: %v", err)
+	}
+	*s = ByteSize(size)
+	return nil
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/yarpcconfig"
+	"gopkg.in/yaml.v2"
+)
+
+type limitsConfig struct {
+	Timeout time.Duration        `config:"timeout"`
+	MaxSize yarpcconfig.ByteSize `config:"maxSize"`
+}
+
+type sizedInboundConfig struct {
+	Address string                `config:"address"`
+	Limits  limitsConfig          `config:"limits"`
+	Tiers   []limitsConfig        `config:"tiers"`
+	Buffer  *yarpcconfig.ByteSize `config:"buffer"`
+}
+
+// sizedTransportSpec returns a spec whose inbounds record their
+// configuration.
+func sizedTransportSpec(got *sizedInboundConfig) yarpcconfig.TransportSpec {
+	return yarpcconfig.TransportSpec{
+		Name: "sized",
+		BuildTransport: func(struct{}, *yarpcconfig.Kit) (transport.Transport, error) {
+			return http.NewTransport(), nil
+		},
+		BuildInbound: func(cfg *sizedInboundConfig, _ transport.Transport, _ *yarpcconfig.Kit) (transport.Inbound, error) {
+			*got = *cfg
+			return http.NewTransport().NewInbound(cfg.Address), nil
+		},
+	}
+}
+
+func loadSized(t *testing.T, give string) (sizedInboundConfig, error) {
+	var got sizedInboundConfig
+	cfg := yarpcconfig.New()
+	cfg.MustRegisterTransport(sizedTransportSpec(&got))
+
+	var data map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(whitespace.Expand(give)), &data))
+	_, err := cfg.LoadConfig("myservice", data)
+	return got, err
+}
+
+func TestDurationsAndSizes(t *testing.T) {
+	got, err := loadSized(t, `
+		inbounds:
+			sized:
+				address: ":0"
+				limits: {timeout: 1m30s, maxSize: 4MiB}
+				tiers:
+					- {timeout: 30 s, maxSize: 512kb}
+					- {timeout: 0, maxSize: 8192}
+				buffer: 1.5 KiB
+	`)
+	require.NoError(t, err)
+
+	buffer := yarpcconfig.ByteSize(1536)
+	assert.Equal(t, sizedInboundConfig{
+		Address: ":0",
+		Limits:  limitsConfig{Timeout: 90 * time.Second, MaxSize: 4 << 20},
+		Tiers: []limitsConfig{
+			{Timeout: 30 * time.Second, MaxSize: 512000},
+			{Timeout: 0, MaxSize: 8192},
+		},
+		Buffer: &buffer,
+	}, got)
+}
+
+func TestDurationsAndSizesErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr []string
+	}{
+		{
+			desc: "nested struct",
+			give: `
+				inbounds:
+					sized:
+						limits: {timeout: 5x, maxSize: 4MB/s}
+			`,
+			wantErr: []string{
+				`invalid duration at inbounds.sized.limits.timeout: time: unknown unit "x" in duration "5x"`,
+				`invalid size at inbounds.sized.limits.maxSize: size "4MB/s" has an unknown unit "MB/s"`,
+			},
+		},
+		{
+			desc: "list",
+			give: `
+				inbounds:
+					sized:
+						tiers:
+							- {timeout: 1s}
+							- {timeout: 500, maxSize: -1}
+			`,
+			wantErr: []string{
+				`invalid duration at inbounds.sized.tiers[1].timeout: duration 500 has no unit, like "500s"`,
+				`invalid size at inbounds.sized.tiers[1].maxSize: size -1 must not be negative`,
+			},
+		},
+		{
+			desc: "fraction of a byte",
+			give: `
+				inbounds:
+					sized:
+						buffer: 0.5
+			`,
+			wantErr: []string{
+				`invalid size at inbounds.sized.buffer: size "0.5" is not a whole number of bytes`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := loadSized(t, tt.give)
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
@@ -128,6 +128,8 @@ func (pc PeerChooser) Empty() bool {
 // The Kit received by the Build*Outbound function MUST be passed to
 // BuildPeerChooser as-is.
 func (pc PeerChooser) BuildPeerChooser(transport peer.Transport, identify func(string) peer.Identifier, kit *Kit) (peer.Chooser, error) {
+	kit = kit.withIdentify(identify)
+
 	// Establish a peer selection strategy.
 	switch {
 	case pc.Peer != "":
@@ -307,16 +309,18 @@ func buildPeerListUpdater(c config.AttributeMap, identify func(string) peer.Iden
 			strings.Join(foundUpdaters, ", "))
 	}
 
-	var peerListUpdaterConfig config.AttributeMap
+	// The value is usually a map of attributes, but peer list updaters may
+	// accept a scalar shorthand, like the path of a file.
+	var peerListUpdaterConfig interface{}
 	if _, err := c.Pop(foundUpdaters[0], &peerListUpdaterConfig); err != nil {
 		return nil, err
 	}
 
 	// This decodes all attributes on the peer list updater block, including the
 	// field with the name of the peer list updater.
-	peerListUpdaterBuilder, err := peerListUpdaterSpec.PeerListUpdater.Decode(peerListUpdaterConfig, config.InterpolateWith(kit.resolver))
+	peerListUpdaterBuilder, err := peerListUpdaterSpec.PeerListUpdater.DecodeValue(peerListUpdaterConfig, config.InterpolateWith(kit.resolver))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to read attribute %q: %v", foundUpdaters[0], err)
 	}
 
 	result, err := peerListUpdaterBuilder.Build(kit)
@@ -1183,3 +1183,83 @@ func mapVariableResolver(m map[string]string) interpolate.VariableResolver {
 		return
 	}
 }
+
+func TestKitBuildPeerList(t *testing.T) {
+	type decoratorConfig struct {
+		With string                 `config:"with"`
+		Etc  map[string]interface{} `config:",squash"`
+	}
+
+	var built peerapi.ChooserList
+	configer := yarpctest.NewFakeConfigurator()
+	configer.MustRegisterPeerList(yarpcconfig.PeerListSpec{
+		Name: "decorator",
+		BuildPeerList: func(c decoratorConfig, t peerapi.Transport, k *yarpcconfig.Kit) (peerapi.ChooserList, error) {
+			list, err := k.BuildPeerList(c.With, c.Etc, t)
+			built = list
+			return list, err
+		},
+	})
+
+	tests := []struct {
+		desc    string
+		given   string
+		wantErr string
+	}{
+		{
+			desc: "success",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							decorator:
+								with: fake-list
+								nop: "*.*"
+								peers:
+									- 127.0.0.1:8080
+			`,
+		},
+		{
+			desc: "unknown list",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							decorator:
+								with: bogus
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: `no recognized peer list or chooser "bogus"`,
+		},
+		{
+			desc: "invalid attributes",
+			given: `
+				outbounds:
+					myservice:
+						fake-transport:
+							decorator:
+								with: fake-list
+								bogus: true
+								peers:
+									- 127.0.0.1:8080
+			`,
+			wantErr: "has invalid keys: bogus",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			built = nil
+			_, err := configer.LoadConfigFromYAML("fake-service", strings.NewReader(whitespace.Expand(tt.given)))
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.IsType(t, &yarpctest.FakePeerList{}, built)
+			assert.Equal(t, "*.*", built.(*yarpctest.FakePeerList).Nop())
+		})
+	}
+}
@@ -28,10 +28,13 @@ import (
 	"os"
 
 	"go.uber.org/multierr"
+	netmetrics "go.uber.org/net/metrics"
 	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/middleware"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/config"
 	"go.uber.org/yarpc/internal/interpolate"
+	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
 )
 
@@ -47,7 +50,12 @@ type Configurator struct {
 	knownPeerLists        map[string]*compiledPeerListSpec
 	knownPeerListUpdaters map[string]*compiledPeerListUpdaterSpec
 	knownCompressors      map[string]transport.Compressor
+	knownMiddleware       map[string]*compiledMiddlewareSpec
 	resolver              interpolate.VariableResolver
+	disableExpansion      bool
+	strictKeys            bool
+	logger                *zap.Logger
+	meter                 *netmetrics.Scope
 }
 
 // New sets up a new empty Configurator. The returned Configurator does not
@@ -59,7 +67,9 @@ func New(opts ...Option) *Configurator {
 		knownPeerLists:        make(map[string]*compiledPeerListSpec),
 		knownPeerListUpdaters: make(map[string]*compiledPeerListUpdaterSpec),
 		knownCompressors:      make(map[string]transport.Compressor),
+		knownMiddleware:       make(map[string]*compiledMiddlewareSpec),
 		resolver:              os.LookupEnv,
+		logger:                zap.NewNop(),
 	}
 
 	for _, opt := range opts {
@@ -207,6 +217,44 @@ func (c *Configurator) MustRegisterPeerListUpdater(s PeerListUpdaterSpec) {
 	}
 }
 
+// RegisterMiddleware registers a MiddlewareSpec with the given Configurator,
+// teaching it how to build middleware of this kind from configuration.
+//
+// Returns an error if the MiddlewareSpec is invalid. Use
+// MustRegisterMiddleware to panic if the registration fails.
+//
+// If middleware with the same name already exists, it will be replaced. The
+// names "inbound" and "outbound" are reserved for the middleware of inbounds
+// and outbounds only, and "procedures" for the middleware that applies the
+// procedure options of outbounds.
+//
+// See MiddlewareSpec for details on how to integrate your own middleware with
+// the system.
+func (c *Configurator) RegisterMiddleware(s MiddlewareSpec) error {
+	if s.Name == "" {
+		return errors.New("name is required")
+	}
+	if s.Name == "inbound" || s.Name == "outbound" || s.Name == _proceduresMiddlewareName {
+		return fmt.Errorf("middleware name %q is reserved", s.Name)
+	}
+
+	spec, err := compileMiddlewareSpec(&s)
+	if err != nil {
+		return fmt.Errorf("invalid MiddlewareSpec for %q: %v", s.Name, err)
+	}
+
+	c.knownMiddleware[s.Name] = spec
+	return nil
+}
+
+// MustRegisterMiddleware registers the given MiddlewareSpec with the
+// Configurator. This function panics if the MiddlewareSpec is invalid.
+func (c *Configurator) MustRegisterMiddleware(s MiddlewareSpec) {
+	if err := c.RegisterMiddleware(s); err != nil {
+		panic(err)
+	}
+}
+
 // RegisterCompressor registers the given Compressor for the configurator, so
 // any transport can use the given compression strategy.
 func (c *Configurator) RegisterCompressor(z transport.Compressor) error {
@@ -227,30 +275,125 @@ func (c *Configurator) MustRegisterCompressor(z transport.Compressor) {
 // LoadConfigFromYAML loads a yarpc.Config from YAML data. Use LoadConfig if
 // you have already parsed a map[string]interface{} or
 // map[interface{}]interface{}.
+//
+// Values tagged !include are replaced with the contents of the YAML file they
+// name, which may include other files in turn:
+//
+//	outbounds: !include outbounds.yaml
+//
+// Relative paths in the data are resolved against the working directory, and
+// relative paths in included files against the directory of the including
+// file. Use LoadConfigFromYAMLFile to resolve the paths in the data against
+// the directory of its file.
 func (c *Configurator) LoadConfigFromYAML(serviceName string, r io.Reader) (yarpc.Config, error) {
-	b, err := ioutil.ReadAll(r)
+	return c.loadConfigFromYAML(serviceName, r, "")
+}
+
+// LoadConfigFromYAMLFile loads a yarpc.Config from the YAML file at the given
+// path, resolving the relative paths of its includes against the directory
+// of the file.
+func (c *Configurator) LoadConfigFromYAMLFile(serviceName string, path string) (yarpc.Config, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return yarpc.Config{}, err
 	}
+	defer f.Close()
+	return c.loadConfigFromYAML(serviceName, f, path)
+}
 
-	var data map[string]interface{}
-	if err := yaml.Unmarshal(b, &data); err != nil {
+func (c *Configurator) loadConfigFromYAML(serviceName string, r io.Reader, file string) (yarpc.Config, error) {
+	data, err := readYAML(r, file)
+	if err != nil {
 		return yarpc.Config{}, err
 	}
 	return c.LoadConfig(serviceName, data)
 }
 
+// LoadConfigFromJSON loads a yarpc.Config from a JSON object.
+//
+// The configuration has the same shape as in YAML, and is interpolated and
+// validated the same way. Integral numbers are decoded as integers, so that
+// they may configure integer attributes.
+func (c *Configurator) LoadConfigFromJSON(serviceName string, r io.Reader) (yarpc.Config, error) {
+	data, err := readJSON(r)
+	if err != nil {
+		return yarpc.Config{}, err
+	}
+	return c.LoadConfig(serviceName, data)
+}
+
+// LoadConfigFromTOML loads a yarpc.Config from a TOML document.
+//
+// The configuration has the same shape as in YAML, and is interpolated and
+// validated the same way. Durations and sizes with units are written as
+// strings:
+//
+//	[transports.http]
+//	keepAlive = "30s"
+func (c *Configurator) LoadConfigFromTOML(serviceName string, r io.Reader) (yarpc.Config, error) {
+	data, err := readTOML(r)
+	if err != nil {
+		return yarpc.Config{}, err
+	}
+	return c.LoadConfig(serviceName, data)
+}
+
+// readYAML reads configuration data from YAML, with the includes of the
+// given file.
+func readYAML(r io.Reader, file string) (map[string]interface{}, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = expandIncludes(b, file); err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := yaml.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 // LoadConfig loads a yarpc.Config from a map[string]interface{} or
 // map[interface{}]interface{}.
 //
 // See the module documentation for the shape the map[string]interface{} is
 // expected to conform to.
 func (c *Configurator) LoadConfig(serviceName string, data interface{}) (yarpc.Config, error) {
+	cfg, checkErr, err := c.decode(data)
+	if err != nil {
+		return yarpc.Config{}, err
+	}
+	return c.load(serviceName, cfg, checkErr)
+}
+
+// decode expands and decodes the configuration data, applying its defaults
+// to its inbounds and outbounds. The errors of unknown
+// keys are returned separately if the rest of the configuration decodes, so
+// that loading it reports them along with its own errors.
+func (c *Configurator) decode(data interface{}) (_ *yarpcConfig, checkErr error, err error) {
+	if !c.disableExpansion {
+		if data, err = expand(data, c.resolver); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Keys that fail the check are dropped from the configuration so that
+	// the errors of decoding the remaining configuration are reported along
+	// with them.
+	checker := keyChecker{c: c, strict: c.strictKeys, logger: c.logger}
+	data, checkErr = checker.Check(data)
+
 	var cfg yarpcConfig
 	if err := config.DecodeInto(&cfg, data); err != nil {
-		return yarpc.Config{}, err
+		return nil, nil, multierr.Append(checkErr, err)
+	}
+	if err := c.applyDefaults(&cfg); err != nil {
+		return nil, nil, multierr.Append(checkErr, err)
 	}
-	return c.load(serviceName, &cfg)
+	return &cfg, checkErr, nil
 }
 
 // NewDispatcherFromYAML builds a Dispatcher from the given YAML
@@ -275,14 +418,22 @@ func (c *Configurator) NewDispatcher(serviceName string, data interface{}) (*yar
 // Kit creates a dependency kit for the configurator, suitable for passing to
 // spec builder functions.
 func (c *Configurator) Kit(serviceName string) *Kit {
-	return &Kit{
-		name:     serviceName,
-		c:        c,
-		resolver: c.resolver,
+	k := &Kit{
+		name:  serviceName,
+		c:     c,
+		meter: c.meter.Tagged(netmetrics.Tags{"dispatcher": serviceName}),
 	}
+	// Expanded configuration has no variables left to interpolate into the
+	// fields that request it, and must not be interpolated again.
+	if c.disableExpansion {
+		k.resolver = c.resolver
+	}
+	return k
 }
 
-func (c *Configurator) load(serviceName string, cfg *yarpcConfig) (_ yarpc.Config, err error) {
+// load loads the decoded configuration, failing with the given errors of the
+// configuration, if any, before building anything.
+func (c *Configurator) load(serviceName string, cfg *yarpcConfig, err error) (yarpc.Config, error) {
 	b := newBuilder(serviceName, c.Kit(serviceName))
 
 	for _, inbound := range cfg.Inbounds {
@@ -316,11 +467,137 @@ func (c *Configurator) load(serviceName string, cfg *yarpcConfig) (_ yarpc.Confi
 		return yc, err
 	}
 
-	cfg.Logging.fill(&yc)
-	cfg.Metrics.fill(&yc)
+	if err := cfg.Logging.fill(&yc); err != nil {
+		return yarpc.Config{}, err
+	}
+	if err := cfg.Metrics.fill(&yc); err != nil {
+		return yarpc.Config{}, err
+	}
+	if err := cfg.Retries.fill(&yc); err != nil {
+		return yarpc.Config{}, err
+	}
+	procedures := make(map[string]map[string]ProcedureOptions)
+	for name, outboundConfig := range cfg.Outbounds {
+		opts, err := outboundConfig.procedureOptions()
+		if err != nil {
+			return yarpc.Config{}, fmt.Errorf("failed to load procedures for outbound %q: %v", name, err)
+		}
+		if opts != nil {
+			procedures[name] = opts
+		}
+	}
+	fillProcedureRetries(&yc, cfg.Outbounds, procedures)
+	if err := cfg.RateLimits.fill(&yc); err != nil {
+		return yarpc.Config{}, err
+	}
+	if err := cfg.Deadlines.fill(&yc); err != nil {
+		return yarpc.Config{}, err
+	}
+	if err := cfg.Timeouts.fill(&yc); err != nil {
+		return yarpc.Config{}, err
+	}
+	cfg.PanicRecovery.fill(&yc)
+	if err := cfg.HeaderPropagation.fill(&yc); err != nil {
+		return yarpc.Config{}, err
+	}
+	chain, err := c.loadMiddleware(b.kit, cfg.Middleware)
+	if err != nil {
+		return yarpc.Config{}, err
+	}
+	yc.MiddlewareChain = chain
+	for name, outboundConfig := range cfg.Outbounds {
+		chain, err := c.loadMiddleware(b.kit, middlewareConfig{Both: outboundConfig.Middleware})
+		if err != nil {
+			return yarpc.Config{}, fmt.Errorf("failed to load middleware for outbound %q: %v", name, err)
+		}
+		if opts, ok := procedures[name]; ok {
+			if chain == nil {
+				chain = middleware.NewChain()
+			}
+			// The options of the procedures are available to all the
+			// middleware of the outbound.
+			if err := chain.Register(_proceduresMiddlewareName, newProceduresMiddleware(opts), middleware.First); err != nil {
+				return yarpc.Config{}, fmt.Errorf("failed to load middleware for outbound %q: %v", name, err)
+			}
+		}
+		if chain == nil {
+			continue
+		}
+		if yc.PerOutboundMiddleware == nil {
+			yc.PerOutboundMiddleware = make(map[string]*middleware.Chain)
+		}
+		yc.PerOutboundMiddleware[name] = chain
+	}
+	if c.meter != nil {
+		yc.Metrics.Metrics = c.meter
+	}
 	return yc, nil
 }
 
+// loadMiddleware builds the configured middleware into a chain, registering
+// them in order, so that middleware whose order is not constrained keep the
+// order of the configuration. Middleware configured for inbounds or
+// outbounds only are restricted to inbound requests or outbound calls.
+func (c *Configurator) loadMiddleware(kit *Kit, cfg middlewareConfig) (*middleware.Chain, error) {
+	if len(cfg.Both)+len(cfg.Inbound)+len(cfg.Outbound) == 0 {
+		return nil, nil
+	}
+
+	chain := middleware.NewChain()
+	sections := []struct {
+		items    middlewareList
+		kind     string
+		restrict func(interface{}) (interface{}, bool)
+	}{
+		{items: cfg.Both},
+		{items: cfg.Inbound, kind: "inbound", restrict: inboundOnly},
+		{items: cfg.Outbound, kind: "outbound", restrict: outboundOnly},
+	}
+	for _, section := range sections {
+		for _, item := range section.items {
+			spec, mw, err := c.buildMiddleware(kit, item)
+			if err != nil {
+				return nil, err
+			}
+			if section.restrict != nil {
+				restricted, ok := section.restrict(mw)
+				if !ok {
+					return nil, fmt.Errorf("middleware %q of type %T implements no %v middleware interface", item.Name, mw, section.kind)
+				}
+				mw = restricted
+			}
+			if err := chain.Register(item.Name, mw, spec.Constraints...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := chain.Names(); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// buildMiddleware builds the configured middleware with its spec.
+func (c *Configurator) buildMiddleware(kit *Kit, item middlewareItem) (*compiledMiddlewareSpec, interface{}, error) {
+	spec, ok := c.knownMiddleware[item.Name]
+	if !ok {
+		known := make([]string, 0, len(c.knownMiddleware))
+		for name := range c.knownMiddleware {
+			known = append(known, name)
+		}
+		return nil, nil, fmt.Errorf("unknown middleware %q%v", item.Name, didYouMean(suggest(item.Name, known)))
+	}
+	cv, err := spec.Middleware.Decode(item.Attributes, config.InterpolateWith(kit.resolver))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode middleware %q: %v", item.Name, err)
+	}
+	mw, err := cv.Build(kit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build middleware %q: %v", item.Name, err)
+	}
+	return spec, mw, nil
+}
+
 func (c *Configurator) loadInboundInto(b *builder, i inbound) error {
 	if i.Disabled {
 		return nil
@@ -393,7 +670,11 @@ func (c *Configurator) loadTransportInto(b *builder, name string, attrs config.A
 func (c *Configurator) spec(name string) (*compiledTransportSpec, error) {
 	spec, ok := c.knownTransports[name]
 	if !ok {
-		return nil, fmt.Errorf("unknown transport %q", name)
+		known := make([]string, 0, len(c.knownTransports))
+		for name := range c.knownTransports {
+			known = append(known, name)
+		}
+		return nil, fmt.Errorf("unknown transport %q%v", name, didYouMean(suggest(name, known)))
 	}
 	return spec, nil
 }
@@ -21,6 +21,7 @@
 package yarpcconfig
 
 import (
+	"errors"
 	"reflect"
 	"strings"
 	"testing"
@@ -30,9 +31,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/middleware"
 	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/internal/backoff"
 	"go.uber.org/yarpc/internal/interpolate"
 	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/yaml.v2"
 )
@@ -89,6 +93,9 @@ func TestConfigurator(t *testing.T) {
 		// Environment variables
 		env map[string]string
 
+		// Options of the Configurator, if any
+		opts []Option
+
 		// If non-empty, an error is expected where the message matches all
 		// strings in this slice
 		wantErr []string
@@ -822,9 +829,7 @@ func TestConfigurator(t *testing.T) {
 				tt.specs = []TransportSpec{http.Spec()}
 
 				tt.wantErr = []string{
-					"failed to decode transport configuration:",
-					"error decoding 'KeepAlive'",
-					`invalid duration`,
+					`invalid duration at transports.http.keepAlive:`,
 					`thirty`,
 				}
 
@@ -1750,6 +1755,8 @@ func TestConfigurator(t *testing.T) {
 							address: :${HTTP_PORT
 				`)
 				tt.env = map[string]string{"HTTP_PORT": "8080"}
+				// Fields are interpolated as they are decoded without expansion.
+				tt.opts = []Option{DisableExpansion()}
 
 				http := mockTransportSpecBuilder{
 					Name:            "http",
@@ -1780,6 +1787,8 @@ func TestConfigurator(t *testing.T) {
 						http:
 							address: :${HTTP_PORT}
 				`)
+				// Fields are interpolated as they are decoded without expansion.
+				tt.opts = []Option{DisableExpansion()}
 
 				http := mockTransportSpecBuilder{
 					Name:            "http",
@@ -1855,7 +1864,7 @@ func TestConfigurator(t *testing.T) {
 			defer mockCtrl.Finish()
 
 			tt := tc.test(t, mockCtrl)
-			cfg := New(InterpolationResolver(mapVariableResolver(tt.env)))
+			cfg := New(append([]Option{InterpolationResolver(mapVariableResolver(tt.env))}, tt.opts...)...)
 
 			if tt.specs != nil {
 				for _, spec := range tt.specs {
@@ -1886,6 +1895,8 @@ func TestConfigurator(t *testing.T) {
 			}
 
 			require.NoError(t, err, "expected success")
+			// Configured dispatchers recover panics unless they opt out.
+			tt.wantConfig.PanicRecovery.Enabled = true
 			assert.Equal(t, tt.wantConfig, gotConfig, "config did not match")
 		})
 	}
@@ -1897,3 +1908,951 @@ func mapVariableResolver(m map[string]string) interpolate.VariableResolver {
 		return
 	}
 }
+
+func TestConfiguratorRetries(t *testing.T) {
+	cfg := New()
+	got, err := cfg.LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+		retries:
+			default:
+				maxAttempts: 3
+			overrides:
+				- service: bar
+				  maxAttempts: 5
+				  backoff:
+						exponential:
+							first: 10ms
+							max: 1s
+				  retryableCodes: [unavailable, resource-exhausted]
+				- service: bar
+				  procedure: charge
+				  maxAttempts: 1
+			budget:
+				maxTokens: 20
+				tokenRatio: 0.2
+	`)))
+	require.NoError(t, err)
+
+	retry := got.Retry
+	require.NotNil(t, retry.Default, "expected a default policy")
+	assert.Equal(t, 3, retry.Default.MaxAttempts)
+	assert.NotNil(t, retry.Default.Backoff, "expected a default backoff")
+	assert.Empty(t, retry.Default.RetryableCodes)
+
+	require.Len(t, retry.Overrides, 2)
+	assert.Equal(t, "bar", retry.Overrides[0].Service)
+	assert.Empty(t, retry.Overrides[0].Procedure)
+	assert.Equal(t, 5, retry.Overrides[0].Policy.MaxAttempts)
+	assert.Equal(t,
+		[]yarpcerrors.Code{yarpcerrors.CodeUnavailable, yarpcerrors.CodeResourceExhausted},
+		retry.Overrides[0].Policy.RetryableCodes)
+
+	wantBackoff, err := backoff.NewExponential(
+		backoff.FirstBackoff(10*time.Millisecond),
+		backoff.MaxBackoff(time.Second),
+	)
+	require.NoError(t, err)
+	gotBackoff, ok := retry.Overrides[0].Policy.Backoff.(*backoff.ExponentialStrategy)
+	require.True(t, ok, "expected exponential backoff, got %T", retry.Overrides[0].Policy.Backoff)
+	assert.True(t, wantBackoff.IsEqual(gotBackoff), "backoff did not match")
+
+	assert.Equal(t, "bar", retry.Overrides[1].Service)
+	assert.Equal(t, "charge", retry.Overrides[1].Procedure)
+	assert.Equal(t, 1, retry.Overrides[1].Policy.MaxAttempts)
+
+	assert.Equal(t, yarpc.RetryBudgetConfig{MaxTokens: 20, TokenRatio: 0.2}, retry.Budget)
+}
+
+func TestConfiguratorRetriesErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr string
+	}{
+		{
+			desc: "override without service",
+			give: `
+				retries:
+					overrides:
+						- procedure: charge
+						  maxAttempts: 2
+			`,
+			wantErr: "service is required",
+		},
+		{
+			desc: "unknown code",
+			give: `
+				retries:
+					default:
+						retryableCodes: [flaky]
+			`,
+			wantErr: "could not decode error code",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(tt.give)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestConfiguratorRateLimits(t *testing.T) {
+	got, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+		rateLimits:
+			default:
+				rate: 100
+			keyByCaller: true
+			overrides:
+				- procedure: Store::get
+				  rate: 1000
+				  burst: 2000
+				- caller: batch
+				  rate: 10
+	`)))
+	require.NoError(t, err)
+
+	assert.Equal(t, yarpc.RateLimitConfig{
+		Default:     &yarpc.RateLimit{Rate: 100},
+		KeyByCaller: true,
+		Overrides: []yarpc.RateLimitOverride{
+			{Procedure: "Store::get", Limit: yarpc.RateLimit{Rate: 1000, Burst: 2000}},
+			{Caller: "batch", Limit: yarpc.RateLimit{Rate: 10}},
+		},
+	}, got.RateLimit)
+
+	_, err = New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+		rateLimits:
+			overrides:
+				- rate: 10
+	`)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "procedure or caller is required")
+}
+
+func TestConfiguratorDeadlines(t *testing.T) {
+	got, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+		deadlines:
+			inbound:
+				minTTL: 10ms
+				overrides:
+					- procedure: Store::scan
+					  minTTL: 500ms
+			outbound:
+				minTTL: 5ms
+				defaultTTL: 1s
+	`)))
+	require.NoError(t, err)
+
+	assert.Equal(t, yarpc.DeadlineConfig{
+		Inbound: yarpc.InboundDeadlineConfig{
+			MinTTL: 10 * time.Millisecond,
+			Overrides: []yarpc.InboundDeadlineOverride{
+				{Procedure: "Store::scan", MinTTL: 500 * time.Millisecond},
+			},
+		},
+		Outbound: yarpc.OutboundDeadlineConfig{
+			MinTTL:     5 * time.Millisecond,
+			DefaultTTL: time.Second,
+		},
+	}, got.Deadline)
+
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr string
+	}{
+		{
+			desc: "override without procedure",
+			give: `
+				deadlines:
+					inbound:
+						overrides:
+							- minTTL: 1s
+			`,
+			wantErr: "procedure is required",
+		},
+		{
+			desc: "negative override",
+			give: `
+				deadlines:
+					inbound:
+						overrides:
+							- procedure: Store::get
+							  minTTL: -1s
+			`,
+			wantErr: `invalid deadline override for procedure "Store::get"`,
+		},
+		{
+			desc: "negative default TTL",
+			give: `
+				deadlines:
+					outbound:
+						defaultTTL: -1s
+			`,
+			wantErr: "TTLs must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(tt.give)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestConfiguratorPanicRecovery(t *testing.T) {
+	got, err := New().LoadConfigFromYAML("foo", strings.NewReader(""))
+	require.NoError(t, err)
+	assert.True(t, got.PanicRecovery.Enabled, "panic recovery must be enabled by default")
+
+	got, err = New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+		panicRecovery:
+			disabled: true
+	`)))
+	require.NoError(t, err)
+	assert.False(t, got.PanicRecovery.Enabled, "panic recovery must be disabled")
+}
+
+func TestConfiguratorHeaderPropagation(t *testing.T) {
+	got, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+		headerPropagation:
+			headers:
+				- x-request-id
+				- X-Tenant
+			maxValueSize: 256
+			rename:
+				x-tenant: x-upstream-tenant
+	`)))
+	require.NoError(t, err)
+	assert.Equal(t, yarpc.HeaderPropagationConfig{
+		Headers:      []string{"x-request-id", "X-Tenant"},
+		MaxValueSize: 256,
+		Rename:       map[string]string{"x-tenant": "x-upstream-tenant"},
+	}, got.HeaderPropagation)
+
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr string
+	}{
+		{
+			desc: "negative size",
+			give: `
+				headerPropagation:
+					headers: [x-request-id]
+					maxValueSize: -1
+			`,
+			wantErr: "maxValueSize must not be negative",
+		},
+		{
+			desc: "empty header",
+			give: `
+				headerPropagation:
+					headers: [""]
+			`,
+			wantErr: "header names must not be empty",
+		},
+		{
+			desc: "rename of header that is not propagated",
+			give: `
+				headerPropagation:
+					headers: [x-request-id]
+					rename:
+						x-tenant: x-upstream-tenant
+			`,
+			wantErr: `cannot rename header "x-tenant", which is not propagated`,
+		},
+		{
+			desc: "rename to empty name",
+			give: `
+				headerPropagation:
+					headers: [x-request-id]
+					rename:
+						x-request-id: ""
+			`,
+			wantErr: `cannot rename header "x-request-id" to an empty name`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(tt.give)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestConfiguratorTimeouts(t *testing.T) {
+	got, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+		timeouts:
+			default: 2s
+			overrides:
+				- procedure: Store::scan
+				  timeout: 30s
+				- procedure: Admin::*
+				  timeout: 5m
+	`)))
+	require.NoError(t, err)
+	assert.Equal(t, yarpc.TimeoutConfig{
+		Default: 2 * time.Second,
+		Overrides: []yarpc.TimeoutOverride{
+			{Procedure: "Store::scan", Timeout: 30 * time.Second},
+			{Procedure: "Admin::*", Timeout: 5 * time.Minute},
+		},
+	}, got.Timeout)
+
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr string
+	}{
+		{
+			desc: "negative default",
+			give: `
+				timeouts:
+					default: -1s
+			`,
+			wantErr: "default must not be negative",
+		},
+		{
+			desc: "missing procedure",
+			give: `
+				timeouts:
+					overrides:
+						- timeout: 1s
+			`,
+			wantErr: "procedure is required",
+		},
+		{
+			desc: "wildcard in the middle",
+			give: `
+				timeouts:
+					overrides:
+						- procedure: Store::*::get
+						  timeout: 1s
+			`,
+			wantErr: `invalid timeout override for procedure "Store::*::get": wildcard must be at the end`,
+		},
+		{
+			desc: "duplicate procedure",
+			give: `
+				timeouts:
+					overrides:
+						- procedure: Store::get
+						  timeout: 1s
+						- procedure: Store::get
+						  timeout: 2s
+			`,
+			wantErr: `procedure is overridden more than once`,
+		},
+		{
+			desc: "negative timeout",
+			give: `
+				timeouts:
+					overrides:
+						- procedure: Store::get
+						  timeout: -1s
+			`,
+			wantErr: `invalid timeout override for procedure "Store::get": timeout must not be negative`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(tt.give)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestConfiguratorLatencyBuckets(t *testing.T) {
+	got, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+		metrics:
+			latencyBuckets:
+				buckets: [1ms, 5ms, 1s]
+			latencyBucketOverrides:
+				- procedure: Batch::*
+				  exponential: {start: 100ms, factor: 2, count: 3}
+				- procedure: Store::get
+				  buckets: [0s, 1ms]
+	`)))
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Millisecond, 5 * time.Millisecond, time.Second}, got.Metrics.LatencyBuckets)
+	assert.Equal(t, []yarpc.LatencyBucketsOverride{
+		{Procedure: "Batch::*", Buckets: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}},
+		{Procedure: "Store::get", Buckets: []time.Duration{0, time.Millisecond}},
+	}, got.Metrics.LatencyBucketOverrides)
+
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr string
+	}{
+		{
+			desc: "sub-millisecond bucket",
+			give: `
+				metrics:
+					latencyBuckets:
+						buckets: [500us]
+			`,
+			wantErr: "invalid latency buckets: bucket 500µs is not a whole number of milliseconds",
+		},
+		{
+			desc: "buckets not ascending",
+			give: `
+				metrics:
+					latencyBuckets:
+						buckets: [5ms, 1ms]
+			`,
+			wantErr: "invalid latency buckets: buckets must be ascending, got 1ms after 5ms",
+		},
+		{
+			desc: "buckets and exponential",
+			give: `
+				metrics:
+					latencyBuckets:
+						buckets: [1ms]
+						exponential: {start: 1ms, factor: 2, count: 2}
+			`,
+			wantErr: "invalid latency buckets: only one of buckets and exponential may be set",
+		},
+		{
+			desc: "exponential factor too small",
+			give: `
+				metrics:
+					latencyBuckets:
+						exponential: {start: 1ms, factor: 1, count: 2}
+			`,
+			wantErr: "invalid latency buckets: exponential factor must be greater than 1, got 1",
+		},
+		{
+			desc: "missing procedure",
+			give: `
+				metrics:
+					latencyBucketOverrides:
+						- buckets: [1ms]
+			`,
+			wantErr: "procedure is required",
+		},
+		{
+			desc: "wildcard in the middle",
+			give: `
+				metrics:
+					latencyBucketOverrides:
+						- procedure: Store::*::get
+						  buckets: [1ms]
+			`,
+			wantErr: `invalid latency bucket override for procedure "Store::*::get": wildcard must be at the end`,
+		},
+		{
+			desc: "duplicate procedure",
+			give: `
+				metrics:
+					latencyBucketOverrides:
+						- procedure: Store::get
+						  buckets: [1ms]
+						- procedure: Store::get
+						  buckets: [2ms]
+			`,
+			wantErr: "procedure is overridden more than once",
+		},
+		{
+			desc: "override without buckets",
+			give: `
+				metrics:
+					latencyBucketOverrides:
+						- procedure: Store::get
+			`,
+			wantErr: `invalid latency bucket override for procedure "Store::get": at least one bucket is required`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(tt.give)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestConfiguratorDisablePayloadSizeMetrics(t *testing.T) {
+	got, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+		metrics:
+			disablePayloadSizeMetrics: true
+	`)))
+	require.NoError(t, err)
+	assert.True(t, got.Metrics.DisablePayloadSizeMetrics)
+
+	got, err = New().LoadConfigFromYAML("foo", strings.NewReader("metrics: {}"))
+	require.NoError(t, err)
+	assert.False(t, got.Metrics.DisablePayloadSizeMetrics)
+}
+
+func TestConfiguratorLoggingRedaction(t *testing.T) {
+	got, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+		logging:
+			redaction:
+				logHeaders: true
+				allowedHeaders: [x-request-id, authorization]
+				deniedHeaders: [authorization]
+				errorPatterns:
+					- 'token=\S+'
+					- '\d{16}'
+	`)))
+	require.NoError(t, err)
+
+	redaction := got.Logging.Redaction
+	assert.True(t, redaction.LogHeaders)
+	assert.Equal(t, []string{"x-request-id", "authorization"}, redaction.AllowedHeaders)
+	assert.Equal(t, []string{"authorization"}, redaction.DeniedHeaders)
+	require.NotNil(t, redaction.ErrorMessageScrubber, "error scrubber must be set")
+	assert.Equal(t,
+		"bad [REDACTED] for card [REDACTED]",
+		redaction.ErrorMessageScrubber("Store::get", "bad token=abc123 for card 4111111111111111"))
+
+	got, err = New().LoadConfigFromYAML("foo", strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Nil(t, got.Logging.Redaction.ErrorMessageScrubber, "error scrubber must not be set by default")
+
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr string
+	}{
+		{
+			desc: "empty header",
+			give: `
+				logging:
+					redaction:
+						deniedHeaders: [""]
+			`,
+			wantErr: "invalid logging redaction: header names must not be empty",
+		},
+		{
+			desc: "invalid pattern",
+			give: `
+				logging:
+					redaction:
+						errorPatterns: ["token=("]
+			`,
+			wantErr: `invalid logging redaction: cannot compile error pattern "token=("`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(tt.give)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+type namedMiddleware struct {
+	middleware.UnaryInbound
+
+	Name string
+}
+
+// bidiMiddleware is inbound and outbound middleware.
+type bidiMiddleware struct {
+	middleware.UnaryInbound
+	middleware.UnaryOutbound
+}
+
+func TestConfiguratorMiddleware(t *testing.T) {
+	type authConfig struct {
+		Key string `config:"key"`
+	}
+	type auditConfig struct {
+		Path string `config:"path,interpolate"`
+	}
+
+	newConfigurator := func(t *testing.T, opts ...Option) *Configurator {
+		c := New(opts...)
+		require.NoError(t, c.RegisterMiddleware(MiddlewareSpec{
+			Name: "auth",
+			BuildMiddleware: func(cfg authConfig, _ *Kit) (middleware.UnaryInbound, error) {
+				if cfg.Key == "" {
+					return nil, errors.New("key is required")
+				}
+				return namedMiddleware{UnaryInbound: middleware.NopUnaryInbound, Name: "auth:" + cfg.Key}, nil
+			},
+		}))
+		require.NoError(t, c.RegisterMiddleware(MiddlewareSpec{
+			Name: "audit",
+			BuildMiddleware: func(cfg *auditConfig, _ *Kit) (interface{}, error) {
+				return namedMiddleware{UnaryInbound: middleware.NopUnaryInbound, Name: "audit:" + cfg.Path}, nil
+			},
+			Constraints: []middleware.Constraint{middleware.After("auth")},
+		}))
+		require.NoError(t, c.RegisterMiddleware(MiddlewareSpec{
+			Name: "bogus",
+			BuildMiddleware: func(struct{}, *Kit) (string, error) {
+				return "not middleware", nil
+			},
+		}))
+		c.MustRegisterMiddleware(MiddlewareSpec{
+			Name: "tracing",
+			BuildMiddleware: func(struct{}, *Kit) (interface{}, error) {
+				return namedMiddleware{UnaryInbound: middleware.NopUnaryInbound, Name: "tracing"}, nil
+			},
+			Constraints: []middleware.Constraint{middleware.Before("auth")},
+		})
+		c.MustRegisterMiddleware(MiddlewareSpec{
+			Name: "bidi",
+			BuildMiddleware: func(struct{}, *Kit) (bidiMiddleware, error) {
+				return bidiMiddleware{
+					UnaryInbound:  middleware.NopUnaryInbound,
+					UnaryOutbound: middleware.NopUnaryOutbound,
+				}, nil
+			},
+		})
+		c.MustRegisterMiddleware(MiddlewareSpec{
+			Name: "metrics",
+			BuildMiddleware: func(struct{}, *Kit) (interface{}, error) {
+				return namedMiddleware{UnaryInbound: middleware.NopUnaryInbound, Name: "metrics"}, nil
+			},
+		})
+		return c
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		got, err := newConfigurator(t).LoadConfigFromYAML("foo", strings.NewReader(""))
+		require.NoError(t, err)
+		assert.Nil(t, got.MiddlewareChain)
+	})
+
+	t.Run("ordered", func(t *testing.T) {
+		c := newConfigurator(t, InterpolationResolver(mapVariableResolver(map[string]string{
+			"AUDIT_PATH": "/var/log/audit.log",
+		})))
+		got, err := c.LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+			middleware:
+				audit:
+					path: ${AUDIT_PATH}
+				auth:
+					key: secret
+				tracing: {}
+		`)))
+		require.NoError(t, err)
+		require.NotNil(t, got.MiddlewareChain)
+
+		names, err := got.MiddlewareChain.Names()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tracing", "auth", "audit"}, names)
+
+		ordered, err := got.MiddlewareChain.Ordered()
+		require.NoError(t, err)
+		var built []string
+		for _, mw := range ordered {
+			built = append(built, mw.(namedMiddleware).Name)
+		}
+		assert.Equal(t, []string{"tracing", "auth:secret", "audit:/var/log/audit.log"}, built)
+	})
+
+	t.Run("per outbound", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		type transportConfig struct{}
+		type outboundConfig struct{ URL string }
+		http := mockTransportSpecBuilder{
+			Name:                "http",
+			TransportConfig:     reflect.TypeOf(&transportConfig{}),
+			UnaryOutboundConfig: reflect.TypeOf(&outboundConfig{}),
+		}.Build(mockCtrl)
+		transport := transporttest.NewMockTransport(mockCtrl)
+		http.EXPECT().BuildTransport(gomock.Any(), gomock.Any()).Return(transport, nil)
+		http.EXPECT().BuildUnaryOutbound(gomock.Any(), transport, gomock.Any()).
+			Return(transporttest.NewMockUnaryOutbound(mockCtrl), nil).Times(2)
+
+		c := newConfigurator(t)
+		require.NoError(t, c.RegisterTransport(http.Spec()))
+		got, err := c.LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+			outbounds:
+				bar:
+					middleware:
+						auth:
+							key: secret
+						tracing: {}
+					http:
+						url: http://localhost:8080/bar
+				baz:
+					http:
+						url: http://localhost:8080/baz
+		`)))
+		require.NoError(t, err)
+		assert.Nil(t, got.MiddlewareChain)
+		require.Len(t, got.PerOutboundMiddleware, 1)
+		require.Contains(t, got.PerOutboundMiddleware, "bar")
+
+		names, err := got.PerOutboundMiddleware["bar"].Names()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tracing", "auth"}, names)
+	})
+
+	t.Run("inbound and outbound only", func(t *testing.T) {
+		got, err := newConfigurator(t).LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+			middleware:
+				inbound:
+					- metrics
+					- audit: {path: /var/log/audit.log}
+					- auth: {key: secret}
+				outbound:
+					bidi: {}
+		`)))
+		require.NoError(t, err)
+		require.NotNil(t, got.MiddlewareChain)
+
+		names, err := got.MiddlewareChain.Names()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"metrics", "auth", "audit", "bidi"}, names,
+			"expected listed middleware in order, except as constrained")
+
+		ordered, err := got.MiddlewareChain.Ordered()
+		require.NoError(t, err)
+		assert.IsType(t, namedMiddleware{}, ordered[0], "expected inbound middleware to be kept as is")
+		_, isInbound := ordered[3].(middleware.UnaryInbound)
+		assert.False(t, isInbound, "expected outbound middleware to be restricted to outbound calls")
+		_, isOutbound := ordered[3].(middleware.UnaryOutbound)
+		assert.True(t, isOutbound, "expected outbound middleware to apply to outbound calls")
+	})
+
+	t.Run("per outbound list", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		type transportConfig struct{}
+		type outboundConfig struct{ URL string }
+		http := mockTransportSpecBuilder{
+			Name:                "http",
+			TransportConfig:     reflect.TypeOf(&transportConfig{}),
+			UnaryOutboundConfig: reflect.TypeOf(&outboundConfig{}),
+		}.Build(mockCtrl)
+		transport := transporttest.NewMockTransport(mockCtrl)
+		http.EXPECT().BuildTransport(gomock.Any(), gomock.Any()).Return(transport, nil)
+		http.EXPECT().BuildUnaryOutbound(gomock.Any(), transport, gomock.Any()).
+			Return(transporttest.NewMockUnaryOutbound(mockCtrl), nil)
+
+		c := newConfigurator(t)
+		require.NoError(t, c.RegisterTransport(http.Spec()))
+		got, err := c.LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+			outbounds:
+				bar:
+					middleware:
+						- metrics
+						- bidi
+						- auth: {key: secret}
+					http:
+						url: http://localhost:8080/bar
+		`)))
+		require.NoError(t, err)
+		require.Contains(t, got.PerOutboundMiddleware, "bar")
+
+		names, err := got.PerOutboundMiddleware["bar"].Names()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"metrics", "bidi", "auth"}, names)
+	})
+
+	t.Run("per outbound error", func(t *testing.T) {
+		_, err := newConfigurator(t).LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+			outbounds:
+				bar:
+					middleware:
+						missing: {}
+					http:
+						url: http://localhost:8080/bar
+		`)))
+		require.Error(t, err)
+	})
+
+	tests := []struct {
+		desc    string
+		opts    []Option
+		give    string
+		wantErr string
+	}{
+		{
+			desc: "unknown middleware",
+			give: `
+				middleware:
+					missing: {}
+			`,
+			wantErr: `unknown middleware "missing"`,
+		},
+		{
+			desc: "build error",
+			give: `
+				middleware:
+					auth: {}
+			`,
+			wantErr: `failed to build middleware "auth": key is required`,
+		},
+		{
+			desc: "decode error",
+			give: `
+				middleware:
+					auth:
+						key: [a, b]
+			`,
+			wantErr: `failed to decode middleware "auth"`,
+		},
+		{
+			desc: "not middleware",
+			give: `
+				middleware:
+					bogus: {}
+			`,
+			wantErr: `middleware "bogus" of type string implements no middleware interface`,
+		},
+		{
+			desc: "unknown listed middleware",
+			give: `
+				middleware:
+					inbound: [metrics, missing]
+			`,
+			wantErr: `unknown middleware "missing"`,
+		},
+		{
+			desc: "unknown key of listed middleware",
+			opts: []Option{StrictKeys()},
+			give: `
+				middleware:
+					inbound:
+						- auth: {keyy: secret}
+			`,
+			wantErr: `unknown key "keyy" at middleware.inbound[0].auth.keyy: did you mean "key"?`,
+		},
+		{
+			desc: "unknown key of outbound middleware",
+			opts: []Option{StrictKeys()},
+			give: `
+				outbounds:
+					bar:
+						middleware:
+							- auth: {keyy: secret}
+						http:
+							url: http://localhost:8080/bar
+			`,
+			wantErr: `unknown key "keyy" at outbounds.bar.middleware[0].auth.keyy: did you mean "key"?`,
+		},
+		{
+			desc: "several middleware in a list item",
+			give: `
+				middleware:
+					inbound:
+						- metrics: {}
+						  tracing: {}
+			`,
+			wantErr: "middleware in a list must be a name, or a map from a name to attributes",
+		},
+		{
+			desc: "inbound middleware for outbounds",
+			give: `
+				middleware:
+					outbound: [metrics]
+			`,
+			wantErr: `middleware "metrics" of type yarpcconfig.namedMiddleware implements no outbound middleware interface`,
+		},
+		{
+			desc: "middleware configured twice",
+			give: `
+				middleware:
+					metrics: {}
+					inbound: [metrics]
+			`,
+			wantErr: `middleware "metrics" is already registered`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := newConfigurator(t, tt.opts...).LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(tt.give)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	t.Run("conflicting constraints", func(t *testing.T) {
+		c := newConfigurator(t)
+		c.MustRegisterMiddleware(MiddlewareSpec{
+			Name: "tracing",
+			BuildMiddleware: func(struct{}, *Kit) (interface{}, error) {
+				return middleware.NopUnaryInbound, nil
+			},
+			Constraints: []middleware.Constraint{middleware.Before("auth"), middleware.After("audit")},
+		})
+		_, err := c.LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+			middleware:
+				audit: {}
+				tracing: {}
+				auth:
+					key: secret
+		`)))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "conflicting middleware order constraints")
+	})
+}
+
+func TestRegisterMiddlewareErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    MiddlewareSpec
+		wantErr string
+	}{
+		{
+			desc:    "no name",
+			wantErr: "name is required",
+		},
+		{
+			desc:    "reserved name",
+			give:    MiddlewareSpec{Name: "inbound"},
+			wantErr: `middleware name "inbound" is reserved`,
+		},
+		{
+			desc:    "reserved name for procedures",
+			give:    MiddlewareSpec{Name: "procedures"},
+			wantErr: `middleware name "procedures" is reserved`,
+		},
+		{
+			desc:    "no builder",
+			give:    MiddlewareSpec{Name: "foo"},
+			wantErr: `invalid MiddlewareSpec for "foo": field BuildMiddleware is required`,
+		},
+		{
+			desc:    "not a function",
+			give:    MiddlewareSpec{Name: "foo", BuildMiddleware: 42},
+			wantErr: "must be a function",
+		},
+		{
+			desc: "missing kit",
+			give: MiddlewareSpec{Name: "foo", BuildMiddleware: func(struct{}) (interface{}, error) {
+				return nil, nil
+			}},
+			wantErr: "must accept exactly two arguments, found 1",
+		},
+		{
+			desc: "no error",
+			give: MiddlewareSpec{Name: "foo", BuildMiddleware: func(struct{}, *Kit) (interface{}, string) {
+				return nil, ""
+			}},
+			wantErr: "must return an error as its second result, found string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := New().RegisterMiddleware(tt.give)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
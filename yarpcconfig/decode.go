@@ -23,32 +23,452 @@ package yarpcconfig
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/uber-go/mapdecode"
 	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/config"
+	"go.uber.org/yarpc/internal/observability"
+	"go.uber.org/yarpc/yarpcerrors"
 	"go.uber.org/zap/zapcore"
 )
 
 type yarpcConfig struct {
-	Inbounds   inbounds                       `config:"inbounds"`
-	Outbounds  clientConfigs                  `config:"outbounds"`
-	Transports map[string]config.AttributeMap `config:"transports"`
-	Logging    logging                        `config:"logging"`
-	Metrics    metrics                        `config:"metrics"`
+	Inbounds          inbounds                       `config:"inbounds"`
+	Outbounds         clientConfigs                  `config:"outbounds"`
+	Transports        map[string]config.AttributeMap `config:"transports"`
+	Defaults          defaults                       `config:"defaults"`
+	Logging           logging                        `config:"logging"`
+	Metrics           metrics                        `config:"metrics"`
+	Retries           retries                        `config:"retries"`
+	RateLimits        rateLimits                     `config:"rateLimits"`
+	Deadlines         deadlines                      `config:"deadlines"`
+	Timeouts          timeouts                       `config:"timeouts"`
+	PanicRecovery     panicRecovery                  `config:"panicRecovery"`
+	HeaderPropagation headerPropagation              `config:"headerPropagation"`
+	Middleware        middlewareConfig               `config:"middleware"`
+}
+
+// middlewareConfig is the named middleware of a dispatcher: those applied to
+// both inbound requests and outbound calls, keyed by name, and those listed
+// under the 'inbound' and 'outbound' keys, applied to inbound requests or
+// outbound calls only.
+type middlewareConfig struct {
+	Both     middlewareList
+	Inbound  middlewareList
+	Outbound middlewareList
+}
+
+func (m *middlewareConfig) Decode(into mapdecode.Into) error {
+	var attrs config.AttributeMap
+	if err := into(&attrs); err != nil {
+		return fmt.Errorf("failed to decode middleware configuration: %v", err)
+	}
+	if _, err := attrs.Pop("inbound", &m.Inbound); err != nil {
+		return fmt.Errorf("failed to read inbound middleware: %v", err)
+	}
+	if _, err := attrs.Pop("outbound", &m.Outbound); err != nil {
+		return fmt.Errorf("failed to read outbound middleware: %v", err)
+	}
+	return attrs.Decode(&m.Both)
+}
+
+// middlewareList is named middleware in order. It is configured either as a
+// map from the names of middleware to their attributes, ordered by name, or
+// as a list whose items are the names of middleware, or maps from the name of
+// a single middleware to its attributes.
+//
+// 	- auth
+// 	- ratelimit:
+// 	    rps: 100
+type middlewareList []middlewareItem
+
+type middlewareItem struct {
+	Name       string
+	Attributes config.AttributeMap
+}
+
+func (l *middlewareList) Decode(into mapdecode.Into) error {
+	var attrs map[string]config.AttributeMap
+	if err := into(&attrs); err == nil {
+		names := make([]string, 0, len(attrs))
+		for name := range attrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		items := make(middlewareList, len(names))
+		for i, name := range names {
+			items[i] = middlewareItem{Name: name, Attributes: attrs[name]}
+		}
+		*l = items
+		return nil
+	}
+
+	var items []middlewareItem
+	if err := into(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+func (i *middlewareItem) Decode(into mapdecode.Into) error {
+	if err := into(&i.Name); err == nil {
+		return nil
+	}
+
+	var attrs map[string]config.AttributeMap
+	if err := into(&attrs); err != nil || len(attrs) != 1 {
+		return errors.New("middleware in a list must be a name, or a map from a name to attributes")
+	}
+	for name, a := range attrs {
+		i.Name, i.Attributes = name, a
+	}
+	return nil
+}
+
+// headerPropagation allows configuring the propagation of application headers
+// from inbound requests to outbound calls from YAML.
+type headerPropagation struct {
+	Headers      []string          `config:"headers"`
+	MaxValueSize int               `config:"maxValueSize"`
+	Rename       map[string]string `config:"rename"`
+}
+
+// Fills values from this object into the provided YARPC config.
+func (p *headerPropagation) fill(cfg *yarpc.Config) error {
+	if p.MaxValueSize < 0 {
+		return errors.New("invalid header propagation: maxValueSize must not be negative")
+	}
+	propagated := make(map[string]struct{}, len(p.Headers))
+	for _, h := range p.Headers {
+		if h == "" {
+			return errors.New("invalid header propagation: header names must not be empty")
+		}
+		propagated[transport.CanonicalizeHeaderKey(h)] = struct{}{}
+	}
+	for from, to := range p.Rename {
+		if _, ok := propagated[transport.CanonicalizeHeaderKey(from)]; !ok {
+			return fmt.Errorf("invalid header propagation: cannot rename header %q, which is not propagated", from)
+		}
+		if to == "" {
+			return fmt.Errorf("invalid header propagation: cannot rename header %q to an empty name", from)
+		}
+	}
+	cfg.HeaderPropagation.Headers = p.Headers
+	cfg.HeaderPropagation.MaxValueSize = p.MaxValueSize
+	cfg.HeaderPropagation.Rename = p.Rename
+	return nil
+}
+
+// panicRecovery allows opting out of the recovery of panics in handlers from
+// YAML.
+type panicRecovery struct {
+	Disabled bool `config:"disabled"`
+}
+
+// Fills values from this object into the provided YARPC config.
+func (p *panicRecovery) fill(cfg *yarpc.Config) {
+	cfg.PanicRecovery.Enabled = !p.Disabled
+}
+
+// deadlines allows configuring the minimum remaining TTLs of inbound requests
+// and outbound calls from YAML.
+type deadlines struct {
+	Inbound struct {
+		MinTTL    time.Duration      `config:"minTTL"`
+		Overrides []deadlineOverride `config:"overrides"`
+	} `config:"inbound"`
+	Outbound struct {
+		MinTTL     time.Duration `config:"minTTL"`
+		DefaultTTL time.Duration `config:"defaultTTL"`
+	} `config:"outbound"`
+}
+
+type deadlineOverride struct {
+	Procedure string        `config:"procedure"`
+	MinTTL    time.Duration `config:"minTTL"`
+}
+
+// Fills values from this object into the provided YARPC config.
+func (d *deadlines) fill(cfg *yarpc.Config) error {
+	if d.Inbound.MinTTL < 0 || d.Outbound.MinTTL < 0 || d.Outbound.DefaultTTL < 0 {
+		return errors.New("invalid deadlines: TTLs must not be negative")
+	}
+	for _, o := range d.Inbound.Overrides {
+		if o.Procedure == "" {
+			return errors.New("invalid deadline override: procedure is required")
+		}
+		if o.MinTTL < 0 {
+			return fmt.Errorf("invalid deadline override for procedure %q: TTL must not be negative", o.Procedure)
+		}
+		cfg.Deadline.Inbound.Overrides = append(cfg.Deadline.Inbound.Overrides, yarpc.InboundDeadlineOverride{
+			Procedure: o.Procedure,
+			MinTTL:    o.MinTTL,
+		})
+	}
+	cfg.Deadline.Inbound.MinTTL = d.Inbound.MinTTL
+	cfg.Deadline.Outbound.MinTTL = d.Outbound.MinTTL
+	cfg.Deadline.Outbound.DefaultTTL = d.Outbound.DefaultTTL
+	return nil
+}
+
+// timeouts allows configuring the timeouts of inbound requests from YAML.
+type timeouts struct {
+	Default   time.Duration     `config:"default"`
+	Overrides []timeoutOverride `config:"overrides"`
+}
+
+type timeoutOverride struct {
+	Procedure string        `config:"procedure"`
+	Timeout   time.Duration `config:"timeout"`
+}
+
+// Fills values from this object into the provided YARPC config.
+func (t *timeouts) fill(cfg *yarpc.Config) error {
+	if t.Default < 0 {
+		return errors.New("invalid timeouts: default must not be negative")
+	}
+	seen := make(map[string]struct{}, len(t.Overrides))
+	for _, o := range t.Overrides {
+		if o.Procedure == "" {
+			return errors.New("invalid timeout override: procedure is required")
+		}
+		if i := strings.Index(o.Procedure, "*"); i >= 0 && i != len(o.Procedure)-1 {
+			return fmt.Errorf("invalid timeout override for procedure %q: wildcard must be at the end", o.Procedure)
+		}
+		if _, ok := seen[o.Procedure]; ok {
+			return fmt.Errorf("invalid timeout override for procedure %q: procedure is overridden more than once", o.Procedure)
+		}
+		seen[o.Procedure] = struct{}{}
+		if o.Timeout < 0 {
+			return fmt.Errorf("invalid timeout override for procedure %q: timeout must not be negative", o.Procedure)
+		}
+		cfg.Timeout.Overrides = append(cfg.Timeout.Overrides, yarpc.TimeoutOverride{
+			Procedure: o.Procedure,
+			Timeout:   o.Timeout,
+		})
+	}
+	cfg.Timeout.Default = t.Default
+	return nil
+}
+
+// rateLimits allows configuring the rate limits of inbound requests from
+// YAML.
+type rateLimits struct {
+	Default        *rateLimit          `config:"default"`
+	Overrides      []rateLimitOverride `config:"overrides"`
+	KeyByProcedure bool                `config:"keyByProcedure"`
+	KeyByCaller    bool                `config:"keyByCaller"`
+}
+
+type rateLimit struct {
+	Rate  float64 `config:"rate"`
+	Burst int     `config:"burst"`
+}
+
+type rateLimitOverride struct {
+	Procedure string    `config:"procedure"`
+	Caller    string    `config:"caller"`
+	Limit     rateLimit `config:",squash"`
+}
+
+// Fills values from this object into the provided YARPC config.
+func (r *rateLimits) fill(cfg *yarpc.Config) error {
+	if r.Default != nil {
+		cfg.RateLimit.Default = &yarpc.RateLimit{Rate: r.Default.Rate, Burst: r.Default.Burst}
+	}
+	for _, o := range r.Overrides {
+		if o.Procedure == "" && o.Caller == "" {
+			return errors.New("invalid rate limit override: procedure or caller is required")
+		}
+		cfg.RateLimit.Overrides = append(cfg.RateLimit.Overrides, yarpc.RateLimitOverride{
+			Procedure: o.Procedure,
+			Caller:    o.Caller,
+			Limit:     yarpc.RateLimit{Rate: o.Limit.Rate, Burst: o.Limit.Burst},
+		})
+	}
+	cfg.RateLimit.KeyByProcedure = r.KeyByProcedure
+	cfg.RateLimit.KeyByCaller = r.KeyByCaller
+	return nil
+}
+
+// retries allows configuring the retries of unary outbound calls from YAML.
+type retries struct {
+	Default   *retryPolicy          `config:"default"`
+	Overrides []retryPolicyOverride `config:"overrides"`
+	Budget    struct {
+		MaxTokens  float64 `config:"maxTokens"`
+		TokenRatio float64 `config:"tokenRatio"`
+	} `config:"budget"`
+}
+
+type retryPolicy struct {
+	MaxAttempts    int         `config:"maxAttempts"`
+	Backoff        Backoff     `config:"backoff"`
+	RetryableCodes []yarpcCode `config:"retryableCodes"`
+}
+
+type retryPolicyOverride struct {
+	Service   string      `config:"service"`
+	Procedure string      `config:"procedure"`
+	Policy    retryPolicy `config:",squash"`
+}
+
+// Fills values from this object into the provided YARPC config.
+func (r *retries) fill(cfg *yarpc.Config) error {
+	if r.Default != nil {
+		p, err := r.Default.policy()
+		if err != nil {
+			return fmt.Errorf("invalid default retry policy: %v", err)
+		}
+		cfg.Retry.Default = &p
+	}
+	for _, o := range r.Overrides {
+		if o.Service == "" {
+			return errors.New("invalid retry policy override: service is required")
+		}
+		p, err := o.Policy.policy()
+		if err != nil {
+			return fmt.Errorf("invalid retry policy for service %q: %v", o.Service, err)
+		}
+		cfg.Retry.Overrides = append(cfg.Retry.Overrides, yarpc.RetryPolicyOverride{
+			Service:   o.Service,
+			Procedure: o.Procedure,
+			Policy:    p,
+		})
+	}
+	cfg.Retry.Budget.MaxTokens = r.Budget.MaxTokens
+	cfg.Retry.Budget.TokenRatio = r.Budget.TokenRatio
+	return nil
+}
+
+func (p *retryPolicy) policy() (yarpc.RetryPolicy, error) {
+	strategy, err := p.Backoff.Strategy()
+	if err != nil {
+		return yarpc.RetryPolicy{}, err
+	}
+	codes := make([]yarpcerrors.Code, len(p.RetryableCodes))
+	for i, code := range p.RetryableCodes {
+		codes[i] = yarpcerrors.Code(code)
+	}
+	return yarpc.RetryPolicy{
+		MaxAttempts:    p.MaxAttempts,
+		Backoff:        strategy,
+		RetryableCodes: codes,
+	}, nil
+}
+
+type yarpcCode yarpcerrors.Code
+
+// mapdecode doesn't suport encoding.TextMarhsaler by default so we have to do
+// this manually.
+func (c *yarpcCode) Decode(into mapdecode.Into) error {
+	var s string
+	if err := into(&s); err != nil {
+		return fmt.Errorf("could not decode error code: %v", err)
+	}
+
+	if err := (*yarpcerrors.Code)(c).UnmarshalText([]byte(s)); err != nil {
+		return fmt.Errorf("could not decode error code: %v", err)
+	}
+	return nil
 }
 
 // metrics allows configuring the way metrics are emitted from YAML
 type metrics struct {
-	TagsBlocklist []string `config:"tagsBlocklist"`
+	TagsBlocklist          []string                `config:"tagsBlocklist"`
+	LatencyBuckets         latencyBuckets          `config:"latencyBuckets"`
+	LatencyBucketOverrides []latencyBucketOverride `config:"latencyBucketOverrides"`
+
+	DisablePayloadSizeMetrics bool `config:"disablePayloadSizeMetrics"`
+}
+
+// latencyBuckets lists the bounds of latency buckets, or generates them
+// exponentially.
+type latencyBuckets struct {
+	Buckets     []time.Duration     `config:"buckets"`
+	Exponential *exponentialBuckets `config:"exponential"`
+}
+
+type exponentialBuckets struct {
+	Start  time.Duration `config:"start"`
+	Factor float64       `config:"factor"`
+	Count  int           `config:"count"`
+}
+
+type latencyBucketOverride struct {
+	Procedure      string `config:"procedure"`
+	latencyBuckets `config:",squash"`
+}
+
+// bounds returns the bounds of the latency buckets, if any.
+func (b latencyBuckets) bounds() ([]time.Duration, error) {
+	e := b.Exponential
+	if e == nil {
+		return b.Buckets, nil
+	}
+	if len(b.Buckets) > 0 {
+		return nil, errors.New("only one of buckets and exponential may be set")
+	}
+	switch {
+	case e.Start < time.Millisecond:
+		return nil, fmt.Errorf("exponential start must be at least 1ms, got %v", e.Start)
+	case e.Factor <= 1:
+		return nil, fmt.Errorf("exponential factor must be greater than 1, got %v", e.Factor)
+	case e.Count < 1:
+		return nil, fmt.Errorf("exponential count must be positive, got %v", e.Count)
+	}
+	return yarpc.ExponentialLatencyBuckets(e.Start, e.Factor, e.Count), nil
 }
 
 // Fills values from this object into the provided YARPC config.
-func (m *metrics) fill(cfg *yarpc.Config) {
+func (m *metrics) fill(cfg *yarpc.Config) error {
 	cfg.Metrics.TagsBlocklist = m.TagsBlocklist
+	cfg.Metrics.DisablePayloadSizeMetrics = m.DisablePayloadSizeMetrics
+
+	buckets, err := m.LatencyBuckets.bounds()
+	if err == nil && len(buckets) > 0 {
+		_, err = observability.LatencyBuckets(buckets)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid latency buckets: %v", err)
+	}
+	cfg.Metrics.LatencyBuckets = buckets
+
+	seen := make(map[string]struct{}, len(m.LatencyBucketOverrides))
+	for _, o := range m.LatencyBucketOverrides {
+		if o.Procedure == "" {
+			return errors.New("invalid latency bucket override: procedure is required")
+		}
+		if i := strings.Index(o.Procedure, "*"); i >= 0 && i != len(o.Procedure)-1 {
+			return fmt.Errorf("invalid latency bucket override for procedure %q: wildcard must be at the end", o.Procedure)
+		}
+		if _, ok := seen[o.Procedure]; ok {
+			return fmt.Errorf("invalid latency bucket override for procedure %q: procedure is overridden more than once", o.Procedure)
+		}
+		seen[o.Procedure] = struct{}{}
+		buckets, err := o.bounds()
+		if err == nil {
+			_, err = observability.LatencyBuckets(buckets)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid latency bucket override for procedure %q: %v", o.Procedure, err)
+		}
+		cfg.Metrics.LatencyBucketOverrides = append(cfg.Metrics.LatencyBucketOverrides, yarpc.LatencyBucketsOverride{
+			Procedure: o.Procedure,
+			Buckets:   buckets,
+		})
+	}
+	return nil
 }
 
-// logging allows configuring the log levels from YAML.
+// logging allows configuring the log levels and redaction from YAML.
 type logging struct {
 	Levels struct {
 		// Defaults regardless of direction.
@@ -62,8 +482,21 @@ type logging struct {
 		Inbound  levels `config:"inbound"`
 		Outbound levels `config:"outbound"`
 	} `config:"levels"`
+
+	Redaction struct {
+		LogHeaders     bool     `config:"logHeaders"`
+		AllowedHeaders []string `config:"allowedHeaders"`
+		DeniedHeaders  []string `config:"deniedHeaders"`
+
+		// Regular expressions whose matches are redacted from logged error
+		// messages.
+		ErrorPatterns []string `config:"errorPatterns"`
+	} `config:"redaction"`
 }
 
+// _redacted replaces the matches of redacted error patterns in logs.
+const _redacted = "[REDACTED]"
+
 type levels struct {
 	Success          *zapLevel `config:"success"`
 	Failure          *zapLevel `config:"failure"`
@@ -73,7 +506,7 @@ type levels struct {
 }
 
 // Fills values from this object into the provided YARPC config.
-func (l *logging) fill(cfg *yarpc.Config) {
+func (l *logging) fill(cfg *yarpc.Config) error {
 	cfg.Logging.Levels.Success = (*zapcore.Level)(l.Levels.Success)
 	cfg.Logging.Levels.Failure = (*zapcore.Level)(l.Levels.Failure)
 	cfg.Logging.Levels.ApplicationError = (*zapcore.Level)(l.Levels.ApplicationError)
@@ -82,6 +515,37 @@ func (l *logging) fill(cfg *yarpc.Config) {
 
 	l.Levels.Inbound.fill(&cfg.Logging.Levels.Inbound)
 	l.Levels.Outbound.fill(&cfg.Logging.Levels.Outbound)
+
+	r := &l.Redaction
+	for _, headers := range [][]string{r.AllowedHeaders, r.DeniedHeaders} {
+		for _, h := range headers {
+			if h == "" {
+				return errors.New("invalid logging redaction: header names must not be empty")
+			}
+		}
+	}
+	cfg.Logging.Redaction.LogHeaders = r.LogHeaders
+	cfg.Logging.Redaction.AllowedHeaders = r.AllowedHeaders
+	cfg.Logging.Redaction.DeniedHeaders = r.DeniedHeaders
+
+	if len(r.ErrorPatterns) == 0 {
+		return nil
+	}
+	patterns := make([]*regexp.Regexp, 0, len(r.ErrorPatterns))
+	for _, p := range r.ErrorPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid logging redaction: cannot compile error pattern %q: %v", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+	cfg.Logging.Redaction.ErrorMessageScrubber = func(_ string, msg string) string {
+		for _, re := range patterns {
+			msg = re.ReplaceAllLiteralString(msg, _redacted)
+		}
+		return msg
+	}
+	return nil
 }
 
 func (l *levels) fill(cfg *yarpc.DirectionalLogLevelConfig) {
@@ -178,6 +642,14 @@ type outbounds struct {
 	Oneway   *outbound
 	Stream   *outbound
 	Implicit *outbound
+
+	// Middleware are the named middleware of the outbound, applied inside
+	// the middleware of every outbound.
+	Middleware middlewareList
+
+	// Procedures are the options of the calls to the procedures of the
+	// outbound, by procedure pattern.
+	Procedures map[string]procedureConfig
 }
 
 func (o *outbounds) Decode(into mapdecode.Into) error {
@@ -192,6 +664,14 @@ func (o *outbounds) Decode(into mapdecode.Into) error {
 		return fmt.Errorf("failed to read service name for outbound: %v", err)
 	}
 
+	if _, err := attrs.Pop("middleware", &o.Middleware); err != nil {
+		return fmt.Errorf("failed to read middleware for outbound: %v", err)
+	}
+
+	if _, err := attrs.Pop("procedures", &o.Procedures); err != nil {
+		return fmt.Errorf("failed to read procedures for outbound: %v", err)
+	}
+
 	hasUnary, err := attrs.Pop("unary", &o.Unary)
 	if err != nil {
 		return fmt.Errorf("failed to unary outbound configuration: %v", err)
@@ -0,0 +1,112 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"fmt"
+	"sort"
+
+	"go.uber.org/yarpc/internal/config"
+)
+
+// defaults are the attributes shared by the inbounds and outbounds of each
+// transport, keyed by the name of the transport.
+//
+//	defaults:
+//	  outbounds:
+//	    http:
+//	      tls: {mode: enforced}
+type defaults struct {
+	Inbounds  map[string]config.AttributeMap `config:"inbounds"`
+	Outbounds map[string]config.AttributeMap `config:"outbounds"`
+}
+
+// applyDefaults merges the defaults of each transport into the attributes of
+// its inbounds and outbounds.
+func (c *Configurator) applyDefaults(cfg *yarpcConfig) error {
+	d := cfg.Defaults
+	for _, section := range []map[string]config.AttributeMap{d.Inbounds, d.Outbounds} {
+		names := make([]string, 0, len(section))
+		for name := range section {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, err := c.spec(name); err != nil {
+				return fmt.Errorf("failed to apply defaults: %v", err)
+			}
+		}
+	}
+
+	for i, ib := range cfg.Inbounds {
+		if attrs, ok := d.Inbounds[ib.Type]; ok {
+			cfg.Inbounds[i].Attributes = mergeAttributes(attrs, ib.Attributes)
+		}
+	}
+	for _, cc := range cfg.Outbounds {
+		for _, o := range []*outbound{cc.Unary, cc.Oneway, cc.Stream, cc.Implicit} {
+			if o == nil {
+				continue
+			}
+			if attrs, ok := d.Outbounds[o.Type]; ok {
+				o.Attributes = mergeAttributes(attrs, o.Attributes)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeAttributes returns the attributes with the defaults they do not
+// specify. Maps are merged key by key; other values, including lists,
+// replace their defaults.
+//
+// The defaults are copied, since the attributes of one outbound must not
+// share them with another.
+func mergeAttributes(defaults, attrs map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(defaults)+len(attrs))
+	for k, v := range defaults {
+		merged[k] = copyValue(v)
+	}
+	for k, v := range attrs {
+		if m, ok := keysOf(v); ok {
+			if d, ok := keysOf(merged[k]); ok {
+				merged[k] = mergeAttributes(d, m)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func copyValue(v interface{}) interface{} {
+	if m, ok := keysOf(v); ok {
+		return mergeAttributes(m, nil)
+	}
+	if l, ok := v.([]interface{}); ok {
+		c := make([]interface{}, len(l))
+		for i, item := range l {
+			c[i] = copyValue(item)
+		}
+		return c
+	}
+	return v
+}
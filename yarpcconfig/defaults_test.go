@@ -0,0 +1,150 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/x/introspection"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/transport/http"
+	"gopkg.in/yaml.v2"
+)
+
+func TestDefaults(t *testing.T) {
+	var data map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(whitespace.Expand(`
+		defaults:
+			inbounds:
+				http:
+					shutdownTimeout: 1s
+					grabHeaders: [x-foo, x-bar]
+			outbounds:
+				http:
+					url: http://127.0.0.1:8080/rpc
+					addHeaders: {X-Team: payments, X-Env: production}
+					round-robin:
+						peers: [127.0.0.1:8080]
+		inbounds:
+			http: {address: ":0", grabHeaders: [x-baz]}
+		outbounds:
+			keyvalue:
+				http:
+					addHeaders: {X-Env: staging}
+			other:
+				unary:
+					http:
+						url: http://127.0.0.1:8081/rpc
+	`)), &data))
+
+	cfg, err := newStrictConfigurator(t).LoadConfig("myservice", data)
+	require.NoError(t, err)
+
+	require.Len(t, cfg.Inbounds, 1)
+	inbound := cfg.Inbounds[0].(*http.Inbound).Introspect()
+	assert.Equal(t, "1s", inbound.Config["shutdownTimeout"], "expected the default")
+	assert.Equal(t, []string{"x-baz"}, inbound.Config["grabHeaders"], "expected lists to replace defaults")
+
+	keyvalue := cfg.Outbounds["keyvalue"].Unary.(introspection.IntrospectableOutbound).Introspect()
+	assert.Equal(t, "http://127.0.0.1:8080/rpc", keyvalue.Config["url"], "expected the default")
+	assert.Equal(t, map[string]interface{}{
+		"X-Team": "payments",
+		"X-Env":  "staging",
+	}, keyvalue.Config["addHeaders"], "expected maps to merge with defaults")
+	assert.Equal(t, "round-robin", keyvalue.Chooser.Name)
+
+	other := cfg.Outbounds["other"].Unary.(introspection.IntrospectableOutbound).Introspect()
+	assert.Equal(t, "http://127.0.0.1:8081/rpc", other.Config["url"], "expected the explicit value")
+}
+
+func TestDefaultsSpecDefaults(t *testing.T) {
+	var data map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(whitespace.Expand(`
+		defaults:
+			outbounds:
+				http:
+					url: http://127.0.0.1:8080/rpc
+		inbounds:
+			http: {address: ":0"}
+	`)), &data))
+
+	cfg, err := newStrictConfigurator(t).LoadConfig("myservice", data)
+	require.NoError(t, err)
+
+	require.Len(t, cfg.Inbounds, 1)
+	inbound := cfg.Inbounds[0].(*http.Inbound).Introspect()
+	assert.Equal(t, "6s", inbound.Config["shutdownTimeout"], "expected the default of the spec")
+}
+
+func TestDefaultsErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr string
+	}{
+		{
+			desc: "typo in outbound defaults",
+			give: `
+				defaults:
+					outbounds:
+						http: {urll: http://127.0.0.1:8080/rpc}
+			`,
+			wantErr: `unknown key "urll" at defaults.outbounds.http.urll: did you mean "url"?`,
+		},
+		{
+			desc: "typo in inbound defaults",
+			give: `
+				defaults:
+					inbounds:
+						http: {shutdownTimout: 1s}
+			`,
+			wantErr: `unknown key "shutdownTimout" at defaults.inbounds.http.shutdownTimout: did you mean "shutdownTimeout"?`,
+		},
+		{
+			desc: "unknown section",
+			give: `
+				defaults:
+					outbound:
+						http: {url: http://127.0.0.1:8080/rpc}
+			`,
+			wantErr: `unknown key "outbound" at defaults.outbound: did you mean "outbounds"?`,
+		},
+		{
+			desc: "unknown transport",
+			give: `
+				defaults:
+					outbounds:
+						htttp: {url: http://127.0.0.1:8080/rpc}
+			`,
+			wantErr: `failed to apply defaults: unknown transport "htttp"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := loadYAML(t, newStrictConfigurator(t), tt.give)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
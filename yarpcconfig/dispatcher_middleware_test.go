@@ -0,0 +1,163 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+// callLog records the middleware that requests and calls pass through.
+type callLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *callLog) add(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func (l *callLog) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := l.entries
+	l.entries = nil
+	return entries
+}
+
+// recordingMiddleware is unary inbound and outbound middleware that records
+// the requests and calls that pass through it.
+type recordingMiddleware struct {
+	name string
+	log  *callLog
+}
+
+func (m recordingMiddleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	m.log.add(m.name + ":in")
+	return h.Handle(ctx, req, resw)
+}
+
+func (m recordingMiddleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	m.log.add(m.name + ":out")
+	return out.Call(ctx, req)
+}
+
+func registerRecordingMiddleware(c *yarpcconfig.Configurator, log *callLog, names ...string) {
+	for _, name := range names {
+		name := name
+		c.MustRegisterMiddleware(yarpcconfig.MiddlewareSpec{
+			Name: name,
+			BuildMiddleware: func(struct{}, *yarpcconfig.Kit) (interface{}, error) {
+				return recordingMiddleware{name: name, log: log}, nil
+			},
+		})
+	}
+}
+
+func TestDispatcherMiddleware(t *testing.T) {
+	backend := newBackend(t, "backend", nil)
+
+	var log callLog
+	c := yarpcconfig.New()
+	c.MustRegisterTransport(http.TransportSpec())
+	registerRecordingMiddleware(c, &log, "both", "first", "second", "calls", "outer", "inner")
+	c.MustRegisterMiddleware(yarpcconfig.MiddlewareSpec{
+		Name: "last",
+		BuildMiddleware: func(struct{}, *yarpcconfig.Kit) (interface{}, error) {
+			return recordingMiddleware{name: "last", log: &log}, nil
+		},
+		Constraints: []middleware.Constraint{middleware.Last},
+	})
+
+	d, err := c.NewDispatcherFromYAML("proxy", strings.NewReader(fmt.Sprintf(`
+inbounds:
+  http:
+    address: 127.0.0.1:0
+outbounds:
+  backend:
+    middleware:
+      - outer
+      - last
+      - inner
+    http:
+      url: http://%v/
+middleware:
+  both: {}
+  inbound:
+    - second
+    - last
+    - first
+  outbound:
+    calls: {}
+`, backend)))
+	require.NoError(t, err)
+
+	backendClient := raw.New(d.ClientConfig("backend"))
+	d.Register(raw.Procedure("proxy", func(ctx context.Context, body []byte) ([]byte, error) {
+		return backendClient.Call(ctx, "name", body)
+	}))
+	require.NoError(t, d.Start())
+	defer func() { assert.NoError(t, d.Stop()) }()
+
+	// The middleware only handle unary calls.
+	for _, status := range d.Introspect().Outbounds {
+		switch status.RPCType {
+		case "unary":
+			assert.Equal(t, []string{"both", "calls", "outer", "inner", "last"}, status.Middleware,
+				"expected the outbound middleware of the dispatcher and the outbound")
+		default:
+			assert.Empty(t, status.Middleware, "unexpected middleware for %v calls", status.RPCType)
+		}
+	}
+
+	clientOutbound := http.NewTransport().NewSingleOutbound(fmt.Sprintf("http://%v/", d.Inbounds()[0].(*http.Inbound).Addr()))
+	client := yarpc.NewDispatcher(yarpc.Config{
+		Name:      "client",
+		Outbounds: yarpc.Outbounds{"proxy": {Unary: clientOutbound}},
+	})
+	require.NoError(t, client.Start())
+	defer func() { assert.NoError(t, client.Stop()) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	res, err := raw.New(client.ClientConfig("proxy")).Call(ctx, "proxy", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "backend", string(res))
+	assert.Equal(t, []string{
+		"both:in", "second:in", "first:in", "last:in",
+		"both:out", "calls:out", "outer:out", "inner:out", "last:out",
+	}, log.take(), "expected requests and calls to pass through the middleware in order")
+}
@@ -72,11 +72,16 @@
 //
 // Configuration
 //
-// The configuration may be specified in YAML or as any Go-level
+// The configuration may be specified in YAML, JSON, TOML or as any Go-level
 // map[string]interface{}. The examples below use YAML for illustration
 // purposes but other markup formats may be parsed into map[string]interface{}
 // as long as the information provided is the same.
 //
+// Use LoadConfigFromJSON and LoadConfigFromTOML to load JSON and TOML
+// configuration. Integral numbers load as integers in all formats, and values
+// are interpolated and validated alike, so the same configuration behaves the
+// same in every format.
+//
 // The configuration accepts the following top-level attributes: transports,
 // inbounds, and outbounds.
 //
@@ -92,6 +97,19 @@
 // See the following sections for details on the logging, transports,
 // inbounds, and outbounds keys in the configuration.
 //
+// Configuration loaded from YAML may be split across files. A value tagged
+// !include is replaced with the contents of the YAML file it names, and an
+// included mapping may be merged with local keys.
+//
+// 	outbounds: !include outbounds.yaml
+// 	timeouts:
+// 	  <<: !include defaults/timeouts.yaml
+// 	  default: 2s
+//
+// Relative paths are resolved against the directory of the including file,
+// so load the top-level file with LoadConfigFromYAMLFile. Circular includes
+// are an error.
+//
 // Inbound Configuration
 //
 // The 'inbounds' attribute configures the different ways in which the service
@@ -243,6 +261,31 @@
 // (For details on the configuration parameters of individual transport types,
 // check the documentation for the corresponding transport package.)
 //
+// Defaults Configuration
+//
+// The 'defaults' attribute declares attributes shared by the inbounds and
+// outbounds of a transport once, keyed by the transport name, instead of
+// repeating them in each of them.
+//
+// 	defaults:
+// 	  outbounds:
+// 	    http:
+// 	      addHeaders: {X-Team: payments}
+// 	      round-robin:
+// 	        peers: [127.0.0.1:8080]
+// 	outbounds:
+// 	  keyvalue:
+// 	    http:
+// 	      url: https://keyvalue/rpc
+// 	      addHeaders: {X-Env: staging}
+//
+// Defaults are merged into each inbound or outbound of the transport, and the
+// attributes of the inbound or outbound take precedence over them. Maps are
+// merged key by key, so the keyvalue outbound above adds both X-Team and
+// X-Env headers, but lists replace the default list altogether. Since peer
+// choosers are keyed by name, outbounds cannot replace a default peer
+// chooser with another one; leave it out of the defaults instead.
+//
 // Logging Configuration
 //
 // The 'logging' attribute configures how YARPC's observability middleware
@@ -303,11 +346,222 @@
 //  panic
 //  fatal
 //
+// The 'redaction' key under 'logging' scrubs request headers and error
+// messages before they are logged. Headers are only logged if 'logHeaders' is
+// set, limited to 'allowedHeaders' if any are listed, and never including
+// 'deniedHeaders'. Matches of the regular expressions in 'errorPatterns' are
+// replaced with "[REDACTED]" in error messages and in the names and details
+// of application errors.
+//
+// 	logging:
+// 	  redaction:
+// 	    logHeaders: true
+// 	    deniedHeaders: [authorization, x-auth-token]
+// 	    errorPatterns:
+// 	      - 'token=\S+'
+//
+// Header transformers and custom error scrubbers may be set on the
+// yarpc.Config returned by LoadConfig.
+//
+// Metrics Configuration
+//
+// The 'metrics' attribute configures the metrics of YARPC's observability
+// middleware. 'latencyBuckets' sets the upper bounds of the buckets of the
+// latency histograms, either as a list or as an exponential series, and
+// 'latencyBucketOverrides' sets them for individual procedures.
+//
+// 	metrics:
+// 	  tagsBlocklist: [routing_delegate]
+// 	  latencyBuckets:
+// 	    buckets: [1ms, 5ms, 10ms, 50ms, 100ms, 500ms, 1s, 5s]
+// 	  latencyBucketOverrides:
+// 	    - procedure: Batch::*
+// 	      exponential:
+// 	        start: 100ms
+// 	        factor: 2
+// 	        count: 12
+//
+// Buckets must be ascending whole numbers of milliseconds, since the
+// histograms record milliseconds. A procedure ending in '*' matches all
+// procedures with the preceding prefix.
+//
+// The histograms of the sizes of request and response payloads, like
+// request_payload_size_bytes, may be turned off.
+//
+// 	metrics:
+// 	  disablePayloadSizeMetrics: true
+//
+// Rate Limit Configuration
+//
+// The 'rateLimits' attribute limits the rate of inbound requests with token
+// buckets, rejecting requests that find their bucket empty with a
+// ResourceExhausted error.
+//
+// 	rateLimits:
+// 	  default:
+// 	    rate: 100   # requests per second
+// 	    burst: 200  # defaults to the rate
+// 	  keyByCaller: true
+// 	  overrides:
+// 	    - procedure: Store::get
+// 	      rate: 1000
+// 	    - caller: batch-jobs
+// 	      rate: 10
+//
+// Requests share the bucket of their limit unless 'keyByProcedure' or
+// 'keyByCaller' give each procedure or caller its own. An override for both a
+// procedure and a caller takes precedence over an override for the
+// procedure, which takes precedence over an override for the caller.
+// Use the RateLimiter of the Dispatcher to change the limits at runtime.
+//
+// Deadline Configuration
+//
+// The 'deadlines' attribute sets the minimum remaining TTLs of inbound
+// requests and outbound calls, so that no work starts on requests that
+// cannot finish in time.
+//
+// 	deadlines:
+// 	  inbound:
+// 	    minTTL: 10ms
+// 	    overrides:
+// 	      - procedure: Store::scan
+// 	        minTTL: 500ms
+// 	  outbound:
+// 	    minTTL: 5ms
+// 	    defaultTTL: 1s
+//
+// Inbound requests with less time left than the minimum of their procedure
+// fail with a DeadlineExceeded error before reaching their handler. Outbound
+// calls with less time left than the minimum fail the same way without being
+// sent, and calls without a deadline fail unless 'defaultTTL' gives them one.
+//
+// Timeout Configuration
+//
+// The 'timeouts' attribute sets the maximum time handlers spend on inbound
+// unary and oneway requests, regardless of the TTLs that callers set.
+//
+// 	timeouts:
+// 	  default: 2s
+// 	  overrides:
+// 	    - procedure: Store::scan
+// 	      timeout: 30s
+// 	    - procedure: Admin::*
+// 	      timeout: 5m
+//
+// Handlers receive a context whose deadline is the earlier of the deadline of
+// the request and the timeout of its procedure. A procedure ending in '*'
+// matches all procedures with the preceding prefix. Requests that fail after
+// their timeout elapsed fail with a DeadlineExceeded error whose application
+// error name, "server-timeout", tells them apart in metrics from requests
+// that ran out of TTL.
+//
+// Header Propagation Configuration
+//
+// The 'headerPropagation' attribute lists application headers of inbound
+// requests, like request IDs, that are propagated to the unary, oneway, and
+// streaming calls made with the context of the requests.
+//
+// 	headerPropagation:
+// 	  headers:
+// 	    - x-request-id
+// 	    - x-tenant
+// 	  maxValueSize: 256
+// 	  rename:
+// 	    x-tenant: x-upstream-tenant
+//
+// Calls that set a propagated header explicitly keep their value. Values
+// longer than 'maxValueSize' bytes are truncated, and 'rename' propagates
+// headers under other names.
+//
+// Panic Recovery Configuration
+//
+// Dispatchers built from configuration recover panics in handlers with the
+// yarpcrecovery middleware, failing their requests with an Internal error
+// that does not reveal the panic value. The 'panicRecovery' attribute opts
+// out of it, leaving panics to the transports.
+//
+// 	panicRecovery:
+// 	  disabled: true
+//
+// To report recovered panics, set the OnPanic field of the PanicRecovery
+// configuration of the loaded yarpc.Config before building the dispatcher.
+//
+// Middleware Configuration
+//
+// The 'middleware' attribute builds named middleware from the MiddlewareSpecs
+// registered with the Configurator, keyed by their names. The middleware are
+// placed in the MiddlewareChain of the dispatcher by the constraints of their
+// specs, and by name otherwise.
+//
+// 	middleware:
+// 	  audit:
+// 	    path: /var/log/audit.log
+// 	  auth: {}
+//
+// Middleware listed under the 'inbound' and 'outbound' attributes apply to
+// inbound requests or outbound calls only, even if they implement both
+// inbound and outbound middleware interfaces. Listed middleware are placed in
+// the order they are listed, after those keyed by name, except as their
+// constraints require. Items of the lists are either the names of middleware
+// without attributes, or maps from the name of a middleware to its
+// attributes. The same middleware may not be configured more than once.
+//
+// 	middleware:
+// 	  inbound:
+// 	    - auth
+// 	    - ratelimit:
+// 	        rps: 100
+// 	  outbound:
+// 	    - retry:
+// 	        maxAttempts: 3
+//
+// Outbounds accept a 'middleware' attribute of their own, which builds named
+// middleware the same way for the calls of that outbound only, either keyed
+// by name or as a list. These are placed in the PerOutboundMiddleware of the
+// dispatcher, inside the middleware of every outbound.
+//
+// 	outbounds:
+// 	  payments:
+// 	    middleware:
+// 	      circuitbreaker:
+// 	        failureThreshold: 5
+// 	    http:
+// 	      url: http://localhost:8080/yarpc
+//
+// Configurations that name middleware unknown to the Configurator fail to
+// load.
+//
+// Procedure Options
+//
+// Outbounds accept a 'procedures' attribute with options for the calls to
+// their procedures, keyed by procedure name, or by a prefix of procedure
+// names followed by "*". Procedure names take precedence over prefixes, and
+// longer prefixes over shorter ones.
+//
+// 	outbounds:
+// 	  keyvalue:
+// 	    http:
+// 	      url: http://localhost:8080/yarpc
+// 	    procedures:
+// 	      KeyValue::GetValue:
+// 	        timeout: 200ms
+// 	      KeyValue::*:
+// 	        timeout: 2s
+// 	        retries:
+// 	          maxAttempts: 3
+//
+// The timeout bounds each attempt of a call, in addition to its deadline.
+// The retry policy takes precedence over the retry policies of the 'retries'
+// attribute for the service of the outbound. The options of the procedure of
+// a call are available to the middleware of its outbound and to the outbound
+// itself with ProcedureOptionsFromContext.
+//
 // Customizing Configuration
 //
-// When building your own TransportSpec, PeerListSpec, or PeerListUpdaterSpec,
-// you will define functions accepting structs or pointers to structs which
-// define the different configuration parameters needed to build that entity.
+// When building your own TransportSpec, PeerListSpec, PeerListUpdaterSpec, or
+// MiddlewareSpec, you will define functions accepting structs or pointers to
+// structs which define the different configuration parameters needed to
+// build that entity.
 // These configuration parameters will be decoded from the user-specified
 // configuration using a case-insensitive match on the field names.
 //
@@ -350,11 +604,27 @@
 //
 // This struct will accept the `addr` key, not `address`.
 //
+// Fields of type time.Duration accept Go duration strings like "1m30s", and
+// numbers with a unit like "30 s". Plain numbers other than 0 are rejected,
+// since their unit would be ambiguous. Fields of type ByteSize accept sizes
+// in bytes, either plain numbers or numbers with a unit like "4MiB" or
+// "512kb". Invalid durations and sizes fail to load with the path of the
+// attribute.
+//
+// 	type MyInboundConfig struct {
+// 		Timeout     time.Duration        `config:"timeout"`
+// 		MaxBodySize yarpcconfig.ByteSize `config:"maxBodySize"`
+// 	}
+//
+// 	invalid size at inbounds.myinbound.maxBodySize: size "4MB/s" has an unknown unit "MB/s"
+//
 // In addition to specifying the field name, the `config` tag may also include
 // an `interpolate` option to request interpolation of variables in the form
 // ${NAME} or ${NAME:default} at the time the value is decoded. By default,
 // environment variables are used to fill these variables; this may be changed
-// with the InterpolationResolver option.
+// with the InterpolationResolver option. Fields are only interpolated when
+// the expansion of configurations is disabled, because expanded
+// configurations have no variables left (see Expansion below).
 //
 // Interpolation may be requested only for primitive fields and time.Duration.
 //
@@ -373,4 +643,93 @@
 //
 // 	addr: localhost:${PORT}
 // 	timeout: ${TIMEOUT_SECONDS:5}s
+//
+// Expansion
+//
+// Before configurations are decoded, the references in all of their string
+// values are expanded, whatever the fields they are decoded into:
+//
+// 	${NAME}          the value of the variable NAME
+// 	${NAME:-DEFAULT} the value of NAME, or DEFAULT if it is unset
+// 	${NAME:DEFAULT}  the same, for compatibility with interpolated fields
+// 	${file:PATH}     the contents of the file at PATH, without a trailing newline
+// 	$$               a single dollar sign
+//
+// Variables are resolved with the InterpolationResolver, which defaults to
+// environment variables. Configurations fail to load if a variable has no
+// value or default, or a file cannot be read, with an error naming the path
+// of the value in the configuration.
+//
+// 	outbounds:
+// 	  payments:
+// 	    http:
+// 	      url: http://${PAYMENTS_HOST:-localhost}:8080/yarpc
+// 	      headers:
+// 	        Authorization: Bearer ${file:/etc/secrets/payments-token}
+//
+// Expansion may be disabled altogether with the DisableExpansion option.
+//
+// Unknown Keys
+//
+// With the StrictKeys option, configurations fail to load if they use an
+// attribute unknown to the section it appears in, with an error naming the
+// path of the attribute, suggesting the closest known attribute, if any, and
+// listing the known attributes.
+//
+// 	unknown key "adress" at inbounds.http.adress: did you mean "address"?; need one of address, ...
+//
+// TransportSpecs, PeerChooserSpecs, and PeerListSpecs may declare
+// DeprecatedAliases for attributes that were renamed. These are decoded as
+// the attributes they were renamed to, logging a warning with the Logger of
+// the Configurator.
+//
+// Secrets
+//
+// Attributes of type Secret, like the TLS keys of inbounds and the OAuth2
+// client secrets of HTTP outbounds, hold sensitive values. They reference a
+// file or an environment variable, or hold the value inline.
+//
+// 	inbounds:
+// 	  grpc:
+// 	    address: :8080
+// 	    tls:
+// 	      enabled: true
+// 	      cert: file:///etc/tls/cert.pem
+// 	      key: env://TLS_KEY
+//
+// Secrets are resolved when the configuration is loaded or reloaded, so that
+// a reload picks up the latest contents of their files. A configuration
+// fails to load if one of its secrets cannot be resolved, with an error
+// naming the path of the attribute. Secrets are redacted wherever they are
+// printed, inline values replaced and references shown as-is, including in
+// the effective configuration.
+//
+// Effective Configuration
+//
+// NewEffectiveConfig reports the configuration that a Dispatcher actually
+// built, as its transports, inbounds, outbounds, and peer choosers report it,
+// with their defaults applied and secrets redacted. It renders as a YAML or
+// JSON document, which the debug pages of go.uber.org/yarpc/x/debug also
+// serve with their EffectiveConfig option.
+//
+// 	out, err := yarpcconfig.NewEffectiveConfig(dispatcher).YAML()
+//
+// Reloading
+//
+// A ReloadableDispatcher is built from configuration like any other
+// Dispatcher, but may later be reloaded with an updated configuration
+// without a restart.
+//
+// 	rd, err := cfg.NewReloadableDispatcherFromYAML("myservice", configFile)
+// 	...
+// 	dispatcher := rd.Dispatcher()
+// 	...
+// 	err = rd.ReloadFromYAML(updatedConfigFile)
+//
+// Reloading replaces the outbounds whose configuration changed, such as
+// their peers, once the replacements have started. Calls in flight on a
+// replaced outbound complete before it stops. Reloading also applies updated
+// retry policies and rate limits. Any other change, like adding an outbound
+// or changing an inbound, requires a restart and fails to reload with an
+// error naming the sections that changed, leaving the Dispatcher as it was.
 package yarpcconfig
@@ -0,0 +1,160 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"encoding/json"
+	"sort"
+
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/x/introspection"
+	"gopkg.in/yaml.v2"
+)
+
+// EffectiveConfig is the configuration that a Dispatcher actually built, as
+// reported by its transports, inbounds, outbounds, and peer choosers, rather
+// than the configuration it was loaded from. The values of attributes that
+// hold secrets are redacted.
+//
+// Components report their resolved attributes, with defaults applied, by
+// setting the Config of their introspection status. Components that do not
+// report them appear with their endpoints and peers only.
+type EffectiveConfig struct {
+	Name       string                            `json:"name" yaml:"name"`
+	Transports map[string]map[string]interface{} `json:"transports,omitempty" yaml:"transports,omitempty"`
+	Inbounds   []EffectiveInbound                `json:"inbounds,omitempty" yaml:"inbounds,omitempty"`
+	Outbounds  map[string]EffectiveOutbounds     `json:"outbounds,omitempty" yaml:"outbounds,omitempty"`
+}
+
+// EffectiveInbound is the effective configuration of an inbound.
+type EffectiveInbound struct {
+	Transport string                 `json:"transport" yaml:"transport"`
+	Endpoint  string                 `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	Config    map[string]interface{} `json:"config,omitempty" yaml:"config,omitempty"`
+}
+
+// EffectiveOutbounds is the effective configuration of the outbounds of an
+// outbound key.
+type EffectiveOutbounds struct {
+	Service string             `json:"service" yaml:"service"`
+	Unary   *EffectiveOutbound `json:"unary,omitempty" yaml:"unary,omitempty"`
+	Oneway  *EffectiveOutbound `json:"oneway,omitempty" yaml:"oneway,omitempty"`
+	Stream  *EffectiveOutbound `json:"stream,omitempty" yaml:"stream,omitempty"`
+}
+
+// EffectiveOutbound is the effective configuration of an outbound of an RPC
+// type, with the names of its middleware, outermost first.
+type EffectiveOutbound struct {
+	Transport  string                 `json:"transport" yaml:"transport"`
+	Endpoint   string                 `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	Middleware []string               `json:"middleware,omitempty" yaml:"middleware,omitempty"`
+	Config     map[string]interface{} `json:"config,omitempty" yaml:"config,omitempty"`
+	Chooser    *EffectiveChooser      `json:"chooser,omitempty" yaml:"chooser,omitempty"`
+}
+
+// EffectiveChooser is the effective configuration of a peer chooser, with
+// the identifiers of its peers and the choosers that it is composed of.
+type EffectiveChooser struct {
+	Name     string                 `json:"name" yaml:"name"`
+	Peers    []string               `json:"peers,omitempty" yaml:"peers,omitempty"`
+	Config   map[string]interface{} `json:"config,omitempty" yaml:"config,omitempty"`
+	Choosers []EffectiveChooser     `json:"choosers,omitempty" yaml:"choosers,omitempty"`
+}
+
+// NewEffectiveConfig reports the effective configuration of the Dispatcher,
+// whether or not it was built from configuration.
+//
+//	d, err := cfg.NewDispatcherFromYAML("myservice", configFile)
+//	...
+//	out, err := yarpcconfig.NewEffectiveConfig(d).YAML()
+func NewEffectiveConfig(d *yarpc.Dispatcher) EffectiveConfig {
+	status := d.Introspect()
+	ec := EffectiveConfig{Name: status.Name}
+
+	for _, t := range status.Transports {
+		if ec.Transports == nil {
+			ec.Transports = make(map[string]map[string]interface{})
+		}
+		ec.Transports[t.Name] = t.Config
+	}
+
+	for _, i := range status.Inbounds {
+		ec.Inbounds = append(ec.Inbounds, EffectiveInbound{
+			Transport: i.Transport,
+			Endpoint:  i.Endpoint,
+			Config:    i.Config,
+		})
+	}
+
+	for _, o := range status.Outbounds {
+		if ec.Outbounds == nil {
+			ec.Outbounds = make(map[string]EffectiveOutbounds)
+		}
+		outs := ec.Outbounds[o.OutboundKey]
+		outs.Service = o.Service
+		out := &EffectiveOutbound{
+			Transport:  o.Transport,
+			Endpoint:   o.Endpoint,
+			Middleware: o.Middleware,
+			Config:     o.Config,
+			Chooser:    effectiveChooser(o.Chooser),
+		}
+		switch o.RPCType {
+		case "unary":
+			outs.Unary = out
+		case "oneway":
+			outs.Oneway = out
+		case "stream":
+			outs.Stream = out
+		}
+		ec.Outbounds[o.OutboundKey] = outs
+	}
+	return ec
+}
+
+// effectiveChooser returns the effective configuration of the chooser, or
+// nil if the outbound reported no chooser.
+func effectiveChooser(status introspection.ChooserStatus) *EffectiveChooser {
+	if status.Name == "" && len(status.Peers) == 0 && len(status.Choosers) == 0 {
+		return nil
+	}
+	c := &EffectiveChooser{Name: status.Name, Config: status.Config}
+	for _, p := range status.Peers {
+		c.Peers = append(c.Peers, p.Identifier)
+	}
+	sort.Strings(c.Peers)
+	for _, child := range status.Choosers {
+		if cc := effectiveChooser(child); cc != nil {
+			c.Choosers = append(c.Choosers, *cc)
+		}
+	}
+	return c
+}
+
+// JSON renders the effective configuration as a JSON document.
+func (c EffectiveConfig) JSON() ([]byte, error) {
+	return json.MarshalIndent(c, "", "  ")
+}
+
+// YAML renders the effective configuration as a YAML document.
+func (c EffectiveConfig) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
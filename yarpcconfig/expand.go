@@ -0,0 +1,215 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.uber.org/yarpc/internal/interpolate"
+)
+
+const _filePrefix = "file:"
+
+// expand returns a copy of the configuration data with the references in
+// its string values expanded, without modifying the data.
+func expand(data interface{}, resolve interpolate.VariableResolver) (interface{}, error) {
+	return expandValue(data, "", resolve)
+}
+
+func expandValue(data interface{}, path string, resolve interpolate.VariableResolver) (interface{}, error) {
+	switch v := data.(type) {
+	case string:
+		s, err := expandString(v, resolve)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand configuration at %q: %v", path, err)
+		}
+		return s, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		out := make(map[string]interface{}, len(v))
+		for _, k := range keys {
+			item, err := expandValue(v[k], joinPath(path, k), resolve)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = item
+		}
+		return out, nil
+	case map[interface{}]interface{}:
+		keys := make([]interface{}, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+
+		out := make(map[interface{}]interface{}, len(v))
+		for _, k := range keys {
+			item, err := expandValue(v[k], joinPath(path, fmt.Sprint(k)), resolve)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = item
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			item, err := expandValue(item, fmt.Sprintf("%s[%d]", path, i), resolve)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = item
+		}
+		return out, nil
+	default:
+		return expandReflect(data, path, resolve)
+	}
+}
+
+// expandReflect expands maps and slices of types other than the ones that
+// decoding YAML produces, like named map types.
+func expandReflect(data interface{}, path string, resolve interpolate.VariableResolver) (interface{}, error) {
+	v := reflect.ValueOf(data)
+	switch v.Kind() {
+	case reflect.Map:
+		m := make(map[interface{}]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().Interface()] = iter.Value().Interface()
+		}
+		return expandValue(m, path, resolve)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return data, nil
+		}
+		l := make([]interface{}, v.Len())
+		for i := range l {
+			l[i] = v.Index(i).Interface()
+		}
+		return expandValue(l, path, resolve)
+	default:
+		return data, nil
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// expandString expands the references of a string: ${NAME}, ${NAME:-DEFAULT}
+// or ${NAME:DEFAULT} with variables of the resolver, ${file:PATH} with the
+// contents of files, and $$ with a single dollar sign.
+func expandString(s string, resolve interpolate.VariableResolver) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated reference in %q", s)
+			}
+			value, err := resolveReference(s[i+2:i+2+end], resolve)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += end + 2
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+func resolveReference(ref string, resolve interpolate.VariableResolver) (string, error) {
+	if strings.HasPrefix(ref, _filePrefix) {
+		path := ref[len(_filePrefix):]
+		if path == "" {
+			return "", fmt.Errorf("file reference %q has no path", "${"+ref+"}")
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %q: %v", path, err)
+		}
+		// Files usually end with a newline that is not part of their value,
+		// like the newline after a token.
+		value := strings.TrimSuffix(string(contents), "\n")
+		return strings.TrimSuffix(value, "\r"), nil
+	}
+
+	name, def, hasDefault := ref, "", false
+	if i := strings.IndexByte(ref, ':'); i >= 0 {
+		name, def, hasDefault = ref[:i], strings.TrimPrefix(ref[i+1:], "-"), true
+	}
+	if !isVariableName(name) {
+		return "", fmt.Errorf("invalid variable name %q", name)
+	}
+	if value, ok := resolve(name); ok {
+		return value, nil
+	}
+	if hasDefault {
+		return def, nil
+	}
+	return "", fmt.Errorf("variable %q does not have a value or a default", name)
+}
+
+// isVariableName reports whether a name starts with a letter or an
+// underscore and contains only letters, digits, underscores, dots and
+// dashes.
+func isVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && ('0' <= r && r <= '9' || r == '.' || r == '-'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/internal/whitespace"
+	"gopkg.in/yaml.v2"
+)
+
+func TestExpand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yarpcconfig-expand")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	token := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(token, []byte("s3cr3t\n"), 0600))
+
+	resolve := mapVariableResolver(map[string]string{
+		"HOST":     "example.com",
+		"PORT":     "8080",
+		"EMPTY":    "",
+		"with.dot": "dotted",
+	})
+
+	tests := []struct {
+		desc    string
+		give    string
+		want    string
+		wantErr string
+	}{
+		{
+			desc: "nested maps and lists",
+			give: `
+				outbounds:
+					foo:
+						http:
+							url: http://${HOST}:${PORT}/rpc
+							peers:
+								- ${HOST}:1
+								- ${HOST}:2
+				count: 42
+			`,
+			want: `
+				outbounds:
+					foo:
+						http:
+							url: http://example.com:8080/rpc
+							peers:
+								- example.com:1
+								- example.com:2
+				count: 42
+			`,
+		},
+		{
+			desc: "defaults",
+			give: `
+				a: ${MISSING:-fallback}
+				b: ${MISSING:legacy}
+				c: ${PORT:-9090}
+				d: ${EMPTY:-unused}
+				e: ${MISSING:-}
+				f: ${with.dot}
+			`,
+			want: `
+				a: fallback
+				b: legacy
+				c: "8080"
+				d: ""
+				e: ""
+				f: dotted
+			`,
+		},
+		{
+			desc: "escapes",
+			give: `
+				a: $${HOST}
+				b: cost $5 or $$5
+				c: trailing $
+			`,
+			want: `
+				a: ${HOST}
+				b: cost $5 or $5
+				c: trailing $
+			`,
+		},
+		{
+			desc: "file",
+			give: `
+				auth:
+					token: ${file:` + token + `}
+			`,
+			want: `
+				auth:
+					token: s3cr3t
+			`,
+		},
+		{
+			desc: "missing variable",
+			give: `
+				outbounds:
+					foo:
+						http:
+							peers:
+								- ${HOST}:1
+								- ${MISSING}:2
+			`,
+			wantErr: `failed to expand configuration at "outbounds.foo.http.peers[1]": ` +
+				`variable "MISSING" does not have a value or a default`,
+		},
+		{
+			desc: "missing file",
+			give: `
+				token: ${file:` + filepath.Join(dir, "missing") + `}
+			`,
+			wantErr: `failed to expand configuration at "token": failed to read file`,
+		},
+		{
+			desc: "file without path",
+			give: `
+				token: ${file:}
+			`,
+			wantErr: `file reference "${file:}" has no path`,
+		},
+		{
+			desc: "unterminated",
+			give: `
+				address: :${PORT
+			`,
+			wantErr: `failed to expand configuration at "address": unterminated reference in ":${PORT"`,
+		},
+		{
+			desc: "invalid name",
+			give: `
+				address: ${1PORT}
+			`,
+			wantErr: `invalid variable name "1PORT"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var give interface{}
+			require.NoError(t, yaml.Unmarshal([]byte(whitespace.Expand(tt.give)), &give))
+			got, err := expand(give, resolve)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var want interface{}
+			require.NoError(t, yaml.Unmarshal([]byte(whitespace.Expand(tt.want)), &want))
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestExpandDoesNotModifyData(t *testing.T) {
+	data := map[string]interface{}{
+		"a": []interface{}{"${HOST}"},
+		"b": map[interface{}]interface{}{"c": "${HOST}"},
+	}
+	got, err := expand(data, mapVariableResolver(map[string]string{"HOST": "example.com"}))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a": []interface{}{"example.com"},
+		"b": map[interface{}]interface{}{"c": "example.com"},
+	}, got)
+	assert.Equal(t, map[string]interface{}{
+		"a": []interface{}{"${HOST}"},
+		"b": map[interface{}]interface{}{"c": "${HOST}"},
+	}, data)
+}
+
+func TestExpandNamedTypes(t *testing.T) {
+	type attrs map[string]interface{}
+	type list []string
+
+	got, err := expand(attrs{
+		"url":   "http://${HOST}/rpc",
+		"peers": list{"${HOST}:1"},
+		"raw":   []byte("${HOST}"),
+	}, mapVariableResolver(map[string]string{"HOST": "example.com"}))
+	require.NoError(t, err)
+	assert.Equal(t, map[interface{}]interface{}{
+		"url":   "http://example.com/rpc",
+		"peers": []interface{}{"example.com:1"},
+		"raw":   []byte("${HOST}"),
+	}, got)
+}
+
+func TestConfiguratorExpansion(t *testing.T) {
+	type tagConfig struct {
+		Tag string `config:"tag"`
+	}
+	newConfigurator := func(opts ...Option) *Configurator {
+		c := New(append([]Option{
+			InterpolationResolver(mapVariableResolver(map[string]string{"TAG": "prod"})),
+		}, opts...)...)
+		c.MustRegisterMiddleware(MiddlewareSpec{
+			Name: "tag",
+			BuildMiddleware: func(cfg tagConfig, _ *Kit) (interface{}, error) {
+				return namedMiddleware{UnaryInbound: middleware.NopUnaryInbound, Name: cfg.Tag}, nil
+			},
+		})
+		return c
+	}
+	load := func(t *testing.T, c *Configurator, tag string) string {
+		cfg, err := c.LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+			middleware:
+				tag:
+					tag: `+tag+`
+		`)))
+		require.NoError(t, err)
+		ordered, err := cfg.MiddlewareChain.Ordered()
+		require.NoError(t, err)
+		return ordered[0].(namedMiddleware).Name
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		assert.Equal(t, "prod-1", load(t, newConfigurator(), "${TAG}-1"),
+			"fields without the interpolate option must be expanded")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Equal(t, "${TAG}-$$1", load(t, newConfigurator(DisableExpansion()), "${TAG}-$$1"))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := newConfigurator().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+			middleware:
+				tag:
+					tag: ${MISSING}
+		`)))
+		require.Error(t, err)
+		assert.Equal(t,
+			`failed to expand configuration at "middleware.tag.tag": variable "MISSING" does not have a value or a default`,
+			err.Error())
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+)
+
+// readJSON reads configuration data from a JSON object.
+func readJSON(r io.Reader) (map[string]interface{}, error) {
+	dec := json.NewDecoder(r)
+	// Numbers are decoded as json.Number so that integers are not rounded
+	// through float64.
+	dec.UseNumber()
+
+	var data map[string]interface{}
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON object at offset %d", dec.InputOffset())
+	}
+
+	v, err := normalizeValue(data)
+	if err != nil {
+		return nil, err
+	}
+	data, _ = v.(map[string]interface{})
+	return data, nil
+}
+
+// readTOML reads configuration data from a TOML document.
+func readTOML(r io.Reader) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	if _, err := toml.DecodeReader(r, &data); err != nil {
+		return nil, err
+	}
+
+	v, err := normalizeValue(data)
+	if err != nil {
+		return nil, err
+	}
+	data, _ = v.(map[string]interface{})
+	return data, nil
+}
+
+// normalizeValue converts the values decoded from JSON and TOML to the types
+// decoded from the equivalent YAML, so that configuration behaves the same
+// in all formats: integral numbers become int, other numbers float64, and
+// lists of tables []interface{}.
+func normalizeValue(data interface{}) (interface{}, error) {
+	switch v := data.(type) {
+	case json.Number:
+		return normalizeNumber(v)
+	case int64:
+		if int64(int(v)) != v {
+			// Like YAML, leave integers that overflow an int as they are.
+			return v, nil
+		}
+		return int(v), nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			item, err := normalizeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = item
+		}
+		return out, nil
+	case []map[string]interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			item, err := normalizeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = item
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			item, err := normalizeValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = item
+		}
+		return out, nil
+	default:
+		return data, nil
+	}
+}
+
+// normalizeNumber converts a JSON number to an int if it is an integer, as
+// YAML does, or to a float64 otherwise.
+func normalizeNumber(n json.Number) (interface{}, error) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return normalizeValue(i)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %v: %v", n, err)
+	}
+	return f, nil
+}
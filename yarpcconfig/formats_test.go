@@ -0,0 +1,267 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+type formatsInboundConfig struct {
+	Address  string               `config:"address"`
+	Capacity int                  `config:"capacity"`
+	Ratio    float64              `config:"ratio"`
+	Timeout  time.Duration        `config:"timeout"`
+	MaxSize  yarpcconfig.ByteSize `config:"maxSize"`
+	Tags     []string             `config:"tags"`
+	Tiers    []limitsConfig       `config:"tiers"`
+	Enabled  bool                 `config:"enabled"`
+}
+
+// formatsConfigurator returns a configurator with a transport whose inbounds
+// record their configuration, and the HTTP transport.
+func formatsConfigurator(got *formatsInboundConfig) *yarpcconfig.Configurator {
+	cfg := yarpcconfig.New(yarpcconfig.StrictKeys(), yarpcconfig.InterpolationResolver(func(k string) (string, bool) {
+		if k == "PORT" {
+			return "8080", true
+		}
+		return "", false
+	}))
+	cfg.MustRegisterTransport(http.TransportSpec())
+	cfg.MustRegisterTransport(yarpcconfig.TransportSpec{
+		Name: "formats",
+		BuildTransport: func(struct{}, *yarpcconfig.Kit) (transport.Transport, error) {
+			return http.NewTransport(), nil
+		},
+		BuildInbound: func(cfg *formatsInboundConfig, _ transport.Transport, _ *yarpcconfig.Kit) (transport.Inbound, error) {
+			*got = *cfg
+			return http.NewTransport().NewInbound(cfg.Address), nil
+		},
+	})
+	return cfg
+}
+
+var (
+	_formatsYAML = whitespace.Expand(`
+		inbounds:
+			formats:
+				address: ":${PORT}"
+				capacity: 10
+				ratio: 0.5
+				timeout: 1m30s
+				maxSize: 4MiB
+				tags: [a, b]
+				tiers:
+					- {timeout: 30s, maxSize: 512kb}
+					- {timeout: 0, maxSize: 8192}
+				enabled: true
+		outbounds:
+			other:
+				http:
+					url: http://127.0.0.1:${PORT}/rpc
+		transports:
+			http:
+				keepAlive: 30s
+				connBackoff:
+					exponential:
+						first: 10ms
+						max: 1s
+	`)
+
+	_formatsJSON = `{
+		"inbounds": {
+			"formats": {
+				"address": ":${PORT}",
+				"capacity": 10,
+				"ratio": 0.5,
+				"timeout": "1m30s",
+				"maxSize": "4MiB",
+				"tags": ["a", "b"],
+				"tiers": [
+					{"timeout": "30s", "maxSize": "512kb"},
+					{"timeout": 0, "maxSize": 8192}
+				],
+				"enabled": true
+			}
+		},
+		"outbounds": {
+			"other": {"http": {"url": "http://127.0.0.1:${PORT}/rpc"}}
+		},
+		"transports": {
+			"http": {
+				"keepAlive": "30s",
+				"connBackoff": {"exponential": {"first": "10ms", "max": "1s"}}
+			}
+		}
+	}`
+
+	_formatsTOML = `
+		[inbounds.formats]
+		address = ":${PORT}"
+		capacity = 10
+		ratio = 0.5
+		timeout = "1m30s"
+		maxSize = "4MiB"
+		tags = ["a", "b"]
+		enabled = true
+
+		[[inbounds.formats.tiers]]
+		timeout = "30s"
+		maxSize = "512kb"
+
+		[[inbounds.formats.tiers]]
+		timeout = 0
+		maxSize = 8192
+
+		[outbounds.other.http]
+		url = "http://127.0.0.1:${PORT}/rpc"
+
+		[transports.http]
+		keepAlive = "30s"
+
+		[transports.http.connBackoff.exponential]
+		first = "10ms"
+		max = "1s"
+	`
+)
+
+func loadFormat(t *testing.T, format string, got *formatsInboundConfig, data string) (yarpc.Config, error) {
+	cfg := formatsConfigurator(got)
+	switch format {
+	case "yaml":
+		return cfg.LoadConfigFromYAML("myservice", strings.NewReader(data))
+	case "json":
+		return cfg.LoadConfigFromJSON("myservice", strings.NewReader(data))
+	case "toml":
+		return cfg.LoadConfigFromTOML("myservice", strings.NewReader(data))
+	}
+	t.Fatalf("unknown format %q", format)
+	return yarpc.Config{}, nil
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	want := formatsInboundConfig{
+		Address:  ":8080",
+		Capacity: 10,
+		Ratio:    0.5,
+		Timeout:  90 * time.Second,
+		MaxSize:  4 << 20,
+		Tags:     []string{"a", "b"},
+		Tiers: []limitsConfig{
+			{Timeout: 30 * time.Second, MaxSize: 512000},
+			{Timeout: 0, MaxSize: 8192},
+		},
+		Enabled: true,
+	}
+
+	var introspections []interface{}
+	for _, tt := range []struct{ format, data string }{
+		{"yaml", _formatsYAML},
+		{"json", _formatsJSON},
+		{"toml", _formatsTOML},
+	} {
+		t.Run(tt.format, func(t *testing.T) {
+			var got formatsInboundConfig
+			cfg, err := loadFormat(t, tt.format, &got, tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+
+			require.Len(t, cfg.Inbounds, 1)
+			require.Contains(t, cfg.Outbounds, "other")
+			out, ok := cfg.Outbounds["other"].Unary.(*http.Outbound)
+			require.True(t, ok, "expected an HTTP outbound")
+			introspections = append(introspections, out.Introspect())
+		})
+	}
+	for _, got := range introspections[1:] {
+		assert.Equal(t, introspections[0], got, "dispatchers must be configured identically")
+	}
+}
+
+func TestLoadConfigFormatsErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		format  string
+		give    string
+		wantErr string
+	}{
+		{
+			desc:    "json unknown key",
+			format:  "json",
+			give:    `{"inbounds": {"formats": {"adress": ":0"}}}`,
+			wantErr: `unknown key "adress" at inbounds.formats`,
+		},
+		{
+			desc:    "toml unknown key",
+			format:  "toml",
+			give:    "[inbounds.formats]\nadress = \":0\"",
+			wantErr: `unknown key "adress" at inbounds.formats`,
+		},
+		{
+			desc:    "json duration without unit",
+			format:  "json",
+			give:    `{"inbounds": {"formats": {"timeout": 500}}}`,
+			wantErr: `invalid duration at inbounds.formats.timeout: duration 500 has no unit, like "500s"`,
+		},
+		{
+			desc:    "toml invalid size",
+			format:  "toml",
+			give:    "[inbounds.formats]\nmaxSize = \"4MB/s\"",
+			wantErr: `invalid size at inbounds.formats.maxSize: size "4MB/s" has an unknown unit "MB/s"`,
+		},
+		{
+			desc:    "json unset variable",
+			format:  "json",
+			give:    `{"inbounds": {"formats": {"address": ":${UNSET}"}}}`,
+			wantErr: `failed to expand configuration at "inbounds.formats.address"`,
+		},
+		{
+			desc:    "json trailing data",
+			format:  "json",
+			give:    `{"inbounds": {}} {}`,
+			wantErr: "unexpected data after the JSON object",
+		},
+		{
+			desc:    "invalid toml",
+			format:  "toml",
+			give:    "[inbounds",
+			wantErr: "line 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var got formatsInboundConfig
+			_, err := loadFormat(t, tt.format, &got, tt.give)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
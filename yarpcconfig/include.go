@@ -0,0 +1,121 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// _includeTag marks a YAML value that is replaced with the contents of the
+// file it names.
+const _includeTag = "!include"
+
+// expandIncludes replaces every value tagged !include in the given YAML
+// document, read from the given file, with the document in the file it
+// names. Relative paths are resolved against the directory of the file, or
+// the working directory if the file is empty.
+//
+// Documents without includes are returned as is.
+func expandIncludes(b []byte, file string) ([]byte, error) {
+	if !bytes.Contains(b, []byte(_includeTag)) {
+		return b, nil
+	}
+
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	r := includeResolver{including: make(map[string]struct{})}
+	dir := "."
+	if file != "" {
+		path, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		r.including[path] = struct{}{}
+		dir = filepath.Dir(path)
+	}
+	if err := r.expand(&doc, dir); err != nil {
+		return nil, err
+	}
+	return yamlv3.Marshal(&doc)
+}
+
+// includeResolver expands includes, tracking the files being included to
+// detect cycles.
+type includeResolver struct {
+	including map[string]struct{}
+}
+
+func (r *includeResolver) expand(node *yamlv3.Node, dir string) error {
+	if node.Kind == yamlv3.ScalarNode && node.Tag == _includeTag {
+		return r.include(node, dir)
+	}
+	for _, child := range node.Content {
+		if err := r.expand(child, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// include replaces the node with the document in the file it names.
+func (r *includeResolver) include(node *yamlv3.Node, dir string) error {
+	path := node.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to include %q: %v", node.Value, err)
+	}
+	if _, ok := r.including[path]; ok {
+		return fmt.Errorf("failed to include %q: circular include of %q", node.Value, path)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to include %q: %v", node.Value, err)
+	}
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(b, &doc); err != nil {
+		return fmt.Errorf("failed to include %q: %v", node.Value, err)
+	}
+
+	r.including[path] = struct{}{}
+	defer delete(r.including, path)
+	if err := r.expand(&doc, filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	if len(doc.Content) == 0 {
+		// The file is empty.
+		*node = yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!null", Value: "null"}
+		return nil
+	}
+	*node = *doc.Content[0]
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/internal/whitespace"
+)
+
+// writeFiles writes the given files, keyed by their path relative to a new
+// temporary directory, and returns the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "yarpcconfig-include")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(whitespace.Expand(contents)), 0644))
+	}
+	return dir
+}
+
+func TestLoadConfigFromYAMLFileIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"yarpc.yaml": `
+			timeouts: !include config/timeouts.yaml
+		`,
+		"config/timeouts.yaml": `
+			default: 2s
+			overrides: !include overrides/all.yaml
+		`,
+		"config/overrides/all.yaml": `
+			- procedure: Store::scan
+			  timeout: 30s
+			- !include ../admin.yaml
+		`,
+		"config/admin.yaml": `
+			procedure: Admin::*
+			timeout: 5m
+		`,
+	})
+
+	got, err := New().LoadConfigFromYAMLFile("foo", filepath.Join(dir, "yarpc.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, yarpc.TimeoutConfig{
+		Default: 2 * time.Second,
+		Overrides: []yarpc.TimeoutOverride{
+			{Procedure: "Store::scan", Timeout: 30 * time.Second},
+			{Procedure: "Admin::*", Timeout: 5 * time.Minute},
+		},
+	}, got.Timeout)
+}
+
+func TestLoadConfigFromYAMLIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"defaults.yaml": `
+			default: 2s
+		`,
+		"empty.yaml": ``,
+	})
+
+	// Included mappings may be merged with local values.
+	got, err := New().LoadConfigFromYAML("foo", strings.NewReader(whitespace.Expand(`
+		timeouts:
+			<<: !include `+filepath.Join(dir, "defaults.yaml")+`
+			overrides:
+				- procedure: Store::scan
+				  timeout: 30s
+		retries: !include `+filepath.Join(dir, "empty.yaml")+`
+	`)))
+	require.NoError(t, err)
+	assert.Equal(t, yarpc.TimeoutConfig{
+		Default: 2 * time.Second,
+		Overrides: []yarpc.TimeoutOverride{
+			{Procedure: "Store::scan", Timeout: 30 * time.Second},
+		},
+	}, got.Timeout)
+}
+
+func TestLoadConfigFromYAMLIncludeErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"self.yaml": `
+			timeouts: !include self.yaml
+		`,
+		"a.yaml": `
+			timeouts: !include nested/b.yaml
+		`,
+		"nested/b.yaml": `
+			default: !include ../a.yaml
+		`,
+		"missing.yaml": `
+			timeouts: !include nested/missing.yaml
+		`,
+		"malformed.yaml": `
+			timeouts: !include nested/malformed.yaml
+		`,
+		"nested/malformed.yaml": `
+			default: [
+		`,
+	})
+
+	tests := []struct {
+		desc    string
+		file    string
+		wantErr []string
+	}{
+		{
+			desc:    "self include",
+			file:    "self.yaml",
+			wantErr: []string{`circular include of "` + filepath.Join(dir, "self.yaml") + `"`},
+		},
+		{
+			desc:    "circular include",
+			file:    "a.yaml",
+			wantErr: []string{`failed to include "../a.yaml"`, `circular include of "` + filepath.Join(dir, "a.yaml") + `"`},
+		},
+		{
+			desc:    "missing file",
+			file:    "missing.yaml",
+			wantErr: []string{`failed to include "nested/missing.yaml"`, "no such file or directory"},
+		},
+		{
+			desc:    "malformed file",
+			file:    "malformed.yaml",
+			wantErr: []string{`failed to include "nested/malformed.yaml"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := New().LoadConfigFromYAMLFile("foo", filepath.Join(dir, tt.file))
+			require.Error(t, err)
+			for _, msg := range tt.wantErr {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFromYAMLFileMissing(t *testing.T) {
+	_, err := New().LoadConfigFromYAMLFile("foo", filepath.Join(writeFiles(t, nil), "missing.yaml"))
+	assert.True(t, os.IsNotExist(err), "expected a not exist error, got %v", err)
+}
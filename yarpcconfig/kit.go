@@ -27,7 +27,10 @@ import (
 	"sort"
 	"strings"
 
+	netmetrics "go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/config"
 	"go.uber.org/yarpc/internal/interpolate"
 )
 
@@ -47,6 +50,14 @@ type Kit struct {
 
 	// TransportSpec currently being used. This may or may not be set.
 	transportSpec *compiledTransportSpec
+
+	// meter is the metrics scope for the components being built, tagged
+	// with the outbound and RPC type being built, if any. This may be nil.
+	meter *netmetrics.Scope
+
+	// identify converts peer names to identifiers for the transport of the
+	// peer chooser being built. This may be nil.
+	identify func(string) peer.Identifier
 }
 
 // Returns a shallow copy of this Kit with spec set to the given value.
@@ -63,6 +74,21 @@ func (k *Kit) withOutboundName(name string) *Kit {
 	return &newK
 }
 
+// Returns a shallow copy of this Kit with the given tags added to its meter.
+func (k *Kit) withMeterTags(tags netmetrics.Tags) *Kit {
+	newK := *k
+	newK.meter = k.meter.Tagged(tags)
+	return &newK
+}
+
+// Returns a shallow copy of this Kit with the peer identify function set to
+// the given value.
+func (k *Kit) withIdentify(identify func(string) peer.Identifier) *Kit {
+	newK := *k
+	newK.identify = identify
+	return &newK
+}
+
 // ServiceName returns the name of the service for which components are being
 // built.
 func (k *Kit) ServiceName() string { return k.name }
@@ -90,6 +116,51 @@ func (k *Kit) peerListSpec(name string) (*compiledPeerListSpec, error) {
 	return nil, errors.New(msg)
 }
 
+// BuildPeerList builds a peer list using the registered PeerListSpec with the
+// given name, decoding its configuration from the given attributes.
+//
+// This allows peer lists to be composed, for example, by a peer list that
+// decorates another peer list configured alongside it. The attributes must
+// not contain a peer list updater; the caller is responsible for updating the
+// returned peer list.
+func (k *Kit) BuildPeerList(name string, attrs map[string]interface{}, t peer.Transport) (peer.ChooserList, error) {
+	spec, err := k.peerListSpec(name)
+	if err != nil {
+		return nil, err
+	}
+
+	listBuilder, err := spec.PeerList.Decode(config.AttributeMap(attrs), config.InterpolateWith(k.resolver))
+	if err != nil {
+		return nil, err
+	}
+	result, err := listBuilder.Build(t, k)
+	if err != nil {
+		return nil, err
+	}
+	return result.(peer.ChooserList), nil
+}
+
+// BuildPeerChooser builds a peer chooser from the given attributes, in the
+// same format as the peer chooser configuration of an outbound, including its
+// peer list updater.
+//
+// This allows peer choosers to be composed, for example, by a peer chooser
+// that chooses among groups of peers with their own updaters. It may only be
+// called with the Kit received by a PeerChooserSpec's BuildPeerChooser
+// function.
+func (k *Kit) BuildPeerChooser(attrs map[string]interface{}, t peer.Transport) (peer.Chooser, error) {
+	if k.identify == nil {
+		return nil, errors.New(
+			"invalid Kit: make sure you passed in the same Kit your Build function received")
+	}
+
+	var pc PeerChooser
+	if err := config.AttributeMap(attrs).Decode(&pc, config.InterpolateWith(k.resolver)); err != nil {
+		return nil, err
+	}
+	return pc.BuildPeerChooser(t, k.identify, k)
+}
+
 func (k *Kit) peerChooserPreset(name string) (*compiledPeerChooserPreset, error) {
 	if k.transportSpec == nil {
 		// Currently, transportspec is set only if we're inside build*Outbound.
@@ -137,6 +208,20 @@ func (k *Kit) peerListUpdaterSpecNames() (names []string) {
 	return
 }
 
+// Meter returns the metrics scope for the components being built, or nil if
+// the Configurator was not given a metrics scope.
+// Meter is safe to call on a nil Kit, returning nil.
+//
+// The scope is tagged with the service name, like the Dispatcher's own
+// metrics, and with the outbound and RPC type inside outbound builders, so
+// that the peer lists of each outbound report distinct metrics.
+func (k *Kit) Meter() *netmetrics.Scope {
+	if k == nil {
+		return nil
+	}
+	return k.meter
+}
+
 // Compressor returns the known compressor for the given name or nil if the
 // named compressor is not known.
 func (k *Kit) Compressor(name string) transport.Compressor {
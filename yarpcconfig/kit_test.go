@@ -24,6 +24,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	netmetrics "go.uber.org/net/metrics"
 )
 
 func TestKitWithTransportSpec(t *testing.T) {
@@ -45,3 +47,25 @@ func TestKitWithTransportSpec(t *testing.T) {
 	assert.Equal(t, "foo", childOutbound.ServiceName())
 	assert.Empty(t, root.outboundName, "outbound name must be empty")
 }
+
+func TestKitMeter(t *testing.T) {
+	var nilKit *Kit
+	assert.Nil(t, nilKit.Meter(), "nil Kit must have no meter")
+	assert.Nil(t, New().Kit("foo").Meter(), "Configurator without metrics must have no meter")
+
+	root := netmetrics.New()
+	kit := New(Metrics(root.Scope())).Kit("foo").withMeterTags(netmetrics.Tags{"outbound": "bar"})
+	_, err := kit.Meter().Gauge(netmetrics.Spec{Name: "test_gauge", Help: "Test gauge."})
+	assert.NoError(t, err)
+
+	gauges := root.Snapshot().Gauges
+	if assert.Len(t, gauges, 1) {
+		assert.Equal(t, netmetrics.Tags{"dispatcher": "foo", "outbound": "bar"}, gauges[0].Tags)
+	}
+}
+
+func TestKitBuildPeerChooserRequiresIdentify(t *testing.T) {
+	_, err := New().Kit("foo").BuildPeerChooser(map[string]interface{}{"peer": "127.0.0.1:8080"}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid Kit")
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/internal/inboundmiddleware"
+	"go.uber.org/yarpc/internal/outboundmiddleware"
+)
+
+// inboundOnly returns middleware restricted to inbound requests, for
+// middleware configured for inbounds only, and false if it implements no
+// inbound middleware interface. The restricted middleware implement the same
+// inbound middleware interfaces, so that the dispatcher applies them to the
+// same RPC types.
+func inboundOnly(mw interface{}) (interface{}, bool) {
+	if !isInboundMiddleware(mw) {
+		return nil, false
+	}
+	if !isOutboundMiddleware(mw) {
+		return mw, true
+	}
+
+	unary, _ := mw.(middleware.UnaryInbound)
+	oneway, _ := mw.(middleware.OnewayInbound)
+	stream, _ := mw.(middleware.StreamInbound)
+	if stream != nil {
+		// Chaining keeps the message hooks of stream middleware.
+		stream = inboundmiddleware.StreamChain(stream)
+	}
+
+	type (
+		u = middleware.UnaryInbound
+		o = middleware.OnewayInbound
+		s = middleware.StreamInbound
+	)
+	switch {
+	case unary != nil && oneway != nil && stream != nil:
+		return struct {
+			u
+			o
+			s
+		}{unary, oneway, stream}, true
+	case unary != nil && oneway != nil:
+		return struct {
+			u
+			o
+		}{unary, oneway}, true
+	case unary != nil && stream != nil:
+		return struct {
+			u
+			s
+		}{unary, stream}, true
+	case oneway != nil && stream != nil:
+		return struct {
+			o
+			s
+		}{oneway, stream}, true
+	case unary != nil:
+		return struct{ u }{unary}, true
+	case oneway != nil:
+		return struct{ o }{oneway}, true
+	default:
+		return struct{ s }{stream}, true
+	}
+}
+
+// outboundOnly returns middleware restricted to outbound calls, for
+// middleware configured for outbounds only, and false if it implements no
+// outbound middleware interface. The restricted middleware implement the same
+// outbound middleware interfaces, so that the dispatcher applies them to the
+// same RPC types.
+func outboundOnly(mw interface{}) (interface{}, bool) {
+	if !isOutboundMiddleware(mw) {
+		return nil, false
+	}
+	if !isInboundMiddleware(mw) {
+		return mw, true
+	}
+
+	unary, _ := mw.(middleware.UnaryOutbound)
+	oneway, _ := mw.(middleware.OnewayOutbound)
+	stream, _ := mw.(middleware.StreamOutbound)
+	if stream != nil {
+		// Chaining keeps the message hooks of stream middleware.
+		stream = outboundmiddleware.StreamChain(stream)
+	}
+
+	type (
+		u = middleware.UnaryOutbound
+		o = middleware.OnewayOutbound
+		s = middleware.StreamOutbound
+	)
+	switch {
+	case unary != nil && oneway != nil && stream != nil:
+		return struct {
+			u
+			o
+			s
+		}{unary, oneway, stream}, true
+	case unary != nil && oneway != nil:
+		return struct {
+			u
+			o
+		}{unary, oneway}, true
+	case unary != nil && stream != nil:
+		return struct {
+			u
+			s
+		}{unary, stream}, true
+	case oneway != nil && stream != nil:
+		return struct {
+			o
+			s
+		}{oneway, stream}, true
+	case unary != nil:
+		return struct{ u }{unary}, true
+	case oneway != nil:
+		return struct{ o }{oneway}, true
+	default:
+		return struct{ s }{stream}, true
+	}
+}
+
+func isInboundMiddleware(mw interface{}) bool {
+	switch mw.(type) {
+	case middleware.UnaryInbound, middleware.OnewayInbound, middleware.StreamInbound:
+		return true
+	default:
+		return false
+	}
+}
+
+func isOutboundMiddleware(mw interface{}) bool {
+	switch mw.(type) {
+	case middleware.UnaryOutbound, middleware.OnewayOutbound, middleware.StreamOutbound:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// rateLimitConfig is the configuration of the rate limit middleware.
+type rateLimitConfig struct {
+	RPS int `config:"rps"`
+}
+
+// rateLimiter is unary inbound middleware that rejects the requests in
+// excess of a number of requests per second.
+type rateLimiter struct {
+	rps int
+
+	mu     sync.Mutex
+	second time.Time
+	count  int
+}
+
+func (l *rateLimiter) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	if !l.allow(time.Now().Truncate(time.Second)) {
+		return yarpcerrors.ResourceExhaustedErrorf("rate limit of %d requests per second exceeded", l.rps)
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+func (l *rateLimiter) allow(second time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !second.Equal(l.second) {
+		l.second, l.count = second, 0
+	}
+	l.count++
+	return l.count <= l.rps
+}
+
+func ExampleConfigurator_RegisterMiddleware() {
+	cfg := yarpcconfig.New()
+	cfg.MustRegisterMiddleware(yarpcconfig.MiddlewareSpec{
+		Name: "ratelimit",
+		BuildMiddleware: func(c rateLimitConfig, _ *yarpcconfig.Kit) (*rateLimiter, error) {
+			if c.RPS <= 0 {
+				return nil, errors.New("rps must be greater than 0")
+			}
+			return &rateLimiter{rps: c.RPS}, nil
+		},
+	})
+
+	// Each environment may set a different limit in its configuration.
+	c, err := cfg.LoadConfigFromYAML("myservice", strings.NewReader(`
+middleware:
+  inbound:
+    - ratelimit:
+        rps: 100
+`))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	names, err := c.MiddlewareChain.Names()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(names)
+
+	// Output: [ratelimit]
+}
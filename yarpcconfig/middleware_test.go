@@ -0,0 +1,102 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/yarpc/api/middleware"
+)
+
+func TestRestrictMiddleware(t *testing.T) {
+	type unaryBidi struct {
+		middleware.UnaryInbound
+		middleware.UnaryOutbound
+	}
+	type streamAndOnewayBidi struct {
+		middleware.OnewayInbound
+		middleware.StreamInbound
+		middleware.UnaryOutbound
+		middleware.OnewayOutbound
+	}
+
+	type implements struct {
+		unaryInbound, onewayInbound, streamInbound    bool
+		unaryOutbound, onewayOutbound, streamOutbound bool
+	}
+	implemented := func(mw interface{}) implements {
+		var i implements
+		_, i.unaryInbound = mw.(middleware.UnaryInbound)
+		_, i.onewayInbound = mw.(middleware.OnewayInbound)
+		_, i.streamInbound = mw.(middleware.StreamInbound)
+		_, i.unaryOutbound = mw.(middleware.UnaryOutbound)
+		_, i.onewayOutbound = mw.(middleware.OnewayOutbound)
+		_, i.streamOutbound = mw.(middleware.StreamOutbound)
+		return i
+	}
+
+	tests := []struct {
+		desc         string
+		give         interface{}
+		wantInbound  *implements
+		wantOutbound *implements
+	}{
+		{
+			desc:        "inbound",
+			give:        middleware.NopUnaryInbound,
+			wantInbound: &implements{unaryInbound: true},
+		},
+		{
+			desc:         "outbound",
+			give:         middleware.NopStreamOutbound,
+			wantOutbound: &implements{streamOutbound: true},
+		},
+		{
+			desc:         "unary",
+			give:         unaryBidi{middleware.NopUnaryInbound, middleware.NopUnaryOutbound},
+			wantInbound:  &implements{unaryInbound: true},
+			wantOutbound: &implements{unaryOutbound: true},
+		},
+		{
+			desc: "several RPC types",
+			give: streamAndOnewayBidi{
+				middleware.NopOnewayInbound, middleware.NopStreamInbound,
+				middleware.NopUnaryOutbound, middleware.NopOnewayOutbound,
+			},
+			wantInbound:  &implements{onewayInbound: true, streamInbound: true},
+			wantOutbound: &implements{unaryOutbound: true, onewayOutbound: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			mw, ok := inboundOnly(tt.give)
+			if assert.Equal(t, tt.wantInbound != nil, ok, "inbound") && ok {
+				assert.Equal(t, *tt.wantInbound, implemented(mw), "inbound")
+			}
+			mw, ok = outboundOnly(tt.give)
+			if assert.Equal(t, tt.wantOutbound != nil, ok, "outbound") && ok {
+				assert.Equal(t, *tt.wantOutbound, implemented(mw), "outbound")
+			}
+		})
+	}
+}
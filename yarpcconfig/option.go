@@ -20,19 +20,26 @@
 
 package yarpcconfig
 
+import (
+	netmetrics "go.uber.org/net/metrics"
+	"go.uber.org/zap"
+)
+
 // Option customizes a Configurator.
 type Option func(*Configurator)
 
 // InterpolationResolver changes how interpolated variables in the
 // configuration are resolved. By default, environment variables are used.
 //
-// Variables will be interpolated using this function if a parsed
-// field inside a configuration structure was annotated with
-// config:",interpolate" or config:"$name,interpolate" where $name is encoded
-// name of that field.
+// Variables in the string values of the configuration are expanded using
+// this function before the configuration is decoded, unless expansion is
+// disabled with DisableExpansion.
 //
-// Only primitive types (numbers, strings, and time.Duration) are
-// interpolated.
+// With expansion disabled, variables will be interpolated using this
+// function if a parsed field inside a configuration structure was annotated
+// with config:",interpolate" or config:"$name,interpolate" where $name is
+// encoded name of that field. Only primitive types (numbers, strings, and
+// time.Duration) are interpolated.
 //
 // 	type myConfig struct {
 // 		Host string `config:"host,interpolate"`
@@ -44,3 +51,51 @@ func InterpolationResolver(f func(k string) (v string, ok bool)) Option {
 		c.resolver = f
 	}
 }
+
+// DisableExpansion disables the expansion of variables, files and escapes
+// in the string values of the configuration, so that values are decoded
+// as-is.
+//
+// Fields annotated with config:",interpolate" are still interpolated with
+// the variables of the InterpolationResolver, as they were before
+// configurations were expanded.
+func DisableExpansion() Option {
+	return func(c *Configurator) {
+		c.disableExpansion = true
+	}
+}
+
+// StrictKeys enables the strict checking of configuration keys.
+//
+// With this option, a key that is not an attribute of the configuration it
+// appears in fails to load, reporting the full path of the key, a
+// suggestion, and the known attributes, like,
+//
+// 	unknown key "adress" at inbounds.http.adress: did you mean "address"?; need one of address, ...
+//
+// Without it, unknown keys are reported by the specs that decode them, if at
+// all, as they always have been.
+func StrictKeys() Option {
+	return func(c *Configurator) {
+		c.strictKeys = true
+	}
+}
+
+// Logger specifies a logger for warnings about the configuration, like the
+// use of deprecated attribute names.
+func Logger(logger *zap.Logger) Option {
+	return func(c *Configurator) {
+		c.logger = logger
+	}
+}
+
+// Metrics specifies the scope for metrics emitted by the Dispatcher and by
+// the peer lists built for its outbounds.
+//
+// The scope is used for Dispatchers built by the Configurator unless the
+// configuration is modified before building the Dispatcher.
+func Metrics(meter *netmetrics.Scope) Option {
+	return func(c *Configurator) {
+		c.meter = meter
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/cancelbody"
+)
+
+// _proceduresMiddlewareName is the name of the middleware that applies the
+// procedure options of an outbound.
+const _proceduresMiddlewareName = "procedures"
+
+// ProcedureOptions are the options that the procedures section of the
+// configuration of an outbound gives the calls to a procedure.
+type ProcedureOptions struct {
+	// Timeout bounds each attempt of the calls, in addition to their
+	// deadline, so that a retried call can try again before its deadline.
+	// Zero leaves the deadline of the calls as it is.
+	Timeout time.Duration
+
+	// Retry is the retry policy of the calls, which takes precedence over
+	// the policies of the retries section for the service of the outbound.
+	// Nil leaves the calls to those policies.
+	Retry *yarpc.RetryPolicy
+}
+
+type procedureOptionsKey struct{}
+
+// ProcedureOptionsFromContext returns the options of the procedure of an
+// outbound call, for the middleware of the outbound and the outbound itself,
+// and false if the configuration of the outbound has no options for the
+// procedure.
+func ProcedureOptionsFromContext(ctx context.Context) (ProcedureOptions, bool) {
+	opts, ok := ctx.Value(procedureOptionsKey{}).(ProcedureOptions)
+	return opts, ok
+}
+
+// procedureConfig allows configuring the calls to a procedure of an outbound
+// from YAML.
+type procedureConfig struct {
+	Timeout time.Duration `config:"timeout"`
+	Retries *retryPolicy  `config:"retries"`
+}
+
+// procedureOptions returns the options of the procedures of the outbound by
+// procedure pattern.
+func (o *outbounds) procedureOptions() (map[string]ProcedureOptions, error) {
+	if len(o.Procedures) == 0 {
+		return nil, nil
+	}
+
+	opts := make(map[string]ProcedureOptions, len(o.Procedures))
+	for pattern, cfg := range o.Procedures {
+		if pattern == "" {
+			return nil, errors.New("invalid procedure options: procedure is required")
+		}
+		if i := strings.Index(pattern, "*"); i >= 0 && i != len(pattern)-1 {
+			return nil, fmt.Errorf("invalid options for procedure %q: wildcard must be at the end", pattern)
+		}
+		if cfg.Timeout < 0 {
+			return nil, fmt.Errorf("invalid options for procedure %q: timeout must not be negative", pattern)
+		}
+		po := ProcedureOptions{Timeout: cfg.Timeout}
+		if cfg.Retries != nil {
+			p, err := cfg.Retries.policy()
+			if err != nil {
+				return nil, fmt.Errorf("invalid retry policy for procedure %q: %v", pattern, err)
+			}
+			po.Retry = &p
+		}
+		opts[pattern] = po
+	}
+	return opts, nil
+}
+
+// fillProcedureRetries adds the retry policies of the procedures of the
+// outbounds to the overrides of the retry configuration, after those of the
+// retries section, so that they take precedence.
+func fillProcedureRetries(cfg *yarpc.Config, outbounds clientConfigs, opts map[string]map[string]ProcedureOptions) {
+	names := make([]string, 0, len(opts))
+	for name := range opts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		patterns := make([]string, 0, len(opts[name]))
+		for pattern := range opts[name] {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+
+		for _, pattern := range patterns {
+			if p := opts[name][pattern].Retry; p != nil {
+				cfg.Retry.Overrides = append(cfg.Retry.Overrides, yarpc.RetryPolicyOverride{
+					Service:   outbounds[name].Service,
+					Procedure: pattern,
+					Policy:    *p,
+				})
+			}
+		}
+	}
+}
+
+var (
+	_ middleware.UnaryOutbound  = (*proceduresMiddleware)(nil)
+	_ middleware.OnewayOutbound = (*proceduresMiddleware)(nil)
+)
+
+// proceduresMiddleware is an outbound middleware that makes the options of
+// the procedures of an outbound available to the calls, and bounds them with
+// their timeouts.
+type proceduresMiddleware struct {
+	exact    map[string]ProcedureOptions
+	prefixes []procedurePrefix
+}
+
+type procedurePrefix struct {
+	prefix string
+	opts   ProcedureOptions
+}
+
+// newProceduresMiddleware builds the middleware for the options of the
+// procedures of an outbound by procedure pattern. A pattern is either a
+// procedure name, or a prefix of procedure names followed by "*", like
+// "KeyValue::*". Procedure names take precedence over prefixes, and longer
+// prefixes over shorter ones.
+func newProceduresMiddleware(opts map[string]ProcedureOptions) *proceduresMiddleware {
+	m := &proceduresMiddleware{exact: make(map[string]ProcedureOptions)}
+	for pattern, o := range opts {
+		if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+			m.prefixes = append(m.prefixes, procedurePrefix{prefix: prefix, opts: o})
+		} else {
+			m.exact[pattern] = o
+		}
+	}
+	return m
+}
+
+func (m *proceduresMiddleware) lookup(procedure string) (ProcedureOptions, bool) {
+	if o, ok := m.exact[procedure]; ok {
+		return o, true
+	}
+
+	var (
+		opts    ProcedureOptions
+		longest = -1
+	)
+	for _, p := range m.prefixes {
+		if len(p.prefix) > longest && strings.HasPrefix(procedure, p.prefix) {
+			opts, longest = p.opts, len(p.prefix)
+		}
+	}
+	return opts, longest >= 0
+}
+
+// withOptions returns the context of a call with the options of its
+// procedure, and with its deadline bounded by their timeout.
+func (m *proceduresMiddleware) withOptions(ctx context.Context, req *transport.Request) (context.Context, context.CancelFunc) {
+	opts, ok := m.lookup(req.Procedure)
+	if !ok {
+		return ctx, func() {}
+	}
+
+	ctx = context.WithValue(ctx, procedureOptionsKey{}, opts)
+	if opts.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, opts.Timeout)
+}
+
+func (m *proceduresMiddleware) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	ctx, cancel := m.withOptions(ctx, req)
+	res, err := out.Call(ctx, req)
+	if err != nil {
+		cancel()
+		return res, err
+	}
+	// The body of the response may still be read with the context of the
+	// call, so the timeout is cancelled when it is closed.
+	return cancelbody.Wrap(res, cancel), nil
+}
+
+func (m *proceduresMiddleware) CallOneway(ctx context.Context, req *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	ctx, cancel := m.withOptions(ctx, req)
+	defer cancel()
+	return out.CallOneway(ctx, req)
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig_test
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/yarpcconfig"
+)
+
+// deadlineRecorder is outbound middleware that records the remaining TTL and
+// the procedure options of calls by procedure, without sending them.
+type deadlineRecorder struct {
+	mu   sync.Mutex
+	ttls map[string]time.Duration
+	opts map[string]yarpcconfig.ProcedureOptions
+}
+
+func (r *deadlineRecorder) Call(ctx context.Context, req *transport.Request, _ transport.UnaryOutbound) (*transport.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		r.ttls[req.Procedure] = time.Until(deadline)
+	}
+	if opts, ok := yarpcconfig.ProcedureOptionsFromContext(ctx); ok {
+		r.opts[req.Procedure] = opts
+	}
+	return &transport.Response{Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+}
+
+func TestProcedureOptions(t *testing.T) {
+	rec := &deadlineRecorder{
+		ttls: make(map[string]time.Duration),
+		opts: make(map[string]yarpcconfig.ProcedureOptions),
+	}
+	c := yarpcconfig.New()
+	c.MustRegisterTransport(http.TransportSpec())
+	c.MustRegisterMiddleware(yarpcconfig.MiddlewareSpec{
+		Name: "recorder",
+		BuildMiddleware: func(struct{}, *yarpcconfig.Kit) (interface{}, error) {
+			return rec, nil
+		},
+	})
+
+	d, err := c.NewDispatcherFromYAML("client", strings.NewReader(whitespace.Expand(`
+		outbounds:
+			keyvalue:
+				middleware: [recorder]
+				http:
+					url: http://127.0.0.1:8080/
+				procedures:
+					KeyValue::GetValue:
+						timeout: 200ms
+					KeyValue::*:
+						timeout: 2s
+						retries: {maxAttempts: 3}
+	`)))
+	require.NoError(t, err)
+	require.NoError(t, d.Start())
+	defer func() { assert.NoError(t, d.Stop()) }()
+
+	client := raw.New(d.ClientConfig("keyvalue"))
+	for _, procedure := range []string{"KeyValue::GetValue", "KeyValue::SetValue", "Admin::Reset"} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		_, err := client.Call(ctx, procedure, nil)
+		cancel()
+		require.NoError(t, err, "call to %q failed", procedure)
+	}
+
+	ttls := rec.ttls
+	assert.True(t, ttls["KeyValue::GetValue"] <= 200*time.Millisecond,
+		"expected the timeout of the procedure, got %v", ttls["KeyValue::GetValue"])
+	assert.True(t, ttls["KeyValue::SetValue"] > 200*time.Millisecond && ttls["KeyValue::SetValue"] <= 2*time.Second,
+		"expected the timeout of the wildcard, got %v", ttls["KeyValue::SetValue"])
+	assert.True(t, ttls["Admin::Reset"] > 2*time.Second,
+		"expected the deadline of the call, got %v", ttls["Admin::Reset"])
+
+	assert.Equal(t, 200*time.Millisecond, rec.opts["KeyValue::GetValue"].Timeout)
+	assert.Nil(t, rec.opts["KeyValue::GetValue"].Retry, "expected no retry policy for the procedure")
+	require.NotNil(t, rec.opts["KeyValue::SetValue"].Retry, "expected the retry policy of the wildcard")
+	assert.Equal(t, 3, rec.opts["KeyValue::SetValue"].Retry.MaxAttempts)
+	assert.NotContains(t, rec.opts, "Admin::Reset", "expected no options for other procedures")
+}
+
+func TestProcedureOptionsRetries(t *testing.T) {
+	c := yarpcconfig.New()
+	c.MustRegisterTransport(http.TransportSpec())
+	got, err := c.LoadConfigFromYAML("client", strings.NewReader(whitespace.Expand(`
+		outbounds:
+			keyvalue:
+				service: kv
+				unary:
+					http:
+						url: http://127.0.0.1:8080/
+				procedures:
+					KeyValue::GetValue:
+						retries: {maxAttempts: 5, retryableCodes: [unavailable]}
+					KeyValue::SetValue:
+						timeout: 1s
+		retries:
+			overrides:
+				- service: kv
+				  maxAttempts: 2
+	`)))
+	require.NoError(t, err)
+
+	overrides := got.Retry.Overrides
+	require.Len(t, overrides, 2, "expected the override of the service and of the procedure")
+	assert.Equal(t, "kv", overrides[0].Service)
+	assert.Empty(t, overrides[0].Procedure)
+	assert.Equal(t, "kv", overrides[1].Service, "expected the service of the outbound")
+	assert.Equal(t, "KeyValue::GetValue", overrides[1].Procedure)
+	assert.Equal(t, 5, overrides[1].Policy.MaxAttempts)
+
+	names, err := got.PerOutboundMiddleware["keyvalue"].Names()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"procedures"}, names)
+}
+
+func TestProcedureOptionsErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr string
+	}{
+		{
+			desc: "wildcard in the middle",
+			give: `
+				outbounds:
+					keyvalue:
+						http: {url: "http://127.0.0.1:8080/"}
+						procedures:
+							KeyValue::*Value:
+								timeout: 1s
+			`,
+			wantErr: `invalid options for procedure "KeyValue::*Value": wildcard must be at the end`,
+		},
+		{
+			desc: "negative timeout",
+			give: `
+				outbounds:
+					keyvalue:
+						http: {url: "http://127.0.0.1:8080/"}
+						procedures:
+							KeyValue::GetValue:
+								timeout: -1s
+			`,
+			wantErr: `invalid options for procedure "KeyValue::GetValue": timeout must not be negative`,
+		},
+		{
+			desc: "unknown retryable code",
+			give: `
+				outbounds:
+					keyvalue:
+						http: {url: "http://127.0.0.1:8080/"}
+						procedures:
+							KeyValue::GetValue:
+								retries: {retryableCodes: [flaky]}
+			`,
+			wantErr: "could not decode error code",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			c := yarpcconfig.New()
+			c.MustRegisterTransport(http.TransportSpec())
+			_, err := c.LoadConfigFromYAML("client", strings.NewReader(whitespace.Expand(tt.give)))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/zap"
+)
+
+// ReloadableDispatcher is a Dispatcher whose outbounds and policies may be
+// reconfigured at runtime from updated configuration, without a restart.
+//
+// Reloading replaces the outbounds whose configuration changed, like their
+// URLs or peer lists, and updates the retry policies and rate limits of the
+// Dispatcher. Other changes require a restart, and fail to reload.
+type ReloadableDispatcher struct {
+	c           *Configurator
+	serviceName string
+	dispatcher  *yarpc.Dispatcher
+	refs        *transportRefs
+
+	mu        sync.Mutex
+	cfg       *yarpcConfig
+	outbounds map[string]*reloadableOutbounds
+}
+
+// reloadableOutbounds are the reloadable outbounds of an outbound key.
+type reloadableOutbounds struct {
+	unary, oneway, stream *reloadableOutbound
+}
+
+// NewReloadableDispatcher builds a new ReloadableDispatcher from the given
+// configuration data.
+func (c *Configurator) NewReloadableDispatcher(serviceName string, data interface{}) (*ReloadableDispatcher, error) {
+	cfg, checkErr, err := c.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	yc, err := c.load(serviceName, cfg, checkErr)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &ReloadableDispatcher{
+		c:           c,
+		serviceName: serviceName,
+		refs:        newTransportRefs(),
+		cfg:         cfg,
+		outbounds:   make(map[string]*reloadableOutbounds, len(yc.Outbounds)),
+	}
+	outbounds := make(yarpc.Outbounds, len(yc.Outbounds))
+	for key, outs := range yc.Outbounds {
+		var ro reloadableOutbounds
+		if outs.Unary != nil {
+			ro.unary = newReloadableOutbound(namerOrEmpty(outs.Unary), outs.Unary)
+			outs.Unary = reloadableUnaryOutbound{ro.unary}
+		}
+		if outs.Oneway != nil {
+			ro.oneway = newReloadableOutbound(namerOrEmpty(outs.Oneway), outs.Oneway)
+			outs.Oneway = reloadableOnewayOutbound{ro.oneway}
+		}
+		if outs.Stream != nil {
+			ro.stream = newReloadableOutbound(namerOrEmpty(outs.Stream), outs.Stream)
+			outs.Stream = reloadableStreamOutbound{ro.stream}
+		}
+		d.outbounds[key] = &ro
+		outbounds[key] = outs
+	}
+	if len(outbounds) > 0 {
+		yc.Outbounds = outbounds
+	}
+	d.dispatcher = yarpc.NewDispatcher(yc)
+	return d, nil
+}
+
+// NewReloadableDispatcherFromYAML builds a new ReloadableDispatcher from the
+// given YAML configuration.
+func (c *Configurator) NewReloadableDispatcherFromYAML(serviceName string, r io.Reader) (*ReloadableDispatcher, error) {
+	data, err := readYAML(r, "")
+	if err != nil {
+		return nil, err
+	}
+	return c.NewReloadableDispatcher(serviceName, data)
+}
+
+func namerOrEmpty(o transport.Outbound) string {
+	if n, ok := o.(transport.Namer); ok {
+		return n.TransportName()
+	}
+	return ""
+}
+
+// Dispatcher returns the Dispatcher, which must be started and stopped like
+// any other Dispatcher.
+func (d *ReloadableDispatcher) Dispatcher() *yarpc.Dispatcher {
+	return d.dispatcher
+}
+
+// ReloadFromYAML reloads the Dispatcher from the given YAML configuration.
+// See Reload.
+func (d *ReloadableDispatcher) ReloadFromYAML(r io.Reader) error {
+	data, err := readYAML(r, "")
+	if err != nil {
+		return err
+	}
+	return d.Reload(data)
+}
+
+// Reload reconfigures the Dispatcher from the given configuration data,
+// applying the changes from its current configuration in place. Outbounds
+// whose configuration changed, like their URLs, peers, or peer list
+// updaters, are replaced by new outbounds. The retry policies and rate
+// limits change, but not the retry budget or the keys of the rate limits.
+//
+// Replaced outbounds stop once the calls in flight on them complete, and
+// new calls use the new outbounds as soon as Reload returns.
+//
+// Changes that require a restart, like changes to inbounds, transports,
+// middleware, to the outbound keys and their services, or to the procedures
+// of outbounds, fail to reload with an error describing them. Configuration
+// that fails to reload, or to load, changes nothing.
+func (d *ReloadableDispatcher) Reload(data interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cfg, checkErr, err := d.c.decode(data)
+	if err != nil {
+		return err
+	}
+	if err := multierr.Append(checkErr, d.restartRequired(cfg)); err != nil {
+		return fmt.Errorf("cannot reload configuration: %v", err)
+	}
+	yc, err := d.c.load(d.serviceName, cfg, nil)
+	if err != nil {
+		return err
+	}
+
+	changed, err := d.changedOutbounds(cfg, yc)
+	if err != nil {
+		return fmt.Errorf("cannot reload configuration: %v", err)
+	}
+	if err := d.replaceOutbounds(changed); err != nil {
+		return fmt.Errorf("cannot reload configuration: %v", err)
+	}
+
+	if r := d.dispatcher.Retrier(); r != nil {
+		r.SetPolicies(yc.Retry.Default, yc.Retry.Overrides)
+	}
+	if l := d.dispatcher.RateLimiter(); l != nil {
+		l.SetLimits(yc.RateLimit.Default, yc.RateLimit.Overrides)
+	}
+	d.cfg = cfg
+	return nil
+}
+
+// restartRequired describes the changes of the configuration that require a
+// restart, if any.
+func (d *ReloadableDispatcher) restartRequired(cfg *yarpcConfig) (err error) {
+	old := d.cfg
+	sections := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"inbounds", sortedInbounds(old.Inbounds), sortedInbounds(cfg.Inbounds)},
+		{"transports", old.Transports, cfg.Transports},
+		{"logging", old.Logging, cfg.Logging},
+		{"metrics", old.Metrics, cfg.Metrics},
+		{"retries.budget", old.Retries.Budget, cfg.Retries.Budget},
+		{"rateLimits.keyByProcedure", old.RateLimits.KeyByProcedure, cfg.RateLimits.KeyByProcedure},
+		{"rateLimits.keyByCaller", old.RateLimits.KeyByCaller, cfg.RateLimits.KeyByCaller},
+		{"deadlines", old.Deadlines, cfg.Deadlines},
+		{"timeouts", old.Timeouts, cfg.Timeouts},
+		{"panicRecovery", old.PanicRecovery, cfg.PanicRecovery},
+		{"headerPropagation", old.HeaderPropagation, cfg.HeaderPropagation},
+		{"middleware", old.Middleware, cfg.Middleware},
+	}
+	for _, s := range sections {
+		if !reflect.DeepEqual(s.old, s.new) {
+			err = multierr.Append(err, fmt.Errorf("changing %q requires a restart", s.name))
+		}
+	}
+
+	if d.dispatcher.Retrier() == nil && (cfg.Retries.Default != nil || len(cfg.Retries.Overrides) > 0) {
+		err = multierr.Append(err, errors.New("adding retry policies requires a restart"))
+	}
+	if d.dispatcher.RateLimiter() == nil && (cfg.RateLimits.Default != nil || len(cfg.RateLimits.Overrides) > 0) {
+		err = multierr.Append(err, errors.New("adding rate limits requires a restart"))
+	}
+
+	for _, name := range sortedKeys(old.Outbounds, cfg.Outbounds) {
+		o, inOld := old.Outbounds[name]
+		n, inNew := cfg.Outbounds[name]
+		switch {
+		case !inOld:
+			err = multierr.Append(err, fmt.Errorf("adding outbound %q requires a restart", name))
+		case !inNew:
+			err = multierr.Append(err, fmt.Errorf("removing outbound %q requires a restart", name))
+		case o.Service != n.Service:
+			err = multierr.Append(err, fmt.Errorf("changing the service of outbound %q requires a restart", name))
+		case !reflect.DeepEqual(o.Middleware, n.Middleware):
+			err = multierr.Append(err, fmt.Errorf("changing the middleware of outbound %q requires a restart", name))
+		case !reflect.DeepEqual(o.Procedures, n.Procedures):
+			err = multierr.Append(err, fmt.Errorf("changing the procedures of outbound %q requires a restart", name))
+		}
+	}
+	return err
+}
+
+// replacement is a new outbound for a reloadable outbound.
+type replacement struct {
+	key      string
+	outbound *reloadableOutbound
+	next     *generation
+	started  bool
+}
+
+// changedOutbounds returns the replacements of the outbounds whose
+// configuration changed.
+func (d *ReloadableDispatcher) changedOutbounds(cfg *yarpcConfig, yc yarpc.Config) (changed []*replacement, err error) {
+	for _, name := range sortedKeys(cfg.Outbounds, nil) {
+		if reflect.DeepEqual(d.cfg.Outbounds[name], cfg.Outbounds[name]) {
+			continue
+		}
+
+		current, outs := d.outbounds[name], yc.Outbounds[name]
+		if (current.unary == nil) != (outs.Unary == nil) ||
+			(current.oneway == nil) != (outs.Oneway == nil) ||
+			(current.stream == nil) != (outs.Stream == nil) {
+			err = multierr.Append(err, fmt.Errorf("changing the RPC types of outbound %q requires a restart", name))
+			continue
+		}
+
+		add := func(o *reloadableOutbound, next transport.Outbound) {
+			if o != nil {
+				changed = append(changed, &replacement{
+					key:      name,
+					outbound: o,
+					next:     &generation{outbound: next, refs: d.refs},
+				})
+			}
+		}
+		add(current.unary, outs.Unary)
+		add(current.oneway, outs.Oneway)
+		add(current.stream, outs.Stream)
+	}
+	return changed, err
+}
+
+// replaceOutbounds starts the new outbounds of running outbounds, and
+// replaces the outbounds if they all start.
+func (d *ReloadableDispatcher) replaceOutbounds(changed []*replacement) error {
+	for i, r := range changed {
+		started, err := r.outbound.prepare(r.next)
+		if err != nil {
+			for _, prepared := range changed[:i] {
+				if prepared.started {
+					err = multierr.Append(err, prepared.next.stop())
+				}
+			}
+			return fmt.Errorf("failed to start outbound %q: %v", r.key, err)
+		}
+		r.started = started
+	}
+
+	for _, r := range changed {
+		release := r.outbound.replace(r.next, r.started)
+		go func(key string) {
+			if err := release(); err != nil {
+				d.c.logger.Error("failed to stop replaced outbound",
+					zap.String("outbound", key), zap.Error(err))
+			}
+		}(r.key)
+	}
+	return nil
+}
+
+// sortedInbounds returns the inbounds in a stable order, since their order
+// in the configuration is not.
+func sortedInbounds(is inbounds) []string {
+	sorted := make([]string, len(is))
+	for i, in := range is {
+		sorted[i] = fmt.Sprintf("%#v", in)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// sortedKeys returns the names of the outbounds of both configurations.
+func sortedKeys(a, b clientConfigs) []string {
+	names := make([]string, 0, len(a)+len(b))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc"
+	"go.uber.org/yarpc/encoding/raw"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/yarpc/yarpcerrors"
+	"gopkg.in/yaml.v2"
+)
+
+// newBackend starts a dispatcher for the backend service, which replies to
+// calls of "name" with its name, after running the optional before hook.
+func newBackend(t *testing.T, name string, before func() error) string {
+	inbound := http.NewTransport().NewInbound("127.0.0.1:0")
+	d := yarpc.NewDispatcher(yarpc.Config{
+		Name:     "backend",
+		Inbounds: yarpc.Inbounds{inbound},
+	})
+	d.Register(raw.Procedure("name", func(ctx context.Context, body []byte) ([]byte, error) {
+		if before != nil {
+			if err := before(); err != nil {
+				return nil, err
+			}
+		}
+		return []byte(name), nil
+	}))
+	require.NoError(t, d.Start())
+	t.Cleanup(func() { assert.NoError(t, d.Stop()) })
+	return inbound.Addr().String()
+}
+
+func newReloadConfigurator() *yarpcconfig.Configurator {
+	c := yarpcconfig.New()
+	c.MustRegisterTransport(http.TransportSpec())
+	c.MustRegisterPeerList(roundrobin.Spec())
+	return c
+}
+
+func reloadConfig(peer string, extra string) string {
+	return fmt.Sprintf(`
+outbounds:
+  backend:
+    unary:
+      http:
+        url: http://backend/
+        round-robin:
+          peers: [%v]
+%v`, peer, extra)
+}
+
+func mustParseYAML(t *testing.T, s string) map[string]interface{} {
+	var data map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(s), &data))
+	return data
+}
+
+func callName(client raw.Client) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), testtime.Second)
+	defer cancel()
+	res, err := client.Call(ctx, "name", nil)
+	return string(res), err
+}
+
+func TestReloadPeers(t *testing.T) {
+	a := newBackend(t, "a", nil)
+	b := newBackend(t, "b", nil)
+
+	rd, err := newReloadConfigurator().NewReloadableDispatcherFromYAML("client", strings.NewReader(reloadConfig(a, "")))
+	require.NoError(t, err)
+	require.NoError(t, rd.Dispatcher().Start())
+	defer func() { assert.NoError(t, rd.Dispatcher().Stop()) }()
+	client := raw.New(rd.Dispatcher().ClientConfig("backend"))
+
+	name, err := callName(client)
+	require.NoError(t, err)
+	assert.Equal(t, "a", name)
+
+	// Call the backend continuously while the peers change, expecting no
+	// failures.
+	var (
+		stop     = make(chan struct{})
+		wg       sync.WaitGroup
+		failures atomic.Int32
+		fromB    atomic.Int32
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				name, err := callName(client)
+				if err != nil {
+					failures.Inc()
+					t.Errorf("call failed during reload: %v", err)
+					return
+				}
+				if name == "b" {
+					fromB.Inc()
+				}
+			}
+		}()
+	}
+
+	time.Sleep(testtime.Millisecond * 20)
+	require.NoError(t, rd.ReloadFromYAML(strings.NewReader(reloadConfig(b, ""))))
+	time.Sleep(testtime.Millisecond * 20)
+	close(stop)
+	wg.Wait()
+
+	assert.Zero(t, failures.Load(), "expected no failed calls")
+	assert.NotZero(t, fromB.Load(), "expected calls to move to the new peer")
+	for i := 0; i < 5; i++ {
+		name, err := callName(client)
+		require.NoError(t, err)
+		assert.Equal(t, "b", name, "expected every call after the reload to use the new peer")
+	}
+}
+
+func TestReloadInFlight(t *testing.T) {
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	var once sync.Once
+	a := newBackend(t, "a", func() error {
+		once.Do(func() { close(entered) })
+		<-unblock
+		return nil
+	})
+	b := newBackend(t, "b", nil)
+
+	rd, err := newReloadConfigurator().NewReloadableDispatcher("client", mustParseYAML(t, reloadConfig(a, "")))
+	require.NoError(t, err)
+	require.NoError(t, rd.Dispatcher().Start())
+	defer func() { assert.NoError(t, rd.Dispatcher().Stop()) }()
+	client := raw.New(rd.Dispatcher().ClientConfig("backend"))
+
+	type result struct {
+		name string
+		err  error
+	}
+	inFlight := make(chan result)
+	go func() {
+		name, err := callName(client)
+		inFlight <- result{name, err}
+	}()
+	<-entered
+
+	require.NoError(t, rd.Reload(mustParseYAML(t, reloadConfig(b, ""))))
+	name, err := callName(client)
+	require.NoError(t, err)
+	assert.Equal(t, "b", name, "expected new calls to use the new peer")
+
+	close(unblock)
+	res := <-inFlight
+	require.NoError(t, res.err, "expected the call in flight to complete on the old outbound")
+	assert.Equal(t, "a", res.name)
+}
+
+func TestReloadRetryPolicies(t *testing.T) {
+	var attempts atomic.Int32
+	a := newBackend(t, "a", func() error {
+		if attempts.Inc()%3 != 0 {
+			return yarpcerrors.UnavailableErrorf("try again")
+		}
+		return nil
+	})
+
+	retries := func(maxAttempts int) string {
+		return fmt.Sprintf(`
+retries:
+  default:
+    maxAttempts: %d
+    backoff:
+      exponential:
+        first: 1ms
+        max: 1ms
+`, maxAttempts)
+	}
+
+	rd, err := newReloadConfigurator().NewReloadableDispatcher("client", mustParseYAML(t, reloadConfig(a, retries(1))))
+	require.NoError(t, err)
+	require.NoError(t, rd.Dispatcher().Start())
+	defer func() { assert.NoError(t, rd.Dispatcher().Stop()) }()
+	client := raw.New(rd.Dispatcher().ClientConfig("backend"))
+
+	_, err = callName(client)
+	assert.Equal(t, yarpcerrors.CodeUnavailable, yarpcerrors.FromError(err).Code())
+
+	attempts.Store(0)
+	require.NoError(t, rd.Reload(mustParseYAML(t, reloadConfig(a, retries(3)))))
+	name, err := callName(client)
+	require.NoError(t, err, "expected the new retry policy to apply")
+	assert.Equal(t, "a", name)
+}
+
+func TestReloadRestartRequired(t *testing.T) {
+	a := newBackend(t, "a", nil)
+	b := newBackend(t, "b", nil)
+
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr []string
+	}{
+		{
+			desc: "inbounds",
+			give: reloadConfig(b, `
+inbounds:
+  http: {address: "127.0.0.1:0"}
+`),
+			wantErr: []string{`changing "inbounds" requires a restart`},
+		},
+		{
+			desc: "new outbound",
+			give: reloadConfig(b, `
+  other:
+    http: {url: "http://127.0.0.1:8080"}
+`),
+			wantErr: []string{`adding outbound "other" requires a restart`},
+		},
+		{
+			desc:    "removed outbound",
+			give:    `{}`,
+			wantErr: []string{`removing outbound "backend" requires a restart`},
+		},
+		{
+			desc: "new RPC type",
+			give: fmt.Sprintf(`
+outbounds:
+  backend:
+    http:
+      url: http://backend/
+      round-robin:
+        peers: [%v]
+`, b),
+			wantErr: []string{`changing the RPC types of outbound "backend" requires a restart`},
+		},
+		{
+			desc: "several changes",
+			give: reloadConfig(b, `
+transports:
+  http: {keepAlive: 10s}
+rateLimits:
+  default: {rate: 10, burst: 10}
+`),
+			wantErr: []string{
+				"cannot reload configuration: ",
+				`changing "transports" requires a restart`,
+				"adding rate limits requires a restart",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			rd, err := newReloadConfigurator().NewReloadableDispatcher("client", mustParseYAML(t, reloadConfig(a, "")))
+			require.NoError(t, err)
+			require.NoError(t, rd.Dispatcher().Start())
+			defer func() { assert.NoError(t, rd.Dispatcher().Stop()) }()
+
+			err = rd.Reload(mustParseYAML(t, tt.give))
+			require.Error(t, err)
+			for _, msg := range tt.wantErr {
+				assert.Contains(t, err.Error(), msg)
+			}
+
+			name, err := callName(raw.New(rd.Dispatcher().ClientConfig("backend")))
+			require.NoError(t, err)
+			assert.Equal(t, "a", name, "expected a failed reload to change nothing")
+		})
+	}
+}
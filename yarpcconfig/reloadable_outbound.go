@@ -0,0 +1,303 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/x/introspection"
+)
+
+// transportRefs starts the transports of reloaded outbounds when the first
+// outbound that uses them starts, and stops them when the last one stops.
+//
+// The transports of the outbounds of the original configuration belong to
+// the Dispatcher, which starts and stops them itself.
+type transportRefs struct {
+	mu     sync.Mutex
+	counts map[transport.Transport]int
+}
+
+func newTransportRefs() *transportRefs {
+	return &transportRefs{counts: make(map[transport.Transport]int)}
+}
+
+func (r *transportRefs) acquire(ts []transport.Transport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, t := range ts {
+		if r.counts[t] == 0 {
+			if err := t.Start(); err != nil {
+				r.releaseLocked(ts[:i])
+				return err
+			}
+		}
+		r.counts[t]++
+	}
+	return nil
+}
+
+func (r *transportRefs) release(ts []transport.Transport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.releaseLocked(ts)
+}
+
+func (r *transportRefs) releaseLocked(ts []transport.Transport) (err error) {
+	for _, t := range ts {
+		r.counts[t]--
+		if r.counts[t] > 0 {
+			continue
+		}
+		delete(r.counts, t)
+		err = multierr.Append(err, t.Stop())
+	}
+	return err
+}
+
+// generation is an outbound of a reloadable outbound, with the calls in
+// flight on it.
+type generation struct {
+	outbound transport.Outbound
+
+	// refs holds the transports of the outbound if they do not belong to
+	// the Dispatcher.
+	refs *transportRefs
+
+	calls sync.WaitGroup
+}
+
+func (g *generation) start() error {
+	if g.refs == nil {
+		return g.outbound.Start()
+	}
+	ts := uniqueTransports(g.outbound.Transports())
+	if err := g.refs.acquire(ts); err != nil {
+		return err
+	}
+	if err := g.outbound.Start(); err != nil {
+		return multierr.Append(err, g.refs.release(ts))
+	}
+	return nil
+}
+
+func (g *generation) stop() error {
+	err := g.outbound.Stop()
+	if g.refs != nil {
+		err = multierr.Append(err, g.refs.release(uniqueTransports(g.outbound.Transports())))
+	}
+	return err
+}
+
+func uniqueTransports(ts []transport.Transport) []transport.Transport {
+	seen := make(map[transport.Transport]struct{}, len(ts))
+	unique := ts[:0:0]
+	for _, t := range ts {
+		if _, ok := seen[t]; !ok {
+			seen[t] = struct{}{}
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
+
+// reloadableOutbound sends calls to its current outbound, which is replaced
+// when the configuration reloads. Replaced outbounds are stopped once the
+// calls in flight on them complete.
+type reloadableOutbound struct {
+	name string
+
+	mu      sync.RWMutex
+	current *generation
+	running bool
+}
+
+func newReloadableOutbound(name string, o transport.Outbound) *reloadableOutbound {
+	return &reloadableOutbound{
+		name:    name,
+		current: &generation{outbound: o},
+	}
+}
+
+// acquire returns the current outbound, counting a call in flight on it
+// until the call is done.
+func (o *reloadableOutbound) acquire() *generation {
+	o.mu.RLock()
+	g := o.current
+	g.calls.Add(1)
+	o.mu.RUnlock()
+	return g
+}
+
+// prepare starts the replacement of the current outbound if the current
+// outbound is running, so that it can take calls as soon as it replaces it.
+func (o *reloadableOutbound) prepare(g *generation) (started bool, err error) {
+	o.mu.RLock()
+	running := o.running
+	o.mu.RUnlock()
+	if !running {
+		return false, nil
+	}
+	return true, g.start()
+}
+
+// replace makes the prepared outbound current, returning a function that
+// waits for the calls in flight on the replaced outbound and stops it.
+func (o *reloadableOutbound) replace(g *generation, started bool) (release func() error) {
+	o.mu.Lock()
+	old, running := o.current, o.running
+	o.current = g
+	if running && !started {
+		// The outbound started after the replacement was prepared.
+		if err := g.start(); err != nil {
+			o.current = old
+			o.mu.Unlock()
+			return func() error { return err }
+		}
+	}
+	o.mu.Unlock()
+
+	return func() error {
+		old.calls.Wait()
+		if !running {
+			return nil
+		}
+		return old.stop()
+	}
+}
+
+func (o *reloadableOutbound) TransportName() string {
+	return o.name
+}
+
+func (o *reloadableOutbound) Transports() []transport.Transport {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.current.outbound.Transports()
+}
+
+func (o *reloadableOutbound) Start() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.running {
+		return nil
+	}
+	if err := o.current.start(); err != nil {
+		return err
+	}
+	o.running = true
+	return nil
+}
+
+func (o *reloadableOutbound) Stop() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.running {
+		return nil
+	}
+	o.running = false
+	return o.current.stop()
+}
+
+func (o *reloadableOutbound) IsRunning() bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.running
+}
+
+func (o *reloadableOutbound) Introspect() introspection.OutboundStatus {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if i, ok := o.current.outbound.(introspection.IntrospectableOutbound); ok {
+		return i.Introspect()
+	}
+	return introspection.OutboundStatusNotSupported
+}
+
+type reloadableUnaryOutbound struct{ *reloadableOutbound }
+
+func (o reloadableUnaryOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	g := o.acquire()
+	defer g.calls.Done()
+	return g.outbound.(transport.UnaryOutbound).Call(ctx, req)
+}
+
+type reloadableOnewayOutbound struct{ *reloadableOutbound }
+
+func (o reloadableOnewayOutbound) CallOneway(ctx context.Context, req *transport.Request) (transport.Ack, error) {
+	g := o.acquire()
+	defer g.calls.Done()
+	return g.outbound.(transport.OnewayOutbound).CallOneway(ctx, req)
+}
+
+type reloadableStreamOutbound struct{ *reloadableOutbound }
+
+// CallStream opens a stream on the current outbound, which counts as a call
+// in flight until the stream is closed or fails.
+func (o reloadableStreamOutbound) CallStream(ctx context.Context, req *transport.StreamRequest) (*transport.ClientStream, error) {
+	g := o.acquire()
+	stream, err := g.outbound.(transport.StreamOutbound).CallStream(ctx, req)
+	if err != nil {
+		g.calls.Done()
+		return nil, err
+	}
+	return transport.NewClientStream(&reloadableClientStream{ClientStream: stream, g: g})
+}
+
+// reloadableClientStream is a stream of a replaceable outbound, which counts
+// as a call in flight on it until the stream ends.
+type reloadableClientStream struct {
+	*transport.ClientStream
+
+	g    *generation
+	once sync.Once
+}
+
+func (s *reloadableClientStream) done() {
+	s.once.Do(s.g.calls.Done)
+}
+
+func (s *reloadableClientStream) SendMessage(ctx context.Context, msg *transport.StreamMessage) error {
+	err := s.ClientStream.SendMessage(ctx, msg)
+	if err != nil {
+		s.done()
+	}
+	return err
+}
+
+func (s *reloadableClientStream) ReceiveMessage(ctx context.Context) (*transport.StreamMessage, error) {
+	msg, err := s.ClientStream.ReceiveMessage(ctx)
+	if err != nil {
+		s.done()
+	}
+	return msg, err
+}
+
+func (s *reloadableClientStream) Close(ctx context.Context) error {
+	defer s.done()
+	return s.ClientStream.Close(ctx)
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/testtime"
+)
+
+type fakeReloadTransport struct {
+	transport.Transport
+
+	running atomic.Bool
+}
+
+func (t *fakeReloadTransport) Start() error    { t.running.Store(true); return nil }
+func (t *fakeReloadTransport) Stop() error     { t.running.Store(false); return nil }
+func (t *fakeReloadTransport) IsRunning() bool { return t.running.Load() }
+
+// fakeReloadOutbound is a unary outbound whose calls signal called and block
+// until unblock is closed, if set.
+type fakeReloadOutbound struct {
+	transport.UnaryOutbound
+
+	transports []transport.Transport
+	called     chan struct{}
+	unblock    chan struct{}
+	running    atomic.Bool
+}
+
+func (o *fakeReloadOutbound) Transports() []transport.Transport { return o.transports }
+func (o *fakeReloadOutbound) Start() error                      { o.running.Store(true); return nil }
+func (o *fakeReloadOutbound) Stop() error                       { o.running.Store(false); return nil }
+func (o *fakeReloadOutbound) IsRunning() bool                   { return o.running.Load() }
+
+func (o *fakeReloadOutbound) Call(ctx context.Context, req *transport.Request) (*transport.Response, error) {
+	if o.unblock != nil {
+		o.called <- struct{}{}
+		<-o.unblock
+	}
+	return &transport.Response{}, nil
+}
+
+func TestReloadableOutboundReplace(t *testing.T) {
+	old := &fakeReloadOutbound{called: make(chan struct{}), unblock: make(chan struct{})}
+	o := newReloadableOutbound("fake", old)
+	out := reloadableUnaryOutbound{o}
+	require.NoError(t, out.Start())
+	assert.True(t, old.IsRunning())
+
+	inFlight := make(chan error)
+	go func() {
+		_, err := out.Call(context.Background(), &transport.Request{})
+		inFlight <- err
+	}()
+	select {
+	case <-old.called:
+	case <-time.After(testtime.Second):
+		t.Fatal("timed out waiting for the call to reach the old outbound")
+	}
+
+	trans := &fakeReloadTransport{}
+	refs := newTransportRefs()
+	next := &generation{
+		outbound: &fakeReloadOutbound{transports: []transport.Transport{trans, trans}},
+		refs:     refs,
+	}
+	started, err := o.prepare(next)
+	require.NoError(t, err)
+	assert.True(t, started, "expected the new outbound of a running outbound to start")
+	assert.True(t, trans.IsRunning(), "expected the transport of the new outbound to start")
+
+	released := make(chan error)
+	release := o.replace(next, started)
+	go func() { released <- release() }()
+
+	_, err = out.Call(context.Background(), &transport.Request{})
+	require.NoError(t, err, "expected new calls to use the new outbound")
+
+	select {
+	case <-released:
+		t.Fatal("the old outbound must not stop while a call is in flight")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.True(t, old.IsRunning())
+
+	close(old.unblock)
+	require.NoError(t, <-inFlight)
+	require.NoError(t, <-released)
+	assert.False(t, old.IsRunning(), "expected the old outbound to stop after the call completed")
+
+	require.NoError(t, out.Stop())
+	assert.False(t, next.outbound.IsRunning())
+	assert.False(t, trans.IsRunning(), "expected the transport of the new outbound to stop with it")
+}
+
+func TestReloadableOutboundReplaceStopped(t *testing.T) {
+	old := &fakeReloadOutbound{}
+	o := newReloadableOutbound("fake", old)
+
+	trans := &fakeReloadTransport{}
+	next := &generation{
+		outbound: &fakeReloadOutbound{transports: []transport.Transport{trans}},
+		refs:     newTransportRefs(),
+	}
+	started, err := o.prepare(next)
+	require.NoError(t, err)
+	assert.False(t, started, "expected the new outbound of a stopped outbound not to start")
+	require.NoError(t, o.replace(next, started)())
+	assert.False(t, trans.IsRunning())
+
+	require.NoError(t, o.Start())
+	assert.False(t, old.IsRunning(), "expected the replaced outbound not to start")
+	assert.True(t, next.outbound.IsRunning())
+	assert.True(t, trans.IsRunning())
+}
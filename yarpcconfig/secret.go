@@ -0,0 +1,190 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/uber-go/mapdecode"
+)
+
+const (
+	_secretFilePrefix = "file://"
+	_secretEnvPrefix  = "env://"
+
+	// _redactedSecret replaces the values of inline secrets when they are
+	// printed or serialized.
+	_redactedSecret = "[REDACTED]"
+)
+
+type secretSource int
+
+const (
+	secretLiteral secretSource = iota
+	secretFile
+	secretEnv
+)
+
+// Secret is a sensitive value, like a private key or an auth token, for the
+// attributes of specs that need one. It decodes from a reference to the
+// value, or from the value itself:
+//
+//	key: file:///etc/tls/key.pem
+//	token: env://PAYMENTS_TOKEN
+//	password: hunter2
+//
+// File and environment sources are only read when the secret is resolved,
+// and are read again every time, so that reloading a configuration picks up
+// the latest contents of its files.
+//
+// Secrets never reveal their values when printed or serialized, as in the
+// effective configuration: inline values are redacted, and file and
+// environment sources are shown as their references.
+type Secret struct {
+	source secretSource
+	// value is the inline value, the path of the file, or the name of the
+	// environment variable of the secret.
+	value string
+}
+
+// ParseSecret parses a reference to a secret, which is either the path of a
+// file prefixed with "file://", the name of an environment variable prefixed
+// with "env://", or the inline value of the secret.
+func ParseSecret(s string) (Secret, error) {
+	switch {
+	case strings.HasPrefix(s, _secretFilePrefix):
+		path := strings.TrimPrefix(s, _secretFilePrefix)
+		if path == "" {
+			return Secret{}, fmt.Errorf("secret %q has no file path", s)
+		}
+		return Secret{source: secretFile, value: path}, nil
+	case strings.HasPrefix(s, _secretEnvPrefix):
+		name := strings.TrimPrefix(s, _secretEnvPrefix)
+		if name == "" {
+			return Secret{}, fmt.Errorf("secret %q has no environment variable name", s)
+		}
+		return Secret{source: secretEnv, value: name}, nil
+	default:
+		return Secret{source: secretLiteral, value: s}, nil
+	}
+}
+
+// Decode decodes a secret from its reference.
+func (s *Secret) Decode(into mapdecode.Into) error {
+	var value interface{}
+	if err := into(&value); err != nil {
+		return err
+	}
+	if value == nil {
+		*s = Secret{}
+		return nil
+	}
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("could not decode secret: expected a string, got %T", value)
+	}
+	secret, err := ParseSecret(str)
+	if err != nil {
+		return fmt.Errorf("could not decode secret: %v", err)
+	}
+	*s = secret
+	return nil
+}
+
+// IsZero reports whether the secret is unset, or set to an empty inline
+// value.
+func (s Secret) IsZero() bool {
+	return s.source == secretLiteral && s.value == ""
+}
+
+// Resolve returns the value of the secret, reading its file or environment
+// variable. A trailing newline is trimmed from the contents of files.
+func (s Secret) Resolve() ([]byte, error) {
+	switch s.source {
+	case secretFile:
+		b, err := ioutil.ReadFile(s.value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read secret %v: %v", s, err)
+		}
+		// Like file references, files usually end with a newline that is not
+		// part of their value.
+		b = bytes.TrimSuffix(b, []byte("\n"))
+		return bytes.TrimSuffix(b, []byte("\r")), nil
+	case secretEnv:
+		v, ok := os.LookupEnv(s.value)
+		if !ok {
+			return nil, fmt.Errorf("cannot read secret %v: environment variable %q is not set", s, s.value)
+		}
+		return []byte(v), nil
+	default:
+		return []byte(s.value), nil
+	}
+}
+
+// check reports whether the source of the secret is available, without
+// reading it.
+func (s Secret) check() error {
+	switch s.source {
+	case secretFile:
+		info, err := os.Stat(s.value)
+		if err != nil {
+			return fmt.Errorf("cannot read secret %v: %v", s, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("cannot read secret %v: %q is a directory", s, s.value)
+		}
+	case secretEnv:
+		if _, ok := os.LookupEnv(s.value); !ok {
+			return fmt.Errorf("cannot read secret %v: environment variable %q is not set", s, s.value)
+		}
+	}
+	return nil
+}
+
+// String returns the reference of the secret, or a redacted placeholder for
+// inline secrets.
+func (s Secret) String() string {
+	switch s.source {
+	case secretFile:
+		return _secretFilePrefix + s.value
+	case secretEnv:
+		return _secretEnvPrefix + s.value
+	}
+	if s.value == "" {
+		return ""
+	}
+	return _redactedSecret
+}
+
+// GoString redacts the secret like String, for the %#v verb.
+func (s Secret) GoString() string {
+	return fmt.Sprintf("yarpcconfig.Secret(%q)", s.String())
+}
+
+// MarshalText redacts the secret like String, so that the secret is redacted
+// in JSON and YAML.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
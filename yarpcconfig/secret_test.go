@@ -0,0 +1,284 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/yarpcconfig"
+	"gopkg.in/yaml.v2"
+)
+
+type secretInboundConfig struct {
+	Address string             `config:"address"`
+	Token   yarpcconfig.Secret `config:"token"`
+}
+
+// secretTransportSpec returns a spec whose inbounds record their
+// configuration.
+func secretTransportSpec(got *secretInboundConfig) yarpcconfig.TransportSpec {
+	return yarpcconfig.TransportSpec{
+		Name: "secretive",
+		BuildTransport: func(struct{}, *yarpcconfig.Kit) (transport.Transport, error) {
+			return http.NewTransport(), nil
+		},
+		BuildInbound: func(cfg *secretInboundConfig, _ transport.Transport, _ *yarpcconfig.Kit) (transport.Inbound, error) {
+			*got = *cfg
+			return http.NewTransport().NewInbound(cfg.Address), nil
+		},
+	}
+}
+
+func loadSecret(t *testing.T, give string) (secretInboundConfig, error) {
+	var got secretInboundConfig
+	cfg := yarpcconfig.New()
+	cfg.MustRegisterTransport(secretTransportSpec(&got))
+
+	var data map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(whitespace.Expand(give)), &data))
+	_, err := cfg.LoadConfig("myservice", data)
+	return got, err
+}
+
+func setEnv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	require.NoError(t, os.Setenv(key, value))
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestSecretSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yarpcconfig-secret")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(path, []byte("from-file\n"), 0600))
+	setEnv(t, "YARPCCONFIG_TEST_TOKEN", "from-env")
+
+	tests := []struct {
+		desc       string
+		give       string
+		wantValue  string
+		wantString string
+	}{
+		{
+			desc:       "file",
+			give:       "file://" + path,
+			wantValue:  "from-file",
+			wantString: "file://" + path,
+		},
+		{
+			desc:       "env",
+			give:       "env://YARPCCONFIG_TEST_TOKEN",
+			wantValue:  "from-env",
+			wantString: "env://YARPCCONFIG_TEST_TOKEN",
+		},
+		{
+			desc:       "inline",
+			give:       "hunter2",
+			wantValue:  "hunter2",
+			wantString: "[REDACTED]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := loadSecret(t, fmt.Sprintf(`
+				inbounds:
+					secretive:
+						address: ":0"
+						token: %q
+			`, tt.give))
+			require.NoError(t, err)
+
+			value, err := got.Token.Resolve()
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantValue, string(value))
+			assert.False(t, got.Token.IsZero())
+			assert.Equal(t, tt.wantString, got.Token.String())
+		})
+	}
+}
+
+func TestSecretFileIsReadOnResolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yarpcconfig-secret")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(path, []byte("first\r\n"), 0600))
+
+	secret, err := yarpcconfig.ParseSecret("file://" + path)
+	require.NoError(t, err)
+
+	value, err := secret.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(value))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("second"), 0600))
+	value, err = secret.Resolve()
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(value), "secret must be read again")
+
+	require.NoError(t, os.Remove(path))
+	_, err = secret.Resolve()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot read secret file://"+path)
+}
+
+func TestSecretUnset(t *testing.T) {
+	got, err := loadSecret(t, `
+		inbounds:
+			secretive:
+				address: ":0"
+	`)
+	require.NoError(t, err)
+	assert.True(t, got.Token.IsZero())
+	assert.Equal(t, "", got.Token.String())
+
+	value, err := got.Token.Resolve()
+	require.NoError(t, err)
+	assert.Empty(t, value)
+}
+
+func TestSecretErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr string
+	}{
+		{
+			desc:    "missing env",
+			give:    `token: env://YARPCCONFIG_TEST_UNSET`,
+			wantErr: `invalid secret at inbounds.secretive.token: cannot read secret env://YARPCCONFIG_TEST_UNSET: environment variable "YARPCCONFIG_TEST_UNSET" is not set`,
+		},
+		{
+			desc:    "missing file",
+			give:    `token: file://testdata/does-not-exist`,
+			wantErr: `invalid secret at inbounds.secretive.token: cannot read secret file://testdata/does-not-exist`,
+		},
+		{
+			desc:    "empty file path",
+			give:    `token: "file://"`,
+			wantErr: `invalid secret at inbounds.secretive.token: secret "file://" has no file path`,
+		},
+		{
+			desc:    "empty env name",
+			give:    `token: "env://"`,
+			wantErr: `invalid secret at inbounds.secretive.token: secret "env://" has no environment variable name`,
+		},
+		{
+			desc:    "not a string",
+			give:    `token: [a, b]`,
+			wantErr: `could not decode secret: expected a string, got []interface {}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			_, err := loadSecret(t, `
+				inbounds:
+					secretive:
+						address: ":0"
+						`+tt.give+`
+			`)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestSecretRedaction(t *testing.T) {
+	inline, err := yarpcconfig.ParseSecret("hunter2")
+	require.NoError(t, err)
+	env, err := yarpcconfig.ParseSecret("env://TOKEN")
+	require.NoError(t, err)
+
+	cfg := struct {
+		Inline yarpcconfig.Secret `json:"inline" yaml:"inline"`
+		Env    yarpcconfig.Secret `json:"env" yaml:"env"`
+	}{Inline: inline, Env: env}
+
+	for _, out := range []string{
+		fmt.Sprint(inline),
+		fmt.Sprintf("%v", cfg),
+		fmt.Sprintf("%+v", cfg),
+		fmt.Sprintf("%#v", cfg),
+	} {
+		assert.NotContains(t, out, "hunter2")
+		assert.Contains(t, out, "[REDACTED]")
+	}
+	assert.Equal(t, `yarpcconfig.Secret("[REDACTED]")`, fmt.Sprintf("%#v", inline))
+
+	b, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"inline": "[REDACTED]", "env": "env://TOKEN"}`, string(b))
+
+	b, err = yaml.Marshal(cfg)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "hunter2")
+	assert.Contains(t, string(b), "env://TOKEN")
+}
+
+func TestSecretReload(t *testing.T) {
+	setEnv(t, "YARPCCONFIG_TEST_CLIENT_SECRET", "hunter2")
+	config := `
+		outbounds:
+			backend:
+				unary:
+					http:
+						url: http://127.0.0.1:8080/
+						oauth2:
+							tokenURL: http://127.0.0.1:8080/token
+							clientID: client
+							clientSecret: env://YARPCCONFIG_TEST_CLIENT_SECRET
+	`
+	rd, err := newReloadConfigurator().NewReloadableDispatcher("client",
+		mustParseYAML(t, whitespace.Expand(config)))
+	require.NoError(t, err)
+
+	// Secrets are resolved again when the configuration reloads.
+	require.NoError(t, os.Unsetenv("YARPCCONFIG_TEST_CLIENT_SECRET"))
+	err = rd.Reload(mustParseYAML(t, whitespace.Expand(config)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(),
+		`invalid secret at outbounds.backend.unary.http.oauth2.clientSecret: `+
+			`cannot read secret env://YARPCCONFIG_TEST_CLIENT_SECRET`)
+
+	setEnv(t, "YARPCCONFIG_TEST_CLIENT_SECRET", "hunter3")
+	assert.NoError(t, rd.Reload(mustParseYAML(t, whitespace.Expand(config))))
+}
@@ -28,6 +28,7 @@ import (
 
 	"github.com/uber-go/mapdecode"
 	"go.uber.org/multierr"
+	"go.uber.org/yarpc/api/middleware"
 	"go.uber.org/yarpc/api/peer"
 	"go.uber.org/yarpc/api/transport"
 	"go.uber.org/yarpc/internal/config"
@@ -120,6 +121,15 @@ type TransportSpec struct {
 	// configuration.
 	PeerChooserPresets []PeerChooserPreset
 
+	// Deprecated names of attributes, mapped to their current names.
+	//
+	// Configuration for the transport, its inbounds, or its outbounds that
+	// uses a deprecated name is decoded as if it used the current name, with
+	// a warning, rather than failing with unknown attributes.
+	//
+	// 	DeprecatedAliases: map[string]string{"keepalive": "keepAlive"}
+	DeprecatedAliases map[string]string
+
 	// TODO(abg): Allow functions to return and accept specific
 	// implementations. Instead of returning a transport.Transport and
 	// accepting a transport.Transport, we could make it so that
@@ -178,6 +188,12 @@ type PeerChooserSpec struct {
 	//
 	// BuildPeerChooser is required.
 	BuildPeerChooser interface{}
+
+	// Deprecated names of attributes, mapped to their current names.
+	//
+	// Configuration that uses a deprecated name is decoded as if it used the
+	// current name, with a warning.
+	DeprecatedAliases map[string]string
 }
 
 // PeerListSpec specifies the configuration parameters for an outbound peer
@@ -208,6 +224,12 @@ type PeerListSpec struct {
 	//
 	// BuildPeerList is required.
 	BuildPeerList interface{}
+
+	// Deprecated names of attributes, mapped to their current names.
+	//
+	// Configuration that uses a deprecated name is decoded as if it used the
+	// current name, with a warning.
+	DeprecatedAliases map[string]string
 }
 
 // PeerListUpdaterSpec specifies the configuration parameters for an outbound
@@ -234,7 +256,9 @@ type PeerListUpdaterSpec struct {
 	//  func(C, *config.Kit) (peer.Binder, error)
 	//
 	// Where C is a struct or pointer to a struct defining the configuration
-	// parameters accepted by this peer chooser.
+	// parameters accepted by this peer chooser. C may implement
+	// mapdecode.Decoder to accept a shorthand that is not a map, like
+	// "peers-file: /etc/hosts.json".
 	//
 	// The returned peer binder will receive the peer list specified alongside
 	// the peer updater; it should return a peer updater that feeds updates to
@@ -244,6 +268,47 @@ type PeerListUpdaterSpec struct {
 	BuildPeerListUpdater interface{}
 }
 
+// MiddlewareSpec specifies the configuration parameters for named
+// middleware. These specifications are registered against a Configurator to
+// teach it how to parse the configuration for that middleware and build
+// instances of it, which are placed in the middleware chain of the
+// dispatcher by their constraints.
+//
+// For example, we could implement and register a middleware spec that audits
+// inbound requests to a file.
+//
+// 	middleware:
+// 	  audit:
+// 	    path: /var/log/audit.log
+//
+// Middleware configured under the 'inbound' or 'outbound' attributes of the
+// 'middleware' section apply to inbound requests or outbound calls only.
+//
+// 	middleware:
+// 	  inbound:
+// 	    - audit:
+// 	        path: /var/log/audit.log
+type MiddlewareSpec struct {
+	// Name of the middleware.
+	Name string
+
+	// A function in the shape,
+	//
+	//  func(C, *config.Kit) (X, error)
+	//
+	// Where C is a struct or pointer to a struct defining the configuration
+	// parameters accepted by this middleware, and X is any type whose values
+	// implement one or more of the inbound and outbound middleware
+	// interfaces of the api/middleware package.
+	//
+	// BuildMiddleware is required.
+	BuildMiddleware interface{}
+
+	// Constraints place the middleware in the chain relative to other named
+	// middleware.
+	Constraints []middleware.Constraint
+}
+
 var (
 	_typeOfError           = reflect.TypeOf((*error)(nil)).Elem()
 	_typeOfTransport       = reflect.TypeOf((*transport.Transport)(nil)).Elem()
@@ -273,6 +338,9 @@ type compiledTransportSpec struct {
 	StreamOutbound *configSpec
 
 	PeerChooserPresets map[string]*compiledPeerChooserPreset
+
+	// Deprecated names of attributes, mapped to their current names.
+	Aliases map[string]string
 }
 
 func (s *compiledTransportSpec) SupportsUnaryOutbound() bool {
@@ -294,6 +362,12 @@ func compileTransportSpec(spec *TransportSpec) (*compiledTransportSpec, error) {
 		return nil, errors.New("field Name is required")
 	}
 
+	aliases, err := compileAliases(spec.DeprecatedAliases)
+	if err != nil {
+		return nil, err
+	}
+	out.Aliases = aliases
+
 	switch strings.ToLower(spec.Name) {
 	case "unary", "oneway", "stream":
 		return nil, fmt.Errorf("transport name cannot be %q: %q is a reserved name", spec.Name, spec.Name)
@@ -303,8 +377,6 @@ func compileTransportSpec(spec *TransportSpec) (*compiledTransportSpec, error) {
 		return nil, errors.New("field BuildTransport is required")
 	}
 
-	var err error
-
 	// Helper to chain together the compile calls
 	appendError := func(cs *configSpec, e error) *configSpec {
 		err = multierr.Append(err, e)
@@ -517,6 +589,7 @@ func compilePeerChooserPreset(preset PeerChooserPreset) (*compiledPeerChooserPre
 type compiledPeerChooserSpec struct {
 	Name        string
 	PeerChooser *configSpec
+	Aliases     map[string]string
 }
 
 func compilePeerChooserSpec(spec *PeerChooserSpec) (*compiledPeerChooserSpec, error) {
@@ -536,6 +609,10 @@ func compilePeerChooserSpec(spec *PeerChooserSpec) (*compiledPeerChooserSpec, er
 	}
 	out.PeerChooser = buildPeerChooser
 
+	if out.Aliases, err = compileAliases(spec.DeprecatedAliases); err != nil {
+		return nil, err
+	}
+
 	return &out, nil
 }
 
@@ -574,6 +651,7 @@ func compilePeerChooserConfig(build interface{}) (*configSpec, error) {
 type compiledPeerListSpec struct {
 	Name     string
 	PeerList *configSpec
+	Aliases  map[string]string
 }
 
 func compilePeerListSpec(spec *PeerListSpec) (*compiledPeerListSpec, error) {
@@ -593,6 +671,10 @@ func compilePeerListSpec(spec *PeerListSpec) (*compiledPeerListSpec, error) {
 	}
 	out.PeerList = buildPeerList
 
+	if out.Aliases, err = compileAliases(spec.DeprecatedAliases); err != nil {
+		return nil, err
+	}
+
 	return &out, nil
 }
 
@@ -627,6 +709,25 @@ func compilePeerListConfig(build interface{}) (*configSpec, error) {
 	return &configSpec{inputType: t.In(0), factory: v}, nil
 }
 
+// compileAliases validates and copies the deprecated aliases of a spec.
+func compileAliases(aliases map[string]string) (map[string]string, error) {
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+
+	out := make(map[string]string, len(aliases))
+	for old, current := range aliases {
+		switch {
+		case old == "" || current == "":
+			return nil, fmt.Errorf("invalid deprecated alias %q of %q: names must not be empty", old, current)
+		case old == current:
+			return nil, fmt.Errorf("invalid deprecated alias %q: an alias must differ from the name it replaces", old)
+		}
+		out[old] = current
+	}
+	return out, nil
+}
+
 type compiledPeerListUpdaterSpec struct {
 	Name            string
 	PeerListUpdater *configSpec
@@ -681,6 +782,59 @@ func compilePeerListUpdaterConfig(name string, build interface{}) (*configSpec,
 	return &configSpec{inputType: t.In(0), factory: v}, nil
 }
 
+type compiledMiddlewareSpec struct {
+	Name        string
+	Middleware  *configSpec
+	Constraints []middleware.Constraint
+}
+
+func compileMiddlewareSpec(spec *MiddlewareSpec) (*compiledMiddlewareSpec, error) {
+	out := compiledMiddlewareSpec{Name: spec.Name, Constraints: spec.Constraints}
+
+	if spec.Name == "" {
+		return nil, errors.New("field Name is required")
+	}
+
+	if spec.BuildMiddleware == nil {
+		return nil, errors.New("field BuildMiddleware is required")
+	}
+
+	buildMiddleware, err := compileMiddlewareConfig(spec.BuildMiddleware)
+	if err != nil {
+		return nil, err
+	}
+	out.Middleware = buildMiddleware
+
+	return &out, nil
+}
+
+func compileMiddlewareConfig(build interface{}) (*configSpec, error) {
+	v := reflect.ValueOf(build)
+	t := v.Type()
+
+	var err error
+	switch {
+	case t.Kind() != reflect.Func:
+		err = errors.New("must be a function")
+	case t.NumIn() != 2:
+		err = fmt.Errorf("must accept exactly two arguments, found %v", t.NumIn())
+	case !isDecodable(t.In(0)):
+		err = fmt.Errorf("must accept a struct or struct pointer as its first argument, found %v", t.In(0))
+	case t.In(1) != _typeOfKit:
+		err = fmt.Errorf("must accept a %v as its second argument, found %v", _typeOfKit, t.In(1))
+	case t.NumOut() != 2:
+		err = fmt.Errorf("must return exactly two results, found %v", t.NumOut())
+	case t.Out(1) != _typeOfError:
+		err = fmt.Errorf("must return an error as its second result, found %v", t.Out(1))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid BuildMiddleware %v: %v", t, err)
+	}
+
+	return &configSpec{inputType: t.In(0), factory: v}, nil
+}
+
 // Validated representation of a configuration function specified by the user.
 type configSpec struct {
 	// Type of object expected by the factory function
@@ -697,8 +851,15 @@ type configSpec struct {
 
 // Decode the configuration for this type from the data map.
 func (cs *configSpec) Decode(attrs config.AttributeMap, opts ...mapdecode.Option) (*buildable, error) {
+	return cs.DecodeValue(attrs, opts...)
+}
+
+// DecodeValue decodes the configuration for this type from an arbitrary
+// value, allowing configuration types that implement mapdecode.Decoder to
+// accept shorthands that are not maps.
+func (cs *configSpec) DecodeValue(src interface{}, opts ...mapdecode.Option) (*buildable, error) {
 	inputConfig := reflect.New(cs.inputType)
-	if err := attrs.Decode(inputConfig.Interface(), opts...); err != nil {
+	if err := config.DecodeInto(inputConfig.Interface(), src, opts...); err != nil {
 		return nil, fmt.Errorf("failed to decode %v: %v", cs.inputType, err)
 	}
 	return &buildable{factory: cs.factory, inputData: inputConfig.Elem()}, nil
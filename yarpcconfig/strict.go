@@ -0,0 +1,665 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uber-go/mapdecode"
+	"go.uber.org/multierr"
+	"go.uber.org/yarpc/internal/config"
+	"go.uber.org/zap"
+)
+
+const _configTagName = "config"
+
+var (
+	_typeOfDecoder           = reflect.TypeOf((*mapdecode.Decoder)(nil)).Elem()
+	_typeOfPeerChooserConfig = reflect.TypeOf(PeerChooser{})
+	_typeOfDuration          = reflect.TypeOf(time.Duration(0))
+	_typeOfByteSize          = reflect.TypeOf(ByteSize(0))
+	_typeOfSecret            = reflect.TypeOf(Secret{})
+)
+
+// keyChecker checks the keys of configuration data against the
+// configuration types of the registered specs before the data is decoded. It
+// reads deprecated aliases as the keys they name, and rejects invalid
+// literals at their full paths.
+//
+// If strict, a typo in a key is reported at its full path, with the most
+// similar known key as a suggestion. Otherwise, unknown keys are left for the
+// decoder to report as it always has. Unknown names of transports and
+// middleware are left for the Configurator to report.
+type keyChecker struct {
+	c      *Configurator
+	strict bool
+	logger *zap.Logger
+}
+
+// configKey is a known key of a section of the configuration.
+type configKey struct {
+	name string
+
+	// check checks the value of the key, returning the value to decode.
+	check func(value interface{}, path string) (interface{}, error)
+}
+
+// section describes the keys of a map in the configuration.
+type section struct {
+	keys    []configKey
+	aliases map[string]string // deprecated names of keys
+	open    bool              // whether to accept any key
+}
+
+// keysOf returns the keys of the map data, and false if data is not a map
+// with string keys.
+func keysOf(data interface{}) (map[string]interface{}, bool) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		return v, true
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			s, ok := k.(string)
+			if !ok {
+				return nil, false
+			}
+			m[s] = item
+		}
+		return m, true
+	default:
+		return nil, false
+	}
+}
+
+// Check checks the keys of the configuration data, returning the data to
+// decode, without the keys that failed the check, and the errors of the
+// check.
+func (kc *keyChecker) Check(data interface{}) (interface{}, error) {
+	s := kc.structSection(reflect.TypeOf(yarpcConfig{}))
+	for i, key := range s.keys {
+		switch key.name {
+		case "inbounds":
+			s.keys[i].check = kc.checkInbounds
+		case "outbounds":
+			s.keys[i].check = kc.checkOutbounds
+		case "transports":
+			s.keys[i].check = kc.checkTransports
+		case "middleware":
+			s.keys[i].check = kc.checkMiddleware
+		case "defaults":
+			s.keys[i].check = kc.checkDefaults
+		}
+	}
+	return kc.checkMap(data, "", s)
+}
+
+// checkMap checks the keys of the map data against the keys of the section,
+// and the values of known keys with their checks. Deprecated aliases are read
+// as the keys they name, and unknown keys are dropped.
+//
+// Checks that fail return a nil value to drop the key.
+func (kc *keyChecker) checkMap(data interface{}, path string, s section) (interface{}, error) {
+	m, ok := keysOf(data)
+	if !ok {
+		// Leave values of the wrong shape to the decoder to report.
+		return data, nil
+	}
+
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs error
+	out := make(map[string]interface{}, len(m))
+	for _, name := range names {
+		value := m[name]
+		keyPath := joinPath(path, name)
+
+		key, ok := lookupKey(s.keys, name)
+		if !ok {
+			if current, isAlias := s.aliases[name]; isAlias {
+				if _, ok := m[current]; ok {
+					errs = multierr.Append(errs, fmt.Errorf(
+						"key %q is a deprecated alias of %q, which is also set", keyPath, current))
+					continue
+				}
+				key, ok = lookupKey(s.keys, current)
+				if ok {
+					kc.logger.Warn("configuration key is deprecated",
+						zap.String("key", keyPath), zap.String("replacement", current))
+					name = current
+				}
+			}
+		}
+
+		if !ok {
+			if s.open || !kc.strict {
+				out[name] = value
+				continue
+			}
+			errs = multierr.Append(errs, unknownKey(keyPath, name, s))
+			continue
+		}
+
+		if key.check != nil {
+			var err error
+			if value, err = key.check(value, keyPath); err != nil {
+				errs = multierr.Append(errs, err)
+				if value == nil {
+					continue
+				}
+			}
+		}
+		out[name] = value
+	}
+	return out, errs
+}
+
+// lookupKey finds the known key with the given name, ignoring case like the
+// decoder does if there is no exact match.
+func lookupKey(keys []configKey, name string) (configKey, bool) {
+	for _, key := range keys {
+		if key.name == name {
+			return key, true
+		}
+	}
+	for _, key := range keys {
+		if strings.EqualFold(key.name, name) {
+			return key, true
+		}
+	}
+	return configKey{}, false
+}
+
+// unknownKey reports an unknown key of the section, with the known keys.
+func unknownKey(path, name string, s section) error {
+	known := make([]string, len(s.keys))
+	for i, key := range s.keys {
+		known[i] = key.name
+	}
+	sort.Strings(known)
+	return fmt.Errorf("unknown key %q at %v%v; need one of %v",
+		name, path, didYouMean(suggest(name, known)), strings.Join(known, ", "))
+}
+
+// didYouMean formats a suggestion for an error message, if any.
+func didYouMean(suggestion string) string {
+	if suggestion == "" {
+		return ""
+	}
+	return fmt.Sprintf(": did you mean %q?", suggestion)
+}
+
+// suggest returns the known name closest to the given name by edit distance,
+// ignoring case, or "" if none is close enough to be a likely typo.
+func suggest(name string, known []string) string {
+	maxDistance := len(name) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	var best string
+	for _, k := range known {
+		d := editDistance(strings.ToLower(name), strings.ToLower(k))
+		if d < maxDistance || (d == maxDistance && (best == "" || k < best)) {
+			best, maxDistance = k, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// checkValue checks the keys of a value that decodes into the given type,
+// and the literals of durations, sizes and secrets, so that their errors name
+// the path of the value. Values of other types that decode themselves are
+// accepted as-is.
+func (kc *keyChecker) checkValue(t reflect.Type, data interface{}, path string) (interface{}, error) {
+	for ; t.Kind() == reflect.Ptr; t = t.Elem() {
+	}
+	switch {
+	case !kc.isLiteral(data):
+		// Leave the value for the decoder to interpolate or report.
+	case t == _typeOfDuration:
+		if _, err := config.ParseDuration(data); err != nil {
+			return nil, fmt.Errorf("invalid duration at %s: %v", path, err)
+		}
+		return data, nil
+	case t == _typeOfByteSize:
+		if _, err := config.ParseByteSize(data); err != nil {
+			return nil, fmt.Errorf("invalid size at %s: %v", path, err)
+		}
+		return data, nil
+	case t == _typeOfSecret:
+		if err := checkSecret(data); err != nil {
+			return nil, fmt.Errorf("invalid secret at %s: %v", path, err)
+		}
+		return data, nil
+	}
+	if t.Implements(_typeOfDecoder) || reflect.PtrTo(t).Implements(_typeOfDecoder) {
+		return data, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return kc.checkMap(data, path, kc.structSection(t))
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || t.Elem().Kind() == reflect.Interface {
+			return data, nil
+		}
+		return kc.checkEach(data, path, func(_ string, value interface{}, path string) (interface{}, error) {
+			return kc.checkValue(t.Elem(), value, path)
+		})
+	case reflect.Slice:
+		items, ok := data.([]interface{})
+		if !ok || t.Elem().Kind() == reflect.Interface {
+			return data, nil
+		}
+		var errs error
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := kc.checkValue(t.Elem(), item, fmt.Sprintf("%s[%d]", path, i))
+			errs = multierr.Append(errs, err)
+			out[i] = v
+		}
+		return out, errs
+	default:
+		return data, nil
+	}
+}
+
+// checkSecret checks that the data references a secret whose source is
+// available, without resolving it.
+func checkSecret(data interface{}) error {
+	s, ok := data.(string)
+	if !ok {
+		return fmt.Errorf("expected a string, got %T", data)
+	}
+	secret, err := ParseSecret(s)
+	if err != nil {
+		return err
+	}
+	return secret.check()
+}
+
+// isLiteral reports whether the data is a literal of a primitive type, other
+// than a string with variables that the decoder interpolates.
+func (kc *keyChecker) isLiteral(data interface{}) bool {
+	switch v := data.(type) {
+	case nil, map[string]interface{}, map[interface{}]interface{}, []interface{}:
+		return false
+	case string:
+		return !kc.c.disableExpansion || !strings.Contains(v, "${")
+	default:
+		return true
+	}
+}
+
+// checkEach checks every value of the map data, whatever its key.
+func (kc *keyChecker) checkEach(
+	data interface{}, path string, check func(name string, value interface{}, path string) (interface{}, error),
+) (interface{}, error) {
+	m, ok := keysOf(data)
+	if !ok {
+		return data, nil
+	}
+
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs error
+	out := make(map[string]interface{}, len(m))
+	for _, name := range names {
+		v, err := check(name, m[name], joinPath(path, name))
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			if v == nil {
+				continue
+			}
+		}
+		out[name] = v
+	}
+	return out, errs
+}
+
+// structSection returns the section of the keys that decode into the fields
+// of a struct type, including the fields of squashed structs. Structs that
+// squash unused keys into a map field accept any key.
+//
+// Structs that embed a PeerChooser also accept the names of the registered
+// peer choosers and peer lists.
+func (kc *keyChecker) structSection(t reflect.Type) (s section) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get(_configTagName), ",")
+
+		squash := field.Anonymous
+		for _, opt := range tag[1:] {
+			switch opt {
+			case "squash":
+				squash = true
+			case "nosquash":
+				squash = false
+			}
+		}
+
+		if squash {
+			switch field.Type.Kind() {
+			case reflect.Struct:
+				fieldSection := kc.structSection(field.Type)
+				s.keys = append(s.keys, fieldSection.keys...)
+				if field.Type == _typeOfPeerChooserConfig {
+					s.keys = append(s.keys, kc.peerChooserKeys()...)
+				} else {
+					s.open = s.open || fieldSection.open
+				}
+			case reflect.Map:
+				s.open = true
+			}
+			continue
+		}
+
+		name := tag[0]
+		if name == "" {
+			name = field.Name
+		}
+		fieldType := field.Type
+		s.keys = append(s.keys, configKey{
+			name: name,
+			check: func(value interface{}, path string) (interface{}, error) {
+				return kc.checkValue(fieldType, value, path)
+			},
+		})
+	}
+	return s
+}
+
+// specSection returns the section of the configuration of the given specs,
+// merging the keys that they have in common.
+func (kc *keyChecker) specSection(aliases map[string]string, specs ...*configSpec) (s section) {
+	s.aliases = aliases
+	for _, spec := range specs {
+		t := spec.inputType
+		for ; t.Kind() == reflect.Ptr; t = t.Elem() {
+		}
+		specSection := kc.structSection(t)
+		for _, key := range specSection.keys {
+			if _, ok := lookupKey(s.keys, key.name); !ok {
+				s.keys = append(s.keys, key)
+			}
+		}
+		s.open = s.open || specSection.open
+	}
+	return s
+}
+
+// peerChooserKeys returns the names of the registered peer choosers and peer
+// lists as keys of outbound configuration.
+func (kc *keyChecker) peerChooserKeys() []configKey {
+	var keys []configKey
+	for name, spec := range kc.c.knownPeerChoosers {
+		spec := spec
+		keys = append(keys, configKey{
+			name: name,
+			check: func(value interface{}, path string) (interface{}, error) {
+				return kc.checkMap(value, path, kc.specSection(spec.Aliases, spec.PeerChooser))
+			},
+		})
+	}
+	for name, spec := range kc.c.knownPeerLists {
+		if _, ok := kc.c.knownPeerChoosers[name]; ok {
+			continue // peer choosers take precedence
+		}
+		spec := spec
+		keys = append(keys, configKey{
+			name: name,
+			check: func(value interface{}, path string) (interface{}, error) {
+				return kc.checkPeerList(spec, value, path)
+			},
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].name < keys[j].name })
+	return keys
+}
+
+// checkPeerList checks the configuration of a peer list, which shares its
+// namespace with the peer list updater.
+func (kc *keyChecker) checkPeerList(spec *compiledPeerListSpec, data interface{}, path string) (interface{}, error) {
+	s := kc.specSection(spec.Aliases, spec.PeerList)
+	s.keys = append(s.keys, configKey{name: "peers"})
+	for name, updater := range kc.c.knownPeerListUpdaters {
+		updater := updater
+		s.keys = append(s.keys, configKey{
+			name: name,
+			check: func(value interface{}, path string) (interface{}, error) {
+				return kc.checkValue(updater.PeerListUpdater.inputType, value, path)
+			},
+		})
+	}
+	return kc.checkMap(data, path, s)
+}
+
+func (kc *keyChecker) checkTransports(data interface{}, path string) (interface{}, error) {
+	return kc.checkEach(data, path, func(name string, value interface{}, path string) (interface{}, error) {
+		spec, ok := kc.c.knownTransports[name]
+		if !ok {
+			return value, nil
+		}
+		return kc.checkMap(value, path, kc.specSection(spec.Aliases, spec.Transport))
+	})
+}
+
+func (kc *keyChecker) checkInbounds(data interface{}, path string) (interface{}, error) {
+	return kc.checkEach(data, path, func(name string, value interface{}, path string) (interface{}, error) {
+		attrs, ok := keysOf(value)
+		if !ok {
+			return value, nil
+		}
+
+		if t, hasType := attrs["type"]; hasType {
+			if name, ok = t.(string); !ok {
+				return value, nil
+			}
+		}
+		spec, ok := kc.c.knownTransports[name]
+		if !ok || spec.Inbound == nil {
+			return value, nil
+		}
+
+		s := kc.specSection(spec.Aliases, spec.Inbound)
+		s.keys = append(s.keys, configKey{name: "type"}, configKey{name: "disabled"})
+		return kc.checkMap(value, path, s)
+	})
+}
+
+func (kc *keyChecker) checkOutbounds(data interface{}, path string) (interface{}, error) {
+	return kc.checkEach(data, path, func(_ string, value interface{}, path string) (interface{}, error) {
+		// Keys other than these name transports, which are left for the
+		// Configurator to report if unknown.
+		s := section{open: true, keys: []configKey{
+			{name: "service"},
+			{name: "middleware", check: kc.checkMiddlewareList},
+			{name: "procedures", check: func(value interface{}, path string) (interface{}, error) {
+				return kc.checkValue(reflect.TypeOf(map[string]procedureConfig{}), value, path)
+			}},
+			{name: "unary", check: kc.outboundsChecker(func(s *compiledTransportSpec) *configSpec { return s.UnaryOutbound })},
+			{name: "oneway", check: kc.outboundsChecker(func(s *compiledTransportSpec) *configSpec { return s.OnewayOutbound })},
+			{name: "stream", check: kc.outboundsChecker(func(s *compiledTransportSpec) *configSpec { return s.StreamOutbound })},
+		}}
+		for name, spec := range kc.c.knownTransports {
+			spec := spec
+			s.keys = append(s.keys, configKey{
+				name: name,
+				check: func(value interface{}, path string) (interface{}, error) {
+					return kc.checkOutbound(spec, value, path)
+				},
+			})
+		}
+		return kc.checkMap(value, path, s)
+	})
+}
+
+// checkOutbound checks an outbound of the transport whose RPC types are
+// implicit, against the configuration sections of all its RPC types.
+func (kc *keyChecker) checkOutbound(spec *compiledTransportSpec, value interface{}, path string) (interface{}, error) {
+	var specs []*configSpec
+	for _, s := range []*configSpec{spec.UnaryOutbound, spec.OnewayOutbound, spec.StreamOutbound} {
+		if s != nil {
+			specs = append(specs, s)
+		}
+	}
+	if len(specs) == 0 {
+		return value, nil
+	}
+	return kc.checkMap(value, path, kc.specSection(spec.Aliases, specs...))
+}
+
+// checkDefaults checks the default attributes of the inbounds and outbounds
+// of each transport, so that a typo is reported in the defaults rather than
+// in every inbound or outbound they apply to.
+func (kc *keyChecker) checkDefaults(data interface{}, path string) (interface{}, error) {
+	return kc.checkMap(data, path, section{keys: []configKey{
+		{name: "inbounds", check: func(data interface{}, path string) (interface{}, error) {
+			return kc.checkEach(data, path, func(name string, value interface{}, path string) (interface{}, error) {
+				spec, ok := kc.c.knownTransports[name]
+				if !ok || spec.Inbound == nil {
+					return value, nil
+				}
+				return kc.checkMap(value, path, kc.specSection(spec.Aliases, spec.Inbound))
+			})
+		}},
+		{name: "outbounds", check: func(data interface{}, path string) (interface{}, error) {
+			return kc.checkEach(data, path, func(name string, value interface{}, path string) (interface{}, error) {
+				spec, ok := kc.c.knownTransports[name]
+				if !ok {
+					return value, nil
+				}
+				return kc.checkOutbound(spec, value, path)
+			})
+		}},
+	}})
+}
+
+// outboundsChecker checks the outbounds of an RPC type, keyed by transport,
+// against the configuration section of the transport for that RPC type.
+func (kc *keyChecker) outboundsChecker(
+	configSection func(*compiledTransportSpec) *configSpec,
+) func(interface{}, string) (interface{}, error) {
+	return func(data interface{}, path string) (interface{}, error) {
+		return kc.checkEach(data, path, func(name string, value interface{}, path string) (interface{}, error) {
+			spec, ok := kc.c.knownTransports[name]
+			if !ok {
+				return value, nil
+			}
+			cs := configSection(spec)
+			if cs == nil {
+				return value, nil
+			}
+			return kc.checkMap(value, path, kc.specSection(spec.Aliases, cs))
+		})
+	}
+}
+
+// checkMiddleware checks the middleware of a dispatcher, including the
+// middleware of inbounds and outbounds only.
+func (kc *keyChecker) checkMiddleware(data interface{}, path string) (interface{}, error) {
+	return kc.checkEach(data, path, func(name string, value interface{}, path string) (interface{}, error) {
+		if name == "inbound" || name == "outbound" {
+			return kc.checkMiddlewareList(value, path)
+		}
+		return kc.checkMiddlewareAttributes(name, value, path)
+	})
+}
+
+// checkMiddlewareList checks middleware configured either as a map or as a
+// list.
+func (kc *keyChecker) checkMiddlewareList(data interface{}, path string) (interface{}, error) {
+	items, ok := data.([]interface{})
+	if !ok {
+		return kc.checkEach(data, path, kc.checkMiddlewareAttributes)
+	}
+
+	var errs error
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		out[i] = item
+		m, ok := keysOf(item)
+		if !ok || len(m) != 1 {
+			// Leave names and items of the wrong shape to the decoder.
+			continue
+		}
+		for name, value := range m {
+			v, err := kc.checkMiddlewareAttributes(name, value, joinPath(fmt.Sprintf("%s[%d]", path, i), name))
+			errs = multierr.Append(errs, err)
+			out[i] = map[string]interface{}{name: v}
+		}
+	}
+	return out, errs
+}
+
+// checkMiddlewareAttributes checks the attributes of the named middleware.
+// Unknown names are left for the Configurator to report.
+func (kc *keyChecker) checkMiddlewareAttributes(name string, value interface{}, path string) (interface{}, error) {
+	spec, ok := kc.c.knownMiddleware[name]
+	if !ok {
+		return value, nil
+	}
+	return kc.checkMap(value, path, kc.specSection(nil, spec.Middleware))
+}
@@ -0,0 +1,339 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcconfig_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/internal/whitespace"
+	"go.uber.org/yarpc/peer/roundrobin"
+	"go.uber.org/yarpc/transport/http"
+	"go.uber.org/yarpc/yarpcconfig"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"gopkg.in/yaml.v2"
+)
+
+func newStrictConfigurator(t *testing.T, opts ...yarpcconfig.Option) *yarpcconfig.Configurator {
+	return newAliasingConfigurator(t, append(opts, yarpcconfig.StrictKeys())...)
+}
+
+func newAliasingConfigurator(t *testing.T, opts ...yarpcconfig.Option) *yarpcconfig.Configurator {
+	cfg := yarpcconfig.New(opts...)
+	cfg.MustRegisterTransport(http.TransportSpec())
+
+	aliased := roundrobin.Spec()
+	aliased.Name = "aliased-round-robin"
+	aliased.DeprecatedAliases = map[string]string{"cap": "capacity"}
+	require.NoError(t, cfg.RegisterPeerList(roundrobin.Spec()))
+	require.NoError(t, cfg.RegisterPeerList(aliased))
+	return cfg
+}
+
+func loadYAML(t *testing.T, cfg *yarpcconfig.Configurator, give string) error {
+	var data map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(whitespace.Expand(give)), &data))
+	_, err := cfg.LoadConfig("myservice", data)
+	return err
+}
+
+func TestStrictKeys(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    string
+		wantErr []string
+	}{
+		{
+			desc: "valid configuration",
+			give: `
+				inbounds:
+					http: {address: ":8080"}
+				outbounds:
+					keyvalue:
+						http:
+							url: http://127.0.0.1:8080/rpc
+					other:
+						unary:
+							http:
+								url: http://127.0.0.1:8081/rpc
+								round-robin:
+									capacity: 2
+									peers: [127.0.0.1:8081]
+				logging:
+					levels:
+						success: debug
+			`,
+		},
+		{
+			desc: "typo in inbound attribute",
+			give: `
+				inbounds:
+					http: {adress: ":8080"}
+			`,
+			wantErr: []string{
+				`unknown key "adress" at inbounds.http.adress: did you mean "address"?`,
+			},
+		},
+		{
+			desc: "unknown inbound attribute",
+			give: `
+				inbounds:
+					http:
+						address: ":8080"
+						wat: true
+			`,
+			wantErr: []string{`unknown key "wat" at inbounds.http.wat`},
+		},
+		{
+			desc: "typo in outbound attribute",
+			give: `
+				outbounds:
+					keyvalue:
+						http:
+							urll: http://127.0.0.1:8080/rpc
+			`,
+			wantErr: []string{
+				`unknown key "urll" at outbounds.keyvalue.http.urll: did you mean "url"?`,
+			},
+		},
+		{
+			desc: "typo in procedure options",
+			give: `
+				outbounds:
+					keyvalue:
+						http:
+							url: http://127.0.0.1:8080/rpc
+						procedures:
+							KeyValue::GetValue:
+								timout: 200ms
+								retries: {maxAttempts: 2}
+			`,
+			wantErr: []string{
+				`unknown key "timout" at outbounds.keyvalue.procedures.KeyValue::GetValue.timout: did you mean "timeout"?`,
+			},
+		},
+		{
+			desc: "typo in peer chooser name",
+			give: `
+				outbounds:
+					keyvalue:
+						http:
+							round-robbin:
+								peers: [127.0.0.1:8080]
+			`,
+			wantErr: []string{
+				`unknown key "round-robbin" at outbounds.keyvalue.http.round-robbin: did you mean "round-robin"?`,
+			},
+		},
+		{
+			desc: "typo in peer list attribute",
+			give: `
+				outbounds:
+					keyvalue:
+						unary:
+							http:
+								round-robin:
+									capacty: 2
+									peers: [127.0.0.1:8080]
+			`,
+			wantErr: []string{
+				`unknown key "capacty" at outbounds.keyvalue.unary.http.round-robin.capacty: did you mean "capacity"?`,
+			},
+		},
+		{
+			desc: "typo in top-level key",
+			give: `
+				loging:
+					levels:
+						success: debug
+			`,
+			wantErr: []string{`unknown key "loging" at loging: did you mean "logging"?`},
+		},
+		{
+			desc: "typo in transport name",
+			give: `
+				inbounds:
+					htp: {address: ":8080"}
+			`,
+			wantErr: []string{`unknown transport "htp": did you mean "http"?`},
+		},
+		{
+			desc: "several unknown keys",
+			give: `
+				inbounds:
+					http: {adress: ":8080"}
+				outbounds:
+					keyvalue:
+						http:
+							urll: http://127.0.0.1:8080/rpc
+			`,
+			wantErr: []string{
+				`unknown key "adress" at inbounds.http.adress`,
+				`unknown key "urll" at outbounds.keyvalue.http.urll`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := loadYAML(t, newStrictConfigurator(t), tt.give)
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, msg := range tt.wantErr {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
+
+func TestStrictKeysListsKnownKeys(t *testing.T) {
+	err := loadYAML(t, newStrictConfigurator(t), `
+		outbounds:
+			keyvalue:
+				unary:
+					http:
+						round-robin:
+							peers: [127.0.0.1:8080]
+							bogus-updater: {}
+	`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(),
+		`unknown key "bogus-updater" at outbounds.keyvalue.unary.http.round-robin.bogus-updater; `+
+			`need one of capacity, defaultChooseTimeout, failFast, maxPendingRequests, peers, slowStart, slowStartCurve`)
+
+	err = loadYAML(t, newStrictConfigurator(t), `
+		inbounds:
+			http: {adress: ":8080"}
+	`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `did you mean "address"?; need one of address, `)
+}
+
+func TestUnknownKeysWithoutStrictKeys(t *testing.T) {
+	err := loadYAML(t, newAliasingConfigurator(t), `
+		inbounds:
+			http: {address: ":8080", adress: ":8081"}
+	`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid keys: adress",
+		"expected the HTTP inbound to report the unknown key")
+	assert.NotContains(t, err.Error(), "unknown key")
+
+	err = loadYAML(t, newAliasingConfigurator(t), `
+		loging:
+			levels:
+				success: debug
+	`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid keys: loging")
+	assert.NotContains(t, err.Error(), "did you mean")
+}
+
+func TestDeprecatedAliases(t *testing.T) {
+	t.Run("warns", func(t *testing.T) {
+		core, logs := observer.New(zap.WarnLevel)
+		cfg := newStrictConfigurator(t, yarpcconfig.Logger(zap.New(core)))
+
+		err := loadYAML(t, cfg, `
+			outbounds:
+				keyvalue:
+					http:
+						url: http://127.0.0.1:8080/rpc
+						aliased-round-robin:
+							cap: 2
+							peers: [127.0.0.1:8080]
+		`)
+		require.NoError(t, err)
+
+		entries := logs.FilterMessage("configuration key is deprecated").AllUntimed()
+		require.Len(t, entries, 1)
+		assert.Equal(t, map[string]interface{}{
+			"key":         "outbounds.keyvalue.http.aliased-round-robin.cap",
+			"replacement": "capacity",
+		}, entries[0].ContextMap())
+	})
+
+	t.Run("without strict keys", func(t *testing.T) {
+		core, logs := observer.New(zap.WarnLevel)
+		cfg := newAliasingConfigurator(t, yarpcconfig.Logger(zap.New(core)))
+
+		err := loadYAML(t, cfg, `
+			outbounds:
+				keyvalue:
+					http:
+						url: http://127.0.0.1:8080/rpc
+						aliased-round-robin:
+							cap: 2
+							peers: [127.0.0.1:8080]
+		`)
+		require.NoError(t, err)
+		assert.Equal(t, 1, logs.FilterMessage("configuration key is deprecated").Len())
+	})
+
+	t.Run("alias and current name", func(t *testing.T) {
+		err := loadYAML(t, newStrictConfigurator(t), `
+			outbounds:
+				keyvalue:
+					http:
+						aliased-round-robin:
+							cap: 2
+							capacity: 3
+							peers: [127.0.0.1:8080]
+		`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(),
+			`key "outbounds.keyvalue.http.aliased-round-robin.cap" is a deprecated alias of "capacity", which is also set`)
+	})
+
+	t.Run("invalid aliases", func(t *testing.T) {
+		tests := []struct {
+			desc    string
+			give    map[string]string
+			wantErr string
+		}{
+			{
+				desc:    "empty name",
+				give:    map[string]string{"cap": ""},
+				wantErr: `invalid deprecated alias "cap" of "": names must not be empty`,
+			},
+			{
+				desc:    "same name",
+				give:    map[string]string{"capacity": "capacity"},
+				wantErr: `invalid deprecated alias "capacity": an alias must differ from the name it replaces`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				spec := roundrobin.Spec()
+				spec.DeprecatedAliases = tt.give
+				err := yarpcconfig.New().RegisterPeerList(spec)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			})
+		}
+	})
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcerrors
+
+import (
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+)
+
+// WithDetails returns a YARPC error with the given Protobuf messages attached
+// as error details, after any details already attached to the error.
+//
+// The details are carried as a serialized google.rpc.Status, which transports
+// map to rich gRPC status details or, for transports without such a concept,
+// a dedicated response header. Use Details to retrieve them.
+//
+// Errors that are not YARPC errors become errors with CodeUnknown, as with
+// FromError. If the error is nil, this will return nil. If a message cannot
+// be serialized, the serialization error is returned instead.
+func WithDetails(err error, details ...proto.Message) error {
+	st := FromError(err)
+	if st == nil {
+		return nil
+	}
+
+	pst, perr := statusProto(st)
+	if perr != nil {
+		return perr
+	}
+	for _, detail := range details {
+		any, aerr := types.MarshalAny(detail)
+		if aerr != nil {
+			return aerr
+		}
+		pst.Details = append(pst.Details, any)
+	}
+
+	b, merr := proto.Marshal(pst)
+	if merr != nil {
+		return merr
+	}
+	return st.WithDetails(b)
+}
+
+// Details returns the Protobuf messages attached to the error with
+// WithDetails, or by a gRPC server as status details.
+//
+// Details returns nil if the error has no details, and an error if the
+// details cannot be deserialized, most likely because a message type is not
+// registered with the Protobuf runtime.
+func Details(err error) ([]proto.Message, error) {
+	st, ok := fromError(err)
+	if !ok {
+		return nil, nil
+	}
+
+	pst, perr := statusProto(st)
+	if perr != nil {
+		return nil, perr
+	}
+	if len(pst.Details) == 0 {
+		return nil, nil
+	}
+
+	messages := make([]proto.Message, 0, len(pst.Details))
+	for _, any := range pst.Details {
+		var detail types.DynamicAny
+		if uerr := types.UnmarshalAny(any, &detail); uerr != nil {
+			return nil, uerr
+		}
+		messages = append(messages, detail.Message)
+	}
+	return messages, nil
+}
+
+// statusProto returns the google.rpc.Status for the given Status, decoding
+// its details if any. YARPC codes share their values with gRPC codes.
+func statusProto(st *Status) (*rpc.Status, error) {
+	pst := &rpc.Status{}
+	if details := st.Details(); len(details) > 0 {
+		if err := proto.Unmarshal(details, pst); err != nil {
+			return nil, Newf(CodeInternal, "failed to deserialize error details: %v", err)
+		}
+	}
+	pst.Code = int32(st.Code())
+	pst.Message = st.Message()
+	return pst, nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcerrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDetails(t *testing.T) {
+	err := WithDetails(InvalidArgumentErrorf("bad %s", "request"),
+		&types.StringValue{Value: "string value"},
+		&rpc.RetryInfo{RetryDelay: &types.Duration{Seconds: 1}},
+	)
+	require.Error(t, err)
+	assert.Equal(t, CodeInvalidArgument, FromError(err).Code())
+	assert.Equal(t, "bad request", FromError(err).Message())
+
+	var st rpc.Status
+	require.NoError(t, proto.Unmarshal(FromError(err).Details(), &st))
+	assert.Equal(t, int32(3), st.Code, "codes must match gRPC codes")
+	assert.Equal(t, "bad request", st.Message)
+
+	details, derr := Details(err)
+	require.NoError(t, derr)
+	assert.Equal(t, []proto.Message{
+		&types.StringValue{Value: "string value"},
+		&rpc.RetryInfo{RetryDelay: &types.Duration{Seconds: 1}},
+	}, details)
+
+	t.Run("appends to existing details", func(t *testing.T) {
+		err := WithDetails(err, &types.Int32Value{Value: 100})
+		details, derr := Details(err)
+		require.NoError(t, derr)
+		require.Len(t, details, 3)
+		assert.Equal(t, &types.Int32Value{Value: 100}, details[2])
+	})
+
+	t.Run("wrapped", func(t *testing.T) {
+		details, derr := Details(fmt.Errorf("wrapped: %w", err))
+		require.NoError(t, derr)
+		assert.Len(t, details, 2)
+	})
+}
+
+func TestWithDetailsNonYARPCError(t *testing.T) {
+	err := WithDetails(errors.New("great sadness"), &types.StringValue{Value: "string value"})
+	assert.Equal(t, CodeUnknown, FromError(err).Code())
+	assert.Equal(t, "great sadness", FromError(err).Message())
+
+	details, derr := Details(err)
+	require.NoError(t, derr)
+	assert.Equal(t, []proto.Message{&types.StringValue{Value: "string value"}}, details)
+}
+
+func TestWithDetailsNil(t *testing.T) {
+	assert.Nil(t, WithDetails(nil, &types.StringValue{}))
+}
+
+func TestDetailsWithoutDetails(t *testing.T) {
+	for _, err := range []error{
+		nil,
+		errors.New("great sadness"),
+		InternalErrorf("great sadness"),
+	} {
+		details, derr := Details(err)
+		assert.NoError(t, derr)
+		assert.Nil(t, details)
+	}
+}
+
+func TestDetailsInvalid(t *testing.T) {
+	t.Run("not a status", func(t *testing.T) {
+		_, err := Details(Newf(CodeInternal, "great sadness").WithDetails([]byte{0xff}))
+		assert.Error(t, err)
+	})
+	t.Run("unknown type", func(t *testing.T) {
+		b, err := proto.Marshal(&rpc.Status{
+			Details: []*types.Any{{TypeUrl: "type.googleapis.com/unknown.Message"}},
+		})
+		require.NoError(t, err)
+		_, err = Details(Newf(CodeInternal, "great sadness").WithDetails(b))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package yarpcfault provides middleware that injects faults into requests,
+// for resilience testing: latency, errors, and truncated responses.
+//
+// An Injector is both inbound and outbound middleware. It applies the first of
+// its rules that matches the service, procedure and caller of a request to a
+// sample of the matching requests.
+//
+// 	injector := yarpcfault.New(yarpcfault.Meter(scope))
+// 	err := injector.SetRules([]yarpcfault.Rule{
+// 		{
+// 			Procedure:    "Store::*",
+// 			Percentage:   10,
+// 			Latency:      100 * time.Millisecond,
+// 			Distribution: yarpcfault.Exponential,
+// 		},
+// 		{
+// 			Caller:     "batch-*",
+// 			Percentage: 1,
+// 			Code:       yarpcerrors.CodeUnavailable,
+// 		},
+// 	})
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name: "myservice",
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary: injector,
+// 		},
+// 	})
+//
+// Rules may be changed at any time with SetRules, or over HTTP, since the
+// Injector is an http.Handler that serves its rules as JSON on GET and
+// replaces them on PUT:
+//
+// 	http.Handle("/debug/yarpc/faults", injector)
+//
+// 	$ curl -X PUT localhost:8080/debug/yarpc/faults -d '{"rules": [
+// 	    {"procedure": "Store::get", "percentage": 50, "latency": "1s"}
+// 	  ]}'
+//
+// Inbound errors injected by the middleware are reported with the error name
+// "injected-fault" in the metrics of the dispatcher, so dashboards can exclude
+// them. Faults injected into outbound calls are never sent, so they are not
+// observed by the dispatcher at all. Every injected fault is counted in the
+// fault_injections metric of the Injector.
+package yarpcfault
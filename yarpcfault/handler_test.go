@@ -0,0 +1,91 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcfault
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+func serve(i *Injector, method, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	i.ServeHTTP(w, httptest.NewRequest(method, "/faults", strings.NewReader(body)))
+	return w
+}
+
+func TestServeHTTP(t *testing.T) {
+	i := New()
+
+	w := serve(i, http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"rules": []}`, w.Body.String())
+
+	w = serve(i, http.MethodPut, `{"rules": [
+		{"procedure": "Store::get", "percentage": 50, "latency": "1s", "distribution": "uniform"},
+		{"caller": "batch-*", "percentage": 1, "code": "unavailable", "message": "game day"}
+	]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	want := []Rule{
+		{Procedure: "Store::get", Percentage: 50, Latency: time.Second, Distribution: Uniform},
+		{Caller: "batch-*", Percentage: 1, Code: yarpcerrors.CodeUnavailable, Message: "game day"},
+	}
+	assert.Equal(t, want, i.Rules())
+
+	w = serve(i, http.MethodGet, "")
+	assert.JSONEq(t, `{"rules": [
+		{"procedure": "Store::get", "percentage": 50, "latency": "1s", "distribution": "uniform"},
+		{"caller": "batch-*", "percentage": 1, "code": "unavailable", "message": "game day"}
+	]}`, w.Body.String())
+}
+
+func TestServeHTTPErrors(t *testing.T) {
+	i := New()
+
+	tests := []struct {
+		desc     string
+		method   string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{desc: "malformed", method: http.MethodPut, body: `{"rules": [`, wantCode: http.StatusBadRequest, wantBody: "invalid rules"},
+		{desc: "bad latency", method: http.MethodPut, body: `{"rules": [{"latency": "soon"}]}`, wantCode: http.StatusBadRequest, wantBody: "invalid rules"},
+		{desc: "invalid rule", method: http.MethodPut, body: `{"rules": [{"percentage": 200}]}`, wantCode: http.StatusBadRequest, wantBody: "percentage must be between 0 and 100"},
+		{desc: "method", method: http.MethodDelete, wantCode: http.StatusMethodNotAllowed, wantBody: "method not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			w := serve(i, tt.method, tt.body)
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+			assert.Empty(t, i.Rules())
+		})
+	}
+}
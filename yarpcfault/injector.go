@@ -0,0 +1,302 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcfault
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+// ErrorName is the name that inbound errors injected by the middleware are
+// reported with in the metrics of the dispatcher.
+const ErrorName = "injected-fault"
+
+// Option customizes the behavior of an Injector.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	seed   int64
+	seeded bool
+	meter  *metrics.Scope
+	logger *zap.Logger
+}
+
+// Seed seeds the randomness that samples requests and draws latencies, so
+// that the faults injected into a sequence of requests are reproducible.
+//
+// Defaults to a seed from the current time.
+func Seed(seed int64) Option {
+	return optionFunc(func(o *options) {
+		o.seed = seed
+		o.seeded = true
+	})
+}
+
+// Meter sets the scope for the metrics of the injector.
+func Meter(meter *metrics.Scope) Option {
+	return optionFunc(func(o *options) {
+		o.meter = meter
+	})
+}
+
+// Logger sets the logger for the injector.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+var (
+	_ middleware.UnaryInbound   = (*Injector)(nil)
+	_ middleware.OnewayInbound  = (*Injector)(nil)
+	_ middleware.UnaryOutbound  = (*Injector)(nil)
+	_ middleware.OnewayOutbound = (*Injector)(nil)
+)
+
+// Injector is inbound and outbound middleware that injects faults into
+// requests, according to rules that may be changed at runtime.
+type Injector struct {
+	metrics *injectorMetrics
+	logger  *zap.Logger
+
+	lock   sync.Mutex
+	rules  []Rule
+	random *rand.Rand
+}
+
+// New returns an Injector without rules, which injects no faults until
+// given rules with SetRules.
+func New(opts ...Option) *Injector {
+	o := options{seed: time.Now().UnixNano()}
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.logger == nil {
+		o.logger = zap.NewNop()
+	}
+
+	return &Injector{
+		metrics: newInjectorMetrics(o.meter, o.logger),
+		logger:  o.logger,
+		random:  rand.New(rand.NewSource(o.seed)),
+	}
+}
+
+// SetRules replaces the rules of the injector. Requests are injected with
+// the faults of the first rule they match.
+//
+// Invalid rules are rejected, leaving the rules unchanged.
+func (i *Injector) SetRules(rules []Rule) error {
+	for idx := range rules {
+		if err := rules[idx].validate(); err != nil {
+			return fmt.Errorf("invalid fault injection rule %d: %v", idx, err)
+		}
+	}
+	rules = append([]Rule(nil), rules...)
+
+	i.lock.Lock()
+	i.rules = rules
+	i.lock.Unlock()
+	i.logger.Info("updated fault injection rules", zap.Int("rules", len(rules)))
+	return nil
+}
+
+// Rules returns the current rules of the injector.
+func (i *Injector) Rules() []Rule {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return append([]Rule(nil), i.rules...)
+}
+
+// fault is the fault to inject into a request.
+type fault struct {
+	latency  time.Duration
+	err      error
+	truncate bool
+	limit    int
+}
+
+// fault returns the fault to inject into the request, if it is sampled by
+// the first rule it matches.
+func (i *Injector) fault(req *transport.Request) (fault, bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	for idx := range i.rules {
+		r := &i.rules[idx]
+		if !r.matches(req) {
+			continue
+		}
+		if i.random.Float64()*100 >= r.Percentage {
+			return fault{}, false
+		}
+		f := fault{
+			latency:  r.latency(i.random),
+			truncate: r.Truncate,
+			limit:    r.TruncateTo,
+		}
+		if r.Code != yarpcerrors.CodeOK {
+			f.err = r.err()
+		}
+		return f, true
+	}
+	return fault{}, false
+}
+
+// inject delays the request and returns the error to fail it with, if any.
+func (i *Injector) inject(ctx context.Context, req *transport.Request, f fault) error {
+	if f.latency > 0 {
+		i.metrics.inject(req, _latencyFault)
+		timer := time.NewTimer(f.latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return yarpcerrors.DeadlineExceededErrorf(
+					"deadline exceeded during injected latency of %v for procedure %q of service %q", f.latency, req.Procedure, req.Service)
+			}
+			return yarpcerrors.CancelledErrorf(
+				"canceled during injected latency of %v for procedure %q of service %q", f.latency, req.Procedure, req.Service)
+		}
+	}
+	if f.err != nil {
+		i.metrics.inject(req, _errorFault)
+		return f.err
+	}
+	return nil
+}
+
+// Handle implements middleware.UnaryInbound.
+func (i *Injector) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) error {
+	f, ok := i.fault(req)
+	if !ok {
+		return h.Handle(ctx, req, resw)
+	}
+	if err := i.inject(ctx, req, f); err != nil {
+		setErrorName(resw)
+		return err
+	}
+	if f.truncate {
+		i.metrics.inject(req, _truncationFault)
+		resw = &truncatingWriter{ResponseWriter: resw, remaining: f.limit}
+	}
+	return h.Handle(ctx, req, resw)
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (i *Injector) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) error {
+	if f, ok := i.fault(req); ok {
+		if err := i.inject(ctx, req, f); err != nil {
+			return err
+		}
+	}
+	return h.HandleOneway(ctx, req)
+}
+
+// Call implements middleware.UnaryOutbound.
+func (i *Injector) Call(ctx context.Context, req *transport.Request, out transport.UnaryOutbound) (*transport.Response, error) {
+	f, ok := i.fault(req)
+	if !ok {
+		return out.Call(ctx, req)
+	}
+	if err := i.inject(ctx, req, f); err != nil {
+		return nil, err
+	}
+	res, err := out.Call(ctx, req)
+	if err != nil || !f.truncate || res.Body == nil {
+		return res, err
+	}
+
+	i.metrics.inject(req, _truncationFault)
+	res.Body = &truncatedBody{Reader: io.LimitReader(res.Body, int64(f.limit)), Closer: res.Body}
+	if res.BodySize > f.limit {
+		res.BodySize = f.limit
+	}
+	return res, nil
+}
+
+// CallOneway implements middleware.OnewayOutbound.
+func (i *Injector) CallOneway(ctx context.Context, req *transport.Request, out transport.OnewayOutbound) (transport.Ack, error) {
+	if f, ok := i.fault(req); ok {
+		if err := i.inject(ctx, req, f); err != nil {
+			return nil, err
+		}
+	}
+	return out.CallOneway(ctx, req)
+}
+
+func setErrorName(resw transport.ResponseWriter) {
+	if setter, ok := resw.(transport.ApplicationErrorMetaSetter); ok {
+		setter.SetApplicationErrorMeta(&transport.ApplicationErrorMeta{Name: ErrorName})
+	}
+}
+
+// truncatingWriter drops the bytes written beyond the remaining length of the
+// response, while reporting them as written to the handler.
+type truncatingWriter struct {
+	transport.ResponseWriter
+
+	remaining int
+}
+
+var _ transport.ApplicationErrorMetaSetter = (*truncatingWriter)(nil)
+
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if n > w.remaining {
+		p = p[:w.remaining]
+	}
+	w.remaining -= len(p)
+	if len(p) > 0 {
+		if _, err := w.ResponseWriter.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (w *truncatingWriter) SetApplicationErrorMeta(meta *transport.ApplicationErrorMeta) {
+	if setter, ok := w.ResponseWriter.(transport.ApplicationErrorMetaSetter); ok {
+		setter.SetApplicationErrorMeta(meta)
+	}
+}
+
+type truncatedBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcfault
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/internal/testtime"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// handler writes the body of the request back, counting its calls.
+type handler struct {
+	calls int
+}
+
+func (h *handler) Handle(_ context.Context, req *transport.Request, resw transport.ResponseWriter) error {
+	h.calls++
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	_, err = resw.Write(body)
+	return err
+}
+
+func (h *handler) HandleOneway(context.Context, *transport.Request) error {
+	h.calls++
+	return nil
+}
+
+// outbound responds with the body of the request, counting its calls.
+type outbound struct {
+	transport.Outbound
+
+	calls int
+}
+
+func (o *outbound) Call(_ context.Context, req *transport.Request) (*transport.Response, error) {
+	o.calls++
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &transport.Response{Body: ioutil.NopCloser(bytes.NewReader(body)), BodySize: len(body)}, nil
+}
+
+func (o *outbound) CallOneway(context.Context, *transport.Request) (transport.Ack, error) {
+	o.calls++
+	return nil, nil
+}
+
+func newRequest(procedure string) *transport.Request {
+	return &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: procedure,
+		Body:      bytes.NewReader([]byte("hello world")),
+	}
+}
+
+func newInjector(t *testing.T, rules []Rule, opts ...Option) *Injector {
+	i := New(opts...)
+	require.NoError(t, i.SetRules(rules))
+	return i
+}
+
+func TestNoRules(t *testing.T) {
+	h := &handler{}
+	resw := &transporttest.FakeResponseWriter{}
+	require.NoError(t, New().Handle(context.Background(), newRequest("get"), resw, h))
+	assert.Equal(t, 1, h.calls)
+	assert.Equal(t, "hello world", resw.Body.String())
+}
+
+func TestErrorInjection(t *testing.T) {
+	root := metrics.New()
+	i := newInjector(t, []Rule{
+		{Procedure: "Store::*", Caller: "batch-*", Percentage: 100, Code: yarpcerrors.CodeUnavailable, Message: "game day"},
+		{Procedure: "Store::get", Percentage: 100, Code: yarpcerrors.CodeInternal},
+	}, Meter(root.Scope()))
+
+	h := &handler{}
+	resw := &transporttest.FakeResponseWriter{}
+	err := i.Handle(context.Background(), newRequest("Store::get"), resw, h)
+	assert.Equal(t, yarpcerrors.InternalErrorf("injected fault"), err, "the first matching rule must apply")
+	assert.Equal(t, &transport.ApplicationErrorMeta{Name: ErrorName}, resw.ApplicationErrorMeta,
+		"injected errors must be told apart in metrics")
+	assert.Equal(t, 0, h.calls)
+
+	req := newRequest("Store::put")
+	require.NoError(t, i.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, h))
+	assert.Equal(t, 1, h.calls, "requests matching no rule must be handled")
+
+	out := &outbound{}
+	req = newRequest("Store::put")
+	req.Caller = "batch-jobs"
+	_, err = i.Call(context.Background(), req, out)
+	assert.Equal(t, yarpcerrors.UnavailableErrorf("game day"), err)
+	_, err = i.CallOneway(context.Background(), req, out)
+	assert.Equal(t, yarpcerrors.UnavailableErrorf("game day"), err)
+	assert.Equal(t, yarpcerrors.UnavailableErrorf("game day"), i.HandleOneway(context.Background(), req, h))
+	assert.Equal(t, 0, out.calls)
+	assert.Equal(t, 1, h.calls)
+
+	var injected int64
+	for _, c := range root.Snapshot().Counters {
+		if c.Name == "fault_injections" && c.Tags[_faultTag] == _errorFault {
+			injected += c.Value
+		}
+	}
+	assert.Equal(t, int64(4), injected)
+}
+
+func TestErrorInjectionRate(t *testing.T) {
+	const requests = 5000
+
+	i := newInjector(t, []Rule{{Percentage: 20, Code: yarpcerrors.CodeUnavailable}}, Seed(1))
+	h := &handler{}
+	var failures int
+	for n := 0; n < requests; n++ {
+		if err := i.Handle(context.Background(), newRequest("get"), &transporttest.FakeResponseWriter{}, h); err != nil {
+			failures++
+		}
+	}
+	assert.InDelta(t, requests/5, failures, requests/50, "expected about 20%% of requests to fail")
+	assert.Equal(t, requests-failures, h.calls)
+}
+
+func TestSeedIsReproducible(t *testing.T) {
+	rules := []Rule{{Percentage: 50, Latency: time.Millisecond, Distribution: Exponential}}
+	a := newInjector(t, rules, Seed(42))
+	b := newInjector(t, rules, Seed(42))
+	for n := 0; n < 100; n++ {
+		fa, oka := a.fault(newRequest("get"))
+		fb, okb := b.fault(newRequest("get"))
+		require.Equal(t, oka, okb, "request %d", n)
+		require.Equal(t, fa, fb, "request %d", n)
+	}
+}
+
+func TestLatencyInjection(t *testing.T) {
+	i := newInjector(t, []Rule{{Procedure: "slow", Percentage: 100, Latency: 20 * time.Millisecond}})
+	h := &handler{}
+
+	start := time.Now()
+	require.NoError(t, i.Handle(context.Background(), newRequest("slow"), &transporttest.FakeResponseWriter{}, h))
+	assert.True(t, time.Since(start) >= 20*time.Millisecond, "the request must be delayed")
+	assert.Equal(t, 1, h.calls)
+
+	start = time.Now()
+	_, err := i.Call(context.Background(), newRequest("slow"), &outbound{})
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) >= 20*time.Millisecond, "the call must be delayed")
+}
+
+func TestLatencyInjectionRespectsDeadline(t *testing.T) {
+	i := newInjector(t, []Rule{{Percentage: 100, Latency: time.Hour}})
+	h := &handler{}
+	out := &outbound{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	resw := &transporttest.FakeResponseWriter{}
+	err := i.Handle(ctx, newRequest("get"), resw, h)
+	assert.Equal(t, yarpcerrors.CodeDeadlineExceeded, yarpcerrors.FromError(err).Code())
+	assert.True(t, time.Since(start) < testtime.Second, "the delay must end with the deadline")
+	assert.Equal(t, &transport.ApplicationErrorMeta{Name: ErrorName}, resw.ApplicationErrorMeta)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = i.Call(ctx, newRequest("get"), out)
+	assert.Equal(t, yarpcerrors.CodeCancelled, yarpcerrors.FromError(err).Code())
+	assert.Equal(t, 0, h.calls)
+	assert.Equal(t, 0, out.calls)
+}
+
+func TestLatencyDistributions(t *testing.T) {
+	i := New(Seed(1))
+	const samples = 10000
+	mean := func(r Rule) time.Duration {
+		var total time.Duration
+		for n := 0; n < samples; n++ {
+			d := r.latency(i.random)
+			if r.Distribution == Uniform {
+				require.True(t, d >= 0 && d <= 2*r.Latency, "uniform latency %v out of range", d)
+			}
+			total += d
+		}
+		return total / samples
+	}
+
+	assert.Equal(t, 10*time.Millisecond, mean(Rule{Latency: 10 * time.Millisecond}))
+	assert.InDelta(t, float64(10*time.Millisecond), float64(mean(Rule{Latency: 10 * time.Millisecond, Distribution: Uniform})), float64(time.Millisecond))
+	assert.InDelta(t, float64(10*time.Millisecond), float64(mean(Rule{Latency: 10 * time.Millisecond, Distribution: Exponential})), float64(time.Millisecond))
+}
+
+func TestTruncation(t *testing.T) {
+	i := newInjector(t, []Rule{{Percentage: 100, Truncate: true, TruncateTo: 5}})
+
+	resw := &transporttest.FakeResponseWriter{}
+	require.NoError(t, i.Handle(context.Background(), newRequest("get"), resw, &handler{}))
+	assert.Equal(t, "hello", resw.Body.String())
+
+	res, err := i.Call(context.Background(), newRequest("get"), &outbound{})
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, 5, res.BodySize)
+	assert.NoError(t, res.Body.Close())
+}
+
+func TestSetRulesValidation(t *testing.T) {
+	tests := []struct {
+		desc    string
+		give    Rule
+		wantErr string
+	}{
+		{desc: "bad pattern", give: Rule{Procedure: "["}, wantErr: `invalid pattern "["`},
+		{desc: "percentage", give: Rule{Percentage: 101}, wantErr: "percentage must be between 0 and 100"},
+		{desc: "latency", give: Rule{Latency: -time.Second}, wantErr: "latency must not be negative"},
+		{desc: "distribution", give: Rule{Distribution: "normal"}, wantErr: `unknown latency distribution "normal"`},
+		{desc: "truncation", give: Rule{TruncateTo: -1}, wantErr: "truncation length must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			i := newInjector(t, []Rule{{Percentage: 1}})
+			err := i.SetRules([]Rule{{}, tt.give})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid fault injection rule 1: "+tt.wantErr)
+			assert.Equal(t, []Rule{{Percentage: 1}}, i.Rules(), "rules must be unchanged")
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcfault
+
+import (
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/zap"
+)
+
+const (
+	_serviceTag   = "service"
+	_procedureTag = "procedure"
+	_faultTag     = "fault"
+
+	_latencyFault    = "latency"
+	_errorFault      = "error"
+	_truncationFault = "truncation"
+)
+
+type injectorMetrics struct {
+	injections *metrics.CounterVector
+}
+
+func newInjectorMetrics(meter *metrics.Scope, logger *zap.Logger) *injectorMetrics {
+	injections, err := meter.CounterVector(metrics.Spec{
+		Name:    "fault_injections",
+		Help:    "Total number of faults injected into requests, by kind of fault.",
+		VarTags: []string{_serviceTag, _procedureTag, _faultTag},
+	})
+	if err != nil {
+		logger.Error("failed to create fault injections counter", zap.Error(err))
+	}
+	return &injectorMetrics{injections: injections}
+}
+
+func (m *injectorMetrics) inject(req *transport.Request, fault string) {
+	m.injections.MustGet(_serviceTag, req.Service, _procedureTag, req.Procedure, _faultTag, fault).Inc()
+}
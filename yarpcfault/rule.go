@@ -0,0 +1,196 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcfault
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"path"
+	"time"
+
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+)
+
+// Distribution is the distribution from which the injected latency of each
+// request is drawn.
+type Distribution string
+
+const (
+	// Fixed injects exactly the latency of the rule.
+	Fixed Distribution = "fixed"
+	// Uniform draws latencies uniformly between zero and twice the latency
+	// of the rule.
+	Uniform Distribution = "uniform"
+	// Exponential draws latencies from an exponential distribution whose
+	// mean is the latency of the rule, giving a long tail.
+	Exponential Distribution = "exponential"
+)
+
+// Rule describes the faults to inject into the requests it matches.
+type Rule struct {
+	// Service, Procedure and Caller are path.Match patterns for the service,
+	// procedure and caller of the requests the rule applies to. Empty
+	// patterns match every request.
+	Service   string
+	Procedure string
+	Caller    string
+
+	// Percentage is the percentage of matching requests, between 0 and 100,
+	// into which faults are injected.
+	Percentage float64
+
+	// Latency delays requests, drawn from the Distribution, which defaults
+	// to Fixed. Delays end early if the context of the request does.
+	Latency      time.Duration
+	Distribution Distribution
+
+	// Code fails requests with an error with this code and Message, after
+	// the latency. CodeOK injects no error.
+	Code    yarpcerrors.Code
+	Message string
+
+	// Truncate cuts the body of responses to at most TruncateTo bytes.
+	// Truncation does not apply to oneway requests.
+	Truncate   bool
+	TruncateTo int
+}
+
+func (r *Rule) validate() error {
+	for _, pattern := range []string{r.Service, r.Procedure, r.Caller} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	if r.Percentage < 0 || r.Percentage > 100 {
+		return fmt.Errorf("percentage must be between 0 and 100, got %v", r.Percentage)
+	}
+	if r.Latency < 0 {
+		return fmt.Errorf("latency must not be negative, got %v", r.Latency)
+	}
+	switch r.Distribution {
+	case "", Fixed, Uniform, Exponential:
+	default:
+		return fmt.Errorf("unknown latency distribution %q", r.Distribution)
+	}
+	if r.TruncateTo < 0 {
+		return fmt.Errorf("truncation length must not be negative, got %v", r.TruncateTo)
+	}
+	return nil
+}
+
+func (r *Rule) matches(req *transport.Request) bool {
+	return match(r.Service, req.Service) && match(r.Procedure, req.Procedure) && match(r.Caller, req.Caller)
+}
+
+func match(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// latency draws the latency of a request.
+func (r *Rule) latency(random *rand.Rand) time.Duration {
+	if r.Latency == 0 {
+		return 0
+	}
+	switch r.Distribution {
+	case Uniform:
+		return time.Duration(random.Int63n(2*int64(r.Latency) + 1))
+	case Exponential:
+		return time.Duration(random.ExpFloat64() * float64(r.Latency))
+	default:
+		return r.Latency
+	}
+}
+
+func (r *Rule) err() error {
+	message := r.Message
+	if message == "" {
+		message = "injected fault"
+	}
+	return yarpcerrors.Newf(r.Code, "%s", message)
+}
+
+// jsonRule is the representation of rules served by the Injector over HTTP,
+// with readable durations.
+type jsonRule struct {
+	Service      string           `json:"service,omitempty"`
+	Procedure    string           `json:"procedure,omitempty"`
+	Caller       string           `json:"caller,omitempty"`
+	Percentage   float64          `json:"percentage"`
+	Latency      string           `json:"latency,omitempty"`
+	Distribution Distribution     `json:"distribution,omitempty"`
+	Code         yarpcerrors.Code `json:"code,omitempty"`
+	Message      string           `json:"message,omitempty"`
+	Truncate     bool             `json:"truncate,omitempty"`
+	TruncateTo   int              `json:"truncateTo,omitempty"`
+}
+
+// MarshalJSON encodes the rule with its latency as a duration string.
+func (r Rule) MarshalJSON() ([]byte, error) {
+	jr := jsonRule{
+		Service:      r.Service,
+		Procedure:    r.Procedure,
+		Caller:       r.Caller,
+		Percentage:   r.Percentage,
+		Distribution: r.Distribution,
+		Code:         r.Code,
+		Message:      r.Message,
+		Truncate:     r.Truncate,
+		TruncateTo:   r.TruncateTo,
+	}
+	if r.Latency != 0 {
+		jr.Latency = r.Latency.String()
+	}
+	return json.Marshal(jr)
+}
+
+// UnmarshalJSON decodes a rule with its latency as a duration string.
+func (r *Rule) UnmarshalJSON(b []byte) error {
+	var jr jsonRule
+	if err := json.Unmarshal(b, &jr); err != nil {
+		return err
+	}
+	var latency time.Duration
+	if jr.Latency != "" {
+		var err error
+		if latency, err = time.ParseDuration(jr.Latency); err != nil {
+			return err
+		}
+	}
+	*r = Rule{
+		Service:      jr.Service,
+		Procedure:    jr.Procedure,
+		Caller:       jr.Caller,
+		Percentage:   jr.Percentage,
+		Latency:      latency,
+		Distribution: jr.Distribution,
+		Code:         jr.Code,
+		Message:      jr.Message,
+		Truncate:     jr.Truncate,
+		TruncateTo:   jr.TruncateTo,
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package yarpcrecovery provides inbound middleware that recovers panics in
+// handlers.
+//
+// The middleware turns a panic into an Internal error with a generic message,
+// so that the panic value never reaches the caller, logs it with the stack of
+// the handler, counts it, and passes it to an optional reporter, like a crash
+// reporting hook.
+//
+// 	recovery := yarpcrecovery.NewInboundMiddleware(
+// 		yarpcrecovery.Logger(logger),
+// 		yarpcrecovery.Meter(scope),
+// 		yarpcrecovery.OnPanic(func(ctx context.Context, meta *transport.RequestMeta, recovered interface{}, stack []byte) {
+// 			crashes.Report(recovered, stack, meta.Procedure)
+// 		}),
+// 	)
+// 	dispatcher := yarpc.NewDispatcher(yarpc.Config{
+// 		Name:     "myservice",
+// 		Inbounds: inbounds,
+// 		InboundMiddleware: yarpc.InboundMiddleware{
+// 			Unary:  recovery,
+// 			Oneway: recovery,
+// 			Stream: recovery,
+// 		},
+// 	})
+//
+// Reporters may also implement CrashReporter, and be set with ReportCrashes.
+// LogReporter is a CrashReporter that logs panics to a logger of its own.
+//
+// Recovering a panic is only possible in the goroutine that panicked, so the
+// middleware recovers panics of handlers, but not of goroutines that handlers
+// spawn: a panic in such a goroutine still crashes the process. Handlers that
+// spawn goroutines must recover their panics themselves.
+//
+// Dispatchers install the middleware, outside of all other inbound
+// middleware, when their PanicRecovery configuration is enabled, as it is for
+// dispatchers constructed with yarpcconfig.
+package yarpcrecovery
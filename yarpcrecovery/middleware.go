@@ -0,0 +1,203 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcrecovery
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/middleware"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+)
+
+const _procedureTag = "procedure"
+
+// Reporter receives the panics that the middleware recovers, with the
+// metadata of the request whose handler panicked and the stack of the
+// handler.
+type Reporter func(ctx context.Context, meta *transport.RequestMeta, recovered interface{}, stack []byte)
+
+// Option customizes the behavior of the recovery middleware.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+type options struct {
+	meter         *metrics.Scope
+	logger        *zap.Logger
+	reporter      Reporter
+	crashReporter CrashReporter
+}
+
+// Meter sets the scope for the metrics of the middleware.
+func Meter(meter *metrics.Scope) Option {
+	return optionFunc(func(o *options) {
+		o.meter = meter
+	})
+}
+
+// Logger sets the logger for the middleware.
+func Logger(logger *zap.Logger) Option {
+	return optionFunc(func(o *options) {
+		o.logger = logger
+	})
+}
+
+// OnPanic sets a reporter that the middleware calls with each panic that it
+// recovers, after logging it. Panics of the reporter are recovered and
+// logged.
+func OnPanic(r Reporter) Option {
+	return optionFunc(func(o *options) {
+		o.reporter = r
+	})
+}
+
+// ReportCrashes sets a crash reporter that the middleware calls with each
+// panic that it recovers, after logging it. Failures and panics of the crash
+// reporter are logged.
+func ReportCrashes(r CrashReporter) Option {
+	return optionFunc(func(o *options) {
+		o.crashReporter = r
+	})
+}
+
+var (
+	_ middleware.UnaryInbound  = (*Middleware)(nil)
+	_ middleware.OnewayInbound = (*Middleware)(nil)
+	_ middleware.StreamInbound = (*Middleware)(nil)
+)
+
+// Middleware is an inbound middleware that recovers panics in handlers.
+type Middleware struct {
+	logger        *zap.Logger
+	reporter      Reporter
+	crashReporter CrashReporter
+	panics        *metrics.CounterVector
+}
+
+// NewInboundMiddleware returns an inbound middleware that recovers panics in
+// unary, oneway, and stream handlers, failing their requests with an
+// Internal error.
+func NewInboundMiddleware(opts ...Option) *Middleware {
+	var o options
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.logger == nil {
+		o.logger = zap.NewNop()
+	}
+
+	panics, err := o.meter.CounterVector(metrics.Spec{
+		Name:    "panics",
+		Help:    "Total number of panics recovered in handlers, by procedure.",
+		VarTags: []string{_procedureTag},
+	})
+	if err != nil {
+		o.logger.Error("failed to create panics counter", zap.Error(err))
+	}
+
+	return &Middleware{
+		logger:        o.logger,
+		reporter:      o.reporter,
+		crashReporter: o.crashReporter,
+		panics:        panics,
+	}
+}
+
+// Handle implements middleware.UnaryInbound.
+func (m *Middleware) Handle(ctx context.Context, req *transport.Request, resw transport.ResponseWriter, h transport.UnaryHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = m.recovered(ctx, transport.Unary, req.ToRequestMeta(), r, debug.Stack())
+		}
+	}()
+	return h.Handle(ctx, req, resw)
+}
+
+// HandleOneway implements middleware.OnewayInbound.
+func (m *Middleware) HandleOneway(ctx context.Context, req *transport.Request, h transport.OnewayHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = m.recovered(ctx, transport.Oneway, req.ToRequestMeta(), r, debug.Stack())
+		}
+	}()
+	return h.HandleOneway(ctx, req)
+}
+
+// HandleStream implements middleware.StreamInbound.
+func (m *Middleware) HandleStream(s *transport.ServerStream, h transport.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = m.recovered(s.Context(), transport.Streaming, s.Request().Meta, r, debug.Stack())
+		}
+	}()
+	return h.HandleStream(s)
+}
+
+// recovered logs, counts, and reports a recovered panic, and returns the
+// error to fail its request with.
+func (m *Middleware) recovered(ctx context.Context, rpcType transport.Type, meta *transport.RequestMeta, r interface{}, stack []byte) error {
+	m.logger.Error(fmt.Sprintf("%s handler panicked", rpcType),
+		zap.String("service", meta.Service),
+		zap.String("procedure", meta.Procedure),
+		zap.String("caller", meta.Caller),
+		zap.String("transport", meta.Transport),
+		zap.String("encoding", string(meta.Encoding)),
+		zap.String("panic", fmt.Sprint(r)),
+		zap.ByteString("stack", stack),
+	)
+	m.panics.MustGet(_procedureTag, meta.Procedure).Inc()
+	if m.reporter != nil {
+		m.report(meta, func() { m.reporter(ctx, meta, r, stack) })
+	}
+	if m.crashReporter != nil {
+		m.report(meta, func() {
+			if err := m.crashReporter.Report(ctx, r, stack); err != nil {
+				m.logger.Error("failed to report panic",
+					zap.String("procedure", meta.Procedure),
+					zap.Error(err),
+				)
+			}
+		})
+	}
+	return yarpcerrors.InternalErrorf("handler for procedure %q of service %q panicked", meta.Procedure, meta.Service)
+}
+
+// report calls a reporter, recovering its panics.
+func (m *Middleware) report(meta *transport.RequestMeta, call func()) {
+	defer func() {
+		if rr := recover(); rr != nil {
+			m.logger.Error("panic reporter panicked",
+				zap.String("procedure", meta.Procedure),
+				zap.String("panic", fmt.Sprint(rr)),
+			)
+		}
+	}()
+	call()
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcrecovery
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/net/metrics"
+	"go.uber.org/yarpc/api/transport"
+	"go.uber.org/yarpc/api/transport/transporttest"
+	"go.uber.org/yarpc/yarpcerrors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// panickyHandler panics with a secret for every request.
+type panickyHandler struct{}
+
+func (panickyHandler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	panic("secret sauce")
+}
+
+func (panickyHandler) HandleOneway(context.Context, *transport.Request) error {
+	panic("secret sauce")
+}
+
+func (panickyHandler) HandleStream(*transport.ServerStream) error {
+	panic("secret sauce")
+}
+
+// failingHandler fails every request.
+type failingHandler struct{}
+
+func (failingHandler) Handle(context.Context, *transport.Request, transport.ResponseWriter) error {
+	return yarpcerrors.NotFoundErrorf("not found")
+}
+
+type report struct {
+	meta      *transport.RequestMeta
+	recovered interface{}
+	stack     string
+}
+
+func TestMiddleware(t *testing.T) {
+	req := &transport.Request{
+		Caller:    "caller",
+		Service:   "service",
+		Procedure: "get",
+		Transport: "fake",
+		Encoding:  "raw",
+	}
+
+	tests := []struct {
+		msg     string
+		rpcType transport.Type
+		give    func(*Middleware) error
+	}{
+		{
+			msg:     "unary",
+			rpcType: transport.Unary,
+			give: func(m *Middleware) error {
+				return m.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, panickyHandler{})
+			},
+		},
+		{
+			msg:     "oneway",
+			rpcType: transport.Oneway,
+			give: func(m *Middleware) error {
+				return m.HandleOneway(context.Background(), req, panickyHandler{})
+			},
+		},
+		{
+			msg:     "stream",
+			rpcType: transport.Streaming,
+			give: func(m *Middleware) error {
+				stream, err := transport.NewServerStream(&fakeStream{
+					ctx:     context.Background(),
+					request: &transport.StreamRequest{Meta: req.ToRequestMeta()},
+				})
+				require.NoError(t, err)
+				return m.HandleStream(stream, panickyHandler{})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			core, logs := observer.New(zapcore.ErrorLevel)
+			root := metrics.New()
+			var reports []report
+			m := NewInboundMiddleware(
+				Logger(zap.New(core)),
+				Meter(root.Scope()),
+				OnPanic(func(_ context.Context, meta *transport.RequestMeta, recovered interface{}, stack []byte) {
+					reports = append(reports, report{meta, recovered, string(stack)})
+				}),
+			)
+
+			err := tt.give(m)
+			require.Error(t, err)
+			assert.Equal(t, yarpcerrors.CodeInternal, yarpcerrors.FromError(err).Code())
+			assert.Equal(t, `handler for procedure "get" of service "service" panicked`, yarpcerrors.FromError(err).Message())
+			assert.NotContains(t, err.Error(), "secret sauce", "panic value must not reach the caller")
+
+			require.Len(t, reports, 1)
+			assert.Equal(t, req.ToRequestMeta(), reports[0].meta)
+			assert.Equal(t, "secret sauce", reports[0].recovered)
+			assert.Contains(t, reports[0].stack, "panickyHandler")
+
+			require.Equal(t, 1, logs.Len())
+			entry := logs.All()[0]
+			assert.Equal(t, tt.rpcType.String()+" handler panicked", entry.Message)
+			fields := entry.ContextMap()
+			assert.Equal(t, "get", fields["procedure"])
+			assert.Equal(t, "caller", fields["caller"])
+			assert.Equal(t, "secret sauce", fields["panic"])
+			assert.Contains(t, fields["stack"], "panickyHandler")
+
+			assert.Equal(t, []metrics.Snapshot{{
+				Name:  "panics",
+				Tags:  metrics.Tags{"procedure": "get"},
+				Value: 1,
+			}}, root.Snapshot().Counters)
+		})
+	}
+}
+
+func TestMiddlewareWithoutPanic(t *testing.T) {
+	var called bool
+	m := NewInboundMiddleware(OnPanic(func(context.Context, *transport.RequestMeta, interface{}, []byte) {
+		called = true
+	}))
+	err := m.Handle(context.Background(), &transport.Request{}, &transporttest.FakeResponseWriter{}, failingHandler{})
+	assert.Equal(t, yarpcerrors.NotFoundErrorf("not found"), err)
+	assert.False(t, called, "reporter must not be called without a panic")
+}
+
+func TestMiddlewarePanickyReporter(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	m := NewInboundMiddleware(
+		Logger(zap.New(core)),
+		OnPanic(func(context.Context, *transport.RequestMeta, interface{}, []byte) {
+			panic("reporter is broken")
+		}),
+	)
+	err := m.HandleOneway(context.Background(), &transport.Request{Procedure: "get"}, panickyHandler{})
+	assert.True(t, yarpcerrors.IsInternal(err))
+	require.Equal(t, 2, logs.Len())
+	assert.Equal(t, "panic reporter panicked", logs.All()[1].Message)
+}
+
+type fakeStream struct {
+	ctx     context.Context
+	request *transport.StreamRequest
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeStream) Request() *transport.StreamRequest {
+	return s.request
+}
+
+func (s *fakeStream) SendMessage(context.Context, *transport.StreamMessage) error {
+	return nil
+}
+
+func (s *fakeStream) ReceiveMessage(context.Context) (*transport.StreamMessage, error) {
+	return nil, nil
+}
+
+// crashReporter records the panics it is given, failing with err.
+type crashReporter struct {
+	panics []interface{}
+	stacks []string
+	err    error
+}
+
+func (r *crashReporter) Report(_ context.Context, panicValue interface{}, stackTrace []byte) error {
+	r.panics = append(r.panics, panicValue)
+	r.stacks = append(r.stacks, string(stackTrace))
+	return r.err
+}
+
+func TestMiddlewareCrashReporter(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	reporter := &crashReporter{}
+	m := NewInboundMiddleware(Logger(zap.New(core)), ReportCrashes(reporter))
+
+	req := &transport.Request{Service: "service", Procedure: "get"}
+	err := m.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, panickyHandler{})
+	assert.True(t, yarpcerrors.IsInternal(err))
+	assert.Equal(t, []interface{}{"secret sauce"}, reporter.panics)
+	require.Len(t, reporter.stacks, 1)
+	assert.Contains(t, reporter.stacks[0], "panickyHandler")
+	assert.Equal(t, 1, logs.Len())
+
+	err = m.Handle(context.Background(), req, &transporttest.FakeResponseWriter{}, failingHandler{})
+	assert.True(t, yarpcerrors.IsNotFound(err))
+	assert.Len(t, reporter.panics, 1, "crash reporter must not be called without a panic")
+}
+
+func TestMiddlewareFailingCrashReporter(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	m := NewInboundMiddleware(
+		Logger(zap.New(core)),
+		ReportCrashes(&crashReporter{err: errors.New("reporting service is down")}),
+	)
+	err := m.HandleOneway(context.Background(), &transport.Request{Procedure: "get"}, panickyHandler{})
+	assert.True(t, yarpcerrors.IsInternal(err))
+	require.Equal(t, 2, logs.Len())
+	entry := logs.All()[1]
+	assert.Equal(t, "failed to report panic", entry.Message)
+	assert.Equal(t, "reporting service is down", entry.ContextMap()["error"])
+}
+
+func TestLogReporter(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	require.NoError(t, LogReporter(zap.New(core)).Report(context.Background(), "secret sauce", []byte("stack")))
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	assert.Equal(t, "recovered panic", entry.Message)
+	assert.Equal(t, map[string]interface{}{
+		"panic": "secret sauce",
+		"stack": "stack",
+	}, entry.ContextMap())
+
+	assert.NoError(t, LogReporter(nil).Report(context.Background(), "secret sauce", nil))
+}
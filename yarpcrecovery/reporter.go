@@ -0,0 +1,59 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpcrecovery
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// CrashReporter reports the panics that the middleware recovers, like to a
+// crash reporting service, with the stack of the handler that panicked.
+//
+// Report is called from the goroutine of the handler, before its request
+// fails, so it should not block for long.
+type CrashReporter interface {
+	Report(ctx context.Context, panicValue interface{}, stackTrace []byte) error
+}
+
+type logReporter struct {
+	logger *zap.Logger
+}
+
+// LogReporter returns a CrashReporter that logs every panic to the given
+// logger with its stack, for services that collect crashes from their logs
+// rather than the logs of the middleware.
+func LogReporter(logger *zap.Logger) CrashReporter {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return logReporter{logger: logger}
+}
+
+func (r logReporter) Report(_ context.Context, panicValue interface{}, stackTrace []byte) error {
+	r.logger.Error("recovered panic",
+		zap.String("panic", fmt.Sprint(panicValue)),
+		zap.ByteString("stack", stackTrace),
+	)
+	return nil
+}
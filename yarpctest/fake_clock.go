@@ -0,0 +1,146 @@
+// Copyright (c) 2022 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package yarpctest
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/yarpc/pkg/clock"
+)
+
+var _ clock.Clock = (*FakeClock)(nil)
+
+// FakeClock is a clock.Clock that only advances when told to, for
+// deterministic tests of peer lists and other components that accept a
+// clock.
+//
+// 	clock := yarpctest.NewFakeClock()
+// 	list := roundrobin.New(trans, roundrobin.Clock(clock), roundrobin.SlowStart(time.Minute))
+// 	clock.Add(30 * time.Second)
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a fake clock that starts at an arbitrary, fixed time.
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Unix(1000, 0)}
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Add advances the clock by the duration, calling the functions scheduled
+// with AfterFunc that become due, in order, before returning.
+func (c *FakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+
+	var due []*fakeTimer
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.when.After(now) {
+			pending = append(pending, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].when.Before(due[j].when)
+	})
+	// The functions run without the lock, so they may use the clock.
+	for _, t := range due {
+		t.f()
+	}
+}
+
+// AfterFunc schedules f to be called once the clock has advanced by the
+// duration.
+// Unlike time.AfterFunc, f runs in the goroutine that advances the clock.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	f     func()
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// NewRandSource returns a seeded source of randomness for the peer lists
+// that accept one, which is safe for concurrent use so that several lists
+// may share it.
+//
+// 	list := randpeer.New(trans, randpeer.Source(yarpctest.NewRandSource(0)))
+func NewRandSource(seed int64) rand.Source {
+	return &lockedSource{src: rand.NewSource(seed)}
+}
+
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.src.Seed(seed)
+}